@@ -0,0 +1,112 @@
+// One exported symbol, as the go/ast helper's api query lists it
+export interface ApiEntry {
+    // Package directory relative to the module root; "." for the root package
+    package: string;
+    packageName: string;
+    kind: 'func' | 'method' | 'type' | 'field' | 'interface method' | 'var' | 'const';
+    // Receiver- or type-qualified for methods and fields: Store.Get
+    name: string;
+    // Signature, field or variable type, or a type's definition
+    detail: string;
+    pointer?: boolean;
+    file: string;
+    line: number;
+    column: number;
+}
+
+export interface ApiChange {
+    change: 'removed' | 'changed' | 'added';
+    breaking: boolean;
+    // Import path of the package
+    package: string;
+    kind: ApiEntry['kind'] | 'package';
+    name: string;
+    before?: string;
+    after?: string;
+    message: string;
+    // Where the symbol is now, or was in the base version when removed
+    file?: string;
+    line?: number;
+}
+
+const key = (e: ApiEntry) => `${e.package}\0${e.kind}\0${e.name}`;
+
+function describe(entry: ApiEntry): string {
+    return entry.detail || '(untyped)';
+}
+
+/**
+ * Compare two versions of a module's exported API. Removing a symbol or
+ * changing its signature or type breaks callers; so does adding a method
+ * to an existing interface (it breaks implementations elsewhere) and
+ * moving a method to a pointer receiver (values lose it). Everything
+ * else added is compatible.
+ */
+export function diffApi(base: ApiEntry[], head: ApiEntry[], modulePath: string): ApiChange[] {
+    const importPath = (dir: string) => (dir === '.' ? modulePath : `${modulePath}/${dir}`);
+    const before = new Map(base.map(e => [key(e), e]));
+    const after = new Map(head.map(e => [key(e), e]));
+    const basePackages = new Set(base.map(e => e.package));
+    const headPackages = new Set(head.map(e => e.package));
+    const addedPackages = new Set<string>();
+    const changes: ApiChange[] = [];
+
+    const removedPackages = new Set<string>();
+    for (const entry of base) {
+        if (after.has(key(entry))) continue;
+        if (!headPackages.has(entry.package)) {
+            // One change for a package that is gone, not one per symbol
+            if (removedPackages.has(entry.package)) continue;
+            removedPackages.add(entry.package);
+            changes.push({ change: 'removed', breaking: true, package: importPath(entry.package), kind: 'package', name: entry.packageName, message: `package ${importPath(entry.package)} was removed`, file: entry.file, line: 1 });
+            continue;
+        }
+        changes.push({
+            change: 'removed', breaking: true, package: importPath(entry.package), kind: entry.kind, name: entry.name, before: entry.detail,
+            message: `${entry.kind} ${entry.packageName}.${entry.name} was removed`, file: entry.file, line: entry.line,
+        });
+    }
+
+    for (const entry of head) {
+        const old = before.get(key(entry));
+        const symbol = `${entry.packageName}.${entry.name}`;
+        const where = { package: importPath(entry.package), kind: entry.kind, name: entry.name, file: entry.file, line: entry.line };
+        if (!old) {
+            const owner = entry.name.split('.')[0]!;
+            // A new package or type is one addition, not one per member
+            if (!basePackages.has(entry.package)) {
+                if (addedPackages.has(entry.package)) continue;
+                addedPackages.add(entry.package);
+                changes.push({ change: 'added', breaking: false, ...where, kind: 'package', name: entry.packageName, message: `package ${importPath(entry.package)} was added` });
+                continue;
+            }
+            if (entry.kind !== 'type' && entry.name.includes('.') && !before.has(`${entry.package}\0type\0${owner}`)) continue;
+            const interfaceGrew = entry.kind === 'interface method' && before.has(`${entry.package}\0type\0${owner}`);
+            changes.push({
+                change: 'added', breaking: interfaceGrew, ...where, after: entry.detail,
+                message: interfaceGrew
+                    ? `interface ${entry.packageName}.${owner} gained ${entry.detail === 'embedded' ? `embedded ${entry.name.slice(owner.length + 1)}` : `method ${entry.name.slice(owner.length + 1)}`}, which its implementations outside the package lack`
+                    : `${entry.kind} ${symbol} was added`,
+            });
+        } else if (old.detail !== entry.detail) {
+            changes.push({ change: 'changed', breaking: true, ...where, before: old.detail, after: entry.detail, message: `${entry.kind} ${symbol} changed from ${describe(old)} to ${describe(entry)}` });
+        } else if (!old.pointer && entry.pointer) {
+            changes.push({ change: 'changed', breaking: true, ...where, before: 'value receiver', after: 'pointer receiver', message: `method ${symbol} moved to a pointer receiver, so ${entry.packageName}.${entry.name.split('.')[0]} values no longer have it` });
+        }
+    }
+    return changes.sort((a, b) => Number(b.breaking) - Number(a.breaking) || a.package.localeCompare(b.package) || a.name.localeCompare(b.name));
+}
+
+/**
+ * The semantic version the change calls for after current (v1.4.2): a new
+ * major version for breaking changes (a new minor one before v1), a minor
+ * one for additions, else a patch. Null when current is not a version.
+ */
+export function nextVersion(current: string | null, breaking: boolean, additions: boolean): string | null {
+    const match = current ? /^v(\d+)\.(\d+)\.(\d+)(?:[-+].*)?$/.exec(current) : null;
+    if (!match) return null;
+    const [major, minor, patch] = [Number(match[1]), Number(match[2]), Number(match[3])];
+    if (breaking) return major === 0 ? `v0.${minor + 1}.0` : `v${major + 1}.0.0`;
+    if (additions) return `v${major}.${minor + 1}.0`;
+    return `v${major}.${minor}.${patch + 1}`;
+}
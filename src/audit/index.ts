@@ -0,0 +1,210 @@
+import { createHash, randomUUID } from 'crypto';
+import { promises as fs } from 'fs';
+import { homedir } from 'os';
+import { dirname, join, resolve } from 'path';
+import { AsyncLocalStorage } from 'async_hooks';
+import { scanForSecrets } from '../utils/secrets.js';
+import { logger } from '../utils/logger.js';
+
+export type AuditStatus = 'success' | 'failure' | 'rejected' | 'cancelled' | 'error';
+
+export interface AuditFileChange {
+    path: string;
+    action: 'write' | 'delete' | 'move' | 'copy';
+    // Content hash after the change; absent for deletes
+    sha256?: string;
+    bytes?: number;
+    // Source of a move or copy
+    from?: string;
+}
+
+export interface AuditEntry {
+    id: string;
+    timestamp: string;
+    tool: string;
+    args: Record<string, unknown>;
+    durationMs: number;
+    // success/failure is the tool's own verdict; rejected calls never ran; cancelled ones were stopped; error means the tool threw
+    status: AuditStatus;
+    errors?: string[];
+    workspace?: string;
+    mutating: boolean;
+    cached?: boolean;
+    limitExceeded?: string[];
+    files: AuditFileChange[];
+}
+
+export interface AuditQuery {
+    tool?: string;
+    status?: AuditStatus;
+    // Entries whose arguments or changed files are at or under this path
+    path?: string;
+    since?: string;
+    until?: string;
+    mutatingOnly?: boolean;
+    limit?: number;
+}
+
+// Longer string arguments (file contents, patches) are logged as a hash
+const MAX_ARG_LENGTH = 2000;
+const MAX_ERRORS = 5;
+const SECRET_KEYS = /(token|password|passwd|secret|authorization|api[-_]?key|cookie)/i;
+
+const changesContext = new AsyncLocalStorage<AuditFileChange[]>();
+
+export function sha256(content: string | Buffer): string {
+    return createHash('sha256').update(content).digest('hex');
+}
+
+/**
+ * Record a file change made by the current tool call. A no-op outside an
+ * audited call. Without content, a written file is read back to hash it.
+ */
+export async function recordFileChange(path: string, action: AuditFileChange['action'], content?: string | Buffer, from?: string): Promise<void> {
+    const changes = changesContext.getStore();
+    if (!changes) return;
+    const change: AuditFileChange = { path: resolve(path), action, ...(from ? { from: resolve(from) } : {}) };
+    if (action !== 'delete') {
+        const data = content ?? await fs.readFile(path).catch(() => undefined);
+        if (data !== undefined) {
+            change.sha256 = sha256(data);
+            change.bytes = Buffer.byteLength(data);
+        }
+    }
+    changes.push(change);
+}
+
+/**
+ * Arguments as logged: secret-looking values are redacted and long strings
+ * replaced by their hash and length
+ */
+export function sanitizeArgs(value: unknown, key = ''): any {
+    if (typeof value === 'string') {
+        if (SECRET_KEYS.test(key) || scanForSecrets(value).length > 0) return '[redacted]';
+        return value.length > MAX_ARG_LENGTH ? `[${value.length} chars, sha256:${sha256(value)}]` : value;
+    }
+    if (Array.isArray(value)) return value.map(item => sanitizeArgs(item, key));
+    if (value && typeof value === 'object') {
+        return Object.fromEntries(Object.entries(value).map(([k, v]) => [k, sanitizeArgs(v, k)]));
+    }
+    return value;
+}
+
+function entryPaths(entry: AuditEntry): string[] {
+    const paths = entry.files.flatMap(f => f.from ? [f.path, f.from] : [f.path]);
+    for (const value of Object.values(entry.args)) {
+        if (typeof value === 'string' && value.startsWith('/')) paths.push(value);
+    }
+    return paths;
+}
+
+/**
+ * Location of the audit log: MCP_AUDIT_LOG or ~/.local/state/code-feedback/audit.jsonl
+ */
+export function getAuditLogPath(): string {
+    return process.env.MCP_AUDIT_LOG || join(homedir(), '.local', 'state', 'code-feedback', 'audit.jsonl');
+}
+
+/**
+ * One in-flight tool call. finish() writes its entry; file changes recorded
+ * inside run() are attached to it.
+ */
+export class AuditRecord {
+    public readonly files: AuditFileChange[] = [];
+    private readonly startedAt = Date.now();
+    private readonly entry: Pick<AuditEntry, 'id' | 'timestamp' | 'tool' | 'args'>;
+    private readonly log: AuditLog;
+
+    constructor(log: AuditLog, tool: string, args: Record<string, unknown>, id: string = randomUUID()) {
+        this.log = log;
+        this.entry = { id, timestamp: new Date(this.startedAt).toISOString(), tool, args: sanitizeArgs(args) };
+    }
+
+    public run<T>(fn: () => Promise<T>): Promise<T> {
+        return changesContext.run(this.files, fn);
+    }
+
+    public finish(status: AuditStatus, details: { errors?: string[]; workspace?: string | null; mutating?: boolean; cached?: boolean; limitExceeded?: string[] } = {}): Promise<void> {
+        const errors = (details.errors ?? []).slice(0, MAX_ERRORS).map(e => String(e).slice(0, MAX_ARG_LENGTH));
+        return this.log.append({
+            ...this.entry,
+            durationMs: Date.now() - this.startedAt,
+            status,
+            ...(errors.length > 0 ? { errors } : {}),
+            ...(details.workspace ? { workspace: details.workspace } : {}),
+            mutating: Boolean(details.mutating) || this.files.length > 0,
+            ...(details.cached ? { cached: true } : {}),
+            ...(details.limitExceeded && details.limitExceeded.length > 0 ? { limitExceeded: details.limitExceeded } : {}),
+            files: this.files,
+        });
+    }
+}
+
+/**
+ * Append-only JSONL record of every tool call, so what an agent did to a
+ * codebase can be reconstructed afterwards. MCP_AUDIT=off disables it.
+ */
+export class AuditLog {
+    private writes: Promise<void> = Promise.resolve();
+
+    public isEnabled(): boolean {
+        return process.env.MCP_AUDIT !== 'off';
+    }
+
+    // id is the call's correlation id, so audit entries join the server logs
+    public start(tool: string, args: Record<string, unknown>, id?: string): AuditRecord {
+        return new AuditRecord(this, tool, args, id);
+    }
+
+    public append(entry: AuditEntry): Promise<void> {
+        if (!this.isEnabled()) return Promise.resolve();
+        const path = getAuditLogPath();
+        // Serialized so concurrent calls never interleave partial lines
+        this.writes = this.writes
+            .then(async () => {
+                await fs.mkdir(dirname(path), { recursive: true });
+                await fs.appendFile(path, JSON.stringify(entry) + '\n', { mode: 0o600 });
+            })
+            .catch(error => logger.error('Failed to write audit log', { path, error }));
+        return this.writes;
+    }
+
+    /**
+     * Matching entries, newest first
+     */
+    public async query(filter: AuditQuery = {}): Promise<{ entries: AuditEntry[]; total: number }> {
+        await this.writes;
+        let raw: string;
+        try {
+            raw = await fs.readFile(getAuditLogPath(), 'utf-8');
+        } catch (error: any) {
+            if (error.code === 'ENOENT') return { entries: [], total: 0 };
+            throw error;
+        }
+        const since = filter.since ? Date.parse(filter.since) : undefined;
+        const until = filter.until ? Date.parse(filter.until) : undefined;
+        const path = filter.path ? resolve(filter.path) : undefined;
+        const matching: AuditEntry[] = [];
+        for (const line of raw.split('\n')) {
+            if (!line.trim()) continue;
+            let entry: AuditEntry;
+            try {
+                entry = JSON.parse(line);
+            } catch {
+                continue;
+            }
+            const time = Date.parse(entry.timestamp);
+            if (filter.tool && entry.tool !== filter.tool) continue;
+            if (filter.status && entry.status !== filter.status) continue;
+            if (filter.mutatingOnly && !entry.mutating) continue;
+            if (since !== undefined && time < since) continue;
+            if (until !== undefined && time > until) continue;
+            if (path && !entryPaths(entry).some(p => p === path || p.startsWith(`${path}/`))) continue;
+            matching.push(entry);
+        }
+        matching.reverse();
+        return { entries: matching.slice(0, filter.limit ?? 100), total: matching.length };
+    }
+}
+
+export const auditLog = new AuditLog();
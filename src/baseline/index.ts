@@ -0,0 +1,188 @@
+import { createHash } from 'crypto';
+import { promises as fs } from 'fs';
+import { dirname, isAbsolute, relative, resolve, sep } from 'path';
+import type { EffectiveConfig } from '../config/project.js';
+import { normalizeMessage, type Diagnostic, type DiagnosticSeverity } from '../diagnostics/index.js';
+import { SourceLines } from '../diagnostics/sources.js';
+import { withoutFindings, type FindingsOutcome } from '../diagnostics/suppressions.js';
+
+export const BASELINE_FILE = '.code-feedback-baseline.json';
+export const DEFAULT_LINE_TOLERANCE = 10;
+
+export interface BaselineEntry {
+    // Relative to the baseline file's directory, with forward slashes
+    file: string;
+    line: number;
+    severity: DiagnosticSeverity;
+    source: string;
+    rule?: string;
+    message: string;
+    // Hash of the flagged line's text, so a finding still matches after moving further than lineTolerance
+    lineHash?: string;
+}
+
+export interface Baseline {
+    version: 1;
+    createdAt: string;
+    commit: string | null;
+    entries: BaselineEntry[];
+}
+
+
+function relativeTo(root: string, file: string): string {
+    const rel = isAbsolute(file) ? relative(root, file) : file;
+    return rel.split(sep).join('/');
+}
+
+function entryKey(file: string, d: { source: string; rule?: string | undefined; message: string }): string {
+    return [file, d.source, d.rule ?? '', normalizeMessage(d.message)].join('\0');
+}
+
+function hashLine(text: string): string {
+    return createHash('sha256').update(text.trim()).digest('hex').slice(0, 16);
+}
+
+async function lineHash(lines: SourceLines, file: string, line: number): Promise<string | undefined> {
+    const text = await lines.line(file, line);
+    return text?.trim() ? hashLine(text) : undefined;
+}
+
+/**
+ * Snapshot findings as a baseline, sorted by location so the file diffs
+ * well when it is regenerated
+ */
+export async function createBaseline(root: string, diagnostics: Diagnostic[], commit: string | null = null): Promise<Baseline> {
+    const lines = new SourceLines(root);
+    const entries: BaselineEntry[] = [];
+    for (const d of diagnostics) {
+        const file = relativeTo(root, d.file);
+        const hash = await lineHash(lines, file, d.line);
+        entries.push({
+            file,
+            line: d.line,
+            severity: d.severity,
+            source: d.source,
+            ...(d.rule ? { rule: d.rule } : {}),
+            message: d.message,
+            ...(hash ? { lineHash: hash } : {}),
+        });
+    }
+    entries.sort((a, b) => a.file.localeCompare(b.file) || a.line - b.line || a.source.localeCompare(b.source));
+    return { version: 1, createdAt: new Date().toISOString(), commit, entries };
+}
+
+/**
+ * Read a baseline file; null when there is none
+ */
+export async function loadBaseline(path: string): Promise<Baseline | null> {
+    let raw: string;
+    try {
+        raw = await fs.readFile(path, 'utf-8');
+    } catch (error: any) {
+        if (error.code === 'ENOENT') return null;
+        throw error;
+    }
+    return parseBaseline(raw, path);
+}
+
+/**
+ * Parse baseline file contents; path only names the file in errors
+ */
+export function parseBaseline(raw: string, path: string): Baseline {
+    let baseline: Baseline;
+    try {
+        baseline = JSON.parse(raw);
+    } catch (error: any) {
+        throw new Error(`Invalid baseline file ${path}: ${error.message}`);
+    }
+    if (baseline?.version !== 1 || !Array.isArray(baseline.entries)) {
+        throw new Error(`Invalid baseline file ${path}: expected version 1 with entries`);
+    }
+    return baseline;
+}
+
+/**
+ * Baseline file of a workspace: baseline.file relative to the project
+ * config, or .code-feedback-baseline.json at the workspace root
+ */
+export function baselinePath(effective: EffectiveConfig, root: string): string {
+    return resolve(effective.workspaceRoot ?? root, effective.config.baseline?.file ?? BASELINE_FILE);
+}
+
+/**
+ * Filters findings already in a baseline. A finding matches an unused entry
+ * with the same file, tool, rule and message (numbers aside) when its line
+ * moved by at most lineTolerance, or anywhere in the file when the flagged
+ * line's text is unchanged.
+ */
+export class BaselineMatcher {
+    private readonly root: string;
+    private readonly lineTolerance: number;
+    private readonly entries = new Map<string, BaselineEntry[]>();
+    private readonly lines: SourceLines;
+
+    constructor(baseline: Baseline, root: string, lineTolerance = DEFAULT_LINE_TOLERANCE) {
+        this.root = root;
+        this.lineTolerance = lineTolerance;
+        for (const entry of baseline.entries) {
+            const key = entryKey(entry.file, entry);
+            this.entries.set(key, [...(this.entries.get(key) ?? []), entry]);
+        }
+        this.lines = new SourceLines(root);
+    }
+
+    public get size(): number {
+        let size = 0;
+        for (const list of this.entries.values()) size += list.length;
+        return size;
+    }
+
+    public async filter(diagnostics: Diagnostic[]): Promise<{ kept: Diagnostic[]; suppressed: Diagnostic[] }> {
+        const kept: Diagnostic[] = [];
+        const suppressed: Diagnostic[] = [];
+        // An entry stands for one finding; repeated findings take entries in line order
+        const used = new Set<BaselineEntry>();
+        const ordered = [...diagnostics].sort((a, b) => a.line - b.line);
+        const matched = new Set<Diagnostic>();
+        for (const d of ordered) {
+            const file = relativeTo(this.root, d.file);
+            const candidates = (this.entries.get(entryKey(file, d)) ?? []).filter(entry => !used.has(entry));
+            if (candidates.length === 0) continue;
+            const hash = await lineHash(this.lines, file, d.line);
+            const distance = (entry: BaselineEntry) => Math.abs(entry.line - d.line);
+            const sameLine = (entry: BaselineEntry) => hash !== undefined && entry.lineHash === hash;
+            const match = candidates
+                .filter(entry => distance(entry) <= this.lineTolerance || sameLine(entry))
+                .sort((a, b) => Number(sameLine(b)) - Number(sameLine(a)) || distance(a) - distance(b))[0];
+            if (!match) continue;
+            used.add(match);
+            matched.add(d);
+        }
+        for (const d of diagnostics) (matched.has(d) ? suppressed : kept).push(d);
+        return { kept, suppressed };
+    }
+
+    /**
+     * Drop baselined findings from a step or tool result. A failure that only
+     * baselined errors explain becomes a pass; failed tests still fail it.
+     */
+    public async apply<T extends FindingsOutcome>(outcome: T): Promise<T & { baselined?: number }> {
+        if (!outcome.diagnostics || outcome.diagnostics.length === 0) return outcome;
+        const { kept, suppressed } = await this.filter(outcome.diagnostics);
+        if (suppressed.length === 0) return outcome;
+        return { ...withoutFindings(outcome, kept, suppressed, `${suppressed.length} finding(s) in the baseline left out`), baselined: suppressed.length };
+    }
+}
+
+/**
+ * The baseline that applies to a workspace, unless it has none or the
+ * project config turns it off
+ */
+export async function openBaseline(effective: EffectiveConfig, root: string): Promise<{ path: string; matcher: BaselineMatcher } | null> {
+    if (effective.config.baseline?.enabled === false) return null;
+    const path = baselinePath(effective, root);
+    const baseline = await loadBaseline(path);
+    if (!baseline) return null;
+    // Entries are relative to the directory the baseline lives in
+    return { path, matcher: new BaselineMatcher(baseline, dirname(path), effective.config.baseline?.lineTolerance) };
+}
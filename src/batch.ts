@@ -0,0 +1,236 @@
+import { promises as fs } from 'fs';
+import { createServer as createHttpServer, type Server as HttpServer } from 'http';
+import { dirname, resolve } from 'path';
+import Config from './config/index.js';
+import { toSarif } from './diagnostics/sarif.js';
+import { toJUnitXml } from './diagnostics/junit.js';
+import { exportSarifTool } from './tools/sarif.js';
+import { exportJUnitTool } from './tools/junit.js';
+import { formatHealthReport, runHealthCheck } from './tools/health.js';
+import { runPipelineTool } from './tools/pipeline.js';
+import { watchWorkspaceTool } from './tools/watch.js';
+import { parseListenAddress } from './transport/http.js';
+import { logger } from './utils/logger.js';
+import { watchManager, type WatchManager, type WatchRun } from './watch/index.js';
+import type { DoctorOptions, ExportSarifOptions, RunOptions, WatchOptions } from './cli.js';
+
+// Paths named on the command line are the caller's to use
+function allowCliPaths(path: string, files: Array<string | undefined>): void {
+  Config.getInstance().addAllowedPaths([path, ...files.filter((f): f is string => f !== undefined).map(f => dirname(f))]);
+}
+
+async function writeResult(file: string | undefined, content: string): Promise<void> {
+  if (!file) {
+    process.stdout.write(content);
+    return;
+  }
+  await fs.mkdir(dirname(file), { recursive: true });
+  await fs.writeFile(file, content);
+}
+
+/**
+ * Run a pipeline once, outside MCP, for CI and git hooks: print the results
+ * as text, JSON or SARIF, and return the exit code (0 passed, 1 failed, 2
+ * when the pipeline could not run)
+ */
+export async function runBatch(options: RunOptions): Promise<number> {
+  // Progress logs would clutter hook and CI output; MCP_LOG_LEVEL still wins
+  if (!process.env.MCP_LOG_LEVEL) logger.setLevel('warn');
+  const path = resolve(options.path);
+  const output = options.output ? resolve(options.output) : undefined;
+  const junitOut = options.junitOut ? resolve(options.junitOut) : undefined;
+  allowCliPaths(path, [output, junitOut]);
+
+  const result: any = await runPipelineTool.run({ path, pipeline: options.pipeline });
+  if (!Array.isArray(result.steps)) {
+    for (const error of result.errors ?? []) console.error(error);
+    return 2;
+  }
+  if (options.format === 'json') {
+    await writeResult(output, JSON.stringify(result, null, 2) + '\n');
+  } else if (options.format === 'sarif') {
+    await writeResult(output, JSON.stringify(toSarif(result.diagnostics, { root: path }), null, 2) + '\n');
+  } else {
+    const lines = [result.verdict.summary, result.output];
+    if (result.errors.length > 0) lines.push('', ...result.errors.map((e: string) => `error: ${e}`));
+    if (result.warnings.length > 0) lines.push('', ...result.warnings.map((w: string) => `warning: ${w}`));
+    await writeResult(output, lines.join('\n') + '\n');
+  }
+  // Text already carries the verdict; other formats get it on stderr
+  if (options.format !== 'text' || output) console.error(result.verdict.summary);
+  if (junitOut) await writeResult(junitOut, toJUnitXml(result.tests?.results ?? []));
+  return result.verdict.passed ? 0 : 1;
+}
+
+// What a watch run event carries; the full result is at /latest
+function runEvent(run: WatchRun) {
+  const { result: _result, ...event } = run;
+  return event;
+}
+
+/**
+ * Serve watch runs over HTTP: GET /events streams each run as a server-sent
+ * "run" event (the latest run of every watch first), GET /latest returns the
+ * latest run of every watch with its full result. Binds to localhost unless
+ * the address names a host, since nothing is authenticated.
+ */
+export async function serveWatchEvents(address: string, manager: WatchManager = watchManager): Promise<HttpServer> {
+  const { host = '127.0.0.1', port } = parseListenAddress(address);
+  const server = createHttpServer((req, res) => {
+    const path = (req.url ?? '/').split('?')[0];
+    if (req.method !== 'GET' || (path !== '/events' && path !== '/latest')) {
+      res.writeHead(404, { 'Content-Type': 'application/json' }).end(JSON.stringify({ error: 'Not found' }));
+      return;
+    }
+    const latest = manager.list().flatMap(session => session.latest ? [session.latest] : []);
+    if (path === '/latest') {
+      res.writeHead(200, { 'Content-Type': 'application/json' }).end(JSON.stringify({ runs: latest }));
+      return;
+    }
+    res.writeHead(200, { 'Content-Type': 'text/event-stream', 'Cache-Control': 'no-cache', Connection: 'keep-alive' });
+    const send = (run: WatchRun) => res.write(`event: run\ndata: ${JSON.stringify(runEvent(run))}\n\n`);
+    latest.forEach(send);
+    const unsubscribe = manager.subscribe(send);
+    res.on('close', unsubscribe);
+  });
+  await new Promise<void>((resolveListen, reject) => {
+    server.once('error', reject);
+    server.listen(port, host, () => resolveListen());
+  });
+  return server;
+}
+
+function formatWatchRun(run: WatchRun): string {
+  const time = new Date(run.startedAt).toLocaleTimeString();
+  const changed = run.trigger === 'change' ? `: ${run.changedFiles.join(', ')}` : '';
+  const lines = [`[${time}] run ${run.runNumber} (${run.trigger}${changed})`, run.verdict.summary];
+  for (const issue of run.verdict.blocking) {
+    lines.push(`  ${issue.step}: ${issue.file ? `${issue.file}:${issue.line} ` : ''}${issue.message}`);
+  }
+  if (run.verdict.moreBlocking > 0) lines.push(`  ...and ${run.verdict.moreBlocking} more`);
+  return lines.join('\n') + '\n\n';
+}
+
+/**
+ * Watch a project until interrupted, printing each run's verdict (and
+ * streaming runs over HTTP with --http); returns the exit code, 1 when the
+ * last run failed
+ */
+export async function watchBatch(options: WatchOptions): Promise<number> {
+  if (!process.env.MCP_LOG_LEVEL) logger.setLevel('warn');
+  const path = resolve(options.path);
+  allowCliPaths(path, []);
+
+  const unsubscribe = watchManager.subscribe(run => process.stdout.write(formatWatchRun(run)));
+  const started: any = await watchWorkspaceTool.run({
+    action: 'start',
+    path,
+    pipeline: options.pipeline,
+    ...(options.debounceMs !== undefined ? { debounceMs: options.debounceMs } : {}),
+  });
+  if (!started.success) {
+    unsubscribe();
+    for (const error of started.errors) console.error(error);
+    return 2;
+  }
+  let events: HttpServer | undefined;
+  if (options.http) {
+    events = await serveWatchEvents(options.http);
+    const bound = events.address();
+    if (bound && typeof bound === 'object') console.error(`Streaming runs at http://${bound.address}:${bound.port}/events`);
+  }
+  console.error(`${started.output}; press Ctrl-C to stop`);
+
+  await new Promise<void>(resolveStop => {
+    process.once('SIGINT', resolveStop);
+    process.once('SIGTERM', resolveStop);
+  });
+  const latest = watchManager.get(started.watch.id)?.latest;
+  unsubscribe();
+  watchManager.stopAll();
+  if (events) {
+    events.closeAllConnections();
+    events.close();
+  }
+  return latest && !latest.verdict.passed ? 1 : 0;
+}
+
+/**
+ * Convert a pipeline's diagnostics (or a diagnostics file) to SARIF, and its
+ * test results to JUnit XML, for CI uploads; returns the exit code
+ */
+export async function exportSarif(options: ExportSarifOptions): Promise<number> {
+  const path = resolve(options.path);
+  const output = options.output ? resolve(options.output) : undefined;
+  const junitOut = options.junitOut ? resolve(options.junitOut) : undefined;
+  allowCliPaths(path, [output, junitOut]);
+
+  let diagnostics: unknown;
+  let tests: unknown;
+  if (options.input) {
+    const text = options.input === '-' ? await readStdin() : await fs.readFile(resolve(options.input), 'utf-8');
+    const parsed = JSON.parse(text);
+    // A bare array, or any tool result with a diagnostics field
+    diagnostics = Array.isArray(parsed) ? parsed : parsed?.diagnostics;
+    tests = parsed?.tests?.results;
+    if (!Array.isArray(diagnostics)) {
+      console.error(`${options.input}: expected a JSON array of diagnostics or an object with a diagnostics array`);
+      return 1;
+    }
+  } else if (junitOut) {
+    // Both reports come from the same run
+    const run: any = await runPipelineTool.run({ path, pipeline: options.pipeline });
+    if (!Array.isArray(run.steps)) {
+      for (const error of run.errors ?? []) console.error(error);
+      return 1;
+    }
+    console.error(run.verdict.summary);
+    diagnostics = run.diagnostics;
+    tests = run.tests?.results ?? [];
+  }
+  const result: any = await exportSarifTool.run({
+    path,
+    ...(diagnostics ? { diagnostics } : { pipeline: options.pipeline }),
+    ...(output ? { outputFile: output } : {}),
+  });
+  for (const warning of result.warnings ?? []) console.error(`warning: ${warning}`);
+  if (!result.success) {
+    for (const error of result.errors ?? []) console.error(error);
+    return 1;
+  }
+  if (output) console.error(result.output);
+  else process.stdout.write(JSON.stringify(result.sarif, null, 2) + '\n');
+
+  if (junitOut) {
+    if (!Array.isArray(tests)) {
+      console.error(`${options.input}: has no tests.results to write to ${options.junitOut}`);
+      return 1;
+    }
+    const junit: any = await exportJUnitTool.run({ path, tests, outputFile: junitOut });
+    if (!junit.success) {
+      for (const error of junit.errors ?? []) console.error(error);
+      return 1;
+    }
+    console.error(junit.output);
+  }
+  return 0;
+}
+
+/**
+ * Print the readiness report (config, tool binaries, workspace permissions)
+ * for the environment the server would start in; returns 0 when ready and
+ * 1 when a check failed
+ */
+export async function runDoctor(options: DoctorOptions): Promise<number> {
+  const path = options.path ? resolve(options.path) : undefined;
+  if (path) allowCliPaths(path, []);
+  const report = await runHealthCheck(path ? { path } : {});
+  process.stdout.write((options.format === 'json' ? JSON.stringify(report, null, 2) : formatHealthReport(report)) + '\n');
+  return report.ready ? 0 : 1;
+}
+
+async function readStdin(): Promise<string> {
+  const chunks: Buffer[] = [];
+  for await (const chunk of process.stdin) chunks.push(chunk as Buffer);
+  return Buffer.concat(chunks).toString('utf-8');
+}
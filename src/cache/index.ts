@@ -0,0 +1,180 @@
+import { createHash } from 'crypto';
+import { promises as fs } from 'fs';
+import { dirname, join, resolve } from 'path';
+import Config, { isWithin } from '../config/index.js';
+import { PATH_ARG_KEYS } from '../utils/paths.js';
+
+// Directories that never influence feedback results
+const SKIPPED_DIRS = new Set(['node_modules', '.git', 'dist', 'build', 'target', '.venv', 'venv', '__pycache__', '.mypy_cache', '.pytest_cache']);
+
+// Files that mark a project root; everything below one can change results for any file inside it
+const PROJECT_MARKERS = ['go.mod', 'go.work', 'package.json', 'tsconfig.json', 'Cargo.toml', 'pyproject.toml', 'setup.py', 'setup.cfg', 'pom.xml', 'build.gradle'];
+
+const MAX_HASHED_FILES = 5000;
+const MAX_ENTRIES = 200;
+
+interface FileHashEntry {
+    mtimeMs: number;
+    size: number;
+    hash: string;
+}
+
+/**
+ * Caches tool results keyed by tool name, arguments, and the content hashes
+ * of the files the arguments point at. Any file change produces a new key,
+ * so stale results are never served.
+ */
+export class ResultCache {
+    private entries = new Map<string, unknown>();
+    // tool+args -> latest full key, so superseded results are dropped eagerly
+    private latestKeys = new Map<string, string>();
+    // Content hashes are reused while a file's mtime and size stay the same
+    private fileHashes = new Map<string, FileHashEntry>();
+    private hits = 0;
+    private misses = 0;
+
+    public get(key: string): unknown | undefined {
+        const value = this.entries.get(key);
+        if (value === undefined) {
+            this.misses++;
+            return undefined;
+        }
+        this.hits++;
+        // Refresh LRU position
+        this.entries.delete(key);
+        this.entries.set(key, value);
+        return value;
+    }
+
+    public set(key: string, value: unknown): void {
+        const baseKey = key.split('\0')[0] || key;
+        const previous = this.latestKeys.get(baseKey);
+        if (previous && previous !== key) {
+            this.entries.delete(previous);
+        }
+        this.latestKeys.set(baseKey, key);
+        this.entries.set(key, value);
+        while (this.entries.size > MAX_ENTRIES) {
+            const oldest = this.entries.keys().next().value;
+            if (oldest === undefined) break;
+            this.entries.delete(oldest);
+        }
+    }
+
+    public clear(): void {
+        this.entries.clear();
+        this.latestKeys.clear();
+    }
+
+    public getStats(): { entries: number; hits: number; misses: number } {
+        return { entries: this.entries.size, hits: this.hits, misses: this.misses };
+    }
+
+    private async hashFile(path: string): Promise<string> {
+        const stats = await fs.stat(path);
+        const known = this.fileHashes.get(path);
+        if (known && known.mtimeMs === stats.mtimeMs && known.size === stats.size) {
+            return known.hash;
+        }
+        const hash = createHash('sha256').update(await fs.readFile(path)).digest('hex');
+        this.fileHashes.set(path, { mtimeMs: stats.mtimeMs, size: stats.size, hash });
+        return hash;
+    }
+
+    // Hash every file under dir; returns false when the tree is too large to hash cheaply
+    private async hashTree(dir: string, hash: ReturnType<typeof createHash>, budget: { files: number }): Promise<boolean> {
+        const entries = await fs.readdir(dir, { withFileTypes: true });
+        entries.sort((a, b) => a.name.localeCompare(b.name));
+        for (const entry of entries) {
+            const fullPath = join(dir, entry.name);
+            if (entry.isDirectory()) {
+                if (SKIPPED_DIRS.has(entry.name)) continue;
+                if (!(await this.hashTree(fullPath, hash, budget))) return false;
+            } else if (entry.isFile()) {
+                if (--budget.files < 0) return false;
+                hash.update(`${fullPath}\0${await this.hashFile(fullPath)}\0`);
+            }
+        }
+        return true;
+    }
+
+    // Hash the regular files directly inside dir (workspace-level config such as go.work or tsconfig.base.json)
+    private async hashTopLevel(dir: string, hash: ReturnType<typeof createHash>, budget: { files: number }): Promise<boolean> {
+        const entries = await fs.readdir(dir, { withFileTypes: true });
+        entries.sort((a, b) => a.name.localeCompare(b.name));
+        for (const entry of entries) {
+            if (!entry.isFile()) continue;
+            if (--budget.files < 0) return false;
+            const fullPath = join(dir, entry.name);
+            hash.update(`${fullPath}\0${await this.hashFile(fullPath)}\0`);
+        }
+        return true;
+    }
+
+    /**
+     * Hash the project around start: the whole tree of the nearest enclosing project
+     * root (manifests, sibling packages) plus the top-level files of every directory
+     * above it up to the allowed root
+     */
+    private async hashProject(start: string, hash: ReturnType<typeof createHash>, budget: { files: number }): Promise<boolean> {
+        const allowed = Config.getInstance().getResolvedAllowedPaths().filter(root => isWithin(root, start));
+        // The innermost allowed root bounds the walk so nothing outside the sandbox is read
+        const top = allowed.sort((a, b) => b.length - a.length)[0] ?? start;
+        const ancestors: string[] = [];
+        for (let dir = start; ; dir = dirname(dir)) {
+            ancestors.push(dir);
+            if (dir === top || dirname(dir) === dir || !isWithin(top, dirname(dir))) break;
+        }
+        let rootIndex = 0;
+        for (let i = 0; i < ancestors.length; i++) {
+            const entries = await fs.readdir(ancestors[i]!);
+            if (PROJECT_MARKERS.some(marker => entries.includes(marker))) {
+                rootIndex = i;
+                break;
+            }
+        }
+        if (!(await this.hashTree(ancestors[rootIndex]!, hash, budget))) return false;
+        for (const dir of ancestors.slice(rootIndex + 1)) {
+            if (!(await this.hashTopLevel(dir, hash, budget))) return false;
+        }
+        return true;
+    }
+
+    /**
+     * Build the cache key for a tool call, or null when the call cannot be cached
+     */
+    public async computeKey(toolName: string, args: Record<string, unknown>, env: Record<string, string> = {}): Promise<string | null> {
+        // The command environment (from project config) changes results as much as the arguments do
+        const baseKey = `${toolName}:${JSON.stringify(args)}${Object.keys(env).length > 0 ? `:${JSON.stringify(env)}` : ''}`;
+        const hash = createHash('sha256');
+        let hashedAny = false;
+        for (const key of PATH_ARG_KEYS) {
+            const value = args[key];
+            if (typeof value !== 'string') continue;
+            // Never read outside the sandbox just to build a key
+            if (!Config.getInstance().isPathAllowed(value)) return null;
+            try {
+                const abs = resolve(value);
+                const stats = await fs.stat(abs);
+                // A single file's results depend on its whole project (module, package manifest, workspace config)
+                const start = stats.isDirectory() ? abs : dirname(abs);
+                if (!(await this.hashProject(start, hash, { files: MAX_HASHED_FILES }))) return null;
+                hashedAny = true;
+            } catch {
+                return null;
+            }
+        }
+        if (!hashedAny) return null;
+        // JSON never contains a raw NUL, so it safely separates args from file hashes
+        return `${baseKey}\0${hash.digest('hex')}`;
+    }
+}
+
+/**
+ * Whether a tool call's result may be served from the cache
+ */
+export function isCacheableCall(tool: { cacheable?: boolean | ((args: any) => boolean) }, args: Record<string, unknown>): boolean {
+    return typeof tool.cacheable === 'function' ? tool.cacheable(args) : Boolean(tool.cacheable);
+}
+
+export const resultCache = new ResultCache();
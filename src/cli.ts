@@ -0,0 +1,321 @@
+export interface ServeOptions {
+  command: 'serve';
+  // Listen address for the HTTP transport; stdio is used when absent
+  http?: string;
+  token?: string;
+}
+
+export interface ExportSarifOptions {
+  command: 'export-sarif';
+  // Repository root the pipeline runs in and result paths are relative to
+  path: string;
+  pipeline: string;
+  // Diagnostics JSON to convert instead of running the pipeline ("-" reads stdin)
+  input?: string;
+  // SARIF file to write; stdout when absent
+  output?: string;
+  // JUnit XML file for the test results
+  junitOut?: string;
+}
+
+export type RunFormat = 'text' | 'json' | 'sarif';
+
+export interface RunOptions {
+  command: 'run';
+  // Project the pipeline runs against
+  path: string;
+  pipeline: string;
+  format: RunFormat;
+  // File for the formatted results; stdout when absent
+  output?: string;
+  junitOut?: string;
+}
+
+export interface WatchOptions {
+  command: 'watch';
+  path: string;
+  pipeline: string;
+  debounceMs?: number;
+  // Listen address for the run event stream
+  http?: string;
+}
+
+export interface LspOptions {
+  command: 'lsp';
+  // Project to check when the editor sends no root
+  path: string;
+  pipeline: string;
+}
+
+export interface DoctorOptions {
+  command: 'doctor';
+  // Project whose config and enabled tools to check; every allowed root when absent
+  path?: string;
+  format: 'text' | 'json';
+}
+
+export interface HelpOptions {
+  command: 'help';
+}
+
+export type CliOptions = ServeOptions | RunOptions | WatchOptions | LspOptions | ExportSarifOptions | DoctorOptions | HelpOptions;
+
+export const USAGE = `Usage: code-feedback [serve] [options]
+       code-feedback run [pipeline] [options]
+       code-feedback watch [pipeline] [options]
+       code-feedback lsp [pipeline] [options]
+       code-feedback export-sarif [options]
+       code-feedback doctor [options]
+
+Commands:
+  serve                 Start the MCP server (default)
+  run                   Run a pipeline without an MCP client; exits 1 when it fails
+  watch                 Re-run a pipeline whenever project files change
+  lsp                   Serve the pipeline's diagnostics to an editor over the Language Server Protocol (stdio)
+  export-sarif          Run a pipeline and print its diagnostics as SARIF 2.1.0 (batch mode)
+  doctor                Check config, tool binaries and workspace permissions; exits 1 when not ready
+
+Options:
+  --http <address>      Serve MCP over streamable HTTP/SSE instead of stdio (e.g. :8080, 127.0.0.1:8080)
+  --token <token>       Require "Authorization: Bearer <token>" on HTTP requests (default: $MCP_AUTH_TOKEN)
+  -h, --help            Show this help
+
+run options:
+  [pipeline]            Pipeline from .code-feedback.yaml to run (default: default)
+  --path <dir>          Project directory (default: current directory)
+  --format <format>     text, json (the run_pipeline result) or sarif (default: text)
+  --output <file>       Write the results to a file instead of stdout
+  --junit-out <file>    Also write the test results as JUnit XML
+
+watch options:
+  [pipeline]            Pipeline from .code-feedback.yaml to run (default: default)
+  --path <dir>          Project directory (default: current directory)
+  --debounce <ms>       Quiet time after the last change before a run (default: 500)
+  --http <address>      Also stream runs as server-sent events at /events (latest run at /latest)
+
+lsp options:
+  [pipeline]            Pipeline to run on every change (default: default; initializationOptions.pipeline wins)
+  --path <dir>          Project directory when the editor sends no rootUri (default: current directory)
+  --stdio               Accepted for editors that pass it; stdio is the only transport
+
+export-sarif options:
+  --path <dir>          Repository root (default: current directory)
+  --pipeline <name>     Pipeline from .code-feedback.yaml to run (default: default)
+  --input <file>        Convert a JSON array of diagnostics instead of running a pipeline (- for stdin)
+  --output <file>       Write the SARIF log to a file instead of stdout
+  --junit-out <file>    Also write the test results as JUnit XML
+
+doctor options:
+  --path <dir>          Project whose .code-feedback.yaml and enabled tools to check (default: every allowed path)
+  --format <format>     text or json (default: text)
+`;
+
+// --flag=value, or the flag alone
+function splitFlag(arg: string): [string, string | undefined] {
+  return arg.startsWith('--') && arg.includes('=')
+    ? [arg.slice(0, arg.indexOf('=')), arg.slice(arg.indexOf('=') + 1)]
+    : [arg, undefined];
+}
+
+function flagValue(flag: string, inlineValue: string | undefined, args: string[]): () => string {
+  return () => {
+    const next = inlineValue ?? args.shift();
+    if (next === undefined || next === '') throw new Error(`Missing value for ${flag}`);
+    return next;
+  };
+}
+
+function parseRunArgs(args: string[]): RunOptions | HelpOptions {
+  const options: RunOptions = { command: 'run', path: '.', pipeline: 'default', format: 'text' };
+  let positional = false;
+  while (args.length > 0) {
+    const arg = args.shift() as string;
+    const [flag, inlineValue] = splitFlag(arg);
+    const value = flagValue(flag, inlineValue, args);
+    switch (flag) {
+      case '-h':
+      case '--help':
+        return { command: 'help' };
+      case '--path':
+        options.path = value();
+        break;
+      case '--pipeline':
+        options.pipeline = value();
+        break;
+      case '--format': {
+        const format = value();
+        if (format !== 'text' && format !== 'json' && format !== 'sarif') throw new Error(`Invalid --format ${format}: use text, json or sarif`);
+        options.format = format;
+        break;
+      }
+      case '--output':
+        options.output = value();
+        break;
+      case '--junit-out':
+        options.junitOut = value();
+        break;
+      default:
+        if (arg.startsWith('-') || positional) throw new Error(`Unknown argument: ${arg}`);
+        options.pipeline = arg;
+        positional = true;
+    }
+  }
+  return options;
+}
+
+function parseWatchArgs(args: string[]): WatchOptions | HelpOptions {
+  const options: WatchOptions = { command: 'watch', path: '.', pipeline: 'default' };
+  let positional = false;
+  while (args.length > 0) {
+    const arg = args.shift() as string;
+    const [flag, inlineValue] = splitFlag(arg);
+    const value = flagValue(flag, inlineValue, args);
+    switch (flag) {
+      case '-h':
+      case '--help':
+        return { command: 'help' };
+      case '--path':
+        options.path = value();
+        break;
+      case '--pipeline':
+        options.pipeline = value();
+        break;
+      case '--debounce': {
+        const debounce = value();
+        const ms = Number(debounce);
+        if (!Number.isInteger(ms) || ms < 50 || ms > 60000) throw new Error(`Invalid --debounce ${debounce}: use 50 to 60000 milliseconds`);
+        options.debounceMs = ms;
+        break;
+      }
+      case '--http':
+        options.http = value();
+        break;
+      default:
+        if (arg.startsWith('-') || positional) throw new Error(`Unknown argument: ${arg}`);
+        options.pipeline = arg;
+        positional = true;
+    }
+  }
+  return options;
+}
+
+function parseLspArgs(args: string[]): LspOptions | HelpOptions {
+  const options: LspOptions = { command: 'lsp', path: '.', pipeline: 'default' };
+  let positional = false;
+  while (args.length > 0) {
+    const arg = args.shift() as string;
+    const [flag, inlineValue] = splitFlag(arg);
+    const value = flagValue(flag, inlineValue, args);
+    switch (flag) {
+      case '-h':
+      case '--help':
+        return { command: 'help' };
+      case '--stdio':
+        break;
+      case '--path':
+        options.path = value();
+        break;
+      case '--pipeline':
+        options.pipeline = value();
+        break;
+      default:
+        if (arg.startsWith('-') || positional) throw new Error(`Unknown argument: ${arg}`);
+        options.pipeline = arg;
+        positional = true;
+    }
+  }
+  return options;
+}
+
+function parseExportSarifArgs(args: string[]): ExportSarifOptions | HelpOptions {
+  const options: ExportSarifOptions = { command: 'export-sarif', path: '.', pipeline: 'default' };
+  while (args.length > 0) {
+    const arg = args.shift() as string;
+    const [flag, inlineValue] = splitFlag(arg);
+    const value = flagValue(flag, inlineValue, args);
+    switch (flag) {
+      case '-h':
+      case '--help':
+        return { command: 'help' };
+      case '--path':
+        options.path = value();
+        break;
+      case '--pipeline':
+        options.pipeline = value();
+        break;
+      case '--input':
+        options.input = value();
+        break;
+      case '--output':
+        options.output = value();
+        break;
+      case '--junit-out':
+        options.junitOut = value();
+        break;
+      default:
+        throw new Error(`Unknown argument: ${arg}`);
+    }
+  }
+  return options;
+}
+
+function parseDoctorArgs(args: string[]): DoctorOptions | HelpOptions {
+  const options: DoctorOptions = { command: 'doctor', format: 'text' };
+  while (args.length > 0) {
+    const arg = args.shift() as string;
+    const [flag, inlineValue] = splitFlag(arg);
+    const value = flagValue(flag, inlineValue, args);
+    switch (flag) {
+      case '-h':
+      case '--help':
+        return { command: 'help' };
+      case '--path':
+        options.path = value();
+        break;
+      case '--format': {
+        const format = value();
+        if (format !== 'text' && format !== 'json') throw new Error(`Invalid --format ${format}: use text or json`);
+        options.format = format;
+        break;
+      }
+      default:
+        throw new Error(`Unknown argument: ${arg}`);
+    }
+  }
+  return options;
+}
+
+/**
+ * Parse command-line arguments (without the node and script entries)
+ */
+export function parseCliArgs(argv: string[], env: NodeJS.ProcessEnv = process.env): CliOptions {
+  const args = [...argv];
+  if (args[0] === 'run') return parseRunArgs(args.slice(1));
+  if (args[0] === 'watch') return parseWatchArgs(args.slice(1));
+  if (args[0] === 'lsp') return parseLspArgs(args.slice(1));
+  if (args[0] === 'export-sarif') return parseExportSarifArgs(args.slice(1));
+  if (args[0] === 'doctor') return parseDoctorArgs(args.slice(1));
+  if (args[0] === 'serve') args.shift();
+  const options: ServeOptions = { command: 'serve' };
+  if (env.MCP_AUTH_TOKEN) options.token = env.MCP_AUTH_TOKEN;
+
+  while (args.length > 0) {
+    const arg = args.shift() as string;
+    const [flag, inlineValue] = splitFlag(arg);
+    const value = flagValue(flag, inlineValue, args);
+    switch (flag) {
+      case '-h':
+      case '--help':
+        return { command: 'help' };
+      case '--http':
+        options.http = value();
+        break;
+      case '--token':
+        options.token = value();
+        break;
+      default:
+        throw new Error(`Unknown argument: ${arg}`);
+    }
+  }
+  return options;
+}
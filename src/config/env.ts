@@ -0,0 +1,109 @@
+import { promises as fs } from 'fs';
+import { resolve } from 'path';
+import { isWithin } from './index.js';
+import { getCommandEnv, type ProjectConfig } from './project.js';
+import { runCommand } from '../utils/command.js';
+import { isSecretVariable, parseDotEnv } from '../utils/env.js';
+import { shellQuote } from '../utils/shell.js';
+
+export interface WorkspaceEnv {
+    // Everything commands get on top of the server's environment: env files, then env, then secrets
+    env: Record<string, string>;
+    // Values hidden in output, by variable name: resolved secrets and credential-like env file entries
+    secrets: Record<string, string>;
+    passEnv?: string[];
+    // Env files that were loaded, relative to the workspace root
+    files: string[];
+    warnings: string[];
+}
+
+// Keychain reads can prompt or take a while, so a value is reused for a minute
+const KEYCHAIN_TTL_MS = 60000;
+const keychainCache = new Map<string, { value: Promise<string>; expires: number }>();
+
+function readKeychain(service: string, account: string): Promise<string> {
+    const key = `${service}/${account}`;
+    const hit = keychainCache.get(key);
+    if (hit && hit.expires > Date.now()) return hit.value;
+    const command = process.platform === 'darwin'
+        ? `security find-generic-password -s ${shellQuote(service)} -a ${shellQuote(account)} -w`
+        : process.platform === 'linux'
+            ? `secret-tool lookup service ${shellQuote(service)} account ${shellQuote(account)}`
+            : null;
+    const value = (async () => {
+        if (!command) throw new Error(`No keychain support on ${process.platform}`);
+        const result = await runCommand(command, { local: true, timeout: 10000 });
+        if (result.exitCode !== 0 || !result.stdout) throw new Error(`not found in the keychain${result.stderr.trim() ? `: ${result.stderr.trim()}` : ''}`);
+        return result.stdout.replace(/\r?\n$/, '');
+    })();
+    keychainCache.set(key, { value, expires: Date.now() + KEYCHAIN_TTL_MS });
+    // A failed lookup is retried on the next call
+    value.catch(() => keychainCache.delete(key));
+    return value;
+}
+
+/**
+ * Value of a secret reference: env:NAME reads the server's environment,
+ * keychain:service/account the macOS keychain (security) or the Secret
+ * Service on Linux (secret-tool)
+ */
+export async function resolveSecret(ref: string, source: NodeJS.ProcessEnv = process.env): Promise<string> {
+    if (ref.startsWith('env:')) {
+        const value = source[ref.slice(4)];
+        if (value === undefined) throw new Error(`${ref.slice(4)} is not set in the server's environment`);
+        return value;
+    }
+    if (ref.startsWith('keychain:')) {
+        const path = ref.slice('keychain:'.length);
+        const slash = path.indexOf('/');
+        return readKeychain(path.slice(0, slash), path.slice(slash + 1));
+    }
+    throw new Error(`Unknown secret reference ${ref}`);
+}
+
+/**
+ * Environment for the commands a tool call runs in root: the config's env
+ * files, env and secrets, merged in that order. Missing files and secrets
+ * that cannot be resolved become warnings, so tools that need neither still run.
+ */
+export async function resolveWorkspaceEnv(config: ProjectConfig, root: string | null): Promise<WorkspaceEnv> {
+    const env: Record<string, string> = {};
+    const secrets: Record<string, string> = {};
+    const files: string[] = [];
+    const warnings: string[] = [];
+    for (const file of root ? config.envFiles ?? [] : []) {
+        const abs = resolve(root!, file);
+        if (!isWithin(root!, abs)) {
+            warnings.push(`Env file ${file} is outside the workspace; ignored`);
+            continue;
+        }
+        let content: string;
+        try {
+            content = await fs.readFile(abs, 'utf-8');
+        } catch {
+            warnings.push(`Env file ${file} not found`);
+            continue;
+        }
+        for (const [name, value] of Object.entries(parseDotEnv(content, { ...process.env, ...env }))) {
+            env[name] = value;
+            if (isSecretVariable(name, value)) secrets[name] = value;
+            else delete secrets[name];
+        }
+        files.push(file);
+    }
+    for (const [name, value] of Object.entries(getCommandEnv(config))) {
+        env[name] = value;
+        // Values written in the config file are not secret, whatever an env file said
+        delete secrets[name];
+    }
+    await Promise.all(Object.entries(config.secrets ?? {}).map(async ([name, ref]) => {
+        try {
+            const value = await resolveSecret(ref);
+            env[name] = value;
+            secrets[name] = value;
+        } catch (error) {
+            warnings.push(`Secret ${name} (${ref}) could not be resolved: ${error instanceof Error ? error.message : String(error)}`);
+        }
+    }));
+    return { env, secrets, ...(config.passEnv ? { passEnv: config.passEnv } : {}), files, warnings };
+}
@@ -1,11 +1,69 @@
-import { resolve } from 'path';
+import { cpus } from 'os';
+import { isAbsolute, relative, resolve } from 'path';
+import { type LimitStrategy, type ResourceLimits } from '../executor/limits.js';
+import { retryPolicyFromEnv, type RetryPolicy } from '../executor/retry.js';
+import { offlineModeFromEnv, type OfflineMode } from '../executor/offline.js';
+import { logger } from '../utils/logger.js';
+
+/**
+ * True when target is base itself or lives underneath it
+ */
+export function isWithin(base: string, target: string): boolean {
+    const rel = relative(base, target);
+    return rel === '' || (!rel.startsWith('..') && !isAbsolute(rel));
+}
+
+export type ExecutorBackend = 'local' | 'docker';
+
+export type SecretScanMode = 'off' | 'warn' | 'block';
+
+// off: use whatever is on PATH; auto: switch to installed pinned versions; install: also download them
+export type ToolchainMode = 'off' | 'auto' | 'install';
+
+const DEFAULT_DOCKER_IMAGE = 'ubuntu:24.04';
+const DEFAULT_MAX_OUTPUT_BYTES = 512 * 1024;
+// Smaller budgets leave no room for the errors themselves
+export const MIN_OUTPUT_BYTES = 1024;
 
 class Config {
     private static instance: Config;
     private allowedPaths: string[];
+    private readOnlyPaths: string[];
+    private executor: ExecutorBackend;
+    private dockerImages: Record<string, string>;
+    private cacheEnabled: boolean;
+    private secretScanMode: SecretScanMode;
+    private resourceLimits: ResourceLimits;
+    private limitStrategy: LimitStrategy;
+    private maxConcurrency: number;
+    private maxQueueDepth: number;
+    private dryRun: boolean;
+    private toolchainMode: ToolchainMode;
+    private maxOutputBytes: number;
+    private retryPolicy: RetryPolicy;
+    private offlineMode: OfflineMode;
 
     private constructor() {
-        this.allowedPaths = this.getAllowedPathsFromEnv();
+        this.allowedPaths = this.getPathsFromEnv('MCP_ALLOWED_PATHS');
+        this.readOnlyPaths = this.getPathsFromEnv('MCP_READONLY_PATHS');
+        this.executor = process.env.MCP_EXECUTOR === 'docker' ? 'docker' : 'local';
+        this.dockerImages = this.getDockerImagesFromEnv();
+        this.cacheEnabled = process.env.MCP_CACHE !== 'off';
+        const secretScan = process.env.MCP_SECRET_SCAN;
+        this.secretScanMode = secretScan === 'off' || secretScan === 'block' ? secretScan : 'warn';
+        this.resourceLimits = this.getResourceLimitsFromEnv();
+        this.limitStrategy = process.env.MCP_LIMIT_STRATEGY === 'cgroup' ? 'cgroup' : 'rlimit';
+        const maxConcurrency = Number(process.env.MCP_MAX_CONCURRENCY);
+        this.maxConcurrency = maxConcurrency >= 1 ? Math.floor(maxConcurrency) : Math.max(2, cpus().length);
+        const maxQueueDepth = Number(process.env.MCP_MAX_QUEUE);
+        this.maxQueueDepth = maxQueueDepth >= 1 ? Math.floor(maxQueueDepth) : 0;
+        this.dryRun = ['1', 'true', 'on'].includes(process.env.MCP_DRY_RUN ?? '');
+        const toolchains = process.env.MCP_TOOLCHAINS;
+        this.toolchainMode = toolchains === 'off' || toolchains === 'install' ? toolchains : 'auto';
+        const maxOutputBytes = Number(process.env.MCP_MAX_OUTPUT_BYTES);
+        this.maxOutputBytes = maxOutputBytes >= MIN_OUTPUT_BYTES ? Math.floor(maxOutputBytes) : DEFAULT_MAX_OUTPUT_BYTES;
+        this.retryPolicy = retryPolicyFromEnv();
+        this.offlineMode = offlineModeFromEnv();
     }
 
     public static getInstance(): Config {
@@ -15,36 +73,83 @@ class Config {
         return Config.instance;
     }
 
-    private getAllowedPathsFromEnv(): string[] {
-        const envPaths = process.env.MCP_ALLOWED_PATHS;
+    private getPathsFromEnv(name: string): string[] {
+        const envPaths = process.env[name];
         if (envPaths) {
             return envPaths.split(',').map(path => path.trim()).filter(path => path.length > 0);
         }
         return [];
     }
 
+    // MCP_DOCKER_IMAGES="go=golang:1.22,cargo=rust:1.79,npm=node:20"
+    private getDockerImagesFromEnv(): Record<string, string> {
+        const images: Record<string, string> = {};
+        for (const entry of (process.env.MCP_DOCKER_IMAGES || '').split(',')) {
+            const [binary, image] = entry.split('=').map(part => part.trim());
+            if (binary && image) {
+                images[binary] = image;
+            }
+        }
+        return images;
+    }
+
+    // MCP_MEMORY_LIMIT_MB=2048 MCP_CPU_LIMIT_SECONDS=600
+    private getResourceLimitsFromEnv(): ResourceLimits {
+        const limits: ResourceLimits = {};
+        const memoryMb = Number(process.env.MCP_MEMORY_LIMIT_MB);
+        const cpuSeconds = Number(process.env.MCP_CPU_LIMIT_SECONDS);
+        if (memoryMb > 0) limits.memoryMb = memoryMb;
+        if (cpuSeconds > 0) limits.cpuSeconds = cpuSeconds;
+        return limits;
+    }
+
+    // Most specific root containing the target, so a read-only subtree can sit inside a writable root
+    private findRoot(absTarget: string): { root: string; readOnly: boolean } | null {
+        let best: { root: string; readOnly: boolean } | null = null;
+        const candidates = [
+            ...this.allowedPaths.map(root => ({ root, readOnly: false })),
+            ...this.readOnlyPaths.map(root => ({ root, readOnly: true })),
+        ];
+        for (const candidate of candidates) {
+            try {
+                const absBase = resolve(candidate.root);
+                if (!isWithin(absBase, absTarget)) continue;
+                if (!best || absBase.length > best.root.length || (absBase.length === best.root.length && candidate.readOnly)) {
+                    best = { root: absBase, readOnly: candidate.readOnly };
+                }
+            } catch (error) {
+                logger.error('Could not resolve allowed path', { path: candidate.root, error });
+            }
+        }
+        return best;
+    }
+
     public isPathAllowed(targetPath: string): boolean {
         if (!targetPath) {
             return false;
         }
         try {
             const absTarget = resolve(targetPath);
-            const isAllowed = this.allowedPaths.some(basePath => {
-                try {
-                    const absBase = resolve(basePath);
-                    return absTarget.startsWith(absBase);
-                } catch (error) {
-                    console.error(`[MCP] Error resolving base path ${basePath}:`, error);
-                    return false;
-                }
-            });
+            const isAllowed = this.findRoot(absTarget) !== null;
             if (!isAllowed) {
-                console.error(`[MCP] Path access denied: ${absTarget}`);
-                console.error(`[MCP] Allowed paths: ${this.allowedPaths.map(p => resolve(p)).join(', ')}`);
+                logger.warn('Path access denied', { path: absTarget, allowedPaths: this.getResolvedAllowedPaths() });
             }
             return isAllowed;
         } catch (error) {
-            console.error(`[MCP] Error checking path permissions for ${targetPath}:`, error);
+            logger.error('Could not check path permissions', { path: targetPath, error });
+            return false;
+        }
+    }
+
+    public isPathWritable(targetPath: string): boolean {
+        if (!targetPath) {
+            return false;
+        }
+        try {
+            const root = this.findRoot(resolve(targetPath));
+            return root !== null && !root.readOnly;
+        } catch (error) {
+            logger.error('Could not check write permissions', { path: targetPath, error });
             return false;
         }
     }
@@ -53,20 +158,159 @@ class Config {
         this.allowedPaths.push(...paths);
     }
 
+    // Removes one occurrence of each path, so a root another caller also added stays allowed
+    public removeAllowedPaths(paths: string[]): void {
+        for (const path of paths) {
+            const index = this.allowedPaths.indexOf(path);
+            if (index !== -1) this.allowedPaths.splice(index, 1);
+        }
+    }
+
+    public addReadOnlyPaths(paths: string[]): void {
+        this.readOnlyPaths.push(...paths);
+    }
+
     public getAllowedPaths(): string[] {
         return this.allowedPaths.slice();
     }
 
+    public getReadOnlyPaths(): string[] {
+        return this.readOnlyPaths.slice();
+    }
+
+    public getExecutor(): ExecutorBackend {
+        return this.executor;
+    }
+
+    public setExecutor(executor: ExecutorBackend): void {
+        this.executor = executor;
+    }
+
+    /**
+     * Image used to run a command in the docker executor, chosen by the command's binary
+     */
+    public getDockerImage(command: string): string {
+        const binary = command.trim().split(/\s+/)[0] || '';
+        return this.dockerImages[binary] || process.env.MCP_DOCKER_IMAGE || DEFAULT_DOCKER_IMAGE;
+    }
+
+    public setDockerImages(images: Record<string, string>): void {
+        this.dockerImages = { ...this.dockerImages, ...images };
+    }
+
+    public isCacheEnabled(): boolean {
+        return this.cacheEnabled;
+    }
+
+    public setCacheEnabled(enabled: boolean): void {
+        this.cacheEnabled = enabled;
+    }
+
+    public getSecretScanMode(): SecretScanMode {
+        return this.secretScanMode;
+    }
+
+    public setSecretScanMode(mode: SecretScanMode): void {
+        this.secretScanMode = mode;
+    }
+
+    public getToolchainMode(): ToolchainMode {
+        return this.toolchainMode;
+    }
+
+    public setToolchainMode(mode: ToolchainMode): void {
+        this.toolchainMode = mode;
+    }
+
+    /**
+     * Memory and CPU caps applied to every spawned command unless project config overrides them
+     */
+    public getResourceLimits(): ResourceLimits {
+        return { ...this.resourceLimits };
+    }
+
+    public setResourceLimits(limits: ResourceLimits): void {
+        this.resourceLimits = { ...limits };
+    }
+
+    /**
+     * How often commands failing for transient reasons (registry 5xx, network timeouts) are retried,
+     * unless project config overrides it (MCP_RETRY_ATTEMPTS, MCP_RETRY_BACKOFF_MS)
+     */
+    public getRetryPolicy(): RetryPolicy {
+        return { ...this.retryPolicy };
+    }
+
+    public setRetryPolicy(policy: RetryPolicy): void {
+        this.retryPolicy = { ...policy };
+    }
+
+    /**
+     * Server-wide offline mode (MCP_OFFLINE): project config and calls can make it stricter, never looser
+     */
+    public getOfflineMode(): OfflineMode {
+        return this.offlineMode;
+    }
+
+    public setOfflineMode(mode: OfflineMode): void {
+        this.offlineMode = mode;
+    }
+
+    public getLimitStrategy(): LimitStrategy {
+        return this.limitStrategy;
+    }
+
+    public setLimitStrategy(strategy: LimitStrategy): void {
+        this.limitStrategy = strategy;
+    }
+
+    /**
+     * Tool calls that may run at the same time (MCP_MAX_CONCURRENCY, default: CPU count)
+     */
+    public getMaxConcurrency(): number {
+        return this.maxConcurrency;
+    }
+
+    /**
+     * Tool calls that may wait for a worker before new ones are refused (MCP_MAX_QUEUE, default: 0, unlimited)
+     */
+    public getMaxQueueDepth(): number {
+        return this.maxQueueDepth;
+    }
+
+    /**
+     * Server-wide dry run (MCP_DRY_RUN): file-writing tools only preview their changes
+     */
+    public isDryRun(): boolean {
+        return this.dryRun;
+    }
+
+    public setDryRun(enabled: boolean): void {
+        this.dryRun = enabled;
+    }
+
+    /**
+     * Size a tool result may reach before it is truncated, for calls that do
+     * not pass max_output_bytes (MCP_MAX_OUTPUT_BYTES, default 512 KB)
+     */
+    public getMaxOutputBytes(): number {
+        return this.maxOutputBytes;
+    }
+
+    public setMaxOutputBytes(bytes: number): void {
+        this.maxOutputBytes = bytes;
+    }
+
     public getResolvedAllowedPaths(): string[] {
-        return this.allowedPaths.map(path => {
+        return [...this.allowedPaths, ...this.readOnlyPaths].map(path => {
             try {
                 return resolve(path);
             } catch (error) {
-                console.error(`[MCP] Error resolving path ${path}:`, error);
+                logger.error('Could not resolve path', { path, error });
                 return path;
             }
         });
     }
 }
 
-export default Config;
\ No newline at end of file
+export default Config;
@@ -0,0 +1,511 @@
+import { promises as fs } from 'fs';
+import { homedir } from 'os';
+import { dirname, join, relative, resolve } from 'path';
+import { z } from 'zod';
+import yaml from 'js-yaml';
+import { minimatch } from 'minimatch';
+import Config, { isWithin, type SecretScanMode } from './index.js';
+import { tightestLimits } from '../executor/limits.js';
+
+export const PROJECT_CONFIG_FILES = ['.code-feedback.yaml', '.code-feedback.yml'];
+
+export const pipelineStepSchema = z.object({
+    name: z.string().optional(),
+    // Either a registered tool with its arguments, or a shell command
+    tool: z.string().optional(),
+    args: z.record(z.unknown()).optional(),
+    command: z.string().optional(),
+    // Keep running later steps when this one fails
+    continueOnError: z.boolean().optional(),
+    timeout: z.number().int().positive().optional(),
+}).strict().refine(step => Boolean(step.tool) !== Boolean(step.command), { message: 'Step needs exactly one of tool or command' });
+
+export type PipelineStep = z.infer<typeof pipelineStepSchema>;
+
+// A forbidden import: packages matching `from` must not import packages matching `to`
+export const architectureRuleSchema = z.object({
+    // "..." matches any suffix, as in Go package patterns: "internal/store/..."
+    from: z.string(),
+    to: z.string(),
+    reason: z.string().optional(),
+}).strict();
+
+export type ArchitectureRule = z.infer<typeof architectureRuleSchema>;
+
+function isValidRegex(pattern: string): boolean {
+    try {
+        new RegExp(pattern);
+        return true;
+    } catch {
+        return false;
+    }
+}
+
+// A binary run_command may run, and how
+export const commandRuleSchema = z.object({
+    // A name looked up on PATH, or a path relative to the workspace root such as ./scripts/check.sh
+    binary: z.string().min(1),
+    // Regexes, anchored at both ends; every argument must match one of them. Omit to allow any arguments
+    args: z.array(z.string().refine(isValidRegex, { message: 'Invalid regular expression' })).optional(),
+    timeout: z.number().int().positive().optional(),
+    // Host environment variables (globs such as NPM_*) passed through for this binary only
+    env: z.array(z.string()).optional(),
+    description: z.string().optional(),
+}).strict();
+
+export type CommandRule = z.infer<typeof commandRuleSchema>;
+
+// A house rule check_rules enforces: a regex matched against each line, or a Go AST pattern
+export const customRuleSchema = z.object({
+    id: z.string().regex(/^[\w.-]+$/, 'Expected letters, digits, ".", "_" or "-"'),
+    message: z.string().min(1),
+    severity: z.enum(['error', 'warning', 'info']).default('warning'),
+    // Globs relative to the workspace root; every file when unset
+    files: z.array(z.string()).optional(),
+    exclude: z.array(z.string()).optional(),
+    pattern: z.string().refine(isValidRegex, { message: 'Invalid regular expression' }).optional(),
+    go: z.object({
+        // A package function, by import path: "fmt.Println", "github.com/pkg/errors.Wrap", "log.*"
+        call: z.string().regex(/^\S+\.(\w+|\*)$/, 'Expected importpath.Function').optional(),
+        // An import path; "..." matches any suffix
+        import: z.string().min(1).optional(),
+        // Go package names the rule applies in, or does not, e.g. exceptPackages: [main]
+        packages: z.array(z.string()).optional(),
+        exceptPackages: z.array(z.string()).optional(),
+    }).strict().refine(go => Boolean(go.call) !== Boolean(go.import), { message: 'Go rule needs exactly one of call or import' }).optional(),
+}).strict().refine(rule => Boolean(rule.pattern) !== Boolean(rule.go), { message: 'Rule needs exactly one of pattern or go' });
+
+export type CustomRule = z.infer<typeof customRuleSchema>;
+
+// What a tool call can do: read, write to the workspace, or reach beyond it (run binaries, push, post)
+export const TOOL_CLASSES = ['read-only', 'mutating', 'dangerous'] as const;
+
+export type ToolClass = typeof TOOL_CLASSES[number];
+
+// A role a client is given: the tool classes it may call, plus or minus named tools
+export const roleSchema = z.object({
+    allow: z.array(z.enum(TOOL_CLASSES)).default([]),
+    // Tools granted whatever their class
+    tools: z.array(z.string()).optional(),
+    // Tools refused whatever their class
+    deny: z.array(z.string()).optional(),
+}).strict();
+
+export type Role = z.infer<typeof roleSchema>;
+
+export const sshRemoteSchema = z.object({
+    host: z.string().min(1),
+    user: z.string().min(1).optional(),
+    port: z.number().int().positive().optional(),
+    identityFile: z.string().min(1).optional(),
+    // Where the workspace is mirrored; by default the same absolute path as locally
+    remoteRoot: z.string().regex(/^\//, 'Expected an absolute path').optional(),
+    // rsync (default) sends only what changed; sftp copies the whole workspace for hosts without rsync
+    sync: z.enum(['rsync', 'sftp']).optional(),
+    // false: files the commands change remotely (formatters, go mod tidy) are not copied back
+    syncBack: z.boolean().optional(),
+    // Paths relative to the workspace root that are not synced (rsync patterns), e.g. node_modules
+    exclude: z.array(z.string().min(1)).optional(),
+}).strict();
+
+export type SshRemote = z.infer<typeof sshRemoteSchema>;
+
+export const SERVICE_KINDS = ['postgres', 'redis', 'localstack'] as const;
+
+const serviceContainerSchema = z.object({
+    // A preset supplying the image, port, health check and connection variables; defaults to the service's name when that is one
+    kind: z.enum(SERVICE_KINDS).optional(),
+    image: z.string().min(1).optional(),
+    // Container port published on a random loopback port of the host
+    port: z.number().int().positive().max(65535).optional(),
+    // Variables set inside the container
+    environment: z.record(z.union([z.string(), z.number(), z.boolean()]).transform(String)).optional(),
+    // Run in the container until it exits 0; otherwise the image's HEALTHCHECK or an open port means ready
+    healthcheck: z.string().min(1).optional(),
+    // Variables given to the tests, with ${host}, ${port} and ${url} filled in
+    env: z.record(z.string()).optional(),
+    // How long to wait for the service to become ready, in ms
+    startTimeout: z.number().int().positive().optional(),
+}).strict();
+
+const servicesSchema = z.object({
+    // Tools whose calls start the services first and stop them afterwards, unless test_services already started them
+    tools: z.array(z.string()).optional(),
+    // Services started with test_services are stopped after this long without a call using them; 60 by default
+    idleMinutes: z.number().positive().optional(),
+    // Start services on this host's docker daemon even when commands run in docker or on a remote; read from the global config only
+    allowIsolated: z.boolean().optional(),
+    containers: z.record(serviceContainerSchema).optional(),
+    // A compose file run with docker compose up --wait instead of (or next to) containers
+    compose: z.object({
+        file: z.string().min(1),
+        // Services to start; all of them by default
+        services: z.array(z.string()).optional(),
+        // Variables given to the tests; ${service:port} becomes the host address that port is published on
+        env: z.record(z.string()).optional(),
+    }).strict().optional(),
+}).strict();
+
+export type ServiceContainer = z.infer<typeof serviceContainerSchema>;
+export type ServicesConfig = z.infer<typeof servicesSchema>;
+
+export const projectConfigSchema = z.object({
+    tools: z.object({
+        // When set, only these tools may run
+        enabled: z.array(z.string()).optional(),
+        disabled: z.array(z.string()).optional(),
+    }).strict().optional(),
+    // Default timeouts in ms: `default` for every tool, or keyed by tool name
+    timeouts: z.record(z.number().int().positive()).optional(),
+    env: z.record(z.union([z.string(), z.number(), z.boolean()]).transform(String)).optional(),
+    // .env files relative to the workspace root, loaded in order before env; a missing file is a warning
+    envFiles: z.array(z.string().min(1)).optional(),
+    // Variables resolved when a tool runs and hidden in its output: env:NAME (the server's environment) or keychain:service/account.
+    // A project may only use references the global config lists
+    secrets: z.record(z.string().regex(/^(env:[A-Za-z_][A-Za-z0-9_]*|keychain:[^/]+\/.+)$/, 'Expected env:NAME or keychain:service/account')).optional(),
+    // When set, commands see only a baseline of the server's environment (PATH, HOME, ...) plus these names (globs such as AWS_*).
+    // A project can narrow the global list, never add to it
+    passEnv: z.array(z.string().min(1)).optional(),
+    // Go build tags, passed to every go command via GOFLAGS
+    buildTags: z.array(z.string()).optional(),
+    // GOOS/GOARCH pairs go_build_matrix builds for, e.g. linux/amd64
+    goTargets: z.array(z.string().regex(/^[a-z0-9]+\/[a-z0-9]+$/, 'Expected GOOS/GOARCH')).optional(),
+    // Globs relative to the workspace root that tools must not touch
+    exclude: z.array(z.string()).optional(),
+    // Memory and CPU caps for spawned commands; can only lower MCP_MEMORY_LIMIT_MB / MCP_CPU_LIMIT_SECONDS and the global config's
+    limits: z.object({
+        memoryMb: z.number().positive().optional(),
+        cpuSeconds: z.number().positive().optional(),
+    }).strict().optional(),
+    // Retries of commands that fail for transient reasons, overriding MCP_RETRY_ATTEMPTS / MCP_RETRY_BACKOFF_MS
+    retry: z.object({
+        attempts: z.number().int().min(0).max(10).optional(),
+        backoffMs: z.number().int().min(0).optional(),
+        // Regexes for failures of this project's own infrastructure that are worth retrying
+        patterns: z.array(z.string().refine(isValidRegex, { message: 'Invalid regular expression' })).optional(),
+    }).strict().optional(),
+    // on: dependencies come from vendor directories and local caches only; strict: commands get no network either. Can only tighten MCP_OFFLINE
+    offline: z.enum(['off', 'on', 'strict']).optional(),
+    // Where commands run: local, docker, or the name of one of the remotes; overrides MCP_EXECUTOR. A project config cannot pick local
+    executor: z.string().min(1).optional(),
+    // Build hosts commands can run on over SSH, by name; read from the global config only, so a repository cannot send its code elsewhere
+    remotes: z.record(sshRemoteSchema).optional(),
+    // Secret detection on file writes: warn (default), block, or off. Can only tighten MCP_SECRET_SCAN and the global config
+    secretScan: z.enum(['off', 'warn', 'block']).optional(),
+    // Pinned toolchain selection (go.mod, .nvmrc, .python-version): off, auto or install, overriding MCP_TOOLCHAINS
+    toolchains: z.enum(['off', 'auto', 'install']).optional(),
+    // Named step lists for run_pipeline, e.g. format -> build -> vet -> test -> lint
+    pipelines: z.record(z.array(pipelineStepSchema).min(1)).optional(),
+    // Code generation commands check_generated reruns, e.g. "go generate ./..." or "buf generate"
+    generate: z.array(z.string().min(1)).optional(),
+    // Dependency license policy for license_check: SPDX ids, matched case-insensitively
+    licenses: z.object({
+        // When set, every dependency needs one of these licenses
+        allow: z.array(z.string()).optional(),
+        deny: z.array(z.string()).optional(),
+        // Packages exempt from the policy (reviewed by hand), by name
+        ignore: z.array(z.string()).optional(),
+    }).strict().optional(),
+    // Commit message rules for validate_commit_message (conventional commits)
+    commits: z.object({
+        // Allowed types; defaults to the conventional set (feat, fix, docs, ...)
+        types: z.array(z.string().min(1)).optional(),
+        // When set, a scope must be one of these
+        scopes: z.array(z.string().min(1)).optional(),
+        requireScope: z.boolean().optional(),
+        maxHeaderLength: z.number().int().positive().optional(),
+        maxLineLength: z.number().int().positive().optional(),
+        // Regexes for issue references such as #\d+ or [A-Z]+-\d+; when set, a message must contain one
+        issuePatterns: z.array(z.string().min(1)).optional(),
+    }).strict().optional(),
+    // Where publish_review posts: the code host (detected from the origin remote when unset), API endpoint and repository
+    review: z.object({
+        provider: z.enum(['github', 'gitlab', 'bitbucket']).optional(),
+        // e.g. https://gitlab.example.com/api/v4; tokens stay in the environment
+        apiUrl: z.string().url().optional(),
+        // owner/repo, GitLab group/subgroup/project, or Bitbucket workspace/repo
+        repository: z.string().regex(/^[\w.-]+(\/[\w.-]+)+$/, 'Expected owner/repo').optional(),
+    }).strict().optional(),
+    // Import boundaries checked by check_architecture
+    architecture: z.array(architectureRuleSchema).optional(),
+    // House rules checked by check_rules
+    rules: z.array(customRuleSchema).optional(),
+    // Thresholds above which code_metrics warns
+    metrics: z.object({
+        complexity: z.number().int().positive().optional(),
+        functionLines: z.number().int().positive().optional(),
+        params: z.number().int().positive().optional(),
+        fileLines: z.number().int().positive().optional(),
+    }).strict().optional(),
+    // Conventions and dictionary check_naming applies
+    naming: z.object({
+        // Words (or whole identifiers) never reported as misspelled
+        allow: z.array(z.string().min(1)).optional(),
+        // Go initialisms beyond the standard ones, such as GRPC
+        initialisms: z.array(z.string().regex(/^[A-Z0-9]+$/, 'Expected upper case letters and digits')).optional(),
+    }).strict().optional(),
+    // How validate_migrations runs migrations
+    migrations: z.object({
+        engine: z.enum(['sqlite', 'postgres', 'none']).optional(),
+        // Image of the throwaway Postgres container; postgres:16-alpine by default
+        image: z.string().min(1).optional(),
+    }).strict().optional(),
+    // Databases, caches and cloud emulators integration tests need, started by test_services
+    services: servicesSchema.optional(),
+    // Inline suppression comments (//nolint, # noqa, eslint-disable-line, code-feedback:ignore)
+    suppressions: z.object({
+        // false reports findings even when a comment suppresses them
+        enabled: z.boolean().optional(),
+        // A comment without a reason suppresses nothing
+        requireReason: z.boolean().optional(),
+    }).strict().optional(),
+    // Accepted findings (written by create_baseline) that pipeline runs leave out
+    baseline: z.object({
+        // Relative to the project config; defaults to .code-feedback-baseline.json
+        file: z.string().min(1).optional(),
+        // How far a finding may move and still match its baseline entry
+        lineTolerance: z.number().int().min(0).optional(),
+        // false keeps every finding even when a baseline file exists
+        enabled: z.boolean().optional(),
+    }).strict().optional(),
+    // Who may call which tools; read from the global config only, so a repository cannot grant itself access
+    permissions: z.object({
+        // Override a tool's class, e.g. mark a tool dangerous
+        classes: z.record(z.enum(TOOL_CLASSES)).optional(),
+        roles: z.record(roleSchema).optional(),
+        // Role of stdio callers and of HTTP clients whose API key names none
+        defaultRole: z.string().optional(),
+    }).strict().optional(),
+    // Policy for run_command: nothing runs unless a rule allows it; read from the global config only, so a repository cannot allow itself binaries
+    commands: z.object({
+        allow: z.array(commandRuleSchema).optional(),
+        // Host environment variables (globs) passed through to every allowed command
+        env: z.array(z.string()).optional(),
+    }).strict().optional(),
+}).strict();
+
+export type ProjectConfig = z.infer<typeof projectConfigSchema>;
+
+export interface EffectiveConfig {
+    globalConfigPath: string | null;
+    projectConfigPath: string | null;
+    // Directory holding the project config; excludes are relative to it
+    workspaceRoot: string | null;
+    config: ProjectConfig;
+}
+
+// Tools that must stay reachable to inspect a misconfigured project
+const ALWAYS_ENABLED = new Set(['get_config']);
+
+const fileCache = new Map<string, { mtimeMs: number; config: ProjectConfig }>();
+
+/**
+ * Location of the user-level config: MCP_CONFIG_FILE or ~/.config/code-feedback/config.yaml
+ */
+export function getGlobalConfigPath(): string {
+    return process.env.MCP_CONFIG_FILE || join(homedir(), '.config', 'code-feedback', 'config.yaml');
+}
+
+/**
+ * Read and validate a config file; returns null when it does not exist
+ */
+export async function loadConfigFile(path: string): Promise<ProjectConfig | null> {
+    let stats;
+    try {
+        stats = await fs.stat(path);
+    } catch {
+        return null;
+    }
+    const known = fileCache.get(path);
+    if (known && known.mtimeMs === stats.mtimeMs) {
+        return known.config;
+    }
+    let raw: unknown;
+    try {
+        raw = yaml.load(await fs.readFile(path, 'utf8'));
+    } catch (error: any) {
+        throw new Error(`Invalid config file ${path}: ${error.message || String(error)}`);
+    }
+    const parseResult = projectConfigSchema.safeParse(raw ?? {});
+    if (!parseResult.success) {
+        const issues = parseResult.error.errors.map(e => `${e.path.join('.') || '(root)'} - ${e.message}`);
+        throw new Error(`Invalid config file ${path}: ${issues.join('; ')}`);
+    }
+    fileCache.set(path, { mtimeMs: stats.mtimeMs, config: parseResult.data });
+    return parseResult.data;
+}
+
+const SECRET_SCAN_STRICTNESS: SecretScanMode[] = ['off', 'warn', 'block'];
+
+/**
+ * The stricter of two secret scan modes; a missing mode leaves the other
+ */
+export function strictestSecretScan(a: SecretScanMode | undefined, b: SecretScanMode | undefined): SecretScanMode | undefined {
+    if (a === undefined || b === undefined) return a ?? b;
+    return SECRET_SCAN_STRICTNESS.indexOf(a) >= SECRET_SCAN_STRICTNESS.indexOf(b) ? a : b;
+}
+
+/**
+ * Overlay project config on global config: maps (including retry, pipelines, commits and review) merge key by key, limits take the
+ * lower value of each key, tool
+ * and license allow-lists, build tags, Go targets, generate commands, toolchains, offline and services are replaced, executor only moves to docker or a
+ * base remote, secretScan only tightens, deny-lists
+ * (tools and licenses), excludes, license ignores, architecture rules and env files accumulate; project secrets and passEnv are limited to what the base
+ * lists; permissions, remotes, commands and services.allowIsolated come from the base only
+ */
+export function mergeConfigs(base: ProjectConfig, override: ProjectConfig): ProjectConfig {
+    const merged: ProjectConfig = { ...base };
+    const enabled = override.tools?.enabled ?? base.tools?.enabled;
+    const disabled = [...new Set([...(base.tools?.disabled ?? []), ...(override.tools?.disabled ?? [])])];
+    if (enabled || disabled.length > 0) {
+        merged.tools = { ...(enabled ? { enabled } : {}), ...(disabled.length > 0 ? { disabled } : {}) };
+    }
+    if (base.timeouts || override.timeouts) merged.timeouts = { ...base.timeouts, ...override.timeouts };
+    if (base.env || override.env) merged.env = { ...base.env, ...override.env };
+    if (base.secrets || override.secrets) {
+        // A repository must not reach keychain entries or server variables the operator did not list
+        const references = new Set(Object.values(base.secrets ?? {}));
+        const allowed = Object.entries(override.secrets ?? {}).filter(([, reference]) => references.has(reference));
+        merged.secrets = { ...base.secrets, ...Object.fromEntries(allowed) };
+    }
+    if (base.envFiles || override.envFiles) merged.envFiles = [...(base.envFiles ?? []), ...(override.envFiles ?? [])];
+    const passEnv = base.passEnv && override.passEnv ? override.passEnv.filter(name => base.passEnv!.includes(name)) : base.passEnv ?? override.passEnv;
+    if (passEnv) merged.passEnv = [...new Set(passEnv)];
+    if (base.limits || override.limits) merged.limits = tightestLimits(base.limits, override.limits);
+    if (base.retry || override.retry) merged.retry = { ...base.retry, ...override.retry };
+    if (base.pipelines || override.pipelines) merged.pipelines = { ...base.pipelines, ...override.pipelines };
+    if (base.commits || override.commits) merged.commits = { ...base.commits, ...override.commits };
+    if (base.review || override.review) merged.review = { ...base.review, ...override.review };
+    if (base.metrics || override.metrics) merged.metrics = { ...base.metrics, ...override.metrics };
+    if (base.naming || override.naming) {
+        const allow = [...(base.naming?.allow ?? []), ...(override.naming?.allow ?? [])];
+        const initialisms = [...(base.naming?.initialisms ?? []), ...(override.naming?.initialisms ?? [])];
+        merged.naming = { ...(allow.length > 0 ? { allow } : {}), ...(initialisms.length > 0 ? { initialisms } : {}) };
+    }
+    if (base.migrations || override.migrations) merged.migrations = { ...base.migrations, ...override.migrations };
+    if (base.suppressions || override.suppressions) merged.suppressions = { ...base.suppressions, ...override.suppressions };
+    if (base.baseline || override.baseline) merged.baseline = { ...base.baseline, ...override.baseline };
+    const buildTags = override.buildTags ?? base.buildTags;
+    if (buildTags) merged.buildTags = buildTags;
+    const generate = override.generate ?? base.generate;
+    if (generate) merged.generate = generate;
+    const goTargets = override.goTargets ?? base.goTargets;
+    if (goTargets) merged.goTargets = goTargets;
+    const secretScan = strictestSecretScan(base.secretScan, override.secretScan);
+    if (secretScan) merged.secretScan = secretScan;
+    const toolchains = override.toolchains ?? base.toolchains;
+    if (toolchains) merged.toolchains = toolchains;
+    const offline = override.offline ?? base.offline;
+    if (offline) merged.offline = offline;
+    // A project can move its commands into docker or onto a remote the global config defines, never back onto the host
+    const projectExecutor = override.executor === 'docker' || (override.executor && base.remotes?.[override.executor]) ? override.executor : undefined;
+    const executor = projectExecutor ?? base.executor;
+    if (executor) merged.executor = executor;
+    const services = override.services ?? base.services;
+    if (services) {
+        // The project's services run on this host, so only the operator can let them out of an isolated executor
+        merged.services = { ...services };
+        delete merged.services.allowIsolated;
+        if (base.services?.allowIsolated !== undefined) merged.services.allowIsolated = base.services.allowIsolated;
+    }
+    if (base.exclude || override.exclude) merged.exclude = [...new Set([...(base.exclude ?? []), ...(override.exclude ?? [])])];
+    if (base.architecture || override.architecture) merged.architecture = [...(base.architecture ?? []), ...(override.architecture ?? [])];
+    if (base.rules || override.rules) {
+        // A project rule replaces the global rule with its id
+        const ids = new Set((override.rules ?? []).map(r => r.id));
+        merged.rules = [...(base.rules ?? []).filter(r => !ids.has(r.id)), ...(override.rules ?? [])];
+    }
+    if (base.licenses || override.licenses) {
+        const allow = override.licenses?.allow ?? base.licenses?.allow;
+        const deny = [...new Set([...(base.licenses?.deny ?? []), ...(override.licenses?.deny ?? [])])];
+        const ignore = [...new Set([...(base.licenses?.ignore ?? []), ...(override.licenses?.ignore ?? [])])];
+        merged.licenses = { ...(allow ? { allow } : {}), ...(deny.length > 0 ? { deny } : {}), ...(ignore.length > 0 ? { ignore } : {}) };
+    }
+    // permissions, remotes and commands stay the base's: a project config cannot widen what its callers may do
+    return merged;
+}
+
+/**
+ * Nearest project config at or above targetPath, never leaving the allowed roots
+ */
+export async function findProjectConfig(targetPath: string): Promise<string | null> {
+    const config = Config.getInstance();
+    const roots = config.getResolvedAllowedPaths();
+    let currentDir = resolve(targetPath);
+    try {
+        if (!(await fs.stat(currentDir)).isDirectory()) currentDir = dirname(currentDir);
+    } catch {
+        currentDir = dirname(currentDir);
+    }
+    while (roots.some(root => isWithin(root, currentDir))) {
+        for (const name of PROJECT_CONFIG_FILES) {
+            const candidate = join(currentDir, name);
+            try {
+                await fs.access(candidate);
+                return candidate;
+            } catch { /* keep searching */ }
+        }
+        const parentDir = dirname(currentDir);
+        if (parentDir === currentDir) break;
+        currentDir = parentDir;
+    }
+    return null;
+}
+
+/**
+ * Global config merged with the project config governing targetPath
+ */
+export async function getEffectiveConfig(targetPath?: string): Promise<EffectiveConfig> {
+    const globalPath = getGlobalConfigPath();
+    const globalConfig = await loadConfigFile(globalPath);
+    const projectPath = targetPath ? await findProjectConfig(targetPath) : null;
+    const projectConfig = projectPath ? await loadConfigFile(projectPath) : null;
+    return {
+        globalConfigPath: globalConfig ? globalPath : null,
+        projectConfigPath: projectPath,
+        workspaceRoot: projectPath ? dirname(projectPath) : null,
+        config: mergeConfigs(globalConfig ?? {}, projectConfig ?? {}),
+    };
+}
+
+export function isToolEnabled(config: ProjectConfig, toolName: string): boolean {
+    if (ALWAYS_ENABLED.has(toolName)) return true;
+    if (config.tools?.disabled?.includes(toolName)) return false;
+    return !config.tools?.enabled || config.tools.enabled.includes(toolName);
+}
+
+/**
+ * True when targetPath, or any directory above it inside the workspace, matches an exclude glob
+ */
+export function isExcluded(config: ProjectConfig, workspaceRoot: string | null, targetPath: string): boolean {
+    if (!config.exclude || config.exclude.length === 0 || !workspaceRoot) return false;
+    const absTarget = resolve(targetPath);
+    if (!isWithin(workspaceRoot, absTarget)) return false;
+    let rel = relative(workspaceRoot, absTarget).split('\\').join('/');
+    while (rel && rel !== '.') {
+        const candidate = rel;
+        if (config.exclude.some(pattern => minimatch(candidate, pattern, { dot: true }))) return true;
+        rel = rel.includes('/') ? rel.slice(0, rel.lastIndexOf('/')) : '';
+    }
+    return false;
+}
+
+/**
+ * Environment for commands run under this config; build tags become GOFLAGS -tags
+ */
+export function getCommandEnv(config: ProjectConfig): Record<string, string> {
+    const env = { ...config.env };
+    if (config.buildTags && config.buildTags.length > 0) {
+        const tagsFlag = `-tags=${config.buildTags.join(',')}`;
+        const goflags = env.GOFLAGS ?? process.env.GOFLAGS;
+        env.GOFLAGS = goflags ? `${goflags} ${tagsFlag}` : tagsFlag;
+    }
+    return env;
+}
+
+/**
+ * Configured default timeout for a tool, if any
+ */
+export function getToolTimeout(config: ProjectConfig, toolName: string): number | undefined {
+    return config.timeouts?.[toolName] ?? config.timeouts?.default;
+}
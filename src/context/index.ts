@@ -0,0 +1,400 @@
+import { promises as fs } from 'fs';
+import { dirname, extname, join, relative, resolve, sep } from 'path';
+import { extractImports, type ImportLanguage } from '../tools/architecture.js';
+import { findUp } from '../utils/paths.js';
+
+export interface ContextSource {
+    path: string;
+    source: string;
+}
+
+export interface LineRange {
+    startLine: number;
+    endLine: number;
+}
+
+export type ContextMode = 'file' | 'ranges' | 'outline' | 'truncated';
+
+/**
+ * One file of a context pack and how much of it made it in
+ */
+export interface ContextEntry {
+    // Relative to the root, with forward slashes
+    path: string;
+    // Why the file is relevant: "seed", "defines Store", "imported by api/handler.go", ...
+    reason: string;
+    // 1 for seeds, lower the further away in the import graph
+    score: number;
+    depth: number;
+    // Whole file, the definitions asked for, declaration lines only, or the head of a seed too large to fit
+    mode: ContextMode;
+    ranges: LineRange[];
+    bytes: number;
+    content: string;
+}
+
+export interface ContextPack {
+    entries: ContextEntry[];
+    // Relevant files the budget had no room for, most relevant first
+    omitted: Array<{ path: string; reason: string; score: number }>;
+    // Symbols with no definition in the sources
+    unresolved: string[];
+    totalBytes: number;
+}
+
+export interface ContextOptions {
+    files: string[];
+    symbols: string[];
+    maxBytes: number;
+    maxDepth: number;
+    includeDependents: boolean;
+}
+
+// Relevance kept per hop along an import, and along a reverse import (a file using the one before)
+const IMPORT_DECAY = 0.5;
+const DEPENDENT_DECAY = 0.35;
+const REFERENCE_SCORE = 0.6;
+const MAX_REFERENCING_FILES = 10;
+// Files this small are included whole even when only one definition in them was asked for
+const SMALL_FILE_BYTES = 8 * 1024;
+const MAX_BLOCK_LINES = 400;
+// Less room than this is not worth spending on the head of a seed
+const MIN_TRUNCATED_BYTES = 256;
+
+const LANGUAGES: Record<string, ImportLanguage> = {
+    '.go': 'go',
+    '.py': 'python',
+    '.js': 'javascript', '.jsx': 'javascript', '.mjs': 'javascript', '.cjs': 'javascript',
+    '.ts': 'javascript', '.tsx': 'javascript', '.mts': 'javascript', '.cts': 'javascript',
+};
+// ESM TypeScript imports name the compiled file: "./store.js" is store.ts
+const JS_SOURCE_EXTENSIONS: Record<string, string[]> = {
+    '.js': ['.ts', '.tsx', '.js', '.jsx'],
+    '.jsx': ['.tsx', '.jsx'],
+    '.mjs': ['.mts', '.mjs'],
+    '.cjs': ['.cts', '.cjs'],
+};
+const JS_EXTENSIONS = ['.ts', '.tsx', '.mts', '.cts', '.js', '.jsx', '.mjs', '.cjs'];
+
+function toPosix(path: string): string {
+    return path.split(sep).join('/');
+}
+
+function escapeRegExp(text: string): string {
+    return text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
+
+/**
+ * Line patterns that declare name in a file with the given extension.
+ * Regex based: good for top-level functions, types, classes, methods and
+ * constants, not for every construct a parser would find.
+ */
+function definitionPatterns(extension: string, name: string): RegExp[] {
+    const n = escapeRegExp(name);
+    if (extension === '.go') {
+        return [new RegExp(`^func\\s+(?:\\([^)]*\\)\\s*)?${n}\\b`), new RegExp(`^\\s*(?:type|var|const)\\s+${n}\\b`)];
+    }
+    if (extension === '.py') return [new RegExp(`^\\s*(?:async\\s+)?def\\s+${n}\\b`), new RegExp(`^\\s*class\\s+${n}\\b`)];
+    if (LANGUAGES[extension] === 'javascript') {
+        return [
+            new RegExp(`^\\s*(?:export\\s+)?(?:default\\s+)?(?:declare\\s+)?(?:async\\s+)?(?:abstract\\s+)?(?:function\\*?|class|interface|type|enum|const|let|var)\\s+${n}\\b`),
+            new RegExp(`^\\s+(?:(?:public|private|protected|static|async|readonly|override|get|set)\\s+)*${n}\\s*(?:<[^>]*>)?\\([^;]*\\)\\s*(?::[^;{=]+)?\\{\\s*$`),
+        ];
+    }
+    return [new RegExp(`\\b(?:fn|func|def|function|class|struct|interface|enum|trait|type|record|object)\\s+${n}\\b`)];
+}
+
+// Declaration lines of any name, for outlines of files too large to include
+function isDeclarationLine(extension: string, line: string): boolean {
+    if (extension === '.go') return /^(?:func|type|var|const)\s/.test(line);
+    if (extension === '.py') return /^(?:\s*(?:async\s+)?def|\s*class)\s/.test(line);
+    if (LANGUAGES[extension] === 'javascript') {
+        return /^(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:async\s+)?(?:abstract\s+)?(?:function\*?|class|interface|type|enum|const|let)\s/.test(line)
+            || /^\s+(?:(?:public|private|protected|static|async|readonly|override|get|set)\s+)*\w+\s*(?:<[^>]*>)?\([^;]*\)\s*(?::[^;{=]+)?\{\s*$/.test(line);
+    }
+    return /^\s*(?:pub\s+)?(?:public\s+|private\s+|protected\s+)?(?:fn|func|def|class|struct|interface|enum|trait|type)\s/.test(line);
+}
+
+// Code of a line without string literals and line comments, so braces in them do not count
+function codeOf(line: string): string {
+    return line
+        .replace(/"(?:\\.|[^"\\])*"|'(?:\\.|[^'\\])*'|`(?:\\.|[^`\\])*`/g, '""')
+        .replace(/\/\/.*$/, '');
+}
+
+/**
+ * Lines of the declaration starting at index (0-based), with the comments
+ * and decorators right above it: to the closing brace in brace languages,
+ * to the end of the indented body in Python
+ */
+export function declarationRange(lines: string[], index: number, extension: string): LineRange {
+    let start = index;
+    while (start > 0 && /^\s*(?:\/\/|\/\*|\*|#|@)/.test(lines[start - 1] ?? '')) start--;
+    let end = index;
+    const limit = Math.min(lines.length - 1, index + MAX_BLOCK_LINES);
+    if (extension === '.py') {
+        const indent = /^\s*/.exec(lines[index] ?? '')?.[0].length ?? 0;
+        for (let i = index + 1; i <= limit; i++) {
+            const line = lines[i] ?? '';
+            if (line.trim() === '') continue;
+            if ((/^\s*/.exec(line)?.[0].length ?? 0) <= indent && !/^\s*[)\]}]/.test(line)) break;
+            end = i;
+        }
+        return { startLine: start + 1, endLine: end + 1 };
+    }
+    let depth = 0;
+    let opened = false;
+    for (let i = index; i <= limit; i++) {
+        const code = codeOf(lines[i] ?? '');
+        for (const ch of code) {
+            if (ch === '{' || ch === '(') {
+                depth++;
+                opened = true;
+            } else if (ch === '}' || ch === ')') {
+                depth--;
+            }
+        }
+        end = i;
+        if (opened && depth <= 0) break;
+        // One-line declarations: "type ID string", "export type Mode = 'a' | 'b';"
+        if (!opened && (i > index || !/[=,([{]\s*$|=>\s*$/.test(code.trimEnd()))) break;
+    }
+    return { startLine: start + 1, endLine: end + 1 };
+}
+
+function mergeRanges(ranges: LineRange[]): LineRange[] {
+    const sorted = [...ranges].sort((a, b) => a.startLine - b.startLine);
+    const merged: LineRange[] = [];
+    for (const range of sorted) {
+        const last = merged[merged.length - 1];
+        if (last && range.startLine <= last.endLine + 1) last.endLine = Math.max(last.endLine, range.endLine);
+        else merged.push({ ...range });
+    }
+    return merged;
+}
+
+// "Store.Get" and "store.Store.Get" name Get; the qualifier is not checked
+function symbolName(symbol: string): string {
+    return symbol.split(/[.#:]/).filter(Boolean).pop() ?? symbol;
+}
+
+/**
+ * Where each symbol is declared, as line ranges per file
+ */
+export function findDefinitions(sources: ContextSource[], symbols: string[]): Map<string, Map<string, LineRange[]>> {
+    const found = new Map<string, Map<string, LineRange[]>>();
+    for (const symbol of symbols) {
+        const name = symbolName(symbol);
+        const files = new Map<string, LineRange[]>();
+        for (const { path, source } of sources) {
+            const extension = extname(path).toLowerCase();
+            if (!source.includes(name)) continue;
+            const patterns = definitionPatterns(extension, name);
+            const lines = source.split('\n');
+            lines.forEach((line, index) => {
+                if (patterns.some(pattern => pattern.test(line))) files.set(path, [...(files.get(path) ?? []), declarationRange(lines, index, extension)]);
+            });
+        }
+        found.set(symbol, files);
+    }
+    return found;
+}
+
+async function readGoModule(root: string): Promise<{ module: string; dir: string } | null> {
+    const modFile = await findUp(root, 'go.mod');
+    if (!modFile) return null;
+    const module = /^module\s+(\S+)/m.exec(await fs.readFile(modFile, 'utf-8').catch(() => ''))?.[1];
+    return module ? { module, dir: dirname(modFile) } : null;
+}
+
+/**
+ * The project files each source imports. JavaScript and TypeScript relative
+ * imports resolve to files (extension swaps and index files included),
+ * Python modules to their .py or __init__.py, and Go import paths inside
+ * the module to every file of the package.
+ */
+export async function buildImportGraph(root: string, sources: ContextSource[]): Promise<Map<string, Set<string>>> {
+    const known = new Set(sources.map(s => s.path));
+    const goModule = sources.some(s => s.path.endsWith('.go')) ? await readGoModule(root) : null;
+    const goPackages = new Map<string, string[]>();
+    for (const { path } of sources) {
+        if (path.endsWith('.go') && !path.endsWith('_test.go')) goPackages.set(dirname(path), [...(goPackages.get(dirname(path)) ?? []), path]);
+    }
+    const firstKnown = (candidates: string[]) => candidates.find(candidate => known.has(candidate));
+
+    const resolveSpecifier = (file: string, specifier: string, language: ImportLanguage): string[] => {
+        if (language === 'go') {
+            if (!goModule || (specifier !== goModule.module && !specifier.startsWith(`${goModule.module}/`))) return [];
+            return goPackages.get(join(goModule.dir, specifier.slice(goModule.module.length))) ?? [];
+        }
+        if (language === 'python') {
+            const dots = /^\.*/.exec(specifier)?.[0].length ?? 0;
+            const parts = specifier.slice(dots).split('.').filter(Boolean);
+            const bases = dots > 0
+                ? [[...Array(dots - 1)].reduce((dir: string) => dirname(dir), dirname(file))]
+                : [root, join(root, 'src')];
+            const hit = firstKnown(bases.flatMap(base => [`${join(base, ...parts)}.py`, join(base, ...parts, '__init__.py')]));
+            return hit ? [hit] : [];
+        }
+        if (!specifier.startsWith('.') && !specifier.startsWith('/')) return [];
+        const target = resolve(dirname(file), specifier);
+        const extension = extname(target);
+        const stem = target.slice(0, target.length - extension.length);
+        const hit = firstKnown([
+            target,
+            ...(JS_SOURCE_EXTENSIONS[extension] ?? []).map(ext => stem + ext),
+            ...JS_EXTENSIONS.map(ext => target + ext),
+            ...JS_EXTENSIONS.map(ext => join(target, `index${ext}`)),
+        ]);
+        return hit ? [hit] : [];
+    };
+
+    const graph = new Map<string, Set<string>>();
+    for (const { path, source } of sources) {
+        const language = LANGUAGES[extname(path).toLowerCase()];
+        const targets = new Set<string>();
+        if (language) {
+            for (const { specifier } of extractImports(source, language)) {
+                for (const target of resolveSpecifier(path, specifier, language)) if (target !== path) targets.add(target);
+            }
+        }
+        graph.set(path, targets);
+    }
+    return graph;
+}
+
+function render(path: string, lines: string[], range: LineRange, note: string): string {
+    return `==> ${path}:${range.startLine}-${range.endLine} (${note})\n${lines.slice(range.startLine - 1, range.endLine).join('\n')}\n`;
+}
+
+/**
+ * Assemble the code most relevant to the seed files and symbols within
+ * maxBytes: the seeds and the files defining the symbols, then what they
+ * import and (optionally) what imports them, up to maxDepth hops, then other
+ * files referencing the symbols. Files are taken most relevant first, whole
+ * when they fit; a file only wanted for some definitions contributes those,
+ * one that does not fit contributes its declaration lines, and a seed whose
+ * outline does not fit either contributes its head.
+ */
+export async function buildContextPack(root: string, sources: ContextSource[], options: ContextOptions): Promise<ContextPack> {
+    const byPath = new Map(sources.map(s => [s.path, s]));
+    const graph = await buildImportGraph(root, sources);
+    const dependents = new Map<string, Set<string>>();
+    for (const [file, targets] of graph) {
+        for (const target of targets) dependents.set(target, (dependents.get(target) ?? new Set()).add(file));
+    }
+    const display = (file: string) => toPosix(relative(root, file)) || file;
+
+    const candidates = new Map<string, { score: number; depth: number; reason: string; focus: LineRange[] }>();
+    const offer = (file: string, score: number, depth: number, reason: string, focus: LineRange[] = []) => {
+        const current = candidates.get(file);
+        if (!current) {
+            candidates.set(file, { score, depth, reason, focus });
+            return;
+        }
+        current.focus.push(...focus);
+        if (score > current.score) Object.assign(current, { score, depth, reason });
+    };
+
+    for (const file of options.files) offer(file, 1, 0, 'seed');
+    const definitions = findDefinitions(sources, options.symbols);
+    const unresolved: string[] = [];
+    for (const [symbol, files] of definitions) {
+        if (files.size === 0) unresolved.push(symbol);
+        for (const [file, ranges] of files) offer(file, 1, 0, `defines ${symbol}`, ranges);
+    }
+
+    // Breadth first over imports (and reverse imports), keeping the best score per file
+    let frontier = [...candidates.keys()];
+    for (let depth = 1; depth <= options.maxDepth && frontier.length > 0; depth++) {
+        const next: string[] = [];
+        for (const file of frontier) {
+            const base = candidates.get(file)!.score;
+            for (const target of graph.get(file) ?? []) {
+                if (!candidates.has(target)) next.push(target);
+                offer(target, base * IMPORT_DECAY, depth, `imported by ${display(file)}`);
+            }
+            if (!options.includeDependents) continue;
+            for (const user of dependents.get(file) ?? []) {
+                if (!candidates.has(user)) next.push(user);
+                offer(user, base * DEPENDENT_DECAY, depth, `imports ${display(file)}`);
+            }
+        }
+        frontier = next;
+    }
+
+    // Files that mention a symbol without defining it, most mentions first
+    for (const symbol of options.symbols) {
+        const word = new RegExp(`\\b${escapeRegExp(symbolName(symbol))}\\b`, 'g');
+        const defining = definitions.get(symbol) ?? new Map();
+        sources
+            .filter(s => !defining.has(s.path))
+            .map(s => ({ path: s.path, count: s.source.match(word)?.length ?? 0 }))
+            .filter(r => r.count > 0)
+            .sort((a, b) => b.count - a.count)
+            .slice(0, MAX_REFERENCING_FILES)
+            .forEach(r => offer(r.path, REFERENCE_SCORE, 1, `references ${symbol}`));
+    }
+
+    const ranked = [...candidates.entries()]
+        .map(([file, c]) => ({ file, ...c, size: Buffer.byteLength(byPath.get(file)?.source ?? '') }))
+        .sort((a, b) => b.score - a.score || a.depth - b.depth || a.size - b.size || a.file.localeCompare(b.file));
+
+    const entries: ContextEntry[] = [];
+    const omitted: ContextPack['omitted'] = [];
+    let remaining = options.maxBytes;
+    for (const candidate of ranked) {
+        const source = byPath.get(candidate.file)?.source;
+        if (source === undefined) continue;
+        const path = display(candidate.file);
+        const lines = source.split('\n');
+        const extension = extname(candidate.file).toLowerCase();
+        const score = Math.round(candidate.score * 100) / 100;
+        const whole: LineRange = { startLine: 1, endLine: lines.length };
+        const attempts: Array<() => Pick<ContextEntry, 'mode' | 'ranges' | 'content'> | null> = [];
+        if (candidate.focus.length === 0 || candidate.size <= SMALL_FILE_BYTES) {
+            attempts.push(() => ({ mode: 'file', ranges: [whole], content: render(path, lines, whole, candidate.reason) }));
+        }
+        if (candidate.focus.length > 0) {
+            attempts.push(() => {
+                const ranges = mergeRanges(candidate.focus);
+                return { mode: 'ranges', ranges, content: ranges.map(range => render(path, lines, range, candidate.reason)).join('') };
+            });
+        }
+        attempts.push(() => {
+            const declarations = lines
+                .map((line, index) => ({ line, number: index + 1 }))
+                .filter(({ line }) => isDeclarationLine(extension, line));
+            if (declarations.length === 0) return null;
+            const ranges = declarations.map(d => ({ startLine: d.number, endLine: d.number }));
+            const body = declarations.map(d => `${d.number}: ${d.line}`).join('\n');
+            return { mode: 'outline', ranges, content: `==> ${path} (outline; ${candidate.reason})\n${body}\n` };
+        });
+        if (candidate.reason === 'seed') {
+            attempts.push(() => {
+                // As many leading lines as fit
+                if (remaining < MIN_TRUNCATED_BYTES) return null;
+                let bytes = Buffer.byteLength(render(path, [], { startLine: 1, endLine: 1 }, `${candidate.reason}, truncated`));
+                let end = 0;
+                while (end < lines.length && bytes + Buffer.byteLength(lines[end]!) + 1 <= remaining) bytes += Buffer.byteLength(lines[end++]!) + 1;
+                if (end === 0) return null;
+                const range = { startLine: 1, endLine: end };
+                return { mode: 'truncated', ranges: [range], content: render(path, lines, range, `${candidate.reason}, truncated`) };
+            });
+        }
+
+        let placed = false;
+        for (const attempt of attempts) {
+            const chunk = attempt();
+            if (!chunk) continue;
+            const bytes = Buffer.byteLength(chunk.content);
+            if (bytes > remaining) continue;
+            entries.push({ path, reason: candidate.reason, score, depth: candidate.depth, ...chunk, bytes });
+            remaining -= bytes;
+            placed = true;
+            break;
+        }
+        if (!placed) omitted.push({ path, reason: candidate.reason, score });
+    }
+    return { entries, omitted, unresolved, totalBytes: options.maxBytes - remaining };
+}
@@ -0,0 +1,204 @@
+import { fileURLToPath } from 'url';
+import { type Diagnostic, type DiagnosticSeverity, resolveDiagnosticPath } from './index.js';
+import { parseClangOutput } from './cpp.js';
+import { parseGoBuildOutput } from './go.js';
+import { parseJavaCompilerOutput } from './java.js';
+
+// Bazel's own messages: "ERROR: /ws/pkg/BUILD.bazel:12:11: no such target '//lib:x'"
+const bazelPattern = /^(ERROR|WARNING|INFO|DEBUG): (.+?):(\d+):(\d+): (.*)$/;
+// The line Bazel prints for a failed action, whose compiler output follows it
+const failedActionPattern = /failed: \(Exit \d+\)|failed \(Exit \d+\)/;
+
+export interface BazelTarget {
+    label: string;
+    // Rule kind, e.g. go_library or cc_test
+    kind?: string;
+    status: 'built' | 'failed' | 'skipped';
+    message?: string;
+}
+
+export interface BazelTestRun {
+    run: number;
+    shard: number;
+    attempt: number;
+    status: string;
+    durationMs: number;
+    cached: boolean;
+    // Local files the test action wrote; absent when outputs only exist in a remote cache
+    logPath?: string;
+    xmlPath?: string;
+}
+
+export interface BazelTestTarget {
+    label: string;
+    // PASSED, FLAKY, FAILED, TIMEOUT, FAILED_TO_BUILD, ...
+    status: string;
+    durationMs: number;
+    runs: BazelTestRun[];
+}
+
+export interface BazelFailedAction {
+    label: string;
+    // Action mnemonic, e.g. CppCompile or GoCompilePkg
+    type?: string;
+    exitCode?: number;
+    stderrPath?: string;
+}
+
+export interface BuildEvents {
+    targets: BazelTarget[];
+    tests: BazelTestTarget[];
+    failedActions: BazelFailedAction[];
+    // BUILD_FAILURE, TESTS_FAILED, ... from the buildFinished event
+    exitCode?: { name: string; code: number };
+}
+
+function localPath(file: { uri?: string } | undefined): string | undefined {
+    // bytestream:// outputs live in a remote cache
+    if (!file?.uri?.startsWith('file://')) return undefined;
+    try {
+        return fileURLToPath(file.uri);
+    } catch {
+        return undefined;
+    }
+}
+
+function kindOf(targetKind: string | undefined): string | undefined {
+    return targetKind ? targetKind.replace(/ rule$/, '') : undefined;
+}
+
+/**
+ * Parse the Build Event Protocol stream written by --build_event_json_file
+ * (one JSON event per line) into targets, test results and failed actions.
+ * Unknown events and unparseable lines are skipped.
+ */
+export function parseBuildEvents(ndjson: string): BuildEvents {
+    const kinds = new Map<string, string>();
+    const targets = new Map<string, BazelTarget>();
+    const runs = new Map<string, BazelTestRun[]>();
+    const summaries = new Map<string, { status: string; durationMs: number }>();
+    const failedActions: BazelFailedAction[] = [];
+    let exitCode: BuildEvents['exitCode'];
+    for (const line of ndjson.split('\n')) {
+        if (!line.trim()) continue;
+        let event: any;
+        try {
+            event = JSON.parse(line);
+        } catch {
+            continue;
+        }
+        const id = event?.id ?? {};
+        if (id.targetConfigured?.label) {
+            const kind = kindOf(event.configured?.targetKind);
+            if (kind) kinds.set(id.targetConfigured.label, kind);
+        } else if (id.targetCompleted?.label) {
+            const label: string = id.targetCompleted.label;
+            if (event.aborted) {
+                targets.set(label, { label, status: 'skipped', ...(event.aborted.description ? { message: event.aborted.description } : {}) });
+            } else {
+                const message = event.completed?.failureDetail?.message;
+                targets.set(label, { label, status: event.completed?.success ? 'built' : 'failed', ...(message ? { message } : {}) });
+            }
+        } else if (id.testResult?.label) {
+            const result = event.testResult ?? {};
+            const outputs: Array<{ name?: string; uri?: string }> = result.testActionOutput ?? [];
+            const logPath = localPath(outputs.find(o => o.name === 'test.log'));
+            const xmlPath = localPath(outputs.find(o => o.name === 'test.xml'));
+            const list = runs.get(id.testResult.label) ?? [];
+            list.push({
+                run: Number(id.testResult.run ?? 1),
+                shard: Number(id.testResult.shard ?? 1),
+                attempt: Number(id.testResult.attempt ?? 1),
+                status: result.status ?? 'NO_STATUS',
+                durationMs: Number(result.testAttemptDurationMillis ?? 0),
+                cached: Boolean(result.cachedLocally || result.executionInfo?.cachedRemotely),
+                ...(logPath ? { logPath } : {}),
+                ...(xmlPath ? { xmlPath } : {}),
+            });
+            runs.set(id.testResult.label, list);
+        } else if (id.testSummary?.label) {
+            const summary = event.testSummary ?? {};
+            summaries.set(id.testSummary.label, { status: summary.overallStatus ?? 'NO_STATUS', durationMs: Number(summary.totalRunDurationMillis ?? 0) });
+        } else if (id.actionCompleted) {
+            const action = event.action ?? {};
+            if (action.success) continue;
+            const stderrPath = localPath(action.stderr);
+            failedActions.push({
+                label: action.label ?? id.actionCompleted.label ?? '',
+                ...(action.type ? { type: action.type } : {}),
+                ...(action.exitCode !== undefined ? { exitCode: Number(action.exitCode) } : {}),
+                ...(stderrPath ? { stderrPath } : {}),
+            });
+        } else if (id.buildFinished) {
+            const code = event.finished?.exitCode;
+            if (code) exitCode = { name: code.name ?? '', code: Number(code.code ?? 0) };
+        }
+    }
+    for (const [label, target] of targets) {
+        const kind = kinds.get(label);
+        if (kind) target.kind = kind;
+    }
+    const tests: BazelTestTarget[] = [];
+    for (const label of new Set([...runs.keys(), ...summaries.keys()])) {
+        const targetRuns = (runs.get(label) ?? []).sort((a, b) => a.run - b.run || a.shard - b.shard || a.attempt - b.attempt);
+        const summary = summaries.get(label);
+        tests.push({
+            label,
+            status: summary?.status ?? targetRuns[targetRuns.length - 1]?.status ?? 'NO_STATUS',
+            durationMs: summary?.durationMs || targetRuns.reduce((sum, r) => sum + r.durationMs, 0),
+            runs: targetRuns,
+        });
+    }
+    // Test targets that never got to run (their build failed) still count as tests
+    for (const target of targets.values()) {
+        if (target.kind?.endsWith('_test') && target.status !== 'built' && !summaries.has(target.label) && !runs.has(target.label)) {
+            tests.push({ label: target.label, status: target.status === 'skipped' ? 'NO_STATUS' : 'FAILED_TO_BUILD', durationMs: 0, runs: [] });
+        }
+    }
+    return {
+        targets: [...targets.values()].sort((a, b) => a.label.localeCompare(b.label)),
+        tests: tests.sort((a, b) => a.label.localeCompare(b.label)),
+        failedActions,
+        ...(exitCode ? { exitCode } : {}),
+    };
+}
+
+function severityOf(level: string): DiagnosticSeverity {
+    if (level === 'ERROR') return 'error';
+    if (level === 'WARNING') return 'warning';
+    return 'info';
+}
+
+/**
+ * Parse Bazel's console output: its own errors and warnings against BUILD
+ * and .bzl files, and the gcc/clang, javac and Go compiler output of failed
+ * actions. Paths are relative to the execution root, which mirrors the
+ * workspace. When compiler findings exist, the "Compiling ... failed" lines
+ * that introduce them are dropped as repeats.
+ */
+export function parseBazelOutput(output: string, workspaceRoot: string): Diagnostic[] {
+    const lines = output.split('\n').map(line => line.replace(/\r$/, '').replace(/\x1b\[[0-9;]*m/g, ''));
+    const bazel: Diagnostic[] = [];
+    const actionFailures: Diagnostic[] = [];
+    for (const line of lines) {
+        const match = bazelPattern.exec(line);
+        if (!match) continue;
+        const diagnostic: Diagnostic = {
+            file: resolveDiagnosticPath(match[2] ?? '', workspaceRoot),
+            line: Number(match[3]),
+            column: Number(match[4]),
+            severity: severityOf(match[1] ?? ''),
+            message: match[5] ?? '',
+            source: 'bazel',
+        };
+        (failedActionPattern.test(diagnostic.message) ? actionFailures : bazel).push(diagnostic);
+    }
+    const compilerLines = lines.filter(line => !bazelPattern.test(line)).join('\n');
+    const goLines = lines.filter(line => /^[^\s:]+\.go:\d+:\d+: /.test(line)).join('\n');
+    const compiler = [
+        ...parseClangOutput(compilerLines, workspaceRoot),
+        ...parseJavaCompilerOutput(compilerLines, workspaceRoot),
+        ...parseGoBuildOutput(goLines, workspaceRoot),
+    ];
+    return [...bazel, ...(compiler.length > 0 ? compiler : actionFailures)];
+}
@@ -0,0 +1,58 @@
+import { type Diagnostic, type DiagnosticSeverity, resolveDiagnosticPath } from './index.js';
+
+// Spectral's DiagnosticSeverity: 0 error, 1 warning, 2 information, 3 hint
+function spectralSeverity(value: unknown): DiagnosticSeverity {
+    if (value === 0) return 'error';
+    if (value === 1) return 'warning';
+    return 'info';
+}
+
+/**
+ * Parse `spectral lint -f json`, whose ranges are 0-based
+ */
+export function parseSpectralOutput(output: string, cwd: string): Diagnostic[] {
+    let findings: any;
+    try {
+        findings = JSON.parse(output);
+    } catch {
+        return [];
+    }
+    if (!Array.isArray(findings)) return [];
+    return findings.map((f: any): Diagnostic => ({
+        file: resolveDiagnosticPath(String(f.source ?? ''), cwd),
+        line: Number(f.range?.start?.line ?? 0) + 1,
+        column: Number(f.range?.start?.character ?? 0) + 1,
+        severity: spectralSeverity(f.severity),
+        message: String(f.message ?? ''),
+        ...(f.code !== undefined ? { rule: String(f.code) } : {}),
+        source: 'spectral',
+    }));
+}
+
+/**
+ * Parse `buf lint` and `buf breaking` with --error-format json (one JSON
+ * object per line); the rule or breaking-change category is the type
+ */
+export function parseBufOutput(output: string, cwd: string, severity: DiagnosticSeverity): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    for (const line of output.split('\n')) {
+        if (!line.trim().startsWith('{')) continue;
+        let finding: Record<string, any>;
+        try {
+            finding = JSON.parse(line);
+        } catch {
+            continue;
+        }
+        diagnostics.push({
+            file: resolveDiagnosticPath(String(finding.path ?? ''), cwd),
+            line: Number(finding.start_line ?? 1),
+            column: Number(finding.start_column ?? 0),
+            // A file that does not compile fails either check
+            severity: finding.type === 'COMPILE' ? 'error' : severity,
+            message: String(finding.message ?? ''),
+            ...(finding.type ? { rule: String(finding.type) } : {}),
+            source: 'buf',
+        });
+    }
+    return diagnostics;
+}
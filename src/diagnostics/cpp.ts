@@ -0,0 +1,132 @@
+import { type Diagnostic, type DiagnosticSeverity, resolveDiagnosticPath } from './index.js';
+
+// gcc/clang/clang-tidy: "src/a.cpp:12:5: error: use of undeclared identifier 'x' [clang-diagnostic-error]"
+const clangPattern = /^(.+?):(\d+):(\d+):\s+(fatal error|error|warning|note|remark):\s+(.*?)(?:\s+\[([\w.,=+-]+)\])?$/;
+// MSVC: "C:\src\a.cpp(12,5): error C2065: 'x': undeclared identifier"
+const msvcPattern = /^(.+?)\((\d+)(?:,(\d+))?\):\s+(fatal error|error|warning)\s+(\w+\d+):\s+(.*)$/;
+// CMake: "CMake Error at CMakeLists.txt:12 (find_package):" followed by indented message lines
+const cmakePattern = /^CMake (Error|Warning|Deprecation Warning)(?: \(dev\))? at (.+?):(\d+)(?: \((\w+)\))?:\s*$/;
+
+function severityOf(level: string): DiagnosticSeverity {
+    if (level.includes('error')) return 'error';
+    if (level === 'warning') return 'warning';
+    return 'info';
+}
+
+/**
+ * Parse gcc, clang, clang-tidy and MSVC diagnostics. The warning flag or
+ * clang-tidy check name becomes the rule; duplicates (the same header finding
+ * reported once per translation unit) are dropped.
+ */
+export function parseClangOutput(output: string, cwd: string, source: string = 'clang'): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    const seen = new Set<string>();
+    for (const rawLine of output.split('\n')) {
+        // Strip colors in case the build forces -fdiagnostics-color
+        const line = rawLine.replace(/\r$/, '').replace(/\x1b\[[0-9;]*m/g, '');
+        let diagnostic: Diagnostic | null = null;
+        let match = clangPattern.exec(line);
+        if (match) {
+            diagnostic = {
+                file: resolveDiagnosticPath(match[1] ?? '', cwd),
+                line: Number(match[2]),
+                column: Number(match[3]),
+                severity: severityOf(match[4] ?? ''),
+                message: match[5] ?? '',
+                source,
+                ...(match[6] ? { rule: match[6] } : {}),
+            };
+        } else if ((match = msvcPattern.exec(line))) {
+            diagnostic = {
+                file: resolveDiagnosticPath(match[1] ?? '', cwd),
+                line: Number(match[2]),
+                column: Number(match[3] ?? 0),
+                severity: severityOf(match[4] ?? ''),
+                message: match[6] ?? '',
+                rule: match[5] ?? '',
+                source: 'msvc',
+            };
+        }
+        if (!diagnostic) continue;
+        const key = `${diagnostic.file}:${diagnostic.line}:${diagnostic.column}:${diagnostic.severity}:${diagnostic.message}`;
+        if (seen.has(key)) continue;
+        seen.add(key);
+        diagnostics.push(diagnostic);
+    }
+    return diagnostics;
+}
+
+/**
+ * Parse CMake configure errors and warnings with their indented message blocks
+ */
+export function parseCMakeOutput(output: string, cwd: string): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    let current: Diagnostic | null = null;
+    for (const rawLine of output.split('\n')) {
+        const line = rawLine.replace(/\r$/, '');
+        const match = cmakePattern.exec(line);
+        if (match) {
+            current = {
+                file: resolveDiagnosticPath(match[2] ?? '', cwd),
+                line: Number(match[3]),
+                column: 0,
+                severity: match[1] === 'Error' ? 'error' : 'warning',
+                message: '',
+                source: 'cmake',
+                ...(match[4] ? { rule: match[4] } : {}),
+            };
+            diagnostics.push(current);
+        } else if (current && (/^\s/.test(line) || line === '')) {
+            const text = line.trim();
+            if (text) current.message = current.message ? `${current.message} ${text}` : text;
+        } else {
+            current = null;
+        }
+    }
+    return diagnostics;
+}
+
+// "==4242==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x602000000014 at pc ..."
+const sanitizerHeaderPattern = /^==\d+==(?:ERROR|WARNING): (\w+Sanitizer): (.*)$/;
+const SANITIZER_SOURCES: Record<string, string> = {
+    AddressSanitizer: 'asan',
+    MemorySanitizer: 'msan',
+    ThreadSanitizer: 'tsan',
+    UndefinedBehaviorSanitizer: 'ubsan',
+    LeakSanitizer: 'lsan',
+};
+// "SUMMARY: AddressSanitizer: heap-buffer-overflow /work/src/buf.c:12:5 in copy"
+const sanitizerSummaryPattern = /^SUMMARY: (\w+Sanitizer): (\S+) (.+?):(\d+)(?::(\d+))?(?: in (.+))?$/;
+
+/**
+ * Parse AddressSanitizer/MemorySanitizer/UBSan reports by their SUMMARY line.
+ * The bug kind becomes the rule; reports without a source location (leaks
+ * summarized by size, frames in stripped libraries) are skipped.
+ */
+export function parseSanitizerOutput(output: string, cwd: string): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    let header: string | null = null;
+    for (const rawLine of output.split('\n')) {
+        const line = rawLine.replace(/\r$/, '');
+        const headerMatch = sanitizerHeaderPattern.exec(line);
+        if (headerMatch) {
+            header = headerMatch[2] ?? null;
+            continue;
+        }
+        const match = sanitizerSummaryPattern.exec(line);
+        if (!match) continue;
+        const sanitizer = match[1] ?? '';
+        const kind = match[2] ?? '';
+        diagnostics.push({
+            file: resolveDiagnosticPath(match[3] ?? '', cwd),
+            line: Number(match[4]),
+            column: Number(match[5] ?? 0),
+            severity: 'error',
+            message: `${header ?? kind}${match[6] ? ` in ${match[6]}` : ''}`,
+            rule: kind,
+            source: SANITIZER_SOURCES[sanitizer] ?? sanitizer,
+        });
+        header = null;
+    }
+    return diagnostics;
+}
@@ -0,0 +1,177 @@
+import { type Diagnostic, type DiagnosticSeverity } from './index.js';
+
+export interface DockerfileInstruction {
+    // First and last line of the instruction, continuations and heredocs included
+    line: number;
+    endLine: number;
+    // Name given with FROM ... AS, else stage-N as BuildKit calls it
+    stage: string;
+    keyword: string;
+    // The instruction on one line, as BuildKit names its steps
+    text: string;
+}
+
+export interface BuildStep {
+    id: number;
+    // [build 2/4] RUN go build ./..., or [internal] load build definition from Dockerfile
+    name: string;
+    stage?: string;
+    instruction?: string;
+    cached: boolean;
+    duration?: number;
+    error?: string;
+    // The step's own output, without BuildKit's timestamps
+    output: string[];
+}
+
+export interface BuildKitResult {
+    steps: BuildStep[];
+    // ERROR: failed to solve: ...
+    failure?: string;
+    // The Dockerfile location BuildKit quotes for the failure
+    failureFile?: string;
+    failureLine?: number;
+    // Build checks: " - FromAsCasing: 'as' and 'FROM' keywords' casing do not match (line 1)"
+    checks: Array<{ rule: string; message: string; line: number }>;
+}
+
+const collapse = (text: string) => text.replace(/\s+/g, ' ').trim();
+
+/**
+ * Split a Dockerfile into instructions, joining continuation lines and
+ * skipping heredoc bodies, comments and parser directives.
+ */
+export function parseDockerfile(source: string): DockerfileInstruction[] {
+    const lines = source.split('\n').map(l => l.replace(/\r$/, ''));
+    const escape = /^#\s*escape\s*=\s*([\\`])/im.exec(lines.slice(0, 5).join('\n'))?.[1] ?? '\\';
+    const instructions: DockerfileInstruction[] = [];
+    let stageIndex = -1;
+    let stage = 'stage-0';
+    for (let k = 0; k < lines.length; k++) {
+        const first = lines[k]!;
+        if (first.trim() === '' || first.trim().startsWith('#')) continue;
+        const start = k;
+        const parts = [first];
+        while (parts[parts.length - 1]!.trimEnd().endsWith(escape) && k + 1 < lines.length) {
+            parts[parts.length - 1] = parts[parts.length - 1]!.trimEnd().slice(0, -1);
+            k++;
+            // Comment lines inside a continued instruction are dropped
+            if (!lines[k]!.trim().startsWith('#')) parts.push(lines[k]!);
+        }
+        const text = collapse(parts.join(' '));
+        // RUN <<EOF ... EOF
+        for (const heredoc of text.matchAll(/<<-?["']?(\w+)["']?/g)) {
+            while (k + 1 < lines.length && lines[k + 1]!.trim() !== heredoc[1]) k++;
+            k++;
+        }
+        const keyword = text.split(' ')[0]!.toUpperCase();
+        if (keyword === 'FROM') {
+            stageIndex++;
+            stage = /\sAS\s+(\S+)\s*$/i.exec(text)?.[1] ?? `stage-${stageIndex}`;
+        }
+        instructions.push({ line: start + 1, endLine: Math.min(k, lines.length - 1) + 1, stage, keyword, text });
+    }
+    return instructions;
+}
+
+const STEP_LINE = /^#(\d+) (.*)$/;
+const STEP_NAME = /^\[(?:(\S+) )?\d+\/\d+\] (.+)$/;
+
+/**
+ * Parse docker build --progress=plain output into its steps (with their
+ * output, cache hits, durations and errors), the final failure and the
+ * Dockerfile line BuildKit points at for it, and build check warnings.
+ */
+export function parseBuildKitOutput(output: string): BuildKitResult {
+    const steps = new Map<number, BuildStep>();
+    const result: BuildKitResult = { steps: [], checks: [] };
+    const lines = output.split('\n').map(l => l.replace(/\r$/, '').replace(/\x1b\[[0-9;]*m/g, ''));
+    for (let k = 0; k < lines.length; k++) {
+        const line = lines[k]!;
+        const match = STEP_LINE.exec(line);
+        if (match) {
+            const id = Number(match[1]);
+            const rest = match[2]!;
+            let step = steps.get(id);
+            if (!step) {
+                const named = STEP_NAME.exec(rest);
+                step = {
+                    id,
+                    name: rest,
+                    cached: false,
+                    output: [],
+                    ...(named ? { ...(named[1] ? { stage: named[1] } : {}), instruction: named[2]! } : {}),
+                };
+                steps.set(id, step);
+                continue;
+            }
+            if (rest === 'CACHED') step.cached = true;
+            else if (/^DONE [\d.]+s$/.test(rest)) step.duration = Number(rest.slice(5, -1));
+            else if (rest.startsWith('ERROR: ')) step.error = rest.slice(7);
+            else if (rest === 'CANCELED' || rest.startsWith('...')) continue;
+            else {
+                const text = /^[\d.]+ (.*)$/.exec(rest);
+                if (text) step.output.push(text[1]!);
+            }
+            continue;
+        }
+        // Dockerfile:8 followed by a quoted snippet of the Dockerfile
+        const location = /^(\S+):(\d+)$/.exec(line);
+        if (location && /^-{10,}$/.test(lines[k + 1] ?? '')) {
+            result.failureFile = location[1]!;
+            result.failureLine = Number(location[2]);
+            continue;
+        }
+        const failure = /^ERROR: (?:failed to build: )?(?:failed to solve: )?(.*)$/.exec(line);
+        if (failure) {
+            result.failure = failure[1]!;
+            const parseError = /dockerfile parse error on line (\d+)/i.exec(failure[1]!);
+            if (parseError && result.failureLine === undefined) result.failureLine = Number(parseError[1]);
+            continue;
+        }
+        const check = /^ - (\w+): (.*) \(line (\d+)\)$/.exec(line);
+        if (check) result.checks.push({ rule: check[1]!, message: check[2]!, line: Number(check[3]) });
+    }
+    result.steps = [...steps.values()].sort((a, b) => a.id - b.id);
+    return result;
+}
+
+/**
+ * The Dockerfile instruction a BuildKit step ran, matched by its text and
+ * preferring the step's stage
+ */
+export function findInstruction(instructions: DockerfileInstruction[], step: BuildStep): DockerfileInstruction | undefined {
+    if (!step.instruction) return undefined;
+    const text = collapse(step.instruction);
+    const candidates = instructions.filter(i => i.text === text || i.text.startsWith(text));
+    return candidates.find(i => step.stage === undefined || i.stage === step.stage) ?? candidates[0];
+}
+
+function hadolintSeverity(level: string): DiagnosticSeverity {
+    if (level === 'error') return 'error';
+    if (level === 'warning') return 'warning';
+    return 'info';
+}
+
+/**
+ * Parse hadolint --format json output; file replaces the name hadolint
+ * reports, which is "-" when the Dockerfile came on stdin.
+ */
+export function parseHadolintOutput(output: string, file: string): Diagnostic[] {
+    let findings: any[];
+    try {
+        findings = JSON.parse(output);
+    } catch {
+        return [];
+    }
+    if (!Array.isArray(findings)) return [];
+    return findings.map(f => ({
+        file,
+        line: Number(f.line) || 1,
+        column: Number(f.column) || 0,
+        severity: hadolintSeverity(String(f.level)),
+        message: String(f.message),
+        rule: String(f.code),
+        source: 'hadolint',
+    }));
+}
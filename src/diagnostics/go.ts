@@ -0,0 +1,304 @@
+import { type Diagnostic, parseLocationLines, resolveDiagnosticPath } from './index.js';
+import { basename } from 'path';
+import { splitJsonObjects } from '../utils/json.js';
+
+/**
+ * Parse `go build` compiler errors
+ */
+export function parseGoBuildOutput(output: string, cwd: string): Diagnostic[] {
+    return parseLocationLines(output, { cwd, source: 'go build', severity: 'error' });
+}
+
+/**
+ * Parse `gopls check` output
+ */
+export function parseGoplsCheckOutput(output: string, cwd: string): Diagnostic[] {
+    return parseLocationLines(output, { cwd, source: 'gopls', severity: 'warning' });
+}
+
+/**
+ * Parse `go vet -json` output; the analyzer name becomes the diagnostic rule.
+ * Falls back to plain "file:line:col: message" lines for non-JSON output.
+ */
+export function parseGoVetOutput(output: string, cwd: string): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    let sawJson = false;
+    for (const chunk of splitJsonObjects(output)) {
+        let parsed: Record<string, Record<string, any>>;
+        try {
+            parsed = JSON.parse(chunk);
+        } catch {
+            continue;
+        }
+        sawJson = true;
+        for (const analyzers of Object.values(parsed)) {
+            for (const [analyzer, findings] of Object.entries(analyzers)) {
+                if (!Array.isArray(findings)) {
+                    // Package-level errors (e.g. type-check failures) come as { error: "..." }
+                    if (findings && typeof findings.error === 'string') {
+                        diagnostics.push(...parseLocationLines(findings.error, { cwd, source: 'go vet' }));
+                    }
+                    continue;
+                }
+                for (const finding of findings) {
+                    const posn = /^(.*?):(\d+)(?::(\d+))?$/.exec(String(finding.posn ?? ''));
+                    const [, file = '', line = '0', column = '0'] = posn ?? [];
+                    diagnostics.push({
+                        file: resolveDiagnosticPath(file, cwd),
+                        line: Number(line),
+                        column: Number(column),
+                        severity: 'warning',
+                        message: String(finding.message ?? ''),
+                        rule: analyzer,
+                        source: 'go vet',
+                    });
+                }
+            }
+        }
+    }
+    if (!sawJson) {
+        // Type-check failures are printed as "vet: file:line:col: message"
+        const typeErrors = output.split('\n').filter(line => line.startsWith('vet: ')).map(line => line.slice(5)).join('\n');
+        if (typeErrors) {
+            return parseLocationLines(typeErrors, { cwd, source: 'go vet', severity: 'error' });
+        }
+        return parseLocationLines(output, { cwd, source: 'go vet', severity: 'warning' });
+    }
+    return diagnostics;
+}
+
+/**
+ * Parse golangci-lint JSON output (`--out-format json` in v1, `--output.json.path stdout` in v2);
+ * the reporting linter becomes the diagnostic rule
+ */
+export function parseGolangciLintOutput(output: string, cwd: string): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    for (const chunk of splitJsonObjects(output)) {
+        let parsed: { Issues?: any[] | null };
+        try {
+            parsed = JSON.parse(chunk);
+        } catch {
+            continue;
+        }
+        if (!parsed || !('Issues' in parsed)) continue;
+        for (const issue of parsed.Issues ?? []) {
+            const severity = String(issue.Severity ?? '').toLowerCase();
+            diagnostics.push({
+                file: resolveDiagnosticPath(String(issue.Pos?.Filename ?? ''), cwd),
+                line: Number(issue.Pos?.Line ?? 0),
+                column: Number(issue.Pos?.Column ?? 0),
+                severity: severity === 'error' ? 'error' : severity === 'info' ? 'info' : 'warning',
+                message: String(issue.Text ?? ''),
+                rule: String(issue.FromLinter ?? ''),
+                source: 'golangci-lint',
+                // v1 attaches a Replacement, v2 SuggestedFixes
+                ...(issue.Replacement || (Array.isArray(issue.SuggestedFixes) && issue.SuggestedFixes.length > 0) ? { fixable: true } : {}),
+            });
+        }
+    }
+    return diagnostics;
+}
+
+export interface GoStackFrame {
+    function: string;
+    file: string;
+    line: number;
+}
+
+export interface GoRaceAccess {
+    kind: 'read' | 'write';
+    // The earlier of the two conflicting accesses
+    previous: boolean;
+    atomic: boolean;
+    address: string;
+    // The main goroutine is reported as goroutine 1
+    goroutine: number;
+    stack: GoStackFrame[];
+}
+
+export interface GoRaceGoroutine {
+    id: number;
+    state: string;
+    createdAt: GoStackFrame[];
+}
+
+export interface GoRaceReport {
+    accesses: GoRaceAccess[];
+    goroutines: GoRaceGoroutine[];
+    // First frame of the current access in user code
+    file?: string;
+    line?: number;
+    // Test during which the race was detected, filled in by the caller
+    test?: string;
+}
+
+// "Previous write at 0x00c0000182a8 by goroutine 9:", "Read at 0x... by main goroutine:"
+const raceAccessPattern = /^(Previous )?(atomic )?(read|write) at (0x[0-9a-f]+) by (?:main goroutine|goroutine (\d+)):$/i;
+const raceGoroutinePattern = /^Goroutine (\d+) \(([^)]+)\) created at:$/;
+const stackFilePattern = /^\s+(\S.*?):(\d+)(?: \+0x[0-9a-f]+)?$/;
+
+// Frames in the runtime and standard library say little about where the race is
+function isRuntimeFrame(frame: GoStackFrame): boolean {
+    return /^(runtime|testing|sync|internal)[./]/.test(frame.function);
+}
+
+/**
+ * Parse race detector reports ("WARNING: DATA RACE" blocks) into the
+ * conflicting accesses with their stacks and the goroutines involved
+ */
+export function parseGoRaceReports(output: string): GoRaceReport[] {
+    const reports: GoRaceReport[] = [];
+    let report: GoRaceReport | null = null;
+    let stack: GoStackFrame[] | null = null;
+    let pendingFunction: string | null = null;
+    for (const rawLine of output.split('\n')) {
+        const line = rawLine.replace(/\r$/, '');
+        if (line === 'WARNING: DATA RACE') {
+            report = { accesses: [], goroutines: [] };
+            reports.push(report);
+            stack = null;
+            continue;
+        }
+        if (!report) continue;
+        if (line.startsWith('==================')) {
+            report = null;
+            continue;
+        }
+        let match = raceAccessPattern.exec(line);
+        if (match) {
+            const access: GoRaceAccess = {
+                kind: match[3]?.toLowerCase() === 'read' ? 'read' : 'write',
+                previous: Boolean(match[1]),
+                atomic: Boolean(match[2]),
+                address: match[4] ?? '',
+                goroutine: match[5] ? Number(match[5]) : 1,
+                stack: [],
+            };
+            report.accesses.push(access);
+            stack = access.stack;
+            continue;
+        }
+        if ((match = raceGoroutinePattern.exec(line))) {
+            const goroutine: GoRaceGoroutine = { id: Number(match[1]), state: match[2] ?? '', createdAt: [] };
+            report.goroutines.push(goroutine);
+            stack = goroutine.createdAt;
+            continue;
+        }
+        if (!stack) continue;
+        const fileMatch = stackFilePattern.exec(line);
+        if (fileMatch && pendingFunction !== null) {
+            stack.push({ function: pendingFunction, file: fileMatch[1] ?? '', line: Number(fileMatch[2]) });
+            pendingFunction = null;
+        } else if (/^  \S/.test(line)) {
+            pendingFunction = line.trim().replace(/\(\)$/, '');
+        }
+    }
+    for (const race of reports) {
+        const current = race.accesses.find(a => !a.previous) ?? race.accesses[0];
+        const frame = current?.stack.find(f => !isRuntimeFrame(f)) ?? current?.stack[0];
+        if (frame) {
+            race.file = frame.file;
+            race.line = frame.line;
+        }
+    }
+    return reports;
+}
+
+function describeAccess(access: GoRaceAccess): string {
+    const frame = access.stack.find(f => !isRuntimeFrame(f)) ?? access.stack[0];
+    const where = frame ? ` at ${basename(frame.file)}:${frame.line}` : '';
+    return `${access.atomic ? 'atomic ' : ''}${access.kind} by goroutine ${access.goroutine}${where}`;
+}
+
+/**
+ * One error diagnostic per race, at the current access
+ */
+export function raceReportDiagnostics(reports: GoRaceReport[], cwd: string): Diagnostic[] {
+    return reports.filter(r => r.file).map(race => {
+        const current = race.accesses.find(a => !a.previous);
+        const previous = race.accesses.find(a => a.previous);
+        let message = 'data race';
+        if (current) message += `: ${describeAccess(current)}`;
+        if (previous) message += ` conflicts with previous ${describeAccess(previous)}`;
+        if (race.test) message += ` (in ${race.test})`;
+        return {
+            file: resolveDiagnosticPath(race.file ?? '', cwd),
+            line: race.line ?? 0,
+            column: 0,
+            severity: 'error' as const,
+            message,
+            rule: 'data-race',
+            source: 'go race',
+        };
+    });
+}
+
+export interface GoFuzzFailure {
+    target: string;
+    message: string;
+    // Where the failure was reported: the t.Error call, or the first user frame of a panic
+    file?: string;
+    line?: number;
+    stack: GoStackFrame[];
+    // Crasher file go test wrote, relative to the package: testdata/fuzz/FuzzX/<hash>
+    inputFile?: string;
+    // Seed corpus entry that failed before fuzzing started: FuzzX/seed#0
+    seed?: string;
+}
+
+const fuzzLogPattern = /^\s+([\w.\-/]+\.go):(\d+): (.*)$/;
+
+// The frames a panic passes through on its way to the test
+function isFuzzHarnessFrame(frame: GoStackFrame): boolean {
+    return isRuntimeFrame(frame) || /^(panic|reflect[./]|created by )/.test(frame.function);
+}
+
+/**
+ * Parse the failure `go test -fuzz` reports: the failing target, its
+ * message (t.Error text or the panic), the panic's stack, and the
+ * minimized crasher it wrote to testdata/fuzz
+ */
+export function parseGoFuzzFailure(output: string): GoFuzzFailure | null {
+    const lines = output.split('\n').map(l => l.replace(/\r$/, ''));
+    const start = lines.findIndex(l => /^--- FAIL: Fuzz\w+/.test(l));
+    if (start < 0) return null;
+    const failure: GoFuzzFailure = { target: /^--- FAIL: (Fuzz\w+)/.exec(lines[start]!)![1]!, message: '', stack: [] };
+    let pendingFunction: string | null = null;
+    let inPanic = false;
+    for (const line of lines.slice(start + 1)) {
+        let match: RegExpExecArray | null;
+        if ((match = /^\s+Failing input written to (\S+)/.exec(line))) {
+            failure.inputFile = match[1]!;
+        } else if (!failure.message && (match = fuzzLogPattern.exec(line))) {
+            // A panic is logged by the testing package, with its stack indented below
+            inPanic = match[3]!.startsWith('panic: ') && match[1] === 'testing.go';
+            failure.message = match[3]!;
+            if (!inPanic) {
+                failure.file = match[1]!;
+                failure.line = Number(match[2]);
+            }
+        } else if (inPanic) {
+            const fileMatch = stackFilePattern.exec(line);
+            if (fileMatch && pendingFunction !== null) {
+                failure.stack.push({ function: pendingFunction, file: fileMatch[1] ?? '', line: Number(fileMatch[2]) });
+                pendingFunction = null;
+            } else if (/^\s+\S/.test(line) && !/^\s+goroutine \d+ \[/.test(line)) {
+                pendingFunction = line.trim().replace(/\([^()]*\)$/, '');
+            } else if (!line.trim()) {
+                inPanic = false;
+            }
+        } else if (!failure.message && line.trim() && !/^\s*(--- FAIL|=== |FAIL|exit status|ok\s)/.test(line)) {
+            // Failures outside the test function, such as a fuzzing process that hung
+            failure.message = line.trim();
+        }
+    }
+    // Printed while gathering baseline coverage, before the failure itself
+    const seed = /failure while testing seed corpus entry: (\S+)/.exec(output);
+    if (seed) failure.seed = seed[1]!;
+    const frame = failure.stack.find(f => !isFuzzHarnessFrame(f));
+    if (frame) {
+        failure.file = frame.file;
+        failure.line = frame.line;
+    }
+    return failure;
+}
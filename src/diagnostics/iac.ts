@@ -0,0 +1,175 @@
+import { type Diagnostic, type DiagnosticSeverity, resolveDiagnosticPath } from './index.js';
+import { locateYamlPath, parsePointer } from '../utils/pointer.js';
+
+// Line of a Kubernetes resource, or of a field in it, in a manifest file
+export type ResourceLocator = (file: string, kind: string, name: string, pointer?: string) => number;
+
+const noLocation: ResourceLocator = () => 1;
+
+function parseJson(output: string): any {
+    try {
+        return JSON.parse(output);
+    } catch {
+        return null;
+    }
+}
+
+function toSeverity(value: unknown): DiagnosticSeverity {
+    const severity = String(value ?? '').toLowerCase();
+    if (severity === 'error') return 'error';
+    if (severity === 'warning') return 'warning';
+    return 'info';
+}
+
+const escapeRegExp = (text: string) => text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+
+/**
+ * The 1-based line of a resource in a multi-document YAML manifest, found
+ * by kind and metadata.name, and of a field in it given as a JSON pointer
+ * (/spec/template/spec/containers/0/image). Falls back to the deepest part
+ * of the pointer that was found.
+ */
+export function locateK8sResource(source: string, kind: string, name: string, pointer: string = ''): number {
+    const lines = source.split('\n').map(l => l.replace(/\r$/, ''));
+    const documents: Array<[number, number]> = [];
+    let start = 0;
+    lines.forEach((line, k) => {
+        if (/^---/.test(line)) {
+            documents.push([start, k]);
+            start = k + 1;
+        }
+    });
+    documents.push([start, lines.length]);
+    const kindLine = new RegExp(`^kind:\\s*["']?${escapeRegExp(kind)}["']?\\s*$`);
+    const nameLine = new RegExp(`^\\s+name:\\s*["']?${escapeRegExp(name)}["']?\\s*$`);
+    const document = documents.find(([from, to]) => {
+        const text = lines.slice(from, to);
+        return text.some(l => kindLine.test(l)) && (!name || text.some(l => nameLine.test(l)));
+    }) ?? documents[0]!;
+
+    return locateYamlPath(lines, document[0], document[1], parsePointer(pointer)) + 1;
+}
+
+/**
+ * Parse `kubeconform -output json`: one diagnostic per schema violation,
+ * placed on the field when locate can find it
+ */
+export function parseKubeconformOutput(output: string, cwd: string, locate: ResourceLocator = noLocation): Diagnostic[] {
+    const report = parseJson(output);
+    const diagnostics: Diagnostic[] = [];
+    for (const resource of report?.resources ?? []) {
+        if (resource.status !== 'statusInvalid' && resource.status !== 'statusError') continue;
+        // YAML that is not a Kubernetes manifest: CI configuration, Helm values
+        if (/missing '(kind|apiVersion)' key/.test(String(resource.msg ?? ''))) continue;
+        const file = resolveDiagnosticPath(String(resource.filename ?? ''), cwd);
+        const kind = String(resource.kind ?? '');
+        const name = String(resource.name ?? '');
+        const label = kind ? `${kind} ${name}`.trim() : '';
+        const violations: Array<{ path?: string; msg?: string }> = resource.validationErrors?.length ? resource.validationErrors : [{ msg: resource.msg }];
+        for (const violation of violations) {
+            diagnostics.push({
+                file,
+                line: locate(file, kind, name, violation.path ?? ''),
+                column: 0,
+                severity: 'error',
+                message: `${label ? `${label}: ` : ''}${violation.path ? `${violation.path}: ` : ''}${violation.msg ?? resource.msg ?? 'invalid'}`,
+                rule: 'schema',
+                source: 'kubeconform',
+            });
+        }
+    }
+    return diagnostics;
+}
+
+/**
+ * Parse `kubeval -o json`, whose errors name the field in dotted form
+ * (spec.replicas: Invalid type...)
+ */
+export function parseKubevalOutput(output: string, cwd: string, locate: ResourceLocator = noLocation): Diagnostic[] {
+    const report = parseJson(output);
+    const diagnostics: Diagnostic[] = [];
+    for (const resource of Array.isArray(report) ? report : []) {
+        if (resource.status !== 'invalid') continue;
+        const file = resolveDiagnosticPath(String(resource.filename ?? ''), cwd);
+        const kind = String(resource.kind ?? '');
+        for (const error of resource.errors ?? []) {
+            const field = /^([\w.-]+): /.exec(String(error))?.[1];
+            const pointer = field && field !== '(root)' ? `/${field.split('.').join('/')}` : '';
+            diagnostics.push({
+                file,
+                line: locate(file, kind, '', pointer),
+                column: 0,
+                severity: 'error',
+                message: `${kind ? `${kind}: ` : ''}${error}`,
+                rule: 'schema',
+                source: 'kubeval',
+            });
+        }
+    }
+    return diagnostics;
+}
+
+/**
+ * Parse `kube-linter lint --format json`; the check name becomes the rule
+ * and its remediation is appended to the message
+ */
+export function parseKubeLinterOutput(output: string, cwd: string, locate: ResourceLocator = noLocation): Diagnostic[] {
+    const report = parseJson(output);
+    return (report?.Reports ?? []).map((r: any): Diagnostic => {
+        const file = resolveDiagnosticPath(String(r.Object?.Metadata?.FilePath ?? ''), cwd);
+        const object = r.Object?.K8sObject ?? {};
+        const kind = String(object.GroupVersionKind?.Kind ?? '');
+        const name = String(object.Name ?? '');
+        return {
+            file,
+            line: locate(file, kind, name),
+            column: 0,
+            severity: 'warning',
+            message: `${kind} ${name}: ${r.Diagnostic?.Message ?? ''}${r.Remediation ? ` (${r.Remediation})` : ''}`,
+            rule: String(r.Check ?? ''),
+            source: 'kube-linter',
+        };
+    });
+}
+
+/**
+ * Parse `terraform validate -json`; the summary and detail are joined
+ */
+export function parseTerraformValidateOutput(output: string, cwd: string): Diagnostic[] {
+    const report = parseJson(output);
+    return (report?.diagnostics ?? []).map((d: any): Diagnostic => ({
+        file: d.range?.filename ? resolveDiagnosticPath(String(d.range.filename), cwd) : cwd,
+        line: Number(d.range?.start?.line ?? 1),
+        column: Number(d.range?.start?.column ?? 0),
+        severity: toSeverity(d.severity),
+        message: d.detail ? `${d.summary}: ${d.detail}` : String(d.summary ?? ''),
+        source: 'terraform',
+    }));
+}
+
+/**
+ * Parse `tflint --format json`: rule issues, plus errors tflint hit while
+ * loading the configuration
+ */
+export function parseTflintOutput(output: string, cwd: string): Diagnostic[] {
+    const report = parseJson(output);
+    const issues = (report?.issues ?? []).map((issue: any): Diagnostic => ({
+        file: resolveDiagnosticPath(String(issue.range?.filename ?? ''), cwd),
+        line: Number(issue.range?.start?.line ?? 1),
+        column: Number(issue.range?.start?.column ?? 0),
+        // tflint's "notice" is the lowest level
+        severity: toSeverity(issue.rule?.severity),
+        message: String(issue.message ?? ''),
+        ...(issue.rule?.name ? { rule: String(issue.rule.name) } : {}),
+        source: 'tflint',
+    }));
+    const errors = (report?.errors ?? []).map((error: any): Diagnostic => ({
+        file: error.range?.filename ? resolveDiagnosticPath(String(error.range.filename), cwd) : cwd,
+        line: Number(error.range?.start?.line ?? 1),
+        column: Number(error.range?.start?.column ?? 0),
+        severity: 'error',
+        message: String(error.message ?? ''),
+        source: 'tflint',
+    }));
+    return [...issues, ...errors];
+}
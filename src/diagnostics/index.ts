@@ -0,0 +1,88 @@
+import { isAbsolute, resolve } from 'path';
+
+export type DiagnosticSeverity = 'error' | 'warning' | 'info';
+
+/**
+ * A single compiler/linter finding tied to a source location
+ */
+export interface Diagnostic {
+    file: string;
+    line: number;
+    column: number;
+    severity: DiagnosticSeverity;
+    message: string;
+    rule?: string;
+    source: string;
+    // The linter can fix it automatically (golangci-lint --fix, ruff --fix, eslint --fix)
+    fixable?: boolean;
+}
+
+// Messages differ run to run in their numbers (counts, positions, lengths)
+export function normalizeMessage(message: string): string {
+    return message.replace(/\d+/g, '#').replace(/\s+/g, ' ').trim();
+}
+
+// file:line[:col][-endcol]: message
+const locationLinePattern = /^(.+?):(\d+)(?::(\d+))?(?:-\d+(?::\d+)?)?:\s+(.*)$/;
+
+/**
+ * Resolve a reported file path against the directory the tool ran in
+ */
+export function resolveDiagnosticPath(file: string, cwd: string): string {
+    return isAbsolute(file) ? file : resolve(cwd, file);
+}
+
+/**
+ * Parse generic "file:line:col: message" output, one finding per line
+ */
+export function parseLocationLines(
+    output: string,
+    options: { cwd: string; source: string; severity?: DiagnosticSeverity }
+): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    for (const rawLine of output.split('\n')) {
+        const line = rawLine.trim();
+        if (!line || line.startsWith('#')) continue;
+        const match = locationLinePattern.exec(line);
+        if (!match) {
+            // Tab-indented continuation lines belong to the previous finding
+            const previous = diagnostics[diagnostics.length - 1];
+            if (previous && rawLine.startsWith('\t')) {
+                previous.message += `\n${line}`;
+            }
+            continue;
+        }
+        const [, file = '', lineNo = '0', column, message = ''] = match;
+        diagnostics.push({
+            file: resolveDiagnosticPath(file, options.cwd),
+            line: Number(lineNo),
+            column: column ? Number(column) : 0,
+            severity: options.severity ?? 'error',
+            message,
+            source: options.source,
+        });
+    }
+    return diagnostics;
+}
+
+/**
+ * Count diagnostics by severity
+ */
+export function countBySeverity(diagnostics: Diagnostic[]): Record<DiagnosticSeverity, number> {
+    const counts: Record<DiagnosticSeverity, number> = { error: 0, warning: 0, info: 0 };
+    for (const diagnostic of diagnostics) {
+        counts[diagnostic.severity]++;
+    }
+    return counts;
+}
+
+export * from './go.js';
+export * from './python.js';
+export * from './typescript.js';
+export * from './java.js';
+export * from './cpp.js';
+export * from './bazel.js';
+export * from './tests.js';
+export * from './docker.js';
+export * from './iac.js';
+export * from './contracts.js';
@@ -0,0 +1,54 @@
+import { type Diagnostic, type DiagnosticSeverity, resolveDiagnosticPath } from './index.js';
+
+// Maven compiler plugin: "[ERROR] /src/Foo.java:[12,8] cannot find symbol"
+const mavenPattern = /^\[(ERROR|WARNING)\]\s+(?:(?:COMPILATION ERROR|-+)\s*:?\s*)?(.+?\.(?:java|kt|scala|groovy)):\[(\d+),(\d+)\]\s+(.*)$/;
+// javac as run by Gradle or directly: "/src/Foo.java:12: error: cannot find symbol"
+const javacPattern = /^(.+?\.java):(\d+):\s+(error|warning):\s+(.*)$/;
+// kotlinc: "e: file:///src/Foo.kt:12:8 Unresolved reference: bar"
+const kotlinPattern = /^([ew]):\s+(?:file:\/\/)?(.+?\.kts?):(\d+):(\d+)\s+(.*)$/;
+
+/**
+ * Parse javac/kotlinc errors as printed by Maven or Gradle. Follow-up lines
+ * ("symbol: ...", "location: ...") are appended to the message.
+ */
+export function parseJavaCompilerOutput(output: string, cwd: string): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    // Maven prints COMPILATION ERROR summaries that repeat the findings
+    const seen = new Set<string>();
+    let last: Diagnostic | null = null;
+    const push = (file: string, line: number, column: number, severity: DiagnosticSeverity, message: string, source: string): Diagnostic | null => {
+        const resolved = resolveDiagnosticPath(file.trim(), cwd);
+        const key = `${resolved}:${line}:${column}:${message}`;
+        if (seen.has(key)) return null;
+        seen.add(key);
+        const diagnostic: Diagnostic = { file: resolved, line, column, severity, message: message.trim(), source };
+        diagnostics.push(diagnostic);
+        return diagnostic;
+    };
+    for (const rawLine of output.split('\n')) {
+        const line = rawLine.replace(/\r$/, '');
+        let match = mavenPattern.exec(line);
+        if (match) {
+            last = push(match[2] ?? '', Number(match[3]), Number(match[4]), match[1] === 'ERROR' ? 'error' : 'warning', match[5] ?? '', 'javac');
+            continue;
+        }
+        match = javacPattern.exec(line);
+        if (match) {
+            last = push(match[1] ?? '', Number(match[2]), 0, match[3] === 'error' ? 'error' : 'warning', match[4] ?? '', 'javac');
+            continue;
+        }
+        match = kotlinPattern.exec(line);
+        if (match) {
+            last = push(match[2] ?? '', Number(match[3]), Number(match[4]), match[1] === 'e' ? 'error' : 'warning', match[5] ?? '', 'kotlinc');
+            continue;
+        }
+        const detail = /^\s*(?:\[ERROR\]\s*)?((?:symbol|location|required|found|reason)\s*:.*)$/.exec(line);
+        if (detail && last) {
+            last.message += `; ${(detail[1] ?? '').trim().replace(/\s+/g, ' ')}`;
+        } else if (line.trim() && !/^\s/.test(line)) {
+            // Indented lines are the echoed source and caret; anything else ends the finding
+            last = null;
+        }
+    }
+    return diagnostics;
+}
@@ -0,0 +1,66 @@
+import type { TestCaseResult } from './tests.js';
+
+// Characters XML 1.0 cannot carry at all, even escaped
+const INVALID_XML = /[^\x09\x0A\x0D\x20-\uD7FF\uE000-\uFFFD\u{10000}-\u{10FFFF}]/gu;
+
+function escapeAttribute(value: string): string {
+    return value.replace(INVALID_XML, '')
+        .replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;')
+        .replace(/"/g, '&quot;').replace(/\n/g, '&#10;').replace(/\r/g, '&#13;').replace(/\t/g, '&#9;');
+}
+
+// "]]>" cannot appear inside CDATA; split it across two sections
+function cdata(value: string): string {
+    return `<![CDATA[${value.replace(INVALID_XML, '').replace(/]]>/g, ']]]]><![CDATA[>')}]]>`;
+}
+
+function seconds(ms: number): string {
+    return (ms / 1000).toFixed(3);
+}
+
+function attributes(values: Record<string, string | number | undefined>): string {
+    return Object.entries(values)
+        .filter(([, value]) => value !== undefined)
+        .map(([key, value]) => ` ${key}="${escapeAttribute(String(value))}"`)
+        .join('');
+}
+
+function testcase(test: TestCaseResult): string {
+    // No line attribute: the one reader that writes it (pytest xunit1) counts from 0, others ignore it
+    const open = `    <testcase${attributes({ classname: test.suite, name: test.name, time: seconds(test.durationMs), file: test.file })}`;
+    const children: string[] = [];
+    if (test.status === 'failed' || test.status === 'error') {
+        const element = test.status === 'failed' ? 'failure' : 'error';
+        const message = attributes({ message: test.message?.split('\n')[0] ?? test.status });
+        children.push(test.details ? `      <${element}${message}>${cdata(test.details)}</${element}>` : `      <${element}${message}/>`);
+    } else if (test.status === 'skipped') {
+        children.push(`      <skipped${attributes({ message: test.message })}/>`);
+    }
+    if (test.output) children.push(`      <system-out>${cdata(test.output)}</system-out>`);
+    return children.length > 0 ? `${open}>\n${children.join('\n')}\n    </testcase>` : `${open}/>`;
+}
+
+/**
+ * Serialize test results as JUnit XML (the surefire dialect most CI systems
+ * read): a <testsuite> per suite, in first-seen order, under one <testsuites>
+ */
+export function toJUnitXml(tests: TestCaseResult[], options: { name?: string } = {}): string {
+    const suites = new Map<string, TestCaseResult[]>();
+    for (const test of tests) suites.set(test.suite, [...(suites.get(test.suite) ?? []), test]);
+
+    const count = (cases: TestCaseResult[]) => ({
+        tests: cases.length,
+        failures: cases.filter(t => t.status === 'failed').length,
+        errors: cases.filter(t => t.status === 'error').length,
+        skipped: cases.filter(t => t.status === 'skipped').length,
+        time: seconds(cases.reduce((sum, t) => sum + t.durationMs, 0)),
+    });
+    const lines = ['<?xml version="1.0" encoding="UTF-8"?>', `<testsuites${attributes({ name: options.name ?? 'code-feedback', ...count(tests) })}>`];
+    for (const [suite, cases] of suites) {
+        lines.push(`  <testsuite${attributes({ name: suite, ...count(cases) })}>`);
+        lines.push(...cases.map(testcase));
+        lines.push('  </testsuite>');
+    }
+    lines.push('</testsuites>');
+    return lines.join('\n') + '\n';
+}
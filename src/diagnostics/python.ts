@@ -0,0 +1,113 @@
+import { type Diagnostic, type DiagnosticSeverity, resolveDiagnosticPath } from './index.js';
+
+function toSeverity(value: unknown): DiagnosticSeverity {
+    const severity = String(value ?? '').toLowerCase();
+    if (severity === 'error') return 'error';
+    if (severity === 'warning') return 'warning';
+    return 'info';
+}
+
+/**
+ * Parse `mypy --output json` (one JSON object per line); the error code becomes the rule
+ */
+export function parseMypyJsonOutput(output: string, cwd: string): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    for (const line of output.split('\n')) {
+        if (!line.trim().startsWith('{')) continue;
+        let finding: Record<string, any>;
+        try {
+            finding = JSON.parse(line);
+        } catch {
+            continue;
+        }
+        const hint = finding.hint ? `\n${finding.hint}` : '';
+        diagnostics.push({
+            file: resolveDiagnosticPath(String(finding.file ?? ''), cwd),
+            line: Number(finding.line ?? 0),
+            // mypy columns are 0-based
+            column: Number(finding.column ?? -1) + 1,
+            severity: toSeverity(finding.severity),
+            message: `${finding.message ?? ''}${hint}`,
+            ...(finding.code ? { rule: String(finding.code) } : {}),
+            source: 'mypy',
+        });
+    }
+    return diagnostics;
+}
+
+// file:line[:col]: error|warning|note: message  [code]
+const mypyLinePattern = /^(.+?):(\d+)(?::(\d+))?: (error|warning|note): (.*?)(?:\s+\[([\w-]+)\])?$/;
+
+/**
+ * Parse mypy text output (`--show-column-numbers --show-error-codes`) for versions without JSON output
+ */
+export function parseMypyTextOutput(output: string, cwd: string): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    for (const line of output.split('\n')) {
+        const match = mypyLinePattern.exec(line.trim());
+        if (!match) continue;
+        const [, file = '', lineNo = '0', column, severity = 'error', message = '', code] = match;
+        diagnostics.push({
+            file: resolveDiagnosticPath(file, cwd),
+            line: Number(lineNo),
+            column: column ? Number(column) : 0,
+            severity: toSeverity(severity),
+            message,
+            ...(code ? { rule: code } : {}),
+            source: 'mypy',
+        });
+    }
+    return diagnostics;
+}
+
+/**
+ * Parse `pyright --outputjson`; positions are converted from 0-based to 1-based
+ */
+export function parsePyrightJsonOutput(output: string, cwd: string): Diagnostic[] {
+    const start = output.indexOf('{');
+    if (start < 0) return [];
+    let parsed: { generalDiagnostics?: any[] };
+    try {
+        parsed = JSON.parse(output.slice(start));
+    } catch {
+        return [];
+    }
+    return (parsed.generalDiagnostics ?? []).map(finding => ({
+        file: resolveDiagnosticPath(String(finding.file ?? ''), cwd),
+        line: Number(finding.range?.start?.line ?? 0) + 1,
+        column: Number(finding.range?.start?.character ?? 0) + 1,
+        severity: toSeverity(finding.severity),
+        message: String(finding.message ?? ''),
+        ...(finding.rule ? { rule: String(finding.rule) } : {}),
+        source: 'pyright',
+    }));
+}
+
+/**
+ * Parse `ruff check --output-format=json`. Syntax errors (no code, or E9xx)
+ * are errors, everything else a warning.
+ */
+export function parseRuffJsonOutput(output: string, cwd: string): Diagnostic[] {
+    const start = output.indexOf('[');
+    if (start < 0) return [];
+    let findings: any[];
+    try {
+        findings = JSON.parse(output.slice(start));
+    } catch {
+        return [];
+    }
+    if (!Array.isArray(findings)) return [];
+    return findings.map(finding => {
+        const code = finding.code ? String(finding.code) : '';
+        return {
+            file: resolveDiagnosticPath(String(finding.filename ?? ''), cwd),
+            line: Number(finding.location?.row ?? 0),
+            column: Number(finding.location?.column ?? 0),
+            severity: !code || code.startsWith('E9') ? 'error' as const : 'warning' as const,
+            message: String(finding.message ?? ''),
+            ...(code ? { rule: code } : {}),
+            source: 'ruff',
+            ...(finding.fix ? { fixable: true } : {}),
+        };
+    });
+}
@@ -0,0 +1,98 @@
+import { isAbsolute, relative, sep } from 'path';
+import { pathToFileURL } from 'url';
+import type { Diagnostic, DiagnosticSeverity } from './index.js';
+
+export const SARIF_SCHEMA = 'https://json.schemastore.org/sarif-2.1.0.json';
+
+export interface SarifLog {
+    $schema: string;
+    version: '2.1.0';
+    runs: SarifRun[];
+}
+
+export interface SarifRun {
+    tool: { driver: { name: string; rules: SarifRule[] } };
+    originalUriBaseIds?: Record<string, { uri: string }>;
+    results: SarifResult[];
+}
+
+export interface SarifRule {
+    id: string;
+    shortDescription: { text: string };
+}
+
+export interface SarifResult {
+    ruleId: string;
+    ruleIndex: number;
+    level: 'error' | 'warning' | 'note';
+    message: { text: string };
+    locations: Array<{
+        physicalLocation: {
+            artifactLocation: { uri: string; uriBaseId?: string };
+            region?: { startLine: number; startColumn?: number };
+        };
+    }>;
+    properties?: { fixable: true };
+}
+
+const LEVELS: Record<DiagnosticSeverity, SarifResult['level']> = { error: 'error', warning: 'warning', info: 'note' };
+const ROOT_BASE_ID = 'SRCROOT';
+
+function artifactLocation(file: string, root: string | undefined): { uri: string; uriBaseId?: string } {
+    if (root && isAbsolute(file)) {
+        const rel = relative(root, file);
+        if (rel && !rel.startsWith('..') && !isAbsolute(rel)) {
+            return { uri: rel.split(sep).map(encodeURIComponent).join('/'), uriBaseId: ROOT_BASE_ID };
+        }
+    }
+    return { uri: isAbsolute(file) ? pathToFileURL(file).href : file.split(sep).map(encodeURIComponent).join('/') };
+}
+
+/**
+ * Serialize diagnostics as a SARIF 2.1.0 log, one run per diagnostic source
+ * (the tool that reported it), for GitHub code scanning and other consumers.
+ * Paths under root are written relative to it (as SRCROOT), which code
+ * scanning needs to place results in the repository.
+ */
+export function toSarif(diagnostics: Diagnostic[], options: { root?: string } = {}): SarifLog {
+    const bySource = new Map<string, Diagnostic[]>();
+    for (const diagnostic of diagnostics) {
+        const source = diagnostic.source || 'code-feedback';
+        bySource.set(source, [...(bySource.get(source) ?? []), diagnostic]);
+    }
+
+    const runs: SarifRun[] = [];
+    for (const [source, group] of [...bySource].sort(([a], [b]) => a.localeCompare(b))) {
+        const rules: SarifRule[] = [];
+        const ruleIndex = new Map<string, number>();
+        const results = group.map((d): SarifResult => {
+            const ruleId = d.rule || source;
+            if (!ruleIndex.has(ruleId)) {
+                ruleIndex.set(ruleId, rules.length);
+                rules.push({ id: ruleId, shortDescription: { text: d.rule ? `${source} ${d.rule}` : `${source} finding` } });
+            }
+            return {
+                ruleId,
+                ruleIndex: ruleIndex.get(ruleId)!,
+                level: LEVELS[d.severity] ?? 'warning',
+                message: { text: d.message },
+                locations: [{
+                    physicalLocation: {
+                        artifactLocation: artifactLocation(d.file, options.root),
+                        // Line 0 means the finding has no position in the file
+                        ...(d.line > 0 ? { region: { startLine: d.line, ...(d.column > 0 ? { startColumn: d.column } : {}) } } : {}),
+                    },
+                }],
+                ...(d.fixable ? { properties: { fixable: true as const } } : {}),
+            };
+        });
+        runs.push({
+            tool: { driver: { name: source, rules } },
+            ...(options.root ? { originalUriBaseIds: { [ROOT_BASE_ID]: { uri: `${pathToFileURL(options.root).href.replace(/\/?$/, '/')}` } } } : {}),
+            results,
+        });
+    }
+    // An empty run tells code scanning that earlier findings are fixed
+    if (runs.length === 0) runs.push({ tool: { driver: { name: 'code-feedback', rules: [] } }, results: [] });
+    return { $schema: SARIF_SCHEMA, version: '2.1.0', runs };
+}
@@ -0,0 +1,35 @@
+import { promises as fs } from 'fs';
+import { resolve } from 'path';
+
+// Generated or vendored blobs this large carry no suppression comments worth reading
+const MAX_SOURCE_BYTES = 8 * 1024 * 1024;
+
+async function readLines(path: string): Promise<string[] | null> {
+    try {
+        const stat = await fs.stat(path);
+        if (!stat.isFile() || stat.size > MAX_SOURCE_BYTES) return null;
+        return (await fs.readFile(path, 'utf-8')).split(/\r?\n/);
+    } catch {
+        return null;
+    }
+}
+
+/**
+ * Lines of the files findings point at, each file read once. Paths resolve
+ * against root; unreadable or very large files have no lines.
+ */
+export class SourceLines {
+    private readonly root: string;
+    private readonly files = new Map<string, Promise<string[] | null>>();
+
+    constructor(root: string) {
+        this.root = root;
+    }
+
+    public async line(file: string, line: number): Promise<string | undefined> {
+        if (!file || line < 1) return undefined;
+        const path = resolve(this.root, file);
+        if (!this.files.has(path)) this.files.set(path, readLines(path));
+        return (await this.files.get(path))?.[line - 1];
+    }
+}
@@ -0,0 +1,112 @@
+import { isAbsolute, relative } from 'path';
+import { countBySeverity, type Diagnostic, type DiagnosticSeverity } from './index.js';
+import type { PipelineStepResult } from '../tools/pipeline.js';
+
+/**
+ * An issue that makes the verdict fail: an error diagnostic of a failed step,
+ * or the step's first error when it reported no diagnostics
+ */
+export interface BlockingIssue {
+    step: string;
+    message: string;
+    file?: string;
+    line?: number;
+    column?: number;
+    rule?: string;
+    source?: string;
+}
+
+/**
+ * Compact outcome of a pipeline run, for clients that cannot afford every
+ * step's output; the full step results stay available to drill into
+ */
+export interface Verdict {
+    passed: boolean;
+    steps: { total: number; passed: number; failed: number; skipped: number };
+    failedSteps: string[];
+    severity: Record<DiagnosticSeverity, number>;
+    blocking: BlockingIssue[];
+    // Blocking issues beyond the ones listed
+    moreBlocking: number;
+    summary: string;
+}
+
+const MAX_MESSAGE_LENGTH = 200;
+const DEFAULT_MAX_ISSUES = 5;
+
+function truncate(text: string): string {
+    const line = text.split('\n')[0]!.trim();
+    return line.length > MAX_MESSAGE_LENGTH ? `${line.slice(0, MAX_MESSAGE_LENGTH - 3)}...` : line;
+}
+
+function plural(n: number, word: string): string {
+    return `${n} ${word}${n === 1 ? '' : 's'}`;
+}
+
+function displayPath(file: string, root: string | undefined): string {
+    if (!root || !isAbsolute(file)) return file;
+    const rel = relative(root, file);
+    return rel && !rel.startsWith('..') ? rel : file;
+}
+
+function fromDiagnostic(step: string, d: Diagnostic, root: string | undefined): BlockingIssue {
+    return {
+        step,
+        message: truncate(d.message),
+        file: displayPath(d.file, root),
+        line: d.line,
+        ...(d.column ? { column: d.column } : {}),
+        ...(d.rule ? { rule: d.rule } : {}),
+        source: d.source,
+    };
+}
+
+function location(issue: BlockingIssue): string {
+    if (!issue.file) return issue.step;
+    return `${issue.file}:${issue.line}${issue.column ? `:${issue.column}` : ''}`;
+}
+
+/**
+ * Roll step results up into a verdict: pass/fail, step and severity counts,
+ * the first maxIssues blocking issues (in step order, then by location) and a
+ * one-line summary. root shortens diagnostic paths.
+ */
+export function summarizePipeline(results: PipelineStepResult[], options: { root?: string; maxIssues?: number } = {}): Verdict {
+    const maxIssues = options.maxIssues ?? DEFAULT_MAX_ISSUES;
+    const failed = results.filter(r => r.status === 'failed');
+    const skipped = results.filter(r => r.status === 'skipped').length;
+    const diagnostics = results.flatMap(r => r.diagnostics ?? []);
+    const severity = countBySeverity(diagnostics);
+
+    const blocking: BlockingIssue[] = [];
+    for (const result of results) {
+        if (result.status !== 'failed') continue;
+        const errors = (result.diagnostics ?? [])
+            .filter(d => d.severity === 'error')
+            .sort((a, b) => a.file.localeCompare(b.file) || a.line - b.line || a.column - b.column);
+        blocking.push(...errors.map(d => fromDiagnostic(result.name, d, options.root)));
+        if (errors.length === 0) {
+            blocking.push({ step: result.name, message: truncate(result.errors[0] ?? 'Step failed') });
+        }
+    }
+
+    const stepCounts = { total: results.length, passed: results.length - failed.length - skipped, failed: failed.length, skipped };
+    const parts = [
+        failed.length > 0
+            ? `${failed.map(r => r.name).join(', ')} failed (${stepCounts.passed} of ${plural(results.length, 'step')} passed${skipped > 0 ? `, ${skipped} skipped` : ''})`
+            : `${plural(results.length, 'step')} passed`,
+        `${plural(severity.error, 'error')}, ${plural(severity.warning, 'warning')}, ${severity.info} info`,
+    ];
+    const first = blocking[0];
+    if (first) parts.push(`first: ${location(first)} ${first.message}`);
+
+    return {
+        passed: failed.length === 0,
+        steps: stepCounts,
+        failedSteps: failed.map(r => r.name),
+        severity,
+        blocking: blocking.slice(0, maxIssues),
+        moreBlocking: Math.max(0, blocking.length - maxIssues),
+        summary: `${failed.length === 0 ? 'PASS' : 'FAIL'}: ${parts.join('; ')}`,
+    };
+}
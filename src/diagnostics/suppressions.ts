@@ -0,0 +1,222 @@
+import type { ProjectConfig } from '../config/project.js';
+import type { Diagnostic } from './index.js';
+import { SourceLines } from './sources.js';
+import type { TestCaseResult } from './tests.js';
+
+export type SuppressionKind = 'nolint' | 'noqa' | 'eslint' | 'code-feedback';
+
+/**
+ * A suppression comment parsed from a source line
+ */
+export interface SuppressionDirective {
+    kind: SuppressionKind;
+    // Rules, linters or codes it covers; empty covers every finding
+    rules: string[];
+    reason?: string;
+    // Findings on its own line, or on the next one (a comment on a line of its own)
+    scope: 'line' | 'next';
+}
+
+// A suppression that silenced findings without saying why
+export interface UnjustifiedSuppression {
+    file: string;
+    line: number;
+    kind: SuppressionKind;
+    comment: string;
+}
+
+export interface SuppressionReport {
+    count: number;
+    byKind: Partial<Record<SuppressionKind, number>>;
+    unjustified: UnjustifiedSuppression[];
+}
+
+export interface FindingsOutcome {
+    success: boolean;
+    errors: string[];
+    warnings: string[];
+    diagnostics?: Diagnostic[];
+    tests?: TestCaseResult[];
+}
+
+const MAX_COMMENT_LENGTH = 200;
+const MAX_LISTED = 5;
+
+// Closers of block comments the directive may sit in
+function stripCloser(text: string): string {
+    return text.replace(/\s*(\*\/|-->)\s*$/, '');
+}
+
+function unquote(text: string): string {
+    const trimmed = text.trim();
+    return /^(["']).*\1$/.test(trimmed) ? trimmed.slice(1, -1).trim() : trimmed;
+}
+
+function splitRules(text: string | undefined): string[] {
+    return (text ?? '').split(/[\s,]+/).filter(Boolean);
+}
+
+const PARSERS: Array<{ kind: SuppressionKind; pattern: RegExp; parse(match: RegExpExecArray): { rules: string[]; reason: string; scope?: 'line' | 'next' } }> = [
+    {
+        // code-feedback:ignore errcheck,unused reason="legacy API"
+        kind: 'code-feedback',
+        pattern: /code-feedback:ignore\b(.*)$/,
+        parse(match) {
+            const rest = stripCloser(match[1]!);
+            const reason = /(?:^|\s)reason=(.*)$/.exec(rest);
+            return { rules: splitRules(reason ? rest.slice(0, reason.index) : rest), reason: reason ? unquote(reason[1]!) : '' };
+        },
+    },
+    {
+        // //nolint:errcheck,gosec // the reason (golangci-lint)
+        kind: 'nolint',
+        pattern: /\/\/ ?nolint\b(?::([\w,-]+))?(.*)$/,
+        parse(match) {
+            const rules = splitRules(match[1]).filter(rule => rule !== 'all');
+            return { rules, reason: /^\s*\/\/\s*(.*)$/.exec(match[2]!)?.[1]?.trim() ?? '' };
+        },
+    },
+    {
+        // # noqa: E501, F401 -- the reason (flake8, ruff)
+        kind: 'noqa',
+        pattern: /#\s*noqa\b(?::\s*([A-Za-z]+\d*(?:\s*,\s*[A-Za-z]+\d*)*))?(.*)$/i,
+        parse(match) {
+            return { rules: splitRules(match[1]), reason: match[2]!.replace(/^[\s#:—–-]+/, '').trim() };
+        },
+    },
+    {
+        // eslint-disable-next-line no-console -- the reason
+        kind: 'eslint',
+        pattern: /eslint-disable-(next-)?line\b(.*)$/,
+        parse(match) {
+            const [rules, ...reason] = stripCloser(match[2]!).split(/(?:^|\s)--\s/);
+            return { rules: splitRules(rules), reason: reason.join(' -- ').trim(), scope: match[1] ? 'next' : 'line' };
+        },
+    },
+];
+
+/**
+ * Parse a suppression comment out of a source line; null when it has none
+ */
+export function parseSuppression(text: string): SuppressionDirective | null {
+    for (const parser of PARSERS) {
+        const match = parser.pattern.exec(text);
+        if (!match) continue;
+        const { rules, reason, scope } = parser.parse(match);
+        // Nothing but a comment marker before it: the comment stands on its own line above the code
+        const alone = /^\s*(?:\/\/+|#+|\/\*+|--|<!--|;+)?\s*$/.test(text.slice(0, match.index));
+        return { kind: parser.kind, rules, ...(reason ? { reason } : {}), scope: scope ?? (alone ? 'next' : 'line') };
+    }
+    return null;
+}
+
+function covers(directive: SuppressionDirective, d: Diagnostic): boolean {
+    if (directive.rules.length === 0) return true;
+    const names = [d.rule, d.source, d.rule ? `${d.source}/${d.rule}` : undefined]
+        .filter((name): name is string => Boolean(name))
+        .map(name => name.toLowerCase());
+    return directive.rules.some(rule => names.includes(rule.toLowerCase()));
+}
+
+/**
+ * Split findings into those inline suppression comments (//nolint, # noqa,
+ * eslint-disable-line, code-feedback:ignore) silence and the rest. A comment
+ * covers findings on its own line, or on the next line when it stands alone.
+ * With requireReason, a comment that gives no reason silences nothing; either
+ * way it is listed as unjustified.
+ */
+export async function findSuppressed(diagnostics: Diagnostic[], options: { root: string; requireReason?: boolean }): Promise<{ kept: Diagnostic[]; suppressed: Diagnostic[]; report: SuppressionReport }> {
+    const lines = new SourceLines(options.root);
+    const kept: Diagnostic[] = [];
+    const suppressed: Diagnostic[] = [];
+    const report: SuppressionReport = { count: 0, byKind: {}, unjustified: [] };
+    const flagged = new Set<string>();
+    for (const d of diagnostics) {
+        let found: { directive: SuppressionDirective; line: number; text: string } | undefined;
+        for (const [line, scope] of [[d.line, 'line'], [d.line - 1, 'next']] as const) {
+            const text = await lines.line(d.file, line);
+            const directive = text === undefined ? null : parseSuppression(text);
+            if (directive && directive.scope === scope && covers(directive, d)) {
+                found = { directive, line, text: text! };
+                break;
+            }
+        }
+        if (!found) {
+            kept.push(d);
+            continue;
+        }
+        const { directive, line, text } = found;
+        if (!directive.reason && !flagged.has(`${d.file}:${line}`)) {
+            flagged.add(`${d.file}:${line}`);
+            const comment = text.trim();
+            report.unjustified.push({ file: d.file, line, kind: directive.kind, comment: comment.length > MAX_COMMENT_LENGTH ? `${comment.slice(0, MAX_COMMENT_LENGTH - 3)}...` : comment });
+        }
+        if (!directive.reason && options.requireReason) {
+            kept.push(d);
+            continue;
+        }
+        suppressed.push(d);
+        report.count++;
+        report.byKind[directive.kind] = (report.byKind[directive.kind] ?? 0) + 1;
+    }
+    return { kept, suppressed, report };
+}
+
+/**
+ * Leave findings out of a tool or step result. A failure that only the left
+ * out errors explain becomes a pass; failed tests still fail it.
+ */
+export function withoutFindings<T extends FindingsOutcome>(outcome: T, kept: Diagnostic[], dropped: Diagnostic[], warning: string): T {
+    const explained = !outcome.success
+        && dropped.some(d => d.severity === 'error')
+        && !kept.some(d => d.severity === 'error')
+        && !(outcome.tests ?? []).some(t => t.status === 'failed' || t.status === 'error');
+    return {
+        ...outcome,
+        ...(explained ? { success: true, errors: [] } : {}),
+        warnings: [...outcome.warnings, warning],
+        diagnostics: kept,
+    };
+}
+
+/**
+ * Suppression settings of a project config; null when it turns them off
+ */
+export function suppressionOptions(config: ProjectConfig): { requireReason?: boolean } | null {
+    if (config.suppressions?.enabled === false) return null;
+    return config.suppressions?.requireReason ? { requireReason: true } : {};
+}
+
+export function mergeSuppressionReports(reports: Array<SuppressionReport | undefined>): SuppressionReport {
+    const merged: SuppressionReport = { count: 0, byKind: {}, unjustified: [] };
+    for (const report of reports) {
+        if (!report) continue;
+        merged.count += report.count;
+        for (const [kind, count] of Object.entries(report.byKind) as Array<[SuppressionKind, number]>) {
+            merged.byKind[kind] = (merged.byKind[kind] ?? 0) + count;
+        }
+        merged.unjustified.push(...report.unjustified);
+    }
+    return merged;
+}
+
+/**
+ * Apply inline suppressions to a tool or step result: silenced findings are
+ * left out and counted under suppressions, with the comments that gave no
+ * reason flagged in the warnings
+ */
+export async function applySuppressions<T extends FindingsOutcome>(outcome: T, options: { root: string; requireReason?: boolean }): Promise<T & { suppressions?: SuppressionReport }> {
+    if (!outcome.diagnostics || outcome.diagnostics.length === 0) return outcome;
+    const { kept, suppressed, report } = await findSuppressed(outcome.diagnostics, options);
+    if (report.count === 0 && report.unjustified.length === 0) return outcome;
+    const result = report.count > 0
+        ? withoutFindings(outcome, kept, suppressed, `${report.count} finding(s) suppressed by inline comments`)
+        : { ...outcome };
+    if (report.unjustified.length > 0) {
+        const listed = report.unjustified.slice(0, MAX_LISTED).map(s => `${s.file}:${s.line} (${s.kind})`);
+        const more = report.unjustified.length > MAX_LISTED ? ` and ${report.unjustified.length - MAX_LISTED} more` : '';
+        const effect = options.requireReason ? ', so they suppress nothing' : '';
+        result.warnings = [...result.warnings, `${report.unjustified.length} suppression comment(s) without a reason${effect}: ${listed.join(', ')}${more}`];
+    }
+    return { ...result, suppressions: report };
+}
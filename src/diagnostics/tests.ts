@@ -0,0 +1,426 @@
+import { isAbsolute, join, relative } from 'path';
+import { findElements, parseXml, type XmlElement } from '../utils/xml.js';
+
+export type TestStatus = 'passed' | 'failed' | 'error' | 'skipped';
+
+/**
+ * One test case, in the same shape whatever framework ran it
+ */
+export interface TestCaseResult {
+    // Package, module or class the test belongs to (Go import path, pytest file, test class)
+    suite: string;
+    name: string;
+    status: TestStatus;
+    durationMs: number;
+    // Assertion or exception message for failed/errored tests, reason for skipped ones
+    message?: string;
+    // Stack trace or captured failure output
+    details?: string;
+    file?: string;
+    line?: number;
+    // Output the test printed while running, when the framework reports it per test
+    output?: string;
+}
+
+export interface TestSummary {
+    total: number;
+    passed: number;
+    failed: number;
+    errored: number;
+    skipped: number;
+    durationMs: number;
+}
+
+export function summarizeTests(tests: TestCaseResult[]): TestSummary {
+    const count = (status: TestStatus) => tests.filter(t => t.status === status).length;
+    return {
+        total: tests.length,
+        passed: count('passed'),
+        failed: count('failed'),
+        errored: count('error'),
+        skipped: count('skipped'),
+        durationMs: Math.round(tests.reduce((sum, t) => sum + t.durationMs, 0)),
+    };
+}
+
+export interface TestReport {
+    summary: TestSummary;
+    failures: TestCaseResult[];
+    results: TestCaseResult[];
+}
+
+/**
+ * The `tests` block test tools return: counts, the failed/errored cases, and every case
+ */
+export function toTestReport(tests: TestCaseResult[]): TestReport {
+    return {
+        summary: summarizeTests(tests),
+        failures: tests.filter(t => t.status === 'failed' || t.status === 'error'),
+        results: tests,
+    };
+}
+
+/**
+ * One-line description of a failed test for the errors list
+ */
+export function describeTestFailure(test: TestCaseResult): string {
+    const location = test.file ? ` (${test.file}${test.line ? `:${test.line}` : ''})` : '';
+    return `${test.suite} ${test.name} ${test.status}: ${test.message ?? ''}${location}`;
+}
+
+function seconds(value: string | undefined): number {
+    const parsed = Number((value ?? '').replace(/,/g, ''));
+    return Number.isFinite(parsed) ? Math.round(parsed * 1000) : 0;
+}
+
+// Innermost stack frame inside the test class: "at com.acme.FooTest.bar(FooTest.java:42)"
+function locateFailure(className: string, trace: string): { file: string; line: number } | undefined {
+    const simpleName = className.split('.').pop() ?? className;
+    const pattern = new RegExp(`at ${className.replace(/[.$]/g, '\\$&')}[.$][^(]*\\((${simpleName}[^:)]*\\.\\w+):(\\d+)\\)`);
+    const match = pattern.exec(trace);
+    if (!match) return undefined;
+    const packagePath = className.includes('.') ? className.slice(0, className.lastIndexOf('.')).replace(/\./g, '/') : '';
+    return { file: packagePath ? `${packagePath}/${match[1]}` : match[1] ?? '', line: Number(match[2]) };
+}
+
+// Python tracebacks end with "tests/test_app.py:12: AssertionError"
+function locatePythonFailure(trace: string): { file: string; line: number } | undefined {
+    let location: { file: string; line: number } | undefined;
+    for (const match of trace.matchAll(/^([^\s:][^:\n]*\.py):(\d+): /gm)) {
+        location = { file: match[1] ?? '', line: Number(match[2]) };
+    }
+    return location;
+}
+
+function toTestCase(testcase: XmlElement, suiteName: string): TestCaseResult {
+    const className = testcase.attributes.classname || suiteName;
+    const result: TestCaseResult = {
+        suite: className,
+        name: testcase.attributes.name || '(unnamed)',
+        status: 'passed',
+        durationMs: seconds(testcase.attributes.time),
+    };
+    const failure = testcase.children.find(c => c.name === 'failure' || c.name === 'error');
+    const skipped = testcase.children.find(c => c.name === 'skipped');
+    if (failure) {
+        result.status = failure.name === 'failure' ? 'failed' : 'error';
+        const details = failure.text.trim();
+        result.message = failure.attributes.message || details.split('\n')[0] || failure.attributes.type || failure.name;
+        if (details) result.details = details;
+        const location = locateFailure(className, details) ?? locatePythonFailure(details);
+        if (location) {
+            result.file = location.file;
+            result.line = location.line;
+        }
+    } else if (skipped) {
+        result.status = 'skipped';
+        const reason = skipped.attributes.message || skipped.text.trim();
+        if (reason) result.message = reason;
+    }
+    // xunit1 reports (pytest junit_family=xunit1) carry the source location as attributes
+    if (!result.file && testcase.attributes.file) {
+        result.file = testcase.attributes.file;
+        if (testcase.attributes.line) result.line = Number(testcase.attributes.line) + 1;
+    }
+    const output = testcase.children.filter(c => c.name === 'system-out' || c.name === 'system-err').map(c => c.text.trim()).filter(Boolean).join('\n');
+    if (output) result.output = output;
+    return result;
+}
+
+/**
+ * Parse a JUnit-style XML report (Maven surefire/failsafe, Gradle, pytest
+ * --junitxml, jest-junit). Accepts a single <testsuite> or a <testsuites> root.
+ * Failure locations are relative source paths derived from the stack trace
+ * (com/acme/FooTest.java) when the trace names the test class.
+ */
+export function parseJUnitXml(xml: string): TestCaseResult[] {
+    const root = parseXml(xml);
+    if (!root) return [];
+    const suites = root.name === 'testsuite' ? [root, ...findElements(root, 'testsuite')] : findElements(root, 'testsuite');
+    const tests: TestCaseResult[] = [];
+    for (const suite of suites) {
+        for (const testcase of suite.children.filter(c => c.name === 'testcase')) {
+            tests.push(toTestCase(testcase, suite.attributes.name || ''));
+        }
+    }
+    return tests;
+}
+
+// --- go test -json ---
+
+interface GoTestEvent {
+    Action: string;
+    Package?: string;
+    // build-output events (Go 1.24+) name the package being compiled, and the
+    // package's fail event points back at it
+    ImportPath?: string;
+    FailedBuild?: string;
+    Test?: string;
+    Elapsed?: number;
+    Output?: string;
+}
+
+// t.Error/t.Log lines: "    calc_test.go:12: expected 2, got 3"
+const goTestLogPattern = /^\s+([\w.\-/]+\.go):(\d+): (.*)$/;
+
+function isGoFrameworkLine(line: string): boolean {
+    return /^\s*(=== (RUN|PAUSE|CONT|NAME)|--- (PASS|FAIL|SKIP)):? /.test(line) || /^(PASS|FAIL|ok\s|FAIL\s|\?\s)/.test(line);
+}
+
+function goStatus(action: string): TestStatus | undefined {
+    if (action === 'pass') return 'passed';
+    if (action === 'fail') return 'failed';
+    if (action === 'skip') return 'skipped';
+    return undefined;
+}
+
+/**
+ * The plain `go test -v` log carried in the Output events of `go test -json`,
+ * with any non-JSON lines (build errors on stdout) kept in place
+ */
+export function goTestJsonText(output: string): string {
+    let text = '';
+    for (const line of output.split('\n')) {
+        if (!line.trim().startsWith('{')) {
+            if (line) text += `${line}\n`;
+            continue;
+        }
+        try {
+            const event: GoTestEvent = JSON.parse(line);
+            if ((event.Action === 'output' || event.Action === 'build-output') && event.Output) text += event.Output;
+        } catch {
+            text += `${line}\n`;
+        }
+    }
+    return text;
+}
+
+/**
+ * Parse the event stream from `go test -json`. Each test (subtests included,
+ * as "TestParent/sub") becomes a case; a package that fails without any
+ * failing test (build error, panic in TestMain) becomes an error case named
+ * "(package)". Log locations are relative to the package directory, and are
+ * resolved against `cwd` when given.
+ */
+export function parseGoTestJson(output: string, cwd?: string): TestCaseResult[] {
+    const tests = new Map<string, TestCaseResult & { lines: string[] }>();
+    const packages = new Map<string, { status?: TestStatus; durationMs: number; lines: string[]; failedBuild?: string }>();
+    const buildOutput = new Map<string, string[]>();
+    for (const rawLine of output.split('\n')) {
+        const line = rawLine.trim();
+        if (!line.startsWith('{')) continue;
+        let event: GoTestEvent;
+        try {
+            event = JSON.parse(line);
+        } catch {
+            continue;
+        }
+        if (event.Action === 'build-output' && event.ImportPath) {
+            buildOutput.set(event.ImportPath, [...(buildOutput.get(event.ImportPath) ?? []), event.Output ?? '']);
+            continue;
+        }
+        const pkg = event.Package ?? '';
+        if (!pkg) continue;
+        if (!event.Test) {
+            const entry = packages.get(pkg) ?? { durationMs: 0, lines: [] };
+            packages.set(pkg, entry);
+            if (event.Action === 'output' && event.Output) entry.lines.push(event.Output);
+            const status = goStatus(event.Action);
+            if (status) {
+                entry.status = status;
+                entry.durationMs = Math.round((event.Elapsed ?? 0) * 1000);
+                if (event.FailedBuild) entry.failedBuild = event.FailedBuild;
+            }
+            continue;
+        }
+        const key = `${pkg}\u0000${event.Test}`;
+        let test = tests.get(key);
+        if (!test) {
+            test = { suite: pkg, name: event.Test, status: 'passed', durationMs: 0, lines: [] };
+            tests.set(key, test);
+        }
+        if (event.Action === 'output' && event.Output) {
+            test.lines.push(event.Output);
+        }
+        const status = goStatus(event.Action);
+        if (status) {
+            test.status = status;
+            test.durationMs = Math.round((event.Elapsed ?? 0) * 1000);
+        }
+    }
+    const results: TestCaseResult[] = [];
+    for (const { lines, ...test } of tests.values()) {
+        const printed = lines.filter(l => !isGoFrameworkLine(l)).join('').replace(/\s+$/, '');
+        if (printed) test.output = printed;
+        if (test.status !== 'passed') {
+            const logLines = printed.split('\n').map(l => goTestLogPattern.exec(l)).filter((m): m is RegExpExecArray => m !== null);
+            const first = logLines[0];
+            if (first) {
+                test.message = first[3] ?? '';
+                // testing.go lines are the framework's own ("race detected during execution of test")
+                if (first[1] !== 'testing.go') {
+                    test.file = cwd ? join(cwd, first[1] ?? '') : first[1] ?? '';
+                    test.line = Number(first[2]);
+                }
+            } else if (printed) {
+                test.message = printed.trim().split('\n')[0] ?? '';
+            }
+        }
+        results.push(test);
+    }
+    // A parent that failed only because a subtest did has no log of its own
+    for (const test of results) {
+        if (test.status !== 'failed' || test.message) continue;
+        const child = results.find(t => t.suite === test.suite && t.status === 'failed' && t.name.startsWith(`${test.name}/`));
+        if (child) test.message = `subtest ${child.name} failed`;
+    }
+    for (const [pkg, entry] of packages) {
+        if (entry.status !== 'failed' || results.some(t => t.suite === pkg && t.status === 'failed')) continue;
+        const lines = entry.failedBuild ? buildOutput.get(entry.failedBuild) ?? [] : entry.lines;
+        const printed = lines.filter(l => !isGoFrameworkLine(l) && !l.startsWith('# ')).join('').trim();
+        results.push({
+            suite: pkg,
+            name: '(package)',
+            status: 'error',
+            durationMs: entry.durationMs,
+            message: printed.split('\n')[0] || 'package failed',
+            ...(printed ? { details: printed } : {}),
+        });
+    }
+    return results;
+}
+
+// --- pytest-json-report ---
+
+interface PytestStage {
+    duration?: number;
+    outcome?: string;
+    longrepr?: string;
+    stdout?: string;
+    stderr?: string;
+    crash?: { path?: string; lineno?: number; message?: string };
+}
+
+function pytestStatus(outcome: string | undefined): TestStatus {
+    switch (outcome) {
+        case 'passed':
+        case 'xpassed':
+            return 'passed';
+        case 'failed':
+            return 'failed';
+        case 'error':
+            return 'error';
+        // xfailed is an expected failure, reported like pytest does: not a failure
+        default:
+            return 'skipped';
+    }
+}
+
+/**
+ * Parse a pytest-json-report (`pytest --json-report`) document. The suite is
+ * the node id up to the test name ("tests/test_app.py::TestCalc").
+ */
+export function parsePytestJson(report: any): TestCaseResult[] {
+    const results: TestCaseResult[] = [];
+    for (const test of Array.isArray(report?.tests) ? report.tests : []) {
+        const nodeId = String(test.nodeid ?? '');
+        const separator = nodeId.lastIndexOf('::');
+        const stages: PytestStage[] = [test.setup, test.call, test.teardown].filter(Boolean);
+        const result: TestCaseResult = {
+            suite: separator >= 0 ? nodeId.slice(0, separator) : nodeId,
+            name: separator >= 0 ? nodeId.slice(separator + 2) : nodeId,
+            status: pytestStatus(test.outcome),
+            durationMs: Math.round(stages.reduce((sum, stage) => sum + (stage.duration ?? 0), 0) * 1000),
+        };
+        const failed = stages.find(stage => stage.outcome === 'failed' || stage.outcome === 'skipped');
+        if (failed && result.status !== 'passed') {
+            const longrepr = String(failed.longrepr ?? '');
+            if (result.status === 'skipped') {
+                // Skip reasons are serialized as "('tests/test_app.py', 5, 'Skipped: not ready')"
+                const reason = /Skipped: (.*)'\)$/.exec(longrepr)?.[1] ?? longrepr;
+                if (reason) result.message = reason;
+            } else {
+                result.message = failed.crash?.message || longrepr.trim().split('\n').pop() || 'failed';
+                if (longrepr) result.details = longrepr;
+                if (failed.crash?.path) {
+                    result.file = failed.crash.path;
+                    if (failed.crash.lineno) result.line = failed.crash.lineno;
+                }
+            }
+        }
+        if (!result.file) {
+            result.file = nodeId.split('::')[0] ?? nodeId;
+            if (typeof test.lineno === 'number') result.line = test.lineno + 1;
+        }
+        const output = stages.map(stage => [stage.stdout, stage.stderr].filter(Boolean).join('')).join('').trim();
+        if (output) result.output = output;
+        results.push(result);
+    }
+    return results;
+}
+
+// --- jest --json (also written by vitest --reporter=json) ---
+
+function stripAnsi(text: string): string {
+    return text.replace(/\x1b\[[0-9;]*m/g, '');
+}
+
+function jestStatus(status: string | undefined): TestStatus {
+    if (status === 'passed') return 'passed';
+    if (status === 'failed') return 'failed';
+    return 'skipped';
+}
+
+// Stack frame inside the test file: "at Object.<anonymous> (/work/src/sum.test.js:12:5)"
+function locateJestFailure(trace: string, testFile: string): number | undefined {
+    const escaped = testFile.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+    const match = new RegExp(`${escaped}:(\\d+):\\d+`).exec(trace);
+    return match ? Number(match[1]) : undefined;
+}
+
+/**
+ * Parse a jest `--json` results document. The suite is the test file, relative
+ * to `cwd` when given; the name joins the describe blocks and title with " > ".
+ * A test file that failed to run at all (syntax error, missing module) becomes
+ * an error case named "(test file)".
+ */
+export function parseJestJson(report: any, cwd?: string): TestCaseResult[] {
+    const results: TestCaseResult[] = [];
+    for (const file of Array.isArray(report?.testResults) ? report.testResults : []) {
+        const testFile = String(file.name ?? file.testFilePath ?? '');
+        const suite = cwd && isAbsolute(testFile) ? relative(cwd, testFile) : testFile;
+        const assertions: any[] = Array.isArray(file.assertionResults) ? file.assertionResults : [];
+        for (const assertion of assertions) {
+            const titles = [...(Array.isArray(assertion.ancestorTitles) ? assertion.ancestorTitles : []), assertion.title];
+            const result: TestCaseResult = {
+                suite,
+                name: titles.filter(Boolean).join(' > ') || String(assertion.fullName ?? '(unnamed)'),
+                status: jestStatus(assertion.status),
+                durationMs: Math.round(Number(assertion.duration) || 0),
+                file: testFile,
+            };
+            const failureMessages: string[] = (assertion.failureMessages ?? []).map((m: unknown) => stripAnsi(String(m)));
+            if (result.status === 'failed') {
+                const details = failureMessages.join('\n\n').trim();
+                result.message = details.split('\n').find(l => l.trim())?.trim() ?? 'failed';
+                if (details) result.details = details;
+            }
+            const line = assertion.location?.line ?? (result.status === 'failed' ? locateJestFailure(result.details ?? '', testFile) : undefined);
+            if (typeof line === 'number') result.line = line;
+            results.push(result);
+        }
+        if (file.status === 'failed' && !assertions.some(a => a.status === 'failed')) {
+            const details = stripAnsi(String(file.message ?? file.failureMessage ?? '')).trim();
+            results.push({
+                suite,
+                name: '(test file)',
+                status: 'error',
+                durationMs: 0,
+                message: details.split('\n').find(l => l.trim())?.trim() ?? 'test file failed to run',
+                file: testFile,
+                ...(details ? { details } : {}),
+            });
+        }
+    }
+    return results;
+}
@@ -0,0 +1,81 @@
+import { type Diagnostic, resolveDiagnosticPath } from './index.js';
+
+// file(line,col): error TS1234: message
+const tscLinePattern = /^(.+?)\((\d+),(\d+)\): (error|warning|message) (TS\d+): (.*)$/;
+
+/**
+ * Parse `tsc --pretty false` output; the TS error code becomes the rule.
+ * Indented lines continue the previous message (e.g. elaborated type mismatches).
+ */
+export function parseTscOutput(output: string, cwd: string): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    for (const rawLine of output.split('\n')) {
+        const line = rawLine.trimEnd();
+        if (!line) continue;
+        const match = tscLinePattern.exec(line);
+        if (!match) {
+            const previous = diagnostics[diagnostics.length - 1];
+            if (previous && /^\s/.test(line)) {
+                previous.message += `\n${line.trim()}`;
+            } else {
+                // Project-level errors have no location: "error TS5058: The specified path does not exist"
+                const global = /^(error|warning) (TS\d+): (.*)$/.exec(line);
+                if (global) {
+                    diagnostics.push({
+                        file: '',
+                        line: 0,
+                        column: 0,
+                        severity: global[1] === 'warning' ? 'warning' : 'error',
+                        message: global[3] ?? '',
+                        rule: global[2] ?? '',
+                        source: 'tsc',
+                    });
+                }
+            }
+            continue;
+        }
+        const [, file = '', lineNo = '0', column = '0', category = 'error', code = '', message = ''] = match;
+        diagnostics.push({
+            file: resolveDiagnosticPath(file, cwd),
+            line: Number(lineNo),
+            column: Number(column),
+            severity: category === 'error' ? 'error' : category === 'warning' ? 'warning' : 'info',
+            message,
+            rule: code,
+            source: 'tsc',
+        });
+    }
+    return diagnostics;
+}
+
+/**
+ * Parse `eslint --format json`: severity 2 is an error, 1 a warning; fatal
+ * (parse) errors have no rule
+ */
+export function parseEslintJsonOutput(output: string, cwd: string): Diagnostic[] {
+    const start = output.indexOf('[');
+    if (start < 0) return [];
+    let results: any[];
+    try {
+        results = JSON.parse(output.slice(start));
+    } catch {
+        return [];
+    }
+    if (!Array.isArray(results)) return [];
+    const diagnostics: Diagnostic[] = [];
+    for (const result of results) {
+        for (const message of result.messages ?? []) {
+            diagnostics.push({
+                file: resolveDiagnosticPath(String(result.filePath ?? ''), cwd),
+                line: Number(message.line ?? 0),
+                column: Number(message.column ?? 0),
+                severity: message.severity === 2 ? 'error' : 'warning',
+                message: String(message.message ?? ''),
+                ...(message.ruleId ? { rule: String(message.ruleId) } : {}),
+                source: 'eslint',
+                ...(message.fix ? { fixable: true } : {}),
+            });
+        }
+    }
+    return diagnostics;
+}
@@ -0,0 +1,195 @@
+import type { Diagnostic, DiagnosticSeverity } from '../diagnostics/index.js';
+
+export interface MarkdownLink {
+    target: string;
+    line: number;
+    column: number;
+    image: boolean;
+}
+
+export interface MarkdownDocument {
+    // Anchors the document offers: heading slugs as GitHub makes them, <a name> and id attributes
+    anchors: Set<string>;
+    links: MarkdownLink[];
+    // [text][ref] and [ref][] uses of reference definitions that do not exist
+    undefinedReferences: Array<{ label: string; line: number; column: number }>;
+    findings: Array<{ line: number; severity: DiagnosticSeverity; rule: string; message: string }>;
+}
+
+// One exported Go declaration, as the go/ast helper's docs query lists it
+export interface GoDocEntry {
+    kind: 'func' | 'method' | 'type' | 'var' | 'const' | 'package';
+    // Receiver-qualified for methods: Store.Get
+    name: string;
+    package: string;
+    doc: string;
+    file: string;
+    line: number;
+    column: number;
+}
+
+const FENCE = /^( {0,3})(`{3,}|~{3,})(.*)$/;
+const HEADING = /^ {0,3}(#{1,6})\s+(.*?)(?:\s+#+)?\s*$/;
+const REFERENCE_DEFINITION = /^ {0,3}\[([^\]]+)\]:\s*(<[^>]*>|\S+)/;
+
+/**
+ * The anchor GitHub gives a heading: inline markup dropped, lower-cased,
+ * punctuation other than - and _ removed, spaces turned into hyphens
+ */
+export function slugify(heading: string): string {
+    const text = heading
+        .replace(/!?\[([^\]]*)\]\([^)]*\)/g, '$1')
+        .replace(/<[^>]+>/g, '')
+        .replace(/(^|\s)_+([^_]+?)_+(?=\s|$)/g, '$1$2');
+    return text.toLowerCase().trim().replace(/[^\p{L}\p{N}\s_-]/gu, '').replace(/\s/g, '-');
+}
+
+const normalizeLabel = (label: string) => label.trim().replace(/\s+/g, ' ').toLowerCase();
+
+/**
+ * Read a Markdown document's anchors, links and reference uses, skipping
+ * code, and check its code fences: a fence never closed, a fence opened
+ * inside another (a missing close), and fences without a language.
+ */
+export function parseMarkdown(source: string): MarkdownDocument {
+    const lines = source.split('\n').map(l => l.replace(/\r$/, ''));
+    const doc: MarkdownDocument = { anchors: new Set(), links: [], undefinedReferences: [], findings: [] };
+    const slugCounts = new Map<string, number>();
+    const definitions = new Set<string>();
+    const references: Array<{ label: string; line: number; column: number }> = [];
+    let fence: { marker: string; line: number } | null = null;
+    let inComment = false;
+
+    for (let k = 0; k < lines.length; k++) {
+        const line = lines[k]!;
+        const fenceMatch = FENCE.exec(line);
+        if (fence) {
+            if (fenceMatch && fenceMatch[2]![0] === fence.marker[0] && fenceMatch[2]!.length >= fence.marker.length) {
+                if (fenceMatch[3]!.trim() === '') {
+                    fence = null;
+                } else if (fence.marker[0] === '`') {
+                    doc.findings.push({ line: k + 1, severity: 'warning', rule: 'nested-fence', message: `Code fence opened on line ${fence.line} is still open, so this fence is part of that block; close it first` });
+                }
+            }
+            continue;
+        }
+        if (fenceMatch && !(fenceMatch[2]![0] === '`' && fenceMatch[3]!.includes('`'))) {
+            fence = { marker: fenceMatch[2]!, line: k + 1 };
+            if (fenceMatch[3]!.trim() === '') doc.findings.push({ line: k + 1, severity: 'info', rule: 'fence-language', message: 'Code fence has no language, so it is not highlighted' });
+            continue;
+        }
+        // Indented code
+        if (/^( {4}|\t)/.test(line) && (k === 0 || lines[k - 1]!.trim() === '')) continue;
+
+        // HTML comments, possibly over several lines
+        let text = line;
+        if (inComment) {
+            const end = text.indexOf('-->');
+            if (end < 0) continue;
+            text = ' '.repeat(end + 3) + text.slice(end + 3);
+            inComment = false;
+        }
+        text = text.replace(/<!--.*?-->/g, m => ' '.repeat(m.length));
+        const open = text.indexOf('<!--');
+        if (open >= 0) {
+            text = text.slice(0, open);
+            inComment = true;
+        }
+        // Code spans, blanked so columns stay put
+        text = text.replace(/(`+)(?:(?!\1).)+?\1/g, m => ' '.repeat(m.length));
+
+        const heading = HEADING.exec(text);
+        if (heading) {
+            const custom = /\s*\{#([\w-]+)\}\s*$/.exec(heading[2]!);
+            if (custom) doc.anchors.add(custom[1]!);
+            const slug = slugify(custom ? heading[2]!.slice(0, custom.index) : heading[2]!);
+            const count = slugCounts.get(slug) ?? 0;
+            doc.anchors.add(count === 0 ? slug : `${slug}-${count}`);
+            slugCounts.set(slug, count + 1);
+        }
+        for (const anchor of text.matchAll(/<a\s[^>]*\bname=["']([^"']+)["']|\bid=["']([^"']+)["']/gi)) doc.anchors.add(anchor[1] ?? anchor[2]!);
+
+        const definition = REFERENCE_DEFINITION.exec(text);
+        if (definition) {
+            definitions.add(normalizeLabel(definition[1]!));
+            doc.links.push({ target: definition[2]!.replace(/^<|>$/g, ''), line: k + 1, column: text.indexOf('[') + 1, image: false });
+            continue;
+        }
+        for (const link of text.matchAll(/(!?)\[((?:[^\]\\]|\\.)*)\]\(\s*(<[^>]*>|[^\s)]*)(?:\s+["'(][^)]*)?\)/g)) {
+            doc.links.push({ target: link[3]!.replace(/^<|>$/g, ''), line: k + 1, column: link.index! + 1, image: link[1] === '!' });
+        }
+        for (const use of text.matchAll(/\[((?:[^\]\\]|\\.)+)\]\[([^\]]*)\]/g)) {
+            references.push({ label: normalizeLabel(use[2] || use[1]!), line: k + 1, column: use.index! + 1 });
+        }
+    }
+    if (fence) doc.findings.push({ line: fence.line, severity: 'error', rule: 'unclosed-fence', message: `Code fence opened here is never closed, so the rest of the document renders as code` });
+    doc.undefinedReferences = references.filter(r => !definitions.has(r.label));
+    return doc;
+}
+
+export interface CodeBlock {
+    // First word of the info string, lower-cased: go, python, ...
+    language: string;
+    // The remaining words of the info string, such as ignore or norun
+    attributes: string[];
+    code: string;
+    // Line the code starts on, after the opening fence
+    line: number;
+}
+
+/**
+ * The fenced code blocks of a Markdown document; unclosed fences run to the end
+ */
+export function extractCodeBlocks(source: string): CodeBlock[] {
+    const lines = source.split('\n').map(l => l.replace(/\r$/, ''));
+    const blocks: CodeBlock[] = [];
+    for (let k = 0; k < lines.length; k++) {
+        const open = FENCE.exec(lines[k]!);
+        if (!open || (open[2]![0] === '`' && open[3]!.includes('`'))) continue;
+        const [language = '', ...attributes] = open[3]!.trim().replace(/[{}.]/g, ' ').split(/[\s,]+/).filter(Boolean);
+        const indent = open[1]!.length;
+        const code: string[] = [];
+        let j = k + 1;
+        for (; j < lines.length; j++) {
+            const close = FENCE.exec(lines[j]!);
+            if (close && close[2]![0] === open[2]![0] && close[2]!.length >= open[2]!.length && close[3]!.trim() === '') break;
+            // Content is unindented by as much as the fence was
+            code.push(lines[j]!.replace(new RegExp(`^ {0,${indent}}`), ''));
+        }
+        blocks.push({ language: language.toLowerCase(), attributes: attributes.map(a => a.toLowerCase()), code: code.join('\n'), line: k + 2 });
+        k = j;
+    }
+    return blocks;
+}
+
+function expectedStart(entry: GoDocEntry): string {
+    return entry.kind === 'method' ? entry.name.slice(entry.name.indexOf('.') + 1) : entry.name;
+}
+
+/**
+ * Check Go doc comments the way golint's exported rule does: every
+ * exported declaration and package has a comment, and it starts with the
+ * name (types may start with A, An or The; deprecation notices pass).
+ */
+export function checkGoDocs(entries: GoDocEntry[], source = 'check_docs'): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    const add = (entry: GoDocEntry, severity: DiagnosticSeverity, rule: string, message: string) =>
+        diagnostics.push({ file: entry.file, line: entry.line, column: entry.column, severity, message, rule, source });
+    for (const entry of entries) {
+        const doc = entry.doc.trim();
+        if (entry.kind === 'package') {
+            if (!doc) add(entry, 'warning', 'missing-package-doc', `package ${entry.name} should have a package comment`);
+            else if (!doc.startsWith(`Package ${entry.name} `) && !doc.startsWith(`Package ${entry.name}\n`)) add(entry, 'info', 'doc-form', `package comment should be of the form "Package ${entry.name} ..."`);
+            continue;
+        }
+        const name = expectedStart(entry);
+        if (!doc) {
+            add(entry, 'warning', 'missing-doc', `exported ${entry.kind} ${entry.package}.${entry.name} should have a comment`);
+            continue;
+        }
+        const first = doc.split(/\s+/);
+        const starts = first[0] === name || (entry.kind === 'type' && ['A', 'An', 'The'].includes(first[0]!) && first[1] === name) || first[0] === 'Deprecated:';
+        if (!starts) add(entry, 'info', 'doc-form', `comment on exported ${entry.kind} ${entry.package}.${entry.name} should be of the form "${name} ..."`);
+    }
+    return diagnostics;
+}
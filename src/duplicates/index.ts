@@ -0,0 +1,245 @@
+export interface Token {
+    // Normalized form: keywords and punctuation as written, ID for identifiers, LIT for literals, COMMENT for comments
+    kind: string;
+    text: string;
+    line: number;
+    column: number;
+}
+
+export interface SourceFile {
+    file: string;
+    tokens: Token[];
+}
+
+export interface Fragment {
+    file: string;
+    startLine: number;
+    endLine: number;
+}
+
+export interface CloneGroup {
+    // Length of the shortest copy, in tokens
+    tokens: number;
+    // Length of the longest copy, in lines
+    lines: number;
+    // Share of tokens identical as written, not just after renaming: 1 for a verbatim copy
+    similarity: number;
+    fragments: Fragment[];
+}
+
+// Kept as written, so only code of the same shape matches; other words are identifiers
+const KEYWORDS = new Set([
+    'if', 'else', 'for', 'while', 'do', 'switch', 'case', 'default', 'break', 'continue', 'return', 'goto',
+    'func', 'function', 'def', 'fn', 'class', 'struct', 'interface', 'enum', 'type', 'impl', 'trait',
+    'var', 'let', 'const', 'static', 'final', 'public', 'private', 'protected', 'async', 'await', 'yield',
+    'try', 'catch', 'except', 'finally', 'throw', 'throws', 'raise', 'new', 'delete', 'in', 'of', 'is', 'not', 'and', 'or',
+    'import', 'from', 'package', 'export', 'go', 'defer', 'select', 'range', 'chan', 'map', 'match', 'with', 'as',
+    'true', 'false', 'null', 'nil', 'None', 'True', 'False', 'undefined', 'this', 'self', 'super', 'lambda', 'pass',
+]);
+
+// Windows shared by more places than this are boilerplate, not copies worth reporting
+const MAX_OCCURRENCES = 64;
+const HASH_BASE = 1000003;
+// Positions pack a file index and a token index into one number
+const POSITION_SCALE = 2 ** 24;
+
+/**
+ * Split source into tokens, dropping whitespace and, unless keepComments,
+ * comments. Works for C-like languages; hashComments also treats # as a
+ * line comment and triple quotes as strings (Python, Ruby, shell).
+ */
+export function tokenize(source: string, hashComments = false, keepComments = false): Token[] {
+    const tokens: Token[] = [];
+    const n = source.length;
+    let line = 1;
+    let lineStart = 0;
+    let i = 0;
+    // Block comments and strings may span lines
+    const countLines = (start: number) => {
+        for (let k = start; k < i; k++) {
+            if (source.charCodeAt(k) === 10) {
+                line++;
+                lineStart = k + 1;
+            }
+        }
+    };
+    const push = (kind: string, start: number) => {
+        tokens.push({ kind, text: source.slice(start, i), line, column: start - lineStart + 1 });
+        countLines(start);
+    };
+    while (i < n) {
+        const c = source[i]!;
+        const next = source[i + 1];
+        const start = i;
+        if (c === '\n') {
+            line++;
+            i++;
+            lineStart = i;
+        } else if (c === ' ' || c === '\t' || c === '\r') {
+            i++;
+        } else if ((c === '/' && next === '/') || (hashComments && c === '#')) {
+            while (i < n && source[i] !== '\n') i++;
+            if (keepComments) push('COMMENT', start);
+        } else if (c === '/' && next === '*') {
+            const end = source.indexOf('*/', i + 2);
+            i = end < 0 ? n : end + 2;
+            if (keepComments) push('COMMENT', start);
+            else countLines(start);
+        } else if (c === '"' || c === '\'' || c === '`') {
+            const quote = hashComments && source.startsWith(c.repeat(3), i) ? c.repeat(3) : c;
+            i += quote.length;
+            while (i < n && !source.startsWith(quote, i)) {
+                // An unclosed quote ends at the line (Rust lifetimes, stray apostrophes)
+                if (source[i] === '\n' && quote.length === 1 && c !== '`') break;
+                i += source[i] === '\\' ? 2 : 1;
+            }
+            if (source.startsWith(quote, i)) i += quote.length;
+            push('LIT', start);
+        } else if (/[0-9]/.test(c)) {
+            while (i < n && /[\w.]/.test(source[i]!)) i++;
+            push('LIT', start);
+        } else if (/[A-Za-z_$]/.test(c)) {
+            while (i < n && /[\w$]/.test(source[i]!)) i++;
+            const word = source.slice(start, i);
+            push(KEYWORDS.has(word) ? word : 'ID', start);
+        } else {
+            i++;
+            push(c, start);
+        }
+    }
+    return tokens;
+}
+
+class UnionFind {
+    private readonly parent = new Map<string, string>();
+
+    public find(key: string): string {
+        let root = key;
+        while (this.parent.has(root) && this.parent.get(root) !== root) root = this.parent.get(root)!;
+        this.parent.set(key, root);
+        return root;
+    }
+
+    public union(a: string, b: string): void {
+        const ra = this.find(a);
+        const rb = this.find(b);
+        if (ra !== rb) this.parent.set(rb, ra);
+    }
+}
+
+/**
+ * Find blocks of at least minTokens tokens that occur more than once, with
+ * identifiers and literals normalized so renamed copies match too. Windows
+ * are matched by rolling hash and grown to the longest common run; copies
+ * of the same block are grouped.
+ */
+export function findClones(files: SourceFile[], options: { minTokens: number; minLines: number }): CloneGroup[] {
+    const window = options.minTokens;
+    const ids = new Map<string, number>();
+    const sequences = files.map(f => Int32Array.from(f.tokens, t => {
+        if (!ids.has(t.kind)) ids.set(t.kind, ids.size + 1);
+        return ids.get(t.kind)!;
+    }));
+
+    let power = 1;
+    for (let k = 1; k < window; k++) power = Math.imul(power, HASH_BASE) >>> 0;
+    const hashes: Uint32Array[] = [];
+    const buckets = new Map<number, number[]>();
+    for (const [f, seq] of sequences.entries()) {
+        const count = Math.max(0, seq.length - window + 1);
+        const fileHashes = new Uint32Array(count);
+        let h = 0;
+        for (let k = 0; k < Math.min(window, seq.length); k++) h = (Math.imul(h, HASH_BASE) + seq[k]!) >>> 0;
+        for (let i = 0; i < count; i++) {
+            fileHashes[i] = h;
+            const bucket = buckets.get(h);
+            if (bucket) bucket.push(f * POSITION_SCALE + i);
+            else buckets.set(h, [f * POSITION_SCALE + i]);
+            if (i + window < seq.length) h = (Math.imul((h - Math.imul(seq[i]!, power)) >>> 0, HASH_BASE) + seq[i + window]!) >>> 0;
+        }
+        hashes.push(fileHashes);
+    }
+
+    const fragments = new Map<string, Fragment>();
+    const groups = new UnionFind();
+    const pairs: Array<{ key: string; tokens: number; similarity: number }> = [];
+    // How far each alignment (file pair and offset) is already matched, so a run is reported once
+    const covered = new Map<string, number>();
+    for (const [fa, seqA] of sequences.entries()) {
+        for (let i = 0; i < hashes[fa]!.length; i++) {
+            const bucket = buckets.get(hashes[fa]![i]!)!;
+            if (bucket.length < 2 || bucket.length > MAX_OCCURRENCES) continue;
+            for (const position of bucket) {
+                const fb = Math.floor(position / POSITION_SCALE);
+                const j = position % POSITION_SCALE;
+                if (fb < fa || (fb === fa && j < i + window)) continue;
+                const alignment = `${fa}:${fb}:${j - i}`;
+                if ((covered.get(alignment) ?? -1) > i) continue;
+                const seqB = sequences[fb]!;
+                let length = 0;
+                while (i + length < seqA.length && j + length < seqB.length && seqA[i + length] === seqB[j + length] && (fa !== fb || i + length < j)) length++;
+                // Equal hashes of different windows
+                if (length < window) continue;
+                covered.set(alignment, i + length);
+
+                const tokensA = files[fa]!.tokens;
+                const tokensB = files[fb]!.tokens;
+                const a = { file: files[fa]!.file, startLine: tokensA[i]!.line, endLine: tokensA[i + length - 1]!.line };
+                const b = { file: files[fb]!.file, startLine: tokensB[j]!.line, endLine: tokensB[j + length - 1]!.line };
+                if (Math.min(a.endLine - a.startLine, b.endLine - b.startLine) + 1 < options.minLines) continue;
+                let same = 0;
+                for (let k = 0; k < length; k++) if (tokensA[i + k]!.text === tokensB[j + k]!.text) same++;
+
+                const keyA = `${a.file}:${a.startLine}-${a.endLine}`;
+                const keyB = `${b.file}:${b.startLine}-${b.endLine}`;
+                fragments.set(keyA, a);
+                fragments.set(keyB, b);
+                groups.union(keyA, keyB);
+                pairs.push({ key: keyA, tokens: length, similarity: same / length });
+            }
+        }
+    }
+
+    // Overlapping ranges of one file are the same copy, matched from different starts
+    const byFile = new Map<string, Array<[string, Fragment]>>();
+    for (const entry of fragments) byFile.set(entry[1].file, [...(byFile.get(entry[1].file) ?? []), entry]);
+    for (const list of byFile.values()) {
+        list.sort((x, y) => x[1].startLine - y[1].startLine);
+        let open = list[0]!;
+        for (const entry of list.slice(1)) {
+            if (entry[1].startLine <= open[1].endLine) groups.union(open[0], entry[0]);
+            if (entry[1].endLine > open[1].endLine) open = entry;
+        }
+    }
+    const grouped = new Map<string, Fragment[]>();
+    for (const [key, fragment] of fragments) {
+        const root = groups.find(key);
+        grouped.set(root, [...(grouped.get(root) ?? []), fragment]);
+    }
+    // A group is as long and as similar as its weakest pair
+    const stats = new Map<string, { tokens: number; similarity: number }>();
+    for (const pair of pairs) {
+        const root = groups.find(pair.key);
+        const before = stats.get(root);
+        stats.set(root, { tokens: Math.min(before?.tokens ?? pair.tokens, pair.tokens), similarity: Math.min(before?.similarity ?? pair.similarity, pair.similarity) });
+    }
+    const result: CloneGroup[] = [];
+    for (const [root, list] of grouped) {
+        const own = stats.get(root)!;
+        list.sort((x, y) => (x.file < y.file ? -1 : x.file > y.file ? 1 : x.startLine - y.startLine));
+        const merged: Fragment[] = [];
+        for (const fragment of list) {
+            const last = merged[merged.length - 1];
+            if (last && last.file === fragment.file && fragment.startLine <= last.endLine) last.endLine = Math.max(last.endLine, fragment.endLine);
+            else merged.push({ ...fragment });
+        }
+        if (merged.length < 2) continue;
+        result.push({
+            tokens: own.tokens,
+            lines: Math.max(...merged.map(f => f.endLine - f.startLine + 1)),
+            similarity: Math.round(own.similarity * 1000) / 1000,
+            fragments: merged,
+        });
+    }
+    return result.sort((x, y) => y.tokens - x.tokens || y.fragments.length - x.fragments.length);
+}
@@ -0,0 +1,124 @@
+import { AsyncLocalStorage } from 'async_hooks';
+import type { StreamHandler } from '../utils/command.js';
+
+// Partial output kept per call, the tail of what its commands printed
+const MAX_PARTIAL_OUTPUT = 64 * 1024;
+
+const executionContext = new AsyncLocalStorage<Execution>();
+
+export interface ExecutionInfo {
+    // The call's request id
+    id: string;
+    tool: string;
+    // HTTP client that made the call; absent over stdio
+    client?: string;
+    startedAt: string;
+    elapsedMs: number;
+    cancelled: boolean;
+}
+
+/**
+ * One in-flight tool call: whoever holds its id can cancel it, which aborts
+ * the signal its commands run with. The output its commands print is kept
+ * (the last 64 KB) so a cancelled call still returns what it got to.
+ */
+export class Execution {
+    public readonly id: string;
+    public readonly tool: string;
+    public readonly client: string | undefined;
+    private readonly startedAt = Date.now();
+    private readonly controller = new AbortController();
+    private output = '';
+    private reason: string | undefined;
+
+    constructor(id: string, tool: string, client?: string) {
+        this.id = id;
+        this.tool = tool;
+        this.client = client;
+    }
+
+    public get signal(): AbortSignal {
+        return this.controller.signal;
+    }
+
+    public get cancelled(): boolean {
+        return this.controller.signal.aborted;
+    }
+
+    public get cancelReason(): string | undefined {
+        return this.reason;
+    }
+
+    public cancel(reason: string): boolean {
+        if (this.cancelled) return false;
+        this.reason = reason;
+        this.controller.abort(new Error(reason));
+        return true;
+    }
+
+    public run<T>(fn: () => Promise<T>): Promise<T> {
+        return executionContext.run(this, fn);
+    }
+
+    // Collects command output; passes it on to the client's progress handler when there is one
+    public streamHandler(forward?: StreamHandler): StreamHandler {
+        return (chunk, stream) => {
+            this.output = (this.output + chunk).slice(-MAX_PARTIAL_OUTPUT);
+            forward?.(chunk, stream);
+        };
+    }
+
+    public get partialOutput(): string {
+        return this.output;
+    }
+
+    public info(): ExecutionInfo {
+        return {
+            id: this.id,
+            tool: this.tool,
+            ...(this.client ? { client: this.client } : {}),
+            startedAt: new Date(this.startedAt).toISOString(),
+            elapsedMs: Date.now() - this.startedAt,
+            cancelled: this.cancelled,
+        };
+    }
+}
+
+/**
+ * Tool calls currently running or queued, by request id
+ */
+export class ExecutionRegistry {
+    private executions = new Map<string, Execution>();
+
+    public start(id: string, tool: string, client?: string): Execution {
+        const execution = new Execution(id, tool, client);
+        // A client reusing a request id addresses its newest call
+        this.executions.set(id, execution);
+        return execution;
+    }
+
+    public finish(execution: Execution): void {
+        if (this.executions.get(execution.id) === execution) this.executions.delete(execution.id);
+    }
+
+    public get(id: string): Execution | undefined {
+        return this.executions.get(id);
+    }
+
+    // client limits the list to one HTTP client's calls
+    public list(client?: string): ExecutionInfo[] {
+        return [...this.executions.values()]
+            .filter(e => client === undefined || e.client === client)
+            .map(e => e.info())
+            .sort((a, b) => a.startedAt.localeCompare(b.startedAt));
+    }
+}
+
+/**
+ * The tool call being handled, if any
+ */
+export function currentExecution(): Execution | undefined {
+    return executionContext.getStore();
+}
+
+export const executionRegistry = new ExecutionRegistry();
@@ -0,0 +1,50 @@
+import { resolve } from 'path';
+import Config, { isWithin } from '../config/index.js';
+import { shellQuote } from '../utils/shell.js';
+import { type ResourceLimits } from './limits.js';
+
+/**
+ * Wrap a shell command so it runs in a short-lived container. Allowed roots are
+ * bind-mounted at their host paths (read-only roots stay read-only), so the
+ * absolute paths tools pass around keep working inside the container.
+ */
+export function buildDockerCommand(
+    command: string,
+    options: { cwd: string; env: Record<string, string>; image?: string; limits?: ResourceLimits; noNetwork?: boolean }
+): string {
+    const config = Config.getInstance();
+    const image = options.image || config.getDockerImage(command);
+    const args = ['docker', 'run', '--rm', '-i'];
+    if (typeof process.getuid === 'function' && typeof process.getgid === 'function') {
+        args.push('--user', `${process.getuid()}:${process.getgid()}`);
+    }
+    if (options.noNetwork) args.push('--network', 'none');
+    // Swap equal to memory means no swap on top of the cap
+    if (options.limits?.memoryMb) {
+        const memory = `${Math.floor(options.limits.memoryMb)}m`;
+        args.push('--memory', memory, '--memory-swap', memory);
+    }
+    if (options.limits?.cpuSeconds) {
+        const cpu = Math.ceil(options.limits.cpuSeconds);
+        args.push('--ulimit', `cpu=${cpu}:${cpu + 5}`);
+    }
+    const mounted = new Set<string>();
+    const mount = (path: string, readOnly: boolean) => {
+        const abs = resolve(path);
+        if (mounted.has(abs)) return;
+        mounted.add(abs);
+        args.push('-v', shellQuote(`${abs}:${abs}${readOnly ? ':ro' : ''}`));
+    };
+    config.getAllowedPaths().forEach(path => mount(path, false));
+    config.getReadOnlyPaths().forEach(path => mount(path, true));
+    // The working directory must exist in the container even when it is outside the roots
+    if (![...mounted].some(path => isWithin(path, resolve(options.cwd)))) {
+        mount(options.cwd, false);
+    }
+    args.push('-w', shellQuote(resolve(options.cwd)));
+    for (const [key, value] of Object.entries(options.env)) {
+        args.push('-e', shellQuote(`${key}=${value}`));
+    }
+    args.push(shellQuote(image), 'sh', '-c', shellQuote(command));
+    return args.join(' ');
+}
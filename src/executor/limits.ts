@@ -0,0 +1,92 @@
+import { shellQuote } from '../utils/shell.js';
+
+export interface ResourceLimits {
+    // Address-space (rlimit) or cgroup memory cap for the command and its children
+    memoryMb?: number;
+    // CPU time, not wall-clock time; wall-clock is the command timeout
+    cpuSeconds?: number;
+}
+
+export type LimitStrategy = 'rlimit' | 'cgroup';
+
+export type LimitKind = 'timeout' | 'memory' | 'cpu';
+
+// 128 + SIGXCPU, as reported by the shell
+const SIGXCPU_EXIT = 152;
+// 128 + SIGKILL, the cgroup OOM killer
+const SIGKILL_EXIT = 137;
+
+const OUT_OF_MEMORY_PATTERN = /out of memory|cannot allocate memory|MemoryError|std::bad_alloc|memory allocation of \d+ bytes failed|heap out of memory|OutOfMemoryError/i;
+
+export function hasLimits(limits: ResourceLimits | undefined): limits is ResourceLimits {
+    return Boolean(limits && (limits.memoryMb || limits.cpuSeconds));
+}
+
+/**
+ * The tightest of several sets of limits, key by key: a later set can lower
+ * a cap but never lift one
+ */
+export function tightestLimits(...sets: (ResourceLimits | undefined)[]): ResourceLimits {
+    const tightest: ResourceLimits = {};
+    for (const key of ['memoryMb', 'cpuSeconds'] as const) {
+        const values = sets.map(set => set?.[key]).filter((value): value is number => value !== undefined && value > 0);
+        if (values.length > 0) tightest[key] = Math.min(...values);
+    }
+    return tightest;
+}
+
+/**
+ * Wrap a shell command so the limits apply to it and everything it spawns.
+ * rlimit sets soft ulimits (CPU overruns get SIGXCPU); cgroup runs the command
+ * in a transient systemd scope with MemoryMax, keeping the CPU rlimit.
+ */
+export function buildLimitedCommand(command: string, limits: ResourceLimits, strategy: LimitStrategy = 'rlimit'): string {
+    const ulimits: string[] = [];
+    if (limits.cpuSeconds) ulimits.push(`ulimit -S -t ${Math.ceil(limits.cpuSeconds)}`);
+    if (limits.memoryMb && strategy === 'rlimit') ulimits.push(`ulimit -S -v ${Math.floor(limits.memoryMb * 1024)}`);
+    const limited = ulimits.length > 0 ? `${ulimits.join(' && ')} && ${command}` : command;
+    if (strategy === 'cgroup' && limits.memoryMb) {
+        const properties = [`MemoryMax=${Math.floor(limits.memoryMb)}M`, 'MemorySwapMax=0'].map(p => `-p ${p}`).join(' ');
+        return `systemd-run --user --scope --quiet --collect ${properties} -- sh -c ${shellQuote(limited)}`;
+    }
+    return limited;
+}
+
+/**
+ * Which limit, if any, explains how a command ended
+ */
+export function detectLimitExceeded(
+    result: { exitCode: number; stderr: string; signal?: string | null; timedOut?: boolean },
+    limits: ResourceLimits | undefined,
+    strategy: LimitStrategy = 'rlimit'
+): LimitKind | null {
+    if (result.timedOut) return 'timeout';
+    if (!hasLimits(limits) || result.exitCode === 0) return null;
+    if (limits.cpuSeconds && (result.signal === 'SIGXCPU' || result.exitCode === SIGXCPU_EXIT)) return 'cpu';
+    if (limits.memoryMb) {
+        if (strategy === 'cgroup' && (result.signal === 'SIGKILL' || result.exitCode === SIGKILL_EXIT)) return 'memory';
+        if (OUT_OF_MEMORY_PATTERN.test(result.stderr)) return 'memory';
+    }
+    return null;
+}
+
+/**
+ * Wrap a shell command so the shell reports the CPU time of everything it
+ * ran on file descriptor 3 (the `times` builtin), keeping the exit status.
+ * The command itself runs with fd 3 closed, so nothing it leaves running
+ * holds the report pipe open.
+ */
+export function buildCpuAccountedCommand(command: string): string {
+    return `(\n${command}\n) 3>&-\n__cf_status=$?\ntimes >&3\nexit $__cf_status`;
+}
+
+/**
+ * CPU seconds (user + system) of the children in `times` output, or null
+ */
+export function parseShellTimes(output: string): number | null {
+    // First line is the shell itself, second its children: "0m1.250s 0m0.310s"
+    const children = output.trim().split('\n')[1];
+    const parts = [...(children ?? '').matchAll(/(\d+)m([\d.]+)s/g)];
+    if (parts.length !== 2) return null;
+    return parts.reduce((total, [, minutes, seconds]) => total + Number(minutes) * 60 + Number(seconds), 0);
+}
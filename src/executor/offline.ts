@@ -0,0 +1,106 @@
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import type { TransientRule } from './retry.js';
+
+// on: package managers use vendored code and local caches only; strict: commands also get no network at all
+export type OfflineMode = 'off' | 'on' | 'strict';
+
+export const OFFLINE_MODES: OfflineMode[] = ['off', 'on', 'strict'];
+
+export interface OfflineViolation {
+    command: string;
+    rule: string;
+    description: string;
+}
+
+// Where nothing listens: with no network namespace, proxies pointing here make every HTTP client fail at once
+export const DENY_PROXY = 'http://127.0.0.1:9';
+
+// How each tool says it needed the network and was refused, by the offline settings or by the missing network
+const NETWORK_ATTEMPT_RULES: TransientRule[] = [
+    { id: 'go-proxy-off', description: 'Go needed a module that is neither vendored nor in the module cache', pattern: /module lookup disabled by GOPROXY=off|GOPROXY=off: (?:module|download) .*disabled/ },
+    { id: 'go-toolchain', description: 'Go tried to download a toolchain', pattern: /go: downloading go\d|toolchain not available|GOTOOLCHAIN=local/ },
+    { id: 'npm-offline', description: 'npm needed a package that is not in its cache', pattern: /^npm (?:ERR!|error) code (?:ENOTCACHED|ENETUNREACH|ENOTFOUND|EAI_AGAIN)\b/m },
+    { id: 'pip-no-index', description: 'pip needed a package with --no-index and no local wheel', pattern: /No matching distribution found for|Could not find a version that satisfies the requirement/ },
+    { id: 'uv-offline', description: 'uv needed a package that is not cached', pattern: /Network connectivity is disabled|because it was not found in the cache/ },
+    { id: 'cargo-offline', description: 'cargo needed a crate that is not vendored or cached', pattern: /attempting to make an HTTP request, but --offline was specified|--offline was specified|you can rerun with `--offline`/ },
+    { id: 'maven-offline', description: 'Maven needed an artifact missing from the local repository', pattern: /has not been downloaded from it before|Cannot access \S+ in offline mode/ },
+    {
+        id: 'network-unreachable',
+        description: 'a command tried to reach the network',
+        pattern: /Temporary failure in name resolution|Network is unreachable|ENETUNREACH|EAI_AGAIN|getaddrinfo ENOTFOUND|Could not resolve host|dial tcp: lookup [^ ]+.*(?:no such host|server misbehaving)|connect(?:ion)? refused.*127\.0\.0\.1:9\b|127\.0\.0\.1:9: connect/,
+    },
+];
+
+/**
+ * Whether a failed command's output shows it trying to reach the network
+ */
+export function detectNetworkAttempt(output: string): TransientRule | null {
+    return NETWORK_ATTEMPT_RULES.find(rule => rule.pattern.test(output)) ?? null;
+}
+
+/**
+ * MCP_OFFLINE: on (or 1/true), strict, or off by default
+ */
+export function offlineModeFromEnv(env: NodeJS.ProcessEnv = process.env): OfflineMode {
+    const value = env.MCP_OFFLINE?.toLowerCase();
+    if (value === 'strict') return 'strict';
+    return value === 'on' || value === '1' || value === 'true' ? 'on' : 'off';
+}
+
+/**
+ * The stricter of two modes: a call may tighten the configured mode, never loosen it
+ */
+export function strictestOffline(a: OfflineMode, b: OfflineMode | undefined): OfflineMode {
+    return OFFLINE_MODES.indexOf(b ?? 'off') > OFFLINE_MODES.indexOf(a) ? b! : a;
+}
+
+/**
+ * Variables that keep package managers off the network: GOPROXY=off (plus
+ * -mod=vendor where vendor/modules.txt exists) and no toolchain downloads,
+ * npm/pnpm/yarn --offline, pip --no-index, uv, cargo and Maven offline.
+ * goflags is the GOFLAGS the command would otherwise get.
+ */
+export async function offlineEnv(root: string | null, goflags: string | undefined): Promise<Record<string, string>> {
+    const vendored = root ? await fs.stat(join(root, 'vendor', 'modules.txt')).then(s => s.isFile(), () => false) : false;
+    const flags = (goflags ?? '').split(/\s+/).filter(flag => flag && !flag.startsWith('-mod='));
+    if (vendored) flags.push('-mod=vendor');
+    return {
+        GOPROXY: 'off',
+        GOTOOLCHAIN: 'local',
+        ...(flags.length > 0 ? { GOFLAGS: flags.join(' ') } : {}),
+        npm_config_offline: 'true',
+        YARN_ENABLE_OFFLINE_MODE: '1',
+        PIP_NO_INDEX: '1',
+        UV_OFFLINE: '1',
+        CARGO_NET_OFFLINE: 'true',
+        MAVEN_ARGS: '--offline',
+    };
+}
+
+/**
+ * Proxy settings that refuse every connection, for strict mode where no network sandbox is available
+ */
+export function denyProxyEnv(): Record<string, string> {
+    const env: Record<string, string> = {};
+    for (const name of ['HTTP_PROXY', 'HTTPS_PROXY', 'ALL_PROXY']) {
+        env[name] = DENY_PROXY;
+        env[name.toLowerCase()] = DENY_PROXY;
+    }
+    env.NO_PROXY = '';
+    env.no_proxy = '';
+    return env;
+}
+
+/**
+ * Advertise offline_mode, which the server applies to the commands a call runs
+ */
+export function withOfflineArg(inputSchema: any) {
+    return {
+        ...inputSchema,
+        properties: {
+            ...inputSchema?.properties,
+            offline_mode: { type: 'string', enum: OFFLINE_MODES, description: 'on: package managers use vendored and cached dependencies only; strict: commands also get no network. Can tighten MCP_OFFLINE or the offline setting in .code-feedback.yaml, not loosen it' },
+        },
+    };
+}
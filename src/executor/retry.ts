@@ -0,0 +1,105 @@
+export interface RetryPolicy {
+    // Extra runs after a transient failure; 0 only classifies
+    attempts: number;
+    // Delay before the first retry; doubled for each one after it
+    backoffMs: number;
+    // Extra regexes (as strings) marking a failure as transient
+    patterns?: string[];
+}
+
+export interface TransientRule {
+    id: string;
+    description: string;
+    pattern: RegExp;
+}
+
+export interface RetryEvent {
+    command: string;
+    rule: string;
+    description: string;
+    // Retries run before this one; the number of retries made when exhausted
+    attempt: number;
+    // Set while retrying: the delay before the next run
+    delayMs?: number;
+    // The last run failed transiently too and no retries are left
+    exhausted?: boolean;
+}
+
+export const DEFAULT_RETRY_POLICY: RetryPolicy = { attempts: 2, backoffMs: 2000 };
+const MAX_BACKOFF_MS = 30000;
+// Only the end of the output is searched: that is where tools print why they gave up
+const CLASSIFIED_TAIL = 64 * 1024;
+
+// Network trouble as the package managers and build tools themselves report it, never what a program under test prints
+const TRANSIENT_RULES: TransientRule[] = [
+    {
+        id: 'go-module-fetch',
+        description: 'network error while fetching Go modules',
+        pattern: /^(?:go: |verifying ).*(?:i\/o timeout|TLS handshake timeout|connection reset by peer|unexpected EOF|Temporary failure in name resolution|: (?:429|50[0234]) )/m,
+    },
+    {
+        id: 'npm-registry',
+        description: 'npm registry unreachable or returned 5xx',
+        pattern: /^npm (?:ERR!|error) (?:code|errno) (?:ETIMEDOUT|ECONNRESET|EAI_AGAIN|ESOCKETTIMEDOUT|ERR_SOCKET_TIMEOUT|E429|E50[0234])\b/m,
+    },
+    {
+        id: 'pnpm-yarn-registry',
+        description: 'package registry unreachable or returned 5xx',
+        pattern: /ERR_PNPM_(?:META_)?FETCH_(?:429|50[0234])|ERR_PNPM_\w+.*\b(?:ETIMEDOUT|ECONNRESET|EAI_AGAIN)\b|There appears to be trouble with your network connection/,
+    },
+    {
+        id: 'pip-index',
+        description: 'network error while downloading Python packages',
+        pattern: /ReadTimeoutError|ConnectTimeoutError|Connection broken: IncompleteRead|ProtocolError\('Connection aborted|HTTP error (?:429|50[0234]) while getting|error sending request for url/,
+    },
+    {
+        id: 'cargo-registry',
+        description: 'network error while downloading crates',
+        pattern: /spurious network error|\[(?:6|7|28|35|56)\] (?:Couldn't resolve host name|Couldn't connect to server|Timeout was reached|SSL connect error|Failure when receiving data)/,
+    },
+    {
+        id: 'maven-gradle-repository',
+        description: 'artifact repository unreachable or returned 5xx',
+        pattern: /Could not transfer artifact .*(?:Connect timed out|Read timed out|Connection reset|status code: 50[0234])|Could not (?:GET|HEAD) '[^']+'\. Received status code 50[0234]/,
+    },
+    {
+        id: 'docker-registry',
+        description: 'container registry unreachable, rate-limited or returned 5xx',
+        pattern: /TLS handshake timeout|net\/http: request canceled while waiting for connection|received unexpected HTTP status: 50[0234]|toomanyrequests: /,
+    },
+    {
+        id: 'git-remote',
+        description: 'git remote unreachable or returned 5xx',
+        pattern: /fatal: unable to access '[^']+': (?:Could not resolve host|Failed to connect|Operation timed out|The requested URL returned error: 50[0234]|GnuTLS recv error|OpenSSL SSL_read)|RPC failed; (?:curl (?:18|28|56)|HTTP 50[0234])|fatal: early EOF/,
+    },
+];
+
+/**
+ * Why a failed command's output looks like flaky infrastructure rather than
+ * a problem with the code, or null when it does not
+ */
+export function classifyFailure(output: string, patterns: string[] = []): TransientRule | null {
+    const tail = output.length > CLASSIFIED_TAIL ? output.slice(-CLASSIFIED_TAIL) : output;
+    const configured = patterns.map((pattern, index) => ({ id: `configured-${index + 1}`, description: `matched ${pattern}`, pattern: new RegExp(pattern, 'm') }));
+    return [...TRANSIENT_RULES, ...configured].find(rule => rule.pattern.test(tail)) ?? null;
+}
+
+/**
+ * Delay before retry number attempt (1-based): exponential with up to 25% jitter, capped at 30 s
+ */
+export function backoffDelay(backoffMs: number, attempt: number, random: () => number = Math.random): number {
+    const base = Math.min(backoffMs * 2 ** (attempt - 1), MAX_BACKOFF_MS);
+    return Math.round(base * (1 + random() * 0.25));
+}
+
+/**
+ * MCP_RETRY_ATTEMPTS and MCP_RETRY_BACKOFF_MS, over the defaults (2 retries, 2 s)
+ */
+export function retryPolicyFromEnv(env: NodeJS.ProcessEnv = process.env): RetryPolicy {
+    const attempts = Number(env.MCP_RETRY_ATTEMPTS);
+    const backoffMs = Number(env.MCP_RETRY_BACKOFF_MS);
+    return {
+        attempts: env.MCP_RETRY_ATTEMPTS !== undefined && Number.isInteger(attempts) && attempts >= 0 ? attempts : DEFAULT_RETRY_POLICY.attempts,
+        backoffMs: backoffMs >= 0 && env.MCP_RETRY_BACKOFF_MS ? backoffMs : DEFAULT_RETRY_POLICY.backoffMs,
+    };
+}
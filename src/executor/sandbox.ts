@@ -0,0 +1,33 @@
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+
+export type NetworkSandbox = 'unshare' | 'sandbox-exec';
+
+// Allows everything but sockets other than local ones
+const MACOS_PROFILE = '(version 1)(allow default)(deny network*)(allow network* (local unix))';
+
+let detected: Promise<NetworkSandbox | null> | undefined;
+
+/**
+ * How this host can run a command without network access: a fresh network
+ * namespace (unprivileged user namespaces on Linux) or a sandbox-exec profile
+ * on macOS. Null when neither works. Probed once per process.
+ */
+export function detectNetworkSandbox(): Promise<NetworkSandbox | null> {
+    detected ??= (async () => {
+        const probe = async (command: string) => (await runCommand(command, { local: true, timeout: 5000 }).catch(() => null))?.exitCode === 0;
+        if (process.platform === 'linux' && await probe('unshare --user --map-root-user --net true')) return 'unshare';
+        if (process.platform === 'darwin' && await probe(`sandbox-exec -p ${shellQuote(MACOS_PROFILE)} true`)) return 'sandbox-exec';
+        return null;
+    })();
+    return detected;
+}
+
+/**
+ * Wrap a shell command so it and everything it starts have no network:
+ * inside the namespace only a loopback interface exists, and it is down
+ */
+export function buildNoNetworkCommand(command: string, sandbox: NetworkSandbox): string {
+    if (sandbox === 'unshare') return `unshare --user --map-root-user --net -- sh -c ${shellQuote(command)}`;
+    return `sandbox-exec -p ${shellQuote(MACOS_PROFILE)} sh -c ${shellQuote(command)}`;
+}
@@ -0,0 +1,165 @@
+import { homedir, tmpdir } from 'os';
+import { join, relative, resolve } from 'path';
+import { isWithin } from '../config/index.js';
+import { type ProjectConfig, type SshRemote } from '../config/project.js';
+import { shellQuote } from '../utils/shell.js';
+import { buildLimitedCommand, hasLimits, type ResourceLimits } from './limits.js';
+
+/**
+ * A workspace bound to a remote build host: commands run under cwd inside
+ * localRoot are run at the same place under remoteRoot
+ */
+export interface SshTarget extends SshRemote {
+    // Name of the entry under remotes
+    name: string;
+    localRoot: string;
+    remoteRoot: string;
+}
+
+/**
+ * The remote a workspace's config selects with executor, or null when it
+ * runs locally or in docker. Throws for a name that is not under remotes.
+ */
+export function resolveSshTarget(config: ProjectConfig, workspaceRoot: string | null): SshTarget | null {
+    const name = config.executor;
+    if (!name || name === 'local' || name === 'docker') return null;
+    const remote = config.remotes?.[name];
+    if (!remote) throw new Error(`Executor "${name}" is not local, docker or one of the remotes in the global config`);
+    if (!workspaceRoot) throw new Error(`Executor "${name}" needs a workspace to sync; the call has no project path`);
+    const localRoot = resolve(workspaceRoot);
+    return { ...remote, name, localRoot, remoteRoot: remote.remoteRoot ?? localRoot };
+}
+
+/**
+ * Where a local path under the workspace lives on the remote host
+ */
+export function toRemotePath(path: string, target: SshTarget): string {
+    const rel = relative(target.localRoot, resolve(path)).split('\\').join('/');
+    return rel ? `${target.remoteRoot.replace(/\/+$/, '')}/${rel}` : target.remoteRoot;
+}
+
+/**
+ * Rewrite remote paths in command output to local ones, so diagnostics point at the local files
+ */
+export function toLocalPaths(text: string, target: SshTarget): string {
+    if (target.remoteRoot === target.localRoot) return text;
+    return text.split(target.remoteRoot).join(target.localRoot);
+}
+
+function destination(target: SshTarget): string {
+    return target.user ? `${target.user}@${target.host}` : target.host;
+}
+
+function expandHome(path: string): string {
+    return path === '~' || path.startsWith('~/') ? join(homedir(), path.slice(1)) : path;
+}
+
+// Never prompts, and reuses one connection per host across the commands of a call
+function sshOptions(target: SshTarget, portFlag: '-p' | '-P'): string[] {
+    const options = [
+        '-o', 'BatchMode=yes',
+        '-o', 'ControlMaster=auto',
+        '-o', shellQuote(`ControlPath=${join(tmpdir(), 'cf-ssh-%C')}`),
+        '-o', 'ControlPersist=60',
+    ];
+    if (target.port) options.push(portFlag, String(target.port));
+    if (target.identityFile) options.push('-i', shellQuote(expandHome(target.identityFile)));
+    return options;
+}
+
+function sshCommand(target: SshTarget): string {
+    return ['ssh', ...sshOptions(target, '-p')].join(' ');
+}
+
+function sftpPath(path: string): string {
+    return `"${path.replace(/["\\]/g, '\\$&')}"`;
+}
+
+// sftp has no excludes or deltas, so it copies the whole tree each way
+function sftpBatch(target: SshTarget, lines: string[]): string {
+    const batch = ['printf', shellQuote('%s\\n'), ...lines.map(shellQuote)].join(' ');
+    return `${batch} | sftp -q -b - ${sshOptions(target, '-P').join(' ')} ${shellQuote(destination(target))}`;
+}
+
+function upload(target: SshTarget): string {
+    const remote = `${destination(target)}:${target.remoteRoot.replace(/\/+$/, '')}/`;
+    if (target.sync === 'sftp') {
+        const mkdir = `${sshCommand(target)} ${shellQuote(destination(target))} ${shellQuote(`mkdir -p ${shellQuote(target.remoteRoot)}`)}`;
+        return `${mkdir} && ${sftpBatch(target, [`lcd ${sftpPath(target.localRoot)}`, `put -pR . ${sftpPath(target.remoteRoot)}`])}`;
+    }
+    const excludes = (target.exclude ?? []).map(pattern => `--exclude=${shellQuote(pattern)}`);
+    return [
+        'rsync', '-az', '--delete',
+        `--rsync-path=${shellQuote(`mkdir -p ${shellQuote(target.remoteRoot)} && rsync`)}`,
+        '-e', shellQuote(sshCommand(target)),
+        ...excludes,
+        shellQuote(`${target.localRoot.replace(/\/+$/, '')}/`), shellQuote(remote),
+    ].join(' ');
+}
+
+// Files are only ever added or updated locally, never deleted
+function download(target: SshTarget): string {
+    if (target.sync === 'sftp') {
+        return sftpBatch(target, [`lcd ${sftpPath(target.localRoot)}`, `get -pR ${sftpPath(`${target.remoteRoot.replace(/\/+$/, '')}/.`)} .`]);
+    }
+    const excludes = (target.exclude ?? []).map(pattern => `--exclude=${shellQuote(pattern)}`);
+    return [
+        'rsync', '-az', '--update',
+        '-e', shellQuote(sshCommand(target)),
+        ...excludes,
+        shellQuote(`${destination(target)}:${target.remoteRoot.replace(/\/+$/, '')}/`), shellQuote(`${target.localRoot.replace(/\/+$/, '')}/`),
+    ].join(' ');
+}
+
+/**
+ * Wrap a shell command so it runs on the remote host: the workspace is synced
+ * there first, the command runs in the matching directory with env exported
+ * (and limits applied as ulimits), and files it changed are copied back. The
+ * command's exit code is kept; a failed sync is reported on stderr.
+ */
+export function buildSshCommand(
+    command: string,
+    options: { cwd: string; env: Record<string, string>; target: SshTarget; limits?: ResourceLimits }
+): string {
+    const { target } = options;
+    const exports = Object.entries(options.env)
+        .filter(([key]) => /^[A-Za-z_][A-Za-z0-9_]*$/.test(key))
+        .map(([key, value]) => `${key}=${shellQuote(value)}`);
+    const limited = options.limits && hasLimits(options.limits) ? buildLimitedCommand(command, options.limits, 'rlimit') : command;
+    const remoteScript = [
+        `cd ${shellQuote(toRemotePath(options.cwd, target))}`,
+        ...(exports.length > 0 ? [`export ${exports.join(' ')}`] : []),
+        `sh -c ${shellQuote(limited)}`,
+    ].join(' && ');
+    const run = `${sshCommand(target)} ${shellQuote(destination(target))} ${shellQuote(remoteScript)}`;
+    const lines = [
+        `if ${upload(target)}; then`,
+        `  ${run}`,
+        '  __cf_status=$?',
+        ...(target.syncBack === false
+            ? []
+            : [`  ${download(target)} || echo ${shellQuote(`Copying changed files back from ${target.name} failed`)} >&2`]),
+        'else',
+        '  __cf_status=$?',
+        `  echo ${shellQuote(`Syncing the workspace to ${target.name} (${target.host}) failed`)} >&2`,
+        'fi',
+        'exit $__cf_status',
+    ];
+    return lines.join('\n');
+}
+
+/**
+ * True when a command run in cwd can go to the remote; anything outside the
+ * synced workspace (temporary copies, caches) runs on the host
+ */
+export function runsRemotely(cwd: string, target: SshTarget): boolean {
+    return isWithin(target.localRoot, resolve(cwd));
+}
+
+/**
+ * Command that checks a remote accepts the connection without prompting
+ */
+export function buildSshProbeCommand(remote: SshRemote, name: string): string {
+    const target: SshTarget = { ...remote, name, localRoot: '/', remoteRoot: remote.remoteRoot ?? '/' };
+    return `${sshCommand(target)} -o ConnectTimeout=10 ${shellQuote(destination(target))} true`;
+}
@@ -0,0 +1,298 @@
+import { randomBytes } from 'crypto';
+import { promises as fs } from 'fs';
+import { homedir } from 'os';
+import { dirname, isAbsolute, join, relative, resolve, sep } from 'path';
+import { normalizeMessage, type Diagnostic, type DiagnosticSeverity } from '../diagnostics/index.js';
+import { runCommand } from '../utils/command.js';
+import { logger } from '../utils/logger.js';
+
+export interface HistoryRun {
+    id: string;
+    // Directory the pipeline ran in
+    workspace: string;
+    commit: string | null;
+    branch: string | null;
+    // Uncommitted changes were present, so the run is not the commit's alone
+    dirty: boolean;
+    // Pipeline name; null for inline steps
+    pipeline: string | null;
+    createdAt: string;
+    passed: boolean;
+    summary: string;
+    counts: Record<DiagnosticSeverity, number>;
+    // File paths relative to the workspace, so clones of a repository compare
+    diagnostics: Diagnostic[];
+}
+
+export interface HistoryQuery {
+    workspace?: string;
+    pipeline?: string | null;
+    // Full sha or a prefix
+    commit?: string;
+    limit?: number;
+}
+
+export interface DiagnosticsDiff {
+    // In head only
+    new: Diagnostic[];
+    // In base only
+    fixed: Diagnostic[];
+    // In both, as found in head
+    existing: Diagnostic[];
+}
+
+interface HistoryBackend {
+    readonly kind: 'sqlite' | 'jsonl';
+    insert(run: HistoryRun, keep: number): Promise<void>;
+    get(id: string): Promise<HistoryRun | null>;
+    // Newest first
+    list(query: HistoryQuery): Promise<HistoryRun[]>;
+}
+
+const DEFAULT_KEEP = 1000;
+const DEFAULT_LIMIT = 20;
+
+/**
+ * Location of the results database: MCP_RESULTS_DB or
+ * ~/.local/state/code-feedback/results.db (results.jsonl without SQLite)
+ */
+export function getResultsDbPath(): string {
+    return process.env.MCP_RESULTS_DB || join(homedir(), '.local', 'state', 'code-feedback', 'results.db');
+}
+
+// Runs kept per workspace (MCP_RESULTS_LIMIT)
+function getKeep(): number {
+    const keep = Number(process.env.MCP_RESULTS_LIMIT);
+    return keep >= 1 ? Math.floor(keep) : DEFAULT_KEEP;
+}
+
+function matchesQuery(run: HistoryRun, query: HistoryQuery): boolean {
+    if (query.workspace && run.workspace !== query.workspace) return false;
+    if (query.pipeline !== undefined && run.pipeline !== query.pipeline) return false;
+    return !query.commit || Boolean(run.commit?.startsWith(query.commit));
+}
+
+class SqliteBackend implements HistoryBackend {
+    public readonly kind = 'sqlite';
+    private readonly db: any;
+
+    constructor(db: any) {
+        this.db = db;
+        this.db.exec(`
+            CREATE TABLE IF NOT EXISTS runs (
+                id TEXT PRIMARY KEY,
+                workspace TEXT NOT NULL,
+                commit_sha TEXT,
+                pipeline TEXT,
+                created_at TEXT NOT NULL,
+                data TEXT NOT NULL
+            );
+            CREATE INDEX IF NOT EXISTS runs_workspace ON runs (workspace, created_at);
+            CREATE INDEX IF NOT EXISTS runs_commit ON runs (commit_sha);
+        `);
+    }
+
+    public async insert(run: HistoryRun, keep: number): Promise<void> {
+        this.db.prepare('INSERT INTO runs (id, workspace, commit_sha, pipeline, created_at, data) VALUES (?, ?, ?, ?, ?, ?)')
+            .run(run.id, run.workspace, run.commit, run.pipeline, run.createdAt, JSON.stringify(run));
+        this.db.prepare('DELETE FROM runs WHERE workspace = ? AND id NOT IN (SELECT id FROM runs WHERE workspace = ? ORDER BY created_at DESC, id DESC LIMIT ?)')
+            .run(run.workspace, run.workspace, keep);
+    }
+
+    public async get(id: string): Promise<HistoryRun | null> {
+        const row = this.db.prepare('SELECT data FROM runs WHERE id = ?').get(id);
+        return row ? JSON.parse(row.data) : null;
+    }
+
+    public async list(query: HistoryQuery): Promise<HistoryRun[]> {
+        const where: string[] = [];
+        const params: unknown[] = [];
+        if (query.workspace) {
+            where.push('workspace = ?');
+            params.push(query.workspace);
+        }
+        if (query.pipeline !== undefined) {
+            where.push(query.pipeline === null ? 'pipeline IS NULL' : 'pipeline = ?');
+            if (query.pipeline !== null) params.push(query.pipeline);
+        }
+        if (query.commit) {
+            where.push("commit_sha LIKE ? || '%'");
+            params.push(query.commit);
+        }
+        const sql = `SELECT data FROM runs ${where.length > 0 ? `WHERE ${where.join(' AND ')}` : ''} ORDER BY created_at DESC, id DESC LIMIT ?`;
+        return this.db.prepare(sql).all(...params, query.limit ?? DEFAULT_LIMIT).map((row: any) => JSON.parse(row.data));
+    }
+}
+
+// One JSON line per run, for Node versions without node:sqlite
+class JsonlBackend implements HistoryBackend {
+    public readonly kind = 'jsonl';
+    private readonly path: string;
+    private writes: Promise<void> = Promise.resolve();
+
+    constructor(path: string) {
+        this.path = path;
+    }
+
+    private async readAll(): Promise<HistoryRun[]> {
+        await this.writes;
+        let raw: string;
+        try {
+            raw = await fs.readFile(this.path, 'utf-8');
+        } catch (error: any) {
+            if (error.code === 'ENOENT') return [];
+            throw error;
+        }
+        const runs: HistoryRun[] = [];
+        for (const line of raw.split('\n')) {
+            if (!line.trim()) continue;
+            try {
+                runs.push(JSON.parse(line));
+            } catch {
+                // A line cut short by a crash
+            }
+        }
+        return runs;
+    }
+
+    public insert(run: HistoryRun, keep: number): Promise<void> {
+        // Serialized so concurrent runs never interleave partial lines
+        this.writes = this.writes.then(async () => {
+            await fs.mkdir(dirname(this.path), { recursive: true });
+            await fs.appendFile(this.path, JSON.stringify(run) + '\n', { mode: 0o600 });
+        });
+        return this.writes.then(async () => {
+            const runs = await this.readAll();
+            const own = runs.filter(r => r.workspace === run.workspace);
+            if (own.length <= keep) return;
+            const dropped = new Set(own.slice(0, own.length - keep).map(r => r.id));
+            const kept = runs.filter(r => !dropped.has(r.id));
+            const partial = `${this.path}.${randomBytes(4).toString('hex')}.tmp`;
+            await fs.writeFile(partial, kept.map(r => JSON.stringify(r) + '\n').join(''), { mode: 0o600 });
+            await fs.rename(partial, this.path);
+        });
+    }
+
+    public async get(id: string): Promise<HistoryRun | null> {
+        return (await this.readAll()).find(run => run.id === id) ?? null;
+    }
+
+    public async list(query: HistoryQuery): Promise<HistoryRun[]> {
+        return (await this.readAll()).filter(run => matchesQuery(run, query)).reverse().slice(0, query.limit ?? DEFAULT_LIMIT);
+    }
+}
+
+async function openBackend(): Promise<HistoryBackend> {
+    const path = getResultsDbPath();
+    const jsonlPath = path.replace(/\.(db|sqlite3?)$/, '') + '.jsonl';
+    if (process.env.MCP_RESULTS_STORE === 'jsonl') return new JsonlBackend(jsonlPath);
+    try {
+        const { DatabaseSync } = await import('node:sqlite');
+        await fs.mkdir(dirname(path), { recursive: true });
+        return new SqliteBackend(new DatabaseSync(path));
+    } catch (error) {
+        // node:sqlite needs Node 22.5 or later
+        logger.debug('SQLite unavailable; storing results as JSON lines', { path: jsonlPath, error });
+        return new JsonlBackend(jsonlPath);
+    }
+}
+
+function diagnosticKey(d: Diagnostic): string {
+    return [d.file, d.source, d.rule ?? '', normalizeMessage(d.message)].join('\0');
+}
+
+/**
+ * Match diagnostics between two runs. A finding is the same when its file,
+ * source, rule and message (numbers aside) are, wherever its line moved to;
+ * repeated findings pair up in line order.
+ */
+export function diffDiagnostics(base: Diagnostic[], head: Diagnostic[]): DiagnosticsDiff {
+    const group = (diagnostics: Diagnostic[]) => {
+        const groups = new Map<string, Diagnostic[]>();
+        for (const d of diagnostics) groups.set(diagnosticKey(d), [...(groups.get(diagnosticKey(d)) ?? []), d]);
+        for (const list of groups.values()) list.sort((a, b) => a.line - b.line || a.column - b.column);
+        return groups;
+    };
+    const before = group(base);
+    const after = group(head);
+    const diff: DiagnosticsDiff = { new: [], fixed: [], existing: [] };
+    for (const [key, list] of after) {
+        const matched = before.get(key)?.length ?? 0;
+        diff.existing.push(...list.slice(0, matched));
+        diff.new.push(...list.slice(matched));
+    }
+    for (const [key, list] of before) diff.fixed.push(...list.slice(after.get(key)?.length ?? 0));
+    return diff;
+}
+
+async function gitState(root: string): Promise<{ commit: string | null; branch: string | null; dirty: boolean }> {
+    const git = (args: string) => runCommand(`git ${args}`, { cwd: root, timeout: 10000, local: true }).catch(() => null);
+    const head = await git('rev-parse HEAD');
+    if (!head || head.exitCode !== 0) return { commit: null, branch: null, dirty: false };
+    const branch = (await git('rev-parse --abbrev-ref HEAD'))?.stdout.trim();
+    const status = await git('status --porcelain --untracked-files=no');
+    return { commit: head.stdout.trim(), branch: branch && branch !== 'HEAD' ? branch : null, dirty: Boolean(status?.stdout.trim()) };
+}
+
+function relativeTo(root: string, file: string): string {
+    if (!file || !isAbsolute(file)) return file;
+    const rel = relative(root, file);
+    return rel.startsWith('..') ? file : rel.split(sep).join('/');
+}
+
+/**
+ * Pipeline runs by workspace and commit, for comparing a run's findings
+ * with earlier ones. SQLite (node:sqlite) when the runtime has it, JSON
+ * lines otherwise; MCP_RESULTS_STORE=off disables recording.
+ */
+export class HistoryStore {
+    private backend: Promise<HistoryBackend> | undefined;
+
+    public isEnabled(): boolean {
+        return process.env.MCP_RESULTS_STORE !== 'off';
+    }
+
+    private open(): Promise<HistoryBackend> {
+        this.backend ??= openBackend();
+        return this.backend;
+    }
+
+    public async kind(): Promise<HistoryBackend['kind']> {
+        return (await this.open()).kind;
+    }
+
+    public async record(root: string, run: { pipeline: string | null; passed: boolean; summary: string; diagnostics: Diagnostic[] }): Promise<HistoryRun> {
+        const workspace = resolve(root);
+        const diagnostics = run.diagnostics.map(d => ({ ...d, file: relativeTo(workspace, d.file) }));
+        const counts = { error: 0, warning: 0, info: 0 };
+        for (const d of diagnostics) counts[d.severity] = (counts[d.severity] ?? 0) + 1;
+        const record: HistoryRun = {
+            id: `${new Date().toISOString().replace(/[-:.]/g, '')}-${randomBytes(3).toString('hex')}`,
+            workspace,
+            ...(await gitState(workspace)),
+            pipeline: run.pipeline,
+            createdAt: new Date().toISOString(),
+            passed: run.passed,
+            summary: run.summary,
+            counts,
+            diagnostics,
+        };
+        await (await this.open()).insert(record, getKeep());
+        return record;
+    }
+
+    public async get(id: string): Promise<HistoryRun | null> {
+        return (await this.open()).get(id);
+    }
+
+    public async list(query: HistoryQuery = {}): Promise<HistoryRun[]> {
+        return (await this.open()).list({ ...query, ...(query.workspace ? { workspace: resolve(query.workspace) } : {}) });
+    }
+
+    // Forget the open database, so the next call opens MCP_RESULTS_DB afresh
+    public reset(): void {
+        this.backend = undefined;
+    }
+}
+
+export const historyStore = new HistoryStore();
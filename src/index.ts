@@ -1,150 +1,143 @@
 #!/usr/bin/env node
 
-import { Server } from '@modelcontextprotocol/sdk/server/index.js';
 import { StdioServerTransport } from '@modelcontextprotocol/sdk/server/stdio.js';
-import {
-  CallToolRequestSchema,
-  ListToolsRequestSchema,
-  InitializeRequestSchema,
-  ErrorCode,
-  McpError
-} from '@modelcontextprotocol/sdk/types.js';
 import { allTools } from './tools/index.js';
-import { registerResources } from './resources/index.js';
-import { registerPrompts } from './prompts/index.js';
+import { createServer } from './server.js';
+import { parseCliArgs, USAGE, type CliOptions } from './cli.js';
+import { startHttpServer, parseListenAddress } from './transport/http.js';
+import { logger } from './utils/logger.js';
+import { tracer, tracingOptionsFromEnv } from './tracing/index.js';
+import { getApiKeysFilePath, loadApiKeys } from './quota/index.js';
+import { getWebhooksFilePath, handleWebhook, loadWebhooks } from './webhooks/index.js';
+import { startCloneCollector } from './workspaces/clone.js';
+import { exportSarif, runBatch, runDoctor, watchBatch } from './batch.js';
+import { serveLsp } from './lsp/index.js';
+import { getPluginsFilePath, registerPluginTools } from './plugins/index.js';
 const VERSION = '__VERSION__';
 
 /**
- * Create the MCP server
+ * Serve MCP over stdio to a single client
  */
-const server = new Server(
-  {
-    name: 'code-feedback-mcp',
-    version: VERSION,
-  },
-  {
-    capabilities: {
-      tools: {},
-      // resources: {},
-      prompts: {},
-    },
-  }
-);
-
-registerResources();
-registerPrompts(server);
-
-/**
- * Error handler
- */
-server.onerror = (error) => {
-  console.error('[MCP Error]', error);
-};
-
-/**
- * Initialize handler
- */
-server.setRequestHandler(InitializeRequestSchema, async () => {
-  console.error('[MCP] Received initialize request');
-
-  return {
-    protocolVersion: '2024-11-05',
-    capabilities: {
-      tools: {},
-      // resources: {},
-      prompts: {},
-    },
-    serverInfo: {
-      name: 'code-feedback-mcp',
-      version: VERSION,
-    },
-  };
-});
+async function serveStdio() {
+  const server = createServer(VERSION);
+
+  /**
+   * Graceful shutdown
+   */
+  process.on('SIGINT', async () => {
+    logger.info('Shutting down');
+    await tracer.flush();
+    await server.close();
+    process.exit(0);
+  });
+
+  const transport = new StdioServerTransport();
+  await server.connect(transport);
+}
 
 /**
- * List tools handler
+ * Serve MCP over HTTP so multiple remote clients can share one server
  */
-server.setRequestHandler(ListToolsRequestSchema, async () => {
-  console.error(`[MCP] Listing ${allTools.length} available tools`);
-
-  return {
-    tools: allTools.map(tool => ({
-      name: tool.name,
-      description: tool.description,
-      inputSchema: tool.inputSchema,
-    })),
-  };
-});
+async function serveHttp(address: string, token: string | undefined) {
+  const { host, port } = parseListenAddress(address);
+  const isLoopback = host === '127.0.0.1' || host === 'localhost' || host === '::1';
+  const apiKeys = await loadApiKeys();
+  if (apiKeys.keys.length > 0) logger.info('Loaded API keys', { path: getApiKeysFilePath(), keys: apiKeys.keys.length });
+  if (!token && apiKeys.keys.length === 0 && !isLoopback) {
+    logger.warn('HTTP server is reachable from the network without a bearer token (set --token or MCP_AUTH_TOKEN)', { address });
+  }
+  const webhooks = await loadWebhooks();
+  if (webhooks.repositories.length > 0) logger.info('Loaded webhooks', { path: getWebhooksFilePath(), repositories: webhooks.repositories.length });
+  const httpServer = await startHttpServer({
+    ...(host ? { host } : {}),
+    port,
+    ...(token ? { token } : {}),
+    apiKeys,
+    createMcpServer: client => createServer(VERSION, { client }),
+    ...(webhooks.repositories.length > 0 ? { webhooks: (provider, headers, body) => handleWebhook(webhooks, provider, headers, body) } : {}),
+  });
+
+  process.on('SIGINT', () => {
+    logger.info('Shutting down');
+    httpServer.close(() => tracer.flush().finally(() => process.exit(0)));
+    // Open SSE streams would otherwise keep the server alive
+    httpServer.closeAllConnections();
+  });
+
+  const bound = httpServer.address();
+  const listening = bound && typeof bound === 'object' ? `${bound.address}:${bound.port}` : address;
+  logger.info(`Listening on http://${listening} (streamable HTTP at /mcp, SSE at /sse, metrics at /metrics${webhooks.repositories.length > 0 ? ', webhooks at /webhooks/github and /webhooks/gitlab' : ''})`);
+}
 
 /**
- * Call tool handler
+ * Start the server
  */
-server.setRequestHandler(CallToolRequestSchema, async (request) => {
-  const { name, arguments: args } = request.params;
-
-  console.error(`[MCP] Tool call: ${name}`);
-
-  // Find the tool
-  const tool = allTools.find(t => t.name === name);
-  if (!tool) {
-    throw new McpError(
-      ErrorCode.MethodNotFound,
-      `Tool "${name}" not found`
-    );
+async function run() {
+  let options: CliOptions;
+  try {
+    options = parseCliArgs(process.argv.slice(2));
+  } catch (error) {
+    console.error(`${error instanceof Error ? error.message : String(error)}\n\n${USAGE}`);
+    process.exit(2);
   }
-
+  if (options.command === 'help') {
+    console.error(USAGE);
+    return;
+  }
+  // Plugin tools join the registry before anything lists or runs tools, pipelines included
+  if (options.command !== 'serve' && !process.env.MCP_LOG_LEVEL) logger.setLevel('warn');
   try {
-    // Execute the tool
-    const result = await tool.run(args || {});
-
-    // Return the result in MCP format
-    return {
-      content: [
-        {
-          type: 'text',
-          text: JSON.stringify(result, null, 2),
-        },
-      ],
-    };
+    const pluginTools = await registerPluginTools();
+    if (pluginTools.length > 0) logger.info('Registered plugin tools', { path: getPluginsFilePath(), tools: pluginTools });
   } catch (error) {
-    const errorMessage = error instanceof Error ? error.message : String(error);
-    throw new McpError(
-      ErrorCode.InternalError,
-      `Tool execution failed: ${errorMessage}`
-    );
+    logger.error('Failed to load plugins', { error });
+    // doctor reports the broken plugins file with everything else
+    if (options.command !== 'doctor') process.exit(1);
+  }
+  if (options.command === 'run') {
+    process.exitCode = await runBatch(options);
+    return;
+  }
+  if (options.command === 'watch') {
+    process.exitCode = await watchBatch(options);
+    return;
+  }
+  if (options.command === 'lsp') {
+    // stdout carries the protocol; logs stay on stderr
+    process.exitCode = await serveLsp({ input: process.stdin, output: process.stdout, root: options.path, pipeline: options.pipeline, version: VERSION });
+    process.exit();
+  }
+  if (options.command === 'export-sarif') {
+    process.exitCode = await exportSarif(options);
+    return;
+  }
+  if (options.command === 'doctor') {
+    process.exitCode = await runDoctor(options);
+    return;
   }
-});
-
-/**
- * Graceful shutdown
- */
-process.on('SIGINT', async () => {
-  console.error('[MCP] Shutting down...');
-  await server.close();
-  process.exit(0);
-});
 
-/**
- * Start the server
- */
-async function run() {
   try {
-    console.error(`[MCP] Starting Code Feedback MCP Server v${VERSION}...`);
+    logger.info('Starting Code Feedback MCP Server', { version: VERSION });
+    tracer.configure({ ...tracingOptionsFromEnv(), serviceVersion: VERSION });
+    if (tracer.isExporting()) logger.info('Exporting traces', { endpoint: tracer.getEndpoint() });
+    startCloneCollector();
 
-    const transport = new StdioServerTransport();
-    await server.connect(transport);
+    if (options.http) {
+      await serveHttp(options.http, options.token);
+    } else {
+      await serveStdio();
+    }
 
-    console.error('[MCP] Server started successfully');
-    console.error(`[MCP] Available tools: ${allTools.map(t => t.name).join(', ')}`);
-    console.error('[MCP] Ready to receive requests');
+    logger.info('Ready to receive requests', { tools: allTools.length });
+    logger.debug('Available tools', { tools: allTools.map(t => t.name) });
 
   } catch (error) {
-    console.error('[MCP] Failed to start server:', error);
+    logger.error('Failed to start server', { error });
     process.exit(1);
   }
 }
 
 run().catch((error) => {
-  console.error('[MCP] Fatal error:', error);
+  logger.error('Fatal error', { error });
   process.exit(1);
 });
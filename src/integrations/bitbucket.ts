@@ -0,0 +1,97 @@
+import { ApiClient } from './http.js';
+import { parseUnifiedDiff } from '../utils/git.js';
+import { diffLines, type ChangeRequest, type HostOptions, type PostedComment, type PublishedReview, type ReviewBackend, type ReviewSubmission } from './review.js';
+
+/**
+ * Bitbucket Cloud API settings: MCP_BITBUCKET_TOKEN or BITBUCKET_TOKEN (a
+ * repository or workspace access token), or BITBUCKET_USERNAME with
+ * BITBUCKET_APP_PASSWORD
+ */
+export function bitbucketOptionsFromEnv(env: NodeJS.ProcessEnv = process.env): HostOptions {
+    const token = env.MCP_BITBUCKET_TOKEN || env.BITBUCKET_TOKEN;
+    const username = env.BITBUCKET_USERNAME;
+    const password = env.BITBUCKET_APP_PASSWORD;
+    return {
+        ...(token ? { token } : username && password ? { token: password, username } : {}),
+        apiUrl: (env.BITBUCKET_API_URL || 'https://api.bitbucket.org/2.0').replace(/\/+$/, ''),
+    };
+}
+
+/**
+ * Pull request reviews through the Bitbucket Cloud API: an inline comment per
+ * finding, the summary as a general comment, then approve or request changes
+ */
+export class BitbucketReviewBackend implements ReviewBackend {
+    public readonly provider = 'bitbucket' as const;
+    private readonly client: ApiClient;
+    private readonly repo: string;
+    private readonly urls = new Map<number, string>();
+
+    // repository is workspace/repo_slug
+    constructor(repository: string, options: HostOptions) {
+        this.repo = `/repositories/${repository}`;
+        const authorization = options.username
+            ? `Basic ${Buffer.from(`${options.username}:${options.token ?? ''}`).toString('base64')}`
+            : options.token ? `Bearer ${options.token}` : undefined;
+        this.client = new ApiClient({
+            name: 'Bitbucket',
+            baseUrl: options.apiUrl,
+            headers: { Accept: 'application/json', ...(authorization ? { Authorization: authorization } : {}) },
+            ...(options.fetch ? { fetch: options.fetch } : {}),
+        });
+    }
+
+    private async pullRequest(number: number) {
+        const { data } = await this.client.request<{ links: { html: { href: string } }; source: { commit: { hash: string } } }>('GET', `${this.repo}/pullrequests/${number}`);
+        this.urls.set(number, data.links.html.href);
+        return data;
+    }
+
+    public async getChangeRequest(number: number): Promise<ChangeRequest> {
+        const pull = await this.pullRequest(number);
+        const diff = await this.client.requestText(`${this.repo}/pullrequests/${number}/diff`);
+        return {
+            url: pull.links.html.href,
+            headSha: pull.source.commit.hash,
+            files: parseUnifiedDiff(diff).filter(f => f.status !== 'deleted').map(f => ({
+                path: f.file,
+                oldPath: f.oldFile,
+                ...(f.binary ? {} : { lines: diffLines(f) }),
+            })),
+        };
+    }
+
+    public async listComments(number: number): Promise<PostedComment[]> {
+        const comments: PostedComment[] = [];
+        // Bitbucket links the next page in the body rather than a Link header
+        let page: string | undefined = `${this.repo}/pullrequests/${number}/comments?pagelen=100`;
+        while (page) {
+            const { data }: { data: { values: Array<{ deleted?: boolean; content: { raw: string }; inline?: { path: string; to?: number | null } }>; next?: string } } = await this.client.request('GET', page);
+            for (const comment of data.values) {
+                if (comment.deleted) continue;
+                comments.push({ path: comment.inline?.path ?? '', line: comment.inline?.to ?? null, body: comment.content.raw });
+            }
+            page = data.next;
+        }
+        return comments;
+    }
+
+    public async submitReview(number: number, review: ReviewSubmission): Promise<PublishedReview> {
+        const url = this.urls.get(number) ?? (await this.pullRequest(number)).links.html.href;
+        const warnings: string[] = [];
+        for (const comment of review.comments) {
+            try {
+                await this.client.request('POST', `${this.repo}/pullrequests/${number}/comments`, {
+                    content: { raw: comment.body },
+                    inline: { path: comment.path, to: comment.line },
+                });
+            } catch (error: any) {
+                warnings.push(`Comment on ${comment.path}:${comment.line} not posted: ${error.message || String(error)}`);
+            }
+        }
+        const { data: summary } = await this.client.request<{ id: number }>('POST', `${this.repo}/pullrequests/${number}/comments`, { content: { raw: review.body } });
+        if (review.event === 'APPROVE') await this.client.request('POST', `${this.repo}/pullrequests/${number}/approve`);
+        if (review.event === 'REQUEST_CHANGES') await this.client.request('POST', `${this.repo}/pullrequests/${number}/request-changes`);
+        return { id: String(summary.id), url: `${url}#comment-${summary.id}`, warnings };
+    }
+}
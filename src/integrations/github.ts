@@ -0,0 +1,70 @@
+import { ApiClient } from './http.js';
+import { patchLines, type ChangeRequest, type HostOptions, type PostedComment, type PublishedReview, type ReviewBackend, type ReviewSubmission } from './review.js';
+
+/**
+ * GitHub API settings: MCP_GITHUB_TOKEN when the server needs its own token,
+ * else GITHUB_TOKEN or GH_TOKEN and GITHUB_API_URL as GitHub Actions and the
+ * gh CLI set them
+ */
+export function githubOptionsFromEnv(env: NodeJS.ProcessEnv = process.env): HostOptions {
+    const token = env.MCP_GITHUB_TOKEN || env.GITHUB_TOKEN || env.GH_TOKEN;
+    return {
+        ...(token ? { token } : {}),
+        // https://HOST/api/v3 for GitHub Enterprise Server
+        apiUrl: (env.GITHUB_API_URL || 'https://api.github.com').replace(/\/+$/, ''),
+    };
+}
+
+/**
+ * Pull request reviews through the GitHub REST API: one review carrying the
+ * summary, the event and every inline comment
+ */
+export class GitHubReviewBackend implements ReviewBackend {
+    public readonly provider = 'github' as const;
+    private readonly client: ApiClient;
+    private readonly repo: string;
+
+    // repository is owner/repo
+    constructor(repository: string, options: HostOptions) {
+        this.repo = repository;
+        this.client = new ApiClient({
+            name: 'GitHub',
+            baseUrl: options.apiUrl,
+            headers: {
+                Accept: 'application/vnd.github+json',
+                'X-GitHub-Api-Version': '2022-11-28',
+                ...(options.token ? { Authorization: `Bearer ${options.token}` } : {}),
+            },
+            ...(options.fetch ? { fetch: options.fetch } : {}),
+        });
+    }
+
+    public async getChangeRequest(number: number): Promise<ChangeRequest> {
+        const { data: pull } = await this.client.request<{ html_url: string; head: { sha: string } }>('GET', `/repos/${this.repo}/pulls/${number}`);
+        const files = await this.client.paginate<{ filename: string; previous_filename?: string; status: string; patch?: string }>(`/repos/${this.repo}/pulls/${number}/files`);
+        return {
+            url: pull.html_url,
+            headSha: pull.head.sha,
+            files: files.filter(f => f.status !== 'removed').map(f => ({
+                path: f.filename,
+                oldPath: f.previous_filename ?? f.filename,
+                ...(f.patch !== undefined ? { lines: patchLines(f.patch) } : {}),
+            })),
+        };
+    }
+
+    public async listComments(number: number): Promise<PostedComment[]> {
+        const comments = await this.client.paginate<{ path: string; line: number | null; body: string }>(`/repos/${this.repo}/pulls/${number}/comments`);
+        return comments.map(c => ({ path: c.path, line: c.line, body: c.body }));
+    }
+
+    public async submitReview(number: number, review: ReviewSubmission): Promise<PublishedReview> {
+        const { data } = await this.client.request<{ id: number; html_url: string }>('POST', `/repos/${this.repo}/pulls/${number}/reviews`, {
+            commit_id: review.commitId,
+            event: review.event,
+            body: review.body,
+            comments: review.comments.map(c => ({ path: c.path, line: c.line, side: 'RIGHT', body: c.body })),
+        });
+        return { id: String(data.id), url: data.html_url, warnings: [] };
+    }
+}
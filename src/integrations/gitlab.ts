@@ -0,0 +1,106 @@
+import { ApiClient } from './http.js';
+import { patchLines, type ChangeRequest, type HostOptions, type PostedComment, type PublishedReview, type ReviewBackend, type ReviewSubmission } from './review.js';
+
+interface DiffRefs {
+    base_sha: string;
+    start_sha: string;
+    head_sha: string;
+}
+
+/**
+ * GitLab API settings: MCP_GITLAB_TOKEN or GITLAB_TOKEN (a personal, project or
+ * group access token with api scope), and CI_API_V4_URL or GITLAB_API_URL,
+ * else the remote's host
+ */
+export function gitlabOptionsFromEnv(env: NodeJS.ProcessEnv = process.env, host = 'gitlab.com'): HostOptions {
+    const token = env.MCP_GITLAB_TOKEN || env.GITLAB_TOKEN;
+    return {
+        ...(token ? { token } : {}),
+        apiUrl: (env.CI_API_V4_URL || env.GITLAB_API_URL || `https://${host}/api/v4`).replace(/\/+$/, ''),
+    };
+}
+
+/**
+ * Merge request reviews through the GitLab REST API: a diff discussion per
+ * inline comment, the summary as a note, and an approval for APPROVE
+ */
+export class GitLabReviewBackend implements ReviewBackend {
+    public readonly provider = 'gitlab' as const;
+    private readonly client: ApiClient;
+    private readonly project: string;
+    // Diff version and page of each merge request; discussion positions refer to the version
+    private readonly requests = new Map<number, { url: string; refs: DiffRefs | null }>();
+
+    // repository is the project path, subgroups included
+    constructor(repository: string, options: HostOptions) {
+        this.project = `/projects/${encodeURIComponent(repository)}`;
+        this.client = new ApiClient({
+            name: 'GitLab',
+            baseUrl: options.apiUrl,
+            headers: options.token ? { 'PRIVATE-TOKEN': options.token } : {},
+            ...(options.fetch ? { fetch: options.fetch } : {}),
+        });
+    }
+
+    private async mergeRequest(number: number) {
+        const { data: mr } = await this.client.request<{ web_url: string; sha: string; diff_refs: DiffRefs | null }>('GET', `${this.project}/merge_requests/${number}`);
+        this.requests.set(number, { url: mr.web_url, refs: mr.diff_refs });
+        return mr;
+    }
+
+    public async getChangeRequest(number: number): Promise<ChangeRequest> {
+        const mr = await this.mergeRequest(number);
+        const diffs = await this.client.paginate<{ old_path: string; new_path: string; diff: string; deleted_file: boolean }>(`${this.project}/merge_requests/${number}/diffs`);
+        return {
+            url: mr.web_url,
+            headSha: mr.diff_refs?.head_sha ?? mr.sha,
+            files: diffs.filter(d => !d.deleted_file).map(d => ({
+                path: d.new_path,
+                oldPath: d.old_path,
+                // Too large and binary diffs come back empty
+                ...(d.diff ? { lines: patchLines(d.diff) } : {}),
+            })),
+        };
+    }
+
+    public async listComments(number: number): Promise<PostedComment[]> {
+        const discussions = await this.client.paginate<{ notes: Array<{ body: string; position?: { new_path?: string; new_line?: number | null } | null }> }>(`${this.project}/merge_requests/${number}/discussions`);
+        return discussions.flatMap(d => d.notes).map(note => ({
+            path: note.position?.new_path ?? '',
+            line: note.position?.new_line ?? null,
+            body: note.body,
+        }));
+    }
+
+    public async submitReview(number: number, review: ReviewSubmission): Promise<PublishedReview> {
+        if (!this.requests.has(number)) await this.mergeRequest(number);
+        const { url, refs } = this.requests.get(number)!;
+        if (!refs && review.comments.length > 0) throw new Error('The merge request has no diff version yet; retry once GitLab has processed it');
+        const warnings: string[] = [];
+        // Discussions are posted one by one; a rejected position should not lose the rest
+        for (const comment of review.comments) {
+            try {
+                await this.client.request('POST', `${this.project}/merge_requests/${number}/discussions`, {
+                    body: comment.body,
+                    position: {
+                        position_type: 'text',
+                        ...refs,
+                        old_path: comment.oldPath ?? comment.path,
+                        new_path: comment.path,
+                        new_line: comment.line,
+                        ...(comment.oldLine !== undefined ? { old_line: comment.oldLine } : {}),
+                    },
+                });
+            } catch (error: any) {
+                warnings.push(`Comment on ${comment.path}:${comment.line} not posted: ${error.message || String(error)}`);
+            }
+        }
+        const { data: note } = await this.client.request<{ id: number }>('POST', `${this.project}/merge_requests/${number}/notes`, { body: review.body });
+        if (review.event === 'APPROVE') {
+            await this.client.request('POST', `${this.project}/merge_requests/${number}/approve`, { sha: review.commitId });
+        } else if (review.event === 'REQUEST_CHANGES') {
+            warnings.push('GitLab has no API to request changes; the review was posted as comments');
+        }
+        return { id: String(note.id), url: `${url}#note_${note.id}`, warnings };
+    }
+}
@@ -0,0 +1,83 @@
+export class ApiError extends Error {
+    public readonly status: number;
+
+    constructor(message: string, status: number) {
+        super(message);
+        this.name = 'ApiError';
+        this.status = status;
+    }
+}
+
+export interface ApiClientOptions {
+    // Used in error messages: "GitLab POST /projects/... responded 403: ..."
+    name: string;
+    baseUrl: string;
+    headers: Record<string, string>;
+    fetch?: typeof fetch;
+}
+
+/**
+ * Minimal JSON REST client shared by the review backends
+ */
+export class ApiClient {
+    private readonly options: ApiClientOptions;
+
+    constructor(options: ApiClientOptions) {
+        this.options = { ...options, baseUrl: options.baseUrl.replace(/\/+$/, '') };
+    }
+
+    public async request<T>(method: string, path: string, body?: unknown): Promise<{ data: T; next?: string }> {
+        const { text, next } = await this.send(method, path, body);
+        return { data: (text ? JSON.parse(text) : undefined) as T, ...(next ? { next } : {}) };
+    }
+
+    // Endpoints that answer with plain text, such as a diff
+    public async requestText(path: string): Promise<string> {
+        return (await this.send('GET', path)).text;
+    }
+
+    /**
+     * Every page of a list endpoint that links the next page in a Link header
+     * (GitHub, GitLab)
+     */
+    public async paginate<T>(path: string): Promise<T[]> {
+        const items: T[] = [];
+        let page: string | undefined = `${path}${path.includes('?') ? '&' : '?'}per_page=100`;
+        while (page) {
+            const { data, next }: { data: T[]; next?: string } = await this.request<T[]>('GET', page);
+            items.push(...data);
+            page = next;
+        }
+        return items;
+    }
+
+    private async send(method: string, path: string, body?: unknown): Promise<{ text: string; next?: string }> {
+        const url = /^https?:\/\//.test(path) ? path : `${this.options.baseUrl}${path}`;
+        const response = await (this.options.fetch ?? fetch)(url, {
+            method,
+            headers: {
+                'User-Agent': 'code-feedback-mcp',
+                ...this.options.headers,
+                ...(body !== undefined ? { 'Content-Type': 'application/json' } : {}),
+            },
+            ...(body !== undefined ? { body: JSON.stringify(body) } : {}),
+            signal: AbortSignal.timeout(30000),
+        });
+        const text = await response.text();
+        if (!response.ok) {
+            let message = response.statusText;
+            try {
+                const data = JSON.parse(text);
+                const errors = Array.isArray(data?.errors) ? data.errors.map((e: any) => typeof e === 'string' ? e : e.message ?? JSON.stringify(e)) : [];
+                // GitHub and GitLab use message, Bitbucket error.message
+                message = [data?.message ?? data?.error?.message ?? (typeof data?.error === 'string' ? data.error : message), ...errors]
+                    .map(m => typeof m === 'string' ? m : JSON.stringify(m)).join('; ');
+            } catch {
+                if (text.trim()) message = text.trim().slice(0, 200);
+            }
+            throw new ApiError(`${this.options.name} ${method} ${path.replace(this.options.baseUrl, '')} responded ${response.status}: ${message}`, response.status);
+        }
+        const next = /<([^>]+)>;\s*rel="next"/.exec(response.headers.get('link') ?? '')?.[1];
+        return { text, ...(next ? { next } : {}) };
+    }
+}
@@ -0,0 +1,79 @@
+import { BitbucketReviewBackend, bitbucketOptionsFromEnv } from './bitbucket.js';
+import { GitHubReviewBackend, githubOptionsFromEnv } from './github.js';
+import { GitLabReviewBackend, gitlabOptionsFromEnv } from './gitlab.js';
+import type { HostOptions, ReviewBackend, ReviewProvider } from './review.js';
+
+export * from './review.js';
+export { ApiError } from './http.js';
+
+// Where each provider reads its token, for the error when none is set
+const TOKEN_VARIABLES: Record<ReviewProvider, string> = {
+    github: 'MCP_GITHUB_TOKEN, GITHUB_TOKEN or GH_TOKEN',
+    gitlab: 'MCP_GITLAB_TOKEN or GITLAB_TOKEN',
+    bitbucket: 'MCP_BITBUCKET_TOKEN, BITBUCKET_TOKEN, or BITBUCKET_USERNAME and BITBUCKET_APP_PASSWORD',
+};
+
+/**
+ * Credentials and endpoint for a provider from the environment; apiUrl (from
+ * project config) wins over the environment, and host names a self-hosted instance
+ */
+export function hostOptionsFor(provider: ReviewProvider, options: { apiUrl?: string; host?: string; env?: NodeJS.ProcessEnv } = {}): HostOptions {
+    const env = options.env ?? process.env;
+    const fromEnv = provider === 'gitlab' ? gitlabOptionsFromEnv(env, options.host)
+        : provider === 'bitbucket' ? bitbucketOptionsFromEnv(env)
+            : githubOptionsFromEnv(env);
+    return options.apiUrl ? { ...fromEnv, apiUrl: options.apiUrl.replace(/\/+$/, '') } : fromEnv;
+}
+
+export function missingTokenMessage(provider: ReviewProvider): string {
+    return `No ${provider} token: set ${TOKEN_VARIABLES[provider]} to a token that can comment on ${provider === 'gitlab' ? 'merge' : 'pull'} requests`;
+}
+
+export function createReviewBackend(provider: ReviewProvider, repository: string, options: HostOptions): ReviewBackend {
+    switch (provider) {
+        case 'gitlab': return new GitLabReviewBackend(repository, options);
+        case 'bitbucket': return new BitbucketReviewBackend(repository, options);
+        default: return new GitHubReviewBackend(repository, options);
+    }
+}
+
+/**
+ * The git host a provider's token belongs to: github.com or the GitHub
+ * Enterprise host of GITHUB_API_URL, gitlab.com or the host of
+ * CI_API_V4_URL/GITLAB_API_URL, and bitbucket.org
+ */
+export function credentialHost(provider: ReviewProvider, env: NodeJS.ProcessEnv = process.env): string {
+    switch (provider) {
+        case 'gitlab': {
+            const apiUrl = env.CI_API_V4_URL || env.GITLAB_API_URL;
+            return apiUrl ? new URL(apiUrl).host : 'gitlab.com';
+        }
+        case 'bitbucket': return 'bitbucket.org';
+        default: {
+            const host = env.GITHUB_API_URL ? new URL(env.GITHUB_API_URL).host : 'api.github.com';
+            return host === 'api.github.com' ? 'github.com' : host;
+        }
+    }
+}
+
+/**
+ * Environment for git commands against an HTTPS remote of the provider: the
+ * token goes in an extra header, never in the URL or on disk, and git never
+ * prompts. Other remotes (SSH, local paths, hosts the token does not belong
+ * to) use the server's own credentials.
+ */
+export function gitCredentialEnv(url: string, provider: ReviewProvider, env: NodeJS.ProcessEnv = process.env): Record<string, string> {
+    const base: Record<string, string> = { GIT_TERMINAL_PROMPT: '0' };
+    if (!/^https:\/\//.test(url)) return base;
+    const host = new URL(url).host.toLowerCase();
+    if (host !== credentialHost(provider, env).toLowerCase()) return base;
+    const { token, username } = hostOptionsFor(provider, { host, env });
+    if (!token) return base;
+    const user = username ?? (provider === 'gitlab' ? 'oauth2' : provider === 'bitbucket' ? 'x-token-auth' : 'x-access-token');
+    return {
+        ...base,
+        GIT_CONFIG_COUNT: '1',
+        GIT_CONFIG_KEY_0: 'http.extraHeader',
+        GIT_CONFIG_VALUE_0: `Authorization: Basic ${Buffer.from(`${user}:${token}`).toString('base64')}`,
+    };
+}
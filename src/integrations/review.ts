@@ -0,0 +1,109 @@
+import { parseUnifiedDiff, type FileDiff } from '../utils/git.js';
+
+export type ReviewProvider = 'github' | 'gitlab' | 'bitbucket';
+
+export type ReviewEvent = 'COMMENT' | 'REQUEST_CHANGES' | 'APPROVE';
+
+// API endpoint and credentials of a code host
+export interface HostOptions {
+    token?: string;
+    // Sends token as the password of HTTP basic auth (Bitbucket app passwords)
+    username?: string;
+    apiUrl: string;
+    fetch?: typeof fetch;
+}
+
+export interface ChangedFile {
+    path: string;
+    oldPath: string;
+    // New-file line -> old-file line (null for added lines); absent when the host sent no patch (large or binary files)
+    lines?: Map<number, number | null>;
+}
+
+export interface ChangeRequest {
+    url: string;
+    headSha: string;
+    files: ChangedFile[];
+}
+
+export interface ReviewComment {
+    path: string;
+    oldPath?: string;
+    // Line in the new version of the file
+    line: number;
+    // Set when the line is unchanged context, which GitLab positions need
+    oldLine?: number;
+    body: string;
+}
+
+export interface PostedComment {
+    path: string;
+    line: number | null;
+    body: string;
+}
+
+export interface ReviewSubmission {
+    commitId: string;
+    event: ReviewEvent;
+    body: string;
+    comments: ReviewComment[];
+}
+
+export interface PublishedReview {
+    id: string;
+    url: string;
+    // What the host could not do, e.g. request changes on GitLab
+    warnings: string[];
+}
+
+/**
+ * A code host that can take a review of a pull (merge) request: inline comments
+ * on changed lines, a summary, and optionally an approval or change request
+ */
+export interface ReviewBackend {
+    readonly provider: ReviewProvider;
+    getChangeRequest(number: number): Promise<ChangeRequest>;
+    listComments(number: number): Promise<PostedComment[]>;
+    submitReview(number: number, review: ReviewSubmission): Promise<PublishedReview>;
+}
+
+/**
+ * Commentable lines of a file diff: added and context lines of the new file,
+ * each with its old-file line when unchanged
+ */
+export function diffLines(file: FileDiff): Map<number, number | null> {
+    const lines = new Map<number, number | null>();
+    for (const hunk of file.hunks) {
+        for (const line of hunk.lines) {
+            if (line.newLine !== null) lines.set(line.newLine, line.type === 'context' ? line.oldLine : null);
+        }
+    }
+    return lines;
+}
+
+/**
+ * diffLines for a bare patch (hunks without file headers), as GitHub and GitLab return per file
+ */
+export function patchLines(patch: string): Map<number, number | null> {
+    const [file] = parseUnifiedDiff(`--- a/file\n+++ b/file\n${patch}`);
+    return file ? diffLines(file) : new Map();
+}
+
+/**
+ * Host and repository path of a git remote URL (https, ssh or scp-style);
+ * the path keeps GitLab subgroups ("group/subgroup/project")
+ */
+export function parseRemoteUrl(url: string): { host: string; path: string } | null {
+    const match = /^(?:[\w+.-]+:\/\/)?(?:[^@/]+@)?([^:/]+)(?::\d+)?[:/](.+?)(?:\.git)?\/?$/.exec(url.trim());
+    if (!match || !match[2]!.includes('/')) return null;
+    return { host: match[1]!, path: match[2]! };
+}
+
+/**
+ * Provider a remote host most likely runs; self-hosted GitLab is recognized by name
+ */
+export function detectProvider(host: string): ReviewProvider {
+    if (/(^|\.)bitbucket\.org$/i.test(host)) return 'bitbucket';
+    if (/gitlab/i.test(host)) return 'gitlab';
+    return 'github';
+}
@@ -0,0 +1,203 @@
+import { resolve } from 'path';
+import { fileURLToPath, pathToFileURL } from 'url';
+import type { Readable, Writable } from 'stream';
+import Config from '../config/index.js';
+import type { Diagnostic, DiagnosticSeverity } from '../diagnostics/index.js';
+import { logger } from '../utils/logger.js';
+import { WatchSession, type WatchOptions, type WatchRun } from '../watch/index.js';
+
+export interface LspDiagnostic {
+    range: { start: { line: number; character: number }; end: { line: number; character: number } };
+    severity: 1 | 2 | 3 | 4;
+    source: string;
+    message: string;
+    code?: string;
+}
+
+export interface LspServerOptions {
+    input: Readable;
+    output: Writable;
+    // Used when the client sends no rootUri
+    root: string;
+    pipeline: string;
+    version: string;
+    debounceMs?: number;
+    // Runs the pipeline; run_pipeline by default
+    run?: WatchOptions['run'];
+}
+
+const SEVERITIES: Record<DiagnosticSeverity, LspDiagnostic['severity']> = { error: 1, warning: 2, info: 3 };
+const MAX_MESSAGE_BYTES = 64 * 1024 * 1024;
+
+// JSON-RPC error codes the protocol defines
+const METHOD_NOT_FOUND = -32601;
+const INVALID_REQUEST = -32600;
+const SERVER_NOT_INITIALIZED = -32002;
+
+/**
+ * Splits a stream of Content-Length framed JSON-RPC messages into messages
+ */
+export class MessageReader {
+    private buffer = Buffer.alloc(0);
+
+    public push(chunk: Buffer): unknown[] {
+        this.buffer = Buffer.concat([this.buffer, chunk]);
+        const messages: unknown[] = [];
+        for (;;) {
+            const headerEnd = this.buffer.indexOf('\r\n\r\n');
+            if (headerEnd < 0) break;
+            const header = this.buffer.subarray(0, headerEnd).toString('ascii');
+            const length = Number(/^content-length:\s*(\d+)\s*$/im.exec(header)?.[1]);
+            if (!Number.isInteger(length) || length > MAX_MESSAGE_BYTES) throw new Error(`Invalid message header: ${header}`);
+            if (this.buffer.length < headerEnd + 4 + length) break;
+            const body = this.buffer.subarray(headerEnd + 4, headerEnd + 4 + length).toString('utf-8');
+            this.buffer = this.buffer.subarray(headerEnd + 4 + length);
+            messages.push(JSON.parse(body));
+        }
+        return messages;
+    }
+}
+
+export function frameMessage(message: unknown): Buffer {
+    const body = Buffer.from(JSON.stringify(message), 'utf-8');
+    return Buffer.concat([Buffer.from(`Content-Length: ${body.length}\r\n\r\n`, 'ascii'), body]);
+}
+
+/**
+ * Convert a diagnostic to its LSP form: positions are 0-based, and a finding
+ * without a line (line 0) covers the start of the file
+ */
+export function toLspDiagnostic(d: Diagnostic): LspDiagnostic {
+    const line = Math.max(0, d.line - 1);
+    const character = Math.max(0, d.column - 1);
+    return {
+        // Without an end column the whole rest of the line is marked
+        range: { start: { line, character }, end: { line, character: d.line > 0 && d.column === 0 ? Number.MAX_SAFE_INTEGER : character } },
+        severity: SEVERITIES[d.severity] ?? 2,
+        source: d.source,
+        message: d.message,
+        ...(d.rule ? { code: d.rule } : {}),
+    };
+}
+
+/**
+ * Group a run's diagnostics by document URI, in the form
+ * textDocument/publishDiagnostics takes
+ */
+export function groupByDocument(diagnostics: Diagnostic[], root: string): Map<string, LspDiagnostic[]> {
+    const documents = new Map<string, LspDiagnostic[]>();
+    for (const diagnostic of diagnostics) {
+        if (!diagnostic.file) continue;
+        const uri = pathToFileURL(resolve(root, diagnostic.file)).href;
+        documents.set(uri, [...(documents.get(uri) ?? []), toLspDiagnostic(diagnostic)]);
+    }
+    return documents;
+}
+
+/**
+ * A language server that publishes the pipeline's diagnostics to the editor:
+ * the pipeline runs when the client initializes and again whenever workspace
+ * files change (see WatchSession), and each run replaces the diagnostics of
+ * the previous one, clearing files that no longer have findings. Resolves
+ * with the exit code once the client sends exit.
+ */
+export function serveLsp(options: LspServerOptions): Promise<number> {
+    const reader = new MessageReader();
+    let session: WatchSession | undefined;
+    let initialized = false;
+    let shutdown = false;
+    let published = new Set<string>();
+
+    const send = (message: Record<string, unknown>) => options.output.write(frameMessage({ jsonrpc: '2.0', ...message }));
+    const notify = (method: string, params: unknown) => send({ method, params });
+
+    const publish = (run: WatchRun, root: string) => {
+        const documents = groupByDocument(run.result?.diagnostics ?? [], root);
+        for (const uri of published) {
+            if (!documents.has(uri)) notify('textDocument/publishDiagnostics', { uri, diagnostics: [] });
+        }
+        for (const [uri, diagnostics] of documents) notify('textDocument/publishDiagnostics', { uri, diagnostics });
+        published = new Set(documents.keys());
+        notify('window/logMessage', { type: run.verdict.passed ? 3 : 2, message: run.verdict.summary });
+    };
+
+    const initialize = (params: any) => {
+        const rootUri = params?.rootUri ?? params?.workspaceFolders?.[0]?.uri;
+        const root = typeof rootUri === 'string' && rootUri.startsWith('file://') ? fileURLToPath(rootUri) : resolve(options.root);
+        // The editor's folder is the user's to check, as paths on the command line are
+        Config.getInstance().addAllowedPaths([root]);
+        const pipeline = typeof params?.initializationOptions?.pipeline === 'string' ? params.initializationOptions.pipeline : options.pipeline;
+        session = new WatchSession('lsp', {
+            root,
+            pipeline,
+            ...(options.debounceMs !== undefined ? { debounceMs: options.debounceMs } : {}),
+            ...(options.run ? { run: options.run } : {}),
+        }, run => publish(run, root));
+        return {
+            capabilities: {
+                // Documents are read from disk on save; edits in progress are not checked
+                textDocumentSync: { openClose: true, change: 0, save: { includeText: false } },
+            },
+            serverInfo: { name: 'code-feedback', version: options.version },
+        };
+    };
+
+    const handle = async (message: any): Promise<number | undefined> => {
+        if (!message || typeof message !== 'object' || message.jsonrpc !== '2.0') {
+            send({ id: null, error: { code: INVALID_REQUEST, message: 'Invalid request' } });
+            return undefined;
+        }
+        const { id, method, params } = message;
+        const isRequest = id !== undefined && id !== null;
+        if (method === 'exit') return shutdown ? 0 : 1;
+        if (method === undefined) return undefined; // A response to us; we send no requests
+
+        if (method === 'initialize') {
+            send({ id, result: initialize(params) });
+        } else if (!session) {
+            if (isRequest) send({ id, error: { code: SERVER_NOT_INITIALIZED, message: 'Server not initialized' } });
+        } else if (method === 'initialized') {
+            if (initialized) return undefined;
+            initialized = true;
+            await session.start().catch(error => {
+                logger.error('Could not watch the workspace', { root: session?.root, error });
+                notify('window/showMessage', { type: 1, message: `code-feedback: cannot watch ${session?.root}: ${error.message}` });
+            });
+        } else if (method === 'shutdown') {
+            shutdown = true;
+            session.stop();
+            send({ id, result: null });
+        } else if (isRequest) {
+            send({ id, error: { code: METHOD_NOT_FOUND, message: `Unhandled method ${method}` } });
+        }
+        // Document notifications need no answer: the watcher sees saves on disk
+        return undefined;
+    };
+
+    return new Promise(resolveExit => {
+        let queue = Promise.resolve();
+        const finish = (code: number) => {
+            session?.stop();
+            options.input.removeAllListeners('data');
+            resolveExit(code);
+        };
+        options.input.on('data', (chunk: Buffer) => {
+            let messages: unknown[];
+            try {
+                messages = reader.push(chunk);
+            } catch (error) {
+                logger.error('Malformed LSP message', { error });
+                finish(1);
+                return;
+            }
+            // Handled in order, one at a time
+            for (const message of messages) {
+                queue = queue.then(async () => {
+                    const code = await handle(message);
+                    if (code !== undefined) finish(code);
+                });
+            }
+        });
+        options.input.on('end', () => finish(shutdown ? 0 : 1));
+    });
+}
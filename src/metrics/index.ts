@@ -0,0 +1,168 @@
+import { resultCache } from '../cache/index.js';
+import { scheduler } from '../scheduler/index.js';
+import type { AuditStatus } from '../audit/index.js';
+
+// Histogram bucket upper bounds in seconds: from quick file edits to full builds and test runs
+export const DURATION_BUCKETS = [0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300];
+
+interface ToolStats {
+    calls: Record<AuditStatus, number>;
+    cached: number;
+    inFlight: number;
+    // Cumulative count per bucket, plus +Inf
+    buckets: number[];
+    durationSum: number;
+    maxDuration: number;
+}
+
+export interface ToolMetrics {
+    tool: string;
+    calls: number;
+    success: number;
+    failure: number;
+    rejected: number;
+    cancelled: number;
+    error: number;
+    cached: number;
+    inFlight: number;
+    failureRate: number;
+    meanMs: number;
+    maxMs: number;
+}
+
+export interface MetricsSnapshot {
+    uptimeSeconds: number;
+    tools: ToolMetrics[];
+    cache: { entries: number; hits: number; misses: number; hitRatio: number | null };
+    scheduler: ReturnType<typeof scheduler.getStats>;
+}
+
+/**
+ * In-memory counters for tool calls, exported in the Prometheus text format
+ * on /metrics and as JSON by get_metrics. Reset when the server restarts.
+ */
+export class Metrics {
+    private tools = new Map<string, ToolStats>();
+    private readonly startedAt = Date.now();
+
+    private stats(tool: string): ToolStats {
+        let stats = this.tools.get(tool);
+        if (!stats) {
+            stats = {
+                calls: { success: 0, failure: 0, rejected: 0, cancelled: 0, error: 0 },
+                cached: 0,
+                inFlight: 0,
+                buckets: new Array(DURATION_BUCKETS.length + 1).fill(0),
+                durationSum: 0,
+                maxDuration: 0,
+            };
+            this.tools.set(tool, stats);
+        }
+        return stats;
+    }
+
+    /**
+     * Count a call as in flight until finish() records its outcome and duration
+     */
+    public start(tool: string): { finish: (status: AuditStatus, details?: { cached?: boolean }) => void } {
+        const stats = this.stats(tool);
+        const startedAt = Date.now();
+        let finished = false;
+        stats.inFlight++;
+        return {
+            finish: (status, details = {}) => {
+                if (finished) return;
+                finished = true;
+                stats.inFlight--;
+                const seconds = (Date.now() - startedAt) / 1000;
+                stats.calls[status]++;
+                if (details.cached) stats.cached++;
+                // Rejected calls never ran, so their duration would only dilute the latencies
+                if (status === 'rejected') return;
+                DURATION_BUCKETS.forEach((bound, i) => { if (seconds <= bound) stats.buckets[i]!++; });
+                stats.buckets[DURATION_BUCKETS.length]!++;
+                stats.durationSum += seconds;
+                stats.maxDuration = Math.max(stats.maxDuration, seconds);
+            },
+        };
+    }
+
+    public reset(): void {
+        this.tools.clear();
+    }
+
+    public snapshot(): MetricsSnapshot {
+        const cache = resultCache.getStats();
+        const lookups = cache.hits + cache.misses;
+        return {
+            uptimeSeconds: Math.round((Date.now() - this.startedAt) / 1000),
+            tools: [...this.tools].sort(([a], [b]) => a.localeCompare(b)).map(([tool, stats]) => {
+                const calls = Object.values(stats.calls).reduce((sum, n) => sum + n, 0);
+                const ran = stats.buckets[DURATION_BUCKETS.length]!;
+                return {
+                    tool,
+                    calls,
+                    ...stats.calls,
+                    cached: stats.cached,
+                    inFlight: stats.inFlight,
+                    failureRate: calls > 0 ? (stats.calls.failure + stats.calls.error) / calls : 0,
+                    meanMs: ran > 0 ? Math.round((stats.durationSum / ran) * 1000) : 0,
+                    maxMs: Math.round(stats.maxDuration * 1000),
+                };
+            }),
+            cache: { ...cache, hitRatio: lookups > 0 ? cache.hits / lookups : null },
+            scheduler: scheduler.getStats(),
+        };
+    }
+
+    /**
+     * Prometheus text exposition format (version 0.0.4)
+     */
+    public render(): string {
+        const lines: string[] = [];
+        const family = (name: string, type: string, help: string) => lines.push(`# HELP ${name} ${help}`, `# TYPE ${name} ${type}`);
+        const tools = [...this.tools].sort(([a], [b]) => a.localeCompare(b));
+
+        family('code_feedback_tool_calls_total', 'counter', 'Tool calls by outcome');
+        for (const [tool, stats] of tools) {
+            for (const [status, count] of Object.entries(stats.calls)) lines.push(`code_feedback_tool_calls_total{tool="${escapeLabel(tool)}",status="${status}"} ${count}`);
+        }
+        family('code_feedback_tool_cached_total', 'counter', 'Tool calls answered from the result cache');
+        for (const [tool, stats] of tools) lines.push(`code_feedback_tool_cached_total{tool="${escapeLabel(tool)}"} ${stats.cached}`);
+        family('code_feedback_tool_duration_seconds', 'histogram', 'Tool call duration, including time queued for a worker');
+        for (const [tool, stats] of tools) {
+            const label = `tool="${escapeLabel(tool)}"`;
+            DURATION_BUCKETS.forEach((bound, i) => lines.push(`code_feedback_tool_duration_seconds_bucket{${label},le="${bound}"} ${stats.buckets[i]}`));
+            lines.push(`code_feedback_tool_duration_seconds_bucket{${label},le="+Inf"} ${stats.buckets[DURATION_BUCKETS.length]}`);
+            lines.push(`code_feedback_tool_duration_seconds_sum{${label}} ${stats.durationSum}`);
+            lines.push(`code_feedback_tool_duration_seconds_count{${label}} ${stats.buckets[DURATION_BUCKETS.length]}`);
+        }
+        family('code_feedback_tool_in_flight', 'gauge', 'Tool calls currently executing or queued');
+        for (const [tool, stats] of tools) lines.push(`code_feedback_tool_in_flight{tool="${escapeLabel(tool)}"} ${stats.inFlight}`);
+
+        const { cache, scheduler: pool, uptimeSeconds } = this.snapshot();
+        family('code_feedback_cache_hits_total', 'counter', 'Result cache hits');
+        lines.push(`code_feedback_cache_hits_total ${cache.hits}`);
+        family('code_feedback_cache_misses_total', 'counter', 'Result cache misses');
+        lines.push(`code_feedback_cache_misses_total ${cache.misses}`);
+        family('code_feedback_cache_entries', 'gauge', 'Results held in the cache');
+        lines.push(`code_feedback_cache_entries ${cache.entries}`);
+        family('code_feedback_scheduler_active', 'gauge', 'Tool calls holding a worker slot');
+        lines.push(`code_feedback_scheduler_active ${pool.active}`);
+        family('code_feedback_scheduler_queued', 'gauge', 'Tool calls waiting for a worker slot');
+        lines.push(`code_feedback_scheduler_queued ${pool.queued}`);
+        family('code_feedback_scheduler_queued_by_priority', 'gauge', 'Tool calls waiting for a worker slot, by priority');
+        for (const [priority, count] of Object.entries(pool.queuedByPriority)) lines.push(`code_feedback_scheduler_queued_by_priority{priority="${priority}"} ${count}`);
+        family('code_feedback_scheduler_concurrency', 'gauge', 'Worker slots (MCP_MAX_CONCURRENCY)');
+        lines.push(`code_feedback_scheduler_concurrency ${pool.concurrency}`);
+        family('code_feedback_uptime_seconds', 'gauge', 'Seconds since the server started');
+        lines.push(`code_feedback_uptime_seconds ${uptimeSeconds}`);
+        return lines.join('\n') + '\n';
+    }
+}
+
+function escapeLabel(value: string): string {
+    return value.replace(/\\/g, '\\\\').replace(/"/g, '\\"').replace(/\n/g, '\\n');
+}
+
+export const metrics = new Metrics();
@@ -0,0 +1,239 @@
+import { promises as fs } from 'fs';
+import { basename, dirname, join } from 'path';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { walkDirectory } from '../utils/gitignore.js';
+
+export type MigrationTool = 'golang-migrate' | 'goose' | 'alembic' | 'knex';
+
+export interface Migration {
+    // Digits for golang-migrate, goose and knex (sequence numbers or timestamps); the revision id for alembic
+    version: string;
+    name: string;
+    file: string;
+    // golang-migrate keeps each direction in its own file
+    up?: string;
+    down?: string;
+    // goose migrations written in Go, which only goose itself can run
+    go?: boolean;
+    // alembic: the revisions this one follows; empty for a base
+    downRevisions?: string[];
+}
+
+export interface MigrationSet {
+    tool: MigrationTool;
+    dir: string;
+    migrations: Migration[];
+}
+
+const MIGRATE_FILE = /^(\d+)_(.+)\.(up|down)\.sql$/;
+const GOOSE_FILE = /^(\d+)_(.+)\.(sql|go)$/;
+const KNEX_FILE = /^(\d{14})_(.+)\.(js|cjs|mjs|ts)$/;
+const GOOSE_UP = /^--\s*\+goose\s+Up\b/im;
+const GOOSE_DOWN = /^--\s*\+goose\s+Down\b/im;
+const MAX_MIGRATION_BYTES = 4 * 1024 * 1024;
+
+const compareVersions = (a: string, b: string) => {
+    if (/^\d+$/.test(a) && /^\d+$/.test(b)) return BigInt(a) < BigInt(b) ? -1 : BigInt(a) > BigInt(b) ? 1 : 0;
+    return a.localeCompare(b);
+};
+
+// Python literals: None, 'abc', ('a', 'b')
+function parseRevisions(value: string): string[] {
+    return [...value.matchAll(/['"]([^'"]+)['"]/g)].map(m => m[1]!);
+}
+
+/**
+ * Find the migration directories under root: golang-migrate
+ * (1_name.up.sql), goose (SQL with -- +goose Up, or Go), alembic
+ * (versions/*.py with revision and down_revision) and knex
+ * (migrations/20240101120000_name.js).
+ */
+export async function detectMigrations(root: string): Promise<MigrationSet[]> {
+    const byDir = new Map<string, string[]>();
+    await walkDirectory(root, {}, entry => {
+        if (entry.type !== 'file') return;
+        const dir = dirname(entry.path);
+        byDir.set(dir, [...(byDir.get(dir) ?? []), entry.path]);
+    });
+
+    const sets: MigrationSet[] = [];
+    for (const [dir, files] of byDir) {
+        const read = async (file: string) => {
+            const stat = await fs.stat(file);
+            return stat.size > MAX_MIGRATION_BYTES ? '' : fs.readFile(file, 'utf-8');
+        };
+        const names = files.map(f => basename(f));
+
+        if (names.some(n => MIGRATE_FILE.test(n))) {
+            const migrations = new Map<string, Migration>();
+            for (const file of files) {
+                const match = MIGRATE_FILE.exec(basename(file));
+                if (!match) continue;
+                const [, version, name] = match as unknown as [string, string, string];
+                const key = `${version}_${name}`;
+                const migration = migrations.get(key) ?? { version, name, file };
+                const direction = match[3] as 'up' | 'down';
+                migration[direction] = file;
+                if (direction === 'up') migration.file = file;
+                migrations.set(key, migration);
+            }
+            sets.push({ tool: 'golang-migrate', dir, migrations: [...migrations.values()] });
+            continue;
+        }
+
+        const sources = new Map<string, string>();
+        for (const file of files) {
+            if (GOOSE_FILE.test(basename(file)) || KNEX_FILE.test(basename(file)) || (basename(dir) === 'versions' && file.endsWith('.py'))) sources.set(file, await read(file));
+        }
+        const gooseFiles = files.filter(f => GOOSE_FILE.test(basename(f)));
+        const isGoose = gooseFiles.some(f => (f.endsWith('.sql') && GOOSE_UP.test(sources.get(f)!)) || (f.endsWith('.go') && /goose\.Add(Named)?Migration/.test(sources.get(f)!)));
+        if (isGoose) {
+            const migrations = gooseFiles.flatMap(file => {
+                const [, version, name, ext] = GOOSE_FILE.exec(basename(file))!;
+                // Go files in the directory that register no migration are helpers
+                if (ext === 'go' && !/goose\.Add(Named)?Migration/.test(sources.get(file)!)) return [];
+                return [{ version: version!, name: name!, file, ...(ext === 'go' ? { go: true } : {}) }];
+            });
+            sets.push({ tool: 'goose', dir, migrations });
+            continue;
+        }
+
+        if (basename(dir) === 'versions') {
+            const migrations: Migration[] = [];
+            for (const file of files.filter(f => f.endsWith('.py'))) {
+                const source = sources.get(file)!;
+                const revision = /^revision\s*(?::\s*\w+\s*)?=\s*['"]([^'"]+)['"]/m.exec(source)?.[1];
+                const down = /^down_revision\s*(?::[^=]+)?=\s*(.+)$/m.exec(source)?.[1];
+                if (!revision || down === undefined) continue;
+                migrations.push({ version: revision, name: basename(file, '.py'), file, downRevisions: parseRevisions(down) });
+            }
+            if (migrations.length > 0) {
+                sets.push({ tool: 'alembic', dir, migrations });
+                continue;
+            }
+        }
+
+        const knexFiles = files.filter(f => KNEX_FILE.test(basename(f)) && /\bup\b/.test(sources.get(f) ?? ''));
+        if (knexFiles.length > 0 && /migrations?$/.test(basename(dir))) {
+            sets.push({
+                tool: 'knex',
+                dir,
+                migrations: knexFiles.map(file => {
+                    const [, version, name] = KNEX_FILE.exec(basename(file))!;
+                    return { version: version!, name: name!, file };
+                }),
+            });
+        }
+    }
+    for (const set of sets) set.migrations.sort((a, b) => compareVersions(a.version, b.version) || a.name.localeCompare(b.name));
+    return sets.sort((a, b) => a.dir.localeCompare(b.dir));
+}
+
+/**
+ * The up or down half of a goose SQL migration, with the other lines
+ * blanked so errors report the file's own line numbers
+ */
+export function gooseSection(source: string, direction: 'up' | 'down'): string {
+    let section: 'up' | 'down' | null = null;
+    return source.split('\n').map(line => {
+        if (GOOSE_UP.test(line)) section = 'up';
+        else if (GOOSE_DOWN.test(line)) section = 'down';
+        return section === direction ? line : '';
+    }).join('\n');
+}
+
+function finding(file: string, line: number, severity: Diagnostic['severity'], rule: string, message: string): Diagnostic {
+    return { file, line, column: 0, severity, message, rule, source: 'validate_migrations' };
+}
+
+/**
+ * Check a migration set without running it: duplicate versions, missing
+ * directions, gaps in sequence numbers, goose annotations, the alembic
+ * revision graph, and new migrations numbered before committed ones
+ * (added is the set of files new since the base ref).
+ */
+export async function checkMigrations(set: MigrationSet, added: Set<string> = new Set()): Promise<Diagnostic[]> {
+    const diagnostics: Diagnostic[] = [];
+    const { migrations } = set;
+
+    if (set.tool === 'alembic') {
+        const revisions = new Map<string, Migration>();
+        for (const m of migrations) {
+            const other = revisions.get(m.version);
+            if (other) diagnostics.push(finding(m.file, 1, 'error', 'duplicate-version', `Revision ${m.version} is also declared in ${basename(other.file)}`));
+            revisions.set(m.version, m);
+        }
+        const referenced = new Set(migrations.flatMap(m => m.downRevisions ?? []));
+        for (const m of migrations) {
+            for (const down of m.downRevisions ?? []) {
+                if (!revisions.has(down)) diagnostics.push(finding(m.file, 1, 'error', 'unknown-revision', `down_revision ${down} of ${m.version} matches no revision`));
+            }
+        }
+        const heads = migrations.filter(m => !referenced.has(m.version));
+        if (heads.length > 1) {
+            for (const head of heads) diagnostics.push(finding(head.file, 1, 'error', 'multiple-heads', `${heads.length} heads (${heads.map(h => h.version).join(', ')}); merge them with alembic merge before upgrading`));
+        }
+        const bases = migrations.filter(m => (m.downRevisions ?? []).length === 0);
+        if (bases.length > 1) diagnostics.push(finding(bases[1]!.file, 1, 'warning', 'multiple-bases', `${bases.length} revisions have no down_revision: ${bases.map(b => b.version).join(', ')}`));
+        return diagnostics;
+    }
+
+    const seen = new Map<string, Migration>();
+    for (const m of migrations) {
+        const other = seen.get(m.version);
+        if (other) diagnostics.push(finding(m.file, 1, 'error', 'duplicate-version', `Version ${m.version} is also used by ${basename(other.file)}`));
+        else seen.set(m.version, m);
+    }
+
+    for (const m of migrations) {
+        if (set.tool === 'golang-migrate') {
+            if (!m.up) diagnostics.push(finding(m.file, 1, 'error', 'missing-up', `${basename(m.file)} has no matching .up.sql`));
+            else if (!m.down) diagnostics.push(finding(m.up, 1, 'warning', 'missing-down', `${basename(m.up)} has no .down.sql, so it cannot be rolled back`));
+            if (m.up && (await fs.readFile(m.up, 'utf-8')).trim() === '') diagnostics.push(finding(m.up, 1, 'warning', 'empty-migration', `${basename(m.up)} is empty`));
+        } else if (set.tool === 'goose' && !m.go) {
+            const source = await fs.readFile(m.file, 'utf-8');
+            if (!GOOSE_UP.test(source)) diagnostics.push(finding(m.file, 1, 'error', 'missing-annotation', `${basename(m.file)} has no -- +goose Up annotation; goose refuses to run it`));
+            else if (!GOOSE_DOWN.test(source)) diagnostics.push(finding(m.file, 1, 'warning', 'missing-down', `${basename(m.file)} has no -- +goose Down section, so it cannot be rolled back`));
+            const begins = (source.match(/^--\s*\+goose\s+StatementBegin\b/gim) ?? []).length;
+            const ends = (source.match(/^--\s*\+goose\s+StatementEnd\b/gim) ?? []).length;
+            if (begins !== ends) diagnostics.push(finding(m.file, 1, 'error', 'unbalanced-statement', `${basename(m.file)} has ${begins} +goose StatementBegin and ${ends} StatementEnd`));
+        } else if (set.tool === 'knex') {
+            const source = await fs.readFile(m.file, 'utf-8');
+            if (!/\bdown\b/.test(source)) diagnostics.push(finding(m.file, 1, 'warning', 'missing-down', `${basename(m.file)} exports no down, so it cannot be rolled back`));
+        }
+    }
+
+    // Sequence numbers (not timestamps) should count up without gaps
+    const numbers = [...seen.keys()].filter(v => v.length < 14).map(Number);
+    if (numbers.length === seen.size) {
+        for (let k = 1; k < numbers.length; k++) {
+            if (numbers[k]! > numbers[k - 1]! + 1) {
+                const m = seen.get([...seen.keys()][k]!)!;
+                diagnostics.push(finding(m.file, 1, 'warning', 'sequence-gap', `Version ${numbers[k]} follows ${numbers[k - 1]}; ${numbers[k - 1]! + 1} is missing`));
+            }
+        }
+    }
+
+    const committed = migrations.filter(m => !added.has(m.file) && !(m.up && added.has(m.up)));
+    const latest = committed[committed.length - 1];
+    if (latest) {
+        for (const m of migrations) {
+            if ((added.has(m.file) || (m.up && added.has(m.up))) && compareVersions(m.version, latest.version) < 0) {
+                diagnostics.push(finding(m.file, 1, 'error', 'out-of-order', `${basename(m.file)} is numbered before ${basename(latest.file)}, which is already committed; databases already past ${latest.version} never run it, so renumber it after`));
+            }
+        }
+    }
+    return diagnostics;
+}
+
+/** The alembic.ini that configures a versions directory, looking up from it. */
+export async function findAlembicIni(versionsDir: string, root: string): Promise<string | null> {
+    let dir = versionsDir;
+    while (dir.startsWith(root)) {
+        const candidate = join(dir, 'alembic.ini');
+        if (await fs.access(candidate).then(() => true, () => false)) return candidate;
+        if (dirname(dir) === dir) break;
+        dir = dirname(dir);
+    }
+    return null;
+}
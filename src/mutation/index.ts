@@ -0,0 +1,166 @@
+export type MutationLanguage = 'go' | 'python';
+
+export interface Mutant {
+    // 1-based
+    line: number;
+    column: number;
+    // go-mutesting style names: expression/comparison, branch/if, ...
+    operator: string;
+    original: string;
+    replacement: string;
+    // The whole line with the mutation applied
+    mutatedLine: string;
+}
+
+export interface FunctionRange {
+    name: string;
+    start: number;
+    end: number;
+}
+
+interface Operator {
+    name: string;
+    pattern: RegExp;
+    replace: (match: RegExpExecArray) => string | null;
+    // Matched against the line itself, once the masked line shows it is code, so the condition keeps its strings
+    wholeLine?: boolean;
+}
+
+const swap = (pairs: Record<string, string>) => (match: RegExpExecArray) => pairs[match[0]] ?? null;
+
+const SHARED: Operator[] = [
+    // <- and << are not comparisons
+    { name: 'expression/comparison', pattern: /(?<![<>=!:-])(<=|>=|<|>)(?![<>=-])/g, replace: swap({ '<': '<=', '<=': '<', '>': '>=', '>=': '>' }) },
+    { name: 'expression/negate', pattern: /==|!=/g, replace: swap({ '==': '!=', '!=': '==' }) },
+    // Only binary operators gofmt and black space out, so unary minus and pointers are left alone
+    { name: 'expression/arithmetic', pattern: /(?<=[\w)\]] )[+\-*/](?= [\w(\[])/g, replace: swap({ '+': '-', '-': '+', '*': '/', '/': '*' }) },
+    { name: 'statement/loop-control', pattern: /\b(break|continue)\b/g, replace: swap({ break: 'continue', continue: 'break' }) },
+];
+
+const OPERATORS: Record<MutationLanguage, Operator[]> = {
+    go: [
+        ...SHARED,
+        { name: 'expression/logical', pattern: /&&|\|\|/g, replace: swap({ '&&': '||', '||': '&&' }) },
+        { name: 'expression/increment', pattern: /\+\+|--(?!\s*>)/g, replace: swap({ '++': '--', '--': '++' }) },
+        { name: 'expression/boolean', pattern: /\b(true|false)\b/g, replace: swap({ true: 'false', false: 'true' }) },
+        // "if x := f(); x > 0 {" keeps its init statement out of the negation
+        { name: 'branch/if', pattern: /^(\s*(?:\} else )?if )(?:([^;{]*;\s*))?(.+?) \{$/g, replace: m => `${m[1]}${m[2] ?? ''}!(${m[3]}) {`, wholeLine: true },
+    ],
+    python: [
+        ...SHARED,
+        { name: 'expression/logical', pattern: /\b(and|or)\b/g, replace: swap({ and: 'or', or: 'and' }) },
+        { name: 'expression/boolean', pattern: /\b(True|False)\b/g, replace: swap({ True: 'False', False: 'True' }) },
+        { name: 'expression/identity', pattern: /\bis not\b|\bis\b(?! not)|\bnot in\b/g, replace: swap({ 'is not': 'is', is: 'is not', 'not in': 'in' }) },
+        { name: 'branch/if', pattern: /^(\s*(?:el)?if )(.+):$/g, replace: m => `${m[1]}not (${m[2]}):`, wholeLine: true },
+        { name: 'statement/return', pattern: /^(\s*return )(?!None\s*$)(.+)$/g, replace: m => `${m[1]}None` },
+    ],
+};
+
+/**
+ * Blank out string literals and comments, keeping every other character in
+ * place, so operators are only found in code. State carries across lines
+ * for Go raw strings and Python triple-quoted strings.
+ */
+export function maskCode(lines: string[], language: MutationLanguage): string[] {
+    let open: string | null = null;
+    return lines.map(line => {
+        let out = '';
+        let k = 0;
+        while (k < line.length) {
+            if (open) {
+                const end = line.indexOf(open, k);
+                if (end < 0) {
+                    out += ' '.repeat(line.length - k);
+                    k = line.length;
+                    break;
+                }
+                out += ' '.repeat(end - k) + open;
+                k = end + open.length;
+                open = null;
+                continue;
+            }
+            const rest = line.slice(k);
+            if ((language === 'go' && rest.startsWith('//')) || (language === 'python' && rest.startsWith('#'))) {
+                out += ' '.repeat(rest.length);
+                break;
+            }
+            const quote = language === 'python' ? /^("""|'''|"|')/.exec(rest)?.[0] : /^(`|"|')/.exec(rest)?.[0];
+            if (!quote) {
+                out += line[k];
+                k++;
+                continue;
+            }
+            out += quote;
+            k += quote.length;
+            if (quote.length === 3 || quote === '`') {
+                open = quote;
+                continue;
+            }
+            // Single-line literal with escapes
+            let j = k;
+            while (j < line.length && line[j] !== quote) j += line[j] === '\\' ? 2 : 1;
+            out += ' '.repeat(Math.min(j, line.length) - k) + (j < line.length ? quote : '');
+            k = j + 1;
+        }
+        return out.slice(0, line.length);
+    });
+}
+
+/**
+ * Functions of a Python file by indentation: from the def (after its
+ * decorators) to the last line indented deeper than it
+ */
+export function pythonFunctions(lines: string[]): FunctionRange[] {
+    const functions: FunctionRange[] = [];
+    lines.forEach((line, k) => {
+        const def = /^(\s*)(?:async\s+)?def\s+(\w+)/.exec(line);
+        if (!def) return;
+        const indent = def[1]!.length;
+        let end = k;
+        for (let j = k + 1; j < lines.length; j++) {
+            const text = lines[j]!;
+            if (!text.trim() || text.trim().startsWith('#')) continue;
+            if (text.length - text.trimStart().length <= indent) break;
+            end = j;
+        }
+        functions.push({ name: def[2]!, start: k + 1, end: end + 1 });
+    });
+    return functions;
+}
+
+/**
+ * Every single-operator mutant of the given lines (1-based, inclusive),
+ * skipping strings, comments, imports and function signatures
+ */
+export function generateMutants(source: string, language: MutationLanguage, lineNumbers: Iterable<number>): Mutant[] {
+    const lines = source.split('\n');
+    const masked = maskCode(lines, language);
+    const mutants: Mutant[] = [];
+    for (const number of [...new Set(lineNumbers)].sort((a, b) => a - b)) {
+        const line = lines[number - 1];
+        const code = masked[number - 1];
+        if (line === undefined || code === undefined || !code.trim()) continue;
+        if (/^\s*(import|package|func|def|async def|class|from|@)\b/.test(code)) continue;
+        for (const operator of OPERATORS[language]) {
+            operator.pattern.lastIndex = 0;
+            const matches = [...code.matchAll(operator.pattern)];
+            if (operator.wholeLine && matches.length > 0) matches.splice(0, 1, ...line.matchAll(operator.pattern));
+            for (const match of matches) {
+                const replacement = operator.replace(match as RegExpExecArray);
+                if (replacement === null) continue;
+                const start = match.index!;
+                const original = line.slice(start, start + match[0].length);
+                if (replacement === original) continue;
+                mutants.push({
+                    line: number,
+                    column: start + 1,
+                    operator: operator.name,
+                    original: original.trim(),
+                    replacement: replacement.trim(),
+                    mutatedLine: line.slice(0, start) + replacement + line.slice(start + match[0].length),
+                });
+            }
+        }
+    }
+    return mutants;
+}
@@ -0,0 +1,234 @@
+import type { Diagnostic } from '../diagnostics/index.js';
+import type { Token } from '../duplicates/index.js';
+import { MISSPELLINGS } from './misspellings.js';
+
+// The initialisms golint keeps in one case: userID, not userId
+export const GO_INITIALISMS = [
+    'ACL', 'API', 'ASCII', 'CPU', 'CSS', 'DNS', 'EOF', 'GUID', 'HTML', 'HTTP', 'HTTPS', 'ID', 'IP', 'JSON', 'LHS', 'QPS', 'RAM', 'RHS',
+    'RPC', 'SLA', 'SMTP', 'SQL', 'SSH', 'TCP', 'TLS', 'TTL', 'UDP', 'UI', 'UID', 'UUID', 'URI', 'URL', 'UTF8', 'VM', 'XML', 'XMPP', 'XSRF', 'XSS',
+];
+
+// camelCase names Python's own frameworks call, so they cannot be renamed
+const PYTHON_FRAMEWORK_NAMES = new Set(['setUp', 'tearDown', 'setUpClass', 'tearDownClass', 'setUpModule', 'tearDownModule', 'asyncSetUp', 'asyncTearDown', 'setUpTestData']);
+// Go test functions may separate the case from the function under test: TestParse_Empty
+const GO_TEST_FUNCTION = /^(Test|Benchmark|Example|Fuzz)/;
+
+export type SpellingTarget = 'identifiers' | 'comments' | 'strings';
+
+export interface NamingOptions {
+    // Check naming conventions (Go, Python); spelling applies to every language
+    conventions: boolean;
+    spelling: SpellingTarget[];
+    // Lower-cased words and identifiers never reported as misspelled
+    allow: Set<string>;
+    initialisms: Set<string>;
+    isTestFile: boolean;
+}
+
+type Language = 'go' | 'python' | 'other';
+
+/** Split an identifier into its words: parseHTTPRequest_v2 gives parse, HTTP, Request, v2. */
+export function splitIdentifier(name: string): string[] {
+    return name.match(/[A-Z]+(?![a-z])|[A-Z]?[a-z]+\d*|\d+[a-z]*/g) ?? [];
+}
+
+/**
+ * The MixedCaps form of a Go name, as golint suggests it: no underscores
+ * and initialisms in one case (HttpServer becomes HTTPServer, user_id
+ * becomes userID).
+ */
+export function goPreferredName(name: string, initialisms: Set<string>): string {
+    const leading = /^_*/.exec(name)![0];
+    const parts = name.slice(leading.length).split(/_+/).filter(Boolean)
+        // golint splits words only where a lower-case letter meets an upper-case one
+        .flatMap(part => part.split(/(?<=[a-z])(?=[A-Z])/));
+    if (parts.length === 0) return name;
+    return leading + parts.map((word, k) => {
+        const upper = word.toUpperCase();
+        if (initialisms.has(upper)) return k === 0 && /^[a-z]/.test(word) ? word.toLowerCase() : upper;
+        // A word after an underscore starts a new capitalised word
+        if (k > 0 && word === word.toLowerCase()) return word[0]!.toUpperCase() + word.slice(1);
+        return word;
+    }).join('');
+}
+
+export function toSnakeCase(name: string): string {
+    const leading = /^_*/.exec(name)![0];
+    return leading + splitIdentifier(name).map(w => w.toLowerCase()).join('_');
+}
+
+export function toCapWords(name: string): string {
+    const leading = /^_*/.exec(name)![0];
+    return leading + splitIdentifier(name).map(w => (w === w.toUpperCase() ? w : w[0]!.toUpperCase() + w.slice(1))).join('');
+}
+
+// The token closing the bracket opened at start
+function matching(code: Token[], start: number): number {
+    const open = code[start]!.kind;
+    const close = open === '(' ? ')' : open === '[' ? ']' : '}';
+    let depth = 0;
+    for (let k = start; k < code.length; k++) {
+        if (code[k]!.kind === open) depth++;
+        else if (code[k]!.kind === close && --depth === 0) return k;
+    }
+    return code.length - 1;
+}
+
+/**
+ * Names a Go file declares: functions, methods, types, variables and
+ * constants (including those in grouped declarations), struct fields,
+ * interface methods and short variable declarations.
+ */
+export function goDeclarations(code: Token[]): Token[] {
+    const declared: Token[] = [];
+    // What each open bracket holds; a grouped declaration or a struct or interface body
+    const stack: string[] = [];
+    let pending = '';
+    for (let k = 0; k < code.length; k++) {
+        const t = code[k]!;
+        const next = code[k + 1];
+        const previous = code[k - 1];
+        const lineStart = !previous || previous.line < t.line;
+        const block = stack[stack.length - 1];
+        if (lineStart && t.kind === 'ID' && next && next.line === t.line) {
+            if ((block === 'var' || block === 'const' || block === 'type' || block === 'struct') && next.kind !== '.' && next.kind !== '}') declared.push(t);
+            else if (block === 'interface' && next.kind === '(') declared.push(t);
+        }
+
+        if (t.kind === 'func') {
+            let m = k + 1;
+            // A method's receiver
+            if (code[m]?.kind === '(') m = matching(code, m) + 1;
+            if (code[m]?.kind === 'ID' && (code[m + 1]?.kind === '(' || code[m + 1]?.kind === '[')) declared.push(code[m]!);
+        } else if (t.kind === 'var' || t.kind === 'const' || t.kind === 'type') {
+            if (next?.kind === 'ID') declared.push(next);
+            else if (next?.kind === '(') pending = t.kind;
+        } else if ((t.kind === 'struct' || t.kind === 'interface') && next?.kind === '{') {
+            pending = t.kind;
+        } else if (t.kind === ':' && next?.kind === '=' && next.column === t.column + 1) {
+            // a, b := ...
+            for (let m = k - 1; m >= 0 && code[m]!.kind === 'ID'; m -= 2) {
+                declared.push(code[m]!);
+                if (code[m - 1]?.kind !== ',') break;
+            }
+        }
+
+        if (t.kind === '(' || t.kind === '{' || t.kind === '[') {
+            stack.push(pending && (previous?.kind === pending) ? pending : '');
+            pending = '';
+        } else if (t.kind === ')' || t.kind === '}' || t.kind === ']') {
+            stack.pop();
+        }
+    }
+    return declared.filter(t => t.text !== '_');
+}
+
+function finding(file: string, at: { line: number; column: number }, rule: string, message: string): Diagnostic {
+    return { file, line: at.line, column: at.column, severity: 'warning', message, rule, source: 'check_naming' };
+}
+
+function checkGoNames(file: string, code: Token[], options: NamingOptions): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    const seen = new Set<string>();
+    for (const t of goDeclarations(code)) {
+        const name = t.text;
+        if (seen.has(`${name}:${t.line}`)) continue;
+        seen.add(`${name}:${t.line}`);
+        if (options.isTestFile && GO_TEST_FUNCTION.test(name)) continue;
+        if (name.length >= 5 && /^[A-Z0-9_]+$/.test(name) && name.includes('_')) {
+            diagnostics.push(finding(file, t, 'all-caps', `Go names use MixedCaps, not ALL_CAPS: ${name}`));
+            continue;
+        }
+        const preferred = goPreferredName(name, options.initialisms);
+        if (preferred === name) continue;
+        diagnostics.push(name.includes('_')
+            ? finding(file, t, 'underscore', `Go names use MixedCaps, not underscores: ${name} should be ${preferred}`)
+            : finding(file, t, 'initialism', `${name} should be ${preferred}; initialisms keep one case in Go names`));
+    }
+    return diagnostics;
+}
+
+function checkPythonNames(file: string, code: Token[]): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    // Inside brackets, name= is a keyword argument
+    let depth = 0;
+    for (let k = 0; k < code.length; k++) {
+        const t = code[k]!;
+        const name = code[k + 1];
+        if (t.kind === '(' || t.kind === '[' || t.kind === '{') depth++;
+        else if (t.kind === ')' || t.kind === ']' || t.kind === '}') depth = Math.max(0, depth - 1);
+        if (t.kind === 'def' && name?.kind === 'ID') {
+            const text = name.text;
+            if (/^__\w+__$/.test(text) || PYTHON_FRAMEWORK_NAMES.has(text) || /^_*[a-z0-9_]+$/.test(text)) continue;
+            diagnostics.push(finding(file, name, 'snake-case', `Function ${text} should be snake_case: ${toSnakeCase(text)}`));
+        } else if (t.kind === 'class' && name?.kind === 'ID') {
+            if (/^_*[A-Z][A-Za-z0-9]*$/.test(name.text)) continue;
+            diagnostics.push(finding(file, name, 'cap-words', `Class ${name.text} should be CapWords: ${toCapWords(name.text)}`));
+        } else if (t.kind === 'ID' && name?.kind === '=' && code[k + 2]?.kind !== '=' && depth === 0 && (k === 0 || code[k - 1]!.line < t.line)) {
+            // Assignments: UPPER_CASE constants and snake_case variables, never mixedCase
+            if (/[a-z][A-Z]/.test(t.text)) diagnostics.push(finding(file, t, 'snake-case', `Variable ${t.text} should be snake_case: ${toSnakeCase(t.text)}`));
+        }
+    }
+    return diagnostics;
+}
+
+// The words in a comment or string literal, with their positions
+function wordsIn(token: Token): Array<{ word: string; line: number; column: number }> {
+    const words: Array<{ word: string; line: number; column: number }> = [];
+    let line = token.line;
+    let lineStart = -token.column + 1;
+    // A letter after a backslash is an escape: \nrecieve holds recieve
+    const pattern = /(?<!\\)[A-Za-z]+(?:'[a-z]+)?|\n/g;
+    let match: RegExpExecArray | null;
+    while ((match = pattern.exec(token.text)) !== null) {
+        if (match[0] === '\n') {
+            line++;
+            lineStart = match.index + 1;
+            continue;
+        }
+        words.push({ word: match[0], line, column: match.index - lineStart + 1 });
+    }
+    return words;
+}
+
+function misspelled(word: string, allow: Set<string>): string | null {
+    const lower = word.toLowerCase();
+    if (allow.has(lower)) return null;
+    return Object.prototype.hasOwnProperty.call(MISSPELLINGS, lower) ? MISSPELLINGS[lower]! : null;
+}
+
+function checkSpelling(file: string, tokens: Token[], options: NamingOptions): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    const identifiers = new Set<string>();
+    for (const t of tokens) {
+        if (t.kind === 'ID' && options.spelling.includes('identifiers')) {
+            // Reported where the identifier first appears, not at every use
+            if (identifiers.has(t.text) || options.allow.has(t.text.toLowerCase())) continue;
+            identifiers.add(t.text);
+            for (const word of splitIdentifier(t.text)) {
+                const correction = misspelled(word, options.allow);
+                if (correction) diagnostics.push(finding(file, t, 'misspelling', `"${word}" in identifier ${t.text} is misspelled; did you mean "${correction}"?`));
+            }
+        } else if ((t.kind === 'COMMENT' && options.spelling.includes('comments')) || (t.kind === 'LIT' && /^['"`]/.test(t.text) && options.spelling.includes('strings'))) {
+            const where = t.kind === 'COMMENT' ? 'comment' : 'string';
+            for (const { word, line, column } of wordsIn(t)) {
+                // doesn't is checked as doesn
+                for (const part of splitIdentifier(word.replace(/'.*/, ''))) {
+                    const correction = misspelled(part, options.allow);
+                    if (correction) diagnostics.push(finding(file, { line, column }, 'misspelling', `"${part}" in ${where} is misspelled; did you mean "${correction}"?`));
+                }
+            }
+        }
+    }
+    return diagnostics;
+}
+
+/** Check one file's names against its language's conventions, and its words against the dictionary. */
+export function checkNaming(file: string, tokens: Token[], language: Language, options: NamingOptions): Diagnostic[] {
+    const code = tokens.filter(t => t.kind !== 'COMMENT');
+    const diagnostics: Diagnostic[] = [];
+    if (options.conventions && language === 'go') diagnostics.push(...checkGoNames(file, code, options));
+    if (options.conventions && language === 'python') diagnostics.push(...checkPythonNames(file, code));
+    if (options.spelling.length > 0) diagnostics.push(...checkSpelling(file, tokens, options));
+    return diagnostics.sort((a, b) => a.line - b.line || a.column - b.column);
+}
@@ -0,0 +1,256 @@
+// Common misspellings of English words and their corrections, as found in
+// code, comments and messages. Words spelled that way on purpose (the HTTP
+// Referer header) are left out; projects allow more with naming.allow.
+export const MISSPELLINGS: Record<string, string> = {
+    accross: 'across',
+    acess: 'access',
+    accomodate: 'accommodate',
+    acheive: 'achieve',
+    adress: 'address',
+    addtional: 'additional',
+    agressive: 'aggressive',
+    aligment: 'alignment',
+    allready: 'already',
+    alot: 'a lot',
+    amoung: 'among',
+    analagous: 'analogous',
+    apparantly: 'apparently',
+    appearence: 'appearance',
+    arbitary: 'arbitrary',
+    arguement: 'argument',
+    asynchronus: 'asynchronous',
+    atleast: 'at least',
+    attribtue: 'attribute',
+    authentification: 'authentication',
+    availabe: 'available',
+    availble: 'available',
+    avaliable: 'available',
+    backgound: 'background',
+    beacuse: 'because',
+    becasue: 'because',
+    becuase: 'because',
+    beggining: 'beginning',
+    begining: 'beginning',
+    beleive: 'believe',
+    boundry: 'boundary',
+    calender: 'calendar',
+    cannonical: 'canonical',
+    charachter: 'character',
+    charater: 'character',
+    choosen: 'chosen',
+    collaborater: 'collaborator',
+    comming: 'coming',
+    commited: 'committed',
+    commiting: 'committing',
+    comparision: 'comparison',
+    compatability: 'compatibility',
+    compatable: 'compatible',
+    compatiblity: 'compatibility',
+    compleete: 'complete',
+    completly: 'completely',
+    concurent: 'concurrent',
+    configuraiton: 'configuration',
+    conjuction: 'conjunction',
+    connectino: 'connection',
+    consistant: 'consistent',
+    containg: 'containing',
+    contian: 'contain',
+    contians: 'contains',
+    continous: 'continuous',
+    convertion: 'conversion',
+    corect: 'correct',
+    corresponing: 'corresponding',
+    coudl: 'could',
+    critera: 'criteria',
+    curent: 'current',
+    currenly: 'currently',
+    deafult: 'default',
+    defered: 'deferred',
+    definate: 'definite',
+    definately: 'definitely',
+    definiton: 'definition',
+    delimeter: 'delimiter',
+    dependancy: 'dependency',
+    dependancies: 'dependencies',
+    depricated: 'deprecated',
+    descripton: 'description',
+    desctiption: 'description',
+    destory: 'destroy',
+    detatch: 'detach',
+    develoment: 'development',
+    diffrent: 'different',
+    dimention: 'dimension',
+    directoy: 'directory',
+    directroy: 'directory',
+    dissapear: 'disappear',
+    doesnt: "doesn't",
+    effecient: 'efficient',
+    elemenet: 'element',
+    embarass: 'embarrass',
+    enviroment: 'environment',
+    enviornment: 'environment',
+    equivelant: 'equivalent',
+    equivalant: 'equivalent',
+    exapmle: 'example',
+    excecute: 'execute',
+    exection: 'execution',
+    existance: 'existence',
+    existant: 'existent',
+    expecially: 'especially',
+    explicitely: 'explicitly',
+    exprected: 'expected',
+    extention: 'extension',
+    failiure: 'failure',
+    familar: 'familiar',
+    feild: 'field',
+    finaly: 'finally',
+    fomat: 'format',
+    foward: 'forward',
+    fucntion: 'function',
+    funtion: 'function',
+    futher: 'further',
+    garantee: 'guarantee',
+    gaurantee: 'guarantee',
+    generaly: 'generally',
+    guarentee: 'guarantee',
+    hanlder: 'handler',
+    heigth: 'height',
+    heirarchy: 'hierarchy',
+    identifer: 'identifier',
+    ignroe: 'ignore',
+    immediatly: 'immediately',
+    implemention: 'implementation',
+    implmentation: 'implementation',
+    incomming: 'incoming',
+    incompatable: 'incompatible',
+    independant: 'independent',
+    indentifier: 'identifier',
+    infomation: 'information',
+    informaton: 'information',
+    initalize: 'initialize',
+    intial: 'initial',
+    inital: 'initial',
+    interupt: 'interrupt',
+    invaild: 'invalid',
+    irrelevent: 'irrelevant',
+    iteratation: 'iteration',
+    lenght: 'length',
+    libary: 'library',
+    lisence: 'license',
+    maintainance: 'maintenance',
+    maintenence: 'maintenance',
+    managment: 'management',
+    mesage: 'message',
+    messsage: 'message',
+    millisecons: 'milliseconds',
+    mispell: 'misspell',
+    mispelled: 'misspelled',
+    mutiple: 'multiple',
+    neccessary: 'necessary',
+    necesary: 'necessary',
+    nessecary: 'necessary',
+    nubmer: 'number',
+    occured: 'occurred',
+    occurence: 'occurrence',
+    occuring: 'occurring',
+    ommit: 'omit',
+    ommited: 'omitted',
+    optionnal: 'optional',
+    orignal: 'original',
+    overriden: 'overridden',
+    paramater: 'parameter',
+    paramter: 'parameter',
+    parmeter: 'parameter',
+    particuler: 'particular',
+    pased: 'passed',
+    peformance: 'performance',
+    performace: 'performance',
+    permision: 'permission',
+    persistant: 'persistent',
+    posible: 'possible',
+    possibilty: 'possibility',
+    preceeding: 'preceding',
+    prefered: 'preferred',
+    presense: 'presence',
+    previos: 'previous',
+    priviledge: 'privilege',
+    privilige: 'privilege',
+    proccess: 'process',
+    procesing: 'processing',
+    propery: 'property',
+    protocal: 'protocol',
+    provded: 'provided',
+    quering: 'querying',
+    reccomend: 'recommend',
+    recieve: 'receive',
+    recieved: 'received',
+    reciever: 'receiver',
+    recomend: 'recommend',
+    recursivly: 'recursively',
+    redundent: 'redundant',
+    refered: 'referred',
+    refrence: 'reference',
+    registery: 'registry',
+    relevent: 'relevant',
+    remaing: 'remaining',
+    repetion: 'repetition',
+    replacment: 'replacement',
+    repositry: 'repository',
+    reponse: 'response',
+    requirment: 'requirement',
+    resouce: 'resource',
+    responce: 'response',
+    retreive: 'retrieve',
+    retrive: 'retrieve',
+    returend: 'returned',
+    seperate: 'separate',
+    seperated: 'separated',
+    seperator: 'separator',
+    sequencial: 'sequential',
+    similiar: 'similar',
+    specifed: 'specified',
+    specifiy: 'specify',
+    sperate: 'separate',
+    stoped: 'stopped',
+    stroage: 'storage',
+    succesful: 'successful',
+    successfull: 'successful',
+    sucess: 'success',
+    sucessful: 'successful',
+    suport: 'support',
+    supress: 'suppress',
+    suppport: 'support',
+    surpress: 'suppress',
+    synchronus: 'synchronous',
+    syncronous: 'synchronous',
+    targetted: 'targeted',
+    teh: 'the',
+    temparary: 'temporary',
+    temporarly: 'temporarily',
+    thier: 'their',
+    threshhold: 'threshold',
+    throught: 'through',
+    tommorow: 'tomorrow',
+    transfered: 'transferred',
+    trasnform: 'transform',
+    truely: 'truly',
+    unecessary: 'unnecessary',
+    unneccessary: 'unnecessary',
+    unkown: 'unknown',
+    untill: 'until',
+    upadte: 'update',
+    usefull: 'useful',
+    usualy: 'usually',
+    utilites: 'utilities',
+    vaild: 'valid',
+    verfiy: 'verify',
+    verison: 'version',
+    visiblity: 'visibility',
+    wether: 'whether',
+    whcih: 'which',
+    wierd: 'weird',
+    wich: 'which',
+    worng: 'wrong',
+    writting: 'writing',
+    writen: 'written',
+};
@@ -0,0 +1,260 @@
+import type { DiagnosticSeverity } from '../diagnostics/index.js';
+import { parsePointer, toPointer } from '../utils/pointer.js';
+
+// A finding on a spec, at the JSON pointer of the node it is about
+export interface SpecFinding {
+    pointer: string;
+    severity: DiagnosticSeverity;
+    rule: string;
+    message: string;
+}
+
+interface Operation {
+    path: string;
+    method: string;
+    operation: any;
+    pathItem: any;
+}
+
+const METHODS = ['get', 'put', 'post', 'delete', 'options', 'head', 'patch', 'trace'];
+
+const isObject = (value: unknown): value is Record<string, any> => typeof value === 'object' && value !== null && !Array.isArray(value);
+
+export function isOpenApiDocument(doc: unknown): boolean {
+    return isObject(doc) && (typeof doc.openapi === 'string' || doc.swagger === '2.0' || doc.swagger === 2);
+}
+
+function operations(doc: any): Operation[] {
+    if (!isObject(doc.paths)) return [];
+    return Object.entries(doc.paths).flatMap(([path, pathItem]) =>
+        isObject(pathItem) ? METHODS.filter(m => isObject(pathItem[m])).map(method => ({ path, method, operation: pathItem[method], pathItem })) : []);
+}
+
+// The node a local $ref points at, following chained references
+function resolveRef(doc: any, node: any, depth = 0): any {
+    if (!isObject(node) || typeof node.$ref !== 'string' || depth > 20) return node;
+    if (!node.$ref.startsWith('#')) return node;
+    let target: any = doc;
+    for (const segment of parsePointer(node.$ref.slice(1))) {
+        target = isObject(target) || Array.isArray(target) ? (target as any)[segment] : undefined;
+        if (target === undefined) return undefined;
+    }
+    return resolveRef(doc, target, depth + 1);
+}
+
+function walk(node: unknown, segments: Array<string | number>, visit: (node: any, segments: Array<string | number>) => void) {
+    visit(node, segments);
+    if (Array.isArray(node)) node.forEach((child, k) => walk(child, [...segments, k], visit));
+    else if (isObject(node)) for (const [key, child] of Object.entries(node)) walk(child, [...segments, key], visit);
+}
+
+// The parameters an operation takes, its own overriding the path item's, keyed by in:name
+function parameters(doc: any, op: Operation): Map<string, { parameter: any; segments: Array<string | number> }> {
+    const result = new Map<string, { parameter: any; segments: Array<string | number> }>();
+    const add = (list: unknown, segments: Array<string | number>) => {
+        if (!Array.isArray(list)) return;
+        list.forEach((raw, k) => {
+            const parameter = resolveRef(doc, raw);
+            if (isObject(parameter) && parameter.name && parameter.in) result.set(`${parameter.in}:${parameter.name}`, { parameter, segments: [...segments, k] });
+        });
+    };
+    add(op.pathItem.parameters, ['paths', op.path, 'parameters']);
+    add(op.operation.parameters, ['paths', op.path, op.method, 'parameters']);
+    return result;
+}
+
+const templateParams = (path: string) => [...path.matchAll(/\{([^}]+)\}/g)].map(m => m[1]!);
+// /users/{id} and /users/{userId} are the same path to a client
+const normalizePath = (path: string) => path.replace(/\{[^}]+\}/g, '{}');
+
+/**
+ * Lint an OpenAPI 3 or Swagger 2 document with the core rules of
+ * Spectral's oas ruleset, under the same names and severities:
+ * operation ids, descriptions, tags, success responses, path parameters,
+ * path shapes, unresolved and unused references, duplicated enum values.
+ */
+export function lintOpenApi(doc: any): SpecFinding[] {
+    const findings: SpecFinding[] = [];
+    const add = (segments: Array<string | number>, severity: DiagnosticSeverity, rule: string, message: string) =>
+        findings.push({ pointer: toPointer(segments), severity, rule, message });
+
+    const info = isObject(doc.info) ? doc.info : {};
+    if (!isObject(info.contact)) add(['info'], 'warning', 'info-contact', 'Info object must have a "contact" object.');
+    if (typeof info.description !== 'string' || info.description.trim() === '') add(['info'], 'warning', 'info-description', 'Info "description" must be present and non-empty string.');
+
+    const globalTags = new Set((Array.isArray(doc.tags) ? doc.tags : []).map((t: any) => t?.name));
+    const operationIds = new Map<string, string>();
+    for (const op of operations(doc)) {
+        const at = ['paths', op.path, op.method];
+        const label = `${op.method.toUpperCase()} ${op.path}`;
+        const { operation } = op;
+        if (typeof operation.operationId !== 'string' || operation.operationId === '') {
+            add(at, 'warning', 'operation-operationId', `Operation must have "operationId" (${label}).`);
+        } else {
+            const other = operationIds.get(operation.operationId);
+            if (other) add([...at, 'operationId'], 'error', 'operation-operationId-unique', `"operationId" must be unique: ${operation.operationId} is also used by ${other}.`);
+            else operationIds.set(operation.operationId, label);
+            if (!/^[A-Za-z0-9\-._~:/?#[\]@!$&'()*+,;=]*$/.test(operation.operationId)) add([...at, 'operationId'], 'warning', 'operation-operationId-valid-in-url', 'operationId must not contain characters that are invalid when used in URL.');
+        }
+        if (typeof operation.description !== 'string' || operation.description.trim() === '') add(at, 'warning', 'operation-description', `Operation "description" must be present and non-empty string (${label}).`);
+        if (!Array.isArray(operation.tags) || operation.tags.length === 0) {
+            add(at, 'warning', 'operation-tags', `Operation must have non-empty "tags" array (${label}).`);
+        } else if (Array.isArray(doc.tags)) {
+            operation.tags.forEach((tag: unknown, k: number) => {
+                if (!globalTags.has(tag)) add([...at, 'tags', k], 'warning', 'operation-tag-defined', `Operation tags must be defined in global tags: ${String(tag)}.`);
+            });
+        }
+        const codes = isObject(operation.responses) ? Object.keys(operation.responses) : [];
+        if (!codes.some(c => /^[23]/.test(c))) add(codes.length > 0 ? [...at, 'responses'] : at, 'warning', 'operation-success-response', `Operation must define at least a single 2xx or 3xx response (${label}).`);
+
+        const declared = parameters(doc, op);
+        const inTemplate = templateParams(op.path);
+        for (const name of inTemplate) {
+            if (!declared.has(`path:${name}`)) add(at, 'error', 'path-params', `Operation must define parameter "{${name}}" as expected by path "${op.path}".`);
+        }
+        for (const [key, { parameter, segments }] of declared) {
+            if (!key.startsWith('path:')) continue;
+            if (!inTemplate.includes(parameter.name)) add(segments, 'error', 'path-params', `Parameter "${parameter.name}" must be used in path "${op.path}".`);
+            else if (parameter.required !== true) add(segments, 'error', 'path-params', `Path parameter "${parameter.name}" must have "required" property that is set to "true".`);
+        }
+    }
+
+    const seenPaths = new Map<string, string>();
+    for (const path of isObject(doc.paths) ? Object.keys(doc.paths) : []) {
+        if (path.length > 1 && path.endsWith('/')) add(['paths', path], 'warning', 'path-keys-no-trailing-slash', `Path must not end with slash: ${path}.`);
+        if (path.includes('?')) add(['paths', path], 'warning', 'path-not-include-query', `Path must not include query string: ${path}.`);
+        if (/\{\}/.test(path)) add(['paths', path], 'warning', 'path-declarations-must-exist', `Path parameter declarations must not be empty, ex."/given/{}" is invalid: ${path}.`);
+        const other = seenPaths.get(normalizePath(path));
+        if (other) add(['paths', path], 'error', 'path-params', `Paths "${other}" and "${path}" must not be equivalent.`);
+        else seenPaths.set(normalizePath(path), path);
+    }
+
+    const refs = new Set<string>();
+    walk(doc, [], (node, segments) => {
+        if (!isObject(node)) return;
+        if (typeof node.$ref === 'string') {
+            refs.add(node.$ref);
+            if (node.$ref.startsWith('#') && resolveRef(doc, { $ref: node.$ref }) === undefined) add([...segments, '$ref'], 'error', 'invalid-ref', `'${node.$ref}' does not exist.`);
+        }
+        if (Array.isArray(node.enum)) {
+            const values = node.enum.map((v: unknown) => JSON.stringify(v));
+            values.forEach((v: string, k: number) => {
+                if (values.indexOf(v) !== k) add([...segments, 'enum', k], 'warning', 'duplicated-entry-in-enum', `Enum has duplicate value: ${v}.`);
+            });
+        }
+    });
+    // A reference from anywhere counts, other components included, as in Spectral
+    const components: Array<[Array<string>, any]> = isObject(doc.components)
+        ? ['schemas', 'responses', 'parameters', 'examples', 'requestBodies', 'headers'].map(section => [['components', section], doc.components[section]])
+        : [[['definitions'], doc.definitions]];
+    for (const [segments, section] of components) {
+        if (!isObject(section)) continue;
+        for (const name of Object.keys(section)) {
+            if (!refs.has(`#${toPointer([...segments, name])}`)) add([...segments, name], 'warning', doc.swagger ? 'oas2-unused-definition' : 'oas3-unused-component', `Potentially unused component has been detected: ${name}.`);
+        }
+    }
+    return findings;
+}
+
+// The JSON body schema of a request or response, Swagger 2 or OpenAPI 3
+function bodySchema(doc: any, body: any): any {
+    const resolved = resolveRef(doc, body);
+    if (!isObject(resolved)) return undefined;
+    if (resolved.schema) return resolveRef(doc, resolved.schema);
+    if (!isObject(resolved.content)) return undefined;
+    const media = resolved.content['application/json'] ?? Object.values(resolved.content)[0];
+    return isObject(media) ? resolveRef(doc, media.schema) : undefined;
+}
+
+// A schema's properties and required list, allOf parts merged in
+function shape(doc: any, schema: any): { properties: Record<string, any>; required: Set<string> } {
+    const properties: Record<string, any> = {};
+    const required = new Set<string>();
+    const visit = (node: any, depth: number) => {
+        const resolved = resolveRef(doc, node);
+        if (!isObject(resolved) || depth > 10) return;
+        for (const [name, property] of Object.entries(isObject(resolved.properties) ? resolved.properties : {})) properties[name] = resolveRef(doc, property);
+        for (const name of Array.isArray(resolved.required) ? resolved.required : []) required.add(name);
+        for (const part of Array.isArray(resolved.allOf) ? resolved.allOf : []) visit(part, depth + 1);
+    };
+    visit(schema, 0);
+    return { properties, required };
+}
+
+const typeOf = (schema: any) => (isObject(schema) ? (schema.type === undefined ? undefined : JSON.stringify(schema.type)) : undefined);
+
+function requestBody(doc: any, op: Operation): { body: any; required: boolean } | undefined {
+    if (op.operation.requestBody) {
+        const body = resolveRef(doc, op.operation.requestBody);
+        return { body, required: body?.required === true };
+    }
+    const param = [...parameters(doc, op).values()].find(p => p.parameter.in === 'body');
+    return param ? { body: param.parameter, required: param.parameter.required === true } : undefined;
+}
+
+/**
+ * The changes from base to head that break existing clients: removed
+ * operations and success responses, new required parameters and request
+ * properties, parameters made required or retyped, and properties removed
+ * from or retyped in success response bodies. Pointers are into head,
+ * or at the closest node head still has.
+ */
+export function diffOpenApi(base: any, head: any): SpecFinding[] {
+    const findings: SpecFinding[] = [];
+    const add = (segments: Array<string | number>, rule: string, message: string) => findings.push({ pointer: toPointer(segments), severity: 'error', rule, message });
+    const headOps = new Map(operations(head).map(op => [`${op.method} ${normalizePath(op.path)}`, op]));
+
+    for (const old of operations(base)) {
+        const label = `${old.method.toUpperCase()} ${old.path}`;
+        const op = headOps.get(`${old.method} ${normalizePath(old.path)}`);
+        if (!op) {
+            const pathStill = isObject(head.paths) && Object.keys(head.paths).find(p => normalizePath(p) === normalizePath(old.path));
+            add(pathStill ? ['paths', pathStill] : ['paths'], 'removed-operation', `${label} was removed`);
+            continue;
+        }
+        const at = ['paths', op.path, op.method];
+
+        const oldResponses = isObject(old.operation.responses) ? old.operation.responses : {};
+        const newResponses = isObject(op.operation.responses) ? op.operation.responses : {};
+        for (const code of Object.keys(oldResponses).filter(c => /^[23]/.test(c))) {
+            if (!(code in newResponses)) {
+                add([...at, 'responses'], 'removed-response', `${label} no longer returns ${code}`);
+                continue;
+            }
+            const before = shape(base, bodySchema(base, oldResponses[code]));
+            const after = shape(head, bodySchema(head, newResponses[code]));
+            for (const [name, property] of Object.entries(before.properties)) {
+                if (!(name in after.properties)) add([...at, 'responses', code], 'response-property-removed', `${label} ${code} response no longer has property ${name}`);
+                else if (typeOf(property) && typeOf(after.properties[name]) && typeOf(property) !== typeOf(after.properties[name])) {
+                    add([...at, 'responses', code], 'response-property-type-changed', `${label} ${code} response property ${name} changed type from ${typeOf(property)} to ${typeOf(after.properties[name])}`);
+                }
+            }
+        }
+
+        const oldParams = parameters(base, old);
+        for (const [key, { parameter, segments }] of parameters(head, op)) {
+            if (parameter.in === 'body') continue;
+            const previous = oldParams.get(key)?.parameter;
+            const where = `${parameter.in} parameter ${parameter.name}`;
+            if (!previous) {
+                if (parameter.required === true && parameter.in !== 'path') add(segments, 'new-required-parameter', `${label} has a new required ${where}`);
+                continue;
+            }
+            if (parameter.required === true && previous.required !== true) add(segments, 'parameter-required', `${label} ${where} became required`);
+            const [before, after] = [typeOf(previous.schema ?? previous), typeOf(parameter.schema ?? parameter)];
+            if (before && after && before !== after) add(segments, 'parameter-type-changed', `${label} ${where} changed type from ${before} to ${after}`);
+        }
+
+        const oldBody = requestBody(base, old);
+        const newBody = requestBody(head, op);
+        if (newBody?.required && !oldBody?.required) add([...at, op.operation.requestBody ? 'requestBody' : 'parameters'], 'request-body-required', `${label} request body became required`);
+        if (oldBody && newBody) {
+            const before = shape(base, bodySchema(base, oldBody.body));
+            const after = shape(head, bodySchema(head, newBody.body));
+            for (const name of after.required) {
+                if (!before.required.has(name)) add([...at, op.operation.requestBody ? 'requestBody' : 'parameters'], 'new-required-property', `${label} request body property ${name} is now required`);
+            }
+        }
+    }
+    return findings;
+}
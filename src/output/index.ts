@@ -0,0 +1,318 @@
+import { randomUUID } from 'crypto';
+import { MIN_OUTPUT_BYTES } from '../config/index.js';
+
+// Kept for paging this long after the call, at most this many and this much
+const PAGE_TTL_MS = 30 * 60 * 1000;
+const MAX_STORED = 100;
+const MAX_STORED_BYTES = 64 * 1024 * 1024;
+// Room for the truncation notice and requestId the server adds
+const RESERVED_BYTES = 512;
+// Fields smaller than this are never shrunk
+const MIN_FIELD_BYTES = 256;
+// Arrays up to this long are shortened item by item instead of losing items
+const MAX_SHARED_ITEMS = 20;
+// Lines around an error line kept with it: a compiler message, a stack, a failed assertion
+const CONTEXT_BEFORE = 2;
+const CONTEXT_AFTER = 8;
+
+// A line that starts an error block in build and test output
+const ERROR_LINE = /\b(error|errors|fail|failed|failure|panic|fatal|exception|traceback)\b|^\s*(FAIL|---\s*FAIL|E\s{2,}|\S+:\d+:\d*:?\s)/i;
+
+export type PagedField = 'output' | 'result';
+
+export interface OutputTruncation {
+    originalBytes: number;
+    returnedBytes: number;
+    // Pass to get_output_page for the full output, page by page
+    token: string;
+    // Top-level fields that were shortened, with how many array items were left out
+    fields: Record<string, { omittedItems?: number; omittedLines?: number }>;
+}
+
+export interface OutputPage {
+    field: PagedField;
+    content: string;
+    // Byte range of content in the full text
+    offset: number;
+    end: number;
+    totalBytes: number;
+    // Token of the page after this one; null on the last page
+    nextToken: string | null;
+}
+
+interface StoredOutput {
+    output: string;
+    result: string;
+    storedAt: number;
+    bytes: number;
+}
+
+function byteLength(value: unknown): number {
+    return Buffer.byteLength(JSON.stringify(value, null, 2) ?? '', 'utf-8');
+}
+
+function encodeToken(id: string, field: PagedField, offset: number, size: number): string {
+    return Buffer.from(JSON.stringify({ id, field, offset, size })).toString('base64url');
+}
+
+function decodeToken(token: string): { id: string; field: PagedField; offset: number; size: number } | null {
+    try {
+        const parsed = JSON.parse(Buffer.from(token, 'base64url').toString('utf-8'));
+        if (typeof parsed?.id !== 'string' || !['output', 'result'].includes(parsed.field)) return null;
+        if (!Number.isInteger(parsed.offset) || parsed.offset < 0 || !Number.isInteger(parsed.size) || parsed.size <= 0) return null;
+        return parsed;
+    } catch {
+        return null;
+    }
+}
+
+/**
+ * Full results of calls whose output was truncated, so the rest can be
+ * fetched page by page. Held in memory; entries expire after 30 minutes
+ * and the oldest go first when the store is full.
+ */
+export class OutputStore {
+    private entries = new Map<string, StoredOutput>();
+    private bytes = 0;
+
+    public put(result: unknown): string {
+        this.prune(Date.now());
+        const id = randomUUID();
+        const output = typeof (result as any)?.output === 'string' ? (result as any).output : '';
+        const text = JSON.stringify(result, null, 2) ?? '';
+        const bytes = Buffer.byteLength(output) + Buffer.byteLength(text);
+        this.entries.set(id, { output, result: text, storedAt: Date.now(), bytes });
+        this.bytes += bytes;
+        while (this.entries.size > MAX_STORED || (this.bytes > MAX_STORED_BYTES && this.entries.size > 1)) {
+            const oldest = this.entries.keys().next().value;
+            if (oldest === undefined) break;
+            this.delete(oldest);
+        }
+        return id;
+    }
+
+    /**
+     * The page a token points at: pageBytes of the text from its offset,
+     * ending on a line break when there is one in the page
+     */
+    public page(token: string, pageBytes?: number): OutputPage {
+        const decoded = decodeToken(token);
+        if (!decoded) throw new Error('Invalid output token');
+        this.prune(Date.now());
+        const entry = this.entries.get(decoded.id);
+        if (!entry) throw new Error('Output expired or unknown; run the tool again');
+        const text = Buffer.from(decoded.field === 'output' ? entry.output : entry.result, 'utf-8');
+        const size = pageBytes ?? decoded.size;
+        const offset = Math.min(decoded.offset, text.length);
+        let end = Math.min(text.length, offset + size);
+        if (end < text.length) {
+            const newline = text.lastIndexOf(0x0a, end - 1);
+            if (newline >= offset) end = newline + 1;
+            // Never split a UTF-8 sequence
+            while (end > offset && (text[end]! & 0xc0) === 0x80) end--;
+        }
+        return {
+            field: decoded.field,
+            content: text.subarray(offset, end).toString('utf-8'),
+            offset,
+            end,
+            totalBytes: text.length,
+            nextToken: end < text.length ? encodeToken(decoded.id, decoded.field, end, size) : null,
+        };
+    }
+
+    public token(id: string, field: PagedField, pageBytes: number, offset = 0): string {
+        return encodeToken(id, field, offset, pageBytes);
+    }
+
+    private delete(id: string): void {
+        const entry = this.entries.get(id);
+        if (!entry) return;
+        this.bytes -= entry.bytes;
+        this.entries.delete(id);
+    }
+
+    private prune(now: number): void {
+        for (const [id, entry] of this.entries) {
+            if (now - entry.storedAt > PAGE_TTL_MS) this.delete(id);
+        }
+    }
+}
+
+export const outputStore = new OutputStore();
+
+/**
+ * Shorten build or test output to about maxBytes, keeping what matters
+ * most: the first lines, the error blocks in order (an error line with the
+ * lines around it) while they fit, then the last lines, where runners print
+ * their summaries. Left-out runs of lines are marked.
+ */
+export function truncateText(text: string, maxBytes: number): { text: string; omittedLines: number } {
+    if (Buffer.byteLength(text) <= maxBytes) return { text, omittedLines: 0 };
+    const lines = text.split('\n');
+    const cost = (i: number) => Buffer.byteLength(lines[i]!) + 1;
+    const keep = new Set<number>();
+    let used = 0;
+    const take = (i: number, limit: number) => {
+        if (keep.has(i) || i < 0 || i >= lines.length) return true;
+        if (used + cost(i) > limit) return false;
+        keep.add(i);
+        used += cost(i);
+        return true;
+    };
+
+    const errors = lines.map((line, i) => (ERROR_LINE.test(line) ? i : -1)).filter(i => i >= 0);
+    // Markers take some of the budget
+    const budget = Math.max(0, maxBytes - 200);
+    const head = errors.length > 0 ? budget * 0.15 : budget * 0.6;
+    for (let i = 0; i < lines.length && take(i, head); i++);
+    const tailReserve = errors.length > 0 ? budget * 0.15 : budget * 0.4;
+    blocks: for (const start of errors) {
+        for (let i = start - CONTEXT_BEFORE; i <= start + CONTEXT_AFTER; i++) {
+            if (!take(i, budget - tailReserve)) break blocks;
+        }
+    }
+    for (let i = lines.length - 1; i >= 0 && take(i, budget); i--);
+
+    // A single line longer than the budget is cut instead
+    if (keep.size === 0) {
+        const cut = Buffer.from(text, 'utf-8').subarray(0, budget).toString('utf-8').replace(/�$/, '');
+        return { text: `${cut}\n[... output truncated ...]`, omittedLines: Math.max(0, lines.length - 1) };
+    }
+
+    const kept: string[] = [];
+    let omittedLines = 0;
+    let gap = 0;
+    for (let i = 0; i < lines.length; i++) {
+        if (keep.has(i)) {
+            if (gap > 0) kept.push(`[... ${gap} line(s) omitted ...]`);
+            gap = 0;
+            kept.push(lines[i]!);
+        } else {
+            gap++;
+            omittedLines++;
+        }
+    }
+    if (gap > 0) kept.push(`[... ${gap} line(s) omitted ...]`);
+    return { text: kept.join('\n'), omittedLines };
+}
+
+function isErrorItem(item: any): boolean {
+    return item?.severity === 'error' || item?.status === 'failed' || item?.status === 'error' || item?.success === false;
+}
+
+function shrinkArray(items: unknown[], maxBytes: number): { value: unknown[]; omittedItems: number } {
+    // A few large items (pipeline steps, per-package results) are each shortened rather than dropped
+    if (items.length <= MAX_SHARED_ITEMS && items.every(item => typeof item === 'string' || (item && typeof item === 'object'))) {
+        const sizes = items.map(item => byteLength(item) + 2);
+        let remaining = maxBytes - 2;
+        let share = remaining / items.length;
+        // Items under their share keep it all; what they leave over goes to the larger ones
+        const small = new Set(items.filter((_, i) => sizes[i]! <= share));
+        for (const [i, item] of items.entries()) if (small.has(item)) remaining -= sizes[i]!;
+        share = remaining / Math.max(1, items.length - small.size);
+        if (share >= MIN_FIELD_BYTES) {
+            return { value: items.map(item => (small.has(item) ? item : shrinkValue(item, share - 2).value)), omittedItems: 0 };
+        }
+    }
+    // Errors and failures are kept ahead of the rest, each group in its order
+    const ordered = [...items.filter(isErrorItem), ...items.filter(item => !isErrorItem(item))];
+    const kept = new Set<unknown>();
+    let used = 2;
+    for (const item of ordered) {
+        const size = byteLength(item) + 2;
+        if (used + size > maxBytes) break;
+        kept.add(item);
+        used += size;
+    }
+    return { value: items.filter(item => kept.has(item)), omittedItems: items.length - kept.size };
+}
+
+function shrinkValue(value: unknown, maxBytes: number): { value: unknown; omittedItems?: number; omittedLines?: number } {
+    if (typeof value === 'string') {
+        // Escaping (newlines, quotes) makes the JSON larger than the text
+        const ratio = Buffer.byteLength(value) / Math.max(1, byteLength(value));
+        const { text, omittedLines } = truncateText(value, Math.floor(maxBytes * ratio));
+        return { value: text, omittedLines };
+    }
+    if (Array.isArray(value)) return shrinkArray(value, maxBytes);
+    if (value && typeof value === 'object') return { value: shrinkObject(value as Record<string, unknown>, maxBytes, []).value };
+    return { value };
+}
+
+// Shrinks the largest fields first; fields in last are shrunk only when nothing else is left
+function shrinkObject(source: Record<string, unknown>, maxBytes: number, last: string[]): { value: Record<string, unknown>; fields: OutputTruncation['fields'] } {
+    const value: Record<string, unknown> = { ...source };
+    const fields: OutputTruncation['fields'] = {};
+    const candidates = Object.keys(value)
+        .filter(key => typeof value[key] === 'string' || (value[key] && typeof value[key] === 'object'))
+        .sort((a, b) => Number(last.includes(a)) - Number(last.includes(b)) || byteLength(value[b]) - byteLength(value[a]));
+    for (const key of candidates) {
+        const excess = byteLength(value) - maxBytes;
+        if (excess <= 0) break;
+        const size = byteLength(value[key]);
+        if (size <= MIN_FIELD_BYTES) continue;
+        const shrunk = shrinkValue(value[key], Math.max(MIN_FIELD_BYTES, size - excess));
+        value[key] = shrunk.value;
+        fields[key] = {
+            ...(shrunk.omittedItems ? { omittedItems: shrunk.omittedItems } : {}),
+            ...(shrunk.omittedLines ? { omittedLines: shrunk.omittedLines } : {}),
+        };
+    }
+    return { value, fields };
+}
+
+/**
+ * Advertise max_output_bytes on every tool's input schema
+ */
+export function withOutputBudgetArg(inputSchema: any) {
+    return {
+        ...inputSchema,
+        properties: {
+            ...inputSchema?.properties,
+            max_output_bytes: { type: 'integer', minimum: MIN_OUTPUT_BYTES, description: 'Truncate the result to about this many bytes of JSON, keeping errors first; get_output_page returns the rest' },
+        },
+    };
+}
+
+/**
+ * Fit a tool result into maxBytes of JSON. Results that fit are returned as
+ * they are. Larger ones keep success, their errors and failing items first,
+ * and get a truncated entry whose token pages through the full output with
+ * get_output_page.
+ */
+export function applyOutputBudget(result: unknown, maxBytes: number, store: OutputStore = outputStore): unknown {
+    if (!result || typeof result !== 'object' || Array.isArray(result)) return result;
+    const originalBytes = byteLength(result);
+    if (originalBytes <= maxBytes) return result;
+
+    const id = store.put(result);
+    const budget = Math.max(MIN_FIELD_BYTES, maxBytes - RESERVED_BYTES);
+    // errors and warnings are what the caller needs most; they go last
+    let { value, fields } = shrinkObject(result as Record<string, unknown>, budget, ['errors', 'warnings']);
+    if (byteLength(value) > budget) {
+        // Many small fields: keep only the standard ones
+        const source = result as Record<string, any>;
+        const minimal = shrinkObject({ success: source.success, errors: source.errors, warnings: source.warnings, output: source.output }, budget, ['errors', 'warnings']);
+        value = minimal.value;
+        fields = { ...fields, ...minimal.fields };
+        for (const key of Object.keys(source)) {
+            if (!(key in value)) fields[key] = {};
+        }
+    }
+    const field: PagedField = typeof (result as any).output === 'string' && fields.output ? 'output' : 'result';
+    const truncated: OutputTruncation = {
+        originalBytes,
+        returnedBytes: 0,
+        token: store.token(id, field, maxBytes),
+        fields,
+    };
+    const warnings = Array.isArray(value.warnings) ? value.warnings : [];
+    const shaped = {
+        ...value,
+        warnings: [...warnings, `Result truncated from ${originalBytes} to about ${maxBytes} bytes; call get_output_page with truncated.token for the full ${field === 'output' ? 'output' : 'result'}`],
+        truncated,
+    };
+    truncated.returnedBytes = byteLength(shaped);
+    return shaped;
+}
@@ -0,0 +1,104 @@
+import { AsyncLocalStorage } from 'async_hooks';
+import { TOOL_CLASSES, type ProjectConfig, type Role, type ToolClass } from '../config/project.js';
+import { isMutatingCall } from '../scheduler/index.js';
+
+export interface ClassifiedTool {
+    name: string;
+    mutates?: boolean | ((args: any) => boolean);
+    // Reaches beyond the workspace: runs arbitrary binaries, pushes, posts to other hosts
+    dangerous?: boolean | ((args: any) => boolean);
+}
+
+// Roles every server has; permissions.roles can redefine them
+export const BUILTIN_ROLES: Record<string, Role> = {
+    reviewer: { allow: ['read-only'] },
+    developer: { allow: ['read-only', 'mutating'] },
+    admin: { allow: [...TOOL_CLASSES] },
+};
+
+// Role of the call in progress, so tools that call other tools (run_pipeline) can check them too
+const roleContext = new AsyncLocalStorage<{ role: string | undefined }>();
+
+/**
+ * A tool's class: permissions.classes when set, else dangerous for tools
+ * marked so, mutating for tools that can write, read-only for the rest.
+ * Tools dangerous only for some arguments are classed by their other calls.
+ */
+export function toolClass(config: ProjectConfig, tool: ClassifiedTool): ToolClass {
+    const configured = config.permissions?.classes?.[tool.name];
+    if (configured) return configured;
+    if (tool.dangerous === true) return 'dangerous';
+    return tool.mutates ? 'mutating' : 'read-only';
+}
+
+/**
+ * The class of one call: a tool that only writes for some arguments (editor
+ * read, eslint without fix) is read-only when called without them, and one
+ * that runs shell for some (run_pipeline with command steps) is dangerous
+ */
+export function callClass(config: ProjectConfig, tool: ClassifiedTool, args: Record<string, unknown>): ToolClass {
+    if (!config.permissions?.classes?.[tool.name] && typeof tool.dangerous === 'function' && tool.dangerous(args)) return 'dangerous';
+    const cls = toolClass(config, tool);
+    if (cls === 'mutating' && !config.permissions?.classes?.[tool.name] && typeof tool.mutates === 'function') {
+        return isMutatingCall(tool, args) ? 'mutating' : 'read-only';
+    }
+    return cls;
+}
+
+export function resolveRole(config: ProjectConfig, name: string): Role | undefined {
+    return config.permissions?.roles?.[name] ?? BUILTIN_ROLES[name];
+}
+
+/**
+ * The role a caller acts as: its API key's, else permissions.defaultRole.
+ * undefined means no role applies and every enabled tool may be called.
+ */
+export function callerRole(config: ProjectConfig, clientRole?: string): string | undefined {
+    return clientRole ?? config.permissions?.defaultRole;
+}
+
+/**
+ * Run fn as a call made by the role; see currentRole
+ */
+export function withRole<T>(role: string | undefined, fn: () => Promise<T>): Promise<T> {
+    return roleContext.run({ role }, fn);
+}
+
+/**
+ * The role of the call in progress; undefined outside one, or when no role applies
+ */
+export function currentRole(): string | undefined {
+    return roleContext.getStore()?.role;
+}
+
+function granted(role: Role, tool: ClassifiedTool, cls: ToolClass): boolean {
+    if (role.deny?.includes(tool.name)) return false;
+    return Boolean(role.tools?.includes(tool.name)) || role.allow.includes(cls);
+}
+
+/**
+ * Why the role may not make this call, or null when it may
+ */
+export function checkPermission(config: ProjectConfig, roleName: string | undefined, tool: ClassifiedTool, args: Record<string, unknown>): string | null {
+    if (roleName === undefined) return null;
+    const role = resolveRole(config, roleName);
+    if (!role) return `Unknown role "${roleName}"; define it under permissions.roles`;
+    const cls = callClass(config, tool, args);
+    if (granted(role, tool, cls)) return null;
+    if (role.deny?.includes(tool.name)) return `Role "${roleName}" may not call ${tool.name}`;
+    return `Role "${roleName}" may not make ${cls} calls; ${tool.name} needs a role that allows ${cls} tools or grants it by name`;
+}
+
+/**
+ * Whether the role can make any call to the tool, so clients are only
+ * offered tools they can use; tools that write for some arguments are
+ * offered to roles that can make their read-only calls
+ */
+export function canCall(config: ProjectConfig, roleName: string | undefined, tool: ClassifiedTool): boolean {
+    if (roleName === undefined) return true;
+    const role = resolveRole(config, roleName);
+    if (!role) return false;
+    const cls = toolClass(config, tool);
+    if (granted(role, tool, cls)) return true;
+    return cls === 'mutating' && !config.permissions?.classes?.[tool.name] && typeof tool.mutates === 'function' && granted(role, tool, 'read-only');
+}
@@ -0,0 +1,284 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { homedir, tmpdir } from 'os';
+import { dirname, isAbsolute, join, resolve } from 'path';
+import { Worker } from 'worker_threads';
+import yaml from 'js-yaml';
+import Config from '../config/index.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { runCommand } from '../utils/command.js';
+import { logger } from '../utils/logger.js';
+import { PATH_ARG_KEYS } from '../utils/paths.js';
+import { shellQuote } from '../utils/shell.js';
+
+// Version of the JSON protocol plugins speak, sent with every request
+export const PLUGIN_PROTOCOL = 1;
+
+const pluginSchema = z.object({
+    name: z.string().regex(/^[a-z][\w-]*$/, 'Expected a lowercase name'),
+    // process: an executable; wasm: a WASI (preview1) module run in a worker
+    type: z.enum(['process', 'wasm']).default('process'),
+    // process: the executable and its arguments; relative paths resolve against the plugins file
+    command: z.array(z.string().min(1)).min(1).optional(),
+    module: z.string().min(1).optional(),
+    timeout: z.number().int().positive().default(60000),
+    // Extra environment for process plugins; WASM modules see none
+    env: z.record(z.string()).optional(),
+}).strict()
+    .refine(p => p.type !== 'process' || p.command, { message: 'process plugins need command' })
+    .refine(p => p.type !== 'wasm' || p.module, { message: 'wasm plugins need module' });
+
+export const pluginsFileSchema = z.object({
+    plugins: z.array(pluginSchema).default([]),
+}).strict();
+
+export type Plugin = z.infer<typeof pluginSchema>;
+export type PluginsFile = z.infer<typeof pluginsFileSchema>;
+
+// What a plugin answers to describe: the tools it provides
+const describeResponseSchema = z.object({
+    tools: z.array(z.object({
+        name: z.string().regex(/^[a-z][a-z0-9_]*$/, 'Expected a snake_case tool name'),
+        description: z.string().min(1),
+        inputSchema: z.record(z.unknown()).refine(schema => schema.type === 'object', { message: 'Expected a JSON schema of type object' }),
+        // Writes files, so calls wait for the workspace to be idle
+        mutates: z.boolean().optional(),
+    }).passthrough()).min(1),
+});
+
+export interface PluginTool {
+    name: string;
+    description: string;
+    inputSchema: Record<string, unknown>;
+    mutates: boolean;
+    plugin: string;
+    // The executable a process plugin runs
+    binaries: string[];
+    run(args: any): Promise<{ success: boolean; errors: string[]; warnings: string[]; output: string; diagnostics?: Diagnostic[] }>;
+}
+
+/**
+ * Location of the plugins file: MCP_PLUGINS_FILE or ~/.config/code-feedback/plugins.yaml
+ */
+export function getPluginsFilePath(): string {
+    return process.env.MCP_PLUGINS_FILE || join(homedir(), '.config', 'code-feedback', 'plugins.yaml');
+}
+
+/**
+ * Read and validate the plugins file; a missing file means no plugins.
+ * Relative command and module paths are made absolute against the file.
+ */
+export async function loadPluginsFile(path: string = getPluginsFilePath()): Promise<PluginsFile> {
+    let raw: string;
+    try {
+        raw = await fs.readFile(path, 'utf-8');
+    } catch (error: any) {
+        if (error.code === 'ENOENT') return { plugins: [] };
+        throw error;
+    }
+    const parsed = pluginsFileSchema.safeParse(yaml.load(raw) ?? {});
+    if (!parsed.success) {
+        throw new Error(`Invalid plugins file ${path}: ${parsed.error.errors.map(e => `${e.path.join('.')} - ${e.message}`).join('; ')}`);
+    }
+    const names = parsed.data.plugins.map(p => p.name);
+    const duplicate = names.find((name, i) => names.indexOf(name) !== i);
+    if (duplicate) throw new Error(`Invalid plugins file ${path}: duplicate plugin ${duplicate}`);
+
+    const base = dirname(resolve(path));
+    // Bare names (go, python3) are looked up on PATH; ./tool and dir/tool are files next to the plugins file
+    const local = (file: string) => isAbsolute(file) || !/[\\/]/.test(file) ? file : resolve(base, file);
+    return {
+        plugins: parsed.data.plugins.map(plugin => ({
+            ...plugin,
+            ...(plugin.command ? { command: [local(plugin.command[0]!), ...plugin.command.slice(1)] } : {}),
+            ...(plugin.module ? { module: resolve(base, plugin.module) } : {}),
+        })),
+    };
+}
+
+// Runs a WASI module with request and response files as stdin and stdout; a
+// worker, so the timeout can stop a module that never returns
+const WASI_WORKER = `
+const { workerData, parentPort } = require('worker_threads');
+const { WASI } = require('wasi');
+const fs = require('fs');
+(async () => {
+    const { module, args, preopens, stdin, stdout, stderr } = workerData;
+    const fds = [fs.openSync(stdin, 'r'), fs.openSync(stdout, 'w'), fs.openSync(stderr, 'w')];
+    try {
+        const wasi = new WASI({ version: 'preview1', args, env: {}, preopens, stdin: fds[0], stdout: fds[1], stderr: fds[2], returnOnExit: true });
+        const instance = await WebAssembly.instantiate(await WebAssembly.compile(fs.readFileSync(module)), wasi.getImportObject());
+        parentPort.postMessage({ exitCode: wasi.start(instance) });
+    } finally {
+        fds.forEach(fd => fs.closeSync(fd));
+    }
+})().catch(error => parentPort.postMessage({ error: error.message }));
+`;
+
+function runWasm(plugin: Plugin, files: { stdin: string; stdout: string; stderr: string }, preopens: Record<string, string>): Promise<number> {
+    return new Promise((resolveRun, reject) => {
+        const worker = new Worker(WASI_WORKER, { eval: true, workerData: { module: plugin.module, args: [plugin.name], preopens, ...files } });
+        const timer = setTimeout(() => {
+            void worker.terminate();
+            reject(new Error(`Plugin ${plugin.name} timed out after ${plugin.timeout}ms`));
+        }, plugin.timeout);
+        worker.once('message', (message: { exitCode?: number; error?: string }) => {
+            clearTimeout(timer);
+            void worker.terminate();
+            if (message.error !== undefined) reject(new Error(`Plugin ${plugin.name} failed: ${message.error}`));
+            else resolveRun(message.exitCode ?? 0);
+        });
+        worker.once('error', error => {
+            clearTimeout(timer);
+            reject(error);
+        });
+    });
+}
+
+/**
+ * Directory a call may touch: its path argument (a file's directory), which
+ * process plugins run in and WASM modules get preopened at the same path
+ */
+function workDirectory(args: Record<string, unknown>): Promise<string | undefined> {
+    const path = PATH_ARG_KEYS.map(key => args[key]).find((v): v is string => typeof v === 'string' && v !== '');
+    if (!path) return Promise.resolve(undefined);
+    const full = resolve(path);
+    return fs.stat(full).then(stat => stat.isDirectory() ? full : dirname(full), () => dirname(full));
+}
+
+/**
+ * Send one request to a plugin and parse its JSON response. Every request
+ * starts a fresh process (or module instance): the request is its stdin and
+ * the response its stdout.
+ */
+export async function callPlugin(plugin: Plugin, request: Record<string, unknown>, cwd?: string): Promise<any> {
+    const dir = await fs.mkdtemp(join(tmpdir(), 'cf-plugin-'));
+    try {
+        const files = { stdin: join(dir, 'request.json'), stdout: join(dir, 'response.json'), stderr: join(dir, 'stderr') };
+        await fs.writeFile(files.stdin, JSON.stringify({ protocol: PLUGIN_PROTOCOL, ...request }));
+        let stdout: string;
+        let stderr: string;
+        let exitCode: number;
+        if (plugin.type === 'wasm') {
+            exitCode = await runWasm(plugin, files, cwd ? { [cwd]: cwd } : {});
+            stdout = await fs.readFile(files.stdout, 'utf-8').catch(() => '');
+            stderr = await fs.readFile(files.stderr, 'utf-8').catch(() => '');
+        } else {
+            const result = await runCommand(`${plugin.command!.map(shellQuote).join(' ')} < ${shellQuote(files.stdin)}`, {
+                cwd: cwd ?? dirname(plugin.command![0]!),
+                timeout: plugin.timeout,
+                local: true,
+                maxBuffer: 16 * 1024 * 1024,
+                env: { CODE_FEEDBACK_PLUGIN_PROTOCOL: String(PLUGIN_PROTOCOL), ...plugin.env },
+            });
+            ({ stdout, stderr, exitCode } = result);
+            if (result.limitExceeded === 'timeout') throw new Error(`Plugin ${plugin.name} timed out after ${plugin.timeout}ms`);
+        }
+        try {
+            return JSON.parse(stdout);
+        } catch {
+            const detail = stderr.trim() || stdout.trim().slice(0, 500) || 'no output';
+            throw new Error(`Plugin ${plugin.name} ${exitCode !== 0 ? `exited with ${exitCode}` : 'did not answer with JSON'}: ${detail}`);
+        }
+    } finally {
+        await fs.rm(dir, { recursive: true, force: true });
+    }
+}
+
+function toStrings(value: unknown): string[] {
+    return Array.isArray(value) ? value.map(String) : [];
+}
+
+function toDiagnostics(value: unknown, plugin: string): Diagnostic[] | undefined {
+    if (!Array.isArray(value)) return undefined;
+    return value
+        .filter((d: any) => d && typeof d.file === 'string' && typeof d.message === 'string')
+        .map((d: any) => ({
+            file: d.file,
+            line: Number.isInteger(d.line) ? d.line : 0,
+            column: Number.isInteger(d.column) ? d.column : 0,
+            severity: d.severity === 'error' || d.severity === 'info' ? d.severity : 'warning',
+            message: d.message,
+            ...(typeof d.rule === 'string' ? { rule: d.rule } : {}),
+            source: typeof d.source === 'string' ? d.source : plugin,
+            ...(d.fixable === true ? { fixable: true } : {}),
+        }));
+}
+
+/**
+ * Ask a plugin for its tools and wrap each as a tool the server registers
+ * like its own: calls are checked against the allowed paths, sent to the
+ * plugin, and the response is shaped into a standard tool result
+ */
+export async function describePlugin(plugin: Plugin): Promise<PluginTool[]> {
+    const parsed = describeResponseSchema.safeParse(await callPlugin(plugin, { method: 'describe' }));
+    if (!parsed.success) {
+        throw new Error(`Plugin ${plugin.name} gave an invalid describe response: ${parsed.error.errors.map(e => `${e.path.join('.')} - ${e.message}`).join('; ')}`);
+    }
+    return parsed.data.tools.map(tool => ({
+        name: tool.name,
+        description: `${tool.description} (plugin ${plugin.name})`,
+        inputSchema: tool.inputSchema,
+        mutates: tool.mutates ?? false,
+        plugin: plugin.name,
+        binaries: plugin.type === 'process' && plugin.command ? [plugin.command[0]!] : [],
+        async run(args: any) {
+            const callArgs: Record<string, unknown> = args && typeof args === 'object' ? args : {};
+            for (const key of PATH_ARG_KEYS) {
+                const value = callArgs[key];
+                if (typeof value === 'string' && value && !Config.getInstance().isPathAllowed(value)) {
+                    return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+                }
+            }
+            try {
+                const response = await callPlugin(plugin, { method: 'call', tool: tool.name, arguments: callArgs }, await workDirectory(callArgs));
+                const diagnostics = toDiagnostics(response?.diagnostics, plugin.name);
+                return {
+                    ...(response && typeof response === 'object' ? response : {}),
+                    success: response?.success === true,
+                    errors: toStrings(response?.errors),
+                    warnings: toStrings(response?.warnings),
+                    output: typeof response?.output === 'string' ? response.output : '',
+                    ...(diagnostics ? { diagnostics } : {}),
+                };
+            } catch (error: any) {
+                return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+            }
+        },
+    }));
+}
+
+/**
+ * Load the tools of every plugin in the plugins file. A plugin that fails to
+ * describe itself, or names a tool that already exists, is left out with an
+ * error logged, so one broken plugin does not keep the server from starting.
+ */
+export async function loadPluginTools(reserved: Iterable<string>, path: string = getPluginsFilePath()): Promise<PluginTool[]> {
+    const { plugins } = await loadPluginsFile(path);
+    const taken = new Set(reserved);
+    const tools: PluginTool[] = [];
+    for (const plugin of plugins) {
+        try {
+            const provided = await describePlugin(plugin);
+            const clash = provided.find(tool => taken.has(tool.name));
+            if (clash) throw new Error(`Plugin ${plugin.name} provides ${clash.name}, which already exists`);
+            for (const tool of provided) taken.add(tool.name);
+            tools.push(...provided);
+            logger.info('Loaded plugin', { plugin: plugin.name, type: plugin.type, tools: provided.map(t => t.name) });
+        } catch (error) {
+            logger.error('Could not load plugin', { plugin: plugin.name, error });
+        }
+    }
+    return tools;
+}
+
+/**
+ * Load the plugins file and add the plugins' tools to the registry, so MCP
+ * clients and pipelines see them like built-in tools; returns their names
+ */
+export async function registerPluginTools(path: string = getPluginsFilePath()): Promise<string[]> {
+    const { allTools } = await import('../tools/index.js');
+    const tools = await loadPluginTools(allTools.map(t => t.name), path);
+    (allTools as Array<{ name: string }>).push(...tools);
+    return tools.map(t => t.name);
+}
@@ -0,0 +1,204 @@
+import { promises as fs } from 'fs';
+import { basename, dirname, isAbsolute, relative, resolve, sep } from 'path';
+import { isWithin } from '../config/index.js';
+import { walkDirectory } from '../utils/gitignore.js';
+
+export type ProjectKind = 'go' | 'node' | 'python' | 'rust';
+
+export const PROJECT_KINDS: readonly ProjectKind[] = ['go', 'node', 'python', 'rust'];
+
+/**
+ * A sub-project of a workspace: a directory with its own manifest
+ */
+export interface Project {
+    // Module path, package name, or the directory name when the manifest has none
+    name: string;
+    kind: ProjectKind;
+    path: string;
+    // Relative to the scanned root, "." for the root itself
+    relativePath: string;
+    manifest: string;
+}
+
+// Manifests that make a directory a project
+const MANIFESTS: Array<[string, ProjectKind]> = [
+    ['go.mod', 'go'],
+    ['package.json', 'node'],
+    ['pyproject.toml', 'python'],
+    ['setup.py', 'python'],
+    ['Cargo.toml', 'rust'],
+];
+// Files that put the directories below them inside a project without being one
+const WORKSPACE_MANIFESTS: Array<[string, ProjectKind]> = [['go.work', 'go']];
+// Fixtures, dependencies and build output, even when git does not ignore them
+const SKIPPED_SEGMENTS = new Set(['node_modules', 'vendor', 'testdata', 'dist', 'build', 'target', '__pycache__', 'venv', 'site-packages']);
+const MAX_DEPTH = 8;
+const CACHE_TTL_MS = 30 * 1000;
+
+// Binaries a tool runs, mapped to the kind of project it works on
+const BINARY_KINDS: Record<string, ProjectKind> = {
+    go: 'go', gopls: 'go', 'golangci-lint': 'go', govulncheck: 'go',
+    node: 'node', npm: 'node', npx: 'node',
+    uv: 'python', ruff: 'python', 'pip-audit': 'python',
+    cargo: 'rust',
+};
+
+const KIND_NAMES: Record<ProjectKind, string> = { go: 'Go', node: 'Node', python: 'Python', rust: 'Rust' };
+
+function tomlName(text: string, table: string): string | undefined {
+    const start = text.search(new RegExp(`^\\[${table.replace('.', '\\.')}\\]\\s*$`, 'm'));
+    if (start < 0) return undefined;
+    const body = text.slice(start).split('\n').slice(1);
+    for (const line of body) {
+        if (/^\s*\[/.test(line)) break;
+        const match = /^\s*name\s*=\s*["']([^"']+)["']/.exec(line);
+        if (match) return match[1];
+    }
+    return undefined;
+}
+
+async function manifestName(manifest: string, kind: ProjectKind): Promise<string | undefined> {
+    const text = await fs.readFile(manifest, 'utf-8').catch(() => '');
+    if (kind === 'go') return /^module\s+(\S+)/m.exec(text)?.[1];
+    if (kind === 'node') {
+        try {
+            const name = JSON.parse(text)?.name;
+            return typeof name === 'string' && name ? name : undefined;
+        } catch {
+            return undefined;
+        }
+    }
+    if (kind === 'rust') return tomlName(text, 'package');
+    return basename(manifest) === 'pyproject.toml' ? tomlName(text, 'project') ?? tomlName(text, 'tool.poetry') : undefined;
+}
+
+/**
+ * Every project at or below root, by manifest, in path order. Ignored
+ * directories, hidden ones, dependencies and fixtures are not searched.
+ * A directory with several manifests (package.json next to pyproject.toml)
+ * is one project per kind.
+ */
+export async function detectProjects(root: string): Promise<Project[]> {
+    const start = resolve(root);
+    const found = new Map<string, { path: string; manifest: string; kind: ProjectKind }>();
+    const consider = (dir: string, fileName: string) => {
+        const entry = MANIFESTS.find(([name]) => name === fileName);
+        if (!entry) return;
+        const key = `${dir}\0${entry[1]}`;
+        // pyproject.toml wins over setup.py in the same directory
+        if (!found.has(key) || fileName === 'pyproject.toml') found.set(key, { path: dir, manifest: resolve(dir, fileName), kind: entry[1] });
+    };
+    await walkDirectory(start, { maxDepth: MAX_DEPTH }, entry => {
+        if (entry.type !== 'file') return;
+        if (entry.relativePath.split('/').some(segment => SKIPPED_SEGMENTS.has(segment))) return;
+        consider(dirname(entry.path), basename(entry.path));
+    });
+    const projects: Project[] = [];
+    for (const { path, manifest, kind } of found.values()) {
+        const relativePath = relative(start, path).split(sep).join('/') || '.';
+        projects.push({ name: (await manifestName(manifest, kind)) ?? (relativePath === '.' ? basename(start) : relativePath), kind, path, relativePath, manifest });
+    }
+    return projects.sort((a, b) => a.relativePath.localeCompare(b.relativePath) || a.kind.localeCompare(b.kind));
+}
+
+/**
+ * Kinds of project a tool works on, from the binaries it declares; empty for
+ * tools that are not tied to one ecosystem
+ */
+export function toolProjectKinds(tool: { binaries?: readonly string[] }): ProjectKind[] {
+    return [...new Set((tool.binaries ?? []).map(binary => BINARY_KINDS[binary]).filter((kind): kind is ProjectKind => kind !== undefined))];
+}
+
+// True when dir, or a directory above it, has a manifest (or workspace file) of kind
+async function isInsideProject(dir: string, kind: ProjectKind): Promise<boolean> {
+    const names = [...MANIFESTS, ...WORKSPACE_MANIFESTS].filter(([, k]) => k === kind).map(([name]) => name);
+    let current = resolve(dir);
+    for (;;) {
+        for (const name of names) {
+            if (await fs.access(resolve(current, name)).then(() => true, () => false)) return true;
+        }
+        // A repository root bounds the search
+        if (await fs.access(resolve(current, '.git')).then(() => true, () => false)) return false;
+        const parent = dirname(current);
+        if (parent === current) return false;
+        current = parent;
+    }
+}
+
+function describeProject(project: Project): string {
+    return project.name === project.relativePath ? project.relativePath : `${project.relativePath} (${project.name})`;
+}
+
+/**
+ * Project maps of workspace roots, rescanned after 30 seconds, and the
+ * routing of tool calls to the sub-project they are meant for
+ */
+export class ProjectDetector {
+    private cache = new Map<string, { scannedAt: number; projects: Promise<Project[]> }>();
+
+    public list(root: string, options: { refresh?: boolean } = {}): Promise<Project[]> {
+        const key = resolve(root);
+        const cached = this.cache.get(key);
+        if (cached && !options.refresh && Date.now() - cached.scannedAt < CACHE_TTL_MS) return cached.projects;
+        const projects = detectProjects(key);
+        this.cache.set(key, { scannedAt: Date.now(), projects });
+        projects.catch(() => this.cache.delete(key));
+        return projects;
+    }
+
+    /**
+     * Point projectPath at the right sub-project. An explicit project (its
+     * name or path relative to projectPath) is looked up in the project map.
+     * Otherwise a call on a directory that is not inside a project the tool
+     * works on goes to the one project of that kind below it, or the one
+     * holding filePath; with several candidates the call is refused with the
+     * list, rather than run in the wrong place. Returns the routed arguments
+     * and the project chosen, if any.
+     */
+    public async route(
+        args: Record<string, unknown>,
+        tool: { binaries?: readonly string[]; inputSchema?: unknown }
+    ): Promise<{ args: Record<string, unknown>; project?: Project }> {
+        const properties = ((tool.inputSchema as any)?.properties ?? {}) as Record<string, unknown>;
+        const { project: requested, ...rest } = args;
+        if (!('projectPath' in properties)) {
+            if (requested !== undefined) throw new Error('project only applies to tools that take a projectPath');
+            return { args };
+        }
+        const projectPath = typeof rest.projectPath === 'string' && rest.projectPath ? resolve(rest.projectPath) : undefined;
+        const kinds = toolProjectKinds(tool);
+
+        if (requested !== undefined) {
+            if (typeof requested !== 'string' || !requested) throw new Error('project must be a project name or path');
+            if (!projectPath) throw new Error('project needs a projectPath or workspace to look in');
+            const projects = (await this.list(projectPath)).filter(p => kinds.length === 0 || kinds.includes(p.kind));
+            const target = isAbsolute(requested) ? resolve(requested) : resolve(projectPath, requested);
+            const matches = projects.filter(p => p.name === requested || p.path === target);
+            const byBasename = matches.length > 0 ? matches : projects.filter(p => basename(p.path) === requested);
+            if (byBasename.length === 0) {
+                throw new Error(`No project ${requested} under ${projectPath}; projects: ${projects.map(describeProject).join(', ') || 'none'}`);
+            }
+            if (new Set(byBasename.map(p => p.path)).size > 1) {
+                throw new Error(`project ${requested} is ambiguous: ${byBasename.map(describeProject).join(', ')}`);
+            }
+            return { args: { ...rest, projectPath: byBasename[0]!.path }, project: byBasename[0]! };
+        }
+
+        // Only tools tied to one ecosystem are routed automatically
+        if (!projectPath || kinds.length !== 1) return { args };
+        const kind = kinds[0]!;
+        if (!(await fs.stat(projectPath).then(s => s.isDirectory(), () => false)) || (await isInsideProject(projectPath, kind))) return { args };
+        const candidates = (await this.list(projectPath)).filter(p => p.kind === kind);
+        const filePath = typeof rest.filePath === 'string' && rest.filePath ? resolve(projectPath, rest.filePath) : undefined;
+        const holding = filePath
+            ? candidates.filter(p => isWithin(p.path, filePath)).sort((a, b) => b.path.length - a.path.length).slice(0, 1)
+            : candidates;
+        if (holding.length === 0) return { args };
+        if (holding.length > 1) {
+            throw new Error(`${projectPath} is not a ${KIND_NAMES[kind]} project but contains ${holding.length}: ${holding.map(describeProject).join(', ')}; pass project, or a projectPath inside one`);
+        }
+        return { args: { ...rest, projectPath: holding[0]!.path }, project: holding[0]! };
+    }
+}
+
+export const projectDetector = new ProjectDetector();
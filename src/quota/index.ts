@@ -0,0 +1,197 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { homedir } from 'os';
+import { join } from 'path';
+import { createHash, timingSafeEqual } from 'crypto';
+import yaml from 'js-yaml';
+
+export const quotaLimitsSchema = z.object({
+    // Rate limit: tool calls in any 60-second window
+    callsPerMinute: z.number().int().positive().optional(),
+    // Quota: tool calls in any hour
+    callsPerHour: z.number().int().positive().optional(),
+    // Quota: CPU time (user + system) of the subprocesses the client's calls ran, in any hour
+    cpuSecondsPerHour: z.number().positive().optional(),
+}).strict();
+
+export type QuotaLimits = z.infer<typeof quotaLimitsSchema>;
+
+const apiKeySchema = z.object({
+    name: z.string().regex(/^[\w.@-]+$/, 'Expected letters, digits, _, ., @ or -'),
+    // The key itself or its sha256 hex digest, so the file need not hold secrets
+    token: z.string().min(16).optional(),
+    tokenSha256: z.string().regex(/^[0-9a-f]{64}$/).optional(),
+    limits: quotaLimitsSchema.optional(),
+    // Role under permissions in the global config (or built in: reviewer, developer, admin)
+    role: z.string().optional(),
+    // Worker queue priority of the key's calls; a call's _meta.priority overrides it
+    priority: z.enum(['interactive', 'background']).optional(),
+}).strict().refine(key => Boolean(key.token) !== Boolean(key.tokenSha256), 'Set exactly one of token or tokenSha256');
+
+export const apiKeysFileSchema = z.object({
+    // Limits of keys without their own, the --token client, and unauthenticated clients
+    defaults: quotaLimitsSchema.optional(),
+    keys: z.array(apiKeySchema).default([]),
+}).strict();
+
+export type ApiKeysFile = z.infer<typeof apiKeysFileSchema>;
+
+export interface ApiClient {
+    name: string;
+    limits: QuotaLimits;
+    // Unset: permissions.defaultRole applies
+    role?: string;
+    // Unset: interactive
+    priority?: 'interactive' | 'background';
+}
+
+export interface QuotaViolation {
+    client: string;
+    limit: keyof QuotaLimits;
+    max: number;
+    used: number;
+    retryAfterSeconds: number;
+}
+
+export interface QuotaUsage {
+    client: string;
+    callsLastMinute: number;
+    callsLastHour: number;
+    cpuSecondsLastHour: number;
+    limits: QuotaLimits;
+}
+
+const MINUTE_MS = 60 * 1000;
+const HOUR_MS = 60 * MINUTE_MS;
+
+/**
+ * Location of the API keys file: MCP_API_KEYS_FILE or ~/.config/code-feedback/api-keys.yaml
+ */
+export function getApiKeysFilePath(): string {
+    return process.env.MCP_API_KEYS_FILE || join(homedir(), '.config', 'code-feedback', 'api-keys.yaml');
+}
+
+/**
+ * Read and validate the API keys file; a missing file means no keys
+ */
+export async function loadApiKeys(path: string = getApiKeysFilePath()): Promise<ApiKeysFile> {
+    let raw: string;
+    try {
+        raw = await fs.readFile(path, 'utf-8');
+    } catch (error: any) {
+        if (error.code === 'ENOENT') return { keys: [] };
+        throw error;
+    }
+    const parsed = apiKeysFileSchema.safeParse(yaml.load(raw) ?? {});
+    if (!parsed.success) {
+        throw new Error(`Invalid API keys file ${path}: ${parsed.error.errors.map(e => `${e.path.join('.')} - ${e.message}`).join('; ')}`);
+    }
+    const names = parsed.data.keys.map(k => k.name);
+    const duplicate = names.find((name, i) => names.indexOf(name) !== i);
+    if (duplicate) throw new Error(`Invalid API keys file ${path}: duplicate key name ${duplicate}`);
+    return parsed.data;
+}
+
+function digest(token: string): Buffer {
+    return createHash('sha256').update(token).digest();
+}
+
+/**
+ * The client a bearer token belongs to: a key from the API keys file, the
+ * single --token ("default"), or with no auth configured an anonymous client
+ * per remote address. undefined means the request is unauthorized.
+ */
+export function authenticateClient(header: string | undefined, options: { token?: string; apiKeys?: ApiKeysFile; remoteAddress?: string }): ApiClient | undefined {
+    const defaults = options.apiKeys?.defaults ?? {};
+    const keys = options.apiKeys?.keys ?? [];
+    if (!options.token && keys.length === 0) return { name: `anonymous@${options.remoteAddress ?? 'unknown'}`, limits: defaults };
+    const match = /^Bearer\s+(.+)$/i.exec(header || '');
+    if (!match) return undefined;
+    const given = digest((match[1] ?? '').trim());
+    // Compared as digests, so every comparison takes the same time whatever the key length
+    let found: ApiClient | undefined;
+    for (const key of keys) {
+        const expected = key.tokenSha256 ? Buffer.from(key.tokenSha256, 'hex') : digest(key.token!);
+        if (timingSafeEqual(given, expected) && !found) found = { name: key.name, limits: key.limits ?? defaults, ...(key.role ? { role: key.role } : {}), ...(key.priority ? { priority: key.priority } : {}) };
+    }
+    if (!found && options.token && timingSafeEqual(given, digest(options.token))) found = { name: 'default', limits: defaults };
+    return found;
+}
+
+function hasLimits(limits: QuotaLimits): boolean {
+    return Boolean(limits.callsPerMinute || limits.callsPerHour || limits.cpuSecondsPerHour);
+}
+
+/**
+ * Sliding-window call counts and CPU usage per client. Calls are counted when
+ * admitted; CPU is charged as each command ends, so a call admitted just under
+ * the CPU quota can overshoot it and the client's next calls wait.
+ */
+export class QuotaTracker {
+    private calls = new Map<string, number[]>();
+    private cpu = new Map<string, Array<{ at: number; seconds: number }>>();
+
+    private prune(client: string, now: number): { calls: number[]; cpu: Array<{ at: number; seconds: number }> } {
+        const calls = (this.calls.get(client) ?? []).filter(at => at > now - HOUR_MS);
+        const cpu = (this.cpu.get(client) ?? []).filter(entry => entry.at > now - HOUR_MS);
+        this.calls.set(client, calls);
+        this.cpu.set(client, cpu);
+        return { calls, cpu };
+    }
+
+    /**
+     * Admit a call and count it, or say which limit it would exceed
+     */
+    public admit(client: ApiClient, now: number = Date.now()): QuotaViolation | null {
+        if (!hasLimits(client.limits)) return null;
+        const { calls, cpu } = this.prune(client.name, now);
+        const { callsPerMinute, callsPerHour, cpuSecondsPerHour } = client.limits;
+
+        const lastMinute = calls.filter(at => at > now - MINUTE_MS);
+        if (callsPerMinute && lastMinute.length >= callsPerMinute) {
+            const freedAt = lastMinute[lastMinute.length - callsPerMinute]! + MINUTE_MS;
+            return { client: client.name, limit: 'callsPerMinute', max: callsPerMinute, used: lastMinute.length, retryAfterSeconds: Math.max(1, Math.ceil((freedAt - now) / 1000)) };
+        }
+        if (callsPerHour && calls.length >= callsPerHour) {
+            const freedAt = calls[calls.length - callsPerHour]! + HOUR_MS;
+            return { client: client.name, limit: 'callsPerHour', max: callsPerHour, used: calls.length, retryAfterSeconds: Math.max(1, Math.ceil((freedAt - now) / 1000)) };
+        }
+        const cpuUsed = cpu.reduce((total, entry) => total + entry.seconds, 0);
+        if (cpuSecondsPerHour && cpuUsed >= cpuSecondsPerHour) {
+            // Wait until enough of the oldest usage leaves the window
+            let remaining = cpuUsed;
+            let freedAt = now;
+            for (const entry of cpu) {
+                remaining -= entry.seconds;
+                freedAt = entry.at + HOUR_MS;
+                if (remaining < cpuSecondsPerHour) break;
+            }
+            return { client: client.name, limit: 'cpuSecondsPerHour', max: cpuSecondsPerHour, used: Math.round(cpuUsed * 100) / 100, retryAfterSeconds: Math.max(1, Math.ceil((freedAt - now) / 1000)) };
+        }
+        calls.push(now);
+        return null;
+    }
+
+    public recordCpu(client: ApiClient, seconds: number, now: number = Date.now()): void {
+        if (!client.limits.cpuSecondsPerHour || seconds <= 0) return;
+        this.prune(client.name, now).cpu.push({ at: now, seconds });
+    }
+
+    public usage(client: ApiClient, now: number = Date.now()): QuotaUsage {
+        const { calls, cpu } = this.prune(client.name, now);
+        return {
+            client: client.name,
+            callsLastMinute: calls.filter(at => at > now - MINUTE_MS).length,
+            callsLastHour: calls.length,
+            cpuSecondsLastHour: Math.round(cpu.reduce((total, entry) => total + entry.seconds, 0) * 100) / 100,
+            limits: client.limits,
+        };
+    }
+}
+
+export function describeViolation(violation: QuotaViolation): string {
+    const what = { callsPerMinute: 'tool calls per minute', callsPerHour: 'tool calls per hour', cpuSecondsPerHour: 'subprocess CPU seconds per hour' }[violation.limit];
+    return `Quota exceeded for client ${violation.client}: ${violation.used} of ${violation.max} ${what} used; retry in ${violation.retryAfterSeconds}s`;
+}
+
+export const quotaTracker = new QuotaTracker();
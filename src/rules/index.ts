@@ -0,0 +1,141 @@
+import { promises as fs } from 'fs';
+import { relative, resolve, sep } from 'path';
+import { minimatch } from 'minimatch';
+import type { CustomRule } from '../config/project.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { matchesPackagePattern } from '../tools/depgraph.js';
+import { queryGoAst } from '../tools/goast.js';
+import { walkDirectory } from '../utils/gitignore.js';
+
+export const RULES_SOURCE = 'custom-rules';
+
+const MAX_FILES = 20000;
+const MAX_FILE_BYTES = 1024 * 1024;
+const MAX_FINDINGS = 1000;
+
+export interface RulesResult {
+    diagnostics: Diagnostic[];
+    // Files the pattern rules read
+    files: number;
+    warnings: string[];
+}
+
+/**
+ * Whether a rule covers a file: its files globs (all files when unset) minus
+ * its exclude globs, relative to base
+ */
+export function ruleApplies(rule: CustomRule, base: string, file: string): boolean {
+    const rel = relative(base, file).split(sep).join('/');
+    if (rule.files && !rule.files.some(glob => minimatch(rel, glob, { dot: true }))) return false;
+    return !rule.exclude?.some(glob => minimatch(rel, glob, { dot: true }));
+}
+
+/**
+ * Findings of the pattern rules in one file's text: one per match, on the
+ * line it starts
+ */
+export function matchPatternRules(rules: CustomRule[], file: string, text: string): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    const lines = text.split('\n');
+    for (const rule of rules) {
+        if (!rule.pattern) continue;
+        const pattern = new RegExp(rule.pattern, 'g');
+        lines.forEach((line, index) => {
+            for (const match of line.replace(/\r$/, '').matchAll(pattern)) {
+                diagnostics.push({ file, line: index + 1, column: (match.index ?? 0) + 1, severity: rule.severity, message: rule.message, rule: rule.id, source: RULES_SOURCE });
+            }
+        });
+    }
+    return diagnostics;
+}
+
+function goPackageAllowed(rule: CustomRule, pkg: string): boolean {
+    if (rule.go?.packages && !rule.go.packages.includes(pkg)) return false;
+    return !rule.go?.exceptPackages?.includes(pkg);
+}
+
+// "fmt.Println" -> fmt, Println; "github.com/pkg/errors.Wrap" -> github.com/pkg/errors, Wrap
+function splitCall(call: string): { importPath: string; name: string } {
+    const dot = call.lastIndexOf('.');
+    return { importPath: call.slice(0, dot), name: call.slice(dot + 1) };
+}
+
+async function runGoRules(root: string, rules: CustomRule[], applies: (rule: CustomRule, file: string) => boolean, timeout: number | undefined): Promise<{ diagnostics: Diagnostic[]; warnings: string[] }> {
+    const diagnostics: Diagnostic[] = [];
+    const warnings: string[] = [];
+    const options = { tests: true, ...(timeout ? { timeout } : {}) };
+    const queries = [
+        { query: 'calls' as const, rules: rules.filter(r => r.go?.call) },
+        { query: 'imports' as const, rules: rules.filter(r => r.go?.import) },
+    ];
+    for (const { query, rules: queryRules } of queries) {
+        if (queryRules.length === 0) continue;
+        const parsed = await queryGoAst(root, query, options);
+        warnings.push(...(parsed.parseErrors ?? []).map(e => e.message));
+        for (const found of parsed.results) {
+            for (const rule of queryRules) {
+                if (!applies(rule, found.file) || !goPackageAllowed(rule, found.package)) continue;
+                if (query === 'calls') {
+                    const { importPath, name } = splitCall(rule.go!.call!);
+                    if (!matchesPackagePattern(importPath, found.importPath) || (name !== '*' && name !== found.function)) continue;
+                } else if (!matchesPackagePattern(rule.go!.import!, found.path)) {
+                    continue;
+                }
+                diagnostics.push({ file: found.file, line: found.line, column: found.column, severity: rule.severity, message: rule.message, rule: rule.id, source: RULES_SOURCE });
+            }
+        }
+    }
+    return { diagnostics, warnings: [...new Set(warnings)] };
+}
+
+/**
+ * Check the files under root against custom rules. Pattern rules read every
+ * text file git does not ignore; Go rules parse the Go files with go/ast
+ * (see go_ast_query), which needs a Go toolchain. Globs are relative to base
+ * (the workspace root), and skip leaves out excluded paths.
+ */
+export async function runCustomRules(
+    root: string,
+    rules: CustomRule[],
+    options: { base?: string; skip?: (path: string) => boolean; timeout?: number } = {}
+): Promise<RulesResult> {
+    const start = resolve(root);
+    const base = options.base ?? start;
+    const applies = (rule: CustomRule, file: string) => ruleApplies(rule, base, file) && !options.skip?.(file);
+    const diagnostics: Diagnostic[] = [];
+    const warnings: string[] = [];
+    let files = 0;
+
+    const patternRules = rules.filter(r => r.pattern);
+    if (patternRules.length > 0) {
+        await walkDirectory(start, {}, async entry => {
+            if (entry.type !== 'file') return;
+            const applicable = patternRules.filter(rule => applies(rule, entry.path));
+            if (applicable.length === 0) return;
+            if (++files > MAX_FILES) {
+                warnings.push(`Stopped after ${MAX_FILES} files; narrow path to check the rest`);
+                return false;
+            }
+            const stat = await fs.stat(entry.path).catch(() => null);
+            if (!stat || stat.size > MAX_FILE_BYTES) return;
+            const text = await fs.readFile(entry.path, 'utf-8').catch(() => '');
+            // Binary files have no lines to match
+            if (text.includes('\0')) return;
+            diagnostics.push(...matchPatternRules(applicable, entry.path, text));
+        });
+    }
+
+    const goRules = rules.filter(r => r.go);
+    if (goRules.length > 0) {
+        const go = await runGoRules(start, goRules, applies, options.timeout);
+        diagnostics.push(...go.diagnostics);
+        warnings.push(...go.warnings);
+    }
+
+    diagnostics.sort((a, b) => a.file.localeCompare(b.file) || a.line - b.line || a.column - b.column);
+    if (diagnostics.length > MAX_FINDINGS) {
+        warnings.push(`Showing the first ${MAX_FINDINGS} of ${diagnostics.length} findings`);
+        diagnostics.length = MAX_FINDINGS;
+    }
+    return { diagnostics, files: Math.min(files, MAX_FILES), warnings };
+}
@@ -0,0 +1,101 @@
+import { randomBytes } from 'crypto';
+import { promises as fs } from 'fs';
+import { homedir } from 'os';
+import { join } from 'path';
+import type { Diagnostic } from '../diagnostics/index.js';
+import type { Verdict } from '../diagnostics/summary.js';
+import type { PipelineStepResult } from '../tools/pipeline.js';
+
+export type RunStatus = 'queued' | 'running' | 'passed' | 'failed' | 'error';
+
+export interface RunRecord {
+    id: string;
+    status: RunStatus;
+    provider: 'github' | 'gitlab';
+    // push, pull_request (GitLab merge requests included)
+    event: 'push' | 'pull_request';
+    repository: string;
+    ref: string;
+    sha: string;
+    pullNumber?: number;
+    pipeline: string;
+    createdAt: string;
+    startedAt?: string;
+    finishedAt?: string;
+    durationMs?: number;
+    summary?: { total: number; passed: number; failed: number; skipped: number };
+    steps?: PipelineStepResult[];
+    diagnostics?: Diagnostic[];
+    verdict?: Verdict;
+    // Review posted on the pull request, when the repository asks for one
+    review?: { published: boolean; url?: string; errors: string[] };
+    warnings: string[];
+    // Why the run could not complete (checkout or config failure)
+    error?: string;
+}
+
+const DEFAULT_KEEP = 100;
+
+/**
+ * Location of run records: MCP_RUNS_DIR or ~/.local/state/code-feedback/runs
+ */
+export function getRunsDir(): string {
+    return process.env.MCP_RUNS_DIR || join(homedir(), '.local', 'state', 'code-feedback', 'runs');
+}
+
+function getKeep(): number {
+    const keep = Number(process.env.MCP_RUN_LIMIT);
+    return keep >= 1 ? Math.floor(keep) : DEFAULT_KEEP;
+}
+
+/**
+ * Webhook-triggered pipeline runs, one JSON file each, rewritten as the run
+ * moves from queued to its result
+ */
+export class RunStore {
+    public newId(): string {
+        // Sorts by time
+        return `${new Date().toISOString().replace(/[-:.]/g, '')}-${randomBytes(3).toString('hex')}`;
+    }
+
+    public async save(run: RunRecord): Promise<void> {
+        const dir = getRunsDir();
+        await fs.mkdir(dir, { recursive: true });
+        const path = join(dir, `${run.id}.json`);
+        const partial = `${path}.${randomBytes(4).toString('hex')}.tmp`;
+        await fs.writeFile(partial, JSON.stringify(run, null, 2) + '\n', { mode: 0o600 });
+        await fs.rename(partial, path);
+        if (run.status === 'queued') await this.prune();
+    }
+
+    public async get(id: string): Promise<RunRecord | null> {
+        if (!/^[\w-]+$/.test(id)) return null;
+        try {
+            return JSON.parse(await fs.readFile(join(getRunsDir(), `${id}.json`), 'utf-8')) as RunRecord;
+        } catch (error: any) {
+            if (error.code === 'ENOENT') return null;
+            throw error;
+        }
+    }
+
+    /**
+     * Runs newest first
+     */
+    public async list(): Promise<RunRecord[]> {
+        const names = await fs.readdir(getRunsDir()).catch(() => [] as string[]);
+        const runs: RunRecord[] = [];
+        for (const name of names.filter(n => n.endsWith('.json')).sort().reverse()) {
+            const run = await this.get(name.slice(0, -'.json'.length)).catch(() => null);
+            if (run) runs.push(run);
+        }
+        return runs;
+    }
+
+    // Drop all but the newest records
+    private async prune(): Promise<void> {
+        const names = (await fs.readdir(getRunsDir()).catch(() => [] as string[])).filter(n => n.endsWith('.json')).sort().reverse();
+        for (const name of names.slice(getKeep())) await fs.rm(join(getRunsDir(), name), { force: true });
+    }
+}
+
+export const runStore = new RunStore();
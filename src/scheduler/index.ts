@@ -0,0 +1,221 @@
+import { dirname, resolve } from 'path';
+import { promises as fs } from 'fs';
+import Config from '../config/index.js';
+import { findUp } from '../utils/paths.js';
+
+interface Waiter {
+    exclusive: boolean;
+    grant: () => void;
+}
+
+// interactive: an agent is waiting on the answer; background: CI, watch and batch work that can wait
+export type Priority = 'interactive' | 'background';
+
+export const PRIORITIES: readonly Priority[] = ['interactive', 'background'];
+
+export interface RunOptions {
+    priority?: Priority;
+    // Told the call's place in the worker queue (1 is next) whenever it changes while waiting
+    onQueued?: (position: number, queued: number) => void;
+}
+
+interface SlotWaiter {
+    priority: Priority;
+    queuedAt: number;
+    grant: () => void;
+    onQueued?: RunOptions['onQueued'];
+    lastPosition?: number;
+}
+
+/**
+ * A call refused because the queue is at MCP_MAX_QUEUE
+ */
+export class QueueFullError extends Error {
+    public readonly queued: number;
+
+    constructor(queued: number) {
+        super(`Server busy: ${queued} tool call(s) already queued (MCP_MAX_QUEUE); retry later`);
+        this.name = 'QueueFullError';
+        this.queued = queued;
+    }
+}
+
+// Background calls that have waited this long are served like interactive ones, so they are never starved
+const BACKGROUND_AGING_MS = 60 * 1000;
+
+/**
+ * Reader/writer lock for one workspace. Builds and checks share it; edits take
+ * it exclusively. Waiters are served in arrival order, so a queued edit is not
+ * starved by a stream of builds.
+ */
+class WorkspaceLock {
+    private readers = 0;
+    private writer = false;
+    private queue: Waiter[] = [];
+
+    public acquire(exclusive: boolean): Promise<void> {
+        return new Promise(grant => {
+            this.queue.push({ exclusive, grant });
+            this.drain();
+        });
+    }
+
+    public release(exclusive: boolean): void {
+        if (exclusive) {
+            this.writer = false;
+        } else {
+            this.readers--;
+        }
+        this.drain();
+    }
+
+    public get idle(): boolean {
+        return !this.writer && this.readers === 0 && this.queue.length === 0;
+    }
+
+    private drain(): void {
+        while (this.queue.length > 0) {
+            const next = this.queue[0]!;
+            if (this.writer || (next.exclusive && this.readers > 0)) return;
+            this.queue.shift();
+            if (next.exclusive) {
+                this.writer = true;
+            } else {
+                this.readers++;
+            }
+            next.grant();
+        }
+    }
+}
+
+/**
+ * Runs tool calls on a bounded worker pool. Calls on different workspaces, or
+ * read-only calls on the same workspace, run concurrently; a mutating call
+ * waits for the workspace to be quiet and holds off everything behind it.
+ * Interactive calls get free workers before background ones, and with a
+ * maximum queue depth, calls beyond it are refused instead of waiting.
+ */
+export class Scheduler {
+    private active = 0;
+    private slots: SlotWaiter[] = [];
+    private locks = new Map<string, WorkspaceLock>();
+    private concurrency: number;
+    // Admitted calls that have not started, whether waiting for a workspace or a worker
+    private waiting = 0;
+    private maxQueued: number;
+
+    // maxQueued 0 lets the queue grow without bound
+    constructor(concurrency: number, maxQueued = 0) {
+        this.concurrency = Math.max(1, concurrency);
+        this.maxQueued = Math.max(0, maxQueued);
+    }
+
+    public setConcurrency(concurrency: number): void {
+        this.concurrency = Math.max(1, concurrency);
+        this.wakeSlots();
+    }
+
+    public setMaxQueued(maxQueued: number): void {
+        this.maxQueued = Math.max(0, maxQueued);
+    }
+
+    /**
+     * Run fn once its workspace and a worker are free; throws QueueFullError
+     * without waiting when the queue is full
+     */
+    public async run<T>(workspace: string | null, exclusive: boolean, fn: () => Promise<T>, options: RunOptions = {}): Promise<T> {
+        // Admitted calls that free workers will take straight away are not queued
+        const queued = this.waiting - Math.max(0, this.concurrency - this.active);
+        if (this.maxQueued > 0 && queued >= this.maxQueued) throw new QueueFullError(queued);
+        const lock = workspace ? this.getLock(workspace) : null;
+        // Neither wait can fail, so the call is counted until it gets its worker
+        this.waiting++;
+        await lock?.acquire(exclusive);
+        try {
+            await this.acquireSlot(options);
+            this.waiting--;
+            try {
+                return await fn();
+            } finally {
+                this.releaseSlot();
+            }
+        } finally {
+            if (lock && workspace) {
+                lock.release(exclusive);
+                if (lock.idle) this.locks.delete(workspace);
+            }
+        }
+    }
+
+    public getStats(): { concurrency: number; active: number; queued: number; queuedByPriority: Record<Priority, number>; maxQueued: number; lockedWorkspaces: number } {
+        const queuedByPriority = { interactive: 0, background: 0 };
+        for (const waiter of this.slots) queuedByPriority[waiter.priority]++;
+        return { concurrency: this.concurrency, active: this.active, queued: this.slots.length, queuedByPriority, maxQueued: this.maxQueued, lockedWorkspaces: this.locks.size };
+    }
+
+    private getLock(workspace: string): WorkspaceLock {
+        let lock = this.locks.get(workspace);
+        if (!lock) {
+            lock = new WorkspaceLock();
+            this.locks.set(workspace, lock);
+        }
+        return lock;
+    }
+
+    private acquireSlot(options: RunOptions): Promise<void> {
+        return new Promise(grant => {
+            this.slots.push({ priority: options.priority ?? 'interactive', queuedAt: Date.now(), grant, ...(options.onQueued ? { onQueued: options.onQueued } : {}) });
+            this.wakeSlots();
+        });
+    }
+
+    private releaseSlot(): void {
+        this.active--;
+        this.wakeSlots();
+    }
+
+    // Interactive first, then background (aged background calls count as interactive); arrival order within each
+    private rank(waiter: SlotWaiter, now: number): number {
+        return waiter.priority === 'interactive' || now - waiter.queuedAt >= BACKGROUND_AGING_MS ? 0 : 1;
+    }
+
+    private wakeSlots(): void {
+        const now = Date.now();
+        // Stable sort keeps arrival order within a rank
+        this.slots.sort((a, b) => this.rank(a, now) - this.rank(b, now));
+        while (this.active < this.concurrency && this.slots.length > 0) {
+            this.active++;
+            this.slots.shift()!.grant();
+        }
+        this.slots.forEach((waiter, index) => {
+            if (waiter.lastPosition === index + 1) return;
+            waiter.lastPosition = index + 1;
+            waiter.onQueued?.(index + 1, this.slots.length);
+        });
+    }
+}
+
+/**
+ * Workspace a path belongs to, used as the lock key: the project config root
+ * when there is one, else the enclosing git repository, else the directory itself
+ */
+export async function resolveWorkspace(targetPath: string, workspaceRoot: string | null): Promise<string> {
+    if (workspaceRoot) return resolve(workspaceRoot);
+    let dir = resolve(targetPath);
+    try {
+        if (!(await fs.stat(dir)).isDirectory()) dir = dirname(dir);
+    } catch {
+        dir = dirname(dir);
+    }
+    const gitDir = await findUp(dir, '.git');
+    return gitDir ? dirname(gitDir) : dir;
+}
+
+/**
+ * Whether a tool call changes files in its workspace and so needs the workspace to itself
+ */
+export function isMutatingCall(tool: { mutates?: boolean | ((args: any) => boolean) }, args: Record<string, unknown>): boolean {
+    return typeof tool.mutates === 'function' ? tool.mutates(args) : Boolean(tool.mutates);
+}
+
+export const scheduler = new Scheduler(Config.getInstance().getMaxConcurrency(), Config.getInstance().getMaxQueueDepth());
@@ -0,0 +1,125 @@
+import { spawn } from 'child_process';
+
+export interface EmbeddingOptions {
+    // OpenAI-compatible API root, e.g. https://api.openai.com/v1 or http://localhost:11434/v1
+    url?: string;
+    apiKey?: string;
+    model?: string;
+    // Local model: reads a JSON array of strings on stdin, prints a JSON array of vectors
+    command?: string;
+    // Texts sent per request
+    batchSize?: number;
+    timeoutMs?: number;
+}
+
+export interface EmbeddingProvider {
+    // Identifies the model; vectors from different providers are never compared
+    id: string;
+    embed(texts: string[]): Promise<number[][]>;
+}
+
+const DEFAULT_MODEL = 'text-embedding-3-small';
+const DEFAULT_BATCH_SIZE = 64;
+const DEFAULT_TIMEOUT_MS = 60 * 1000;
+
+/**
+ * Embedding settings from MCP_EMBEDDINGS_* (and OPENAI_API_KEY). With a key
+ * and no URL the OpenAI API is used; a command takes precedence over both.
+ */
+export function embeddingOptionsFromEnv(env: NodeJS.ProcessEnv = process.env): EmbeddingOptions {
+    const apiKey = env.MCP_EMBEDDINGS_API_KEY || env.OPENAI_API_KEY;
+    const url = env.MCP_EMBEDDINGS_URL || (apiKey ? 'https://api.openai.com/v1' : undefined);
+    const batchSize = Number(env.MCP_EMBEDDINGS_BATCH);
+    return {
+        ...(url ? { url: url.replace(/\/+$/, '') } : {}),
+        ...(apiKey ? { apiKey } : {}),
+        ...(env.MCP_EMBEDDINGS_MODEL ? { model: env.MCP_EMBEDDINGS_MODEL } : {}),
+        ...(env.MCP_EMBEDDINGS_COMMAND ? { command: env.MCP_EMBEDDINGS_COMMAND } : {}),
+        ...(batchSize >= 1 ? { batchSize: Math.floor(batchSize) } : {}),
+    };
+}
+
+function checkVectors(vectors: unknown, count: number, source: string): number[][] {
+    if (!Array.isArray(vectors) || vectors.length !== count || !vectors.every(v => Array.isArray(v) && v.length > 0 && v.every(n => typeof n === 'number'))) {
+        throw new Error(`${source} did not return ${count} embedding vector(s)`);
+    }
+    const dimensions = (vectors[0] as number[]).length;
+    if (vectors.some(v => v.length !== dimensions)) throw new Error(`${source} returned vectors of different lengths`);
+    return vectors as number[][];
+}
+
+async function embedOverHttp(options: EmbeddingOptions, model: string, texts: string[]): Promise<number[][]> {
+    const response = await fetch(`${options.url}/embeddings`, {
+        method: 'POST',
+        headers: {
+            'content-type': 'application/json',
+            ...(options.apiKey ? { authorization: `Bearer ${options.apiKey}` } : {}),
+        },
+        body: JSON.stringify({ model, input: texts }),
+        signal: AbortSignal.timeout(options.timeoutMs ?? DEFAULT_TIMEOUT_MS),
+    });
+    const text = await response.text();
+    if (!response.ok) throw new Error(`Embeddings request to ${options.url} failed: HTTP ${response.status} ${text.slice(0, 500)}`);
+    let body: any;
+    try {
+        body = JSON.parse(text);
+    } catch {
+        throw new Error(`Embeddings response from ${options.url} is not JSON`);
+    }
+    // Entries carry their index; providers need not keep input order
+    const data: Array<{ index?: number; embedding?: number[] }> = Array.isArray(body?.data) ? body.data : [];
+    const ordered = [...data].sort((a, b) => (a.index ?? 0) - (b.index ?? 0)).map(d => d.embedding);
+    return checkVectors(ordered, texts.length, options.url ?? 'Embeddings API');
+}
+
+function embedWithCommand(command: string, texts: string[], timeoutMs: number): Promise<number[][]> {
+    return new Promise((resolve, reject) => {
+        const child = spawn(command, { shell: true, stdio: ['pipe', 'pipe', 'pipe'] });
+        const stdout: Buffer[] = [];
+        const stderr: Buffer[] = [];
+        const timer = setTimeout(() => child.kill('SIGKILL'), timeoutMs);
+        child.stdout.on('data', chunk => stdout.push(chunk));
+        child.stderr.on('data', chunk => stderr.push(chunk));
+        child.on('error', error => {
+            clearTimeout(timer);
+            reject(error);
+        });
+        child.on('close', (code, signal) => {
+            clearTimeout(timer);
+            if (code !== 0) {
+                const reason = signal ? `was killed (${signal})` : `exited with ${code}`;
+                reject(new Error(`Embedding command ${reason}: ${Buffer.concat(stderr).toString().trim().slice(0, 500)}`));
+                return;
+            }
+            try {
+                resolve(checkVectors(JSON.parse(Buffer.concat(stdout).toString()), texts.length, 'Embedding command'));
+            } catch (error: any) {
+                reject(error instanceof SyntaxError ? new Error('Embedding command did not print JSON') : error);
+            }
+        });
+        child.stdin.on('error', () => undefined);
+        child.stdin.end(JSON.stringify(texts));
+    });
+}
+
+/**
+ * The configured embedding provider, or null when semantic search is not set up
+ */
+export function createEmbeddingProvider(options: EmbeddingOptions = embeddingOptionsFromEnv()): EmbeddingProvider | null {
+    const batchSize = options.batchSize ?? DEFAULT_BATCH_SIZE;
+    const timeoutMs = options.timeoutMs ?? DEFAULT_TIMEOUT_MS;
+    const batched = (embed: (texts: string[]) => Promise<number[][]>) => async (texts: string[]) => {
+        const vectors: number[][] = [];
+        for (let i = 0; i < texts.length; i += batchSize) vectors.push(...(await embed(texts.slice(i, i + batchSize))));
+        return vectors;
+    };
+    if (options.command) {
+        const command = options.command;
+        return { id: `command:${command}`, embed: batched(texts => embedWithCommand(command, texts, timeoutMs)) };
+    }
+    if (options.url) {
+        const model = options.model ?? DEFAULT_MODEL;
+        return { id: `${options.url}#${model}`, embed: batched(texts => embedOverHttp(options, model, texts)) };
+    }
+    return null;
+}
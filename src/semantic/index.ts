@@ -0,0 +1,266 @@
+import { createHash } from 'crypto';
+import { promises as fs } from 'fs';
+import { dirname, join, relative, resolve, sep } from 'path';
+import { collectSourceFiles, isTestFile } from '../utils/sourcefiles.js';
+import { cacheDirectory } from '../utils/paths.js';
+import { logger } from '../utils/logger.js';
+import type { EmbeddingProvider } from './embeddings.js';
+
+export { createEmbeddingProvider, embeddingOptionsFromEnv, type EmbeddingOptions, type EmbeddingProvider } from './embeddings.js';
+
+export interface Chunk {
+    startLine: number;
+    endLine: number;
+    text: string;
+}
+
+interface IndexedChunk {
+    startLine: number;
+    endLine: number;
+    // Float32 vector, base64
+    vector: string;
+}
+
+interface IndexedFile {
+    hash: string;
+    chunks: IndexedChunk[];
+}
+
+interface IndexFile {
+    version: number;
+    root: string;
+    provider: string;
+    // Relative paths with forward slashes
+    files: Record<string, IndexedFile>;
+}
+
+export interface RefreshStats {
+    files: number;
+    chunks: number;
+    // Files chunked and embedded by this refresh, and chunks sent to the provider
+    reindexedFiles: number;
+    embeddedChunks: number;
+    removedFiles: number;
+    warnings: string[];
+}
+
+export interface SemanticMatch {
+    file: string;
+    startLine: number;
+    endLine: number;
+    // Cosine similarity of the chunk to the query
+    score: number;
+    snippet: string;
+}
+
+const INDEX_VERSION = 1;
+const CHUNK_LINES = 60;
+const CHUNK_OVERLAP = 10;
+// Characters of a chunk sent to the provider; embedding models cap their input
+const MAX_CHUNK_CHARS = 6000;
+const MAX_CHUNKS = 50000;
+
+function toPosix(path: string): string {
+    return path.split(sep).join('/');
+}
+
+/**
+ * Split a file into overlapping windows of about 60 lines. A window ends
+ * at a blank line near its end when there is one, so chunks tend to hold
+ * whole functions rather than halves of two.
+ */
+export function chunkSource(source: string): Chunk[] {
+    const lines = source.split('\n');
+    if (lines.length > 0 && lines[lines.length - 1] === '') lines.pop();
+    const chunks: Chunk[] = [];
+    let start = 0;
+    while (start < lines.length) {
+        let end = Math.min(lines.length, start + CHUNK_LINES);
+        if (end < lines.length) {
+            for (let i = end - 1; i > start + CHUNK_LINES / 2; i--) {
+                if (lines[i]!.trim() === '') {
+                    end = i + 1;
+                    break;
+                }
+            }
+        }
+        const text = lines.slice(start, end).join('\n');
+        if (text.trim() !== '') chunks.push({ startLine: start + 1, endLine: end, text: text.slice(0, MAX_CHUNK_CHARS) });
+        if (end >= lines.length) break;
+        start = Math.max(start + 1, end - CHUNK_OVERLAP);
+    }
+    return chunks;
+}
+
+function encodeVector(vector: number[]): string {
+    return Buffer.from(new Float32Array(vector).buffer).toString('base64');
+}
+
+function decodeVector(encoded: string): Float32Array {
+    const buffer = Buffer.from(encoded, 'base64');
+    return new Float32Array(buffer.buffer, buffer.byteOffset, buffer.byteLength / 4);
+}
+
+function cosine(a: ArrayLike<number>, b: ArrayLike<number>): number {
+    if (a.length !== b.length) return 0;
+    let dot = 0;
+    let normA = 0;
+    let normB = 0;
+    for (let i = 0; i < a.length; i++) {
+        dot += a[i]! * b[i]!;
+        normA += a[i]! * a[i]!;
+        normB += b[i]! * b[i]!;
+    }
+    return normA && normB ? dot / Math.sqrt(normA * normB) : 0;
+}
+
+/**
+ * Embeddings of one workspace's source chunks, kept on disk under the cache
+ * directory and brought up to date incrementally: only files whose content
+ * changed since the last refresh are chunked and embedded again.
+ */
+export class SemanticIndex {
+    public readonly root: string;
+    public readonly path: string;
+    private readonly provider: EmbeddingProvider;
+    private data: IndexFile | undefined;
+    private refreshing: Promise<RefreshStats> | undefined;
+
+    constructor(root: string, provider: EmbeddingProvider, directory: string = cacheDirectory('semantic')) {
+        this.root = resolve(root);
+        this.provider = provider;
+        const key = createHash('sha256').update(`${this.root}\0${provider.id}`).digest('hex').slice(0, 16);
+        this.path = join(directory, `${key}.json`);
+    }
+
+    private async load(): Promise<IndexFile> {
+        if (this.data) return this.data;
+        const empty: IndexFile = { version: INDEX_VERSION, root: this.root, provider: this.provider.id, files: {} };
+        try {
+            const parsed = JSON.parse(await fs.readFile(this.path, 'utf-8')) as IndexFile;
+            this.data = parsed.version === INDEX_VERSION && parsed.root === this.root && parsed.provider === this.provider.id ? parsed : empty;
+        } catch (error: any) {
+            if (error.code !== 'ENOENT') logger.warn('Discarding unreadable semantic index', { path: this.path, error });
+            this.data = empty;
+        }
+        return this.data;
+    }
+
+    private async save(data: IndexFile): Promise<void> {
+        await fs.mkdir(dirname(this.path), { recursive: true });
+        // Written aside and renamed, so a crash never leaves half an index
+        const temporary = `${this.path}.${process.pid}.tmp`;
+        await fs.writeFile(temporary, JSON.stringify(data));
+        await fs.rename(temporary, this.path);
+    }
+
+    /**
+     * Re-embed what changed since the last refresh. Every source file is
+     * indexed, tests included, so searches with different filters share one
+     * index. Concurrent calls share one refresh.
+     */
+    public refresh(options: { full?: boolean } = {}): Promise<RefreshStats> {
+        if (!this.refreshing) {
+            this.refreshing = this.doRefresh(options).finally(() => { this.refreshing = undefined; });
+        }
+        return this.refreshing;
+    }
+
+    private async doRefresh(options: { full?: boolean }): Promise<RefreshStats> {
+        const data = await this.load();
+        if (options.full) data.files = {};
+        const { files: sources, warnings } = await collectSourceFiles(this.root, { includeTests: true });
+        const seen = new Set<string>();
+        const stale: Array<{ rel: string; hash: string; chunks: Chunk[] }> = [];
+        let total = 0;
+        for (const { path, source } of sources) {
+            const rel = toPosix(relative(this.root, path));
+            seen.add(rel);
+            const hash = createHash('sha256').update(source).digest('hex');
+            const current = data.files[rel];
+            if (current?.hash === hash) {
+                total += current.chunks.length;
+                continue;
+            }
+            const chunks = chunkSource(source);
+            if (total + chunks.length > MAX_CHUNKS) {
+                warnings.push(`Stopped indexing at ${MAX_CHUNKS} chunks; narrow the extensions or exclude`);
+                break;
+            }
+            total += chunks.length;
+            stale.push({ rel, hash, chunks });
+        }
+        let removedFiles = 0;
+        for (const rel of Object.keys(data.files)) {
+            if (seen.has(rel)) continue;
+            delete data.files[rel];
+            removedFiles++;
+        }
+
+        // The path goes in with the code: names of files and directories say a lot about it
+        const texts = stale.flatMap(file => file.chunks.map(chunk => `${file.rel}\n${chunk.text}`));
+        const vectors = texts.length > 0 ? await this.provider.embed(texts) : [];
+        let next = 0;
+        for (const file of stale) {
+            data.files[file.rel] = {
+                hash: file.hash,
+                chunks: file.chunks.map(chunk => ({ startLine: chunk.startLine, endLine: chunk.endLine, vector: encodeVector(vectors[next++]!) })),
+            };
+        }
+        if (stale.length > 0 || removedFiles > 0 || options.full) await this.save(data);
+        return {
+            files: Object.keys(data.files).length,
+            chunks: Object.values(data.files).reduce((sum, file) => sum + file.chunks.length, 0),
+            reindexedFiles: stale.length,
+            embeddedChunks: texts.length,
+            removedFiles,
+            warnings,
+        };
+    }
+
+    /**
+     * Chunks closest in meaning to the query, best first
+     */
+    public async search(query: string, options: { limit: number; minScore?: number; includeTests?: boolean }): Promise<SemanticMatch[]> {
+        const data = await this.load();
+        const [queryVector] = await this.provider.embed([query]);
+        const scored: Array<Omit<SemanticMatch, 'snippet'>> = [];
+        for (const [file, entry] of Object.entries(data.files)) {
+            if (!options.includeTests && isTestFile(file)) continue;
+            for (const chunk of entry.chunks) {
+                const score = cosine(queryVector!, decodeVector(chunk.vector));
+                if (score >= (options.minScore ?? 0)) scored.push({ file, startLine: chunk.startLine, endLine: chunk.endLine, score });
+            }
+        }
+        scored.sort((a, b) => b.score - a.score);
+        const matches: SemanticMatch[] = [];
+        const lines = new Map<string, string[]>();
+        for (const match of scored.slice(0, options.limit)) {
+            if (!lines.has(match.file)) {
+                lines.set(match.file, (await fs.readFile(join(this.root, match.file), 'utf-8').catch(() => '')).split('\n'));
+            }
+            const snippet = lines.get(match.file)!.slice(match.startLine - 1, match.endLine).join('\n');
+            matches.push({ ...match, score: Math.round(match.score * 1000) / 1000, snippet });
+        }
+        return matches;
+    }
+}
+
+/**
+ * One index per workspace root and provider for the life of the process
+ */
+export class SemanticIndexer {
+    private indexes = new Map<string, SemanticIndex>();
+
+    public get(root: string, provider: EmbeddingProvider): SemanticIndex {
+        const key = `${resolve(root)}\0${provider.id}`;
+        let index = this.indexes.get(key);
+        if (!index) {
+            index = new SemanticIndex(root, provider);
+            this.indexes.set(key, index);
+        }
+        return index;
+    }
+}
+
+export const semanticIndexer = new SemanticIndexer();
@@ -0,0 +1,573 @@
+import { Server } from '@modelcontextprotocol/sdk/server/index.js';
+import {
+  CallToolRequestSchema,
+  ListToolsRequestSchema,
+  InitializeRequestSchema,
+  ErrorCode,
+  McpError
+} from '@modelcontextprotocol/sdk/types.js';
+import { allTools } from './tools/index.js';
+import { registerResources } from './resources/index.js';
+import { registerPrompts } from './prompts/index.js';
+import { withStreamHandler, withCommandDefaults, type LimitEvent, type StreamHandler } from './utils/command.js';
+import type { RetryEvent } from './executor/retry.js';
+import { OFFLINE_MODES, denyProxyEnv, offlineEnv, strictestOffline, withOfflineArg, type OfflineMode, type OfflineViolation } from './executor/offline.js';
+import { detectNetworkSandbox, type NetworkSandbox } from './executor/sandbox.js';
+import { resultCache, isCacheableCall } from './cache/index.js';
+import Config, { MIN_OUTPUT_BYTES } from './config/index.js';
+import { getEffectiveConfig, isToolEnabled, isExcluded, getToolTimeout } from './config/project.js';
+import { resolveWorkspaceEnv } from './config/env.js';
+import { serviceManager, servicesRefusal, type ServiceLease } from './services/index.js';
+import { redactResult } from './utils/env.js';
+import { getPathArg, withWorkspaceArg } from './utils/paths.js';
+import { workspaceRegistry } from './workspaces/index.js';
+import { projectDetector } from './projects/index.js';
+import { createHash, randomUUID } from 'crypto';
+import { auditLog, type AuditStatus } from './audit/index.js';
+import { metrics } from './metrics/index.js';
+import { callerRole, canCall, checkPermission, withRole, type ClassifiedTool } from './permissions/index.js';
+import { applyOutputBudget, withOutputBudgetArg } from './output/index.js';
+import { applySuppressions, suppressionOptions, type FindingsOutcome } from './diagnostics/suppressions.js';
+import { logger, withLogContext } from './utils/logger.js';
+import { parseTraceparent, tracer } from './tracing/index.js';
+import { describeViolation, quotaTracker, type ApiClient } from './quota/index.js';
+import { snapshotStore } from './snapshots/index.js';
+import { selectToolchains } from './toolchains/index.js';
+import { resolveSshTarget, type SshTarget } from './executor/ssh.js';
+import { scheduler, resolveWorkspace, isMutatingCall, QueueFullError, PRIORITIES, type Priority } from './scheduler/index.js';
+import { watchManager } from './watch/index.js';
+import { executionRegistry, type Execution } from './executions/index.js';
+
+// A call to a tool listed under services.tools is checked as this one too
+const SERVICES_CALL: ClassifiedTool = { name: 'test_services', dangerous: true };
+
+/**
+ * Sends a call's MCP progress notifications: its place in the worker queue
+ * while it waits, then its command output. They share one counter, since
+ * progress must increase across the whole call.
+ */
+function createProgressReporter(
+  progressToken: string | number,
+  sendNotification: (notification: any) => Promise<void>
+): { queued: (position: number, queued: number) => void; output: StreamHandler } {
+  let progress = 0;
+  const send = (message: string) => {
+    progress++;
+    sendNotification({
+      method: 'notifications/progress',
+      params: { progressToken, progress, message },
+    }).catch((error) => {
+      logger.warn('Failed to send progress notification', { error });
+    });
+  };
+  return {
+    queued: (position, queued) => send(`Queued: position ${position} of ${queued}`),
+    output: (chunk, stream) => send(`[${stream}] ${chunk}`),
+  };
+}
+
+/**
+ * Worker queue priority of a call: its _meta.priority, else the API key's, else interactive
+ */
+function callPriority(meta: unknown, client: ApiClient | undefined): Priority {
+  const requested = (meta as { priority?: unknown } | undefined)?.priority;
+  return PRIORITIES.includes(requested as Priority) ? requested as Priority : client?.priority ?? 'interactive';
+}
+
+/**
+ * Tool result for a call rejected before the tool ran
+ */
+function errorContent(message: string, requestId: string, details: Record<string, unknown> = {}) {
+  return {
+    content: [
+      {
+        type: 'text',
+        text: JSON.stringify({ success: false, errors: [message], warnings: [], output: '', ...details, requestId }, null, 2),
+      },
+    ],
+  };
+}
+
+/**
+ * Mark a tool result as failed because commands it ran hit resource limits
+ */
+function withLimitErrors(result: any, events: LimitEvent[]) {
+  const units = { timeout: 'ms', memory: 'MB', cpu: 's CPU' };
+  const errors = events.map(e => `Command exceeded its ${e.limit} limit (${e.value}${units[e.limit]}): ${e.command}`);
+  return {
+    ...result,
+    success: false,
+    errors: [...(Array.isArray(result?.errors) ? result.errors : []), ...errors],
+    limitExceeded: events,
+  };
+}
+
+/**
+ * Note the retries a call's commands needed, and flag failures that were
+ * still transient once retries ran out as infrastructure trouble
+ */
+function withRetries(result: any, events: RetryEvent[]) {
+  const retried = events.filter(e => !e.exhausted);
+  const exhausted = events.filter(e => e.exhausted);
+  return {
+    ...result,
+    warnings: [
+      ...(Array.isArray(result?.warnings) ? result.warnings : []),
+      ...retried.map(e => `Retried after a transient failure (${e.description}): ${e.command}`),
+    ],
+    ...(exhausted.length > 0 ? {
+      errors: [
+        ...(Array.isArray(result?.errors) ? result.errors : []),
+        ...exhausted.map(e => `Failed for a transient reason (${e.description}) after ${e.attempt} retr${e.attempt === 1 ? 'y' : 'ies'}; this points at the network or a registry, not the code: ${e.command}`),
+      ],
+      transientFailure: true,
+    } : {}),
+    retries: retried.length,
+  };
+}
+
+/**
+ * Fail a call whose commands tried to reach the network in offline mode, naming what they needed
+ */
+function withOfflineViolations(result: any, violations: OfflineViolation[]) {
+  return {
+    ...result,
+    success: false,
+    errors: [
+      ...(Array.isArray(result?.errors) ? result.errors : []),
+      ...violations.map(v => `Tried to reach the network in offline mode (${v.description}); vendor or cache the dependency first: ${v.command}`),
+    ],
+    offlineViolation: true,
+  };
+}
+
+/**
+ * Mark a tool result as cancelled, with the output its commands printed before they were killed
+ */
+function withCancellation(result: any, execution: Execution) {
+  return {
+    ...result,
+    success: false,
+    errors: [...(Array.isArray(result?.errors) ? result.errors : []), execution.cancelReason ?? 'Cancelled'],
+    cancelled: true,
+    partialOutput: execution.partialOutput,
+  };
+}
+
+// Cache keys change when a secret does without holding its value
+function hashSecrets(secrets: Record<string, string>): Record<string, string> {
+  return Object.fromEntries(Object.entries(secrets).map(([name, value]) => [name, createHash('sha256').update(value).digest('hex')]));
+}
+
+function withWarnings(result: any, warnings: string[]) {
+  return { ...result, warnings: [...(Array.isArray(result?.warnings) ? result.warnings : []), ...warnings] };
+}
+
+function isFindingsResult(result: any): result is FindingsOutcome {
+  return typeof result?.success === 'boolean' && Array.isArray(result.errors) && Array.isArray(result.warnings) && Array.isArray(result.diagnostics);
+}
+
+/**
+ * Create an MCP server with all tools and prompts registered.
+ * Each transport connection needs its own server instance; over HTTP it
+ * serves one client, whose rate limits and quotas apply to its calls.
+ */
+export function createServer(version: string, options: { client?: ApiClient } = {}): Server {
+  const { client } = options;
+  const server = new Server(
+    {
+      name: 'code-feedback-mcp',
+      version,
+    },
+    {
+      capabilities: {
+        tools: {},
+        // resources: {},
+        prompts: {},
+        logging: {},
+      },
+    }
+  );
+
+  registerResources();
+  registerPrompts(server);
+
+  /**
+   * Error handler
+   */
+  server.onerror = (error) => {
+    logger.error('MCP server error', { error });
+  };
+
+  /**
+   * Watch runs are pushed to every connected client as log messages
+   */
+  const unsubscribe = watchManager.subscribe(run => {
+    server.notification({
+      method: 'notifications/message',
+      params: {
+        level: run.verdict.passed ? 'info' : 'warning',
+        logger: 'code-feedback/watch',
+        data: { watchId: run.watchId, runNumber: run.runNumber, trigger: run.trigger, changedFiles: run.changedFiles, verdict: run.verdict },
+      },
+    }).catch(error => logger.debug('Could not send watch notification', { error }));
+  });
+  server.onclose = unsubscribe;
+
+  /**
+   * Initialize handler
+   */
+  server.setRequestHandler(InitializeRequestSchema, async () => {
+    logger.info('Received initialize request');
+
+    return {
+      protocolVersion: '2024-11-05',
+      capabilities: {
+        tools: {},
+        // resources: {},
+        prompts: {},
+        logging: {},
+      },
+      serverInfo: {
+        name: 'code-feedback-mcp',
+        version,
+      },
+    };
+  });
+
+  /**
+   * List tools handler
+   */
+  server.setRequestHandler(ListToolsRequestSchema, async () => {
+    const { config } = await getEffectiveConfig();
+    // Tools the client's role can never call are not offered
+    const role = callerRole(config, client?.role);
+    const tools = allTools.filter(tool => isToolEnabled(config, tool.name) && canCall(config, role, tool));
+    logger.debug('Listing available tools', { count: tools.length });
+
+    return {
+      tools: tools.map(tool => ({
+        name: tool.name,
+        description: tool.description,
+        inputSchema: withOfflineArg(withOutputBudgetArg(withWorkspaceArg(tool.inputSchema))),
+      })),
+    };
+  });
+
+  /**
+   * Call tool handler
+   */
+  server.setRequestHandler(CallToolRequestSchema, async (request, extra) => {
+    const { name, arguments: args } = request.params;
+    const progressToken = request.params._meta?.progressToken;
+
+    // Correlation id echoed in the result and attached to every log line and the audit entry; a client can pass its own
+    const clientRequestId = request.params._meta?.requestId;
+    const requestId = typeof clientRequestId === 'string' && clientRequestId ? clientRequestId : randomUUID();
+
+    // One span per call, continuing the client's trace when it sends a W3C traceparent
+    const parent = parseTraceparent(request.params._meta?.traceparent);
+    return tracer.withSpan(`tool ${name}`, { kind: 'server', parent, attributes: { 'mcp.tool.name': name, 'mcp.request_id': requestId } }, span => withLogContext({ requestId, traceId: span.context.traceId, tool: name, ...(client ? { client: client.name } : {}) }, async () => {
+      logger.info('Tool call');
+
+      // Find the tool
+      const tool = allTools.find(t => t.name === name);
+      if (!tool) {
+        throw new McpError(
+          ErrorCode.MethodNotFound,
+          `Tool "${name}" not found`
+        );
+      }
+
+      // Every call is audited, including ones rejected before the tool runs
+      const audit = auditLog.start(name, args || {}, requestId);
+      const call = metrics.start(name);
+      const startedAt = Date.now();
+      const finish = (status: AuditStatus, details: Parameters<typeof audit.finish>[1] = {}) => {
+        call.finish(status, details);
+        span.setAttributes({ 'mcp.tool.status': status, 'mcp.cached': details.cached, 'mcp.workspace': details.workspace ?? undefined, 'mcp.mutating': details.mutating });
+        if (status === 'error') span.setStatus('error', details.errors?.[0]);
+        logger.log(status === 'error' ? 'error' : 'info', 'Tool call finished', {
+          status,
+          durationMs: Date.now() - startedAt,
+          ...(details.cached ? { cached: true } : {}),
+          ...(status !== 'success' && details.errors ? { errors: details.errors.slice(0, 5) } : {}),
+        });
+        return audit.finish(status, details);
+      };
+      const reject = async (message: string, details: Record<string, unknown> = {}) => {
+        await finish('rejected', { errors: [message] });
+        return errorContent(message, requestId, details);
+      };
+
+      // Rate limits and quotas of the HTTP client, checked before any work is done
+      const violation = client ? quotaTracker.admit(client) : null;
+      if (violation) {
+        span.setAttributes({ 'mcp.client': violation.client, 'mcp.quota_exceeded': violation.limit });
+        return reject(describeViolation(violation), { quotaExceeded: violation });
+      }
+
+      // Cancellable by request id, with cancel_execution or the client's notifications/cancelled
+      const execution = executionRegistry.start(requestId, name, client?.name);
+      const onAbort = () => execution.cancel('Cancelled by the client');
+      extra.signal.addEventListener('abort', onAbort, { once: true });
+
+      try {
+        // The output budget and offline mode are the server's to apply, not arguments of the tool
+        const { max_output_bytes: maxOutputBytes, offline_mode: offlineArg, ...toolArgs } = args || {};
+        if (maxOutputBytes !== undefined && (!Number.isInteger(maxOutputBytes) || (maxOutputBytes as number) < MIN_OUTPUT_BYTES)) {
+          return reject(`max_output_bytes must be an integer of at least ${MIN_OUTPUT_BYTES}`);
+        }
+        if (offlineArg !== undefined && !OFFLINE_MODES.includes(offlineArg as OfflineMode)) {
+          return reject(`offline_mode must be one of ${OFFLINE_MODES.join(', ')}`);
+        }
+        const budget = (maxOutputBytes as number | undefined) ?? Config.getInstance().getMaxOutputBytes();
+        const fit = (value: unknown) => ('budgeted' in tool && tool.budgeted === false ? value : applyOutputBudget(value, budget));
+
+        // A workspace id stands in for absolute paths: resolve them against its root
+        let callArgs: Record<string, unknown> = toolArgs;
+        try {
+          callArgs = await workspaceRegistry.resolveArgs(callArgs, (tool.inputSchema as any).properties);
+        } catch (error) {
+          return reject(error instanceof Error ? error.message : String(error));
+        }
+        // In a monorepo, a Go, Node, Python or Rust tool runs in the sub-project it is meant for
+        const routeWarnings: string[] = [];
+        try {
+          const routed = await projectDetector.route(callArgs, tool);
+          callArgs = routed.args;
+          if (routed.project) {
+            routeWarnings.push(`Routed to project ${routed.project.name} (${routed.project.relativePath})`);
+            span.setAttributes({ 'mcp.project': routed.project.relativePath });
+          }
+        } catch (error) {
+          return reject(error instanceof Error ? error.message : String(error));
+        }
+
+        // Project config (.code-feedback.yaml) governing the path the call targets
+        const targetPath = getPathArg(callArgs);
+        const effective = await getEffectiveConfig(targetPath);
+        if (!isToolEnabled(effective.config, name)) {
+          return reject(`Tool "${name}" is disabled by ${effective.projectConfigPath || effective.globalConfigPath}`);
+        }
+        const role = callerRole(effective.config, client?.role);
+        const denied = checkPermission(effective.config, role, tool, callArgs);
+        if (denied) {
+          return reject(denied);
+        }
+        if (targetPath && isExcluded(effective.config, effective.workspaceRoot, targetPath)) {
+          return reject(`Path excluded by ${effective.projectConfigPath}: ${targetPath}`);
+        }
+        const timeout = getToolTimeout(effective.config, name);
+        if (timeout !== undefined && callArgs.timeout === undefined && (tool.inputSchema as any).properties?.timeout) {
+          callArgs = { ...callArgs, timeout };
+        }
+        // Server-wide dry run: tools that can preview do so, other writes are refused
+        if (Config.getInstance().isDryRun()) {
+          if ((tool.inputSchema as any).properties?.dryRun) {
+            callArgs = { ...callArgs, dryRun: true };
+          } else if (isMutatingCall(tool, callArgs)) {
+            return reject(`Dry run mode is on (MCP_DRY_RUN); ${name} cannot preview its changes`);
+          }
+        }
+        const workspace = targetPath ? await resolveWorkspace(targetPath, effective.workspaceRoot) : null;
+        // The workspace's config picks where its commands run: the host, docker, or a remote build host
+        let remote: SshTarget | null;
+        try {
+          remote = resolveSshTarget(effective.config, workspace);
+        } catch (error) {
+          return reject(error instanceof Error ? error.message : String(error));
+        }
+        const executor = effective.config.executor === 'local' || effective.config.executor === 'docker' ? effective.config.executor : undefined;
+        const local = !remote && (executor ?? Config.getInstance().getExecutor()) === 'local';
+        // Tools listed under services.tools get the workspace's services for the call, started first if need be
+        const servicesRoot = effective.config.services?.tools?.includes(name) ? effective.workspaceRoot ?? workspace : null;
+        if (servicesRoot) {
+          // Starting them runs the workspace's images and compose file on this host's docker daemon, as test_services does
+          const refused = checkPermission(effective.config, role, SERVICES_CALL, { action: 'start' })
+            ?? servicesRefusal(effective.config.services!, remote?.name ?? executor ?? Config.getInstance().getExecutor());
+          if (refused) {
+            return reject(`${name} starts the test services: ${refused}`);
+          }
+        }
+        // Offline: MCP_OFFLINE, the offline setting and offline_mode each can only make it stricter
+        const offline = strictestOffline(
+          strictestOffline(Config.getInstance().getOfflineMode(), effective.config.offline),
+          offlineArg as OfflineMode | undefined
+        );
+        // Pinned toolchains (go.mod, .nvmrc, .python-version) are picked on the host; images and remote hosts pin their own
+        const configuredToolchains = effective.config.toolchains ?? Config.getInstance().getToolchainMode();
+        // Offline, only toolchains already installed are used
+        const toolchainMode = offline !== 'off' && configuredToolchains === 'install' ? 'auto' : configuredToolchains;
+        // .env files, config env and secrets; secret values never appear in what the call returns
+        const workspaceEnv = await resolveWorkspaceEnv(effective.config, workspace ?? effective.workspaceRoot);
+        const offlineWarnings: string[] = [];
+        let sandbox: NetworkSandbox | null = null;
+        let projectEnv = workspaceEnv.env;
+        if (offline !== 'off') {
+          projectEnv = { ...projectEnv, ...(await offlineEnv(workspace ?? effective.workspaceRoot, projectEnv.GOFLAGS ?? process.env.GOFLAGS)) };
+        }
+        if (offline === 'strict') {
+          // Detected out here: the probe is itself a command and must not run under the call's defaults
+          sandbox = local ? await detectNetworkSandbox() : null;
+          if (remote) {
+            offlineWarnings.push(`Commands run on ${remote.host}, whose network strict offline mode cannot block; package managers are still kept offline`);
+          } else if (local && !sandbox) {
+            projectEnv = { ...projectEnv, ...denyProxyEnv() };
+            offlineWarnings.push('No network sandbox (unshare or sandbox-exec) is available; network access is denied through proxy settings only, which programs ignoring HTTP_PROXY bypass');
+          }
+        }
+        const toolchains = targetPath && toolchainMode !== 'off' && local
+          ? await selectToolchains(targetPath, toolchainMode, projectEnv).catch(error => ({
+            env: {},
+            warnings: [`Toolchain selection failed: ${error instanceof Error ? error.message : String(error)}`],
+          }))
+          : null;
+        // Services test_services started stay up for every call in their workspace
+        const serviceEnv = targetPath ? serviceManager.envFor(targetPath) : undefined;
+        const commandEnv = { ...projectEnv, ...serviceEnv, ...toolchains?.env };
+        const hasSecrets = Object.keys(workspaceEnv.secrets).length > 0;
+        const mutating = isMutatingCall(tool, callArgs);
+        const limitEvents: LimitEvent[] = [];
+        const retryEvents: RetryEvent[] = [];
+        const offlineViolations: OfflineViolation[] = [];
+        // Files a mutating call changes are snapshotted first so revert_to_snapshot can undo it
+        // Tools that call other tools check them against the caller's role
+        const execute = () => withRole(role, () => mutating
+          ? snapshotStore.capture({ tool: name, workspace }, () => tool.run(callArgs))
+          : tool.run(callArgs));
+        const runWithDefaults = (env: Record<string, string>) => withCommandDefaults(
+          {
+            env,
+            ...(timeout !== undefined ? { timeout } : {}),
+            ...(effective.config.limits ? { limits: effective.config.limits } : {}),
+            onLimitExceeded: event => limitEvents.push(event),
+            ...(effective.config.retry ? { retry: effective.config.retry } : {}),
+            onRetry: event => retryEvents.push(event),
+            ...(client?.limits.cpuSecondsPerHour ? { onCpuTime: (seconds: number) => quotaTracker.recordCpu(client, seconds) } : {}),
+            signal: execution.signal,
+            ...(executor ? { executor } : {}),
+            ...(remote ? { remote } : {}),
+            ...(workspaceEnv.passEnv ? { passEnv: workspaceEnv.passEnv } : {}),
+            ...(hasSecrets ? { secrets: workspaceEnv.secrets } : {}),
+            ...(offline !== 'off' ? { offline: { mode: offline, sandbox }, onOfflineViolation: (violation: OfflineViolation) => offlineViolations.push(violation) } : {}),
+          },
+          () => execution.run(() => audit.run(execute))
+        );
+        const runTool = async () => {
+          let lease: ServiceLease | undefined;
+          if (servicesRoot) {
+            try {
+              lease = await serviceManager.acquire(servicesRoot, effective.config.services!);
+            } catch (error) {
+              return { success: false, errors: [`Test services did not start: ${error instanceof Error ? error.message : String(error)}`], warnings: [], output: '' };
+            }
+          }
+          try {
+            return await runWithDefaults(lease ? { ...commandEnv, ...lease.session.env } : commandEnv);
+          } finally {
+            await lease?.release();
+          }
+        };
+
+        // Serve repeated identical requests from the cache while the inputs are unchanged; calls that write always run
+        const cacheKey = isCacheableCall(tool as any, callArgs) && !mutating && Config.getInstance().isCacheEnabled()
+          ? await resultCache.computeKey(name, callArgs, hasSecrets ? { ...commandEnv, ...hashSecrets(workspaceEnv.secrets) } : commandEnv)
+          : null;
+        const cached = cacheKey ? resultCache.get(cacheKey) : undefined;
+        if (cached !== undefined) {
+          await finish((cached as any)?.success === false ? 'failure' : 'success', { workspace, mutating, cached: true });
+          return {
+            content: [
+              {
+                type: 'text',
+                text: JSON.stringify({ ...(fit(cached) as object), cached: true, requestId }, null, 2),
+              },
+            ],
+          };
+        }
+
+        // Execute the tool, streaming output when the client asked for progress
+        // Concurrent calls share the worker pool; edits get their workspace to themselves
+        // Interactive calls get free workers first; the client hears its queue position while it waits
+        const queuedAt = Date.now();
+        const priority = callPriority(request.params._meta, client);
+        const progress = progressToken !== undefined ? createProgressReporter(progressToken, extra.sendNotification) : undefined;
+        const startTool = () => {
+          span.setAttributes({ 'mcp.scheduler.wait_ms': Date.now() - queuedAt, 'mcp.scheduler.priority': priority });
+          // Cancelled while queued: nothing ran
+          if (execution.cancelled) return Promise.resolve({ success: false, errors: [], warnings: [], output: '' });
+          // Output is always collected, so a cancelled call can return what it got to
+          return withStreamHandler(execution.streamHandler(progress?.output), runTool);
+        };
+        let toolResult: any;
+        try {
+          toolResult = 'scheduled' in tool && tool.scheduled === false
+            ? await execution.run(startTool)
+            : await scheduler.run(workspace, mutating, startTool, { priority, ...(progress ? { onQueued: progress.queued } : {}) });
+        } catch (error) {
+          // Backpressure: refused up front rather than left to wait behind a full queue
+          if (error instanceof QueueFullError) return reject(error.message, { queued: error.queued });
+          throw error;
+        }
+        if (execution.cancelled) {
+          const result = withCancellation(hasSecrets ? redactResult(toolResult, workspaceEnv.secrets) : toolResult, execution);
+          await finish('cancelled', { errors: result.errors.map(String), workspace, mutating });
+          return {
+            content: [
+              {
+                type: 'text',
+                text: JSON.stringify({ ...(fit(result) as object), requestId }, null, 2),
+              },
+            ],
+          };
+        }
+        // A run cut short by a limit, by flaky infrastructure or by offline mode says nothing reliable about the code, so it is never cached
+        const retried = retryEvents.length > 0 ? withRetries(toolResult, retryEvents) : toolResult;
+        const limited = limitEvents.length > 0 ? withLimitErrors(retried, limitEvents) : retried;
+        const isolated = offlineViolations.length > 0 ? withOfflineViolations(limited, offlineViolations) : limited;
+        const callWarnings = [...routeWarnings, ...workspaceEnv.warnings, ...offlineWarnings, ...(toolchains?.warnings ?? [])];
+        // Tools that read files or format errors themselves can still echo a secret; runCommand only covers command output
+        const redacted = hasSecrets ? redactResult(isolated, workspaceEnv.secrets) : isolated;
+        const warned = callWarnings.length > 0 ? withWarnings(redacted, callWarnings) : redacted;
+        // Inline suppression comments silence findings; run_pipeline already applied them step by step
+        const suppressions = suppressionOptions(effective.config);
+        const result = suppressions && isFindingsResult(warned) && !('suppressions' in warned)
+          ? await applySuppressions(warned, { root: workspace ?? process.cwd(), ...suppressions })
+          : warned;
+        if (cacheKey && limitEvents.length === 0 && offlineViolations.length === 0 && !retryEvents.some(e => e.exhausted)) {
+          resultCache.set(cacheKey, result);
+        }
+        const outcome = result as { success?: boolean; errors?: unknown };
+        await finish(outcome?.success === false ? 'failure' : 'success', {
+          ...(Array.isArray(outcome?.errors) ? { errors: outcome.errors.map(String) } : {}),
+          workspace,
+          mutating,
+          limitExceeded: limitEvents.map(e => e.limit),
+        });
+
+        // Return the result in MCP format
+        return {
+          content: [
+            {
+              type: 'text',
+              text: JSON.stringify({ ...(fit(result) as object), requestId }, null, 2),
+            },
+          ],
+        };
+      } catch (error) {
+        const errorMessage = error instanceof Error ? error.message : String(error);
+        await finish('error', { errors: [errorMessage] });
+        throw new McpError(
+          ErrorCode.InternalError,
+          `Tool execution failed (request ${requestId}): ${errorMessage}`
+        );
+      } finally {
+        extra.signal.removeEventListener('abort', onAbort);
+        executionRegistry.finish(execution);
+      }
+    }));
+  });
+
+  return server;
+}
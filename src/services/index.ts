@@ -0,0 +1,470 @@
+import { createHash } from 'crypto';
+import { spawnSync } from 'child_process';
+import { connect } from 'net';
+import { resolve } from 'path';
+import { isWithin } from '../config/index.js';
+import type { SERVICE_KINDS, ServicesConfig } from '../config/project.js';
+import { commandExists, runCommand } from '../utils/command.js';
+import { logger } from '../utils/logger.js';
+import { shellQuote } from '../utils/shell.js';
+
+export type ServiceKind = typeof SERVICE_KINDS[number];
+
+interface Preset {
+    image: string;
+    port: number;
+    environment: Record<string, string>;
+    // A shell command run in the container, or a path fetched over HTTP from the host
+    ready: { exec: string } | { http: string };
+    url(address: string, environment: Record<string, string>): string;
+    // The variables client libraries read by convention
+    env(host: string, port: string, url: string, environment: Record<string, string>): Record<string, string>;
+}
+
+const PRESETS: Record<ServiceKind, Preset> = {
+    postgres: {
+        image: 'postgres:16-alpine',
+        port: 5432,
+        environment: { POSTGRES_USER: 'postgres', POSTGRES_PASSWORD: 'postgres', POSTGRES_DB: 'test' },
+        // Over TCP, so the temporary server the image runs during initialization never counts as ready
+        ready: { exec: 'pg_isready -h 127.0.0.1 -U "$POSTGRES_USER" -d "$POSTGRES_DB"' },
+        url: (address, e) => `postgres://${encodeURIComponent(e.POSTGRES_USER!)}:${encodeURIComponent(e.POSTGRES_PASSWORD!)}@${address}/${e.POSTGRES_DB}?sslmode=disable`,
+        env: (host, port, url, e) => ({ DATABASE_URL: url, PGHOST: host, PGPORT: port, PGUSER: e.POSTGRES_USER!, PGPASSWORD: e.POSTGRES_PASSWORD!, PGDATABASE: e.POSTGRES_DB! }),
+    },
+    redis: {
+        image: 'redis:7-alpine',
+        port: 6379,
+        environment: {},
+        ready: { exec: 'redis-cli ping | grep -q PONG' },
+        url: address => `redis://${address}/0`,
+        env: (_host, _port, url) => ({ REDIS_URL: url }),
+    },
+    localstack: {
+        image: 'localstack/localstack:3',
+        port: 4566,
+        environment: {},
+        ready: { http: '/_localstack/health' },
+        url: address => `http://${address}`,
+        env: (_host, _port, url) => ({ AWS_ENDPOINT_URL: url, AWS_ACCESS_KEY_ID: 'test', AWS_SECRET_ACCESS_KEY: 'test', AWS_REGION: 'us-east-1', AWS_DEFAULT_REGION: 'us-east-1' }),
+    },
+};
+
+const DEFAULT_START_TIMEOUT = 120000;
+const DEFAULT_IDLE_MINUTES = 60;
+const POLL_MS = 1000;
+// Containers carry the server's pid, so ones a crashed server left behind can be found
+const PID_LABEL = 'code-feedback.pid';
+
+export interface ServiceSpec {
+    name: string;
+    kind: ServiceKind | null;
+    image: string;
+    port: number;
+    environment: Record<string, string>;
+    ready: { exec: string } | { http: string } | null;
+    env: Record<string, string>;
+    startTimeout: number;
+}
+
+export interface RunningService {
+    name: string;
+    kind: ServiceKind | null;
+    backend: 'container' | 'compose';
+    image?: string;
+    container?: string;
+    // host:port on this machine
+    address?: string;
+    url?: string;
+    readyMs: number;
+}
+
+/**
+ * The containers a services config declares, with preset defaults filled in;
+ * a service whose name is a preset (postgres, redis, localstack) is of that kind
+ */
+export function resolveServiceSpecs(config: ServicesConfig): ServiceSpec[] {
+    return Object.entries(config.containers ?? {}).map(([name, service]) => {
+        const kind = service.kind ?? (name in PRESETS ? name as ServiceKind : null);
+        const preset = kind ? PRESETS[kind] : undefined;
+        const image = service.image ?? preset?.image;
+        const port = service.port ?? preset?.port;
+        if (!image || !port) throw new Error(`Service ${name} needs an image and a port, or a kind (${Object.keys(PRESETS).join(', ')})`);
+        return {
+            name,
+            kind,
+            image,
+            port,
+            environment: { ...preset?.environment, ...service.environment },
+            ready: service.healthcheck ? { exec: service.healthcheck } : preset?.ready ?? null,
+            env: service.env ?? {},
+            startTimeout: service.startTimeout ?? DEFAULT_START_TIMEOUT,
+        };
+    });
+}
+
+function envPrefix(name: string): string {
+    return name.toUpperCase().replace(/[^A-Z0-9]+/g, '_');
+}
+
+/**
+ * What the tests get for a service published at address: NAME_HOST, NAME_PORT
+ * and NAME_URL, the preset's conventional variables (DATABASE_URL, REDIS_URL,
+ * AWS_ENDPOINT_URL, ...) when conventional is set, and the configured env
+ * with ${host}, ${port} and ${url} filled in
+ */
+export function serviceEnv(spec: ServiceSpec, address: string, conventional: boolean): Record<string, string> {
+    const separator = address.lastIndexOf(':');
+    const host = address.slice(0, separator);
+    const port = address.slice(separator + 1);
+    const preset = spec.kind ? PRESETS[spec.kind] : undefined;
+    const url = preset ? preset.url(address, spec.environment) : address;
+    const prefix = envPrefix(spec.name);
+    const expand = (template: string) => template.replace(/\$\{(host|port|url)\}/g, (_, key: string) => ({ host, port, url } as Record<string, string>)[key]!);
+    return {
+        [`${prefix}_HOST`]: host,
+        [`${prefix}_PORT`]: port,
+        [`${prefix}_URL`]: url,
+        ...(preset && conventional ? preset.env(host, port, url, spec.environment) : {}),
+        ...Object.fromEntries(Object.entries(spec.env).map(([key, value]) => [key, expand(value)])),
+    };
+}
+
+/**
+ * The host address a published port is reachable on, from `docker port` or
+ * `docker compose port` output; wildcard binds become the loopback address
+ */
+export function parsePublishedAddress(output: string): string | null {
+    const match = /^(.*):(\d+)\s*$/m.exec(output.trim().split('\n')[0] ?? '');
+    if (!match) return null;
+    const host = match[1]!.replace(/^\[|\]$/g, '');
+    return `${host === '0.0.0.0' || host === '::' || host === '' ? '127.0.0.1' : host}:${match[2]}`;
+}
+
+async function docker(command: string, timeout = 60000): Promise<{ exitCode: number; stdout: string; stderr: string }> {
+    // Services run next to the server whatever executor the workspace's commands use
+    return runCommand(command, { local: true, timeout });
+}
+
+function tcpOpen(address: string): Promise<boolean> {
+    const separator = address.lastIndexOf(':');
+    return new Promise(done => {
+        const socket = connect(Number(address.slice(separator + 1)), address.slice(0, separator));
+        const finish = (open: boolean) => { socket.destroy(); done(open); };
+        socket.setTimeout(2000, () => finish(false));
+        socket.once('connect', () => finish(true));
+        socket.once('error', () => finish(false));
+    });
+}
+
+async function probe(spec: ServiceSpec, container: string, address: string): Promise<boolean> {
+    if (spec.ready && 'exec' in spec.ready) {
+        return (await docker(`docker exec ${shellQuote(container)} sh -c ${shellQuote(spec.ready.exec)}`, 15000)).exitCode === 0;
+    }
+    if (spec.ready) {
+        return fetch(`http://${address}${spec.ready.http}`, { signal: AbortSignal.timeout(5000) }).then(response => response.ok, () => false);
+    }
+    // No check configured: the image's HEALTHCHECK, else the port accepting connections
+    const health = await docker(`docker inspect -f '{{if .State.Health}}{{.State.Health.Status}}{{end}}' ${shellQuote(container)}`, 15000);
+    const status = health.stdout.trim();
+    return status ? status === 'healthy' : tcpOpen(address);
+}
+
+async function waitReady(spec: ServiceSpec, container: string, address: string): Promise<void> {
+    const deadline = Date.now() + spec.startTimeout;
+    while (true) {
+        const running = await docker(`docker inspect -f '{{.State.Running}}' ${shellQuote(container)}`, 15000);
+        if (running.stdout.trim() === 'false') {
+            const logs = await docker(`docker logs --tail 20 ${shellQuote(container)}`, 15000);
+            throw new Error(`Service ${spec.name} (${spec.image}) exited while starting:\n${`${logs.stdout}${logs.stderr}`.trim()}`);
+        }
+        if (await probe(spec, container, address)) return;
+        if (Date.now() > deadline) throw new Error(`Service ${spec.name} (${spec.image}) was not ready within ${spec.startTimeout} ms`);
+        await new Promise(r => setTimeout(r, POLL_MS));
+    }
+}
+
+let composeCommand: Promise<string | null> | undefined;
+
+// Compose v2 as a docker plugin, else the standalone docker-compose
+function detectCompose(): Promise<string | null> {
+    composeCommand ??= (async () => {
+        if ((await docker('docker compose version', 15000).catch(() => null))?.exitCode === 0) return 'docker compose';
+        return await commandExists('docker-compose') ? 'docker-compose' : null;
+    })();
+    return composeCommand;
+}
+
+let staleRemoved: Promise<void> | undefined;
+
+// Containers labelled with the pid of a server that is no longer running
+function removeStaleContainers(): Promise<void> {
+    staleRemoved ??= (async () => {
+        const listed = await docker(`docker ps -a --filter label=${PID_LABEL} --format '{{.ID}} {{.Label "${PID_LABEL}"}}'`, 15000).catch(() => null);
+        const stale = (listed?.exitCode === 0 ? listed.stdout.split('\n') : [])
+            .map(line => line.trim().split(' '))
+            .filter(([id, pid]) => id && pid && !isAlive(Number(pid)))
+            .map(([id]) => id!);
+        if (stale.length > 0) {
+            logger.info('Removing service containers left by a stopped server', { containers: stale });
+            await docker(`docker rm -f ${stale.map(shellQuote).join(' ')}`).catch(() => undefined);
+        }
+    })();
+    return staleRemoved;
+}
+
+function isAlive(pid: number): boolean {
+    try {
+        process.kill(pid, 0);
+        return true;
+    } catch (error: any) {
+        return error?.code === 'EPERM';
+    }
+}
+
+/**
+ * The services of one workspace: containers started with random loopback
+ * ports and a compose project, all ready, and the variables that reach them
+ */
+export class ServiceSession {
+    public readonly root: string;
+    public readonly config: ServicesConfig;
+    public readonly configHash: string;
+    public readonly project: string;
+    public services: RunningService[] = [];
+    public env: Record<string, string> = {};
+    public startedAt = '';
+    // Calls currently using the services
+    public users = 0;
+    // Started with test_services: stays up between calls until stopped or idle
+    public keep = false;
+    public lastUsed = Date.now();
+    private containers: string[] = [];
+    private compose: string | undefined;
+
+    constructor(root: string, config: ServicesConfig) {
+        this.root = root;
+        this.config = config;
+        this.configHash = createHash('sha256').update(JSON.stringify(config)).digest('hex').slice(0, 12);
+        this.project = `cf-${createHash('sha256').update(root).digest('hex').slice(0, 10)}`;
+    }
+
+    public async start(): Promise<void> {
+        const specs = resolveServiceSpecs(this.config);
+        if (specs.length === 0 && !this.config.compose) throw new Error('No services are declared under services in .code-feedback.yaml');
+        if (!await commandExists('docker')) throw new Error('docker is not available to start the services');
+        await removeStaleContainers();
+        this.startedAt = new Date().toISOString();
+        try {
+            const started = Date.now();
+            const [containers, composed] = await Promise.all([
+                Promise.all(specs.map(spec => this.startContainer(spec))),
+                this.config.compose ? this.startCompose(this.config.compose) : Promise.resolve({ services: [], env: {} }),
+            ]);
+            // The first service of each kind gets the conventional variables
+            const seen = new Set<ServiceKind>();
+            for (const { spec, service } of containers) {
+                const conventional = spec.kind !== null && !seen.has(spec.kind);
+                if (spec.kind) seen.add(spec.kind);
+                Object.assign(this.env, serviceEnv(spec, service.address!, conventional));
+                this.services.push(service);
+            }
+            this.services.push(...composed.services);
+            Object.assign(this.env, composed.env);
+            logger.info('Test services ready', { root: this.root, services: this.services.map(s => s.name), durationMs: Date.now() - started });
+        } catch (error) {
+            await this.stop();
+            throw error;
+        }
+    }
+
+    private async startContainer(spec: ServiceSpec): Promise<{ spec: ServiceSpec; service: RunningService }> {
+        const started = Date.now();
+        const labels = `--label code-feedback.project=${this.project} --label code-feedback.service=${shellQuote(spec.name)} --label ${PID_LABEL}=${process.pid}`;
+        const environment = Object.entries(spec.environment).map(([key, value]) => `-e ${shellQuote(`${key}=${value}`)}`).join(' ');
+        const run = await docker(`docker run -d ${labels} -p 127.0.0.1::${spec.port} ${environment} ${shellQuote(spec.image)}`, Math.max(spec.startTimeout, 60000));
+        if (run.exitCode !== 0) throw new Error(`Could not start service ${spec.name} (${spec.image}): ${run.stderr.trim()}`);
+        const container = run.stdout.trim().split('\n').pop()!;
+        this.containers.push(container);
+        const port = await docker(`docker port ${shellQuote(container)} ${spec.port}/tcp`, 15000);
+        const address = parsePublishedAddress(port.stdout);
+        if (!address) throw new Error(`Could not tell where service ${spec.name} publishes port ${spec.port}: ${port.stderr.trim() || port.stdout.trim()}`);
+        await waitReady(spec, container, address);
+        const url = spec.kind ? PRESETS[spec.kind].url(address, spec.environment) : address;
+        return { spec, service: { name: spec.name, kind: spec.kind, backend: 'container', image: spec.image, container: container.slice(0, 12), address, url, readyMs: Date.now() - started } };
+    }
+
+    private async startCompose(compose: NonNullable<ServicesConfig['compose']>): Promise<{ services: RunningService[]; env: Record<string, string> }> {
+        const started = Date.now();
+        const file = resolve(this.root, compose.file);
+        if (!isWithin(this.root, file)) throw new Error(`Compose file ${compose.file} is outside the workspace`);
+        const binary = await detectCompose();
+        if (!binary) throw new Error('docker compose is not available to start the services in the compose file');
+        this.compose = `${binary} -f ${shellQuote(file)} -p ${this.project}`;
+        // --wait returns once every service is running and every health check passes
+        const up = await runCommand(`${this.compose} up -d --wait ${(compose.services ?? []).map(shellQuote).join(' ')}`, { cwd: this.root, local: true, timeout: DEFAULT_START_TIMEOUT * 2 });
+        if (up.exitCode !== 0) throw new Error(`docker compose up failed: ${(up.stderr || up.stdout).trim().split('\n').slice(-10).join('\n')}`);
+        const env: Record<string, string> = {};
+        const addresses = new Map<string, string>();
+        for (const [key, template] of Object.entries(compose.env ?? {})) {
+            let value = template;
+            for (const match of template.matchAll(/\$\{([\w.-]+):(\d+)\}/g)) {
+                const placeholder = match[0];
+                if (!addresses.has(placeholder)) {
+                    const port = await runCommand(`${this.compose} port ${shellQuote(match[1]!)} ${match[2]}`, { cwd: this.root, local: true, timeout: 15000 });
+                    const address = parsePublishedAddress(port.stdout);
+                    if (!address) throw new Error(`Compose service ${match[1]} does not publish port ${match[2]}`);
+                    addresses.set(placeholder, address);
+                }
+                value = value.split(placeholder).join(addresses.get(placeholder)!);
+            }
+            env[key] = value;
+        }
+        const ps = await runCommand(`${this.compose} ps --services`, { cwd: this.root, local: true, timeout: 15000 });
+        const names = ps.stdout.split('\n').map(line => line.trim()).filter(Boolean);
+        return { services: names.map(name => ({ name, kind: null, backend: 'compose', readyMs: Date.now() - started })), env };
+    }
+
+    public async stop(): Promise<void> {
+        const containers = this.containers.splice(0);
+        const compose = this.compose;
+        this.compose = undefined;
+        await Promise.all([
+            containers.length > 0 ? docker(`docker rm -f -v ${containers.map(shellQuote).join(' ')}`).catch(() => undefined) : undefined,
+            compose ? runCommand(`${compose} down -v --remove-orphans`, { cwd: this.root, local: true, timeout: 120000 }).catch(() => undefined) : undefined,
+        ]);
+    }
+
+    // For the exit hook, which cannot wait for promises
+    public stopSync(): void {
+        if (this.containers.length > 0) spawnSync('docker', ['rm', '-f', '-v', ...this.containers], { stdio: 'ignore', timeout: 30000 });
+        if (this.compose) spawnSync(`${this.compose} down -v --remove-orphans`, { cwd: this.root, shell: true, stdio: 'ignore', timeout: 60000 });
+    }
+}
+
+export interface ServiceLease {
+    session: ServiceSession;
+    release(): Promise<void>;
+}
+
+/**
+ * The running test services of this process, one session per workspace.
+ * Calls for tools listed under services.tools lease them: the first call
+ * starts them and the last one to finish stops them, unless test_services
+ * started them, in which case they stay up until stopped or idle.
+ */
+/**
+ * Why a workspace's services may not start while its commands run on executor, or null when they may.
+ * Services always run on this host's docker daemon, which a workspace isolated in docker or on a build host
+ * must not reach unless the global config sets services.allowIsolated.
+ */
+export function servicesRefusal(config: ServicesConfig, executor: string): string | null {
+    if (executor === 'local' || config.allowIsolated) return null;
+    return `Test services run on this host's docker daemon and commands in this workspace run on ${executor}; set services.allowIsolated in the global config to allow it`;
+}
+
+export class ServiceManager {
+    private sessions = new Map<string, Promise<ServiceSession>>();
+    private ready = new Map<string, ServiceSession>();
+    private idleTimers = new Map<string, NodeJS.Timeout>();
+
+    constructor() {
+        process.once('exit', () => {
+            for (const session of this.ready.values()) session.stopSync();
+        });
+    }
+
+    public async start(root: string, config: ServicesConfig, options: { keep?: boolean } = {}): Promise<ServiceSession> {
+        const key = resolve(root);
+        let pending = this.sessions.get(key);
+        if (!pending) {
+            const session = new ServiceSession(key, config);
+            pending = session.start().then(() => {
+                this.ready.set(key, session);
+                return session;
+            });
+            this.sessions.set(key, pending);
+            pending.catch(() => this.sessions.delete(key));
+        }
+        const session = await pending;
+        if (options.keep) session.keep = true;
+        this.touch(session);
+        return session;
+    }
+
+    public async acquire(root: string, config: ServicesConfig): Promise<ServiceLease> {
+        const session = await this.start(root, config);
+        session.users++;
+        let released = false;
+        return {
+            session,
+            release: async () => {
+                if (released) return;
+                released = true;
+                session.users--;
+                this.touch(session);
+                if (session.users === 0 && !session.keep) await this.stop(session.root);
+            },
+        };
+    }
+
+    /**
+     * Variables of the running services whose workspace holds path, for
+     * calls that do not lease them; counts as use for the idle timeout
+     */
+    public envFor(path: string): Record<string, string> | undefined {
+        const abs = resolve(path);
+        const session = [...this.ready.values()].find(s => isWithin(s.root, abs));
+        if (!session) return undefined;
+        this.touch(session);
+        return session.env;
+    }
+
+    /**
+     * Stop a workspace's services now, or once the calls using them finish
+     */
+    public async stop(root: string): Promise<{ session: ServiceSession; deferred: boolean } | null> {
+        const key = resolve(root);
+        const session = this.ready.get(key);
+        if (!session) return null;
+        if (session.users > 0) {
+            session.keep = false;
+            return { session, deferred: true };
+        }
+        this.ready.delete(key);
+        this.sessions.delete(key);
+        clearTimeout(this.idleTimers.get(key));
+        this.idleTimers.delete(key);
+        await session.stop();
+        logger.info('Test services stopped', { root: key });
+        return { session, deferred: false };
+    }
+
+    public get(root: string): ServiceSession | undefined {
+        return this.ready.get(resolve(root));
+    }
+
+    public list(): ServiceSession[] {
+        return [...this.ready.values()];
+    }
+
+    public async stopAll(): Promise<void> {
+        for (const session of this.list()) {
+            session.users = 0;
+            await this.stop(session.root);
+        }
+    }
+
+    private touch(session: ServiceSession): void {
+        session.lastUsed = Date.now();
+        if (!session.keep) return;
+        clearTimeout(this.idleTimers.get(session.root));
+        const idleMs = (session.config.idleMinutes ?? DEFAULT_IDLE_MINUTES) * 60000;
+        this.idleTimers.set(session.root, setTimeout(() => {
+            if (session.users > 0 || !this.ready.has(session.root)) return;
+            logger.info('Stopping idle test services', { root: session.root });
+            void this.stop(session.root);
+        }, idleMs).unref());
+    }
+}
+
+export const serviceManager = new ServiceManager();
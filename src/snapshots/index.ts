@@ -0,0 +1,285 @@
+import { randomBytes } from 'crypto';
+import { promises as fs } from 'fs';
+import { homedir } from 'os';
+import { dirname, join, resolve } from 'path';
+import { AsyncLocalStorage } from 'async_hooks';
+import { sha256 } from '../audit/index.js';
+import { logger } from '../utils/logger.js';
+
+export interface SnapshotFile {
+    path: string;
+    // Content before the call, stored as a blob; absent when the file did not exist
+    sha256?: string;
+    bytes?: number;
+    // State right after the call ("directory", a content hash, or absent when missing),
+    // used to spot edits made outside tool calls before reverting
+    after?: string;
+}
+
+export interface Snapshot {
+    id: string;
+    timestamp: string;
+    tool: string;
+    workspace?: string;
+    files: SnapshotFile[];
+    // Files too large to keep; reverting leaves them as they are
+    skipped?: string[];
+}
+
+export interface RevertPlan {
+    // Snapshots undone, newest first
+    snapshots: string[];
+    // Path -> content to restore (null: the file did not exist)
+    files: Map<string, Buffer | null>;
+    // Files changed outside tool calls since the newest snapshot that touched them
+    conflicts: string[];
+    // Files whose pre-call content was not kept
+    skipped: string[];
+}
+
+// Larger files are not copied into a snapshot
+const MAX_FILE_BYTES = 10 * 1024 * 1024;
+// Captures of one directory delete; the rest are skipped
+const MAX_DIRECTORY_FILES = 2000;
+const DEFAULT_KEEP = 50;
+
+interface Capture {
+    files: Map<string, SnapshotFile>;
+    skipped: string[];
+}
+
+const captureContext = new AsyncLocalStorage<Capture>();
+// Blobs of captures not saved yet, which no snapshot refers to; pruning leaves them
+const heldBlobs = new Map<string, number>();
+
+function holdBlob(hash: string): void {
+    heldBlobs.set(hash, (heldBlobs.get(hash) ?? 0) + 1);
+}
+
+function releaseBlob(hash: string): void {
+    const count = (heldBlobs.get(hash) ?? 1) - 1;
+    if (count > 0) heldBlobs.set(hash, count);
+    else heldBlobs.delete(hash);
+}
+
+/**
+ * Location of snapshots: MCP_SNAPSHOTS_DIR or ~/.local/state/code-feedback/snapshots
+ */
+export function getSnapshotsDir(): string {
+    return process.env.MCP_SNAPSHOTS_DIR || join(homedir(), '.local', 'state', 'code-feedback', 'snapshots');
+}
+
+function getKeep(): number {
+    const keep = Number(process.env.MCP_SNAPSHOT_LIMIT);
+    return keep >= 1 ? Math.floor(keep) : DEFAULT_KEEP;
+}
+
+function blobPath(hash: string): string {
+    return join(getSnapshotsDir(), 'blobs', hash);
+}
+
+async function writeBlob(content: Buffer, hash: string): Promise<void> {
+    const path = blobPath(hash);
+    if (!(await fs.access(path).then(() => true, () => false))) {
+        await fs.mkdir(dirname(path), { recursive: true });
+        const partial = `${path}.${randomBytes(4).toString('hex')}.tmp`;
+        await fs.writeFile(partial, content, { mode: 0o600 });
+        await fs.rename(partial, path);
+    }
+}
+
+// "directory", the content hash, or undefined for a missing path
+async function currentState(path: string): Promise<string | undefined> {
+    try {
+        return sha256(await fs.readFile(path));
+    } catch (error: any) {
+        if (error.code === 'EISDIR') return 'directory';
+        if (error.code === 'ENOENT' || error.code === 'ENOTDIR') return undefined;
+        throw error;
+    }
+}
+
+async function captureInto(capture: Capture, path: string): Promise<void> {
+    if (capture.files.has(path) || capture.skipped.includes(path)) return;
+    let stats;
+    try {
+        stats = await fs.stat(path);
+    } catch (error: any) {
+        if (error.code !== 'ENOENT' && error.code !== 'ENOTDIR') throw error;
+        capture.files.set(path, { path });
+        return;
+    }
+    if (stats.isDirectory()) {
+        // Recorded as missing so reverting removes what was put there, then each file in it
+        capture.files.set(path, { path });
+        const pending = [path];
+        let count = 0;
+        while (pending.length > 0) {
+            const dir = pending.pop()!;
+            for (const entry of await fs.readdir(dir, { withFileTypes: true })) {
+                const full = join(dir, entry.name);
+                if (entry.isDirectory()) {
+                    pending.push(full);
+                } else if (entry.isFile()) {
+                    if (++count > MAX_DIRECTORY_FILES) capture.skipped.push(full);
+                    else await captureInto(capture, full);
+                }
+            }
+        }
+        return;
+    }
+    if (!stats.isFile()) return;
+    if (stats.size > MAX_FILE_BYTES) {
+        capture.skipped.push(path);
+        return;
+    }
+    const content = await fs.readFile(path);
+    const hash = sha256(content);
+    // Held before it is written: the blob may already exist, unreferenced, and a prune may be under way
+    holdBlob(hash);
+    try {
+        await writeBlob(content, hash);
+    } catch (error) {
+        releaseBlob(hash);
+        throw error;
+    }
+    capture.files.set(path, { path, sha256: hash, bytes: content.length });
+}
+
+/**
+ * Keep the current content of a path (a file, a directory's files, or the
+ * fact that it does not exist) before the running tool call changes it.
+ * The first capture of a path in a call wins; a no-op outside snapshotted calls.
+ */
+export async function captureBeforeChange(path: string): Promise<void> {
+    const capture = captureContext.getStore();
+    if (!capture) return;
+    try {
+        await captureInto(capture, resolve(path));
+    } catch (error) {
+        logger.warn('Could not snapshot file', { path, error });
+    }
+}
+
+/**
+ * Pre-call copies of every file a mutating tool call changed, so a change
+ * set can be reverted in one call. MCP_SNAPSHOTS=off disables it.
+ */
+export class SnapshotStore {
+    public isEnabled(): boolean {
+        return process.env.MCP_SNAPSHOTS !== 'off';
+    }
+
+    /**
+     * Run a tool call, saving a snapshot of the files it changed (if any)
+     * when it finishes, even if it throws
+     */
+    public async capture<T>(details: { tool: string; workspace?: string | null }, fn: () => Promise<T>): Promise<T> {
+        if (!this.isEnabled()) return fn();
+        const capture: Capture = { files: new Map(), skipped: [] };
+        try {
+            return await captureContext.run(capture, fn);
+        } finally {
+            if (capture.files.size > 0 || capture.skipped.length > 0) {
+                await this.save(details, capture).catch(error => logger.error('Failed to save snapshot', { error }));
+            }
+            for (const file of capture.files.values()) if (file.sha256) releaseBlob(file.sha256);
+        }
+    }
+
+    private async save(details: { tool: string; workspace?: string | null }, capture: Capture): Promise<Snapshot> {
+        const files: SnapshotFile[] = [];
+        for (const file of capture.files.values()) {
+            const after = await currentState(file.path);
+            files.push(after === undefined ? file : { ...file, after });
+        }
+        const now = new Date();
+        const snapshot: Snapshot = {
+            // Sorts by time
+            id: `${now.toISOString().replace(/[-:.]/g, '')}-${randomBytes(3).toString('hex')}`,
+            timestamp: now.toISOString(),
+            tool: details.tool,
+            ...(details.workspace ? { workspace: details.workspace } : {}),
+            files,
+            ...(capture.skipped.length > 0 ? { skipped: capture.skipped } : {}),
+        };
+        const dir = getSnapshotsDir();
+        await fs.mkdir(dir, { recursive: true });
+        await fs.writeFile(join(dir, `${snapshot.id}.json`), JSON.stringify(snapshot, null, 2) + '\n', { mode: 0o600 });
+        await this.prune();
+        return snapshot;
+    }
+
+    // Drop all but the newest snapshots, then the blobs nothing refers to or will
+    private async prune(): Promise<void> {
+        // Taken before listing: a capture saved in between is held until after its snapshot is written
+        const held = new Set(heldBlobs.keys());
+        const snapshots = await this.list();
+        const stale = snapshots.slice(getKeep());
+        if (stale.length === 0) return;
+        const dir = getSnapshotsDir();
+        for (const snapshot of stale) await fs.rm(join(dir, `${snapshot.id}.json`), { force: true });
+        const referenced = new Set(snapshots.slice(0, getKeep()).flatMap(s => s.files.flatMap(f => f.sha256 ? [f.sha256] : [])));
+        const blobs = await fs.readdir(join(dir, 'blobs')).catch(() => [] as string[]);
+        for (const blob of blobs) {
+            if (referenced.has(blob) || held.has(blob) || heldBlobs.has(blob)) continue;
+            await fs.rm(join(dir, 'blobs', blob), { force: true });
+        }
+    }
+
+    /**
+     * Saved snapshots, newest first
+     */
+    public async list(): Promise<Snapshot[]> {
+        const dir = getSnapshotsDir();
+        const names = await fs.readdir(dir).catch(() => [] as string[]);
+        const snapshots: Snapshot[] = [];
+        for (const name of names.filter(n => n.endsWith('.json')).sort().reverse()) {
+            try {
+                snapshots.push(JSON.parse(await fs.readFile(join(dir, name), 'utf-8')));
+            } catch {
+                // Partially written or removed by a concurrent prune
+            }
+        }
+        return snapshots;
+    }
+
+    /**
+     * File content a snapshot kept, by its hash; null once pruned
+     */
+    public async readBlob(hash: string): Promise<Buffer | null> {
+        return fs.readFile(blobPath(hash)).catch(() => null);
+    }
+
+    /**
+     * What reverting to the state before snapshot id takes: that call and
+     * every later one in the same workspace are undone, each file going back
+     * to its content before the oldest of them that touched it
+     */
+    public async planRevert(id: string): Promise<RevertPlan> {
+        const all = await this.list();
+        const target = all.find(s => s.id === id);
+        if (!target) throw new Error(`Unknown snapshot ${id}; use list_snapshots`);
+        // Other workspaces' edits are not part of this change set
+        const snapshots = all.filter(s => s.workspace === target.workspace);
+        const undone = snapshots.slice(0, snapshots.indexOf(target) + 1);
+        const earliest = new Map<string, SnapshotFile>();
+        const latest = new Map<string, SnapshotFile>();
+        const skipped = new Set<string>();
+        for (const snapshot of undone) {
+            for (const file of snapshot.files) {
+                earliest.set(file.path, file);
+                if (!latest.has(file.path)) latest.set(file.path, file);
+            }
+            snapshot.skipped?.forEach(path => skipped.add(path));
+        }
+        const plan: RevertPlan = { snapshots: undone.map(s => s.id), files: new Map(), conflicts: [], skipped: [...skipped] };
+        for (const [path, file] of earliest) {
+            if ((await currentState(path)) !== latest.get(path)?.after) plan.conflicts.push(path);
+            plan.files.set(path, file.sha256 ? await fs.readFile(blobPath(file.sha256)) : null);
+        }
+        return plan;
+    }
+}
+
+export const snapshotStore = new SnapshotStore();
@@ -0,0 +1,257 @@
+import { promises as fs } from 'fs';
+import { homedir } from 'os';
+import { delimiter, dirname, join, resolve } from 'path';
+import { type ToolchainMode } from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+
+export type ToolchainLanguage = 'go' | 'node' | 'python' | 'rust';
+
+export interface ToolchainPin {
+    language: ToolchainLanguage;
+    version: string;
+    file: string;
+    // go.mod's go directive is a minimum; every other pin names the version to use
+    minimum?: boolean;
+}
+
+export interface ToolchainSelection extends ToolchainPin {
+    // Version found on PATH, if any
+    current?: string;
+    // Version commands will run with; absent when the pin could not be met
+    selected?: string;
+    via?: 'path' | 'GOTOOLCHAIN' | 'nvm' | 'pyenv' | 'rustup';
+    // Downloaded for this call
+    installed?: boolean;
+}
+
+export interface ToolchainEnv {
+    env: Record<string, string>;
+    selections: ToolchainSelection[];
+    warnings: string[];
+}
+
+const INSTALL_TIMEOUT = 10 * 60 * 1000;
+// Version on PATH per binary and PATH value; running `node --version` on every call adds up
+const currentVersions = new Map<string, Promise<string | undefined>>();
+const goEnvs = new Map<string, Promise<Record<string, string> | null>>();
+
+function parseVersion(version: string): number[] {
+    return version.split('.').map(part => parseInt(part, 10) || 0);
+}
+
+export function compareVersions(a: string, b: string): number {
+    const left = parseVersion(a);
+    const right = parseVersion(b);
+    for (let i = 0; i < Math.max(left.length, right.length); i++) {
+        const diff = (left[i] ?? 0) - (right[i] ?? 0);
+        if (diff !== 0) return diff;
+    }
+    return 0;
+}
+
+/**
+ * True when version satisfies a pin: "20" takes any 20.x, "3.12" any 3.12.x
+ */
+export function matchesPin(version: string, pin: string): boolean {
+    const wanted = parseVersion(pin);
+    const actual = parseVersion(version);
+    return wanted.every((part, i) => actual[i] === part);
+}
+
+function numericVersion(text: string | undefined): string | undefined {
+    return text ? /^v?(\d+(?:\.\d+)*)$/.exec(text.trim())?.[1] : undefined;
+}
+
+async function readPinFile(startDir: string, names: string[]): Promise<{ file: string; content: string } | null> {
+    for (const name of names) {
+        const file = await findUp(startDir, name);
+        if (file) return { file, content: await fs.readFile(file, 'utf-8') };
+    }
+    return null;
+}
+
+/**
+ * Toolchain versions a project pins: go.mod (toolchain, else go directive), .nvmrc / .node-version,
+ * .python-version and rust-toolchain(.toml), falling back to asdf's .tool-versions
+ */
+export async function findToolchainPins(startDir: string): Promise<{ pins: ToolchainPin[]; warnings: string[] }> {
+    const pins: ToolchainPin[] = [];
+    const warnings: string[] = [];
+    const goMod = await readPinFile(startDir, ['go.mod']);
+    if (goMod) {
+        const toolchain = /^toolchain\s+go(\S+)/m.exec(goMod.content)?.[1];
+        const go = /^go\s+(\d+(?:\.\d+)*)/m.exec(goMod.content)?.[1];
+        if (toolchain) pins.push({ language: 'go', version: toolchain, file: goMod.file });
+        else if (go) pins.push({ language: 'go', version: go, file: goMod.file, minimum: true });
+    }
+    const node = await readPinFile(startDir, ['.nvmrc', '.node-version']);
+    if (node) {
+        const version = numericVersion(node.content.split('\n')[0]);
+        if (version) pins.push({ language: 'node', version, file: node.file });
+        else warnings.push(`${node.file}: only numeric versions are selected, not "${node.content.trim()}"`);
+    }
+    const python = await readPinFile(startDir, ['.python-version']);
+    if (python) {
+        // pyenv allows several versions, one per line; the first is the default
+        const version = numericVersion(python.content.split('\n').find(line => line.trim()));
+        if (version) pins.push({ language: 'python', version, file: python.file });
+        else warnings.push(`${python.file}: only numeric CPython versions are selected, not "${python.content.trim()}"`);
+    }
+    const rust = await readPinFile(startDir, ['rust-toolchain.toml', 'rust-toolchain']);
+    if (rust) {
+        const channel = /channel\s*=\s*"([^"]+)"/.exec(rust.content)?.[1] ?? rust.content.trim().split('\n')[0];
+        if (channel) pins.push({ language: 'rust', version: channel.trim(), file: rust.file });
+    }
+
+    const toolVersions = await readPinFile(startDir, ['.tool-versions']);
+    if (toolVersions) {
+        const names: Record<string, ToolchainLanguage> = { golang: 'go', nodejs: 'node', python: 'python', rust: 'rust' };
+        for (const line of toolVersions.content.split('\n')) {
+            const [tool, version] = line.trim().split(/\s+/);
+            const language = tool ? names[tool] : undefined;
+            const numeric = numericVersion(version);
+            if (!language || !numeric || pins.some(p => p.language === language)) continue;
+            pins.push({ language, version: numeric, file: toolVersions.file });
+        }
+    }
+    return { pins, warnings };
+}
+
+function currentVersion(binary: string, args: string, pattern: RegExp, env: Record<string, string>): Promise<string | undefined> {
+    const key = `${env.PATH ?? process.env.PATH}\0${binary}`;
+    let version = currentVersions.get(key);
+    if (!version) {
+        version = runCommand(`${binary} ${args}`, { env, timeout: 10000, local: true })
+            .then(result => result.exitCode === 0 ? pattern.exec(`${result.stdout}\n${result.stderr}`)?.[1] : undefined, () => undefined);
+        currentVersions.set(key, version);
+    }
+    return version;
+}
+
+// Newest installed version directory matching the pin, e.g. ~/.nvm/versions/node/v20.11.1
+async function findInstalled(dir: string, pin: string): Promise<{ path: string; version: string } | null> {
+    const entries = await fs.readdir(dir).catch(() => [] as string[]);
+    const matching = entries
+        .map(name => ({ name, version: numericVersion(name) }))
+        .filter((e): e is { name: string; version: string } => Boolean(e.version) && matchesPin(e.version!, pin))
+        .sort((a, b) => compareVersions(b.version, a.version));
+    return matching[0] ? { path: join(dir, matching[0].name), version: matching[0].version } : null;
+}
+
+async function exists(path: string): Promise<boolean> {
+    return fs.access(path).then(() => true, () => false);
+}
+
+// Toolchain names are go1.21.0 and later; a go directive may say just "1.22"
+function goToolchainName(version: string): string {
+    const parts = parseVersion(version);
+    return parts.length === 2 && compareVersions(version, '1.21') >= 0 ? `go${version}.0` : `go${version}`;
+}
+
+async function selectGo(pin: ToolchainPin, mode: ToolchainMode, env: Record<string, string>, result: ToolchainEnv): Promise<ToolchainSelection> {
+    const key = env.PATH ?? process.env.PATH ?? '';
+    if (!goEnvs.has(key)) {
+        // GOTOOLCHAIN=local so asking for the version does not itself switch toolchains
+        goEnvs.set(key, runCommand('go env -json GOVERSION GOMODCACHE GOOS GOARCH', { env: { ...env, GOTOOLCHAIN: 'local' }, timeout: 10000, local: true })
+            .then(r => r.exitCode === 0 ? JSON.parse(r.stdout) as Record<string, string> : null).catch(() => null));
+    }
+    const goEnv = await goEnvs.get(key);
+    const current = goEnv?.GOVERSION?.replace(/^go/, '').replace(/[^\d.].*$/, '');
+    const selection: ToolchainSelection = { ...pin, ...(current ? { current } : {}) };
+    if (current && (pin.minimum ? compareVersions(current, pin.version) >= 0 : matchesPin(current, pin.version))) {
+        return { ...selection, selected: current, via: 'path' };
+    }
+    if (!goEnv || !current) {
+        result.warnings.push(`${pin.file} wants go ${pin.version} but go is not on PATH`);
+        return selection;
+    }
+    if (compareVersions(current, '1.21') < 0) {
+        result.warnings.push(`${pin.file} wants go ${pin.version} but go ${current} is on PATH and cannot switch toolchains (needs go 1.21 or later)`);
+        return selection;
+    }
+    const name = goToolchainName(pin.version);
+    const downloaded = await exists(join(goEnv.GOMODCACHE ?? '', 'golang.org', `toolchain@v0.0.1-${name}.${goEnv.GOOS}-${goEnv.GOARCH}`));
+    if (!downloaded && mode !== 'install') {
+        result.warnings.push(`${pin.file} wants go ${pin.version} but go ${current} is on PATH and ${name} is not downloaded; set toolchains: install to fetch it`);
+        return selection;
+    }
+    // The go command fetches the toolchain into the module cache on first use
+    result.env.GOTOOLCHAIN = name;
+    return { ...selection, selected: name.replace(/^go/, ''), via: 'GOTOOLCHAIN', ...(downloaded ? {} : { installed: true }) };
+}
+
+async function selectManaged(
+    pin: ToolchainPin,
+    mode: ToolchainMode,
+    env: Record<string, string>,
+    result: ToolchainEnv,
+    manager: { via: 'nvm' | 'pyenv'; binary: string; pattern: RegExp; versionsDir: string; install: string | null },
+): Promise<ToolchainSelection> {
+    const current = await currentVersion(manager.binary, '--version', manager.pattern, env);
+    const selection: ToolchainSelection = { ...pin, ...(current ? { current } : {}) };
+    if (current && matchesPin(current, pin.version)) return { ...selection, selected: current, via: 'path' };
+
+    let installed = await findInstalled(manager.versionsDir, pin.version);
+    let fetched = false;
+    if (!installed && mode === 'install' && manager.install) {
+        const install = await runCommand(manager.install, { env, timeout: INSTALL_TIMEOUT, local: true }).catch(() => null);
+        if (install?.exitCode !== 0) result.warnings.push(`${manager.via} could not install ${pin.language} ${pin.version}: ${(install?.stderr || install?.stdout || '').trim()}`);
+        installed = await findInstalled(manager.versionsDir, pin.version);
+        fetched = installed !== null;
+    }
+    if (!installed) {
+        const hint = manager.install ? (mode === 'install' ? '' : '; set toolchains: install to fetch it') : ` and ${manager.via} is not installed`;
+        result.warnings.push(`${pin.file} wants ${pin.language} ${pin.version} but ${current ? `${current} is on PATH` : `${manager.binary} is not on PATH`}${hint}`);
+        return selection;
+    }
+    const base = result.env.PATH ?? env.PATH ?? process.env.PATH ?? '';
+    result.env.PATH = [join(installed.path, 'bin'), base].filter(Boolean).join(delimiter);
+    return { ...selection, selected: installed.version, via: manager.via, ...(fetched ? { installed: true } : {}) };
+}
+
+/**
+ * Environment that makes commands for targetPath run with the toolchains the
+ * project pins: GOTOOLCHAIN for Go, PATH entries from nvm and pyenv for Node
+ * and Python. rustup already honours rust-toolchain files, so Rust pins are
+ * only reported. env is the environment the call would otherwise use.
+ */
+export async function selectToolchains(targetPath: string, mode: ToolchainMode, env: Record<string, string> = {}): Promise<ToolchainEnv> {
+    const result: ToolchainEnv = { env: {}, selections: [], warnings: [] };
+    if (mode === 'off') return result;
+    let startDir = resolve(targetPath);
+    if (!(await fs.stat(startDir).then(s => s.isDirectory(), () => false))) startDir = dirname(startDir);
+    const { pins, warnings } = await findToolchainPins(startDir);
+    result.warnings.push(...warnings);
+
+    const nvmDir = env.NVM_DIR || process.env.NVM_DIR || join(homedir(), '.nvm');
+    const pyenvRoot = env.PYENV_ROOT || process.env.PYENV_ROOT || join(homedir(), '.pyenv');
+    for (const pin of pins) {
+        if (pin.language === 'go') {
+            result.selections.push(await selectGo(pin, mode, env, result));
+        } else if (pin.language === 'node') {
+            const nvmScript = join(nvmDir, 'nvm.sh');
+            result.selections.push(await selectManaged(pin, mode, env, result, {
+                via: 'nvm',
+                binary: 'node',
+                pattern: /v(\d+\.\d+\.\d+)/,
+                versionsDir: join(nvmDir, 'versions', 'node'),
+                // nvm is a shell function, so it has to be sourced first
+                install: await exists(nvmScript) ? `. ${shellQuote(nvmScript)} && nvm install ${shellQuote(pin.version)}` : null,
+            }));
+        } else if (pin.language === 'python') {
+            const pyenv = join(pyenvRoot, 'bin', 'pyenv');
+            result.selections.push(await selectManaged(pin, mode, env, result, {
+                via: 'pyenv',
+                binary: 'python3',
+                pattern: /Python (\d+\.\d+\.\d+)/,
+                versionsDir: join(pyenvRoot, 'versions'),
+                install: await exists(pyenv) ? `${shellQuote(pyenv)} install -s ${shellQuote(pin.version)}` : null,
+            }));
+        } else {
+            result.selections.push({ ...pin, selected: pin.version, via: 'rustup' });
+        }
+    }
+    return result;
+}
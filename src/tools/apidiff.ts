@@ -0,0 +1,143 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { dirname, join, relative, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { diffApi, nextVersion, type ApiEntry } from '../apidiff/index.js';
+import { runCommand } from '../utils/command.js';
+import { findUp } from '../utils/paths.js';
+import { shellQuote } from '../utils/shell.js';
+import { queryGoAst } from './goast.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Go module directory (or any directory inside it)'),
+    base: z.string().optional().describe('Git ref to compare against, such as v1.4.0 or main; default the latest v* tag'),
+    version: z.string().optional().describe('Published module version to compare against instead of a git ref, such as v1.4.0 or latest; fetched with go mod download'),
+    timeout: z.number().default(300000),
+});
+
+// The newest semver tag of the module; tags of a module in a subdirectory carry its path: api/v1.2.0
+async function latestTag(repoRoot: string, prefix: string, timeout: number): Promise<string | null> {
+    const pattern = prefix ? `${prefix}/v*` : 'v*';
+    const result = await runCommand(`git tag --list ${shellQuote(pattern)} --sort=-v:refname`, { cwd: repoRoot, timeout, local: true });
+    if (result.exitCode !== 0) return null;
+    return result.stdout.split('\n').map(t => t.trim()).find(t => /\/?v\d+\.\d+\.\d+$/.test(t)) ?? null;
+}
+
+export const apiDiffTool = {
+    name: 'api_diff',
+    binaries: ['go', 'git'],
+    description: 'Check a Go module for breaking API changes, as apidiff and gorelease do: the exported API of the workspace (functions, methods, types, struct fields, interface methods, variables and constants of every non-internal package) is compared with a base git ref (default the latest v* tag) or a published module version. Removed symbols, changed signatures and types, methods added to existing interfaces and methods moved to pointer receivers are breaking and fail the check, as error diagnostics; additions are listed as compatible. Suggests the next semantic version.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, base, version, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        if (base && version) {
+            return { success: false, errors: ['Pass base or version, not both'], warnings: [], output: '' };
+        }
+        let workDir: string | undefined;
+        try {
+            const goMod = await findUp(resolve(path), 'go.mod');
+            if (!goMod) return { success: false, errors: [`No go.mod found at or above ${path}`], warnings: [], output: '' };
+            const moduleRoot = dirname(goMod);
+            const modulePath = /^module\s+(\S+)/m.exec(await fs.readFile(goMod, 'utf-8'))?.[1];
+            if (!modulePath) return { success: false, errors: [`${goMod} declares no module path`], warnings: [], output: '' };
+            workDir = await fs.mkdtemp(join(tmpdir(), 'cf-apidiff-'));
+
+            let baseDir: string;
+            let label: string;
+            let current: string | null;
+            if (version) {
+                // Outside any module, so the workspace's go.mod and go.sum are left alone
+                const download = await runCommand(`go mod download -json ${shellQuote(`${modulePath}@${version}`)}`, { cwd: workDir, timeout, local: true, env: { GOFLAGS: '', GOWORK: 'off' } });
+                const info = (() => {
+                    try {
+                        return JSON.parse(download.stdout);
+                    } catch {
+                        return null;
+                    }
+                })();
+                if (download.exitCode !== 0 || !info?.Dir) {
+                    return { success: false, errors: [`Could not download ${modulePath}@${version}: ${info?.Error || download.stderr.trim() || download.stdout.trim()}`], warnings: [], output: '' };
+                }
+                baseDir = info.Dir;
+                current = info.Version ?? version;
+                label = `${modulePath}@${current}`;
+            } else {
+                const top = await runCommand('git rev-parse --show-toplevel', { cwd: moduleRoot, timeout, local: true });
+                if (top.exitCode !== 0) return { success: false, errors: [`Not a git repository: ${moduleRoot}; pass version to compare with a published release`], warnings: [], output: '' };
+                const repoRoot = top.stdout.trim();
+                const prefix = relative(repoRoot, moduleRoot).split('\\').join('/');
+                const ref = base ?? await latestTag(repoRoot, prefix, timeout);
+                if (!ref) return { success: false, errors: ['No v* tag to compare against; pass base (a git ref) or version'], warnings: [], output: '' };
+                baseDir = join(workDir, 'base');
+                await fs.mkdir(baseDir);
+                // The module's tree at ref, extracted without touching the checkout
+                const tarball = join(workDir, 'base.tar');
+                const archive = await runCommand(`git archive --format=tar -o ${shellQuote(tarball)} ${shellQuote(`${ref}:${prefix}`)}`, { cwd: repoRoot, timeout, local: true });
+                if (archive.exitCode !== 0) return { success: false, errors: [`Could not read ${ref}: ${archive.stderr.trim()}`], warnings: [], output: '' };
+                const extract = await runCommand(`tar -xf ${shellQuote(tarball)} -C ${shellQuote(baseDir)}`, { cwd: workDir, timeout, local: true });
+                if (extract.exitCode !== 0) throw new Error(`Extracting ${ref} failed: ${extract.stderr.trim()}`);
+                label = ref;
+                current = /v\d+\.\d+\.\d+.*$/.exec(ref)?.[0] ?? null;
+            }
+
+            const [before, after] = await Promise.all([
+                queryGoAst(baseDir, 'api', { timeout }),
+                queryGoAst(moduleRoot, 'api', { timeout }),
+            ]);
+            const warnings = (after.parseErrors ?? []).map(e => e.message);
+            const changes = diffApi(before.results as ApiEntry[], after.results as ApiEntry[], modulePath);
+            const breaking = changes.filter(c => c.breaking);
+            const compatible = changes.filter(c => !c.breaking);
+            const suggested = nextVersion(current, breaking.length > 0, compatible.length > 0);
+
+            const diagnostics: Diagnostic[] = breaking.map(c => ({
+                // Removed symbols are reported where they were, in the workspace's terms
+                file: c.file ? (c.file.startsWith(baseDir) ? join(moduleRoot, relative(baseDir, c.file)) : c.file) : goMod,
+                line: c.line ?? 1,
+                column: 0,
+                severity: 'error' as const,
+                message: c.message,
+                rule: `api-${c.change}`,
+                source: 'api_diff',
+            }));
+            const lines = [
+                `${breaking.length} breaking change(s) and ${compatible.length} compatible addition(s) since ${label}${suggested ? `; next version: ${suggested}` : ''}`,
+                ...breaking.map(c => `BREAKING ${c.message}`),
+                ...compatible.map(c => `+ ${c.message}`),
+            ];
+            // A breaking release of v2 and later needs the new major version in the module path
+            const major = suggested ? /^v(\d+)\.0\.0$/.exec(suggested)?.[1] : undefined;
+            if (breaking.length > 0 && major && Number(major) >= 2) lines.push(`A v${major} release needs the module path ${modulePath.replace(/\/v\d+$/, '')}/v${major}`);
+            return {
+                success: breaking.length === 0,
+                errors: breaking.length > 0 ? [`${breaking.length} breaking change(s) to the exported API since ${label}`] : [],
+                warnings,
+                output: lines.join('\n'),
+                base: label,
+                breaking,
+                compatible,
+                ...(suggested ? { suggestedVersion: suggested } : {}),
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            if (workDir) await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
@@ -0,0 +1,244 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, extname, join, relative, resolve, sep } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { findUp } from '../utils/paths.js';
+import { architectureRuleSchema, getEffectiveConfig, isExcluded, type ArchitectureRule } from '../config/project.js';
+import { matchesPackagePattern } from './depgraph.js';
+
+export type ImportLanguage = 'go' | 'python' | 'javascript';
+
+export interface SourceImport {
+    file: string;
+    line: number;
+    // The import as written: "example.com/app/api", "..models", "./api/client"
+    specifier: string;
+    // Importing package: the Go import path, or the file's directory relative to the root
+    from: string;
+    // Imported package: the same form for project code, the package name for dependencies
+    to: string;
+    local: boolean;
+}
+
+export interface ArchitectureViolation extends SourceImport {
+    rule: string;
+}
+
+const inputSchema = z.object({
+    path: z.string().describe('Project root to check'),
+    rules: z.array(architectureRuleSchema).default([]).describe('Rules to check in addition to `architecture` in the project config'),
+    languages: z.array(z.enum(['go', 'python', 'javascript'])).default(['go', 'python', 'javascript']),
+});
+
+const EXTENSIONS: Record<string, ImportLanguage> = {
+    '.go': 'go',
+    '.py': 'python',
+    '.js': 'javascript',
+    '.jsx': 'javascript',
+    '.mjs': 'javascript',
+    '.cjs': 'javascript',
+    '.ts': 'javascript',
+    '.tsx': 'javascript',
+    '.mts': 'javascript',
+    '.cts': 'javascript',
+};
+
+const SKIPPED_DIRS = new Set(['node_modules', '.git', 'vendor', 'testdata', 'dist', 'build', 'target', '.venv', 'venv', '__pycache__']);
+const MAX_FILES = 20000;
+
+function lineAt(text: string, offset: number): number {
+    let line = 1;
+    for (let i = 0; i < offset; i++) if (text.charCodeAt(i) === 10) line++;
+    return line;
+}
+
+function toPosix(path: string): string {
+    return path.split(sep).join('/') || '.';
+}
+
+/**
+ * Import specifiers in a source file with their line numbers. Regex based, so
+ * it needs no toolchain and build constraints are ignored: every import counts.
+ */
+export function extractImports(text: string, language: ImportLanguage): { specifier: string; line: number }[] {
+    const found: { specifier: string; line: number }[] = [];
+    const add = (specifier: string | undefined, offset: number) => {
+        if (specifier) found.push({ specifier, line: lineAt(text, offset) });
+    };
+    if (language === 'go') {
+        for (const block of text.matchAll(/^import\s*\(([\s\S]*?)^\)/gm)) {
+            const start = (block.index ?? 0) + block[0].indexOf('(') + 1;
+            for (const spec of (block[1] ?? '').matchAll(/^[ \t]*(?:[\w.]+[ \t]+)?"([^"]+)"/gm)) add(spec[1], start + (spec.index ?? 0));
+        }
+        for (const single of text.matchAll(/^import[ \t]+(?:[\w.]+[ \t]+)?"([^"]+)"/gm)) add(single[1], single.index ?? 0);
+    } else if (language === 'python') {
+        for (const match of text.matchAll(/^[ \t]*import[ \t]+([\w.]+(?:[ \t]+as[ \t]+\w+)?(?:[ \t]*,[ \t]*[\w.]+(?:[ \t]+as[ \t]+\w+)?)*)/gm)) {
+            for (const name of (match[1] ?? '').split(',')) add(name.trim().split(/\s+/)[0], match.index ?? 0);
+        }
+        for (const match of text.matchAll(/^[ \t]*from[ \t]+(\.*[\w.]*)[ \t]+import\b/gm)) add(match[1], match.index ?? 0);
+    } else {
+        const patterns = [
+            /\b(?:import|export)\s[^'"`;]*?\bfrom\s*['"]([^'"]+)['"]/g,
+            /^\s*import\s*['"]([^'"]+)['"]/gm,
+            /\b(?:require|import)\s*\(\s*['"]([^'"]+)['"]\s*\)/g,
+        ];
+        for (const pattern of patterns) {
+            for (const match of text.matchAll(pattern)) add(match[1], (match.index ?? 0) + match[0].indexOf(match[1] ?? ''));
+        }
+    }
+    return found.sort((a, b) => a.line - b.line);
+}
+
+async function isDirectory(path: string): Promise<boolean> {
+    return fs.stat(path).then(s => s.isDirectory(), () => false);
+}
+
+async function exists(path: string): Promise<boolean> {
+    return fs.access(path).then(() => true, () => false);
+}
+
+// Package name of a bare JS specifier: "@scope/pkg/sub" -> "@scope/pkg", "lodash/fp" -> "lodash"
+function npmPackageName(specifier: string): string {
+    const parts = specifier.split('/');
+    return specifier.startsWith('@') ? parts.slice(0, 2).join('/') : parts[0] ?? specifier;
+}
+
+/**
+ * Resolve an import to the package it names. Project packages are
+ * directories relative to root (Go: import paths); anything else is a dependency.
+ */
+async function resolveImport(root: string, file: string, specifier: string, language: ImportLanguage, goModule: string | null): Promise<{ to: string; local: boolean }> {
+    if (language === 'go') {
+        const local = goModule !== null && (specifier === goModule || specifier.startsWith(`${goModule}/`));
+        return { to: specifier, local };
+    }
+    if (language === 'python') {
+        const dots = /^\.*/.exec(specifier)?.[0].length ?? 0;
+        const parts = specifier.slice(dots).split('.').filter(Boolean);
+        const bases = dots > 0
+            ? [[...Array(dots - 1)].reduce((dir: string) => dirname(dir), dirname(file))]
+            : [root, join(root, 'src')];
+        for (const base of bases) {
+            // A package directory, or a module whose package is its directory
+            const candidate = join(base, ...parts);
+            if (await isDirectory(candidate)) return { to: toPosix(relative(root, candidate)), local: true };
+            if (parts.length > 0 && await exists(`${candidate}.py`)) return { to: toPosix(relative(root, dirname(candidate))), local: true };
+        }
+        return { to: parts[0] ?? specifier, local: false };
+    }
+    if (specifier.startsWith('.') || specifier.startsWith('/')) {
+        const target = resolve(dirname(file), specifier);
+        const dir = await isDirectory(target) ? target : dirname(target);
+        return { to: toPosix(relative(root, dir)), local: true };
+    }
+    return { to: npmPackageName(specifier), local: false };
+}
+
+async function readGoModule(root: string): Promise<{ module: string; dir: string } | null> {
+    const modFile = await findUp(root, 'go.mod');
+    if (!modFile) return null;
+    const module = /^module\s+(\S+)/m.exec(await fs.readFile(modFile, 'utf-8'))?.[1];
+    return module ? { module, dir: dirname(modFile) } : null;
+}
+
+/**
+ * Every import in the project's source files, resolved to package level
+ */
+export async function scanImports(root: string, languages: ImportLanguage[], skip: (path: string) => boolean = () => false): Promise<{ imports: SourceImport[]; files: number; truncated: boolean; goModule: string | null }> {
+    const go = languages.includes('go') ? await readGoModule(root) : null;
+    const imports: SourceImport[] = [];
+    let files = 0;
+    let truncated = false;
+
+    const walk = async (dir: string): Promise<void> => {
+        for (const entry of await fs.readdir(dir, { withFileTypes: true })) {
+            if (files >= MAX_FILES) {
+                truncated = true;
+                return;
+            }
+            const full = join(dir, entry.name);
+            if (skip(full)) continue;
+            if (entry.isDirectory()) {
+                if (!SKIPPED_DIRS.has(entry.name) && !entry.name.startsWith('.')) await walk(full);
+                continue;
+            }
+            const language = EXTENSIONS[extname(entry.name)];
+            if (!entry.isFile() || !language || !languages.includes(language) || entry.name.endsWith('.d.ts')) continue;
+            files++;
+            const text = await fs.readFile(full, 'utf-8');
+            const from = language === 'go'
+                ? [go?.module ?? '', toPosix(relative(go?.dir ?? root, dir))].filter(p => p && p !== '.').join('/')
+                : toPosix(relative(root, dir));
+            for (const { specifier, line } of extractImports(text, language)) {
+                const resolved = await resolveImport(root, full, specifier, language, go?.module ?? null);
+                imports.push({ file: full, line, specifier, from, ...resolved });
+            }
+        }
+    };
+    await walk(root);
+    return { imports, files, truncated, goModule: go?.module ?? null };
+}
+
+// Go import paths are also matched relative to the module, so rules can say "internal/store"
+function packageNames(id: string, goModule: string | null): string[] {
+    if (goModule && id.startsWith(`${goModule}/`)) return [id, id.slice(goModule.length + 1)];
+    return [id];
+}
+
+export function findViolations(imports: SourceImport[], rules: ArchitectureRule[], goModule: string | null = null): ArchitectureViolation[] {
+    const violations: ArchitectureViolation[] = [];
+    for (const entry of imports) {
+        if (entry.from === entry.to) continue;
+        const fromNames = packageNames(entry.from, goModule);
+        const toNames = packageNames(entry.to, goModule);
+        for (const rule of rules) {
+            if (!fromNames.some(n => matchesPackagePattern(rule.from, n)) || !toNames.some(n => matchesPackagePattern(rule.to, n))) continue;
+            violations.push({ ...entry, rule: `${rule.from} must not import ${rule.to}${rule.reason ? `: ${rule.reason}` : ''}` });
+        }
+    }
+    return violations;
+}
+
+export const checkArchitectureTool = {
+    name: 'check_architecture',
+    description: 'Check Go, Python, and JavaScript/TypeScript imports against layering rules ("internal/store must not import internal/api") declared under `architecture` in .code-feedback.yaml or passed as rules. Packages are Go import paths (also matched relative to the module) or directories relative to the project root; dependencies are matched by package name. Returns each violating import with its file and line.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, languages } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const root = resolve(path);
+            const effective = await getEffectiveConfig(root);
+            const rules = [...(effective.config.architecture ?? []), ...parseResult.data.rules];
+            if (rules.length === 0) {
+                return { success: false, errors: ['No architecture rules: add `architecture` to .code-feedback.yaml or pass rules'], warnings: [], output: '' };
+            }
+            const skip = (p: string) => isExcluded(effective.config, effective.workspaceRoot, p);
+            const { imports, files, truncated, goModule } = await scanImports(root, languages, skip);
+            const violations = findViolations(imports, rules, goModule);
+            const location = (v: ArchitectureViolation) => `${relative(root, v.file) || v.file}:${v.line}`;
+            return {
+                success: violations.length === 0,
+                errors: violations.map(v => `${location(v)}: ${v.from} imports ${v.to} (${v.rule})`),
+                warnings: truncated ? [`Stopped after ${MAX_FILES} files; narrow path to check the rest`] : [],
+                output: `${violations.length} violation(s) of ${rules.length} rule(s) in ${files} file(s)`,
+                rules,
+                violations,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,64 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { auditLog, getAuditLogPath, type AuditEntry } from '../audit/index.js';
+
+const inputSchema = z.object({
+    tool: z.string().optional().describe('Only calls to this tool'),
+    status: z.enum(['success', 'failure', 'rejected', 'cancelled', 'error']).optional(),
+    path: z.string().optional().describe('Only calls whose path arguments or changed files are at or under this path'),
+    since: z.string().optional().describe('ISO timestamp; only calls made at or after it'),
+    until: z.string().optional().describe('ISO timestamp; only calls made at or before it'),
+    mutatingOnly: z.boolean().default(false).describe('Only calls that could change files'),
+    limit: z.number().int().positive().max(1000).default(50),
+});
+
+function summarize(entry: AuditEntry): string {
+    const files = entry.files.length > 0 ? `, ${entry.files.length} file(s) changed` : '';
+    return `${entry.timestamp} ${entry.tool} ${entry.status} (${entry.durationMs}ms${entry.cached ? ', cached' : ''}${files})`;
+}
+
+export const getAuditLogTool = {
+    name: 'get_audit_log',
+    description: 'Query the audit log of tool calls made through this server, newest first: arguments (secrets redacted, long values hashed), duration, status, and the path and sha256 of every file written, moved, copied, or deleted. Filter by tool, status, path, time range, or mutating calls only.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { tool, status, path, since, until, mutatingOnly, limit } = parseResult.data;
+        for (const [key, value] of [['since', since], ['until', until]] as const) {
+            if (value !== undefined && Number.isNaN(Date.parse(value))) {
+                return { success: false, errors: [`Validation error: ${key} - Invalid timestamp`], warnings: [], output: '' };
+            }
+        }
+        try {
+            const warnings = auditLog.isEnabled() ? [] : ['Auditing is disabled (MCP_AUDIT=off); only earlier entries are shown'];
+            const { entries, total } = await auditLog.query({
+                ...(tool ? { tool } : {}),
+                ...(status ? { status } : {}),
+                ...(path ? { path } : {}),
+                ...(since ? { since } : {}),
+                ...(until ? { until } : {}),
+                mutatingOnly,
+                limit,
+            });
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: entries.length > 0 ? entries.map(summarize).join('\n') : 'No matching audit entries',
+                logPath: getAuditLogPath(),
+                total,
+                entries,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
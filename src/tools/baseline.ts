@@ -0,0 +1,110 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, relative, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig, getToolTimeout, isToolEnabled, pipelineStepSchema } from '../config/project.js';
+import { baselinePath, createBaseline } from '../baseline/index.js';
+import { countBySeverity, type Diagnostic } from '../diagnostics/index.js';
+import { suppressionOptions } from '../diagnostics/suppressions.js';
+import { historyStore } from '../history/index.js';
+import { recordFileChange } from '../audit/index.js';
+import { captureBeforeChange } from '../snapshots/index.js';
+import { runCommand } from '../utils/command.js';
+import { runPipeline, type PipelineTool } from './pipeline.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Project directory; the baseline is written next to its .code-feedback.yaml'),
+    pipeline: z.string().default('default').describe('Pipeline whose findings become the baseline'),
+    steps: z.array(pipelineStepSchema).optional().describe('Inline steps, used instead of a configured pipeline'),
+    run: z.string().optional().describe('Id of a recorded run (get_history) to take the findings from instead of running the pipeline'),
+});
+
+export const createBaselineTool = {
+    name: 'create_baseline',
+    mutates: true,
+    description: 'Snapshot the current findings of a workspace into a baseline file (.code-feedback-baseline.json, or baseline.file in .code-feedback.yaml), so later run_pipeline runs leave them out and report only new issues. Runs every step of the pipeline, past failures, or takes the findings of a recorded run. A baselined finding still matches after its line moves within baseline.lineTolerance (default 10) lines, or anywhere in its file while the flagged line is unchanged. Commit the file so every checkout shares it; regenerate it to accept the current findings.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, pipeline, steps: inlineSteps, run: runId } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const effective = await getEffectiveConfig(path);
+            const root = (await fs.stat(path)).isDirectory() ? resolve(path) : dirname(resolve(path));
+            const file = baselinePath(effective, root);
+            if (!config.isPathAllowed(file)) {
+                return { success: false, errors: [`Baseline file outside the allowed paths: ${file}`], warnings: [], output: '' };
+            }
+            const warnings: string[] = [];
+            let diagnostics: Diagnostic[];
+            if (runId) {
+                const recorded = await historyStore.get(runId);
+                if (!recorded) return { success: false, errors: [`No run found for ${runId}`], warnings: [], output: '' };
+                if (!config.isPathAllowed(recorded.workspace)) {
+                    return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+                }
+                // Recorded paths are relative to the run's workspace
+                diagnostics = recorded.diagnostics.map(d => ({ ...d, file: resolve(recorded.workspace, d.file) }));
+            } else {
+                const steps = inlineSteps ?? effective.config.pipelines?.[pipeline];
+                if (!steps) {
+                    const available = Object.keys(effective.config.pipelines ?? {});
+                    const hint = available.length > 0 ? `available: ${available.join(', ')}` : 'no pipelines are configured';
+                    return { success: false, errors: [`Pipeline "${pipeline}" not found (${hint})`], warnings: [], output: '' };
+                }
+                // Imported lazily: the tool registry imports this module
+                const { allTools } = await import('./index.js');
+                // Every step runs, so findings of steps after a failing one are baselined too; the old baseline filters nothing
+                const results = await runPipeline(
+                    steps.map(step => ({ ...step, continueOnError: true })),
+                    root,
+                    allTools as PipelineTool[],
+                    name => isToolEnabled(effective.config, name),
+                    name => getToolTimeout(effective.config, name),
+                    { suppressions: suppressionOptions(effective.config) },
+                );
+                diagnostics = results.flatMap(r => r.diagnostics ?? []);
+                for (const r of results) {
+                    if (r.status === 'failed' && !r.diagnostics?.length) {
+                        warnings.push(`${r.name} failed without reporting findings; a baseline cannot cover it: ${r.errors[0] ?? 'step failed'}`);
+                    }
+                }
+            }
+
+            const head = await runCommand('git rev-parse HEAD', { cwd: root, timeout: 10000, local: true }).catch(() => null);
+            const baseline = await createBaseline(dirname(file), diagnostics, head?.exitCode === 0 ? head.stdout.trim() : null);
+            const content = JSON.stringify(baseline, null, 2) + '\n';
+            await fs.mkdir(dirname(file), { recursive: true });
+            await captureBeforeChange(file);
+            await fs.writeFile(file, content, 'utf-8');
+            await recordFileChange(file, 'write', content);
+
+            const counts = countBySeverity(diagnostics);
+            const files = new Set(baseline.entries.map(e => e.file)).size;
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: `Baselined ${baseline.entries.length} finding(s) (${counts.error} error(s), ${counts.warning} warning(s), ${counts.info} info) in ${files} file(s) to ${relative(root, file) || file}`,
+                file,
+                entries: baseline.entries.length,
+                counts,
+                commit: baseline.commit,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
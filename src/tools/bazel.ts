@@ -0,0 +1,276 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, join, resolve } from 'path';
+import { tmpdir } from 'os';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import {
+    type Diagnostic,
+    type TestCaseResult,
+    type TestStatus,
+    type BazelTestTarget,
+    parseBuildEvents,
+    parseBazelOutput,
+    parseJUnitXml,
+    toTestReport,
+    describeTestFailure,
+    countBySeverity,
+} from '../diagnostics/index.js';
+
+// Labels and patterns: //pkg/..., //pkg:target, @repo//pkg:all, -//pkg/excluded/... (after --)
+const targetPattern = z.string().regex(/^-?[@/:\w][^\s]*$/, 'Expected a target pattern such as //pkg/... or //pkg:target');
+// Log lines kept for a failed test that wrote no test.xml
+const MAX_LOG_LINES = 50;
+const WORKSPACE_FILES = ['MODULE.bazel', 'REPO.bazel', 'WORKSPACE.bazel', 'WORKSPACE'];
+
+const bazelSchema = z.object({
+    projectPath: z.string().describe('Directory inside the Bazel workspace (MODULE.bazel or WORKSPACE)'),
+    targets: z.array(targetPattern).min(1).default(['//...']).describe('Target patterns, e.g. //server/... or //lib:codec; a leading - excludes'),
+    config: z.array(z.string().regex(/^[\w.-]+$/)).default([]).describe('.bazelrc configs to apply (--config)'),
+    keepGoing: z.boolean().default(true).describe('Build as much as possible and report every failing target (--keep_going)'),
+    args: z.array(z.string()).default([]).describe('Extra Bazel options, e.g. --compilation_mode=dbg'),
+    timeout: z.number().default(1800000),
+});
+
+const bazelTestSchema = bazelSchema.extend({
+    testFilter: z.string().optional().describe('Only run test cases matching this filter (--test_filter), e.g. TestParse'),
+    flakyAttempts: z.number().int().min(1).max(10).optional().describe('Attempts per failing test before it counts as failed (--flaky_test_attempts)'),
+    cacheResults: z.boolean().default(true).describe('false reruns tests whose results are cached (--nocache_test_results)'),
+});
+
+const bazelQuerySchema = z.object({
+    projectPath: z.string().describe('Directory inside the Bazel workspace'),
+    expression: z.string().min(1).optional().describe('Bazel query expression, e.g. "deps(//server:main)" or "rdeps(//..., //lib:codec)"; defaults to the rules matching kind under scope'),
+    kind: z.string().regex(/^[\w.*|^$+?()-]+$/).default('rule').describe('Rule kind regex for the default expression, e.g. "_test$" or "go_library"'),
+    scope: targetPattern.default('//...').describe('Target pattern the default expression searches'),
+    timeout: z.number().default(120000),
+});
+
+function validationFailure(error: z.ZodError) {
+    return {
+        success: false,
+        errors: error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+        warnings: [],
+        output: ''
+    };
+}
+
+function bazelFlags(options: z.infer<typeof bazelSchema>, eventsFile: string): string {
+    const flags = ['--color=no', '--curses=no', `--build_event_json_file=${shellQuote(eventsFile)}`];
+    if (options.keepGoing) flags.push('--keep_going');
+    flags.push(...options.config.map(config => `--config=${config}`));
+    flags.push(...options.args.map(shellQuote));
+    return flags.join(' ');
+}
+
+function testStatus(bazelStatus: string): TestStatus {
+    if (bazelStatus === 'PASSED' || bazelStatus === 'FLAKY') return 'passed';
+    if (bazelStatus === 'FAILED_TO_BUILD' || bazelStatus === 'TOOL_HALTED_BEFORE_TESTING') return 'error';
+    if (bazelStatus === 'NO_STATUS') return 'skipped';
+    // FAILED, TIMEOUT, INCOMPLETE, REMOTE_FAILURE
+    return 'failed';
+}
+
+async function readLogTail(path: string | undefined): Promise<string | undefined> {
+    if (!path) return undefined;
+    const log = await fs.readFile(path, 'utf-8').catch(() => null);
+    return log === null ? undefined : log.trimEnd().split('\n').slice(-MAX_LOG_LINES).join('\n');
+}
+
+/**
+ * Root of the Bazel workspace containing dir; compiler output is relative to it
+ */
+export async function findBazelWorkspace(dir: string): Promise<string> {
+    let current = resolve(dir);
+    for (;;) {
+        for (const name of WORKSPACE_FILES) {
+            if (await fs.access(join(current, name)).then(() => true, () => false)) return current;
+        }
+        const parent = dirname(current);
+        if (parent === current) return resolve(dir);
+        current = parent;
+    }
+}
+
+/**
+ * Test cases of each test target: the cases in the test.xml of its last
+ * attempt per run and shard, or one case for the whole target when the test
+ * wrote none (or never ran because it failed to build)
+ */
+export async function bazelTestCases(tests: BazelTestTarget[]): Promise<TestCaseResult[]> {
+    const cases: TestCaseResult[] = [];
+    for (const target of tests) {
+        const finalRuns = new Map<string, BazelTestTarget['runs'][number]>();
+        for (const run of target.runs) finalRuns.set(`${run.run}/${run.shard}`, run);
+        const parsed: TestCaseResult[] = [];
+        for (const run of finalRuns.values()) {
+            const xml = run.xmlPath ? await fs.readFile(run.xmlPath, 'utf-8').catch(() => null) : null;
+            if (xml) parsed.push(...parseJUnitXml(xml).map(test => ({ ...test, suite: test.suite || target.label })));
+        }
+        if (parsed.length > 0) {
+            cases.push(...parsed);
+            continue;
+        }
+        const status = testStatus(target.status);
+        const failedRun = [...finalRuns.values()].find(run => testStatus(run.status) !== 'passed');
+        const details = status === 'failed' ? await readLogTail(failedRun?.logPath) : undefined;
+        cases.push({
+            suite: target.label,
+            name: target.label,
+            status,
+            durationMs: target.durationMs,
+            ...(status !== 'passed' ? { message: target.status === 'FAILED_TO_BUILD' ? 'Failed to build' : target.status } : {}),
+            ...(details ? { details } : {}),
+        });
+    }
+    return cases;
+}
+
+/**
+ * Run a Bazel build or test command with a Build Event Protocol file and
+ * shape the result: targets and failed actions from the events, compiler and
+ * BUILD file diagnostics from the console output
+ */
+async function runBazel(verb: 'build' | 'test', options: z.infer<typeof bazelSchema>, extraFlags: string[]) {
+    const workDir = await fs.mkdtemp(join(tmpdir(), 'cf-bazel-'));
+    try {
+        const eventsFile = join(workDir, 'events.json');
+        const targets = options.targets.map(shellQuote).join(' ');
+        const command = `bazel ${verb} ${bazelFlags(options, eventsFile)}${extraFlags.length > 0 ? ` ${extraFlags.join(' ')}` : ''} -- ${targets}`;
+        const result = await runCommand(command, { cwd: options.projectPath, timeout: options.timeout, maxBuffer: 32 * 1024 * 1024 });
+        const events = parseBuildEvents(await fs.readFile(eventsFile, 'utf-8').catch(() => ''));
+        const output = `${result.stdout}\n${result.stderr}`;
+        const diagnostics: Diagnostic[] = parseBazelOutput(output, await findBazelWorkspace(options.projectPath));
+        const failedTargets = events.targets.filter(t => t.status === 'failed').map(t => t.label);
+        const feedback: Record<string, any> = {
+            success: result.exitCode === 0,
+            errors: diagnostics.filter(d => d.severity === 'error').map(d => `${d.file}:${d.line}:${d.column}: ${d.message}`),
+            warnings: diagnostics.filter(d => d.severity === 'warning').map(d => `${d.file}:${d.line}:${d.column}: ${d.message}`),
+            output: result.stderr,
+            command,
+            diagnostics,
+            summary: countBySeverity(diagnostics),
+            targets: {
+                built: events.targets.filter(t => t.status === 'built').length,
+                failed: failedTargets,
+                skipped: events.targets.filter(t => t.status === 'skipped').length,
+            },
+            failedActions: events.failedActions,
+            ...(events.exitCode ? { exitCode: events.exitCode.name } : {}),
+        };
+        if (verb === 'test') {
+            const tests = toTestReport(await bazelTestCases(events.tests));
+            feedback.tests = tests;
+            feedback.testTargets = events.tests.map(t => ({ label: t.label, status: t.status, durationMs: t.durationMs, attempts: t.runs.length, cached: t.runs.length > 0 && t.runs.every(r => r.cached) }));
+            feedback.errors.push(...tests.failures.map(describeTestFailure));
+            for (const target of events.tests.filter(t => t.status === 'FLAKY')) {
+                feedback.warnings.push(`${target.label} is flaky: it failed and then passed within ${target.runs.length} attempts`);
+            }
+        }
+        if (result.exitCode !== 0 && feedback.errors.length === 0) {
+            // Failed for a reason we could not tie to a file (bad target pattern, fetch error, ...)
+            const errorLines = output.split('\n').filter(line => /^ERROR: /.test(line) && !/did NOT complete successfully/.test(line)).slice(0, 20);
+            feedback.errors.push(errorLines.length > 0 ? errorLines.join('\n') : `Exited with code ${result.exitCode}`);
+        }
+        return feedback;
+    } finally {
+        await fs.rm(workDir, { recursive: true, force: true });
+    }
+}
+
+export const bazelBuildTool = {
+    name: 'bazel_build',
+    binaries: ['bazel'],
+    description: 'Build Bazel targets (default //...) with --keep_going and return every failing target plus structured diagnostics: Bazel errors in BUILD and .bzl files, and the gcc/clang, javac and Go compiler errors of failed actions. Targets and failed actions come from the Build Event Protocol. Works for any language in a monorepo.',
+    inputSchema: zodToJsonSchema(bazelSchema),
+    async run(args: any) {
+        const parseResult = bazelSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const options = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(options.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            return await runBazel('build', options, []);
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const bazelTestTool = {
+    name: 'bazel_test',
+    binaries: ['bazel'],
+    description: 'Run Bazel tests (default //...) and return per-test results parsed from each target\'s test.xml (falling back to one result per target with the tail of its test.log), the status of every test target (cached, flaky, timed out, failed to build) and build diagnostics, in the same shape as the other test tools.',
+    inputSchema: zodToJsonSchema(bazelTestSchema),
+    async run(args: any) {
+        const parseResult = bazelTestSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const options = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(options.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const flags = ['--test_output=summary'];
+            if (options.testFilter) flags.push(`--test_filter=${shellQuote(options.testFilter)}`);
+            if (options.flakyAttempts) flags.push(`--flaky_test_attempts=${options.flakyAttempts}`);
+            if (!options.cacheResults) flags.push('--nocache_test_results');
+            return await runBazel('test', options, flags);
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+/**
+ * Targets from `bazel query --output=label_kind` lines: "go_library rule //lib:codec", "source file //lib:codec.go"
+ */
+export function parseLabelKindOutput(output: string): Array<{ label: string; kind: string }> {
+    const targets: Array<{ label: string; kind: string }> = [];
+    for (const line of output.split('\n')) {
+        const match = /^(?:(\S+) rule|(source file|generated file|package group)) (\S+)$/.exec(line.trim());
+        if (match) targets.push({ kind: match[1] ?? match[2] ?? '', label: match[3] ?? '' });
+    }
+    return targets;
+}
+
+export const bazelQueryTool = {
+    name: 'bazel_query',
+    binaries: ['bazel'],
+    description: 'Discover Bazel targets: runs `bazel query` (by default every rule under //..., or those whose kind matches `kind`, e.g. "_test$") and returns each target\'s label and rule kind, counts by kind and the test targets. Pass `expression` for any query, e.g. deps(), rdeps() or somepath().',
+    inputSchema: zodToJsonSchema(bazelQuerySchema),
+    async run(args: any) {
+        const parseResult = bazelQuerySchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const options = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(options.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const expression = options.expression ?? `kind("${options.kind}", ${options.scope})`;
+            const command = `bazel query --output=label_kind --keep_going --color=no --curses=no --noshow_progress -- ${shellQuote(expression)}`;
+            const result = await runCommand(command, { cwd: options.projectPath, timeout: options.timeout, maxBuffer: 32 * 1024 * 1024 });
+            const targets = parseLabelKindOutput(result.stdout);
+            const byKind: Record<string, number> = {};
+            for (const target of targets) byKind[target.kind] = (byKind[target.kind] ?? 0) + 1;
+            // With --keep_going, exit code 3 means some of the universe could not be loaded
+            const partial = result.exitCode === 3;
+            const errorLines = result.stderr.split('\n').filter(line => /^ERROR: /.test(line)).slice(0, 20);
+            return {
+                success: result.exitCode === 0 || partial,
+                errors: result.exitCode === 0 || partial ? [] : [errorLines.length > 0 ? errorLines.join('\n') : `Exited with code ${result.exitCode}`],
+                warnings: partial ? [`Results are partial: ${errorLines.join('; ') || 'some packages failed to load'}`] : [],
+                output: targets.length > 0 ? targets.map(t => `${t.kind} ${t.label}`).join('\n') : 'No targets match',
+                command,
+                expression,
+                targets,
+                byKind,
+                tests: targets.filter(t => t.kind.endsWith('_test')).map(t => t.label),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,201 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { recordFileChange } from '../audit/index.js';
+import { captureBeforeChange } from '../snapshots/index.js';
+import { shellQuote } from '../utils/shell.js';
+import { median, mannWhitneyUTest } from '../utils/stats.js';
+
+export interface BenchmarkResult {
+    package: string;
+    name: string;
+    // Samples per unit (ns/op, B/op, allocs/op, MB/s, custom b.ReportMetric units), one per -count run
+    metrics: Record<string, number[]>;
+}
+
+export interface BenchmarkComparison {
+    package: string;
+    name: string;
+    unit: string;
+    baseline: number;
+    current: number;
+    deltaPercent: number;
+    pValue: number;
+    // Both sides had enough samples for the U-test to reach the significance level
+    testable: boolean;
+    verdict: 'regression' | 'improvement' | 'unchanged';
+}
+
+// "BenchmarkEncode/small-8   	  500000	      2371 ns/op	     512 B/op	       3 allocs/op"
+const benchLinePattern = /^(Benchmark\S+?)(?:-\d+)?\s+(\d+)\s+(.+)$/;
+
+/**
+ * Parse `go test -bench` output (the format benchstat reads). Runs of the same
+ * benchmark (-count) become samples; the -GOMAXPROCS suffix is dropped so
+ * results from machines with different core counts line up.
+ */
+export function parseGoBenchOutput(output: string): BenchmarkResult[] {
+    const results = new Map<string, BenchmarkResult>();
+    let pkg = '';
+    for (const rawLine of output.split('\n')) {
+        const line = rawLine.replace(/\r$/, '');
+        if (line.startsWith('pkg: ')) {
+            pkg = line.slice(5).trim();
+            continue;
+        }
+        const match = benchLinePattern.exec(line);
+        if (!match) continue;
+        const name = match[1] ?? '';
+        const key = `${pkg}\u0000${name}`;
+        let result = results.get(key);
+        if (!result) {
+            result = { package: pkg, name, metrics: {} };
+            results.set(key, result);
+        }
+        const fields = (match[3] ?? '').trim().split(/\s+/);
+        for (let i = 0; i + 1 < fields.length; i += 2) {
+            const value = Number(fields[i]);
+            const unit = fields[i + 1] ?? '';
+            if (!Number.isFinite(value) || !unit) continue;
+            (result.metrics[unit] ??= []).push(value);
+        }
+    }
+    return [...results.values()];
+}
+
+// Throughput units (MB/s, ops/s) improve upwards, everything else (time, bytes, allocs) downwards
+function higherIsBetter(unit: string): boolean {
+    return unit.endsWith('/s');
+}
+
+/**
+ * Compare medians benchstat-style: a change counts when its U-test p-value is
+ * below alpha and it moves the median by more than thresholdPercent. With too
+ * few samples to ever reach alpha (fewer than 4 per side at 0.05), the
+ * threshold alone decides and the comparison is marked untestable.
+ */
+export function compareBenchmarks(baseline: BenchmarkResult[], current: BenchmarkResult[], thresholdPercent: number, alpha: number = 0.05): BenchmarkComparison[] {
+    const comparisons: BenchmarkComparison[] = [];
+    const byKey = new Map(baseline.map(b => [`${b.package}\u0000${b.name}`, b]));
+    // Baselines saved from another package path (fork, renamed module) still match by name
+    const byName = new Map(baseline.map(b => [b.name, b]));
+    for (const result of current) {
+        const old = byKey.get(`${result.package}\u0000${result.name}`) ?? byName.get(result.name);
+        if (!old) continue;
+        for (const [unit, samples] of Object.entries(result.metrics)) {
+            const oldSamples = old.metrics[unit];
+            if (!oldSamples || oldSamples.length === 0 || samples.length === 0) continue;
+            const before = median(oldSamples);
+            const after = median(samples);
+            const deltaPercent = before === 0 ? (after === 0 ? 0 : 100) : Math.round(((after - before) / before) * 10000) / 100;
+            const pValue = mannWhitneyUTest(oldSamples, samples);
+            // Smallest p-value the exact test can produce for these sample sizes
+            const testable = mannWhitneyUTest(oldSamples.map((_, i) => i), samples.map((_, i) => oldSamples.length + i)) < alpha;
+            const changed = Math.abs(deltaPercent) > thresholdPercent && (!testable || pValue < alpha);
+            const worse = higherIsBetter(unit) ? deltaPercent < 0 : deltaPercent > 0;
+            comparisons.push({
+                package: result.package,
+                name: result.name,
+                unit,
+                baseline: before,
+                current: after,
+                deltaPercent,
+                pValue: Math.round(pValue * 10000) / 10000,
+                testable,
+                verdict: !changed ? 'unchanged' : worse ? 'regression' : 'improvement',
+            });
+        }
+    }
+    return comparisons;
+}
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Go module or package directory'),
+    packages: z.string().default('.').describe('Package pattern to benchmark'),
+    bench: z.string().default('.').describe('-bench regex'),
+    count: z.number().int().min(1).max(50).default(5).describe('Samples per benchmark; 5 or more lets the comparison test significance'),
+    benchtime: z.string().optional().describe('-benchtime, e.g. 2s or 1000x'),
+    baseline: z.string().optional().describe('File with earlier `go test -bench` output to compare against'),
+    saveBaseline: z.string().optional().describe('Write this run\'s raw output here for later comparisons'),
+    threshold: z.number().min(0).default(5).describe('Minimum change in percent to flag'),
+    alpha: z.number().gt(0).lt(1).default(0.05).describe('Significance level for the Mann-Whitney U test'),
+    timeout: z.number().default(600000),
+});
+
+export const goBenchmarkTool = {
+    name: 'go_benchmark',
+    binaries: ['go'],
+    mutates: (args: any) => typeof args?.saveBaseline === 'string',
+    description: 'Run `go test -bench` with -benchmem and return per-benchmark samples (ns/op, B/op, allocs/op, MB/s). With a baseline file, compares medians benchstat-style (Mann-Whitney U test) and fails on regressions above the threshold.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, packages, bench, count, benchtime, baseline, saveBaseline, threshold, alpha, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(projectPath) || (baseline && !config.isPathAllowed(baseline)) || (saveBaseline && !config.isPathWritable(saveBaseline))) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            let baselineResults: BenchmarkResult[] | undefined;
+            if (baseline) {
+                baselineResults = parseGoBenchOutput(await fs.readFile(baseline, 'utf-8'));
+                if (baselineResults.length === 0) {
+                    return { success: false, errors: [`No benchmark results in baseline ${baseline}`], warnings: [], output: '' };
+                }
+            }
+            let command = `go test -run '^$' -bench ${shellQuote(bench)} -benchmem -count ${count}`;
+            if (benchtime) command += ` -benchtime ${shellQuote(benchtime)}`;
+            const result = await runCommand(`${command} ${packages}`, { cwd: projectPath, timeout, maxBuffer: 16 * 1024 * 1024 });
+            const benchmarks = parseGoBenchOutput(result.stdout);
+            if (result.exitCode !== 0) {
+                return { success: false, errors: [`go test -bench failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout, benchmarks };
+            }
+            if (saveBaseline) {
+                await fs.mkdir(dirname(saveBaseline), { recursive: true });
+                await captureBeforeChange(saveBaseline);
+                await fs.writeFile(saveBaseline, result.stdout, 'utf-8');
+                await recordFileChange(saveBaseline, 'write', result.stdout);
+            }
+            const warnings: string[] = [];
+            if (benchmarks.length === 0) warnings.push(`No benchmarks matched ${bench}`);
+            if (!baselineResults) {
+                return { success: true, errors: [], warnings, output: result.stdout, benchmarks };
+            }
+
+            const comparisons = compareBenchmarks(baselineResults, benchmarks, threshold, alpha);
+            const regressions = comparisons.filter(c => c.verdict === 'regression');
+            const describe = (c: BenchmarkComparison) => `${c.name} ${c.unit}: ${c.baseline} -> ${c.current} (${c.deltaPercent > 0 ? '+' : ''}${c.deltaPercent}%, p=${c.pValue})`;
+            if (comparisons.some(c => !c.testable)) {
+                warnings.push(`Too few samples to test significance; changes above ${threshold}% are flagged on the medians alone. Use count >= 5 for both runs.`);
+            }
+            const missing = baselineResults.filter(b => !benchmarks.some(c => c.name === b.name)).map(b => b.name);
+            if (missing.length > 0) warnings.push(`Benchmarks in the baseline that did not run: ${missing.join(', ')}`);
+            return {
+                success: regressions.length === 0,
+                errors: regressions.map(c => `Regression: ${describe(c)}`),
+                warnings: [...warnings, ...comparisons.filter(c => c.verdict === 'improvement').map(c => `Improvement: ${describe(c)}`)],
+                output: result.stdout,
+                benchmarks,
+                comparisons,
+                summary: {
+                    compared: comparisons.length,
+                    regressions: regressions.length,
+                    improvements: comparisons.filter(c => c.verdict === 'improvement').length,
+                },
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,237 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { dirname, join, relative, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { runCommand } from '../utils/command.js';
+import { findUp } from '../utils/paths.js';
+import { shellQuote } from '../utils/shell.js';
+
+export interface SizeEntry {
+    name: string;
+    bytes: number;
+}
+
+export interface BinarySize {
+    total: number;
+    packages: SizeEntry[];
+    // Packages summed by the module that provides them: the main module, each dependency, and std
+    modules: SizeEntry[];
+}
+
+export interface SizeChange {
+    name: string;
+    before: number;
+    after: number;
+    delta: number;
+}
+
+// Code and data that take room in the file; bss (B, b) is only reserved at run time
+const SIZED_SYMBOLS = new Set(['T', 't', 'R', 'r', 'D', 'd']);
+const METADATA = '(runtime metadata)';
+const UNATTRIBUTED = '(headers, pclntab, debug info)';
+
+/**
+ * The import path a linker symbol belongs to: "encoding/json/v2.(*T).M" is
+ * encoding/json/v2, "gopkg.in/yaml%2ev3.Marshal" gopkg.in/yaml.v3. Type
+ * descriptors of a package's types count for it; compiler-generated
+ * symbols (go:, type:., constants) are runtime metadata.
+ */
+export function packageOfSymbol(symbol: string): string {
+    let name = symbol.split('[')[0]!;
+    if (name.startsWith('type:')) name = name.slice(5).replace(/^[*\]\[]+/, '');
+    if (!name || /^(go:|\$|\.|_|type:)/.test(name)) return METADATA;
+    const slash = name.lastIndexOf('/');
+    const dot = name.indexOf('.', slash + 1);
+    if (dot <= 0) return METADATA;
+    return name.slice(0, dot).replace(/%2e/gi, '.');
+}
+
+/**
+ * Sum `go tool nm -size` output by package
+ */
+export function sumSymbolSizes(nmOutput: string): Map<string, number> {
+    const sizes = new Map<string, number>();
+    for (const line of nmOutput.split('\n')) {
+        const match = /^\s*[0-9a-f]*\s+(\d+) (\S) (.+)$/.exec(line);
+        if (!match || !SIZED_SYMBOLS.has(match[2]!)) continue;
+        const pkg = packageOfSymbol(match[3]!);
+        sizes.set(pkg, (sizes.get(pkg) ?? 0) + Number(match[1]));
+    }
+    return sizes;
+}
+
+/**
+ * Module paths from `go version -m`: the main module (mod) and each dependency (dep)
+ */
+export function parseBuildInfoModules(output: string): string[] {
+    const modules: string[] = [];
+    for (const line of output.split('\n')) {
+        const match = /^\s+(mod|dep)\s+(\S+)/.exec(line);
+        if (match) modules.push(match[2]!);
+    }
+    return modules;
+}
+
+// Longest module path that is the package or a parent of it; packages outside every module are std
+function moduleOf(pkg: string, modules: string[]): string {
+    if (pkg === METADATA || pkg === UNATTRIBUTED) return pkg;
+    let best: string | null = null;
+    for (const mod of modules) {
+        if ((pkg === mod || pkg.startsWith(`${mod}/`)) && (!best || mod.length > best.length)) best = mod;
+    }
+    return best ?? 'std';
+}
+
+const sorted = (sizes: Map<string, number>): SizeEntry[] => [...sizes].map(([name, bytes]) => ({ name, bytes })).sort((a, b) => b.bytes - a.bytes || a.name.localeCompare(b.name));
+
+export function formatSize(bytes: number): string {
+    const sign = bytes < 0 ? '-' : '';
+    const abs = Math.abs(bytes);
+    if (abs >= 1024 * 1024) return `${sign}${(abs / 1024 / 1024).toFixed(2)} MiB`;
+    if (abs >= 1024) return `${sign}${(abs / 1024).toFixed(1)} KiB`;
+    return `${sign}${abs} B`;
+}
+
+export function diffSizes(before: SizeEntry[], after: SizeEntry[]): SizeChange[] {
+    const names = new Set([...before, ...after].map(e => e.name));
+    const size = (entries: SizeEntry[], name: string) => entries.find(e => e.name === name)?.bytes ?? 0;
+    return [...names]
+        .map(name => ({ name, before: size(before, name), after: size(after, name), delta: size(after, name) - size(before, name) }))
+        .filter(c => c.delta !== 0)
+        .sort((a, b) => b.delta - a.delta || a.name.localeCompare(b.name));
+}
+
+async function measure(binary: string, cwd: string, timeout: number): Promise<BinarySize> {
+    const total = (await fs.stat(binary)).size;
+    const nm = await runCommand(`go tool nm -size ${shellQuote(binary)}`, { cwd, timeout, local: true, maxBuffer: 256 * 1024 * 1024 });
+    if (nm.exitCode !== 0) throw new Error(`go tool nm failed: ${nm.stderr.trim()}`);
+    const info = await runCommand(`go version -m ${shellQuote(binary)}`, { cwd, timeout, local: true });
+    const modules = parseBuildInfoModules(info.stdout);
+    const packages = sumSymbolSizes(nm.stdout);
+    const symbols = [...packages.values()].reduce((sum, n) => sum + n, 0);
+    if (total > symbols) packages.set(UNATTRIBUTED, total - symbols);
+    const byModule = new Map<string, number>();
+    for (const [pkg, bytes] of packages) {
+        const mod = moduleOf(pkg, modules);
+        byModule.set(mod, (byModule.get(mod) ?? 0) + bytes);
+    }
+    return { total, packages: sorted(packages), modules: sorted(byModule) };
+}
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Go module directory (or any directory inside it)'),
+    package: z.string().default('.').describe('The main package to build, relative to projectPath'),
+    base: z.string().optional().describe('Git ref to build too and compare with, flagging size regressions and new dependencies'),
+    ldflags: z.string().optional().describe('-ldflags for both builds, such as "-s -w" to measure a stripped release binary'),
+    threshold: z.number().min(0).default(5).describe('Fail when the binary grows by more than this percentage over base'),
+    top: z.number().int().min(1).default(20).describe('Packages and modules to list'),
+    timeout: z.number().default(600000),
+});
+
+export const binaryReportTool = {
+    name: 'binary_report',
+    binaries: ['go', 'git'],
+    description: 'Build a Go main package and report what its binary is made of: total size, and the bytes each package and each module (the main module, every dependency, the standard library) contributes, from go tool nm symbol sizes. With base, builds that git ref too and diffs the two: growth above threshold percent fails, and each dependency that is new or grew is a warning on its go.mod line.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, package: pkg, base, ldflags, threshold, top, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        let workDir: string | undefined;
+        try {
+            const cwd = resolve(projectPath);
+            const goMod = await findUp(cwd, 'go.mod');
+            if (!goMod) return { success: false, errors: [`No go.mod found at or above ${projectPath}`], warnings: [], output: '' };
+            workDir = await fs.mkdtemp(join(tmpdir(), 'cf-binsize-'));
+            const build = async (dir: string, output: string) => runCommand(
+                `go build${ldflags ? ` -ldflags ${shellQuote(ldflags)}` : ''} -o ${shellQuote(output)} ${shellQuote(pkg)}`,
+                { cwd: dir, timeout, local: true, maxBuffer: 16 * 1024 * 1024 }
+            );
+
+            const built = await build(cwd, join(workDir, 'current'));
+            if (built.exitCode !== 0) return { success: false, errors: [`go build failed: ${built.stderr.trim()}`], warnings: [], output: '' };
+            const current = await measure(join(workDir, 'current'), cwd, timeout);
+            const lines = [
+                `${formatSize(current.total)} total`,
+                'By module:',
+                ...current.modules.slice(0, top).map(m => `  ${formatSize(m.bytes).padStart(11)}  ${m.name}`),
+                'By package:',
+                ...current.packages.slice(0, top).map(p => `  ${formatSize(p.bytes).padStart(11)}  ${p.name}`),
+            ];
+            if (!base) {
+                return { success: true, errors: [], warnings: [], output: lines.join('\n'), size: current };
+            }
+
+            const toplevel = await runCommand('git rev-parse --show-toplevel', { cwd, timeout, local: true });
+            if (toplevel.exitCode !== 0) return { success: false, errors: [`Not a git repository, so there is no ${base} to compare with`], warnings: [], output: '' };
+            const repoRoot = toplevel.stdout.trim();
+            // The whole tree at base, so replace directives pointing at sibling directories still resolve
+            const baseRoot = join(workDir, 'base');
+            await fs.mkdir(baseRoot);
+            const tarball = join(workDir, 'base.tar');
+            const archive = await runCommand(`git archive --format=tar -o ${shellQuote(tarball)} ${shellQuote(base)}`, { cwd: repoRoot, timeout, local: true });
+            if (archive.exitCode !== 0) return { success: false, errors: [`Could not read ${base}: ${archive.stderr.trim()}`], warnings: [], output: '' };
+            const extract = await runCommand(`tar -xf ${shellQuote(tarball)} -C ${shellQuote(baseRoot)}`, { cwd: workDir, timeout, local: true });
+            if (extract.exitCode !== 0) throw new Error(`Extracting ${base} failed: ${extract.stderr.trim()}`);
+            const baseBuilt = await build(join(baseRoot, relative(repoRoot, cwd)), join(workDir, 'base.bin'));
+            if (baseBuilt.exitCode !== 0) {
+                return { success: false, errors: [`go build at ${base} failed: ${baseBuilt.stderr.trim()}`], warnings: [], output: lines.join('\n'), size: current };
+            }
+            const before = await measure(join(workDir, 'base.bin'), cwd, timeout);
+
+            const delta = current.total - before.total;
+            const percent = before.total > 0 ? Math.round(delta / before.total * 10000) / 100 : 0;
+            const modules = diffSizes(before.modules, current.modules);
+            const packages = diffSizes(before.packages, current.packages);
+            const added = modules.filter(m => m.before === 0 && m.name !== 'std' && !m.name.startsWith('('));
+
+            // Dependencies that are new or grew, on their require lines
+            const goModLines = (await fs.readFile(goMod, 'utf-8')).split('\n');
+            const requireLine = (mod: string) => goModLines.findIndex(l => new RegExp(`^\\s*(require\\s+)?${mod.replace(/[.*+?^${}()|[\]\\]/g, '\\$&')}\\s+v`).test(l));
+            const diagnostics: Diagnostic[] = modules
+                .filter(m => m.delta > 0 && requireLine(m.name) >= 0)
+                .map(m => ({
+                    file: goMod,
+                    line: requireLine(m.name) + 1,
+                    column: 0,
+                    severity: 'warning' as const,
+                    message: m.before === 0 ? `New dependency ${m.name} adds ${formatSize(m.delta)} to the binary` : `${m.name} grew by ${formatSize(m.delta)} (${formatSize(m.before)} to ${formatSize(m.after)})`,
+                    rule: m.before === 0 ? 'new-dependency-size' : 'dependency-size',
+                    source: 'binary_report',
+                }));
+            const regressed = percent > threshold;
+            const change = (c: SizeChange) => `  ${`${c.delta > 0 ? '+' : ''}${formatSize(c.delta)}`.padStart(12)}  ${c.name}${c.before === 0 ? ' (new)' : c.after === 0 ? ' (removed)' : ''}`;
+            lines.push(
+                `Since ${base}: ${formatSize(before.total)} -> ${formatSize(current.total)} (${delta >= 0 ? '+' : ''}${formatSize(delta)}, ${percent >= 0 ? '+' : ''}${percent}%)`,
+                ...(modules.length > 0 ? ['Module changes:', ...modules.slice(0, top).map(change)] : []),
+                ...(packages.length > 0 ? ['Package changes:', ...packages.slice(0, top).map(change)] : []),
+            );
+            return {
+                success: !regressed,
+                errors: regressed ? [`Binary grew ${percent}% since ${base} (${formatSize(delta)}), above the ${threshold}% threshold${added.length > 0 ? `; new dependencies: ${added.map(m => m.name).join(', ')}` : ''}`] : [],
+                warnings: [],
+                output: lines.join('\n'),
+                size: current,
+                base: { ref: base, total: before.total, delta, percent, modules, packages: packages.slice(0, top * 5) },
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            if (workDir) await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
@@ -0,0 +1,134 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig } from '../config/project.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { type Diagnostic, parseGoBuildOutput } from '../diagnostics/index.js';
+
+export interface MatrixTargetResult {
+    target: string;
+    success: boolean;
+    durationMs: number;
+    errors: string[];
+    diagnostics: Diagnostic[];
+}
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Go module or package directory'),
+    packages: z.array(z.string()).default(['./...']).describe('Package patterns relative to projectPath'),
+    targets: z.array(z.string().regex(/^[a-z0-9]+\/[a-z0-9]+$/, 'Expected GOOS/GOARCH')).optional()
+        .describe('GOOS/GOARCH pairs; defaults to goTargets in .code-feedback.yaml, else linux, darwin and windows on amd64 and arm64'),
+    cgo: z.boolean().default(false).describe('Build with CGO_ENABLED=1; cross builds then need a C cross-compiler per target'),
+    concurrency: z.number().int().positive().optional().describe('Targets built at once (default: MCP_MAX_CONCURRENCY)'),
+    timeout: z.number().default(600000).describe('Per-target timeout in ms'),
+});
+
+export const DEFAULT_GO_TARGETS = ['linux/amd64', 'linux/arm64', 'darwin/amd64', 'darwin/arm64', 'windows/amd64', 'windows/arm64'];
+
+/**
+ * Diagnostics of every target, each finding once; findings that only some
+ * targets hit name them, since those are the platform-specific breakages
+ */
+export function mergeMatrixDiagnostics(results: MatrixTargetResult[]): Diagnostic[] {
+    const merged = new Map<string, { diagnostic: Diagnostic; targets: string[] }>();
+    for (const result of results) {
+        for (const diagnostic of result.diagnostics) {
+            const key = `${diagnostic.file}:${diagnostic.line}:${diagnostic.column}:${diagnostic.message}`;
+            const entry = merged.get(key);
+            if (entry) entry.targets.push(result.target);
+            else merged.set(key, { diagnostic, targets: [result.target] });
+        }
+    }
+    return [...merged.values()].map(({ diagnostic, targets }) => targets.length === results.length
+        ? diagnostic
+        : { ...diagnostic, message: `${diagnostic.message} (${targets.join(', ')})` });
+}
+
+async function buildTarget(target: string, projectPath: string, packages: string[], cgo: boolean, timeout: number): Promise<MatrixTargetResult> {
+    const [goos = '', goarch = ''] = target.split('/');
+    const started = Date.now();
+    // Binaries go to a scratch directory: -o with a trailing slash takes any number of main packages
+    const outDir = await fs.mkdtemp(join(tmpdir(), `cf-matrix-${goos}-${goarch}-`));
+    try {
+        const result = await runCommand(`go build -o ${shellQuote(`${outDir}/`)} ${packages.map(shellQuote).join(' ')}`, {
+            cwd: projectPath,
+            timeout,
+            env: { GOOS: goos, GOARCH: goarch, CGO_ENABLED: cgo ? '1' : '0' },
+            maxBuffer: 16 * 1024 * 1024,
+        });
+        const diagnostics = result.exitCode === 0 ? [] : parseGoBuildOutput(result.stderr, projectPath);
+        return {
+            target,
+            success: result.exitCode === 0,
+            durationMs: Date.now() - started,
+            errors: result.exitCode === 0 ? [] : [diagnostics.length > 0 ? `${diagnostics.length} build error(s)` : result.stderr.trim() || `go build exited with code ${result.exitCode}`],
+            diagnostics,
+        };
+    } finally {
+        await fs.rm(outDir, { recursive: true, force: true });
+    }
+}
+
+export const goBuildMatrixTool = {
+    name: 'go_build_matrix',
+    binaries: ['go'],
+    cacheable: true,
+    description: 'Cross-compile a Go project for a set of GOOS/GOARCH targets concurrently and report per-target success or failure with structured build diagnostics. Findings that only some targets hit are tagged with those targets, which points at platform-specific code (build tags, syscall use, file suffixes). Targets come from the call, goTargets in .code-feedback.yaml, or the six common desktop/server platforms.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, packages, cgo, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            await fs.access(projectPath);
+            const targets = [...new Set(parseResult.data.targets ?? (await getEffectiveConfig(projectPath)).config.goTargets ?? DEFAULT_GO_TARGETS)];
+            const warnings: string[] = [];
+            // Unknown pairs fail fast instead of each waiting on a go build error
+            const dist = await runCommand('go tool dist list', { cwd: projectPath, timeout: 30000 });
+            const supported = dist.exitCode === 0 ? new Set(dist.stdout.split('\n').map(l => l.trim()).filter(Boolean)) : null;
+            if (!supported) warnings.push(`Could not list supported targets: ${dist.stderr.trim()}`);
+
+            const results: MatrixTargetResult[] = new Array(targets.length);
+            let next = 0;
+            const worker = async () => {
+                while (next < targets.length) {
+                    const index = next++;
+                    const target = targets[index]!;
+                    results[index] = supported && !supported.has(target)
+                        ? { target, success: false, durationMs: 0, errors: [`${target} is not a target this Go toolchain supports (see go tool dist list)`], diagnostics: [] }
+                        : await buildTarget(target, projectPath, packages, cgo, timeout);
+                }
+            };
+            const concurrency = Math.min(targets.length, parseResult.data.concurrency ?? config.getMaxConcurrency());
+            await Promise.all(Array.from({ length: concurrency }, worker));
+
+            const failed = results.filter(r => !r.success);
+            return {
+                success: failed.length === 0,
+                errors: failed.flatMap(r => r.errors.map(e => `${r.target}: ${e}`)),
+                warnings,
+                output: results.map(r => `${r.success ? 'ok    ' : 'FAILED'} ${r.target} (${r.durationMs}ms)`).join('\n'),
+                summary: { total: results.length, passed: results.length - failed.length, failed: failed.length },
+                targets: results,
+                diagnostics: mergeMatrixDiagnostics(results),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
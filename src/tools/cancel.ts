@@ -0,0 +1,60 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { currentExecution, executionRegistry, type ExecutionInfo } from '../executions/index.js';
+
+const inputSchema = z.object({
+    executionId: z.string().min(1).optional().describe('requestId of the call to cancel (the _meta.requestId the client sent, or one listed by calling this tool without it)'),
+    reason: z.string().max(200).optional().describe('Recorded as the cancelled call\'s error'),
+});
+
+function describe(execution: ExecutionInfo): string {
+    return `${execution.id} ${execution.tool} (${Math.round(execution.elapsedMs / 1000)}s${execution.cancelled ? ', cancelling' : ''})`;
+}
+
+export const cancelExecutionTool = {
+    name: 'cancel_execution',
+    // Must not wait for a worker slot behind the very calls it cancels
+    scheduled: false,
+    description: 'Cancel a tool call that is still running or queued: its commands (and every process they spawned) are killed, commands it would run next are skipped, and the call returns with cancelled: true and the output collected so far. Without executionId, lists the calls in flight. Over HTTP, only the calling client\'s own calls are listed and can be cancelled. MCP notifications/cancelled for a request has the same effect.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { executionId, reason } = parseResult.data;
+        const caller = currentExecution();
+        const client = caller?.client;
+        const running = executionRegistry.list(client).filter(e => e.id !== caller?.id);
+        if (!executionId) {
+            return {
+                success: true,
+                errors: [],
+                warnings: [],
+                output: running.length > 0 ? running.map(describe).join('\n') : 'No tool calls in flight',
+                executions: running,
+            };
+        }
+        if (executionId === caller?.id) {
+            return { success: false, errors: ['A call cannot cancel itself'], warnings: [], output: '' };
+        }
+        const execution = executionRegistry.get(executionId);
+        // Another client's call is reported as unknown rather than confirming it exists
+        if (!execution || (client !== undefined && execution.client !== client)) {
+            return { success: false, errors: [`No tool call ${executionId} is in flight`], warnings: [], output: '', executions: running };
+        }
+        const cancelled = execution.cancel(reason ? `Cancelled: ${reason}` : 'Cancelled by cancel_execution');
+        return {
+            success: true,
+            errors: [],
+            warnings: cancelled ? [] : [`${execution.tool} call ${executionId} was already being cancelled`],
+            output: `Cancelled ${execution.tool} call ${executionId}`,
+            execution: execution.info(),
+        };
+    },
+};
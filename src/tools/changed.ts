@@ -0,0 +1,250 @@
+import { z } from 'zod';
+import { runCommand } from '../utils/command.js';
+import { promises as fs } from 'fs';
+import { dirname, extname, join, relative } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+import { type Diagnostic, type TestReport, parseGoVetOutput, parseGoTestJson, goTestJsonText, toTestReport, describeTestFailure } from '../diagnostics/index.js';
+import { getEffectiveConfig, isExcluded } from '../config/project.js';
+import { checkRepo } from './git.js';
+import { pythonTool } from './python.js';
+import { javascriptTool } from './javascript.js';
+import { typescriptTool } from './typescript.js';
+import { rustTool } from './rust.js';
+
+const inputSchema = z.object({
+    repoPath: z.string().describe('Repository root'),
+    base: z.string().default('HEAD').describe('Base ref; files changed since its merge-base with HEAD, including uncommitted and untracked files, are checked'),
+    runTests: z.boolean().default(true).describe('Run the test packages affected by the change'),
+    pythonLinter: z.enum(['pylint', 'flake8', 'black', 'mypy']).optional(),
+    timeout: z.number().default(600000),
+});
+
+export interface GoPackage {
+    importPath: string;
+    dir: string;
+    deps: string[];
+    testImports: string[];
+}
+
+export interface ChangedStep {
+    name: string;
+    target: string;
+    success: boolean;
+    errors: string[];
+    warnings: string[];
+    tests?: TestReport;
+}
+
+// Tab-separated so import paths and dirs never need escaping
+const GO_LIST_FORMAT = '{{.ImportPath}}\t{{.Dir}}\t{{join .Deps " "}}\t{{join .TestImports " "}} {{join .XTestImports " "}}';
+
+/**
+ * Parse `go list -f GO_LIST_FORMAT` output
+ */
+export function parseGoListPackages(output: string): GoPackage[] {
+    const packages: GoPackage[] = [];
+    for (const line of output.split('\n')) {
+        if (!line.trim()) continue;
+        const [importPath = '', dir = '', deps = '', testImports = ''] = line.split('\t');
+        if (!importPath || !dir) continue;
+        packages.push({
+            importPath,
+            dir,
+            deps: deps.split(' ').filter(Boolean),
+            testImports: testImports.split(' ').filter(Boolean),
+        });
+    }
+    return packages;
+}
+
+/**
+ * Packages containing a changed directory, and every package whose build or tests import one of them
+ */
+export function selectGoPackages(packages: GoPackage[], changedDirs: Set<string>): { changed: string[]; affected: string[] } {
+    const changed = new Set(packages.filter(pkg => changedDirs.has(pkg.dir)).map(pkg => pkg.importPath));
+    const affected = packages
+        .filter(pkg => changed.has(pkg.importPath)
+            || pkg.deps.some(dep => changed.has(dep))
+            || pkg.testImports.some(imp => changed.has(imp)))
+        .map(pkg => pkg.importPath);
+    return { changed: [...changed], affected };
+}
+
+/**
+ * Group changed files (absolute paths) by the lint family that handles them
+ */
+export function groupChangedFiles(files: string[]): Record<'go' | 'python' | 'javascript' | 'typescript' | 'rust', string[]> {
+    const groups = { go: [] as string[], python: [] as string[], javascript: [] as string[], typescript: [] as string[], rust: [] as string[] };
+    for (const file of files) {
+        const ext = extname(file);
+        if (ext === '.go') groups.go.push(file);
+        else if (ext === '.py') groups.python.push(file);
+        else if (['.js', '.mjs', '.cjs', '.jsx'].includes(ext)) groups.javascript.push(file);
+        else if (['.ts', '.tsx', '.mts', '.cts'].includes(ext) && !file.endsWith('.d.ts')) groups.typescript.push(file);
+        else if (ext === '.rs') groups.rust.push(file);
+    }
+    return groups;
+}
+
+async function listChangedFiles(repoPath: string, base: string, timeout: number): Promise<string[]> {
+    const mergeBase = await runCommand(`git merge-base ${shellQuote(base)} HEAD`, { cwd: repoPath, timeout });
+    if (mergeBase.exitCode !== 0) {
+        throw new Error(`Cannot resolve base ref ${base}: ${mergeBase.stderr.trim()}`);
+    }
+    const diff = await runCommand(`git diff --name-only --no-renames ${mergeBase.stdout.trim()}`, { cwd: repoPath, timeout });
+    if (diff.exitCode !== 0) {
+        throw new Error(`git diff failed: ${diff.stderr.trim()}`);
+    }
+    const untracked = await runCommand('git ls-files --others --exclude-standard', { cwd: repoPath, timeout });
+    const files = new Set([...diff.stdout.split('\n'), ...untracked.stdout.split('\n')].map(f => f.trim()).filter(Boolean));
+    return [...files].sort();
+}
+
+async function exists(path: string): Promise<boolean> {
+    try {
+        await fs.access(path);
+        return true;
+    } catch {
+        return false;
+    }
+}
+
+export const feedbackChangedTool = {
+    name: 'feedback_changed',
+    binaries: ['git', 'go'],
+    description: 'Compute the files changed since a base ref and run only the lints relevant to them, plus only the Go test packages that import a changed package. Returns per-step results and structured diagnostics.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { repoPath, base, runTests, pythonLinter, timeout } = parseResult.data;
+        const repoError = await checkRepo(repoPath);
+        if (repoError) {
+            return { success: false, errors: [repoError], warnings: [], output: '' };
+        }
+        try {
+            const effective = await getEffectiveConfig(repoPath);
+            const changedFiles = (await listChangedFiles(repoPath, base, timeout))
+                .filter(f => !isExcluded(effective.config, effective.workspaceRoot, join(repoPath, f)));
+            const feedback = {
+                success: true,
+                errors: [] as string[],
+                warnings: [] as string[],
+                output: '',
+                changedFiles,
+                steps: [] as ChangedStep[],
+                diagnostics: [] as Diagnostic[],
+            };
+            if (changedFiles.length === 0) {
+                feedback.output = `No files changed since ${base}`;
+                return feedback;
+            }
+            const record = (step: ChangedStep, diagnostics: Diagnostic[] = []) => {
+                feedback.steps.push(step);
+                feedback.diagnostics.push(...diagnostics);
+                feedback.errors.push(...step.errors.map(e => `${step.name} ${step.target}: ${e}`));
+                feedback.warnings.push(...step.warnings.map(w => `${step.name} ${step.target}: ${w}`));
+                if (!step.success) feedback.success = false;
+            };
+
+            const absFiles = changedFiles.map(f => join(repoPath, f));
+            const groups = groupChangedFiles(absFiles);
+            // Deleted files only matter for Go, where the package still needs re-checking
+            const present = async (files: string[]) => (await Promise.all(files.map(async f => (await exists(f)) ? f : null))).filter((f): f is string => f !== null);
+
+            // Go: vet the changed packages, test every package that imports them, per module
+            const goModules = new Map<string, Set<string>>();
+            for (const file of groups.go) {
+                const modFile = await findUp(dirname(file), 'go.mod');
+                if (!modFile) continue;
+                const moduleDir = dirname(modFile);
+                if (!goModules.has(moduleDir)) goModules.set(moduleDir, new Set());
+                goModules.get(moduleDir)?.add(dirname(file));
+            }
+            for (const [moduleDir, changedDirs] of goModules) {
+                const target = relative(repoPath, moduleDir) || '.';
+                const list = await runCommand(`go list -e -f ${shellQuote(GO_LIST_FORMAT)} ./...`, { cwd: moduleDir, timeout, maxBuffer: 16 * 1024 * 1024 });
+                if (list.exitCode !== 0) {
+                    record({ name: 'go list', target, success: false, errors: [list.stderr], warnings: [] });
+                    continue;
+                }
+                const { changed, affected } = selectGoPackages(parseGoListPackages(list.stdout), changedDirs);
+                if (changed.length === 0) continue;
+                const vet = await runCommand(`go vet -json ${changed.map(shellQuote).join(' ')}`, { cwd: moduleDir, timeout });
+                const vetDiagnostics = parseGoVetOutput(vet.stdout + vet.stderr, moduleDir);
+                feedback.output += `go vet ${changed.join(' ')}\n${vet.stdout}`;
+                record({
+                    name: 'go vet',
+                    target,
+                    success: vet.exitCode === 0 && vetDiagnostics.length === 0,
+                    errors: vetDiagnostics.length > 0 ? [`${vetDiagnostics.length} finding(s)`] : vet.exitCode !== 0 ? [vet.stderr] : [],
+                    warnings: [],
+                }, vetDiagnostics);
+                if (runTests && affected.length > 0) {
+                    const test = await runCommand(`go test -json ${affected.map(shellQuote).join(' ')}`, { cwd: moduleDir, timeout, maxBuffer: 32 * 1024 * 1024 });
+                    feedback.output += `go test ${affected.join(' ')}\n${goTestJsonText(test.stdout)}`;
+                    const tests = toTestReport(parseGoTestJson(test.stdout));
+                    const failures = tests.failures.map(describeTestFailure);
+                    record({
+                        name: 'go test',
+                        target,
+                        success: test.exitCode === 0,
+                        errors: test.exitCode !== 0 ? (failures.length > 0 ? failures : [test.stderr || goTestJsonText(test.stdout)]) : [],
+                        warnings: [],
+                        tests,
+                    });
+                }
+            }
+
+            // Per-file lints reuse the language tools
+            const fileTools: Array<[string, { run(args: any): Promise<any> }, string[], Record<string, unknown>]> = [
+                ['python', pythonTool, groups.python, pythonLinter ? { linter: pythonLinter } : {}],
+                ['javascript', javascriptTool, groups.javascript, {}],
+                ['typescript', typescriptTool, groups.typescript, {}],
+            ];
+            for (const [name, tool, files, extraArgs] of fileTools) {
+                for (const file of await present(files)) {
+                    const result = await tool.run({ filePath: file, ...extraArgs });
+                    record({
+                        name,
+                        target: relative(repoPath, file),
+                        success: result.success,
+                        errors: result.errors ?? [],
+                        warnings: result.warnings ?? [],
+                    }, result.diagnostics ?? []);
+                }
+            }
+
+            // Rust: one clippy (and optionally test) run per changed crate
+            const crates = new Set<string>();
+            for (const file of await present(groups.rust)) {
+                const manifest = await findUp(dirname(file), 'Cargo.toml');
+                if (manifest) crates.add(manifest);
+            }
+            for (const manifest of crates) {
+                const result = await rustTool.run({ filePath: manifest, actions: runTests ? ['clippy', 'test'] : ['clippy'] });
+                record({
+                    name: 'rust',
+                    target: relative(repoPath, dirname(manifest)) || '.',
+                    success: result.success,
+                    errors: result.errors,
+                    warnings: result.warnings,
+                });
+            }
+
+            feedback.output = `${changedFiles.length} changed file(s), ${feedback.steps.length} check(s) run, ${feedback.steps.filter(s => !s.success).length} failed\n` + feedback.output;
+            return feedback;
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,244 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { isAbsolute, join, relative, resolve, sep } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { walkDirectory } from '../utils/gitignore.js';
+
+// Where GitHub and GitLab look for the file, in their order of precedence
+const CODEOWNERS_LOCATIONS = ['.github/CODEOWNERS', 'CODEOWNERS', 'docs/CODEOWNERS', '.gitlab/CODEOWNERS'];
+
+export interface CodeownersRule {
+    pattern: string;
+    owners: string[];
+    line: number;
+    // GitLab [Section] the rule is in; rules before any header are in the default section
+    section?: string;
+    regex: RegExp;
+}
+
+export interface CodeownersFile {
+    rules: CodeownersRule[];
+    // Lines that could not be used
+    problems: string[];
+}
+
+export interface PathOwners {
+    path: string;
+    owners: string[];
+    // The deciding rule per section
+    rules: Array<{ pattern: string; line: number; section?: string }>;
+}
+
+const OWNER = /^(@[\w.-]+(\/[\w.-]+)?|[^@\s]+@[^@\s]+\.[^@\s]+)$/;
+
+/**
+ * Translate a CODEOWNERS pattern to a regular expression over repository-relative
+ * paths. The gitignore rules apply, with GitHub's exception that a trailing
+ * wildcard segment ("docs/*") does not reach into subdirectories.
+ */
+export function codeownersPatternToRegExp(pattern: string): RegExp {
+    let body = pattern;
+    const directoryOnly = body.endsWith('/');
+    if (directoryOnly) body = body.slice(0, -1);
+    const anchored = body.startsWith('/') || body.includes('/');
+    body = body.replace(/^\//, '');
+
+    let source = '';
+    for (let i = 0; i < body.length; i++) {
+        const char = body[i]!;
+        if (char === '*' && body[i + 1] === '*') {
+            const slashAfter = body[i + 2] === '/';
+            source += slashAfter ? '(?:.*/)?' : '.*';
+            i += slashAfter ? 2 : 1;
+        } else if (char === '*') {
+            source += '[^/]*';
+        } else if (char === '?') {
+            source += '[^/]';
+        } else if (char === '\\' && i + 1 < body.length) {
+            source += body[++i]!.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+        } else {
+            source += char.replace(/[.*+?^${}()|[\]\\/]/g, '\\$&');
+        }
+    }
+    const lastSegment = body.slice(body.lastIndexOf('/') + 1);
+    const suffix = directoryOnly ? '/.*' : /[*?]/.test(lastSegment) && lastSegment !== '**' ? '' : '(?:/.*)?';
+    return new RegExp(`${anchored ? '^' : '^(?:.*/)?'}${source}${suffix}$`);
+}
+
+/**
+ * Parse CODEOWNERS: "pattern owner..." lines, # comments, and GitLab
+ * sections ("[Docs] @docs-team", "^[Optional]") whose default owners apply
+ * to the section's rules that name none.
+ */
+export function parseCodeowners(content: string): CodeownersFile {
+    const rules: CodeownersRule[] = [];
+    const problems: string[] = [];
+    let section: string | undefined;
+    let sectionOwners: string[] = [];
+
+    content.split(/\r?\n/).forEach((raw, index) => {
+        const line = index + 1;
+        // A # starts a comment unless escaped
+        const text = raw.replace(/(^|\s)#.*$/, '').trim();
+        if (!text) return;
+
+        const header = /^\^?\[([^\]]+)\](?:\[\d+\])?\s*(.*)$/.exec(text);
+        if (header) {
+            section = header[1]!.trim();
+            sectionOwners = (header[2] ?? '').split(/\s+/).filter(Boolean);
+            return;
+        }
+
+        const [pattern, ...listed] = text.split(/\s+/);
+        if (!pattern) return;
+        if (pattern.startsWith('!')) {
+            problems.push(`line ${line}: negated patterns are not supported in CODEOWNERS: ${pattern}`);
+            return;
+        }
+        if (/\[[^\]]*\]/.test(pattern)) {
+            problems.push(`line ${line}: character ranges are not supported in CODEOWNERS: ${pattern}`);
+            return;
+        }
+        const invalid = listed.filter(owner => !OWNER.test(owner));
+        if (invalid.length > 0) problems.push(`line ${line}: not a @user, @org/team or email: ${invalid.join(', ')}`);
+        const owners = listed.filter(owner => OWNER.test(owner));
+        rules.push({
+            pattern: pattern.replace(/\\#/g, '#'),
+            owners: owners.length > 0 || listed.length > 0 ? owners : sectionOwners,
+            line,
+            ...(section ? { section } : {}),
+            regex: codeownersPatternToRegExp(pattern.replace(/\\#/g, '#')),
+        });
+    });
+    return { rules, problems };
+}
+
+/**
+ * Owners of a repository-relative path: in each section the last matching rule
+ * wins (a rule without owners leaves the path unowned), and the owners of all
+ * sections are combined
+ */
+export function ownersForPath(file: CodeownersFile, path: string): PathOwners {
+    const normalized = path.split(sep).join('/').replace(/^\.?\//, '');
+    const deciding = new Map<string, CodeownersRule>();
+    for (const rule of file.rules) {
+        if (rule.regex.test(normalized)) deciding.set(rule.section ?? '', rule);
+    }
+    const owners = [...new Set([...deciding.values()].flatMap(rule => rule.owners))];
+    return {
+        path: normalized,
+        owners,
+        rules: [...deciding.values()].map(rule => ({ pattern: rule.pattern, line: rule.line, ...(rule.section ? { section: rule.section } : {}) })),
+    };
+}
+
+/**
+ * Find the CODEOWNERS file of a repository; the owning root is the directory
+ * the patterns are relative to
+ */
+export async function findCodeowners(root: string): Promise<string | null> {
+    for (const location of CODEOWNERS_LOCATIONS) {
+        const path = join(root, location);
+        if (await fs.stat(path).then(s => s.isFile(), () => false)) return path;
+    }
+    return null;
+}
+
+function sameOwner(a: string, b: string): boolean {
+    // GitHub user and team names are case-insensitive
+    return a.toLowerCase() === b.toLowerCase();
+}
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Repository root (where .github/, CODEOWNERS or docs/ live)'),
+    paths: z.array(z.string()).optional().describe('Files or directories to look up, relative to the repository root or absolute'),
+    owner: z.string().optional().describe('Reverse query: list the rules and files owned by this user, team or email, e.g. @org/platform'),
+    maxResults: z.number().int().positive().max(20000).default(1000).describe('Most files listed for an owner query'),
+}).refine(args => Boolean(args.paths?.length) !== Boolean(args.owner), 'Pass either paths or owner');
+
+export const ownersForPathTool = {
+    name: 'owners_for_path',
+    description: 'Look up code owners in the repository\'s CODEOWNERS (.github/, root, docs/ or .gitlab/): the users, teams and emails that own each given path, with the deciding rule and line. With owner instead of paths, lists the CODEOWNERS rules naming that owner and the tracked files they own. Follows GitHub\'s matching (last matching rule wins) and GitLab sections.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { paths, owner, maxResults } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(parseResult.data.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const root = resolve(parseResult.data.projectPath);
+        try {
+            const codeownersPath = await findCodeowners(root);
+            if (!codeownersPath) {
+                return { success: false, errors: [`No CODEOWNERS file in ${root} (looked in ${CODEOWNERS_LOCATIONS.join(', ')})`], warnings: [], output: '' };
+            }
+            const file = parseCodeowners(await fs.readFile(codeownersPath, 'utf-8'));
+            const warnings = file.problems.map(p => `${relative(root, codeownersPath)} ${p}`);
+
+            if (owner) {
+                const rules = file.rules.filter(rule => rule.owners.some(o => sameOwner(o, owner)));
+                const owned: string[] = [];
+                let truncated = false;
+                if (rules.length > 0) {
+                    await walkDirectory(root, { includeHidden: true }, entry => {
+                        if (entry.type !== 'file') return;
+                        if (!ownersForPath(file, entry.relativePath).owners.some(o => sameOwner(o, owner))) return;
+                        if (owned.length >= maxResults) {
+                            truncated = true;
+                            return false;
+                        }
+                        owned.push(entry.relativePath);
+                    });
+                }
+                if (truncated) warnings.push(`Stopped listing files at maxResults ${maxResults}`);
+                return {
+                    success: true,
+                    errors: [],
+                    warnings,
+                    output: rules.length > 0
+                        ? [`${owner} is named by ${rules.length} rule(s) and owns ${owned.length}${truncated ? '+' : ''} file(s)`, ...rules.map(r => `  line ${r.line}: ${r.pattern}${r.section ? ` [${r.section}]` : ''}`)].join('\n')
+                        : `${owner} is not named in ${relative(root, codeownersPath)}`,
+                    codeownersFile: codeownersPath,
+                    owner,
+                    rules: rules.map(r => ({ pattern: r.pattern, line: r.line, owners: r.owners, ...(r.section ? { section: r.section } : {}) })),
+                    paths: owned,
+                    truncated,
+                };
+            }
+
+            const errors: string[] = [];
+            const results: PathOwners[] = [];
+            for (const path of paths ?? []) {
+                const rel = isAbsolute(path) ? relative(root, path) : path;
+                if (rel.startsWith('..')) {
+                    errors.push(`${path} is outside ${root}`);
+                    continue;
+                }
+                results.push(ownersForPath(file, rel || '.'));
+            }
+            const unowned = results.filter(r => r.owners.length === 0);
+            if (unowned.length > 0) warnings.push(`No owners for ${unowned.map(r => r.path).join(', ')}`);
+            return {
+                success: errors.length === 0,
+                errors,
+                warnings,
+                output: results.map(r => `${r.path}: ${r.owners.length > 0 ? r.owners.join(' ') : '(no owners)'}`).join('\n'),
+                codeownersFile: codeownersPath,
+                owners: results,
+                // Everyone to ask about the paths together
+                allOwners: [...new Set(results.flatMap(r => r.owners))],
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
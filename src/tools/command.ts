@@ -0,0 +1,120 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { minimatch } from 'minimatch';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { filterEnv } from '../utils/env.js';
+import { getEffectiveConfig, getToolTimeout, type CommandRule, type ProjectConfig } from '../config/project.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Working directory; relative rule binaries resolve against the workspace root above it'),
+    command: z.string().describe('Binary to run, exactly as named in a commands.allow rule'),
+    args: z.array(z.string()).default([]).describe('Arguments, passed without a shell'),
+    env: z.record(z.string()).default({}).describe('Extra environment variables; only names the policy passes through are accepted'),
+});
+
+const DEFAULT_TIMEOUT = 60000;
+
+export interface CommandDecision {
+    rule: CommandRule;
+    // What actually runs: the bare name, or the binary resolved against the workspace root
+    executable: string;
+    // Names (globs) of host environment variables the command may see
+    envPatterns: string[];
+}
+
+function matchesAny(name: string, patterns: string[]): boolean {
+    return patterns.some(pattern => minimatch(name, pattern));
+}
+
+/**
+ * Find the rule allowing command with args under the policy, or explain why none does
+ */
+export function checkCommand(policy: ProjectConfig['commands'], root: string, command: string, args: string[]): CommandDecision | { error: string } {
+    const rules = policy?.allow ?? [];
+    if (rules.length === 0) return { error: 'No commands are allowed; add rules under commands.allow in the global config' };
+    const isPath = (binary: string) => binary.includes('/') || binary.includes('\\');
+    const candidates = rules.filter(rule => isPath(rule.binary)
+        ? isPath(command) && resolve(root, rule.binary) === resolve(root, command)
+        : rule.binary === command);
+    if (candidates.length === 0) {
+        return { error: `Command not allowed: ${command} (allowed: ${[...new Set(rules.map(r => r.binary))].join(', ')})` };
+    }
+    // Several rules may name the same binary with different argument patterns
+    const rule = candidates.find(r => !r.args || args.every(arg => r.args!.some(pattern => new RegExp(`^(?:${pattern})$`).test(arg))));
+    if (!rule) {
+        const rejected = args.filter(arg => !candidates.some(r => r.args!.some(pattern => new RegExp(`^(?:${pattern})$`).test(arg))));
+        return { error: `Arguments not allowed for ${command}: ${(rejected.length > 0 ? rejected : args).join(' ')}` };
+    }
+    return {
+        rule,
+        executable: isPath(rule.binary) ? resolve(root, rule.binary) : rule.binary,
+        envPatterns: [...(policy?.env ?? []), ...(rule.env ?? [])],
+    };
+}
+
+export const runCommandTool = {
+    name: 'run_command',
+    // Runs whatever binaries the command policy allows
+    dangerous: true,
+    // Project scripts may write anything
+    mutates: true,
+    description: 'Run a project-specific command allowed by the `commands` policy in the server\'s global config. The binary must match an allow rule and every argument one of its patterns; arguments are passed without a shell, the environment is reduced to a baseline plus the variables the policy passes through, and the rule\'s timeout applies.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, command, args: commandArgs, env } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const cwd = (await fs.stat(path)).isDirectory() ? resolve(path) : dirname(resolve(path));
+            const effective = await getEffectiveConfig(cwd);
+            const decision = checkCommand(effective.config.commands, effective.workspaceRoot ?? cwd, command, commandArgs);
+            if ('error' in decision) {
+                return { success: false, errors: [decision.error], warnings: [], output: '' };
+            }
+            if (decision.executable !== decision.rule.binary && !config.isPathAllowed(decision.executable)) {
+                return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+            }
+            const refused = Object.keys(env).filter(name => !matchesAny(name, decision.envPatterns));
+            if (refused.length > 0) {
+                return { success: false, errors: [`Environment variables not allowed for ${command}: ${refused.join(', ')}`], warnings: [], output: '' };
+            }
+
+            const timeout = decision.rule.timeout ?? getToolTimeout(effective.config, 'run_command') ?? DEFAULT_TIMEOUT;
+            const commandLine = [decision.executable, ...commandArgs].map(shellQuote).join(' ');
+            const result = await runCommand(commandLine, {
+                cwd,
+                timeout,
+                env: { ...filterEnv(decision.envPatterns), ...env },
+                inheritEnv: false,
+                maxBuffer: 16 * 1024 * 1024,
+            });
+            return {
+                success: result.exitCode === 0,
+                errors: result.exitCode === 0 ? [] : [`Exited with code ${result.exitCode}${result.stderr ? `: ${result.stderr.trim()}` : ''}`],
+                warnings: result.exitCode === 0 && result.stderr ? [result.stderr.trim()] : [],
+                output: result.stdout,
+                exitCode: result.exitCode,
+                duration: result.duration,
+                timeout,
+                ...(result.limitExceeded ? { limitExceeded: result.limitExceeded } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,267 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig, type ProjectConfig } from '../config/project.js';
+
+export const DEFAULT_COMMIT_TYPES = ['feat', 'fix', 'docs', 'style', 'refactor', 'perf', 'test', 'build', 'ci', 'chore', 'revert'];
+const DEFAULT_MAX_HEADER_LENGTH = 72;
+const DEFAULT_MAX_LINE_LENGTH = 100;
+
+type CommitRules = NonNullable<ProjectConfig['commits']>;
+
+export interface ParsedCommit {
+    header: string;
+    type?: string;
+    scope?: string;
+    breaking: boolean;
+    description?: string;
+    body: string[];
+    footers: Array<{ token: string; separator: ': ' | ' #'; value: string }>;
+}
+
+export interface CommitCheck {
+    errors: string[];
+    warnings: string[];
+    parsed: ParsedCommit;
+    // Messages git creates or rewrites itself are not checked
+    skipped?: string;
+}
+
+const HEADER = /^([A-Za-z]+)(?:\(([^()\r\n]*)\))?(!)?: ?(.*)$/;
+const FOOTER = /^(BREAKING[ -]CHANGE|[\w-]+)(: | #)(.*)$/;
+
+// Common misspellings of a type
+const TYPE_ALIASES: Record<string, string> = {
+    feature: 'feat', features: 'feat', bugfix: 'fix', hotfix: 'fix', doc: 'docs', tests: 'test',
+    refactoring: 'refactor', performance: 'perf', chores: 'chore', builds: 'build',
+};
+
+// First words of free-form subjects, and the type they suggest; the verb is dropped when the type says it
+const VERB_TYPES: Array<[RegExp, string, boolean]> = [
+    [/^(fix|fixed|fixes|fixing)$/i, 'fix', true],
+    [/^(add|adds|added|implement|implements|implemented|introduce|introduces|support|supports)$/i, 'feat', false],
+    [/^(document|documents|documented|docs?)$/i, 'docs', false],
+    [/^(refactor|refactors|refactored|restructure|simplify|simplifies)$/i, 'refactor', true],
+    [/^(test|tests|tested)$/i, 'test', false],
+    [/^(bump|bumps|bumped|upgrade|upgrades|upgraded)$/i, 'build', false],
+    [/^(speed|optimize|optimise|optimizes|optimises)$/i, 'perf', false],
+];
+
+/**
+ * Split a commit message into a conventional-commit header, body and
+ * trailing footers (git comment lines are dropped, as git does)
+ */
+export function parseCommitMessage(message: string): ParsedCommit {
+    const lines = message.replace(/\r\n/g, '\n').split('\n').filter(line => !line.startsWith('#'));
+    while (lines.length > 0 && lines[lines.length - 1]!.trim() === '') lines.pop();
+    const header = lines[0] ?? '';
+    const match = HEADER.exec(header);
+    const rest = lines.slice(1);
+
+    // Footers are the last paragraph when every line of it is a "Token: value" (or continues one)
+    const footers: ParsedCommit['footers'] = [];
+    let bodyEnd = rest.length;
+    const lastBlank = rest.map(line => line.trim() === '').lastIndexOf(true);
+    const paragraph = rest.slice(lastBlank + 1);
+    if (lastBlank >= 0 && paragraph.length > 0 && FOOTER.test(paragraph[0]!)) {
+        for (const line of paragraph) {
+            const footer = FOOTER.exec(line);
+            if (footer) footers.push({ token: footer[1]!, separator: footer[2] as ': ' | ' #', value: footer[3]! });
+            else if (footers.length > 0) footers[footers.length - 1]!.value += `\n${line}`;
+        }
+        bodyEnd = lastBlank;
+    }
+    const body = rest.slice(0, bodyEnd);
+    return {
+        header,
+        ...(match ? { type: match[1]!, description: match[4]! } : {}),
+        ...(match?.[2] !== undefined ? { scope: match[2] } : {}),
+        breaking: Boolean(match?.[3]) || footers.some(f => /^BREAKING[ -]CHANGE$/.test(f.token)),
+        body,
+        footers,
+    };
+}
+
+function isSkipped(header: string): string | undefined {
+    if (/^Merge (branch|pull request|remote-tracking branch|tag) /.test(header)) return 'merge commit';
+    if (/^Revert ".*"$/.test(header)) return 'git revert';
+    if (/^(fixup|squash|amend)! /.test(header)) return 'autosquash commit';
+    return undefined;
+}
+
+/**
+ * Check a message against conventional-commit rules: header shape, type and
+ * scope lists, subject style, line lengths, and required issue references
+ */
+export function checkCommitMessage(message: string, rules: CommitRules = {}): CommitCheck {
+    const parsed = parseCommitMessage(message);
+    const errors: string[] = [];
+    const warnings: string[] = [];
+    const skipped = isSkipped(parsed.header);
+    if (skipped) return { errors, warnings: [`Not checked: ${skipped}`], parsed, skipped };
+
+    const types = rules.types ?? DEFAULT_COMMIT_TYPES;
+    const maxHeader = rules.maxHeaderLength ?? DEFAULT_MAX_HEADER_LENGTH;
+    const maxLine = rules.maxLineLength ?? DEFAULT_MAX_LINE_LENGTH;
+
+    if (!parsed.header.trim()) {
+        errors.push('header-empty: the message is empty');
+        return { errors, warnings, parsed };
+    }
+    if (parsed.type === undefined) {
+        errors.push('header-format: header must look like "type(scope): description"');
+    } else {
+        if (parsed.type !== parsed.type.toLowerCase()) errors.push(`type-case: type "${parsed.type}" must be lowercase`);
+        if (!types.includes(parsed.type.toLowerCase())) errors.push(`type-enum: type "${parsed.type}" is not one of ${types.join(', ')}`);
+        if (parsed.scope !== undefined && !parsed.scope.trim()) errors.push('scope-empty: scope parentheses are empty');
+        if (parsed.scope === undefined && rules.requireScope) errors.push('scope-required: a scope is required');
+        if (parsed.scope && rules.scopes && !rules.scopes.includes(parsed.scope)) errors.push(`scope-enum: scope "${parsed.scope}" is not one of ${rules.scopes.join(', ')}`);
+        const description = parsed.description ?? '';
+        if (!/^[A-Za-z]+(\([^()]*\))?!?: /.test(parsed.header)) errors.push('header-format: a single space must follow the colon');
+        if (!description.trim()) errors.push('subject-empty: description is empty');
+        if (/\.$/.test(description)) errors.push('subject-full-stop: description must not end with a period');
+        // An acronym ("API ...") may stay capitalized
+        if (/^[A-Z][a-z]/.test(description)) errors.push('subject-case: description must start with a lowercase letter');
+    }
+    if (parsed.header.length > maxHeader) errors.push(`header-max-length: header is ${parsed.header.length} characters, the limit is ${maxHeader}`);
+
+    if (parsed.body[0]?.trim()) errors.push('body-leading-blank: a blank line must separate the header from the body');
+    parsed.body.forEach((line, i) => {
+        // URLs and other unbreakable lines cannot be wrapped
+        if (line.length > maxLine && /\s/.test(line.trim())) errors.push(`body-max-line-length: line ${i + 2} is ${line.length} characters, the limit is ${maxLine}`);
+    });
+
+    if (rules.issuePatterns && rules.issuePatterns.length > 0) {
+        const patterns = rules.issuePatterns.map(p => new RegExp(p));
+        if (!patterns.some(p => p.test(message))) errors.push(`references-empty: the message must reference an issue matching ${rules.issuePatterns.join(' or ')}`);
+    }
+    if (parsed.breaking && parsed.type !== undefined && !parsed.footers.some(f => /^BREAKING[ -]CHANGE$/.test(f.token)) && !parsed.body.some(l => l.trim())) {
+        warnings.push('Breaking change without a body or BREAKING CHANGE footer explaining it');
+    }
+    return { errors, warnings, parsed };
+}
+
+function wrap(line: string, width: number): string[] {
+    if (line.length <= width || !/\s/.test(line.trim())) return [line];
+    // List items continue under their text
+    const indent = /^\s*(?:[-*] )?/.exec(line)![0];
+    const [first, ...words] = line.slice(indent.length).trim().split(/\s+/);
+    const out: string[] = [];
+    let current = indent + first;
+    for (const word of words) {
+        if (current.length + 1 + word.length > width) {
+            out.push(current);
+            current = ' '.repeat(indent.length) + word;
+        } else {
+            current += ` ${word}`;
+        }
+    }
+    out.push(current);
+    return out;
+}
+
+/**
+ * Rewrite a message to fix what can be fixed mechanically: type case and
+ * aliases, a missing type (guessed from the first word), subject case and
+ * trailing period, the blank line after the header, and long body lines
+ */
+export function suggestCommitMessage(message: string, rules: CommitRules = {}): string {
+    const parsed = parseCommitMessage(message);
+    if (isSkipped(parsed.header)) return message.trim();
+    const types = rules.types ?? DEFAULT_COMMIT_TYPES;
+    const maxLine = rules.maxLineLength ?? DEFAULT_MAX_LINE_LENGTH;
+
+    let type = parsed.type?.toLowerCase();
+    let scope = parsed.scope?.trim();
+    let description = (parsed.description ?? parsed.header).trim();
+    if (type && !types.includes(type)) type = TYPE_ALIASES[type] && types.includes(TYPE_ALIASES[type]!) ? TYPE_ALIASES[type] : undefined;
+    if (!type) {
+        // "Fix race in watcher" -> "fix: race in watcher"; "[api] Add x" keeps the scope
+        const bracketed = /^\[([^\]]+)\]\s*(.*)$/.exec(description);
+        if (bracketed && !scope) {
+            scope = bracketed[1]!.trim();
+            description = bracketed[2]!;
+        }
+        const [first = '', ...others] = description.split(/\s+/);
+        const guess = VERB_TYPES.find(([pattern, guessed]) => pattern.test(first) && types.includes(guessed));
+        type = guess?.[1] ?? (types.includes('chore') ? 'chore' : types[0]);
+        if (guess?.[2] && others.length > 0) description = others.join(' ');
+    }
+    description = description.replace(/\.+$/, '');
+    if (/^[A-Z][a-z]/.test(description)) description = description[0]!.toLowerCase() + description.slice(1);
+    const bang = parsed.type !== undefined && HEADER.exec(parsed.header)?.[3] ? '!' : '';
+    const header = `${type}${scope ? `(${scope})` : ''}${bang}: ${description}`;
+
+    const body: string[] = [];
+    let fenced = false;
+    for (const line of parsed.body) {
+        if (line.trimStart().startsWith('```')) fenced = !fenced;
+        body.push(...(fenced ? [line] : wrap(line, maxLine)));
+    }
+    while (body.length > 0 && body[0]!.trim() === '') body.shift();
+    const footers = parsed.footers.map(f => `${f.token}${f.separator}${f.value}`);
+    return [header, ...(body.length > 0 ? ['', ...body] : []), ...(footers.length > 0 ? ['', ...footers] : [])].join('\n');
+}
+
+const inputSchema = z.object({
+    message: z.string().describe('The full commit message: header, optional body and footers'),
+    projectPath: z.string().optional().describe('Project whose .code-feedback.yaml `commits` rules apply'),
+    types: z.array(z.string()).optional().describe('Allowed types, overriding the config'),
+    scopes: z.array(z.string()).optional().describe('Allowed scopes, overriding the config'),
+    requireScope: z.boolean().optional(),
+    maxHeaderLength: z.number().int().positive().optional(),
+    maxLineLength: z.number().int().positive().optional(),
+    issuePatterns: z.array(z.string()).optional().describe('Issue reference regexes, one of which must match'),
+});
+
+export const validateCommitMessageTool = {
+    name: 'validate_commit_message',
+    description: 'Check a proposed commit message against the Conventional Commits rules: "type(scope)!: description" header with an allowed type and scope, lowercase description without a trailing period, header and body line lengths, a blank line before the body, and a required issue reference when `commits.issuePatterns` is configured. Rules come from `commits` in .code-feedback.yaml. Returns the violations and a corrected message where they can be fixed mechanically. Merge, revert and fixup! commits are not checked.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { message, projectPath, ...overrides } = parseResult.data;
+        if (projectPath && !Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const configured = projectPath ? (await getEffectiveConfig(projectPath)).config.commits ?? {} : {};
+            const rules: CommitRules = { ...configured };
+            for (const [key, value] of Object.entries(overrides)) {
+                if (value !== undefined) (rules as Record<string, unknown>)[key] = value;
+            }
+            for (const pattern of rules.issuePatterns ?? []) {
+                try {
+                    new RegExp(pattern);
+                } catch (error: any) {
+                    return { success: false, errors: [`Invalid issue pattern ${pattern}: ${error.message}`], warnings: [], output: '' };
+                }
+            }
+
+            const check = checkCommitMessage(message, rules);
+            const suggestion = check.errors.length > 0 ? suggestCommitMessage(message, rules) : undefined;
+            // What a reviewer still has to fix by hand
+            const remaining = suggestion !== undefined ? checkCommitMessage(suggestion, rules).errors : [];
+            return {
+                success: check.errors.length === 0,
+                errors: check.errors,
+                warnings: check.warnings,
+                output: check.errors.length === 0
+                    ? check.skipped ? `Not checked: ${check.skipped}` : 'Commit message follows the conventions'
+                    : `${check.errors.length} problem(s)${suggestion !== undefined ? `\n\nSuggested message:\n${suggestion}` : ''}${remaining.length > 0 ? `\n\nStill to fix by hand:\n${remaining.map(e => `- ${e}`).join('\n')}` : ''}`,
+                parsed: check.parsed,
+                ...(suggestion !== undefined ? { suggestion, remainingErrors: remaining } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
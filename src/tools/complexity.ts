@@ -0,0 +1,336 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, extname, join, relative, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig } from '../config/project.js';
+import { resolveDiagnosticPath, type Diagnostic } from '../diagnostics/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+import { queryGoAst } from './goast.js';
+import { findVenvPython } from './python.js';
+
+const LANGUAGES = ['go', 'python', 'javascript'] as const;
+
+type MetricsLanguage = typeof LANGUAGES[number];
+
+export interface FunctionMetrics {
+    name: string;
+    file: string;
+    line: number;
+    endLine?: number;
+    lines: number;
+    complexity: number;
+    // Not reported for Python (radon does not count them)
+    params?: number;
+    language: MetricsLanguage;
+}
+
+export interface FileMetrics {
+    file: string;
+    lines: number;
+    bytes: number;
+    language: MetricsLanguage;
+}
+
+export interface MetricsThresholds {
+    complexity: number;
+    functionLines: number;
+    params: number;
+    fileLines: number;
+}
+
+export const DEFAULT_THRESHOLDS: MetricsThresholds = { complexity: 15, functionLines: 80, params: 6, fileLines: 1000 };
+
+const EXTENSIONS: Record<string, MetricsLanguage> = {
+    '.go': 'go',
+    '.py': 'python',
+    '.js': 'javascript', '.jsx': 'javascript', '.mjs': 'javascript', '.cjs': 'javascript',
+    '.ts': 'javascript', '.tsx': 'javascript', '.mts': 'javascript', '.cts': 'javascript',
+};
+
+const MARKERS: Array<[string, MetricsLanguage]> = [
+    ['go.mod', 'go'],
+    ['pyproject.toml', 'python'],
+    ['setup.py', 'python'],
+    ['setup.cfg', 'python'],
+    ['package.json', 'javascript'],
+];
+
+const inputSchema = z.object({
+    path: z.string().describe('File or directory to measure'),
+    language: z.enum(['auto', ...LANGUAGES]).default('auto').describe('auto picks by file extension, or by go.mod, pyproject.toml/setup.py and package.json in the directory'),
+    maxComplexity: z.number().int().positive().optional().describe(`Cyclomatic complexity above which a function is flagged; default metrics.complexity in .code-feedback.yaml, else ${DEFAULT_THRESHOLDS.complexity}`),
+    maxFunctionLines: z.number().int().positive().optional().describe(`Function length in lines; default metrics.functionLines, else ${DEFAULT_THRESHOLDS.functionLines}`),
+    maxParams: z.number().int().positive().optional().describe(`Parameter count; default metrics.params, else ${DEFAULT_THRESHOLDS.params}`),
+    maxFileLines: z.number().int().positive().optional().describe(`File length in lines; default metrics.fileLines, else ${DEFAULT_THRESHOLDS.fileLines}`),
+    includeTests: z.boolean().default(false).describe('Include Go _test.go files'),
+    limit: z.number().int().positive().max(1000).default(50).describe('Functions listed, most complex first'),
+    timeout: z.number().default(300000),
+});
+
+async function detectLanguages(path: string, isDirectory: boolean): Promise<MetricsLanguage[]> {
+    if (!isDirectory) {
+        const language = EXTENSIONS[extname(path).toLowerCase()];
+        return language ? [language] : [];
+    }
+    const found = new Set<MetricsLanguage>();
+    for (const [marker, language] of MARKERS) {
+        if (await fs.stat(join(path, marker)).then(() => true, () => false)) found.add(language);
+    }
+    if (found.size === 0) {
+        // No manifest: go by the files at the top of the directory
+        for (const entry of await fs.readdir(path)) {
+            const language = EXTENSIONS[extname(entry).toLowerCase()];
+            if (language) found.add(language);
+        }
+    }
+    return LANGUAGES.filter(language => found.has(language));
+}
+
+/**
+ * Parse `radon cc -j` output: functions and methods (class totals are left
+ * out) and every file radon read
+ */
+export function parseRadonOutput(output: string, cwd: string): { functions: FunctionMetrics[]; files: string[]; errors: string[] } {
+    const start = output.indexOf('{');
+    if (start < 0) return { functions: [], files: [], errors: [] };
+    const parsed = JSON.parse(output.slice(start));
+    const functions: FunctionMetrics[] = [];
+    const files: string[] = [];
+    const errors: string[] = [];
+    for (const [name, blocks] of Object.entries<any>(parsed)) {
+        const file = resolveDiagnosticPath(name, cwd);
+        if (!Array.isArray(blocks)) {
+            errors.push(`${name}: ${blocks?.error ?? 'not analyzed'}`);
+            continue;
+        }
+        files.push(file);
+        const seen = new Set<string>();
+        const add = (block: any) => {
+            if (block?.type !== 'function' && block?.type !== 'method') return;
+            const fullName = block.classname ? `${block.classname}.${block.name}` : String(block.name);
+            const key = `${fullName}:${block.lineno}`;
+            if (seen.has(key)) return;
+            seen.add(key);
+            const line = Number(block.lineno ?? 0);
+            const endLine = Number(block.endline ?? line);
+            functions.push({ name: fullName, file, line, endLine, lines: endLine - line + 1, complexity: Number(block.complexity ?? 1), language: 'python' });
+        };
+        for (const block of blocks) {
+            add(block);
+            for (const method of block?.methods ?? []) add(method);
+        }
+    }
+    return { functions, files, errors };
+}
+
+// "Function 'parse' has a complexity of 7. Maximum allowed is 0.", "Method 'run' has too many parameters (4)."
+const ESLINT_METRIC = /^(.+?) has (?:a complexity of (\d+)|too many (parameters|lines) \((\d+)\))/;
+
+/**
+ * Parse ESLint JSON output run with the complexity, max-params and
+ * max-lines-per-function rules at 0, so they report every function; other
+ * rules' messages are ignored
+ */
+export function parseEslintMetricsOutput(output: string, cwd: string): { functions: FunctionMetrics[]; files: string[] } {
+    const start = output.indexOf('[');
+    if (start < 0) return { functions: [], files: [] };
+    const results = JSON.parse(output.slice(start));
+    const functions = new Map<string, FunctionMetrics>();
+    const files: string[] = [];
+    for (const result of Array.isArray(results) ? results : []) {
+        const file = resolveDiagnosticPath(String(result.filePath ?? ''), cwd);
+        files.push(file);
+        for (const message of result.messages ?? []) {
+            if (!['complexity', 'max-params', 'max-lines-per-function'].includes(message.ruleId)) continue;
+            const match = ESLINT_METRIC.exec(String(message.message ?? ''));
+            if (!match) continue;
+            const label = match[1]!;
+            const line = Number(message.line ?? 0);
+            const key = `${file}:${line}:${label}`;
+            const entry = functions.get(key) ?? {
+                name: /'([^']+)'/.exec(label)?.[1] ?? label.toLowerCase(),
+                file,
+                line,
+                lines: 0,
+                complexity: 1,
+                params: 0,
+                language: 'javascript' as const,
+            };
+            if (match[2]) entry.complexity = Number(match[2]);
+            else if (match[3] === 'parameters') entry.params = Number(match[4]);
+            else {
+                entry.lines = Number(match[4]);
+                if (message.endLine) entry.endLine = Number(message.endLine);
+            }
+            functions.set(key, entry);
+        }
+    }
+    return { functions: [...functions.values()], files };
+}
+
+async function measureFile(file: string, language: MetricsLanguage): Promise<FileMetrics | null> {
+    try {
+        const content = await fs.readFile(file, 'utf-8');
+        const newlines = content.split('\n').length - 1;
+        return { file, lines: newlines + (content.length > 0 && !content.endsWith('\n') ? 1 : 0), bytes: Buffer.byteLength(content), language };
+    } catch {
+        return null;
+    }
+}
+
+async function measureGo(path: string, tests: boolean, timeout: number): Promise<{ functions: FunctionMetrics[]; files: FileMetrics[]; warnings: string[] }> {
+    const parsed = await queryGoAst(path, 'metrics', { tests, timeout });
+    return {
+        functions: parsed.results.map(r => ({ name: r.name, file: r.file, line: r.line, endLine: r.endLine, lines: r.lines, complexity: r.complexity, params: r.params, language: 'go' as const })),
+        files: (parsed.fileStats ?? []).map(f => ({ file: f.file, lines: f.lines, bytes: f.bytes, language: 'go' as const })),
+        warnings: (parsed.parseErrors ?? []).map(e => e.message),
+    };
+}
+
+async function measurePython(path: string, cwd: string, timeout: number): Promise<{ functions: FunctionMetrics[]; files: FileMetrics[]; warnings: string[] }> {
+    const python = (await findVenvPython(cwd)) || 'python';
+    const command = `${shellQuote(python)} -m radon cc -j --exclude '*/.venv/*,*/venv/*,*/node_modules/*' ${shellQuote(path)}`;
+    const result = await runCommand(command, { cwd, timeout, maxBuffer: 32 * 1024 * 1024 });
+    if (result.exitCode !== 0) throw new Error(`radon failed (pip install radon): ${result.stderr || result.stdout}`);
+    const parsed = parseRadonOutput(result.stdout, cwd);
+    const files = (await Promise.all(parsed.files.map(file => measureFile(file, 'python')))).filter((f): f is FileMetrics => f !== null);
+    return { functions: parsed.functions, files, warnings: parsed.errors };
+}
+
+async function measureJavaScript(path: string, startDir: string, timeout: number): Promise<{ functions: FunctionMetrics[]; files: FileMetrics[]; warnings: string[] }> {
+    // The project's ESLint config supplies the parser (TypeScript, JSX); the metric rules are switched on here
+    const packageJson = await findUp(startDir, 'package.json');
+    const cwd = packageJson ? dirname(packageJson) : startDir;
+    const local = await findUp(cwd, join('node_modules', '.bin', 'eslint'));
+    const rules = ['complexity: [1, 0]', 'max-params: [1, 0]', 'max-lines-per-function: [1, {max: 0}]'].map(rule => `--rule ${shellQuote(rule)}`).join(' ');
+    const command = `${local ? shellQuote(local) : 'npx --no-install eslint'} --format json ${rules} ${shellQuote(path)}`;
+    const result = await runCommand(command, { cwd, timeout, maxBuffer: 64 * 1024 * 1024 });
+    // Exit code 1 means lint findings; 2 is a configuration or crash error
+    if (result.exitCode !== 0 && result.exitCode !== 1) throw new Error(`eslint failed: ${result.stderr || result.stdout}`);
+    const parsed = parseEslintMetricsOutput(result.stdout, cwd);
+    const files = (await Promise.all(parsed.files.map(file => measureFile(file, 'javascript')))).filter((f): f is FileMetrics => f !== null);
+    return { functions: parsed.functions, files, warnings: [] };
+}
+
+/**
+ * Warnings for the functions and files over a threshold
+ */
+export function checkThresholds(functions: FunctionMetrics[], files: FileMetrics[], thresholds: MetricsThresholds): Diagnostic[] {
+    const diagnostics: Diagnostic[] = [];
+    const flag = (file: string, line: number, rule: string, message: string) =>
+        diagnostics.push({ file, line, column: 0, severity: 'warning', message, rule, source: 'code_metrics' });
+    for (const fn of functions) {
+        if (fn.complexity > thresholds.complexity) {
+            flag(fn.file, fn.line, 'complexity', `${fn.name} has a cyclomatic complexity of ${fn.complexity} (threshold ${thresholds.complexity})`);
+        }
+        if (fn.lines > thresholds.functionLines) {
+            flag(fn.file, fn.line, 'function-length', `${fn.name} is ${fn.lines} lines long (threshold ${thresholds.functionLines})`);
+        }
+        if (fn.params !== undefined && fn.params > thresholds.params) {
+            flag(fn.file, fn.line, 'parameters', `${fn.name} takes ${fn.params} parameters (threshold ${thresholds.params})`);
+        }
+    }
+    for (const file of files) {
+        if (file.lines > thresholds.fileLines) {
+            flag(file.file, 0, 'file-length', `File is ${file.lines} lines long (threshold ${thresholds.fileLines})`);
+        }
+    }
+    return diagnostics;
+}
+
+export const codeMetricsTool = {
+    name: 'code_metrics',
+    cacheable: true,
+    description: 'Measure code: cyclomatic complexity, length in lines and parameter count of every function, and the size of every file. Go is parsed with go/ast (complexity counted as gocyclo does), Python measured with radon, and JavaScript/TypeScript with ESLint\'s complexity, max-params and max-lines-per-function rules under the project\'s ESLint config. Functions and files over the thresholds (arguments, else metrics in .code-feedback.yaml, else complexity 15, 80 lines, 6 parameters, 1000-line files) are returned as warning diagnostics, such as "parseArgs has a cyclomatic complexity of 23 (threshold 15)". Lists the most complex functions first.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, language, includeTests, limit, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const isDirectory = (await fs.stat(path)).isDirectory();
+            const root = isDirectory ? resolve(path) : dirname(resolve(path));
+            const languages = language === 'auto' ? await detectLanguages(resolve(path), isDirectory) : [language];
+            if (languages.length === 0) {
+                return { success: false, errors: ['Could not tell the language; pass language (go, python or javascript)'], warnings: [], output: '' };
+            }
+            const configured = (await getEffectiveConfig(path)).config.metrics ?? {};
+            const thresholds: MetricsThresholds = {
+                complexity: parseResult.data.maxComplexity ?? configured.complexity ?? DEFAULT_THRESHOLDS.complexity,
+                functionLines: parseResult.data.maxFunctionLines ?? configured.functionLines ?? DEFAULT_THRESHOLDS.functionLines,
+                params: parseResult.data.maxParams ?? configured.params ?? DEFAULT_THRESHOLDS.params,
+                fileLines: parseResult.data.maxFileLines ?? configured.fileLines ?? DEFAULT_THRESHOLDS.fileLines,
+            };
+
+            const functions: FunctionMetrics[] = [];
+            const files: FileMetrics[] = [];
+            const warnings: string[] = [];
+            const errors: string[] = [];
+            for (const lang of languages) {
+                try {
+                    const measured = lang === 'go'
+                        ? await measureGo(path, includeTests, timeout)
+                        : lang === 'python'
+                            ? await measurePython(resolve(path), root, timeout)
+                            : await measureJavaScript(resolve(path), root, timeout);
+                    functions.push(...measured.functions);
+                    files.push(...measured.files);
+                    warnings.push(...measured.warnings);
+                } catch (error: any) {
+                    // One language failing leaves the others' numbers usable
+                    errors.push(`${lang}: ${error.message || String(error)}`);
+                }
+            }
+            if (errors.length === languages.length) {
+                return { success: false, errors, warnings, output: '' };
+            }
+            warnings.push(...errors);
+
+            functions.sort((a, b) => b.complexity - a.complexity || b.lines - a.lines || a.file.localeCompare(b.file) || a.line - b.line);
+            const diagnostics = checkThresholds(functions, files, thresholds);
+            const totalComplexity = functions.reduce((sum, fn) => sum + fn.complexity, 0);
+            const worst = functions[0];
+            const totals = {
+                functions: functions.length,
+                files: files.length,
+                lines: files.reduce((sum, file) => sum + file.lines, 0),
+                averageComplexity: functions.length > 0 ? Math.round((totalComplexity / functions.length) * 10) / 10 : 0,
+                maxComplexity: worst?.complexity ?? 0,
+                overThreshold: diagnostics.length,
+            };
+            const display = (file: string) => relative(root, file) || file;
+            const lines = [
+                `${totals.functions} function(s) in ${totals.files} file(s): average complexity ${totals.averageComplexity}, max ${totals.maxComplexity}${worst ? ` (${worst.name} at ${display(worst.file)}:${worst.line})` : ''}; ${diagnostics.length} over a threshold`,
+                ...functions.slice(0, limit).map(fn => `${String(fn.complexity).padStart(4)}  ${display(fn.file)}:${fn.line} ${fn.name} (${fn.lines} lines${fn.params !== undefined ? `, ${fn.params} params` : ''})`),
+                ...(functions.length > limit ? [`... ${functions.length - limit} more`] : []),
+            ];
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: lines.join('\n'),
+                languages,
+                thresholds,
+                totals,
+                functions: functions.slice(0, limit),
+                files: [...files].sort((a, b) => b.lines - a.lines),
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
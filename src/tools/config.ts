@@ -0,0 +1,61 @@
+import { z } from 'zod';
+import Config from '../config/index.js';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { getEffectiveConfig } from '../config/project.js';
+import { resolveWorkspaceEnv } from '../config/env.js';
+import { redactResult } from '../utils/env.js';
+import { logger } from '../utils/logger.js';
+import { tracer } from '../tracing/index.js';
+
+const inputSchema = z.object({
+    path: z.string().optional().describe('File or directory whose project config (.code-feedback.yaml) should be resolved'),
+});
+
+export const getConfigTool = {
+    name: 'get_config',
+    description: 'Show the effective configuration: global config merged with the .code-feedback.yaml governing a path (enabled tools, timeouts, env with .env files and secrets redacted, build tags, excludes), plus server settings.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path } = parseResult.data;
+        const config = Config.getInstance();
+        if (path && !config.isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const effective = await getEffectiveConfig(path);
+            const sources = [effective.globalConfigPath, effective.projectConfigPath].filter(Boolean);
+            const workspaceEnv = await resolveWorkspaceEnv(effective.config, effective.workspaceRoot);
+            return {
+                success: true,
+                errors: [],
+                warnings: workspaceEnv.warnings,
+                output: sources.length > 0 ? `Config loaded from ${sources.join(', ')}` : 'No config files found; using defaults',
+                ...effective,
+                // Secrets show as [redacted:NAME]: which variables are set, never their values
+                commandEnv: redactResult(workspaceEnv.env, workspaceEnv.secrets),
+                envFiles: workspaceEnv.files,
+                server: {
+                    allowedPaths: config.getAllowedPaths(),
+                    readOnlyPaths: config.getReadOnlyPaths(),
+                    executor: config.getExecutor(),
+                    cache: config.isCacheEnabled(),
+                    toolchains: config.getToolchainMode(),
+                    logLevel: logger.getLevel(),
+                    logFormat: logger.getFormat(),
+                    tracing: tracer.getEndpoint() ?? null,
+                },
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
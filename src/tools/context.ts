@@ -0,0 +1,78 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { buildContextPack, type ContextSource } from '../context/index.js';
+import { collectSourceFiles } from '../utils/sourcefiles.js';
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Project root whose code graph is searched; .gitignore is respected'),
+    files: z.array(z.string()).default([]).describe('Seed files, absolute or relative to projectPath'),
+    symbols: z.array(z.string()).default([]).describe('Seed symbols (functions, types, classes, methods) whose definitions and users are wanted; "Store.Get" looks up Get'),
+    maxBytes: z.number().int().min(1024).max(1024 * 1024).default(64 * 1024).describe('Size of the bundle in bytes of code (about 4 bytes per token)'),
+    maxDepth: z.number().int().min(0).max(5).default(2).describe('Import hops followed from the seeds'),
+    includeDependents: z.boolean().default(true).describe('Also follow reverse imports: files that use the seeds'),
+    includeTests: z.boolean().default(false).describe('Consider test files beyond the seeds'),
+    exclude: z.array(z.string()).default([]).describe('Globs (relative to projectPath) of files to leave out'),
+}).refine(args => args.files.length > 0 || args.symbols.length > 0, { message: 'Pass at least one seed file or symbol', path: ['files'] });
+
+export const buildContextTool = {
+    name: 'build_context',
+    cacheable: true,
+    description: 'Assemble a size-bounded bundle of the code most relevant to a task, starting from seed files and/or symbols. The server follows imports (Go packages, Python modules, JavaScript/TypeScript relative imports) from the seeds and, optionally, back to the files that import them, finds where each symbol is declared and which files mention it, ranks everything by distance from the seeds, and fills maxBytes most relevant first. Files come whole when they fit; otherwise as the requested definitions, an outline of their declaration lines, or (for seeds) their head. Every entry has its path, line ranges and the reason it was included; files that did not fit are listed under omitted.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, files, symbols, maxBytes, maxDepth, includeDependents, includeTests, exclude } = parseResult.data;
+        const seeds = files.map(file => resolve(projectPath, file));
+        if (!Config.getInstance().isPathAllowed(projectPath) || seeds.some(seed => !Config.getInstance().isPathAllowed(seed))) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const root = resolve(projectPath);
+            const collected = await collectSourceFiles(root, { exclude, includeTests });
+            const sources: ContextSource[] = collected.files;
+            const known = new Set(sources.map(s => s.path));
+            const errors: string[] = [];
+            // Seeds count even when the walk skips them: tests, generated or ignored files
+            for (const seed of seeds) {
+                if (known.has(seed)) continue;
+                try {
+                    sources.push({ path: seed, source: await fs.readFile(seed, 'utf-8') });
+                    known.add(seed);
+                } catch (error: any) {
+                    errors.push(`Cannot read seed ${seed}: ${error.message || String(error)}`);
+                }
+            }
+            if (errors.length > 0) return { success: false, errors, warnings: collected.warnings, output: '' };
+
+            const pack = await buildContextPack(root, sources, { files: seeds, symbols, maxBytes, maxDepth, includeDependents });
+            const warnings = [
+                ...collected.warnings,
+                ...pack.unresolved.map(symbol => `No definition of ${symbol} found`),
+                ...(pack.omitted.length > 0 ? [`${pack.omitted.length} relevant file(s) did not fit in ${maxBytes} bytes`] : []),
+            ];
+            const summary = `${pack.entries.length} file(s), ${pack.totalBytes} of ${maxBytes} bytes`;
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: `${summary}\n\n${pack.entries.map(e => e.content).join('\n')}`,
+                files: pack.entries.map(({ content, ...entry }) => entry),
+                omitted: pack.omitted,
+                totalBytes: pack.totalBytes,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
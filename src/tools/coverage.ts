@@ -0,0 +1,354 @@
+import { z } from 'zod';
+import { runCommand } from '../utils/command.js';
+import Config from '../config/index.js';
+import { join, isAbsolute, relative, resolve } from 'path';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { findVenvPython } from './python.js';
+import { shellQuote } from '../utils/shell.js';
+
+export interface LineRange {
+    start: number;
+    end: number;
+}
+
+export interface FunctionCoverage {
+    name: string;
+    line: number;
+    percent: number;
+}
+
+export interface FileCoverage {
+    file: string;
+    percent: number;
+    coveredStatements: number;
+    totalStatements: number;
+    uncoveredRanges: LineRange[];
+    functions: FunctionCoverage[];
+}
+
+export interface CoverageReport {
+    totalPercent: number;
+    files: FileCoverage[];
+}
+
+function percentOf(covered: number, total: number): number {
+    return total === 0 ? 100 : Math.round((covered / total) * 10000) / 100;
+}
+
+/**
+ * Collapse a set of line numbers into sorted, contiguous ranges
+ */
+export function toLineRanges(lines: Iterable<number>): LineRange[] {
+    const sorted = [...new Set(lines)].sort((a, b) => a - b);
+    const ranges: LineRange[] = [];
+    for (const line of sorted) {
+        const last = ranges[ranges.length - 1];
+        if (last && line === last.end + 1) {
+            last.end = line;
+        } else {
+            ranges.push({ start: line, end: line });
+        }
+    }
+    return ranges;
+}
+
+// --- Go ---
+
+/**
+ * Parse a `go test -coverprofile` file. `funcOutput` is the optional
+ * `go tool cover -func` output used for the per-function breakdown.
+ */
+export function parseGoCoverProfile(profile: string, funcOutput: string = ''): CoverageReport {
+    const byFile = new Map<string, { covered: number; total: number; uncovered: Set<number> }>();
+    // file:startLine.startCol,endLine.endCol numStmt count
+    const blockPattern = /^(.+):(\d+)\.\d+,(\d+)\.\d+ (\d+) (\d+)$/;
+    for (const line of profile.split('\n')) {
+        const match = blockPattern.exec(line.trim());
+        if (!match) continue;
+        const [, file = '', start = '0', end = '0', statements = '0', count = '0'] = match;
+        const entry = byFile.get(file) ?? { covered: 0, total: 0, uncovered: new Set<number>() };
+        entry.total += Number(statements);
+        if (Number(count) > 0) {
+            entry.covered += Number(statements);
+        } else {
+            for (let l = Number(start); l <= Number(end); l++) entry.uncovered.add(l);
+        }
+        byFile.set(file, entry);
+    }
+
+    const functionsByFile = new Map<string, FunctionCoverage[]>();
+    let totalFromFunc: number | null = null;
+    for (const line of funcOutput.split('\n')) {
+        const parts = line.trim().split(/\s+/);
+        if (parts.length < 3) continue;
+        const [location = '', name = '', percent = ''] = parts;
+        if (location === 'total:') {
+            totalFromFunc = parseFloat(percent);
+            continue;
+        }
+        const locationMatch = /^(.+):(\d+):$/.exec(location);
+        if (!locationMatch) continue;
+        const [, file = '', lineNo = '0'] = locationMatch;
+        const functions = functionsByFile.get(file) ?? [];
+        functions.push({ name, line: Number(lineNo), percent: parseFloat(percent) });
+        functionsByFile.set(file, functions);
+    }
+
+    let covered = 0;
+    let total = 0;
+    const files: FileCoverage[] = [];
+    for (const [file, entry] of byFile) {
+        covered += entry.covered;
+        total += entry.total;
+        files.push({
+            file,
+            percent: percentOf(entry.covered, entry.total),
+            coveredStatements: entry.covered,
+            totalStatements: entry.total,
+            uncoveredRanges: toLineRanges(entry.uncovered),
+            functions: functionsByFile.get(file) ?? [],
+        });
+    }
+    return { totalPercent: totalFromFunc ?? percentOf(covered, total), files };
+}
+
+// Map import-path file names from the profile back to paths relative to the module root
+async function relativizeGoFiles(report: CoverageReport, projectPath: string): Promise<void> {
+    try {
+        const goMod = await fs.readFile(join(projectPath, 'go.mod'), 'utf-8');
+        const modulePath = /^module\s+(\S+)/m.exec(goMod)?.[1];
+        if (!modulePath) return;
+        for (const file of report.files) {
+            if (file.file.startsWith(`${modulePath}/`)) {
+                file.file = file.file.slice(modulePath.length + 1);
+            }
+        }
+    } catch { /* no go.mod: keep import paths */ }
+}
+
+// --- Python (coverage.py) ---
+
+/**
+ * Parse the report written by `coverage json`
+ */
+export function parseCoveragePyJson(json: any): CoverageReport {
+    const files: FileCoverage[] = [];
+    for (const [file, data] of Object.entries<any>(json.files ?? {})) {
+        const summary = data.summary ?? {};
+        const functions: FunctionCoverage[] = [];
+        for (const [name, fn] of Object.entries<any>(data.functions ?? {})) {
+            if (!name) continue; // module-level code is reported under ""
+            const executed: number[] = fn.executed_lines ?? [];
+            const missing: number[] = fn.missing_lines ?? [];
+            const lines = [...executed, ...missing];
+            functions.push({
+                name,
+                line: lines.length > 0 ? Math.min(...lines) : 0,
+                percent: fn.summary?.percent_covered ?? percentOf(executed.length, executed.length + missing.length),
+            });
+        }
+        files.push({
+            file,
+            percent: summary.percent_covered ?? 0,
+            coveredStatements: summary.covered_lines ?? 0,
+            totalStatements: summary.num_statements ?? 0,
+            uncoveredRanges: toLineRanges(data.missing_lines ?? []),
+            functions,
+        });
+    }
+    return { totalPercent: json.totals?.percent_covered ?? 0, files };
+}
+
+// --- Node (istanbul coverage-final.json from c8/nyc) ---
+
+/**
+ * Parse an istanbul coverage-final.json map
+ */
+export function parseIstanbulCoverage(json: any, projectPath: string): CoverageReport {
+    const files: FileCoverage[] = [];
+    let covered = 0;
+    let total = 0;
+    for (const [filePath, data] of Object.entries<any>(json ?? {})) {
+        const statementMap = data.statementMap ?? {};
+        const counts = data.s ?? {};
+        let fileCovered = 0;
+        let fileTotal = 0;
+        const uncovered = new Set<number>();
+        for (const [id, location] of Object.entries<any>(statementMap)) {
+            fileTotal++;
+            if ((counts[id] ?? 0) > 0) {
+                fileCovered++;
+            } else {
+                for (let l = location.start.line; l <= location.end.line; l++) uncovered.add(l);
+            }
+        }
+        const functions: FunctionCoverage[] = Object.entries<any>(data.fnMap ?? {}).map(([id, fn]) => ({
+            name: fn.name,
+            line: fn.decl?.start?.line ?? fn.loc?.start?.line ?? 0,
+            percent: (data.f?.[id] ?? 0) > 0 ? 100 : 0,
+        }));
+        covered += fileCovered;
+        total += fileTotal;
+        files.push({
+            file: isAbsolute(filePath) ? relative(projectPath, filePath) : filePath,
+            percent: percentOf(fileCovered, fileTotal),
+            coveredStatements: fileCovered,
+            totalStatements: fileTotal,
+            uncoveredRanges: toLineRanges(uncovered),
+            functions,
+        });
+    }
+    return { totalPercent: percentOf(covered, total), files };
+}
+
+// --- Tools ---
+
+const goCoverageSchema = z.object({
+    projectPath: z.string().describe('Go module or package directory'),
+    packages: z.string().default('./...').describe('Space-separated package patterns to test'),
+    timeout: z.number().default(300000),
+});
+
+const pythonCoverageSchema = z.object({
+    projectPath: z.string(),
+    testCommand: z.string().default('-m pytest').describe('Arguments passed to `coverage run` (default: -m pytest)'),
+    timeout: z.number().default(300000),
+});
+
+const nodeCoverageSchema = z.object({
+    projectPath: z.string(),
+    runner: z.enum(['c8', 'nyc']).default('c8'),
+    testCommand: z.string().default('npm test'),
+    timeout: z.number().default(300000),
+});
+
+function validationFailure(error: z.ZodError) {
+    return {
+        success: false,
+        errors: error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+        warnings: [] as string[],
+        output: '',
+    };
+}
+
+export const goCoverageTool = {
+    name: 'go_coverage',
+    binaries: ['go'],
+    cacheable: true,
+    description: 'Run `go test -coverprofile` and return structured coverage: total %, per-file % with uncovered line ranges, and per-function %.',
+    inputSchema: zodToJsonSchema(goCoverageSchema),
+    async run(args: any) {
+        const parseResult = goCoverageSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { projectPath, packages, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const workDir = await fs.mkdtemp(join(tmpdir(), 'cf-go-cover-'));
+        try {
+            const profilePath = join(workDir, 'cover.out');
+            const testResult = await runCommand(`go test -coverprofile=${shellQuote(profilePath)} ${packages.split(/\s+/).filter(Boolean).map(shellQuote).join(' ')}`, { cwd: projectPath, timeout });
+            let profile = '';
+            try {
+                profile = await fs.readFile(profilePath, 'utf-8');
+            } catch {
+                return { success: false, errors: [`No coverage profile produced: ${testResult.stderr || testResult.stdout}`], warnings: [], output: testResult.stdout };
+            }
+            const funcResult = await runCommand(`go tool cover -func="${profilePath}"`, { cwd: projectPath });
+            const coverage = parseGoCoverProfile(profile, funcResult.stdout);
+            await relativizeGoFiles(coverage, resolve(projectPath));
+            return {
+                success: testResult.exitCode === 0,
+                errors: testResult.exitCode !== 0 ? [`Tests failed: ${testResult.stderr || testResult.stdout}`] : [],
+                warnings: [],
+                output: testResult.stdout,
+                coverage,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
+
+export const pythonCoverageTool = {
+    name: 'python_coverage',
+    cacheable: true,
+    description: 'Run tests under coverage.py and return structured coverage: total %, per-file % with uncovered line ranges, and per-function % (coverage 7.5+).',
+    inputSchema: zodToJsonSchema(pythonCoverageSchema),
+    async run(args: any) {
+        const parseResult = pythonCoverageSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { projectPath, testCommand, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const workDir = await fs.mkdtemp(join(tmpdir(), 'cf-py-cover-'));
+        try {
+            const pythonExec = (await findVenvPython(projectPath)) || 'python';
+            const dataFile = join(workDir, '.coverage');
+            const reportPath = join(workDir, 'coverage.json');
+            const env = { COVERAGE_FILE: dataFile };
+            const testResult = await runCommand(`${pythonExec} -m coverage run ${testCommand}`, { cwd: projectPath, timeout, env });
+            const reportResult = await runCommand(`${pythonExec} -m coverage json -o "${reportPath}"`, { cwd: projectPath, env });
+            if (reportResult.exitCode !== 0) {
+                return { success: false, errors: [`coverage json failed: ${reportResult.stderr || testResult.stderr}`], warnings: [], output: testResult.stdout };
+            }
+            const coverage = parseCoveragePyJson(JSON.parse(await fs.readFile(reportPath, 'utf-8')));
+            return {
+                success: testResult.exitCode === 0,
+                errors: testResult.exitCode !== 0 ? [`Tests failed: ${testResult.stderr || testResult.stdout}`] : [],
+                warnings: [],
+                output: testResult.stdout,
+                coverage,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
+
+export const nodeCoverageTool = {
+    name: 'node_coverage',
+    cacheable: true,
+    description: 'Run the test command under c8 or nyc and return structured coverage: total %, per-file % with uncovered line ranges, and per-function hit status.',
+    inputSchema: zodToJsonSchema(nodeCoverageSchema),
+    async run(args: any) {
+        const parseResult = nodeCoverageSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { projectPath, runner, testCommand, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const workDir = await fs.mkdtemp(join(tmpdir(), 'cf-node-cover-'));
+        try {
+            const command = runner === 'nyc'
+                ? `npx nyc --reporter=json --report-dir="${workDir}" ${testCommand}`
+                : `npx c8 --reporter=json --reports-dir="${workDir}" ${testCommand}`;
+            const testResult = await runCommand(command, { cwd: projectPath, timeout });
+            let raw: string;
+            try {
+                raw = await fs.readFile(join(workDir, 'coverage-final.json'), 'utf-8');
+            } catch {
+                return { success: false, errors: [`No coverage report produced: ${testResult.stderr || testResult.stdout}`], warnings: [], output: testResult.stdout };
+            }
+            const coverage = parseIstanbulCoverage(JSON.parse(raw), resolve(projectPath));
+            return {
+                success: testResult.exitCode === 0,
+                errors: testResult.exitCode !== 0 ? [`Tests failed: ${testResult.stderr || testResult.stdout}`] : [],
+                warnings: [],
+                output: testResult.stdout,
+                coverage,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
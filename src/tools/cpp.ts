@@ -0,0 +1,248 @@
+import { z } from 'zod';
+import { runCommand } from '../utils/command.js';
+import Config, { isWithin } from '../config/index.js';
+import { promises as fs } from 'fs';
+import { cpus } from 'os';
+import { dirname, isAbsolute, join, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+import { type Diagnostic, parseClangOutput, parseCMakeOutput, countBySeverity } from '../diagnostics/index.js';
+
+export interface CompileCommand {
+    directory: string;
+    file: string;
+    command?: string;
+    arguments?: string[];
+}
+
+// Build directories people commonly configure into
+const BUILD_DIR_CANDIDATES = ['build', 'out', 'cmake-build-debug', 'cmake-build-release', 'build/debug', 'build/release'];
+const CPP_SOURCE_PATTERN = /\.(c|cc|cpp|cxx|c\+\+|m|mm)$/i;
+const MAX_TIDY_FILES = 200;
+
+const cmakeConfigureSchema = z.object({
+    projectPath: z.string().describe('Source directory containing CMakeLists.txt'),
+    buildDir: z.string().default('build').describe('Build directory, relative to projectPath unless absolute'),
+    buildType: z.string().default('Debug').describe('CMAKE_BUILD_TYPE'),
+    generator: z.string().optional().describe('CMake generator, e.g. "Ninja"'),
+    definitions: z.record(z.union([z.string(), z.number(), z.boolean()])).default({}).describe('Cache entries passed as -D<name>=<value>'),
+    args: z.array(z.string()).default([]),
+    timeout: z.number().default(300000),
+});
+
+const cmakeBuildSchema = z.object({
+    projectPath: z.string().describe('Source directory containing CMakeLists.txt'),
+    buildDir: z.string().default('build').describe('Configured build directory, relative to projectPath unless absolute'),
+    target: z.string().optional(),
+    config: z.string().optional().describe('Configuration for multi-config generators (Debug, Release)'),
+    jobs: z.number().int().positive().optional().describe('Parallel build jobs; defaults to the CPU count'),
+    timeout: z.number().default(900000),
+});
+
+const clangTidySchema = z.object({
+    projectPath: z.string().describe('Project source directory'),
+    files: z.array(z.string()).default([]).describe('Files to check; defaults to every project source in compile_commands.json'),
+    buildDir: z.string().optional().describe('Directory holding compile_commands.json; discovered when omitted'),
+    checks: z.string().optional().describe('clang-tidy --checks value, e.g. "-*,bugprone-*"'),
+    headerFilter: z.string().optional().describe('Regex of headers to report findings in'),
+    timeout: z.number().default(600000),
+});
+
+function validationFailure(error: z.ZodError) {
+    return {
+        success: false,
+        errors: error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+        warnings: [],
+        output: ''
+    };
+}
+
+function resolveBuildDir(projectPath: string, buildDir: string): string {
+    return isAbsolute(buildDir) ? buildDir : resolve(projectPath, buildDir);
+}
+
+function formatDiagnostic(d: Diagnostic): string {
+    return `${d.file}:${d.line}:${d.column}: ${d.message}${d.rule ? ` [${d.rule}]` : ''}`;
+}
+
+/**
+ * Locate compile_commands.json for a project: in the project root, in a
+ * common build directory, or in a parent directory (e.g. a monorepo root)
+ */
+export async function findCompileCommands(projectPath: string): Promise<string | null> {
+    for (const dir of ['', ...BUILD_DIR_CANDIDATES]) {
+        const candidate = join(projectPath, dir, 'compile_commands.json');
+        try {
+            await fs.access(candidate);
+            return candidate;
+        } catch { /* keep searching */ }
+    }
+    return findUp(dirname(resolve(projectPath)), 'compile_commands.json');
+}
+
+/**
+ * Absolute source paths from a compilation database, in database order without duplicates
+ */
+export function compileCommandSources(database: CompileCommand[]): string[] {
+    const files = database.map(entry => isAbsolute(entry.file) ? entry.file : resolve(entry.directory, entry.file));
+    return [...new Set(files)];
+}
+
+export const cmakeConfigureTool = {
+    name: 'cmake_configure',
+    binaries: ['cmake'],
+    mutates: true,
+    description: 'Configure a CMake project into a build directory with compile_commands.json export enabled, returning CMake errors and warnings as structured diagnostics.',
+    inputSchema: zodToJsonSchema(cmakeConfigureSchema),
+    async run(args: any) {
+        const parseResult = cmakeConfigureSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { projectPath, buildDir, buildType, generator, definitions, args: extraArgs, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        const buildPath = resolveBuildDir(projectPath, buildDir);
+        if (!config.isPathAllowed(projectPath) || !config.isPathWritable(buildPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            await fs.access(join(projectPath, 'CMakeLists.txt'));
+            const flags = [
+                '-S', shellQuote(resolve(projectPath)),
+                '-B', shellQuote(buildPath),
+                `-DCMAKE_BUILD_TYPE=${shellQuote(buildType)}`,
+                '-DCMAKE_EXPORT_COMPILE_COMMANDS=ON',
+                ...Object.entries(definitions).map(([name, value]) => shellQuote(`-D${name}=${typeof value === 'boolean' ? (value ? 'ON' : 'OFF') : value}`)),
+                ...(generator ? ['-G', shellQuote(generator)] : []),
+                ...extraArgs.map(shellQuote),
+            ];
+            const command = `cmake ${flags.join(' ')}`;
+            const result = await runCommand(command, { cwd: projectPath, timeout, maxBuffer: 16 * 1024 * 1024 });
+            const diagnostics = parseCMakeOutput(`${result.stdout}\n${result.stderr}`, projectPath);
+            const compileCommands = join(buildPath, 'compile_commands.json');
+            const hasCompileCommands = await fs.access(compileCommands).then(() => true, () => false);
+            const errors = diagnostics.filter(d => d.severity === 'error').map(formatDiagnostic);
+            if (result.exitCode !== 0 && errors.length === 0) errors.push(`cmake failed: ${result.stderr || result.stdout}`);
+            return {
+                success: result.exitCode === 0,
+                errors,
+                warnings: diagnostics.filter(d => d.severity !== 'error').map(formatDiagnostic),
+                output: result.stdout,
+                command,
+                buildDir: buildPath,
+                compileCommands: hasCompileCommands ? compileCommands : null,
+                diagnostics,
+                summary: countBySeverity(diagnostics),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const cmakeBuildTool = {
+    name: 'cmake_build',
+    binaries: ['cmake'],
+    mutates: true,
+    description: 'Build a configured CMake project (`cmake --build`) and return compiler errors and warnings (gcc, clang, MSVC) as structured diagnostics with the warning flag as rule.',
+    inputSchema: zodToJsonSchema(cmakeBuildSchema),
+    async run(args: any) {
+        const parseResult = cmakeBuildSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { projectPath, buildDir, target, config: buildConfig, jobs, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        const buildPath = resolveBuildDir(projectPath, buildDir);
+        if (!config.isPathAllowed(projectPath) || !config.isPathWritable(buildPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            try {
+                await fs.access(join(buildPath, 'CMakeCache.txt'));
+            } catch {
+                return { success: false, errors: [`${buildPath} is not a configured build directory; run cmake_configure first`], warnings: [], output: '' };
+            }
+            let command = `cmake --build ${shellQuote(buildPath)} --parallel ${jobs ?? cpus().length}`;
+            if (target) command += ` --target ${shellQuote(target)}`;
+            if (buildConfig) command += ` --config ${shellQuote(buildConfig)}`;
+            const result = await runCommand(command, { cwd: projectPath, timeout, maxBuffer: 32 * 1024 * 1024 });
+            // Compilers report paths relative to the build directory
+            const diagnostics = parseClangOutput(`${result.stdout}\n${result.stderr}`, buildPath, 'compiler');
+            const errors = diagnostics.filter(d => d.severity === 'error').map(formatDiagnostic);
+            if (result.exitCode !== 0 && errors.length === 0) {
+                const failure = `${result.stdout}\n${result.stderr}`.split('\n').filter(line => /error|FAILED/i.test(line)).slice(0, 20);
+                errors.push(failure.length > 0 ? failure.join('\n') : `Build failed with exit code ${result.exitCode}`);
+            }
+            return {
+                success: result.exitCode === 0,
+                errors,
+                warnings: diagnostics.filter(d => d.severity === 'warning').map(formatDiagnostic),
+                output: result.stdout,
+                command,
+                diagnostics,
+                summary: countBySeverity(diagnostics),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const clangTidyTool = {
+    name: 'clang_tidy',
+    binaries: ['clang-tidy'],
+    cacheable: true,
+    description: 'Run clang-tidy using the project\'s compile_commands.json (discovered in the project, common build directories, or parents) and return findings as structured diagnostics with the check name as rule.',
+    inputSchema: zodToJsonSchema(clangTidySchema),
+    async run(args: any) {
+        const parseResult = clangTidySchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { projectPath, files, buildDir, checks, headerFilter, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(projectPath) || files.some(file => !config.isPathAllowed(resolve(projectPath, file)))) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const compileCommands = buildDir
+                ? join(resolveBuildDir(projectPath, buildDir), 'compile_commands.json')
+                : await findCompileCommands(projectPath);
+            if (!compileCommands) {
+                return { success: false, errors: ['compile_commands.json not found; run cmake_configure (it enables CMAKE_EXPORT_COMPILE_COMMANDS) or pass buildDir'], warnings: [], output: '' };
+            }
+            const database: CompileCommand[] = JSON.parse(await fs.readFile(compileCommands, 'utf-8'));
+            const root = resolve(projectPath);
+            const buildRoot = dirname(compileCommands);
+            // Generated sources in the build tree are not worth linting
+            let targets = files.length > 0
+                ? files.map(file => resolve(projectPath, file))
+                : compileCommandSources(database).filter(file => isWithin(root, file) && !isWithin(buildRoot, file) && CPP_SOURCE_PATTERN.test(file));
+            const warnings: string[] = [];
+            if (targets.length === 0) {
+                return { success: true, errors: [], warnings: ['No source files to check'], output: '', compileCommands, diagnostics: [] };
+            }
+            if (targets.length > MAX_TIDY_FILES) {
+                warnings.push(`Checked the first ${MAX_TIDY_FILES} of ${targets.length} files; pass files to narrow the run`);
+                targets = targets.slice(0, MAX_TIDY_FILES);
+            }
+            let command = `clang-tidy -p ${shellQuote(buildRoot)} --quiet`;
+            if (checks) command += ` --checks=${shellQuote(checks)}`;
+            if (headerFilter) command += ` --header-filter=${shellQuote(headerFilter)}`;
+            command += ` ${targets.map(shellQuote).join(' ')}`;
+            const result = await runCommand(command, { cwd: projectPath, timeout, maxBuffer: 32 * 1024 * 1024 });
+            const diagnostics = parseClangOutput(result.stdout, projectPath, 'clang-tidy').filter(d => d.severity !== 'info');
+            if (result.exitCode !== 0 && diagnostics.length === 0) {
+                return { success: false, errors: [`clang-tidy failed: ${result.stderr || result.stdout}`], warnings, output: result.stdout, compileCommands, diagnostics };
+            }
+            return {
+                success: !diagnostics.some(d => d.severity === 'error'),
+                errors: diagnostics.filter(d => d.severity === 'error').map(formatDiagnostic),
+                warnings: [...warnings, ...diagnostics.filter(d => d.severity === 'warning').map(formatDiagnostic)],
+                output: result.stdout,
+                compileCommands,
+                filesChecked: targets.length,
+                diagnostics,
+                summary: countBySeverity(diagnostics),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,278 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, join } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+import { splitJsonObjects } from '../utils/json.js';
+import { type Edges, findCycles, reachable, reverseEdges } from '../utils/graph.js';
+import { architectureRuleSchema, type ArchitectureRule } from '../config/project.js';
+import { findVenvPython } from './python.js';
+
+export interface DependencyNode {
+    // Unique in the graph: the import path for Go, name@version for npm, the normalized name for Python
+    id: string;
+    name: string;
+    version?: string;
+    // Part of the project itself (main module packages, the npm root, editable installs)
+    local: boolean;
+}
+
+export interface DependencyGraph {
+    nodes: DependencyNode[];
+    edges: Edges;
+    // Problems the package manager reported while resolving the graph
+    problems: string[];
+}
+
+const inputSchema = z.object({
+    path: z.string().describe('Go module, npm project, or Python project directory'),
+    ecosystem: z.enum(['auto', 'go', 'npm', 'python']).default('auto'),
+    packages: z.string().default('./...').describe('Go package pattern to start from'),
+    target: z.string().optional().describe('Package to query: returns what depends on it and what it depends on, directly and transitively'),
+    includeStdlib: z.boolean().default(false).describe('Go: keep standard library packages in the graph'),
+    localOnly: z.boolean().default(false).describe('Only keep the project\'s own packages (and edges between them)'),
+    rules: z.array(architectureRuleSchema).default([]).describe('Forbidden direct imports, for layering checks: { from, to } package patterns where "..." matches any suffix'),
+    timeout: z.number().default(120000),
+});
+
+// --- Go ---
+
+/**
+ * Parse `go list -e -deps -json`: one object per package, edges from Imports.
+ * Load errors other than import cycles are kept as problems.
+ */
+export function parseGoListDeps(output: string, includeStdlib: boolean): DependencyGraph {
+    const nodes: DependencyNode[] = [];
+    const edges: Edges = new Map();
+    const problems = new Set<string>();
+    const packages = splitJsonObjects(output).map(chunk => JSON.parse(chunk)).filter(p => includeStdlib || !p.Standard);
+    // Edges only to listed packages, which drops the stdlib when excluded and cgo's "C"
+    const listed = new Set(packages.map(p => String(p.ImportPath ?? '')));
+    for (const pkg of packages) {
+        const id = String(pkg.ImportPath ?? '');
+        nodes.push({ id, name: id, ...(pkg.Module?.Version ? { version: String(pkg.Module.Version) } : {}), local: Boolean(pkg.Module?.Main) });
+        edges.set(id, (pkg.Imports ?? []).filter((i: string) => listed.has(i)));
+        // Cycles are found from the edges themselves, with the shortest path through them
+        if (pkg.Error?.Err && pkg.Error.Err !== 'import cycle not allowed') {
+            const stack: string[] = pkg.Error.ImportStack ?? [];
+            problems.add(`${id}: ${pkg.Error.Err}${stack.length > 0 ? ` (${stack.join(' -> ')})` : ''}`);
+        }
+    }
+    return { nodes, edges, problems: [...problems] };
+}
+
+// --- npm ---
+
+/**
+ * Parse `npm ls --all --json`. Packages are name@version, so two versions of
+ * a dependency are two nodes.
+ */
+export function parseNpmLsJson(output: string): DependencyGraph {
+    const start = output.indexOf('{');
+    const tree = start >= 0 ? JSON.parse(output.slice(start)) : {};
+    const nodes = new Map<string, DependencyNode>();
+    const edges: Edges = new Map();
+    const problems: string[] = [...(tree.problems ?? [])].map(String);
+    const rootId = tree.version ? `${tree.name}@${tree.version}` : String(tree.name ?? '(root)');
+    nodes.set(rootId, { id: rootId, name: String(tree.name ?? '(root)'), ...(tree.version ? { version: String(tree.version) } : {}), local: true });
+
+    const visit = (id: string, dependencies: Record<string, any>) => {
+        const targets = edges.get(id) ?? [];
+        edges.set(id, targets);
+        for (const [name, dependency] of Object.entries(dependencies ?? {})) {
+            if (dependency.missing) continue;
+            const childId = dependency.version ? `${name}@${dependency.version}` : name;
+            if (!targets.includes(childId)) targets.push(childId);
+            if (!nodes.has(childId)) {
+                // Workspace packages are linked from the project rather than installed
+                const local = typeof dependency.resolved === 'string' && dependency.resolved.startsWith('file:');
+                nodes.set(childId, { id: childId, name, ...(dependency.version ? { version: String(dependency.version) } : {}), local });
+            }
+            if (dependency.dependencies) visit(childId, dependency.dependencies);
+            else if (!edges.has(childId)) edges.set(childId, []);
+        }
+    };
+    visit(rootId, tree.dependencies ?? {});
+    return { nodes: [...nodes.values()], edges, problems };
+}
+
+// --- Python ---
+
+function normalizePythonName(name: string): string {
+    return name.toLowerCase().replace(/[-_.]+/g, '-');
+}
+
+/**
+ * Parse `pip inspect`: installed distributions and their Requires-Dist.
+ * Requirements behind an extra are optional, so they are not edges.
+ */
+export function parsePipInspect(output: string): DependencyGraph {
+    const start = output.indexOf('{');
+    const report = start >= 0 ? JSON.parse(output.slice(start)) : {};
+    const installed: any[] = report.installed ?? [];
+    const nodes: DependencyNode[] = [];
+    const edges: Edges = new Map();
+    const names = new Set(installed.map(d => normalizePythonName(String(d.metadata?.name ?? ''))));
+    for (const dist of installed) {
+        const id = normalizePythonName(String(dist.metadata?.name ?? ''));
+        const version = dist.metadata?.version;
+        nodes.push({ id, name: String(dist.metadata?.name ?? id), ...(version ? { version: String(version) } : {}), local: Boolean(dist.direct_url?.dir_info?.editable) });
+        const targets: string[] = [];
+        for (const requirement of dist.metadata?.requires_dist ?? []) {
+            const [spec, marker = ''] = String(requirement).split(';');
+            if (/\bextra\s*==/.test(marker)) continue;
+            const name = /^\s*([A-Za-z0-9][A-Za-z0-9._-]*)/.exec(spec ?? '')?.[1];
+            const target = name ? normalizePythonName(name) : undefined;
+            if (target && names.has(target) && !targets.includes(target)) targets.push(target);
+        }
+        edges.set(id, targets);
+    }
+    return { nodes, edges, problems: [] };
+}
+
+// --- Queries ---
+
+/**
+ * Go-style package pattern: "example.com/app/internal/..." matches the
+ * package and everything below it; "..." elsewhere matches any string
+ */
+export function matchesPackagePattern(pattern: string, id: string): boolean {
+    const escaped = pattern.split('...').map(part => part.replace(/[.*+?^${}()|[\]\\]/g, '\\$&'));
+    let source = escaped.join('.*');
+    if (pattern.endsWith('/...')) source = `${escaped.slice(0, -1).join('.*').replace(/\/$/, '')}(/.*)?`;
+    return new RegExp(`^${source}$`).test(id);
+}
+
+export function findRuleViolations(graph: DependencyGraph, rules: ArchitectureRule[]) {
+    const byId = new Map(graph.nodes.map(n => [n.id, n]));
+    const violations: { from: string; to: string; rule: string }[] = [];
+    for (const [from, targets] of graph.edges) {
+        for (const rule of rules) {
+            if (!matchesPackagePattern(rule.from, byId.get(from)?.name ?? from)) continue;
+            for (const to of targets) {
+                if (!matchesPackagePattern(rule.to, byId.get(to)?.name ?? to)) continue;
+                violations.push({ from, to, rule: `${rule.from} must not import ${rule.to}${rule.reason ? `: ${rule.reason}` : ''}` });
+            }
+        }
+    }
+    return violations;
+}
+
+function onlyLocal(graph: DependencyGraph): DependencyGraph {
+    const local = new Set(graph.nodes.filter(n => n.local).map(n => n.id));
+    const edges: Edges = new Map();
+    for (const [from, targets] of graph.edges) {
+        if (local.has(from)) edges.set(from, targets.filter(t => local.has(t)));
+    }
+    return { nodes: graph.nodes.filter(n => local.has(n.id)), edges, problems: graph.problems };
+}
+
+export async function detectEcosystem(dir: string): Promise<'go' | 'npm' | 'python' | null> {
+    if (await findUp(dir, 'go.mod')) return 'go';
+    if (await fs.access(join(dir, 'package.json')).then(() => true, () => false)) return 'npm';
+    for (const marker of ['pyproject.toml', 'setup.py', 'setup.cfg', 'requirements.txt']) {
+        if (await fs.access(join(dir, marker)).then(() => true, () => false)) return 'python';
+    }
+    return null;
+}
+
+export const dependencyGraphTool = {
+    name: 'dependency_graph',
+    cacheable: true,
+    description: 'Build the package dependency graph of a Go module (go list -deps), npm project (npm ls --all), or Python environment (pip inspect). Reports import cycles, answers "what depends on X" for a target package (direct and transitive dependents and dependencies), and checks layering rules that forbid some packages from importing others.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, packages, target, includeStdlib, localOnly, rules, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const stats = await fs.stat(path);
+            const dir = stats.isDirectory() ? path : dirname(path);
+            const ecosystem = parseResult.data.ecosystem === 'auto' ? await detectEcosystem(dir) : parseResult.data.ecosystem;
+            if (!ecosystem) {
+                return { success: false, errors: ['Could not detect the project ecosystem; pass ecosystem explicitly'], warnings: [], output: '' };
+            }
+
+            let graph: DependencyGraph;
+            if (ecosystem === 'go') {
+                const result = await runCommand(`go list -e -deps -json ${packages}`, { cwd: dir, timeout, maxBuffer: 64 * 1024 * 1024 });
+                if (result.exitCode !== 0) {
+                    return { success: false, errors: [`go list failed: ${result.stderr || result.stdout}`], warnings: [], output: '' };
+                }
+                graph = parseGoListDeps(result.stdout, includeStdlib);
+            } else if (ecosystem === 'npm') {
+                // npm ls exits 1 on missing or invalid packages but still prints the tree
+                const result = await runCommand('npm ls --all --json', { cwd: dir, timeout, maxBuffer: 64 * 1024 * 1024 });
+                if (!result.stdout.includes('{')) {
+                    return { success: false, errors: [`npm ls failed: ${result.stderr || result.stdout}`], warnings: [], output: '' };
+                }
+                graph = parseNpmLsJson(result.stdout);
+            } else {
+                const python = (await findVenvPython(dir)) ?? 'python3';
+                const result = await runCommand(`${shellQuote(python)} -m pip inspect --local`, { cwd: dir, timeout, maxBuffer: 64 * 1024 * 1024 });
+                if (result.exitCode !== 0) {
+                    return { success: false, errors: [`pip inspect failed: ${result.stderr || result.stdout}`], warnings: [], output: '' };
+                }
+                graph = parsePipInspect(result.stdout);
+            }
+            if (localOnly) graph = onlyLocal(graph);
+
+            const cycles = findCycles(graph.edges);
+            const violations = findRuleViolations(graph, rules);
+            const errors: string[] = [];
+            const warnings = [...graph.problems];
+            // Go refuses to build import cycles; in npm and pip graphs they are legal if unusual
+            const cycleMessages = cycles.map(c => `Import cycle: ${c.join(' -> ')}`);
+            if (ecosystem === 'go') errors.push(...cycleMessages);
+            else warnings.push(...cycleMessages);
+            errors.push(...violations.map(v => `${v.from} imports ${v.to} (${v.rule})`));
+
+            let query: Record<string, unknown> | undefined;
+            if (target) {
+                const matches = graph.nodes.filter(n => n.id === target || n.name === target || matchesPackagePattern(target, n.name));
+                if (matches.length === 0) {
+                    return { success: false, errors: [`${target} is not in the dependency graph`], warnings, output: '' };
+                }
+                const reversed = reverseEdges(graph.edges);
+                const ids = matches.map(n => n.id);
+                const collect = (edges: Edges, transitive: boolean) => [...new Set(ids.flatMap(id => transitive ? reachable(edges, id) : edges.get(id) ?? []))].filter(id => !ids.includes(id)).sort();
+                query = {
+                    target: ids,
+                    dependents: collect(reversed, false),
+                    transitiveDependents: collect(reversed, true),
+                    dependencies: collect(graph.edges, false),
+                    transitiveDependencies: collect(graph.edges, true),
+                };
+            }
+
+            const edgeCount = [...graph.edges.values()].reduce((count, targets) => count + targets.length, 0);
+            return {
+                success: errors.length === 0,
+                errors,
+                warnings,
+                output: `${graph.nodes.length} package(s), ${edgeCount} edge(s), ${cycles.length} cycle(s)${query ? `; ${(query.transitiveDependents as string[]).length} package(s) depend on ${target}` : ''}`,
+                ecosystem,
+                nodes: graph.nodes,
+                edges: Object.fromEntries(graph.edges),
+                cycles,
+                violations,
+                ...(query ? { query } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
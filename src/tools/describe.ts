@@ -0,0 +1,200 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig, isToolEnabled, type ToolClass } from '../config/project.js';
+import { auditLog } from '../audit/index.js';
+import { metrics } from '../metrics/index.js';
+import { withOutputBudgetArg } from '../output/index.js';
+import { toolClass } from '../permissions/index.js';
+import { withWorkspaceArg } from '../utils/paths.js';
+import { whichBinary } from './environment.js';
+
+const inputSchema = z.object({
+    tools: z.array(z.string()).optional().describe('Only these tools (all enabled tools by default)'),
+    schemas: z.boolean().default(true).describe('Include each tool\'s input and output JSON schemas; false for a compact listing'),
+});
+
+// The result every tool returns; most add fields of their own
+export const TOOL_RESULT_SCHEMA = {
+    type: 'object',
+    properties: {
+        success: { type: 'boolean' },
+        errors: { type: 'array', items: { type: 'string' } },
+        warnings: { type: 'array', items: { type: 'string' } },
+        output: { type: 'string', description: 'Human-readable summary' },
+        diagnostics: {
+            type: 'array',
+            description: 'Findings tied to source locations, from tools that check code',
+            items: {
+                type: 'object',
+                properties: {
+                    file: { type: 'string' },
+                    line: { type: 'integer' },
+                    column: { type: 'integer' },
+                    severity: { type: 'string', enum: ['error', 'warning', 'info'] },
+                    message: { type: 'string' },
+                    rule: { type: 'string' },
+                    source: { type: 'string' },
+                    fixable: { type: 'boolean' },
+                },
+                required: ['file', 'line', 'column', 'severity', 'message', 'source'],
+            },
+        },
+        requestId: { type: 'string', description: 'Correlation id of the call' },
+    },
+    required: ['success', 'errors', 'warnings', 'output'],
+    additionalProperties: true,
+};
+
+// Durations of this many recent audited calls per tool make up its latency
+const LATENCY_SAMPLE = 50;
+const AUDIT_SCAN_LIMIT = 10000;
+
+interface DescribableTool {
+    name: string;
+    description: string;
+    inputSchema: any;
+    mutates?: boolean | ((args: any) => boolean);
+    cacheable?: boolean | ((args: any) => boolean);
+    binaries?: string[];
+    dangerous?: boolean | ((args: any) => boolean);
+    plugin?: string;
+}
+
+export interface ToolLatency {
+    // history: recent calls in the audit log; session: calls since the server started
+    source: 'history' | 'session';
+    calls: number;
+    medianMs?: number;
+    p90Ms?: number;
+    meanMs?: number;
+    maxMs: number;
+}
+
+export interface ToolDescription {
+    name: string;
+    description: string;
+    // always, never, or depending on the arguments (eslint with fix, editor writes)
+    mutates: 'always' | 'never' | 'depends';
+    // What a role must allow to call it (see permissions in the config)
+    class: ToolClass;
+    // Results may be served from the cache (for some arguments when cacheability depends on them)
+    cacheable: boolean;
+    plugin?: string;
+    binaries: { name: string; found: boolean; path?: string }[];
+    // Every required binary is on PATH
+    available: boolean;
+    latency: ToolLatency | null;
+    inputSchema?: any;
+    outputSchema?: any;
+}
+
+function percentile(sorted: number[], p: number): number {
+    return sorted[Math.min(sorted.length - 1, Math.floor(sorted.length * p))]!;
+}
+
+async function historyLatencies(): Promise<Map<string, number[]>> {
+    const durations = new Map<string, number[]>();
+    if (!auditLog.isEnabled()) return durations;
+    const { entries } = await auditLog.query({ limit: AUDIT_SCAN_LIMIT });
+    for (const entry of entries) {
+        // Cache hits, rejected and cancelled calls say nothing about how long the tool takes
+        if (entry.cached || entry.status === 'rejected' || entry.status === 'cancelled') continue;
+        const tool = durations.get(entry.tool) ?? [];
+        if (tool.length < LATENCY_SAMPLE) tool.push(entry.durationMs);
+        durations.set(entry.tool, tool);
+    }
+    return durations;
+}
+
+function latencyOf(name: string, history: Map<string, number[]>, session: ReturnType<typeof metrics.snapshot>['tools']): ToolLatency | null {
+    const recent = history.get(name);
+    if (recent && recent.length > 0) {
+        const sorted = [...recent].sort((a, b) => a - b);
+        return { source: 'history', calls: sorted.length, medianMs: percentile(sorted, 0.5), p90Ms: percentile(sorted, 0.9), maxMs: sorted[sorted.length - 1]! };
+    }
+    const stats = session.find(t => t.tool === name);
+    const calls = stats ? stats.calls - stats.cached - stats.rejected - stats.cancelled : 0;
+    if (!stats || calls <= 0) return null;
+    return { source: 'session', calls, meanMs: stats.meanMs, maxMs: stats.maxMs };
+}
+
+function describeMutates(tool: DescribableTool): ToolDescription['mutates'] {
+    if (typeof tool.mutates === 'function') return 'depends';
+    return tool.mutates ? 'always' : 'never';
+}
+
+function summarize(tool: ToolDescription): string {
+    const missing = tool.binaries.filter(b => !b.found).map(b => b.name);
+    const latency = tool.latency ? `, ~${tool.latency.medianMs ?? tool.latency.meanMs}ms` : '';
+    const mutates = tool.mutates === 'never' ? '' : tool.mutates === 'always' ? ', mutates' : ', may mutate';
+    return `${tool.name}${mutates}${latency}${missing.length > 0 ? ` (missing ${missing.join(', ')})` : ''}`;
+}
+
+export const describeToolsTool = {
+    name: 'describe_tools',
+    description: 'Describe the tools this server offers, so an agent can plan which to call: each tool\'s input and output JSON schemas, whether it writes to the workspace (always, never, or depending on arguments), its permission class (read-only, mutating or dangerous), whether results are cached, the binaries it needs and whether they are on PATH, and its typical latency from recent calls in the audit log (or since the server started). Pass tools to describe only some.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { tools: names, schemas } = parseResult.data;
+        try {
+            // Imported lazily: the tool registry imports this module
+            const { allTools } = await import('./index.js');
+            const { config } = await getEffectiveConfig();
+            const enabled = (allTools as DescribableTool[]).filter(tool => isToolEnabled(config, tool.name));
+            const warnings: string[] = [];
+            const unknown = (names ?? []).filter(name => !enabled.some(tool => tool.name === name));
+            if (unknown.length > 0) warnings.push(`Unknown or disabled tools: ${unknown.join(', ')}`);
+            const selected = names ? enabled.filter(tool => names.includes(tool.name)) : enabled;
+
+            const executor = Config.getInstance().getExecutor();
+            if (executor !== 'local') warnings.push(`Commands run in the ${executor} executor; binaries were looked up on the server host, not in the container`);
+            const history = await historyLatencies().catch(error => {
+                warnings.push(`Could not read the audit log for latencies: ${error.message}`);
+                return new Map<string, number[]>();
+            });
+            const session = metrics.snapshot().tools;
+            const lookups = new Map<string, Promise<string | null>>();
+            const lookup = (binary: string) => {
+                if (!lookups.has(binary)) lookups.set(binary, whichBinary(binary));
+                return lookups.get(binary)!;
+            };
+
+            const described: ToolDescription[] = await Promise.all(selected.map(async tool => {
+                const binaries = await Promise.all((tool.binaries ?? []).map(async name => {
+                    const path = await lookup(name);
+                    return path ? { name, found: true, path } : { name, found: false };
+                }));
+                return {
+                    name: tool.name,
+                    description: tool.description,
+                    mutates: describeMutates(tool),
+                    class: toolClass(config, tool),
+                    cacheable: Boolean(tool.cacheable),
+                    ...(tool.plugin ? { plugin: tool.plugin } : {}),
+                    binaries,
+                    available: binaries.every(b => b.found),
+                    latency: latencyOf(tool.name, history, session),
+                    ...(schemas ? { inputSchema: withOutputBudgetArg(withWorkspaceArg(tool.inputSchema)), outputSchema: TOOL_RESULT_SCHEMA } : {}),
+                };
+            }));
+            const unavailable = described.filter(tool => !tool.available);
+            const lines = [
+                `${described.length} tool(s)${unavailable.length > 0 ? `, ${unavailable.length} missing a binary` : ''}`,
+                ...described.map(summarize),
+            ];
+            return { success: true, errors: [], warnings, output: lines.join('\n'), tools: described };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
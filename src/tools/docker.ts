@@ -10,12 +10,15 @@ const dockerInputSchema = z.object({
 type DockerInput = z.infer<typeof dockerInputSchema>;
 
 async function isContainerRunning(containerId: string): Promise<boolean> {
-    const result = await runCommand(`docker inspect -f '{{.State.Running}}' ${containerId}`);
+    const result = await runCommand(`docker inspect -f '{{.State.Running}}' ${containerId}`, { local: true });
     return result.stdout.trim() === 'true';
 }
 
 export const dockerTool = {
     name: 'docker',
+    binaries: ['docker'],
+    // Runs containers with host access to the daemon
+    dangerous: true,
     description: 'Run Docker commands (build, run, stop, etc.) in a project directory.',
     inputSchema: zodToJsonSchema(dockerInputSchema),
     async run(args: unknown) {
@@ -50,7 +53,7 @@ export const dockerTool = {
             dockerCmd += ' rmi ' + dockerArgs.join(' ');
         }
         try {
-            const result = await runCommand(dockerCmd);
+            const result = await runCommand(dockerCmd, { local: true });
             return { success: result.exitCode === 0, errors: result.stderr ? [result.stderr] : [], warnings: [], output: result.stdout };
         } catch (error: any) {
             return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
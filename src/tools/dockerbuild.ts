@@ -0,0 +1,158 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { join, relative, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { type Diagnostic, countBySeverity, findInstruction, parseBuildKitOutput, parseDockerfile, parseHadolintOutput } from '../diagnostics/index.js';
+import { commandExists, runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+
+const HADOLINT_IMAGE = 'hadolint/hadolint';
+// Lines of a failed step's output kept in the result
+const OUTPUT_TAIL = 40;
+
+const inputSchema = z.object({
+    path: z.string().describe('Build context directory'),
+    dockerfile: z.string().default('Dockerfile').describe('Dockerfile, relative to path'),
+    target: z.string().optional().describe('Stage to build (--target)'),
+    buildArgs: z.record(z.string()).default({}).describe('Build arguments passed as --build-arg NAME=value'),
+    platform: z.string().optional().describe('Target platform, such as linux/amd64'),
+    tag: z.string().optional().describe('Tag the built image; untagged by default'),
+    noCache: z.boolean().default(false).describe('Build without the layer cache'),
+    build: z.boolean().default(true).describe('Build the image; false only lints the Dockerfile'),
+    lint: z.boolean().default(true).describe('Lint the Dockerfile with hadolint (a local binary, else the hadolint/hadolint image)'),
+    timeout: z.number().default(900000),
+});
+
+async function runHadolint(dockerfile: string, cwd: string, timeout: number): Promise<{ diagnostics: Diagnostic[]; warning?: string }> {
+    let command: string;
+    if (await commandExists('hadolint')) command = `hadolint --format json --no-fail ${shellQuote(dockerfile)}`;
+    else if (await commandExists('docker')) command = `docker run --rm -i ${HADOLINT_IMAGE} hadolint --format json --no-fail - < ${shellQuote(dockerfile)}`;
+    else return { diagnostics: [], warning: 'hadolint is not installed and docker is not available, so the Dockerfile was not linted' };
+    const result = await runCommand(command, { cwd, timeout, local: true });
+    if (result.exitCode !== 0) return { diagnostics: [], warning: `hadolint failed: ${(result.stderr || result.stdout).trim()}` };
+    return { diagnostics: parseHadolintOutput(result.stdout, dockerfile) };
+}
+
+export const dockerBuildTool = {
+    name: 'docker_build',
+    binaries: ['docker'],
+    // RUN instructions execute arbitrary commands through the Docker daemon
+    dangerous: true,
+    description: 'Build a Dockerfile with BuildKit and return structured results: every build step with whether it was cached and how long it took, and for a failed step its error, the tail of its output and the Dockerfile line it came from, as error diagnostics. BuildKit\'s build checks become warnings. With lint (default), hadolint\'s best-practice findings on the Dockerfile are included too. Set build to false to only lint.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, target, buildArgs, platform, tag, noCache, build, lint, timeout } = parseResult.data;
+        const context = resolve(path);
+        const dockerfile = resolve(context, parseResult.data.dockerfile);
+        if (!Config.getInstance().isPathAllowed(context) || !Config.getInstance().isPathAllowed(dockerfile)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        let workDir: string | undefined;
+        try {
+            const source = await fs.readFile(dockerfile, 'utf-8').catch(() => null);
+            if (source === null) return { success: false, errors: [`No Dockerfile at ${dockerfile}`], warnings: [], output: '' };
+            const instructions = parseDockerfile(source);
+            const warnings: string[] = [];
+            const diagnostics: Diagnostic[] = [];
+
+            if (lint) {
+                const linted = await runHadolint(dockerfile, context, timeout);
+                diagnostics.push(...linted.diagnostics);
+                if (linted.warning) warnings.push(linted.warning);
+            }
+
+            let built: { ok: boolean; imageId: string | null; steps: ReturnType<typeof parseBuildKitOutput>['steps']; failure?: string } | undefined;
+            if (build) {
+                if (!await commandExists('docker')) {
+                    return { success: false, errors: ['docker is not available to build the image'], warnings, output: '', diagnostics };
+                }
+                workDir = await fs.mkdtemp(join(tmpdir(), 'cf-docker-build-'));
+                const iidFile = join(workDir, 'iid');
+                let command = `docker build --progress=plain -f ${shellQuote(dockerfile)} --iidfile ${shellQuote(iidFile)}`;
+                if (target) command += ` --target ${shellQuote(target)}`;
+                if (platform) command += ` --platform ${shellQuote(platform)}`;
+                if (tag) command += ` -t ${shellQuote(tag)}`;
+                if (noCache) command += ' --no-cache';
+                for (const [name, value] of Object.entries(buildArgs)) command += ` --build-arg ${shellQuote(`${name}=${value}`)}`;
+                command += ` ${shellQuote(context)}`;
+
+                const result = await runCommand(command, { cwd: context, timeout, local: true, env: { DOCKER_BUILDKIT: '1' }, maxBuffer: 64 * 1024 * 1024 });
+                const parsed = parseBuildKitOutput(`${result.stdout}\n${result.stderr}`);
+                for (const step of parsed.steps.filter(s => s.error)) {
+                    const instruction = findInstruction(instructions, step);
+                    step.output = step.output.slice(-OUTPUT_TAIL);
+                    diagnostics.push({
+                        file: dockerfile,
+                        line: instruction?.line ?? parsed.failureLine ?? 1,
+                        column: 0,
+                        severity: 'error',
+                        message: `${step.name} failed: ${step.error}`,
+                        rule: 'build-step',
+                        source: 'docker_build',
+                    });
+                }
+                // Failures outside any step: a parse error, a missing COPY source, an unknown base image
+                if (result.exitCode !== 0 && !parsed.steps.some(s => s.error)) {
+                    diagnostics.push({
+                        file: dockerfile,
+                        line: parsed.failureLine ?? 1,
+                        column: 0,
+                        severity: 'error',
+                        message: parsed.failure ?? (result.stderr.trim().split('\n').pop() || `docker build exited with code ${result.exitCode}`),
+                        rule: 'build',
+                        source: 'docker_build',
+                    });
+                }
+                for (const check of parsed.checks) {
+                    diagnostics.push({ file: dockerfile, line: check.line, column: 0, severity: 'warning', message: check.message, rule: check.rule, source: 'buildkit' });
+                }
+                const imageId = result.exitCode === 0 ? (await fs.readFile(iidFile, 'utf-8').catch(() => '')).trim() || null : null;
+                built = {
+                    ok: result.exitCode === 0,
+                    imageId,
+                    // BuildKit's own bookkeeping steps (loading the context, metadata) are left out
+                    steps: parsed.steps.filter(s => !s.name.startsWith('[internal]') && (s.instruction || s.error)),
+                    ...(result.exitCode !== 0 && parsed.failure ? { failure: parsed.failure } : {}),
+                };
+            }
+
+            const counts = countBySeverity(diagnostics);
+            const buildFailed = built !== undefined && !built.ok;
+            const lines: string[] = [];
+            if (built) {
+                const cached = built.steps.filter(s => s.cached).length;
+                lines.push(buildFailed
+                    ? `Build of ${relative(context, dockerfile) || dockerfile} failed${built.failure ? `: ${built.failure}` : ''}`
+                    : `Built ${tag ?? built.imageId ?? 'the image'} in ${built.steps.length} step(s), ${cached} cached`);
+            }
+            lines.push(...diagnostics.map(d => `${d.severity.toUpperCase()} ${relative(context, d.file) || d.file}:${d.line} [${d.rule}] ${d.message}`));
+            if (!built) lines.push(`${diagnostics.length} lint finding(s)`);
+            return {
+                success: !buildFailed && counts.error === 0,
+                errors: [
+                    ...(buildFailed ? ['docker build failed'] : []),
+                    ...(counts.error > 0 && !buildFailed ? [`${counts.error} Dockerfile error(s)`] : []),
+                ],
+                warnings,
+                output: lines.join('\n'),
+                ...(built ? { imageId: built.imageId, steps: built.steps } : {}),
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            if (workDir) await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
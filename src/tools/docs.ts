@@ -0,0 +1,162 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, extname, join, relative, resolve } from 'path';
+import { minimatch } from 'minimatch';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { type Diagnostic, countBySeverity } from '../diagnostics/index.js';
+import { checkGoDocs, type GoDocEntry, type MarkdownDocument, parseMarkdown } from '../docs/index.js';
+import { commandExists, runCommand } from '../utils/command.js';
+import { walkDirectory } from '../utils/gitignore.js';
+import { queryGoAst } from './goast.js';
+
+const MARKDOWN_FILE = /\.(md|markdown)$/i;
+const MAX_FILES = 2000;
+
+const inputSchema = z.object({
+    path: z.string().describe('Markdown file, or a directory to check every .md and .markdown file in (respecting .gitignore)'),
+    goDocs: z.boolean().default(false).describe('Also check that exported Go functions, methods, types, vars, consts and packages under path have doc comments in the conventional form'),
+    exclude: z.array(z.string()).default([]).describe('Glob patterns, relative to path, of files to skip, such as CHANGELOG.md or docs/archive/**'),
+    timeout: z.number().default(120000),
+});
+
+// Targets that are not files in the tree: web links, mail, and template placeholders
+const isExternal = (target: string) => /^[a-z][a-z0-9+.-]*:/i.test(target) || target.startsWith('//') || target.includes('{{');
+
+export const checkDocsTool = {
+    name: 'check_docs',
+    binaries: ['go'],
+    cacheable: true,
+    description: 'Check Markdown documentation: relative links and images that point at files that do not exist, #anchors that match no heading (GitHub slugs) or <a name> in the target document, reference-style links with no definition, and code fences that are never closed or opened inside another. With goDocs, also reports exported Go declarations and packages without doc comments, and comments that do not start with the name.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, goDocs, exclude, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const target = resolve(path);
+            const isDirectory = (await fs.stat(target)).isDirectory();
+            const root = isDirectory ? target : dirname(target);
+            const warnings: string[] = [];
+            const files: string[] = [];
+            if (isDirectory) {
+                await walkDirectory(target, { respectGitignore: true }, entry => {
+                    if (files.length >= MAX_FILES) return false;
+                    if (entry.type !== 'file' || !MARKDOWN_FILE.test(entry.path) || /(^|\/)(node_modules|vendor)\//.test(entry.relativePath)) return;
+                    if (exclude.some(pattern => minimatch(entry.relativePath, pattern, { dot: true }))) return;
+                    files.push(entry.path);
+                });
+                if (files.length >= MAX_FILES) warnings.push(`Stopped at ${MAX_FILES} Markdown files`);
+            } else {
+                files.push(target);
+            }
+            files.sort();
+
+            // Links starting with / are relative to the repository root, as GitHub renders them
+            const top = await runCommand('git rev-parse --show-toplevel', { cwd: root, timeout, local: true });
+            const linkRoot = top.exitCode === 0 ? top.stdout.trim() : root;
+
+            const parsed = new Map<string, MarkdownDocument | null>();
+            const load = async (file: string) => {
+                if (!parsed.has(file)) {
+                    try {
+                        parsed.set(file, parseMarkdown(await fs.readFile(file, 'utf-8')));
+                    } catch {
+                        parsed.set(file, null);
+                    }
+                }
+                return parsed.get(file)!;
+            };
+
+            const diagnostics: Diagnostic[] = [];
+            let links = 0;
+            for (const file of files) {
+                const doc = await load(file);
+                if (!doc) continue;
+                for (const finding of doc.findings) {
+                    diagnostics.push({ file, line: finding.line, column: 1, severity: finding.severity, message: finding.message, rule: finding.rule, source: 'check_docs' });
+                }
+                for (const use of doc.undefinedReferences) {
+                    diagnostics.push({ file, line: use.line, column: use.column, severity: 'warning', message: `No definition for reference link [${use.label}]`, rule: 'undefined-reference', source: 'check_docs' });
+                }
+                for (const link of doc.links) {
+                    if (!link.target || isExternal(link.target)) continue;
+                    links++;
+                    const hash = link.target.indexOf('#');
+                    const pathPart = hash >= 0 ? link.target.slice(0, hash) : link.target;
+                    const anchor = hash >= 0 ? link.target.slice(hash + 1) : '';
+                    let linked = file;
+                    if (pathPart) {
+                        let decoded = pathPart.split('?')[0]!;
+                        try {
+                            decoded = decodeURIComponent(decoded);
+                        } catch {
+                            // Keep the raw path
+                        }
+                        linked = decoded.startsWith('/') ? join(linkRoot, decoded) : resolve(dirname(file), decoded);
+                        const stat = await fs.stat(linked).catch(() => null);
+                        if (!stat) {
+                            diagnostics.push({ file, line: link.line, column: link.column, severity: 'error', message: `${link.image ? 'Image' : 'Link'} target ${pathPart} does not exist`, rule: 'broken-link', source: 'check_docs' });
+                            continue;
+                        }
+                        if (stat.isDirectory()) continue;
+                    }
+                    // Anchors are only known for Markdown targets; GitHub's #L10 line anchors are left alone
+                    if (!anchor || !MARKDOWN_FILE.test(extname(linked)) || /^L\d+(-L\d+)?$/.test(anchor)) continue;
+                    const linkedDoc = await load(linked);
+                    let slug = anchor;
+                    try {
+                        slug = decodeURIComponent(anchor);
+                    } catch {
+                        // Keep the raw anchor
+                    }
+                    if (linkedDoc && !linkedDoc.anchors.has(slug) && !linkedDoc.anchors.has(slug.toLowerCase())) {
+                        const where = linked === file ? 'this document' : relative(dirname(file), linked);
+                        diagnostics.push({ file, line: link.line, column: link.column, severity: 'error', message: `No heading or anchor #${anchor} in ${where}`, rule: 'dead-anchor', source: 'check_docs' });
+                    }
+                }
+            }
+
+            let goSummary = '';
+            if (goDocs) {
+                if (!await commandExists('go')) {
+                    warnings.push('go is not installed, so Go doc comments were not checked');
+                } else {
+                    const result = await queryGoAst(isDirectory ? target : root, 'docs', { timeout });
+                    warnings.push(...(result.parseErrors ?? []).map(e => e.message));
+                    const entries = result.results as GoDocEntry[];
+                    const found = checkGoDocs(entries);
+                    diagnostics.push(...found);
+                    goSummary = `${entries.length} exported Go declaration(s) and package(s) checked, ${found.filter(d => d.rule !== 'doc-form').length} undocumented`;
+                }
+            }
+
+            const counts = countBySeverity(diagnostics);
+            const display = (file: string) => relative(root, file) || file;
+            return {
+                success: counts.error === 0,
+                errors: counts.error > 0 ? [`${counts.error} error(s) in the documentation`] : [],
+                warnings,
+                output: [
+                    ...diagnostics.map(d => `${display(d.file)}:${d.line}:${d.column}: ${d.severity}: ${d.message}${d.rule ? ` [${d.rule}]` : ''}`),
+                    `${files.length} Markdown file(s), ${links} local link(s) checked: ${counts.error} error(s), ${counts.warning} warning(s)`,
+                    ...(goSummary ? [goSummary] : []),
+                ].join('\n'),
+                files: files.length,
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,102 @@
+import { z } from 'zod';
+import { extname, relative } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { findClones, tokenize, type CloneGroup, type SourceFile } from '../duplicates/index.js';
+import { collectSourceFiles, HASH_COMMENT_EXTENSIONS, SOURCE_EXTENSIONS } from '../utils/sourcefiles.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Directory (or file) to search for duplicated code; .gitignore is respected'),
+    minTokens: z.number().int().min(10).max(1000).default(50).describe('Shortest duplicated block reported, in tokens'),
+    minLines: z.number().int().min(1).default(5).describe('Shortest duplicated block reported, in lines'),
+    minSimilarity: z.number().min(0).max(1).default(0).describe('Only blocks whose tokens are at least this share identical as written (1 = verbatim copies only); renamed copies score lower'),
+    extensions: z.array(z.string().regex(/^\.[\w.]+$/, 'Expected an extension such as .go')).optional().describe(`File extensions to compare; default ${SOURCE_EXTENSIONS.join(' ')}`),
+    exclude: z.array(z.string()).default([]).describe('Globs (relative to path) of files to leave out'),
+    includeTests: z.boolean().default(false).describe('Compare test files too'),
+    limit: z.number().int().positive().max(500).default(50).describe('Duplicated blocks listed, largest first'),
+});
+
+// Lines covered by at least one duplicated block, per file
+function duplicatedLines(groups: CloneGroup[]): Map<string, number> {
+    const ranges = new Map<string, Array<[number, number]>>();
+    for (const group of groups) {
+        for (const f of group.fragments) ranges.set(f.file, [...(ranges.get(f.file) ?? []), [f.startLine, f.endLine]]);
+    }
+    const counts = new Map<string, number>();
+    for (const [file, list] of ranges) {
+        list.sort((a, b) => a[0] - b[0]);
+        let total = 0;
+        let end = 0;
+        for (const [start, stop] of list) {
+            if (stop <= end) continue;
+            total += stop - Math.max(start, end + 1) + 1;
+            end = stop;
+        }
+        counts.set(file, total);
+    }
+    return counts;
+}
+
+export const findDuplicatesTool = {
+    name: 'find_duplicates',
+    cacheable: true,
+    description: 'Find duplicated code across a workspace by token-based clone detection: source is tokenized without comments or whitespace, identifiers and literals are normalized so renamed copies still match, and blocks of at least minTokens tokens (default 50) and minLines lines occurring in more than one place are reported. Each block lists every location it occurs at, its length, and a similarity score (share of tokens identical as written; 1.0 is a verbatim copy). Every copy is also returned as a warning diagnostic. Skips tests, generated and git-ignored files by default.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, minTokens, minLines, minSimilarity, extensions, exclude, includeTests, limit } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const { root, files: sources, warnings } = await collectSourceFiles(path, { ...(extensions ? { extensions } : {}), exclude, includeTests });
+            let totalLines = 0;
+            const files: SourceFile[] = sources.map(({ path: file, source }) => {
+                totalLines += source.split('\n').length;
+                return { file, tokens: tokenize(source, HASH_COMMENT_EXTENSIONS.has(extname(file).toLowerCase())) };
+            });
+
+            const groups = findClones(files, { minTokens, minLines }).filter(g => g.similarity >= minSimilarity);
+            const lineCounts = duplicatedLines(groups);
+            const duplicated = [...lineCounts.values()].reduce((sum, n) => sum + n, 0);
+            const percent = totalLines > 0 ? Math.round((duplicated / totalLines) * 1000) / 10 : 0;
+            const display = (file: string) => relative(root, file) || file;
+            const where = (f: { file: string; startLine: number; endLine: number }) => `${display(f.file)}:${f.startLine}-${f.endLine}`;
+
+            const diagnostics: Diagnostic[] = groups.flatMap(group => group.fragments.map(fragment => ({
+                file: fragment.file,
+                line: fragment.startLine,
+                column: 0,
+                severity: 'warning' as const,
+                message: `Duplicated block of ${group.tokens} tokens (lines ${fragment.startLine}-${fragment.endLine}), also at ${group.fragments.filter(f => f !== fragment).map(where).join(', ')}${group.similarity < 1 ? `; ${Math.round(group.similarity * 100)}% identical as written` : ''}`,
+                rule: 'duplicate-code',
+                source: 'find_duplicates',
+            })));
+            const summary = groups.length > 0
+                ? `${groups.length} duplicated block(s) in ${lineCounts.size} file(s): ${duplicated} of ${totalLines} lines (${percent}%) in ${files.length} file(s) compared`
+                : `No duplicated blocks of ${minTokens}+ tokens in ${files.length} file(s)`;
+            const listed = groups.slice(0, limit).map(group =>
+                `${group.tokens} tokens, ${group.lines} lines, similarity ${group.similarity}: ${group.fragments.map(where).join(', ')}`);
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: [summary, ...listed, ...(groups.length > limit ? [`... ${groups.length - limit} more`] : [])].join('\n'),
+                totals: { files: files.length, lines: totalLines, duplicatedLines: duplicated, duplicatedPercent: percent, blocks: groups.length },
+                duplicates: groups.slice(0, limit),
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
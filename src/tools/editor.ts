@@ -1,6 +1,11 @@
 import { z } from 'zod';
 import { promises as fs } from 'fs';
-import Config from '../config/index.js';
+import { validatePath } from '../utils/sandbox.js';
+import { checkContentForSecrets } from '../utils/secrets.js';
+import { recordFileChange } from '../audit/index.js';
+import { captureBeforeChange } from '../snapshots/index.js';
+import { formatPreviews, previewChange } from '../utils/preview.js';
+import { describeRead, readFileWindow, DEFAULT_MAX_READ_BYTES } from '../utils/fileread.js';
 import * as diffLib from 'diff';
 import { zodToJsonSchema } from 'zod-to-json-schema';
 
@@ -27,11 +32,10 @@ function createUnifiedDiff(oldStr: string, newStr: string, filePath: string) {
     return diffLib.createPatch(filePath, oldStr, newStr, '', '');
 }
 
-async function applyUnifiedEdits(
-    filePath: string,
-    edits: Array<any>
-): Promise<string> {
-    const content = normalizeLineEndings(await fs.readFile(filePath, 'utf-8'));
+/**
+ * Apply content-matching or line-based edits to file content
+ */
+export function applyEditsToContent(content: string, edits: Array<any>): string {
     let lines = content.split('\n');
     let modifiedContent = content;
     for (const edit of edits) {
@@ -87,39 +91,79 @@ async function applyUnifiedEdits(
             modifiedContent = lines.join('\n');
         }
     }
+    return modifiedContent;
+}
+
+async function applyUnifiedEdits(
+    filePath: string,
+    edits: Array<any>
+): Promise<{ diff: string; content: string }> {
+    const content = normalizeLineEndings(await fs.readFile(filePath, 'utf-8'));
+    const modifiedContent = applyEditsToContent(content, edits);
     const diff = createUnifiedDiff(content, modifiedContent, filePath);
-    return `${'`'.repeat(3)}diff\n${diff}${'`'.repeat(3)}\n\n`;
+    return { diff: `${'`'.repeat(3)}diff\n${diff}${'`'.repeat(3)}\n\n`, content: modifiedContent };
 }
 
 export const editor = {
     name: 'editor',
-    description: 'Edit text files with line-based or content-matching edits. By default, each edit is treated as content-matching (mode: "content"), which is robust to line changes. In content mode, each edit replaces exact line sequences (oldText) with new content (newText). Returns a git-style diff showing the changes made. Only works within allowed directories. To use line-number-based edits, set mode: "line" and specify start/end (for replace/remove) or start (for add).',
+    mutates: (args: any) => args?.action !== 'read' && !args?.dryRun,
+    description: 'Edit text files with line-based or content-matching edits. By default, each edit is treated as content-matching (mode: "content"), which is robust to line changes. In content mode, each edit replaces exact line sequences (oldText) with new content (newText). Returns a git-style diff showing the changes made. Only works within allowed directories; symlinks escaping them and writes to read-only roots are rejected. To use line-number-based edits, set mode: "line" and specify start/end (for replace/remove) or start (for add). With dryRun, create/edit/delete return the would-be diff and size change without writing. read takes startLine/endLine, head or tail, and cuts output at maxBytes (256 KB by default), reporting where to continue.',
     inputSchema: zodToJsonSchema(z.object({
-        action: z.enum(['read', 'edit', 'delete', 'create']).describe('Action to perform: "read" to get file content, "create" to create a file, "delete" to remove a file, "edit" to apply edits.'),
+        action: z.enum(['read', 'edit', 'delete', 'create']).describe('Action to perform: "read" to get file content (or a line range of it; binary files are summarized), "create" to create a file, "delete" to remove a file, "edit" to apply edits.'),
         file_path: z.string().describe('Target file path (must be in allowed directories).'),
         edits: z.array(editActionSchema).describe('Array of edits. By default, each edit is treated as content-matching (mode: "content"). In content mode, each edit replaces exact line sequences (oldText) with new content (newText). Returns a git-style diff showing the changes made. For line-number-based edits, set mode: "line" and use type, start, end, content. For add, use start as the insertion index.').optional(),
         content: z.string().describe('Content to create file (for create action).').optional(),
+        dryRun: z.boolean().describe('Return the diff the create/edit/delete would produce without writing.').optional(),
+        startLine: z.number().int().positive().describe('read: first line to return (1-based).').optional(),
+        endLine: z.number().int().positive().describe('read: last line to return (inclusive).').optional(),
+        head: z.number().int().positive().describe('read: return only the first N lines.').optional(),
+        tail: z.number().int().positive().describe('read: return only the last N lines.').optional(),
+        maxBytes: z.number().int().positive().describe(`read: cut the output at this many bytes, at a line boundary (default ${DEFAULT_MAX_READ_BYTES}).`).optional(),
     }).required({ action: true, file_path: true })),
     async run(args: any) {
-        const { action, file_path, edits, content } = args;
-        if (!Config.getInstance().isPathAllowed(file_path)) {
-            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        const { action, edits, content, dryRun } = args;
+        let file_path: string;
+        try {
+            file_path = await validatePath(args.file_path, action === 'read' ? 'read' : 'write');
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
         }
         try {
             switch (action) {
                 case 'read': {
-                    const fileContent = await fs.readFile(file_path, 'utf-8');
-                    return { success: true, errors: [], warnings: [], output: fileContent };
+                    const { startLine, endLine, head, tail, maxBytes } = args;
+                    const read = await readFileWindow(file_path, { ...(startLine ? { startLine } : {}), ...(endLine ? { endLine } : {}), ...(head ? { head } : {}), ...(tail ? { tail } : {}), ...(maxBytes ? { maxBytes } : {}) });
+                    const { content: fileContent, ...file } = read;
+                    const warnings = read.truncated || read.binary ? [describeRead(file_path, read)] : [];
+                    return { success: true, errors: [], warnings, output: fileContent, file };
                 }
                 case 'create': {
                     if (typeof content !== 'string') {
                         return { success: false, errors: ['Missing content for create'], warnings: [], output: '' };
                     }
+                    const secrets = await checkContentForSecrets(file_path, content);
+                    if (secrets.blocked) {
+                        return { success: false, errors: ['Write blocked: content looks like it contains secrets', ...secrets.warnings], warnings: [], output: '' };
+                    }
+                    if (dryRun) {
+                        const existing = await fs.readFile(file_path, 'utf-8').catch(() => null);
+                        const preview = previewChange(file_path, existing, content);
+                        return { success: true, errors: [], warnings: secrets.warnings, output: formatPreviews([preview]), dryRun: true, previews: [preview] };
+                    }
+                    await captureBeforeChange(file_path);
                     await fs.writeFile(file_path, content);
-                    return { success: true, errors: [], warnings: [], output: 'File created' };
+                    await recordFileChange(file_path, 'write', content);
+                    return { success: true, errors: [], warnings: secrets.warnings, output: 'File created' };
                 }
                 case 'delete': {
+                    if (dryRun) {
+                        const existing = await fs.readFile(file_path, 'utf-8').catch(() => null);
+                        const previews = existing === null ? [] : [previewChange(file_path, existing, null)];
+                        return { success: true, errors: [], warnings: [], output: formatPreviews(previews), dryRun: true, previews };
+                    }
+                    await captureBeforeChange(file_path);
                     await fs.rm(file_path, { force: true });
+                    await recordFileChange(file_path, 'delete');
                     return { success: true, errors: [], warnings: [], output: 'File deleted' };
                 }
                 case 'edit': {
@@ -134,9 +178,20 @@ export const editor = {
                         }
                         return edit;
                     });
-                    const diff = await applyUnifiedEdits(file_path, normalizedEdits);
-                    await fs.writeFile(file_path, normalizeLineEndings((await fs.readFile(file_path, 'utf-8')).split('\n').join('\n')));
-                    return { success: true, errors: [], warnings: [], output: diff };
+                    const { diff, content: newContent } = await applyUnifiedEdits(file_path, normalizedEdits);
+                    const secrets = await checkContentForSecrets(file_path, newContent);
+                    if (secrets.blocked) {
+                        return { success: false, errors: ['Edit blocked: content looks like it contains secrets', ...secrets.warnings], warnings: [], output: '' };
+                    }
+                    if (dryRun) {
+                        const original = normalizeLineEndings(await fs.readFile(file_path, 'utf-8'));
+                        const preview = previewChange(file_path, original, newContent);
+                        return { success: true, errors: [], warnings: secrets.warnings, output: formatPreviews([preview]), dryRun: true, previews: [preview] };
+                    }
+                    await captureBeforeChange(file_path);
+                    await fs.writeFile(file_path, newContent);
+                    await recordFileChange(file_path, 'write', newContent);
+                    return { success: true, errors: [], warnings: secrets.warnings, output: diff };
                 }
                 default:
                     return { success: false, errors: ['Unknown action'], warnings: [], output: '' };
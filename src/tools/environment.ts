@@ -0,0 +1,213 @@
+import { z } from 'zod';
+import { promises as fs, constants } from 'fs';
+import { delimiter, join } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getCommandEnv, getEffectiveConfig } from '../config/project.js';
+import { selectToolchains } from '../toolchains/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+
+export interface ToolProbe {
+    name: string;
+    kind: 'toolchain' | 'linter' | 'extra';
+    found: boolean;
+    path?: string;
+    version?: string;
+    // First line the version command printed
+    versionOutput?: string;
+    // The version command started (whatever its exit code); false when the binary cannot be executed
+    runs?: boolean;
+}
+
+export interface EnvironmentReport {
+    platform: string;
+    arch: string;
+    executor: string;
+    toolchains: ToolProbe[];
+    linters: ToolProbe[];
+    extra: ToolProbe[];
+    go: Record<string, string> | null;
+    path: Array<{ dir: string; exists: boolean }>;
+    inspectedAt: string;
+}
+
+const inputSchema = z.object({
+    path: z.string().optional().describe('Also report the toolchain versions this project pins and which ones its commands run with'),
+    tools: z.array(z.string().regex(/^[\w.+-]+$/)).default([]).describe('Further binaries to look for, e.g. protoc or terraform'),
+    refresh: z.boolean().default(false).describe('Inspect again instead of returning the cached report'),
+});
+
+// Binary -> arguments printing its version
+const TOOLCHAINS: Record<string, string> = {
+    go: 'version',
+    node: '--version',
+    npm: '--version',
+    python3: '--version',
+    python: '--version',
+    uv: '--version',
+    docker: '--version',
+    rustc: '--version',
+    cargo: '--version',
+    java: '-version',
+    gcc: '--version',
+    clang: '--version',
+    cmake: '--version',
+    make: '--version',
+    git: '--version',
+};
+
+const LINTERS: Record<string, string> = {
+    'golangci-lint': '--version',
+    staticcheck: '-version',
+    gopls: 'version',
+    govulncheck: '-version',
+    // Has no version flag
+    goimports: '',
+    ruff: '--version',
+    black: '--version',
+    mypy: '--version',
+    pyright: '--version',
+    eslint: '--version',
+    prettier: '--version',
+    tsc: '--version',
+    'clang-tidy': '--version',
+    'clang-format': '--version',
+};
+
+const GO_ENV_KEYS = ['GOVERSION', 'GOROOT', 'GOPATH', 'GOMODCACHE', 'GOOS', 'GOARCH', 'GOFLAGS', 'GOPROXY', 'CGO_ENABLED'];
+// Toolchains and PATH rarely change while the server runs
+const CACHE_TTL_MS = 10 * 60 * 1000;
+
+let cached: { key: string; at: number; report: EnvironmentReport } | null = null;
+
+async function findOnPath(name: string, dirs: string[]): Promise<string | null> {
+    const names = process.platform === 'win32' ? [`${name}.exe`, `${name}.cmd`, name] : [name];
+    for (const dir of dirs) {
+        for (const candidate of names) {
+            const full = join(dir, candidate);
+            if (await fs.access(full, constants.X_OK).then(() => true, () => false)) return full;
+        }
+    }
+    return null;
+}
+
+/**
+ * Where an executable resolves on the server's PATH, or null when it does
+ * not; a name with a slash is checked as a path
+ */
+export async function whichBinary(name: string): Promise<string | null> {
+    if (name.includes('/')) return fs.access(name, constants.X_OK).then(() => name, () => null);
+    return findOnPath(name, (process.env.PATH ?? '').split(delimiter).filter(Boolean));
+}
+
+async function probe(name: string, versionArgs: string | undefined, kind: ToolProbe['kind'], dirs: string[]): Promise<ToolProbe> {
+    const path = await findOnPath(name, dirs);
+    if (!path) return { name, kind, found: false };
+    if (!versionArgs) return { name, kind, found: true, path };
+    // Host binaries are what is being reported, whatever the executor
+    const result = await runCommand(`${shellQuote(path)} ${versionArgs}`, { timeout: 10000, local: true }).catch(() => null);
+    // java and some Go tools print their version on stderr
+    const versionOutput = `${result?.stdout ?? ''}\n${result?.stderr ?? ''}`.split('\n').map(l => l.trim()).find(Boolean);
+    const version = versionOutput ? /\d+\.\d+(?:\.\d+)?(?:[-+.][\w.]+)?/.exec(versionOutput)?.[0] : undefined;
+    // 126 and 127 are the shell's "cannot execute" and "not found", e.g. a broken symlink or a missing interpreter
+    const runs = result !== null && result.exitCode !== 126 && result.exitCode !== 127;
+    return { name, kind, found: true, path, ...(version ? { version } : {}), ...(versionOutput ? { versionOutput } : {}), runs };
+}
+
+/**
+ * Find a binary on the server's PATH (a name with a slash is a path) and run its version command
+ */
+export async function probeBinary(name: string, kind: ToolProbe['kind'] = 'extra'): Promise<ToolProbe> {
+    const dirs = name.includes('/') ? [''] : (process.env.PATH ?? '').split(delimiter).filter(Boolean);
+    return probe(name, TOOLCHAINS[name] ?? LINTERS[name] ?? '--version', kind, dirs);
+}
+
+async function goEnv(goPath: string | undefined): Promise<Record<string, string> | null> {
+    if (!goPath) return null;
+    const result = await runCommand(`${shellQuote(goPath)} env -json ${GO_ENV_KEYS.join(' ')}`, { timeout: 10000, local: true }).catch(() => null);
+    try {
+        return result && result.exitCode === 0 ? JSON.parse(result.stdout) : null;
+    } catch {
+        return null;
+    }
+}
+
+/**
+ * Look up the toolchains and linters on the server's PATH and their versions
+ */
+export async function inspectEnvironment(extra: string[] = []): Promise<EnvironmentReport> {
+    const entries = (process.env.PATH ?? '').split(delimiter).filter(Boolean);
+    const path = await Promise.all(entries.map(async dir => ({ dir, exists: await fs.stat(dir).then(s => s.isDirectory(), () => false) })));
+    const dirs = path.filter(p => p.exists).map(p => p.dir);
+    const [toolchains, linters, extras] = await Promise.all([
+        Promise.all(Object.entries(TOOLCHAINS).map(([name, args]) => probe(name, args, 'toolchain', dirs))),
+        Promise.all(Object.entries(LINTERS).map(([name, args]) => probe(name, args, 'linter', dirs))),
+        Promise.all(extra.map(name => probe(name, TOOLCHAINS[name] ?? LINTERS[name] ?? '--version', 'extra', dirs))),
+    ]);
+    return {
+        platform: process.platform,
+        arch: process.arch,
+        executor: Config.getInstance().getExecutor(),
+        toolchains,
+        linters,
+        extra: extras,
+        go: await goEnv(toolchains.find(t => t.name === 'go')?.path),
+        path,
+        inspectedAt: new Date().toISOString(),
+    };
+}
+
+function describeProbe(probe: ToolProbe): string {
+    if (!probe.found) return `  ${probe.name}: not found`;
+    return `  ${probe.name}: ${probe.version ?? 'unknown version'} (${probe.path})`;
+}
+
+export const inspectEnvironmentTool = {
+    name: 'inspect_environment',
+    description: 'Report what is installed where the server runs: toolchain versions (go, node, python, docker, rust, java, C/C++), go env (GOPATH, GOOS, GOARCH, ...), which linters and formatters are on PATH, and the PATH entries themselves. With a path, also the toolchain versions the project pins and which ones its commands run with. Results are cached for 10 minutes; pass refresh to inspect again.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, tools, refresh } = parseResult.data;
+        if (path && !Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const key = `${process.env.PATH ?? ''}\0${[...tools].sort().join(',')}`;
+            const fresh = cached && cached.key === key && Date.now() - cached.at < CACHE_TTL_MS && !refresh;
+            const report = fresh && cached ? cached.report : await inspectEnvironment(tools);
+            if (!fresh) cached = { key, at: Date.now(), report };
+
+            const warnings: string[] = [];
+            if (report.executor !== 'local') warnings.push(`Commands run in the ${report.executor} executor; these are the server host's tools, not the container's`);
+            const missing = report.path.filter(p => !p.exists).map(p => p.dir);
+            if (missing.length > 0) warnings.push(`PATH entries that do not exist: ${missing.join(', ')}`);
+            let toolchains = null;
+            if (path) {
+                const effective = await getEffectiveConfig(path);
+                toolchains = await selectToolchains(path, effective.config.toolchains ?? Config.getInstance().getToolchainMode(), getCommandEnv(effective.config));
+                warnings.push(...toolchains.warnings);
+            }
+            const lines = [
+                `Platform: ${report.platform}/${report.arch}`,
+                'Toolchains:', ...report.toolchains.map(describeProbe),
+                'Linters and formatters:', ...report.linters.map(describeProbe),
+                ...(report.extra.length > 0 ? ['Other:', ...report.extra.map(describeProbe)] : []),
+                ...(report.go ? ['Go env:', ...Object.entries(report.go).map(([k, v]) => `  ${k}=${v}`)] : []),
+                ...(toolchains && toolchains.selections.length > 0 ? ['Pinned toolchains:', ...toolchains.selections.map(s => `  ${s.language} ${s.version} (${s.file}): ${s.selected ? `${s.selected} via ${s.via}` : 'not available'}`)] : []),
+            ];
+            return { success: true, errors: [], warnings, output: lines.join('\n'), cached: Boolean(fresh), ...report, ...(toolchains ? { toolchains: toolchains.selections } : {}) };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
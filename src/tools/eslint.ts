@@ -0,0 +1,86 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, join } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+import { type Diagnostic, parseEslintJsonOutput, countBySeverity } from '../diagnostics/index.js';
+import { captureFixTargets, describeFixes, summarizeFixes } from '../utils/autofix.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('File or directory to lint'),
+    fix: z.boolean().default(false).describe('Apply eslint\'s fixes (--fix) and report what changed and what is left'),
+    timeout: z.number().default(120000),
+});
+
+export const eslintTool = {
+    name: 'eslint',
+    mutates: (args: any) => Boolean(args?.fix),
+    cacheable: true,
+    description: 'Lint JavaScript/TypeScript with the project\'s ESLint and return structured diagnostics (file, line, column, severity, rule, whether it is auto-fixable). Runs from the nearest package.json so the project\'s config applies. With fix, applies the fixes and returns the changed files with diffs plus the issues that remain.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, fix, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const startDir = (await fs.stat(path)).isDirectory() ? path : dirname(path);
+            const packageJson = await findUp(startDir, 'package.json');
+            const cwd = packageJson ? dirname(packageJson) : startDir;
+            // Prefer the project's own eslint version
+            const local = await findUp(cwd, join('node_modules', '.bin', 'eslint'));
+            const command = `${local ? shellQuote(local) : 'npx --no-install eslint'} --format json ${shellQuote(path)}`;
+
+            const result = await runCommand(command, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+            const diagnostics: Diagnostic[] = parseEslintJsonOutput(result.stdout, cwd);
+            // Exit code 1 means lint errors; 2 is a configuration or crash error
+            if (result.exitCode !== 0 && (result.exitCode !== 1 || diagnostics.length === 0)) {
+                return { success: false, errors: [`eslint failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout, diagnostics };
+            }
+            if (fix && diagnostics.some(d => d.fixable)) {
+                const before = await captureFixTargets(diagnostics);
+                const fixRun = await runCommand(command.replace(' --format json ', ' --fix --format json '), { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                // With --fix eslint reports only what is left
+                const remaining = parseEslintJsonOutput(fixRun.stdout, cwd);
+                if (fixRun.exitCode !== 0 && (fixRun.exitCode !== 1 || remaining.length === 0)) {
+                    return { success: false, errors: [`eslint --fix failed: ${fixRun.stderr || fixRun.stdout}`], warnings: [], output: fixRun.stdout, diagnostics };
+                }
+                const summary = await summarizeFixes(before, diagnostics, remaining);
+                const counts = countBySeverity(remaining);
+                return {
+                    success: counts.error === 0,
+                    errors: counts.error > 0 ? [`eslint reported ${counts.error} error(s) it could not fix`] : [],
+                    warnings: counts.warning > 0 ? [`eslint reported ${counts.warning} warning(s) it could not fix`] : [],
+                    output: describeFixes(summary),
+                    diagnostics: remaining,
+                    fixed: summary.fixed,
+                    changes: summary.changes,
+                };
+            }
+            const counts = countBySeverity(diagnostics);
+            const fixable = diagnostics.filter(d => d.fixable).length;
+            return {
+                success: counts.error === 0,
+                errors: counts.error > 0 ? [`eslint reported ${counts.error} error(s)`] : [],
+                warnings: counts.warning > 0 ? [`eslint reported ${counts.warning} warning(s)`] : [],
+                output: `${diagnostics.length} issue(s) found${fixable > 0 ? `, ${fixable} auto-fixable` : ''}`,
+                diagnostics,
+                ...(fix ? { fixed: 0, changes: [] } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,128 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { join } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { buildNoNetworkCommand, detectNetworkSandbox } from '../executor/sandbox.js';
+import { commandExists, runCommand } from '../utils/command.js';
+import { filterEnv } from '../utils/env.js';
+
+const inputSchema = z.object({
+    language: z.enum(['go', 'python', 'node']),
+    code: z.string().min(1).max(100 * 1024).describe('Go: a main package, or statements (after any imports) that become the body of main. Python: a script. Node: an ES module (top-level await works), or CommonJS when it uses require and no import'),
+    stdin: z.string().max(1024 * 1024).optional().describe('Fed to the snippet on standard input'),
+    timeout: z.number().int().positive().max(60000).default(10000).describe('Wall-clock limit in milliseconds, compilation included; CPU time is capped to the same'),
+});
+
+// Go module caches the compiler reuses; nothing else from the server's environment reaches the snippet
+const GO_ENV = ['GOCACHE', 'GOPATH', 'GOROOT', 'GOMODCACHE'];
+const MAX_OUTPUT = 1024 * 1024;
+
+let goVersion: Promise<string | null> | undefined;
+
+// The local toolchain's language version for go.mod: module semantics (generics, loop vars) follow it
+function localGoVersion(): Promise<string | null> {
+    goVersion ??= runCommand('go env GOVERSION', { local: true, timeout: 10000 })
+        .then(result => /^go(\d+\.\d+)/.exec(result.stdout.trim())?.[1] ?? null, () => null);
+    return goVersion;
+}
+
+/**
+ * A Go snippet as a main package: code with a package clause is used as is,
+ * code with a main function gets one, and anything else becomes the body
+ * of main with its leading imports kept at file level
+ */
+export function wrapGoSnippet(code: string): string {
+    if (/^\s*package\s+\w+/m.test(code)) return code;
+    if (/^func\s+main\s*\(\s*\)/m.test(code)) return `package main\n\n${code}\n`;
+    const imports = /^(?:\s*(?:\/\/[^\n]*|import\s*\([^)]*\)|import\s+(?:[\w.]+\s+)?"[^"]+"))*/.exec(code)?.[0] ?? '';
+    const body = code.slice(imports.length).replace(/^\n+/, '');
+    return `package main\n\n${imports.trim()}\n\nfunc main() {\n${body}\n}\n`;
+}
+
+async function prepare(dir: string, language: 'go' | 'python' | 'node', code: string): Promise<{ command: string; runner: string; env: Record<string, string> }> {
+    if (language === 'python') {
+        await fs.writeFile(join(dir, 'main.py'), code);
+        // Isolated mode: no user site-packages, no PYTHON* variables, the script's directory only
+        return { command: 'python3 -I main.py', runner: 'python3', env: {} };
+    }
+    if (language === 'node') {
+        const commonJs = /\brequire\s*\(/.test(code) && !/^\s*import\s/m.test(code);
+        const file = commonJs ? 'main.cjs' : 'main.mjs';
+        await fs.writeFile(join(dir, file), code);
+        return { command: `node ${file}`, runner: 'node', env: {} };
+    }
+    await fs.writeFile(join(dir, 'main.go'), wrapGoSnippet(code));
+    if (await commandExists('yaegi')) return { command: 'yaegi run main.go', runner: 'yaegi', env: {} };
+    const version = await localGoVersion();
+    await fs.writeFile(join(dir, 'go.mod'), `module snippet\n${version ? `\ngo ${version}\n` : ''}`);
+    // Standard library only: nothing is downloaded, not even a newer toolchain
+    return { command: 'go run .', runner: 'go run', env: { GOPROXY: 'off', GOFLAGS: '-mod=mod', GOTOOLCHAIN: 'local', GOWORK: 'off' } };
+}
+
+export const evalSnippetTool = {
+    name: 'eval_snippet',
+    // Runs arbitrary code, though never in the workspace and without network
+    dangerous: true,
+    description: 'Run a short Go, Python or Node snippet to check a hypothesis without writing files into the workspace. The snippet runs in a fresh temporary directory that is deleted afterwards, with no network access (a separate network namespace on Linux, sandbox-exec on macOS; the call is refused where neither is available), a minimal environment, and a strict wall-clock and CPU timeout (default 10 s). Go statements are wrapped in a main function and run with yaegi when installed, otherwise compiled in a temporary module against the standard library only. Returns stdout, stderr and the exit code.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { language, code, stdin, timeout } = parseResult.data;
+        const sandbox = await detectNetworkSandbox();
+        if (!sandbox) {
+            return {
+                success: false,
+                errors: ['No network sandbox on this host: eval_snippet needs unprivileged user namespaces (unshare) on Linux or sandbox-exec on macOS'],
+                warnings: [],
+                output: '',
+            };
+        }
+        const dir = await fs.mkdtemp(join(tmpdir(), 'cf-eval-'));
+        try {
+            const prepared = await prepare(dir, language, code);
+            if (stdin !== undefined) await fs.writeFile(join(dir, 'stdin'), stdin);
+            const command = `${prepared.command} < ${stdin !== undefined ? 'stdin' : '/dev/null'}`;
+            const result = await runCommand(buildNoNetworkCommand(command, sandbox), {
+                cwd: dir,
+                timeout,
+                // The temporary directory is on this host, whatever executor the workspace uses
+                local: true,
+                env: { ...filterEnv(language === 'go' ? GO_ENV : []), ...prepared.env },
+                inheritEnv: false,
+                maxBuffer: MAX_OUTPUT,
+                limits: { cpuSeconds: Math.ceil(timeout / 1000) },
+            });
+            const errors = result.limitExceeded === 'timeout'
+                ? [`Timed out after ${timeout} ms`]
+                : result.limitExceeded === 'cpu'
+                    ? [`Exceeded ${Math.ceil(timeout / 1000)} s of CPU time`]
+                    : result.exitCode !== 0 ? [`Exited with code ${result.exitCode}`] : [];
+            return {
+                success: result.exitCode === 0,
+                errors,
+                warnings: [],
+                output: result.stdout,
+                stdout: result.stdout,
+                stderr: result.stderr,
+                exitCode: result.exitCode,
+                duration: result.duration,
+                runner: prepared.runner,
+                sandbox,
+                ...(result.limitExceeded ? { limitExceeded: result.limitExceeded } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            await fs.rm(dir, { recursive: true, force: true }).catch(() => undefined);
+        }
+    },
+};
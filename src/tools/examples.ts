@@ -0,0 +1,329 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { dirname, join, relative, resolve } from 'path';
+import { minimatch } from 'minimatch';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { type Diagnostic, countBySeverity, parseLocationLines } from '../diagnostics/index.js';
+import { type CodeBlock, extractCodeBlocks } from '../docs/index.js';
+import { commandExists, runCommand } from '../utils/command.js';
+import { walkDirectory } from '../utils/gitignore.js';
+import { findUp } from '../utils/paths.js';
+import { shellQuote } from '../utils/shell.js';
+import { findVenvPython } from './python.js';
+
+const MARKDOWN_FILE = /\.(md|markdown)$/i;
+const MAX_FILES = 2000;
+const LANGUAGES: Record<string, 'go' | 'python'> = { go: 'go', golang: 'go', python: 'python', python3: 'python', py: 'python', pycon: 'python' };
+// Standard packages added to Go snippets that use them without importing, when goimports is not installed
+const STD_IMPORTS: Record<string, string> = {
+    bytes: 'bytes', context: 'context', errors: 'errors', filepath: 'path/filepath', fmt: 'fmt', http: 'net/http', io: 'io',
+    json: 'encoding/json', log: 'log', math: 'math', os: 'os', regexp: 'regexp', sort: 'sort', strconv: 'strconv', strings: 'strings',
+    sync: 'sync', time: 'time',
+};
+
+const inputSchema = z.object({
+    path: z.string().describe('Markdown file, or a directory to check every .md and .markdown file in (respecting .gitignore)'),
+    languages: z.array(z.enum(['go', 'python'])).default(['go', 'python']),
+    run: z.boolean().default(false).describe('Also run the snippets that compile and are programs (Go package main, any Python), reporting those that fail or exit non-zero'),
+    exclude: z.array(z.string()).default([]).describe('Glob patterns, relative to path, of files to skip'),
+    timeout: z.number().default(300000),
+});
+
+type Status = 'ok' | 'compile-error' | 'runtime-error' | 'skipped';
+
+interface Snippet {
+    doc: string;
+    block: CodeBlock;
+    language: 'go' | 'python';
+    // Path of the generated file, relative to the work directory
+    file: string;
+    // Block line (0-based) of each generated line, or null for lines added around the snippet
+    lineMap: (number | null)[];
+    program: boolean;
+    status: Status;
+    reason?: string;
+}
+
+// A "<!-- check_examples: ignore -->" comment on the line before the fence has the same effect as the attribute
+function isIgnored(block: CodeBlock, lines: string[]): boolean {
+    return block.attributes.includes('ignore') || /<!--\s*check_examples:\s*ignore\s*-->/.test(lines[block.line - 3] ?? '');
+}
+
+/**
+ * Turn a Go snippet into a compilable file: complete files are used as
+ * they are, bare declarations get a package clause, and bare statements
+ * go into func main with their imports hoisted above it
+ */
+function prepareGo(code: string): { lines: string[]; lineMap: (number | null)[]; program: boolean } {
+    const source = code.split('\n');
+    if (/^package\s+\w+/m.test(code)) {
+        return { lines: source, lineMap: source.map((_, k) => k), program: /^package\s+main\b/m.test(code) };
+    }
+    const lines: string[] = [];
+    const lineMap: (number | null)[] = [];
+    const push = (line: string, from: number | null) => {
+        lines.push(line);
+        lineMap.push(from);
+    };
+    // Declarations only: nothing at the top level but declarations, comments and their closing brackets
+    const declarations = source.every(line => !/^\S/.test(line) || /^(func|type|var|const|import)\b|^\/[/*]|^[)}]/.test(line));
+    if (declarations && /^(func|type|var|const)\b/m.test(code)) {
+        const program = /^func\s+main\s*\(/m.test(code);
+        push(program ? 'package main' : 'package example', null);
+        source.forEach((line, k) => push(line, k));
+        return { lines, lineMap, program };
+    }
+    push('package main', null);
+    let k = 0;
+    // Leading imports stay at the top level
+    for (; k < source.length; k++) {
+        const line = source[k]!;
+        if (line.trim() === '' || line.trim().startsWith('//')) {
+            push(line, k);
+            continue;
+        }
+        if (!line.startsWith('import')) break;
+        push(line, k);
+        if (/^import\s*\($/.test(line.trim())) {
+            while (++k < source.length) {
+                push(source[k]!, k);
+                if (source[k]!.trim() === ')') break;
+            }
+        }
+    }
+    push('func main() {', null);
+    for (; k < source.length; k++) push(source[k]!, k);
+    push('}', null);
+    return { lines, lineMap, program: true };
+}
+
+// Imports the snippet uses but does not declare, for the packages in STD_IMPORTS
+function addStdImports(lines: string[], lineMap: (number | null)[]): void {
+    const text = lines.join('\n').replace(/"(?:[^"\\\n]|\\.)*"|`[^`]*`|\/\/.*$/gm, '');
+    const declared = new Set([...text.matchAll(/(?:^import\s+|^\s+)(?:(\w+)\s+)?"([^"]+)"/gm)].map(m => m[1] ?? m[2]!.split('/').pop()!));
+    const missing = Object.keys(STD_IMPORTS).filter(name => !declared.has(name) && new RegExp(`(^|[^\\w.])${name}\\.[A-Z]`, 'm').test(text));
+    if (missing.length === 0) return;
+    const at = lines.findIndex(line => /^package\s/.test(line)) + 1;
+    lines.splice(at, 0, ...missing.map(name => `import "${STD_IMPORTS[name]}"`));
+    lineMap.splice(at, 0, ...missing.map(() => null));
+}
+
+// Interactive sessions keep only their >>> and ... input lines; the rest is output
+function preparePython(block: CodeBlock): { lines: string[]; lineMap: (number | null)[] } {
+    const source = block.code.split('\n');
+    const session = block.language === 'pycon' || source.some(line => line.startsWith('>>> '));
+    if (!session) return { lines: source, lineMap: source.map((_, k) => k) };
+    const lines: string[] = [];
+    const lineMap: (number | null)[] = [];
+    source.forEach((line, k) => {
+        const input = /^(>>>|\.\.\.)( |$)(.*)$/.exec(line);
+        lines.push(input ? input[3]! : '');
+        lineMap.push(input ? k : null);
+    });
+    return { lines, lineMap };
+}
+
+// The Markdown line of a generated line; lines added around the snippet map to the fence
+function docLine(snippet: Snippet, generated: number): number {
+    let k = Math.min(Math.max(generated - 1, 0), snippet.lineMap.length - 1);
+    while (k > 0 && snippet.lineMap[k] == null) k--;
+    const from = snippet.lineMap[k];
+    return from == null ? snippet.block.line - 1 : snippet.block.line + from;
+}
+
+// Compiles every file it is given and prints one JSON line per syntax error
+const PY_COMPILE = `import json, sys
+for path in sys.argv[1:]:
+    try:
+        compile(open(path, encoding="utf-8").read(), path, "exec")
+    except SyntaxError as e:
+        print(json.dumps({"file": path, "line": e.lineno or 1, "column": e.offset or 0, "message": f"{type(e).__name__}: {e.msg}"}))
+`;
+
+export const checkExamplesTool = {
+    name: 'check_examples',
+    binaries: ['go', 'python3'],
+    description: 'Check that the Go and Python code blocks in Markdown documentation still compile: each fenced block is written to a temporary module (Go snippets of bare statements are wrapped in func main, and can import the documented module, which is replaced with the local copy) and built, or byte-compiled for Python, with errors reported on the Markdown lines they come from. With run, programs that compile are also run and failures reported. Blocks marked ```go ignore or preceded by <!-- check_examples: ignore --> are skipped; norun blocks are compiled only.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, languages, run, exclude, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        let workDir: string | undefined;
+        try {
+            const target = resolve(path);
+            const isDirectory = (await fs.stat(target)).isDirectory();
+            const root = isDirectory ? target : dirname(target);
+            const warnings: string[] = [];
+            const docs: string[] = [];
+            if (isDirectory) {
+                await walkDirectory(target, { respectGitignore: true }, entry => {
+                    if (docs.length >= MAX_FILES) return false;
+                    if (entry.type !== 'file' || !MARKDOWN_FILE.test(entry.path) || /(^|\/)(node_modules|vendor)\//.test(entry.relativePath)) return;
+                    if (exclude.some(pattern => minimatch(entry.relativePath, pattern, { dot: true }))) return;
+                    docs.push(entry.path);
+                });
+                if (docs.length >= MAX_FILES) warnings.push(`Stopped at ${MAX_FILES} Markdown files`);
+            } else {
+                docs.push(target);
+            }
+            docs.sort();
+
+            const snippets: Snippet[] = [];
+            for (const doc of docs) {
+                const source = await fs.readFile(doc, 'utf-8');
+                const lines = source.split('\n');
+                for (const block of extractCodeBlocks(source)) {
+                    const language = LANGUAGES[block.language];
+                    if (!language || !languages.includes(language) || !block.code.trim()) continue;
+                    const n = snippets.length + 1;
+                    const snippet: Snippet = { doc, block, language, file: '', lineMap: [], program: language === 'python', status: 'ok' };
+                    snippets.push(snippet);
+                    if (isIgnored(block, lines)) {
+                        snippet.status = 'skipped';
+                        continue;
+                    }
+                    workDir ??= await fs.mkdtemp(join(tmpdir(), 'cf-examples-'));
+                    if (language === 'go') {
+                        const prepared = prepareGo(block.code);
+                        snippet.file = `snippet${n}/main.go`;
+                        snippet.lineMap = prepared.lineMap;
+                        snippet.program = prepared.program;
+                        if (!await commandExists('goimports')) addStdImports(prepared.lines, prepared.lineMap);
+                        await fs.mkdir(join(workDir, `snippet${n}`));
+                        await fs.writeFile(join(workDir, snippet.file), prepared.lines.join('\n') + '\n');
+                    } else {
+                        const prepared = preparePython(block);
+                        snippet.file = `snippet${n}.py`;
+                        snippet.lineMap = prepared.lineMap;
+                        await fs.writeFile(join(workDir, snippet.file), prepared.lines.join('\n') + '\n');
+                    }
+                }
+            }
+
+            const diagnostics: Diagnostic[] = [];
+            const fail = (snippet: Snippet, status: Status, generatedLine: number, column: number, message: string) => {
+                if (snippet.status === 'ok') snippet.status = status;
+                snippet.reason ??= message;
+                diagnostics.push({
+                    file: snippet.doc,
+                    line: docLine(snippet, generatedLine),
+                    column,
+                    severity: 'error',
+                    message: `${snippet.language === 'go' ? 'Go' : 'Python'} example ${status === 'runtime-error' ? 'fails when run' : 'does not compile'}: ${message}`,
+                    rule: status,
+                    source: 'check_examples',
+                });
+            };
+            const byFile = (file: string) => workDir ? snippets.find(s => s.file && resolve(workDir!, s.file) === file) : undefined;
+            const runTimeout = Math.min(timeout, 60000);
+
+            const goSnippets = snippets.filter(s => s.language === 'go' && s.status !== 'skipped');
+            if (goSnippets.length > 0 && workDir) {
+                if (!await commandExists('go')) {
+                    warnings.push('go is not installed, so Go examples were not checked');
+                    goSnippets.forEach(s => { s.status = 'skipped'; s.reason = 'go is not installed'; });
+                } else {
+                    // Snippets may import the documented module, which then resolves to this checkout
+                    const moduleFile = await findUp(root, 'go.mod');
+                    const goMod = ['module examples', ''];
+                    if (moduleFile) {
+                        const manifest = await fs.readFile(moduleFile, 'utf-8');
+                        const modulePath = /^module\s+(\S+)/m.exec(manifest)?.[1];
+                        const goVersion = /^go\s+(\S+)/m.exec(manifest)?.[1];
+                        if (goVersion) goMod.push(`go ${goVersion}`, '');
+                        if (modulePath && goSnippets.some(s => s.block.code.includes(`"${modulePath}`))) {
+                            goMod.push(`require ${modulePath} v0.0.0`, `replace ${modulePath} => ${dirname(moduleFile)}`);
+                            await fs.copyFile(join(dirname(moduleFile), 'go.sum'), join(workDir, 'go.sum')).catch(() => undefined);
+                        }
+                    }
+                    await fs.writeFile(join(workDir, 'go.mod'), goMod.join('\n') + '\n');
+                    if (await commandExists('goimports')) await runCommand(`goimports -w ${goSnippets.map(s => shellQuote(s.file)).join(' ')}`, { cwd: workDir, timeout, local: true });
+
+                    const build = await runCommand('go build ./...', { cwd: workDir, timeout, local: true, env: { GOFLAGS: '-mod=mod', GOWORK: 'off' }, maxBuffer: 16 * 1024 * 1024 });
+                    for (const d of parseLocationLines(build.stderr, { cwd: workDir, source: 'go' })) {
+                        const snippet = byFile(d.file);
+                        if (snippet) fail(snippet, 'compile-error', d.line, d.column, d.message);
+                    }
+                    if (build.exitCode !== 0 && !goSnippets.some(s => s.status === 'compile-error')) {
+                        return { success: false, errors: [`go build failed: ${build.stderr.trim()}`], warnings, output: '' };
+                    }
+                    for (const snippet of goSnippets) {
+                        if (!run || !snippet.program || snippet.status !== 'ok' || snippet.block.attributes.includes('norun')) continue;
+                        const result = await runCommand(`go run ./${dirname(snippet.file)}`, { cwd: workDir, timeout: runTimeout, local: true, env: { GOFLAGS: '-mod=mod', GOWORK: 'off' } });
+                        if (result.exitCode === 0) continue;
+                        // Panics name the snippet's file in their stack
+                        const frame = new RegExp(`${snippet.file.replace('.', '\\.')}:(\\d+)`).exec(result.stderr);
+                        const last = result.stderr.trim().split('\n').find(line => line.startsWith('panic:')) ?? result.stderr.trim().split('\n').pop() ?? '';
+                        fail(snippet, 'runtime-error', frame ? Number(frame[1]) : 0, 0, `exited with ${result.exitCode}${last ? `: ${last}` : ''}`);
+                    }
+                }
+            }
+
+            const pySnippets = snippets.filter(s => s.language === 'python' && s.status !== 'skipped');
+            if (pySnippets.length > 0 && workDir) {
+                const python = await findVenvPython(root) ?? (await commandExists('python3') ? 'python3' : null);
+                if (!python) {
+                    warnings.push('python3 is not installed, so Python examples were not checked');
+                    pySnippets.forEach(s => { s.status = 'skipped'; s.reason = 'python3 is not installed'; });
+                } else {
+                    await fs.writeFile(join(workDir, 'compile_check.py'), PY_COMPILE);
+                    const compiled = await runCommand(`${shellQuote(python)} compile_check.py ${pySnippets.map(s => shellQuote(s.file)).join(' ')}`, { cwd: workDir, timeout, local: true });
+                    for (const line of compiled.stdout.split('\n')) {
+                        if (!line.startsWith('{')) continue;
+                        const error = JSON.parse(line);
+                        const snippet = byFile(resolve(workDir, error.file));
+                        if (snippet) fail(snippet, 'compile-error', error.line, error.column, error.message);
+                    }
+                    for (const snippet of pySnippets) {
+                        if (!run || snippet.status !== 'ok' || snippet.block.attributes.includes('norun')) continue;
+                        // Run from the documentation's directory so relative imports of the project work
+                        const result = await runCommand(`${shellQuote(python)} ${shellQuote(join(workDir, snippet.file))}`, { cwd: dirname(snippet.doc), timeout: runTimeout, local: true, env: { PYTHONPATH: dirname(snippet.doc) } });
+                        if (result.exitCode === 0) continue;
+                        const frames = [...result.stderr.matchAll(new RegExp(`File "[^"]*${snippet.file.replace('.', '\\.')}", line (\\d+)`, 'g'))];
+                        const last = result.stderr.trim().split('\n').pop() ?? '';
+                        fail(snippet, 'runtime-error', frames.length > 0 ? Number(frames[frames.length - 1]![1]) : 0, 0, `exited with ${result.exitCode}${last ? `: ${last}` : ''}`);
+                    }
+                }
+            }
+
+            const counts = countBySeverity(diagnostics);
+            const display = (file: string) => relative(root, file) || file;
+            const broken = snippets.filter(s => s.status === 'compile-error' || s.status === 'runtime-error');
+            const skipped = snippets.filter(s => s.status === 'skipped').length;
+            return {
+                success: counts.error === 0,
+                errors: broken.length > 0 ? [`${broken.length} of ${snippets.length} example(s) broken`] : [],
+                warnings,
+                output: [
+                    ...diagnostics.map(d => `${display(d.file)}:${d.line}:${d.column}: ${d.severity}: ${d.message}`),
+                    `${snippets.length} example(s) in ${docs.length} Markdown file(s): ${snippets.length - broken.length - skipped} ok, ${broken.length} broken, ${skipped} skipped`,
+                ].join('\n'),
+                snippets: snippets.map(s => ({
+                    file: s.doc,
+                    line: s.block.line - 1,
+                    language: s.language,
+                    status: s.status,
+                    ...(s.reason ? { reason: s.reason } : {}),
+                })),
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            if (workDir) await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
@@ -0,0 +1,430 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, isAbsolute, relative, resolve } from 'path';
+import * as diffLib from 'diff';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { auditLog, sha256, type AuditEntry } from '../audit/index.js';
+import { snapshotStore, type Snapshot } from '../snapshots/index.js';
+import { historyStore, type HistoryRun } from '../history/index.js';
+import type { Diagnostic, TestCaseResult } from '../diagnostics/index.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Workspace whose failures are explained'),
+    diagnostics: z.array(z.object({
+        file: z.string().optional(),
+        line: z.number().int().optional(),
+        message: z.string(),
+        source: z.string().optional(),
+        rule: z.string().optional(),
+    })).optional().describe('The failures to explain (e.g. the error diagnostics of a result); relative files resolve against path'),
+    pipeline: z.string().optional().describe('Run this pipeline now and explain its failures'),
+    run: z.string().optional().describe('Explain the failures of this recorded run_pipeline run (see get_history); the latest run by default'),
+    since: z.string().optional().describe('Only edits from this ISO time on; by default edits since the last passing run of the workspace'),
+    limit: z.number().int().positive().max(500).default(50).describe('Most recent edits considered'),
+}).refine(args => [args.diagnostics, args.pipeline, args.run].filter(v => v !== undefined).length <= 1, { message: 'Pass at most one of diagnostics, pipeline or run' });
+
+export interface Failure {
+    message: string;
+    file?: string;
+    line?: number;
+    source?: string;
+    rule?: string;
+}
+
+/**
+ * One file a recorded tool call changed, with its diff when the snapshot
+ * still has the content before the call
+ */
+export interface EditedFile {
+    path: string;
+    action: 'write' | 'delete' | 'move' | 'copy';
+    // Unified diff of the call's change; absent when the earlier content was not kept
+    diff?: string;
+    // Lines the change added or removed at, numbered as in the file now
+    lines: number[];
+    // Text the change removed and added, searched for the symbols failures name
+    removed: string;
+    added: string;
+    // The file changed again outside tool calls, so lines may be off
+    drifted?: boolean;
+}
+
+export interface Edit {
+    // Audit entry id
+    id: string;
+    tool: string;
+    timestamp: string;
+    // revert_to_snapshot undoes this call (and every later one)
+    snapshot?: string;
+    files: EditedFile[];
+}
+
+export type CauseReason = 'line' | 'symbol' | 'file' | 'directory' | 'recent';
+
+export interface Cause {
+    edit: string;
+    tool: string;
+    timestamp: string;
+    file: string;
+    reason: CauseReason;
+    detail: string;
+    // The part of the diff behind the failure
+    hunk?: string;
+    snapshot?: string;
+}
+
+interface Hunk {
+    oldStart: number;
+    oldLines: number;
+    newStart: number;
+    newLines: number;
+    lines: string[];
+}
+
+// How much each kind of link explains a failure; stronger first, then newer edits
+const STRENGTH: Record<CauseReason, number> = { line: 4, symbol: 3, file: 2, directory: 1, recent: 0 };
+// A failing line this close to a changed one counts as caused by it
+const LINE_WINDOW = 3;
+const MAX_CAUSES = 3;
+// Snapshots are saved as the call ends, shortly before its audit entry
+const SNAPSHOT_SLACK_MS = 2000;
+
+/**
+ * Where the lines of oldText went in newText: the new line number of each
+ * old one, or null for lines newText no longer has
+ */
+export function lineMapper(oldText: string, newText: string): (line: number) => number | null {
+    const { hunks } = diffLib.structuredPatch('a', 'b', oldText, newText, '', '', { context: 0 });
+    return line => {
+        let offset = 0;
+        for (const hunk of hunks) {
+            if (line < hunk.oldStart) break;
+            if (line < hunk.oldStart + hunk.oldLines) return null;
+            offset += hunk.newLines - hunk.oldLines;
+        }
+        return line + offset;
+    };
+}
+
+/**
+ * Lines of after where a change from before added or removed something, and the removed and added text
+ */
+export function changedLines(before: string, after: string): { lines: number[]; removed: string; added: string; hunks: Hunk[] } {
+    const patch = diffLib.structuredPatch('a', 'b', before, after, '', '', { context: LINE_WINDOW });
+    const lines = new Set<number>();
+    const removed: string[] = [];
+    const added: string[] = [];
+    for (const hunk of patch.hunks) {
+        let newLine = hunk.newStart;
+        for (const line of hunk.lines) {
+            if (line.startsWith('+')) {
+                lines.add(newLine++);
+                added.push(line.slice(1));
+            } else if (line.startsWith('-')) {
+                lines.add(Math.max(newLine, 1));
+                removed.push(line.slice(1));
+            } else if (!line.startsWith('\\')) {
+                newLine++;
+            }
+        }
+    }
+    return { lines: [...lines], removed: removed.join('\n'), added: added.join('\n'), hunks: patch.hunks };
+}
+
+/**
+ * Identifiers a failure message names: quoted ones, and those of "undefined: X" or "X is not defined"
+ */
+export function failureSymbols(message: string): string[] {
+    const symbols = new Set<string>();
+    for (const match of message.matchAll(/[`'"‘“]([A-Za-z_$][\w$.]*)[`'"’”]|undefined: ([\w.]+)|\b([A-Za-z_$][\w$]*) is not defined/g)) {
+        const name = (match[1] ?? match[2] ?? match[3] ?? '').split('.').pop()!;
+        if (name.length >= 3) symbols.add(name);
+    }
+    return [...symbols];
+}
+
+function hasWord(text: string, word: string): boolean {
+    return new RegExp(`(^|[^\\w$])${word.replace(/\$/g, '\\$')}([^\\w$]|$)`).test(text);
+}
+
+function formatHunk(hunk: Hunk): string {
+    return [`@@ -${hunk.oldStart},${hunk.oldLines} +${hunk.newStart},${hunk.newLines} @@`, ...hunk.lines].join('\n');
+}
+
+// The snapshot a recorded call left: same tool, saved while the call ran, sharing a file
+function snapshotFor(entry: AuditEntry, snapshots: Snapshot[]): Snapshot | undefined {
+    const start = Date.parse(entry.timestamp);
+    const paths = new Set(entry.files.map(f => f.path));
+    return snapshots.find(s => {
+        const time = Date.parse(s.timestamp);
+        return s.tool === entry.tool && time >= start && time <= start + entry.durationMs + SNAPSHOT_SLACK_MS && s.files.some(f => paths.has(f.path));
+    });
+}
+
+async function readCurrent(path: string): Promise<Buffer | null> {
+    return fs.readFile(path).catch(() => null);
+}
+
+/**
+ * The recorded edits, each file with its diff and the lines it changed
+ * mapped onto the file as it is now. entries are newest first; so are the edits.
+ */
+export async function loadEdits(entries: AuditEntry[], snapshots: Snapshot[]): Promise<{ edits: Edit[]; hunks: Map<string, Hunk[]> }> {
+    const edits: Edit[] = [];
+    // Diff hunks by edit id and path, for the causes that point into them
+    const hunks = new Map<string, Hunk[]>();
+    const current = new Map<string, Buffer | null>();
+    const seen = new Set<string>();
+    for (const entry of entries) {
+        const snapshot = snapshotFor(entry, snapshots);
+        const files: EditedFile[] = [];
+        for (const change of entry.files) {
+            const kept = snapshot?.files.find(f => f.path === change.path);
+            if (!current.has(change.path)) current.set(change.path, await readCurrent(change.path));
+            const now = current.get(change.path)!;
+            // Only the newest edit of a file should match it as it is now
+            const latest = !seen.has(change.path);
+            seen.add(change.path);
+            const unchanged = now !== null && change.sha256 === sha256(now);
+            const drifted = latest && (change.action === 'delete' ? now !== null : !unchanged);
+            const base: EditedFile = { path: change.path, action: change.action, lines: [], removed: '', added: '', ...(drifted ? { drifted: true } : {}) };
+            // Content before the call: the snapshot's copy, or nothing when the file was created
+            const before = kept === undefined ? undefined : kept.sha256 ? (await snapshotStore.readBlob(kept.sha256)) ?? undefined : null;
+            // Content after the call: the file now, or a later call's copy of it. Once
+            // edited outside tool calls, the newest edit is compared with the file as it is
+            const afterCopy = change.sha256 && !unchanged ? await snapshotStore.readBlob(change.sha256) : null;
+            const after = change.action === 'delete' ? null
+                : unchanged ? now
+                : afterCopy ?? (latest && now ? now : undefined);
+            if (before === undefined || after === undefined) {
+                files.push(base);
+                continue;
+            }
+            const beforeText = before?.toString('utf-8') ?? '';
+            const afterText = after?.toString('utf-8') ?? '';
+            const changed = changedLines(beforeText, afterText);
+            const toNow = now && after && !now.equals(after) ? lineMapper(afterText, now.toString('utf-8')) : (line: number) => line;
+            hunks.set(`${entry.id}\0${change.path}`, changed.hunks.map(h => ({ ...h, newStart: toNow(h.newStart) ?? h.newStart })));
+            files.push({
+                ...base,
+                ...(changed.hunks.length > 0 ? { diff: diffLib.createPatch(change.path, beforeText, afterText, '', '') } : {}),
+                lines: changed.lines.map(toNow).filter((line): line is number => line !== null),
+                removed: changed.removed,
+                added: changed.added,
+            });
+        }
+        edits.push({ id: entry.id, tool: entry.tool, timestamp: entry.timestamp, ...(snapshot ? { snapshot: snapshot.id } : {}), files });
+    }
+    return { edits, hunks };
+}
+
+/**
+ * The edits most likely behind each failure: one that changed the failing
+ * lines, removed a symbol the failure names, changed the failing file, or
+ * changed a file next to it. A failure with no file points at the newest edit.
+ */
+export function correlate(failures: Failure[], edits: Edit[], hunks: Map<string, Hunk[]> = new Map()): Array<Failure & { causes: Cause[] }> {
+    return failures.map(failure => {
+        const causes: Cause[] = [];
+        const symbols = failureSymbols(failure.message);
+        const file = failure.file ? resolve(failure.file) : undefined;
+        for (const edit of edits) {
+            const cause = (changed: EditedFile, reason: CauseReason, detail: string, hunk?: Hunk): Cause => ({
+                edit: edit.id,
+                tool: edit.tool,
+                timestamp: edit.timestamp,
+                file: changed.path,
+                reason,
+                detail,
+                ...(hunk ? { hunk: formatHunk(hunk) } : {}),
+                ...(edit.snapshot ? { snapshot: edit.snapshot } : {}),
+            });
+            let best: Cause | undefined;
+            const consider = (candidate: Cause) => {
+                if (!best || STRENGTH[candidate.reason] > STRENGTH[best.reason]) best = candidate;
+            };
+            for (const changed of edit.files) {
+                const editHunks = hunks.get(`${edit.id}\0${changed.path}`) ?? [];
+                if (file && changed.path === file) {
+                    const near = failure.line !== undefined ? changed.lines.find(line => Math.abs(line - failure.line!) <= LINE_WINDOW) : undefined;
+                    if (near !== undefined) {
+                        consider(cause(changed, 'line', `changed line ${near}${changed.drifted ? ' (the file was edited outside tool calls since)' : ''}`, editHunks.find(h => near >= h.newStart && near < h.newStart + Math.max(h.newLines, 1))));
+                    } else {
+                        consider(cause(changed, 'file', changed.action === 'delete' ? 'deleted the file' : 'changed the failing file elsewhere'));
+                    }
+                }
+                const gone = symbols.find(symbol => hasWord(changed.removed, symbol) && !hasWord(changed.added, symbol));
+                if (gone) {
+                    consider(cause(changed, 'symbol', `removed or renamed ${gone}`, editHunks.find(h => h.lines.some(l => l.startsWith('-') && hasWord(l, gone)))));
+                }
+                if (file && changed.path !== file && dirname(changed.path) === dirname(file)) {
+                    consider(cause(changed, 'directory', `changed ${relative(dirname(file), changed.path)} in the same directory`));
+                }
+            }
+            if (best) causes.push(best);
+        }
+        if (!file && causes.length === 0 && edits[0]?.files[0]) {
+            const newest = edits[0];
+            causes.push({
+                edit: newest.id,
+                tool: newest.tool,
+                timestamp: newest.timestamp,
+                file: newest.files[0]!.path,
+                reason: 'recent',
+                detail: 'the newest edit before the failure',
+                ...(newest.snapshot ? { snapshot: newest.snapshot } : {}),
+            });
+        }
+        // Edits are newest first, so the stable sort keeps newer edits ahead among equals
+        causes.sort((a, b) => STRENGTH[b.reason] - STRENGTH[a.reason]);
+        return { ...failure, causes: causes.slice(0, MAX_CAUSES) };
+    });
+}
+
+function fromDiagnostics(diagnostics: Array<Partial<Diagnostic> & { message: string }>, root: string): Failure[] {
+    return diagnostics.map(d => ({
+        message: d.message,
+        ...(d.file ? { file: isAbsolute(d.file) ? d.file : resolve(root, d.file) } : {}),
+        ...(d.line ? { line: d.line } : {}),
+        ...(d.source ? { source: d.source } : {}),
+        ...(d.rule ? { rule: d.rule } : {}),
+    }));
+}
+
+function fromTests(tests: TestCaseResult[], root: string): Failure[] {
+    return tests
+        .filter(t => t.status === 'failed' || t.status === 'errored')
+        .map(t => ({
+            message: `${t.suite} ${t.name} ${t.status}: ${t.message ?? ''}`.trim(),
+            ...(t.file ? { file: isAbsolute(t.file) ? t.file : resolve(root, t.file) } : {}),
+            ...(t.line ? { line: t.line } : {}),
+            source: 'test',
+        }));
+}
+
+function fromRun(run: HistoryRun): Failure[] {
+    const errors = run.diagnostics.filter(d => d.severity === 'error');
+    return errors.length > 0 ? fromDiagnostics(errors, run.workspace) : [{ message: run.summary }];
+}
+
+function describeFailure(failure: Failure, root: string): string {
+    const where = failure.file ? `${relative(root, failure.file) || failure.file}${failure.line ? `:${failure.line}` : ''} ` : '';
+    return `${where}${failure.message.split('\n')[0]}`;
+}
+
+export const explainFailureTool = {
+    name: 'explain_failure',
+    // Running a pipeline runs its steps, which may format, generate code or run shell
+    mutates: (args: any) => typeof args?.pipeline === 'string',
+    description: 'Explain current build or test failures by the recent edits that caused them. Correlates the failures (given as diagnostics, from running a pipeline, or from the latest recorded run) with the file changes in the audit log since the workspace last passed, and the diffs their snapshots kept. Each failure gets its likely causes, strongest first: the edit that changed the failing lines, one that removed or renamed the symbol the error names, one that changed the failing file, or one next to it. Each cause carries the tool, time, diff hunk and the snapshot to pass to revert_to_snapshot. Also ranks the edits by how many failures they explain, so the first change to fix or revert is named.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, diagnostics, pipeline, run: runId, since, limit } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            if (!auditLog.isEnabled()) {
+                return { success: false, errors: ['The audit log is off (MCP_AUDIT=off), so there are no recorded edits to correlate'], warnings: [], output: '' };
+            }
+            const root = resolve(path);
+            const warnings: string[] = [];
+            const recent = historyStore.isEnabled() ? await historyStore.list({ workspace: root, limit: 200 }) : [];
+
+            // The failures, and when they were seen: edits after that cannot have caused them
+            let failures: Failure[];
+            let failedAt: string | undefined;
+            if (diagnostics) {
+                failures = fromDiagnostics(diagnostics, root);
+            } else if (pipeline) {
+                // Imported lazily: the tool registry imports this module
+                const { runPipelineTool } = await import('./pipeline.js');
+                const result: any = await runPipelineTool.run({ path: root, pipeline });
+                if (!result.steps) return { success: false, errors: result.errors, warnings: result.warnings ?? [], output: '' };
+                const errors = (result.diagnostics as Diagnostic[]).filter(d => d.severity === 'error');
+                failures = [
+                    ...fromDiagnostics(errors, root),
+                    ...fromTests(result.tests?.results ?? [], root),
+                    // Failed steps that said nothing more precise
+                    ...(result.steps as any[])
+                        .filter(s => s.status === 'failed' && !(s.diagnostics ?? []).some((d: Diagnostic) => d.severity === 'error') && !(s.tests ?? []).some((t: TestCaseResult) => t.status === 'failed' || t.status === 'errored'))
+                        .map(s => ({ message: `${s.name}: ${s.errors[0] ?? 'failed'}` })),
+                ];
+            } else {
+                if (!historyStore.isEnabled()) {
+                    return { success: false, errors: ['Run history is off (MCP_RESULTS_STORE=off); pass diagnostics or pipeline'], warnings: [], output: '' };
+                }
+                const run = runId ? await historyStore.get(runId) : recent[0] ?? null;
+                if (!run) return { success: false, errors: [runId ? `No run found for ${runId}` : 'No runs recorded for this workspace; pass diagnostics or pipeline'], warnings: [], output: '' };
+                if (!Config.getInstance().isPathAllowed(run.workspace)) {
+                    return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+                }
+                failures = run.passed ? [] : fromRun(run);
+                failedAt = run.createdAt;
+            }
+            if (failures.length === 0) {
+                return { success: true, errors: [], warnings, output: 'Nothing is failing', failures: [], edits: [], suspects: [] };
+            }
+
+            // Edits since the workspace last passed, or the given time
+            const lastPass = recent.find(r => r.passed && (!failedAt || r.createdAt < failedAt));
+            const from = since ?? lastPass?.createdAt;
+            const { entries } = await auditLog.query({ path: root, mutatingOnly: true, ...(from ? { since: from } : {}), ...(failedAt ? { until: failedAt } : {}), limit });
+            const changing = entries.filter(e => e.files.length > 0 && e.status !== 'rejected');
+            const { edits, hunks } = await loadEdits(changing, await snapshotStore.list());
+            const explained = correlate(failures, edits, hunks);
+
+            if (edits.length === 0) {
+                warnings.push(`No tool call changed files under ${root}${from ? ` since ${from}` : ''}; the failures come from elsewhere`);
+            }
+            const unrestorable = edits.filter(e => !e.snapshot).length;
+            if (unrestorable > 0) warnings.push(`${unrestorable} edit(s) have no snapshot left, so their diffs are unknown`);
+            const drifted = [...new Set(edits.flatMap(e => e.files.filter(f => f.drifted).map(f => f.path)))];
+            if (drifted.length > 0) warnings.push(`Changed outside tool calls since their last recorded edit: ${drifted.map(p => relative(root, p)).join(', ')}`);
+
+            // Edits by the failures they are the strongest cause of
+            const suspects = edits
+                .map(edit => {
+                    const top = explained.filter(f => f.causes[0]?.edit === edit.id);
+                    return { edit: edit.id, tool: edit.tool, timestamp: edit.timestamp, files: edit.files.map(f => f.path), ...(edit.snapshot ? { snapshot: edit.snapshot } : {}), explains: top.length, reasons: [...new Set(top.map(f => f.causes[0]!.reason))] };
+                })
+                .filter(s => s.explains > 0)
+                .sort((a, b) => b.explains - a.explains || Math.max(...b.reasons.map(r => STRENGTH[r])) - Math.max(...a.reasons.map(r => STRENGTH[r])));
+
+            const lines = [`${failures.length} failure(s), ${edits.length} edit(s) ${from ? `since ${from}` : 'recorded'}`];
+            for (const [index, failure] of explained.entries()) {
+                lines.push(`${index + 1}. ${describeFailure(failure, root)}`);
+                if (failure.causes.length === 0) lines.push('   not explained by a recorded edit');
+                for (const cause of failure.causes) {
+                    lines.push(`   <- ${cause.tool} ${cause.timestamp} on ${relative(root, cause.file) || cause.file}: ${cause.detail}${cause.snapshot ? ` [snapshot ${cause.snapshot}]` : ''}`);
+                }
+            }
+            const first = suspects[0];
+            if (first) {
+                lines.push(`Most likely cause: ${first.tool} at ${first.timestamp} (${first.files.map(p => relative(root, p)).join(', ')}) explains ${first.explains} of ${failures.length} failure(s)${first.snapshot ? `; revert_to_snapshot ${first.snapshot} undoes it and every later edit` : ''}`);
+            }
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: lines.join('\n'),
+                ...(from ? { since: from } : {}),
+                failures: explained,
+                suspects,
+                edits,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -1,37 +1,16 @@
 import { z } from 'zod';
 import { promises as fs } from 'fs';
-import { dirname, resolve, isAbsolute, join } from 'path';
-import Config from '../config/index.js';
+import { dirname, join } from 'path';
+import { validatePath } from '../utils/sandbox.js';
+import { checkContentForSecrets } from '../utils/secrets.js';
+import { recordFileChange } from '../audit/index.js';
+import { captureBeforeChange } from '../snapshots/index.js';
+import { describeRead, readFileBytes, readFileWindow, DEFAULT_MAX_READ_BYTES } from '../utils/fileread.js';
+import { formatPreviews, previewChange, previewDirectory, type ChangePreview } from '../utils/preview.js';
 import { randomBytes } from 'crypto';
 import { minimatch } from 'minimatch';
 import { zodToJsonSchema } from 'zod-to-json-schema';
 
-// --- Secure Path Validation ---
-async function validatePath(p: string): Promise<string> {
-    if (!Config.getInstance().isPathAllowed(p)) {
-        throw new Error(`Access denied: path not allowed (${p})`);
-    }
-    const abs = isAbsolute(p) ? resolve(p) : resolve(process.cwd(), p);
-    try {
-        const real = await fs.realpath(abs);
-        if (!Config.getInstance().isPathAllowed(real)) {
-            throw new Error(`Access denied: real path not allowed (${real})`);
-        }
-        return real;
-    } catch (e: any) {
-        if (e.code === 'ENOENT') {
-            // For new files, check parent dir
-            const parent = dirname(abs);
-            const realParent = await fs.realpath(parent);
-            if (!Config.getInstance().isPathAllowed(realParent)) {
-                throw new Error(`Access denied: parent dir not allowed (${realParent})`);
-            }
-            return abs;
-        }
-        throw e;
-    }
-}
-
 // --- Forgiving LLM misspellings ---
 function normalizeOpType(type: string): string {
     const action = type.toLowerCase();
@@ -76,7 +55,12 @@ const fsOpSchema = z.discriminatedUnion('type', [
     z.object({
         type: z.literal('readFile'),
         path: z.string().describe('Path to file to read.'),
-        encoding: z.string().optional().describe('File encoding, default utf-8.'),
+        encoding: z.string().optional().describe('File encoding, default utf-8. base64, hex or latin1 return the raw bytes (up to maxBytes); binary files are otherwise summarized, not returned.'),
+        startLine: z.number().int().positive().optional().describe('First line to return (1-based).'),
+        endLine: z.number().int().positive().optional().describe('Last line to return (inclusive).'),
+        head: z.number().int().positive().optional().describe('Return only the first N lines.'),
+        tail: z.number().int().positive().optional().describe('Return only the last N lines.'),
+        maxBytes: z.number().int().positive().optional().describe(`Cut the output at this many bytes, at a line boundary (default ${DEFAULT_MAX_READ_BYTES}). The result says where to continue.`),
     }),
     z.object({
         type: z.literal('listDirectory'),
@@ -99,6 +83,7 @@ const fsOpSchema = z.discriminatedUnion('type', [
 
 const inputSchema = z.object({
     ops: z.array(fsOpSchema).describe('Array of file/folder operations to perform.'),
+    dryRun: z.boolean().optional().describe('Report what each mutating operation would do, with diffs, without touching the disk.'),
 });
 
 // --- Helper functions ---
@@ -132,6 +117,14 @@ async function getDirectoryTree(dir: string, maxDepth = 10, depth = 0): Promise<
     }));
 }
 
+async function readExisting(path: string): Promise<Buffer | null> {
+    return fs.readFile(path).catch(() => null);
+}
+
+async function isDirectory(path: string): Promise<boolean> {
+    return fs.stat(path).then(s => s.isDirectory(), () => false);
+}
+
 async function getFileInfo(path: string) {
     const stats = await fs.stat(path);
     return {
@@ -145,18 +138,24 @@ async function getFileInfo(path: string) {
     };
 }
 
+// Operations that never change the filesystem; anything else locks the workspace
+const READ_OPS = new Set(['readFile', 'listDirectory', 'listDirectoryWithSizes', 'directoryTree', 'getFileInfo']);
+
 // --- Main Tool ---
 export const filesystem = {
     name: 'filesystem',
+    mutates: (args: any) => !args?.dryRun && (!Array.isArray(args?.ops) || args.ops.some((op: any) => !READ_OPS.has(op?.type))),
     description: `Secure, LLM-friendly multi-file/folder CRUD and query tool for the filesystem.\n
-**Features:**\n- Batch delete, create, move, copy, read, stat, search, and directory tree operations.\n- All paths are validated against allowed directories and checked for symlink attacks.\n- File creation uses atomic write (temp file + rename) for safety.\n- Pattern/glob support for batch operations (delete, search).\n- Forgives common LLM misspellings (e.g., str_read → readFile, include → readFile).\n- Returns a detailed result for each operation.\n- Schema is self-describing and exported as JSON schema.\n\n**listDirectory**: Lists both files and directories in the specified path, each entry prefixed with [FILE] or [DIR].\n\n**Examples:**\n\nDelete all .log files in logs:\n{\n  "ops": [ { "type": "delete", "path": "logs/*.log" } ]\n}\n\nRead a file:\n{\n  "ops": [ { "type": "readFile", "path": "README.md" } ]\n}\n\nMove a file:\n{\n  "ops": [ { "type": "move", "source": "foo.txt", "destination": "bar.txt" } ]\n}\n\nList directory with sizes:\n{\n  "ops": [ { "type": "listDirectoryWithSizes", "path": "." } ]\n}\n\nGet directory tree:\n{\n  "ops": [ { "type": "directoryTree", "path": ".", "maxDepth": 2 } ]\n}\n`,
+**Features:**\n- Batch delete, create, move, copy, read, stat, search, and directory tree operations.\n- readFile takes line ranges (startLine/endLine), head or tail, and a maxBytes guard (default 256 KB); truncated reads report the line to continue from, and binary files are summarized instead of dumped.\n- All paths are validated against allowed directories and checked for symlink attacks; read-only roots reject mutating operations.\n- File creation uses atomic write (temp file + rename) for safety.\n- Pattern/glob support for batch operations (delete, search).\n- Forgives common LLM misspellings (e.g., str_read → readFile, include → readFile).\n- Returns a detailed result for each operation.\n- dryRun: true previews every delete, create, move, and copy (diffs and size changes) without touching the disk.\n- Schema is self-describing and exported as JSON schema.\n\n**listDirectory**: Lists both files and directories in the specified path, each entry prefixed with [FILE] or [DIR].\n\n**Examples:**\n\nDelete all .log files in logs:\n{\n  "ops": [ { "type": "delete", "path": "logs/*.log" } ]\n}\n\nRead a file:\n{\n  "ops": [ { "type": "readFile", "path": "README.md" } ]\n}\n\nRead lines 100-150 of a large file:\n{\n  "ops": [ { "type": "readFile", "path": "src/big.go", "startLine": 100, "endLine": 150 } ]\n}\n\nMove a file:\n{\n  "ops": [ { "type": "move", "source": "foo.txt", "destination": "bar.txt" } ]\n}\n\nList directory with sizes:\n{\n  "ops": [ { "type": "listDirectoryWithSizes", "path": "." } ]\n}\n\nGet directory tree:\n{\n  "ops": [ { "type": "directoryTree", "path": ".", "maxDepth": 2 } ]\n}\n`,
     inputSchema: zodToJsonSchema(inputSchema),
     async run(args: any) {
-        const { ops } = args;
+        const { ops, dryRun } = args;
         const results: any[] = [];
+        const previews: ChangePreview[] = [];
         for (const opRaw of ops) {
             const op = { ...opRaw, type: normalizeOpType(opRaw.type) };
             const result: any = { type: op.type, input: opRaw, success: false };
+            const opPreviews: ChangePreview[] = [];
             try {
                 if (op.type === 'delete') {
                     // Support glob delete
@@ -165,47 +164,91 @@ export const filesystem = {
                     let deleted = 0;
                     for await (const file of walk(base)) {
                         if (minimatch(file, pattern, { matchBase: true })) {
-                            const path = await validatePath(file);
-                            await fs.rm(path, { recursive: true, force: true });
+                            const path = await validatePath(file, 'write');
+                            if (dryRun) {
+                                opPreviews.push(await isDirectory(path)
+                                    ? previewDirectory(path, 'delete', 'directory would be deleted with its contents')
+                                    : previewChange(path, await readExisting(path), null));
+                            } else {
+                                await captureBeforeChange(path);
+                                await fs.rm(path, { recursive: true, force: true });
+                                await recordFileChange(path, 'delete');
+                            }
                             deleted++;
                         }
                     }
                     result.success = true;
                     result.message = `Deleted ${deleted} files/folders matching ${pattern}`;
                 } else if (op.type === 'createFile') {
-                    const path = await validatePath(op.path);
-                    await fs.mkdir(dirname(path), { recursive: true });
-                    const tempPath = `${path}.${randomBytes(8).toString('hex')}.tmp`;
-                    await fs.writeFile(tempPath, op.content || '', 'utf-8');
-                    await fs.rename(tempPath, path);
+                    const path = await validatePath(op.path, 'write');
+                    const secrets = await checkContentForSecrets(path, op.content || '');
+                    if (secrets.blocked) {
+                        throw new Error(`Write blocked: content looks like it contains secrets\n${secrets.warnings.join('\n')}`);
+                    }
+                    if (dryRun) {
+                        opPreviews.push(previewChange(path, await readExisting(path), op.content || ''));
+                    } else {
+                        await captureBeforeChange(path);
+                        await fs.mkdir(dirname(path), { recursive: true });
+                        const tempPath = `${path}.${randomBytes(8).toString('hex')}.tmp`;
+                        await fs.writeFile(tempPath, op.content || '', 'utf-8');
+                        await fs.rename(tempPath, path);
+                        await recordFileChange(path, 'write', op.content || '');
+                    }
                     result.success = true;
                     result.message = `Created file ${path}`;
+                    if (secrets.warnings.length > 0) result.warnings = secrets.warnings;
                 } else if (op.type === 'createDirectory') {
-                    const path = await validatePath(op.path);
-                    await fs.mkdir(path, { recursive: true });
+                    const path = await validatePath(op.path, 'write');
+                    if (dryRun) {
+                        opPreviews.push(previewDirectory(path, 'mkdir', await isDirectory(path) ? 'directory already exists' : 'directory would be created'));
+                    } else {
+                        await fs.mkdir(path, { recursive: true });
+                    }
                     result.success = true;
                     result.message = `Created directory ${path}`;
                 } else if (op.type === 'move') {
-                    const src = await validatePath(op.source);
-                    const dst = await validatePath(op.destination);
-                    await fs.mkdir(dirname(dst), { recursive: true });
-                    await fs.rename(src, dst);
+                    const src = await validatePath(op.source, 'write');
+                    const dst = await validatePath(op.destination, 'write');
+                    if (dryRun) {
+                        opPreviews.push(await isDirectory(src)
+                            ? previewDirectory(dst, 'move', `directory would be moved from ${src}`, src)
+                            : previewChange(dst, await readExisting(dst), await fs.readFile(src), { action: 'move', from: src }));
+                    } else {
+                        await captureBeforeChange(src);
+                        await captureBeforeChange(dst);
+                        await fs.mkdir(dirname(dst), { recursive: true });
+                        await fs.rename(src, dst);
+                        await recordFileChange(dst, 'move', undefined, src);
+                    }
                     result.success = true;
                     result.message = `Moved ${src} to ${dst}`;
                 } else if (op.type === 'copy') {
                     const src = await validatePath(op.source);
-                    const dst = await validatePath(op.destination);
-                    await fs.mkdir(dirname(dst), { recursive: true });
-                    await fs.copyFile(src, dst);
+                    const dst = await validatePath(op.destination, 'write');
+                    if (dryRun) {
+                        opPreviews.push(previewChange(dst, await readExisting(dst), await fs.readFile(src), { action: 'copy', from: src }));
+                    } else {
+                        await captureBeforeChange(dst);
+                        await fs.mkdir(dirname(dst), { recursive: true });
+                        await fs.copyFile(src, dst);
+                        await recordFileChange(dst, 'copy', undefined, src);
+                    }
                     result.success = true;
                     result.message = `Copied ${src} to ${dst}`;
                 } else if (op.type === 'readFile') {
                     const path = await validatePath(op.path);
-                    const encoding = op.encoding || 'utf-8';
-                    const content = await fs.readFile(path, encoding);
+                    const encoding = (op.encoding || 'utf-8').toLowerCase();
+                    const { startLine, endLine, head, tail, maxBytes } = op;
+                    const window = { ...(startLine ? { startLine } : {}), ...(endLine ? { endLine } : {}), ...(head ? { head } : {}), ...(tail ? { tail } : {}), ...(maxBytes ? { maxBytes } : {}) };
+                    const read = encoding === 'utf-8' || encoding === 'utf8'
+                        ? await readFileWindow(path, window)
+                        : await readFileBytes(path, encoding as BufferEncoding, maxBytes);
+                    const { content, ...file } = read;
                     result.success = true;
-                    result.message = `Read file ${path}`;
+                    result.message = describeRead(path, read);
                     result.output = content;
+                    result.file = file;
                 } else if (op.type === 'listDirectory') {
                     const path = await validatePath(op.path);
                     const entries = await fs.readdir(path, { withFileTypes: true });
@@ -240,6 +283,11 @@ export const filesystem = {
                 } else {
                     throw new Error(`Unknown operation type: ${op.type}`);
                 }
+                if (dryRun && !READ_OPS.has(op.type)) {
+                    result.message = opPreviews.map(p => `${p.path}: ${p.summary}`).join('\n') || `Nothing matches ${op.path}`;
+                    result.previews = opPreviews;
+                    previews.push(...opPreviews);
+                }
             } catch (e: any) {
                 result.success = false;
                 result.error = e.message || String(e);
@@ -249,7 +297,8 @@ export const filesystem = {
         return {
             success: results.every(r => r.success),
             results,
-            output: results.map(r => r.message || r.error).join('\n'),
+            output: dryRun ? `${formatPreviews(previews)}${results.filter(r => !r.success).map(r => `\n${r.error}`).join('')}` : results.map(r => r.message || r.error).join('\n'),
+            ...(dryRun ? { dryRun: true } : {}),
         };
     },
 }; 
\ No newline at end of file
@@ -0,0 +1,183 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, join } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { type TestCaseResult, parseGoTestJson, goTestJsonText } from '../diagnostics/index.js';
+import { pythonTestTool } from './python.js';
+import { nodeTestTool } from './npm.js';
+
+export type FlakyVerdict = 'stable' | 'flaky' | 'failing' | 'skipped';
+
+export interface FlakyTestStats {
+    suite: string;
+    name: string;
+    runs: number;
+    passed: number;
+    failed: number;
+    skipped: number;
+    passRate: number;
+    verdict: FlakyVerdict;
+    // Distinct failure messages seen across runs
+    messages: string[];
+    file?: string;
+    line?: number;
+}
+
+const inputSchema = z.object({
+    path: z.string().describe('Project or package directory (or a test file for pytest)'),
+    test: z.string().optional().describe('Test to repeat: a go -run regex, pytest -k expression, or jest/vitest -t pattern. Defaults to every test.'),
+    language: z.enum(['auto', 'go', 'python', 'node']).default('auto'),
+    runs: z.number().int().min(2).max(100).default(10),
+    race: z.boolean().default(false).describe('Go: run with the race detector'),
+    shuffle: z.boolean().default(false).describe('Go: shuffle test order on every run'),
+    stopOnFailure: z.boolean().default(false).describe('Stop as soon as a run fails'),
+    timeout: z.number().default(300000).describe('Timeout for each run'),
+});
+
+async function exists(path: string): Promise<boolean> {
+    return fs.access(path).then(() => true, () => false);
+}
+
+async function detectLanguage(dir: string): Promise<'go' | 'python' | 'node' | null> {
+    if (await exists(join(dir, 'go.mod')) || (await fs.readdir(dir)).some(f => f.endsWith('_test.go'))) return 'go';
+    if (await exists(join(dir, 'package.json'))) return 'node';
+    for (const marker of ['pyproject.toml', 'setup.py', 'setup.cfg', 'pytest.ini', 'tox.ini']) {
+        if (await exists(join(dir, marker))) return 'python';
+    }
+    return null;
+}
+
+/**
+ * Tally per-test outcomes over repeated runs. A test that both passed and
+ * failed is flaky; one that failed every time it ran is failing. Errors
+ * count as failures.
+ */
+export function aggregateRuns(runs: TestCaseResult[][]): FlakyTestStats[] {
+    const stats = new Map<string, FlakyTestStats>();
+    for (const run of runs) {
+        for (const test of run) {
+            const key = `${test.suite}\u0000${test.name}`;
+            let entry = stats.get(key);
+            if (!entry) {
+                entry = { suite: test.suite, name: test.name, runs: 0, passed: 0, failed: 0, skipped: 0, passRate: 0, verdict: 'stable', messages: [] };
+                stats.set(key, entry);
+            }
+            entry.runs++;
+            if (test.status === 'passed') entry.passed++;
+            else if (test.status === 'skipped') entry.skipped++;
+            else {
+                entry.failed++;
+                if (test.message && !entry.messages.includes(test.message) && entry.messages.length < 5) entry.messages.push(test.message);
+                if (test.file && !entry.file) {
+                    entry.file = test.file;
+                    if (test.line) entry.line = test.line;
+                }
+            }
+        }
+    }
+    return [...stats.values()].map(entry => {
+        const executed = entry.passed + entry.failed;
+        const verdict: FlakyVerdict = executed === 0 ? 'skipped' : entry.failed === 0 ? 'stable' : entry.passed === 0 ? 'failing' : 'flaky';
+        return { ...entry, passRate: executed === 0 ? 0 : Math.round((entry.passed / executed) * 10000) / 100, verdict };
+    });
+}
+
+export const detectFlakyTool = {
+    name: 'detect_flaky',
+    description: 'Re-run a test (or a whole Go package, pytest target, or jest/vitest project) N times and report the pass/fail distribution per test. Tests that both pass and fail are flaky; tests that fail every run are broken. Go runs can add -race and a shuffled order.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, test, runs, race, shuffle, stopOnFailure, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const stats = await fs.stat(path);
+            const dir = stats.isDirectory() ? path : dirname(path);
+            const language = parseResult.data.language === 'auto' ? await detectLanguage(dir) : parseResult.data.language;
+            if (!language) {
+                return { success: false, errors: ['Could not detect the project language; pass language explicitly'], warnings: [], output: '' };
+            }
+
+            const runOnce = async (): Promise<{ tests: TestCaseResult[]; passed: boolean; output: string; error?: string }> => {
+                if (language === 'go') {
+                    let command = 'go test -json -count=1';
+                    if (test) command += ` -run ${shellQuote(test)}`;
+                    if (race) command += ' -race';
+                    if (shuffle) command += ' -shuffle=on';
+                    const result = await runCommand(`${command} .`, { cwd: dir, timeout, maxBuffer: 32 * 1024 * 1024 });
+                    const tests = parseGoTestJson(result.stdout, dir);
+                    return {
+                        tests,
+                        passed: result.exitCode === 0,
+                        output: goTestJsonText(result.stdout),
+                        ...(tests.length === 0 && result.exitCode !== 0 ? { error: result.stderr || goTestJsonText(result.stdout) } : {}),
+                    };
+                }
+                const result = language === 'python'
+                    ? await pythonTestTool.run({ path, ...(test ? { keyword: test } : {}), timeout })
+                    : await nodeTestTool.run({ projectPath: dir, ...(test ? { testNamePattern: test } : {}), timeout });
+                const tests: TestCaseResult[] = result.tests?.results ?? [];
+                return {
+                    tests,
+                    passed: result.success,
+                    output: result.output,
+                    ...(!result.tests ? { error: result.errors.join('\n') } : {}),
+                };
+            };
+
+            const results: TestCaseResult[][] = [];
+            const failedRuns: number[] = [];
+            let lastOutput = '';
+            for (let i = 0; i < runs; i++) {
+                const run = await runOnce();
+                if (run.error !== undefined) {
+                    // Nothing ran (build failure, missing runner): repeating will not tell us more
+                    return { success: false, errors: [`Run ${i + 1} produced no test results: ${run.error}`], warnings: [], output: run.output, language };
+                }
+                results.push(run.tests);
+                lastOutput = run.output;
+                if (!run.passed) {
+                    failedRuns.push(i + 1);
+                    if (stopOnFailure) break;
+                }
+            }
+
+            const tests = aggregateRuns(results);
+            const flaky = tests.filter(t => t.verdict === 'flaky');
+            const failing = tests.filter(t => t.verdict === 'failing');
+            const describe = (t: FlakyTestStats) => `${t.suite} ${t.name}: failed ${t.failed}/${t.passed + t.failed} runs${t.messages[0] ? ` (${t.messages[0]})` : ''}`;
+            return {
+                success: flaky.length === 0 && failing.length === 0,
+                errors: failing.map(t => `${describe(t)}, fails consistently`),
+                warnings: flaky.map(t => `${describe(t)}, flaky`),
+                output: `${language}: ${results.length} run(s), ${failedRuns.length} failed; ${flaky.length} flaky and ${failing.length} failing test(s) out of ${tests.length}\n\n${lastOutput}`,
+                language,
+                runs: results.length,
+                failedRuns,
+                summary: {
+                    total: tests.length,
+                    stable: tests.filter(t => t.verdict === 'stable').length,
+                    flaky: flaky.length,
+                    failing: failing.length,
+                    skipped: tests.filter(t => t.verdict === 'skipped').length,
+                },
+                tests: [...flaky, ...failing, ...tests.filter(t => t.verdict === 'stable' || t.verdict === 'skipped')],
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
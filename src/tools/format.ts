@@ -0,0 +1,200 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, extname, isAbsolute, join, resolve } from 'path';
+import { randomBytes } from 'crypto';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+import { validatePath } from '../utils/sandbox.js';
+import { previewChange, type ChangePreview } from '../utils/preview.js';
+import { recordFileChange } from '../audit/index.js';
+import { captureBeforeChange } from '../snapshots/index.js';
+import { findVenvPython } from './python.js';
+
+export type Formatter = 'gofmt' | 'goimports' | 'black' | 'ruff' | 'prettier';
+
+export interface FormatChange extends ChangePreview {
+    formatter: Formatter;
+}
+
+const inputSchema = z.object({
+    path: z.string().describe('File or directory to format'),
+    formatter: z.enum(['auto', 'gofmt', 'goimports', 'black', 'ruff', 'prettier']).default('auto')
+        .describe('auto picks by file extension, or for a directory every formatter its project markers call for (go.mod, pyproject.toml, package.json)'),
+    fix: z.boolean().default(false).describe('Write the formatted files; otherwise only report the diff of needed changes'),
+    timeout: z.number().default(120000),
+});
+
+const PRETTIER_EXTENSIONS = new Set(['.js', '.jsx', '.mjs', '.cjs', '.ts', '.tsx', '.mts', '.cts', '.json', '.css', '.scss', '.less', '.html', '.vue', '.md', '.yaml', '.yml', '.graphql']);
+const PYTHON_MARKERS = ['pyproject.toml', 'setup.py', 'setup.cfg', 'requirements.txt'];
+// Diffs beyond this many files are left out of the result; the files are still listed
+const MAX_DIFF_FILES = 100;
+
+// ruff when the project configures it, black otherwise
+async function pythonFormatter(dir: string): Promise<Formatter> {
+    if (await findUp(dir, 'ruff.toml') || await findUp(dir, '.ruff.toml')) return 'ruff';
+    const pyproject = await findUp(dir, 'pyproject.toml');
+    if (pyproject && /^\[tool\.ruff\b/m.test(await fs.readFile(pyproject, 'utf-8'))) return 'ruff';
+    return 'black';
+}
+
+export async function detectFormatters(path: string, isDirectory: boolean): Promise<Formatter[]> {
+    if (!isDirectory) {
+        const ext = extname(path);
+        if (ext === '.go') return ['gofmt'];
+        if (ext === '.py' || ext === '.pyi') return [await pythonFormatter(dirname(path))];
+        return PRETTIER_EXTENSIONS.has(ext) ? ['prettier'] : [];
+    }
+    const entries = await fs.readdir(path);
+    const formatters: Formatter[] = [];
+    if (entries.includes('go.mod') || entries.some(e => e.endsWith('.go'))) formatters.push('gofmt');
+    if (PYTHON_MARKERS.some(m => entries.includes(m)) || entries.some(e => e.endsWith('.py'))) formatters.push(await pythonFormatter(path));
+    if (entries.includes('package.json')) formatters.push('prettier');
+    return formatters;
+}
+
+interface FormatterCommands {
+    // Lists the files under target that are not formatted
+    list: string;
+    // Prints one file, formatted, to stdout
+    format: (file: string) => string;
+    parseList: (stdout: string, stderr: string, cwd: string) => string[];
+    // Exit codes of the list command that mean it ran over every file
+    okExitCodes: number[];
+}
+
+function absoluteLines(text: string, cwd: string, pattern?: RegExp): string[] {
+    const files: string[] = [];
+    for (const line of text.split('\n')) {
+        const file = pattern ? pattern.exec(line.trim())?.[1] : line.trim();
+        if (file) files.push(isAbsolute(file) ? file : resolve(cwd, file));
+    }
+    return files;
+}
+
+async function formatterCommands(formatter: Formatter, target: string, cwd: string): Promise<FormatterCommands> {
+    const quoted = shellQuote(target);
+    if (formatter === 'gofmt' || formatter === 'goimports') {
+        return {
+            list: `${formatter} -l ${quoted}`,
+            format: file => `${formatter} ${shellQuote(file)}`,
+            // gofmt walks vendor and testdata too; those are not the project's to format
+            parseList: stdout => absoluteLines(stdout, cwd).filter(f => !/[/\\](vendor|testdata)[/\\]/.test(f)),
+            okExitCodes: [0],
+        };
+    }
+    if (formatter === 'black' || formatter === 'ruff') {
+        const python = shellQuote((await findVenvPython(cwd)) || 'python');
+        const tool = formatter === 'black' ? `${python} -m black` : `${python} -m ruff format`;
+        return formatter === 'black'
+            ? {
+                list: `${tool} --check ${quoted}`,
+                format: file => `${tool} -q --stdin-filename ${shellQuote(file)} - < ${shellQuote(file)}`,
+                parseList: (_stdout, stderr) => absoluteLines(stderr, cwd, /^would reformat (.+)$/),
+                okExitCodes: [0, 1],
+            }
+            : {
+                list: `${tool} --check ${quoted}`,
+                format: file => `${tool} --stdin-filename ${shellQuote(file)} - < ${shellQuote(file)}`,
+                parseList: stdout => absoluteLines(stdout, cwd, /^Would reformat: (.+)$/),
+                okExitCodes: [0, 1],
+            };
+    }
+    // Prefer the project's own prettier version
+    const local = await findUp(cwd, join('node_modules', '.bin', 'prettier'));
+    const prettier = local ? shellQuote(local) : 'npx --no-install prettier';
+    return {
+        list: `${prettier} --list-different ${quoted}`,
+        format: file => `${prettier} ${shellQuote(file)}`,
+        parseList: stdout => absoluteLines(stdout, cwd),
+        okExitCodes: [0, 1],
+    };
+}
+
+async function writeFormatted(path: string, content: string): Promise<void> {
+    await captureBeforeChange(path);
+    const tempPath = `${path}.${randomBytes(8).toString('hex')}.tmp`;
+    await fs.writeFile(tempPath, content, 'utf-8');
+    await fs.rename(tempPath, path);
+    await recordFileChange(path, 'write', content);
+}
+
+export const formatCodeTool = {
+    name: 'format_code',
+    mutates: (args: any) => Boolean(args?.fix),
+    cacheable: true,
+    description: 'Check or fix formatting with gofmt/goimports (Go), black or ruff format (Python), and prettier (JavaScript, TypeScript, JSON, CSS, Markdown, YAML). Check mode returns the diff each unformatted file needs; fix mode writes the formatted files. Uses the project\'s virtualenv and node_modules versions when present.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, fix, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const target = resolve(path);
+            const isDirectory = (await fs.stat(target)).isDirectory();
+            const cwd = isDirectory ? target : dirname(target);
+            const formatters = parseResult.data.formatter === 'auto' ? await detectFormatters(target, isDirectory) : [parseResult.data.formatter];
+            if (formatters.length === 0) {
+                return { success: false, errors: ['No formatter applies to this path; pass formatter explicitly'], warnings: [], output: '' };
+            }
+
+            const errors: string[] = [];
+            const warnings: string[] = [];
+            const changes: FormatChange[] = [];
+            for (const formatter of formatters) {
+                const commands = await formatterCommands(formatter, target, cwd);
+                const listed = await runCommand(commands.list, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                const files = [...new Set(commands.parseList(listed.stdout, listed.stderr, cwd))];
+                // Unparsable files fail the listing but the rest are still reported; a "needs
+                // formatting" exit that names no file means the formatter did not run at all
+                if (!commands.okExitCodes.includes(listed.exitCode) || (listed.exitCode !== 0 && files.length === 0)) {
+                    errors.push(`${formatter} failed: ${(listed.stderr || listed.stdout).trim()}`);
+                }
+                for (const file of files) {
+                    const original = await fs.readFile(file, 'utf-8');
+                    const formatted = await runCommand(commands.format(file), { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                    if (formatted.exitCode !== 0) {
+                        errors.push(`${formatter} could not format ${file}: ${(formatted.stderr || formatted.stdout).trim()}`);
+                        continue;
+                    }
+                    const preview = previewChange(file, original, formatted.stdout);
+                    if (preview.summary === 'would be unchanged') continue;
+                    if (changes.length >= MAX_DIFF_FILES) delete preview.diff;
+                    if (fix) await writeFormatted(await validatePath(file, 'write'), formatted.stdout);
+                    changes.push({ ...preview, formatter });
+                }
+            }
+            if (changes.length > MAX_DIFF_FILES) warnings.push(`Diffs are shown for the first ${MAX_DIFF_FILES} of ${changes.length} files`);
+
+            const diffs = changes.flatMap(c => c.diff ? [c.diff] : []);
+            const listing = changes.map(c => `${c.path} (${c.formatter})`).join('\n');
+            const output = changes.length === 0
+                ? `All files formatted (${formatters.join(', ')})`
+                : fix
+                    ? `Formatted ${changes.length} file(s):\n${listing}`
+                    : `${changes.length} file(s) need formatting:\n${listing}\n\n${'`'.repeat(3)}diff\n${diffs.join('')}${'`'.repeat(3)}\n`;
+            return {
+                success: errors.length === 0 && (fix || changes.length === 0),
+                errors: errors.length > 0 || fix || changes.length === 0 ? errors : [`${changes.length} file(s) need formatting; run with fix to apply`],
+                warnings,
+                output,
+                formatters,
+                files: changes,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,199 @@
+import { z } from 'zod';
+import { createHash } from 'crypto';
+import { promises as fs } from 'fs';
+import { join, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { recordFileChange } from '../audit/index.js';
+import { type Diagnostic, parseGoBuildOutput, parseGoFuzzFailure, resolveDiagnosticPath } from '../diagnostics/index.js';
+import { runCommand } from '../utils/command.js';
+import { cacheDirectory, findUp } from '../utils/paths.js';
+import { shellQuote } from '../utils/shell.js';
+
+export interface FuzzTarget {
+    package: string;
+    // Package directory
+    dir: string;
+    name: string;
+}
+
+export interface FuzzRun extends FuzzTarget {
+    status: 'passed' | 'crashed' | 'error';
+    durationMs: number;
+    // Entries in the kept corpus after the run, and how many this run found
+    corpus: number;
+    newCorpus: number;
+    crasher?: {
+        message: string;
+        file?: string;
+        line?: number;
+        // Crasher file in testdata/fuzz, which go test then runs as a regression case
+        inputFile?: string;
+        // The minimized input as go test records it: "go test fuzz v1" and one value per line
+        input?: string;
+        seed?: string;
+        reproduce: string;
+    };
+}
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Go module or package directory'),
+    packages: z.string().default('./...').describe('Package pattern to find fuzz targets in'),
+    target: z.string().optional().describe('Regex of fuzz target names to run, such as FuzzParse; default every FuzzXxx function found'),
+    fuzztime: z.string().default('30s').describe('-fuzztime for each target, such as 1m or 50000x'),
+    minimizeTime: z.string().optional().describe('-fuzzminimizetime, how long to spend minimizing a crasher (go default 60s)'),
+    parallel: z.number().int().min(1).optional().describe('Fuzzing workers per target (-parallel); default GOMAXPROCS'),
+    corpusDir: z.string().optional().describe('Directory to keep generated corpora in between runs; default a per-module directory under ~/.cache/code-feedback/fuzz'),
+    timeout: z.number().default(600000).describe('Timeout for each target, including its build'),
+});
+
+// "FuzzParse" lines, then "ok  	example.com/fz	0.002s" for their package
+export function parseFuzzList(output: string): Array<{ package: string; name: string }> {
+    const targets: Array<{ package: string; name: string }> = [];
+    let pending: string[] = [];
+    for (const line of output.split('\n')) {
+        const trimmed = line.trim();
+        if (/^Fuzz\w*$/.test(trimmed)) {
+            pending.push(trimmed);
+            continue;
+        }
+        const ok = /^ok\s+(\S+)/.exec(trimmed);
+        if (ok) {
+            targets.push(...pending.map(name => ({ package: ok[1]!, name })));
+            pending = [];
+        }
+    }
+    return targets;
+}
+
+async function listEntries(dir: string): Promise<string[]> {
+    return fs.readdir(dir).catch(() => []);
+}
+
+// Copies the entries of from that to does not have yet; returns how many
+async function mergeCorpus(from: string, to: string): Promise<number> {
+    const existing = new Set(await listEntries(to));
+    const entries = (await listEntries(from)).filter(name => !existing.has(name));
+    if (entries.length === 0) return 0;
+    await fs.mkdir(to, { recursive: true });
+    for (const name of entries) await fs.copyFile(join(from, name), join(to, name));
+    return entries.length;
+}
+
+export const goFuzzTool = {
+    name: 'go_fuzz',
+    binaries: ['go'],
+    // go test writes each crasher to testdata/fuzz in the package
+    mutates: true,
+    description: 'Run Go native fuzz targets (func FuzzXxx(f *testing.F)) found by go test -list, one at a time for fuzztime each. The corpus each target builds up is kept in a cache directory and restored on the next run, so fuzzing picks up where it stopped. Crashers are reported as error diagnostics at the failing check or the panicking line, with the minimized input go test wrote to testdata/fuzz (which go test then runs as a regression case) and the command that reproduces it.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, packages, target, fuzztime, minimizeTime, parallel, corpusDir, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(projectPath) || (corpusDir && !config.isPathAllowed(corpusDir))) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        let pattern: RegExp | undefined;
+        try {
+            pattern = target ? new RegExp(target) : undefined;
+        } catch (error: any) {
+            return { success: false, errors: [`Invalid target regex: ${error.message}`], warnings: [], output: '' };
+        }
+        try {
+            const cwd = resolve(projectPath);
+            const listed = await runCommand(`go test -list '^Fuzz' ${packages}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+            if (listed.exitCode !== 0) {
+                const diagnostics = parseGoBuildOutput(listed.stderr + listed.stdout, cwd);
+                return { success: false, errors: [`go test -list failed: ${(listed.stderr || listed.stdout).trim()}`], warnings: [], output: listed.stdout, diagnostics };
+            }
+            const found = parseFuzzList(listed.stdout).filter(t => !pattern || pattern.test(t.name));
+            if (found.length === 0) {
+                return { success: true, errors: [], warnings: [`No fuzz targets${target ? ` matching ${target}` : ''} in ${packages}`], output: '', runs: [], diagnostics: [] };
+            }
+            const dirs = await runCommand(`go list -f '{{.ImportPath}} {{.Dir}}' ${[...new Set(found.map(t => t.package))].map(shellQuote).join(' ')}`, { cwd, timeout });
+            const dirOf = new Map(dirs.stdout.split('\n').filter(Boolean).map(line => [line.slice(0, line.indexOf(' ')), line.slice(line.indexOf(' ') + 1)] as const));
+            const goCache = (await runCommand('go env GOCACHE', { cwd, timeout })).stdout.trim();
+            const moduleFile = await findUp(cwd, 'go.mod');
+            const keptRoot = corpusDir ? resolve(corpusDir) : cacheDirectory('fuzz', createHash('sha256').update(moduleFile ?? cwd).digest('hex').slice(0, 12));
+
+            const runs: FuzzRun[] = [];
+            const diagnostics: Diagnostic[] = [];
+            const warnings: string[] = [];
+            for (const t of found) {
+                const dir = dirOf.get(t.package) ?? cwd;
+                // go test keeps generated inputs in GOCACHE/fuzz, which go clean -fuzzcache empties
+                const goCorpus = join(goCache, 'fuzz', t.package, t.name);
+                const kept = join(keptRoot, t.package, t.name);
+                if (goCache) await mergeCorpus(kept, goCorpus);
+                const before = (await listEntries(goCorpus)).length;
+
+                let command = `go test -run '^$' -fuzz ${shellQuote(`^${t.name}$`)} -fuzztime ${shellQuote(fuzztime)}`;
+                if (minimizeTime) command += ` -fuzzminimizetime ${shellQuote(minimizeTime)}`;
+                if (parallel) command += ` -parallel ${parallel}`;
+                const result = await runCommand(`${command} ${shellQuote(t.package)}`, { cwd: dir, timeout, maxBuffer: 16 * 1024 * 1024 });
+                const output = result.stdout + result.stderr;
+
+                const newCorpus = Math.max((await listEntries(goCorpus)).length - before, 0);
+                if (goCache) await mergeCorpus(goCorpus, kept);
+                const run: FuzzRun = { ...t, dir, status: 'passed', durationMs: result.duration, corpus: (await listEntries(kept)).length, newCorpus };
+                runs.push(run);
+                if (result.exitCode === 0) continue;
+
+                const failure = parseGoFuzzFailure(output);
+                if (!failure) {
+                    run.status = 'error';
+                    diagnostics.push(...parseGoBuildOutput(output, dir));
+                    warnings.push(`${t.name} failed without a crasher: ${output.trim().split('\n').slice(-5).join('\n')}`);
+                    continue;
+                }
+                run.status = 'crashed';
+                const input = failure.inputFile ? await fs.readFile(join(dir, failure.inputFile), 'utf-8').catch(() => undefined) : undefined;
+                if (failure.inputFile && input !== undefined) await recordFileChange(join(dir, failure.inputFile), 'write', input);
+                const rerun = failure.inputFile ? `${t.name}/${failure.inputFile.split('/').pop()}` : failure.seed ?? t.name;
+                run.crasher = {
+                    message: failure.message,
+                    ...(failure.file ? { file: resolveDiagnosticPath(failure.file, dir), line: failure.line ?? 0 } : {}),
+                    ...(failure.inputFile ? { inputFile: join(dir, failure.inputFile) } : {}),
+                    ...(input !== undefined ? { input } : {}),
+                    ...(failure.seed ? { seed: failure.seed } : {}),
+                    reproduce: `go test -run=${shellQuote(rerun)} ${t.package}`,
+                };
+                const values = input?.split('\n').slice(1).filter(Boolean).join(', ');
+                diagnostics.push({
+                    file: run.crasher.file ?? dir,
+                    line: run.crasher.line ?? 0,
+                    column: 0,
+                    severity: 'error',
+                    message: `${t.name} ${failure.seed ? `failed on seed corpus entry ${failure.seed}` : 'found a crasher'}: ${failure.message}${values ? ` (input: ${values})` : ''}`,
+                    rule: 'fuzz-crash',
+                    source: 'go fuzz',
+                });
+            }
+
+            const crashed = runs.filter(r => r.status === 'crashed');
+            return {
+                success: runs.every(r => r.status === 'passed'),
+                errors: crashed.map(r => `${r.name} (${r.package}): ${r.crasher!.message}`),
+                warnings,
+                output: runs.map(r => {
+                    const head = `${r.name} (${r.package}): ${r.status} in ${(r.durationMs / 1000).toFixed(1)}s, corpus ${r.corpus} (+${r.newCorpus})`;
+                    return r.crasher ? `${head}\n  ${r.crasher.message}\n  reproduce: ${r.crasher.reproduce}` : head;
+                }).join('\n'),
+                runs,
+                diagnostics,
+                corpusDir: keptRoot,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
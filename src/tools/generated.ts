@@ -0,0 +1,135 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { join, relative, resolve } from 'path';
+import { tmpdir } from 'os';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig } from '../config/project.js';
+import { runCommand } from '../utils/command.js';
+import { sha256 } from '../audit/index.js';
+import { previewChange, type ChangePreview } from '../utils/preview.js';
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Project root; it is copied and the generators run in the copy'),
+    commands: z.array(z.string().min(1)).optional()
+        .describe('Generation commands, run in order; defaults to generate in .code-feedback.yaml, else `go generate ./...` for Go modules'),
+    timeout: z.number().default(600000).describe('Per-command timeout in ms'),
+});
+
+// Not copied: too large to copy per call, so dependency directories are linked in instead
+const LINKED_DIRS = new Set(['node_modules', '.venv', 'venv']);
+const SKIPPED_DIRS = new Set(['.git', '.hg', '.svn']);
+// Diffs beyond this many files are left out of the result; the files are still listed
+const MAX_DIFF_FILES = 100;
+
+// Relative path -> content hash of every regular file under root
+async function hashTree(root: string): Promise<Map<string, string>> {
+    const hashes = new Map<string, string>();
+    const pending = [root];
+    while (pending.length > 0) {
+        const dir = pending.pop()!;
+        for (const entry of await fs.readdir(dir, { withFileTypes: true })) {
+            const full = join(dir, entry.name);
+            if (entry.isDirectory()) {
+                if (!SKIPPED_DIRS.has(entry.name) && !LINKED_DIRS.has(entry.name)) pending.push(full);
+            } else if (entry.isFile()) {
+                hashes.set(relative(root, full), sha256(await fs.readFile(full)));
+            }
+        }
+    }
+    return hashes;
+}
+
+async function copyProject(source: string, destination: string): Promise<void> {
+    for (const entry of await fs.readdir(source, { withFileTypes: true })) {
+        const from = join(source, entry.name);
+        const to = join(destination, entry.name);
+        if (SKIPPED_DIRS.has(entry.name)) continue;
+        if (entry.isDirectory() && LINKED_DIRS.has(entry.name)) {
+            await fs.symlink(from, to, 'dir');
+        } else {
+            await fs.cp(from, to, { recursive: true, verbatimSymlinks: true, filter: path => !SKIPPED_DIRS.has(path.split(/[/\\]/).pop() ?? '') });
+        }
+    }
+}
+
+async function defaultCommands(projectPath: string): Promise<string[]> {
+    const isGoModule = await fs.access(join(projectPath, 'go.mod')).then(() => true, () => false);
+    return isGoModule ? ['go generate ./...'] : [];
+}
+
+export const checkGeneratedTool = {
+    name: 'check_generated',
+    cacheable: true,
+    description: 'Check that committed generated code is up to date: copies the project to a temporary directory, reruns the generators there (`go generate ./...`, or the protoc/buf/mockgen/... commands under `generate` in .code-feedback.yaml), and reports every file the generators changed, created or deleted, with diffs. The project itself is not modified.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(parseResult.data.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const projectPath = resolve(parseResult.data.projectPath);
+        let copy: string | null = null;
+        try {
+            if (!(await fs.stat(projectPath)).isDirectory()) {
+                return { success: false, errors: ['projectPath must be a directory'], warnings: [], output: '' };
+            }
+            const commands = parseResult.data.commands ?? (await getEffectiveConfig(projectPath)).config.generate ?? await defaultCommands(projectPath);
+            if (commands.length === 0) {
+                return { success: false, errors: ['No generation commands: pass commands or set generate in .code-feedback.yaml'], warnings: [], output: '' };
+            }
+
+            copy = await fs.mkdtemp(join(tmpdir(), 'cf-generated-'));
+            await copyProject(projectPath, copy);
+            const before = await hashTree(copy);
+            const errors: string[] = [];
+            const log: string[] = [];
+            for (const command of commands) {
+                const result = await runCommand(command, { cwd: copy, timeout, maxBuffer: 16 * 1024 * 1024 });
+                log.push(`$ ${command}\n${result.stdout}${result.stderr}`.trimEnd());
+                if (result.exitCode !== 0) {
+                    // A failed generator leaves the copy half-regenerated, so later commands and the diff mean little
+                    errors.push(`${command} exited with code ${result.exitCode}: ${(result.stderr || result.stdout).trim()}`);
+                    break;
+                }
+            }
+
+            const after = await hashTree(copy);
+            const stale: ChangePreview[] = [];
+            const paths = [...new Set([...before.keys(), ...after.keys()])].sort();
+            for (const rel of paths) {
+                if (before.get(rel) === after.get(rel)) continue;
+                const original = before.has(rel) ? await fs.readFile(join(projectPath, rel)) : null;
+                const regenerated = after.has(rel) ? await fs.readFile(join(copy, rel)) : null;
+                const preview = previewChange(join(projectPath, rel), original, regenerated);
+                if (stale.length >= MAX_DIFF_FILES) delete preview.diff;
+                stale.push(preview);
+            }
+            const warnings = stale.length > MAX_DIFF_FILES ? [`Diffs are shown for the first ${MAX_DIFF_FILES} of ${stale.length} files`] : [];
+
+            const listing = stale.map(p => `${p.path}: ${p.action === 'create' ? 'missing, the generators create it' : p.action === 'delete' ? 'no longer generated' : `stale (+${p.added ?? 0} -${p.removed ?? 0})`}`).join('\n');
+            if (stale.length > 0 && errors.length === 0) errors.push(`${stale.length} generated file(s) are out of date; rerun: ${commands.join(' && ')}`);
+            return {
+                success: errors.length === 0,
+                errors,
+                warnings,
+                output: stale.length > 0 ? `${listing}\n\n${log.join('\n')}` : `Generated code is up to date (${commands.join(', ')})\n${log.join('\n')}`.trimEnd(),
+                commands,
+                stale,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            if (copy) await fs.rm(copy, { recursive: true, force: true });
+        }
+    },
+};
@@ -4,6 +4,8 @@ import Config from '../config/index.js';
 import { promises as fs } from 'fs';
 import { join } from 'path';
 import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
+import { parseUnifiedDiff, parseGitStatus, parseGitBlame } from '../utils/git.js';
 
 const inputSchema = z.object({
     repoPath: z.string(),
@@ -28,6 +30,10 @@ const inputSchema = z.object({
 
 export const gitTool = {
     name: 'git',
+    binaries: ['git'],
+    // Can push, reset and run arbitrary git commands
+    dangerous: true,
+    mutates: true,
     description: 'Run git commands (status, add, commit, push, etc.) in a repository.',
     inputSchema: zodToJsonSchema(inputSchema),
     async run(args: any) {
@@ -89,4 +95,149 @@ export const gitTool = {
             return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
         }
     },
-}; 
\ No newline at end of file
+};
+
+// A ref starting with '-' would be parsed by git as an option (--output=..., --open-files-in-pager)
+const refSchema = z.string().refine(ref => !ref.startsWith('-'), 'Refs must not start with "-"');
+
+const gitDiffSchema = z.object({
+    repoPath: z.string(),
+    base: refSchema.optional().describe('Base ref; omit to diff the working tree against the index (or HEAD with staged)'),
+    head: refSchema.optional().describe('Head ref for a ref range (base..head)'),
+    staged: z.boolean().default(false).describe('Diff staged changes (git diff --cached)'),
+    paths: z.array(z.string()).default([]).describe('Limit the diff to these paths'),
+    contextLines: z.number().default(3),
+    timeout: z.number().default(60000),
+});
+
+const gitStatusSchema = z.object({
+    repoPath: z.string(),
+    timeout: z.number().default(30000),
+});
+
+const gitBlameSchema = z.object({
+    repoPath: z.string(),
+    file: z.string().describe('File path relative to the repository root'),
+    startLine: z.number().optional(),
+    endLine: z.number().optional(),
+    ref: refSchema.optional(),
+    timeout: z.number().default(60000),
+});
+
+export async function checkRepo(repoPath: string): Promise<string | null> {
+    if (!Config.getInstance().isPathAllowed(repoPath)) {
+        return 'Path not allowed';
+    }
+    try {
+        await fs.access(join(repoPath, '.git'));
+        return null;
+    } catch {
+        return `Not a git repository: ${repoPath}`;
+    }
+}
+
+export const gitDiffTool = {
+    name: 'git_diff',
+    binaries: ['git'],
+    description: 'Show changes in the working tree, the index (staged), or a ref range as structured per-file hunks with old/new line numbers.',
+    inputSchema: zodToJsonSchema(gitDiffSchema),
+    async run(args: any) {
+        const parseResult = gitDiffSchema.safeParse(args);
+        if (!parseResult.success) {
+            return { success: false, errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')}: ${e.message}`), warnings: [], output: '' };
+        }
+        const { repoPath, base, head, staged, paths, contextLines, timeout } = parseResult.data;
+        const repoError = await checkRepo(repoPath);
+        if (repoError) {
+            return { success: false, errors: [repoError], warnings: [], output: '' };
+        }
+        try {
+            let command = `git diff --no-color --no-ext-diff -U${contextLines}`;
+            if (staged) command += ' --cached';
+            if (base) command += ` ${shellQuote(head ? `${base}..${head}` : base)}`;
+            if (paths.length > 0) command += ` -- ${paths.map(shellQuote).join(' ')}`;
+            const result = await runCommand(command, { cwd: repoPath, timeout, maxBuffer: 16 * 1024 * 1024 });
+            if (result.exitCode !== 0) {
+                return { success: false, errors: [result.stderr], warnings: [], output: '' };
+            }
+            const files = parseUnifiedDiff(result.stdout);
+            const additions = files.reduce((sum, f) => sum + f.hunks.reduce((n, h) => n + h.lines.filter(l => l.type === 'add').length, 0), 0);
+            const deletions = files.reduce((sum, f) => sum + f.hunks.reduce((n, h) => n + h.lines.filter(l => l.type === 'del').length, 0), 0);
+            return {
+                success: true,
+                errors: [],
+                warnings: [],
+                output: `${files.length} file(s) changed, ${additions} insertion(s), ${deletions} deletion(s)`,
+                files,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const gitStatusTool = {
+    name: 'git_status',
+    binaries: ['git'],
+    description: 'Return the branch, upstream tracking (ahead/behind), and staged, unstaged, and untracked files as structured entries.',
+    inputSchema: zodToJsonSchema(gitStatusSchema),
+    async run(args: any) {
+        const parseResult = gitStatusSchema.safeParse(args);
+        if (!parseResult.success) {
+            return { success: false, errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')}: ${e.message}`), warnings: [], output: '' };
+        }
+        const { repoPath, timeout } = parseResult.data;
+        const repoError = await checkRepo(repoPath);
+        if (repoError) {
+            return { success: false, errors: [repoError], warnings: [], output: '' };
+        }
+        try {
+            const result = await runCommand('git status --porcelain=v1 --branch --untracked-files=all', { cwd: repoPath, timeout });
+            if (result.exitCode !== 0) {
+                return { success: false, errors: [result.stderr], warnings: [], output: '' };
+            }
+            const status = parseGitStatus(result.stdout);
+            return {
+                success: true,
+                errors: [],
+                warnings: [],
+                output: status.clean ? 'Working tree clean' : `${status.entries.length} changed file(s)`,
+                status,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const gitBlameTool = {
+    name: 'git_blame',
+    binaries: ['git'],
+    description: 'Show which commit and author last changed each line of a file (optionally a line range).',
+    inputSchema: zodToJsonSchema(gitBlameSchema),
+    async run(args: any) {
+        const parseResult = gitBlameSchema.safeParse(args);
+        if (!parseResult.success) {
+            return { success: false, errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')}: ${e.message}`), warnings: [], output: '' };
+        }
+        const { repoPath, file, startLine, endLine, ref, timeout } = parseResult.data;
+        const repoError = await checkRepo(repoPath);
+        if (repoError) {
+            return { success: false, errors: [repoError], warnings: [], output: '' };
+        }
+        try {
+            let command = 'git blame --porcelain';
+            if (startLine !== undefined) command += ` -L ${startLine},${endLine ?? ''}`;
+            if (ref) command += ` ${shellQuote(ref)}`;
+            command += ` -- ${shellQuote(file)}`;
+            const result = await runCommand(command, { cwd: repoPath, timeout, maxBuffer: 16 * 1024 * 1024 });
+            if (result.exitCode !== 0) {
+                return { success: false, errors: [result.stderr], warnings: [], output: '' };
+            }
+            const lines = parseGitBlame(result.stdout);
+            return { success: true, errors: [], warnings: [], output: `${lines.length} line(s) blamed`, lines };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
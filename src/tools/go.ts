@@ -4,16 +4,65 @@ import Config from '../config/index.js';
 import { dirname } from 'path';
 import { promises as fs } from 'fs';
 import { zodToJsonSchema } from 'zod-to-json-schema';
+import {
+    type Diagnostic,
+    type TestReport,
+    type TestCaseResult,
+    type GoRaceReport,
+    parseGoBuildOutput,
+    parseGoVetOutput,
+    parseGoplsCheckOutput,
+    parseGoTestJson,
+    goTestJsonText,
+    toTestReport,
+    describeTestFailure,
+    parseGoRaceReports,
+    raceReportDiagnostics,
+    parseSanitizerOutput,
+} from '../diagnostics/index.js';
+import { shellQuote } from '../utils/shell.js';
 
 const inputSchema = z.object({
     filePath: z.string(),
-    actions: z.array(z.enum(['build', 'fmt', 'mod', 'vet', 'test'])).optional(),
+    actions: z.array(z.enum(['build', 'fmt', 'mod', 'vet', 'test', 'gopls'])).optional(),
     command: z.string().optional(),
+    // Flags for the test action
+    run: z.string().optional().describe('Test action: only run tests matching this -run regex'),
+    race: z.boolean().default(false).describe('Test action: enable the race detector; races come back as structured `races`'),
+    msan: z.boolean().default(false).describe('Test action: build with MemorySanitizer (linux/amd64 with clang)'),
+    asan: z.boolean().default(false).describe('Test action: build with AddressSanitizer'),
+    count: z.number().int().min(1).optional().describe('Test action: run each test this many times (also disables the test cache)'),
+    shuffle: z.union([z.boolean(), z.number().int()]).optional().describe('Test action: randomize test order; a number replays that seed'),
 });
 
+/**
+ * Race reports, attributed to the test whose output they appeared in. Races
+ * reported outside any test (after the run) are parsed from the whole log.
+ */
+function findRaces(tests: TestCaseResult[], output: string): GoRaceReport[] {
+    const races: GoRaceReport[] = [];
+    for (const test of tests) {
+        for (const race of parseGoRaceReports(test.output ?? '')) {
+            races.push({ ...race, test: `${test.suite} ${test.name}` });
+            // Point the failed test at the race rather than at the testing framework
+            if (!test.file && race.file) {
+                test.file = race.file;
+                if (race.line) test.line = race.line;
+            }
+        }
+    }
+    return races.length > 0 ? races : parseGoRaceReports(output);
+}
+
 export const goTool = {
     name: 'go',
-    description: 'Run Go code and return the output, errors, and execution time.',
+    binaries: ['go'],
+    mutates: (args: any) => Array.isArray(args?.actions) && args.actions.includes('mod'),
+    // command is appended to `go` in a shell, so it can run anything
+    dangerous: (args: any) => typeof args?.command === 'string',
+    // Free-form commands can do anything (go generate, go get), so only the fixed actions are cached
+    cacheable: (args: any) => typeof args?.command !== 'string' && !(Array.isArray(args?.actions) && args.actions.includes('mod')),
+    description: 'Run Go code and return the output, errors, and execution time. Build, vet, and gopls findings are also returned as structured diagnostics (file, line, column, severity, message, rule), and the test action returns per-test results (status, duration, failure message and location, output). Tests can run with -race (data races parsed into structured reports with goroutine stacks), -msan or -asan, -count, and -shuffle.',
     inputSchema: zodToJsonSchema(inputSchema),
     async run(args: any) {
         // Validate input using Zod
@@ -27,18 +76,32 @@ export const goTool = {
             };
         }
 
-        const { filePath, command } = parseResult.data;
+        const { filePath, command, run, race, msan, asan, count, shuffle } = parseResult.data;
         let { actions } = parseResult.data;
         if (!actions || actions.length === 0) {
             actions = ['build', 'fmt'];
         }
 
+        if ([race, msan, asan].filter(Boolean).length > 1) {
+            return { success: false, errors: ['race, msan and asan cannot be combined'] as string[], warnings: [] as string[], output: '' };
+        }
         if (!Config.getInstance().isPathAllowed(filePath)) {
             return { success: false, errors: ['Path not allowed'] as string[], warnings: [] as string[], output: '' };
         }
         try {
             await fs.access(filePath);
-            const feedback = { success: true, errors: [] as string[], warnings: [] as string[], output: '' };
+            const feedback: {
+                success: boolean;
+                errors: string[];
+                warnings: string[];
+                output: string;
+                diagnostics: Diagnostic[];
+                tests?: TestReport;
+                races?: GoRaceReport[];
+                shuffleSeed?: string;
+            } = {
+                success: true, errors: [], warnings: [], output: '', diagnostics: [],
+            };
             const dir = dirname(filePath);
             if (command) {
                 const result = await runCommand(`go ${command}`, { cwd: dir });
@@ -56,6 +119,7 @@ export const goTool = {
                     if (buildResult.exitCode !== 0) {
                         feedback.success = false;
                         feedback.errors.push(`Build failed: ${buildResult.stderr}`);
+                        feedback.diagnostics.push(...parseGoBuildOutput(buildResult.stderr, dir));
                     }
                 } else if (action === 'fmt') {
                     const fmtResult = await runCommand(`gofmt -d "${filePath}"`, { cwd: dir });
@@ -72,28 +136,54 @@ export const goTool = {
                         feedback.errors.push(`go mod tidy failed: ${modResult.stderr}`);
                     }
                 } else if (action === 'vet') {
-                    const vetResult = await runCommand(`go vet "${filePath}"`, { cwd: dir });
+                    // -json exits 0 even when analyzers report findings
+                    const vetResult = await runCommand(`go vet -json "${filePath}"`, { cwd: dir });
                     feedback.output += `Vet: ${vetResult.stdout}\n`;
-                    if (vetResult.exitCode !== 0) {
+                    const vetDiagnostics = parseGoVetOutput(vetResult.stdout + vetResult.stderr, dir);
+                    feedback.diagnostics.push(...vetDiagnostics);
+                    if (vetResult.exitCode !== 0 || vetDiagnostics.length > 0) {
                         feedback.success = false;
-                        feedback.errors.push(`go vet failed: ${vetResult.stderr}`);
+                        feedback.errors.push(`go vet failed: ${vetResult.stderr || vetResult.stdout}`);
+                    }
+                } else if (action === 'gopls') {
+                    const goplsResult = await runCommand(`gopls check "${filePath}"`, { cwd: dir });
+                    feedback.output += `Gopls: ${goplsResult.stdout}\n`;
+                    const goplsDiagnostics = parseGoplsCheckOutput(goplsResult.stdout + goplsResult.stderr, dir);
+                    feedback.diagnostics.push(...goplsDiagnostics);
+                    if (goplsResult.exitCode !== 0) {
+                        feedback.success = false;
+                        feedback.errors.push(`gopls check failed: ${goplsResult.stderr}`);
+                    } else if (goplsDiagnostics.length > 0) {
+                        feedback.warnings.push(`gopls reported ${goplsDiagnostics.length} issue(s)`);
                     }
                 } else if (action === 'test') {
-                    if (filePath.includes('_test.go')) {
-                        const testResult = await runCommand(`go test -v "${filePath}"`, { cwd: dir });
-                        feedback.output += `Test: ${testResult.stdout}\n`;
-                        if (testResult.exitCode !== 0) {
-                            feedback.errors.push(`Tests failed: ${testResult.stderr}`);
-                            feedback.success = false;
-                        }
-                    } else {
-                        // If a directory is provided, run all tests in the package
-                        const testResult = await runCommand(`go test -v`, { cwd: dir });
-                        feedback.output += `Test: ${testResult.stdout}\n`;
-                        if (testResult.exitCode !== 0) {
-                            feedback.errors.push(`Tests failed: ${testResult.stderr}`);
-                            feedback.success = false;
-                        }
+                    let flags = '-json';
+                    if (run) flags += ` -run ${shellQuote(run)}`;
+                    if (race) flags += ' -race';
+                    if (msan) flags += ' -msan';
+                    if (asan) flags += ' -asan';
+                    if (count !== undefined) flags += ` -count=${count}`;
+                    if (shuffle !== undefined && shuffle !== false) flags += ` -shuffle=${shuffle === true ? 'on' : shuffle}`;
+                    // A _test.go file runs on its own; anything else runs the whole package
+                    const target = filePath.includes('_test.go') ? ` "${filePath}"` : '';
+                    const testResult = await runCommand(`go test ${flags}${target}`, { cwd: dir, maxBuffer: 32 * 1024 * 1024 });
+                    const testOutput = goTestJsonText(testResult.stdout);
+                    feedback.output += `Test: ${testOutput}\n`;
+                    feedback.tests = toTestReport(parseGoTestJson(testResult.stdout, dir));
+                    // "-test.shuffle 1697040000000000000" is printed so the order can be replayed
+                    const seed = /^-test\.shuffle (\d+)$/m.exec(testOutput)?.[1];
+                    if (seed) feedback.shuffleSeed = seed;
+                    if (race) {
+                        feedback.races = findRaces(feedback.tests.results, testOutput);
+                        feedback.diagnostics.push(...raceReportDiagnostics(feedback.races, dir));
+                    }
+                    if (msan || asan) {
+                        feedback.diagnostics.push(...parseSanitizerOutput(testOutput + testResult.stderr, dir));
+                    }
+                    if (testResult.exitCode !== 0) {
+                        feedback.success = false;
+                        const failures = feedback.tests.failures.map(describeTestFailure);
+                        feedback.errors.push(failures.length > 0 ? `Tests failed:\n${failures.join('\n')}` : `Tests failed: ${testResult.stderr}`);
                     }
                 }
             }
@@ -0,0 +1,844 @@
+import { z } from 'zod';
+import { createHash } from 'crypto';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { join, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { cacheDirectory } from '../utils/paths.js';
+import { shellQuote } from '../utils/shell.js';
+
+// Parses with go/ast and prints {results, files, parseErrors?} as JSON.
+// Built once per source version into the user cache directory.
+const HELPER_SOURCE = String.raw`// Structural queries over Go source with go/ast, printed as JSON
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type object = map[string]any
+
+var fset = token.NewFileSet()
+
+func position(pos token.Pos) (string, int, int) {
+	p := fset.Position(pos)
+	return p.Filename, p.Line, p.Column
+}
+
+func located(pos token.Pos, fields object) object {
+	file, line, column := position(pos)
+	fields["file"] = file
+	fields["line"] = line
+	fields["column"] = column
+	return fields
+}
+
+func exprString(expr ast.Node) string {
+	var buf bytes.Buffer
+	printer.Fprint(&buf, fset, expr)
+	return buf.String()
+}
+
+// Signature without parameter names, so implementations match declarations
+func signature(fn *ast.FuncType) string {
+	list := func(fields *ast.FieldList) []string {
+		var types []string
+		if fields == nil {
+			return types
+		}
+		for _, field := range fields.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				types = append(types, exprString(field.Type))
+			}
+		}
+		return types
+	}
+	sig := "(" + strings.Join(list(fn.Params), ", ") + ")"
+	results := list(fn.Results)
+	if len(results) == 1 {
+		sig += " " + results[0]
+	} else if len(results) > 1 {
+		sig += " (" + strings.Join(results, ", ") + ")"
+	}
+	return sig
+}
+
+// Type parameters with their constraints: [K comparable, V any]
+func typeParams(fields *ast.FieldList) string {
+	if fields == nil || len(fields.List) == 0 {
+		return ""
+	}
+	var params []string
+	for _, field := range fields.List {
+		for _, n := range field.Names {
+			params = append(params, n.Name+" "+exprString(field.Type))
+		}
+	}
+	return "[" + strings.Join(params, ", ") + "]"
+}
+
+// Whether a package directory (relative, with slashes) is internal to its module
+func internalDir(dir string) bool {
+	return dir == "internal" || strings.HasPrefix(dir, "internal/") || strings.HasSuffix(dir, "/internal") || strings.Contains(dir, "/internal/")
+}
+
+func receiverType(recv *ast.FieldList) (string, bool) {
+	if recv == nil || len(recv.List) == 0 {
+		return "", false
+	}
+	expr := recv.List[0].Type
+	pointer := false
+	if star, ok := expr.(*ast.StarExpr); ok {
+		pointer = true
+		expr = star.X
+	}
+	switch t := expr.(type) {
+	case *ast.IndexExpr:
+		expr = t.X
+	case *ast.IndexListExpr:
+		expr = t.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name, pointer
+	}
+	return exprString(expr), pointer
+}
+
+func docText(group *ast.CommentGroup) string {
+	if group == nil {
+		return ""
+	}
+	return strings.TrimSpace(group.Text())
+}
+
+type method struct {
+	signature string
+	pointer   bool
+}
+
+// Struct tag keys and values: json:"id,omitempty" db:"id" -> {json: "id,omitempty", db: "id"}
+func parseTag(tag string) map[string]string {
+	values := map[string]string{}
+	for {
+		tag = strings.TrimLeft(tag, " ")
+		i := strings.Index(tag, ":")
+		if i <= 0 {
+			return values
+		}
+		key, rest := tag[:i], tag[i+1:]
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return values
+		}
+		values[key], _ = strconv.Unquote(quoted)
+		tag = rest[len(quoted):]
+	}
+}
+
+// The name a package is imported under by default, from its path:
+// "gopkg.in/yaml.v3" -> yaml, "github.com/google/go-cmp/cmp" -> cmp, "example.com/api/v2" -> api
+func importName(path string) string {
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && regexp.MustCompile("^v[0-9]+$").MatchString(name) {
+		name = parts[len(parts)-2]
+	}
+	name = regexp.MustCompile("\\.v[0-9]+$").ReplaceAllString(name, "")
+	return strings.ReplaceAll(strings.TrimPrefix(name, "go-"), "-", "_")
+}
+
+// Cyclomatic complexity as gocyclo counts it: 1, plus one per if, for,
+// range, non-default case and select case, && and ||
+func complexity(body ast.Node) int {
+	n := 1
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch x := node.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			n++
+		case *ast.CaseClause:
+			if x.List != nil {
+				n++
+			}
+		case *ast.CommClause:
+			if x.Comm != nil {
+				n++
+			}
+		case *ast.BinaryExpr:
+			if x.Op == token.LAND || x.Op == token.LOR {
+				n++
+			}
+		}
+		return true
+	})
+	return n
+}
+
+type parsedFile struct {
+	file *ast.File
+	pkg  string
+}
+
+func collectFiles(paths []string, recursive bool, tests bool) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if path != root && (!recursive || name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(path, ".go") && (tests || !strings.HasSuffix(path, "_test.go")) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func main() {
+	query := flag.String("query", "", "functions, types, interfaces, implementations, struct_fields, todos, imports, calls, metrics, api, docs")
+	name := flag.String("name", "", "interface (implementations) or struct (struct_fields) name")
+	exportedOnly := flag.Bool("exported", false, "only exported declarations")
+	recursive := flag.Bool("recursive", true, "descend into subdirectories")
+	tests := flag.Bool("tests", false, "include _test.go files")
+	flag.Parse()
+
+	paths, err := collectFiles(flag.Args(), *recursive, *tests)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	// calls needs identifiers resolved to tell package names from shadowing locals
+	mode := parser.ParseComments
+	if *query != "calls" {
+		mode |= parser.SkipObjectResolution
+	}
+	var files []parsedFile
+	var parseErrors []object
+	for _, path := range paths {
+		file, err := parser.ParseFile(fset, path, nil, mode)
+		if err != nil {
+			parseErrors = append(parseErrors, object{"file": path, "message": err.Error()})
+			if file == nil {
+				continue
+			}
+		}
+		files = append(files, parsedFile{file, file.Name.Name})
+	}
+
+	results := []object{}
+	fileStats := []object{}
+	keep := func(ident string) bool { return !*exportedOnly || ast.IsExported(ident) }
+
+	// Method sets by package and receiver type name, for implementations
+	methods := map[string]map[string]method{}
+	interfaces := map[string]*ast.InterfaceType{}
+	interfacePackages := map[string]string{}
+	for _, f := range files {
+		for _, decl := range f.file.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if recv, pointer := receiverType(d.Recv); recv != "" {
+					key := f.pkg + "." + recv
+					if methods[key] == nil {
+						methods[key] = map[string]method{}
+					}
+					methods[key][d.Name.Name] = method{signature(d.Type), pointer}
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						if it, ok := ts.Type.(*ast.InterfaceType); ok {
+							interfaces[ts.Name.Name] = it
+							interfacePackages[ts.Name.Name] = f.pkg
+						}
+					}
+				}
+			}
+		}
+	}
+
+	switch *query {
+	case "functions":
+		for _, f := range files {
+			for _, decl := range f.file.Decls {
+				d, ok := decl.(*ast.FuncDecl)
+				if !ok || !keep(d.Name.Name) {
+					continue
+				}
+				entry := object{"name": d.Name.Name, "package": f.pkg, "signature": signature(d.Type), "exported": ast.IsExported(d.Name.Name), "doc": docText(d.Doc)}
+				if recv, pointer := receiverType(d.Recv); recv != "" {
+					entry["receiver"] = recv
+					entry["pointerReceiver"] = pointer
+				}
+				_, endLine, _ := position(d.End())
+				entry["endLine"] = endLine
+				results = append(results, located(d.Pos(), entry))
+			}
+		}
+	case "types", "interfaces":
+		for _, f := range files {
+			for _, decl := range f.file.Decls {
+				d, ok := decl.(*ast.GenDecl)
+				if !ok || d.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range d.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if !keep(ts.Name.Name) {
+						continue
+					}
+					kind := "other"
+					switch ts.Type.(type) {
+					case *ast.StructType:
+						kind = "struct"
+					case *ast.InterfaceType:
+						kind = "interface"
+					case *ast.FuncType:
+						kind = "func"
+					case *ast.MapType:
+						kind = "map"
+					case *ast.ArrayType:
+						kind = "slice"
+					case *ast.Ident, *ast.SelectorExpr:
+						kind = "named"
+					}
+					if *query == "interfaces" && kind != "interface" {
+						continue
+					}
+					doc := ts.Doc
+					if doc == nil {
+						doc = d.Doc
+					}
+					entry := object{"name": ts.Name.Name, "package": f.pkg, "kind": kind, "exported": ast.IsExported(ts.Name.Name), "doc": docText(doc), "alias": ts.Assign.IsValid()}
+					if it, ok := ts.Type.(*ast.InterfaceType); ok {
+						var names []string
+						for _, m := range it.Methods.List {
+							if len(m.Names) == 0 {
+								names = append(names, exprString(m.Type))
+							}
+							for _, n := range m.Names {
+								names = append(names, n.Name)
+							}
+						}
+						entry["methods"] = names
+					}
+					results = append(results, located(ts.Pos(), entry))
+				}
+			}
+		}
+	case "implementations":
+		it := interfaces[*name]
+		if it == nil {
+			fmt.Fprintf(os.Stderr, "interface %s not found\n", *name)
+			os.Exit(2)
+		}
+		// Required methods, with embedded interfaces from the parsed files flattened in
+		required := map[string]string{}
+		var addMethods func(it *ast.InterfaceType, depth int)
+		addMethods = func(it *ast.InterfaceType, depth int) {
+			for _, m := range it.Methods.List {
+				if fn, ok := m.Type.(*ast.FuncType); ok {
+					for _, n := range m.Names {
+						required[n.Name] = signature(fn)
+					}
+				} else if ident, ok := m.Type.(*ast.Ident); ok && interfaces[ident.Name] != nil && depth < 10 {
+					addMethods(interfaces[ident.Name], depth+1)
+				}
+			}
+		}
+		addMethods(it, 0)
+		for _, f := range files {
+			for _, decl := range f.file.Decls {
+				d, ok := decl.(*ast.GenDecl)
+				if !ok || d.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range d.Specs {
+					ts := spec.(*ast.TypeSpec)
+					if _, isInterface := ts.Type.(*ast.InterfaceType); isInterface || len(required) == 0 {
+						continue
+					}
+					have := methods[f.pkg+"."+ts.Name.Name]
+					matches, pointer := true, false
+					for methodName, sig := range required {
+						m, ok := have[methodName]
+						if !ok || m.signature != sig {
+							matches = false
+							break
+						}
+						pointer = pointer || m.pointer
+					}
+					if matches {
+						// With a pointer-receiver method only *T is in the interface's method set
+						results = append(results, located(ts.Pos(), object{"name": ts.Name.Name, "package": f.pkg, "interface": *name, "interfacePackage": interfacePackages[*name], "pointer": pointer}))
+					}
+				}
+			}
+		}
+	case "struct_fields":
+		for _, f := range files {
+			ast.Inspect(f.file, func(n ast.Node) bool {
+				ts, ok := n.(*ast.TypeSpec)
+				if !ok {
+					return true
+				}
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok || (*name != "" && ts.Name.Name != *name) || !keep(ts.Name.Name) {
+					return true
+				}
+				fields := []object{}
+				for _, field := range st.Fields.List {
+					tag := ""
+					if field.Tag != nil {
+						tag, _ = strconv.Unquote(field.Tag.Value)
+					}
+					names := field.Names
+					if len(names) == 0 {
+						fields = append(fields, located(field.Pos(), object{"name": exprString(field.Type), "type": exprString(field.Type), "tag": tag, "tags": parseTag(tag), "embedded": true, "doc": docText(field.Doc)}))
+						continue
+					}
+					for _, n := range names {
+						if !keep(n.Name) {
+							continue
+						}
+						fields = append(fields, located(n.Pos(), object{"name": n.Name, "type": exprString(field.Type), "tag": tag, "tags": parseTag(tag), "embedded": false, "doc": docText(field.Doc)}))
+					}
+				}
+				results = append(results, located(ts.Pos(), object{"name": ts.Name.Name, "package": f.pkg, "fields": fields}))
+				return true
+			})
+		}
+	case "todos":
+		marker := regexp.MustCompile("\\b(TODO|FIXME|XXX|HACK|BUG)\\b[:(]?\\s*(.*)")
+		for _, f := range files {
+			for _, group := range f.file.Comments {
+				for _, c := range group.List {
+					text := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), "/*"), "*/"))
+					if m := marker.FindStringSubmatch(text); m != nil {
+						results = append(results, located(c.Pos(), object{"tag": m[1], "text": strings.TrimSpace(m[2]), "comment": text}))
+					}
+				}
+			}
+		}
+	case "imports":
+		for _, f := range files {
+			for _, imp := range f.file.Imports {
+				path, _ := strconv.Unquote(imp.Path.Value)
+				entry := object{"path": path, "package": f.pkg}
+				if imp.Name != nil {
+					entry["alias"] = imp.Name.Name
+				}
+				results = append(results, located(imp.Pos(), entry))
+			}
+		}
+	case "calls":
+		for _, f := range files {
+			imported := map[string]string{}
+			for _, imp := range f.file.Imports {
+				path, _ := strconv.Unquote(imp.Path.Value)
+				name := importName(path)
+				if imp.Name != nil {
+					name = imp.Name.Name
+				}
+				if name != "_" && name != "." {
+					imported[name] = path
+				}
+			}
+			ast.Inspect(f.file, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				// Package names resolve to no object; locals of the same name do
+				ident, ok := sel.X.(*ast.Ident)
+				if !ok || ident.Obj != nil {
+					return true
+				}
+				if path, ok := imported[ident.Name]; ok {
+					results = append(results, located(call.Pos(), object{"callee": ident.Name + "." + sel.Sel.Name, "importPath": path, "function": sel.Sel.Name, "package": f.pkg}))
+				}
+				return true
+			})
+		}
+	case "metrics":
+		for _, f := range files {
+			functions := 0
+			for _, decl := range f.file.Decls {
+				d, ok := decl.(*ast.FuncDecl)
+				if !ok || d.Body == nil || !keep(d.Name.Name) {
+					continue
+				}
+				functions++
+				name := d.Name.Name
+				if recv, _ := receiverType(d.Recv); recv != "" {
+					name = recv + "." + name
+				}
+				_, line, _ := position(d.Pos())
+				_, endLine, _ := position(d.End())
+				params := 0
+				for _, field := range d.Type.Params.List {
+					params += max(len(field.Names), 1)
+				}
+				// Function literals count towards the function they are in
+				results = append(results, located(d.Pos(), object{"name": name, "package": f.pkg, "complexity": complexity(d.Body), "lines": endLine - line + 1, "endLine": endLine, "params": params}))
+			}
+			tf := fset.File(f.file.Pos())
+			fileStats = append(fileStats, object{"file": tf.Name(), "lines": tf.LineCount(), "bytes": tf.Size(), "functions": functions})
+		}
+	case "api":
+		// The exported API, one entry per symbol, by package directory
+		// relative to the first path; main and internal packages have none
+		root := flag.Arg(0)
+		for _, f := range files {
+			tf := fset.File(f.file.Pos())
+			dir, _ := filepath.Rel(root, filepath.Dir(tf.Name()))
+			dir = filepath.ToSlash(dir)
+			if f.pkg == "main" || strings.HasSuffix(f.pkg, "_test") || internalDir(dir) {
+				continue
+			}
+			add := func(pos token.Pos, kind, name, detail string, extra object) {
+				entry := object{"package": dir, "packageName": f.pkg, "kind": kind, "name": name, "detail": detail}
+				for k, v := range extra {
+					entry[k] = v
+				}
+				results = append(results, located(pos, entry))
+			}
+			for _, decl := range f.file.Decls {
+				switch d := decl.(type) {
+				case *ast.FuncDecl:
+					if !ast.IsExported(d.Name.Name) {
+						continue
+					}
+					recv, pointer := receiverType(d.Recv)
+					if recv == "" {
+						add(d.Pos(), "func", d.Name.Name, typeParams(d.Type.TypeParams)+signature(d.Type), nil)
+					} else if ast.IsExported(recv) {
+						add(d.Pos(), "method", recv+"."+d.Name.Name, signature(d.Type), object{"pointer": pointer})
+					}
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						switch s := spec.(type) {
+						case *ast.TypeSpec:
+							if !ast.IsExported(s.Name.Name) {
+								continue
+							}
+							name := s.Name.Name
+							params := typeParams(s.TypeParams)
+							switch t := s.Type.(type) {
+							case *ast.StructType:
+								add(s.Pos(), "type", name, params+"struct", nil)
+								for _, field := range t.Fields.List {
+									if len(field.Names) == 0 {
+										// Embedded: promoted fields and methods are part of the API
+										embedded := strings.TrimPrefix(exprString(field.Type), "*")
+										if i := strings.LastIndex(embedded, "."); i >= 0 {
+											embedded = embedded[i+1:]
+										}
+										if ast.IsExported(embedded) {
+											add(field.Pos(), "field", name+"."+embedded, "embedded "+exprString(field.Type), nil)
+										}
+									}
+									for _, n := range field.Names {
+										if ast.IsExported(n.Name) {
+											add(n.Pos(), "field", name+"."+n.Name, exprString(field.Type), nil)
+										}
+									}
+								}
+							case *ast.InterfaceType:
+								add(s.Pos(), "type", name, params+"interface", nil)
+								for _, m := range t.Methods.List {
+									fn, ok := m.Type.(*ast.FuncType)
+									if !ok {
+										// Embedded interfaces and type set terms
+										add(m.Pos(), "interface method", name+"."+exprString(m.Type), "embedded", nil)
+										continue
+									}
+									// Unexported methods count too: adding one stops outside implementations
+									for _, n := range m.Names {
+										add(n.Pos(), "interface method", name+"."+n.Name, signature(fn), nil)
+									}
+								}
+							default:
+								detail := exprString(s.Type)
+								if s.Assign.IsValid() {
+									detail = "= " + detail
+								}
+								add(s.Pos(), "type", name, params+detail, nil)
+							}
+						case *ast.ValueSpec:
+							kind := "var"
+							if d.Tok == token.CONST {
+								kind = "const"
+							}
+							detail := ""
+							if s.Type != nil {
+								detail = exprString(s.Type)
+							}
+							for _, n := range s.Names {
+								if ast.IsExported(n.Name) {
+									add(n.Pos(), kind, n.Name, detail, nil)
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	case "docs":
+		// Exported declarations with their doc comments, then one entry per
+		// package with its package comment, from whichever file has one
+		type pkgDoc struct {
+			pos  token.Pos
+			name string
+			doc  string
+		}
+		packages := map[string]*pkgDoc{}
+		var dirs []string
+		for _, f := range files {
+			if f.pkg == "main" || strings.HasSuffix(f.pkg, "_test") {
+				continue
+			}
+			dir := filepath.Dir(fset.File(f.file.Pos()).Name())
+			if packages[dir] == nil {
+				packages[dir] = &pkgDoc{f.file.Package, f.pkg, ""}
+				dirs = append(dirs, dir)
+			}
+			if f.file.Doc != nil && packages[dir].doc == "" {
+				packages[dir].doc = f.file.Doc.Text()
+			}
+			add := func(pos token.Pos, kind, name string, docs ...*ast.CommentGroup) {
+				text := ""
+				for _, d := range docs {
+					if d != nil {
+						text = d.Text()
+						break
+					}
+				}
+				results = append(results, located(pos, object{"kind": kind, "name": name, "package": f.pkg, "doc": text}))
+			}
+			for _, decl := range f.file.Decls {
+				switch d := decl.(type) {
+				case *ast.FuncDecl:
+					if !ast.IsExported(d.Name.Name) {
+						continue
+					}
+					if recv, _ := receiverType(d.Recv); recv == "" {
+						add(d.Pos(), "func", d.Name.Name, d.Doc)
+					} else if ast.IsExported(recv) {
+						add(d.Pos(), "method", recv+"."+d.Name.Name, d.Doc)
+					}
+				case *ast.GenDecl:
+					// The comment on an ungrouped declaration is the spec's; a group's documents its values
+					var declDoc *ast.CommentGroup
+					if !d.Lparen.IsValid() || d.Tok != token.TYPE {
+						declDoc = d.Doc
+					}
+					for _, spec := range d.Specs {
+						switch s := spec.(type) {
+						case *ast.TypeSpec:
+							if ast.IsExported(s.Name.Name) {
+								add(s.Pos(), "type", s.Name.Name, s.Doc, declDoc)
+							}
+						case *ast.ValueSpec:
+							kind := "var"
+							if d.Tok == token.CONST {
+								kind = "const"
+							}
+							for _, n := range s.Names {
+								if ast.IsExported(n.Name) {
+									add(n.Pos(), kind, n.Name, s.Doc, s.Comment, declDoc)
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+		sort.Strings(dirs)
+		for _, dir := range dirs {
+			p := packages[dir]
+			results = append(results, located(p.pos, object{"kind": "package", "name": p.name, "package": p.name, "doc": p.doc}))
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown query %q\n", *query)
+		os.Exit(2)
+	}
+
+	out := object{"results": results, "files": len(files)}
+	if len(parseErrors) > 0 {
+		out["parseErrors"] = parseErrors
+	}
+	if *query == "metrics" {
+		out["fileStats"] = fileStats
+	}
+	enc := json.NewEncoder(os.Stdout)
+	if err := enc.Encode(out); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+}
+`;
+
+const QUERIES = ['functions', 'types', 'interfaces', 'implementations', 'struct_fields', 'todos', 'imports', 'calls'] as const;
+
+// metrics backs code_metrics, api backs api_diff and docs backs check_docs rather than go_ast_query
+export type GoAstQuery = typeof QUERIES[number] | 'metrics' | 'api' | 'docs';
+
+const inputSchema = z.object({
+    path: z.string().describe('Go file or directory (searched recursively, skipping vendor, testdata and hidden directories)'),
+    query: z.enum(QUERIES).describe('functions, types, interfaces, implementations (of the interface in name), struct_fields (all structs, or the one in name), todos (TODO/FIXME/XXX/HACK/BUG comments), imports, calls (calls of imported package functions, such as fmt.Println)'),
+    name: z.string().optional().describe('Interface for implementations; struct for struct_fields'),
+    exported: z.boolean().default(false).describe('Only exported functions, types and fields'),
+    recursive: z.boolean().default(true),
+    includeTests: z.boolean().default(false).describe('Include _test.go files'),
+    timeout: z.number().default(120000),
+});
+
+let helperPath: Promise<string> | undefined;
+
+async function buildHelper(): Promise<string> {
+    const hash = createHash('sha256').update(HELPER_SOURCE).digest('hex').slice(0, 12);
+    const binary = join(cacheDirectory(), `go-ast-${hash}${process.platform === 'win32' ? '.exe' : ''}`);
+    if (await fs.stat(binary).then(() => true, () => false)) return binary;
+
+    const workDir = await fs.mkdtemp(join(tmpdir(), 'cf-go-ast-'));
+    try {
+        await fs.writeFile(join(workDir, 'go.mod'), 'module goast\n\ngo 1.21\n');
+        await fs.writeFile(join(workDir, 'main.go'), HELPER_SOURCE);
+        await fs.mkdir(cacheDirectory(), { recursive: true });
+        // Built under a temporary name and renamed, so concurrent builds never see a partial binary
+        const partial = `${binary}.${process.pid}.tmp`;
+        const result = await runCommand(`go build -o ${shellQuote(partial)} .`, { cwd: workDir, timeout: 120000, local: true, env: { GOFLAGS: '', GOWORK: 'off' } });
+        if (result.exitCode !== 0) throw new Error(`Building the go/ast helper failed: ${result.stderr || result.stdout}`);
+        await fs.rename(partial, binary);
+        return binary;
+    } finally {
+        await fs.rm(workDir, { recursive: true, force: true });
+    }
+}
+
+function helper(): Promise<string> {
+    helperPath ??= buildHelper().catch(error => {
+        helperPath = undefined;
+        throw error;
+    });
+    return helperPath;
+}
+
+function summarize(query: string, name: string | undefined, count: number, files: number): string {
+    switch (query) {
+        case 'implementations':
+            return `${count} type(s) implementing ${name} in ${files} file(s)`;
+        case 'struct_fields':
+            return `${count} struct(s) in ${files} file(s)`;
+        case 'todos':
+            return `${count} TODO comment(s) in ${files} file(s)`;
+        case 'calls':
+            return `${count} package function call(s) in ${files} file(s)`;
+        default:
+            return `${count} ${query === 'functions' ? 'function(s)' : query === 'imports' ? 'import(s)' : 'type(s)'} in ${files} file(s)`;
+    }
+}
+
+/**
+ * Run a query with the go/ast helper (built on first use); throws when the
+ * helper cannot be built or fails
+ */
+export async function queryGoAst(
+    path: string,
+    query: GoAstQuery,
+    options: { name?: string; exported?: boolean; recursive?: boolean; tests?: boolean; timeout?: number } = {}
+): Promise<{ results: any[]; files: number; parseErrors?: { file: string; message: string }[]; fileStats?: any[] }> {
+    const binary = await helper();
+    const flags = [
+        `-query=${query}`,
+        ...(options.name ? [`-name=${shellQuote(options.name)}`] : []),
+        `-exported=${options.exported ?? false}`,
+        `-recursive=${options.recursive ?? true}`,
+        `-tests=${options.tests ?? false}`,
+    ];
+    const result = await runCommand(`${shellQuote(binary)} ${flags.join(' ')} ${shellQuote(resolve(path))}`, { timeout: options.timeout ?? 120000, local: true, maxBuffer: 32 * 1024 * 1024 });
+    if (result.exitCode !== 0) throw new Error(result.stderr.trim() || `go/ast helper exited with ${result.exitCode}`);
+    return JSON.parse(result.stdout);
+}
+
+export const goAstQueryTool = {
+    name: 'go_ast_query',
+    binaries: ['go'],
+    cacheable: true,
+    description: 'Answer structural questions about Go code by parsing it with go/ast (no build or type-check needed): list functions and methods with signatures (optionally exported only), types and interfaces, the types implementing an interface (matched by method names and signatures), struct fields with types and parsed tags, TODO/FIXME comments, imports, or calls of imported package functions (fmt.Println, with aliases resolved). Returns JSON results with file, line, and column.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, query, name, exported, recursive, includeTests, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        if (query === 'implementations' && !name) {
+            return { success: false, errors: ['name (the interface) is required for implementations'], warnings: [], output: '' };
+        }
+        try {
+            const parsed = await queryGoAst(path, query, { ...(name ? { name } : {}), exported, recursive, tests: includeTests, timeout });
+            const warnings = (parsed.parseErrors ?? []).map(e => e.message);
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: summarize(query, name, parsed.results.length, parsed.files),
+                results: parsed.results,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,95 @@
+import { z } from 'zod';
+import { runCommand } from '../utils/command.js';
+import Config from '../config/index.js';
+import { promises as fs } from 'fs';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
+import { type Diagnostic, parseGolangciLintOutput, countBySeverity } from '../diagnostics/index.js';
+import { captureFixTargets, describeFixes, summarizeFixes } from '../utils/autofix.js';
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Go module or package directory to lint'),
+    packages: z.array(z.string()).default(['./...']).describe('Package patterns relative to projectPath'),
+    enable: z.array(z.string()).default([]).describe('Linters to enable in addition to the configured set'),
+    disable: z.array(z.string()).default([]).describe('Linters to disable'),
+    configPath: z.string().optional().describe('Path to a .golangci.yml; defaults to golangci-lint discovery'),
+    fix: z.boolean().default(false).describe('Apply the fixes linters suggest (--fix) and report what changed and what is left'),
+    timeout: z.number().default(300000),
+});
+
+// golangci-lint v2 replaced --out-format with per-format output flags
+async function jsonOutputFlag(cwd: string): Promise<string> {
+    const version = await runCommand('golangci-lint --version', { cwd, timeout: 10000 });
+    return /version v?2\./.test(version.stdout + version.stderr) ? '--output.json.path=stdout' : '--out-format=json';
+}
+
+export const golangciLintTool = {
+    name: 'golangci_lint',
+    binaries: ['golangci-lint'],
+    mutates: (args: any) => Boolean(args?.fix),
+    cacheable: true,
+    description: 'Run golangci-lint on a Go module or package and return the findings as structured diagnostics (file, line, column, severity, message, linter as rule). With fix, applies the auto-fixable findings and returns the changed files with diffs plus the issues that remain.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, packages, enable, disable, configPath, fix, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(projectPath) || (configPath && !config.isPathAllowed(configPath))) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            await fs.access(projectPath);
+            let command = `golangci-lint run ${await jsonOutputFlag(projectPath)}`;
+            if (enable.length > 0) command += ` --enable=${shellQuote(enable.join(','))}`;
+            if (disable.length > 0) command += ` --disable=${shellQuote(disable.join(','))}`;
+            if (configPath) command += ` --config=${shellQuote(configPath)}`;
+            command += ` ${packages.map(shellQuote).join(' ')}`;
+
+            const result = await runCommand(command, { cwd: projectPath, timeout, maxBuffer: 16 * 1024 * 1024 });
+            const diagnostics: Diagnostic[] = parseGolangciLintOutput(result.stdout, projectPath);
+            // Exit code 1 means issues were found; anything else non-zero is a failed run
+            if (result.exitCode !== 0 && diagnostics.length === 0) {
+                return { success: false, errors: [`golangci-lint failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout, diagnostics };
+            }
+            if (fix && diagnostics.some(d => d.fixable)) {
+                // The fix run reports only the issues it could not fix
+                const before = await captureFixTargets(diagnostics);
+                const fixRun = await runCommand(`${command} --fix`, { cwd: projectPath, timeout, maxBuffer: 16 * 1024 * 1024 });
+                const remaining = parseGolangciLintOutput(fixRun.stdout, projectPath);
+                if (fixRun.exitCode !== 0 && remaining.length === 0) {
+                    return { success: false, errors: [`golangci-lint --fix failed: ${fixRun.stderr || fixRun.stdout}`], warnings: [], output: fixRun.stdout, diagnostics };
+                }
+                const summary = await summarizeFixes(before, diagnostics, remaining);
+                const counts = countBySeverity(remaining);
+                return {
+                    success: remaining.length === 0,
+                    errors: counts.error > 0 ? [`golangci-lint reported ${counts.error} error(s) it could not fix`] : [],
+                    warnings: counts.warning + counts.info > 0 ? [`golangci-lint reported ${counts.warning + counts.info} issue(s) it could not fix`] : [],
+                    output: describeFixes(summary),
+                    diagnostics: remaining,
+                    fixed: summary.fixed,
+                    changes: summary.changes,
+                };
+            }
+            const counts = countBySeverity(diagnostics);
+            return {
+                success: diagnostics.length === 0,
+                errors: counts.error > 0 ? [`golangci-lint reported ${counts.error} error(s)`] : [],
+                warnings: counts.warning + counts.info > 0 ? [`golangci-lint reported ${counts.warning + counts.info} issue(s)`] : [],
+                output: `${diagnostics.length} issue(s) found${fix && diagnostics.length > 0 ? ', none auto-fixable' : ''}`,
+                diagnostics,
+                ...(fix ? { fixed: 0, changes: [] } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
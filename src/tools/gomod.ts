@@ -0,0 +1,243 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, isAbsolute, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { findUp } from '../utils/paths.js';
+import { type Edges } from '../utils/graph.js';
+import { type Diagnostic } from '../diagnostics/index.js';
+
+export interface ModuleVersion {
+    path: string;
+    version?: string;
+}
+
+export interface GoModFile {
+    module: string;
+    go?: string;
+    require: Array<ModuleVersion & { indirect: boolean }>;
+    replace: Array<{ old: ModuleVersion; new: ModuleVersion }>;
+}
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Go module directory (or any directory inside it)'),
+    checks: z.array(z.enum(['tidy', 'replace', 'major', 'graph'])).default(['tidy', 'replace', 'major', 'graph']),
+    timeout: z.number().default(120000),
+});
+
+/**
+ * Parse `go mod edit -json`
+ */
+export function parseGoModJson(output: string): GoModFile {
+    const data = JSON.parse(output);
+    const version = (mod: any): ModuleVersion => ({ path: String(mod?.Path ?? ''), ...(mod?.Version ? { version: String(mod.Version) } : {}) });
+    return {
+        module: String(data.Module?.Path ?? ''),
+        ...(data.Go ? { go: String(data.Go) } : {}),
+        require: (data.Require ?? []).map((r: any) => ({ ...version(r), indirect: Boolean(r.Indirect) })),
+        replace: (data.Replace ?? []).map((r: any) => ({ old: version(r.Old), new: version(r.New) })),
+    };
+}
+
+/**
+ * Parse `go mod graph`: "from@version to@version" per line; the main module has no version.
+ * The go@ and toolchain@ requirements Go 1.21+ lists are versions, not modules, and are dropped.
+ */
+export function parseGoModGraph(output: string): Edges {
+    const edges: Edges = new Map();
+    for (const line of output.split('\n')) {
+        const [from, to] = line.trim().split(/\s+/);
+        if (!from || !to || /^(go|toolchain)@/.test(to) || /^(go|toolchain)@/.test(from)) continue;
+        edges.set(from, [...(edges.get(from) ?? []), to]);
+        if (!edges.has(to)) edges.set(to, []);
+    }
+    return edges;
+}
+
+// github.com/a/b/v3 -> { base: github.com/a/b, major: 3 }; gopkg.in/yaml.v3 -> { base: gopkg.in/yaml, major: 3 }
+export function splitMajor(path: string): { base: string; major: number } {
+    const gopkg = /^(gopkg\.in\/.+)\.v(\d+)$/.exec(path);
+    if (gopkg) return { base: gopkg[1]!, major: Number(gopkg[2]) };
+    const suffix = /^(.+)\/v(\d+)$/.exec(path);
+    if (suffix && Number(suffix[2]) >= 2) return { base: suffix[1]!, major: Number(suffix[2]) };
+    return { base: path, major: 1 };
+}
+
+function versionMajor(version: string): number {
+    return Number(/^v(\d+)/.exec(version)?.[1] ?? 0);
+}
+
+function compareSemver(a: string, b: string): number {
+    const parts = (v: string) => (/^v(\d+)\.(\d+)\.(\d+)/.exec(v) ?? []).slice(1).map(Number);
+    const [left, right] = [parts(a), parts(b)];
+    for (let i = 0; i < 3; i++) {
+        const diff = (left[i] ?? 0) - (right[i] ?? 0);
+        if (diff !== 0) return diff;
+    }
+    return 0;
+}
+
+function isLocalPath(path: string): boolean {
+    return path.startsWith('./') || path.startsWith('../') || isAbsolute(path);
+}
+
+/**
+ * Replace directives worth a second look: local directories (break every
+ * build outside this checkout), forks, downgrades below what is required,
+ * and replacements of modules nothing requires
+ */
+export function findReplaceIssues(mod: GoModFile, graphModules: Set<string> | null): Array<{ path: string; message: string }> {
+    const issues: Array<{ path: string; message: string }> = [];
+    for (const { old, new: replacement } of mod.replace) {
+        const target = `${old.path}${old.version ? `@${old.version}` : ''}`;
+        if (isLocalPath(replacement.path)) {
+            issues.push({ path: old.path, message: `${target} is replaced by the local directory ${replacement.path}; builds outside this checkout and \`go install\` of the module will fail` });
+        } else if (replacement.path !== old.path) {
+            issues.push({ path: old.path, message: `${target} is replaced by a different module, ${replacement.path}@${replacement.version}; make sure the fork is meant to stay` });
+        }
+        const required = mod.require.find(r => r.path === old.path);
+        if (required?.version && replacement.version && !isLocalPath(replacement.path) && compareSemver(replacement.version, required.version) < 0) {
+            issues.push({ path: old.path, message: `${old.path} is required at ${required.version} but replaced by the older ${replacement.version}` });
+        }
+        if (graphModules && !graphModules.has(old.path) && !required) {
+            issues.push({ path: old.path, message: `${target} is replaced but no module in the graph requires it; the directive has no effect` });
+        }
+    }
+    return issues;
+}
+
+/**
+ * Major-version problems: +incompatible requirements (v2+ published without
+ * a /vN module path), path suffixes that disagree with the version, and one
+ * module present at several major versions
+ */
+export function findMajorVersionIssues(mod: GoModFile, graphNodes: string[]): Array<{ path: string; message: string }> {
+    const issues: Array<{ path: string; message: string }> = [];
+    for (const req of mod.require) {
+        if (!req.version) continue;
+        const { major } = splitMajor(req.path);
+        if (req.version.endsWith('+incompatible')) {
+            issues.push({ path: req.path, message: `${req.path}@${req.version} is a v${versionMajor(req.version)} release without a /v${versionMajor(req.version)} module path (+incompatible); its API can break without a path change` });
+        } else if (versionMajor(req.version) > 1 && versionMajor(req.version) !== major) {
+            issues.push({ path: req.path, message: `${req.path} is at ${req.version}, but its path says v${major}` });
+        }
+    }
+    const majors = new Map<string, Set<string>>();
+    for (const node of [...mod.require.map(r => r.path), ...graphNodes.map(n => n.split('@')[0] ?? n)]) {
+        const { base } = splitMajor(node);
+        majors.set(base, (majors.get(base) ?? new Set()).add(node));
+    }
+    for (const [base, paths] of majors) {
+        if (paths.size < 2 || base === mod.module) continue;
+        const sorted = [...paths].sort();
+        issues.push({ path: sorted[0]!, message: `${base} is in the module graph at ${sorted.length} major versions (${sorted.join(', ')}); consider converging on one` });
+    }
+    return issues;
+}
+
+// Line of go.mod mentioning a module path (its replace directive for replace findings), so findings point somewhere useful
+function lineOf(goModText: string, path: string, replace: boolean): number {
+    const lines = goModText.split('\n');
+    const mentions = (line: string) => line.split(/\s+/).includes(path);
+    const index = replace ? lines.findIndex(line => mentions(line) && line.includes('=>')) : -1;
+    const found = index !== -1 ? index : lines.findIndex(mentions);
+    return found === -1 ? 1 : found + 1;
+}
+
+export const goModCheckTool = {
+    name: 'go_mod_check',
+    binaries: ['go'],
+    cacheable: true,
+    description: 'Check go.mod hygiene: whether `go mod tidy` would change go.mod or go.sum (with the diff), suspicious replace directives (local paths, forks, downgrades, no-op replaces), major-version problems (+incompatible requirements, one module at several majors), and the module requirement graph from `go mod graph`. Findings come back as diagnostics on go.mod lines.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, checks, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const goModPath = await findUp(resolve(projectPath), 'go.mod');
+            if (!goModPath) return { success: false, errors: ['No go.mod found at or above projectPath'], warnings: [], output: '' };
+            const cwd = dirname(goModPath);
+            const goModText = await fs.readFile(goModPath, 'utf-8');
+            const edit = await runCommand('go mod edit -json', { cwd, timeout });
+            if (edit.exitCode !== 0) return { success: false, errors: [`go mod edit failed: ${edit.stderr.trim()}`], warnings: [], output: '' };
+            const mod = parseGoModJson(edit.stdout);
+
+            const errors: string[] = [];
+            const warnings: string[] = [];
+            const diagnostics: Diagnostic[] = [];
+            const report = (path: string, message: string, rule: string, severity: Diagnostic['severity'] = 'warning') => {
+                diagnostics.push({ file: goModPath, line: lineOf(goModText, path, rule === 'replace'), column: 1, severity, message, rule, source: 'go_mod_check' });
+            };
+            const lines: string[] = [];
+
+            let tidy: { tidy: boolean; diff?: string } | null = null;
+            if (checks.includes('tidy')) {
+                // -diff (Go 1.23+) reports the change without writing go.mod or go.sum
+                const result = await runCommand('go mod tidy -diff', { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                if (result.exitCode === 0) {
+                    tidy = { tidy: true };
+                } else if (result.stdout.trim()) {
+                    tidy = { tidy: false, diff: result.stdout };
+                    errors.push('go mod tidy would change go.mod or go.sum; run `go mod tidy`');
+                    diagnostics.push({ file: goModPath, line: 1, column: 1, severity: 'error', message: 'go.mod or go.sum is not tidy', rule: 'tidy', source: 'go_mod_check' });
+                } else if (/flag provided but not defined: -diff/.test(result.stderr)) {
+                    warnings.push('Skipped the tidy check: go mod tidy -diff needs Go 1.23 or later');
+                } else {
+                    errors.push(`go mod tidy failed: ${result.stderr.trim()}`);
+                }
+                if (tidy) lines.push(tidy.tidy ? 'tidy: ok' : 'tidy: go mod tidy would make changes');
+            }
+
+            let graph: Edges | null = null;
+            if (checks.some(c => c === 'graph' || c === 'replace' || c === 'major')) {
+                const result = await runCommand('go mod graph', { cwd, timeout, maxBuffer: 64 * 1024 * 1024 });
+                if (result.exitCode === 0) graph = parseGoModGraph(result.stdout);
+                else warnings.push(`go mod graph failed, so graph-based checks are partial: ${result.stderr.trim()}`);
+            }
+            const graphNodes = graph ? [...graph.keys()] : [];
+
+            if (checks.includes('replace')) {
+                const issues = findReplaceIssues(mod, graph ? new Set(graphNodes.map(n => n.split('@')[0] ?? n)) : null);
+                issues.forEach(issue => report(issue.path, issue.message, 'replace'));
+                lines.push(`replace: ${mod.replace.length} directive(s), ${issues.length} issue(s)`);
+            }
+            if (checks.includes('major')) {
+                const issues = findMajorVersionIssues(mod, graphNodes);
+                issues.forEach(issue => report(issue.path, issue.message, 'major-version'));
+                lines.push(`major versions: ${issues.length} issue(s)`);
+            }
+            if (graph && checks.includes('graph')) {
+                const edgeCount = [...graph.values()].reduce((count, targets) => count + targets.length, 0);
+                lines.push(`graph: ${graph.size} module(s), ${edgeCount} requirement(s)`);
+            }
+            warnings.push(...diagnostics.filter(d => d.severity === 'warning').map(d => d.message));
+
+            return {
+                success: errors.length === 0,
+                errors,
+                warnings,
+                output: [`module ${mod.module}${mod.go ? ` (go ${mod.go})` : ''}`, ...lines, ...(tidy?.diff ? ['', tidy.diff] : [])].join('\n'),
+                module: mod.module,
+                ...(tidy ? { tidy } : {}),
+                require: mod.require,
+                replace: mod.replace,
+                diagnostics,
+                ...(graph && checks.includes('graph') ? { graph: { main: mod.module, edges: Object.fromEntries(graph) } } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
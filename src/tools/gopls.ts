@@ -0,0 +1,268 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname } from 'path';
+import { fileURLToPath } from 'url';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+
+export interface SourceLocation {
+    file: string;
+    line: number;
+    column: number;
+    endLine?: number;
+    endColumn?: number;
+    // The source line, trimmed, so callers need not open the file
+    text?: string;
+}
+
+export interface GoSymbol extends SourceLocation {
+    name: string;
+    kind: string;
+}
+
+// gopls spans: "/work/a.go:12:5", "/work/a.go:12:5-9", "/work/a.go:12:5-14:2"
+const spanPattern = /^(.+?):(\d+):(\d+)(?:-(?:(\d+):)?(\d+))?$/;
+
+export function parseGoplsSpan(span: string): SourceLocation | null {
+    const match = spanPattern.exec(span.trim());
+    if (!match) return null;
+    const line = Number(match[2]);
+    return {
+        file: match[1] ?? '',
+        line,
+        column: Number(match[3]),
+        ...(match[5] ? { endLine: match[4] ? Number(match[4]) : line, endColumn: Number(match[5]) } : {}),
+    };
+}
+
+/**
+ * Parse `gopls workspace_symbol` output: one "span name kind" line per symbol
+ */
+export function parseWorkspaceSymbols(output: string): GoSymbol[] {
+    const symbols: GoSymbol[] = [];
+    for (const line of output.split('\n')) {
+        const match = /^(\S.*?:\d+:\d+(?:-\d+(?::\d+)?)?) (\S+) (\S+)$/.exec(line.trim());
+        const location = match ? parseGoplsSpan(match[1] ?? '') : null;
+        if (!match || !location) continue;
+        symbols.push({ name: match[2] ?? '', kind: match[3] ?? '', ...location });
+    }
+    return symbols;
+}
+
+/**
+ * Parse `gopls references` output: one span per line
+ */
+export function parseReferences(output: string): SourceLocation[] {
+    return output.split('\n').map(parseGoplsSpan).filter((l): l is SourceLocation => l !== null);
+}
+
+/**
+ * Parse `gopls definition -json`: the span of the declaration and its
+ * signature and doc comment as description
+ */
+export function parseDefinitionJson(output: string): { location: SourceLocation; description: string } | null {
+    let parsed: any;
+    try {
+        parsed = JSON.parse(output);
+    } catch {
+        return null;
+    }
+    const span = parsed?.span;
+    if (!span?.uri || !span.start) return null;
+    const file = String(span.uri).startsWith('file://') ? fileURLToPath(span.uri) : String(span.uri);
+    const location: SourceLocation = { file, line: Number(span.start.line), column: Number(span.start.column) };
+    if (span.end?.line) {
+        location.endLine = Number(span.end.line);
+        location.endColumn = Number(span.end.column);
+    }
+    return { location, description: String(parsed.description ?? '').trim() };
+}
+
+// Fill in the source line of each location, reading every file once
+async function withSourceText<T extends SourceLocation>(locations: T[]): Promise<T[]> {
+    const files = new Map<string, string[] | null>();
+    for (const location of locations) {
+        if (!files.has(location.file)) {
+            files.set(location.file, await fs.readFile(location.file, 'utf-8').then(c => c.split('\n'), () => null));
+        }
+        const text = files.get(location.file)?.[location.line - 1];
+        if (text !== undefined) location.text = text.trim();
+    }
+    return locations;
+}
+
+// gopls loads the workspace from its working directory
+async function moduleRoot(path: string): Promise<string> {
+    const stats = await fs.stat(path);
+    const dir = stats.isDirectory() ? path : dirname(path);
+    const modFile = (await findUp(dir, 'go.work')) ?? (await findUp(dir, 'go.mod'));
+    return modFile ? dirname(modFile) : dir;
+}
+
+const positionSchema = {
+    filePath: z.string().describe('Go file containing the identifier, or any path in the module when using symbol'),
+    line: z.number().int().min(1).optional().describe('1-based line of the identifier'),
+    column: z.number().int().min(1).optional().describe('1-based column (byte offset in the line) of the identifier'),
+    symbol: z.string().optional().describe('Symbol name (Foo, Type.Method, pkg.Func) to look up instead of a position'),
+    timeout: z.number().default(120000),
+};
+
+const findSymbolSchema = z.object({
+    path: z.string().describe('Any path inside the Go module or workspace'),
+    query: z.string().min(1).describe('Symbol name or fragment'),
+    matcher: z.enum(['fuzzy', 'caseSensitive', 'caseInsensitive']).default('fuzzy'),
+    kind: z.string().optional().describe('Only return symbols of this kind (Function, Method, Struct, Interface, Variable, Constant, Field, ...)'),
+    limit: z.number().int().min(1).max(1000).default(100),
+    timeout: z.number().default(120000),
+});
+
+const referencesSchema = z.object({
+    ...positionSchema,
+    includeDeclaration: z.boolean().default(false),
+});
+
+const definitionSchema = z.object(positionSchema);
+
+function validationFailure(error: z.ZodError) {
+    return {
+        success: false,
+        errors: error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+        warnings: [] as string[],
+        output: '',
+    };
+}
+
+async function workspaceSymbols(cwd: string, query: string, matcher: string, timeout: number) {
+    const result = await runCommand(`gopls workspace_symbol -matcher=${matcher} ${shellQuote(query)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+    return { result, symbols: parseWorkspaceSymbols(result.stdout) };
+}
+
+/**
+ * The gopls position ("file:line:col") for a call: the given position, or the
+ * declaration of the named symbol. An error string when it cannot be resolved.
+ */
+async function resolvePosition(args: z.infer<typeof definitionSchema>, cwd: string): Promise<{ position: string; symbol?: GoSymbol } | { error: string; candidates?: GoSymbol[] }> {
+    if (args.line !== undefined) {
+        return { position: `${args.filePath}:${args.line}:${args.column ?? 1}` };
+    }
+    if (!args.symbol) return { error: 'Either line (and column) or symbol is required' };
+    const { result, symbols } = await workspaceSymbols(cwd, args.symbol, 'caseSensitive', args.timeout);
+    if (result.exitCode !== 0) return { error: `gopls workspace_symbol failed: ${result.stderr || result.stdout}` };
+    const wanted = args.symbol;
+    const exact = symbols.filter(s => s.name === wanted || s.name.endsWith(`.${wanted}`));
+    if (exact.length === 0) return { error: `No symbol named ${wanted}` };
+    if (exact.length > 1) return { error: `Symbol ${wanted} is ambiguous; pass a qualified name or a position`, candidates: exact };
+    const symbol = exact[0]!;
+    return { position: `${symbol.file}:${symbol.line}:${symbol.column}`, symbol };
+}
+
+export const findSymbolTool = {
+    name: 'find_symbol',
+    binaries: ['gopls'],
+    description: 'Search the Go workspace for symbols (functions, methods, types, fields, constants, variables) by name with gopls `workspace_symbol`. Returns name, kind, file, line, column, and the declaration line.',
+    inputSchema: zodToJsonSchema(findSymbolSchema),
+    async run(args: any) {
+        const parseResult = findSymbolSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { path, query, matcher, kind, limit, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const cwd = await moduleRoot(path);
+            const { result, symbols } = await workspaceSymbols(cwd, query, matcher, timeout);
+            if (result.exitCode !== 0) {
+                return { success: false, errors: [`gopls workspace_symbol failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout };
+            }
+            const matching = symbols.filter(s => !kind || s.kind.toLowerCase() === kind.toLowerCase());
+            const returned = await withSourceText(matching.slice(0, limit));
+            return {
+                success: true,
+                errors: [],
+                warnings: matching.length > limit ? [`Showing ${limit} of ${matching.length} symbols`] : [],
+                output: `${matching.length} symbol(s) matching ${query}`,
+                symbols: returned,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const findReferencesTool = {
+    name: 'find_references',
+    binaries: ['gopls'],
+    description: 'List every reference to the Go identifier at a position (or to a named symbol) with gopls `references`. Returns file, line, column, and the source line of each reference.',
+    inputSchema: zodToJsonSchema(referencesSchema),
+    async run(args: any) {
+        const parseResult = referencesSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { filePath, includeDeclaration, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(filePath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const cwd = await moduleRoot(filePath);
+            const resolved = await resolvePosition(parseResult.data, cwd);
+            if ('error' in resolved) {
+                return { success: false, errors: [resolved.error], warnings: [], output: '', ...(resolved.candidates ? { candidates: resolved.candidates } : {}) };
+            }
+            const flags = includeDeclaration ? ' -d' : '';
+            const result = await runCommand(`gopls references${flags} ${shellQuote(resolved.position)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+            if (result.exitCode !== 0) {
+                return { success: false, errors: [`gopls references failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout };
+            }
+            const references = await withSourceText(parseReferences(result.stdout));
+            return {
+                success: true,
+                errors: [],
+                warnings: [],
+                output: `${references.length} reference(s) in ${new Set(references.map(r => r.file)).size} file(s)`,
+                ...(resolved.symbol ? { symbol: resolved.symbol } : {}),
+                references,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const gotoDefinitionTool = {
+    name: 'goto_definition',
+    binaries: ['gopls'],
+    description: 'Find where the Go identifier at a position (or a named symbol) is declared with gopls `definition`. Returns the file, line, and column of the declaration plus its signature and doc comment.',
+    inputSchema: zodToJsonSchema(definitionSchema),
+    async run(args: any) {
+        const parseResult = definitionSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { filePath, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(filePath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const cwd = await moduleRoot(filePath);
+            const resolved = await resolvePosition(parseResult.data, cwd);
+            if ('error' in resolved) {
+                return { success: false, errors: [resolved.error], warnings: [], output: '', ...(resolved.candidates ? { candidates: resolved.candidates } : {}) };
+            }
+            const result = await runCommand(`gopls definition -json ${shellQuote(resolved.position)}`, { cwd, timeout });
+            const definition = result.exitCode === 0 ? parseDefinitionJson(result.stdout) : null;
+            if (!definition) {
+                return { success: false, errors: [`gopls definition failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout };
+            }
+            const [location] = await withSourceText([definition.location]);
+            return {
+                success: true,
+                errors: [],
+                warnings: [],
+                output: definition.description,
+                definition: { ...location, description: definition.description },
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,248 @@
+import { z } from 'zod';
+import { promises as fs, constants } from 'fs';
+import { tmpdir } from 'os';
+import { join } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { findProjectConfig, getGlobalConfigPath, isToolEnabled, loadConfigFile, mergeConfigs, type ProjectConfig } from '../config/project.js';
+import { buildSshProbeCommand } from '../executor/ssh.js';
+import { getPluginsFilePath, loadPluginsFile } from '../plugins/index.js';
+import { getApiKeysFilePath, loadApiKeys } from '../quota/index.js';
+import { runCommand } from '../utils/command.js';
+import { cacheDirectory } from '../utils/paths.js';
+import { getWebhooksFilePath, loadWebhooks } from '../webhooks/index.js';
+import { workspaceRegistry } from '../workspaces/index.js';
+import { probeBinary } from './environment.js';
+
+export type CheckStatus = 'ok' | 'warn' | 'fail';
+
+export interface HealthCheck {
+    category: 'config' | 'binaries' | 'workspaces' | 'runtime';
+    name: string;
+    status: CheckStatus;
+    message: string;
+    // Tools a missing or broken binary leaves unusable
+    tools?: string[];
+}
+
+export interface HealthReport {
+    // No check failed: the server can take tool calls
+    ready: boolean;
+    summary: Record<CheckStatus, number>;
+    checks: HealthCheck[];
+    checkedAt: string;
+}
+
+interface CheckedTool {
+    name: string;
+    binaries?: string[];
+}
+
+// engines.node in package.json
+const MIN_NODE_MAJOR = 16;
+
+async function access(path: string, mode: number): Promise<boolean> {
+    return fs.access(path, mode).then(() => true, () => false);
+}
+
+async function checkConfigFiles(path: string | undefined): Promise<{ checks: HealthCheck[]; config: ProjectConfig }> {
+    const checks: HealthCheck[] = [];
+    let config: ProjectConfig = {};
+    const globalPath = getGlobalConfigPath();
+    try {
+        const global = await loadConfigFile(globalPath);
+        if (global) config = global;
+        checks.push({ category: 'config', name: 'global config', status: 'ok', message: global ? `${globalPath} is valid` : `No ${globalPath}; defaults apply` });
+    } catch (error: any) {
+        checks.push({ category: 'config', name: 'global config', status: 'fail', message: error.message });
+    }
+    // The project this check is for, or every allowed root's own config
+    const roots = path ? [path] : Config.getInstance().getAllowedPaths();
+    const projectPaths = new Set<string>();
+    for (const root of roots) {
+        const found = await findProjectConfig(root).catch(() => null);
+        if (found) projectPaths.add(found);
+    }
+    for (const projectPath of projectPaths) {
+        try {
+            const project = await loadConfigFile(projectPath);
+            if (path && project) config = mergeConfigs(config, project);
+            checks.push({ category: 'config', name: 'project config', status: 'ok', message: `${projectPath} is valid` });
+        } catch (error: any) {
+            checks.push({ category: 'config', name: 'project config', status: 'fail', message: error.message });
+        }
+    }
+    const files: Array<[string, string, () => Promise<unknown>]> = [
+        ['plugins', getPluginsFilePath(), () => loadPluginsFile()],
+        ['API keys', getApiKeysFilePath(), () => loadApiKeys()],
+        ['webhooks', getWebhooksFilePath(), () => loadWebhooks()],
+    ];
+    for (const [name, file, load] of files) {
+        if (!await access(file, constants.F_OK)) continue;
+        try {
+            await load();
+            checks.push({ category: 'config', name: `${name} file`, status: 'ok', message: `${file} is valid` });
+        } catch (error: any) {
+            checks.push({ category: 'config', name: `${name} file`, status: 'fail', message: error.message || String(error) });
+        }
+    }
+    return { checks, config };
+}
+
+async function checkBinaries(tools: CheckedTool[]): Promise<HealthCheck[]> {
+    const users = new Map<string, string[]>();
+    for (const tool of tools) {
+        for (const binary of tool.binaries ?? []) users.set(binary, [...(users.get(binary) ?? []), tool.name]);
+    }
+    const names = [...users.keys()].sort();
+    const probes = await Promise.all(names.map(name => probeBinary(name)));
+    return probes.map(probe => {
+        const needed = users.get(probe.name)!;
+        if (!probe.found) {
+            return { category: 'binaries', name: probe.name, status: 'warn', message: `Not found on PATH; ${needed.length} tool(s) cannot run`, tools: needed };
+        }
+        if (probe.runs === false) {
+            return { category: 'binaries', name: probe.name, status: 'fail', message: `${probe.path} cannot be executed${probe.versionOutput ? `: ${probe.versionOutput}` : ''}`, tools: needed };
+        }
+        return { category: 'binaries', name: probe.name, status: 'ok', message: `${probe.version ?? 'unknown version'} (${probe.path})` };
+    });
+}
+
+async function checkDirectory(name: string, dir: string, writable: boolean): Promise<HealthCheck> {
+    const stats = await fs.stat(dir).catch(() => null);
+    if (!stats) return { category: 'workspaces', name, status: 'fail', message: `${dir} does not exist` };
+    if (!stats.isDirectory()) return { category: 'workspaces', name, status: 'fail', message: `${dir} is not a directory` };
+    if (!await access(dir, constants.R_OK | constants.X_OK)) return { category: 'workspaces', name, status: 'fail', message: `${dir} is not readable by this process` };
+    if (writable && !await access(dir, constants.W_OK)) {
+        return { category: 'workspaces', name, status: 'warn', message: `${dir} is not writable, so tools that edit files, format or install fail there` };
+    }
+    return { category: 'workspaces', name, status: 'ok', message: `${dir} is ${writable ? 'readable and writable' : 'readable'}` };
+}
+
+async function checkWorkspaces(): Promise<HealthCheck[]> {
+    const config = Config.getInstance();
+    const checks: HealthCheck[] = [];
+    if (config.getAllowedPaths().length === 0 && config.getReadOnlyPaths().length === 0) {
+        checks.push({ category: 'workspaces', name: 'allowed paths', status: 'warn', message: 'MCP_ALLOWED_PATHS is empty, so every tool call that takes a path is refused' });
+    }
+    for (const path of config.getAllowedPaths()) checks.push(await checkDirectory(`allowed path ${path}`, path, true));
+    for (const path of config.getReadOnlyPaths()) checks.push(await checkDirectory(`read-only path ${path}`, path, false));
+    for (const workspace of await workspaceRegistry.list()) {
+        const name = `workspace ${workspace.id}`;
+        checks.push(config.isPathAllowed(workspace.root)
+            ? await checkDirectory(name, workspace.root, config.isPathWritable(workspace.root))
+            : { category: 'workspaces', name, status: 'fail', message: `${workspace.root} is outside the allowed paths` });
+    }
+    return checks;
+}
+
+async function checkRuntime(config: ProjectConfig): Promise<HealthCheck[]> {
+    const checks: HealthCheck[] = [];
+    const major = Number(process.versions.node.split('.')[0]);
+    checks.push(major >= MIN_NODE_MAJOR
+        ? { category: 'runtime', name: 'node', status: 'ok', message: `Node ${process.versions.node}` }
+        : { category: 'runtime', name: 'node', status: 'fail', message: `Node ${process.versions.node} is older than ${MIN_NODE_MAJOR}` });
+    // Builds, fuzz corpora and profiles go through temporary and cache directories
+    for (const [name, dir] of [['temp directory', tmpdir()], ['cache directory', cacheDirectory()]] as const) {
+        try {
+            await fs.mkdir(dir, { recursive: true });
+            const probe = await fs.mkdtemp(join(dir, 'cf-health-'));
+            await fs.rm(probe, { recursive: true, force: true });
+            checks.push({ category: 'runtime', name, status: 'ok', message: `${dir} is writable` });
+        } catch (error: any) {
+            checks.push({ category: 'runtime', name, status: 'fail', message: `${dir} is not writable: ${error.message}` });
+        }
+    }
+    const executor = Config.getInstance().getExecutor();
+    if (executor === 'docker') {
+        const info = await runCommand('docker version --format "{{.Server.Version}}"', { timeout: 15000, local: true }).catch(() => null);
+        checks.push(info && info.exitCode === 0
+            ? { category: 'runtime', name: 'docker executor', status: 'ok', message: `Docker daemon ${info.stdout.trim()}` }
+            : { category: 'runtime', name: 'docker executor', status: 'fail', message: `MCP_EXECUTOR=docker but the Docker daemon is not reachable${info ? `: ${info.stderr.trim()}` : ''}` });
+    }
+    // Only the remote the config selects has to be up; the others are reported for information
+    await Promise.all(Object.entries(config.remotes ?? {}).map(async ([name, remote]) => {
+        const probe = await runCommand(buildSshProbeCommand(remote, name), { timeout: 20000, local: true }).catch(() => null);
+        const selected = config.executor === name;
+        checks.push(probe && probe.exitCode === 0
+            ? { category: 'runtime', name: `remote ${name}`, status: 'ok', message: `${remote.host} accepts SSH connections` }
+            : { category: 'runtime', name: `remote ${name}`, status: selected ? 'fail' : 'warn', message: `${remote.host} is not reachable over SSH without a prompt${probe?.stderr.trim() ? `: ${probe.stderr.trim()}` : ''}` });
+    }));
+    return checks;
+}
+
+/**
+ * Check that the server can do its work: config files parse, the binaries
+ * of enabled tools exist and run, allowed paths and workspaces are
+ * accessible, and temporary, cache and executor resources work. Missing
+ * binaries only warn, since the other tools still run.
+ */
+export async function runHealthCheck(options: { path?: string; tools?: CheckedTool[] } = {}): Promise<HealthReport> {
+    const { checks: configChecks, config } = await checkConfigFiles(options.path);
+    // Imported lazily: the tool registry imports this module
+    const tools = options.tools ?? (await import('./index.js')).allTools as CheckedTool[];
+    const [binaries, workspaces, runtime] = await Promise.all([
+        checkBinaries(tools.filter(tool => isToolEnabled(config, tool.name))),
+        checkWorkspaces(),
+        checkRuntime(config),
+    ]);
+    const checks = [...configChecks, ...binaries, ...workspaces, ...runtime];
+    const summary = { ok: 0, warn: 0, fail: 0 };
+    for (const check of checks) summary[check.status]++;
+    return { ready: summary.fail === 0, summary, checks, checkedAt: new Date().toISOString() };
+}
+
+const MARKS: Record<CheckStatus, string> = { ok: 'ok  ', warn: 'warn', fail: 'FAIL' };
+
+/**
+ * The report as text, grouped by category
+ */
+export function formatHealthReport(report: HealthReport): string {
+    const lines = [`${report.ready ? 'Ready' : 'Not ready'}: ${report.summary.ok} ok, ${report.summary.warn} warning(s), ${report.summary.fail} failed`];
+    for (const category of ['config', 'binaries', 'workspaces', 'runtime'] as const) {
+        const checks = report.checks.filter(c => c.category === category);
+        if (checks.length === 0) continue;
+        lines.push('', `${category[0]!.toUpperCase()}${category.slice(1)}:`);
+        for (const check of checks) {
+            lines.push(`  [${MARKS[check.status]}] ${check.name}: ${check.message}${check.tools ? ` (${check.tools.join(', ')})` : ''}`);
+        }
+    }
+    return lines.join('\n');
+}
+
+const inputSchema = z.object({
+    path: z.string().optional().describe('Project whose .code-feedback.yaml to validate and whose enabled tools to check; default every allowed root'),
+});
+
+export const healthCheckTool = {
+    name: 'health_check',
+    description: 'Report whether the server is ready for work, for orchestrators and readiness probes: config files (global, project, plugins, API keys, webhooks) validate against their schemas, the binaries of enabled tools exist and run, allowed paths and registered workspaces are readable and writable, and the temp and cache directories (and the Docker daemon under the docker executor, and the SSH remotes in the global config) work. Each check is ok, warn or fail; ready is false when any check fails. Missing binaries only warn.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path } = parseResult.data;
+        if (path && !Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const report = await runHealthCheck(path ? { path } : {});
+            return {
+                success: report.ready,
+                errors: report.checks.filter(c => c.status === 'fail').map(c => `${c.name}: ${c.message}`),
+                warnings: report.checks.filter(c => c.status === 'warn').map(c => `${c.name}: ${c.message}`),
+                output: formatHealthReport(report),
+                ...report,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
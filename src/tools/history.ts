@@ -0,0 +1,180 @@
+import { z } from 'zod';
+import { isAbsolute, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import type { Diagnostic, DiagnosticSeverity } from '../diagnostics/index.js';
+import { diffDiagnostics, historyStore, type HistoryRun } from '../history/index.js';
+
+// Run ids sort by time: 20250101T120000000Z-a1b2c3
+const RUN_ID = /^\d{8}T\d{9}Z-[0-9a-f]{6}$/;
+const COMMIT = /^[0-9a-f]{4,40}$/;
+const MAX_LISTED = 200;
+
+const historySchema = z.object({
+    path: z.string().describe('Workspace the pipeline ran in'),
+    pipeline: z.string().optional().describe('Only runs of this pipeline'),
+    commit: z.string().regex(COMMIT, 'Expected a commit sha or prefix').optional().describe('Only runs at this commit'),
+    limit: z.number().int().positive().max(200).default(20),
+});
+
+const compareSchema = z.object({
+    path: z.string().optional().describe('Workspace; needed unless base and head are run ids'),
+    head: z.string().optional().describe('Run id, or a commit whose latest run is used; defaults to the latest run'),
+    base: z.string().optional().describe('Run id, or a commit whose latest run is used; defaults to the run before head'),
+    pipeline: z.string().optional().describe('Only consider runs of this pipeline when picking runs by commit or default'),
+});
+
+function describeRun(run: HistoryRun): string {
+    const commit = run.commit ? `${run.commit.slice(0, 8)}${run.dirty ? '+dirty' : ''}` : 'no commit';
+    return `${run.id} ${commit}${run.branch ? ` (${run.branch})` : ''} ${run.pipeline ?? 'inline'}: ${run.passed ? 'passed' : 'failed'}, ${run.counts.error} error(s), ${run.counts.warning} warning(s)`;
+}
+
+function describeDiagnostic(d: Diagnostic): string {
+    return `${d.file}${d.line > 0 ? `:${d.line}` : ''} [${d.source}${d.rule ? `/${d.rule}` : ''}] ${d.severity}: ${d.message}`;
+}
+
+// "2 errors, 1 warning" by severity
+function bySeverity(diagnostics: Diagnostic[]): string {
+    const counts = new Map<DiagnosticSeverity, number>();
+    for (const d of diagnostics) counts.set(d.severity, (counts.get(d.severity) ?? 0) + 1);
+    return (['error', 'warning', 'info'] as const)
+        .filter(severity => counts.has(severity))
+        .map(severity => `${counts.get(severity)} ${severity}${counts.get(severity) === 1 ? '' : 's'}`)
+        .join(', ');
+}
+
+// "2 new golangci-lint errors" reads better than a bare count when one tool found them all
+function describeNew(diagnostics: Diagnostic[]): string {
+    if (diagnostics.length === 0) return '0 new';
+    const sources = [...new Set(diagnostics.map(d => d.source))];
+    const severities = [...new Set(diagnostics.map(d => d.severity))];
+    if (sources.length === 1 && severities.length === 1) {
+        return `${diagnostics.length} new ${sources[0]} ${severities[0]}${diagnostics.length === 1 ? '' : 's'}`;
+    }
+    return `${diagnostics.length} new (${bySeverity(diagnostics)})`;
+}
+
+function withoutDiagnostics(run: HistoryRun): Omit<HistoryRun, 'diagnostics'> {
+    const { diagnostics: _diagnostics, ...rest } = run;
+    return rest;
+}
+
+// Stored paths are relative to the workspace; tools report absolute ones
+function absolute(run: HistoryRun, diagnostics: Diagnostic[]): Diagnostic[] {
+    return diagnostics.map(d => (d.file && !isAbsolute(d.file) ? { ...d, file: resolve(run.workspace, d.file) } : d));
+}
+
+async function resolveRun(ref: string, workspace: string | undefined, pipeline: string | undefined): Promise<HistoryRun | null> {
+    if (RUN_ID.test(ref)) return historyStore.get(ref);
+    if (!COMMIT.test(ref)) throw new Error(`Expected a run id or commit sha: ${ref}`);
+    if (!workspace) throw new Error('path is needed to pick a run by commit');
+    const [run] = await historyStore.list({ workspace, commit: ref, ...(pipeline ? { pipeline } : {}), limit: 1 });
+    return run ?? null;
+}
+
+export const getHistoryTool = {
+    name: 'get_history',
+    description: 'List the recorded run_pipeline runs of a workspace, newest first: commit (marked +dirty when uncommitted changes were present), branch, pipeline, verdict and finding counts, and how each run\'s findings changed from the run before it. Filter by pipeline or commit. Use compare_runs for the findings themselves.',
+    inputSchema: zodToJsonSchema(historySchema),
+    async run(args: any) {
+        const parseResult = historySchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, pipeline, commit, limit } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            if (!historyStore.isEnabled()) {
+                return { success: false, errors: ['Run history is off (MCP_RESULTS_STORE=off)'], warnings: [], output: '' };
+            }
+            // One more, so the oldest listed run has something to compare with
+            const found = await historyStore.list({ workspace: path, ...(pipeline ? { pipeline } : {}), ...(commit ? { commit } : {}), limit: limit + 1 });
+            const runs = found.slice(0, limit).map((run, i) => {
+                const previous = commit ? undefined : found[i + 1];
+                const diff = previous ? diffDiagnostics(previous.diagnostics, run.diagnostics) : undefined;
+                return { ...withoutDiagnostics(run), ...(diff && previous ? { change: { since: previous.id, new: diff.new.length, fixed: diff.fixed.length } } : {}) };
+            });
+            const lines = runs.length > 0
+                ? runs.map(run => `${describeRun({ ...run, diagnostics: [] })}${run.change ? ` (+${run.change.new} new, -${run.change.fixed} fixed)` : ''}`)
+                : ['No runs recorded for this workspace'];
+            return { success: true, errors: [], warnings: [], output: lines.join('\n'), store: await historyStore.kind(), runs };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const compareRunsTool = {
+    name: 'compare_runs',
+    description: 'Compare the findings of two recorded run_pipeline runs and split them into new (only in head), fixed (only in base) and pre-existing (in both), e.g. "2 new golangci-lint errors, 1 fixed, 3 pre-existing". A finding is the same across runs when its file, tool, rule and message match, even if its line moved. Runs are picked by id or by commit (its latest run); by default head is the latest run and base the one before it. Fails when head has new errors, so it can gate a review on regressions rather than on baseline noise.',
+    inputSchema: zodToJsonSchema(compareSchema),
+    async run(args: any) {
+        const parseResult = compareSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, head: headRef, base: baseRef, pipeline } = parseResult.data;
+        const config = Config.getInstance();
+        if (path && !config.isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            if (!historyStore.isEnabled()) {
+                return { success: false, errors: ['Run history is off (MCP_RESULTS_STORE=off)'], warnings: [], output: '' };
+            }
+            if ((!headRef || !baseRef) && !path) {
+                return { success: false, errors: ['path is needed unless both base and head are given'], warnings: [], output: '' };
+            }
+            const recent = path && (!headRef || !baseRef) ? await historyStore.list({ workspace: path, ...(pipeline ? { pipeline } : {}), limit: 200 }) : [];
+            const head = headRef ? await resolveRun(headRef, path, pipeline) : recent[0] ?? null;
+            if (!head) return { success: false, errors: [headRef ? `No run found for ${headRef}` : 'No runs recorded for this workspace'], warnings: [], output: '' };
+            const base = baseRef
+                ? await resolveRun(baseRef, path ?? head.workspace, pipeline)
+                : recent.find(run => run.createdAt < head.createdAt || (run.createdAt === head.createdAt && run.id < head.id)) ?? null;
+            if (!base) return { success: false, errors: [baseRef ? `No run found for ${baseRef}` : `No run before ${head.id} to compare with`], warnings: [], output: '' };
+            // Runs are only shown for workspaces the caller may read
+            if (!config.isPathAllowed(head.workspace) || !config.isPathAllowed(base.workspace)) {
+                return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+            }
+
+            const diff = diffDiagnostics(base.diagnostics, head.diagnostics);
+            const newErrors = diff.new.filter(d => d.severity === 'error');
+            const warnings: string[] = [];
+            if (base.workspace !== head.workspace) warnings.push(`Comparing runs of different workspaces: ${base.workspace} and ${head.workspace}`);
+            if (base.pipeline !== head.pipeline) warnings.push(`Comparing different pipelines: ${base.pipeline ?? 'inline'} and ${head.pipeline ?? 'inline'}`);
+            const listed = (prefix: string, diagnostics: Diagnostic[]) => [
+                ...diagnostics.slice(0, MAX_LISTED).map(d => `${prefix} ${describeDiagnostic(d)}`),
+                ...(diagnostics.length > MAX_LISTED ? [`${prefix} ... ${diagnostics.length - MAX_LISTED} more`] : []),
+            ];
+            const summary = `${describeNew(diff.new)}, ${diff.fixed.length} fixed, ${diff.existing.length} pre-existing`;
+            return {
+                success: newErrors.length === 0,
+                errors: newErrors.length > 0 ? [`${newErrors.length} new error(s) since ${base.id}`] : [],
+                warnings,
+                output: [`base ${describeRun(base)}`, `head ${describeRun(head)}`, summary, ...listed('+', diff.new), ...listed('-', diff.fixed)].join('\n'),
+                base: withoutDiagnostics(base),
+                head: withoutDiagnostics(head),
+                summary,
+                counts: { new: diff.new.length, fixed: diff.fixed.length, existing: diff.existing.length },
+                // The new findings, for publish_review or export_sarif
+                diagnostics: absolute(head, diff.new),
+                fixed: absolute(base, diff.fixed),
+                existing: absolute(head, diff.existing),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,292 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import yaml from 'js-yaml';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { checkRepo } from './git.js';
+
+export type HookFramework = 'pre-commit' | 'husky';
+
+export interface HookResult {
+    framework: HookFramework;
+    id: string;
+    name: string;
+    status: 'passed' | 'failed' | 'skipped';
+    // Why a hook was skipped, e.g. "no files to check"
+    reason?: string;
+    exitCode?: number;
+    durationMs?: number;
+    // The hook rewrote files (formatters, end-of-file fixers)
+    filesModified?: boolean;
+    output: string;
+}
+
+export interface ConfiguredHook {
+    id: string;
+    name: string;
+    repo: string;
+    stages?: string[];
+}
+
+const inputSchema = z.object({
+    repoPath: z.string().describe('Repository root'),
+    framework: z.enum(['auto', 'pre-commit', 'husky']).default('auto').describe('auto runs every framework the repository configures'),
+    stage: z.enum(['pre-commit', 'pre-push', 'commit-msg', 'manual']).default('pre-commit'),
+    files: z.enum(['changed', 'staged', 'all']).default('changed')
+        .describe('pre-commit framework only: changed (staged, unstaged and untracked), staged, or all files. husky hooks decide for themselves (lint-staged reads the index)'),
+    hooks: z.array(z.string()).optional().describe('pre-commit hook ids to run; all by default'),
+    commitMessage: z.string().optional().describe('Message for commit-msg hooks'),
+    timeout: z.number().default(600000),
+});
+
+/**
+ * Hooks listed in .pre-commit-config.yaml
+ */
+export function parsePreCommitConfig(content: string): ConfiguredHook[] {
+    const parsed = yaml.load(content) as { repos?: Array<{ repo?: unknown; hooks?: unknown }> } | null;
+    const hooks: ConfiguredHook[] = [];
+    for (const repo of parsed?.repos ?? []) {
+        for (const hook of Array.isArray(repo?.hooks) ? repo.hooks : []) {
+            if (!hook || typeof hook.id !== 'string') continue;
+            hooks.push({
+                id: hook.id,
+                name: typeof hook.name === 'string' ? hook.name : hook.id,
+                repo: typeof repo.repo === 'string' ? repo.repo : 'local',
+                ...(Array.isArray(hook.stages) ? { stages: hook.stages.map(String) } : {}),
+            });
+        }
+    }
+    return hooks;
+}
+
+/**
+ * Per-hook results from `pre-commit run --verbose`: a dotted status line per
+ * hook, then "- hook id", "- exit code", "- duration" details and its output
+ */
+export function parsePreCommitOutput(output: string): { hooks: HookResult[]; warnings: string[] } {
+    const hooks: HookResult[] = [];
+    const warnings: string[] = [];
+    let current: HookResult | null = null;
+    for (const raw of output.split('\n')) {
+        const line = raw.replace(/\x1b\[[0-9;]*m/g, '').trimEnd();
+        const status = /^(.*?)\.{3,}(?:\(([^)]*)\))?(Passed|Failed|Skipped)$/.exec(line);
+        if (status) {
+            const name = status[1]!.trim();
+            current = {
+                framework: 'pre-commit',
+                id: name,
+                name,
+                status: status[3]!.toLowerCase() as HookResult['status'],
+                ...(status[2] ? { reason: status[2] } : {}),
+                output: '',
+            };
+            hooks.push(current);
+            continue;
+        }
+        if (/^\[WARNING\]/.test(line)) {
+            warnings.push(line.replace(/^\[WARNING\]\s*/, ''));
+            continue;
+        }
+        if (!current || /^\[INFO\]/.test(line)) continue;
+        const detail = /^- (hook id|exit code|duration): (.+)$/.exec(line);
+        if (detail) {
+            if (detail[1] === 'hook id') current.id = detail[2]!;
+            if (detail[1] === 'exit code') current.exitCode = Number(detail[2]);
+            if (detail[1] === 'duration') current.durationMs = Math.round(parseFloat(detail[2]!) * 1000);
+        } else if (line === '- files were modified by this hook') {
+            current.filesModified = true;
+        } else {
+            current.output += `${line}\n`;
+        }
+    }
+    for (const hook of hooks) hook.output = hook.output.trim();
+    return { hooks, warnings };
+}
+
+async function exists(path: string): Promise<boolean> {
+    return fs.access(path).then(() => true, () => false);
+}
+
+/**
+ * Husky hooks for a stage: the .husky/<stage> script (husky 5+), or the
+ * command under husky.hooks in package.json (husky 4)
+ */
+async function findHuskyHook(repoPath: string, stage: string): Promise<{ script?: string; command?: string } | null> {
+    const script = join(repoPath, '.husky', stage);
+    if (await exists(script)) return { script };
+    const pkg = await fs.readFile(join(repoPath, 'package.json'), 'utf-8').then(JSON.parse, () => null);
+    const command = pkg?.husky?.hooks?.[stage];
+    return typeof command === 'string' ? { command } : null;
+}
+
+async function changedFiles(repoPath: string, scope: 'changed' | 'staged', timeout: number): Promise<string[]> {
+    const commands = scope === 'staged'
+        ? ['git diff --cached --name-only --diff-filter=d']
+        : ['git diff HEAD --name-only --diff-filter=d', 'git ls-files --others --exclude-standard'];
+    const files = new Set<string>();
+    for (const command of commands) {
+        const result = await runCommand(command, { cwd: repoPath, timeout });
+        // A repository without commits has no HEAD to diff against
+        const listed = result.exitCode === 0 ? result.stdout : (await runCommand('git diff --cached --name-only --diff-filter=d', { cwd: repoPath, timeout })).stdout;
+        for (const file of listed.split('\n').map(f => f.trim()).filter(Boolean)) files.add(file);
+    }
+    return [...files].sort();
+}
+
+async function runPreCommit(repoPath: string, options: { stage: string; scope: 'changed' | 'staged' | 'all'; hooks?: string[]; messageFile?: string; timeout: number }) {
+    const configured = parsePreCommitConfig(await fs.readFile(join(repoPath, '.pre-commit-config.yaml'), 'utf-8'));
+    const unknown = (options.hooks ?? []).filter(id => !configured.some(h => h.id === id));
+    if (unknown.length > 0) {
+        return { error: `Unknown pre-commit hook(s): ${unknown.join(', ')}; configured: ${configured.map(h => h.id).join(', ')}` };
+    }
+    const files = options.scope === 'all' ? [] : await changedFiles(repoPath, options.scope, options.timeout);
+    if (options.scope !== 'all' && files.length === 0 && options.stage !== 'commit-msg') {
+        return { hooks: [] as HookResult[], warnings: [] as string[], commands: [] as string[], files, note: `No ${options.scope} files to check` };
+    }
+    const binary = await exists(join(repoPath, '.venv', 'bin', 'pre-commit')) ? shellQuote(join(repoPath, '.venv', 'bin', 'pre-commit')) : 'pre-commit';
+    const base = [
+        `${binary} run --verbose --color never --hook-stage ${shellQuote(options.stage)}`,
+        ...(options.messageFile ? [`--commit-msg-filename ${shellQuote(options.messageFile)}`] : []),
+        options.scope === 'all' ? '--all-files' : `--files ${files.map(shellQuote).join(' ')}`,
+    ].join(' ');
+
+    const hooks: HookResult[] = [];
+    const warnings: string[] = [];
+    const commands: string[] = [];
+    // pre-commit runs one hook id per invocation
+    for (const id of options.hooks?.length ? options.hooks : [undefined]) {
+        const command = id ? base.replace(' --verbose', ` --verbose ${shellQuote(id)}`) : base;
+        commands.push(command);
+        const result = await runCommand(command, { cwd: repoPath, timeout: options.timeout, maxBuffer: 16 * 1024 * 1024 });
+        if (result.exitCode === 127 || (/command not found|No such file/.test(result.stderr) && !result.stdout.trim())) {
+            return { error: 'pre-commit is not installed (pip install pre-commit, or install it into .venv)' };
+        }
+        const parsed = parsePreCommitOutput(`${result.stdout}\n${result.stderr}`);
+        if (parsed.hooks.length === 0 && result.exitCode !== 0) {
+            return { error: `pre-commit exited with code ${result.exitCode}: ${(result.stderr || result.stdout).trim()}` };
+        }
+        // Names come from the status line; configured names fill in hooks without --verbose ids
+        for (const hook of parsed.hooks) {
+            const known = configured.find(h => h.id === hook.id || h.name === hook.name);
+            if (known) hook.id = known.id;
+        }
+        hooks.push(...parsed.hooks);
+        warnings.push(...parsed.warnings);
+    }
+    return { hooks, warnings, commands, files };
+}
+
+async function runHusky(repoPath: string, options: { stage: string; messageFile?: string; timeout: number }) {
+    const hook = await findHuskyHook(repoPath, options.stage);
+    if (!hook) return { hooks: [] as HookResult[], warnings: [] as string[], commands: [] as string[], note: `No husky ${options.stage} hook` };
+    const message = options.messageFile ? ` ${shellQuote(options.messageFile)}` : '';
+    const command = hook.script ? `sh -e ${shellQuote(hook.script)}${message}` : `sh -ec ${shellQuote(hook.command!)} husky${message}`;
+    const warnings: string[] = [];
+    const content = hook.script ? await fs.readFile(hook.script, 'utf-8') : '';
+    if (/_\/husky\.sh/.test(content) && !(await exists(join(repoPath, '.husky', '_', 'husky.sh')))) {
+        warnings.push('The hook sources .husky/_/husky.sh, which is missing; run `npx husky install` first');
+    }
+    // Hooks call locally installed tools (lint-staged, eslint) by name, as husky arranges
+    const path = `${join(repoPath, 'node_modules', '.bin')}:${process.env.PATH ?? ''}`;
+    const result = await runCommand(command, { cwd: repoPath, timeout: options.timeout, maxBuffer: 16 * 1024 * 1024, env: { PATH: path } });
+    const output = `${result.stdout}${result.stderr ? `\n${result.stderr}` : ''}`.trim();
+    return {
+        hooks: [{
+            framework: 'husky' as const,
+            id: options.stage,
+            name: hook.script ? `.husky/${options.stage}` : `package.json husky.hooks.${options.stage}`,
+            status: result.exitCode === 0 ? 'passed' as const : 'failed' as const,
+            exitCode: result.exitCode,
+            durationMs: result.duration,
+            output,
+        }],
+        warnings,
+        commands: [command],
+    };
+}
+
+export const runHooksTool = {
+    name: 'run_hooks',
+    // Fixer hooks (formatters, end-of-file-fixer) rewrite files
+    mutates: true,
+    description: 'Run the git hooks a repository already defines, without committing: the pre-commit framework (.pre-commit-config.yaml) against the changed, staged or all files, and husky hooks (.husky/<stage> or package.json husky.hooks). Returns one result per hook (passed, failed or skipped, exit code, duration, whether it modified files, and its output).',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { repoPath, framework, stage, files: scope, hooks: hookIds, commitMessage, timeout } = parseResult.data;
+        const repoError = await checkRepo(repoPath);
+        if (repoError) {
+            return { success: false, errors: [repoError], warnings: [], output: '' };
+        }
+        if (stage === 'commit-msg' && commitMessage === undefined) {
+            return { success: false, errors: ['commitMessage is required for the commit-msg stage'], warnings: [], output: '' };
+        }
+        let messageDir: string | undefined;
+        try {
+            const hasPreCommit = await exists(join(repoPath, '.pre-commit-config.yaml'));
+            const hasHusky = await exists(join(repoPath, '.husky')) || Boolean(await findHuskyHook(repoPath, stage));
+            const frameworks: HookFramework[] = framework === 'auto'
+                ? [...(hasPreCommit ? ['pre-commit' as const] : []), ...(hasHusky ? ['husky' as const] : [])]
+                : [framework];
+            if (frameworks.length === 0) {
+                return { success: false, errors: ['No .pre-commit-config.yaml or husky hooks found'], warnings: [], output: '' };
+            }
+
+            let messageFile: string | undefined;
+            if (commitMessage !== undefined) {
+                messageDir = await fs.mkdtemp(join(tmpdir(), 'cf-hooks-'));
+                messageFile = join(messageDir, 'COMMIT_EDITMSG');
+                await fs.writeFile(messageFile, commitMessage);
+            }
+
+            const results: HookResult[] = [];
+            const warnings: string[] = [];
+            const commands: string[] = [];
+            const notes: string[] = [];
+            let files: string[] | undefined;
+            for (const name of frameworks) {
+                const run = name === 'pre-commit'
+                    ? await runPreCommit(repoPath, { stage, scope, ...(hookIds ? { hooks: hookIds } : {}), ...(messageFile ? { messageFile } : {}), timeout })
+                    : await runHusky(repoPath, { stage, ...(messageFile ? { messageFile } : {}), timeout });
+                if ('error' in run) return { success: false, errors: [run.error!], warnings, output: '' };
+                results.push(...run.hooks);
+                warnings.push(...run.warnings);
+                commands.push(...run.commands);
+                if ('files' in run) files = run.files;
+                if ('note' in run && run.note) notes.push(run.note);
+            }
+            if (frameworks.includes('husky') && scope === 'all') warnings.push('husky hooks do not take a file list; they ran as they would on commit');
+
+            const failed = results.filter(h => h.status === 'failed');
+            const modified = results.filter(h => h.filesModified);
+            if (modified.length > 0) warnings.push(`${modified.map(h => h.id).join(', ')} modified files; review and stage the changes`);
+            const summary = results.map(h => `${h.status === 'passed' ? 'PASS' : h.status === 'failed' ? 'FAIL' : 'SKIP'} ${h.framework} ${h.id}${h.reason ? ` (${h.reason})` : ''}`);
+            return {
+                success: failed.length === 0,
+                errors: failed.map(h => `${h.framework} hook ${h.id} failed${h.exitCode !== undefined ? ` (exit code ${h.exitCode})` : ''}${h.output ? `: ${h.output.split('\n').slice(0, 5).join('\n')}` : ''}`),
+                warnings,
+                output: [...summary, ...notes].join('\n'),
+                frameworks,
+                stage,
+                hooks: results,
+                commands,
+                ...(files ? { files } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            if (messageDir) await fs.rm(messageDir, { recursive: true, force: true });
+        }
+    },
+};
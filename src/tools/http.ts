@@ -33,6 +33,8 @@ function isLocalhostOrLocalIp(hostname: string): boolean {
 
 export const httpTool = {
     name: 'http',
+    // Sends requests off the host
+    dangerous: true,
     description: 'Make HTTP requests (GET, POST, etc.) and return the response.',
     inputSchema: zodToJsonSchema(httpInputSchema),
     async run(args: unknown) {
@@ -0,0 +1,212 @@
+import { z } from 'zod';
+import { promises as fs, readFileSync } from 'fs';
+import { dirname, join, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import {
+    type Diagnostic,
+    type ResourceLocator,
+    countBySeverity,
+    locateK8sResource,
+    parseKubeconformOutput,
+    parseKubeLinterOutput,
+    parseKubevalOutput,
+    parseTerraformValidateOutput,
+    parseTflintOutput,
+} from '../diagnostics/index.js';
+import { commandExists, runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+
+const k8sInputSchema = z.object({
+    path: z.string().describe('Manifest file or directory of manifests'),
+    kubernetesVersion: z.string().optional().describe('Kubernetes version to validate against, such as 1.30.0; default the latest schemas'),
+    strict: z.boolean().default(true).describe('Reject fields the schema does not define'),
+    ignoreMissingSchemas: z.boolean().default(true).describe('Skip resources without a schema (custom resources) instead of failing them'),
+    schemaLocations: z.array(z.string()).default([]).describe('Extra schema locations (URL templates or directories) for custom resources, tried after the default ones'),
+    lint: z.boolean().default(true).describe('Also run kube-linter\'s best-practice checks'),
+    timeout: z.number().default(300000),
+});
+
+const terraformInputSchema = z.object({
+    path: z.string().describe('Terraform module directory'),
+    init: z.boolean().default(true).describe('Run terraform init -backend=false first when the module has no .terraform directory; validate needs the providers'),
+    lint: z.boolean().default(true).describe('Also run tflint'),
+    timeout: z.number().default(300000),
+});
+
+// Locates resources in manifests read once per call
+function manifestLocator(): ResourceLocator {
+    const sources = new Map<string, string>();
+    return (file, kind, name, pointer) => {
+        if (!sources.has(file)) {
+            try {
+                sources.set(file, readFileSync(file, 'utf-8'));
+            } catch {
+                sources.set(file, '');
+            }
+        }
+        return locateK8sResource(sources.get(file)!, kind, name, pointer);
+    };
+}
+
+function summarize(diagnostics: Diagnostic[], checked: string): string {
+    const counts = countBySeverity(diagnostics);
+    return [
+        ...diagnostics.map(d => `${d.file}:${d.line}:${d.column}: ${d.severity}: ${d.message}${d.rule ? ` [${d.rule}]` : ''}`),
+        `${checked}: ${counts.error} error(s), ${counts.warning} warning(s)`,
+    ].join('\n');
+}
+
+export const validateK8sTool = {
+    name: 'validate_k8s',
+    binaries: ['kubeconform', 'kube-linter'],
+    cacheable: true,
+    description: 'Validate Kubernetes manifests against the API schemas with kubeconform (or kubeval when kubeconform is not installed), reporting each violation as an error diagnostic on the offending field\'s line, and lint them with kube-linter for best practices (missing resource limits, containers running as root, latest tags), as warnings. Custom resources without a schema are skipped unless schemaLocations provides one.',
+    inputSchema: zodToJsonSchema(k8sInputSchema),
+    async run(args: any) {
+        const parseResult = k8sInputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, kubernetesVersion, strict, ignoreMissingSchemas, schemaLocations, lint, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const target = resolve(path);
+            const isDirectory = (await fs.stat(target)).isDirectory();
+            const cwd = isDirectory ? target : dirname(target);
+            const locate = manifestLocator();
+            const diagnostics: Diagnostic[] = [];
+            const warnings: string[] = [];
+            const errors: string[] = [];
+
+            if (await commandExists('kubeconform')) {
+                let command = 'kubeconform -output json';
+                if (strict) command += ' -strict';
+                if (ignoreMissingSchemas) command += ' -ignore-missing-schemas';
+                if (kubernetesVersion) command += ` -kubernetes-version ${shellQuote(kubernetesVersion)}`;
+                if (schemaLocations.length > 0) command += ` -schema-location default${schemaLocations.map(l => ` -schema-location ${shellQuote(l)}`).join('')}`;
+                const result = await runCommand(`${command} ${shellQuote(target)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                const found = parseKubeconformOutput(result.stdout, cwd, locate);
+                // Exit code 1 means invalid resources; anything else without a report is a failed run
+                if (result.exitCode !== 0 && found.length === 0 && !result.stdout.trim().startsWith('{')) errors.push(`kubeconform failed: ${(result.stderr || result.stdout).trim()}`);
+                diagnostics.push(...found);
+            } else if (await commandExists('kubeval')) {
+                let command = 'kubeval -o json';
+                if (strict) command += ' --strict';
+                if (ignoreMissingSchemas) command += ' --ignore-missing-schemas';
+                if (kubernetesVersion) command += ` --kubernetes-version ${shellQuote(kubernetesVersion)}`;
+                for (const location of schemaLocations) command += ` --additional-schema-locations ${shellQuote(location)}`;
+                const result = await runCommand(`${command} ${isDirectory ? '-d ' : ''}${shellQuote(target)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                const found = parseKubevalOutput(result.stdout, cwd, locate);
+                if (result.exitCode !== 0 && found.length === 0 && !result.stdout.trim().startsWith('[')) errors.push(`kubeval failed: ${(result.stderr || result.stdout).trim()}`);
+                diagnostics.push(...found);
+            } else {
+                warnings.push('Neither kubeconform nor kubeval is installed, so the manifests were not validated against the schemas');
+            }
+
+            if (lint) {
+                if (await commandExists('kube-linter')) {
+                    // kube-linter picks up .kube-linter.yaml from the directory it runs in
+                    const result = await runCommand(`kube-linter lint --format json ${shellQuote(target)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                    const found = parseKubeLinterOutput(result.stdout, cwd, locate);
+                    if (result.exitCode !== 0 && found.length === 0) errors.push(`kube-linter failed: ${(result.stderr || result.stdout).trim()}`);
+                    diagnostics.push(...found);
+                } else {
+                    warnings.push('kube-linter is not installed, so the manifests were not linted');
+                }
+            }
+
+            const counts = countBySeverity(diagnostics);
+            if (counts.error > 0) errors.push(`${counts.error} manifest error(s)`);
+            return {
+                success: errors.length === 0,
+                errors,
+                warnings,
+                output: summarize(diagnostics, path),
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const validateTerraformTool = {
+    name: 'validate_terraform',
+    binaries: ['terraform', 'tflint'],
+    // terraform init writes .terraform and .terraform.lock.hcl into the module
+    mutates: (args: any) => args?.init !== false,
+    description: 'Validate a Terraform (or OpenTofu) module with terraform validate, after terraform init -backend=false when its providers are not installed yet, and lint it with tflint (installing the plugins .tflint.hcl asks for). Returns standard diagnostics with file, line and column: validate\'s errors and warnings, and tflint\'s rule findings at their configured severity.',
+    inputSchema: zodToJsonSchema(terraformInputSchema),
+    async run(args: any) {
+        const parseResult = terraformInputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, init, lint, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const target = resolve(path);
+            const cwd = (await fs.stat(target)).isDirectory() ? target : dirname(target);
+            const terraform = await commandExists('terraform') ? 'terraform' : await commandExists('tofu') ? 'tofu' : null;
+            if (!terraform) return { success: false, errors: ['Neither terraform nor tofu is installed'], warnings: [], output: '' };
+            const diagnostics: Diagnostic[] = [];
+            const warnings: string[] = [];
+            const errors: string[] = [];
+
+            const initialized = await fs.access(join(cwd, '.terraform')).then(() => true, () => false);
+            if (init && !initialized) {
+                const result = await runCommand(`${terraform} init -backend=false -input=false -no-color`, { cwd, timeout });
+                if (result.exitCode !== 0) {
+                    return { success: false, errors: [`${terraform} init failed: ${(result.stderr || result.stdout).trim()}`], warnings: [], output: result.stdout };
+                }
+            }
+            const validate = await runCommand(`${terraform} validate -json -no-color`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+            if (!validate.stdout.trim().startsWith('{')) {
+                errors.push(`${terraform} validate failed: ${(validate.stderr || validate.stdout).trim()}`);
+            }
+            diagnostics.push(...parseTerraformValidateOutput(validate.stdout, cwd));
+
+            if (lint) {
+                if (await commandExists('tflint')) {
+                    if (await fs.access(join(cwd, '.tflint.hcl')).then(() => true, () => false)) {
+                        const plugins = await runCommand('tflint --init', { cwd, timeout });
+                        if (plugins.exitCode !== 0) warnings.push(`tflint --init failed: ${(plugins.stderr || plugins.stdout).trim()}`);
+                    }
+                    // Exit code 2 means issues were found
+                    const result = await runCommand('tflint --format json', { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                    if (!result.stdout.trim().startsWith('{')) errors.push(`tflint failed: ${(result.stderr || result.stdout).trim()}`);
+                    diagnostics.push(...parseTflintOutput(result.stdout, cwd));
+                } else {
+                    warnings.push('tflint is not installed, so the module was not linted');
+                }
+            }
+
+            const counts = countBySeverity(diagnostics);
+            if (counts.error > 0) errors.push(`${counts.error} Terraform error(s)`);
+            return {
+                success: errors.length === 0,
+                errors,
+                warnings,
+                output: summarize(diagnostics, path),
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
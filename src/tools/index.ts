@@ -1,28 +1,169 @@
-import { typescriptTool } from './typescript.js';
+import { typescriptTool, tscCheckTool } from './typescript.js';
 import { javascriptTool } from './javascript.js';
-import { pythonTool } from './python.js';
+import { eslintTool } from './eslint.js';
+import { pythonTool, pythonTypecheckTool, pythonTestTool } from './python.js';
+import { ruffCheckTool } from './ruff.js';
 import { goTool } from './go.js';
+import { golangciLintTool } from './golangci.js';
+import { goBuildMatrixTool } from './buildmatrix.js';
+import { goModCheckTool } from './gomod.js';
+import { findSymbolTool, findReferencesTool, gotoDefinitionTool } from './gopls.js';
+import { goAstQueryTool } from './goast.js';
+import { codeMetricsTool } from './complexity.js';
+import { findDuplicatesTool } from './duplicates.js';
+import { checkNamingTool } from './naming.js';
+import { apiDiffTool } from './apidiff.js';
+import { validateMigrationsTool } from './migrations.js';
+import { testServicesTool } from './services.js';
+import { rustTool } from './rust.js';
+import { mvnCompileTool, mvnTestTool, gradleBuildTool, gradleTestTool } from './java.js';
+import { cmakeConfigureTool, cmakeBuildTool, clangTidyTool } from './cpp.js';
+import { bazelBuildTool, bazelTestTool, bazelQueryTool } from './bazel.js';
+import { goCoverageTool, pythonCoverageTool, nodeCoverageTool } from './coverage.js';
+import { detectFlakyTool } from './flaky.js';
+import { goBenchmarkTool } from './benchmark.js';
+import { goFuzzTool } from './fuzz.js';
+import { mutationTestTool } from './mutation.js';
+import { profileRunTool } from './profile.js';
+import { binaryReportTool } from './binsize.js';
+import { goVulncheckTool, npmAuditTool, pipAuditTool } from './vulns.js';
+import { licenseCheckTool } from './licenses.js';
+import { findUnusedTool } from './unused.js';
+import { dependencyGraphTool } from './depgraph.js';
+import { checkArchitectureTool } from './architecture.js';
+import { checkRulesTool } from './rules.js';
+import { checkGeneratedTool } from './generated.js';
+import { formatCodeTool } from './format.js';
 import { makeTool, listMakeCommandsTool } from './make.js';
-import { npmTool, listNpmScriptsTool, checkNpmDependencyTool } from './npm.js';
-import { gitTool } from './git.js';
+import { taskRunnerTool } from './tasks.js';
+import { npmTool, listNpmScriptsTool, checkNpmDependencyTool, nodeTestTool } from './npm.js';
+import { gitTool, gitDiffTool, gitStatusTool, gitBlameTool } from './git.js';
+import { ownersForPathTool } from './codeowners.js';
+import { validateCommitMessageTool } from './commits.js';
+import { feedbackChangedTool } from './changed.js';
+import { runHooksTool } from './hooks.js';
+import { publishReviewTool } from './review.js';
+import { exportSarifTool } from './sarif.js';
+import { exportJUnitTool } from './junit.js';
+import { watchWorkspaceTool } from './watch.js';
+import { getRunResultTool } from './runs.js';
+import { getOutputPageTool } from './output.js';
+import { runPipelineTool } from './pipeline.js';
+import { fullRepoCheckTool } from './repocheck.js';
+import { getHistoryTool, compareRunsTool } from './history.js';
+import { createBaselineTool } from './baseline.js';
+import { runCommandTool } from './command.js';
+import { evalSnippetTool } from './eval.js';
 import { uvInitTool, uvAddTool, uvRunTool, uvLockTool, uvSyncTool, uvVenvTool } from './uv.js';
 import { httpTool } from './http.js';
 import { dockerTool } from './docker.js';
+import { dockerBuildTool } from './dockerbuild.js';
+import { validateK8sTool, validateTerraformTool } from './iac.js';
+import { lintOpenApiTool } from './openapi.js';
+import { lintProtoTool } from './proto.js';
+import { checkDocsTool } from './docs.js';
+import { checkExamplesTool } from './examples.js';
 import { editor } from './editor.js';
+import { applyChangesTool, applyPatchTool } from './patch.js';
+import { scaffoldProjectTool } from './scaffold.js';
 import { filesystem } from './filesystem.js';
 import { find } from './find.js';
+import { listTreeTool, searchFilesTool } from './navigation.js';
+import { buildContextTool } from './context.js';
+import { semanticSearchTool } from './semantic.js';
+import { getConfigTool } from './config.js';
+import { inspectEnvironmentTool } from './environment.js';
+import { healthCheckTool } from './health.js';
+import { describeToolsTool } from './describe.js';
+import { getAuditLogTool } from './audit.js';
+import { getMetricsTool } from './metrics.js';
+import { cancelExecutionTool } from './cancel.js';
+import { listSnapshotsTool, revertToSnapshotTool } from './snapshots.js';
+import { explainFailureTool } from './explain.js';
+import { registerWorkspaceTool, listWorkspacesTool, unregisterWorkspaceTool, cloneWorkspaceTool } from './workspaces.js';
+import { listProjectsTool } from './projects.js';
 
 export const allTools = [
     typescriptTool,
+    tscCheckTool,
     javascriptTool,
+    eslintTool,
     pythonTool,
+    pythonTypecheckTool,
+    pythonTestTool,
+    ruffCheckTool,
     goTool,
+    golangciLintTool,
+    goBuildMatrixTool,
+    goModCheckTool,
+    findSymbolTool,
+    findReferencesTool,
+    gotoDefinitionTool,
+    goAstQueryTool,
+    codeMetricsTool,
+    findDuplicatesTool,
+    checkNamingTool,
+    apiDiffTool,
+    validateMigrationsTool,
+    testServicesTool,
+    rustTool,
+    mvnCompileTool,
+    mvnTestTool,
+    gradleBuildTool,
+    gradleTestTool,
+    cmakeConfigureTool,
+    cmakeBuildTool,
+    clangTidyTool,
+    bazelBuildTool,
+    bazelTestTool,
+    bazelQueryTool,
+    goCoverageTool,
+    pythonCoverageTool,
+    nodeCoverageTool,
+    detectFlakyTool,
+    goBenchmarkTool,
+    goFuzzTool,
+    mutationTestTool,
+    profileRunTool,
+    binaryReportTool,
+    goVulncheckTool,
+    npmAuditTool,
+    pipAuditTool,
+    licenseCheckTool,
+    findUnusedTool,
+    dependencyGraphTool,
+    checkArchitectureTool,
+    checkRulesTool,
+    checkGeneratedTool,
+    formatCodeTool,
     makeTool,
     listMakeCommandsTool,
+    taskRunnerTool,
     npmTool,
     listNpmScriptsTool,
     checkNpmDependencyTool,
+    nodeTestTool,
     gitTool,
+    gitDiffTool,
+    gitStatusTool,
+    gitBlameTool,
+    ownersForPathTool,
+    validateCommitMessageTool,
+    feedbackChangedTool,
+    runHooksTool,
+    publishReviewTool,
+    exportSarifTool,
+    exportJUnitTool,
+    watchWorkspaceTool,
+    getRunResultTool,
+    getOutputPageTool,
+    runPipelineTool,
+    fullRepoCheckTool,
+    getHistoryTool,
+    compareRunsTool,
+    createBaselineTool,
+    runCommandTool,
+    evalSnippetTool,
     uvInitTool,
     uvAddTool,
     uvRunTool,
@@ -31,9 +172,38 @@ export const allTools = [
     uvVenvTool,
     httpTool,
     dockerTool,
+    dockerBuildTool,
+    validateK8sTool,
+    validateTerraformTool,
+    lintOpenApiTool,
+    lintProtoTool,
+    checkDocsTool,
+    checkExamplesTool,
     editor,
+    applyChangesTool,
+    applyPatchTool,
+    scaffoldProjectTool,
     filesystem,
     find,
+    listTreeTool,
+    searchFilesTool,
+    buildContextTool,
+    semanticSearchTool,
+    getConfigTool,
+    inspectEnvironmentTool,
+    healthCheckTool,
+    describeToolsTool,
+    getAuditLogTool,
+    getMetricsTool,
+    cancelExecutionTool,
+    listSnapshotsTool,
+    revertToSnapshotTool,
+    explainFailureTool,
+    registerWorkspaceTool,
+    listWorkspacesTool,
+    unregisterWorkspaceTool,
+    cloneWorkspaceTool,
+    listProjectsTool,
 ];
 
 export function registerTools(server: { registerTool: (tool: any) => void }) {
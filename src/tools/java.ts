@@ -0,0 +1,269 @@
+import { z } from 'zod';
+import { runCommand } from '../utils/command.js';
+import Config from '../config/index.js';
+import { promises as fs } from 'fs';
+import { dirname, join, relative } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
+import {
+    type Diagnostic,
+    type TestCaseResult,
+    parseJavaCompilerOutput,
+    parseJUnitXml,
+    toTestReport,
+    describeTestFailure,
+    countBySeverity,
+} from '../diagnostics/index.js';
+
+// Report directories that hold JUnit XML, relative to a module root
+const REPORT_DIRS = ['target/surefire-reports', 'target/failsafe-reports', 'build/test-results'];
+const SKIPPED_DIRS = new Set(['.git', 'node_modules', 'src', '.gradle', '.idea', '.mvn']);
+const TEST_SOURCE_DIRS = ['src/test/java', 'src/test/kotlin', 'src/integrationTest/java', 'src/it/java'];
+const MAX_REPORT_DEPTH = 8;
+
+const mavenSchema = z.object({
+    projectPath: z.string().describe('Directory containing pom.xml'),
+    profiles: z.array(z.string()).default([]).describe('Maven profiles to activate (-P)'),
+    modules: z.array(z.string()).default([]).describe('Only build these modules and what they need (-pl ... -am)'),
+    offline: z.boolean().default(false),
+    args: z.array(z.string()).default([]).describe('Extra Maven arguments'),
+    timeout: z.number().default(600000),
+});
+
+const mvnCompileSchema = mavenSchema.extend({
+    includeTests: z.boolean().default(true).describe('Also compile test sources (test-compile)'),
+});
+
+const mvnTestSchema = mavenSchema.extend({
+    test: z.string().optional().describe('Surefire test filter, e.g. "FooTest" or "FooTest#bar"'),
+});
+
+const gradleSchema = z.object({
+    projectPath: z.string().describe('Directory containing settings.gradle(.kts) or build.gradle(.kts)'),
+    args: z.array(z.string()).default([]).describe('Extra Gradle arguments'),
+    offline: z.boolean().default(false),
+    timeout: z.number().default(600000),
+});
+
+const gradleBuildSchema = gradleSchema.extend({
+    tasks: z.array(z.string()).default(['build']),
+    skipTests: z.boolean().default(false).describe('Exclude the test task (-x test)'),
+});
+
+const gradleTestSchema = gradleSchema.extend({
+    task: z.string().default('test'),
+    tests: z.string().optional().describe('Gradle test filter, e.g. "com.acme.FooTest" or "*FooTest.bar"'),
+});
+
+function validationFailure(error: z.ZodError) {
+    return {
+        success: false,
+        errors: error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+        warnings: [],
+        output: ''
+    };
+}
+
+async function exists(path: string): Promise<boolean> {
+    return fs.access(path).then(() => true, () => false);
+}
+
+// Prefer the project's wrapper so the pinned Maven/Gradle version is used
+async function buildToolBinary(projectPath: string, wrapper: string, fallback: string): Promise<string> {
+    return (await exists(join(projectPath, wrapper))) ? `./${wrapper}` : fallback;
+}
+
+/**
+ * JUnit XML reports under projectPath written at or after `since` (ms), so
+ * stale reports from earlier runs are ignored
+ */
+export async function findJUnitReports(projectPath: string, since: number): Promise<string[]> {
+    const reports: string[] = [];
+    const walk = async (dir: string, depth: number) => {
+        if (depth > MAX_REPORT_DEPTH) return;
+        let entries;
+        try {
+            entries = await fs.readdir(dir, { withFileTypes: true });
+        } catch {
+            return;
+        }
+        const rel = relative(projectPath, dir).split('\\').join('/');
+        const inReportDir = REPORT_DIRS.some(reportDir => rel === reportDir || rel.endsWith(`/${reportDir}`) || rel.includes(`${reportDir}/`));
+        for (const entry of entries) {
+            const fullPath = join(dir, entry.name);
+            if (entry.isDirectory()) {
+                if (!SKIPPED_DIRS.has(entry.name)) await walk(fullPath, depth + 1);
+            } else if (inReportDir && /^TEST-.*\.xml$/.test(entry.name)) {
+                const stats = await fs.stat(fullPath);
+                if (stats.mtimeMs >= since) reports.push(fullPath);
+            }
+        }
+    };
+    await walk(projectPath, 0);
+    return reports.sort();
+}
+
+// Module root for a report in <module>/target/surefire-reports or <module>/build/test-results/<task>
+function moduleRootOf(reportPath: string): string {
+    const dir = dirname(reportPath);
+    return /[\\/]build[\\/]test-results[\\/]/.test(reportPath) ? dirname(dirname(dirname(dir))) : dirname(dirname(dir));
+}
+
+/**
+ * Parse reports and point failure locations at the test sources when they can be found
+ */
+export async function collectTestResults(reportPaths: string[]): Promise<TestCaseResult[]> {
+    const tests: TestCaseResult[] = [];
+    for (const reportPath of reportPaths) {
+        const moduleRoot = moduleRootOf(reportPath);
+        for (const test of parseJUnitXml(await fs.readFile(reportPath, 'utf-8'))) {
+            if (test.file) {
+                for (const sourceDir of TEST_SOURCE_DIRS) {
+                    const candidate = join(moduleRoot, sourceDir, test.file);
+                    if (await exists(candidate)) {
+                        test.file = candidate;
+                        break;
+                    }
+                }
+            }
+            tests.push(test);
+        }
+    }
+    return tests;
+}
+
+/**
+ * Run a Maven/Gradle command and shape the result: compiler diagnostics from
+ * the output, test results from the JUnit reports it wrote
+ */
+async function runJavaBuild(command: string, projectPath: string, timeout: number, collectTests: boolean) {
+    const started = Date.now();
+    const result = await runCommand(command, { cwd: projectPath, timeout, maxBuffer: 32 * 1024 * 1024 });
+    const output = `${result.stdout}\n${result.stderr}`;
+    const diagnostics: Diagnostic[] = parseJavaCompilerOutput(output, projectPath);
+    const counts = countBySeverity(diagnostics);
+    const feedback: Record<string, any> = {
+        success: result.exitCode === 0,
+        errors: diagnostics.filter(d => d.severity === 'error').map(d => `${d.file}:${d.line}:${d.column}: ${d.message}`),
+        warnings: diagnostics.filter(d => d.severity !== 'error').map(d => `${d.file}:${d.line}:${d.column}: ${d.message}`),
+        output: result.stdout,
+        command,
+        diagnostics,
+        summary: counts,
+    };
+    if (collectTests) {
+        // Report mtimes have second granularity on some filesystems
+        const tests = toTestReport(await collectTestResults(await findJUnitReports(projectPath, started - 2000)));
+        feedback.tests = tests;
+        feedback.errors.push(...tests.failures.map(describeTestFailure));
+    }
+    if (result.exitCode !== 0 && feedback.errors.length === 0) {
+        // Failed for a reason we could not parse (dependency resolution, plugin error, ...)
+        const errorLines = output.split('\n').filter(line => /^\[ERROR\]|FAILURE:|^\* What went wrong/.test(line)).slice(0, 20);
+        feedback.errors.push(errorLines.length > 0 ? errorLines.join('\n') : `Exited with code ${result.exitCode}`);
+    }
+    return feedback;
+}
+
+function mavenFlags(options: z.infer<typeof mavenSchema>): string {
+    const flags = ['--batch-mode', '--no-transfer-progress', '-Dstyle.color=never'];
+    if (options.offline) flags.push('--offline');
+    if (options.profiles.length > 0) flags.push(`-P${shellQuote(options.profiles.join(','))}`);
+    if (options.modules.length > 0) flags.push('-pl', shellQuote(options.modules.join(',')), '-am');
+    flags.push(...options.args.map(shellQuote));
+    return flags.join(' ');
+}
+
+function gradleFlags(options: z.infer<typeof gradleSchema>): string {
+    const flags = ['--console=plain'];
+    if (options.offline) flags.push('--offline');
+    flags.push(...options.args.map(shellQuote));
+    return flags.join(' ');
+}
+
+export const mvnCompileTool = {
+    name: 'mvn_compile',
+    description: 'Compile a Maven project (compile or test-compile) and return javac/kotlinc errors as structured diagnostics. Uses ./mvnw when present.',
+    inputSchema: zodToJsonSchema(mvnCompileSchema),
+    async run(args: any) {
+        const parseResult = mvnCompileSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const options = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(options.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const mvn = await buildToolBinary(options.projectPath, 'mvnw', 'mvn');
+            const phase = options.includeTests ? 'test-compile' : 'compile';
+            return await runJavaBuild(`${mvn} ${mavenFlags(options)} ${phase}`, options.projectPath, options.timeout, false);
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const mvnTestTool = {
+    name: 'mvn_test',
+    description: 'Run `mvn test` and return compiler diagnostics plus per-test results parsed from the surefire XML reports (status, duration, message, stack trace, source location of failures).',
+    inputSchema: zodToJsonSchema(mvnTestSchema),
+    async run(args: any) {
+        const parseResult = mvnTestSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const options = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(options.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const mvn = await buildToolBinary(options.projectPath, 'mvnw', 'mvn');
+            // With a filter, modules without matching tests must not fail the build
+            const filter = options.test ? ` -Dtest=${shellQuote(options.test)} -Dsurefire.failIfNoSpecifiedTests=false -DfailIfNoTests=false` : '';
+            return await runJavaBuild(`${mvn} ${mavenFlags(options)}${filter} test`, options.projectPath, options.timeout, true);
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const gradleBuildTool = {
+    name: 'gradle_build',
+    description: 'Run Gradle build tasks (default: build) and return compiler diagnostics, plus test results from the JUnit XML reports when tests ran. Uses ./gradlew when present.',
+    inputSchema: zodToJsonSchema(gradleBuildSchema),
+    async run(args: any) {
+        const parseResult = gradleBuildSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const options = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(options.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const gradle = await buildToolBinary(options.projectPath, 'gradlew', 'gradle');
+            const tasks = options.tasks.map(shellQuote).join(' ');
+            const command = `${gradle} ${gradleFlags(options)} ${tasks}${options.skipTests ? ' -x test' : ''}`;
+            return await runJavaBuild(command, options.projectPath, options.timeout, !options.skipTests);
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const gradleTestTool = {
+    name: 'gradle_test',
+    description: 'Run a Gradle test task and return compiler diagnostics plus per-test results parsed from build/test-results (status, duration, message, stack trace, source location of failures).',
+    inputSchema: zodToJsonSchema(gradleTestSchema),
+    async run(args: any) {
+        const parseResult = gradleTestSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const options = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(options.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const gradle = await buildToolBinary(options.projectPath, 'gradlew', 'gradle');
+            const filter = options.tests ? ` --tests ${shellQuote(options.tests)}` : '';
+            const command = `${gradle} ${gradleFlags(options)} ${shellQuote(options.task)}${filter}`;
+            return await runJavaBuild(command, options.projectPath, options.timeout, true);
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -11,6 +11,8 @@ const inputSchema = z.object({
 
 export const javascriptTool = {
     name: 'javascript',
+    binaries: ['node'],
+    cacheable: true,
     description: 'Run JavaScript code and return the output, errors, and execution time.',
     inputSchema: zodToJsonSchema(inputSchema),
     async run(args: any) {
@@ -0,0 +1,85 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { summarizeTests, type TestCaseResult } from '../diagnostics/index.js';
+import { toJUnitXml } from '../diagnostics/junit.js';
+import { runPipelineTool } from './pipeline.js';
+
+const testCaseSchema = z.object({
+    suite: z.string(),
+    name: z.string(),
+    status: z.enum(['passed', 'failed', 'error', 'skipped']),
+    durationMs: z.number().nonnegative().default(0),
+    message: z.string().optional(),
+    details: z.string().optional(),
+    file: z.string().optional(),
+    line: z.number().int().positive().optional(),
+    output: z.string().optional(),
+});
+
+const inputSchema = z.object({
+    path: z.string().describe('Project directory the pipeline runs in and outputFile is relative to'),
+    tests: z.array(testCaseSchema).optional().describe('Test cases to export, as returned in tests.results of the go, npm, python and java tools'),
+    pipeline: z.string().optional().describe('Run this pipeline from .code-feedback.yaml and export the tests its steps ran (when tests are not given)'),
+    name: z.string().optional().describe('Name of the <testsuites> root; defaults to code-feedback'),
+    outputFile: z.string().optional().describe('Write the XML here instead of only returning it'),
+});
+
+export const exportJUnitTool = {
+    name: 'export_junit',
+    mutates: (args: any) => typeof args?.pipeline === 'string' || typeof args?.outputFile === 'string',
+    description: 'Convert test results from the test tools (Go, Jest/Vitest, pytest, JUnit) to JUnit XML so CI systems can show them natively: pass tests.results from another tool, or name a pipeline to run and export. Optionally writes the XML to a file.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, tests: given, pipeline, name, outputFile } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        if (!given && !pipeline) {
+            return { success: false, errors: ['Pass tests or a pipeline to run'], warnings: [], output: '' };
+        }
+        const target = outputFile ? resolve(path, outputFile) : undefined;
+        if (target && !config.isPathWritable(target)) {
+            return { success: false, errors: [`Path not writable: ${outputFile}`], warnings: [], output: '' };
+        }
+        try {
+            const warnings: string[] = [];
+            let tests = (given ?? []) as TestCaseResult[];
+            if (!given && pipeline) {
+                const result: any = await runPipelineTool.run({ path, pipeline });
+                if (!Array.isArray(result.steps)) return { ...result, success: false };
+                tests = result.tests?.results ?? [];
+                if (tests.length === 0) warnings.push(`No step of pipeline ${pipeline} reported test results`);
+            }
+            const xml = toJUnitXml(tests, name ? { name } : {});
+            if (target) {
+                await fs.mkdir(dirname(target), { recursive: true });
+                await fs.writeFile(target, xml);
+            }
+            const summary = summarizeTests(tests);
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: `${summary.total} tests (${summary.failed} failed, ${summary.errored} errored, ${summary.skipped} skipped)${target ? ` written to ${target}` : ''}`,
+                ...(target ? { outputFile: target } : {}),
+                summary,
+                xml,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,242 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, join, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig } from '../config/project.js';
+import { runCommand } from '../utils/command.js';
+import { findUp } from '../utils/paths.js';
+import { shellQuote } from '../utils/shell.js';
+import { detectEcosystem } from './depgraph.js';
+import { findVenvPython } from './python.js';
+
+export interface DependencyLicense {
+    package: string;
+    version?: string;
+    // As the scanner reported it: an SPDX id, an expression such as "MIT OR Apache-2.0", or "UNKNOWN"
+    license: string;
+}
+
+export type LicenseVerdict = 'allowed' | 'denied' | 'unlisted' | 'unknown' | 'ignored';
+
+export interface LicensePolicy {
+    allow?: string[];
+    deny?: string[];
+    ignore?: string[];
+}
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Go module, npm project or Python project directory'),
+    ecosystem: z.enum(['auto', 'go', 'npm', 'python']).default('auto'),
+    allow: z.array(z.string()).optional().describe('Allowed SPDX ids; replaces licenses.allow from .code-feedback.yaml'),
+    deny: z.array(z.string()).optional().describe('Denied SPDX ids, added to licenses.deny from .code-feedback.yaml'),
+    timeout: z.number().default(300000),
+});
+
+/**
+ * Parse `go-licenses report` CSV: "module,license URL,license" per line
+ */
+export function parseGoLicensesReport(output: string): DependencyLicense[] {
+    const licenses: DependencyLicense[] = [];
+    for (const line of output.split('\n')) {
+        const fields = line.trim().split(',');
+        if (fields.length < 3 || !fields[0]) continue;
+        licenses.push({ package: fields[0], license: fields[fields.length - 1]!.trim() || 'UNKNOWN' });
+    }
+    return licenses;
+}
+
+/**
+ * Parse `license-checker --json`: { "name@version": { licenses: string | string[] } }
+ */
+export function parseLicenseCheckerJson(output: string): DependencyLicense[] {
+    const data = JSON.parse(output) as Record<string, { licenses?: string | string[] }>;
+    return Object.entries(data).map(([key, info]) => {
+        // Scoped names start with @, so the version is after the last one
+        const at = key.lastIndexOf('@');
+        const [name, version] = at > 0 ? [key.slice(0, at), key.slice(at + 1)] : [key, ''];
+        const raw = Array.isArray(info.licenses) ? info.licenses.join(' OR ') : info.licenses ?? 'UNKNOWN';
+        // license-checker marks licenses it guessed from the license text with a trailing *
+        return { package: name, ...(version ? { version } : {}), license: raw.replace(/\*$/, '').replace(/^\((.*)\)$/, '$1') || 'UNKNOWN' };
+    });
+}
+
+/**
+ * Parse `pip-licenses --format=json`: [{ Name, Version, License }]
+ */
+export function parsePipLicensesJson(output: string): DependencyLicense[] {
+    const data = JSON.parse(output) as Array<{ Name?: string; Version?: string; License?: string }>;
+    return data.filter(d => d.Name).map(d => ({
+        package: d.Name!,
+        ...(d.Version ? { version: d.Version } : {}),
+        // Classifier-derived licenses come back ";"-separated
+        license: (d.License ?? '').split(';').map(l => l.trim()).filter(Boolean).join(' OR ') || 'UNKNOWN',
+    }));
+}
+
+function normalize(license: string): string {
+    return license.trim().toLowerCase();
+}
+
+function isUnknown(license: string): boolean {
+    return ['', 'unknown', 'unlicensed', 'custom', 'see license'].includes(normalize(license)) || normalize(license).startsWith('custom:');
+}
+
+// "(A OR B)" -> "A OR B", but "(A) AND (B)" is left alone
+function stripParens(expression: string): string {
+    while (expression.startsWith('(') && expression.endsWith(')')) {
+        let depth = 0;
+        const inner = expression.slice(1, -1);
+        for (const char of inner) {
+            depth += char === '(' ? 1 : char === ')' ? -1 : 0;
+            if (depth < 0) return expression;
+        }
+        expression = inner.trim();
+    }
+    return expression;
+}
+
+// Split on operator tokens outside parentheses
+function splitTopLevel(expression: string, operator: RegExp): string[] {
+    const tokens = expression.replace(/([()/])/g, ' $1 ').split(/\s+/).filter(Boolean);
+    const parts: string[][] = [[]];
+    let depth = 0;
+    for (const token of tokens) {
+        if (token === '(') depth++;
+        if (token === ')') depth--;
+        if (depth < 0) return [expression];
+        if (depth === 0 && operator.test(token)) parts.push([]);
+        else parts[parts.length - 1]!.push(token);
+    }
+    return depth === 0 ? parts.map(p => p.join(' ').replace(/\( /g, '(').replace(/ \)/g, ')')).filter(Boolean) : [expression];
+}
+
+function pick(verdicts: LicenseVerdict[], preference: LicenseVerdict[]): LicenseVerdict {
+    return preference.find(v => verdicts.includes(v)) ?? 'unknown';
+}
+
+/**
+ * Judge one license (or SPDX expression) against the policy. "A OR B" needs
+ * one acceptable option and "A AND B" needs every part acceptable; a denied
+ * license wins over an allowed one. Without an allow list, anything not
+ * denied passes.
+ */
+export function evaluateLicense(license: string, policy: LicensePolicy): LicenseVerdict {
+    const allow = new Set((policy.allow ?? []).map(normalize));
+    const deny = new Set((policy.deny ?? []).map(normalize));
+    const judge = (expression: string): LicenseVerdict => {
+        const trimmed = stripParens(expression.trim());
+        // OR binds looser than AND, so it is split first
+        const options = splitTopLevel(trimmed, /^(?:OR|\/)$/i);
+        if (options.length > 1) return pick(options.map(judge), ['allowed', 'unlisted', 'unknown', 'denied']);
+        const parts = splitTopLevel(trimmed, /^AND$/i);
+        if (parts.length > 1) return pick(parts.map(judge), ['denied', 'unknown', 'unlisted', 'allowed']);
+        // "WITH" exceptions only relax a license, so the base license decides
+        const id = normalize(trimmed.split(/\s+WITH\s+/i)[0] ?? trimmed);
+        if (deny.has(id)) return 'denied';
+        if (isUnknown(id)) return 'unknown';
+        if (allow.size === 0 || allow.has(id)) return 'allowed';
+        return 'unlisted';
+    };
+    return judge(license);
+}
+
+async function scanGo(dir: string, timeout: number): Promise<{ licenses: DependencyLicense[]; error?: string }> {
+    // go-licenses logs unresolved modules to stderr and still reports the rest
+    const result = await runCommand('go-licenses report ./...', { cwd: dir, timeout, maxBuffer: 16 * 1024 * 1024 });
+    const licenses = parseGoLicensesReport(result.stdout);
+    if (result.exitCode !== 0 && licenses.length === 0) return { licenses, error: `go-licenses failed: ${(result.stderr || result.stdout).trim()}` };
+    return { licenses };
+}
+
+async function scanNpm(dir: string, timeout: number): Promise<{ licenses: DependencyLicense[]; error?: string }> {
+    const local = join(dir, 'node_modules', '.bin', 'license-checker');
+    const binary = await fs.access(local).then(() => shellQuote(local), () => 'npx --no-install license-checker');
+    const result = await runCommand(`${binary} --json --production --excludePrivatePackages`, { cwd: dir, timeout, maxBuffer: 32 * 1024 * 1024 });
+    try {
+        return { licenses: parseLicenseCheckerJson(result.stdout) };
+    } catch {
+        return { licenses: [], error: `license-checker failed: ${(result.stderr || result.stdout).trim()}` };
+    }
+}
+
+async function scanPython(dir: string, timeout: number): Promise<{ licenses: DependencyLicense[]; error?: string }> {
+    const venvPython = await findVenvPython(dir);
+    const binary = venvPython ? `${shellQuote(venvPython)} -m piplicenses` : 'pip-licenses';
+    const result = await runCommand(`${binary} --format=json --from=mixed`, { cwd: dir, timeout, maxBuffer: 16 * 1024 * 1024 });
+    try {
+        return { licenses: parsePipLicensesJson(result.stdout) };
+    } catch {
+        return { licenses: [], error: `pip-licenses failed: ${(result.stderr || result.stdout).trim()}` };
+    }
+}
+
+export const licenseCheckTool = {
+    name: 'license_check',
+    cacheable: true,
+    description: 'Resolve the licenses of a project\'s dependencies (go-licenses for Go modules, license-checker for npm, pip-licenses for Python) and check them against the allow and deny lists under `licenses` in .code-feedback.yaml. SPDX expressions are honoured: "MIT OR GPL-3.0" passes when MIT is allowed, "MIT AND GPL-3.0" fails when GPL-3.0 is denied. Denied licenses, and licenses outside a configured allow list, are violations.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(parseResult.data.projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const projectPath = resolve(parseResult.data.projectPath);
+        try {
+            const ecosystem = parseResult.data.ecosystem === 'auto' ? await detectEcosystem(projectPath) : parseResult.data.ecosystem;
+            if (!ecosystem) {
+                return { success: false, errors: ['Could not detect the project ecosystem; pass ecosystem explicitly'], warnings: [], output: '' };
+            }
+            const configured = (await getEffectiveConfig(projectPath)).config.licenses ?? {};
+            const policy: LicensePolicy = {
+                ...(parseResult.data.allow ?? configured.allow ? { allow: parseResult.data.allow ?? configured.allow } : {}),
+                deny: [...(configured.deny ?? []), ...(parseResult.data.deny ?? [])],
+                ignore: configured.ignore ?? [],
+            };
+
+            const goMod = ecosystem === 'go' ? await findUp(projectPath, 'go.mod') : null;
+            const dir = goMod ? dirname(goMod) : projectPath;
+            const scan = ecosystem === 'go' ? await scanGo(dir, timeout) : ecosystem === 'npm' ? await scanNpm(dir, timeout) : await scanPython(dir, timeout);
+            if (scan.error) return { success: false, errors: [scan.error], warnings: [], output: '' };
+
+            const ignored = new Set(policy.ignore);
+            const dependencies = scan.licenses.map(dep => ({
+                ...dep,
+                verdict: ignored.has(dep.package) ? 'ignored' as const : evaluateLicense(dep.license, policy),
+            }));
+            // Unknown licenses cannot satisfy an allow list; without one they only need a look
+            const violations = dependencies.filter(d => d.verdict === 'denied' || d.verdict === 'unlisted' || (d.verdict === 'unknown' && policy.allow));
+            const unknown = dependencies.filter(d => d.verdict === 'unknown' && !policy.allow);
+            const describe = (d: DependencyLicense & { verdict: LicenseVerdict }) => `${d.package}${d.version ? `@${d.version}` : ''} (${d.license})`;
+
+            const byLicense = new Map<string, number>();
+            for (const dep of dependencies) byLicense.set(dep.license, (byLicense.get(dep.license) ?? 0) + 1);
+            const warnings = unknown.length > 0 ? [`${unknown.length} dependency(ies) with an unknown license: ${unknown.map(describe).join(', ')}`] : [];
+            if (!policy.allow && (policy.deny ?? []).length === 0) warnings.push('No license policy: set licenses.allow or licenses.deny in .code-feedback.yaml');
+            return {
+                success: violations.length === 0,
+                errors: violations.map(d => `${describe(d)}: ${d.verdict === 'denied' ? 'license is denied' : d.verdict === 'unknown' ? 'license is unknown' : 'license is not in the allow list'}`),
+                warnings,
+                output: [
+                    `${dependencies.length} ${ecosystem} dependency(ies), ${violations.length} violation(s)`,
+                    ...[...byLicense].sort((a, b) => b[1] - a[1]).map(([license, count]) => `  ${license}: ${count}`),
+                ].join('\n'),
+                ecosystem,
+                policy,
+                summary: Object.fromEntries([...byLicense].sort((a, b) => b[1] - a[1])),
+                dependencies,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
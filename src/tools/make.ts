@@ -19,6 +19,7 @@ const listMakeCommandsInputSchema = z.object({
 
 export const makeTool = {
     name: 'make',
+    binaries: ['make'],
     description: 'Run Makefile targets and return the output, errors, and execution time.',
     inputSchema: zodToJsonSchema(inputSchema),
     async run(args: any) {
@@ -77,6 +78,7 @@ function extractMakeTargets(stdout: string): string[] {
 
 export const listMakeCommandsTool = {
     name: 'list_make_commands',
+    binaries: ['make'],
     description: 'List available make targets/commands from a Makefile',
     inputSchema: {
         type: 'object',
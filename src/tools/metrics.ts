@@ -0,0 +1,49 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { metrics, type ToolMetrics } from '../metrics/index.js';
+
+const inputSchema = z.object({
+    tool: z.string().optional().describe('Only this tool'),
+    format: z.enum(['json', 'prometheus']).default('json').describe('prometheus returns the /metrics text exposition as output'),
+});
+
+function summarize(stats: ToolMetrics): string {
+    const cached = stats.cached > 0 ? `, ${stats.cached} cached` : '';
+    const running = stats.inFlight > 0 ? `, ${stats.inFlight} in flight` : '';
+    return `${stats.tool}: ${stats.calls} call(s), ${(stats.failureRate * 100).toFixed(1)}% failed, mean ${stats.meanMs}ms, max ${stats.maxMs}ms${cached}${running}`;
+}
+
+export const getMetricsTool = {
+    name: 'get_metrics',
+    description: 'Report server metrics since it started: calls per tool by outcome (success, failure, rejected, error), failure rates, mean and max latencies, result cache hits, misses and hit ratio, and the calls executing or queued for a worker. The same numbers are served in the Prometheus format on /metrics in HTTP mode.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { tool, format } = parseResult.data;
+        try {
+            const snapshot = metrics.snapshot();
+            const tools = tool ? snapshot.tools.filter(t => t.tool === tool) : snapshot.tools;
+            if (format === 'prometheus') {
+                return { success: true, errors: [], warnings: [], output: metrics.render() };
+            }
+            const { cache, scheduler } = snapshot;
+            const lines = [
+                `Uptime: ${snapshot.uptimeSeconds}s`,
+                `Cache: ${cache.hits} hit(s), ${cache.misses} miss(es)${cache.hitRatio !== null ? ` (${(cache.hitRatio * 100).toFixed(1)}% hit ratio)` : ''}, ${cache.entries} entr(ies)`,
+                `Workers: ${scheduler.active}/${scheduler.concurrency} busy, ${scheduler.queued} queued`,
+                ...tools.map(summarize),
+            ];
+            return { success: true, errors: [], warnings: [], output: lines.join('\n'), ...snapshot, tools };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,311 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { basename, dirname, join, relative, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig } from '../config/project.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { checkMigrations, detectMigrations, findAlembicIni, gooseSection, type Migration, type MigrationSet } from '../migrations/index.js';
+import { commandExists, runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+
+const DEFAULT_POSTGRES_IMAGE = 'postgres:16-alpine';
+// Dependencies that tell which database the SQL is written for
+const POSTGRES_MARKERS = /jackc\/pgx|lib\/pq|"pg"|psycopg|asyncpg|postgres/i;
+const SQLITE_MARKERS = /go-sqlite3|modernc\.org\/sqlite|better-sqlite3|"sqlite3"|aiosqlite|sqlite/i;
+const DEPENDENCY_FILES = ['go.mod', 'package.json', 'requirements.txt', 'pyproject.toml', 'alembic.ini'];
+
+const inputSchema = z.object({
+    path: z.string().describe('Project directory; migration directories under it are found automatically'),
+    run: z.boolean().default(true).describe('Run SQL migrations up, down and up again against a throwaway database; false only checks them statically'),
+    engine: z.enum(['auto', 'sqlite', 'postgres', 'none']).default('auto').describe('Database to run SQL migrations on: sqlite (the sqlite3 CLI), postgres (a throwaway Docker container), or none; auto picks from migrations.engine in .code-feedback.yaml, else the project\'s database driver'),
+    base: z.string().default('HEAD').describe('Git ref; migrations added since it must be numbered after the ones it already has'),
+    timeout: z.number().default(300000),
+});
+
+interface SqlResult {
+    ok: boolean;
+    message?: string;
+    // Line in the SQL applied, when the database reports one
+    line?: number;
+}
+
+interface SqlEngine {
+    name: 'sqlite' | 'postgres';
+    // A fresh, empty database for the next migration set
+    reset(): Promise<void>;
+    apply(sql: string): Promise<SqlResult>;
+    close(): Promise<void>;
+}
+
+export interface MigrationStep {
+    dir: string;
+    version: string;
+    name: string;
+    direction: 'up' | 'down';
+    success: boolean;
+    error?: string;
+}
+
+async function openSqlite(workDir: string, timeout: number): Promise<SqlEngine> {
+    let database = '';
+    let count = 0;
+    const script = join(workDir, 'step.sql');
+    return {
+        name: 'sqlite',
+        async reset() {
+            database = join(workDir, `migrations-${++count}.db`);
+        },
+        async apply(sql) {
+            await fs.writeFile(script, sql);
+            const result = await runCommand(`sqlite3 -bail ${shellQuote(database)} ${shellQuote(`.read ${script}`)}`, { cwd: workDir, timeout, local: true });
+            if (result.exitCode === 0 && !/^(Parse|Runtime )?error/im.test(result.stderr)) return { ok: true };
+            const text = (result.stderr || result.stdout).trim();
+            const match = /line (\d+):\s*(.*)/.exec(text);
+            return { ok: false, message: match?.[2] ?? text, ...(match ? { line: Number(match[1]) } : {}) };
+        },
+        async close() { /* files go with the work directory */ },
+    };
+}
+
+async function openPostgres(image: string, workDir: string, timeout: number): Promise<SqlEngine> {
+    const started = await runCommand(`docker run -d --rm -e POSTGRES_PASSWORD=postgres -e POSTGRES_DB=migrations ${shellQuote(image)}`, { timeout, local: true });
+    if (started.exitCode !== 0) throw new Error(`Could not start ${image}: ${started.stderr.trim()}`);
+    const container = started.stdout.trim().split('\n').pop()!;
+    // Over TCP, so the temporary server the image runs during initialization never counts as ready
+    const psql = `docker exec ${shellQuote(container)} psql -h 127.0.0.1 -U postgres -d migrations -v ON_ERROR_STOP=1 -q`;
+    const engine: SqlEngine = {
+        name: 'postgres',
+        async reset() {
+            const result = await runCommand(`${psql} -c ${shellQuote('DROP SCHEMA public CASCADE; CREATE SCHEMA public;')}`, { timeout, local: true });
+            if (result.exitCode !== 0) throw new Error(`Could not reset the database: ${result.stderr.trim()}`);
+        },
+        async apply(sql) {
+            const script = join(workDir, 'step.sql');
+            await fs.writeFile(script, sql);
+            const copied = await runCommand(`docker cp ${shellQuote(script)} ${shellQuote(`${container}:/tmp/step.sql`)}`, { timeout, local: true });
+            if (copied.exitCode !== 0) throw new Error(`docker cp failed: ${copied.stderr.trim()}`);
+            const result = await runCommand(`${psql} -f /tmp/step.sql`, { timeout, local: true });
+            if (result.exitCode === 0) return { ok: true };
+            const match = /psql:[^:]*:(\d+):\s*(ERROR:.*)/.exec(result.stderr);
+            return { ok: false, message: match?.[2] ?? result.stderr.trim(), ...(match ? { line: Number(match[1]) } : {}) };
+        },
+        async close() {
+            await runCommand(`docker rm -f ${shellQuote(container)}`, { timeout: 60000, local: true });
+        },
+    };
+    const deadline = Date.now() + Math.min(timeout, 120000);
+    while (true) {
+        const ready = await runCommand(`${psql} -c 'select 1'`, { timeout: 10000, local: true });
+        if (ready.exitCode === 0) return engine;
+        if (Date.now() > deadline) {
+            await engine.close();
+            throw new Error(`${image} did not accept connections in time: ${ready.stderr.trim()}`);
+        }
+        await new Promise(r => setTimeout(r, 1000));
+    }
+}
+
+async function detectEngine(root: string): Promise<'sqlite' | 'postgres' | null> {
+    const text = (await Promise.all(DEPENDENCY_FILES.map(f => fs.readFile(join(root, f), 'utf-8').catch(() => '')))).join('\n');
+    if (POSTGRES_MARKERS.test(text)) return 'postgres';
+    if (SQLITE_MARKERS.test(text)) return 'sqlite';
+    return null;
+}
+
+// Files added since base, committed or not, as absolute paths
+async function addedFiles(root: string, base: string, timeout: number): Promise<Set<string>> {
+    const top = await runCommand('git rev-parse --show-toplevel', { cwd: root, timeout, local: true });
+    if (top.exitCode !== 0) return new Set();
+    const repoRoot = top.stdout.trim();
+    const [diff, untracked] = await Promise.all([
+        runCommand(`git diff --name-only --diff-filter=A ${shellQuote(base)}`, { cwd: repoRoot, timeout, local: true }),
+        runCommand('git ls-files --others --exclude-standard', { cwd: repoRoot, timeout, local: true }),
+    ]);
+    return new Set([diff, untracked].flatMap(r => (r.exitCode === 0 ? r.stdout.split('\n') : [])).filter(Boolean).map(f => resolve(repoRoot, f)));
+}
+
+function sqlFor(set: MigrationSet, migration: Migration, direction: 'up' | 'down'): { file: string; read: () => Promise<string> } | null {
+    if (set.tool === 'golang-migrate') {
+        const file = migration[direction];
+        return file ? { file, read: () => fs.readFile(file, 'utf-8') } : null;
+    }
+    return { file: migration.file, read: async () => gooseSection(await fs.readFile(migration.file, 'utf-8'), direction) };
+}
+
+/**
+ * Apply a set's migrations up, then down in reverse, then up again, on a
+ * fresh database; stops at the first failure. A missing down ends the run
+ * after the first pass, as rolling back past it is impossible.
+ */
+async function runSqlSet(set: MigrationSet, engine: SqlEngine, display: (file: string) => string): Promise<{ steps: MigrationStep[]; diagnostics: Diagnostic[]; notes: string[] }> {
+    const steps: MigrationStep[] = [];
+    const diagnostics: Diagnostic[] = [];
+    const notes: string[] = [];
+    await engine.reset();
+    const ascending = set.migrations;
+    const passes: Array<['up' | 'down', Migration[]]> = [['up', ascending], ['down', [...ascending].reverse()], ['up', ascending]];
+    for (const [direction, migrations] of passes) {
+        const missing = direction === 'down' ? migrations.find(m => !sqlFor(set, m, 'down')) : undefined;
+        if (missing) {
+            notes.push(`${display(set.dir)}: stopped after the up pass; ${basename(missing.file)} has no down migration`);
+            break;
+        }
+        for (const migration of migrations) {
+            const source = sqlFor(set, migration, direction)!;
+            const result = await engine.apply(await source.read());
+            steps.push({ dir: display(set.dir), version: migration.version, name: migration.name, direction, success: result.ok, ...(result.message ? { error: result.message } : {}) });
+            if (!result.ok) {
+                diagnostics.push({
+                    file: source.file,
+                    line: result.line ?? 1,
+                    column: 0,
+                    severity: 'error',
+                    message: `${direction} of ${migration.version}_${migration.name} failed on ${engine.name}: ${result.message}`,
+                    rule: 'sql-error',
+                    source: 'validate_migrations',
+                });
+                return { steps, diagnostics, notes };
+            }
+        }
+    }
+    return { steps, diagnostics, notes };
+}
+
+// alembic's offline mode renders every revision's SQL, which runs the scripts without a database
+async function runAlembic(set: MigrationSet, root: string, timeout: number): Promise<{ diagnostics: Diagnostic[]; notes: string[] }> {
+    const ini = await findAlembicIni(set.dir, root);
+    if (!ini) return { diagnostics: [], notes: [`${set.dir}: no alembic.ini found, so the revisions were not run`] };
+    if (!await commandExists('alembic')) return { diagnostics: [], notes: ['alembic is not installed, so the revisions were only checked statically'] };
+    const diagnostics: Diagnostic[] = [];
+    for (const [direction, target] of [['upgrade', 'head'], ['downgrade', 'head:base']] as const) {
+        const result = await runCommand(`alembic -c ${shellQuote(ini)} ${direction} ${target} --sql`, { cwd: dirname(ini), timeout });
+        if (result.exitCode === 0) continue;
+        // The innermost frame in a revision script, if the traceback has one
+        const frames = [...result.stderr.matchAll(/File "([^"]+\.py)", line (\d+)/g)].filter(m => resolve(dirname(ini), m[1]!).startsWith(set.dir));
+        const frame = frames[frames.length - 1];
+        const lastLine = result.stderr.trim().split('\n').pop() ?? `alembic ${direction} failed`;
+        diagnostics.push({
+            file: frame ? resolve(dirname(ini), frame[1]!) : ini,
+            line: frame ? Number(frame[2]) : 1,
+            column: 0,
+            severity: 'error',
+            message: `alembic ${direction} ${target} --sql failed: ${lastLine}`,
+            rule: 'script-error',
+            source: 'validate_migrations',
+        });
+        break;
+    }
+    return { diagnostics, notes: [] };
+}
+
+export const validateMigrationsTool = {
+    name: 'validate_migrations',
+    description: 'Validate database migrations. Finds golang-migrate, goose, alembic and knex migration directories and checks them statically: duplicate versions, missing up or down migrations, gaps in sequence numbers, goose annotations, alembic\'s revision graph (unknown down_revision, multiple heads), and migrations added since base but numbered before committed ones. Then runs SQL migrations up, down and up again on a throwaway SQLite database or Postgres container, reporting SQL errors with file and line, and renders alembic revisions in offline mode. Every problem is a diagnostic.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, run, base, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        let workDir: string | undefined;
+        let engine: SqlEngine | undefined;
+        try {
+            const root = resolve(path);
+            const sets = await detectMigrations(root);
+            const display = (file: string) => relative(root, file) || '.';
+            if (sets.length === 0) {
+                return { success: true, errors: [], warnings: [], output: 'No migration directories found (golang-migrate, goose, alembic or knex)', sets: [], diagnostics: [] };
+            }
+
+            const added = await addedFiles(root, base, timeout);
+            const diagnostics: Diagnostic[] = [];
+            for (const set of sets) diagnostics.push(...await checkMigrations(set, added));
+
+            const warnings: string[] = [];
+            const steps: MigrationStep[] = [];
+            const configured = (await getEffectiveConfig(path)).config.migrations ?? {};
+            const sqlSets = sets.filter(s => s.tool === 'golang-migrate' || s.tool === 'goose');
+            let engineName: 'sqlite' | 'postgres' | 'none' | null = parseResult.data.engine === 'auto' ? configured.engine ?? await detectEngine(root) : parseResult.data.engine;
+            if (run && sqlSets.length > 0) {
+                if (!engineName) {
+                    warnings.push('Could not tell which database the SQL is for; pass engine (sqlite or postgres) to run the migrations');
+                    engineName = 'none';
+                }
+                if (engineName === 'sqlite' && !await commandExists('sqlite3')) {
+                    warnings.push('sqlite3 is not installed, so the SQL migrations were not run');
+                    engineName = 'none';
+                }
+                if (engineName === 'postgres' && !await commandExists('docker')) {
+                    warnings.push('docker is not available to run a Postgres container, so the SQL migrations were not run');
+                    engineName = 'none';
+                }
+                if (engineName !== 'none') {
+                    workDir = await fs.mkdtemp(join(tmpdir(), 'cf-migrations-'));
+                    engine = engineName === 'sqlite' ? await openSqlite(workDir, timeout) : await openPostgres(configured.image ?? DEFAULT_POSTGRES_IMAGE, workDir, timeout);
+                    for (const set of sqlSets) {
+                        if (set.migrations.some(m => m.go)) {
+                            warnings.push(`${display(set.dir)} has goose migrations written in Go, which only goose can run, so it was only checked statically`);
+                            continue;
+                        }
+                        // Running a set whose versions already clash would only repeat that error
+                        if (diagnostics.some(d => d.severity === 'error' && set.migrations.some(m => m.file === d.file || m.up === d.file || m.down === d.file))) {
+                            warnings.push(`${display(set.dir)} was not run because of the errors above`);
+                            continue;
+                        }
+                        const ran = await runSqlSet(set, engine, display);
+                        steps.push(...ran.steps);
+                        diagnostics.push(...ran.diagnostics);
+                        warnings.push(...ran.notes);
+                    }
+                }
+            }
+            if (run) {
+                for (const set of sets.filter(s => s.tool === 'alembic')) {
+                    const ran = await runAlembic(set, root, timeout);
+                    diagnostics.push(...ran.diagnostics);
+                    warnings.push(...ran.notes);
+                }
+                if (sets.some(s => s.tool === 'knex')) warnings.push('knex migrations were only checked statically; run knex migrate:latest and migrate:rollback against a database to exercise them');
+            }
+
+            const errors = diagnostics.filter(d => d.severity === 'error');
+            const summary = sets.map(set => {
+                const latest = set.migrations[set.migrations.length - 1];
+                const applied = steps.filter(s => s.dir === display(set.dir));
+                const ranText = applied.length > 0 ? `; ran ${applied.filter(s => s.success).length}/${applied.length} step(s) on ${engine!.name}` : '';
+                return `${set.tool} ${display(set.dir)}: ${set.migrations.length} migration(s)${latest ? `, latest ${latest.version}` : ''}${ranText}`;
+            });
+            const lines = [
+                ...summary,
+                ...diagnostics.map(d => `${d.severity.toUpperCase()} ${display(d.file)}:${d.line}: ${d.message}`),
+                errors.length === 0 ? 'Migrations OK' : `${errors.length} migration error(s)`,
+            ];
+            return {
+                success: errors.length === 0,
+                errors: errors.length > 0 ? [`${errors.length} migration error(s)`] : [],
+                warnings,
+                output: lines.join('\n'),
+                engine: engine?.name ?? 'none',
+                sets: sets.map(set => ({ tool: set.tool, dir: set.dir, migrations: set.migrations.length, latest: set.migrations[set.migrations.length - 1]?.version ?? null })),
+                steps,
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            await engine?.close();
+            if (workDir) await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
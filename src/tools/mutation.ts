@@ -0,0 +1,237 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { dirname, join, relative, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { type FunctionRange, type Mutant, type MutationLanguage, generateMutants, pythonFunctions } from '../mutation/index.js';
+import { captureBeforeChange } from '../snapshots/index.js';
+import { runCommand } from '../utils/command.js';
+import { parseUnifiedDiff } from '../utils/git.js';
+import { findUp } from '../utils/paths.js';
+import { shellQuote } from '../utils/shell.js';
+import { queryGoAst } from './goast.js';
+import { findPythonProjectRoot, findVenvPython } from './python.js';
+
+export type MutantStatus = 'killed' | 'survived' | 'invalid' | 'timeout';
+
+export interface MutantResult extends Mutant {
+    file: string;
+    function: string;
+    status: MutantStatus;
+}
+
+const inputSchema = z.object({
+    path: z.string().describe('Repository or project directory'),
+    base: z.string().default('HEAD').describe('Mutate the functions changed since the merge-base of this ref with HEAD, including uncommitted and untracked files'),
+    files: z.array(z.string()).optional().describe('Mutate every function in these files (relative to path) instead of the changed ones'),
+    testCommand: z.string().optional().describe('Command that runs the tests covering the mutated code; default go test for the package, or pytest from the Python project root'),
+    maxMutants: z.number().int().min(1).default(50).describe('Stop after this many mutants, spread over the changed functions'),
+    minScore: z.number().min(0).max(100).optional().describe('Pass when at least this percentage of valid mutants is killed; default any surviving mutant fails'),
+    timeout: z.number().default(1800000).describe('Timeout for the whole run; each mutant gets the baseline test time times three, plus 10 seconds'),
+});
+
+const languageOf = (file: string): MutationLanguage | null => file.endsWith('.go') ? 'go' : file.endsWith('.py') ? 'python' : null;
+
+// Tests themselves are not mutated
+const isTestFile = (file: string) => /_test\.go$|(^|\/)(test_[^/]*|[^/]*_test|conftest)\.py$/.test(file);
+
+// Lines each changed file gained since the merge-base; untracked files count whole
+async function changedLines(repoRoot: string, base: string, timeout: number): Promise<Map<string, Set<number> | 'all'>> {
+    const mergeBase = await runCommand(`git merge-base ${shellQuote(base)} HEAD`, { cwd: repoRoot, timeout, local: true });
+    if (mergeBase.exitCode !== 0) throw new Error(`Cannot resolve base ref ${base}: ${mergeBase.stderr.trim()}`);
+    const diff = await runCommand(`git diff -U0 --no-renames ${mergeBase.stdout.trim()}`, { cwd: repoRoot, timeout, local: true, maxBuffer: 64 * 1024 * 1024 });
+    if (diff.exitCode !== 0) throw new Error(`git diff failed: ${diff.stderr.trim()}`);
+    const changed = new Map<string, Set<number> | 'all'>();
+    for (const file of parseUnifiedDiff(diff.stdout)) {
+        if (file.status === 'deleted' || file.binary) continue;
+        const lines = new Set(file.hunks.flatMap(h => h.lines.filter(l => l.type === 'add').map(l => l.newLine!)));
+        if (lines.size > 0) changed.set(join(repoRoot, file.file), lines);
+    }
+    const untracked = await runCommand('git ls-files --others --exclude-standard', { cwd: repoRoot, timeout, local: true });
+    for (const file of untracked.stdout.split('\n').map(f => f.trim()).filter(Boolean)) changed.set(join(repoRoot, file), 'all');
+    return changed;
+}
+
+async function functionsOf(file: string, language: MutationLanguage, source: string, timeout: number): Promise<FunctionRange[]> {
+    if (language === 'python') return pythonFunctions(source.split('\n'));
+    const parsed = await queryGoAst(file, 'functions', { timeout });
+    return parsed.results.map((f: any) => ({ name: f.receiver ? `${f.receiver}.${f.name}` : f.name, start: f.line, end: f.endLine }));
+}
+
+// Round-robin over functions, so a small budget still reaches every changed function
+function spread<T>(groups: T[][], limit: number): T[] {
+    const picked: T[] = [];
+    for (let k = 0; picked.length < limit && groups.some(g => k < g.length); k++) {
+        for (const group of groups) if (k < group.length && picked.length < limit) picked.push(group[k]!);
+    }
+    return picked;
+}
+
+export const mutationTestTool = {
+    name: 'mutation_test',
+    binaries: ['go', 'python3'],
+    // Python files are mutated in place while their tests run, and restored after
+    mutates: true,
+    description: 'Grade how well tests check the code they cover: mutate the Go and Python functions changed since base (or every function in files) one operator at a time, in the style of go-mutesting and mutmut (flip comparisons and boundaries, swap && and ||, + and -, true and false, negate if conditions, return None), run the tests for each mutant, and report every mutant no test noticed as a warning on its line. Go mutants are applied with go test -overlay, so the tree is never touched; Python files are changed in place and restored. Returns the mutation score (killed / valid mutants).',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, base, files, testCommand, maxMutants, minScore, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        let workDir: string | undefined;
+        try {
+            const root = resolve(path);
+            const deadline = Date.now() + timeout;
+            let targets: Map<string, Set<number> | 'all'>;
+            if (files) {
+                targets = new Map(files.map(f => [resolve(root, f), 'all' as const]));
+                if (files.some(f => !Config.getInstance().isPathAllowed(resolve(root, f)))) {
+                    return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+                }
+            } else {
+                const top = await runCommand('git rev-parse --show-toplevel', { cwd: root, timeout, local: true });
+                if (top.exitCode !== 0) return { success: false, errors: ['Not a git repository; pass files to choose what to mutate'], warnings: [], output: '' };
+                targets = await changedLines(top.stdout.trim(), base, timeout);
+                for (const file of targets.keys()) if (relative(root, file).startsWith('..')) targets.delete(file);
+            }
+
+            // Mutants per changed function, grouped by file
+            const candidates: Array<Array<MutantResult>> = [];
+            const sources = new Map<string, string>();
+            for (const [file, lines] of [...targets].sort(([a], [b]) => a.localeCompare(b))) {
+                const language = languageOf(file);
+                if (!language || isTestFile(file)) continue;
+                const source = await fs.readFile(file, 'utf-8').catch(() => null);
+                if (source === null) continue;
+                sources.set(file, source);
+                for (const fn of await functionsOf(file, language, source, timeout)) {
+                    const body = Array.from({ length: fn.end - fn.start }, (_, k) => fn.start + 1 + k);
+                    if (lines !== 'all' && !body.concat(fn.start).some(n => lines.has(n))) continue;
+                    const mutants = generateMutants(source, language, body).map(m => ({ ...m, file, function: fn.name, status: 'survived' as MutantStatus }));
+                    if (mutants.length > 0) candidates.push(mutants);
+                }
+            }
+            const total = candidates.reduce((sum, group) => sum + group.length, 0);
+            const mutants = spread(candidates, maxMutants);
+            if (mutants.length === 0) {
+                return { success: true, errors: [], warnings: [], output: files ? 'No functions to mutate in the given files' : `No changed Go or Python functions to mutate since ${base}`, mutants: [], diagnostics: [] };
+            }
+            const warnings: string[] = [];
+            if (total > mutants.length) warnings.push(`Tested ${mutants.length} of ${total} mutants (maxMutants)`);
+
+            // One test command per file: go test for its package (taking -overlay for the mutant), or the Python project's tests
+            const commandFor = async (file: string): Promise<{ command: (overlay?: string) => string; cwd: string }> => {
+                if (languageOf(file) === 'go' && !testCommand) {
+                    const goMod = await findUp(dirname(file), 'go.mod');
+                    const moduleRoot = goMod ? dirname(goMod) : dirname(file);
+                    const pkg = `./${relative(moduleRoot, dirname(file)).split('\\').join('/')}`.replace(/\/$/, '');
+                    return { command: overlay => `go test -count=1 -failfast${overlay ? ` -overlay ${shellQuote(overlay)}` : ''} ${shellQuote(pkg)}`, cwd: moduleRoot };
+                }
+                const cwd = languageOf(file) === 'go' ? root : await findPythonProjectRoot(dirname(file));
+                const python = await findVenvPython(cwd) ?? 'python3';
+                return { command: () => testCommand ?? `${shellQuote(python)} -m pytest -x -q -p no:cacheprovider`, cwd };
+            };
+
+            const baselines = new Map<string, { command: (overlay?: string) => string; cwd: string; limit: number }>();
+            const byCommand = new Map<string, { command: (overlay?: string) => string; cwd: string; limit: number }>();
+            for (const file of new Set(mutants.map(m => m.file))) {
+                const { command, cwd } = await commandFor(file);
+                const key = `${cwd}\u0000${command()}`;
+                if (!byCommand.has(key)) {
+                    const result = await runCommand(command(), { cwd, timeout: Math.max(deadline - Date.now(), 1000), maxBuffer: 16 * 1024 * 1024 });
+                    if (result.exitCode !== 0) {
+                        return { success: false, errors: [`Tests fail before mutating, so mutants cannot be graded: ${command()}\n${(result.stdout + result.stderr).trim().split('\n').slice(-20).join('\n')}`], warnings, output: '' };
+                    }
+                    byCommand.set(key, { command, cwd, limit: result.duration * 3 + 10000 });
+                }
+                baselines.set(file, byCommand.get(key)!);
+            }
+
+            workDir = await fs.mkdtemp(join(tmpdir(), 'cf-mutation-'));
+            let tested = 0;
+            for (const mutant of mutants) {
+                if (Date.now() >= deadline) {
+                    warnings.push(`Stopped after ${tested} of ${mutants.length} mutants at the timeout`);
+                    break;
+                }
+                const source = sources.get(mutant.file)!;
+                const lines = source.split('\n');
+                lines[mutant.line - 1] = mutant.mutatedLine;
+                const mutated = lines.join('\n');
+                const { command, cwd, limit } = baselines.get(mutant.file)!;
+                const runTimeout = Math.min(limit, Math.max(deadline - Date.now(), 1000));
+                let result;
+                if (languageOf(mutant.file) === 'go' && !testCommand) {
+                    const replacement = join(workDir, `mutant${tested}.go`);
+                    const overlay = join(workDir, `overlay${tested}.json`);
+                    await fs.writeFile(replacement, mutated);
+                    await fs.writeFile(overlay, JSON.stringify({ Replace: { [mutant.file]: replacement } }));
+                    result = await runCommand(command(overlay), { cwd, timeout: runTimeout, maxBuffer: 16 * 1024 * 1024 });
+                } else {
+                    await captureBeforeChange(mutant.file);
+                    await fs.writeFile(mutant.file, mutated);
+                    try {
+                        result = await runCommand(command(), { cwd, timeout: runTimeout, maxBuffer: 16 * 1024 * 1024 });
+                    } finally {
+                        await fs.writeFile(mutant.file, source);
+                    }
+                }
+                tested++;
+                const output = result.stdout + result.stderr;
+                if (result.limitExceeded === 'timeout') mutant.status = 'timeout';
+                else if (result.exitCode === 0) mutant.status = 'survived';
+                // A mutant that does not build says nothing about the tests
+                else if (/\[build failed\]|\[setup failed\]|SyntaxError|IndentationError/.test(output)) mutant.status = 'invalid';
+                else mutant.status = 'killed';
+            }
+
+            const graded = mutants.slice(0, tested);
+            const count = (status: MutantStatus) => graded.filter(m => m.status === status).length;
+            // Timeouts count as killed, as an infinite loop is a detected change
+            const killed = count('killed') + count('timeout');
+            const survived = graded.filter(m => m.status === 'survived');
+            const valid = killed + survived.length;
+            const score = valid > 0 ? Math.round(killed / valid * 1000) / 10 : 100;
+            const diagnostics: Diagnostic[] = survived.map(m => ({
+                file: m.file,
+                line: m.line,
+                column: m.column,
+                severity: 'warning',
+                message: `Mutant survived in ${m.function}: ${m.original} replaced with ${m.replacement} and no test failed`,
+                rule: m.operator,
+                source: 'mutation_test',
+            }));
+            const passed = minScore !== undefined ? score >= minScore : survived.length === 0;
+            const display = (file: string) => relative(root, file) || file;
+            return {
+                success: passed,
+                errors: passed ? [] : [`Mutation score ${score}% (${survived.length} of ${valid} valid mutants survived)${minScore !== undefined ? `, below ${minScore}%` : ''}`],
+                warnings,
+                output: [
+                    ...diagnostics.map(d => `${display(d.file)}:${d.line}:${d.column}: ${d.message} [${d.rule}]`),
+                    `${graded.length} mutant(s): ${killed} killed, ${survived.length} survived, ${count('invalid')} invalid; score ${score}%`,
+                ].join('\n'),
+                score,
+                summary: { mutants: graded.length, killed: count('killed'), timeout: count('timeout'), survived: survived.length, invalid: count('invalid') },
+                mutants: graded,
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            if (workDir) await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
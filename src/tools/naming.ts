@@ -0,0 +1,85 @@
+import { z } from 'zod';
+import { extname, relative } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig } from '../config/project.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { tokenize } from '../duplicates/index.js';
+import { checkNaming, GO_INITIALISMS } from '../naming/index.js';
+import { collectSourceFiles, HASH_COMMENT_EXTENSIONS, isTestFile } from '../utils/sourcefiles.js';
+
+const SPELLING_TARGETS = ['identifiers', 'comments', 'strings'] as const;
+const NAMING_RULES = new Set(['initialism', 'underscore', 'all-caps', 'snake-case', 'cap-words']);
+
+const inputSchema = z.object({
+    path: z.string().describe('Directory (or file) to check; .gitignore is respected'),
+    conventions: z.boolean().default(true).describe('Check naming conventions: Go MixedCaps and initialisms (userID, not userId), Python snake_case functions and variables and CapWords classes'),
+    spelling: z.array(z.enum(SPELLING_TARGETS)).default([...SPELLING_TARGETS]).describe('Where to look for misspelled words; [] turns the spell check off'),
+    allow: z.array(z.string()).default([]).describe('Words or identifiers never reported as misspelled, on top of naming.allow in .code-feedback.yaml'),
+    exclude: z.array(z.string()).default([]).describe('Globs (relative to path) of files to leave out'),
+    includeTests: z.boolean().default(true).describe('Check test files too'),
+    limit: z.number().int().positive().max(1000).default(100).describe('Findings listed in the output; all are returned as diagnostics'),
+});
+
+export const checkNamingTool = {
+    name: 'check_naming',
+    cacheable: true,
+    description: 'Check identifier naming and spelling. Go declarations are held to MixedCaps with golint\'s initialisms (HttpClient should be HTTPClient, user_id should be userID); Python functions and variables to snake_case and classes to CapWords. Identifiers (split into words), comments and string literals in every supported language are checked against a dictionary of common misspellings ("recieve" should be "receive"). Project words go in naming.allow, extra initialisms in naming.initialisms. Every finding is a warning diagnostic with a suggested fix.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, conventions, spelling, allow, exclude, includeTests, limit } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const configured = (await getEffectiveConfig(path)).config.naming ?? {};
+            const { root, files, warnings } = await collectSourceFiles(path, { exclude, includeTests });
+            const allowed = new Set([...allow, ...(configured.allow ?? [])].map(w => w.toLowerCase()));
+            const initialisms = new Set([...GO_INITIALISMS, ...(configured.initialisms ?? [])]);
+
+            const diagnostics: Diagnostic[] = [];
+            for (const { path: file, source } of files) {
+                const ext = extname(file).toLowerCase();
+                const language = ext === '.go' ? 'go' : ext === '.py' ? 'python' : 'other';
+                const tokens = tokenize(source, HASH_COMMENT_EXTENSIONS.has(ext), true);
+                diagnostics.push(...checkNaming(file, tokens, language, {
+                    conventions,
+                    spelling,
+                    allow: allowed,
+                    initialisms,
+                    isTestFile: isTestFile(relative(root, file) || file),
+                }));
+            }
+
+            const naming = diagnostics.filter(d => NAMING_RULES.has(d.rule ?? '')).length;
+            const misspellings = diagnostics.length - naming;
+            const display = (file: string) => relative(root, file) || file;
+            const lines = [
+                diagnostics.length > 0
+                    ? `${naming} naming issue(s) and ${misspellings} misspelling(s) in ${new Set(diagnostics.map(d => d.file)).size} of ${files.length} file(s)`
+                    : `No naming issues or misspellings in ${files.length} file(s)`,
+                ...diagnostics.slice(0, limit).map(d => `${display(d.file)}:${d.line}:${d.column}: ${d.message} (${d.rule})`),
+                ...(diagnostics.length > limit ? [`... ${diagnostics.length - limit} more`] : []),
+            ];
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: lines.join('\n'),
+                totals: { files: files.length, naming, misspellings },
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,233 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { basename, dirname, resolve } from 'path';
+import { minimatch } from 'minimatch';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { walkDirectory, type WalkEntry } from '../utils/gitignore.js';
+import { isBinaryContent } from '../utils/fileread.js';
+
+const listTreeSchema = z.object({
+    path: z.string().describe('Directory to list'),
+    maxDepth: z.number().int().min(1).max(20).default(3).describe('Levels to descend; directories at the limit are shown collapsed'),
+    respectGitignore: z.boolean().default(true).describe('Skip what .gitignore, .ignore and .git/info/exclude ignore'),
+    includeHidden: z.boolean().default(false).describe('Include dotfiles and dot-directories (.git is always skipped)'),
+    dirsOnly: z.boolean().default(false).describe('List directories only'),
+    maxEntries: z.number().int().positive().max(10000).default(500).describe('Stop after this many entries'),
+});
+
+const searchFilesSchema = z.object({
+    pattern: z.string().min(1).describe('Regular expression (JavaScript syntax), or text with literal: true'),
+    path: z.string().describe('Directory or file to search'),
+    literal: z.boolean().default(false).describe('Match pattern as plain text'),
+    caseSensitive: z.boolean().default(true),
+    wholeWord: z.boolean().default(false).describe('Only match at word boundaries'),
+    include: z.array(z.string()).optional().describe('Globs of files to search, e.g. ["*.go", "src/**/*.ts"]'),
+    exclude: z.array(z.string()).optional().describe('Globs of files to skip'),
+    context: z.number().int().min(0).max(20).default(0).describe('Lines of context before and after each match'),
+    maxResults: z.number().int().positive().max(5000).default(200).describe('Stop after this many matching lines'),
+    maxFileBytes: z.number().int().positive().default(1024 * 1024).describe('Skip files larger than this'),
+    respectGitignore: z.boolean().default(true).describe('Skip what .gitignore, .ignore and .git/info/exclude ignore'),
+    includeHidden: z.boolean().default(false).describe('Search dotfiles and dot-directories (.git is always skipped)'),
+});
+
+export interface SearchMatch {
+    file: string;
+    line: number;
+    column: number;
+    text: string;
+    before?: string[];
+    after?: string[];
+}
+
+// Long lines (minified code) are cut around the match
+const MAX_LINE_CHARS = 300;
+
+function validationFailure(error: z.ZodError) {
+    return {
+        success: false,
+        errors: error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+        warnings: [] as string[],
+        output: '',
+    };
+}
+
+function clip(line: string, column: number): string {
+    if (line.length <= MAX_LINE_CHARS) return line;
+    const start = Math.max(0, Math.min(column - 1 - MAX_LINE_CHARS / 3, line.length - MAX_LINE_CHARS));
+    return `${start > 0 ? '…' : ''}${line.slice(start, start + MAX_LINE_CHARS)}${start + MAX_LINE_CHARS < line.length ? '…' : ''}`;
+}
+
+function escapeRegExp(text: string): string {
+    return text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+}
+
+/**
+ * Compile a search pattern, throwing a readable error for an invalid regex
+ */
+export function compileSearchPattern(pattern: string, options: { literal?: boolean; caseSensitive?: boolean; wholeWord?: boolean } = {}): RegExp {
+    const source = options.literal ? escapeRegExp(pattern) : pattern;
+    try {
+        return new RegExp(options.wholeWord ? `\\b(?:${source})\\b` : source, options.caseSensitive === false ? 'i' : '');
+    } catch (error: any) {
+        throw new Error(`Invalid pattern: ${error.message}`);
+    }
+}
+
+function matchesGlobs(relativePath: string, globs: string[]): boolean {
+    return globs.some(glob => minimatch(relativePath, glob, { dot: true, matchBase: !glob.includes('/') }));
+}
+
+export const listTreeTool = {
+    name: 'list_tree',
+    description: 'List a directory as an indented tree, depth-limited and skipping what git ignores (.gitignore files at every level, .ignore, .git/info/exclude), so build outputs and dependencies such as node_modules do not drown the listing. Returns the rendered tree and the entries with relative paths.',
+    inputSchema: zodToJsonSchema(listTreeSchema),
+    async run(args: any) {
+        const parseResult = listTreeSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { maxDepth, respectGitignore, includeHidden, dirsOnly, maxEntries } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(parseResult.data.path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const root = resolve(parseResult.data.path);
+        try {
+            if (!(await fs.stat(root)).isDirectory()) {
+                return { success: false, errors: [`Not a directory: ${root}`], warnings: [], output: '' };
+            }
+            const entries: Array<Omit<WalkEntry, 'path'> & { collapsed?: boolean }> = [];
+            let truncated = false;
+            await walkDirectory(root, { maxDepth, respectGitignore, includeHidden }, entry => {
+                if (dirsOnly && entry.type !== 'directory') return;
+                if (entries.length >= maxEntries) {
+                    truncated = true;
+                    return false;
+                }
+                entries.push({
+                    relativePath: entry.relativePath,
+                    type: entry.type,
+                    depth: entry.depth,
+                    ...(entry.type === 'directory' && entry.depth === maxDepth ? { collapsed: true } : {}),
+                });
+            });
+
+            const directories = entries.filter(e => e.type === 'directory').length;
+            const lines = [
+                `${basename(root) || root}/`,
+                ...entries.map(e => `${'  '.repeat(e.depth)}${basename(e.relativePath)}${e.type === 'directory' ? '/' : e.type === 'symlink' ? '@' : ''}${e.collapsed ? ' …' : ''}`),
+                '',
+                `${directories} director${directories === 1 ? 'y' : 'ies'}, ${entries.length - directories} file(s)${truncated ? ` (stopped at maxEntries ${maxEntries})` : ''}`,
+            ];
+            return {
+                success: true,
+                errors: [],
+                warnings: truncated ? [`Listing truncated at ${maxEntries} entries; narrow the path or lower maxDepth`] : [],
+                output: lines.join('\n'),
+                root,
+                entries,
+                truncated,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const searchFilesTool = {
+    name: 'search_files',
+    description: 'Search file contents for a regular expression or literal text, like ripgrep but without needing it installed: skips what git ignores, binary files and files over maxFileBytes, and returns structured matches (file, line, column, text) with optional context lines. Narrow the search with include/exclude globs.',
+    inputSchema: zodToJsonSchema(searchFilesSchema),
+    async run(args: any) {
+        const parseResult = searchFilesSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { pattern, literal, caseSensitive, wholeWord, include, exclude, context, maxResults, maxFileBytes, respectGitignore, includeHidden } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(parseResult.data.path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const target = resolve(parseResult.data.path);
+        try {
+            const regex = compileSearchPattern(pattern, { literal, caseSensitive, wholeWord });
+            const stats = await fs.stat(target);
+            const files: Array<{ path: string; relativePath: string }> = [];
+            if (stats.isDirectory()) {
+                await walkDirectory(target, { respectGitignore, includeHidden }, entry => {
+                    if (entry.type !== 'file') return;
+                    if (include?.length && !matchesGlobs(entry.relativePath, include)) return;
+                    if (exclude?.length && matchesGlobs(entry.relativePath, exclude)) return;
+                    files.push(entry);
+                });
+            } else {
+                files.push({ path: target, relativePath: basename(target) });
+            }
+
+            const matches: SearchMatch[] = [];
+            const matchedFiles = new Set<string>();
+            const skipped = { binary: 0, large: 0 };
+            let searched = 0;
+            let truncated = false;
+            for (const file of files) {
+                if (truncated) break;
+                const { size } = await fs.stat(file.path);
+                if (size > maxFileBytes) {
+                    skipped.large++;
+                    continue;
+                }
+                const content = await fs.readFile(file.path);
+                if (isBinaryContent(content.subarray(0, 8000))) {
+                    skipped.binary++;
+                    continue;
+                }
+                searched++;
+                const lines = content.toString('utf-8').split(/\r?\n/);
+                if (lines[lines.length - 1] === '') lines.pop();
+                for (let i = 0; i < lines.length; i++) {
+                    const line = lines[i]!;
+                    const found = regex.exec(line);
+                    if (!found) continue;
+                    if (matches.length >= maxResults) {
+                        truncated = true;
+                        break;
+                    }
+                    const column = found.index + 1;
+                    matchedFiles.add(file.relativePath);
+                    matches.push({
+                        file: file.relativePath,
+                        line: i + 1,
+                        column,
+                        text: clip(line, column),
+                        ...(context > 0 ? {
+                            before: lines.slice(Math.max(0, i - context), i).map(l => clip(l, 1)),
+                            after: lines.slice(i + 1, i + 1 + context).map(l => clip(l, 1)),
+                        } : {}),
+                    });
+                }
+            }
+
+            const rendered: string[] = [];
+            for (const match of matches) {
+                if (context > 0 && rendered.length > 0) rendered.push('--');
+                (match.before ?? []).forEach((l, j, all) => rendered.push(`${match.file}-${match.line - all.length + j}-${l}`));
+                rendered.push(`${match.file}:${match.line}:${match.column}:${match.text}`);
+                (match.after ?? []).forEach((l, j) => rendered.push(`${match.file}-${match.line + 1 + j}-${l}`));
+            }
+            const warnings: string[] = [];
+            if (truncated) warnings.push(`Stopped at maxResults ${maxResults}; narrow the pattern or the include globs`);
+            if (skipped.large > 0) warnings.push(`Skipped ${skipped.large} file(s) over ${maxFileBytes} bytes`);
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: matches.length > 0
+                    ? `${rendered.join('\n')}\n\n${matches.length} match(es) in ${matchedFiles.size} file(s), ${searched} file(s) searched`
+                    : `No matches in ${searched} file(s)`,
+                root: stats.isDirectory() ? target : dirname(target),
+                matches,
+                filesSearched: searched,
+                filesMatched: matchedFiles.size,
+                skipped,
+                truncated,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -3,10 +3,13 @@ import { runCommand } from '../utils/command.js';
 import Config from '../config/index.js';
 import { join } from 'path';
 import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
 import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
+import { parseJestJson, toTestReport, describeTestFailure } from '../diagnostics/index.js';
 
 // Utility to detect package manager based on lock files
-async function detectPackageManager(projectPath: string): Promise<'pnpm' | 'yarn' | 'npm'> {
+export async function detectPackageManager(projectPath: string): Promise<'pnpm' | 'yarn' | 'npm'> {
     const pnpmLock = join(projectPath, 'pnpm-lock.yaml');
     const yarnLock = join(projectPath, 'yarn.lock');
     const npmLock = join(projectPath, 'package-lock.json');
@@ -26,8 +29,32 @@ const npmToolSchema = z.object({
     timeout: z.number().optional().default(120000),
 });
 
+const nodeTestSchema = z.object({
+    projectPath: z.string(),
+    runner: z.enum(['auto', 'jest', 'vitest']).default('auto').describe('Test runner; auto picks the one listed in package.json'),
+    files: z.array(z.string()).optional().describe('Test files or patterns to run'),
+    testNamePattern: z.string().optional().describe('Only run tests whose name matches (-t)'),
+    timeout: z.number().default(300000),
+});
+
+// vitest writes the same JSON shape as jest
+async function detectTestRunner(projectPath: string): Promise<'jest' | 'vitest'> {
+    try {
+        const packageJson = JSON.parse(await fs.readFile(join(projectPath, 'package.json'), 'utf-8'));
+        const deps = { ...packageJson.dependencies, ...packageJson.devDependencies };
+        if (deps.vitest || /\bvitest\b/.test(packageJson.scripts?.test ?? '')) return 'vitest';
+    } catch { /* fall back to jest */ }
+    return 'jest';
+}
+
+// Subcommands npm builds itself; anything else (and exec) runs whatever the caller names
+const NPM_COMMANDS = ['run', 'test', 'install', 'uninstall', 'audit', 'outdated', 'add', 'remove', 'update', 'upgrade', 'list', 'ls'];
+
 export const npmTool = {
     name: 'npm',
+    binaries: ['npm'],
+    mutates: true,
+    dangerous: (args: any) => !NPM_COMMANDS.includes(args?.command),
     description:
         `Run any npm, pnpm, or yarn command in a project directory.\n\nUse cases:\n- Run scripts (test, build, lint, etc.)\n- Install or uninstall dependencies\n- Run audit, outdated, or custom commands\n- Pass arbitrary arguments to npm/pnpm/yarn\n\nEdge cases handled:\n- Auto-detects package manager (npm, pnpm, yarn)\n- Handles missing package.json, missing scripts, invalid paths\n- Returns clear errors for unsupported or malformed commands\n- Supports all major npm commands and script execution\n\nExamples:\n- { projectPath, command: 'run', scriptName: 'test' }\n- { projectPath, command: 'install', packages: ['lodash'], isDev: true }\n- { projectPath, command: 'audit' }\n- { projectPath, command: 'outdated' }\n- { projectPath, command: 'exec', args: ['echo', 'hello'] }`,
     inputSchema: zodToJsonSchema(npmToolSchema),
@@ -181,7 +208,7 @@ export const npmTool = {
                     if (extraArgs) finalArgs = finalArgs.concat(extraArgs);
                 }
             }
-            const fullCmd = [cmd, ...finalArgs].join(' ');
+            const fullCmd = [cmd, ...finalArgs.map(shellQuote)].join(' ');
             const result = await runCommand(fullCmd, { cwd: projectPath, timeout });
             return {
                 success: result.exitCode === 0,
@@ -264,4 +291,59 @@ export const checkNpmDependencyTool = {
             return { success: false, errors: [error.message || String(error)], found: false };
         }
     },
-}; 
\ No newline at end of file
+};
+
+export const nodeTestTool = {
+    name: 'node_test',
+    binaries: ['npx'],
+    cacheable: true,
+    description: 'Run jest or vitest with the JSON reporter and return per-test results: status, duration, failure message, and the failing line in the test file. Test files that fail to load are reported as errors.',
+    inputSchema: zodToJsonSchema(nodeTestSchema),
+    async run(args: any) {
+        const parseResult = nodeTestSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, runner, files, testNamePattern, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const workDir = await fs.mkdtemp(join(tmpdir(), 'cf-node-test-'));
+        try {
+            const selected = runner === 'auto' ? await detectTestRunner(projectPath) : runner;
+            const reportPath = join(workDir, 'results.json');
+            let command = selected === 'vitest'
+                ? `npx vitest run --reporter=json --outputFile=${shellQuote(reportPath)}`
+                : `npx jest --json --testLocationInResults --outputFile=${shellQuote(reportPath)}`;
+            if (testNamePattern) command += ` -t ${shellQuote(testNamePattern)}`;
+            if (files && files.length > 0) command += ` ${files.map(shellQuote).join(' ')}`;
+            const result = await runCommand(command, { cwd: projectPath, timeout, maxBuffer: 32 * 1024 * 1024, env: { CI: 'true' } });
+            let raw: string;
+            try {
+                raw = await fs.readFile(reportPath, 'utf-8');
+            } catch {
+                return { success: false, errors: [`${selected} produced no report: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout };
+            }
+            const tests = toTestReport(parseJestJson(JSON.parse(raw), projectPath));
+            return {
+                success: result.exitCode === 0,
+                errors: tests.failures.length > 0
+                    ? tests.failures.map(describeTestFailure)
+                    : result.exitCode !== 0 ? [`${selected} failed: ${result.stderr || result.stdout}`] : [],
+                warnings: [],
+                output: result.stderr || result.stdout,
+                runner: selected,
+                tests,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
@@ -0,0 +1,168 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { dirname, join, relative, resolve } from 'path';
+import yaml from 'js-yaml';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { type Diagnostic, countBySeverity, parseSpectralOutput } from '../diagnostics/index.js';
+import { diffOpenApi, isOpenApiDocument, lintOpenApi, type SpecFinding } from '../openapi/index.js';
+import { commandExists, runCommand } from '../utils/command.js';
+import { walkDirectory } from '../utils/gitignore.js';
+import { findUp } from '../utils/paths.js';
+import { locatePointer } from '../utils/pointer.js';
+import { shellQuote } from '../utils/shell.js';
+
+const SPEC_FILE = /\.(ya?ml|json)$/;
+const SPECTRAL_RULESETS = ['.spectral.yaml', '.spectral.yml', '.spectral.json', '.spectral.js', '.spectral.mjs'];
+const MAX_SPECS = 200;
+
+const inputSchema = z.object({
+    path: z.string().describe('OpenAPI or Swagger spec (YAML or JSON), or a directory to find specs in'),
+    base: z.string().optional().describe('Git ref to compare each spec with, reporting changes that break existing clients'),
+    engine: z.enum(['auto', 'spectral', 'builtin']).default('auto').describe('Lint with the Spectral CLI, or with the built-in port of its core oas rules; auto uses Spectral when installed'),
+    ruleset: z.string().optional().describe('Spectral ruleset file; default a .spectral.yaml found above the spec, else spectral:oas'),
+    timeout: z.number().default(120000),
+});
+
+async function findSpecs(target: string): Promise<string[]> {
+    if (!(await fs.stat(target)).isDirectory()) return [target];
+    const specs: string[] = [];
+    await walkDirectory(target, { respectGitignore: true }, async entry => {
+        if (specs.length >= MAX_SPECS) return false;
+        if (entry.type !== 'file' || !SPEC_FILE.test(entry.path) || entry.relativePath.includes('node_modules/')) return;
+        // Only the first lines are read to tell a spec from other YAML and JSON
+        const handle = await fs.open(entry.path, 'r');
+        try {
+            const { buffer, bytesRead } = await handle.read(Buffer.alloc(2048), 0, 2048, 0);
+            if (/(^|\n)\s*["']?(openapi|swagger)["']?\s*:/.test(buffer.subarray(0, bytesRead).toString('utf-8'))) specs.push(entry.path);
+        } finally {
+            await handle.close();
+        }
+    });
+    return specs.sort();
+}
+
+function parseSpec(source: string): { doc?: any; error?: { message: string; line: number } } {
+    try {
+        return { doc: yaml.load(source) };
+    } catch (error: any) {
+        return { error: { message: error.reason ?? error.message ?? String(error), line: (error.mark?.line ?? 0) + 1 } };
+    }
+}
+
+const toDiagnostic = (file: string, source: string, finding: SpecFinding): Diagnostic => ({
+    file,
+    line: locatePointer(source, finding.pointer),
+    column: 0,
+    severity: finding.severity,
+    message: finding.message,
+    rule: finding.rule,
+    source: 'lint_openapi',
+});
+
+export const lintOpenApiTool = {
+    name: 'lint_openapi',
+    binaries: ['spectral'],
+    description: 'Lint OpenAPI 3 and Swagger 2 specs with Spectral (or, when it is not installed, a built-in port of its core oas rules: operationIds, descriptions, tags, success responses, path parameters, unresolved $refs, unused components), honoring .spectral.yaml. With base, also compares each spec with its version at that git ref and reports changes that break existing clients as errors: removed operations or success responses, new required parameters or request properties, parameters made required or retyped, response properties removed or retyped.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, base, engine, ruleset, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path) || (ruleset && !Config.getInstance().isPathAllowed(ruleset))) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        let workDir: string | undefined;
+        try {
+            const target = resolve(path);
+            const specs = await findSpecs(target);
+            if (specs.length === 0) return { success: true, errors: [], warnings: [], output: 'No OpenAPI or Swagger specs found', diagnostics: [] };
+            const warnings: string[] = [];
+            if (specs.length >= MAX_SPECS) warnings.push(`Stopped at ${MAX_SPECS} specs`);
+            const useSpectral = engine === 'spectral' || (engine === 'auto' && await commandExists('spectral'));
+            if (engine === 'spectral' && !await commandExists('spectral')) {
+                return { success: false, errors: ['spectral is not installed (npm install -g @stoplight/spectral-cli)'], warnings: [], output: '' };
+            }
+
+            let repoRoot: string | null = null;
+            if (base) {
+                const top = await runCommand('git rev-parse --show-toplevel', { cwd: dirname(specs[0]!), timeout, local: true });
+                if (top.exitCode !== 0) return { success: false, errors: [`Not a git repository, so there is no ${base} to compare with`], warnings: [], output: '' };
+                repoRoot = top.stdout.trim();
+            }
+
+            const diagnostics: Diagnostic[] = [];
+            const lines: string[] = [];
+            for (const spec of specs) {
+                const source = await fs.readFile(spec, 'utf-8');
+                const parsed = parseSpec(source);
+                if (parsed.error) {
+                    diagnostics.push({ file: spec, line: parsed.error.line, column: 0, severity: 'error', message: parsed.error.message, rule: 'parse', source: 'lint_openapi' });
+                    continue;
+                }
+                if (!isOpenApiDocument(parsed.doc)) continue;
+
+                if (useSpectral) {
+                    let rules = ruleset ? resolve(ruleset) : null;
+                    for (const name of SPECTRAL_RULESETS) {
+                        if (rules) break;
+                        rules = await findUp(dirname(spec), name);
+                    }
+                    if (!rules) {
+                        workDir ??= await fs.mkdtemp(join(tmpdir(), 'cf-openapi-'));
+                        rules = join(workDir, '.spectral.yaml');
+                        await fs.writeFile(rules, 'extends: ["spectral:oas"]\n');
+                    }
+                    const result = await runCommand(`spectral lint -f json -r ${shellQuote(rules)} ${shellQuote(spec)}`, { cwd: dirname(spec), timeout, maxBuffer: 16 * 1024 * 1024 });
+                    // Exit code 1 means findings at the fail severity
+                    if (result.exitCode > 1 || !result.stdout.trim().startsWith('[')) {
+                        warnings.push(`spectral failed on ${spec}: ${(result.stderr || result.stdout).trim()}`);
+                    }
+                    diagnostics.push(...parseSpectralOutput(result.stdout, dirname(spec)));
+                } else {
+                    diagnostics.push(...lintOpenApi(parsed.doc).map(f => toDiagnostic(spec, source, f)));
+                }
+
+                if (base && repoRoot) {
+                    const shown = await runCommand(`git show ${shellQuote(`${base}:${relative(repoRoot, spec).split('\\').join('/')}`)}`, { cwd: repoRoot, timeout, local: true, maxBuffer: 64 * 1024 * 1024 });
+                    if (shown.exitCode !== 0) {
+                        lines.push(`${relative(target, spec) || spec}: new since ${base}`);
+                        continue;
+                    }
+                    const before = parseSpec(shown.stdout);
+                    if (!isOpenApiDocument(before.doc)) continue;
+                    const breaking = diffOpenApi(before.doc, parsed.doc);
+                    diagnostics.push(...breaking.map(f => toDiagnostic(spec, source, f)));
+                    lines.push(`${relative(target, spec) || spec}: ${breaking.length} breaking change(s) since ${base}`);
+                }
+            }
+
+            const counts = countBySeverity(diagnostics);
+            const display = (file: string) => relative(target, file) || file;
+            return {
+                success: counts.error === 0,
+                errors: counts.error > 0 ? [`${counts.error} error(s) in ${specs.length} spec(s)`] : [],
+                warnings,
+                output: [
+                    ...diagnostics.map(d => `${display(d.file)}:${d.line}: ${d.severity}: ${d.message}${d.rule ? ` [${d.rule}]` : ''}`),
+                    ...lines,
+                    `${specs.length} spec(s) linted with ${useSpectral ? 'spectral' : 'the built-in rules'}: ${counts.error} error(s), ${counts.warning} warning(s)`,
+                ].join('\n'),
+                specs,
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            if (workDir) await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
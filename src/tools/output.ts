@@ -0,0 +1,37 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { MIN_OUTPUT_BYTES } from '../config/index.js';
+import { outputStore } from '../output/index.js';
+
+const MAX_PAGE_BYTES = 4 * 1024 * 1024;
+
+const inputSchema = z.object({
+    token: z.string().min(1).describe('truncated.token of a truncated result, or nextToken of the previous page'),
+    pageBytes: z.number().int().min(MIN_OUTPUT_BYTES).max(MAX_PAGE_BYTES).optional().describe('Page size; defaults to the max_output_bytes of the truncated call'),
+});
+
+export const getOutputPageTool = {
+    name: 'get_output_page',
+    // Pages are sized by their token; budgeting them again would truncate the pages
+    budgeted: false,
+    description: 'Fetch the full output of a tool call whose result was truncated to max_output_bytes, one page at a time. Pass the truncated.token from the result, then each page\'s nextToken until it is null. Pages end on line breaks. The page text is the result\'s output field, or the whole result as JSON when output was not what was cut. Truncated results are kept for 30 minutes.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { token, pageBytes } = parseResult.data;
+        try {
+            const { content, ...page } = outputStore.page(token, pageBytes);
+            return { success: true, errors: [], warnings: [], output: content, ...page };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
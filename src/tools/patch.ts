@@ -0,0 +1,369 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, resolve } from 'path';
+import { randomBytes } from 'crypto';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { runCommand } from '../utils/command.js';
+import { validatePath } from '../utils/sandbox.js';
+import { checkContentForSecrets } from '../utils/secrets.js';
+import { recordFileChange } from '../audit/index.js';
+import { captureBeforeChange } from '../snapshots/index.js';
+import { parseUnifiedDiff, type FileDiff } from '../utils/git.js';
+import { applyFileDiff, type ApplyDiffOptions, type HunkResult } from '../utils/patch.js';
+import { applyEditsToContent } from './editor.js';
+import { formatPreviews, previewChange, type ChangePreview } from '../utils/preview.js';
+
+const fileChangeSchema = z.object({
+    path: z.string().describe('File path, absolute or relative to rootPath'),
+    action: z.enum(['write', 'edit', 'delete']).optional().describe('Defaults to "write" when content is given, "edit" when edits are given'),
+    content: z.string().optional().describe('Full file content (write)'),
+    edits: z.array(z.object({
+        oldText: z.string(),
+        newText: z.string(),
+    })).optional().describe('Content-matching replacements (edit)'),
+});
+
+const applyChangesSchema = z.object({
+    rootPath: z.string().describe('Directory that relative paths and diff paths resolve against; verifyCommand runs here'),
+    changes: z.array(fileChangeSchema).default([]),
+    patch: z.string().optional().describe('Unified diff applied in the same transaction'),
+    verifyCommand: z.string().optional().describe('Build or test command run after writing; a non-zero exit rolls every change back'),
+    timeout: z.number().default(300000),
+    dryRun: z.boolean().default(false).describe('Validate and return the diff of every change without writing; verifyCommand is not run'),
+});
+
+export interface FileChange {
+    path: string;
+    action: 'created' | 'modified' | 'deleted';
+}
+
+async function readIfExists(path: string): Promise<string | null> {
+    try {
+        return await fs.readFile(path, 'utf-8');
+    } catch (error: any) {
+        if (error.code === 'ENOENT') return null;
+        throw error;
+    }
+}
+
+/**
+ * Final content per file (null = delete), built from the change list and the patch
+ * without touching the disk. Later changes to the same file see earlier ones.
+ */
+export class ChangePlan {
+    readonly files = new Map<string, string | null>();
+    readonly errors: string[] = [];
+
+    async current(path: string): Promise<string | null> {
+        return this.files.has(path) ? this.files.get(path) ?? null : readIfExists(path);
+    }
+
+    set(path: string, content: string | null): void {
+        this.files.set(path, content);
+    }
+}
+
+async function planChanges(plan: ChangePlan, rootPath: string, changes: z.infer<typeof fileChangeSchema>[]): Promise<void> {
+    for (const change of changes) {
+        const action = change.action ?? (change.edits ? 'edit' : 'write');
+        try {
+            const path = await validatePath(resolve(rootPath, change.path), 'write');
+            const current = await plan.current(path);
+            if (action === 'write') {
+                if (typeof change.content !== 'string') throw new Error('Missing content');
+                plan.set(path, change.content);
+            } else if (action === 'edit') {
+                if (current === null) throw new Error('File does not exist');
+                if (!change.edits || change.edits.length === 0) throw new Error('Missing edits');
+                const edits = change.edits.map(edit => ({ ...edit, mode: 'content' }));
+                plan.set(path, applyEditsToContent(current.replace(/\r\n|\r/g, '\n'), edits));
+            } else {
+                if (current === null) throw new Error('File does not exist');
+                plan.set(path, null);
+            }
+        } catch (error: any) {
+            plan.errors.push(`${change.path}: ${error.message || String(error)}`);
+        }
+    }
+}
+
+export interface FilePatchReport {
+    file: string;
+    status: FileDiff['status'];
+    applied: boolean;
+    hunks: HunkResult[];
+    error?: string;
+}
+
+/**
+ * Plan a unified diff on top of the other changes. Unless allowPartial is set,
+ * any failed hunk is a plan error; otherwise files keep the hunks that applied.
+ * Missing files and path errors are always plan errors.
+ */
+async function planPatch(plan: ChangePlan, rootPath: string, patch: string, options: ApplyDiffOptions & { allowPartial?: boolean } = {}): Promise<FilePatchReport[]> {
+    const fileDiffs = parseUnifiedDiff(patch);
+    if (fileDiffs.length === 0) {
+        plan.errors.push('Patch contains no file diffs');
+        return [];
+    }
+    const reports: FilePatchReport[] = [];
+    for (const fileDiff of fileDiffs) {
+        const report: FilePatchReport = { file: fileDiff.file, status: fileDiff.status, applied: false, hunks: [] };
+        reports.push(report);
+        try {
+            if (fileDiff.binary) throw new Error('Binary patches are not supported');
+            const target = await validatePath(resolve(rootPath, fileDiff.file), 'write');
+            const source = fileDiff.status === 'renamed' ? await validatePath(resolve(rootPath, fileDiff.oldFile), 'write') : target;
+            const current = fileDiff.status === 'added' ? null : await plan.current(source);
+            if (fileDiff.status === 'added' && (await plan.current(target)) !== null) throw new Error('File already exists');
+            if (fileDiff.status !== 'added' && current === null) throw new Error('File does not exist');
+            const result = applyFileDiff(current ?? '', fileDiff, options);
+            report.hunks = result.hunks;
+            report.applied = result.applied;
+            if (!result.applied) {
+                const failed = result.hunks.filter(h => !h.applied).map(h => `hunk ${h.index + 1} (${h.header}): ${h.error}`);
+                report.error = failed.join('; ');
+                if (!options.allowPartial) plan.errors.push(`${fileDiff.file}: ${report.error}`);
+                if (!options.allowPartial || result.hunks.every(h => !h.applied)) continue;
+            }
+            if (source !== target) plan.set(source, null);
+            // A partially applied deletion leaves the file in place
+            plan.set(target, fileDiff.status === 'deleted' && result.applied ? null : result.content);
+        } catch (error: any) {
+            report.error = error.message || String(error);
+            plan.errors.push(`${fileDiff.file}: ${report.error}`);
+        }
+    }
+    return reports;
+}
+
+/**
+ * What applying the plan would do to each file, read against the disk
+ */
+export async function previewPlan(plan: ChangePlan): Promise<ChangePreview[]> {
+    const previews: ChangePreview[] = [];
+    for (const [path, content] of plan.files) {
+        previews.push(previewChange(path, await readIfExists(path), content));
+    }
+    return previews;
+}
+
+// Secret check for every planned write; blocked files become plan errors
+export async function scanPlan(plan: ChangePlan): Promise<string[]> {
+    const warnings: string[] = [];
+    for (const [path, content] of plan.files) {
+        if (content === null) continue;
+        const secrets = await checkContentForSecrets(path, content);
+        if (secrets.blocked) plan.errors.push(`${path}: write blocked, content looks like it contains secrets`);
+        warnings.push(...secrets.warnings);
+    }
+    return warnings;
+}
+
+/**
+ * Snapshot of every touched file, restored when any later step fails
+ */
+export class Transaction {
+    private originals = new Map<string, string | null>();
+    private createdDirs: string[] = [];
+
+    async write(path: string, content: string | null): Promise<void> {
+        if (!this.originals.has(path)) {
+            this.originals.set(path, await readIfExists(path));
+        }
+        await captureBeforeChange(path);
+        if (content === null) {
+            await fs.rm(path, { force: true });
+            await recordFileChange(path, 'delete');
+            return;
+        }
+        // Remember the topmost directory this transaction creates so rollback can remove it
+        let missing: string | null = null;
+        for (let dir = dirname(path); dir !== dirname(dir); dir = dirname(dir)) {
+            if (await fs.stat(dir).then(() => true, () => false)) break;
+            missing = dir;
+        }
+        await fs.mkdir(dirname(path), { recursive: true });
+        if (missing) this.createdDirs.push(missing);
+        const tempPath = `${path}.${randomBytes(8).toString('hex')}.tmp`;
+        await fs.writeFile(tempPath, content, 'utf-8');
+        await fs.rename(tempPath, path);
+        await recordFileChange(path, 'write', content);
+    }
+
+    async apply(plan: ChangePlan): Promise<FileChange[]> {
+        const files: FileChange[] = [];
+        for (const [path, content] of plan.files) {
+            await this.write(path, content);
+            const action = content === null ? 'deleted' : this.originals.get(path) === null ? 'created' : 'modified';
+            files.push({ path, action });
+        }
+        return files;
+    }
+
+    async rollback(): Promise<string[]> {
+        const errors: string[] = [];
+        for (const [path, original] of this.originals) {
+            try {
+                if (original === null) {
+                    await fs.rm(path, { force: true });
+                    await recordFileChange(path, 'delete');
+                } else {
+                    await fs.writeFile(path, original, 'utf-8');
+                    await recordFileChange(path, 'write', original);
+                }
+            } catch (error: any) {
+                errors.push(`Rollback failed for ${path}: ${error.message || String(error)}`);
+            }
+        }
+        for (const dir of this.createdDirs.reverse()) {
+            await fs.rm(dir, { recursive: true, force: true }).catch(() => { /* already gone */ });
+        }
+        return errors;
+    }
+}
+
+export const applyChangesTool = {
+    name: 'apply_changes',
+    mutates: (args: any) => !args?.dryRun,
+    // verifyCommand is a shell command line
+    dangerous: (args: any) => typeof args?.verifyCommand === 'string',
+    description: 'Apply a set of file writes, content edits, deletions, and/or a unified diff as one transaction. Every change is validated before anything is written; if any change fails, or the optional verifyCommand (e.g. "go build ./...") exits non-zero, all files are rolled back to their pre-edit state. With dryRun, returns the diff and size change of each file instead of writing.',
+    inputSchema: zodToJsonSchema(applyChangesSchema),
+    async run(args: any) {
+        const parseResult = applyChangesSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { rootPath, changes, patch, verifyCommand, timeout, dryRun } = parseResult.data;
+        if (changes.length === 0 && !patch) {
+            return { success: false, errors: ['No changes or patch provided'], warnings: [], output: '' };
+        }
+        try {
+            await validatePath(rootPath);
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+
+        const plan = new ChangePlan();
+        await planChanges(plan, rootPath, changes);
+        if (patch) await planPatch(plan, rootPath, patch);
+        const warnings = await scanPlan(plan);
+        if (plan.errors.length > 0) {
+            return { success: false, errors: plan.errors, warnings, output: 'Validation failed; no files were changed', rolledBack: false, files: [] };
+        }
+        if (dryRun) {
+            const previews = await previewPlan(plan);
+            if (verifyCommand) warnings.push(`Dry run: ${verifyCommand} was not run`);
+            return { success: true, errors: [], warnings, output: formatPreviews(previews), dryRun: true, rolledBack: false, files: [], previews };
+        }
+
+        const transaction = new Transaction();
+        let files: FileChange[];
+        try {
+            files = await transaction.apply(plan);
+        } catch (error: any) {
+            const rollbackErrors = await transaction.rollback();
+            return { success: false, errors: [error.message || String(error), ...rollbackErrors], warnings, output: 'Write failed; changes were rolled back', rolledBack: true, files: [] };
+        }
+
+        if (verifyCommand) {
+            const verify = await runCommand(verifyCommand, { cwd: rootPath, timeout });
+            if (verify.exitCode !== 0) {
+                const rollbackErrors = await transaction.rollback();
+                return {
+                    success: false,
+                    errors: [`Verification failed (${verifyCommand}): ${verify.stderr || verify.stdout}`, ...rollbackErrors],
+                    warnings,
+                    output: 'Verification failed; changes were rolled back',
+                    rolledBack: true,
+                    files: [],
+                    verify,
+                };
+            }
+            return { success: true, errors: [], warnings, output: `Applied ${files.length} file change(s); ${verifyCommand} passed`, rolledBack: false, files, verify };
+        }
+        return { success: true, errors: [], warnings, output: `Applied ${files.length} file change(s)`, rolledBack: false, files };
+    },
+};
+
+const applyPatchSchema = z.object({
+    patch: z.string().describe('Unified diff text (git diff or diff -u output)'),
+    rootPath: z.string().describe('Directory the diff paths resolve against'),
+    fuzz: z.number().int().min(0).max(3).default(2).describe('Context lines that may be ignored at each end of a hunk when it does not match exactly'),
+    ignoreWhitespace: z.boolean().default(false).describe('Match context lines with whitespace differences collapsed'),
+    allowPartial: z.boolean().default(false).describe('Write the hunks that apply even when others fail'),
+    dryRun: z.boolean().default(false).describe('Only check whether the patch applies and return the resulting diff'),
+});
+
+export const applyPatchTool = {
+    name: 'apply_patch',
+    mutates: (args: any) => !args?.dryRun,
+    description: 'Apply a unified diff to files under rootPath. Hunk context is validated against the current contents; hunks that moved are located by searching nearby lines, and up to `fuzz` context lines may be ignored. Returns per-file, per-hunk results (offset, fuzz, error). Nothing is written if any hunk fails unless allowPartial is set.',
+    inputSchema: zodToJsonSchema(applyPatchSchema),
+    async run(args: any) {
+        const parseResult = applyPatchSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { patch, rootPath, fuzz, ignoreWhitespace, allowPartial, dryRun } = parseResult.data;
+        try {
+            await validatePath(rootPath);
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+
+        const plan = new ChangePlan();
+        const reports = await planPatch(plan, rootPath, patch, { fuzz, ignoreWhitespace, allowPartial });
+        const warnings = await scanPlan(plan);
+        const hunkCount = reports.reduce((sum, r) => sum + r.hunks.length, 0);
+        const appliedCount = reports.reduce((sum, r) => sum + r.hunks.filter(h => h.applied).length, 0);
+        for (const report of reports) {
+            for (const hunk of report.hunks) {
+                if (hunk.applied && (hunk.fuzz > 0 || hunk.offset !== 0)) {
+                    warnings.push(`${report.file}: hunk ${hunk.index + 1} applied with offset ${hunk.offset} and fuzz ${hunk.fuzz}`);
+                }
+            }
+        }
+        // In partial mode hunk failures are reported here instead of blocking the write
+        const errors = allowPartial
+            ? [...plan.errors, ...reports.flatMap(r => r.hunks.filter(h => !h.applied).map(h => `${r.file}: hunk ${h.index + 1} (${h.header}): ${h.error}`))]
+            : plan.errors;
+        const summary = `${appliedCount}/${hunkCount} hunk(s) in ${reports.length} file(s)`;
+
+        if (plan.errors.length > 0) {
+            return { success: false, errors, warnings, output: `${summary} apply; no files were changed`, files: reports };
+        }
+        if (dryRun) {
+            const previews = await previewPlan(plan);
+            return {
+                success: errors.length === 0,
+                errors,
+                warnings,
+                output: `${summary} apply (dry run)\n${formatPreviews(previews)}`,
+                dryRun: true,
+                files: reports,
+                previews,
+            };
+        }
+
+        const transaction = new Transaction();
+        try {
+            await transaction.apply(plan);
+        } catch (error: any) {
+            const rollbackErrors = await transaction.rollback();
+            return { success: false, errors: [error.message || String(error), ...rollbackErrors], warnings, output: 'Write failed; changes were rolled back', files: reports };
+        }
+        return { success: errors.length === 0, errors, warnings, output: `Applied ${summary}`, files: reports };
+    },
+};
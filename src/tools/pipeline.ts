@@ -0,0 +1,255 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, isAbsolute, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { tracer } from '../tracing/index.js';
+import { PATH_ARG_KEYS } from '../utils/paths.js';
+import { type Diagnostic, type TestCaseResult, toTestReport } from '../diagnostics/index.js';
+import { summarizePipeline } from '../diagnostics/summary.js';
+import { historyStore } from '../history/index.js';
+import { openBaseline, type BaselineMatcher } from '../baseline/index.js';
+import { applySuppressions, mergeSuppressionReports, suppressionOptions, type SuppressionReport } from '../diagnostics/suppressions.js';
+import { logger } from '../utils/logger.js';
+import { checkPermission, currentRole, type ClassifiedTool } from '../permissions/index.js';
+import { getEffectiveConfig, isToolEnabled, getToolTimeout, pipelineStepSchema, type PipelineStep } from '../config/project.js';
+
+export interface PipelineStepResult {
+    name: string;
+    status: 'passed' | 'failed' | 'skipped';
+    durationMs: number;
+    errors: string[];
+    warnings: string[];
+    output: string;
+    diagnostics?: Diagnostic[];
+    // Test cases the step's tool ran, when it reports them
+    tests?: TestCaseResult[];
+    // Findings silenced by inline suppression comments
+    suppressions?: SuppressionReport;
+    // Findings left out because the baseline has them
+    baselined?: number;
+}
+
+/**
+ * What a run leaves out of each step's findings: those inline comments
+ * suppress, then those in the baseline
+ */
+export interface FindingFilters {
+    suppressions?: { requireReason?: boolean } | null;
+    baseline?: BaselineMatcher | null;
+}
+
+export interface PipelineTool extends ClassifiedTool {
+    name: string;
+    inputSchema: any;
+    run(args: any): Promise<any>;
+}
+
+// A command step can run anything, as run_command can, and needs the role run_command does
+const COMMAND_STEP: ClassifiedTool = { name: 'run_command', dangerous: true };
+
+const inputSchema = z.object({
+    path: z.string().describe('Project directory; its .code-feedback.yaml supplies the pipeline and relative paths resolve against it'),
+    pipeline: z.string().default('default').describe('Name of a pipeline under `pipelines` in the project config'),
+    steps: z.array(pipelineStepSchema).optional().describe('Inline steps, used instead of a configured pipeline'),
+    detail: z.enum(['full', 'summary']).default('full').describe('summary returns the verdict and per-step status only, without step output or diagnostics'),
+    maxIssues: z.number().int().positive().max(100).default(5).describe('Blocking issues listed in the verdict'),
+    baseline: z.boolean().default(true).describe('Leave out findings recorded in the baseline file by create_baseline; false reports every finding'),
+});
+
+function stepName(step: PipelineStep, index: number): string {
+    return step.name || step.tool || step.command?.split(/\s+/).slice(0, 2).join(' ') || `step ${index + 1}`;
+}
+
+// Relative path arguments are relative to the pipeline root, not the server's cwd
+function resolveStepArgs(args: Record<string, unknown>, root: string): Record<string, unknown> {
+    const resolved = { ...args };
+    for (const key of PATH_ARG_KEYS) {
+        const value = resolved[key];
+        if (typeof value === 'string' && !isAbsolute(value)) resolved[key] = resolve(root, value);
+    }
+    return resolved;
+}
+
+type StepOutcome = { success: boolean; errors: string[]; warnings: string[]; output: string; diagnostics?: Diagnostic[]; tests?: TestCaseResult[]; suppressions?: SuppressionReport; baselined?: number };
+
+/**
+ * Why the caller's role may not run the step, or null when it may: each step
+ * is checked as the call it makes, so a pipeline grants nothing its caller lacks
+ */
+async function stepDenied(tool: ClassifiedTool, args: Record<string, unknown>, root: string): Promise<string | null> {
+    const role = currentRole();
+    if (role === undefined) return null;
+    const { config } = await getEffectiveConfig(root);
+    return checkPermission(config, role, tool, args);
+}
+
+async function runStep(step: PipelineStep, root: string, tools: PipelineTool[], isEnabled: (name: string) => boolean, timeoutFor: (name: string) => number | undefined): Promise<StepOutcome> {
+    try {
+        if (step.command) {
+            const denied = await stepDenied(COMMAND_STEP, {}, root);
+            if (denied) throw new Error(denied);
+            const commandResult = await runCommand(step.command, { cwd: root, ...(step.timeout !== undefined ? { timeout: step.timeout } : {}) });
+            return {
+                success: commandResult.exitCode === 0,
+                errors: commandResult.exitCode === 0 ? [] : [`Exited with code ${commandResult.exitCode}${commandResult.stderr ? `: ${commandResult.stderr.trim()}` : ''}`],
+                warnings: [],
+                output: commandResult.stdout,
+            };
+        } else {
+            const tool = tools.find(t => t.name === step.tool);
+            if (!tool || tool.name === 'run_pipeline') throw new Error(`Unknown tool: ${step.tool}`);
+            if (!isEnabled(tool.name)) throw new Error(`Tool "${tool.name}" is disabled by config`);
+            const args = resolveStepArgs(step.args ?? {}, root);
+            const denied = await stepDenied(tool, args, root);
+            if (denied) throw new Error(denied);
+            const timeout = step.timeout ?? timeoutFor(tool.name);
+            if (timeout !== undefined && args.timeout === undefined && tool.inputSchema?.properties?.timeout) args.timeout = timeout;
+            const toolResult = await tool.run(args);
+            return {
+                success: toolResult.success !== false,
+                errors: toolResult.errors ?? [],
+                warnings: toolResult.warnings ?? [],
+                output: typeof toolResult.output === 'string' ? toolResult.output : '',
+                ...(Array.isArray(toolResult.diagnostics) ? { diagnostics: toolResult.diagnostics } : {}),
+                ...(Array.isArray(toolResult.tests?.results) ? { tests: toolResult.tests.results } : {}),
+            };
+        }
+    } catch (error: any) {
+        return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+    }
+}
+
+/**
+ * Run steps in order. A failed step stops the pipeline unless it has
+ * continueOnError; steps after the stop are reported as skipped. Filtered
+ * findings are left out of each step before its status is decided.
+ */
+export async function runPipeline(steps: PipelineStep[], root: string, tools: PipelineTool[], isEnabled: (name: string) => boolean, timeoutFor: (name: string) => number | undefined = () => undefined, filters: FindingFilters = {}): Promise<PipelineStepResult[]> {
+    const results: PipelineStepResult[] = [];
+    let stopped = false;
+    for (const [index, step] of steps.entries()) {
+        const name = stepName(step, index);
+        if (stopped) {
+            results.push({ name, status: 'skipped', durationMs: 0, errors: [], warnings: [], output: '' });
+            continue;
+        }
+        const started = Date.now();
+        // Commands and tools the step runs become children of its span
+        const result = await tracer.withSpan(`pipeline step ${name}`, {
+            attributes: { 'pipeline.step.name': name, 'pipeline.step.index': index, ...(step.tool ? { 'pipeline.step.tool': step.tool } : { 'pipeline.step.command': step.command ?? '' }) },
+        }, async span => {
+            const ran = await runStep(step, root, tools, isEnabled, timeoutFor);
+            const unsuppressed = filters.suppressions ? await applySuppressions(ran, { root, ...filters.suppressions }) : ran;
+            const outcome = filters.baseline ? await filters.baseline.apply(unsuppressed) : unsuppressed;
+            span.setAttributes({ 'pipeline.step.status': outcome.success ? 'passed' : 'failed' });
+            if (!outcome.success) span.setStatus('error', outcome.errors[0]);
+            return outcome;
+        });
+        results.push({ name, status: result.success ? 'passed' : 'failed', durationMs: Date.now() - started, ...result });
+        if (!result.success && !step.continueOnError) stopped = true;
+    }
+    return results;
+}
+
+export const runPipelineTool = {
+    name: 'run_pipeline',
+    // Steps may run formatters or code generators
+    mutates: true,
+    // Inline command steps are shell; configured ones and tool steps are checked as they run
+    dangerous: (args: any) => Array.isArray(args?.steps) && args.steps.some((step: any) => step?.command),
+    description: 'Run a declarative multi-step validation pipeline (e.g. format -> build -> vet -> test -> lint) defined under `pipelines` in .code-feedback.yaml, or given inline. Each step runs a tool or a shell command; a failing step stops the run unless it sets continueOnError. Returns every step\'s result and the aggregated diagnostics in one response, plus a verdict (pass/fail, counts by severity, the top blocking issues and a one-line summary); detail: summary returns only the verdict and step statuses. Findings recorded in the workspace baseline (see create_baseline) are left out, so a step only fails on new ones.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, pipeline, steps: inlineSteps, detail, maxIssues, baseline: useBaseline } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const effective = await getEffectiveConfig(path);
+            const steps = inlineSteps ?? effective.config.pipelines?.[pipeline];
+            if (!steps) {
+                const available = Object.keys(effective.config.pipelines ?? {});
+                const hint = available.length > 0 ? `available: ${available.join(', ')}` : 'no pipelines are configured';
+                return { success: false, errors: [`Pipeline "${pipeline}" not found (${hint})`], warnings: [], output: '' };
+            }
+            const root = (await fs.stat(path)).isDirectory() ? resolve(path) : dirname(resolve(path));
+            const baseline = useBaseline ? await openBaseline(effective, root) : null;
+            // Imported lazily: the tool registry imports this module
+            const { allTools } = await import('./index.js');
+            const results = await runPipeline(
+                steps,
+                root,
+                allTools as PipelineTool[],
+                name => isToolEnabled(effective.config, name),
+                name => getToolTimeout(effective.config, name),
+                { suppressions: suppressionOptions(effective.config), baseline: baseline?.matcher ?? null },
+            );
+            const verdict = summarizePipeline(results, { root, maxIssues });
+            // Recorded for get_history and compare_runs; a failure to record never fails the run
+            const warnings: string[] = [];
+            let historyId: string | undefined;
+            if (historyStore.isEnabled()) {
+                try {
+                    historyId = (await historyStore.record(root, {
+                        pipeline: inlineSteps ? null : pipeline,
+                        passed: verdict.passed,
+                        summary: verdict.summary,
+                        diagnostics: results.flatMap(r => r.diagnostics ?? []),
+                    })).id;
+                } catch (error: any) {
+                    logger.warn('Could not record the pipeline run', { root, error });
+                    warnings.push(`Run not recorded in the history: ${error.message || String(error)}`);
+                }
+            }
+            const stepLines = results.map(r => `${r.status.padEnd(7)} ${r.name}${r.status === 'skipped' ? '' : ` (${r.durationMs}ms)`}`);
+            const baselined = results.reduce((n, r) => n + (r.baselined ?? 0), 0);
+            const baselineInfo = baseline ? { baseline: { file: baseline.path, suppressed: baselined } } : {};
+            if (baselined > 0) stepLines.push(`${baselined} finding(s) in the baseline left out`);
+            const suppressions = mergeSuppressionReports(results.map(r => r.suppressions));
+            if (suppressions.count > 0) stepLines.push(`${suppressions.count} finding(s) suppressed by inline comments`);
+            if (detail === 'summary') {
+                // Just enough to act on; rerun with detail: full for step output and every diagnostic
+                return {
+                    success: verdict.passed,
+                    errors: verdict.blocking.map(i => `${i.step}: ${i.file ? `${i.file}:${i.line} ` : ''}${i.message}`),
+                    warnings,
+                    output: [verdict.summary, ...stepLines].join('\n'),
+                    pipeline: inlineSteps ? null : pipeline,
+                    ...(historyId ? { historyId } : {}),
+                    ...baselineInfo,
+                    suppressions,
+                    verdict,
+                    steps: results.map(r => ({ name: r.name, status: r.status, durationMs: r.durationMs, diagnosticCount: r.diagnostics?.length ?? 0 })),
+                };
+            }
+            return {
+                success: verdict.passed,
+                errors: results.flatMap(r => r.errors.map(e => `${r.name}: ${e}`)),
+                warnings: [...results.flatMap(r => r.warnings.map(w => `${r.name}: ${w}`)), ...warnings],
+                output: stepLines.join('\n'),
+                pipeline: inlineSteps ? null : pipeline,
+                ...(historyId ? { historyId } : {}),
+                ...baselineInfo,
+                suppressions,
+                summary: verdict.steps,
+                verdict,
+                steps: results,
+                diagnostics: results.flatMap(r => r.diagnostics ?? []),
+                ...(results.some(r => r.tests) ? { tests: toTestReport(results.flatMap(r => r.tests ?? [])) } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,194 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { join, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+
+export interface ProfileEntry {
+    // Function name; with lines, also where in it: "example.com/pp.Build /src/pp.go:8"
+    function: string;
+    file?: string;
+    line?: number;
+    // In the profile's unit: milliseconds for CPU, bytes or objects for memory
+    flat: number;
+    flatPercent: number;
+    cum: number;
+    cumPercent: number;
+}
+
+export interface ProfileSummary {
+    type: string;
+    unit: 'ms' | 'bytes' | 'count';
+    // Total of the sample type over the run, in unit
+    total: number;
+    duration?: string;
+    entries: ProfileEntry[];
+}
+
+const TIME_UNITS: Record<string, number> = { ns: 1e-6, us: 1e-3, 'µs': 1e-3, ms: 1, s: 1000, mins: 60000, hrs: 3600000 };
+const SIZE_UNITS: Record<string, number> = { B: 1, kB: 1024, KB: 1024, MB: 1024 ** 2, GB: 1024 ** 3, TB: 1024 ** 4 };
+const COUNT_UNITS: Record<string, number> = { '': 1, k: 1e3, M: 1e6, G: 1e9 };
+
+// "4.02s", "1.02GB", "12.5k", "0"
+function parseQuantity(text: string): number {
+    const match = /^([\d.]+)([a-zµ]*)$/i.exec(text);
+    if (!match) return 0;
+    const unit = match[2] ?? '';
+    const scale = TIME_UNITS[unit] ?? SIZE_UNITS[unit] ?? COUNT_UNITS[unit] ?? 1;
+    return Math.round(Number(match[1]) * scale * 1000) / 1000;
+}
+
+const topLinePattern = /^\s*(\S+)\s+([\d.]+)%\s+[\d.]+%\s+(\S+)\s+([\d.]+)%\s+(.+)$/;
+
+/**
+ * Parse `go tool pprof -top` output (with -lines, entries carry their
+ * file and line); quantities are converted to ms, bytes or counts
+ */
+export function parsePprofTop(output: string): ProfileSummary {
+    const type = /^Type: (\S+)/m.exec(output)?.[1] ?? 'unknown';
+    const unit = type === 'cpu' || type === 'delay' ? 'ms' : type.endsWith('_space') ? 'bytes' : 'count';
+    const duration = /^Duration: ([^,]+)/m.exec(output)?.[1];
+    const total = /of ([\d.]+[a-zµ]*) total/i.exec(output)?.[1] ?? /Total samples = ([\d.]+[a-zµ]*)/.exec(output)?.[1] ?? '0';
+    const entries: ProfileEntry[] = [];
+    let inTable = false;
+    for (const line of output.split('\n')) {
+        if (/^\s*flat\s+flat%/.test(line)) {
+            inTable = true;
+            continue;
+        }
+        if (!inTable) continue;
+        const match = topLinePattern.exec(line);
+        if (!match) continue;
+        const name = match[5]!.trim().replace(/ \(inline\)$/, '');
+        const location = /^(\S+) (.+):(\d+)$/.exec(name);
+        entries.push({
+            function: location ? location[1]! : name,
+            ...(location ? { file: location[2]!, line: Number(location[3]) } : {}),
+            flat: parseQuantity(match[1]!),
+            flatPercent: Number(match[2]),
+            cum: parseQuantity(match[3]!),
+            cumPercent: Number(match[4]),
+        });
+    }
+    return { type, unit, total: parseQuantity(total), ...(duration ? { duration: duration.trim() } : {}), entries };
+}
+
+const inputSchema = z.object({
+    projectPath: z.string().describe('Go module or package directory'),
+    package: z.string().default('.').describe('The one package whose tests or benchmarks to profile'),
+    run: z.string().optional().describe('-run regex of the tests to profile; default all tests, or none when bench is set'),
+    bench: z.string().optional().describe('-bench regex; profiles benchmarks instead of tests'),
+    benchtime: z.string().optional().describe('-benchtime, e.g. 5s or 1000x'),
+    command: z.string().optional().describe('Profile a program instead of tests: a command line where {cpu} and {heap} are replaced with the profile paths for its own flags, e.g. ./server -cpuprofile {cpu} -memprofile {heap}'),
+    profiles: z.array(z.enum(['cpu', 'heap'])).default(['cpu', 'heap']),
+    heapSample: z.enum(['alloc_space', 'alloc_objects', 'inuse_space', 'inuse_objects']).default('alloc_space').describe('What the heap summary ranks: bytes or objects allocated over the run, or still live at the end'),
+    top: z.number().int().min(1).max(200).default(20).describe('Entries per summary'),
+    timeout: z.number().default(600000),
+});
+
+export const profileRunTool = {
+    name: 'profile_run',
+    binaries: ['go'],
+    // A program command line runs in a shell
+    dangerous: (args: any) => typeof args?.command === 'string',
+    description: 'Profile Go tests, benchmarks, or a program with pprof and summarize the result: the top CPU functions (flat and cumulative time, with percentages) and the top allocation sites by function and line (bytes or objects allocated, or still in use). Allocation sites above 5% of the total are also returned as info diagnostics on their lines.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, package: pkg, run, bench, benchtime, command, profiles, heapSample, top, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        if (command && (run || bench)) {
+            return { success: false, errors: ['Pass command, or run and bench for tests, not both'], warnings: [], output: '' };
+        }
+        const workDir = await fs.mkdtemp(join(tmpdir(), 'cf-profile-'));
+        try {
+            const cwd = resolve(projectPath);
+            const paths = { cpu: join(workDir, 'cpu.pprof'), heap: join(workDir, 'heap.pprof') };
+            let runLine: string;
+            let binary: string | undefined;
+            if (command) {
+                runLine = command.replace(/\{cpu\}/g, shellQuote(paths.cpu)).replace(/\{heap\}/g, shellQuote(paths.heap));
+            } else {
+                binary = join(workDir, 'test.bin');
+                runLine = `go test -count=1 -run ${shellQuote(run ?? (bench ? '^$' : '.'))} -o ${shellQuote(binary)}`;
+                if (bench) runLine += ` -bench ${shellQuote(bench)} -benchmem`;
+                if (benchtime) runLine += ` -benchtime ${shellQuote(benchtime)}`;
+                if (profiles.includes('cpu')) runLine += ` -cpuprofile ${shellQuote(paths.cpu)}`;
+                if (profiles.includes('heap')) runLine += ` -memprofile ${shellQuote(paths.heap)}`;
+                runLine += ` ${shellQuote(pkg)}`;
+            }
+            // On the host, where the profiles are read back
+            const result = await runCommand(runLine, { cwd, timeout, local: true, maxBuffer: 16 * 1024 * 1024 });
+            const warnings: string[] = [];
+            if (result.exitCode !== 0) warnings.push(`${command ? 'The program' : 'go test'} exited with ${result.exitCode}; the profiles cover the run until then`);
+
+            const summaries: Partial<Record<'cpu' | 'heap', ProfileSummary>> = {};
+            for (const kind of profiles) {
+                if (!await fs.stat(paths[kind]).then(s => s.size > 0, () => false)) {
+                    warnings.push(command ? `No ${kind} profile written; pass {${kind}} in command where the program takes its profile path` : `go test wrote no ${kind} profile`);
+                    continue;
+                }
+                const flags = kind === 'cpu' ? '' : ` -lines -sample_index=${heapSample}`;
+                const report = await runCommand(`go tool pprof -top -nodecount=${top}${flags} ${binary && await fs.stat(binary).then(() => true, () => false) ? `${shellQuote(binary)} ` : ''}${shellQuote(paths[kind])}`, { cwd, timeout, local: true, maxBuffer: 16 * 1024 * 1024 });
+                if (report.exitCode !== 0) {
+                    warnings.push(`go tool pprof failed on the ${kind} profile: ${report.stderr.trim()}`);
+                    continue;
+                }
+                summaries[kind] = parsePprofTop(report.stdout);
+            }
+            if (Object.keys(summaries).length === 0) {
+                return { success: false, errors: ['No profile to summarize'], warnings, output: result.stdout + result.stderr };
+            }
+
+            const heap = summaries.heap;
+            const diagnostics: Diagnostic[] = (heap?.entries ?? [])
+                .filter(e => e.file && e.flatPercent >= 5 && !e.file.includes('/src/runtime/'))
+                .map(e => ({
+                    file: e.file!,
+                    line: e.line ?? 0,
+                    column: 0,
+                    severity: 'info' as const,
+                    message: `${e.flatPercent}% of ${heap!.type === 'alloc_space' ? 'allocated bytes' : heap!.type.replace('_', ' ')} (${heap!.unit === 'bytes' ? formatBytes(e.flat) : e.flat}) in ${e.function}`,
+                    rule: 'allocation-site',
+                    source: 'pprof',
+                }));
+            const describe = (kind: string, s: ProfileSummary) => [
+                `${kind} (${s.type}, total ${s.unit === 'bytes' ? formatBytes(s.total) : `${s.total}${s.unit === 'ms' ? 'ms' : ''}`}${s.duration ? ` over ${s.duration}` : ''}):`,
+                ...s.entries.map(e => `  ${e.flatPercent.toFixed(2).padStart(6)}% flat ${e.cumPercent.toFixed(2).padStart(6)}% cum  ${e.function}${e.file ? ` ${e.file}:${e.line}` : ''}`),
+            ].join('\n');
+            return {
+                success: result.exitCode === 0,
+                errors: result.exitCode === 0 ? [] : [`${command ? command : 'go test'} failed: ${(result.stderr || result.stdout).trim().split('\n').slice(-10).join('\n')}`],
+                warnings,
+                output: Object.entries(summaries).map(([kind, s]) => describe(kind, s!)).join('\n\n'),
+                ...summaries,
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
+
+function formatBytes(bytes: number): string {
+    if (bytes >= 1024 ** 3) return `${(bytes / 1024 ** 3).toFixed(2)}GB`;
+    if (bytes >= 1024 ** 2) return `${(bytes / 1024 ** 2).toFixed(2)}MB`;
+    if (bytes >= 1024) return `${(bytes / 1024).toFixed(1)}kB`;
+    return `${bytes}B`;
+}
@@ -0,0 +1,48 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { PROJECT_KINDS, projectDetector } from '../projects/index.js';
+
+const listProjectsSchema = z.object({
+    path: z.string().describe('Workspace or monorepo root to scan'),
+    kind: z.enum(PROJECT_KINDS as [string, ...string[]]).optional().describe('Only list projects of this kind'),
+    refresh: z.boolean().default(false).describe('Rescan instead of using the project map from the last 30 seconds'),
+});
+
+export const listProjectsTool = {
+    name: 'list_projects',
+    description: 'Map the sub-projects of a monorepo: every directory below path with a go.mod, package.json, pyproject.toml (or setup.py) or Cargo.toml, with its name from the manifest. Go, Node, Python and Rust tools called on a directory that is not itself a project of their kind run in the one such project below it (or the one holding filePath); pass `project` with a name or relative path from this list to pick one when there are several.',
+    inputSchema: zodToJsonSchema(listProjectsSchema),
+    async run(args: any) {
+        const parseResult = listProjectsSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: '',
+            };
+        }
+        const { path, kind, refresh } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const projects = (await projectDetector.list(path, { refresh })).filter(p => !kind || p.kind === kind);
+            const counts: Record<string, number> = {};
+            for (const project of projects) counts[project.kind] = (counts[project.kind] ?? 0) + 1;
+            return {
+                success: true,
+                errors: [],
+                warnings: [],
+                output: projects.length > 0
+                    ? projects.map(p => `${p.relativePath} [${p.kind}] ${p.name}`).join('\n')
+                    : `No projects found under ${path}`,
+                projects,
+                counts,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
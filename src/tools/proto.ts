@@ -0,0 +1,102 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, join, relative, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { type Diagnostic, countBySeverity, parseBufOutput, parseLocationLines } from '../diagnostics/index.js';
+import { commandExists, runCommand } from '../utils/command.js';
+import { findUp } from '../utils/paths.js';
+import { shellQuote } from '../utils/shell.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Directory of the buf module or workspace (buf.yaml or buf.work.yaml), or any directory inside it'),
+    lint: z.boolean().default(true).describe('Run buf lint with the rules buf.yaml configures'),
+    base: z.string().optional().describe('Git ref to check for breaking changes against with buf breaking, such as main or v1.2.0'),
+    against: z.string().optional().describe('Any buf input to check for breaking changes against instead of a git ref, such as buf.build/acme/api or a directory'),
+    timeout: z.number().default(300000),
+});
+
+// Compile errors come as text on stderr: "api/v1/user.proto:12:3:syntax error: unexpected identifier"
+function parseCompileErrors(stderr: string, cwd: string): Diagnostic[] {
+    return parseLocationLines(stderr, { cwd, source: 'buf', severity: 'error' }).filter(d => d.file.endsWith('.proto'));
+}
+
+export const lintProtoTool = {
+    name: 'lint_proto',
+    binaries: ['buf'],
+    description: 'Check Protocol Buffers files with buf: buf lint (the rules buf.yaml configures, such as field and enum naming, package versioning, comments) as warnings, and with base (a git ref) or against (any buf input), buf breaking as errors, one per wire- or source-incompatible change (deleted fields, changed field types or numbers, renamed packages). Files that do not compile are errors either way.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, lint, base, against, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        if (base && against) {
+            return { success: false, errors: ['Pass base or against, not both'], warnings: [], output: '' };
+        }
+        if (!lint && !base && !against) {
+            return { success: false, errors: ['Nothing to check: enable lint or pass base or against'], warnings: [], output: '' };
+        }
+        try {
+            if (!await commandExists('buf')) return { success: false, errors: ['buf is not installed (https://buf.build/docs/installation)'], warnings: [], output: '' };
+            const target = resolve(path);
+            const start = (await fs.stat(target)).isDirectory() ? target : dirname(target);
+            // A workspace covers several modules, so it wins over the nearest buf.yaml
+            const config = await findUp(start, 'buf.work.yaml') ?? await findUp(start, 'buf.yaml');
+            const cwd = config ? dirname(config) : start;
+            const diagnostics: Diagnostic[] = [];
+            const errors: string[] = [];
+            const lines: string[] = [];
+
+            if (lint) {
+                const result = await runCommand('buf lint --error-format json', { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                const found = [...parseBufOutput(result.stdout, cwd, 'warning'), ...parseCompileErrors(result.stderr, cwd)];
+                // Exit code 100 means lint failures
+                if (result.exitCode !== 0 && found.length === 0) errors.push(`buf lint failed: ${(result.stderr || result.stdout).trim()}`);
+                diagnostics.push(...found);
+                lines.push(`buf lint: ${found.length} finding(s)`);
+            }
+
+            let input = against;
+            if (base) {
+                const top = await runCommand('git rev-parse --show-toplevel', { cwd, timeout, local: true });
+                if (top.exitCode !== 0) return { success: false, errors: [`Not a git repository: ${cwd}; pass against to compare with another input`], warnings: [], output: '' };
+                const repoRoot = top.stdout.trim();
+                const subdir = relative(repoRoot, cwd).split('\\').join('/');
+                input = `${join(repoRoot, '.git')}#ref=${base}${subdir ? `,subdir=${subdir}` : ''}`;
+            }
+            if (input) {
+                const result = await runCommand(`buf breaking --error-format json --against ${shellQuote(input)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                const found = [...parseBufOutput(result.stdout, cwd, 'error'), ...parseCompileErrors(result.stderr, cwd)];
+                if (result.exitCode !== 0 && found.length === 0) errors.push(`buf breaking failed: ${(result.stderr || result.stdout).trim()}`);
+                // Compile errors were already reported by lint
+                diagnostics.push(...found.filter(d => !diagnostics.some(o => o.file === d.file && o.line === d.line && o.message === d.message)));
+                lines.push(`buf breaking against ${base ?? against}: ${found.filter(d => d.rule !== undefined).length} breaking change(s)`);
+            }
+
+            const counts = countBySeverity(diagnostics);
+            if (counts.error > 0) errors.push(`${counts.error} error(s)`);
+            return {
+                success: errors.length === 0,
+                errors,
+                warnings: [],
+                output: [
+                    ...diagnostics.map(d => `${relative(cwd, d.file) || d.file}:${d.line}:${d.column}: ${d.severity}: ${d.message}${d.rule ? ` [${d.rule}]` : ''}`),
+                    ...lines,
+                ].join('\n'),
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
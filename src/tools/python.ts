@@ -1,9 +1,23 @@
 import { z } from 'zod';
-import { runCommand } from '../utils/command.js';
+import { runCommand, commandExists } from '../utils/command.js';
 import Config from '../config/index.js';
-import { dirname, join } from 'path';
+import { dirname, isAbsolute, join } from 'path';
+import { tmpdir } from 'os';
 import { promises as fs } from 'fs';
 import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+import {
+    type Diagnostic,
+    countBySeverity,
+    parseMypyJsonOutput,
+    parseMypyTextOutput,
+    parsePyrightJsonOutput,
+    parseJUnitXml,
+    parsePytestJson,
+    toTestReport,
+    describeTestFailure,
+} from '../diagnostics/index.js';
 
 const inputSchema = z.object({
     filePath: z.string(),
@@ -11,8 +25,50 @@ const inputSchema = z.object({
     fix: z.boolean().default(false),
 });
 
+const typecheckSchema = z.object({
+    path: z.string().describe('Python file or directory to type-check'),
+    checker: z.enum(['mypy', 'pyright']).default('mypy'),
+    configPath: z.string().optional().describe('mypy config file or pyrightconfig.json; defaults to discovery from the project root'),
+    strict: z.boolean().default(false),
+    timeout: z.number().default(300000),
+});
+
+const testSchema = z.object({
+    path: z.string().describe('Test file or directory; pytest runs from the project root above it'),
+    keyword: z.string().optional().describe('Only run tests matching this -k expression'),
+    report: z.enum(['junit', 'json']).default('junit').describe('Report pytest writes: junit (built in) or json (needs pytest-json-report)'),
+    args: z.array(z.string()).optional().describe('Additional pytest arguments'),
+    timeout: z.number().default(300000),
+});
+
+// Checker config files, nearest one marks the project root
+const TYPECHECK_CONFIG_FILES = ['pyproject.toml', 'mypy.ini', '.mypy.ini', 'setup.cfg', 'pyrightconfig.json'];
+
+// Find venv or .venv python executable (check up to 2 parent directories)
+export async function findVenvPython(startDir: string): Promise<string | null> {
+    let currentDir = startDir;
+    let levels = 0;
+    while (levels < 3) { // current, parent, grandparent
+        for (const venvName of ['venv', '.venv']) {
+            const venvPath = join(currentDir, venvName, process.platform === 'win32' ? 'Scripts/python.exe' : 'bin/python');
+            try {
+                await fs.access(venvPath);
+                return venvPath;
+            } catch { /* ignore not found */ }
+        }
+        const parentDir = dirname(currentDir);
+        if (parentDir === currentDir) break; // reached root
+        currentDir = parentDir;
+        levels++;
+    }
+    return null;
+}
+
 export const pythonTool = {
     name: 'python',
+    // black without --check rewrites the file
+    mutates: (args: any) => args?.fix === true,
+    cacheable: (args: any) => args?.fix !== true,
     description: 'Run Python code and return the output, errors, and execution time.',
     inputSchema: zodToJsonSchema(inputSchema),
     async run(args: any) {
@@ -33,26 +89,6 @@ export const pythonTool = {
             return { success: false, errors: ['Path not allowed'] as string[], warnings: [] as string[], output: '' };
         }
 
-        // Find venv or .venv python executable (check up to 2 parent directories)
-        async function findVenvPython(startDir: string): Promise<string | null> {
-            let currentDir = startDir;
-            let levels = 0;
-            while (levels < 3) { // current, parent, grandparent
-                for (const venvName of ['venv', '.venv']) {
-                    const venvPath = join(currentDir, venvName, process.platform === 'win32' ? 'Scripts/python.exe' : 'bin/python');
-                    try {
-                        await fs.access(venvPath);
-                        return venvPath;
-                    } catch { /* ignore not found */ }
-                }
-                const parentDir = dirname(currentDir);
-                if (parentDir === currentDir) break; // reached root
-                currentDir = parentDir;
-                levels++;
-            }
-            return null;
-        }
-
         try {
             await fs.access(filePath);
             const feedback = { success: true, errors: [] as string[], warnings: [] as string[], output: '' };
@@ -92,4 +128,146 @@ export const pythonTool = {
             return { success: false, errors: [error.message || String(error)] as string[], warnings: [] as string[], output: '' };
         }
     },
-}; 
\ No newline at end of file
+};
+
+export async function findPythonProjectRoot(startDir: string): Promise<string> {
+    let best: string | null = null;
+    for (const name of TYPECHECK_CONFIG_FILES) {
+        const found = await findUp(startDir, name);
+        if (found && (!best || dirname(found).length > best.length)) best = dirname(found);
+    }
+    return best ?? startDir;
+}
+
+export const pythonTypecheckTool = {
+    name: 'python_typecheck',
+    cacheable: true,
+    description: 'Type-check a Python file or package with mypy or pyright and return the type errors as structured diagnostics (file, line, column, severity, message, error code as rule).',
+    inputSchema: zodToJsonSchema(typecheckSchema),
+    async run(args: any) {
+        const parseResult = typecheckSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, checker, configPath, strict, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(path) || (configPath && !config.isPathAllowed(configPath))) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const stats = await fs.stat(path);
+            const startDir = stats.isDirectory() ? path : dirname(path);
+            const cwd = await findPythonProjectRoot(startDir);
+            const pythonExec = (await findVenvPython(startDir)) || 'python';
+            let diagnostics: Diagnostic[];
+            let result;
+
+            if (checker === 'mypy') {
+                let command = `${pythonExec} -m mypy --show-column-numbers --show-error-codes --no-error-summary`;
+                if (strict) command += ' --strict';
+                if (configPath) command += ` --config-file ${shellQuote(configPath)}`;
+                result = await runCommand(`${command} --output json ${shellQuote(path)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                if (result.exitCode === 2 && /unrecognized arguments: --output/.test(result.stderr)) {
+                    // mypy < 1.11 has no JSON output
+                    result = await runCommand(`${command} ${shellQuote(path)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                    diagnostics = parseMypyTextOutput(result.stdout, cwd);
+                } else {
+                    diagnostics = parseMypyJsonOutput(result.stdout, cwd);
+                }
+            } else {
+                const pyright = (await commandExists('pyright')) ? 'pyright' : `${pythonExec} -m pyright`;
+                let command = `${pyright} --outputjson`;
+                if (configPath) command += ` --project ${shellQuote(configPath)}`;
+                if (pythonExec !== 'python') command += ` --pythonpath ${shellQuote(pythonExec)}`;
+                result = await runCommand(`${command} ${shellQuote(path)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                diagnostics = parsePyrightJsonOutput(result.stdout, cwd);
+                if (strict) {
+                    // pyright has no --strict flag; treat warnings as failures instead
+                    diagnostics = diagnostics.map(d => d.severity === 'warning' ? { ...d, severity: 'error' as const } : d);
+                }
+            }
+
+            // Both checkers exit 1 when they found type errors; anything else non-zero is a failed run
+            if (result.exitCode !== 0 && diagnostics.length === 0) {
+                return { success: false, errors: [`${checker} failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout, diagnostics };
+            }
+            const counts = countBySeverity(diagnostics);
+            return {
+                success: counts.error === 0,
+                errors: counts.error > 0 ? [`${checker} reported ${counts.error} type error(s)`] : [],
+                warnings: counts.warning > 0 ? [`${checker} reported ${counts.warning} warning(s)`] : [],
+                output: `${checker}: ${counts.error} error(s), ${counts.warning} warning(s), ${counts.info} note(s)`,
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const pythonTestTool = {
+    name: 'python_test',
+    cacheable: true,
+    description: 'Run pytest and return per-test results parsed from its JUnit XML (or pytest-json-report) output: status, duration, failure message, source location, and captured output.',
+    inputSchema: zodToJsonSchema(testSchema),
+    async run(args: any) {
+        const parseResult = testSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, keyword, report, args: extraArgs, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const workDir = await fs.mkdtemp(join(tmpdir(), 'cf-pytest-'));
+        try {
+            const stats = await fs.stat(path);
+            const startDir = stats.isDirectory() ? path : dirname(path);
+            const cwd = await findPythonProjectRoot(startDir);
+            const pythonExec = (await findVenvPython(startDir)) || 'python';
+            const reportPath = join(workDir, report === 'junit' ? 'report.xml' : 'report.json');
+            let command = `${pythonExec} -m pytest -q -p no:cacheprovider`;
+            command += report === 'junit'
+                ? ` --junitxml=${shellQuote(reportPath)} -o junit_logging=all`
+                : ` --json-report --json-report-file=${shellQuote(reportPath)}`;
+            if (keyword) command += ` -k ${shellQuote(keyword)}`;
+            if (extraArgs && extraArgs.length > 0) command += ` ${extraArgs.map(shellQuote).join(' ')}`;
+            const result = await runCommand(`${command} ${shellQuote(path)}`, { cwd, timeout, maxBuffer: 32 * 1024 * 1024 });
+            let raw: string;
+            try {
+                raw = await fs.readFile(reportPath, 'utf-8');
+            } catch {
+                return { success: false, errors: [`pytest produced no report: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout };
+            }
+            const tests = toTestReport(report === 'junit' ? parseJUnitXml(raw) : parsePytestJson(JSON.parse(raw)));
+            for (const test of tests.results) {
+                if (test.file && !isAbsolute(test.file)) test.file = join(cwd, test.file);
+            }
+            // Exit code 5: nothing collected, which is not a failure of the code under test
+            const noTests = result.exitCode === 5;
+            return {
+                success: result.exitCode === 0 || noTests,
+                errors: tests.failures.length > 0
+                    ? tests.failures.map(describeTestFailure)
+                    : result.exitCode !== 0 && !noTests ? [`pytest failed: ${result.stderr || result.stdout}`] : [],
+                warnings: noTests ? ['No tests were collected'] : [],
+                output: result.stdout,
+                tests,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        } finally {
+            await fs.rm(workDir, { recursive: true, force: true });
+        }
+    },
+};
@@ -0,0 +1,251 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { join, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { commandExists } from '../utils/command.js';
+import { type Diagnostic, type DiagnosticSeverity, countBySeverity } from '../diagnostics/index.js';
+import { summarizePipeline, type Verdict } from '../diagnostics/summary.js';
+import { suppressionOptions } from '../diagnostics/suppressions.js';
+import { openBaseline } from '../baseline/index.js';
+import { getEffectiveConfig, getToolTimeout, isExcluded, isToolEnabled, type PipelineStep } from '../config/project.js';
+import { projectDetector, type Project, type ProjectKind } from '../projects/index.js';
+import { withRole } from '../permissions/index.js';
+import { runPipeline, type PipelineStepResult, type PipelineTool } from './pipeline.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Repository root; every Go, Node, Python and Rust project below it is checked'),
+    languages: z.array(z.enum(['go', 'node', 'python', 'rust'])).optional().describe('Only check projects of these kinds'),
+    concurrency: z.number().int().positive().optional().describe('Projects checked at once (default: MCP_MAX_CONCURRENCY)'),
+    detail: z.enum(['full', 'summary']).default('full').describe('summary returns the verdict and per-directory counts only, without step output or diagnostics'),
+    maxIssues: z.number().int().positive().max(100).default(10).describe('Blocking issues listed in the verdict'),
+    baseline: z.boolean().default(true).describe('Leave out findings recorded in each project\'s baseline file; false reports every finding'),
+});
+
+export interface ProjectCheck {
+    name: string;
+    kind: ProjectKind;
+    directory: string;
+    // Where the steps came from: a pipeline named after the kind in .code-feedback.yaml, or the built-in one
+    pipeline: 'configured' | 'default';
+    durationMs: number;
+    verdict: Verdict;
+    steps: PipelineStepResult[];
+}
+
+/**
+ * The findings of every project in one directory, by severity
+ */
+export interface DirectoryReport {
+    directory: string;
+    kinds: ProjectKind[];
+    passed: boolean;
+    failedSteps: string[];
+    severity: Record<DiagnosticSeverity, number>;
+    diagnostics: Record<DiagnosticSeverity, Diagnostic[]>;
+}
+
+const SEVERITIES: DiagnosticSeverity[] = ['error', 'warning', 'info'];
+const KIND_NAMES: Record<ProjectKind, string> = { go: 'Go', node: 'Node', python: 'Python', rust: 'Rust' };
+const ESLINT_CONFIGS = ['eslint.config.js', 'eslint.config.mjs', 'eslint.config.cjs', 'eslint.config.ts', '.eslintrc', '.eslintrc.js', '.eslintrc.cjs', '.eslintrc.json', '.eslintrc.yml', '.eslintrc.yaml'];
+
+async function exists(path: string): Promise<boolean> {
+    return fs.access(path).then(() => true, () => false);
+}
+
+async function nodeSteps(dir: string): Promise<PipelineStep[]> {
+    let pkg: any = {};
+    try {
+        pkg = JSON.parse(await fs.readFile(join(dir, 'package.json'), 'utf-8'));
+    } catch { /* checked with what the directory has */ }
+    const steps: PipelineStep[] = [];
+    if (await exists(join(dir, 'tsconfig.json'))) {
+        steps.push({ name: 'tsc', tool: 'tsc_check', args: { projectPath: '.' }, continueOnError: true });
+    }
+    const eslintConfigs = await Promise.all(ESLINT_CONFIGS.map(name => exists(join(dir, name))));
+    if (pkg.eslintConfig || eslintConfigs.some(Boolean)) {
+        steps.push({ name: 'eslint', tool: 'eslint', args: { path: '.' }, continueOnError: true });
+    }
+    const deps = { ...pkg.dependencies, ...pkg.devDependencies };
+    const testScript = typeof pkg.scripts?.test === 'string' ? pkg.scripts.test : '';
+    if (deps.jest || deps.vitest) {
+        steps.push({ name: 'test', tool: 'node_test', args: { projectPath: '.' } });
+    } else if (testScript && !/no test specified/.test(testScript)) {
+        // npm init's placeholder script always fails
+        steps.push({ name: 'test', command: 'npm test' });
+    }
+    return steps;
+}
+
+/**
+ * The built-in pipeline for a project of one kind: build or type-check, lint
+ * (reported past failures), then tests. Steps whose linter is not installed,
+ * or whose config or tests the project does not have, are left out.
+ */
+export async function defaultPipeline(project: Project, has: (binary: string) => Promise<boolean> = commandExists): Promise<PipelineStep[]> {
+    switch (project.kind) {
+        case 'go':
+            return [
+                { name: 'go build', command: 'go build ./...' },
+                (await has('golangci-lint'))
+                    ? { name: 'golangci-lint', tool: 'golangci_lint', args: { projectPath: '.' }, continueOnError: true }
+                    : { name: 'go vet', command: 'go vet ./...', continueOnError: true },
+                { name: 'go test', command: 'go test ./...' },
+            ];
+        case 'node':
+            return nodeSteps(project.path);
+        case 'python': {
+            const steps: PipelineStep[] = [];
+            if (await has('ruff')) steps.push({ name: 'ruff', tool: 'ruff_check', args: { path: '.' }, continueOnError: true });
+            const hasTests = (await Promise.all(['tests', 'test'].map(name => exists(join(project.path, name))))).some(Boolean);
+            if (hasTests) steps.push({ name: 'pytest', tool: 'python_test', args: { path: '.' } });
+            return steps;
+        }
+        case 'rust':
+            return [{ name: 'cargo', tool: 'rust', args: { filePath: 'Cargo.toml', actions: ['clippy', 'test'] } }];
+    }
+}
+
+/**
+ * Roll the projects' results up by directory: a directory holding several
+ * projects (package.json next to pyproject.toml) is one entry
+ */
+export function groupByDirectory(checks: ProjectCheck[]): DirectoryReport[] {
+    const reports = new Map<string, DirectoryReport>();
+    for (const check of checks) {
+        let report = reports.get(check.directory);
+        if (!report) {
+            report = { directory: check.directory, kinds: [], passed: true, failedSteps: [], severity: { error: 0, warning: 0, info: 0 }, diagnostics: { error: [], warning: [], info: [] } };
+            reports.set(check.directory, report);
+        }
+        report.kinds.push(check.kind);
+        report.passed &&= check.verdict.passed;
+        report.failedSteps.push(...check.verdict.failedSteps);
+        for (const diagnostic of check.steps.flatMap(s => s.diagnostics ?? [])) {
+            report.diagnostics[diagnostic.severity].push(diagnostic);
+        }
+    }
+    for (const report of reports.values()) {
+        for (const severity of SEVERITIES) {
+            report.diagnostics[severity].sort((a, b) => a.file.localeCompare(b.file) || a.line - b.line || a.column - b.column);
+            report.severity[severity] = report.diagnostics[severity].length;
+        }
+    }
+    return [...reports.values()].sort((a, b) => a.directory.localeCompare(b.directory));
+}
+
+async function checkProject(project: Project, tools: PipelineTool[], useBaseline: boolean, maxIssues: number, root: string): Promise<ProjectCheck> {
+    const started = Date.now();
+    const effective = await getEffectiveConfig(project.path);
+    const configured = effective.config.pipelines?.[project.kind];
+    const steps = configured ?? await defaultPipeline(project);
+    const baseline = useBaseline ? await openBaseline(effective, project.path) : null;
+    const run = () => runPipeline(
+        steps,
+        project.path,
+        tools,
+        name => isToolEnabled(effective.config, name),
+        name => getToolTimeout(effective.config, name),
+        { suppressions: suppressionOptions(effective.config), baseline: baseline?.matcher ?? null },
+    );
+    // Configured steps are held to the caller's role; the built-in ones are the server's own
+    const results = configured ? await run() : await withRole(undefined, run);
+    // Step names carry the directory, so the merged verdict says where a failure is
+    const named = project.relativePath === '.' ? results : results.map(r => ({ ...r, name: `${project.relativePath} ${r.name}` }));
+    return {
+        name: project.name,
+        kind: project.kind,
+        directory: project.relativePath,
+        pipeline: configured ? 'configured' : 'default',
+        durationMs: Date.now() - started,
+        verdict: summarizePipeline(named, { root, maxIssues }),
+        steps: named,
+    };
+}
+
+export const fullRepoCheckTool = {
+    name: 'full_repo_check',
+    // Configured pipelines may run formatters or code generators
+    mutates: true,
+    description: 'Check a polyglot repository in one call: detect every Go, Node, Python and Rust project below path (by go.mod, package.json, pyproject.toml/setup.py, Cargo.toml), run each one\'s pipeline in parallel, and merge the results into one report. A project runs the pipeline named after its kind under `pipelines` in its .code-feedback.yaml (pipelines.go, pipelines.node, ...), or else the built-in one: go build, golangci-lint (or go vet) and go test; tsc, eslint and jest/vitest or npm test; ruff and pytest; cargo clippy and test. Returns one verdict across languages, findings grouped by directory and severity, and every project\'s step results; detail: summary returns the verdict and per-directory counts only.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, languages, detail, maxIssues, baseline } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const root = resolve(path);
+            const effective = await getEffectiveConfig(root);
+            const projects = (await projectDetector.list(root))
+                .filter(p => !languages || languages.includes(p.kind))
+                .filter(p => !isExcluded(effective.config, effective.workspaceRoot, p.path));
+            if (projects.length === 0) {
+                const kinds = (languages ?? ['go', 'node', 'python', 'rust']).map(kind => KIND_NAMES[kind]).join(', ');
+                return { success: false, errors: [`No ${kinds} projects found under ${root}`], warnings: [], output: '' };
+            }
+            // Imported lazily: the tool registry imports this module
+            const { allTools } = await import('./index.js');
+            const tools = (allTools as PipelineTool[]).filter(t => t.name !== 'full_repo_check');
+
+            const checks: ProjectCheck[] = new Array(projects.length);
+            let next = 0;
+            const worker = async () => {
+                while (next < projects.length) {
+                    const index = next++;
+                    checks[index] = await checkProject(projects[index]!, tools, baseline, maxIssues, root);
+                }
+            };
+            const concurrency = Math.min(projects.length, parseResult.data.concurrency ?? config.getMaxConcurrency());
+            await Promise.all(Array.from({ length: concurrency }, worker));
+
+            const results = checks.flatMap(c => c.steps);
+            const verdict = summarizePipeline(results, { root, maxIssues });
+            const directories = groupByDirectory(checks);
+            const warnings = checks
+                .filter(c => c.steps.length === 0)
+                .map(c => `${c.directory} (${KIND_NAMES[c.kind]}): nothing to run; add pipelines.${c.kind} to its .code-feedback.yaml`);
+            const output = [
+                verdict.summary,
+                ...checks.map(c => `${c.directory} (${KIND_NAMES[c.kind]}, ${c.durationMs}ms): ${c.verdict.summary}`),
+            ].join('\n');
+            const diagnostics = results.flatMap(r => r.diagnostics ?? []);
+            const byDirectory = directories.map(d => ({ directory: d.directory, kinds: d.kinds, passed: d.passed, failedSteps: d.failedSteps, severity: d.severity }));
+            if (detail === 'summary') {
+                return {
+                    success: verdict.passed,
+                    errors: verdict.blocking.map(i => `${i.step}: ${i.file ? `${i.file}:${i.line} ` : ''}${i.message}`),
+                    warnings,
+                    output,
+                    verdict,
+                    severity: countBySeverity(diagnostics),
+                    directories: byDirectory,
+                    projects: checks.map(c => ({ name: c.name, kind: c.kind, directory: c.directory, passed: c.verdict.passed, durationMs: c.durationMs })),
+                };
+            }
+            return {
+                success: verdict.passed,
+                errors: results.flatMap(r => r.errors.map(e => `${r.name}: ${e}`)),
+                warnings: [...results.flatMap(r => r.warnings.map(w => `${r.name}: ${w}`)), ...warnings],
+                output,
+                verdict,
+                severity: countBySeverity(diagnostics),
+                directories,
+                projects: checks,
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,228 @@
+import { z } from 'zod';
+import { isAbsolute, relative, resolve, sep } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { runCommand } from '../utils/command.js';
+import { getEffectiveConfig } from '../config/project.js';
+import {
+    createReviewBackend, detectProvider, hostOptionsFor, missingTokenMessage, parseRemoteUrl,
+    type ChangedFile, type ReviewComment, type ReviewEvent, type ReviewProvider,
+} from '../integrations/index.js';
+import { checkRepo } from './git.js';
+
+// Hidden in the rendered comment; marks comments this server wrote so reruns do not repeat them
+const MARKER = '<!-- code-feedback -->';
+
+const SEVERITY_RANK = { info: 0, warning: 1, error: 2 } as const;
+
+const diagnosticSchema = z.object({
+    file: z.string(),
+    line: z.number().int().positive(),
+    column: z.number().int().nonnegative().optional(),
+    severity: z.enum(['error', 'warning', 'info']).default('warning'),
+    message: z.string(),
+    rule: z.string().optional(),
+    source: z.string().optional(),
+});
+
+type ReviewDiagnostic = z.infer<typeof diagnosticSchema>;
+
+const inputSchema = z.object({
+    repoPath: z.string().describe('Local checkout of the pull request; diagnostic paths are relative to it or absolute'),
+    pullNumber: z.number().int().positive().describe('Pull request number (GitLab: merge request iid)'),
+    diagnostics: z.array(diagnosticSchema).describe('Findings to publish, as returned in the diagnostics of the lint, build and test tools'),
+    provider: z.enum(['github', 'gitlab', 'bitbucket']).optional().describe('Code host; defaults to review.provider in .code-feedback.yaml, else detected from the origin remote'),
+    repository: z.string().regex(/^[\w.-]+(\/[\w.-]+)+$/).optional()
+        .describe('owner/repo (GitLab: group/subgroup/project, Bitbucket: workspace/repo); defaults to review.repository, else the origin remote'),
+    event: z.enum(['auto', 'COMMENT', 'REQUEST_CHANGES', 'APPROVE']).default('auto')
+        .describe('auto requests changes when an error lands on a changed line, and comments otherwise'),
+    body: z.string().optional().describe('Text to open the review summary with'),
+    minSeverity: z.enum(['error', 'warning', 'info']).default('info'),
+    maxComments: z.number().int().positive().max(200).default(50).describe('Inline comments per review; the rest are listed in the summary'),
+    dryRun: z.boolean().default(false).describe('Build the review and return it without posting'),
+});
+
+/**
+ * Repository-relative path with forward slashes, or null outside the repository
+ */
+function toRepoPath(root: string, file: string): string | null {
+    const rel = relative(root, isAbsolute(file) ? file : resolve(root, file));
+    return rel && !rel.startsWith('..') && !isAbsolute(rel) ? rel.split(sep).join('/') : null;
+}
+
+function formatFinding(d: ReviewDiagnostic): string {
+    const origin = [d.source, d.rule].filter(Boolean).join(' ');
+    return `**${d.severity}**${origin ? ` (${origin})` : ''}: ${d.message}`;
+}
+
+/**
+ * One comment per line, each finding on the line as a bullet
+ */
+export function renderInlineComment(diagnostics: ReviewDiagnostic[]): string {
+    const body = diagnostics.length === 1 ? formatFinding(diagnostics[0]!) : diagnostics.map(d => `- ${formatFinding(d)}`).join('\n');
+    return `${body}\n\n${MARKER}`;
+}
+
+/**
+ * Split diagnostics into inline comments on lines the change touched, findings
+ * elsewhere in its changed files (for the summary), and findings in files it
+ * does not touch
+ */
+export function planReview(diagnostics: Array<ReviewDiagnostic & { path: string }>, files: ChangedFile[], maxComments: number) {
+    const byLine = new Map<string, Array<ReviewDiagnostic & { path: string }>>();
+    const outsideDiff: Array<ReviewDiagnostic & { path: string }> = [];
+    const otherFiles: Array<ReviewDiagnostic & { path: string }> = [];
+    const changed = new Map(files.map(file => [file.path, file]));
+
+    for (const d of diagnostics) {
+        const file = changed.get(d.path);
+        if (!file) otherFiles.push(d);
+        else if (!file.lines?.has(d.line)) outsideDiff.push(d);
+        else {
+            const key = `${d.path}:${d.line}`;
+            byLine.set(key, [...(byLine.get(key) ?? []), d]);
+        }
+    }
+    // Worst findings get the inline slots first
+    const groups = [...byLine.values()].sort((a, b) => Math.max(...b.map(d => SEVERITY_RANK[d.severity])) - Math.max(...a.map(d => SEVERITY_RANK[d.severity])));
+    const comments: ReviewComment[] = [];
+    const commented: Array<ReviewDiagnostic & { path: string }> = [];
+    for (const group of groups) {
+        if (comments.length >= maxComments) {
+            outsideDiff.push(...group);
+            continue;
+        }
+        const { path, line } = group[0]!;
+        const file = changed.get(path)!;
+        const oldLine = file.lines!.get(line);
+        comments.push({
+            path,
+            ...(file.oldPath !== path ? { oldPath: file.oldPath } : {}),
+            line,
+            ...(oldLine != null ? { oldLine } : {}),
+            body: renderInlineComment(group),
+        });
+        commented.push(...group);
+    }
+    return { comments, commented, outsideDiff, otherFiles };
+}
+
+async function originRemote(repoPath: string): Promise<{ host: string; path: string } | null> {
+    const result = await runCommand('git remote get-url origin', { cwd: repoPath, timeout: 10000 });
+    return result.exitCode === 0 ? parseRemoteUrl(result.stdout) : null;
+}
+
+export const publishReviewTool = {
+    name: 'publish_review',
+    // Posts to the code host
+    dangerous: true,
+    description: 'Publish diagnostics as a pull request review on GitHub, GitLab (merge request discussions) or Bitbucket Cloud: findings on lines the change touched become inline comments (several on one line are grouped), findings elsewhere in its files are listed in the review summary, and comments already posted by an earlier run are not repeated. The host comes from review.provider in .code-feedback.yaml or the origin remote; tokens come from the environment (GITHUB_TOKEN, GITLAB_TOKEN, BITBUCKET_TOKEN). With dryRun, returns the review without posting.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { repoPath, pullNumber, diagnostics, event, body, minSeverity, maxComments, dryRun } = parseResult.data;
+        const repoError = await checkRepo(repoPath);
+        if (repoError) {
+            return { success: false, errors: [repoError], warnings: [], output: '' };
+        }
+        try {
+            const configured = (await getEffectiveConfig(repoPath)).config.review ?? {};
+            const remote = await originRemote(repoPath);
+            const provider: ReviewProvider = parseResult.data.provider ?? configured.provider ?? (remote ? detectProvider(remote.host) : 'github');
+            // GitLab paths keep their subgroups; GitHub Enterprise and Bitbucket name owner/repo last
+            const repository = parseResult.data.repository ?? configured.repository
+                ?? (remote ? (provider === 'gitlab' ? remote.path : remote.path.split('/').slice(-2).join('/')) : undefined);
+            if (!repository) {
+                return { success: false, errors: ['No origin remote to take the repository from; pass repository or set review.repository'], warnings: [], output: '' };
+            }
+            const options = hostOptionsFor(provider, {
+                ...(configured.apiUrl ? { apiUrl: configured.apiUrl } : {}),
+                ...(remote && detectProvider(remote.host) === provider ? { host: remote.host } : {}),
+            });
+            if (!options.token && !dryRun) {
+                return { success: false, errors: [missingTokenMessage(provider)], warnings: [], output: '' };
+            }
+
+            const root = resolve(repoPath);
+            const warnings: string[] = [];
+            const selected: Array<ReviewDiagnostic & { path: string }> = [];
+            for (const d of diagnostics) {
+                if (SEVERITY_RANK[d.severity] < SEVERITY_RANK[minSeverity]) continue;
+                const path = toRepoPath(root, d.file);
+                if (path) selected.push({ ...d, path });
+                else warnings.push(`${d.file} is outside ${root}; not published`);
+            }
+
+            const backend = createReviewBackend(provider, repository, options);
+            const change = await backend.getChangeRequest(pullNumber);
+            if (change.files.some(f => !f.lines)) {
+                warnings.push(`${provider} sent no patch for some large or binary files; their findings go to the summary`);
+            }
+            const plan = planReview(selected, change.files, maxComments);
+
+            // Skip what an earlier run already posted on the same line
+            const existing = await backend.listComments(pullNumber);
+            const posted = new Set(existing.filter(c => c.body.includes(MARKER)).map(c => `${c.path}:${c.line}:${c.body.trim()}`));
+            const comments = plan.comments.filter(c => !posted.has(`${c.path}:${c.line}:${c.body.trim()}`));
+            const repeated = plan.comments.length - comments.length;
+
+            const inlineErrors = plan.commented.some(d => d.severity === 'error');
+            const reviewEvent: ReviewEvent = event === 'auto' ? (inlineErrors && comments.length > 0 ? 'REQUEST_CHANGES' : 'COMMENT') : event;
+            const summary = [
+                ...(body ? [body, ''] : []),
+                `code-feedback found ${plan.commented.length + plan.outsideDiff.length} issue(s) in the changed files${repeated > 0 ? ` (${repeated} comment(s) already posted)` : ''}.`,
+                ...(plan.outsideDiff.length > 0 ? ['', 'Outside the changed lines:', ...plan.outsideDiff.map(d => `- \`${d.path}:${d.line}\` ${formatFinding(d)}`)] : []),
+                '',
+                MARKER,
+            ].join('\n');
+            if (plan.otherFiles.length > 0) {
+                warnings.push(`${plan.otherFiles.length} finding(s) in files the change does not touch were not published`);
+            }
+
+            const review = { commitId: change.headSha, event: reviewEvent, body: summary, comments };
+            const nothingNew = comments.length === 0 && plan.outsideDiff.length === 0 && event !== 'APPROVE';
+            const base = {
+                provider,
+                repository,
+                pullNumber,
+                pullUrl: change.url,
+                review,
+                skipped: { repeated, otherFiles: plan.otherFiles.length },
+            };
+            if (nothingNew) {
+                return { success: true, errors: [], warnings, output: `Nothing new to publish on ${change.url}`, ...base, published: false };
+            }
+            if (dryRun) {
+                return {
+                    success: true,
+                    errors: [],
+                    warnings,
+                    output: `Would post a ${reviewEvent} review with ${comments.length} inline comment(s) and ${plan.outsideDiff.length} summary finding(s) on ${change.url}`,
+                    ...base,
+                    published: false,
+                    dryRun: true,
+                };
+            }
+            const created = await backend.submitReview(pullNumber, review);
+            return {
+                success: true,
+                errors: [],
+                warnings: [...warnings, ...created.warnings],
+                output: `Posted a ${reviewEvent} review with ${comments.length} inline comment(s) and ${plan.outsideDiff.length} summary finding(s): ${created.url}`,
+                ...base,
+                published: true,
+                reviewId: created.id,
+                reviewUrl: created.url,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
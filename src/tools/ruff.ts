@@ -0,0 +1,90 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { type Diagnostic, parseRuffJsonOutput, countBySeverity } from '../diagnostics/index.js';
+import { captureFixTargets, describeFixes, summarizeFixes } from '../utils/autofix.js';
+import { findVenvPython } from './python.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Python file or directory to lint'),
+    select: z.array(z.string()).default([]).describe('Rule codes or prefixes to check instead of the configured set, e.g. ["E", "F", "I"]'),
+    ignore: z.array(z.string()).default([]).describe('Rule codes or prefixes to skip'),
+    fix: z.boolean().default(false).describe('Apply ruff\'s fixes (--fix) and report what changed and what is left'),
+    unsafeFixes: z.boolean().default(false).describe('With fix, also apply fixes ruff marks as unsafe'),
+    timeout: z.number().default(120000),
+});
+
+export const ruffCheckTool = {
+    name: 'ruff_check',
+    binaries: ['ruff'],
+    mutates: (args: any) => Boolean(args?.fix),
+    cacheable: true,
+    description: 'Lint Python with ruff check and return structured diagnostics (file, line, column, rule code, whether it is auto-fixable). With fix, applies the fixes and returns the changed files with diffs plus the issues that remain.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, select, ignore, fix, unsafeFixes, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const cwd = (await fs.stat(path)).isDirectory() ? path : dirname(path);
+            const python = (await findVenvPython(cwd)) || 'python';
+            let command = `${shellQuote(python)} -m ruff check --output-format=json`;
+            if (select.length > 0) command += ` --select=${shellQuote(select.join(','))}`;
+            if (ignore.length > 0) command += ` --ignore=${shellQuote(ignore.join(','))}`;
+            command += ` ${shellQuote(path)}`;
+
+            const result = await runCommand(command, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+            const diagnostics: Diagnostic[] = parseRuffJsonOutput(result.stdout, cwd);
+            // Exit code 1 means violations were found; 2 is a failed run
+            if (result.exitCode !== 0 && (result.exitCode !== 1 || diagnostics.length === 0)) {
+                return { success: false, errors: [`ruff failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout, diagnostics };
+            }
+            if (fix && diagnostics.some(d => d.fixable)) {
+                const before = await captureFixTargets(diagnostics);
+                const fixRun = await runCommand(`${command} --fix${unsafeFixes ? ' --unsafe-fixes' : ''}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+                // With --fix ruff reports only what is left
+                const remaining = parseRuffJsonOutput(fixRun.stdout, cwd);
+                if (fixRun.exitCode !== 0 && (fixRun.exitCode !== 1 || remaining.length === 0)) {
+                    return { success: false, errors: [`ruff --fix failed: ${fixRun.stderr || fixRun.stdout}`], warnings: [], output: fixRun.stdout, diagnostics };
+                }
+                const summary = await summarizeFixes(before, diagnostics, remaining);
+                const counts = countBySeverity(remaining);
+                return {
+                    success: remaining.length === 0,
+                    errors: counts.error > 0 ? [`ruff reported ${counts.error} syntax error(s)`] : [],
+                    warnings: counts.warning > 0 ? [`ruff reported ${counts.warning} issue(s) it could not fix${remaining.some(d => d.fixable) ? '; some have unsafe fixes (unsafeFixes)' : ''}`] : [],
+                    output: describeFixes(summary),
+                    diagnostics: remaining,
+                    fixed: summary.fixed,
+                    changes: summary.changes,
+                };
+            }
+            const counts = countBySeverity(diagnostics);
+            const fixable = diagnostics.filter(d => d.fixable).length;
+            return {
+                success: diagnostics.length === 0,
+                errors: counts.error > 0 ? [`ruff reported ${counts.error} syntax error(s)`] : [],
+                warnings: counts.warning > 0 ? [`ruff reported ${counts.warning} issue(s)`] : [],
+                output: `${diagnostics.length} issue(s) found${fixable > 0 ? `, ${fixable} auto-fixable` : ''}`,
+                diagnostics,
+                ...(fix ? { fixed: 0, changes: [] } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
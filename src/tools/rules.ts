@@ -0,0 +1,62 @@
+import { z } from 'zod';
+import { relative, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { customRuleSchema, getEffectiveConfig, isExcluded } from '../config/project.js';
+import { countBySeverity } from '../diagnostics/index.js';
+import { runCustomRules } from '../rules/index.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Project root or directory to check'),
+    rules: z.array(customRuleSchema).default([]).describe('Rules to check in addition to `rules` in the project config'),
+    only: z.array(z.string()).optional().describe('Check only the rules with these ids'),
+    timeout: z.number().default(120000).describe('Timeout for parsing Go files'),
+});
+
+export const checkRulesTool = {
+    name: 'check_rules',
+    cacheable: true,
+    description: 'Check a project against its house rules, declared under `rules` in .code-feedback.yaml (or passed as rules): regex patterns matched line by line in the files their globs select ("no console.log in src/**"), and Go AST patterns such as calls of a package function outside some packages (go: {call: fmt.Println, exceptPackages: [main]}) or forbidden imports. Returns standard diagnostics with the rule id, so it works as a run_pipeline step; fails when a rule of severity error matches.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, only, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const root = resolve(path);
+            const effective = await getEffectiveConfig(root);
+            let rules = [...(effective.config.rules ?? []), ...parseResult.data.rules];
+            if (only) {
+                const unknown = only.filter(id => !rules.some(r => r.id === id));
+                if (unknown.length > 0) return { success: false, errors: [`Unknown rule(s): ${unknown.join(', ')}`], warnings: [], output: '' };
+                rules = rules.filter(r => only.includes(r.id));
+            }
+            if (rules.length === 0) {
+                return { success: false, errors: ['No rules: add `rules` to .code-feedback.yaml or pass rules'], warnings: [], output: '' };
+            }
+            const skip = (p: string) => isExcluded(effective.config, effective.workspaceRoot, p);
+            const { diagnostics, files, warnings } = await runCustomRules(root, rules, { base: effective.workspaceRoot ?? root, skip, timeout });
+            const counts = countBySeverity(diagnostics);
+            const location = (file: string, line: number) => `${relative(root, file) || file}:${line}`;
+            return {
+                success: counts.error === 0,
+                errors: diagnostics.filter(d => d.severity === 'error').map(d => `${location(d.file, d.line)}: ${d.message} (${d.rule})`),
+                warnings,
+                output: `${diagnostics.length} finding(s) of ${rules.length} rule(s) (${counts.error} error, ${counts.warning} warning, ${counts.info} info)${files > 0 ? ` in ${files} file(s) matched by pattern rules` : ''}`,
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,77 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { runStore, type RunRecord } from '../runs/index.js';
+
+const inputSchema = z.object({
+    runId: z.string().optional().describe('Run id returned by the webhook delivery; omit to list recent runs'),
+    repository: z.string().optional().describe('Only runs of this repository (owner/repo)'),
+    ref: z.string().optional().describe('Only runs of this branch'),
+    sha: z.string().optional().describe('Only runs of this commit (a prefix is enough)'),
+    status: z.enum(['queued', 'running', 'passed', 'failed', 'error']).optional(),
+    limit: z.number().int().positive().max(100).default(20),
+    detail: z.enum(['full', 'summary']).default('full').describe('With runId: summary returns the verdict and step statuses without step output or diagnostics'),
+    step: z.string().optional().describe('With runId: return only this step\'s full result'),
+});
+
+function describeRun(run: RunRecord): string {
+    const target = `${run.repository} ${run.pullNumber !== undefined ? `#${run.pullNumber} ` : ''}${run.ref}@${run.sha.slice(0, 12)}`;
+    const counts = run.summary ? ` ${run.summary.passed}/${run.summary.total} steps passed` : '';
+    return `${run.id} ${run.status.padEnd(7)} ${run.event} ${target}${counts}${run.error ? `: ${run.error}` : ''}`;
+}
+
+export const getRunResultTool = {
+    name: 'get_run_result',
+    description: 'Get the result of a webhook-triggered pipeline run (HTTP mode with a webhooks file): status, verdict, per-step results and the diagnostics, by run id (detail: summary for just the verdict, step to drill into one step). Without runId, lists recent runs, filtered by repository, branch, commit or status, newest first.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { runId, repository, ref, sha, status, limit, detail, step } = parseResult.data;
+        try {
+            if (runId) {
+                const run = await runStore.get(runId);
+                if (!run) return { success: false, errors: [`Run not found: ${runId}`], warnings: [], output: '' };
+                if (step) {
+                    const result = run.steps?.find(s => s.name === step);
+                    if (!result) return { success: false, errors: [`Run ${runId} has no step ${step}`], warnings: [], output: '' };
+                    return { success: true, errors: [], warnings: [], output: `${result.status} ${result.name}\n${result.output}`.trimEnd(), step: result };
+                }
+                const steps = (run.steps ?? []).map(s => `  ${s.status.padEnd(7)} ${s.name}${s.status === 'skipped' ? '' : ` (${s.durationMs}ms)`}`);
+                const { steps: fullSteps, diagnostics, ...rest } = run;
+                return {
+                    success: true,
+                    errors: [],
+                    warnings: run.warnings,
+                    output: [describeRun(run), ...(run.verdict ? [run.verdict.summary] : []), ...steps].join('\n'),
+                    run: detail === 'summary'
+                        ? { ...rest, steps: fullSteps?.map(s => ({ name: s.name, status: s.status, durationMs: s.durationMs, diagnosticCount: s.diagnostics?.length ?? 0 })) }
+                        : run,
+                };
+            }
+            const runs = (await runStore.list()).filter(run =>
+                (!repository || run.repository.toLowerCase() === repository.toLowerCase())
+                && (!ref || run.ref === ref)
+                && (!sha || run.sha.startsWith(sha))
+                && (!status || run.status === status));
+            const shown = runs.slice(0, limit);
+            return {
+                success: true,
+                errors: [],
+                warnings: [],
+                output: shown.length > 0 ? shown.map(describeRun).join('\n') : 'No runs',
+                // Listing omits steps and diagnostics; fetch a run by id for those
+                runs: shown.map(({ steps, diagnostics, ...run }) => ({ ...run, diagnosticCount: diagnostics?.length ?? 0 })),
+                total: runs.length,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
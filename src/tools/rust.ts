@@ -0,0 +1,102 @@
+import { z } from 'zod';
+import { runCommand } from '../utils/command.js';
+import Config from '../config/index.js';
+import { dirname } from 'path';
+import { promises as fs } from 'fs';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { findUp } from '../utils/paths.js';
+
+const inputSchema = z.object({
+    filePath: z.string().describe('Path to a Rust source file or Cargo.toml inside the crate'),
+    actions: z.array(z.enum(['build', 'test', 'clippy', 'fmt'])).optional(),
+    command: z.string().optional(),
+});
+
+export const rustTool = {
+    name: 'rust',
+    binaries: ['cargo'],
+    // cargo add, update and generate-lockfile rewrite Cargo.toml and Cargo.lock
+    mutates: (args: any) => typeof args?.command === 'string',
+    // command is appended to `cargo` in a shell, so it can run anything
+    dangerous: (args: any) => typeof args?.command === 'string',
+    // Only the fixed actions are cached
+    cacheable: (args: any) => typeof args?.command !== 'string',
+    description: 'Build, test, lint (clippy), and format-check a Rust crate with cargo and return the output and errors.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        // Validate input using Zod
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+
+        const { filePath, command } = parseResult.data;
+        let { actions } = parseResult.data;
+        if (!actions || actions.length === 0) {
+            actions = ['build', 'fmt'];
+        }
+
+        if (!Config.getInstance().isPathAllowed(filePath)) {
+            return { success: false, errors: ['Path not allowed'] as string[], warnings: [] as string[], output: '' };
+        }
+        try {
+            await fs.access(filePath);
+            const manifestPath = filePath.endsWith('Cargo.toml') ? filePath : await findUp(dirname(filePath), 'Cargo.toml');
+            if (!manifestPath) {
+                return { success: false, errors: ['Cargo.toml not found for file'] as string[], warnings: [] as string[], output: '' };
+            }
+            const feedback = { success: true, errors: [] as string[], warnings: [] as string[], output: '' };
+            const dir = dirname(manifestPath);
+            const manifestArg = `--manifest-path "${manifestPath}"`;
+            if (command) {
+                const result = await runCommand(`cargo ${command}`, { cwd: dir });
+                feedback.output += `Command: cargo ${command}\n${result.stdout}\n`;
+                if (result.exitCode !== 0) {
+                    feedback.success = false;
+                    feedback.errors.push(`Command failed: ${result.stderr}`);
+                }
+                return feedback;
+            }
+            for (const action of actions) {
+                if (action === 'build') {
+                    const buildResult = await runCommand(`cargo build ${manifestArg}`, { cwd: dir, timeout: 300000 });
+                    feedback.output += `Build: ${buildResult.stdout}\n`;
+                    if (buildResult.exitCode !== 0) {
+                        feedback.success = false;
+                        feedback.errors.push(`Build failed: ${buildResult.stderr}`);
+                    }
+                } else if (action === 'test') {
+                    const testResult = await runCommand(`cargo test ${manifestArg}`, { cwd: dir, timeout: 300000 });
+                    feedback.output += `Test: ${testResult.stdout}\n`;
+                    if (testResult.exitCode !== 0) {
+                        feedback.success = false;
+                        feedback.errors.push(`Tests failed: ${testResult.stderr || testResult.stdout}`);
+                    }
+                } else if (action === 'clippy') {
+                    const clippyResult = await runCommand(`cargo clippy ${manifestArg}`, { cwd: dir, timeout: 300000 });
+                    feedback.output += `Clippy: ${clippyResult.stdout}\n`;
+                    if (clippyResult.exitCode !== 0) {
+                        feedback.success = false;
+                        feedback.errors.push(`cargo clippy failed: ${clippyResult.stderr}`);
+                    } else if (clippyResult.stderr.includes('warning:')) {
+                        feedback.warnings.push(`cargo clippy warnings: ${clippyResult.stderr}`);
+                    }
+                } else if (action === 'fmt') {
+                    const fmtResult = await runCommand(`cargo fmt ${manifestArg} -- --check`, { cwd: dir });
+                    if (fmtResult.exitCode !== 0) {
+                        feedback.warnings.push('Crate is not properly formatted. Run cargo fmt to fix.');
+                        feedback.output += `Format diff:\n${fmtResult.stdout}\n`;
+                    }
+                }
+            }
+            return feedback;
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)] as string[], warnings: [] as string[], output: '' };
+        }
+    },
+};
@@ -0,0 +1,85 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { toSarif } from '../diagnostics/sarif.js';
+import type { Diagnostic } from '../diagnostics/index.js';
+import { runPipelineTool } from './pipeline.js';
+
+const diagnosticSchema = z.object({
+    file: z.string(),
+    line: z.number().int().nonnegative().default(0),
+    column: z.number().int().nonnegative().default(0),
+    severity: z.enum(['error', 'warning', 'info']).default('warning'),
+    message: z.string(),
+    rule: z.string().optional(),
+    source: z.string().default('code-feedback'),
+    fixable: z.boolean().optional(),
+});
+
+const inputSchema = z.object({
+    path: z.string().describe('Repository root; result paths are written relative to it'),
+    diagnostics: z.array(diagnosticSchema).optional().describe('Findings to export, as returned in the diagnostics of the lint, build and test tools'),
+    pipeline: z.string().optional().describe('Run this pipeline from .code-feedback.yaml and export its diagnostics (when diagnostics are not given)'),
+    outputFile: z.string().optional().describe('Write the SARIF log here instead of only returning it'),
+});
+
+export const exportSarifTool = {
+    name: 'export_sarif',
+    mutates: (args: any) => typeof args?.pipeline === 'string' || typeof args?.outputFile === 'string',
+    description: 'Convert diagnostics to a SARIF 2.1.0 log for GitHub code scanning and other SARIF consumers: pass diagnostics from other tools, or name a pipeline to run and export. Optionally writes the log to a file.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, diagnostics: given, pipeline, outputFile } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        if (!given && !pipeline) {
+            return { success: false, errors: ['Pass diagnostics or a pipeline to run'], warnings: [], output: '' };
+        }
+        const target = outputFile ? resolve(path, outputFile) : undefined;
+        if (target && !config.isPathWritable(target)) {
+            return { success: false, errors: [`Path not writable: ${outputFile}`], warnings: [], output: '' };
+        }
+        try {
+            const warnings: string[] = [];
+            let diagnostics: Diagnostic[] = given ?? [];
+            if (!given && pipeline) {
+                const result: any = await runPipelineTool.run({ path, pipeline });
+                if (!Array.isArray(result.diagnostics)) return { ...result, success: false };
+                diagnostics = result.diagnostics;
+                // A failed step without diagnostics (a crashed build, say) has nothing to export
+                for (const step of result.steps ?? []) {
+                    if (step.status === 'failed' && !(step.diagnostics?.length > 0)) warnings.push(`Step ${step.name} failed without diagnostics`);
+                }
+            }
+            const sarif = toSarif(diagnostics, { root: resolve(path) });
+            if (target) {
+                await fs.mkdir(dirname(target), { recursive: true });
+                await fs.writeFile(target, JSON.stringify(sarif, null, 2) + '\n');
+            }
+            const results = sarif.runs.reduce((n, run) => n + run.results.length, 0);
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: `${results} result${results === 1 ? '' : 's'} from ${sarif.runs.filter(r => r.results.length > 0).length} tool(s)${target ? ` written to ${target}` : ''}`,
+                ...(target ? { outputFile: target } : {}),
+                sarif,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,190 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { basename, join, relative, resolve, sep } from 'path';
+import yaml from 'js-yaml';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { runCommand } from '../utils/command.js';
+import { validatePath } from '../utils/sandbox.js';
+import { BUILTIN_TEMPLATES, type ProjectTemplate, type TemplateVariable } from './templates.js';
+import { ChangePlan, Transaction, previewPlan, scanPlan, type FileChange } from './patch.js';
+import { formatPreviews } from '../utils/preview.js';
+
+const MANIFEST_FILES = ['template.json', 'template.yaml', 'template.yml'];
+const SKIPPED_DIRS = new Set(['.git', 'node_modules']);
+
+const inputSchema = z.object({
+    path: z.string().describe('Directory to create the project in; must be empty or missing'),
+    template: z.string().describe(`Built-in template (${Object.keys(BUILTIN_TEMPLATES).join(', ')}) or a template directory`),
+    variables: z.record(z.string()).default({}).describe('Template variables; name defaults to the directory name'),
+    gitInit: z.boolean().default(false).describe('Run git init in the new project'),
+    dryRun: z.boolean().default(false).describe('Render the template and return the files it would create without writing'),
+});
+
+const variablePattern = /\{\{\s*([A-Za-z_]\w*)\s*\}\}/g;
+
+/**
+ * Replace {{variable}} placeholders. Names that are not defined are returned
+ * instead of being left in the output.
+ */
+export function renderTemplate(text: string, variables: Record<string, string>): { text: string; missing: string[] } {
+    const missing = new Set<string>();
+    const rendered = text.replace(variablePattern, (match, name: string) => {
+        const value = variables[name];
+        if (value === undefined) {
+            missing.add(name);
+            return match;
+        }
+        return value;
+    });
+    return { text: rendered, missing: [...missing] };
+}
+
+// Python-importable form of a project name: "my-service" -> "my_service"
+function toPackageName(name: string): string {
+    const identifier = name.toLowerCase().replace(/[^a-z0-9_]+/g, '_').replace(/^_+|_+$/g, '');
+    return /^[0-9]/.test(identifier) ? `_${identifier}` : identifier || 'app';
+}
+
+/**
+ * Variables for rendering: the ones given, then template defaults (which may
+ * reference other variables), with name, package and description always set
+ */
+export function resolveVariables(declared: Record<string, TemplateVariable>, projectPath: string, provided: Record<string, string>): Record<string, string> {
+    const name = provided.name ?? basename(resolve(projectPath));
+    const variables: Record<string, string> = { name, package: toPackageName(name), description: '', ...provided };
+    for (const [key, variable] of Object.entries(declared)) {
+        if (variables[key] === undefined && variable.default !== undefined) {
+            variables[key] = renderTemplate(variable.default, variables).text;
+        }
+    }
+    return variables;
+}
+
+async function walkTemplateDir(root: string, dir: string, files: Record<string, string>, skipped: string[]): Promise<void> {
+    for (const entry of await fs.readdir(dir, { withFileTypes: true })) {
+        const full = join(dir, entry.name);
+        const rel = relative(root, full).split(sep).join('/');
+        if (entry.isDirectory()) {
+            if (!SKIPPED_DIRS.has(entry.name)) await walkTemplateDir(root, full, files, skipped);
+            continue;
+        }
+        if (!entry.isFile() || (dir === root && MANIFEST_FILES.includes(entry.name))) continue;
+        const content = await fs.readFile(full);
+        if (content.includes(0)) {
+            skipped.push(rel);
+            continue;
+        }
+        // "main.go.tmpl" keeps editors and tools from treating the template itself as source
+        files[rel.replace(/\.tmpl$/, '')] = content.toString('utf-8');
+    }
+}
+
+/**
+ * Load a template directory: every file is a templated file, and an optional
+ * template.json/template.yaml at its root declares description and variables
+ */
+export async function loadTemplateDir(dir: string): Promise<{ template: ProjectTemplate; skipped: string[] }> {
+    let manifest: Partial<ProjectTemplate> = {};
+    for (const file of MANIFEST_FILES) {
+        const raw = await fs.readFile(join(dir, file), 'utf-8').catch(() => null);
+        if (raw === null) continue;
+        manifest = (file.endsWith('.json') ? JSON.parse(raw) : yaml.load(raw)) as Partial<ProjectTemplate> ?? {};
+        break;
+    }
+    const files: Record<string, string> = {};
+    const skipped: string[] = [];
+    await walkTemplateDir(dir, dir, files, skipped);
+    return {
+        template: { description: manifest.description ?? '', variables: manifest.variables ?? {}, files },
+        skipped,
+    };
+}
+
+export const scaffoldProjectTool = {
+    name: 'scaffold_project',
+    mutates: (args: any) => !args?.dryRun,
+    description: `Create a new project from a built-in template (${Object.keys(BUILTIN_TEMPLATES).join(', ')}) or a template directory. File paths and contents may use {{variable}} placeholders; name (default: directory name), package (name as an identifier), and description are always available. A template directory may declare more variables with defaults in template.json or template.yaml; a .tmpl suffix is dropped from file names. Files are written all-or-nothing; dryRun lists them with their contents as a diff instead.`,
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, template: templateName, variables: provided, gitInit, dryRun } = parseResult.data;
+        try {
+            const root = await validatePath(path, 'write');
+            const existing = await fs.readdir(root).catch(() => [] as string[]);
+            if (existing.length > 0) {
+                return { success: false, errors: [`${path} is not empty`], warnings: [], output: '' };
+            }
+
+            const warnings: string[] = [];
+            let template = BUILTIN_TEMPLATES[templateName];
+            if (!template) {
+                const dir = await validatePath(templateName);
+                if (!(await fs.stat(dir).then(s => s.isDirectory(), () => false))) {
+                    return { success: false, errors: [`Unknown template ${templateName}; use one of ${Object.keys(BUILTIN_TEMPLATES).join(', ')} or a template directory`], warnings: [], output: '' };
+                }
+                const loaded = await loadTemplateDir(dir);
+                template = loaded.template;
+                if (loaded.skipped.length > 0) warnings.push(`Binary files are not copied: ${loaded.skipped.join(', ')}`);
+            }
+
+            const variables = resolveVariables(template.variables, root, provided);
+            const plan = new ChangePlan();
+            const missing = new Set<string>();
+            for (const [filePath, content] of Object.entries(template.files)) {
+                const renderedPath = renderTemplate(filePath, variables);
+                const renderedContent = renderTemplate(content, variables);
+                [...renderedPath.missing, ...renderedContent.missing].forEach(name => missing.add(name));
+                const target = resolve(root, renderedPath.text);
+                if (relative(root, target).startsWith('..')) {
+                    plan.errors.push(`${filePath}: resolves outside the project directory`);
+                    continue;
+                }
+                plan.set(await validatePath(target, 'write'), renderedContent.text);
+            }
+            if (missing.size > 0) {
+                return { success: false, errors: [`Missing template variables: ${[...missing].join(', ')}`], warnings, output: '' };
+            }
+            warnings.push(...await scanPlan(plan));
+            if (plan.errors.length > 0) {
+                return { success: false, errors: plan.errors, warnings, output: 'No files were written' };
+            }
+            if (dryRun) {
+                const previews = await previewPlan(plan);
+                if (gitInit) warnings.push('Dry run: git init was not run');
+                return { success: true, errors: [], warnings, output: formatPreviews(previews), dryRun: true, files: previews.map(p => relative(root, p.path).split(sep).join('/')), variables, previews };
+            }
+
+            const transaction = new Transaction();
+            let files: FileChange[];
+            try {
+                files = await transaction.apply(plan);
+            } catch (error: any) {
+                const rollbackErrors = await transaction.rollback();
+                return { success: false, errors: [error.message || String(error), ...rollbackErrors], warnings, output: 'Write failed; files were removed' };
+            }
+
+            if (gitInit) {
+                const git = await runCommand('git init', { cwd: root });
+                if (git.exitCode !== 0) warnings.push(`git init failed: ${git.stderr || git.stdout}`);
+            }
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: `Created ${files.length} file(s) in ${root} from ${BUILTIN_TEMPLATES[templateName] ? `the ${templateName}` : templateName} template`,
+                files: files.map(f => relative(root, f.path).split(sep).join('/')),
+                variables,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
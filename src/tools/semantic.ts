@@ -0,0 +1,60 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { createEmbeddingProvider, semanticIndexer } from '../semantic/index.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Workspace root to search; its index is built on first use'),
+    query: z.string().min(1).describe('What the code does, in words: "retry with exponential backoff", "where sessions expire"'),
+    limit: z.number().int().positive().max(100).default(10).describe('Chunks returned, best first'),
+    minScore: z.number().min(-1).max(1).default(0).describe('Lowest cosine similarity returned'),
+    includeTests: z.boolean().default(false).describe('Search test files too'),
+    reindex: z.boolean().default(false).describe('Discard the index and embed every file again'),
+});
+
+export const semanticSearchTool = {
+    name: 'semantic_search',
+    description: 'Search a workspace by meaning rather than by keyword: source files are split into chunks of about 60 lines, embedded with the configured provider (an OpenAI-compatible API or a local model command, set with MCP_EMBEDDINGS_URL or MCP_EMBEDDINGS_COMMAND), and the chunks closest to the query are returned with file, line range, similarity score and code. The index is stored on disk and updated incrementally before each search: only files whose content changed since the last one are embedded again. Finds conceptually related code that a grep for exact words misses.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, query, limit, minScore, includeTests, reindex } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        const provider = createEmbeddingProvider();
+        if (!provider) {
+            return {
+                success: false,
+                errors: ['Semantic search is not configured: set MCP_EMBEDDINGS_URL (an OpenAI-compatible API), MCP_EMBEDDINGS_API_KEY or OPENAI_API_KEY, or MCP_EMBEDDINGS_COMMAND (a local model)'],
+                warnings: [],
+                output: '',
+            };
+        }
+        try {
+            const index = semanticIndexer.get(path, provider);
+            const { warnings, ...stats } = await index.refresh({ full: reindex });
+            const matches = await index.search(query, { limit, minScore, includeTests });
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: matches.length > 0
+                    ? matches.map(m => `${m.file}:${m.startLine}-${m.endLine} (${m.score})`).join('\n')
+                    : 'No matching code',
+                matches,
+                index: { ...stats, provider: provider.id },
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,108 @@
+import { z } from 'zod';
+import { resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig } from '../config/project.js';
+import { serviceManager, servicesRefusal, type ServiceSession } from '../services/index.js';
+
+const inputSchema = z.object({
+    path: z.string().describe('Workspace whose .code-feedback.yaml declares the services'),
+    action: z.enum(['start', 'stop', 'status']).default('start').describe('start: start the services (if not running) and wait until they are ready; stop: remove them; status: what is running'),
+});
+
+function describeSession(session: ServiceSession) {
+    return {
+        root: session.root,
+        startedAt: session.startedAt,
+        services: session.services,
+        env: session.env,
+        activeCalls: session.users,
+        keep: session.keep,
+    };
+}
+
+function summarize(session: ServiceSession): string {
+    return session.services
+        .map(s => `${s.name}: ${s.backend === 'container' ? `${s.image} on ${s.address}` : 'compose'}, ready in ${(s.readyMs / 1000).toFixed(1)} s`)
+        .join('\n');
+}
+
+export const testServicesTool = {
+    name: 'test_services',
+    binaries: ['docker'],
+    // Starting runs the workspace's images, compose file and health checks on this host's docker daemon
+    dangerous: (args: any) => (args?.action ?? 'start') === 'start',
+    description: 'Start the service dependencies integration tests need (Postgres, Redis, LocalStack or any image, declared under services.containers in .code-feedback.yaml, and/or a docker compose file under services.compose), wait until each passes its health check, and return the connection variables (DATABASE_URL, REDIS_URL, AWS_ENDPOINT_URL, NAME_HOST/NAME_PORT/NAME_URL and configured ones). While they run, every tool call in the workspace gets those variables, so go test, pytest or run_pipeline reach them. Ports are published on random loopback ports. Services stop with action stop, after services.idleMinutes without use (60 by default), or when the server exits. Tools listed in services.tools start and stop them around each call automatically.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, action } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const effective = await getEffectiveConfig(path);
+            const root = effective.workspaceRoot ?? resolve(path);
+            if (action === 'status') {
+                const session = serviceManager.get(root);
+                return {
+                    success: true,
+                    errors: [],
+                    warnings: [],
+                    output: session ? summarize(session) : 'No test services are running for this workspace',
+                    running: Boolean(session),
+                    ...(session ? describeSession(session) : {}),
+                };
+            }
+            if (action === 'stop') {
+                const stopped = await serviceManager.stop(root);
+                return {
+                    success: true,
+                    errors: [],
+                    warnings: [],
+                    output: !stopped
+                        ? 'No test services are running for this workspace'
+                        : stopped.deferred
+                            ? `Stopping once ${stopped.session.users} running call(s) finish`
+                            : `Stopped ${stopped.session.services.map(s => s.name).join(', ')}`,
+                    ...(stopped ? { stopped: stopped.session.services.map(s => s.name), deferred: stopped.deferred } : { stopped: [] }),
+                };
+            }
+            const services = effective.config.services;
+            if (!services || (!services.containers && !services.compose)) {
+                return { success: false, errors: ['No services are declared under services in .code-feedback.yaml'], warnings: [], output: '' };
+            }
+            const warnings: string[] = [];
+            const executor = effective.config.executor ?? config.getExecutor();
+            const refused = servicesRefusal(services, executor);
+            if (refused) {
+                return { success: false, errors: [refused], warnings: [], output: '' };
+            }
+            if (executor !== 'local') {
+                warnings.push(`Commands in this workspace run on ${executor}, which cannot reach services published on this host's loopback interface`);
+            }
+            const session = await serviceManager.start(root, services, { keep: true });
+            if (JSON.stringify(session.config) !== JSON.stringify(services)) {
+                warnings.push('The services are running with the config they were started with; stop and start them to apply changes');
+            }
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: summarize(session),
+                ...describeSession(session),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
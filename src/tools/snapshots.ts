@@ -0,0 +1,126 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, resolve } from 'path';
+import { randomBytes } from 'crypto';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { isWithin } from '../config/index.js';
+import { validatePath } from '../utils/sandbox.js';
+import { formatPreviews, previewChange } from '../utils/preview.js';
+import { recordFileChange } from '../audit/index.js';
+import { captureBeforeChange, getSnapshotsDir, snapshotStore, type Snapshot } from '../snapshots/index.js';
+
+const listSchema = z.object({
+    path: z.string().optional().describe('Only snapshots that changed files at or under this path'),
+    tool: z.string().optional().describe('Only snapshots taken for this tool'),
+    limit: z.number().int().positive().max(500).default(20),
+});
+
+const revertSchema = z.object({
+    id: z.string().describe('Snapshot to revert to: that call and every later one are undone'),
+    force: z.boolean().default(false).describe('Overwrite files that were changed outside tool calls since the snapshot'),
+    dryRun: z.boolean().default(false).describe('Return the diff the revert would produce without writing'),
+});
+
+function validationFailure(error: z.ZodError) {
+    return {
+        success: false,
+        errors: error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+        warnings: [] as string[],
+        output: '',
+    };
+}
+
+function summarize(snapshot: Snapshot): string {
+    const sample = snapshot.files.slice(0, 3).map(f => f.path).join(', ');
+    const more = snapshot.files.length > 3 ? ` and ${snapshot.files.length - 3} more` : '';
+    return `${snapshot.id} ${snapshot.timestamp} ${snapshot.tool}: ${sample}${more}`;
+}
+
+export const listSnapshotsTool = {
+    name: 'list_snapshots',
+    description: 'List snapshots, newest first. A snapshot is taken before every tool call that changes files (editor, filesystem, apply_changes, apply_patch, scaffold_project, revert_to_snapshot) and keeps the previous content of each file it touched; pass its id to revert_to_snapshot to undo that call and everything after it.',
+    inputSchema: zodToJsonSchema(listSchema),
+    async run(args: any) {
+        const parseResult = listSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { path, tool, limit } = parseResult.data;
+        try {
+            const root = path ? resolve(path) : undefined;
+            const snapshots = (await snapshotStore.list())
+                .filter(s => !tool || s.tool === tool)
+                .filter(s => !root || s.files.some(f => isWithin(root, f.path)));
+            const warnings = snapshotStore.isEnabled() ? [] : ['Snapshots are disabled (MCP_SNAPSHOTS=off); only earlier ones are shown'];
+            return {
+                success: true,
+                errors: [],
+                warnings,
+                output: snapshots.length > 0 ? snapshots.slice(0, limit).map(summarize).join('\n') : 'No snapshots',
+                directory: getSnapshotsDir(),
+                total: snapshots.length,
+                snapshots: snapshots.slice(0, limit),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const revertToSnapshotTool = {
+    name: 'revert_to_snapshot',
+    mutates: (args: any) => !args?.dryRun,
+    description: 'Restore files to their state before a snapshot was taken, undoing that tool call and every later one in the same workspace in a single step. Files created since are removed. Files edited outside tool calls since then are reported as conflicts and left alone unless force is set. The revert is itself snapshotted, so it can be undone.',
+    inputSchema: zodToJsonSchema(revertSchema),
+    async run(args: any) {
+        const parseResult = revertSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { id, force, dryRun } = parseResult.data;
+        try {
+            const plan = await snapshotStore.planRevert(id);
+            const warnings = plan.skipped.map(path => `${path} was too large to snapshot and is left as is`);
+            if (plan.conflicts.length > 0 && !force) {
+                return {
+                    success: false,
+                    errors: plan.conflicts.map(path => `${path} changed outside tool calls since the snapshot; pass force to overwrite it`),
+                    warnings,
+                    output: 'No files were changed',
+                    conflicts: plan.conflicts,
+                };
+            }
+            // Paths are checked up front so a disallowed one stops the revert before anything is written
+            const targets = new Map<string, Buffer | null>();
+            for (const [path, content] of plan.files) targets.set(await validatePath(path, 'write'), content);
+            const summary = `${targets.size} file(s) from ${plan.snapshots.length} snapshot(s)`;
+
+            if (dryRun) {
+                const previews = [];
+                for (const [path, content] of targets) {
+                    const current = await fs.readFile(path).catch(() => null);
+                    if (current === null && content === null) continue;
+                    previews.push(previewChange(path, current, content));
+                }
+                return { success: true, errors: [], warnings, output: `Would revert ${summary}\n${formatPreviews(previews)}`, dryRun: true, snapshots: plan.snapshots, previews };
+            }
+
+            // Removals first: a directory that did not exist may hold restored files
+            const entries = [...targets].sort(([, a], [, b]) => Number(a !== null) - Number(b !== null));
+            for (const [path, content] of entries) {
+                await captureBeforeChange(path);
+                if (content === null) {
+                    const existed = await fs.access(path).then(() => true, () => false);
+                    await fs.rm(path, { recursive: true, force: true });
+                    if (existed) await recordFileChange(path, 'delete');
+                    continue;
+                }
+                await fs.mkdir(dirname(path), { recursive: true });
+                const tempPath = `${path}.${randomBytes(8).toString('hex')}.tmp`;
+                await fs.writeFile(tempPath, content);
+                await fs.rename(tempPath, path);
+                await recordFileChange(path, 'write', content);
+            }
+            if (plan.conflicts.length > 0) warnings.push(`Overwrote changes made outside tool calls: ${plan.conflicts.join(', ')}`);
+            return { success: true, errors: [], warnings, output: `Reverted ${summary}`, snapshots: plan.snapshots, files: [...targets.keys()] };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,193 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import yaml from 'js-yaml';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { parseLocationLines, type Diagnostic } from '../diagnostics/index.js';
+import { detectPackageManager } from './npm.js';
+
+export type TaskRunner = 'make' | 'task' | 'npm';
+
+export interface TaskTarget {
+    name: string;
+    runner: TaskRunner;
+    // File the target is defined in
+    file: string;
+    description?: string;
+}
+
+const inputSchema = z.object({
+    projectPath: z.string(),
+    action: z.enum(['list', 'run']).default('list'),
+    target: z.string().optional().describe('Target, task or script to run'),
+    runner: z.enum(['auto', 'make', 'task', 'npm']).default('auto')
+        .describe('Where to look the target up; auto takes the first of Makefile, Taskfile and package.json that defines it'),
+    args: z.array(z.string()).default([]).describe('Extra arguments: variables such as VAR=1 for make, CLI_ARGS for task, script arguments for npm'),
+    timeout: z.number().default(300000),
+});
+
+// In the order GNU make looks for them
+const MAKEFILES = ['GNUmakefile', 'makefile', 'Makefile'];
+const TASKFILES = ['Taskfile.yml', 'Taskfile.yaml', 'taskfile.yml', 'taskfile.yaml'];
+
+async function firstExisting(dir: string, names: string[]): Promise<string | null> {
+    for (const name of names) {
+        const path = join(dir, name);
+        if (await fs.access(path).then(() => true, () => false)) return path;
+    }
+    return null;
+}
+
+/**
+ * Explicit targets of a Makefile. Descriptions come from a trailing `## text`
+ * (the self-documenting help convention) or the comment lines right above.
+ */
+export function parseMakefileTargets(content: string, file: string): TaskTarget[] {
+    const targets = new Map<string, TaskTarget>();
+    let comment: string[] = [];
+    for (const line of content.split('\n')) {
+        if (line.startsWith('#')) {
+            comment.push(line.replace(/^#+\s?/, '').trim());
+            continue;
+        }
+        // Recipe lines, variable assignments (including := and ::=) and conditionals are not rules
+        const match = /^([A-Za-z0-9_][A-Za-z0-9_.\-/ ]*?)\s*::?(?![:=])(.*)$/.exec(line);
+        if (match && !line.startsWith('\t')) {
+            const helpText = /##\s*(.+)$/.exec(match[2] ?? '')?.[1]?.trim();
+            const description = helpText || comment.filter(Boolean).join(' ');
+            for (const name of (match[1] ?? '').split(/\s+/)) {
+                if (!name || name.includes('%') || targets.has(name)) continue;
+                targets.set(name, { name, runner: 'make', file, ...(description ? { description } : {}) });
+            }
+        }
+        comment = [];
+    }
+    return [...targets.values()];
+}
+
+/**
+ * Tasks of a Taskfile (go-task); internal tasks cannot be run directly and are left out
+ */
+export function parseTaskfileTargets(content: string, file: string): TaskTarget[] {
+    const parsed = yaml.load(content) as { tasks?: Record<string, unknown> } | null;
+    const targets: TaskTarget[] = [];
+    for (const [name, task] of Object.entries(parsed?.tasks ?? {})) {
+        const details = task && typeof task === 'object' && !Array.isArray(task) ? task as { desc?: unknown; summary?: unknown; internal?: unknown } : {};
+        if (details.internal === true) continue;
+        const description = typeof details.desc === 'string' ? details.desc : typeof details.summary === 'string' ? details.summary.split('\n')[0] : undefined;
+        targets.push({ name, runner: 'task', file, ...(description ? { description } : {}) });
+    }
+    return targets;
+}
+
+export function parsePackageScripts(content: string, file: string): TaskTarget[] {
+    const scripts = (JSON.parse(content) as { scripts?: Record<string, unknown> }).scripts ?? {};
+    return Object.entries(scripts)
+        .filter(([, script]) => typeof script === 'string')
+        .map(([name, script]) => ({ name, runner: 'npm' as const, file, description: script as string }));
+}
+
+/**
+ * Every target defined by the project's Makefile, Taskfile and package.json, in that order
+ */
+export async function discoverTasks(projectPath: string): Promise<{ targets: TaskTarget[]; warnings: string[] }> {
+    const targets: TaskTarget[] = [];
+    const warnings: string[] = [];
+    const sources: Array<[string | null, (content: string, file: string) => TaskTarget[]]> = [
+        [await firstExisting(projectPath, MAKEFILES), parseMakefileTargets],
+        [await firstExisting(projectPath, TASKFILES), parseTaskfileTargets],
+        [await firstExisting(projectPath, ['package.json']), parsePackageScripts],
+    ];
+    for (const [file, parse] of sources) {
+        if (!file) continue;
+        try {
+            targets.push(...parse(await fs.readFile(file, 'utf-8'), file));
+        } catch (error: any) {
+            warnings.push(`Could not parse ${file}: ${error.message || String(error)}`);
+        }
+    }
+    return { targets, warnings };
+}
+
+async function taskCommand(target: TaskTarget, args: string[], projectPath: string): Promise<string> {
+    const quotedArgs = args.map(shellQuote).join(' ');
+    if (target.runner === 'make') return `make -f ${shellQuote(target.file)} ${shellQuote(target.name)}${quotedArgs ? ` ${quotedArgs}` : ''}`;
+    if (target.runner === 'task') return `task --taskfile ${shellQuote(target.file)} ${shellQuote(target.name)}${quotedArgs ? ` -- ${quotedArgs}` : ''}`;
+    const manager = await detectPackageManager(projectPath);
+    // npm needs -- to pass arguments to the script; pnpm and yarn forward them as is
+    const separator = manager === 'npm' && quotedArgs ? ' --' : '';
+    return `${manager} run ${shellQuote(target.name)}${separator}${quotedArgs ? ` ${quotedArgs}` : ''}`;
+}
+
+// Compiler-style findings in the output, limited to files that exist so "make: *** [Makefile:3: build]" is not one
+async function findDiagnostics(output: string, cwd: string, source: string): Promise<Diagnostic[]> {
+    const diagnostics: Diagnostic[] = [];
+    for (const diagnostic of parseLocationLines(output, { cwd, source })) {
+        if (await fs.stat(diagnostic.file).then(s => s.isFile(), () => false)) diagnostics.push(diagnostic);
+    }
+    return diagnostics;
+}
+
+export const taskRunnerTool = {
+    name: 'task_runner',
+    // Build and codegen targets write files
+    mutates: (args: any) => args?.action === 'run',
+    description: 'List the targets a project defines in its Makefile, Taskfile.yml and package.json scripts, with their descriptions, or run one of them. Running returns the exit code, duration, output, and any file:line diagnostics found in it.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, action, target, runner, args: extraArgs, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const { targets, warnings } = await discoverTasks(projectPath);
+            const available = targets.filter(t => runner === 'auto' || t.runner === runner);
+            if (action === 'list') {
+                const output = available.length > 0
+                    ? available.map(t => `${t.runner} ${t.name}${t.description ? ` - ${t.description}` : ''}`).join('\n')
+                    : 'No Makefile, Taskfile or package.json targets found';
+                return { success: true, errors: [], warnings, output, targets: available };
+            }
+
+            if (!target) return { success: false, errors: ['target is required to run'], warnings, output: '' };
+            const matches = available.filter(t => t.name === target);
+            const selected = matches[0];
+            if (!selected) {
+                return { success: false, errors: [`Target "${target}" not found; use action list to see the available targets`], warnings, output: '' };
+            }
+            if (matches.length > 1) {
+                warnings.push(`"${target}" is also defined for ${matches.slice(1).map(t => t.runner).join(', ')}; running the ${selected.runner} one (pass runner to choose)`);
+            }
+            const command = await taskCommand(selected, extraArgs, projectPath);
+            const result = await runCommand(command, { cwd: projectPath, timeout, maxBuffer: 16 * 1024 * 1024 });
+            const diagnostics = await findDiagnostics(`${result.stdout}\n${result.stderr}`, projectPath, selected.runner);
+            return {
+                success: result.exitCode === 0,
+                errors: result.exitCode === 0 ? [] : [`${selected.runner} ${selected.name} exited with code ${result.exitCode}${result.stderr ? `: ${result.stderr.trim()}` : ''}`],
+                warnings,
+                output: result.stdout,
+                runner: selected.runner,
+                target: selected.name,
+                command,
+                exitCode: result.exitCode,
+                duration: result.duration,
+                diagnostics,
+                ...(result.limitExceeded ? { limitExceeded: result.limitExceeded } : {}),
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,163 @@
+export interface TemplateVariable {
+    description: string;
+    // May reference other variables: "example.com/{{name}}"
+    default?: string;
+}
+
+export interface ProjectTemplate {
+    description: string;
+    variables: Record<string, TemplateVariable>;
+    // Relative path (itself templated) -> templated content
+    files: Record<string, string>;
+}
+
+const README = `# {{name}}
+
+{{description}}
+`;
+
+export const BUILTIN_TEMPLATES: Record<string, ProjectTemplate> = {
+    go: {
+        description: 'Go module with a main package and a table-driven test',
+        variables: {
+            module: { description: 'Module path', default: 'example.com/{{name}}' },
+            goVersion: { description: 'go directive in go.mod', default: '1.22' },
+        },
+        files: {
+            'go.mod': `module {{module}}
+
+go {{goVersion}}
+`,
+            'main.go': `package main
+
+import "fmt"
+
+func greet(name string) string {
+	if name == "" {
+		name = "world"
+	}
+	return fmt.Sprintf("hello, %s", name)
+}
+
+func main() {
+	fmt.Println(greet(""))
+}
+`,
+            'main_test.go': `package main
+
+import "testing"
+
+func TestGreet(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", "hello, world"},
+		{"gopher", "hello, gopher"},
+	}
+	for _, tt := range tests {
+		if got := greet(tt.name); got != tt.want {
+			t.Errorf("greet(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+`,
+            '.gitignore': `/{{name}}
+*.test
+*.out
+`,
+            'README.md': README,
+        },
+    },
+    python: {
+        description: 'Python package with a src layout, pyproject.toml and pytest tests',
+        variables: {
+            pythonVersion: { description: 'Minimum Python version', default: '3.10' },
+        },
+        files: {
+            'pyproject.toml': `[build-system]
+requires = ["hatchling"]
+build-backend = "hatchling.build"
+
+[project]
+name = "{{name}}"
+version = "0.1.0"
+description = "{{description}}"
+readme = "README.md"
+requires-python = ">={{pythonVersion}}"
+dependencies = []
+
+[project.optional-dependencies]
+dev = ["pytest"]
+
+[tool.pytest.ini_options]
+testpaths = ["tests"]
+pythonpath = ["src"]
+`,
+            'src/{{package}}/__init__.py': `"""{{description}}"""
+
+__version__ = "0.1.0"
+`,
+            'src/{{package}}/core.py': `def greet(name: str = "") -> str:
+    return f"hello, {name or 'world'}"
+`,
+            'tests/test_core.py': `from {{package}}.core import greet
+
+
+def test_greet_default():
+    assert greet() == "hello, world"
+
+
+def test_greet_name():
+    assert greet("python") == "hello, python"
+`,
+            '.gitignore': `__pycache__/
+*.py[cod]
+.venv/
+venv/
+dist/
+*.egg-info/
+.pytest_cache/
+`,
+            'README.md': README,
+        },
+    },
+    node: {
+        description: 'Node.js ESM package tested with the built-in node:test runner',
+        variables: {},
+        files: {
+            'package.json': `{
+  "name": "{{name}}",
+  "version": "0.1.0",
+  "description": "{{description}}",
+  "type": "module",
+  "main": "src/index.js",
+  "scripts": {
+    "start": "node src/index.js",
+    "test": "node --test"
+  }
+}
+`,
+            'src/index.js': `export function greet(name = '') {
+  return \`hello, \${name || 'world'}\`;
+}
+`,
+            'test/index.test.js': `import { test } from 'node:test';
+import assert from 'node:assert/strict';
+import { greet } from '../src/index.js';
+
+test('greets the world by default', () => {
+  assert.equal(greet(), 'hello, world');
+});
+
+test('greets by name', () => {
+  assert.equal(greet('node'), 'hello, node');
+});
+`,
+            '.gitignore': `node_modules/
+coverage/
+`,
+            'README.md': README,
+        },
+    },
+};
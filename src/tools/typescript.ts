@@ -4,6 +4,9 @@ import Config from '../config/index.js';
 import { dirname, join } from 'path';
 import { promises as fs } from 'fs';
 import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+import { type Diagnostic, countBySeverity, parseTscOutput } from '../diagnostics/index.js';
 
 // Define the input schema for the tool
 const inputSchema = z.object({
@@ -11,8 +14,15 @@ const inputSchema = z.object({
     tsConfigPath: z.string().optional().describe('Optional path to tsconfig.json (defaults to searching up the directory tree)'),
 });
 
+const tscCheckSchema = z.object({
+    projectPath: z.string().describe('Project directory, any file inside it, or a tsconfig.json'),
+    tsConfigPath: z.string().optional().describe('Optional path to tsconfig.json (defaults to searching up the directory tree)'),
+    timeout: z.number().default(300000),
+});
+
 export const typescriptTool = {
     name: 'validate_typescript_file',
+    cacheable: true,
     description: 'Validate and compile a TypeScript file, checking for syntax and type errors',
     inputSchema: zodToJsonSchema(inputSchema),
 
@@ -97,4 +107,56 @@ export const typescriptTool = {
             };
         }
     },
-}; 
\ No newline at end of file
+};
+
+export const tscCheckTool = {
+    name: 'tsc_check',
+    cacheable: true,
+    description: 'Type-check a TypeScript project with tsc --noEmit (tsconfig.json is discovered up the directory tree) and return compiler errors as structured diagnostics (file, line, column, TS error code as rule).',
+    inputSchema: zodToJsonSchema(tscCheckSchema),
+    async run(args: any) {
+        const parseResult = tscCheckSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { projectPath, tsConfigPath, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(projectPath) || (tsConfigPath && !config.isPathAllowed(tsConfigPath))) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const stats = await fs.stat(projectPath);
+            const startDir = stats.isDirectory() ? projectPath : dirname(projectPath);
+            const configPath = tsConfigPath
+                ?? (projectPath.endsWith('.json') && stats.isFile() ? projectPath : await findUp(startDir, 'tsconfig.json'));
+            if (!configPath) {
+                return { success: false, errors: ['tsconfig.json not found'], warnings: [], output: '' };
+            }
+            const cwd = dirname(configPath);
+            // Prefer the project's own compiler version
+            const localTsc = await findUp(cwd, join('node_modules', '.bin', 'tsc'));
+            const tsc = localTsc ? shellQuote(localTsc) : 'npx tsc';
+            const result = await runCommand(`${tsc} --noEmit --pretty false --project ${shellQuote(configPath)}`, { cwd, timeout, maxBuffer: 16 * 1024 * 1024 });
+            const diagnostics: Diagnostic[] = parseTscOutput(result.stdout + result.stderr, cwd);
+            if (result.exitCode !== 0 && diagnostics.length === 0) {
+                return { success: false, errors: [`tsc failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout, diagnostics };
+            }
+            const counts = countBySeverity(diagnostics);
+            return {
+                success: counts.error === 0,
+                errors: counts.error > 0 ? [`tsc reported ${counts.error} error(s)`] : [],
+                warnings: [],
+                output: `tsc: ${counts.error} error(s) in ${new Set(diagnostics.map(d => d.file)).size} file(s)`,
+                tsConfigPath: configPath,
+                diagnostics,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,247 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { dirname, isAbsolute, join, resolve } from 'path';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { findUp } from '../utils/paths.js';
+import { findVenvPython } from './python.js';
+
+export type UnusedKind = 'function' | 'method' | 'type' | 'class' | 'field' | 'variable' | 'constant' | 'import' | 'attribute' | 'property' | 'unreachable';
+
+export interface UnusedSymbol {
+    name: string;
+    kind: UnusedKind;
+    file: string;
+    line: number;
+    column?: number;
+    message: string;
+    // vulture's estimate that the code is really dead; Go analyses are exact
+    confidence: number;
+    source: 'staticcheck' | 'deadcode' | 'vulture';
+}
+
+const inputSchema = z.object({
+    path: z.string().describe('Go module or package directory, or a Python package, file or project directory'),
+    language: z.enum(['auto', 'go', 'python']).default('auto'),
+    goAnalyzer: z.enum(['staticcheck', 'deadcode']).default('staticcheck')
+        .describe('staticcheck U1000 reports unused declarations of any kind; deadcode reports functions unreachable from main'),
+    packages: z.string().default('./...').describe('Go package pattern'),
+    includeTests: z.boolean().default(true).describe('Count uses from test files (Go); a symbol used only by tests is otherwise reported'),
+    minConfidence: z.number().int().min(0).max(100).default(60).describe('vulture: lowest confidence to report'),
+    exclude: z.array(z.string()).default([]).describe('vulture: file patterns to skip'),
+    ignoreNames: z.array(z.string()).default([]).describe('vulture: names (globs) that are used implicitly, such as framework hooks'),
+    timeout: z.number().default(300000),
+});
+
+// --- Go ---
+
+const STATICCHECK_KINDS: Record<string, UnusedKind> = { func: 'function', type: 'type', field: 'field', var: 'variable', const: 'constant' };
+
+function goFunctionKind(name: string): UnusedKind {
+    // Methods come as "T.m", "(T).m" or "(*T).m"
+    return name.includes('.') ? 'method' : 'function';
+}
+
+/**
+ * Parse `staticcheck -checks U1000 -f json`: one JSON object per line, with
+ * messages like "func helper is unused" or "field name is unused"
+ */
+export function parseStaticcheckUnused(output: string, cwd: string): { unused: UnusedSymbol[]; errors: string[] } {
+    const unused: UnusedSymbol[] = [];
+    const errors: string[] = [];
+    for (const line of output.split('\n')) {
+        if (!line.trim().startsWith('{')) continue;
+        let issue: any;
+        try {
+            issue = JSON.parse(line);
+        } catch {
+            continue;
+        }
+        const location = issue.location ?? {};
+        const file = String(location.file ?? '');
+        if (issue.code !== 'U1000') {
+            // "compile" issues mean the package did not type-check, so the analysis is incomplete
+            if (issue.code === 'compile') errors.push(`${file}:${location.line}: ${issue.message}`);
+            continue;
+        }
+        const message = String(issue.message ?? '');
+        const match = /^(\w+) (.+) is unused/.exec(message);
+        const name = match?.[2] ?? message;
+        const kind = match?.[1] === 'func' ? goFunctionKind(name) : STATICCHECK_KINDS[match?.[1] ?? ''] ?? 'function';
+        unused.push({
+            name,
+            kind,
+            file: file && !isAbsolute(file) ? resolve(cwd, file) : file,
+            line: Number(location.line ?? 0),
+            ...(location.column ? { column: Number(location.column) } : {}),
+            message,
+            confidence: 100,
+            source: 'staticcheck',
+        });
+    }
+    return { unused, errors };
+}
+
+/**
+ * Parse `deadcode -json`: packages with the functions unreachable from main.
+ * Generated functions are left out since nobody edits them by hand.
+ */
+export function parseDeadcodeJson(output: string): UnusedSymbol[] {
+    const start = output.indexOf('[');
+    if (start < 0) return [];
+    const packages: any[] = JSON.parse(output.slice(start));
+    const unused: UnusedSymbol[] = [];
+    for (const pkg of packages ?? []) {
+        for (const fn of pkg.Funcs ?? []) {
+            if (fn.Generated) continue;
+            const name = String(fn.Name ?? '');
+            unused.push({
+                name,
+                kind: goFunctionKind(name),
+                file: String(fn.Position?.File ?? ''),
+                line: Number(fn.Position?.Line ?? 0),
+                ...(fn.Position?.Col ? { column: Number(fn.Position.Col) } : {}),
+                message: `unreachable func: ${name} (${pkg.Path})`,
+                confidence: 100,
+                source: 'deadcode',
+            });
+        }
+    }
+    return unused;
+}
+
+// --- Python ---
+
+const VULTURE_KINDS: Record<string, UnusedKind> = {
+    function: 'function',
+    method: 'method',
+    class: 'class',
+    variable: 'variable',
+    import: 'import',
+    attribute: 'attribute',
+    property: 'property',
+};
+
+/**
+ * Parse vulture's report lines:
+ *   src/app.py:12: unused function 'helper' (60% confidence)
+ *   src/app.py:30: unreachable code after 'return' (100% confidence, 2 lines)
+ */
+export function parseVultureOutput(output: string, cwd: string): UnusedSymbol[] {
+    const unused: UnusedSymbol[] = [];
+    for (const line of output.split('\n')) {
+        const match = /^(.+?):(\d+): (.+) \((\d+)% confidence(?:, \d+ lines?)?\)$/.exec(line.trim());
+        if (!match) continue;
+        const message = match[3] ?? '';
+        const symbol = /^unused (\w+) '(.+)'$/.exec(message);
+        const file = match[1] ?? '';
+        unused.push({
+            name: symbol?.[2] ?? '',
+            kind: symbol ? VULTURE_KINDS[symbol[1] ?? ''] ?? 'variable' : 'unreachable',
+            file: isAbsolute(file) ? file : resolve(cwd, file),
+            line: Number(match[2]),
+            message,
+            confidence: Number(match[4]),
+            source: 'vulture',
+        });
+    }
+    return unused;
+}
+
+async function detectLanguage(path: string, dir: string): Promise<'go' | 'python' | null> {
+    if (path.endsWith('.go')) return 'go';
+    if (path.endsWith('.py')) return 'python';
+    if (await findUp(dir, 'go.mod')) return 'go';
+    for (const marker of ['pyproject.toml', 'setup.py', 'setup.cfg']) {
+        if (await fs.access(join(dir, marker)).then(() => true, () => false)) return 'python';
+    }
+    return (await fs.readdir(dir)).some(f => f.endsWith('.py')) ? 'python' : null;
+}
+
+function summarize(unused: UnusedSymbol[]): Record<string, number> {
+    const counts: Record<string, number> = {};
+    for (const symbol of unused) counts[symbol.kind] = (counts[symbol.kind] ?? 0) + 1;
+    return counts;
+}
+
+export const findUnusedTool = {
+    name: 'find_unused',
+    cacheable: true,
+    description: 'Find dead code: unused functions, methods, types, fields, variables, constants and imports. Go uses staticcheck\'s unused check (U1000) or deadcode (functions unreachable from main); Python uses vulture, with its confidence per finding. Run it after a refactor to see what was left behind.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { path, goAnalyzer, packages, includeTests, minConfidence, exclude, ignoreNames, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(path)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const stats = await fs.stat(path);
+            const dir = stats.isDirectory() ? path : dirname(path);
+            const language = parseResult.data.language === 'auto' ? await detectLanguage(path, dir) : parseResult.data.language;
+            if (!language) {
+                return { success: false, errors: ['Could not detect the project language; pass language explicitly'], warnings: [], output: '' };
+            }
+
+            let unused: UnusedSymbol[];
+            const warnings: string[] = [];
+            if (language === 'go') {
+                const command = goAnalyzer === 'staticcheck'
+                    ? `staticcheck -checks U1000 -f json -tests=${includeTests} ${packages}`
+                    : `deadcode -json${includeTests ? ' -test' : ''} ${packages}`;
+                const result = await runCommand(command, { cwd: dir, timeout, maxBuffer: 32 * 1024 * 1024 });
+                if (goAnalyzer === 'staticcheck') {
+                    const parsed = parseStaticcheckUnused(result.stdout, dir);
+                    // staticcheck exits 1 when it reports anything
+                    if (result.exitCode !== 0 && parsed.unused.length === 0 && parsed.errors.length === 0) {
+                        return { success: false, errors: [`staticcheck failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout };
+                    }
+                    if (parsed.errors.length > 0) {
+                        return { success: false, errors: ['Packages do not compile; fix these first', ...parsed.errors], warnings: [], output: result.stdout, unused: parsed.unused };
+                    }
+                    unused = parsed.unused;
+                } else {
+                    if (result.exitCode !== 0) {
+                        return { success: false, errors: [`deadcode failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout };
+                    }
+                    unused = parseDeadcodeJson(result.stdout);
+                }
+            } else {
+                const venvPython = await findVenvPython(dir);
+                let command = venvPython ? `${shellQuote(venvPython)} -m vulture` : 'vulture';
+                command += ` ${shellQuote(resolve(path))} --min-confidence ${minConfidence}`;
+                if (exclude.length > 0) command += ` --exclude ${shellQuote(exclude.join(','))}`;
+                if (ignoreNames.length > 0) command += ` --ignore-names ${shellQuote(ignoreNames.join(','))}`;
+                const result = await runCommand(command, { cwd: dir, timeout, maxBuffer: 16 * 1024 * 1024 });
+                // Exit code 3 means dead code was found; 1 and 2 are bad input or arguments
+                if (result.exitCode !== 0 && result.exitCode !== 3) {
+                    return { success: false, errors: [`vulture failed: ${result.stderr || result.stdout}`], warnings: [], output: result.stdout };
+                }
+                unused = parseVultureOutput(result.stdout, dir);
+                if (result.stderr.trim()) warnings.push(result.stderr.trim());
+            }
+
+            unused.sort((a, b) => a.file.localeCompare(b.file) || a.line - b.line);
+            return {
+                success: true,
+                errors: [],
+                warnings: unused.length > 0 ? [`${unused.length} unused symbol(s) found`, ...warnings] : warnings,
+                output: unused.map(u => `${u.file}:${u.line}: ${u.message}`).join('\n') || 'No unused code found',
+                summary: summarize(unused),
+                unused,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -2,6 +2,7 @@ import { z } from 'zod';
 import { runCommand } from '../utils/command.js';
 import Config from '../config/index.js';
 import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
 
 const uvInitSchema = z.object({
     projectPath: z.string(),
@@ -38,6 +39,8 @@ const uvVenvSchema = z.object({
 
 export const uvInitTool = {
     name: 'uv_init',
+    binaries: ['uv'],
+    mutates: true,
     description: 'Initialize a new Python project using uv',
     inputSchema: zodToJsonSchema(uvInitSchema),
     async run(args: any) {
@@ -50,7 +53,7 @@ export const uvInitTool = {
             return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
         }
         try {
-            const command = `uv init ${projectName}`;
+            const command = `uv init ${shellQuote(projectName)}`;
             const result = await runCommand(command, { cwd: projectPath, timeout });
             return { success: result.exitCode === 0, errors: result.stderr ? [result.stderr] : [], warnings: [], output: result.stdout };
         } catch (error: any) {
@@ -61,6 +64,8 @@ export const uvInitTool = {
 
 export const uvAddTool = {
     name: 'uv_add',
+    binaries: ['uv'],
+    mutates: true,
     description: 'Add Python dependencies to a project using uv',
     inputSchema: zodToJsonSchema(uvAddSchema),
     async run(args: any) {
@@ -73,7 +78,7 @@ export const uvAddTool = {
             return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
         }
         try {
-            const command = `uv add ${packages.join(' ')}`;
+            const command = `uv add ${packages.map(shellQuote).join(' ')}`;
             const result = await runCommand(command, { cwd: projectPath, timeout });
             return { success: result.exitCode === 0, errors: result.stderr ? [result.stderr] : [], warnings: [], output: result.stdout };
         } catch (error: any) {
@@ -84,6 +89,9 @@ export const uvAddTool = {
 
 export const uvRunTool = {
     name: 'uv_run',
+    binaries: ['uv'],
+    // command is a shell command line
+    dangerous: true,
     description: 'Run a command in the uv environment',
     inputSchema: zodToJsonSchema(uvRunSchema),
     async run(args: any) {
@@ -107,6 +115,8 @@ export const uvRunTool = {
 
 export const uvLockTool = {
     name: 'uv_lock',
+    binaries: ['uv'],
+    mutates: true,
     description: 'Lock Python dependencies using uv',
     inputSchema: zodToJsonSchema(uvLockSchema),
     async run(args: any) {
@@ -130,6 +140,8 @@ export const uvLockTool = {
 
 export const uvSyncTool = {
     name: 'uv_sync',
+    binaries: ['uv'],
+    mutates: true,
     description: 'Sync Python dependencies using uv',
     inputSchema: zodToJsonSchema(uvSyncSchema),
     async run(args: any) {
@@ -153,6 +165,8 @@ export const uvSyncTool = {
 
 export const uvVenvTool = {
     name: 'uv_venv',
+    binaries: ['uv'],
+    mutates: true,
     description: 'Manage the uv virtual environment',
     inputSchema: zodToJsonSchema(uvVenvSchema),
     async run(args: any) {
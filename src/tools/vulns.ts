@@ -0,0 +1,303 @@
+import { z } from 'zod';
+import { runCommand } from '../utils/command.js';
+import Config from '../config/index.js';
+import { join } from 'path';
+import { promises as fs } from 'fs';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { shellQuote } from '../utils/shell.js';
+import { splitJsonObjects } from '../utils/json.js';
+import { findVenvPython } from './python.js';
+
+export type VulnerabilitySeverity = 'critical' | 'high' | 'medium' | 'low' | 'unknown';
+
+export interface VulnerabilityRecord {
+    package: string;
+    // Installed version, when the scanner reports it
+    version: string | null;
+    // Preferred identifier: CVE, then GHSA, then the scanner's native id
+    id: string;
+    aliases: string[];
+    severity: VulnerabilitySeverity;
+    fixedVersion: string | null;
+    summary: string;
+    url: string | null;
+    source: 'govulncheck' | 'npm audit' | 'pip-audit';
+}
+
+const SEVERITY_RANK: Record<VulnerabilitySeverity, number> = { unknown: 4, critical: 4, high: 3, medium: 2, low: 1 };
+
+function normalizeSeverity(value: unknown): VulnerabilitySeverity {
+    switch (String(value ?? '').toLowerCase()) {
+        case 'critical': return 'critical';
+        case 'high': return 'high';
+        case 'moderate':
+        case 'medium': return 'medium';
+        case 'low':
+        case 'info': return 'low';
+        default: return 'unknown';
+    }
+}
+
+function preferredId(nativeId: string, aliases: string[]): string {
+    return aliases.find(a => a.startsWith('CVE-')) ?? aliases.find(a => a.startsWith('GHSA-')) ?? nativeId;
+}
+
+/**
+ * Records at or above the threshold; unknown severity always counts, since it cannot be ruled out
+ */
+export function filterBySeverity(records: VulnerabilityRecord[], threshold: Exclude<VulnerabilitySeverity, 'unknown'>): VulnerabilityRecord[] {
+    return records.filter(r => SEVERITY_RANK[r.severity] >= SEVERITY_RANK[threshold]);
+}
+
+// --- Go ---
+
+/**
+ * Parse `govulncheck -json`. Only findings whose call trace reaches a vulnerable
+ * symbol are reported, one record per vulnerability and module.
+ */
+export function parseGovulncheckOutput(output: string): VulnerabilityRecord[] {
+    const osvs = new Map<string, any>();
+    const findings: any[] = [];
+    for (const chunk of splitJsonObjects(output)) {
+        let message: any;
+        try {
+            message = JSON.parse(chunk);
+        } catch {
+            continue;
+        }
+        if (message.osv) osvs.set(message.osv.id, message.osv);
+        if (message.finding) findings.push(message.finding);
+    }
+    const records = new Map<string, VulnerabilityRecord>();
+    for (const finding of findings) {
+        const trace: any[] = finding.trace ?? [];
+        const frame = trace[0];
+        // Module- or package-level findings mean the code is imported but the vulnerable symbol is never called
+        if (!frame?.function) continue;
+        const osv = osvs.get(finding.osv) ?? {};
+        const key = `${finding.osv}\0${frame.module}`;
+        if (records.has(key)) continue;
+        const aliases: string[] = osv.aliases ?? [];
+        records.set(key, {
+            package: String(frame.module ?? ''),
+            version: frame.version ?? null,
+            id: preferredId(String(finding.osv), aliases),
+            aliases: [String(finding.osv), ...aliases.filter(a => a !== finding.osv)],
+            severity: 'unknown',
+            fixedVersion: finding.fixed_version ?? null,
+            summary: String(osv.summary ?? osv.details ?? ''),
+            url: osv.database_specific?.url ?? `https://pkg.go.dev/vuln/${finding.osv}`,
+            source: 'govulncheck',
+        });
+    }
+    return [...records.values()];
+}
+
+// --- npm ---
+
+/**
+ * Parse `npm audit --json` (npm 7+). Each advisory becomes a record for the package
+ * it was filed against; entries that are only vulnerable through a dependency are skipped.
+ */
+export function parseNpmAuditOutput(output: string): VulnerabilityRecord[] {
+    const start = output.indexOf('{');
+    if (start < 0) return [];
+    const report = JSON.parse(output.slice(start));
+    const records: VulnerabilityRecord[] = [];
+    for (const [name, entry] of Object.entries<any>(report.vulnerabilities ?? {})) {
+        for (const via of entry.via ?? []) {
+            if (typeof via !== 'object' || !via) continue;
+            const url: string | null = via.url ?? null;
+            const ghsa = url ? /GHSA-[\w-]+/.exec(url)?.[0] : undefined;
+            const fix = entry.fixAvailable;
+            records.push({
+                package: String(via.name ?? name),
+                version: null,
+                id: ghsa ?? String(via.source ?? ''),
+                aliases: ghsa ? [ghsa] : [],
+                severity: normalizeSeverity(via.severity ?? entry.severity),
+                fixedVersion: fix && typeof fix === 'object' && fix.name === name ? String(fix.version) : null,
+                summary: String(via.title ?? ''),
+                url,
+                source: 'npm audit',
+            });
+        }
+    }
+    return records;
+}
+
+// --- Python ---
+
+/**
+ * Parse `pip-audit -f json` (both the `{ dependencies: [...] }` and the older bare-list format)
+ */
+export function parsePipAuditOutput(output: string): VulnerabilityRecord[] {
+    const start = output.search(/[[{]/);
+    if (start < 0) return [];
+    const report = JSON.parse(output.slice(start));
+    const dependencies: any[] = Array.isArray(report) ? report : report.dependencies ?? [];
+    const records: VulnerabilityRecord[] = [];
+    for (const dependency of dependencies) {
+        for (const vuln of dependency.vulns ?? []) {
+            const aliases: string[] = vuln.aliases ?? [];
+            records.push({
+                package: String(dependency.name ?? ''),
+                version: dependency.version ?? null,
+                id: preferredId(String(vuln.id ?? ''), aliases),
+                aliases: [String(vuln.id ?? ''), ...aliases],
+                severity: 'unknown',
+                fixedVersion: vuln.fix_versions?.[0] ?? null,
+                summary: String(vuln.description ?? ''),
+                url: null,
+                source: 'pip-audit',
+            });
+        }
+    }
+    return records;
+}
+
+// --- Tools ---
+
+const failOnSchema = z.enum(['critical', 'high', 'medium', 'low']).default('low')
+    .describe('Lowest severity that fails the check; records with unknown severity always fail');
+
+const goVulncheckSchema = z.object({
+    projectPath: z.string().describe('Go module directory'),
+    packages: z.string().default('./...').describe('Space-separated package patterns'),
+    timeout: z.number().default(300000),
+});
+
+const npmAuditSchema = z.object({
+    projectPath: z.string(),
+    omitDev: z.boolean().default(false).describe('Skip devDependencies'),
+    failOn: failOnSchema,
+    timeout: z.number().default(120000),
+});
+
+const pipAuditSchema = z.object({
+    projectPath: z.string(),
+    requirementsFile: z.string().optional().describe('Audit a requirements file instead of the project environment'),
+    timeout: z.number().default(300000),
+});
+
+function validationFailure(error: z.ZodError) {
+    return {
+        success: false,
+        errors: error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+        warnings: [] as string[],
+        output: '',
+    };
+}
+
+function vulnerabilityResult(records: VulnerabilityRecord[], failing: VulnerabilityRecord[], scanner: string) {
+    const counts: Record<VulnerabilitySeverity, number> = { critical: 0, high: 0, medium: 0, low: 0, unknown: 0 };
+    for (const record of records) counts[record.severity]++;
+    return {
+        success: failing.length === 0,
+        errors: failing.length > 0 ? [`${scanner} found ${failing.length} vulnerability(ies): ${[...new Set(failing.map(r => `${r.package} (${r.id})`))].join(', ')}`] : [],
+        warnings: records.length > failing.length ? [`${records.length - failing.length} vulnerability(ies) below the failure threshold`] : [],
+        output: `${records.length} vulnerability(ies) found`,
+        summary: counts,
+        vulnerabilities: records,
+    };
+}
+
+export const goVulncheckTool = {
+    name: 'go_vulncheck',
+    binaries: ['govulncheck'],
+    description: 'Scan a Go module with govulncheck and return normalized vulnerability records (package, version, CVE/GHSA id, severity, fixed version) for vulnerabilities the code actually calls.',
+    inputSchema: zodToJsonSchema(goVulncheckSchema),
+    async run(args: any) {
+        const parseResult = goVulncheckSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { projectPath, packages, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const result = await runCommand(`govulncheck -json ${packages.split(/\s+/).filter(Boolean).map(shellQuote).join(' ')}`, { cwd: projectPath, timeout, maxBuffer: 32 * 1024 * 1024 });
+            if (result.exitCode !== 0 && !result.stdout.includes('"config"')) {
+                return { success: false, errors: [`govulncheck failed: ${result.stderr || result.stdout}`], warnings: [], output: '' };
+            }
+            const records = parseGovulncheckOutput(result.stdout);
+            return vulnerabilityResult(records, records, 'govulncheck');
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const npmAuditTool = {
+    name: 'npm_audit',
+    binaries: ['npm'],
+    description: 'Run npm audit and return normalized vulnerability records (package, installed version, GHSA id, severity, fixed version). Fails when any record meets the failOn severity.',
+    inputSchema: zodToJsonSchema(npmAuditSchema),
+    async run(args: any) {
+        const parseResult = npmAuditSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { projectPath, omitDev, failOn, timeout } = parseResult.data;
+        if (!Config.getInstance().isPathAllowed(projectPath)) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            await fs.access(join(projectPath, 'package.json'));
+            const result = await runCommand(`npm audit --json${omitDev ? ' --omit=dev' : ''}`, { cwd: projectPath, timeout, maxBuffer: 32 * 1024 * 1024 });
+            let records: VulnerabilityRecord[];
+            try {
+                records = parseNpmAuditOutput(result.stdout);
+            } catch {
+                return { success: false, errors: [`npm audit failed: ${result.stderr || result.stdout}`], warnings: [], output: '' };
+            }
+            // Both exit non-zero when vulnerabilities are found, so only an empty report means the run failed
+            if (result.exitCode !== 0 && records.length === 0) {
+                return { success: false, errors: [`npm audit failed: ${result.stderr || result.stdout}`], warnings: [], output: '' };
+            }
+            // npm audit reports affected ranges only; read installed versions from node_modules
+            for (const record of records) {
+                try {
+                    const pkg = JSON.parse(await fs.readFile(join(projectPath, 'node_modules', record.package, 'package.json'), 'utf8'));
+                    record.version = pkg.version ?? null;
+                } catch { /* not installed at top level */ }
+            }
+            return vulnerabilityResult(records, filterBySeverity(records, failOn), 'npm audit');
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const pipAuditTool = {
+    name: 'pip_audit',
+    binaries: ['pip-audit'],
+    description: 'Run pip-audit on the project environment (venv or .venv when present) or a requirements file and return normalized vulnerability records (package, version, CVE/GHSA id, fixed version).',
+    inputSchema: zodToJsonSchema(pipAuditSchema),
+    async run(args: any) {
+        const parseResult = pipAuditSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { projectPath, requirementsFile, timeout } = parseResult.data;
+        const config = Config.getInstance();
+        if (!config.isPathAllowed(projectPath) || (requirementsFile && !config.isPathAllowed(requirementsFile))) {
+            return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+        }
+        try {
+            const venvPython = await findVenvPython(projectPath);
+            let command = venvPython ? `${shellQuote(venvPython)} -m pip_audit` : 'pip-audit';
+            command += ' -f json --progress-spinner off';
+            if (requirementsFile) command += ` -r ${shellQuote(requirementsFile)}`;
+            const result = await runCommand(command, { cwd: projectPath, timeout, maxBuffer: 32 * 1024 * 1024 });
+            let records: VulnerabilityRecord[];
+            try {
+                records = parsePipAuditOutput(result.stdout);
+            } catch {
+                return { success: false, errors: [`pip-audit failed: ${result.stderr || result.stdout}`], warnings: [], output: '' };
+            }
+            // Both exit non-zero when vulnerabilities are found, so only an empty report means the run failed
+            if (result.exitCode !== 0 && records.length === 0) {
+                return { success: false, errors: [`pip-audit failed: ${result.stderr || result.stdout}`], warnings: [], output: '' };
+            }
+            return vulnerabilityResult(records, records, 'pip-audit');
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,73 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import Config from '../config/index.js';
+import { getEffectiveConfig } from '../config/project.js';
+import { watchManager, type WatchSession } from '../watch/index.js';
+
+const inputSchema = z.object({
+    action: z.enum(['start', 'stop', 'status', 'list']).default('start'),
+    path: z.string().optional().describe('Project directory to watch (start)'),
+    pipeline: z.string().default('default').describe('Pipeline from .code-feedback.yaml to run on changes (start)'),
+    debounceMs: z.number().int().min(50).max(60000).default(500).describe('Quiet time after the last change before the pipeline runs'),
+    id: z.string().optional().describe('Watch id returned by start (stop, status)'),
+});
+
+function describeSession(session: WatchSession): string {
+    const latest = session.latest ? `: ${session.latest.verdict.summary}` : ': waiting for the first run';
+    return `${session.id} ${session.pipeline} on ${session.root}${latest}`;
+}
+
+export const watchWorkspaceTool = {
+    name: 'watch_workspace',
+    description: 'Watch a project and re-run a pipeline whenever its files change (debounced; files git ignores and saves that leave content unchanged do not count). Each run is pushed to connected clients as an MCP log notification (logger "code-feedback/watch") carrying the verdict and the changed files; status returns the latest full result. Actions: start, stop, status, list.',
+    inputSchema: zodToJsonSchema(inputSchema),
+    async run(args: any) {
+        const parseResult = inputSchema.safeParse(args);
+        if (!parseResult.success) {
+            return {
+                success: false,
+                errors: parseResult.error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+                warnings: [],
+                output: ''
+            };
+        }
+        const { action, path, pipeline, debounceMs, id } = parseResult.data;
+        try {
+            if (action === 'list') {
+                const sessions = watchManager.list();
+                return {
+                    success: true,
+                    errors: [],
+                    warnings: [],
+                    output: sessions.length > 0 ? sessions.map(describeSession).join('\n') : 'Nothing is being watched',
+                    watches: sessions.map(s => ({ ...s.status(), ...(s.latest ? { verdict: s.latest.verdict } : {}) })),
+                };
+            }
+            if (action === 'stop' || action === 'status') {
+                if (!id) return { success: false, errors: [`${action} needs the watch id`], warnings: [], output: '' };
+                const session = watchManager.get(id);
+                if (!session) return { success: false, errors: [`Unknown watch ${id}`], warnings: [], output: '' };
+                if (action === 'stop') {
+                    watchManager.stop(id);
+                    return { success: true, errors: [], warnings: [], output: `Stopped ${id}` };
+                }
+                return { success: true, errors: [], warnings: [], output: describeSession(session), watch: session.status(), latest: session.latest ?? null };
+            }
+
+            if (!path) return { success: false, errors: ['start needs a path'], warnings: [], output: '' };
+            if (!Config.getInstance().isPathAllowed(path)) {
+                return { success: false, errors: ['Path not allowed'], warnings: [], output: '' };
+            }
+            const effective = await getEffectiveConfig(path);
+            if (!effective.config.pipelines?.[pipeline]) {
+                const available = Object.keys(effective.config.pipelines ?? {});
+                const hint = available.length > 0 ? `available: ${available.join(', ')}` : 'no pipelines are configured';
+                return { success: false, errors: [`Pipeline "${pipeline}" not found (${hint})`], warnings: [], output: '' };
+            }
+            const session = await watchManager.start({ root: path, pipeline, debounceMs });
+            return { success: true, errors: [], warnings: [], output: `Watching ${session.root} as ${session.id}; ${pipeline} runs on every change`, watch: session.status() };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
@@ -0,0 +1,124 @@
+import { z } from 'zod';
+import { zodToJsonSchema } from 'zod-to-json-schema';
+import { workspaceRegistry } from '../workspaces/index.js';
+import { cloneWorkspace } from '../workspaces/clone.js';
+
+const registerSchema = z.object({
+    path: z.string().describe('Project root; must be inside the allowed paths'),
+    id: z.string().optional().describe('Workspace id to use in tool calls; defaults to the directory name'),
+    name: z.string().optional().describe('Human-readable label'),
+});
+
+const cloneSchema = z.object({
+    url: z.string().describe('Remote to clone: https://, ssh://, git:// or user@host:path'),
+    branch: z.string().optional().describe('Branch or tag to check out; the remote default branch when omitted'),
+    depth: z.number().int().positive().optional().describe('Fetch only this many commits of history (shallow clone)'),
+    id: z.string().optional().describe('Workspace id; defaults to the repository name'),
+    name: z.string().optional().describe('Human-readable label'),
+    ttlMinutes: z.number().int().positive().max(7 * 24 * 60).default(60).describe('Minutes until the clone is deleted and unregistered'),
+    provider: z.enum(['github', 'gitlab', 'bitbucket']).optional().describe('Host whose token authenticates HTTPS clones; detected from the URL when omitted'),
+    timeout: z.number().int().positive().optional().describe('Clone timeout in milliseconds (default 10 minutes)'),
+});
+
+const unregisterSchema = z.object({
+    id: z.string(),
+});
+
+function validationFailure(error: z.ZodError) {
+    return {
+        success: false,
+        errors: error.errors.map(e => `Validation error: ${e.path.join('.')} - ${e.message}`),
+        warnings: [] as string[],
+        output: '',
+    };
+}
+
+export const registerWorkspaceTool = {
+    name: 'register_workspace',
+    description: 'Register a project root under an id. Any tool call can then pass `workspace: <id>` with paths relative to that root (or no path at all, meaning the root) instead of absolute paths. Registrations persist across server restarts.',
+    inputSchema: zodToJsonSchema(registerSchema),
+    async run(args: any) {
+        const parseResult = registerSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { path, id, name } = parseResult.data;
+        try {
+            const workspace = await workspaceRegistry.register(path, { ...(id ? { id } : {}), ...(name ? { name } : {}) });
+            return { success: true, errors: [], warnings: [], output: `Workspace ${workspace.id} -> ${workspace.root}`, workspace };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const listWorkspacesTool = {
+    name: 'list_workspaces',
+    description: 'List the registered workspaces (id, root, name) that tool calls can reference with `workspace`.',
+    inputSchema: zodToJsonSchema(z.object({})),
+    async run() {
+        try {
+            const workspaces = await workspaceRegistry.list();
+            return {
+                success: true,
+                errors: [],
+                warnings: [],
+                output: workspaces.length > 0 ? workspaces.map(w => `${w.id}: ${w.root}`).join('\n') : 'No workspaces registered',
+                workspaces,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const cloneWorkspaceTool = {
+    name: 'clone_workspace',
+    binaries: ['git'],
+    // Fetches code from the network
+    dangerous: true,
+    description: 'Clone a remote Git repository into a managed temporary directory and register it as a workspace, so other tools can work on code the server has not seen before via `workspace: <id>`. Private HTTPS remotes use the GitHub, GitLab or Bitbucket token from the environment. The clone is deleted when its TTL passes.',
+    inputSchema: zodToJsonSchema(cloneSchema),
+    async run(args: any) {
+        const parseResult = cloneSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        const { url, branch, depth, id, name, ttlMinutes, provider, timeout } = parseResult.data;
+        try {
+            const cloned = await cloneWorkspace({
+                url,
+                ttlMinutes,
+                ...(branch ? { branch } : {}),
+                ...(depth ? { depth } : {}),
+                ...(id ? { id } : {}),
+                ...(name ? { name } : {}),
+                ...(provider ? { provider } : {}),
+                ...(timeout ? { timeout } : {}),
+            });
+            return {
+                success: true,
+                errors: [],
+                warnings: [],
+                output: `Workspace ${cloned.workspace.id} -> ${cloned.workspace.root} (${cloned.branch}@${cloned.head.slice(0, 12)}, expires ${cloned.workspace.expiresAt})`,
+                ...cloned,
+            };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
+
+export const unregisterWorkspaceTool = {
+    name: 'unregister_workspace',
+    description: 'Remove a registered workspace. Files in it are not touched.',
+    inputSchema: zodToJsonSchema(unregisterSchema),
+    async run(args: any) {
+        const parseResult = unregisterSchema.safeParse(args);
+        if (!parseResult.success) return validationFailure(parseResult.error);
+        try {
+            const removed = await workspaceRegistry.unregister(parseResult.data.id);
+            return removed
+                ? { success: true, errors: [], warnings: [], output: `Workspace ${parseResult.data.id} removed` }
+                : { success: false, errors: [`Unknown workspace ${parseResult.data.id}`], warnings: [], output: '' };
+        } catch (error: any) {
+            return { success: false, errors: [error.message || String(error)], warnings: [], output: '' };
+        }
+    },
+};
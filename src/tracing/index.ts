@@ -0,0 +1,271 @@
+import { AsyncLocalStorage } from 'async_hooks';
+import { randomBytes } from 'crypto';
+import { hostname } from 'os';
+import { logger } from '../utils/logger.js';
+
+export type SpanKind = 'internal' | 'server' | 'client';
+export type AttributeValue = string | number | boolean | string[];
+
+export interface SpanContext {
+    traceId: string;
+    spanId: string;
+}
+
+export interface FinishedSpan extends SpanContext {
+    parentSpanId?: string;
+    name: string;
+    kind: SpanKind;
+    startTimeUnixNano: bigint;
+    endTimeUnixNano: bigint;
+    attributes: Record<string, AttributeValue>;
+    status: { code: 'unset' | 'ok' | 'error'; message?: string };
+}
+
+export interface TracingOptions {
+    // OTLP/HTTP traces endpoint, e.g. http://collector:4318/v1/traces; tracing is off without one
+    endpoint?: string;
+    headers?: Record<string, string>;
+    serviceName?: string;
+    serviceVersion?: string;
+    // Spans buffered before an export is forced
+    maxBatch?: number;
+    flushIntervalMs?: number;
+    export?: (spans: FinishedSpan[]) => Promise<void>;
+}
+
+const KIND_CODES: Record<SpanKind, number> = { internal: 1, server: 2, client: 3 };
+const STATUS_CODES = { unset: 0, ok: 1, error: 2 };
+
+// Span the code running now belongs to
+const spanContext = new AsyncLocalStorage<SpanContext>();
+
+/**
+ * Parse a W3C traceparent header ("00-<trace id>-<span id>-<flags>")
+ */
+export function parseTraceparent(value: unknown): SpanContext | null {
+    if (typeof value !== 'string') return null;
+    const match = /^[\da-f]{2}-([\da-f]{32})-([\da-f]{16})-[\da-f]{2}$/.exec(value.trim().toLowerCase());
+    if (!match || /^0+$/.test(match[1]!) || /^0+$/.test(match[2]!)) return null;
+    return { traceId: match[1]!, spanId: match[2]! };
+}
+
+export function formatTraceparent(context: SpanContext): string {
+    return `00-${context.traceId}-${context.spanId}-01`;
+}
+
+// OTEL_EXPORTER_OTLP_HEADERS="api-key=abc,x-team=dev"
+function parseHeaders(value: string | undefined): Record<string, string> {
+    const headers: Record<string, string> = {};
+    for (const pair of (value ?? '').split(',')) {
+        const index = pair.indexOf('=');
+        if (index > 0) headers[decodeURIComponent(pair.slice(0, index).trim())] = decodeURIComponent(pair.slice(index + 1).trim());
+    }
+    return headers;
+}
+
+/**
+ * Tracing settings from the standard OpenTelemetry environment variables
+ */
+export function tracingOptionsFromEnv(env: NodeJS.ProcessEnv = process.env): TracingOptions {
+    if (env.OTEL_SDK_DISABLED === 'true' || env.OTEL_TRACES_EXPORTER === 'none') return {};
+    const base = env.OTEL_EXPORTER_OTLP_ENDPOINT?.replace(/\/+$/, '');
+    const endpoint = env.OTEL_EXPORTER_OTLP_TRACES_ENDPOINT || (base ? `${base}/v1/traces` : undefined);
+    return {
+        ...(endpoint ? { endpoint } : {}),
+        headers: { ...parseHeaders(env.OTEL_EXPORTER_OTLP_HEADERS), ...parseHeaders(env.OTEL_EXPORTER_OTLP_TRACES_HEADERS) },
+        serviceName: env.OTEL_SERVICE_NAME || 'code-feedback-mcp',
+    };
+}
+
+function toAttributes(attributes: Record<string, AttributeValue>) {
+    return Object.entries(attributes).map(([key, value]) => ({
+        key,
+        value: Array.isArray(value) ? { arrayValue: { values: value.map(v => ({ stringValue: v })) } }
+            : typeof value === 'boolean' ? { boolValue: value }
+                : typeof value === 'number' ? (Number.isInteger(value) ? { intValue: String(value) } : { doubleValue: value })
+                    : { stringValue: value },
+    }));
+}
+
+/**
+ * OTLP/HTTP JSON request body for a batch of spans
+ */
+export function toOtlpJson(spans: FinishedSpan[], resource: Record<string, AttributeValue>) {
+    return {
+        resourceSpans: [{
+            resource: { attributes: toAttributes(resource) },
+            scopeSpans: [{
+                scope: { name: 'code-feedback' },
+                spans: spans.map(span => ({
+                    traceId: span.traceId,
+                    spanId: span.spanId,
+                    ...(span.parentSpanId ? { parentSpanId: span.parentSpanId } : {}),
+                    name: span.name,
+                    kind: KIND_CODES[span.kind],
+                    startTimeUnixNano: span.startTimeUnixNano.toString(),
+                    endTimeUnixNano: span.endTimeUnixNano.toString(),
+                    attributes: toAttributes(span.attributes),
+                    status: { code: STATUS_CODES[span.status.code], ...(span.status.message ? { message: span.status.message } : {}) },
+                })),
+            }],
+        }],
+    };
+}
+
+function nowNanos(): bigint {
+    return BigInt(Date.now()) * 1_000_000n;
+}
+
+export class Span {
+    public readonly context: SpanContext;
+    private readonly parentSpanId: string | undefined;
+    private readonly name: string;
+    private readonly kind: SpanKind;
+    private readonly startTime = nowNanos();
+    private readonly attributes: Record<string, AttributeValue>;
+    private status: FinishedSpan['status'] = { code: 'unset' };
+    private ended = false;
+    private readonly tracer: Tracer;
+
+    constructor(tracer: Tracer, name: string, kind: SpanKind, parent: SpanContext | null, attributes: Record<string, AttributeValue>) {
+        this.tracer = tracer;
+        this.name = name;
+        this.kind = kind;
+        this.parentSpanId = parent?.spanId;
+        this.context = { traceId: parent?.traceId ?? randomBytes(16).toString('hex'), spanId: randomBytes(8).toString('hex') };
+        this.attributes = { ...attributes };
+    }
+
+    public setAttributes(attributes: Record<string, AttributeValue | undefined>): this {
+        for (const [key, value] of Object.entries(attributes)) {
+            if (value !== undefined) this.attributes[key] = value;
+        }
+        return this;
+    }
+
+    public setStatus(code: 'ok' | 'error', message?: string): this {
+        this.status = { code, ...(message ? { message: message.slice(0, 1024) } : {}) };
+        return this;
+    }
+
+    public end(): void {
+        if (this.ended) return;
+        this.ended = true;
+        this.tracer.record({
+            ...this.context,
+            ...(this.parentSpanId ? { parentSpanId: this.parentSpanId } : {}),
+            name: this.name,
+            kind: this.kind,
+            startTimeUnixNano: this.startTime,
+            endTimeUnixNano: nowNanos(),
+            attributes: this.attributes,
+            status: this.status,
+        });
+    }
+}
+
+/**
+ * Minimal OpenTelemetry tracer: spans nest through async context and are
+ * exported in batches to an OTLP/HTTP collector. Without an endpoint, spans
+ * are still created (so trace ids propagate) but nothing is exported.
+ */
+export class Tracer {
+    private options: TracingOptions;
+    private buffer: FinishedSpan[] = [];
+    private timer: NodeJS.Timeout | null = null;
+    private exporting: Promise<void> = Promise.resolve();
+
+    constructor(options: TracingOptions = {}) {
+        this.options = options;
+    }
+
+    public configure(options: TracingOptions): void {
+        this.options = options;
+    }
+
+    public isExporting(): boolean {
+        return Boolean(this.options.export || this.options.endpoint);
+    }
+
+    public getEndpoint(): string | undefined {
+        return this.options.endpoint;
+    }
+
+    /**
+     * Start a span that is not made current; the caller ends it
+     */
+    public startSpan(name: string, options: { kind?: SpanKind; attributes?: Record<string, AttributeValue>; parent?: SpanContext | null } = {}): Span {
+        const parent = options.parent ?? spanContext.getStore() ?? null;
+        return new Span(this, name, options.kind ?? 'internal', parent, options.attributes ?? {});
+    }
+
+    /**
+     * Run fn inside a new span, a child of the current one (or of parent).
+     * The span ends when fn settles and is marked as an error if fn throws.
+     */
+    public async withSpan<T>(name: string, options: { kind?: SpanKind; attributes?: Record<string, AttributeValue>; parent?: SpanContext | null }, fn: (span: Span) => Promise<T>): Promise<T> {
+        const span = this.startSpan(name, options);
+        try {
+            return await spanContext.run(span.context, () => fn(span));
+        } catch (error) {
+            span.setStatus('error', error instanceof Error ? error.message : String(error));
+            throw error;
+        } finally {
+            span.end();
+        }
+    }
+
+    public record(span: FinishedSpan): void {
+        if (!this.isExporting()) return;
+        this.buffer.push(span);
+        if (this.buffer.length >= (this.options.maxBatch ?? 512)) {
+            void this.flush();
+        } else if (!this.timer) {
+            this.timer = setTimeout(() => void this.flush(), this.options.flushIntervalMs ?? 5000);
+            this.timer.unref();
+        }
+    }
+
+    /**
+     * Export buffered spans; failures are logged and the batch is dropped
+     */
+    public flush(): Promise<void> {
+        if (this.timer) {
+            clearTimeout(this.timer);
+            this.timer = null;
+        }
+        const batch = this.buffer;
+        this.buffer = [];
+        if (batch.length === 0) return this.exporting;
+        this.exporting = this.exporting.then(() => this.send(batch)).catch(error => {
+            logger.warn('Failed to export spans', { endpoint: this.options.endpoint, spans: batch.length, error });
+        });
+        return this.exporting;
+    }
+
+    private async send(batch: FinishedSpan[]): Promise<void> {
+        if (this.options.export) return this.options.export(batch);
+        const resource = {
+            'service.name': this.options.serviceName ?? 'code-feedback-mcp',
+            ...(this.options.serviceVersion ? { 'service.version': this.options.serviceVersion } : {}),
+            'host.name': hostname(),
+            'process.pid': process.pid,
+        };
+        const response = await fetch(this.options.endpoint!, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json', ...this.options.headers },
+            body: JSON.stringify(toOtlpJson(batch, resource)),
+            signal: AbortSignal.timeout(10000),
+        });
+        if (!response.ok) throw new Error(`collector responded ${response.status} ${response.statusText}`);
+    }
+}
+
+/**
+ * Trace context of the code running now, if inside a span
+ */
+export function getSpanContext(): SpanContext | undefined {
+    return spanContext.getStore();
+}
+
+export const tracer = new Tracer(tracingOptionsFromEnv());
@@ -0,0 +1,207 @@
+import { createServer as createHttpServer, type IncomingMessage, type ServerResponse, type Server as HttpServer } from 'http';
+import { randomUUID, timingSafeEqual } from 'crypto';
+import type { Server } from '@modelcontextprotocol/sdk/server/index.js';
+import { StreamableHTTPServerTransport } from '@modelcontextprotocol/sdk/server/streamableHttp.js';
+import { SSEServerTransport } from '@modelcontextprotocol/sdk/server/sse.js';
+import { isInitializeRequest } from '@modelcontextprotocol/sdk/types.js';
+import { metrics } from '../metrics/index.js';
+import { logger } from '../utils/logger.js';
+import { authenticateClient, type ApiClient, type ApiKeysFile } from '../quota/index.js';
+
+export interface HttpServerOptions {
+  host?: string;
+  port: number;
+  // Required as `Authorization: Bearer <token>` on every MCP request when set
+  token?: string;
+  // Further per-client keys, each with its own rate limits and quotas
+  apiKeys?: ApiKeysFile;
+  // Builds a fresh MCP server per session, for the client that opened it
+  createMcpServer: (client: ApiClient) => Server;
+  // Handles POST /webhooks/github and /webhooks/gitlab, which authenticate by signature instead of bearer token
+  webhooks?: (provider: 'github' | 'gitlab', headers: IncomingMessage['headers'], body: Buffer) => Promise<{ status: number; body: unknown }>;
+}
+
+const MAX_BODY_BYTES = 4 * 1024 * 1024;
+
+/**
+ * Parse a listen address such as ":8080", "8080", "127.0.0.1:8080" or "[::1]:8080"
+ */
+export function parseListenAddress(address: string): { host?: string; port: number } {
+  const trimmed = address.trim();
+  const match = /^\d+$/.test(trimmed) ? ['', undefined, undefined, trimmed] : /^(?:\[([^\]]+)\]|([^:]*)):(\d+)$/.exec(trimmed);
+  const port = Number(match?.[3]);
+  if (!match || !Number.isInteger(port) || port > 65535) {
+    throw new Error(`Invalid listen address: ${address}`);
+  }
+  const host = match[1] || match[2];
+  return host ? { host, port } : { port };
+}
+
+/**
+ * Constant-time bearer token check; always passes when no token is configured
+ */
+export function isAuthorized(header: string | undefined, token: string | undefined): boolean {
+  if (!token) return true;
+  const match = /^Bearer\s+(.+)$/i.exec(header || '');
+  if (!match) return false;
+  const given = Buffer.from((match[1] ?? '').trim());
+  const expected = Buffer.from(token);
+  return given.length === expected.length && timingSafeEqual(given, expected);
+}
+
+function sendJson(res: ServerResponse, status: number, body: unknown, headers: Record<string, string> = {}): void {
+  res.writeHead(status, { 'Content-Type': 'application/json', ...headers });
+  res.end(JSON.stringify(body));
+}
+
+function sendRpcError(res: ServerResponse, status: number, message: string): void {
+  sendJson(res, status, { jsonrpc: '2.0', error: { code: -32000, message }, id: null });
+}
+
+async function readBody(req: IncomingMessage): Promise<Buffer> {
+  const chunks: Buffer[] = [];
+  let size = 0;
+  for await (const chunk of req) {
+    size += chunk.length;
+    if (size > MAX_BODY_BYTES) throw new Error('Request body too large');
+    chunks.push(chunk);
+  }
+  return Buffer.concat(chunks);
+}
+
+async function readJsonBody(req: IncomingMessage): Promise<unknown> {
+  const raw = (await readBody(req)).toString('utf8');
+  return raw ? JSON.parse(raw) : undefined;
+}
+
+/**
+ * Serve MCP over HTTP: the streamable HTTP transport on /mcp, plus the
+ * legacy SSE transport (GET /sse, POST /messages) for older clients,
+ * Prometheus metrics on GET /metrics, and webhook deliveries when configured.
+ */
+export async function startHttpServer(options: HttpServerOptions): Promise<HttpServer> {
+  const streamable = new Map<string, StreamableHTTPServerTransport>();
+  const sse = new Map<string, SSEServerTransport>();
+  // Client that opened each session; a session's requests must come from the same client
+  const owners = new Map<string, string>();
+
+  const handleStreamable = async (req: IncomingMessage, res: ServerResponse, client: ApiClient) => {
+    const sessionHeader = req.headers['mcp-session-id'];
+    const sessionId = Array.isArray(sessionHeader) ? sessionHeader[0] : sessionHeader;
+    const body = req.method === 'POST' ? await readJsonBody(req) : undefined;
+
+    const existing = sessionId ? streamable.get(sessionId) : undefined;
+    if (existing) {
+      if (owners.get(sessionId!) !== client.name) {
+        sendRpcError(res, 403, 'Session belongs to another client');
+        return;
+      }
+      await existing.handleRequest(req, res, body);
+      return;
+    }
+    if (sessionId || req.method !== 'POST' || !isInitializeRequest(body)) {
+      sendRpcError(res, sessionId ? 404 : 400, sessionId ? 'Session not found' : 'No valid session ID provided');
+      return;
+    }
+
+    const transport: StreamableHTTPServerTransport = new StreamableHTTPServerTransport({
+      sessionIdGenerator: () => randomUUID(),
+      onsessioninitialized: (id) => {
+        streamable.set(id, transport);
+        owners.set(id, client.name);
+        logger.info('HTTP session started', { sessionId: id, client: client.name });
+      },
+    });
+    transport.onclose = () => {
+      if (transport.sessionId) {
+        streamable.delete(transport.sessionId);
+        owners.delete(transport.sessionId);
+        logger.info('HTTP session closed', { sessionId: transport.sessionId });
+      }
+    };
+    await options.createMcpServer(client).connect(transport);
+    await transport.handleRequest(req, res, body);
+  };
+
+  const handleSse = async (res: ServerResponse, client: ApiClient) => {
+    const transport = new SSEServerTransport('/messages', res);
+    sse.set(transport.sessionId, transport);
+    owners.set(transport.sessionId, client.name);
+    res.on('close', () => {
+      sse.delete(transport.sessionId);
+      owners.delete(transport.sessionId);
+    });
+    await options.createMcpServer(client).connect(transport);
+  };
+
+  const handleSseMessage = async (req: IncomingMessage, res: ServerResponse, url: URL, client: ApiClient) => {
+    const sessionId = url.searchParams.get('sessionId') || '';
+    const transport = sse.get(sessionId);
+    if (!transport) {
+      sendRpcError(res, 404, 'Session not found');
+      return;
+    }
+    if (owners.get(sessionId) !== client.name) {
+      sendRpcError(res, 403, 'Session belongs to another client');
+      return;
+    }
+    await transport.handlePostMessage(req, res, await readJsonBody(req));
+  };
+
+  const httpServer = createHttpServer(async (req, res) => {
+    const url = new URL(req.url || '/', 'http://localhost');
+    try {
+      if (url.pathname === '/health') {
+        sendJson(res, 200, { status: 'ok', sessions: streamable.size + sse.size });
+        return;
+      }
+      const webhook = /^\/webhooks\/(github|gitlab)$/.exec(url.pathname);
+      if (webhook && options.webhooks && req.method === 'POST') {
+        const result = await options.webhooks(webhook[1] as 'github' | 'gitlab', req.headers, await readBody(req));
+        sendJson(res, result.status, result.body);
+        return;
+      }
+      const client = authenticateClient(req.headers.authorization, {
+        ...(options.token ? { token: options.token } : {}),
+        ...(options.apiKeys ? { apiKeys: options.apiKeys } : {}),
+        ...(req.socket.remoteAddress ? { remoteAddress: req.socket.remoteAddress } : {}),
+      });
+      if (!client) {
+        sendJson(res, 401, { error: 'Unauthorized' }, { 'WWW-Authenticate': 'Bearer' });
+        return;
+      }
+      if (url.pathname === '/metrics' && req.method === 'GET') {
+        res.writeHead(200, { 'Content-Type': 'text/plain; version=0.0.4; charset=utf-8' });
+        res.end(metrics.render());
+      } else if (url.pathname === '/mcp') {
+        await handleStreamable(req, res, client);
+      } else if (url.pathname === '/sse' && req.method === 'GET') {
+        await handleSse(res, client);
+      } else if (url.pathname === '/messages' && req.method === 'POST') {
+        await handleSseMessage(req, res, url, client);
+      } else {
+        sendJson(res, 404, { error: 'Not found' });
+      }
+    } catch (error) {
+      logger.error('HTTP request failed', { method: req.method, path: url.pathname, error });
+      if (!res.headersSent) {
+        sendRpcError(res, error instanceof SyntaxError ? 400 : 500, error instanceof Error ? error.message : String(error));
+      }
+    }
+  });
+
+  httpServer.on('close', () => {
+    for (const transport of [...streamable.values(), ...sse.values()]) {
+      transport.close().catch(() => { /* already closed */ });
+    }
+  });
+
+  await new Promise<void>((resolve, reject) => {
+    httpServer.once('error', reject);
+    httpServer.listen(options.port, options.host, () => {
+      httpServer.off('error', reject);
+      resolve();
+    });
+  });
+  return httpServer;
+}
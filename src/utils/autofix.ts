@@ -0,0 +1,47 @@
+import { promises as fs } from 'fs';
+import { type Diagnostic } from '../diagnostics/index.js';
+import { recordFileChange } from '../audit/index.js';
+import { captureBeforeChange } from '../snapshots/index.js';
+import { previewChange, type ChangePreview } from './preview.js';
+
+export interface FixSummary {
+    // Findings that were there before and are gone after the fix run
+    fixed: number;
+    // Findings the fixer left: not auto-fixable, or unsafe to fix
+    remaining: Diagnostic[];
+    changes: ChangePreview[];
+}
+
+/**
+ * Contents of the files a fixer may rewrite (those with findings), taken
+ * before it runs. They also go into the call's snapshot, since the fixer
+ * writes behind the server's back.
+ */
+export async function captureFixTargets(diagnostics: Diagnostic[]): Promise<Map<string, string | null>> {
+    const contents = new Map<string, string | null>();
+    for (const file of new Set(diagnostics.map(d => d.file).filter(Boolean))) {
+        await captureBeforeChange(file);
+        contents.set(file, await fs.readFile(file, 'utf-8').catch(() => null));
+    }
+    return contents;
+}
+
+/**
+ * What a fixer changed, compared against the captured contents
+ */
+export async function summarizeFixes(before: Map<string, string | null>, found: Diagnostic[], remaining: Diagnostic[]): Promise<FixSummary> {
+    const changes: ChangePreview[] = [];
+    for (const [file, original] of before) {
+        const current = await fs.readFile(file, 'utf-8').catch(() => null);
+        if (current === original) continue;
+        changes.push(previewChange(file, original, current));
+        if (current === null) await recordFileChange(file, 'delete');
+        else await recordFileChange(file, 'write', current);
+    }
+    return { fixed: Math.max(0, found.length - remaining.length), remaining, changes };
+}
+
+export function describeFixes(summary: FixSummary): string {
+    const files = summary.changes.map(c => `${c.path} (+${c.added ?? 0} -${c.removed ?? 0})`).join('\n');
+    return `Fixed ${summary.fixed} issue(s) in ${summary.changes.length} file(s); ${summary.remaining.length} remaining${files ? `\n${files}` : ''}`;
+}
@@ -1,67 +1,367 @@
-import { exec } from 'child_process';
+import { exec, spawn, type ChildProcess } from 'child_process';
 import { promisify } from 'util';
+import { AsyncLocalStorage } from 'async_hooks';
+import Config, { type ExecutorBackend } from '../config/index.js';
+import { logger } from './logger.js';
+import { filterEnv, redactSecrets } from './env.js';
+import { formatTraceparent, tracer } from '../tracing/index.js';
+import { buildDockerCommand } from '../executor/docker.js';
+import { buildSshCommand, runsRemotely, toLocalPaths, type SshTarget } from '../executor/ssh.js';
+import { backoffDelay, classifyFailure, type RetryEvent, type RetryPolicy } from '../executor/retry.js';
+import { detectNetworkAttempt, type OfflineMode, type OfflineViolation } from '../executor/offline.js';
+import { buildNoNetworkCommand, type NetworkSandbox } from '../executor/sandbox.js';
+import { buildCpuAccountedCommand, buildLimitedCommand, detectLimitExceeded, hasLimits, parseShellTimes, tightestLimits, type LimitKind, type ResourceLimits } from '../executor/limits.js';
 
 /**
- * Enhanced command execution utility with proper error handling
+ * Receives stdout/stderr chunks as a command produces them
  */
-export function runCommand(
-  command: string,
-  options: {
-    cwd?: string;
-    timeout?: number;
-    env?: Record<string, string>;
-    maxBuffer?: number;
-  } = {}
-): Promise<{ stdout: string; stderr: string; exitCode: number; duration: number }> {
+export type StreamHandler = (chunk: string, stream: 'stdout' | 'stderr') => void;
+
+const streamContext = new AsyncLocalStorage<StreamHandler>();
+
+/**
+ * Run fn with a stream handler that every runCommand inside it forwards output to
+ */
+export function withStreamHandler<T>(handler: StreamHandler, fn: () => Promise<T>): Promise<T> {
+  return streamContext.run(handler, fn);
+}
+
+/**
+ * Defaults applied to every runCommand inside a tool call (from project config)
+ */
+export interface CommandDefaults {
+  env?: Record<string, string>;
+  timeout?: number;
+  limits?: ResourceLimits;
+  // Told about every command that ended because it hit a limit
+  onLimitExceeded?: (event: LimitEvent) => void;
+  // Told the CPU seconds (user + system) every command used, for quotas
+  onCpuTime?: (seconds: number) => void;
+  // Aborted when the tool call is cancelled: running commands are killed, later ones never start
+  signal?: AbortSignal;
+  // The workspace's executor, overriding MCP_EXECUTOR
+  executor?: ExecutorBackend;
+  // Remote host the workspace's commands run on
+  remote?: SshTarget;
+  // Overrides the server's retry policy for transient failures
+  retry?: Partial<RetryPolicy>;
+  // Told about every retry, and about transient failures left once retries ran out
+  onRetry?: (event: RetryEvent) => void;
+  // Offline mode: never retried, and strict mode runs local commands in the sandbox (no network) and containers with --network none
+  offline?: { mode: OfflineMode; sandbox: NetworkSandbox | null };
+  // Told about every command that failed because it tried to reach the network in offline mode
+  onOfflineViolation?: (violation: OfflineViolation) => void;
+  // When set, commands see only the baseline environment plus these host variables (globs) instead of all of it
+  passEnv?: string[];
+  // Values hidden from captured and streamed output, by variable name
+  secrets?: Record<string, string>;
+}
+
+export interface LimitEvent {
+  command: string;
+  limit: LimitKind;
+  // Timeout in ms, memory in MB or CPU time in seconds
+  value: number | undefined;
+}
+
+// Grace period between SIGTERM and SIGKILL for a timed-out or cancelled process group
+const KILL_GRACE_MS = 2000;
+// What a shell reports for a command interrupted with Ctrl-C
+const CANCELLED_EXIT = 130;
+
+// Process groups still running, killed if the server exits first
+const runningGroups = new Set<number>();
+process.once('exit', () => {
+  for (const pid of runningGroups) {
+    try {
+      process.kill(-pid, 'SIGKILL');
+    } catch { /* already gone */ }
+  }
+});
+
+/**
+ * Kill a command and everything it spawned. Commands run in their own
+ * process group on POSIX so test binaries forked by `go test` etc. die too.
+ */
+function killTree(child: ChildProcess, signal: NodeJS.Signals): void {
+  if (child.pid === undefined) return;
+  try {
+    if (process.platform === 'win32') {
+      child.kill(signal);
+    } else {
+      process.kill(-child.pid, signal);
+    }
+  } catch { /* already gone */ }
+}
+
+const defaultsContext = new AsyncLocalStorage<CommandDefaults>();
+
+/**
+ * Run fn with command defaults; explicit runCommand options still take precedence
+ */
+export function withCommandDefaults<T>(defaults: CommandDefaults, fn: () => Promise<T>): Promise<T> {
+  return defaultsContext.run(defaults, fn);
+}
+
+export interface RunCommandOptions {
+  cwd?: string;
+  timeout?: number;
+  env?: Record<string, string>;
+  maxBuffer?: number;
+  onOutput?: StreamHandler;
+  // Always run on the host, even when a container executor is configured
+  local?: boolean;
+  image?: string;
+  limits?: ResourceLimits;
+  // Pass the server's environment through (default); when false the command sees only env
+  inheritEnv?: boolean;
+  signal?: AbortSignal;
+  // false: a transient failure is reported as is, never retried
+  retry?: boolean;
+}
+
+export interface CommandResult {
+  stdout: string;
+  stderr: string;
+  exitCode: number;
+  duration: number;
+  limitExceeded?: LimitKind;
+  cancelled?: boolean;
+  // Runs repeated after transient failures
+  retries?: number;
+  // The rule that classified the final failure as transient, when retries did not help
+  transientFailure?: string;
+  // In offline mode: the rule showing the command failed because it needed the network
+  offlineViolation?: string;
+}
+
+/**
+ * Enhanced command execution utility with proper error handling. Failures
+ * that look like flaky infrastructure (registry 5xx, network timeouts while
+ * fetching dependencies) are retried with backoff under the retry policy.
+ */
+export async function runCommand(command: string, options: RunCommandOptions = {}): Promise<CommandResult> {
+  const defaults = defaultsContext.getStore() ?? {};
+  const policy = { ...Config.getInstance().getRetryPolicy(), ...defaults.retry };
+  const signal = options.signal ?? defaults.signal;
+  let result = await runOnce(command, options);
+  const offline = defaults.offline && defaults.offline.mode !== 'off';
+  if (offline && result.exitCode !== 0 && !result.cancelled && !result.limitExceeded) {
+    // Offline, network trouble is the setting at work: retrying cannot help
+    const output = `${result.stdout}\n${result.stderr}`;
+    const rule = detectNetworkAttempt(output) ?? classifyFailure(output, policy.patterns);
+    if (rule) {
+      result.offlineViolation = rule.id;
+      logger.info('Command tried to reach the network in offline mode', { command, rule: rule.id });
+      defaults.onOfflineViolation?.({ command, rule: rule.id, description: rule.description });
+    }
+    return result;
+  }
+  let retries = 0;
+  while (result.exitCode !== 0 && !result.cancelled && !result.limitExceeded) {
+    const rule = classifyFailure(`${result.stdout}\n${result.stderr}`, policy.patterns);
+    if (!rule) break;
+    if (options.retry === false || retries >= policy.attempts || signal?.aborted) {
+      result.transientFailure = rule.id;
+      defaults.onRetry?.({ command, rule: rule.id, description: rule.description, attempt: retries, exhausted: true });
+      break;
+    }
+    retries++;
+    const delayMs = backoffDelay(policy.backoffMs, retries);
+    logger.warn('Transient failure; retrying', { command, rule: rule.id, attempt: retries, delayMs });
+    defaults.onRetry?.({ command, rule: rule.id, description: rule.description, attempt: retries, delayMs });
+    (options.onOutput ?? streamContext.getStore())?.(`\n${rule.description}; retrying in ${delayMs} ms (retry ${retries} of ${policy.attempts})\n`, 'stderr');
+    await sleep(delayMs, signal);
+    result = await runOnce(command, options);
+  }
+  if (retries > 0) result.retries = retries;
+  return result;
+}
+
+// Resolves early when the signal aborts; the next run then returns as cancelled
+function sleep(ms: number, signal: AbortSignal | undefined): Promise<void> {
+  return new Promise(done => {
+    const timer = setTimeout(finish, ms);
+    function finish() {
+      clearTimeout(timer);
+      signal?.removeEventListener('abort', finish);
+      done();
+    }
+    signal?.addEventListener('abort', finish, { once: true });
+  });
+}
+
+function runOnce(command: string, options: RunCommandOptions): Promise<CommandResult> {
+  const config = Config.getInstance();
+  const defaults = defaultsContext.getStore() ?? {};
   const {
     cwd = process.cwd(),
-    timeout = 30000,
-    env = {},
+    timeout = defaults.timeout ?? 30000,
     maxBuffer = 1024 * 1024 // 1MB default
   } = options;
+  // Commands outside the synced workspace run on the host
+  const remote = !options.local && defaults.remote && runsRemotely(cwd, defaults.remote) ? defaults.remote : undefined;
+  const useDocker = !options.local && !remote && (defaults.executor ?? config.getExecutor()) === 'docker';
+  const executor = remote ? 'ssh' : useDocker ? 'docker' : 'local';
+  // A child span per subprocess; TRACEPARENT lets OpenTelemetry-aware commands continue the trace
+  const span = tracer.startSpan(`exec ${command.trim().split(/\s+/)[0] ?? ''}`, {
+    kind: 'client',
+    attributes: { 'process.command_line': command.slice(0, 1024), 'process.cwd': cwd, 'mcp.executor': executor, ...(remote ? { 'mcp.remote': remote.name } : {}) },
+  });
+  const env = {
+    ...(tracer.isExporting() && options.inheritEnv !== false ? { TRACEPARENT: formatTraceparent(span.context) } : {}),
+    ...defaults.env,
+    ...options.env,
+  };
+  // Workspace and call limits can only lower the server's caps
+  const limits = tightestLimits(config.getResourceLimits(), defaults.limits, options.limits);
+  // Remote output names remote paths; diagnostics must point at the local files
+  // Secrets are hidden before output leaves this function; a value split across two streamed chunks is only caught in the result
+  const secrets = defaults.secrets ?? {};
+  const localize = (text: string) => redactSecrets(remote ? toLocalPaths(text, remote) : text, secrets);
+  const streamHandler = options.onOutput ?? streamContext.getStore();
+  const onOutput: StreamHandler | undefined = streamHandler && (remote || Object.keys(secrets).length > 0) ? (chunk, stream) => streamHandler(localize(chunk), stream) : streamHandler;
+  // Containers are cgroup-limited, so they get OOM-killed like the cgroup strategy; remote hosts get ulimits
+  const strategy = useDocker ? 'cgroup' : remote ? 'rlimit' : config.getLimitStrategy();
+  // The container and the remote shell apply limits themselves; locally they wrap the shell command
+  // Remote hosts are left alone: their package managers are still offline, but their network is theirs
+  const strict = defaults.offline?.mode === 'strict';
+  const isolated = strict && executor === 'local' && defaults.offline?.sandbox ? buildNoNetworkCommand(command, defaults.offline.sandbox) : command;
+  const shellCommand = useDocker
+    ? buildDockerCommand(command, { cwd, env, ...(options.image ? { image: options.image } : {}), ...(hasLimits(limits) ? { limits } : {}), ...(strict ? { noNetwork: true } : {}) })
+    : remote
+      ? buildSshCommand(command, { cwd, env, target: remote, ...(hasLimits(limits) ? { limits } : {}) })
+      : hasLimits(limits) ? buildLimitedCommand(isolated, limits, strategy) : isolated;
+  // Only the local POSIX shell can report its children's CPU time; elsewhere wall-clock time is charged
+  const accountCpu = Boolean(defaults.onCpuTime) && executor === 'local' && process.platform !== 'win32';
+  const finalCommand = accountCpu ? buildCpuAccountedCommand(shellCommand) : shellCommand;
+
+  const signal = options.signal ?? defaults.signal;
 
   const startTime = Date.now();
 
+  if (signal?.aborted) {
+    span.setStatus('error', 'cancelled');
+    span.end();
+    logger.info('Command skipped: the call was cancelled', { command });
+    return Promise.resolve({ stdout: '', stderr: 'Cancelled before it started', exitCode: CANCELLED_EXIT, duration: 0, cancelled: true });
+  }
+
   return new Promise((resolve, reject) => {
-    console.error(`[MCP] Executing command: ${command}`);
-    console.error(`[MCP] Working directory: ${cwd}`);
-
-    const child = exec(
-      command,
-      {
-        cwd,
-        timeout,
-        maxBuffer,
-        env: { ...process.env, ...env }
-      },
-      (error, stdout, stderr) => {
-        const duration = Date.now() - startTime;
-
-        console.error(`[MCP] Command completed in ${duration}ms`);
-
-        // Even if there's an error, we want to capture the output
-        const result = {
-          stdout: stdout || '',
-          stderr: stderr || '',
-          exitCode: error ? (error as any).code || 1 : 0,
-          duration,
-        };
-
-        if (error) {
-          console.error(`[MCP] Command failed with exit code ${result.exitCode}:`, error.message);
-          if (stderr) {
-            console.error(`[MCP] Command stderr:`, stderr);
-          }
-        }
-
-        resolve(result);
+    logger.info('Executing command', { command, cwd, ...(executor !== 'local' ? { executor } : {}), ...(remote ? { remote: remote.name } : {}) });
+
+    let timedOut = false;
+    let cancelled = false;
+    let settled = false;
+    const stdout: string[] = [];
+    const stderr: string[] = [];
+    const times: string[] = [];
+    let bufferedBytes = 0;
+    let overflowed = false;
+
+    const child = spawn(finalCommand, {
+      cwd,
+      env: options.inheritEnv === false ? env : { ...(defaults.passEnv ? filterEnv(defaults.passEnv) : process.env), ...env },
+      shell: true,
+      // Own process group, so a timeout can kill the whole tree
+      detached: process.platform !== 'win32',
+      stdio: accountCpu ? ['ignore', 'pipe', 'pipe', 'pipe'] : ['ignore', 'pipe', 'pipe'],
+    });
+    child.stdio[3]?.on('data', (data: Buffer) => times.push(data.toString()));
+    if (child.pid !== undefined && process.platform !== 'win32') runningGroups.add(child.pid);
+
+    const finish = (code: number | null, exitSignal: NodeJS.Signals | null) => {
+      if (settled) return;
+      settled = true;
+      clearTimeout(timer);
+      signal?.removeEventListener('abort', cancel);
+      if (child.pid !== undefined) runningGroups.delete(child.pid);
+      const duration = Date.now() - startTime;
+
+      logger.debug('Command completed', { command, exitCode: code, durationMs: duration });
+
+      // Even if there's an error, we want to capture the output
+      const result: CommandResult = {
+        stdout: localize(stdout.join('')),
+        stderr: localize(overflowed ? `${stderr.join('')}\nOutput exceeded ${maxBuffer} bytes; command was killed` : cancelled ? `${stderr.join('')}\nCancelled; command was killed` : stderr.join('')),
+        exitCode: cancelled ? CANCELLED_EXIT : code === 0 && !overflowed && !timedOut ? 0 : code || 1,
+        duration,
+      };
+      if (cancelled) result.cancelled = true;
+      // A cancelled command was killed by us, not by a limit
+      const limitExceeded = cancelled ? null : detectLimitExceeded({ exitCode: code ?? 1, stderr: result.stderr, signal: exitSignal, timedOut }, limits, strategy);
+      if (limitExceeded) {
+        result.limitExceeded = limitExceeded;
+        const value = limitExceeded === 'timeout' ? timeout : limitExceeded === 'memory' ? limits.memoryMb : limits.cpuSeconds;
+        logger.warn('Command exceeded a resource limit', { command, limit: limitExceeded, value });
+        defaults.onLimitExceeded?.({ command, limit: limitExceeded, value });
+      }
+      if (defaults.onCpuTime) {
+        const cpuSeconds = parseShellTimes(times.join(''));
+        defaults.onCpuTime(cpuSeconds ?? duration / 1000);
+        if (cpuSeconds !== null) span.setAttributes({ 'process.cpu_seconds': cpuSeconds });
+      }
+      span.setAttributes({ 'process.exit_code': result.exitCode, 'mcp.limit_exceeded': limitExceeded });
+      if (result.exitCode !== 0) span.setStatus('error', `exit code ${result.exitCode}`);
+      span.end();
+
+      if (result.exitCode !== 0) {
+        // Non-zero exits are what feedback tools look for, so they are not warnings
+        logger.info('Command failed', { command, exitCode: result.exitCode, ...(exitSignal ? { signal: exitSignal } : {}), ...(result.stderr ? { stderr: result.stderr.slice(0, 4096) } : {}) });
       }
-    );
+
+      resolve(result);
+    };
+
+    // SIGTERM the process group, then SIGKILL whatever ignores it
+    const terminate = () => {
+      killTree(child, 'SIGTERM');
+      setTimeout(() => {
+        killTree(child, 'SIGKILL');
+        // A process that escaped the group may still hold the pipes open
+        setTimeout(() => finish(null, 'SIGKILL'), KILL_GRACE_MS).unref();
+      }, KILL_GRACE_MS).unref();
+    };
+    const timer = setTimeout(() => {
+      timedOut = true;
+      logger.warn('Command timed out', { command, timeoutMs: timeout });
+      terminate();
+    }, timeout);
+    function cancel() {
+      if (settled || cancelled || timedOut) return;
+      cancelled = true;
+      logger.info('Command cancelled', { command });
+      terminate();
+    }
+    signal?.addEventListener('abort', cancel, { once: true });
+
+    const collect = (chunks: string[], stream: 'stdout' | 'stderr') => (data: Buffer) => {
+      if (overflowed) return;
+      bufferedBytes += data.length;
+      if (bufferedBytes > maxBuffer) {
+        overflowed = true;
+        killTree(child, 'SIGKILL');
+        return;
+      }
+      const chunk = data.toString();
+      chunks.push(chunk);
+      // Forward output incrementally when a stream handler is attached
+      onOutput?.(chunk, stream);
+    };
+    child.stdout?.on('data', collect(stdout, 'stdout'));
+    child.stderr?.on('data', collect(stderr, 'stderr'));
+    child.on('close', finish);
 
     // Handle other types of errors (spawn errors, etc.)
     child.on('error', (error) => {
+      if (settled) return;
+      settled = true;
+      clearTimeout(timer);
+      signal?.removeEventListener('abort', cancel);
       const duration = Date.now() - startTime;
-      console.error(`[MCP] Command spawn error:`, error);
+      logger.error('Command could not be started', { command, error });
+      span.setStatus('error', error.message);
+      span.end();
 
       reject({
         error,
@@ -71,13 +371,6 @@ export function runCommand(
         duration,
       });
     });
-
-    // Handle timeout
-    child.on('exit', (code, signal) => {
-      if (signal === 'SIGTERM') {
-        console.error(`[MCP] Command timed out after ${timeout}ms`);
-      }
-    });
   });
 }
 
@@ -0,0 +1,248 @@
+import { createReadStream, promises as fs } from 'fs';
+import { createHash } from 'crypto';
+import { createInterface } from 'readline';
+
+// Reads larger than this are cut at a line boundary unless the caller raises maxBytes
+export const DEFAULT_MAX_READ_BYTES = 256 * 1024;
+
+// Bytes sniffed to tell binary from text, as git does
+const SNIFF_BYTES = 8000;
+
+export interface ReadWindow {
+    // 1-based, inclusive
+    startLine?: number;
+    endLine?: number;
+    // First or last N lines
+    head?: number;
+    tail?: number;
+    maxBytes?: number;
+}
+
+export interface FileReadResult {
+    // The text, or a one-line summary for binary files
+    content: string;
+    binary: boolean;
+    totalBytes: number;
+    returnedBytes: number;
+    truncated: boolean;
+    // Line numbers are absent for binary files and raw (non-text encoding) reads
+    totalLines?: number;
+    startLine?: number;
+    endLine?: number;
+    // Where to continue when a forward read was cut by maxBytes
+    nextStartLine?: number;
+    // Binary files: detected format and content digest
+    kind?: string;
+    sha256?: string;
+}
+
+const SIGNATURES: Array<[string, number[]]> = [
+    ['PNG image', [0x89, 0x50, 0x4e, 0x47]],
+    ['JPEG image', [0xff, 0xd8, 0xff]],
+    ['GIF image', [0x47, 0x49, 0x46, 0x38]],
+    ['PDF document', [0x25, 0x50, 0x44, 0x46]],
+    ['ZIP archive', [0x50, 0x4b, 0x03, 0x04]],
+    ['gzip archive', [0x1f, 0x8b]],
+    ['ELF executable', [0x7f, 0x45, 0x4c, 0x46]],
+    ['Mach-O executable', [0xcf, 0xfa, 0xed, 0xfe]],
+    ['PE executable', [0x4d, 0x5a]],
+    ['WebAssembly module', [0x00, 0x61, 0x73, 0x6d]],
+    ['SQLite database', [0x53, 0x51, 0x4c, 0x69, 0x74, 0x65]],
+    ['UTF-16 text', [0xff, 0xfe]],
+    ['UTF-16 text', [0xfe, 0xff]],
+];
+
+/**
+ * Whether a file's first bytes look binary: a NUL byte, or mostly control characters
+ */
+export function isBinaryContent(chunk: Buffer): boolean {
+    if (chunk.length === 0) return false;
+    if (chunk.includes(0)) return true;
+    let control = 0;
+    for (const byte of chunk) {
+        // Tab, newlines, form feed, carriage return and escape appear in text
+        if (byte < 32 && byte !== 9 && byte !== 10 && byte !== 12 && byte !== 13 && byte !== 27) control++;
+    }
+    return control / chunk.length > 0.1;
+}
+
+export function detectBinaryKind(chunk: Buffer): string {
+    const match = SIGNATURES.find(([, magic]) => magic.every((byte, i) => chunk[i] === byte));
+    return match ? match[0] : 'binary data';
+}
+
+export function formatByteSize(bytes: number): string {
+    if (bytes < 1024) return `${bytes} B`;
+    if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+    return `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+}
+
+function countLines(text: string): number {
+    if (text === '') return 0;
+    return text.split('\n').length - (text.endsWith('\n') ? 1 : 0);
+}
+
+async function sniff(path: string): Promise<Buffer> {
+    const handle = await fs.open(path, 'r');
+    try {
+        const buffer = Buffer.alloc(SNIFF_BYTES);
+        const { bytesRead } = await handle.read(buffer, 0, SNIFF_BYTES, 0);
+        return buffer.subarray(0, bytesRead);
+    } finally {
+        await handle.close();
+    }
+}
+
+async function hashFile(path: string): Promise<string> {
+    const hash = createHash('sha256');
+    for await (const chunk of createReadStream(path)) hash.update(chunk);
+    return hash.digest('hex');
+}
+
+// Cut text to at most maxBytes of UTF-8 without splitting a character
+function cutToBytes(text: string, maxBytes: number): string {
+    const cut = Buffer.from(text).subarray(0, maxBytes).toString('utf-8');
+    return cut.endsWith('\uFFFD') ? cut.slice(0, -1) : cut;
+}
+
+function validateWindow(window: ReadWindow): void {
+    const ranged = window.startLine !== undefined || window.endLine !== undefined;
+    if ([ranged, window.head !== undefined, window.tail !== undefined].filter(Boolean).length > 1) {
+        throw new Error('Use only one of startLine/endLine, head or tail');
+    }
+    if (window.startLine !== undefined && window.endLine !== undefined && window.endLine < window.startLine) {
+        throw new Error(`endLine ${window.endLine} is before startLine ${window.startLine}`);
+    }
+}
+
+/**
+ * Read a text file, or the lines of it a window selects, capped at maxBytes.
+ * Lines are streamed, so a range of a huge file never loads all of it; binary
+ * files are summarized (format, size, sha256) instead of returned.
+ */
+export async function readFileWindow(path: string, window: ReadWindow = {}): Promise<FileReadResult> {
+    validateWindow(window);
+    const maxBytes = window.maxBytes ?? DEFAULT_MAX_READ_BYTES;
+    const { size } = await fs.stat(path);
+    const head = await sniff(path);
+
+    if (isBinaryContent(head)) {
+        const kind = detectBinaryKind(head);
+        const sha256 = await hashFile(path);
+        return {
+            content: `Binary file (${kind}, ${formatByteSize(size)}, sha256 ${sha256}); content not shown`,
+            binary: true,
+            totalBytes: size,
+            returnedBytes: 0,
+            truncated: false,
+            kind,
+            sha256,
+        };
+    }
+
+    const windowed = window.startLine !== undefined || window.endLine !== undefined || window.head !== undefined || window.tail !== undefined;
+    if (!windowed && size <= maxBytes) {
+        const content = await fs.readFile(path, 'utf-8');
+        const totalLines = countLines(content);
+        return { content, binary: false, totalBytes: size, returnedBytes: size, truncated: false, totalLines, startLine: totalLines > 0 ? 1 : 0, endLine: totalLines };
+    }
+
+    const from = window.tail !== undefined ? 1 : window.startLine ?? 1;
+    const to = window.head ?? window.endLine ?? Infinity;
+    const lines: string[] = [];
+    let bytes = 0;
+    let total = 0;
+    let truncated = false;
+    let nextStartLine: number | undefined;
+
+    const reader = createInterface({ input: createReadStream(path, { encoding: 'utf-8' }), crlfDelay: Infinity });
+    for await (const line of reader) {
+        total++;
+        if (window.tail !== undefined) {
+            lines.push(line);
+            if (lines.length > window.tail) lines.shift();
+            continue;
+        }
+        if (total < from || total > to || truncated) continue;
+        // Counted as joined: a newline between lines
+        const lineBytes = Buffer.byteLength(line) + (lines.length > 0 ? 1 : 0);
+        if (bytes + lineBytes > maxBytes) {
+            truncated = true;
+            // A single line over the limit is still shown, cut
+            if (lines.length === 0) {
+                lines.push(cutToBytes(line, maxBytes));
+                bytes = maxBytes;
+                nextStartLine = total + 1;
+            } else {
+                nextStartLine = total;
+            }
+            continue;
+        }
+        lines.push(line);
+        bytes += lineBytes;
+    }
+
+    if (window.tail !== undefined) {
+        // Keep the newest lines that fit
+        let kept = Buffer.byteLength(lines.join('\n'));
+        while (lines.length > 1 && kept > maxBytes) {
+            kept -= Buffer.byteLength(lines.shift()!) + 1;
+            truncated = true;
+        }
+        if (lines.length === 1 && kept > maxBytes) {
+            lines[0] = cutToBytes(lines[0]!, maxBytes);
+            truncated = true;
+        }
+        const startLine = lines.length > 0 ? total - lines.length + 1 : 0;
+        const content = lines.join('\n');
+        return { content, binary: false, totalBytes: size, returnedBytes: Buffer.byteLength(content), truncated, totalLines: total, startLine, endLine: total };
+    }
+
+    if (from > total && total > 0) {
+        throw new Error(`startLine ${from} is past the end of the file (${total} lines)`);
+    }
+    const content = lines.join('\n');
+    const startLine = lines.length > 0 ? from : 0;
+    return {
+        content,
+        binary: false,
+        totalBytes: size,
+        returnedBytes: Buffer.byteLength(content),
+        truncated,
+        totalLines: total,
+        startLine,
+        endLine: lines.length > 0 ? from + lines.length - 1 : 0,
+        ...(nextStartLine !== undefined && nextStartLine <= Math.min(to, total) ? { nextStartLine } : {}),
+    };
+}
+
+/**
+ * Read raw bytes in a non-text encoding (base64, hex, latin1), capped at maxBytes
+ */
+export async function readFileBytes(path: string, encoding: BufferEncoding, maxBytes: number = DEFAULT_MAX_READ_BYTES): Promise<FileReadResult> {
+    const { size } = await fs.stat(path);
+    const handle = await fs.open(path, 'r');
+    try {
+        const buffer = Buffer.alloc(Math.min(size, maxBytes));
+        const { bytesRead } = await handle.read(buffer, 0, buffer.length, 0);
+        return { content: buffer.subarray(0, bytesRead).toString(encoding), binary: false, totalBytes: size, returnedBytes: bytesRead, truncated: bytesRead < size };
+    } finally {
+        await handle.close();
+    }
+}
+
+/**
+ * One-line account of a read: which lines of how many, and why it stopped
+ */
+export function describeRead(path: string, result: FileReadResult): string {
+    if (result.binary) return `Read file ${path}: ${result.content}`;
+    if (result.totalLines === undefined) {
+        return `Read ${formatByteSize(result.returnedBytes)} of ${formatByteSize(result.totalBytes)} from ${path}${result.truncated ? ' (truncated at maxBytes)' : ''}`;
+    }
+    const partial = result.startLine !== 1 || result.endLine !== result.totalLines;
+    const range = partial ? ` (lines ${result.startLine}-${result.endLine} of ${result.totalLines})` : '';
+    const cut = result.truncated
+        ? `; truncated at ${formatByteSize(result.returnedBytes)} of ${formatByteSize(result.totalBytes)}${result.nextStartLine ? `, continue with startLine ${result.nextStartLine}` : ''}`
+        : '';
+    return `Read file ${path}${range}${cut}`;
+}
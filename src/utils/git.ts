@@ -0,0 +1,206 @@
+export interface DiffLine {
+    type: 'add' | 'del' | 'context';
+    content: string;
+    oldLine: number | null;
+    newLine: number | null;
+}
+
+export interface DiffHunk {
+    header: string;
+    oldStart: number;
+    oldLines: number;
+    newStart: number;
+    newLines: number;
+    lines: DiffLine[];
+}
+
+export interface FileDiff {
+    file: string;
+    oldFile: string;
+    status: 'added' | 'deleted' | 'modified' | 'renamed';
+    binary: boolean;
+    hunks: DiffHunk[];
+}
+
+const hunkHeaderPattern = /^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@/;
+
+function stripDiffPrefix(path: string): string {
+    // Plain diffs may carry a timestamp after a tab: "--- a/file\t2024-01-01 ..."
+    return (path.split('\t')[0] ?? path).replace(/^"?(?:a|b)\//, '').replace(/"$/, '');
+}
+
+/**
+ * Parse `git diff` output into per-file hunks with old/new line numbers.
+ * Plain unified diffs without `diff --git` headers are accepted too.
+ */
+export function parseUnifiedDiff(diff: string): FileDiff[] {
+    const files: FileDiff[] = [];
+    let current: FileDiff | null = null;
+    let hunk: DiffHunk | null = null;
+    let oldLine = 0;
+    let newLine = 0;
+    // Lines still expected by the current hunk, so "--- " inside a hunk is read as a deletion
+    let oldRemaining = 0;
+    let newRemaining = 0;
+
+    for (const line of diff.split('\n')) {
+        if (line.startsWith('diff --git ')) {
+            const match = /^diff --git (\S+) (\S+)$/.exec(line);
+            const oldFile = stripDiffPrefix(match?.[1] ?? '');
+            const newFile = stripDiffPrefix(match?.[2] ?? '');
+            current = { file: newFile, oldFile, status: 'modified', binary: false, hunks: [] };
+            files.push(current);
+            hunk = null;
+            continue;
+        }
+        if (line.startsWith('--- ') && (!current || (hunk && oldRemaining <= 0 && newRemaining <= 0))) {
+            current = { file: '', oldFile: '', status: 'modified', binary: false, hunks: [] };
+            files.push(current);
+            hunk = null;
+        }
+        if (!current) continue;
+        if (!hunk) {
+            if (line.startsWith('new file mode')) current.status = 'added';
+            else if (line.startsWith('deleted file mode')) current.status = 'deleted';
+            else if (line.startsWith('rename from ')) {
+                current.status = 'renamed';
+                current.oldFile = line.slice('rename from '.length);
+            } else if (line.startsWith('rename to ')) current.file = line.slice('rename to '.length);
+            else if (line.startsWith('Binary files ')) current.binary = true;
+            else if (line === '--- /dev/null' || line.startsWith('--- /dev/null\t')) current.status = 'added';
+            else if (line === '+++ /dev/null' || line.startsWith('+++ /dev/null\t')) {
+                current.status = 'deleted';
+                if (!current.file) current.file = current.oldFile;
+            } else if (line.startsWith('--- ')) {
+                current.oldFile = stripDiffPrefix(line.slice(4));
+            } else if (line.startsWith('+++ ')) {
+                current.file = stripDiffPrefix(line.slice(4));
+                if (!current.oldFile) current.oldFile = current.file;
+            }
+        }
+        const header = hunkHeaderPattern.exec(line);
+        if (header) {
+            const [, oldStart = '0', oldCount = '1', newStart = '0', newCount = '1'] = header;
+            hunk = {
+                header: line,
+                oldStart: Number(oldStart),
+                oldLines: Number(oldCount),
+                newStart: Number(newStart),
+                newLines: Number(newCount),
+                lines: [],
+            };
+            current.hunks.push(hunk);
+            oldLine = hunk.oldStart;
+            newLine = hunk.newStart;
+            oldRemaining = hunk.oldLines;
+            newRemaining = hunk.newLines;
+            continue;
+        }
+        if (!hunk) continue;
+        if (line.startsWith('+')) {
+            hunk.lines.push({ type: 'add', content: line.slice(1), oldLine: null, newLine: newLine++ });
+            newRemaining--;
+        } else if (line.startsWith('-')) {
+            hunk.lines.push({ type: 'del', content: line.slice(1), oldLine: oldLine++, newLine: null });
+            oldRemaining--;
+        } else if (line.startsWith(' ') || (line === '' && oldRemaining > 0 && newRemaining > 0)) {
+            // Editors often strip the leading space from empty context lines
+            hunk.lines.push({ type: 'context', content: line.slice(1), oldLine: oldLine++, newLine: newLine++ });
+            oldRemaining--;
+            newRemaining--;
+        }
+    }
+    return files;
+}
+
+export interface StatusEntry {
+    path: string;
+    origPath: string | null;
+    index: string;
+    worktree: string;
+    staged: boolean;
+    untracked: boolean;
+}
+
+export interface RepoStatus {
+    branch: string | null;
+    upstream: string | null;
+    ahead: number;
+    behind: number;
+    entries: StatusEntry[];
+    clean: boolean;
+}
+
+/**
+ * Parse `git status --porcelain=v1 --branch` output
+ */
+export function parseGitStatus(output: string): RepoStatus {
+    const status: RepoStatus = { branch: null, upstream: null, ahead: 0, behind: 0, entries: [], clean: true };
+    for (const line of output.split('\n')) {
+        if (!line) continue;
+        if (line.startsWith('## ')) {
+            const branchLine = line.slice(3);
+            const match = /^(.+?)(?:\.\.\.(\S+))?(?: \[(.+)\])?$/.exec(branchLine);
+            status.branch = (match?.[1] ?? branchLine).replace(/^No commits yet on /, '');
+            status.upstream = match?.[2] ?? null;
+            const tracking = match?.[3] ?? '';
+            status.ahead = Number(/ahead (\d+)/.exec(tracking)?.[1] ?? 0);
+            status.behind = Number(/behind (\d+)/.exec(tracking)?.[1] ?? 0);
+            continue;
+        }
+        const index = line[0] ?? ' ';
+        const worktree = line[1] ?? ' ';
+        const rest = line.slice(3);
+        const rename = rest.split(' -> ');
+        const untracked = index === '?';
+        status.entries.push({
+            path: rename.length === 2 ? rename[1] ?? rest : rest,
+            origPath: rename.length === 2 ? rename[0] ?? null : null,
+            index,
+            worktree,
+            staged: !untracked && index !== ' ',
+            untracked,
+        });
+    }
+    status.clean = status.entries.length === 0;
+    return status;
+}
+
+export interface BlameLine {
+    line: number;
+    commit: string;
+    author: string;
+    authorTime: string;
+    summary: string;
+    content: string;
+}
+
+/**
+ * Parse `git blame --porcelain` output
+ */
+export function parseGitBlame(output: string): BlameLine[] {
+    const commits = new Map<string, { author: string; authorTime: string; summary: string }>();
+    const lines: BlameLine[] = [];
+    let currentCommit = '';
+    let currentLine = 0;
+    for (const line of output.split('\n')) {
+        const header = /^([0-9a-f]{40}) \d+ (\d+)/.exec(line);
+        if (header) {
+            currentCommit = header[1] ?? '';
+            currentLine = Number(header[2]);
+            if (!commits.has(currentCommit)) {
+                commits.set(currentCommit, { author: '', authorTime: '', summary: '' });
+            }
+            continue;
+        }
+        const info = commits.get(currentCommit);
+        if (!info) continue;
+        if (line.startsWith('author ')) info.author = line.slice(7);
+        else if (line.startsWith('author-time ')) info.authorTime = new Date(Number(line.slice(12)) * 1000).toISOString();
+        else if (line.startsWith('summary ')) info.summary = line.slice(8);
+        else if (line.startsWith('\t')) {
+            lines.push({ line: currentLine, commit: currentCommit, ...info, content: line.slice(1) });
+        }
+    }
+    return lines;
+}
@@ -0,0 +1,142 @@
+import { promises as fs } from 'fs';
+import { dirname, join, relative, resolve, sep } from 'path';
+import ignore, { type Ignore } from 'ignore';
+
+export interface WalkEntry {
+    path: string;
+    // Relative to the walk root, with forward slashes
+    relativePath: string;
+    type: 'file' | 'directory' | 'symlink';
+    depth: number;
+}
+
+export interface WalkOptions {
+    // Depth 1 is the root's own entries
+    maxDepth?: number;
+    respectGitignore?: boolean;
+    includeHidden?: boolean;
+}
+
+interface IgnoreLevel {
+    base: string;
+    matcher: Ignore;
+}
+
+// Never walked, whatever the ignore files say
+const ALWAYS_SKIPPED = new Set(['.git', '.hg', '.svn']);
+
+async function readIgnoreFile(path: string): Promise<string | null> {
+    return fs.readFile(path, 'utf-8').catch(() => null);
+}
+
+async function levelFor(dir: string, ...files: string[]): Promise<IgnoreLevel | null> {
+    const contents = (await Promise.all(files.map(file => readIgnoreFile(join(dir, file))))).filter((c): c is string => c !== null);
+    return contents.length > 0 ? { base: dir, matcher: ignore().add(contents) } : null;
+}
+
+async function findRepoRoot(start: string): Promise<string | null> {
+    let dir = start;
+    for (;;) {
+        if (await fs.stat(join(dir, '.git')).then(() => true, () => false)) return dir;
+        const parent = dirname(dir);
+        if (parent === dir) return null;
+        dir = parent;
+    }
+}
+
+/**
+ * Ignore rules in force at dir: .git/info/exclude and every .gitignore from
+ * the repository root down to dir (just dir's own outside a repository)
+ */
+async function inheritedLevels(dir: string): Promise<IgnoreLevel[]> {
+    const repoRoot = await findRepoRoot(dir);
+    const levels: IgnoreLevel[] = [];
+    if (!repoRoot) {
+        const own = await levelFor(dir, '.gitignore', '.ignore');
+        return own ? [own] : [];
+    }
+    const exclude = await readIgnoreFile(join(repoRoot, '.git', 'info', 'exclude'));
+    if (exclude) levels.push({ base: repoRoot, matcher: ignore().add(exclude) });
+    const parts = relative(repoRoot, dir).split(sep).filter(Boolean);
+    for (let i = 0; i <= parts.length; i++) {
+        const level = await levelFor(join(repoRoot, ...parts.slice(0, i)), '.gitignore', '.ignore');
+        if (level) levels.push(level);
+    }
+    return levels;
+}
+
+function isIgnored(levels: IgnoreLevel[], path: string, isDirectory: boolean): boolean {
+    return levels.some(({ base, matcher }) => {
+        const rel = relative(base, path).split(sep).join('/');
+        return rel !== '' && !rel.startsWith('..') && matcher.ignores(isDirectory ? `${rel}/` : rel);
+    });
+}
+
+/**
+ * Walk a directory depth-first in name order, skipping what git would ignore
+ * (.gitignore files at every level, .ignore, .git/info/exclude) and VCS
+ * metadata directories. Stops early when the callback returns false.
+ */
+export async function walkDirectory(root: string, options: WalkOptions, visit: (entry: WalkEntry) => boolean | void | Promise<boolean | void>): Promise<void> {
+    const start = resolve(root);
+    const respect = options.respectGitignore ?? true;
+    const maxDepth = options.maxDepth ?? Infinity;
+    let stopped = false;
+
+    const walk = async (dir: string, depth: number, levels: IgnoreLevel[]): Promise<void> => {
+        const entries = await fs.readdir(dir, { withFileTypes: true }).catch(() => []);
+        entries.sort((a, b) => a.name.localeCompare(b.name));
+        for (const entry of entries) {
+            if (stopped) return;
+            if (ALWAYS_SKIPPED.has(entry.name)) continue;
+            if (!options.includeHidden && entry.name.startsWith('.')) continue;
+            const path = join(dir, entry.name);
+            const isDirectory = entry.isDirectory();
+            if (respect && isIgnored(levels, path, isDirectory)) continue;
+            const type = isDirectory ? 'directory' : entry.isSymbolicLink() ? 'symlink' : 'file';
+            const result = await visit({ path, relativePath: relative(start, path).split(sep).join('/'), type, depth });
+            if (result === false) {
+                stopped = true;
+                return;
+            }
+            if (isDirectory && depth < maxDepth) {
+                const own = respect ? await levelFor(path, '.gitignore', '.ignore') : null;
+                await walk(path, depth + 1, own ? [...levels, own] : levels);
+            }
+        }
+    };
+
+    await walk(start, 1, respect ? await inheritedLevels(start) : []);
+}
+
+/**
+ * Predicate for paths under root that git would ignore, by the same rules
+ * walkDirectory follows, for checking paths one at a time (file watchers).
+ * Ignore files are read once; create a new filter when they change.
+ */
+export async function createIgnoreFilter(root: string): Promise<(path: string, isDirectory: boolean) => Promise<boolean>> {
+    const start = resolve(root);
+    const inherited = await inheritedLevels(start);
+    const ownLevels = new Map<string, Promise<IgnoreLevel | null>>();
+    const own = (dir: string) => {
+        if (!ownLevels.has(dir)) ownLevels.set(dir, levelFor(dir, '.gitignore', '.ignore'));
+        return ownLevels.get(dir)!;
+    };
+    return async (path, isDirectory) => {
+        const parts = relative(start, resolve(path)).split(sep).filter(Boolean);
+        if (parts.length === 0) return false;
+        if (parts[0] === '..' || parts.some(part => ALWAYS_SKIPPED.has(part))) return true;
+        // An ignored directory hides everything below it
+        const levels = [...inherited];
+        for (let i = 1; i <= parts.length; i++) {
+            const current = join(start, ...parts.slice(0, i));
+            const last = i === parts.length;
+            if (isIgnored(levels, current, last ? isDirectory : true)) return true;
+            if (!last) {
+                const level = await own(current);
+                if (level) levels.push(level);
+            }
+        }
+        return false;
+    };
+}
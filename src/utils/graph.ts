@@ -0,0 +1,122 @@
+/**
+ * Directed graph as adjacency lists: node -> the nodes it points at
+ */
+export type Edges = Map<string, string[]>;
+
+/**
+ * Strongly connected components (Tarjan), iterative so deep dependency
+ * chains cannot overflow the stack
+ */
+export function stronglyConnectedComponents(edges: Edges): string[][] {
+    const index = new Map<string, number>();
+    const lowLink = new Map<string, number>();
+    const onStack = new Set<string>();
+    const stack: string[] = [];
+    const components: string[][] = [];
+    let counter = 0;
+
+    for (const root of edges.keys()) {
+        if (index.has(root)) continue;
+        const work: { node: string; next: number }[] = [{ node: root, next: 0 }];
+        index.set(root, counter);
+        lowLink.set(root, counter++);
+        stack.push(root);
+        onStack.add(root);
+        while (work.length > 0) {
+            const frame = work[work.length - 1]!;
+            const targets = edges.get(frame.node) ?? [];
+            if (frame.next < targets.length) {
+                const target = targets[frame.next++]!;
+                if (!index.has(target)) {
+                    index.set(target, counter);
+                    lowLink.set(target, counter++);
+                    stack.push(target);
+                    onStack.add(target);
+                    work.push({ node: target, next: 0 });
+                } else if (onStack.has(target)) {
+                    lowLink.set(frame.node, Math.min(lowLink.get(frame.node)!, index.get(target)!));
+                }
+                continue;
+            }
+            work.pop();
+            const parent = work[work.length - 1];
+            if (parent) lowLink.set(parent.node, Math.min(lowLink.get(parent.node)!, lowLink.get(frame.node)!));
+            if (lowLink.get(frame.node) === index.get(frame.node)) {
+                const component: string[] = [];
+                let member: string;
+                do {
+                    member = stack.pop()!;
+                    onStack.delete(member);
+                    component.push(member);
+                } while (member !== frame.node);
+                components.push(component);
+            }
+        }
+    }
+    return components;
+}
+
+// Shortest path from start back to itself using only nodes of the component
+function cycleThrough(edges: Edges, start: string, members: Set<string>): string[] {
+    const previous = new Map<string, string>();
+    const queue = [start];
+    for (let i = 0; i < queue.length; i++) {
+        const node = queue[i]!;
+        for (const target of edges.get(node) ?? []) {
+            if (!members.has(target)) continue;
+            if (target === start) {
+                const path = [start];
+                for (let at: string | undefined = node; at !== undefined && at !== start; at = previous.get(at)) path.unshift(at);
+                return [start, ...path.slice(0, -1), start];
+            }
+            if (!previous.has(target)) {
+                previous.set(target, node);
+                queue.push(target);
+            }
+        }
+    }
+    return [start];
+}
+
+/**
+ * One representative cycle per strongly connected component, as a path that
+ * starts and ends at the same node: [a, b, a]. Self-loops give [a, a].
+ */
+export function findCycles(edges: Edges): string[][] {
+    const cycles: string[][] = [];
+    for (const component of stronglyConnectedComponents(edges)) {
+        const node = component.length === 1 ? component[0]! : [...component].sort()[0]!;
+        if (component.length === 1 && !(edges.get(node) ?? []).includes(node)) continue;
+        cycles.push(component.length === 1 ? [node, node] : cycleThrough(edges, node, new Set(component)));
+    }
+    return cycles.sort((a, b) => (a[0] ?? '').localeCompare(b[0] ?? ''));
+}
+
+export function reverseEdges(edges: Edges): Edges {
+    const reversed: Edges = new Map();
+    for (const node of edges.keys()) reversed.set(node, []);
+    for (const [node, targets] of edges) {
+        for (const target of targets) {
+            if (!reversed.has(target)) reversed.set(target, []);
+            reversed.get(target)!.push(node);
+        }
+    }
+    return reversed;
+}
+
+/**
+ * Every node reachable from start, not including start itself
+ */
+export function reachable(edges: Edges, start: string): string[] {
+    const seen = new Set<string>([start]);
+    const queue = [start];
+    for (let i = 0; i < queue.length; i++) {
+        for (const target of edges.get(queue[i]!) ?? []) {
+            if (seen.has(target)) continue;
+            seen.add(target);
+            queue.push(target);
+        }
+    }
+    seen.delete(start);
+    return [...seen].sort();
+}
@@ -0,0 +1,30 @@
+/**
+ * Split a stream of concatenated JSON objects (go vet -json, govulncheck -json),
+ * ignoring any non-JSON text between them
+ */
+export function splitJsonObjects(output: string): string[] {
+    const objects: string[] = [];
+    let depth = 0;
+    let start = -1;
+    let inString = false;
+    for (let i = 0; i < output.length; i++) {
+        const ch = output[i];
+        if (inString) {
+            if (ch === '\\') i++;
+            else if (ch === '"') inString = false;
+            continue;
+        }
+        if (ch === '"') inString = true;
+        else if (ch === '{') {
+            if (depth === 0) start = i;
+            depth++;
+        } else if (ch === '}' && depth > 0) {
+            depth--;
+            if (depth === 0 && start >= 0) {
+                objects.push(output.slice(start, i + 1));
+                start = -1;
+            }
+        }
+    }
+    return objects;
+}
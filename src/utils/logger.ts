@@ -0,0 +1,109 @@
+import { AsyncLocalStorage } from 'async_hooks';
+
+export type LogLevel = 'debug' | 'info' | 'warn' | 'error';
+export type LogFormat = 'text' | 'json';
+export type LogAttrs = Record<string, unknown>;
+
+const LEVELS: Record<LogLevel, number> = { debug: 10, info: 20, warn: 30, error: 40 };
+
+// Attributes every line logged during a tool call carries (requestId, tool)
+const requestContext = new AsyncLocalStorage<LogAttrs>();
+
+function parseLevel(value: string | undefined): LogLevel {
+    const level = value?.toLowerCase();
+    return level === 'debug' || level === 'warn' || level === 'error' ? level : 'info';
+}
+
+function serialize(value: unknown): unknown {
+    if (value instanceof Error) return { name: value.name, message: value.message, ...(value.stack ? { stack: value.stack } : {}) };
+    return value;
+}
+
+// key=value text: strings are quoted when they would not read back as one token, errors show their message
+function formatValue(value: unknown): string {
+    const text = value instanceof Error ? value.message : value;
+    if (typeof text === 'string') return /[\s"=]/.test(text) || text === '' ? JSON.stringify(text) : text;
+    return typeof text === 'object' && text !== null ? JSON.stringify(text) : String(text);
+}
+
+/**
+ * Leveled logger writing to stderr (stdout carries the MCP protocol in stdio
+ * mode). MCP_LOG_LEVEL picks the minimum level (default info) and
+ * MCP_LOG_FORMAT=json switches from key=value text lines to JSON lines.
+ */
+export class Logger {
+    private level: LogLevel;
+    private format: LogFormat;
+    private readonly write: (line: string) => void;
+
+    constructor(options: { level?: LogLevel; format?: LogFormat; write?: (line: string) => void } = {}) {
+        this.level = options.level ?? parseLevel(process.env.MCP_LOG_LEVEL);
+        this.format = options.format ?? (process.env.MCP_LOG_FORMAT === 'json' ? 'json' : 'text');
+        this.write = options.write ?? (line => process.stderr.write(line + '\n'));
+    }
+
+    public getLevel(): LogLevel {
+        return this.level;
+    }
+
+    public setLevel(level: LogLevel): void {
+        this.level = level;
+    }
+
+    public getFormat(): LogFormat {
+        return this.format;
+    }
+
+    public setFormat(format: LogFormat): void {
+        this.format = format;
+    }
+
+    public enabled(level: LogLevel): boolean {
+        return LEVELS[level] >= LEVELS[this.level];
+    }
+
+    public debug(message: string, attrs?: LogAttrs): void {
+        this.log('debug', message, attrs);
+    }
+
+    public info(message: string, attrs?: LogAttrs): void {
+        this.log('info', message, attrs);
+    }
+
+    public warn(message: string, attrs?: LogAttrs): void {
+        this.log('warn', message, attrs);
+    }
+
+    public error(message: string, attrs?: LogAttrs): void {
+        this.log('error', message, attrs);
+    }
+
+    public log(level: LogLevel, message: string, attrs: LogAttrs = {}): void {
+        if (!this.enabled(level)) return;
+        const all = { ...requestContext.getStore(), ...attrs };
+        const time = new Date().toISOString();
+        if (this.format === 'json') {
+            this.write(JSON.stringify({ time, level, msg: message, ...Object.fromEntries(Object.entries(all).map(([k, v]) => [k, serialize(v)])) }));
+            return;
+        }
+        const pairs = Object.entries(all).filter(([, v]) => v !== undefined).map(([k, v]) => `${k}=${formatValue(v)}`);
+        this.write([time, level.toUpperCase().padEnd(5), `[MCP] ${message}`, ...pairs].join(' '));
+    }
+}
+
+/**
+ * Run fn with attrs attached to every line logged inside it
+ */
+export function withLogContext<T>(attrs: LogAttrs, fn: () => T): T {
+    return requestContext.run({ ...requestContext.getStore(), ...attrs }, fn);
+}
+
+/**
+ * Correlation id of the tool call being handled, if any
+ */
+export function getRequestId(): string | undefined {
+    const id = requestContext.getStore()?.requestId;
+    return typeof id === 'string' ? id : undefined;
+}
+
+export const logger = new Logger();
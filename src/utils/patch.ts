@@ -0,0 +1,130 @@
+import { type DiffHunk, type DiffLine, type FileDiff } from './git.js';
+
+export interface HunkResult {
+    index: number;
+    header: string;
+    applied: boolean;
+    // Lines between where the hunk header said it starts and where it matched
+    offset: number;
+    // Context lines dropped from each end of the hunk to make it match
+    fuzz: number;
+    error?: string;
+}
+
+export interface FilePatchResult {
+    content: string;
+    hunks: HunkResult[];
+    applied: boolean;
+}
+
+export interface ApplyDiffOptions {
+    // Maximum context lines that may be ignored at each end of a hunk (GNU patch default: 2)
+    fuzz?: number;
+    // Compare lines with runs of whitespace collapsed
+    ignoreWhitespace?: boolean;
+}
+
+function splitLines(content: string): { lines: string[]; trailingNewline: boolean } {
+    const normalized = content.replace(/\r\n/g, '\n');
+    const trailingNewline = normalized.endsWith('\n');
+    const lines = normalized.split('\n');
+    if (trailingNewline) lines.pop();
+    return { lines: normalized === '' ? [] : lines, trailingNewline: trailingNewline || normalized === '' };
+}
+
+function normalizeWhitespace(line: string): string {
+    return line.trim().replace(/\s+/g, ' ');
+}
+
+function matchesAt(lines: string[], block: string[], at: number, ignoreWhitespace: boolean): boolean {
+    if (at < 0 || at + block.length > lines.length) return false;
+    return block.every((line, i) => {
+        const actual = lines[at + i] ?? '';
+        return ignoreWhitespace ? normalizeWhitespace(actual) === normalizeWhitespace(line) : actual === line;
+    });
+}
+
+// Search outward from the expected position, never before minStart (hunks apply in order)
+function findBlock(lines: string[], block: string[], expected: number, minStart: number, ignoreWhitespace: boolean): number {
+    const maxDistance = Math.max(expected - minStart, lines.length - expected);
+    for (let distance = 0; distance <= maxDistance; distance++) {
+        for (const at of distance === 0 ? [expected] : [expected - distance, expected + distance]) {
+            if (at >= minStart && matchesAt(lines, block, at, ignoreWhitespace)) return at;
+        }
+    }
+    return -1;
+}
+
+// Drop up to `fuzz` context lines from each end of the hunk
+function trimContext(hunk: DiffHunk, fuzz: number): { lines: DiffLine[]; leading: number } {
+    let start = 0;
+    while (start < fuzz && hunk.lines[start]?.type === 'context') start++;
+    let end = hunk.lines.length;
+    while (hunk.lines.length - end < fuzz && end > start && hunk.lines[end - 1]?.type === 'context') end--;
+    return { lines: hunk.lines.slice(start, end), leading: start };
+}
+
+function countContext(hunk: DiffHunk): number {
+    let leading = 0;
+    while (hunk.lines[leading]?.type === 'context') leading++;
+    let trailing = 0;
+    while (trailing < hunk.lines.length - leading && hunk.lines[hunk.lines.length - 1 - trailing]?.type === 'context') trailing++;
+    return Math.max(leading, trailing);
+}
+
+/**
+ * Apply one file's hunks to its current content. Hunks may have moved; when
+ * context does not match exactly, up to `fuzz` context lines are ignored at
+ * each end. Each hunk's result is reported separately and failed hunks are
+ * skipped, so `content` holds every hunk that did apply.
+ */
+export function applyFileDiff(content: string, fileDiff: FileDiff, options: ApplyDiffOptions = {}): FilePatchResult {
+    const { fuzz: maxFuzz = 0, ignoreWhitespace = false } = options;
+    const { lines, trailingNewline } = splitLines(content);
+    const hunks: HunkResult[] = [];
+    // Line count change from hunks applied so far, to predict where the next one starts
+    let delta = 0;
+    let minStart = 0;
+    fileDiff.hunks.forEach((hunk, index) => {
+        const isInsertion = hunk.lines.every(l => l.type === 'add');
+        // A pure insertion's start line is the line it goes after
+        const expected = Math.max(0, (isInsertion ? hunk.oldStart : hunk.oldStart - 1) + delta);
+        if (isInsertion) {
+            const at = Math.min(expected, lines.length);
+            lines.splice(at, 0, ...hunk.lines.map(l => l.content));
+            hunks.push({ index, header: hunk.header, applied: true, offset: at - expected, fuzz: 0 });
+            delta += hunk.lines.length;
+            minStart = at + hunk.lines.length;
+            return;
+        }
+        const fuzzLimit = Math.min(maxFuzz, countContext(hunk));
+        for (let fuzz = 0; fuzz <= fuzzLimit; fuzz++) {
+            const trimmed = trimContext(hunk, fuzz);
+            const oldBlock = trimmed.lines.filter(l => l.type !== 'add').map(l => l.content);
+            // Fuzzing must leave something to anchor on
+            if (oldBlock.length === 0) break;
+            const at = findBlock(lines, oldBlock, expected + trimmed.leading, minStart, ignoreWhitespace);
+            if (at < 0) continue;
+            // Context lines keep the file's version so whitespace-insensitive matches do not rewrite them
+            const replacement: string[] = [];
+            let cursor = at;
+            for (const line of trimmed.lines) {
+                if (line.type === 'context') replacement.push(lines[cursor++] ?? line.content);
+                else if (line.type === 'del') cursor++;
+                else replacement.push(line.content);
+            }
+            lines.splice(at, oldBlock.length, ...replacement);
+            hunks.push({ index, header: hunk.header, applied: true, offset: at - trimmed.leading - expected, fuzz });
+            delta += replacement.length - oldBlock.length;
+            minStart = at + replacement.length;
+            return;
+        }
+        hunks.push({ index, header: hunk.header, applied: false, offset: 0, fuzz: 0, error: 'Hunk context does not match the file' });
+    });
+    const joined = lines.join('\n');
+    return {
+        content: lines.length > 0 && trailingNewline ? `${joined}\n` : joined,
+        hunks,
+        applied: hunks.every(h => h.applied),
+    };
+}
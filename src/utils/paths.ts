@@ -0,0 +1,62 @@
+import { promises as fs } from 'fs';
+import { homedir } from 'os';
+import { dirname, join } from 'path';
+
+/**
+ * Walk up from startDir looking for fileName; returns its path or null at the filesystem root
+ */
+export async function findUp(startDir: string, fileName: string): Promise<string | null> {
+    let currentDir = startDir;
+    while (true) {
+        const tryPath = join(currentDir, fileName);
+        try {
+            await fs.access(tryPath);
+            return tryPath;
+        } catch { /* keep searching */ }
+        const parentDir = dirname(currentDir);
+        if (parentDir === currentDir) return null;
+        currentDir = parentDir;
+    }
+}
+
+/**
+ * Directory for state kept across runs (built helpers, fuzz corpora), under XDG_CACHE_HOME or ~/.cache
+ */
+export function cacheDirectory(...segments: string[]): string {
+    return join(process.env.XDG_CACHE_HOME || join(homedir(), '.cache'), 'code-feedback', ...segments);
+}
+
+// Argument names that point at files or project directories
+export const PATH_ARG_KEYS = ['filePath', 'projectPath', 'repoPath', 'path', 'file_path'];
+
+/**
+ * First path-like argument of a tool call, used to locate the project it targets
+ */
+export function getPathArg(args: Record<string, unknown>): string | undefined {
+    for (const key of PATH_ARG_KEYS) {
+        const value = args[key];
+        if (typeof value === 'string' && value) return value;
+    }
+    return undefined;
+}
+
+/**
+ * Advertise the workspace argument on tools that take a path. With a
+ * workspace the path is optional (it defaults to the workspace root).
+ * Tools that take a projectPath also get project, to pick a sub-project.
+ */
+export function withWorkspaceArg(inputSchema: any) {
+    const properties = inputSchema?.properties ?? {};
+    if (!PATH_ARG_KEYS.some(key => key in properties)) return inputSchema;
+    return {
+        ...inputSchema,
+        properties: {
+            ...properties,
+            workspace: { type: 'string', description: 'Registered workspace id; paths are then relative to its root' },
+            ...('projectPath' in properties
+                ? { project: { type: 'string', description: 'Sub-project to run in, by name or path relative to projectPath (see list_projects)' } }
+                : {}),
+        },
+        ...(Array.isArray(inputSchema.required) ? { required: inputSchema.required.filter((key: string) => !PATH_ARG_KEYS.includes(key)) } : {}),
+    };
+}
@@ -0,0 +1,152 @@
+// JSON pointers (RFC 6901) and the lines they point at in YAML and JSON documents
+
+export function toPointer(segments: Array<string | number>): string {
+    return segments.map(s => `/${String(s).replace(/~/g, '~0').replace(/\//g, '~1')}`).join('');
+}
+
+export function parsePointer(pointer: string): string[] {
+    return pointer.split('/').slice(1).map(s => s.replace(/~1/g, '/').replace(/~0/g, '~'));
+}
+
+const escapeRegExp = (text: string) => text.replace(/[.*+?^${}()|[\]\\]/g, '\\$&');
+const indentOf = (line: string) => /^\s*/.exec(line)![0].length;
+const isContent = (line: string) => line.trim() !== '' && !line.trim().startsWith('#');
+
+/**
+ * The 0-based line a path of keys and indexes leads to in the block-style
+ * YAML between lines from and to, or the deepest part of it that exists.
+ * Indentation is all it goes by, which holds for hand-written manifests
+ * and specs; flow-style collections are not entered.
+ */
+export function locateYamlPath(lines: string[], from: number, to: number, segments: string[]): number {
+    let found = from;
+    while (found < to && !isContent(lines[found]!)) found++;
+    // The end of the block that starts at line k and holds everything indented past indent
+    const blockEnd = (k: number, indent: number) => {
+        let end = k + 1;
+        while (end < to && (!isContent(lines[end]!) || indentOf(lines[end]!) > indent)) end++;
+        return end;
+    };
+    for (const segment of segments) {
+        if (/^\d+$/.test(segment)) {
+            const items = [];
+            let itemIndent: number | null = null;
+            for (let k = from; k < to; k++) {
+                const item = /^(\s*)-(\s|$)/.exec(lines[k]!);
+                if (!item) continue;
+                itemIndent ??= item[1]!.length;
+                if (item[1]!.length === itemIndent) items.push(k);
+            }
+            const k = items[Number(segment)];
+            if (k === undefined || itemIndent === null) break;
+            found = k;
+            // The item's first key is on its own "- " line
+            [from, to] = [k, blockEnd(k, itemIndent)];
+            continue;
+        }
+        const keyLine = new RegExp(`^(\\s*)(- )?["']?${escapeRegExp(segment)}["']?\\s*:(\\s|$)`);
+        // Keys of a mapping share the indentation of its first key
+        let childIndent: number | null = null;
+        let match = -1;
+        for (let k = from; k < to; k++) {
+            const line = lines[k]!;
+            if (!isContent(line)) continue;
+            const key = /^(\s*)(- )?/.exec(line)!;
+            const indent = key[1]!.length + (key[2] ? 2 : 0);
+            childIndent ??= indent;
+            if (indent !== childIndent) continue;
+            if (keyLine.test(line)) {
+                match = k;
+                break;
+            }
+        }
+        if (match < 0) break;
+        found = match;
+        [from, to] = [match + 1, blockEnd(match, childIndent!)];
+    }
+    return found;
+}
+
+/**
+ * The 1-based line of every value in a JSON document, by pointer; an
+ * object member's line is that of its key. Null when it does not parse.
+ */
+export function indexJsonPointers(source: string): Map<string, number> | null {
+    const lines = new Map<string, number>();
+    let pos = 0;
+    let line = 1;
+    const skip = () => {
+        while (pos < source.length && /\s/.test(source[pos]!)) {
+            if (source[pos] === '\n') line++;
+            pos++;
+        }
+    };
+    const string = (): string => {
+        const start = pos;
+        pos++;
+        while (pos < source.length && source[pos] !== '"') pos += source[pos] === '\\' ? 2 : 1;
+        pos++;
+        return JSON.parse(source.slice(start, pos));
+    };
+    const value = (pointer: string): void => {
+        skip();
+        if (!lines.has(pointer)) lines.set(pointer, line);
+        const ch = source[pos];
+        if (ch === '{' || ch === '[') {
+            const close = ch === '{' ? '}' : ']';
+            pos++;
+            skip();
+            let index = 0;
+            while (pos < source.length && source[pos] !== close) {
+                if (ch === '{') {
+                    const keyLine = line;
+                    const key = string();
+                    skip();
+                    if (source[pos] !== ':') throw new Error('expected :');
+                    pos++;
+                    const member = `${pointer}${toPointer([key])}`;
+                    lines.set(member, keyLine);
+                    value(member);
+                } else {
+                    value(`${pointer}/${index++}`);
+                }
+                skip();
+                if (source[pos] === ',') {
+                    pos++;
+                    skip();
+                }
+            }
+            pos++;
+        } else if (ch === '"') {
+            string();
+        } else {
+            while (pos < source.length && !/[\s,}\]]/.test(source[pos]!)) pos++;
+        }
+    };
+    try {
+        value('');
+        return lines;
+    } catch {
+        return null;
+    }
+}
+
+/**
+ * The 1-based line a JSON pointer leads to in a YAML or JSON document,
+ * or the deepest part of it that exists
+ */
+export function locatePointer(source: string, pointer: string): number {
+    const segments = parsePointer(pointer);
+    if (source.trimStart().startsWith('{')) {
+        const index = indexJsonPointers(source);
+        if (index) {
+            for (let k = segments.length; k >= 0; k--) {
+                const line = index.get(toPointer(segments.slice(0, k)));
+                if (line !== undefined) return line;
+            }
+        }
+        return 1;
+    }
+    const lines = source.split('\n').map(l => l.replace(/\r$/, ''));
+    return locateYamlPath(lines, 0, lines.length, segments) + 1;
+}
@@ -0,0 +1,96 @@
+import * as diffLib from 'diff';
+
+/**
+ * What a mutating tool would do to one path, reported instead of writing when a call is a dry run
+ */
+export interface ChangePreview {
+    path: string;
+    action: 'create' | 'modify' | 'delete' | 'move' | 'copy' | 'mkdir';
+    // Source of a move or copy
+    from?: string;
+    bytesBefore: number;
+    bytesAfter: number;
+    // Absent for binary content and directories
+    linesBefore?: number;
+    linesAfter?: number;
+    added?: number;
+    removed?: number;
+    // Human-readable post-condition, e.g. "would grow by 40 lines (+42 -2)"
+    summary: string;
+    diff?: string;
+}
+
+type Content = string | Buffer | null;
+
+function isText(content: Content): boolean {
+    return content === null || typeof content === 'string' || !content.includes(0);
+}
+
+function countLines(text: string): number {
+    if (text === '') return 0;
+    return text.split('\n').length - (text.endsWith('\n') ? 1 : 0);
+}
+
+function plural(n: number, word: string): string {
+    return `${n} ${word}${n === 1 ? '' : 's'}`;
+}
+
+/**
+ * Preview replacing a file's content (null: the file does not exist before,
+ * or is deleted after) with a unified diff and line counts for text
+ */
+export function previewChange(path: string, before: Content, after: Content, options: { action?: 'move' | 'copy'; from?: string } = {}): ChangePreview {
+    const action = options.action ?? (after === null ? 'delete' : before === null ? 'create' : 'modify');
+    const preview: ChangePreview = {
+        path,
+        action,
+        ...(options.from ? { from: options.from } : {}),
+        bytesBefore: before === null ? 0 : Buffer.byteLength(before),
+        bytesAfter: after === null ? 0 : Buffer.byteLength(after),
+        summary: '',
+    };
+    const text = isText(before) && isText(after);
+    if (text) {
+        const oldText = before?.toString('utf-8') ?? '';
+        const newText = after?.toString('utf-8') ?? '';
+        preview.linesBefore = countLines(oldText);
+        preview.linesAfter = countLines(newText);
+        const patch = diffLib.structuredPatch(path, path, oldText, newText, '', '');
+        preview.added = patch.hunks.reduce((sum, h) => sum + h.lines.filter(l => l.startsWith('+')).length, 0);
+        preview.removed = patch.hunks.reduce((sum, h) => sum + h.lines.filter(l => l.startsWith('-')).length, 0);
+        if (patch.hunks.length > 0) preview.diff = diffLib.createPatch(path, oldText, newText, '', '');
+    }
+
+    const size = (lines: number | undefined, bytes: number) => text ? plural(lines ?? 0, 'line') : plural(bytes, 'byte');
+    const counts = text ? ` (+${preview.added} -${preview.removed})` : '';
+    if (action === 'move' || action === 'copy') {
+        preview.summary = `would be ${action === 'move' ? 'moved' : 'copied'} from ${options.from}${before === null ? '' : `, replacing ${size(preview.linesBefore, preview.bytesBefore)}`}`;
+    } else if (action === 'create') {
+        preview.summary = `would be created with ${size(preview.linesAfter, preview.bytesAfter)}`;
+    } else if (action === 'delete') {
+        preview.summary = `would be deleted (${size(preview.linesBefore, preview.bytesBefore)})`;
+    } else if (text ? preview.added === 0 && preview.removed === 0 : Buffer.compare(Buffer.from(before ?? ''), Buffer.from(after ?? '')) === 0) {
+        preview.summary = 'would be unchanged';
+    } else {
+        const delta = text ? (preview.linesAfter ?? 0) - (preview.linesBefore ?? 0) : preview.bytesAfter - preview.bytesBefore;
+        const unit = text ? 'line' : 'byte';
+        preview.summary = delta > 0 ? `would grow by ${plural(delta, unit)}${counts}`
+            : delta < 0 ? `would shrink by ${plural(-delta, unit)}${counts}`
+            : `would keep ${size(preview.linesAfter, preview.bytesAfter)}${counts}`;
+    }
+    return preview;
+}
+
+export function previewDirectory(path: string, action: 'mkdir' | 'delete' | 'move', summary: string, from?: string): ChangePreview {
+    return { path, action, ...(from ? { from } : {}), bytesBefore: 0, bytesAfter: 0, summary };
+}
+
+/**
+ * Dry-run output: one summary line per path, then the diffs
+ */
+export function formatPreviews(previews: ChangePreview[]): string {
+    if (previews.length === 0) return 'Dry run: no changes';
+    const summaries = previews.map(p => `${p.path}: ${p.summary}`).join('\n');
+    const diffs = previews.flatMap(p => p.diff ? [p.diff] : []);
+    return `Dry run, nothing was written:\n${summaries}${diffs.length > 0 ? `\n\n${'`'.repeat(3)}diff\n${diffs.join('')}${'`'.repeat(3)}\n` : ''}`;
+}
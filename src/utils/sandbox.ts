@@ -0,0 +1,52 @@
+import { promises as fs } from 'fs';
+import { dirname, resolve, isAbsolute } from 'path';
+import Config from '../config/index.js';
+
+export type AccessMode = 'read' | 'write';
+
+function assertAccess(path: string, mode: AccessMode, label: string): void {
+    const config = Config.getInstance();
+    if (!config.isPathAllowed(path)) {
+        throw new Error(`Path not allowed: ${label} (${path})`);
+    }
+    if (mode === 'write' && !config.isPathWritable(path)) {
+        throw new Error(`Path not allowed: ${label} is in a read-only root (${path})`);
+    }
+}
+
+// Closest ancestor that exists on disk, used to validate paths that are about to be created
+async function realpathOfNearestExisting(abs: string): Promise<string> {
+    let current = abs;
+    while (true) {
+        try {
+            return await fs.realpath(current);
+        } catch (e: any) {
+            const parent = dirname(current);
+            if (e.code !== 'ENOENT' || parent === current) throw e;
+            current = parent;
+        }
+    }
+}
+
+/**
+ * Resolve a path inside the sandbox: it must sit under an allowed root both
+ * lexically and after symlink resolution, and write access requires a
+ * root that is not read-only.
+ */
+export async function validatePath(p: string, mode: AccessMode = 'read'): Promise<string> {
+    const abs = isAbsolute(p) ? resolve(p) : resolve(process.cwd(), p);
+    assertAccess(abs, mode, 'path');
+    try {
+        const real = await fs.realpath(abs);
+        assertAccess(real, mode, 'symlink target');
+        return real;
+    } catch (e: any) {
+        if (e.code === 'ENOENT') {
+            // For new files, check the closest existing ancestor
+            const realAncestor = await realpathOfNearestExisting(dirname(abs));
+            assertAccess(realAncestor, mode, 'parent dir');
+            return abs;
+        }
+        throw e;
+    }
+}
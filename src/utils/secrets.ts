@@ -0,0 +1,103 @@
+import Config, { type SecretScanMode } from '../config/index.js';
+import { getEffectiveConfig, strictestSecretScan } from '../config/project.js';
+
+export interface SecretFinding {
+    rule: string;
+    description: string;
+    line: number;
+    column: number;
+    // Redacted so the secret itself never ends up in tool output
+    match: string;
+}
+
+interface SecretRule {
+    id: string;
+    description: string;
+    pattern: RegExp;
+    // Capture group holding the secret value; defaults to the whole match
+    group?: number;
+    // Minimum Shannon entropy (bits per char) for the value to count
+    minEntropy?: number;
+}
+
+const SECRET_RULES: SecretRule[] = [
+    { id: 'private-key', description: 'Private key', pattern: /-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----/ },
+    { id: 'aws-access-key-id', description: 'AWS access key ID', pattern: /\b((?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16})\b/, group: 1 },
+    { id: 'aws-secret-access-key', description: 'AWS secret access key', pattern: /aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})\b/i, group: 1, minEntropy: 3.5 },
+    { id: 'github-token', description: 'GitHub token', pattern: /\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{60,})\b/, group: 1 },
+    { id: 'gitlab-token', description: 'GitLab personal access token', pattern: /\b(glpat-[A-Za-z0-9_-]{20,})\b/, group: 1 },
+    { id: 'slack-token', description: 'Slack token', pattern: /\b(xox[abposr]-[A-Za-z0-9-]{10,})\b/, group: 1 },
+    { id: 'stripe-secret-key', description: 'Stripe secret key', pattern: /\b((?:sk|rk)_live_[A-Za-z0-9]{20,})\b/, group: 1 },
+    { id: 'google-api-key', description: 'Google API key', pattern: /\b(AIza[0-9A-Za-z_-]{35})\b/, group: 1 },
+    { id: 'openai-api-key', description: 'OpenAI API key', pattern: /\b(sk-(?:proj-)?[A-Za-z0-9_-]{32,})\b/, group: 1, minEntropy: 3.5 },
+    { id: 'jwt', description: 'JSON Web Token', pattern: /\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})\b/, group: 1 },
+    {
+        id: 'generic-secret',
+        description: 'High-entropy value assigned to a secret-like name',
+        pattern: /(?:secret|token|passw(?:or)?d|api[_-]?key|access[_-]?key|auth|credential)[\w-]*["']?\s*[:=]\s*["'`]([^"'`\s]{12,})["'`]/i,
+        group: 1,
+        minEntropy: 3.5,
+    },
+];
+
+// Lines carrying one of these markers are intentionally exempt (e.g. test fixtures)
+const ALLOW_MARKERS = ['pragma: allowlist secret', 'gitleaks:allow'];
+
+/**
+ * Shannon entropy in bits per character
+ */
+export function shannonEntropy(value: string): number {
+    if (!value) return 0;
+    const counts = new Map<string, number>();
+    for (const ch of value) counts.set(ch, (counts.get(ch) ?? 0) + 1);
+    let entropy = 0;
+    for (const count of counts.values()) {
+        const p = count / value.length;
+        entropy -= p * Math.log2(p);
+    }
+    return entropy;
+}
+
+function redact(value: string): string {
+    return value.length <= 8 ? '****' : `${value.slice(0, 4)}****${value.slice(-2)}`;
+}
+
+/**
+ * Find likely credentials in file content
+ */
+export function scanForSecrets(content: string): SecretFinding[] {
+    const findings: SecretFinding[] = [];
+    const lines = content.split('\n');
+    lines.forEach((line, index) => {
+        if (ALLOW_MARKERS.some(marker => line.includes(marker))) return;
+        for (const rule of SECRET_RULES) {
+            const match = rule.pattern.exec(line);
+            if (!match) continue;
+            const value = match[rule.group ?? 0] ?? match[0];
+            if (rule.minEntropy !== undefined && shannonEntropy(value) < rule.minEntropy) continue;
+            findings.push({
+                rule: rule.id,
+                description: rule.description,
+                line: index + 1,
+                column: match.index + match[0].indexOf(value) + 1,
+                match: redact(value),
+            });
+            // One finding per line; the most specific rule is listed first
+            break;
+        }
+    });
+    return findings;
+}
+
+/**
+ * Secret check run before a file tool persists content. `secretScan` in
+ * .code-feedback.yaml can make MCP_SECRET_SCAN stricter, never looser.
+ */
+export async function checkContentForSecrets(filePath: string, content: string): Promise<{ blocked: boolean; warnings: string[]; findings: SecretFinding[] }> {
+    const { config } = await getEffectiveConfig(filePath);
+    const mode: SecretScanMode = strictestSecretScan(Config.getInstance().getSecretScanMode(), config.secretScan)!;
+    if (mode === 'off') return { blocked: false, warnings: [], findings: [] };
+    const findings = scanForSecrets(content);
+    const warnings = findings.map(f => `Possible secret (${f.description}) at ${filePath}:${f.line}:${f.column}: ${f.match}`);
+    return { blocked: mode === 'block' && findings.length > 0, warnings, findings };
+}
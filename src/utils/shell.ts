@@ -0,0 +1,6 @@
+/**
+ * Quote a string for safe use as a single POSIX shell word
+ */
+export function shellQuote(value: string): string {
+    return `'${value.replace(/'/g, `'\\''`)}'`;
+}
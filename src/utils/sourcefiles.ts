@@ -0,0 +1,78 @@
+import { promises as fs } from 'fs';
+import { dirname, extname, resolve } from 'path';
+import { minimatch } from 'minimatch';
+import { walkDirectory } from './gitignore.js';
+
+export const SOURCE_EXTENSIONS = ['.go', '.ts', '.tsx', '.mts', '.cts', '.js', '.jsx', '.mjs', '.cjs', '.py', '.java', '.kt', '.scala', '.c', '.h', '.cc', '.cpp', '.hpp', '.cs', '.rs', '.swift', '.php', '.rb'];
+// Languages whose line comments start with #
+export const HASH_COMMENT_EXTENSIONS = new Set(['.py', '.rb']);
+
+const TEST_FILE = /(_test\.go|\.(test|spec)\.[cm]?[jt]sx?|(^|\/)test_[^/]*\.py|_test\.py|Test\.java)$|(^|\/)(__tests__|tests?)\//;
+const GENERATED = /^\/\/ Code generated .* DO NOT EDIT\.$|@generated/m;
+const MAX_FILE_BYTES = 1024 * 1024;
+const MAX_FILES = 10000;
+
+export interface SourceFileOptions {
+    extensions?: string[];
+    // Globs relative to the root
+    exclude?: string[];
+    includeTests?: boolean;
+}
+
+export interface CollectedSources {
+    // The directory searched, or the file's directory
+    root: string;
+    files: Array<{ path: string; source: string }>;
+    warnings: string[];
+}
+
+export function isTestFile(relativePath: string): boolean {
+    return TEST_FILE.test(relativePath);
+}
+
+/**
+ * Read the source files under target (a directory or one file), respecting
+ * .gitignore and skipping generated and oversized files.
+ */
+export async function collectSourceFiles(target: string, options: SourceFileOptions = {}): Promise<CollectedSources> {
+    const path = resolve(target);
+    const isDirectory = (await fs.stat(path)).isDirectory();
+    const root = isDirectory ? path : dirname(path);
+    const wanted = new Set((options.extensions ?? SOURCE_EXTENSIONS).map(e => e.toLowerCase()));
+    const exclude = options.exclude ?? [];
+    const warnings: string[] = [];
+
+    const candidates: string[] = [];
+    if (isDirectory) {
+        await walkDirectory(root, {}, entry => {
+            if (entry.type !== 'file' || !wanted.has(extname(entry.path).toLowerCase())) return;
+            if (!options.includeTests && isTestFile(entry.relativePath)) return;
+            if (exclude.some(glob => minimatch(entry.relativePath, glob, { dot: true }))) return;
+            candidates.push(entry.path);
+            if (candidates.length >= MAX_FILES) {
+                warnings.push(`Stopped at ${MAX_FILES} files; narrow path or exclude`);
+                return false;
+            }
+        });
+    } else {
+        candidates.push(path);
+    }
+
+    const files: CollectedSources['files'] = [];
+    let skipped = 0;
+    for (const file of candidates) {
+        const stat = await fs.stat(file);
+        if (stat.size > MAX_FILE_BYTES) {
+            skipped++;
+            continue;
+        }
+        const source = await fs.readFile(file, 'utf-8');
+        if (GENERATED.test(source.slice(0, 2000))) {
+            skipped++;
+            continue;
+        }
+        files.push({ path: file, source });
+    }
+    if (skipped > 0) warnings.push(`${skipped} generated or oversized file(s) skipped`);
+    return { root, files, warnings };
+}
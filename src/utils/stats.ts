@@ -0,0 +1,78 @@
+export function median(values: number[]): number {
+    if (values.length === 0) return NaN;
+    const sorted = [...values].sort((a, b) => a - b);
+    const mid = Math.floor(sorted.length / 2);
+    return sorted.length % 2 === 1 ? sorted[mid] ?? NaN : ((sorted[mid - 1] ?? 0) + (sorted[mid] ?? 0)) / 2;
+}
+
+// Ranks of the combined samples, ties get the average of the ranks they span
+function rankAll(values: number[]): { ranks: number[]; tieCorrection: number } {
+    const order = values.map((value, index) => ({ value, index })).sort((a, b) => a.value - b.value);
+    const ranks = new Array<number>(values.length).fill(0);
+    let tieCorrection = 0;
+    for (let i = 0; i < order.length;) {
+        let j = i;
+        while (j + 1 < order.length && order[j + 1]?.value === order[i]?.value) j++;
+        const rank = (i + j) / 2 + 1;
+        for (let k = i; k <= j; k++) ranks[order[k]?.index ?? 0] = rank;
+        const tied = j - i + 1;
+        tieCorrection += tied ** 3 - tied;
+        i = j + 1;
+    }
+    return { ranks, tieCorrection };
+}
+
+// Number of orderings of n1 + n2 samples giving each U value, for the exact test
+function uDistribution(n1: number, n2: number): number[] {
+    // counts[m][u]: arrangements of m x-samples among the first samples with statistic u
+    let counts: number[][] = Array.from({ length: n1 + 1 }, (_, m) => (m === 0 ? [1] : [0]));
+    for (let seen = 1; seen <= n1 + n2; seen++) {
+        const next: number[][] = Array.from({ length: n1 + 1 }, () => [] as number[]);
+        for (let m = 0; m <= Math.min(n1, seen); m++) {
+            const ys = seen - m;
+            if (ys > n2) continue;
+            const row: number[] = [];
+            // last sample is an x: it beats all ys before it
+            const fromX = m > 0 ? counts[m - 1] ?? [] : [];
+            fromX.forEach((c, u) => { row[u + ys] = (row[u + ys] ?? 0) + c; });
+            // last sample is a y
+            if (ys > 0) (counts[m] ?? []).forEach((c, u) => { row[u] = (row[u] ?? 0) + c; });
+            next[m] = Array.from(row, c => c ?? 0);
+        }
+        counts = next;
+    }
+    return counts[n1] ?? [];
+}
+
+function normalCdf(z: number): number {
+    // Abramowitz-Stegun 7.1.26 approximation of erf
+    const t = 1 / (1 + 0.3275911 * Math.abs(z) / Math.SQRT2);
+    const erf = 1 - t * (0.254829592 + t * (-0.284496736 + t * (1.421413741 + t * (-1.453152027 + t * 1.061405429)))) * Math.exp(-(z * z) / 2);
+    return z >= 0 ? (1 + erf) / 2 : (1 - erf) / 2;
+}
+
+/**
+ * Two-sided Mann-Whitney U test p-value for two independent samples, the
+ * test benchstat uses. Exact for small samples without ties, otherwise the
+ * normal approximation with tie and continuity correction.
+ */
+export function mannWhitneyUTest(a: number[], b: number[]): number {
+    const n1 = a.length;
+    const n2 = b.length;
+    if (n1 === 0 || n2 === 0) return 1;
+    const { ranks, tieCorrection } = rankAll([...a, ...b]);
+    const rankSum = ranks.slice(0, n1).reduce((sum, r) => sum + r, 0);
+    const u1 = rankSum - (n1 * (n1 + 1)) / 2;
+    const u = Math.min(u1, n1 * n2 - u1);
+    if (tieCorrection === 0 && n1 <= 20 && n2 <= 20) {
+        const distribution = uDistribution(n1, n2);
+        const total = distribution.reduce((sum, c) => sum + c, 0);
+        const tail = distribution.slice(0, Math.floor(u) + 1).reduce((sum, c) => sum + c, 0);
+        return Math.min(1, (2 * tail) / total);
+    }
+    const n = n1 + n2;
+    const variance = ((n1 * n2) / 12) * (n + 1 - tieCorrection / (n * (n - 1)));
+    if (variance <= 0) return 1;
+    const z = (Math.abs(u1 - (n1 * n2) / 2) - 0.5) / Math.sqrt(variance);
+    return Math.min(1, 2 * (1 - normalCdf(Math.max(0, z))));
+}
@@ -0,0 +1,69 @@
+export interface XmlElement {
+    name: string;
+    attributes: Record<string, string>;
+    children: XmlElement[];
+    // Concatenated text and CDATA directly inside this element
+    text: string;
+}
+
+const ENTITIES: Record<string, string> = { lt: '<', gt: '>', amp: '&', quot: '"', apos: "'" };
+
+export function decodeXmlEntities(value: string): string {
+    return value.replace(/&(#x[0-9a-fA-F]+|#\d+|\w+);/g, (match, entity: string) => {
+        if (entity.startsWith('#x')) return String.fromCodePoint(parseInt(entity.slice(2), 16));
+        if (entity.startsWith('#')) return String.fromCodePoint(parseInt(entity.slice(1), 10));
+        return ENTITIES[entity] ?? match;
+    });
+}
+
+function parseAttributes(source: string): Record<string, string> {
+    const attributes: Record<string, string> = {};
+    const pattern = /([\w:.-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')/g;
+    let match: RegExpExecArray | null;
+    while ((match = pattern.exec(source)) !== null) {
+        attributes[match[1] ?? ''] = decodeXmlEntities(match[2] ?? match[3] ?? '');
+    }
+    return attributes;
+}
+
+/**
+ * Minimal non-validating XML parser for tool reports (JUnit, coverage).
+ * Ignores the prolog, comments, processing instructions and doctypes;
+ * returns the root element, or null when there is none.
+ */
+export function parseXml(xml: string): XmlElement | null {
+    const root: XmlElement = { name: '#document', attributes: {}, children: [], text: '' };
+    const stack: XmlElement[] = [root];
+    const pattern = /<!\[CDATA\[([\s\S]*?)\]\]>|<!--[\s\S]*?-->|<\?[\s\S]*?\?>|<!DOCTYPE[^>]*>|<\/([\w:.-]+)\s*>|<([\w:.-]+)((?:\s+[\w:.-]+\s*=\s*(?:"[^"]*"|'[^']*'))*)\s*(\/?)>|([^<]+)/g;
+    let match: RegExpExecArray | null;
+    while ((match = pattern.exec(xml)) !== null) {
+        const current = stack[stack.length - 1]!;
+        const [, cdata, closing, opening, attributes = '', selfClosing, text] = match;
+        if (cdata !== undefined) {
+            current.text += cdata;
+        } else if (closing) {
+            // Tolerate mismatched tags by unwinding to the nearest matching open element
+            const index = stack.map(e => e.name).lastIndexOf(closing);
+            if (index > 0) stack.length = index;
+        } else if (opening) {
+            const element: XmlElement = { name: opening, attributes: parseAttributes(attributes), children: [], text: '' };
+            current.children.push(element);
+            if (!selfClosing) stack.push(element);
+        } else if (text !== undefined) {
+            current.text += decodeXmlEntities(text);
+        }
+    }
+    return root.children[0] ?? null;
+}
+
+/**
+ * Every descendant element (depth-first) with the given tag name
+ */
+export function findElements(element: XmlElement, name: string): XmlElement[] {
+    const found: XmlElement[] = [];
+    for (const child of element.children) {
+        if (child.name === name) found.push(child);
+        found.push(...findElements(child, name));
+    }
+    return found;
+}
@@ -0,0 +1,256 @@
+import { createHash } from 'crypto';
+import { watch as watchPath, promises as fs, type FSWatcher } from 'fs';
+import { join, relative, resolve, sep } from 'path';
+import type { Verdict } from '../diagnostics/summary.js';
+import { createIgnoreFilter, walkDirectory } from '../utils/gitignore.js';
+import { logger } from '../utils/logger.js';
+
+export interface WatchRun {
+    watchId: string;
+    runNumber: number;
+    // start: the run when watching began; change: files changed since the last run
+    trigger: 'start' | 'change';
+    // Relative to the watched root, with forward slashes
+    changedFiles: string[];
+    startedAt: string;
+    durationMs: number;
+    verdict: Verdict;
+    // The full run_pipeline result
+    result: any;
+}
+
+export interface WatchOptions {
+    root: string;
+    pipeline: string;
+    // Quiet time after the last change before the pipeline runs
+    debounceMs?: number;
+    // Runs the pipeline; run_pipeline by default
+    run?: (root: string, pipeline: string) => Promise<any>;
+}
+
+export type WatchListener = (run: WatchRun) => void;
+
+const DEFAULT_DEBOUNCE_MS = 500;
+const MAX_SESSIONS = 8;
+// Files larger than this are compared by size and mtime instead of content
+const MAX_HASHED_BYTES = 4 * 1024 * 1024;
+
+async function defaultRun(root: string, pipeline: string): Promise<any> {
+    // Imported lazily: the tool registry imports this module
+    const { runPipelineTool } = await import('../tools/pipeline.js');
+    return runPipelineTool.run({ path: root, pipeline });
+}
+
+async function fingerprint(path: string): Promise<string | null> {
+    try {
+        const stat = await fs.stat(path);
+        if (!stat.isFile()) return stat.isDirectory() ? 'directory' : null;
+        if (stat.size > MAX_HASHED_BYTES) return `${stat.size}:${stat.mtimeMs}`;
+        return createHash('sha256').update(await fs.readFile(path)).digest('hex');
+    } catch {
+        return null;
+    }
+}
+
+/**
+ * One watched workspace: file changes (outside what git ignores) are
+ * collected until the tree has been quiet for debounceMs, and the pipeline
+ * then runs once for all of them. Runs never overlap; changes during a run
+ * trigger one more. Saves that leave a file's content as it was (editors
+ * touching files, formatters rewriting them identically) do not count.
+ */
+export class WatchSession {
+    public readonly id: string;
+    public readonly root: string;
+    public readonly pipeline: string;
+    public readonly debounceMs: number;
+    public latest: WatchRun | undefined;
+    private runs = 0;
+    private readonly run: (root: string, pipeline: string) => Promise<any>;
+    private readonly onRun: WatchListener;
+    private watchers: FSWatcher[] = [];
+    private ignored: ((path: string, isDirectory: boolean) => Promise<boolean>) | undefined;
+    private pending = new Set<string>();
+    private fingerprints = new Map<string, string | null>();
+    private timer: NodeJS.Timeout | undefined;
+    private running: Promise<void> | undefined;
+    private stopped = false;
+
+    constructor(id: string, options: WatchOptions, onRun: WatchListener) {
+        this.id = id;
+        this.root = resolve(options.root);
+        this.pipeline = options.pipeline;
+        this.debounceMs = options.debounceMs ?? DEFAULT_DEBOUNCE_MS;
+        this.run = options.run ?? defaultRun;
+        this.onRun = onRun;
+    }
+
+    public async start(): Promise<void> {
+        this.ignored = await createIgnoreFilter(this.root);
+        try {
+            this.watchers.push(watchPath(this.root, { recursive: true }, (_event, name) => this.changed(name)));
+        } catch (error: any) {
+            // Recursive watching needs Node 20 on Linux; watch each directory instead
+            if (error.code !== 'ERR_FEATURE_UNAVAILABLE_ON_PLATFORM') throw error;
+            await this.watchDirectory(this.root);
+            await walkDirectory(this.root, { includeHidden: true }, async entry => {
+                if (entry.type === 'directory') await this.watchDirectory(entry.path);
+            });
+        }
+        this.schedule('start', 0);
+    }
+
+    public stop(): void {
+        this.stopped = true;
+        clearTimeout(this.timer);
+        for (const watcher of this.watchers) watcher.close();
+        this.watchers = [];
+    }
+
+    public status() {
+        return { id: this.id, root: this.root, pipeline: this.pipeline, debounceMs: this.debounceMs, runs: this.runs, running: this.running !== undefined, pending: [...this.pending].sort() };
+    }
+
+    /**
+     * Resolves when no run is in progress or waiting for the debounce
+     */
+    public async idle(): Promise<void> {
+        while (this.running || this.timer) {
+            await (this.running ?? new Promise(resolve => setTimeout(resolve, 10)));
+        }
+    }
+
+    private async watchDirectory(dir: string): Promise<void> {
+        try {
+            const watcher = watchPath(dir, (_event, name) => {
+                if (!name) return;
+                const path = join(dir, name.toString());
+                this.changed(relative(this.root, path));
+                // New directories need a watcher of their own
+                fs.stat(path).then(stat => stat.isDirectory() && this.watchDirectory(path), () => undefined);
+            });
+            this.watchers.push(watcher);
+        } catch (error) {
+            logger.debug('Could not watch directory', { dir, error });
+        }
+    }
+
+    private changed(name: string | Buffer | null): void {
+        if (!name || this.stopped) return;
+        const rel = name.toString().split(sep).join('/');
+        void (async () => {
+            const path = join(this.root, rel);
+            if (/(^|\/)\.(git|hg|svn)(\/|$)/.test(rel)) return;
+            if (/(^|\/)\.(git)?ignore$/.test(rel)) this.ignored = await createIgnoreFilter(this.root);
+            const isDirectory = await fs.stat(path).then(stat => stat.isDirectory(), () => false);
+            if (isDirectory || await this.ignored?.(path, false)) return;
+            this.pending.add(rel);
+            this.schedule('change', this.debounceMs);
+        })();
+    }
+
+    private schedule(trigger: WatchRun['trigger'], delay: number): void {
+        if (this.stopped) return;
+        clearTimeout(this.timer);
+        this.timer = setTimeout(() => {
+            this.timer = undefined;
+            // A run in progress picks the pending changes up when it ends
+            if (!this.running) this.running = this.flush(trigger).finally(() => { this.running = undefined; });
+        }, delay);
+    }
+
+    private async flush(trigger: WatchRun['trigger']): Promise<void> {
+        const candidates = [...this.pending].sort();
+        this.pending.clear();
+        const changedFiles: string[] = [];
+        for (const rel of candidates) {
+            const next = await fingerprint(join(this.root, rel));
+            const previous = this.fingerprints.get(rel);
+            if (previous === undefined || previous !== next) changedFiles.push(rel);
+            this.fingerprints.set(rel, next);
+        }
+        // Every file is as it was when last seen
+        if (trigger === 'change' && changedFiles.length === 0) return this.afterRun();
+
+        const started = Date.now();
+        try {
+            const result = await this.run(this.root, this.pipeline);
+            if (this.stopped) return;
+            if (!result?.verdict) throw new Error(result?.errors?.[0] ?? 'Pipeline did not run');
+            const run: WatchRun = {
+                watchId: this.id,
+                runNumber: ++this.runs,
+                trigger,
+                changedFiles,
+                startedAt: new Date(started).toISOString(),
+                durationMs: Date.now() - started,
+                verdict: result.verdict,
+                result,
+            };
+            this.latest = run;
+            this.onRun(run);
+        } catch (error) {
+            logger.error('Watch run failed', { watchId: this.id, root: this.root, pipeline: this.pipeline, error });
+        }
+        return this.afterRun();
+    }
+
+    private afterRun(): void {
+        if (this.pending.size > 0 && !this.timer) this.schedule('change', this.debounceMs);
+    }
+}
+
+/**
+ * The watched workspaces of this process, and who hears about their runs
+ */
+export class WatchManager {
+    private sessions = new Map<string, WatchSession>();
+    private listeners = new Set<WatchListener>();
+    private nextId = 1;
+
+    public async start(options: WatchOptions): Promise<WatchSession> {
+        const root = resolve(options.root);
+        const existing = [...this.sessions.values()].find(s => s.root === root && s.pipeline === options.pipeline);
+        if (existing) return existing;
+        if (this.sessions.size >= MAX_SESSIONS) throw new Error(`At most ${MAX_SESSIONS} workspaces can be watched at once; stop one first`);
+        const session = new WatchSession(`watch-${this.nextId++}`, options, run => {
+            for (const listener of this.listeners) {
+                try {
+                    listener(run);
+                } catch (error) {
+                    logger.warn('Watch listener failed', { error });
+                }
+            }
+        });
+        await session.start();
+        this.sessions.set(session.id, session);
+        return session;
+    }
+
+    public stop(id: string): boolean {
+        const session = this.sessions.get(id);
+        if (!session) return false;
+        session.stop();
+        this.sessions.delete(id);
+        return true;
+    }
+
+    public stopAll(): void {
+        for (const id of [...this.sessions.keys()]) this.stop(id);
+    }
+
+    public get(id: string): WatchSession | undefined {
+        return this.sessions.get(id);
+    }
+
+    public list(): WatchSession[] {
+        return [...this.sessions.values()];
+    }
+
+    public subscribe(listener: WatchListener): () => void {
+        this.listeners.add(listener);
+        return () => { this.listeners.delete(listener); };
+    }
+}
+
+export const watchManager = new WatchManager();
@@ -0,0 +1,372 @@
+import { z } from 'zod';
+import { promises as fs } from 'fs';
+import { homedir, tmpdir } from 'os';
+import { dirname, join, relative, sep } from 'path';
+import { createHash, createHmac, timingSafeEqual } from 'crypto';
+import yaml from 'js-yaml';
+import { minimatch } from 'minimatch';
+import Config, { isWithin } from '../config/index.js';
+import { getEffectiveConfig, getGlobalConfigPath, getToolTimeout, isToolEnabled, loadConfigFile, mergeConfigs, pipelineStepSchema, projectConfigSchema, PROJECT_CONFIG_FILES, type ProjectConfig } from '../config/project.js';
+import { gitCredentialEnv } from '../integrations/index.js';
+import { summarizePipeline } from '../diagnostics/summary.js';
+import { BaselineMatcher, baselinePath, openBaseline, parseBaseline } from '../baseline/index.js';
+import { suppressionOptions } from '../diagnostics/suppressions.js';
+import { runStore, type RunRecord } from '../runs/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { logger } from '../utils/logger.js';
+import type { PipelineTool } from '../tools/pipeline.js';
+
+export type WebhookProvider = 'github' | 'gitlab';
+
+const webhookRepositorySchema = z.object({
+    // owner/repo, or the GitLab project path with its groups
+    repository: z.string().regex(/^[\w.-]+(\/[\w.-]+)+$/, 'Expected owner/repo'),
+    provider: z.enum(['github', 'gitlab']).default('github'),
+    // GitHub: the webhook secret the payload is signed with; GitLab: the secret token it sends
+    secret: z.string().min(16),
+    events: z.array(z.enum(['push', 'pull_request'])).default(['push', 'pull_request']),
+    // Globs on the pushed branch or the pull request's source branch, e.g. ai/**; all branches when omitted
+    branches: z.array(z.string()).optional(),
+    // Pipeline from the checked-out .code-feedback.yaml (for pull requests, the target branch's), unless steps are given here
+    pipeline: z.string().default('default'),
+    steps: z.array(pipelineStepSchema).min(1).optional(),
+    // Post the run's diagnostics as a review on the pull request (publish_review)
+    publishReview: z.boolean().default(false),
+}).strict();
+
+export const webhooksFileSchema = z.object({
+    repositories: z.array(webhookRepositorySchema).default([]),
+}).strict();
+
+export type WebhookRepository = z.infer<typeof webhookRepositorySchema>;
+export type WebhooksFile = z.infer<typeof webhooksFileSchema>;
+
+export interface WebhookEvent {
+    provider: WebhookProvider;
+    event: 'push' | 'pull_request';
+    repository: string;
+    // Branch name
+    ref: string;
+    // What to fetch from the repository: the branch, or the host's ref for the pull request head
+    fetchRef: string;
+    // Branch a pull request targets
+    baseRef?: string;
+    sha: string;
+    pullNumber?: number;
+    cloneUrl: string;
+}
+
+export type ParsedWebhook = { kind: 'event'; event: WebhookEvent } | { kind: 'ignored'; reason: string } | { kind: 'ping' };
+
+export interface WebhookResponse {
+    status: number;
+    body: Record<string, unknown>;
+}
+
+/**
+ * Location of the webhooks file: MCP_WEBHOOKS_FILE or ~/.config/code-feedback/webhooks.yaml
+ */
+export function getWebhooksFilePath(): string {
+    return process.env.MCP_WEBHOOKS_FILE || join(homedir(), '.config', 'code-feedback', 'webhooks.yaml');
+}
+
+/**
+ * Read and validate the webhooks file; a missing file means webhooks are off
+ */
+export async function loadWebhooks(path: string = getWebhooksFilePath()): Promise<WebhooksFile> {
+    let raw: string;
+    try {
+        raw = await fs.readFile(path, 'utf-8');
+    } catch (error: any) {
+        if (error.code === 'ENOENT') return { repositories: [] };
+        throw error;
+    }
+    const parsed = webhooksFileSchema.safeParse(yaml.load(raw) ?? {});
+    if (!parsed.success) {
+        throw new Error(`Invalid webhooks file ${path}: ${parsed.error.errors.map(e => `${e.path.join('.')} - ${e.message}`).join('; ')}`);
+    }
+    const names = parsed.data.repositories.map(r => `${r.provider}:${r.repository.toLowerCase()}`);
+    const duplicate = names.find((name, i) => names.indexOf(name) !== i);
+    if (duplicate) throw new Error(`Invalid webhooks file ${path}: duplicate repository ${duplicate}`);
+    return parsed.data;
+}
+
+function header(headers: Record<string, string | string[] | undefined>, name: string): string {
+    const value = headers[name];
+    return (Array.isArray(value) ? value[0] : value) ?? '';
+}
+
+/**
+ * Check a delivery came from the host: GitHub's X-Hub-Signature-256 HMAC of
+ * the raw body, or GitLab's X-Gitlab-Token
+ */
+export function verifyWebhook(provider: WebhookProvider, secret: string, headers: Record<string, string | string[] | undefined>, rawBody: Buffer): boolean {
+    // Compared as digests, so the comparison time does not depend on the input
+    const digest = (value: string | Buffer) => createHash('sha256').update(value).digest();
+    if (provider === 'gitlab') return timingSafeEqual(digest(header(headers, 'x-gitlab-token')), digest(secret));
+    const expected = `sha256=${createHmac('sha256', secret).update(rawBody).digest('hex')}`;
+    return timingSafeEqual(digest(header(headers, 'x-hub-signature-256')), digest(expected));
+}
+
+/**
+ * The repository a delivery is about, before the signature is checked against its secret
+ */
+export function webhookRepository(provider: WebhookProvider, payload: any): string | null {
+    const name = provider === 'gitlab' ? payload?.project?.path_with_namespace : payload?.repository?.full_name;
+    return typeof name === 'string' ? name : null;
+}
+
+const BRANCH_PREFIX = 'refs/heads/';
+
+/**
+ * Turn a push or pull request delivery into the commit to check out; other
+ * events and actions that add no commits are ignored
+ */
+export function parseWebhookEvent(provider: WebhookProvider, eventName: string, payload: any): ParsedWebhook {
+    const repository = webhookRepository(provider, payload) ?? '';
+    if (provider === 'github') {
+        if (eventName === 'ping') return { kind: 'ping' };
+        if (eventName === 'push') {
+            if (payload.deleted || !String(payload.ref ?? '').startsWith(BRANCH_PREFIX)) return { kind: 'ignored', reason: 'not a branch update' };
+            return {
+                kind: 'event',
+                event: { provider, event: 'push', repository, ref: payload.ref.slice(BRANCH_PREFIX.length), fetchRef: payload.ref, sha: payload.after, cloneUrl: payload.repository.clone_url },
+            };
+        }
+        if (eventName === 'pull_request') {
+            if (!['opened', 'synchronize', 'reopened', 'ready_for_review'].includes(payload.action)) return { kind: 'ignored', reason: `pull_request ${payload.action}` };
+            const pull = payload.pull_request;
+            return {
+                kind: 'event',
+                event: {
+                    provider, event: 'pull_request', repository, ref: pull.head.ref,
+                    // Fetched from the base repository, which also serves forks' heads
+                    fetchRef: `refs/pull/${pull.number}/head`,
+                    baseRef: pull.base?.ref,
+                    sha: pull.head.sha, pullNumber: pull.number, cloneUrl: payload.repository.clone_url,
+                },
+            };
+        }
+        return { kind: 'ignored', reason: `${eventName} events are not handled` };
+    }
+
+    if (eventName === 'Push Hook') {
+        if (!payload.checkout_sha || !String(payload.ref ?? '').startsWith(BRANCH_PREFIX)) return { kind: 'ignored', reason: 'not a branch update' };
+        return {
+            kind: 'event',
+            event: { provider, event: 'push', repository, ref: payload.ref.slice(BRANCH_PREFIX.length), fetchRef: payload.ref, sha: payload.checkout_sha, cloneUrl: payload.project.git_http_url },
+        };
+    }
+    if (eventName === 'Merge Request Hook') {
+        const mr = payload.object_attributes ?? {};
+        // "update" also fires for title and label edits; only oldrev marks new commits
+        const addsCommits = mr.action === 'open' || mr.action === 'reopen' || (mr.action === 'update' && mr.oldrev);
+        if (!addsCommits) return { kind: 'ignored', reason: `merge request ${mr.action ?? 'event'}` };
+        return {
+            kind: 'event',
+            event: {
+                provider, event: 'pull_request', repository, ref: mr.source_branch,
+                fetchRef: `refs/merge-requests/${mr.iid}/head`,
+                baseRef: mr.target_branch,
+                sha: mr.last_commit?.id, pullNumber: mr.iid, cloneUrl: payload.project.git_http_url,
+            },
+        };
+    }
+    return { kind: 'ignored', reason: `${eventName || 'unknown'} events are not handled` };
+}
+
+function findRepository(file: WebhooksFile, provider: WebhookProvider, repository: string): WebhookRepository | undefined {
+    return file.repositories.find(r => r.provider === provider && r.repository.toLowerCase() === repository.toLowerCase());
+}
+
+// Runs go one at a time, in delivery order
+let queue: Promise<void> = Promise.resolve();
+
+/**
+ * Resolves once every queued run has finished
+ */
+export function waitForRuns(): Promise<void> {
+    return queue;
+}
+
+async function checkout(event: WebhookEvent, workspace: string, warnings: string[]): Promise<void> {
+    const env = gitCredentialEnv(event.cloneUrl, event.provider);
+    const commands = [
+        'git init -q',
+        `git remote add origin ${shellQuote(event.cloneUrl)}`,
+        `git fetch -q --depth 1 origin ${shellQuote(event.fetchRef)}`,
+        'git checkout -q --detach FETCH_HEAD',
+    ];
+    for (const command of commands) {
+        const result = await runCommand(command, { cwd: workspace, env, timeout: 300000 });
+        if (result.exitCode !== 0) throw new Error(`Checkout failed: ${command.replace(/ origin .*/, ' origin ...')}: ${result.stderr.trim()}`);
+    }
+    const head = (await runCommand('git rev-parse HEAD', { cwd: workspace, timeout: 10000 })).stdout.trim();
+    if (event.sha && head !== event.sha) warnings.push(`${event.ref} moved on since the event; ran ${head} instead of ${event.sha}`);
+}
+
+interface BaseBranch {
+    config: ProjectConfig;
+    baseline: BaselineMatcher | null;
+}
+
+/**
+ * What a pull request's pipeline runs under: the global config with the target
+ * branch's .code-feedback.yaml, and the target branch's baseline. The head may
+ * come from a fork, and its own files could add command steps, enable tools,
+ * or baseline and suppress the findings it introduces.
+ */
+async function openBaseBranch(event: WebhookEvent, workspace: string): Promise<BaseBranch> {
+    if (!event.baseRef) throw new Error('The pull request event names no target branch');
+    const env = gitCredentialEnv(event.cloneUrl, event.provider);
+    const fetched = await runCommand(`git fetch -q --depth 1 origin ${shellQuote(BRANCH_PREFIX + event.baseRef)}`, { cwd: workspace, env, timeout: 300000 });
+    if (fetched.exitCode !== 0) throw new Error(`Fetching ${event.baseRef} failed: ${fetched.stderr.trim()}`);
+    const config = await baseBranchConfig(event, workspace);
+    if (config.baseline?.enabled === false) return { config, baseline: null };
+    const path = baselinePath({ globalConfigPath: null, projectConfigPath: null, workspaceRoot: workspace, config }, workspace);
+    // A baseline outside the checkout is not on the branch
+    if (!isWithin(workspace, path)) return { config, baseline: null };
+    const file = relative(workspace, path);
+    const shown = await runCommand(`git show ${shellQuote(`FETCH_HEAD:${file.split(sep).join('/')}`)}`, { cwd: workspace, timeout: 10000, maxBuffer: 32 * 1024 * 1024 });
+    if (shown.exitCode !== 0) return { config, baseline: null };
+    const baseline = parseBaseline(shown.stdout, `${file} on ${event.baseRef}`);
+    return { config, baseline: new BaselineMatcher(baseline, dirname(path), config.baseline?.lineTolerance) };
+}
+
+// The target branch's config merged over the global one; its commit must be FETCH_HEAD
+async function baseBranchConfig(event: WebhookEvent, workspace: string): Promise<ProjectConfig> {
+    const globalConfig = await loadConfigFile(getGlobalConfigPath()) ?? {};
+    for (const name of PROJECT_CONFIG_FILES) {
+        const shown = await runCommand(`git show ${shellQuote(`FETCH_HEAD:${name}`)}`, { cwd: workspace, timeout: 10000 });
+        if (shown.exitCode !== 0) continue;
+        let raw: unknown;
+        try {
+            raw = yaml.load(shown.stdout);
+        } catch (error: any) {
+            throw new Error(`Invalid ${name} on ${event.baseRef}: ${error.message || String(error)}`);
+        }
+        const parsed = projectConfigSchema.safeParse(raw ?? {});
+        if (!parsed.success) {
+            throw new Error(`Invalid ${name} on ${event.baseRef}: ${parsed.error.errors.map(e => `${e.path.join('.') || '(root)'} - ${e.message}`).join('; ')}`);
+        }
+        return mergeConfigs(globalConfig, parsed.data);
+    }
+    return globalConfig;
+}
+
+async function executeRun(run: RunRecord, event: WebhookEvent, config: WebhookRepository): Promise<void> {
+    const workspace = join(tmpdir(), 'code-feedback-runs', run.id);
+    const started = Date.now();
+    Object.assign(run, { status: 'running', startedAt: new Date(started).toISOString() });
+    await runStore.save(run);
+    // Tools check paths against the allowed roots; the run's checkout is one while it runs
+    Config.getInstance().addAllowedPaths([workspace]);
+    try {
+        await fs.mkdir(workspace, { recursive: true });
+        await checkout(event, workspace, run.warnings);
+
+        const effective = await getEffectiveConfig(workspace);
+        const base = event.event === 'pull_request' ? await openBaseBranch(event, workspace) : null;
+        const trusted = base?.config ?? effective.config;
+        const steps = config.steps ?? trusted.pipelines?.[config.pipeline];
+        if (!steps) {
+            const source = event.event === 'pull_request' ? `the .code-feedback.yaml on ${event.baseRef}` : 'the checked-out .code-feedback.yaml';
+            throw new Error(`Pipeline "${config.pipeline}" not found in ${source}`);
+        }
+        // Imported lazily: the tool registry imports modules that import this one
+        const { allTools } = await import('../tools/index.js');
+        const { runPipeline } = await import('../tools/pipeline.js');
+        // A checked-in baseline keeps a pull request's check to the findings it introduces
+        const baseline = base ? base.baseline : (await openBaseline(effective, workspace))?.matcher ?? null;
+        const results = await runPipeline(
+            steps,
+            workspace,
+            allTools as PipelineTool[],
+            name => isToolEnabled(trusted, name),
+            name => getToolTimeout(trusted, name),
+            { suppressions: suppressionOptions(trusted), baseline },
+        );
+        const verdict = summarizePipeline(results, { root: workspace });
+        Object.assign(run, {
+            status: verdict.passed ? 'passed' : 'failed',
+            summary: verdict.steps,
+            verdict,
+            steps: results,
+            diagnostics: results.flatMap(r => r.diagnostics ?? []),
+        });
+
+        if (config.publishReview && event.pullNumber !== undefined) {
+            const { publishReviewTool } = await import('../tools/review.js');
+            const review: any = await publishReviewTool.run({
+                repoPath: workspace,
+                pullNumber: event.pullNumber,
+                provider: event.provider,
+                repository: event.repository,
+                diagnostics: run.diagnostics ?? [],
+            });
+            run.review = { published: Boolean(review.published), ...(review.reviewUrl ? { url: review.reviewUrl } : {}), errors: review.errors ?? [] };
+        }
+    } catch (error: any) {
+        Object.assign(run, { status: 'error', error: error.message || String(error) });
+    } finally {
+        Config.getInstance().removeAllowedPaths([workspace]);
+        await fs.rm(workspace, { recursive: true, force: true }).catch(() => { /* already gone */ });
+        Object.assign(run, { finishedAt: new Date().toISOString(), durationMs: Date.now() - started });
+        await runStore.save(run);
+        logger.info('Webhook run finished', { runId: run.id, repository: run.repository, ref: run.ref, status: run.status, durationMs: run.durationMs });
+    }
+}
+
+/**
+ * Handle a delivery to /webhooks/<provider>: verify it, and queue a pipeline
+ * run for pushes and pull request updates of configured repositories
+ */
+export async function handleWebhook(file: WebhooksFile, provider: WebhookProvider, headers: Record<string, string | string[] | undefined>, rawBody: Buffer): Promise<WebhookResponse> {
+    let payload: any;
+    try {
+        payload = JSON.parse(rawBody.toString('utf8'));
+    } catch {
+        return { status: 400, body: { error: 'Expected a JSON payload' } };
+    }
+    const repository = webhookRepository(provider, payload);
+    const config = repository ? findRepository(file, provider, repository) : undefined;
+    // Unknown repositories get the same answer as bad signatures
+    if (!config || !verifyWebhook(provider, config.secret, headers, rawBody)) {
+        return { status: 401, body: { error: 'Invalid signature' } };
+    }
+
+    let parsed: ParsedWebhook;
+    try {
+        parsed = parseWebhookEvent(provider, header(headers, provider === 'gitlab' ? 'x-gitlab-event' : 'x-github-event'), payload);
+    } catch {
+        return { status: 400, body: { error: 'Malformed payload' } };
+    }
+    if (parsed.kind === 'ping') return { status: 200, body: { ok: true } };
+    if (parsed.kind === 'ignored') return { status: 202, body: { ignored: parsed.reason } };
+    const { event } = parsed;
+    if (!config.events.includes(event.event)) return { status: 202, body: { ignored: `${event.event} events are not enabled for ${config.repository}` } };
+    if (config.branches && !config.branches.some(glob => minimatch(event.ref, glob))) {
+        return { status: 202, body: { ignored: `branch ${event.ref} is not listed` } };
+    }
+
+    const run: RunRecord = {
+        id: runStore.newId(),
+        status: 'queued',
+        provider,
+        event: event.event,
+        repository: config.repository,
+        ref: event.ref,
+        sha: event.sha,
+        ...(event.pullNumber !== undefined ? { pullNumber: event.pullNumber } : {}),
+        pipeline: config.steps ? 'inline' : config.pipeline,
+        createdAt: new Date().toISOString(),
+        warnings: [],
+    };
+    await runStore.save(run);
+    logger.info('Webhook run queued', { runId: run.id, repository: run.repository, event: run.event, ref: run.ref, sha: run.sha });
+    queue = queue.then(() => executeRun(run, event, config)).catch(error => {
+        logger.error('Webhook run failed', { runId: run.id, error });
+    });
+    return { status: 202, body: { runId: run.id, status: 'queued' } };
+}
@@ -0,0 +1,159 @@
+import { randomBytes } from 'crypto';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { isAbsolute, join, resolve } from 'path';
+import { fileURLToPath, pathToFileURL } from 'url';
+import Config, { isWithin } from '../config/index.js';
+import { detectProvider, gitCredentialEnv, parseRemoteUrl, type ReviewProvider } from '../integrations/index.js';
+import { runCommand } from '../utils/command.js';
+import { shellQuote } from '../utils/shell.js';
+import { logger } from '../utils/logger.js';
+import { workspaceRegistry, type Workspace } from './index.js';
+
+export interface CloneOptions {
+    url: string;
+    branch?: string;
+    // Commits of history to fetch; full history when omitted
+    depth?: number;
+    id?: string;
+    name?: string;
+    ttlMinutes: number;
+    // Whose token authenticates HTTPS clones; guessed from the host when omitted
+    provider?: ReviewProvider;
+    timeout?: number;
+}
+
+export interface ClonedWorkspace {
+    workspace: Workspace;
+    head: string;
+    branch: string;
+}
+
+const COLLECT_INTERVAL_MS = 60 * 1000;
+let collector: NodeJS.Timeout | undefined;
+let allowed = false;
+// Checkouts still being cloned, which the collector must leave alone
+const pending = new Set<string>();
+
+/**
+ * Where clones are checked out: MCP_CLONES_DIR or <tmpdir>/code-feedback-clones
+ */
+export function getClonesDir(): string {
+    return resolve(process.env.MCP_CLONES_DIR || join(tmpdir(), 'code-feedback-clones'));
+}
+
+// Tools check paths against the allowed roots; clones live under one of them
+function allowClonesDir(): void {
+    if (allowed) return;
+    Config.getInstance().addAllowedPaths([getClonesDir()]);
+    allowed = true;
+}
+
+/**
+ * Remote URL validated for cloning: HTTPS, SSH and git:// remotes, or a local
+ * repository inside the allowed paths. Other transports (ext::, fd::) can run
+ * commands and are refused.
+ */
+export function parseCloneUrl(url: string): { url: string; host?: string; local: boolean } {
+    const trimmed = url.trim();
+    if (trimmed.startsWith('-')) throw new Error(`Invalid remote URL ${url}`);
+    if (/^(https?|ssh|git):\/\//i.test(trimmed)) return { url: trimmed, host: new URL(trimmed).hostname, local: false };
+    const scp = /^[\w.-]+@([\w.-]+):(?!\/\/)/.exec(trimmed);
+    if (scp) return { url: trimmed, host: scp[1]!, local: false };
+    const path = /^file:\/\//i.test(trimmed) ? fileURLToPath(trimmed) : trimmed;
+    if (!isAbsolute(path) || trimmed.includes('::')) throw new Error(`Unsupported remote URL ${url}: use https://, ssh://, git:// or user@host:path`);
+    if (!Config.getInstance().isPathAllowed(path)) throw new Error('Path not allowed');
+    // As a file:// URL so that depth applies to local clones too
+    return { url: pathToFileURL(path).href, local: true };
+}
+
+// Credentials in the URL stay out of the registry and the results
+function redactUrl(url: string): string {
+    return url.replace(/([a-z]+:\/\/)[^@/\s]+@/gi, '$1');
+}
+
+function cloneId(url: string): string | undefined {
+    const name = (parseRemoteUrl(url)?.path ?? url).split('/').filter(Boolean).pop()?.replace(/\.git$/, '');
+    return name?.replace(/[^A-Za-z0-9._-]+/g, '-').replace(/^[^A-Za-z0-9]+/, '').slice(0, 56) || undefined;
+}
+
+/**
+ * Clone a remote repository into a fresh managed directory and register it as
+ * a workspace that expires after ttlMinutes
+ */
+export async function cloneWorkspace(options: CloneOptions): Promise<ClonedWorkspace> {
+    await collectExpiredClones();
+    allowClonesDir();
+    const remote = parseCloneUrl(options.url);
+    const provider = options.provider ?? (remote.host ? detectProvider(remote.host) : 'github');
+
+    const dir = join(getClonesDir(), `${new Date().toISOString().slice(0, 10)}-${randomBytes(4).toString('hex')}`);
+    await fs.mkdir(getClonesDir(), { recursive: true });
+    pending.add(dir);
+    try {
+        const args = [
+            'git', 'clone', '-q',
+            ...(options.depth ? ['--depth', String(options.depth)] : []),
+            ...(options.branch ? ['--branch', options.branch, '--single-branch'] : []),
+            '--', remote.url, dir,
+        ];
+        const result = await runCommand(args.map(shellQuote).join(' '), {
+            cwd: getClonesDir(),
+            env: gitCredentialEnv(remote.url, provider),
+            timeout: options.timeout ?? 600000,
+        });
+        if (result.exitCode !== 0) {
+            throw new Error(`git clone failed: ${redactUrl(result.stderr.trim() || `exit code ${result.exitCode}`)}`);
+        }
+        const head = (await runCommand('git rev-parse HEAD', { cwd: dir, timeout: 10000 })).stdout.trim();
+        const branch = (await runCommand('git rev-parse --abbrev-ref HEAD', { cwd: dir, timeout: 10000 })).stdout.trim();
+        const id = options.id ?? cloneId(remote.url);
+        const workspace = await workspaceRegistry.register(dir, {
+            ...(id ? { id } : {}),
+            ...(options.name ? { name: options.name } : {}),
+            remote: redactUrl(remote.url),
+            expiresAt: new Date(Date.now() + options.ttlMinutes * 60 * 1000).toISOString(),
+        });
+        return { workspace, head, branch };
+    } catch (error) {
+        await fs.rm(dir, { recursive: true, force: true });
+        throw error;
+    } finally {
+        pending.delete(dir);
+    }
+}
+
+/**
+ * Remove clones whose TTL has passed: unregister the workspace and delete the
+ * checkout, along with leftover checkouts no workspace points at
+ */
+export async function collectExpiredClones(now: number = Date.now()): Promise<string[]> {
+    const clonesDir = getClonesDir();
+    const removed: string[] = [];
+    const workspaces = await workspaceRegistry.list();
+    for (const workspace of workspaces) {
+        if (!workspace.expiresAt || Date.parse(workspace.expiresAt) > now) continue;
+        await workspaceRegistry.unregister(workspace.id);
+        if (isWithin(clonesDir, workspace.root) && workspace.root !== clonesDir) await fs.rm(workspace.root, { recursive: true, force: true });
+        removed.push(workspace.id);
+    }
+    const roots = new Set(workspaces.filter(w => !removed.includes(w.id)).map(w => w.root));
+    for (const name of await fs.readdir(clonesDir).catch(() => [] as string[])) {
+        const dir = join(clonesDir, name);
+        if (!roots.has(dir) && !pending.has(dir)) await fs.rm(dir, { recursive: true, force: true });
+    }
+    if (removed.length > 0) logger.info('Removed expired clones', { workspaces: removed });
+    return removed;
+}
+
+/**
+ * Collect expired clones now and every minute for the life of the process
+ */
+export function startCloneCollector(): void {
+    if (collector) return;
+    allowClonesDir();
+    const collect = () => collectExpiredClones().catch(error => logger.error('Could not remove expired clones', { error }));
+    void collect();
+    collector = setInterval(collect, COLLECT_INTERVAL_MS);
+    collector.unref();
+}
@@ -0,0 +1,158 @@
+import { promises as fs } from 'fs';
+import { homedir } from 'os';
+import { basename, dirname, isAbsolute, join, resolve } from 'path';
+import Config, { isWithin } from '../config/index.js';
+import { PATH_ARG_KEYS } from '../utils/paths.js';
+import { logger } from '../utils/logger.js';
+
+export interface Workspace {
+    id: string;
+    root: string;
+    name?: string;
+    registeredAt: string;
+    // Cloned by clone_workspace: the remote (without credentials) and when the clone is removed
+    remote?: string;
+    expiresAt?: string;
+}
+
+const ID_PATTERN = /^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$/;
+
+/**
+ * Location of the persisted registry: MCP_WORKSPACES_FILE or ~/.config/code-feedback/workspaces.json
+ */
+export function getWorkspacesFilePath(): string {
+    return process.env.MCP_WORKSPACES_FILE || join(homedir(), '.config', 'code-feedback', 'workspaces.json');
+}
+
+// MCP_WORKSPACES="api=/srv/api,web=/srv/web", registered at startup and never persisted
+function getWorkspacesFromEnv(): Workspace[] {
+    const workspaces: Workspace[] = [];
+    for (const entry of (process.env.MCP_WORKSPACES || '').split(',')) {
+        const [id, root] = entry.split('=').map(part => part.trim());
+        if (id && root) workspaces.push({ id, root: resolve(root), registeredAt: new Date(0).toISOString() });
+    }
+    return workspaces;
+}
+
+/**
+ * Project roots a server serves, by id, so one instance can work on several
+ * repositories and tool calls can name a workspace instead of an absolute path.
+ * Registrations survive restarts in the workspaces file.
+ */
+export class WorkspaceRegistry {
+    private workspaces = new Map<string, Workspace>();
+    private fromEnv = new Set<string>();
+    private loaded: Promise<void> | undefined;
+
+    private load(): Promise<void> {
+        this.loaded ??= (async () => {
+            for (const workspace of getWorkspacesFromEnv()) {
+                this.workspaces.set(workspace.id, workspace);
+                this.fromEnv.add(workspace.id);
+            }
+            try {
+                const saved = JSON.parse(await fs.readFile(getWorkspacesFilePath(), 'utf-8'));
+                for (const workspace of Array.isArray(saved?.workspaces) ? saved.workspaces : []) {
+                    if (typeof workspace?.id === 'string' && typeof workspace.root === 'string' && !this.workspaces.has(workspace.id)) {
+                        this.workspaces.set(workspace.id, workspace);
+                    }
+                }
+            } catch (error: any) {
+                if (error.code !== 'ENOENT') logger.error('Could not read workspaces file', { path: getWorkspacesFilePath(), error });
+            }
+        })();
+        return this.loaded;
+    }
+
+    private async save(): Promise<void> {
+        const path = getWorkspacesFilePath();
+        const workspaces = [...this.workspaces.values()].filter(w => !this.fromEnv.has(w.id));
+        await fs.mkdir(dirname(path), { recursive: true });
+        // Written under a temporary name and renamed, so a crash never leaves a truncated file
+        const partial = `${path}.${process.pid}.tmp`;
+        await fs.writeFile(partial, JSON.stringify({ workspaces }, null, 2) + '\n');
+        await fs.rename(partial, path);
+    }
+
+    /**
+     * Register a root, which must be inside the allowed paths. The id defaults
+     * to the directory name, with a numeric suffix when it is taken.
+     */
+    public async register(root: string, options: { id?: string; name?: string; remote?: string; expiresAt?: string } = {}): Promise<Workspace> {
+        await this.load();
+        const absRoot = resolve(root);
+        if (!Config.getInstance().isPathAllowed(absRoot)) throw new Error('Path not allowed');
+        if (!(await fs.stat(absRoot)).isDirectory()) throw new Error(`${root} is not a directory`);
+
+        const existing = [...this.workspaces.values()].find(w => w.root === absRoot);
+        if (existing && (!options.id || options.id === existing.id)) return existing;
+        let id = options.id;
+        if (id) {
+            if (!ID_PATTERN.test(id)) throw new Error(`Invalid workspace id ${id}: use letters, digits, ".", "_" and "-"`);
+            const taken = this.workspaces.get(id);
+            if (taken && taken.root !== absRoot) throw new Error(`Workspace ${id} is already registered for ${taken.root}`);
+        } else {
+            const base = basename(absRoot).replace(/[^A-Za-z0-9._-]+/g, '-').replace(/^[^A-Za-z0-9]+/, '').slice(0, 56) || 'workspace';
+            id = base;
+            for (let n = 2; this.workspaces.has(id); n++) id = `${base}-${n}`;
+        }
+        const workspace: Workspace = {
+            id,
+            root: absRoot,
+            ...(options.name ? { name: options.name } : {}),
+            registeredAt: new Date().toISOString(),
+            ...(options.remote ? { remote: options.remote } : {}),
+            ...(options.expiresAt ? { expiresAt: options.expiresAt } : {}),
+        };
+        this.workspaces.set(id, workspace);
+        await this.save();
+        return workspace;
+    }
+
+    public async unregister(id: string): Promise<boolean> {
+        await this.load();
+        if (this.fromEnv.has(id)) throw new Error(`Workspace ${id} comes from MCP_WORKSPACES and cannot be removed at runtime`);
+        if (!this.workspaces.delete(id)) return false;
+        await this.save();
+        return true;
+    }
+
+    public async list(): Promise<Workspace[]> {
+        await this.load();
+        return [...this.workspaces.values()].sort((a, b) => a.id.localeCompare(b.id));
+    }
+
+    public async get(id: string): Promise<Workspace | undefined> {
+        await this.load();
+        return this.workspaces.get(id);
+    }
+
+    /**
+     * Rewrite a tool call that names a workspace: path arguments become
+     * absolute paths inside its root (the root itself when the tool's path
+     * argument is left out), and the workspace argument is dropped.
+     */
+    public async resolveArgs(args: Record<string, unknown>, schemaProperties: Record<string, unknown> = {}): Promise<Record<string, unknown>> {
+        if (args.workspace === undefined) return args;
+        if (typeof args.workspace !== 'string') throw new Error('workspace must be a workspace id');
+        const workspace = await this.get(args.workspace);
+        if (!workspace) throw new Error(`Unknown workspace ${args.workspace}; register it with register_workspace`);
+
+        const resolved = { ...args };
+        delete resolved.workspace;
+        let hasPath = false;
+        for (const key of PATH_ARG_KEYS) {
+            const value = resolved[key];
+            if (typeof value !== 'string') continue;
+            hasPath = true;
+            const absPath = isAbsolute(value) ? value : resolve(workspace.root, value);
+            if (!isWithin(workspace.root, absPath)) throw new Error(`${value} is outside workspace ${workspace.id}`);
+            resolved[key] = absPath;
+        }
+        const pathKey = PATH_ARG_KEYS.find(key => key in schemaProperties);
+        if (!hasPath && pathKey) resolved[pathKey] = workspace.root;
+        return resolved;
+    }
+}
+
+export const workspaceRegistry = new WorkspaceRegistry();
@@ -0,0 +1,113 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { execSync } from 'child_process';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { diffApi, nextVersion, type ApiEntry } from '../src/apidiff/index.js';
+import { apiDiffTool } from '../src/tools/apidiff.js';
+
+const STORE_V1 = `package store
+
+type Store struct {
+	Name string
+}
+
+func (s Store) Get(key string) string { return "" }
+
+type Backend interface {
+	Load(key string) ([]byte, error)
+}
+
+func Open(path string) (*Store, error) { return nil, nil }
+
+func Close() {}
+`;
+
+const STORE_V2 = `package store
+
+type Store struct {
+	Name  string
+	Limit int
+}
+
+func (s *Store) Get(key string) string { return "" }
+
+type Backend interface {
+	Load(key string) ([]byte, error)
+	Save(key string, value []byte) error
+}
+
+func Open(path string, readOnly bool) (*Store, error) { return nil, nil }
+
+func Stat(path string) error { return nil }
+`;
+
+const entry = (kind: ApiEntry['kind'], name: string, detail: string, extra: Partial<ApiEntry> = {}): ApiEntry =>
+    ({ package: '.', packageName: 'lib', kind, name, detail, file: '/m/lib.go', line: 1, column: 1, ...extra });
+
+describe('api_diff', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-apidiff-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.mkdir(join(root, 'store'));
+        await fs.mkdir(join(root, 'internal'));
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/kv\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'store', 'store.go'), STORE_V1);
+        await fs.writeFile(join(root, 'internal', 'util.go'), 'package internal\n\nfunc Helper() {}\n');
+        const git = (command: string) => execSync(`git -c user.name=t -c user.email=t@example.com ${command}`, { cwd: root, stdio: 'pipe' });
+        git('init -q');
+        git('add -A');
+        git('commit -qm v1');
+        git('tag v1.3.0');
+        await fs.writeFile(join(root, 'store', 'store.go'), STORE_V2);
+        await fs.writeFile(join(root, 'internal', 'util.go'), 'package internal\n\nfunc Other() {}\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should classify API changes', () => {
+        const changes = diffApi(
+            [entry('func', 'Run', '(string) error'), entry('type', 'Opts', 'struct'), entry('field', 'Opts.Debug', 'bool'), entry('const', 'Mode', '')],
+            [entry('func', 'Run', '(string, int) error'), entry('type', 'Opts', 'struct'), entry('field', 'Opts.Debug', 'bool'), entry('field', 'Opts.Trace', 'bool'), entry('type', 'Client', 'struct'), entry('field', 'Client.URL', 'string'), entry('func', 'Extra', '()', { package: 'extra', packageName: 'extra' })],
+            'example.com/lib',
+        );
+        expect(changes.map(c => `${c.breaking ? '!' : '+'} ${c.message}`)).toEqual([
+            '! const lib.Mode was removed',
+            '! func lib.Run changed from (string) error to (string, int) error',
+            '+ type lib.Client was added',
+            '+ field lib.Opts.Trace was added',
+            '+ package example.com/lib/extra was added',
+        ]);
+        expect(nextVersion('v1.4.2', true, true)).toBe('v2.0.0');
+        expect(nextVersion('v0.4.2', true, false)).toBe('v0.5.0');
+        expect(nextVersion('v1.4.2', false, true)).toBe('v1.5.0');
+        expect(nextVersion('v1.4.2', false, false)).toBe('v1.4.3');
+        expect(nextVersion('main', true, false)).toBeNull();
+    });
+
+    it('should report breaking changes since the latest tag', async () => {
+        const result: any = await apiDiffTool.run({ path: root });
+        expect(result.success).toBe(false);
+        expect(result.base).toBe('v1.3.0');
+        expect(result.breaking.map((c: any) => c.message)).toEqual([
+            'interface store.Backend gained method Save, which its implementations outside the package lack',
+            'func store.Close was removed',
+            'func store.Open changed from (string) (*Store, error) to (string, bool) (*Store, error)',
+            'method store.Store.Get moved to a pointer receiver, so store.Store values no longer have it',
+        ]);
+        expect(result.compatible.map((c: any) => c.message)).toEqual(['func store.Stat was added', 'field store.Store.Limit was added']);
+        expect(result.suggestedVersion).toBe('v2.0.0');
+        expect(result.output).toContain('A v2 release needs the module path example.com/kv/v2');
+        expect(result.diagnostics[1]).toMatchObject({ file: join(root, 'store', 'store.go'), line: 15, severity: 'error', rule: 'api-removed', source: 'api_diff' });
+        expect(result.errors).toEqual(['4 breaking change(s) to the exported API since v1.3.0']);
+
+        const same: any = await apiDiffTool.run({ path: join(root, 'store'), base: 'HEAD' });
+        expect(same.breaking).toHaveLength(4);
+        expect((await apiDiffTool.run({ path: root, base: 'HEAD', version: 'v1.0.0' })).errors).toEqual(['Pass base or version, not both']);
+    });
+});
@@ -0,0 +1,76 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { mergeConfigs } from '../src/config/project.js';
+import { extractImports, checkArchitectureTool } from '../src/tools/architecture.js';
+
+describe('Import extraction', () => {
+    it('should find Go imports in blocks and single declarations', () => {
+        const source = 'package api\n\nimport "fmt"\n\nimport (\n\t"context"\n\tstore "example.com/app/internal/store"\n\t_ "embed"\n)\n';
+        expect(extractImports(source, 'go')).toEqual([
+            { specifier: 'fmt', line: 3 },
+            { specifier: 'context', line: 6 },
+            { specifier: 'example.com/app/internal/store', line: 7 },
+            { specifier: 'embed', line: 8 },
+        ]);
+    });
+
+    it('should find Python and JavaScript imports', () => {
+        expect(extractImports('import os, app.db as db\nfrom ..models import User\nfrom . import utils\n', 'python').map(i => `${i.line}:${i.specifier}`))
+            .toEqual(['1:os', '1:app.db', '2:..models', '3:.']);
+        const js = "import React from 'react';\nimport type { A } from './a';\nimport {\n  b,\n} from '../b/index.js';\nimport './side-effect';\nconst c = require('@scope/c/sub');\nexport * from './d';\nconst e = await import('./e');\n";
+        expect(extractImports(js, 'javascript').map(i => `${i.line}:${i.specifier}`))
+            .toEqual(['1:react', '2:./a', '5:../b/index.js', '6:./side-effect', '7:@scope/c/sub', '8:./d', '9:./e']);
+    });
+});
+
+describe('check_architecture', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-arch-'));
+        Config.getInstance().addAllowedPaths([root]);
+        const write = async (file: string, content: string) => {
+            await fs.mkdir(join(root, file, '..'), { recursive: true });
+            await fs.writeFile(join(root, file), content);
+        };
+        await write('go/go.mod', 'module example.com/app\n\ngo 1.22\n');
+        await write('go/internal/store/store.go', 'package store\n\nimport (\n\t"database/sql"\n\t"example.com/app/internal/api"\n)\n');
+        await write('go/internal/api/api.go', 'package api\n\nimport "example.com/app/internal/store"\n');
+        await write('go/.code-feedback.yaml', 'architecture:\n  - { from: "internal/store/...", to: "internal/api/...", reason: storage must not depend on transport }\n');
+        await write('py/app/domain/user.py', 'from ..web import routes\nimport flask\n');
+        await write('py/app/web/routes.py', 'from app.domain import user\n');
+        await write('py/app/domain/__init__.py', '');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should report Go imports that break configured rules', async () => {
+        const result: any = await checkArchitectureTool.run({ path: join(root, 'go') });
+        expect(result.success).toBe(false);
+        expect(result.errors).toEqual(['internal/store/store.go:5: example.com/app/internal/store imports example.com/app/internal/api (internal/store/... must not import internal/api/...: storage must not depend on transport)']);
+        expect(result.violations[0]).toMatchObject({ specifier: 'example.com/app/internal/api', line: 5, local: true });
+    });
+
+    it('should resolve Python imports to project directories and dependencies', async () => {
+        const result: any = await checkArchitectureTool.run({
+            path: join(root, 'py'),
+            rules: [{ from: 'app/domain/...', to: 'app/web/...' }, { from: 'app/domain/...', to: 'flask' }],
+        });
+        expect(result.violations.map((v: any) => `${v.from} -> ${v.to}:${v.line}`)).toEqual(['app/domain -> app/web:1', 'app/domain -> flask:2']);
+    });
+
+    it('should require rules', async () => {
+        const result = await checkArchitectureTool.run({ path: join(root, 'py') });
+        expect(result.errors[0]).toContain('No architecture rules');
+    });
+
+    it('should accumulate rules from global and project config', () => {
+        const merged = mergeConfigs({ architecture: [{ from: 'a/...', to: 'b' }] }, { architecture: [{ from: 'c', to: 'd' }] });
+        expect(merged.architecture).toHaveLength(2);
+    });
+});
@@ -0,0 +1,38 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { type Diagnostic } from '../src/diagnostics/index.js';
+import { captureFixTargets, describeFixes, summarizeFixes } from '../src/utils/autofix.js';
+
+describe('Lint auto-fix summaries', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-autofix-'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should report the files a fixer rewrote and the issues it left', async () => {
+        const a = join(root, 'a.py');
+        const b = join(root, 'b.py');
+        await fs.writeFile(a, 'import os\nimport sys\nprint(sys.argv)\n');
+        await fs.writeFile(b, 'x = 1\n');
+        const diagnostic = (file: string, line: number, rule: string): Diagnostic => ({ file, line, column: 1, severity: 'warning', message: rule, rule, source: 'ruff' });
+        const found = [diagnostic(a, 1, 'F401'), diagnostic(a, 3, 'T201'), diagnostic(b, 1, 'E501')];
+
+        const before = await captureFixTargets(found);
+        expect([...before.keys()]).toEqual([a, b]);
+        // What `ruff check --fix` would do: drop the unused import, leave the rest
+        await fs.writeFile(a, 'import sys\nprint(sys.argv)\n');
+        const summary = await summarizeFixes(before, found, [diagnostic(a, 2, 'T201'), diagnostic(b, 1, 'E501')]);
+
+        expect(summary.fixed).toBe(1);
+        expect(summary.changes.map(c => [c.path, c.added, c.removed])).toEqual([[a, 0, 1]]);
+        expect(summary.changes[0]?.diff).toContain('-import os');
+        expect(describeFixes(summary)).toBe(`Fixed 1 issue(s) in 1 file(s); 2 remaining\n${a} (+0 -1)`);
+    });
+});
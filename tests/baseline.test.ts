@@ -0,0 +1,106 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import type { Diagnostic } from '../src/diagnostics/index.js';
+import { BaselineMatcher, createBaseline, loadBaseline } from '../src/baseline/index.js';
+import { createBaselineTool } from '../src/tools/baseline.js';
+import { runPipelineTool } from '../src/tools/pipeline.js';
+
+const CONFIG = `rules:
+  - { id: no-todo, pattern: "TODO", files: ["**/*.ts"], message: Resolve the TODO, severity: error }
+pipelines:
+  lint:
+    - tool: check_rules
+      args: { path: . }
+`;
+
+const lint = (file: string, line: number, message = 'Error return value is not checked'): Diagnostic =>
+    ({ file, line, column: 1, severity: 'error', message, rule: 'errcheck', source: 'golangci-lint' });
+
+describe('Baselines', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-baseline-'));
+        process.env.MCP_RESULTS_STORE = 'off';
+        Config.getInstance().addAllowedPaths([root]);
+    });
+
+    afterAll(async () => {
+        delete process.env.MCP_RESULTS_STORE;
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should match baselined findings across small line shifts and moved but unchanged lines', async () => {
+        const dir = join(root, 'unit');
+        await fs.mkdir(dir);
+        const lines = Array.from({ length: 60 }, (_, i) => `line ${i + 1}`);
+        await fs.writeFile(join(dir, 'a.go'), lines.join('\n'));
+        const baseline = await createBaseline(dir, [lint(join(dir, 'a.go'), 5), lint(join(dir, 'a.go'), 10, 'line is 130 characters'), lint(join(dir, 'b.go'), 3)]);
+        expect(baseline.entries.map(e => `${e.file}:${e.line}`)).toEqual(['a.go:5', 'a.go:10', 'b.go:3']);
+        expect(baseline.entries[0]!.lineHash).toMatch(/^[0-9a-f]{16}$/);
+
+        // Line 10 moved 30 lines down unchanged; line 5's finding shifted by 3
+        await fs.writeFile(join(dir, 'a.go'), [...lines.slice(0, 9), ...Array(30).fill('new'), ...lines.slice(9)].join('\n'));
+        const matcher = new BaselineMatcher(baseline, dir);
+        const { kept, suppressed } = await matcher.filter([
+            lint(join(dir, 'a.go'), 8),
+            lint(join(dir, 'a.go'), 40, 'line is 131 characters'),
+            lint(join(dir, 'b.go'), 40),
+            lint(join(dir, 'a.go'), 9),
+        ]);
+        expect(suppressed.map(d => d.line)).toEqual([8, 40]);
+        // b.go:40 is too far from b.go:3, and the second a.go:9 finding has no entry left
+        expect(kept.map(d => `${d.file.slice(dir.length + 1)}:${d.line}`)).toEqual(['b.go:40', 'a.go:9']);
+    });
+
+    it('should turn a failure explained only by baselined errors into a pass', async () => {
+        const matcher = new BaselineMatcher(await createBaseline(root, [lint('x.go', 1)]), root);
+        const outcome = { success: false, errors: ['golangci-lint found issues'], warnings: [], diagnostics: [lint('x.go', 2)] };
+        const applied = await matcher.apply(outcome);
+        expect(applied.success).toBe(true);
+        expect(applied.errors).toEqual([]);
+        expect(applied.diagnostics).toEqual([]);
+        expect(applied.baselined).toBe(1);
+
+        const failedTests = await matcher.apply({ ...outcome, tests: [{ suite: 'app', name: 'TestX', status: 'failed' as const, durationMs: 1 }] });
+        expect(failedTests.success).toBe(false);
+        expect(await matcher.apply({ ...outcome, diagnostics: [lint('y.go', 1)] })).toEqual({ ...outcome, diagnostics: [lint('y.go', 1)] });
+    });
+
+    it('should baseline a pipeline and report only new findings afterwards', async () => {
+        const repo = join(root, 'repo');
+        await fs.mkdir(repo);
+        await fs.writeFile(join(repo, '.code-feedback.yaml'), CONFIG);
+        await fs.writeFile(join(repo, 'a.ts'), 'const a = 1; // TODO remove\nexport const b = 2;\n// TODO rename\n');
+
+        const before: any = await runPipelineTool.run({ path: repo, pipeline: 'lint' });
+        expect(before.success).toBe(false);
+        expect(before.diagnostics).toHaveLength(2);
+        expect(before.baseline).toBeUndefined();
+
+        const created: any = await createBaselineTool.run({ path: repo, pipeline: 'lint' });
+        expect(created.success).toBe(true);
+        expect(created.entries).toBe(2);
+        expect(created.file).toBe(join(repo, '.code-feedback-baseline.json'));
+        expect((await loadBaseline(created.file))?.entries.map(e => e.file)).toEqual(['a.ts', 'a.ts']);
+
+        const clean: any = await runPipelineTool.run({ path: repo, pipeline: 'lint' });
+        expect(clean.success).toBe(true);
+        expect(clean.diagnostics).toEqual([]);
+        expect(clean.baseline).toEqual({ file: created.file, suppressed: 2 });
+        expect(clean.output).toContain('2 finding(s) in the baseline left out');
+
+        // Shift the old findings down and add a new one
+        await fs.writeFile(join(repo, 'a.ts'), 'import x from "x";\n\nconst a = 1; // TODO remove\nexport const b = 2;\n// TODO rename\n// TODO new\n');
+        const after: any = await runPipelineTool.run({ path: repo, pipeline: 'lint' });
+        expect(after.success).toBe(false);
+        expect(after.diagnostics.map((d: Diagnostic) => d.line)).toEqual([6]);
+        expect(after.baseline.suppressed).toBe(2);
+
+        const everything: any = await runPipelineTool.run({ path: repo, pipeline: 'lint', baseline: false });
+        expect(everything.diagnostics).toHaveLength(3);
+    });
+});
@@ -0,0 +1,61 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { runBatch } from '../src/batch.js';
+import { parseCliArgs, type RunOptions } from '../src/cli.js';
+
+describe('CLI batch mode', () => {
+    let root: string;
+    const run = (options: Partial<RunOptions>) => runBatch({ command: 'run', path: root, pipeline: 'default', format: 'text', ...options });
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-batch-'));
+        await fs.writeFile(join(root, '.code-feedback.yaml'), [
+            'pipelines:',
+            '  default:',
+            '    - { name: build, command: "echo built" }',
+            '    - { name: test, command: "echo boom >&2; exit 4" }',
+            '  quick:',
+            '    - { name: build, command: "true" }',
+        ].join('\n'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should parse the run command', () => {
+        expect(parseCliArgs(['run'], {})).toEqual({ command: 'run', path: '.', pipeline: 'default', format: 'text' });
+        expect(parseCliArgs(['run', 'pre-push', '--path=api', '--format', 'json', '--output', 'out.json'], {}))
+            .toEqual({ command: 'run', path: 'api', pipeline: 'pre-push', format: 'json', output: 'out.json' });
+        expect(() => parseCliArgs(['run', '--format', 'xml'], {})).toThrow('Invalid --format xml');
+        expect(() => parseCliArgs(['run', 'a', 'b'], {})).toThrow('Unknown argument: b');
+    });
+
+    it('should print the verdict and exit 1 when a step fails', async () => {
+        const output = join(root, 'out', 'result.txt');
+        expect(await run({ output })).toBe(1);
+        const text = await fs.readFile(output, 'utf-8');
+        const lines = text.split('\n');
+        expect(lines[0]).toBe('FAIL: test failed (1 of 2 steps passed); 0 errors, 0 warnings, 0 info; first: test Exited with code 4: boom');
+        expect(lines[1]).toMatch(/^passed  build \(\d+ms\)$/);
+        expect(lines[2]).toMatch(/^failed  test \(\d+ms\)$/);
+        expect(text).toContain('error: test: Exited with code 4: boom');
+    });
+
+    it('should write JSON, SARIF and JUnit and exit 0 when the pipeline passes', async () => {
+        expect(await run({ pipeline: 'quick', format: 'json', output: join(root, 'result.json'), junitOut: join(root, 'junit.xml') })).toBe(0);
+        const json = JSON.parse(await fs.readFile(join(root, 'result.json'), 'utf-8'));
+        expect(json.verdict.passed).toBe(true);
+        expect(json.steps[0].name).toBe('build');
+        expect(await fs.readFile(join(root, 'junit.xml'), 'utf-8')).toContain('<testsuites name="code-feedback" tests="0"');
+
+        expect(await run({ format: 'sarif', output: join(root, 'result.sarif') })).toBe(1);
+        expect(JSON.parse(await fs.readFile(join(root, 'result.sarif'), 'utf-8')).version).toBe('2.1.0');
+    });
+
+    it('should exit 2 when the pipeline cannot run', async () => {
+        expect(await run({ pipeline: 'missing' })).toBe(2);
+    });
+});
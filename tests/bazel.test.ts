@@ -0,0 +1,145 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { pathToFileURL } from 'url';
+import Config from '../src/config/index.js';
+import { parseBazelOutput, parseBuildEvents } from '../src/diagnostics/index.js';
+import { bazelQueryTool, bazelTestCases, bazelTestTool, findBazelWorkspace, parseLabelKindOutput } from '../src/tools/bazel.js';
+
+const JUNIT = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites><testsuite name="example.com/lib/codec" tests="2">
+<testcase classname="example.com/lib/codec" name="TestDecode" time="0.01"></testcase>
+<testcase classname="example.com/lib/codec" name="TestEncode" time="0.02"><failure message="Failed">codec_test.go:12: got 1, want 2</failure></testcase>
+</testsuite></testsuites>`;
+
+function events(dir: string): string {
+    const file = (name: string) => pathToFileURL(join(dir, name)).href;
+    return [
+        { id: { targetConfigured: { label: '//lib/codec:codec' } }, configured: { targetKind: 'go_library rule' } },
+        { id: { targetConfigured: { label: '//lib/codec:codec_test' } }, configured: { targetKind: 'go_test rule', testSize: 'SMALL' } },
+        { id: { targetConfigured: { label: '//server:server_test' } }, configured: { targetKind: 'go_test rule' } },
+        { id: { targetConfigured: { label: '//tools:gen_test' } }, configured: { targetKind: 'sh_test rule' } },
+        { id: { targetCompleted: { label: '//lib/codec:codec' } }, completed: { success: true } },
+        { id: { targetCompleted: { label: '//lib/codec:codec_test' } }, completed: { success: true } },
+        { id: { targetCompleted: { label: '//server:server_test' } }, completed: { failureDetail: { message: 'GoCompilePkg failed' } } },
+        { id: { targetCompleted: { label: '//tools:gen_test' } }, completed: { success: true } },
+        { id: { actionCompleted: { primaryOutput: 'bazel-out/k8-fastbuild/bin/server/server.a', label: '//server:server_test' } }, action: { label: '//server:server_test', type: 'GoCompilePkg', exitCode: 1, stderr: { name: 'stderr', uri: 'bytestream://cache/blobs/abc' } } },
+        { id: { testResult: { label: '//lib/codec:codec_test', run: 1, shard: 1, attempt: 1 } }, testResult: { status: 'FAILED', testAttemptDurationMillis: '40', testActionOutput: [{ name: 'test.log', uri: file('codec.log') }, { name: 'test.xml', uri: file('codec.xml') }] } },
+        { id: { testSummary: { label: '//lib/codec:codec_test' } }, testSummary: { overallStatus: 'FAILED', totalRunDurationMillis: '40' } },
+        { id: { testResult: { label: '//tools:gen_test', run: 1, shard: 1, attempt: 1 } }, testResult: { status: 'FAILED', testAttemptDurationMillis: '10', testActionOutput: [{ name: 'test.log', uri: file('gen.log') }] } },
+        { id: { testResult: { label: '//tools:gen_test', run: 1, shard: 1, attempt: 2 } }, testResult: { status: 'PASSED', testAttemptDurationMillis: '12', cachedLocally: true, testActionOutput: [{ name: 'test.log', uri: file('gen.log') }] } },
+        { id: { testSummary: { label: '//tools:gen_test' } }, testSummary: { overallStatus: 'FLAKY', totalRunDurationMillis: '22' } },
+        { id: { buildFinished: {} }, finished: { exitCode: { name: 'TESTS_FAILED', code: 3 } } },
+    ].map(event => JSON.stringify(event)).join('\n') + '\nnot json\n';
+}
+
+describe('Bazel', () => {
+    let dir: string;
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-bazel-test-'));
+        Config.getInstance().addAllowedPaths([dir]);
+        await fs.writeFile(join(dir, 'codec.xml'), JUNIT);
+        await fs.writeFile(join(dir, 'codec.log'), 'exec ${PAGER:-/usr/bin/less} "$0" || exit 1\n--- FAIL: TestEncode\n');
+        await fs.writeFile(join(dir, 'gen.log'), 'ok\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should parse targets, test results and failed actions from the build events', () => {
+        const parsed = parseBuildEvents(events(dir));
+        expect(parsed.targets.map(t => `${t.label} ${t.kind} ${t.status}`)).toEqual([
+            '//lib/codec:codec go_library built',
+            '//lib/codec:codec_test go_test built',
+            '//server:server_test go_test failed',
+            '//tools:gen_test sh_test built',
+        ]);
+        expect(parsed.tests.map(t => `${t.label} ${t.status} ${t.runs.length}`)).toEqual([
+            '//lib/codec:codec_test FAILED 1',
+            '//server:server_test FAILED_TO_BUILD 0',
+            '//tools:gen_test FLAKY 2',
+        ]);
+        expect(parsed.tests[0]?.runs[0]).toMatchObject({ status: 'FAILED', durationMs: 40, xmlPath: join(dir, 'codec.xml') });
+        // Remote-cache outputs have no local path
+        expect(parsed.failedActions).toEqual([{ label: '//server:server_test', type: 'GoCompilePkg', exitCode: 1 }]);
+        expect(parsed.exitCode).toEqual({ name: 'TESTS_FAILED', code: 3 });
+    });
+
+    it('should turn test targets into test cases', async () => {
+        const cases = await bazelTestCases(parseBuildEvents(events(dir)).tests);
+        expect(cases.map(c => `${c.suite} ${c.name} ${c.status}`)).toEqual([
+            'example.com/lib/codec TestDecode passed',
+            'example.com/lib/codec TestEncode failed',
+            '//server:server_test //server:server_test error',
+            '//tools:gen_test //tools:gen_test passed',
+        ]);
+        expect(cases[2]?.message).toBe('Failed to build');
+    });
+
+    it('should parse Bazel and compiler errors from the console output', () => {
+        const output = [
+            "ERROR: /ws/server/BUILD.bazel:3:8: no such target '//lib:missing': target 'missing' not declared in package 'lib'",
+            'ERROR: /ws/server/BUILD.bazel:10:8: GoCompilePkg server/server.a failed: (Exit 1): builder failed: error executing command',
+            'server/handler.go:14:2: undefined: decode',
+            'ERROR: /ws/native/BUILD:1:11: Compiling native/fast.cc failed: (Exit 1): gcc failed: error executing command',
+            "native/fast.cc:3:10: error: 'y' was not declared in this scope",
+            'WARNING: /ws/tools/BUILD:2:1: target //tools:old is deprecated',
+            'ERROR: Build did NOT complete successfully',
+        ].join('\n');
+        const diagnostics = parseBazelOutput(output, '/ws');
+        expect(diagnostics.map(d => `${d.source} ${d.severity} ${d.file}:${d.line}`)).toEqual([
+            'bazel error /ws/server/BUILD.bazel:3',
+            'bazel warning /ws/tools/BUILD:2',
+            'clang error /ws/native/fast.cc:3',
+            'go build error /ws/server/handler.go:14',
+        ]);
+        // Without compiler output, the failed action itself is the finding
+        expect(parseBazelOutput(output.split('\n')[1]!, '/ws')).toHaveLength(1);
+    });
+
+    it('should parse label_kind query output', () => {
+        expect(parseLabelKindOutput('go_library rule //lib/codec:codec\nsource file //lib/codec:codec.go\ngo_test rule //lib/codec:codec_test\n'))
+            .toEqual([
+                { kind: 'go_library', label: '//lib/codec:codec' },
+                { kind: 'source file', label: '//lib/codec:codec.go' },
+                { kind: 'go_test', label: '//lib/codec:codec_test' },
+            ]);
+    });
+
+    it('should run bazel test and report through the common test model', async () => {
+        // A stand-in bazel that writes the build events and fails like a test run would
+        const bin = join(dir, 'bin');
+        await fs.mkdir(bin, { recursive: true });
+        await fs.writeFile(join(dir, 'events.fixture'), events(dir));
+        await fs.writeFile(join(bin, 'bazel'), `#!/bin/sh\nfor a; do case "$a" in --build_event_json_file=*) cp ${join(dir, 'events.fixture')} "\${a#*=}";; esac; done\necho "$*" > ${join(dir, 'args')}\necho 'ERROR: Build did NOT complete successfully' >&2\nexit 3\n`, { mode: 0o755 });
+        const path = process.env.PATH;
+        process.env.PATH = `${bin}:${path}`;
+        try {
+            const result: any = await bazelTestTool.run({ projectPath: dir, targets: ['//lib/...', '-//lib/legacy/...'], testFilter: 'TestEncode', config: ['ci'] });
+            const args = await fs.readFile(join(dir, 'args'), 'utf8');
+            expect(args).toContain('--keep_going --config=ci --test_output=summary --test_filter=TestEncode -- //lib/... -//lib/legacy/...');
+            expect(result.success).toBe(false);
+            expect(result.tests.summary).toMatchObject({ total: 4, passed: 2, failed: 1, errored: 1 });
+            expect(result.targets).toEqual({ built: 3, failed: ['//server:server_test'], skipped: 0 });
+            expect(result.warnings).toContain('//tools:gen_test is flaky: it failed and then passed within 2 attempts');
+            expect(result.exitCode).toBe('TESTS_FAILED');
+        } finally {
+            process.env.PATH = path;
+        }
+    });
+
+    it('should find the workspace root above a package', async () => {
+        await fs.mkdir(join(dir, 'ws', 'lib', 'codec'), { recursive: true });
+        await fs.writeFile(join(dir, 'ws', 'MODULE.bazel'), 'module(name = "example")\n');
+        expect(await findBazelWorkspace(join(dir, 'ws', 'lib', 'codec'))).toBe(join(dir, 'ws'));
+    });
+
+    it('should validate target patterns', async () => {
+        const result = await bazelQueryTool.run({ projectPath: dir, scope: '--output=build' });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('Expected a target pattern');
+    });
+});
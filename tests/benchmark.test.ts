@@ -0,0 +1,70 @@
+import { describe, it, expect } from 'vitest';
+import { parseGoBenchOutput, compareBenchmarks } from '../src/tools/benchmark.js';
+import { mannWhitneyUTest, median } from '../src/utils/stats.js';
+
+const benchOutput = (nsPerOp: number[], allocs: number = 3) => [
+    'goos: linux',
+    'goarch: amd64',
+    'pkg: example.com/codec',
+    'cpu: Intel(R) Xeon(R) Processor',
+    ...nsPerOp.map(ns => `BenchmarkEncode/small-8   \t  500000\t      ${ns} ns/op\t     512 B/op\t       ${allocs} allocs/op`),
+    ...nsPerOp.map(() => 'BenchmarkRead-8   \t  1000\t      100 ns/op\t 200.50 MB/s'),
+    'PASS',
+    'ok  \texample.com/codec\t3.012s',
+].join('\n');
+
+describe('Benchmark statistics', () => {
+    it('should compute the exact Mann-Whitney p-value for small samples', () => {
+        expect(mannWhitneyUTest([1, 2, 3, 4, 5], [6, 7, 8, 9, 10])).toBeCloseTo(2 / 252, 6);
+        expect(mannWhitneyUTest([1, 3, 5, 7, 9], [2, 4, 6, 8, 10])).toBeCloseTo(0.6905, 3);
+        expect(mannWhitneyUTest([1], [2])).toBe(1);
+    });
+
+    it('should fall back to the normal approximation with ties', () => {
+        const p = mannWhitneyUTest([1, 1, 2, 2, 3], [3, 4, 4, 5, 5]);
+        expect(p).toBeGreaterThan(0.005);
+        expect(p).toBeLessThan(0.05);
+    });
+
+    it('should take medians of odd and even samples', () => {
+        expect(median([3, 1, 2])).toBe(2);
+        expect(median([4, 1, 2, 3])).toBe(2.5);
+    });
+});
+
+describe('Go benchmarks', () => {
+    it('should parse samples per unit and drop the GOMAXPROCS suffix', () => {
+        const results = parseGoBenchOutput(benchOutput([2371, 2400]));
+        expect(results.map(r => r.name)).toEqual(['BenchmarkEncode/small', 'BenchmarkRead']);
+        expect(results[0]).toEqual({
+            package: 'example.com/codec',
+            name: 'BenchmarkEncode/small',
+            metrics: { 'ns/op': [2371, 2400], 'B/op': [512, 512], 'allocs/op': [3, 3] },
+        });
+        expect(results[1]?.metrics['MB/s']).toEqual([200.5, 200.5]);
+    });
+
+    it('should flag significant regressions above the threshold', () => {
+        const baseline = parseGoBenchOutput(benchOutput([1000, 1010, 990, 1005, 995]));
+        const current = parseGoBenchOutput(benchOutput([1200, 1210, 1190, 1205, 1195], 4));
+        const comparisons = compareBenchmarks(baseline, current, 5);
+        const time = comparisons.find(c => c.name === 'BenchmarkEncode/small' && c.unit === 'ns/op');
+        expect(time).toMatchObject({ baseline: 1000, current: 1200, deltaPercent: 20, testable: true, verdict: 'regression' });
+        expect(time?.pValue).toBeLessThan(0.05);
+        expect(comparisons.find(c => c.unit === 'allocs/op')?.verdict).toBe('regression');
+        expect(comparisons.find(c => c.unit === 'MB/s')?.verdict).toBe('unchanged');
+    });
+
+    it('should not flag noise that the U-test cannot separate', () => {
+        const baseline = parseGoBenchOutput(benchOutput([1000, 1300, 900, 1200, 1100]));
+        const current = parseGoBenchOutput(benchOutput([1300, 950, 1400, 1050, 1200]));
+        const time = compareBenchmarks(baseline, current, 5).find(c => c.unit === 'ns/op');
+        expect(time?.deltaPercent).toBeGreaterThan(5);
+        expect(time?.verdict).toBe('unchanged');
+    });
+
+    it('should decide on the threshold alone when samples are too few', () => {
+        const [time] = compareBenchmarks(parseGoBenchOutput(benchOutput([100])), parseGoBenchOutput(benchOutput([80])), 5);
+        expect(time).toMatchObject({ unit: 'ns/op', testable: false, verdict: 'improvement' });
+    });
+});
@@ -0,0 +1,83 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { execSync } from 'child_process';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { binaryReportTool, diffSizes, packageOfSymbol, parseBuildInfoModules, sumSymbolSizes } from '../src/tools/binsize.js';
+import { commandExists } from '../src/utils/command.js';
+
+const NM = `  4a5e20     412672 R runtime.pclntab
+  401000       2300 T main.main
+  402000       1200 T encoding/json.(*decodeState).object
+  403000        300 t gopkg.in/yaml%2ev3.(*parser).parse
+  404000        180 T slices.Sort[go.shape.[]string,go.shape.string]
+  405000         96 R type:*encoding/json.Decoder
+  406000         64 R go:func.*
+  407000       8192 B runtime.mheap_
+  408000          8 D $f64.3ff0000000000000
+`;
+
+describe('binary_report', () => {
+    let root: string;
+    const git = (command: string) => execSync(`git -c user.name=t -c user.email=t@example.com ${command}`, { cwd: root, stdio: 'pipe' });
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-binsize-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/hello\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'main.go'), 'package main\n\nimport "fmt"\n\nfunc main() {\n\tfmt.Println("hello")\n}\n');
+        git('init -q');
+        git('add -A');
+        git('commit -qm init');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should attribute symbols to packages', () => {
+        expect(packageOfSymbol('encoding/json.(*decodeState).object')).toBe('encoding/json');
+        expect(packageOfSymbol('gopkg.in/yaml%2ev3.(*parser).parse')).toBe('gopkg.in/yaml.v3');
+        expect(packageOfSymbol('type:*encoding/json.Decoder')).toBe('encoding/json');
+        expect(packageOfSymbol('go:func.*')).toBe('(runtime metadata)');
+        expect(Object.fromEntries(sumSymbolSizes(NM))).toEqual({
+            runtime: 412672,
+            main: 2300,
+            'encoding/json': 1296,
+            'gopkg.in/yaml.v3': 300,
+            slices: 180,
+            '(runtime metadata)': 72,
+        });
+        expect(parseBuildInfoModules('bin: go1.22\n\tpath\texample.com/hello\n\tmod\texample.com/hello\t(devel)\t\n\tdep\tgopkg.in/yaml.v3\tv3.0.1\th1:x=\n')).toEqual(['example.com/hello', 'gopkg.in/yaml.v3']);
+        expect(diffSizes([{ name: 'std', bytes: 100 }, { name: 'old', bytes: 5 }], [{ name: 'std', bytes: 120 }, { name: 'new', bytes: 40 }])).toEqual([
+            { name: 'new', before: 0, after: 40, delta: 40 },
+            { name: 'std', before: 100, after: 120, delta: 20 },
+            { name: 'old', before: 5, after: 0, delta: -5 },
+        ]);
+    });
+
+    it('should report size by package and flag growth since base', async () => {
+        if (!await commandExists('go')) return;
+        const result: any = await binaryReportTool.run({ projectPath: root });
+        expect(result.success).toBe(true);
+        expect(result.size.total).toBeGreaterThan(0);
+        expect(result.size.packages.map((p: any) => p.name)).toContain('fmt');
+        expect(result.size.modules.map((m: any) => m.name)).toContain('std');
+
+        // Pulling in net/http and a new dependency grows the binary far beyond 5%
+        await fs.mkdir(join(root, 'extra'));
+        await fs.writeFile(join(root, 'extra', 'go.mod'), 'module example.com/extra\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'extra', 'extra.go'), 'package extra\n\nvar Table = map[string]int{"a": 1}\n\nfunc Lookup(k string) int { return Table[k] }\n');
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/hello\n\ngo 1.21\n\nrequire example.com/extra v0.0.0\n\nreplace example.com/extra => ./extra\n');
+        await fs.writeFile(join(root, 'main.go'), 'package main\n\nimport (\n\t"fmt"\n\t"net/http"\n\n\t"example.com/extra"\n)\n\nfunc main() {\n\tfmt.Println(http.StatusOK, extra.Lookup("a"))\n\thttp.ListenAndServe(":0", nil)\n}\n');
+        const grown: any = await binaryReportTool.run({ projectPath: root, base: 'HEAD' });
+        expect(grown.success).toBe(false);
+        expect(grown.errors[0]).toMatch(/^Binary grew [\d.]+% since HEAD .*above the 5% threshold; new dependencies: example.com\/extra$/);
+        expect(grown.base.packages.find((p: any) => p.name === 'net/http')).toMatchObject({ before: 0 });
+        expect(grown.output).toContain('net/http (new)');
+        expect(grown.diagnostics).toHaveLength(1);
+        expect(grown.diagnostics[0]).toMatchObject({ file: join(root, 'go.mod'), line: 5, severity: 'warning', rule: 'new-dependency-size' });
+        expect(grown.diagnostics[0].message).toMatch(/^New dependency example.com\/extra adds \d+ B to the binary$/);
+    }, 300000);
+});
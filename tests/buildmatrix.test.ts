@@ -0,0 +1,51 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { goBuildMatrixTool, mergeMatrixDiagnostics } from '../src/tools/buildmatrix.js';
+
+describe('Go build matrix', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-matrix-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/matrix\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'main.go'), 'package main\n\nimport "fmt"\n\nfunc main() { fmt.Println(platformName()) }\n');
+        // No darwin implementation: only darwin builds break
+        await fs.writeFile(join(root, 'name_linux.go'), 'package main\n\nfunc platformName() string { return "linux" }\n');
+        await fs.writeFile(join(root, 'name_windows.go'), 'package main\n\nfunc platformName() string { return "windows" }\n');
+        await fs.writeFile(join(root, '.code-feedback.yaml'), 'goTargets: [linux/amd64, windows/amd64]\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should tag findings with the targets that hit them', () => {
+        const diagnostic = { file: '/p/main.go', line: 5, column: 28, severity: 'error' as const, message: 'undefined: platformName', source: 'go' };
+        const merged = mergeMatrixDiagnostics([
+            { target: 'linux/amd64', success: true, durationMs: 1, errors: [], diagnostics: [] },
+            { target: 'darwin/amd64', success: false, durationMs: 1, errors: [], diagnostics: [diagnostic] },
+            { target: 'darwin/arm64', success: false, durationMs: 1, errors: [], diagnostics: [{ ...diagnostic }] },
+        ]);
+        expect(merged.map(d => d.message)).toEqual(['undefined: platformName (darwin/amd64, darwin/arm64)']);
+    });
+
+    it('should build every target and report the platform-specific failure', async () => {
+        const result: any = await goBuildMatrixTool.run({ projectPath: root, targets: ['linux/amd64', 'darwin/arm64', 'plan9/bogus'] });
+        expect(result.success).toBe(false);
+        expect(result.targets.map((t: any) => [t.target, t.success])).toEqual([['linux/amd64', true], ['darwin/arm64', false], ['plan9/bogus', false]]);
+        expect(result.targets[2].errors[0]).toContain('not a target');
+        expect(result.diagnostics).toHaveLength(1);
+        expect(result.diagnostics[0]).toMatchObject({ file: join(root, 'main.go'), line: 5 });
+        expect(result.diagnostics[0].message).toContain('undefined: platformName (darwin/arm64)');
+    }, 120000);
+
+    it('should take targets from the project config', async () => {
+        const result: any = await goBuildMatrixTool.run({ projectPath: root });
+        expect(result.success).toBe(true);
+        expect(result.summary).toEqual({ total: 2, passed: 2, failed: 0 });
+    }, 120000);
+});
@@ -0,0 +1,87 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { ResultCache, isCacheableCall } from '../src/cache/index.js';
+import { goTool } from '../src/tools/go.js';
+import { pythonTool } from '../src/tools/python.js';
+import { rustTool } from '../src/tools/rust.js';
+
+describe('Result cache', () => {
+    let dir: string;
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-cache-'));
+        await fs.writeFile(join(dir, 'main.go'), 'package main\n');
+        Config.getInstance().addAllowedPaths([dir]);
+    });
+
+    afterAll(async () => {
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should return the same key while files are unchanged', async () => {
+        const cache = new ResultCache();
+        const args = { filePath: join(dir, 'main.go') };
+        expect(await cache.computeKey('go', args)).toBe(await cache.computeKey('go', args));
+    });
+
+    it('should invalidate entries when a file changes', async () => {
+        const cache = new ResultCache();
+        const args = { filePath: join(dir, 'main.go') };
+        const before = await cache.computeKey('go', args);
+        expect(before).not.toBeNull();
+        cache.set(before as string, { success: true });
+        expect(cache.get(before as string)).toEqual({ success: true });
+
+        await fs.writeFile(join(dir, 'main.go'), 'package main\n\nfunc main() {}\n');
+        const after = await cache.computeKey('go', args);
+        expect(after).not.toBe(before);
+        expect(cache.get(after as string)).toBeUndefined();
+
+        cache.set(after as string, { success: false });
+        expect(cache.get(before as string)).toBeUndefined();
+        expect(cache.getStats().entries).toBe(1);
+    });
+
+    it('should not cache calls without path arguments or outside allowed roots', async () => {
+        const cache = new ResultCache();
+        expect(await cache.computeKey('http', { url: 'http://localhost' })).toBeNull();
+        expect(await cache.computeKey('go', { filePath: '/invalid/path/main.go' })).toBeNull();
+    });
+
+    it('should invalidate entries when the module manifest or a sibling package changes', async () => {
+        const cache = new ResultCache();
+        const module = join(dir, 'mod');
+        await fs.mkdir(join(module, 'pkg', 'a'), { recursive: true });
+        await fs.mkdir(join(module, 'pkg', 'b'), { recursive: true });
+        await fs.writeFile(join(module, 'go.mod'), 'module example.com/m\n');
+        await fs.writeFile(join(module, 'pkg', 'a', 'a.go'), 'package a\n');
+        await fs.writeFile(join(module, 'pkg', 'b', 'b.go'), 'package b\n');
+        const args = { filePath: join(module, 'pkg', 'a', 'a.go') };
+
+        const initial = await cache.computeKey('go', args);
+        await fs.writeFile(join(module, 'go.mod'), 'module example.com/m\n\ngo 1.22\n');
+        const afterManifest = await cache.computeKey('go', args);
+        expect(afterManifest).not.toBe(initial);
+
+        await fs.writeFile(join(module, 'pkg', 'b', 'b.go'), 'package b\n\nconst B = 1\n');
+        const afterSibling = await cache.computeKey('go', args);
+        expect(afterSibling).not.toBe(afterManifest);
+
+        // Workspace-root config above the module counts too
+        await fs.writeFile(join(dir, 'go.work'), 'go 1.22\n\nuse ./mod\n');
+        expect(await cache.computeKey('go', args)).not.toBe(afterSibling);
+    });
+
+    it('should not cache calls that run arbitrary commands or rewrite files', () => {
+        expect(isCacheableCall(goTool, { filePath: 'main.go', actions: ['vet'] })).toBe(true);
+        expect(isCacheableCall(goTool, { filePath: 'main.go', actions: ['mod'] })).toBe(false);
+        expect(isCacheableCall(goTool, { filePath: 'main.go', command: 'generate ./...' })).toBe(false);
+        expect(isCacheableCall(pythonTool, { filePath: 'main.py' })).toBe(true);
+        expect(isCacheableCall(pythonTool, { filePath: 'main.py', fix: true })).toBe(false);
+        expect(isCacheableCall(rustTool, { filePath: 'src/main.rs', actions: ['clippy'] })).toBe(true);
+        expect(isCacheableCall(rustTool, { filePath: 'src/main.rs', command: 'update' })).toBe(false);
+    });
+});
@@ -0,0 +1,43 @@
+import { describe, it, expect } from 'vitest';
+import { parseGoListPackages, selectGoPackages, groupChangedFiles } from '../src/tools/changed.js';
+import { registerTools } from '../src/tools';
+
+class DummyServer {
+    tools: any[] = [];
+    registerTool(tool: any) { this.tools.push(tool); }
+}
+
+describe('feedback_changed tool', () => {
+    it('should register feedback_changed', () => {
+        const server = new DummyServer();
+        registerTools(server);
+        const tool = server.tools.find(t => t.name === 'feedback_changed');
+        expect(tool).toBeDefined();
+        expect(tool.inputSchema.properties.repoPath).toBeDefined();
+        expect(tool.inputSchema.properties.base).toBeDefined();
+    });
+
+    it('should select changed packages and their reverse dependencies', () => {
+        const output = [
+            'example.com/m/util\t/repo/util\tfmt strings\t',
+            'example.com/m/api\t/repo/api\texample.com/m/util fmt\t',
+            'example.com/m/cli\t/repo/cli\tfmt\texample.com/m/util testing',
+            'example.com/m/other\t/repo/other\tfmt\ttesting',
+        ].join('\n');
+        const packages = parseGoListPackages(output);
+        expect(packages).toHaveLength(4);
+        expect(packages[1]?.deps).toEqual(['example.com/m/util', 'fmt']);
+        const { changed, affected } = selectGoPackages(packages, new Set(['/repo/util']));
+        expect(changed).toEqual(['example.com/m/util']);
+        expect(affected).toEqual(['example.com/m/util', 'example.com/m/api', 'example.com/m/cli']);
+    });
+
+    it('should group changed files by language', () => {
+        const groups = groupChangedFiles(['/r/a.go', '/r/b.py', '/r/c.ts', '/r/types.d.ts', '/r/d.mjs', '/r/e.rs', '/r/README.md']);
+        expect(groups.go).toEqual(['/r/a.go']);
+        expect(groups.python).toEqual(['/r/b.py']);
+        expect(groups.typescript).toEqual(['/r/c.ts']);
+        expect(groups.javascript).toEqual(['/r/d.mjs']);
+        expect(groups.rust).toEqual(['/r/e.rs']);
+    });
+});
@@ -0,0 +1,186 @@
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { parseUnifiedDiff } from '../src/utils/git.js';
+import { applyFileDiff } from '../src/utils/patch.js';
+import { applyChangesTool, applyPatchTool } from '../src/tools/patch.js';
+
+const PATCH = `--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ one
+-two
++TWO
+ three
+`;
+
+describe('applyFileDiff', () => {
+    it('should apply hunks that moved and report the offset', () => {
+        const [fileDiff] = parseUnifiedDiff(PATCH);
+        const result = applyFileDiff('zero\none\ntwo\nthree\n', fileDiff!);
+        expect(result.applied).toBe(true);
+        expect(result.content).toBe('zero\none\nTWO\nthree\n');
+        expect(result.hunks[0]).toMatchObject({ applied: true, offset: 1 });
+    });
+
+    it('should reject hunks whose context does not match', () => {
+        const [fileDiff] = parseUnifiedDiff(PATCH);
+        const result = applyFileDiff('one\nthree\n', fileDiff!);
+        expect(result.applied).toBe(false);
+        expect(result.hunks[0]?.error).toContain('does not match');
+    });
+
+    it('should ignore mismatched outer context within the fuzz factor', () => {
+        const [fileDiff] = parseUnifiedDiff(PATCH);
+        const content = 'ONE\ntwo\nthree\n';
+        expect(applyFileDiff(content, fileDiff!).applied).toBe(false);
+        const result = applyFileDiff(content, fileDiff!, { fuzz: 1 });
+        expect(result.content).toBe('ONE\nTWO\nthree\n');
+        expect(result.hunks[0]).toMatchObject({ applied: true, fuzz: 1, offset: 0 });
+    });
+
+    it('should keep file whitespace when matching loosely', () => {
+        const [fileDiff] = parseUnifiedDiff(PATCH);
+        const result = applyFileDiff('one  \ntwo\n\tthree\n', fileDiff!, { ignoreWhitespace: true });
+        expect(result.content).toBe('one  \nTWO\n\tthree\n');
+    });
+});
+
+describe('apply_changes', () => {
+    let root: string;
+
+    beforeEach(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-changes-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'a.txt'), 'one\ntwo\nthree\n');
+        await fs.writeFile(join(root, 'b.txt'), 'keep\n');
+    });
+
+    afterEach(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should apply writes, edits, deletes and a patch together', async () => {
+        const result: any = await applyChangesTool.run({
+            rootPath: root,
+            changes: [
+                { path: 'new/c.txt', content: 'created\n' },
+                { path: 'b.txt', action: 'delete' },
+            ],
+            patch: PATCH,
+        });
+        expect(result.success).toBe(true);
+        expect(await fs.readFile(join(root, 'a.txt'), 'utf-8')).toBe('one\nTWO\nthree\n');
+        expect(await fs.readFile(join(root, 'new/c.txt'), 'utf-8')).toBe('created\n');
+        await expect(fs.access(join(root, 'b.txt'))).rejects.toThrow();
+        expect(result.files.map((f: any) => f.action).sort()).toEqual(['created', 'deleted', 'modified']);
+    });
+
+    it('should change nothing when any edit fails validation', async () => {
+        const result: any = await applyChangesTool.run({
+            rootPath: root,
+            changes: [
+                { path: 'b.txt', content: 'replaced\n' },
+                { path: 'a.txt', edits: [{ oldText: 'missing', newText: 'x' }] },
+            ],
+        });
+        expect(result.success).toBe(false);
+        expect(result.rolledBack).toBe(false);
+        expect(result.errors[0]).toContain('a.txt');
+        expect(await fs.readFile(join(root, 'b.txt'), 'utf-8')).toBe('keep\n');
+    });
+
+    it('should roll back every file when the verify command fails', async () => {
+        const result: any = await applyChangesTool.run({
+            rootPath: root,
+            changes: [
+                { path: 'a.txt', edits: [{ oldText: 'two', newText: 'deux' }] },
+                { path: 'b.txt', action: 'delete' },
+                { path: 'nested/dir/c.txt', content: 'created\n' },
+            ],
+            verifyCommand: 'grep -q deux a.txt && exit 3',
+        });
+        expect(result.success).toBe(false);
+        expect(result.rolledBack).toBe(true);
+        expect(result.verify.exitCode).toBe(3);
+        expect(await fs.readFile(join(root, 'a.txt'), 'utf-8')).toBe('one\ntwo\nthree\n');
+        expect(await fs.readFile(join(root, 'b.txt'), 'utf-8')).toBe('keep\n');
+        await expect(fs.access(join(root, 'nested'))).rejects.toThrow();
+    });
+
+    it('should keep the changes when the verify command passes', async () => {
+        const result: any = await applyChangesTool.run({
+            rootPath: root,
+            changes: [{ path: 'a.txt', edits: [{ oldText: 'two', newText: 'deux' }] }],
+            verifyCommand: 'grep -q deux a.txt',
+        });
+        expect(result.success).toBe(true);
+        expect(await fs.readFile(join(root, 'a.txt'), 'utf-8')).toBe('one\ndeux\nthree\n');
+    });
+});
+
+describe('apply_patch', () => {
+    let root: string;
+
+    beforeEach(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-patch-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'a.txt'), 'one\ntwo\nthree\n');
+        await fs.writeFile(join(root, 'b.txt'), 'alpha\nbeta\n');
+    });
+
+    afterEach(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    const TWO_FILES = `${PATCH}--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+ alpha
+-gamma
++delta
+`;
+
+    it('should report per-hunk results and write nothing when a hunk fails', async () => {
+        const result: any = await applyPatchTool.run({ rootPath: root, patch: TWO_FILES });
+        expect(result.success).toBe(false);
+        expect(result.files.map((f: any) => f.applied)).toEqual([true, false]);
+        expect(result.files[1].hunks[0].error).toContain('does not match');
+        expect(await fs.readFile(join(root, 'a.txt'), 'utf-8')).toBe('one\ntwo\nthree\n');
+    });
+
+    it('should write the hunks that apply when allowPartial is set', async () => {
+        const result: any = await applyPatchTool.run({ rootPath: root, patch: TWO_FILES, allowPartial: true });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('b.txt: hunk 1');
+        expect(await fs.readFile(join(root, 'a.txt'), 'utf-8')).toBe('one\nTWO\nthree\n');
+        expect(await fs.readFile(join(root, 'b.txt'), 'utf-8')).toBe('alpha\nbeta\n');
+    });
+
+    it('should not touch files on a dry run', async () => {
+        const result: any = await applyPatchTool.run({ rootPath: root, patch: PATCH, dryRun: true });
+        expect(result.success).toBe(true);
+        expect(result.output).toContain('dry run');
+        expect(await fs.readFile(join(root, 'a.txt'), 'utf-8')).toBe('one\ntwo\nthree\n');
+    });
+
+    it('should create and delete files from the patch', async () => {
+        const patch = `--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+--- a/b.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-alpha
+-beta
+`;
+        const result: any = await applyPatchTool.run({ rootPath: root, patch });
+        expect(result.success).toBe(true);
+        expect(await fs.readFile(join(root, 'new.txt'), 'utf-8')).toBe('hello\nworld\n');
+        await expect(fs.access(join(root, 'b.txt'))).rejects.toThrow();
+    });
+});
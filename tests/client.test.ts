@@ -413,6 +413,18 @@ if __name__ == "__main__":
         expect(response.errors.length).toBeGreaterThan(0);
     });
 
+    it('should echo the correlation id of a call', async () => {
+        const result = await client.callTool({
+            name: "get_config",
+            arguments: {},
+            _meta: { requestId: 'trace-1234' }
+        });
+        expect(JSON.parse(result.content[0].text).requestId).toBe('trace-1234');
+
+        const generated = await client.callTool({ name: "get_config", arguments: {} });
+        expect(JSON.parse(generated.content[0].text).requestId).toMatch(/^[0-9a-f-]{36}$/);
+    });
+
     it('should handle connection health check', async () => {
         // Simple health check to ensure connection is working
         try {
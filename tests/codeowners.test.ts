@@ -0,0 +1,89 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { dirname, join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { codeownersPatternToRegExp, ownersForPath, parseCodeowners, ownersForPathTool } from '../src/tools/codeowners.js';
+
+const CODEOWNERS = `# Default owners
+*       @org/core
+
+*.go    @org/go-team @alice
+/docs/  docs@example.com
+apps/   @org/apps
+/build/logs/ @bob
+src/*.ts @carol
+**/vendor
+config/\\#weird @dave   # escaped hash
+!negated @nobody
+`;
+
+describe('CODEOWNERS', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-owners-'));
+        Config.getInstance().addAllowedPaths([root]);
+        for (const path of ['.github/CODEOWNERS', 'main.go', 'docs/guide.md', 'apps/web/index.ts', 'src/a.ts', 'src/nested/b.ts', 'lib/vendor/x.go']) {
+            await fs.mkdir(dirname(join(root, path)), { recursive: true });
+            await fs.writeFile(join(root, path), path === '.github/CODEOWNERS' ? CODEOWNERS : '');
+        }
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should translate patterns like GitHub does', () => {
+        const matches = (pattern: string, path: string) => codeownersPatternToRegExp(pattern).test(path);
+        expect(matches('*', 'any/file.txt')).toBe(true);
+        expect(matches('*.js', 'a/b/c.js')).toBe(true);
+        expect(matches('/docs/', 'docs/a/b.md')).toBe(true);
+        expect(matches('/docs/', 'other/docs/a.md')).toBe(false);
+        expect(matches('docs/', 'other/docs/a.md')).toBe(true);
+        expect(matches('apps/', 'x/apps/a.ts')).toBe(true);
+        expect(matches('docs/*', 'docs/a.md')).toBe(true);
+        expect(matches('docs/*', 'docs/sub/a.md')).toBe(false);
+        expect(matches('**/logs', 'deep/down/logs/app.log')).toBe(true);
+        expect(matches('/build/logs/', 'build/logs/x/y.log')).toBe(true);
+        expect(matches('README.md', 'pkg/README.md')).toBe(true);
+        expect(matches('/README.md', 'pkg/README.md')).toBe(false);
+    });
+
+    it('should let the last matching rule win', () => {
+        const file = parseCodeowners(CODEOWNERS);
+        expect(file.problems).toEqual(['line 11: negated patterns are not supported in CODEOWNERS: !negated']);
+        expect(ownersForPath(file, 'main.go').owners).toEqual(['@org/go-team', '@alice']);
+        expect(ownersForPath(file, 'README.md').owners).toEqual(['@org/core']);
+        expect(ownersForPath(file, 'docs/guide.md')).toEqual({ path: 'docs/guide.md', owners: ['docs@example.com'], rules: [{ pattern: '/docs/', line: 5 }] });
+        expect(ownersForPath(file, 'src/a.ts').owners).toEqual(['@carol']);
+        expect(ownersForPath(file, 'src/nested/b.ts').owners).toEqual(['@org/core']);
+        // A rule without owners unsets them
+        expect(ownersForPath(file, 'lib/vendor/x.go').owners).toEqual([]);
+        expect(ownersForPath(file, 'config/#weird').owners).toEqual(['@dave']);
+    });
+
+    it('should combine GitLab sections and apply section default owners', () => {
+        const file = parseCodeowners('* @org/core\n\n[Docs] @org/writers\n*.md\n\n^[Security][2] @org/sec\n/auth/ @eve\n');
+        expect(ownersForPath(file, 'README.md').owners).toEqual(['@org/core', '@org/writers']);
+        const auth = ownersForPath(file, 'auth/login.go');
+        expect(auth.owners).toEqual(['@org/core', '@eve']);
+        expect(auth.rules.map(r => r.section)).toEqual([undefined, 'Security']);
+    });
+
+    it('should look up owners for paths and paths for an owner', async () => {
+        const result: any = await ownersForPathTool.run({ projectPath: root, paths: ['main.go', join(root, 'docs/guide.md'), 'lib/vendor/x.go'] });
+        expect(result.success).toBe(true);
+        expect(result.codeownersFile).toBe(join(root, '.github/CODEOWNERS'));
+        expect(result.output).toBe('main.go: @org/go-team @alice\ndocs/guide.md: docs@example.com\nlib/vendor/x.go: (no owners)');
+        expect(result.allOwners).toEqual(['@org/go-team', '@alice', 'docs@example.com']);
+        expect(result.warnings).toContain('No owners for lib/vendor/x.go');
+
+        const reverse: any = await ownersForPathTool.run({ projectPath: root, owner: '@ORG/CORE' });
+        expect(reverse.rules.map((r: any) => r.pattern)).toEqual(['*']);
+        expect(reverse.paths).toEqual(['.github/CODEOWNERS', 'src/nested/b.ts']);
+
+        expect((await ownersForPathTool.run({ projectPath: root }) as any).errors[0]).toContain('Pass either paths or owner');
+        expect((await ownersForPathTool.run({ projectPath: root, paths: ['../x'] }) as any).errors).toEqual(['../x is outside ' + root]);
+    });
+});
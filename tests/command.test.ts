@@ -0,0 +1,79 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { mergeConfigs } from '../src/config/project.js';
+import { checkCommand, runCommandTool } from '../src/tools/command.js';
+import { filterEnv } from '../src/utils/env.js';
+
+describe('Command policy', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-command-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.mkdir(join(root, 'scripts'));
+        await fs.writeFile(join(root, 'scripts', 'check.sh'), '#!/bin/sh\necho "check $* ${CF_VISIBLE:-unset} ${CF_HIDDEN:-unset}"\n', { mode: 0o755 });
+        process.env.MCP_CONFIG_FILE = join(root, 'global.yaml');
+        await fs.writeFile(join(root, 'global.yaml'), [
+            'commands:',
+            '  env: ["CF_VISIBLE"]',
+            '  allow:',
+            '    - { binary: ./scripts/check.sh, args: ["--fast", "--only=[a-z]+"], timeout: 5000 }',
+            '    - { binary: echo }',
+        ].join('\n'));
+        // A repository cannot allow itself more
+        await fs.writeFile(join(root, '.code-feedback.yaml'), 'commands:\n  env: ["CF_HIDDEN"]\n  allow:\n    - { binary: sh }\n');
+    });
+
+    afterAll(async () => {
+        delete process.env.MCP_CONFIG_FILE;
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should match binaries exactly and every argument against the rule patterns', () => {
+        const policy = { allow: [{ binary: 'npm', args: ['run', 'build|lint'] }, { binary: './bin/gen' }] };
+        expect(checkCommand(policy, '/work', 'npm', ['run', 'lint'])).toMatchObject({ executable: 'npm' });
+        expect(checkCommand(policy, '/work', 'npm', ['run', 'lint; rm -rf /'])).toEqual({ error: 'Arguments not allowed for npm: lint; rm -rf /' });
+        expect(checkCommand(policy, '/work', 'bin/gen', ['anything'])).toMatchObject({ executable: '/work/bin/gen' });
+        expect(checkCommand(policy, '/work', '/usr/bin/npm', [])).toHaveProperty('error');
+        expect(checkCommand(policy, '/work', 'gen', [])).toHaveProperty('error');
+        expect(checkCommand(undefined, '/work', 'npm', [])).toHaveProperty('error');
+    });
+
+    it('should keep only baseline and allowed environment variables', () => {
+        const env = filterEnv(['NPM_*'], { PATH: '/bin', NPM_TOKEN: 'x', AWS_SECRET_ACCESS_KEY: 'y', LC_ALL: 'C' });
+        expect(env).toEqual({ PATH: '/bin', NPM_TOKEN: 'x', LC_ALL: 'C' });
+    });
+
+    it('should take command rules from the global config only', () => {
+        const merged = mergeConfigs({ commands: { allow: [{ binary: 'make' }], env: ['CI'] } }, { commands: { allow: [{ binary: 'npm' }], env: ['CI', 'HOME'] } });
+        expect(merged.commands).toEqual({ allow: [{ binary: 'make' }], env: ['CI'] });
+        expect(mergeConfigs({}, { commands: { allow: [{ binary: 'sh' }] } }).commands).toBeUndefined();
+    });
+
+    it('should run an allowed script with a filtered environment', async () => {
+        process.env.CF_VISIBLE = 'shown';
+        process.env.CF_HIDDEN = 'leaked';
+        try {
+            const result: any = await runCommandTool.run({ path: root, command: './scripts/check.sh', args: ['--fast', '--only=go'] });
+            expect(result.success).toBe(true);
+            expect(result.output.trim()).toBe('check --fast --only=go shown unset');
+            expect(result.timeout).toBe(5000);
+        } finally {
+            delete process.env.CF_VISIBLE;
+            delete process.env.CF_HIDDEN;
+        }
+    });
+
+    it('should refuse commands, arguments and variables outside the policy', async () => {
+        const denied: any = await runCommandTool.run({ path: root, command: 'sh', args: ['-c', 'id'] });
+        expect(denied.success).toBe(false);
+        expect(denied.errors[0]).toContain('Command not allowed: sh');
+        const badArgs: any = await runCommandTool.run({ path: root, command: './scripts/check.sh', args: ['--only=$(id)'] });
+        expect(badArgs.errors[0]).toContain('Arguments not allowed');
+        const badEnv: any = await runCommandTool.run({ path: root, command: 'echo', args: ['hi'], env: { LD_PRELOAD: '/tmp/x.so' } });
+        expect(badEnv.errors[0]).toContain('LD_PRELOAD');
+    });
+});
@@ -0,0 +1,85 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { checkCommitMessage, parseCommitMessage, suggestCommitMessage, validateCommitMessageTool } from '../src/tools/commits.js';
+
+describe('Commit messages', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-commits-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, '.code-feedback.yaml'), 'commits:\n  scopes: [api, cli]\n  issuePatterns: ["[A-Z]+-\\\\d+"]\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should parse headers, bodies and footers', () => {
+        const parsed = parseCommitMessage('feat(api)!: drop v1 endpoints\n\nClients must move to v2.\n\nBREAKING CHANGE: /v1 is gone\nRefs #12\n# comment from the editor\n');
+        expect(parsed).toEqual({
+            header: 'feat(api)!: drop v1 endpoints',
+            type: 'feat',
+            scope: 'api',
+            description: 'drop v1 endpoints',
+            breaking: true,
+            body: ['', 'Clients must move to v2.'],
+            footers: [{ token: 'BREAKING CHANGE', separator: ': ', value: '/v1 is gone' }, { token: 'Refs', separator: ' #', value: '12' }],
+        });
+    });
+
+    it('should accept conventional messages', () => {
+        expect(checkCommitMessage('fix: handle empty input').errors).toEqual([]);
+        expect(checkCommitMessage('docs(readme): explain API keys\n\nLonger text.').errors).toEqual([]);
+        expect(checkCommitMessage('feat: support the API v2').errors).toEqual([]);
+        expect(checkCommitMessage("Merge branch 'main' into topic").skipped).toBe('merge commit');
+    });
+
+    it('should report each broken rule', () => {
+        expect(checkCommitMessage('Feature(): Add things.').errors).toEqual([
+            'type-case: type "Feature" must be lowercase',
+            'type-enum: type "Feature" is not one of feat, fix, docs, style, refactor, perf, test, build, ci, chore, revert',
+            'scope-empty: scope parentheses are empty',
+            'subject-full-stop: description must not end with a period',
+            'subject-case: description must start with a lowercase letter',
+        ]);
+        expect(checkCommitMessage('Fixed the bug').errors).toEqual(['header-format: header must look like "type(scope): description"']);
+        expect(checkCommitMessage(`fix: ${'x'.repeat(80)}`).errors[0]).toBe('header-max-length: header is 85 characters, the limit is 72');
+        expect(checkCommitMessage('fix: a\nbody right away').errors).toEqual(['body-leading-blank: a blank line must separate the header from the body']);
+        expect(checkCommitMessage(`fix: a\n\n${'word '.repeat(30)}\nhttps://example.com/${'a'.repeat(120)}`).errors).toEqual(['body-max-line-length: line 3 is 150 characters, the limit is 100']);
+        expect(checkCommitMessage('chore(db): x', { scopes: ['api'], requireScope: true }).errors).toEqual(['scope-enum: scope "db" is not one of api']);
+        expect(checkCommitMessage('chore: x', { requireScope: true }).errors).toEqual(['scope-required: a scope is required']);
+        expect(checkCommitMessage('chore: x', { issuePatterns: ['#\\d+'] }).errors[0]).toContain('references-empty');
+        expect(checkCommitMessage('chore: x\n\nCloses #4', { issuePatterns: ['#\\d+'] }).errors).toEqual([]);
+    });
+
+    it('should suggest a corrected message', () => {
+        expect(suggestCommitMessage('Fixed race in the file watcher.')).toBe('fix: race in the file watcher');
+        expect(suggestCommitMessage('Add support for custom key bindings')).toBe('feat: add support for custom key bindings');
+        expect(suggestCommitMessage('[cli] Update help text')).toBe('chore(cli): update help text');
+        expect(suggestCommitMessage('Feature(api)!: New endpoint\nDetails here\n\nRefs #3')).toBe('feat(api)!: new endpoint\n\nDetails here\n\nRefs #3');
+        const wrapped = suggestCommitMessage(`fix: a\n\n- ${'word '.repeat(30).trim()}`, { maxLineLength: 40 }).split('\n');
+        expect(wrapped.slice(2).every(line => line.length <= 40)).toBe(true);
+        expect(wrapped[3]!.startsWith('  word')).toBe(true);
+    });
+
+    it('should apply the project rules', async () => {
+        const result: any = await validateCommitMessageTool.run({ message: 'Fix(store): Crash on start.', projectPath: root });
+        expect(result.success).toBe(false);
+        expect(result.errors).toContain('scope-enum: scope "store" is not one of api, cli');
+        expect(result.errors.some((e: string) => e.startsWith('references-empty'))).toBe(true);
+        expect(result.suggestion).toBe('fix(store): crash on start');
+        expect(result.remainingErrors).toHaveLength(2);
+        expect(result.output).toContain('Still to fix by hand');
+
+        const ok: any = await validateCommitMessageTool.run({ message: 'fix(api): crash on start\n\nRefs: PROJ-7', projectPath: root });
+        expect(ok.success).toBe(true);
+        expect(ok.suggestion).toBeUndefined();
+        const overridden: any = await validateCommitMessageTool.run({ message: 'fix(db): x PROJ-1', projectPath: root, scopes: ['db'] });
+        expect(overridden.success).toBe(true);
+        expect((await validateCommitMessageTool.run({ message: 'fix: x', issuePatterns: ['('] }) as any).errors[0]).toContain('Invalid issue pattern');
+    });
+});
@@ -0,0 +1,101 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { codeMetricsTool, parseEslintMetricsOutput, parseRadonOutput } from '../src/tools/complexity.js';
+
+const ROUTER = `package router
+
+func Route(method, path string, admin bool, retries int) string {
+	for i := 0; i < retries; i++ {
+		switch method {
+		case "GET":
+			if admin && path != "" {
+				return "admin"
+			}
+		case "POST", "PUT":
+			return "write"
+		default:
+			return "other"
+		}
+	}
+	return ""
+}
+
+func (r *Router) Name() string { return "router" }
+
+type Router struct{}
+`;
+
+describe('code_metrics', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-metrics-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/router\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'router.go'), ROUTER);
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should measure Go functions and flag those over a threshold', async () => {
+        const result: any = await codeMetricsTool.run({ path: root, maxComplexity: 4, maxParams: 3 });
+        expect(result.success).toBe(true);
+        expect(result.languages).toEqual(['go']);
+        // 1 + for + case + case + if + &&
+        expect(result.functions[0]).toMatchObject({ name: 'Route', line: 3, lines: 15, complexity: 6, params: 4, language: 'go' });
+        expect(result.functions[1]).toMatchObject({ name: 'Router.Name', complexity: 1, params: 0 });
+        expect(result.files).toEqual([{ file: join(root, 'router.go'), lines: 21, bytes: ROUTER.length, language: 'go' }]);
+        expect(result.totals).toMatchObject({ functions: 2, files: 1, maxComplexity: 6, averageComplexity: 3.5, overThreshold: 2 });
+        expect(result.diagnostics.map((d: any) => `${d.rule}: ${d.message}`)).toEqual([
+            'complexity: Route has a cyclomatic complexity of 6 (threshold 4)',
+            'parameters: Route takes 4 parameters (threshold 3)',
+        ]);
+        expect(result.output).toContain('max 6 (Route at router.go:3)');
+    });
+
+    it('should take thresholds from the project config', async () => {
+        await fs.writeFile(join(root, '.code-feedback.yaml'), 'metrics:\n  functionLines: 10\n  fileLines: 20\n');
+        const result: any = await codeMetricsTool.run({ path: join(root, 'router.go') });
+        expect(result.thresholds).toEqual({ complexity: 15, functionLines: 10, params: 6, fileLines: 20 });
+        expect(result.diagnostics.map((d: any) => d.rule)).toEqual(['function-length', 'file-length']);
+        await fs.rm(join(root, '.code-feedback.yaml'));
+
+        const unknown: any = await codeMetricsTool.run({ path: join(root, 'go.mod') });
+        expect(unknown.errors[0]).toContain('Could not tell the language');
+    });
+
+    it('should parse radon and ESLint metrics output', () => {
+        const radon = parseRadonOutput(JSON.stringify({
+            'app/views.py': [
+                { type: 'function', name: 'index', lineno: 3, endline: 20, complexity: 7, rank: 'B' },
+                { type: 'class', name: 'Store', lineno: 22, endline: 40, complexity: 4, methods: [{ type: 'method', classname: 'Store', name: 'save', lineno: 25, endline: 30, complexity: 3 }] },
+                { type: 'method', classname: 'Store', name: 'save', lineno: 25, endline: 30, complexity: 3 },
+            ],
+            'app/broken.py': { error: 'invalid syntax (<unknown>, line 2)' },
+        }), '/repo');
+        expect(radon.functions.map(f => `${f.name}:${f.complexity}:${f.lines}`)).toEqual(['index:7:18', 'Store.save:3:6']);
+        expect(radon.functions[0]!.file).toBe('/repo/app/views.py');
+        expect(radon.files).toEqual(['/repo/app/views.py']);
+        expect(radon.errors).toEqual(['app/broken.py: invalid syntax (<unknown>, line 2)']);
+
+        const eslint = parseEslintMetricsOutput(JSON.stringify([{
+            filePath: '/repo/src/app.ts',
+            messages: [
+                { ruleId: 'complexity', message: "Function 'handle' has a complexity of 9. Maximum allowed is 0.", line: 4, column: 1 },
+                { ruleId: 'max-params', message: "Function 'handle' has too many parameters (3). Maximum allowed is 0.", line: 4, column: 1 },
+                { ruleId: 'max-lines-per-function', message: "Function 'handle' has too many lines (25). Maximum allowed is 0.", line: 4, column: 1, endLine: 28 },
+                { ruleId: 'complexity', message: 'Arrow function has a complexity of 1. Maximum allowed is 0.', line: 30, column: 12 },
+                { ruleId: 'no-console', message: 'Unexpected console statement.', line: 5, column: 3 },
+            ],
+        }]), '/repo');
+        expect(eslint.functions).toEqual([
+            { name: 'handle', file: '/repo/src/app.ts', line: 4, endLine: 28, lines: 25, complexity: 9, params: 3, language: 'javascript' },
+            { name: 'arrow function', file: '/repo/src/app.ts', line: 30, lines: 0, complexity: 1, params: 0, language: 'javascript' },
+        ]);
+    });
+});
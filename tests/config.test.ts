@@ -0,0 +1,86 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import {
+    mergeConfigs,
+    isToolEnabled,
+    isExcluded,
+    getCommandEnv,
+    getToolTimeout,
+    getEffectiveConfig,
+    loadConfigFile,
+} from '../src/config/project.js';
+import { getConfigTool } from '../src/tools/config.js';
+
+describe('Project config', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-config-'));
+        process.env.MCP_CONFIG_FILE = join(root, 'global.yaml');
+        await fs.writeFile(join(root, 'global.yaml'), 'timeouts:\n  default: 10000\nenv:\n  SHARED: global\n  ONLY_GLOBAL: "1"\n');
+        await fs.mkdir(join(root, 'project', 'pkg', 'sub'), { recursive: true });
+        await fs.writeFile(join(root, 'project', '.code-feedback.yaml'), [
+            'tools:',
+            '  disabled: [docker]',
+            'timeouts:',
+            '  go: 120000',
+            'env:',
+            '  SHARED: project',
+            'buildTags: [integration, e2e]',
+            'exclude:',
+            '  - "vendor/**"',
+            '  - "gen"',
+        ].join('\n'));
+        Config.getInstance().addAllowedPaths([root]);
+    });
+
+    afterAll(async () => {
+        delete process.env.MCP_CONFIG_FILE;
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should merge project config over global config', () => {
+        const merged = mergeConfigs(
+            { tools: { enabled: ['go'], disabled: ['http'] }, env: { A: '1', B: '1' }, exclude: ['a/**'] },
+            { tools: { disabled: ['docker'] }, env: { B: '2' }, exclude: ['b/**'] },
+        );
+        expect(merged.tools).toEqual({ enabled: ['go'], disabled: ['http', 'docker'] });
+        expect(merged.env).toEqual({ A: '1', B: '2' });
+        expect(merged.exclude).toEqual(['a/**', 'b/**']);
+    });
+
+    it('should resolve the effective config for a nested path', async () => {
+        const effective = await getEffectiveConfig(join(root, 'project', 'pkg', 'sub'));
+        expect(effective.projectConfigPath).toBe(join(root, 'project', '.code-feedback.yaml'));
+        expect(effective.workspaceRoot).toBe(join(root, 'project'));
+        expect(effective.config.env).toEqual({ SHARED: 'project', ONLY_GLOBAL: '1' });
+        expect(getToolTimeout(effective.config, 'go')).toBe(120000);
+        expect(getToolTimeout(effective.config, 'rust')).toBe(10000);
+        expect(isToolEnabled(effective.config, 'docker')).toBe(false);
+        expect(isToolEnabled(effective.config, 'get_config')).toBe(true);
+        expect(getCommandEnv(effective.config).GOFLAGS).toContain('-tags=integration,e2e');
+    });
+
+    it('should match excluded paths and their contents', async () => {
+        const effective = await getEffectiveConfig(join(root, 'project'));
+        expect(isExcluded(effective.config, effective.workspaceRoot, join(root, 'project', 'vendor', 'x', 'a.go'))).toBe(true);
+        expect(isExcluded(effective.config, effective.workspaceRoot, join(root, 'project', 'gen', 'b.go'))).toBe(true);
+        expect(isExcluded(effective.config, effective.workspaceRoot, join(root, 'project', 'pkg', 'c.go'))).toBe(false);
+    });
+
+    it('should reject unknown keys', async () => {
+        const bad = join(root, 'bad.yaml');
+        await fs.writeFile(bad, 'tols: []\n');
+        await expect(loadConfigFile(bad)).rejects.toThrow('Invalid config file');
+    });
+
+    it('should expose the effective config through get_config', async () => {
+        const result: any = await getConfigTool.run({ path: join(root, 'project') });
+        expect(result.success).toBe(true);
+        expect(result.config.buildTags).toEqual(['integration', 'e2e']);
+        expect(result.server.executor).toBe('local');
+    });
+});
@@ -0,0 +1,108 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { buildImportGraph, declarationRange, findDefinitions } from '../src/context/index.js';
+import { buildContextTool } from '../src/tools/context.js';
+
+const STORE_GO = `package store
+
+import "errors"
+
+// Store keeps items in memory.
+type Store struct {
+	items map[string]string
+}
+
+// Get returns the item with id.
+func (s *Store) Get(id string) (string, error) {
+	item, ok := s.items[id]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return item, nil
+}
+
+type ID string
+`;
+
+async function write(path: string, content: string) {
+    await fs.mkdir(join(path, '..'), { recursive: true });
+    await fs.writeFile(path, content);
+}
+
+describe('Context packs', () => {
+    let dir: string;
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-context-test-'));
+        Config.getInstance().addAllowedPaths([dir]);
+        await write(join(dir, 'go.mod'), 'module example.com/app\n');
+        await write(join(dir, 'store', 'store.go'), STORE_GO);
+        await write(join(dir, 'api', 'handler.go'), 'package api\n\nimport "example.com/app/store"\n\nfunc Handle(s *store.Store) { s.Get("a") }\n');
+        await write(join(dir, 'web', 'src', 'index.ts'), "import { client } from './api/client.js';\nexport const app = client;\n");
+        await write(join(dir, 'web', 'src', 'api', 'client.ts'), "import { retry } from '../util';\nexport const client = retry(() => 1);\n");
+        await write(join(dir, 'web', 'src', 'util', 'index.ts'), 'export function retry<T>(fn: () => T): T {\n    return fn();\n}\n');
+        await write(join(dir, 'web', 'src', 'big.ts'), `export function big() {\n${'    const x = 1;\n'.repeat(400)}}\nexport const other = 2;\n`);
+        await write(join(dir, 'web', 'src', 'consts.ts'), Array.from({ length: 200 }, (_, i) => `export const value${i} = ${i};`).join('\n'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should find declaration ranges with their doc comments', () => {
+        const lines = STORE_GO.split('\n');
+        expect(declarationRange(lines, lines.indexOf('func (s *Store) Get(id string) (string, error) {'), '.go')).toEqual({ startLine: 10, endLine: 17 });
+        expect(declarationRange(lines, lines.indexOf('type ID string'), '.go')).toEqual({ startLine: 19, endLine: 19 });
+        const python = ['class Cache:', '    def get(self, key):', '        return self.items[key]', '', 'x = 1'];
+        expect(declarationRange(python, 1, '.py')).toEqual({ startLine: 2, endLine: 3 });
+        const defs = findDefinitions([{ path: join(dir, 'store', 'store.go'), source: STORE_GO }], ['Store.Get', 'Missing']);
+        expect([...defs.get('Store.Get')!.values()]).toEqual([[{ startLine: 10, endLine: 17 }]]);
+        expect(defs.get('Missing')!.size).toBe(0);
+    });
+
+    it('should resolve Go packages and TypeScript relative imports to files', async () => {
+        const files = ['store/store.go', 'api/handler.go', 'web/src/index.ts', 'web/src/api/client.ts', 'web/src/util/index.ts'].map(f => join(dir, f));
+        const sources = await Promise.all(files.map(async path => ({ path, source: await fs.readFile(path, 'utf8') })));
+        const graph = await buildImportGraph(dir, sources);
+        expect([...graph.get(join(dir, 'api', 'handler.go'))!]).toEqual([join(dir, 'store', 'store.go')]);
+        expect([...graph.get(join(dir, 'web', 'src', 'index.ts'))!]).toEqual([join(dir, 'web', 'src', 'api', 'client.ts')]);
+        expect([...graph.get(join(dir, 'web', 'src', 'api', 'client.ts'))!]).toEqual([join(dir, 'web', 'src', 'util', 'index.ts')]);
+    });
+
+    it('should bundle seeds and their imports ranked by distance', async () => {
+        const result: any = await buildContextTool.run({ projectPath: dir, files: ['web/src/index.ts'] });
+        expect(result.success).toBe(true);
+        expect(result.files.map((f: any) => `${f.path} ${f.mode} ${f.score} ${f.reason}`)).toEqual([
+            'web/src/index.ts file 1 seed',
+            'web/src/api/client.ts file 0.5 imported by web/src/index.ts',
+            'web/src/util/index.ts file 0.25 imported by web/src/api/client.ts',
+        ]);
+        expect(result.output).toContain('==> web/src/util/index.ts:1-4 (imported by web/src/api/client.ts)\nexport function retry');
+    });
+
+    it('should bring in definitions and users of symbols', async () => {
+        const result: any = await buildContextTool.run({ projectPath: dir, symbols: ['Store.Get'], maxDepth: 1 });
+        expect(result.files.map((f: any) => `${f.path} ${f.reason}`)).toEqual([
+            'store/store.go defines Store.Get',
+            'api/handler.go references Store.Get',
+        ]);
+    });
+
+    it('should fall back to definitions, outlines and truncation when the budget is tight', async () => {
+        const options = { projectPath: dir, maxBytes: 1024, maxDepth: 0 };
+        const ranges: any = await buildContextTool.run({ ...options, symbols: ['other'] });
+        expect(ranges.files[0]).toMatchObject({ path: 'web/src/big.ts', mode: 'ranges', ranges: [{ startLine: 403, endLine: 403 }] });
+        const outline: any = await buildContextTool.run({ ...options, symbols: ['big'] });
+        expect(outline.files[0]).toMatchObject({ path: 'web/src/big.ts', mode: 'outline', ranges: [{ startLine: 1, endLine: 1 }, { startLine: 403, endLine: 403 }] });
+        // A seed whose outline is as large as it is
+        const truncated: any = await buildContextTool.run({ ...options, files: ['web/src/consts.ts'] });
+        expect(truncated.files[0].mode).toBe('truncated');
+        expect(truncated.files[0].ranges[0].startLine).toBe(1);
+        expect(truncated.totalBytes).toBeLessThanOrEqual(1024);
+        const invalid = await buildContextTool.run({ projectPath: dir });
+        expect(invalid.errors[0]).toContain('Pass at least one seed file or symbol');
+    });
+});
@@ -0,0 +1,204 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { execSync } from 'child_process';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import yaml from 'js-yaml';
+import Config from '../src/config/index.js';
+import { parseBufOutput, parseSpectralOutput } from '../src/diagnostics/index.js';
+import { diffOpenApi, lintOpenApi } from '../src/openapi/index.js';
+import { lintOpenApiTool } from '../src/tools/openapi.js';
+import { lintProtoTool } from '../src/tools/proto.js';
+import { locatePointer } from '../src/utils/pointer.js';
+
+const SPEC_V1 = `openapi: 3.0.3
+info:
+  title: Users
+  version: 1.0.0
+  description: User accounts
+  contact:
+    name: API team
+tags:
+  - name: users
+paths:
+  /users/{id}:
+    get:
+      operationId: getUser
+      description: Get a user
+      tags: [users]
+      parameters:
+        - name: id
+          in: path
+          required: true
+          schema:
+            type: string
+      responses:
+        "200":
+          description: The user
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/User"
+        "404":
+          description: Not found
+  /users:
+    delete:
+      operationId: deleteUsers
+      description: Delete all users
+      tags: [users]
+      responses:
+        "204":
+          description: Deleted
+components:
+  schemas:
+    User:
+      type: object
+      properties:
+        id:
+          type: string
+        email:
+          type: string
+`;
+
+const SPEC_V2 = `openapi: 3.0.3
+info:
+  title: Users
+  version: 2.0.0
+tags:
+  - name: users
+paths:
+  /users/{userId}:
+    get:
+      operationId: getUser
+      tags: [users, admin]
+      parameters:
+        - name: id
+          in: path
+          schema:
+            type: string
+        - name: fields
+          in: query
+          required: true
+          schema:
+            type: string
+      responses:
+        "200":
+          description: The user
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/User"
+  /users/:
+    post:
+      operationId: getUser
+      description: Create a user
+      tags: [users]
+      requestBody:
+        $ref: "#/components/requestBodies/Missing"
+      responses:
+        "400":
+          description: Invalid
+components:
+  schemas:
+    User:
+      type: object
+      properties:
+        id:
+          type: integer
+    Unused:
+      type: object
+      properties:
+        state:
+          enum: [on, off, on]
+`;
+
+describe('lint_openapi and lint_proto', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-contracts-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.mkdir(join(root, 'api'));
+        await fs.writeFile(join(root, 'api', 'openapi.yaml'), SPEC_V1);
+        await fs.writeFile(join(root, 'package.json'), '{"name": "users"}');
+        const git = (command: string) => execSync(`git -c user.name=t -c user.email=t@example.com ${command}`, { cwd: root, stdio: 'pipe' });
+        git('init -q');
+        git('add -A');
+        git('commit -qm v1');
+        await fs.writeFile(join(root, 'api', 'openapi.yaml'), SPEC_V2);
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should locate JSON pointers in YAML and JSON', () => {
+        expect(locatePointer(SPEC_V1, '/paths/~1users~1{id}/get/parameters/0/required')).toBe(19);
+        expect(locatePointer(SPEC_V1, '/components/schemas/User/properties/email')).toBe(46);
+        expect(locatePointer(SPEC_V1, '/components/schemas/Missing')).toBe(40);
+        const json = JSON.stringify(yaml.load(SPEC_V1), null, 2);
+        expect(json.split('\n')[locatePointer(json, '/paths/~1users/delete/operationId') - 1]!.trim()).toBe('"operationId": "deleteUsers",');
+        expect(locatePointer(json, '/nothing/here')).toBe(1);
+    });
+
+    it('should lint specs with the built-in rules', () => {
+        expect(lintOpenApi(yaml.load(SPEC_V1))).toEqual([]);
+        expect(lintOpenApi(yaml.load(SPEC_V2)).map(f => `${f.severity} ${f.rule} ${f.pointer}`)).toEqual([
+            'warning info-contact /info',
+            'warning info-description /info',
+            'warning operation-description /paths/~1users~1{userId}/get',
+            'warning operation-tag-defined /paths/~1users~1{userId}/get/tags/1',
+            'error path-params /paths/~1users~1{userId}/get',
+            'error path-params /paths/~1users~1{userId}/get/parameters/0',
+            'error operation-operationId-unique /paths/~1users~1/post/operationId',
+            'warning operation-success-response /paths/~1users~1/post/responses',
+            'warning path-keys-no-trailing-slash /paths/~1users~1',
+            'error invalid-ref /paths/~1users~1/post/requestBody/$ref',
+            'warning duplicated-entry-in-enum /components/schemas/Unused/properties/state/enum/2',
+            'warning oas3-unused-component /components/schemas/Unused',
+        ]);
+    });
+
+    it('should report breaking changes between spec versions', () => {
+        expect(diffOpenApi(yaml.load(SPEC_V1), yaml.load(SPEC_V2)).map(f => `${f.rule}: ${f.message}`)).toEqual([
+            'response-property-type-changed: GET /users/{id} 200 response property id changed type from "string" to "integer"',
+            'response-property-removed: GET /users/{id} 200 response no longer has property email',
+            'new-required-parameter: GET /users/{id} has a new required query parameter fields',
+            'removed-operation: DELETE /users was removed',
+        ]);
+        // 404 is not a success response, so removing it breaks nobody
+        const without404 = SPEC_V1.replace('        "404":\n          description: Not found\n', '');
+        expect(diffOpenApi(yaml.load(SPEC_V1), yaml.load(without404))).toEqual([]);
+        expect(diffOpenApi(yaml.load(SPEC_V1), yaml.load(SPEC_V1.replace('"204"', '"202"')))[0]).toMatchObject({ rule: 'removed-response', pointer: '/paths/~1users/delete/responses' });
+    });
+
+    it('should lint and diff a spec against a git ref', async () => {
+        const result: any = await lintOpenApiTool.run({ path: root, base: 'HEAD', engine: 'builtin' });
+        expect(result.success).toBe(false);
+        expect(result.specs).toEqual([join(root, 'api', 'openapi.yaml')]);
+        const removed = result.diagnostics.find((d: any) => d.rule === 'removed-operation');
+        expect(removed).toMatchObject({ file: join(root, 'api', 'openapi.yaml'), line: 7, severity: 'error', source: 'lint_openapi' });
+        expect(result.output).toContain('api/openapi.yaml: 4 breaking change(s) since HEAD');
+
+        await fs.writeFile(join(root, 'api', 'broken.yaml'), 'openapi: 3.0.3\ninfo: [\n');
+        const broken: any = await lintOpenApiTool.run({ path: join(root, 'api', 'broken.yaml'), engine: 'builtin' });
+        expect(broken.diagnostics[0]).toMatchObject({ rule: 'parse', severity: 'error', line: 3 });
+    });
+
+    it('should parse Spectral and buf reports', () => {
+        const spectral = JSON.stringify([{ code: 'operation-tags', path: ['paths', '/users', 'get'], message: 'Operation must have non-empty "tags" array.', severity: 1, range: { start: { line: 11, character: 4 }, end: { line: 20, character: 0 } }, source: 'api/openapi.yaml' }]);
+        expect(parseSpectralOutput(spectral, root)).toEqual([
+            { file: join(root, 'api', 'openapi.yaml'), line: 12, column: 5, severity: 'warning', message: 'Operation must have non-empty "tags" array.', rule: 'operation-tags', source: 'spectral' },
+        ]);
+        const buf = [
+            '{"path":"acme/v1/user.proto","start_line":8,"start_column":3,"end_line":8,"end_column":20,"type":"FIELD_LOWER_SNAKE_CASE","message":"Field name \\"userID\\" should be lower_snake_case, such as \\"user_id\\"."}',
+            '{"path":"acme/v1/user.proto","start_line":4,"start_column":1,"type":"FIELD_NO_DELETE","message":"Previously present field \\"3\\" with name \\"email\\" on message \\"User\\" was deleted."}',
+        ].join('\n');
+        expect(parseBufOutput(buf, root, 'error').map(d => `${d.line}:${d.column} ${d.severity} ${d.rule}`)).toEqual(['8:3 error FIELD_LOWER_SNAKE_CASE', '4:1 error FIELD_NO_DELETE']);
+    });
+
+    it('should validate proto tool arguments', async () => {
+        expect((await lintProtoTool.run({ path: root, base: 'main', against: 'buf.build/acme/api' })).errors).toEqual(['Pass base or against, not both']);
+        expect((await lintProtoTool.run({ path: root, lint: false })).errors).toEqual(['Nothing to check: enable lint or pass base or against']);
+    });
+});
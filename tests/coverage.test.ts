@@ -0,0 +1,69 @@
+import { describe, it, expect } from 'vitest';
+import { parseGoCoverProfile, parseCoveragePyJson, parseIstanbulCoverage, toLineRanges } from '../src/tools/coverage.js';
+
+describe('Coverage parsers', () => {
+    it('should collapse lines into ranges', () => {
+        expect(toLineRanges([5, 1, 2, 3, 7, 6])).toEqual([{ start: 1, end: 3 }, { start: 5, end: 7 }]);
+    });
+
+    it('should parse a go cover profile with per-function output', () => {
+        const profile = [
+            'mode: set',
+            'example.com/gc/calc.go:3.24,3.40 1 1',
+            'example.com/gc/calc.go:5.22,6.11 1 0',
+            'example.com/gc/calc.go:6.11,8.3 1 0',
+            'example.com/gc/calc.go:9.2,9.10 1 0',
+        ].join('\n');
+        const funcOutput = [
+            'example.com/gc/calc.go:3:\tAdd\t\t100.0%',
+            'example.com/gc/calc.go:5:\tSign\t\t0.0%',
+            'total:\t\t\t(statements)\t25.0%',
+        ].join('\n');
+        const report = parseGoCoverProfile(profile, funcOutput);
+        expect(report.totalPercent).toBe(25);
+        expect(report.files).toHaveLength(1);
+        expect(report.files[0]).toMatchObject({
+            file: 'example.com/gc/calc.go',
+            coveredStatements: 1,
+            totalStatements: 4,
+            uncoveredRanges: [{ start: 5, end: 9 }],
+        });
+        expect(report.files[0]?.functions.map(f => f.name)).toEqual(['Add', 'Sign']);
+    });
+
+    it('should parse coverage.py json reports', () => {
+        const report = parseCoveragePyJson({
+            files: {
+                'pkg/mod.py': {
+                    summary: { percent_covered: 50, covered_lines: 2, num_statements: 4 },
+                    missing_lines: [4, 5],
+                    functions: {
+                        '': { executed_lines: [1], missing_lines: [] },
+                        'helper': { executed_lines: [], missing_lines: [4, 5], summary: { percent_covered: 0 } },
+                    },
+                },
+            },
+            totals: { percent_covered: 50 },
+        });
+        expect(report.totalPercent).toBe(50);
+        expect(report.files[0]?.uncoveredRanges).toEqual([{ start: 4, end: 5 }]);
+        expect(report.files[0]?.functions).toEqual([{ name: 'helper', line: 4, percent: 0 }]);
+    });
+
+    it('should parse istanbul coverage maps', () => {
+        const report = parseIstanbulCoverage({
+            '/project/src/a.js': {
+                statementMap: {
+                    '0': { start: { line: 1 }, end: { line: 1 } },
+                    '1': { start: { line: 3 }, end: { line: 4 } },
+                },
+                s: { '0': 2, '1': 0 },
+                fnMap: { '0': { name: 'a', decl: { start: { line: 2 } } } },
+                f: { '0': 0 },
+            },
+        }, '/project');
+        expect(report.totalPercent).toBe(50);
+        expect(report.files[0]).toMatchObject({ file: 'src/a.js', uncoveredRanges: [{ start: 3, end: 4 }] });
+        expect(report.files[0]?.functions).toEqual([{ name: 'a', line: 2, percent: 0 }]);
+    });
+});
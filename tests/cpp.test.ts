@@ -0,0 +1,98 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { parseClangOutput, parseCMakeOutput, parseSanitizerOutput } from '../src/diagnostics/index.js';
+import { findCompileCommands, compileCommandSources } from '../src/tools/cpp.js';
+
+describe('C/C++ diagnostics', () => {
+    it('should parse gcc output with warning flags as rules', () => {
+        const output = [
+            "t.c: In function 'main':",
+            "t.c:1:27: error: 'y' undeclared (first use in this function)",
+            '    1 | int main(){ int x; return y; }',
+            '      |                           ^',
+            't.c:1:27: note: each undeclared identifier is reported only once for each function it appears in',
+            "t.c:1:17: warning: unused variable 'x' [-Wunused-variable]",
+        ].join('\n');
+        const diagnostics = parseClangOutput(output, '/work/build');
+        expect(diagnostics.map(d => d.severity)).toEqual(['error', 'info', 'warning']);
+        expect(diagnostics[0]).toMatchObject({ file: '/work/build/t.c', line: 1, column: 27 });
+        expect(diagnostics[2]).toMatchObject({ rule: '-Wunused-variable', message: "unused variable 'x'" });
+    });
+
+    it('should parse clang-tidy findings and drop repeats from shared headers', () => {
+        const output = [
+            "/work/include/util.h:3:1: warning: function 'f' is never used [misc-unused-functions]",
+            "/work/src/a.cpp:10:5: warning: use nullptr [modernize-use-nullptr]",
+            "/work/include/util.h:3:1: warning: function 'f' is never used [misc-unused-functions]",
+        ].join('\n');
+        const diagnostics = parseClangOutput(output, '/work', 'clang-tidy');
+        expect(diagnostics.map(d => d.rule)).toEqual(['misc-unused-functions', 'modernize-use-nullptr']);
+        expect(diagnostics[0]?.source).toBe('clang-tidy');
+    });
+
+    it('should parse MSVC diagnostics', () => {
+        const diagnostics = parseClangOutput("C:\\src\\a.cpp(12,5): error C2065: 'x': undeclared identifier", 'C:\\src');
+        expect(diagnostics[0]).toMatchObject({ line: 12, column: 5, severity: 'error', rule: 'C2065', source: 'msvc' });
+    });
+
+    it('should parse CMake errors with their message blocks', () => {
+        const output = [
+            '-- The C compiler identification is GNU 13.2.0',
+            'CMake Error at CMakeLists.txt:7 (find_package):',
+            '  By not providing "FindFoo.cmake" in CMAKE_MODULE_PATH this project has',
+            '  asked CMake to find a package configuration file provided by "Foo".',
+            '',
+            '-- Configuring incomplete, errors occurred!',
+        ].join('\n');
+        const diagnostics = parseCMakeOutput(output, '/work');
+        expect(diagnostics).toHaveLength(1);
+        expect(diagnostics[0]).toMatchObject({ file: '/work/CMakeLists.txt', line: 7, severity: 'error', rule: 'find_package' });
+        expect(diagnostics[0]?.message).toContain('asked CMake to find a package configuration file');
+    });
+
+    it('should parse sanitizer reports by their summary line', () => {
+        const output = [
+            '==4242==ERROR: AddressSanitizer: heap-buffer-overflow on address 0x602000000014 at pc 0x4011d6 bp 0x7ffd sp 0x7ffd',
+            'READ of size 4 at 0x602000000014 thread T0',
+            '    #0 0x4011d5 in copy /work/src/buf.c:12',
+            'SUMMARY: AddressSanitizer: heap-buffer-overflow /work/src/buf.c:12:5 in copy',
+            '==4243==WARNING: MemorySanitizer: use-of-uninitialized-value',
+            'SUMMARY: MemorySanitizer: use-of-uninitialized-value src/init.c:7 in main',
+            'SUMMARY: AddressSanitizer: 24 byte(s) leaked in 1 allocation(s).',
+        ].join('\n');
+        const diagnostics = parseSanitizerOutput(output, '/work');
+        expect(diagnostics).toHaveLength(2);
+        expect(diagnostics[0]).toMatchObject({ file: '/work/src/buf.c', line: 12, column: 5, rule: 'heap-buffer-overflow', source: 'asan' });
+        expect(diagnostics[0]?.message).toBe('heap-buffer-overflow on address 0x602000000014 at pc 0x4011d6 bp 0x7ffd sp 0x7ffd in copy');
+        expect(diagnostics[1]).toMatchObject({ file: '/work/src/init.c', line: 7, source: 'msan', message: 'use-of-uninitialized-value in main' });
+    });
+});
+
+describe('Compilation database', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-cpp-'));
+        await fs.mkdir(join(root, 'cmake-build-debug'), { recursive: true });
+        await fs.writeFile(join(root, 'cmake-build-debug', 'compile_commands.json'), '[]');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should discover compile_commands.json in common build directories', async () => {
+        expect(await findCompileCommands(root)).toBe(join(root, 'cmake-build-debug', 'compile_commands.json'));
+    });
+
+    it('should resolve relative database entries', () => {
+        const sources = compileCommandSources([
+            { directory: '/work/build', file: '../src/a.cpp', command: 'c++ -c ../src/a.cpp' },
+            { directory: '/work/build', file: '/work/src/b.cpp', arguments: ['c++', '-c', '/work/src/b.cpp'] },
+            { directory: '/work/build', file: '/work/src/b.cpp', arguments: ['c++', '-DX', '-c', '/work/src/b.cpp'] },
+        ]);
+        expect(sources).toEqual(['/work/src/a.cpp', '/work/src/b.cpp']);
+    });
+});
@@ -0,0 +1,86 @@
+import { describe, it, expect } from 'vitest';
+import { findCycles, reachable, reverseEdges } from '../src/utils/graph.js';
+import { parseGoListDeps, parseNpmLsJson, parsePipInspect, matchesPackagePattern, findRuleViolations } from '../src/tools/depgraph.js';
+
+const graph = (edges: Record<string, string[]>) => new Map(Object.entries(edges));
+
+describe('Graph helpers', () => {
+    it('should find one shortest cycle per strongly connected component', () => {
+        const edges = graph({ a: ['b'], b: ['c', 'd'], c: ['a'], d: ['b'], e: ['e'], f: ['a'] });
+        expect(findCycles(edges)).toEqual([['a', 'b', 'c', 'a'], ['e', 'e']]);
+        expect(findCycles(graph({ a: ['b'], b: [] }))).toEqual([]);
+    });
+
+    it('should answer transitive dependents', () => {
+        const edges = graph({ app: ['api', 'db'], api: ['db'], db: ['log'], log: [] });
+        expect(reachable(reverseEdges(edges), 'db')).toEqual(['api', 'app']);
+        expect(reachable(edges, 'api')).toEqual(['db', 'log']);
+    });
+});
+
+describe('Dependency graph parsers', () => {
+    it('should parse go list -deps output without the standard library', () => {
+        const output = [
+            { ImportPath: 'fmt', Standard: true, Imports: ['errors'] },
+            { ImportPath: 'golang.org/x/text/width', Imports: ['unicode/utf8'], Module: { Path: 'golang.org/x/text', Version: 'v0.14.0' } },
+            { ImportPath: 'example.com/app/store', Imports: ['fmt', 'example.com/app/api'], Module: { Path: 'example.com/app', Main: true }, Error: { ImportStack: [], Err: 'import cycle not allowed' } },
+            { ImportPath: 'example.com/app/api', Imports: ['example.com/app/store', 'golang.org/x/text/width', 'C'], Module: { Path: 'example.com/app', Main: true } },
+        ].map(p => JSON.stringify(p, null, 2)).join('\n');
+        const result = parseGoListDeps(output, false);
+        expect(result.nodes.map(n => n.id)).toEqual(['golang.org/x/text/width', 'example.com/app/store', 'example.com/app/api']);
+        expect(result.nodes[0]).toMatchObject({ version: 'v0.14.0', local: false });
+        expect(result.edges.get('example.com/app/api')).toEqual(['example.com/app/store', 'golang.org/x/text/width']);
+        expect(result.problems).toEqual([]);
+        expect(findCycles(result.edges)).toEqual([['example.com/app/api', 'example.com/app/store', 'example.com/app/api']]);
+    });
+
+    it('should parse npm ls --all --json with versioned nodes', () => {
+        const output = JSON.stringify({
+            name: 'web',
+            version: '1.0.0',
+            problems: ['missing: left-pad@^1.0.0, required by web@1.0.0'],
+            dependencies: {
+                express: { version: '4.18.2', dependencies: { debug: { version: '2.6.9', dependencies: { ms: { version: '2.0.0' } } } } },
+                debug: { version: '4.3.4', dependencies: { ms: { version: '2.1.2' } } },
+                shared: { version: '0.1.0', resolved: 'file:../shared' },
+                'left-pad': { required: '^1.0.0', missing: true },
+            },
+        });
+        const result = parseNpmLsJson(output);
+        expect(result.edges.get('web@1.0.0')).toEqual(['express@4.18.2', 'debug@4.3.4', 'shared@0.1.0']);
+        expect(result.edges.get('debug@2.6.9')).toEqual(['ms@2.0.0']);
+        expect(result.nodes.filter(n => n.local).map(n => n.id)).toEqual(['web@1.0.0', 'shared@0.1.0']);
+        expect(result.problems).toHaveLength(1);
+    });
+
+    it('should parse pip inspect and ignore optional extras', () => {
+        const output = JSON.stringify({
+            installed: [
+                { metadata: { name: 'requests', version: '2.31.0', requires_dist: ['charset-normalizer<4,>=2', 'urllib3<3,>=1.21.1', "PySocks!=1.5.7,>=1.5.6; extra == 'socks'"] } },
+                { metadata: { name: 'charset_normalizer', version: '3.3.2' } },
+                { metadata: { name: 'urllib3', version: '2.1.0' } },
+                { metadata: { name: 'myapp', version: '0.1.0', requires_dist: ['requests (>=2)'] }, direct_url: { url: 'file:///work', dir_info: { editable: true } } },
+            ],
+        });
+        const result = parsePipInspect(output);
+        expect(result.edges.get('requests')).toEqual(['charset-normalizer', 'urllib3']);
+        expect(result.edges.get('myapp')).toEqual(['requests']);
+        expect(result.nodes.find(n => n.id === 'myapp')?.local).toBe(true);
+    });
+});
+
+describe('Layering rules', () => {
+    it('should match Go-style package patterns', () => {
+        expect(matchesPackagePattern('example.com/app/domain/...', 'example.com/app/domain')).toBe(true);
+        expect(matchesPackagePattern('example.com/app/domain/...', 'example.com/app/domain/user')).toBe(true);
+        expect(matchesPackagePattern('example.com/app/domain/...', 'example.com/app/domainx')).toBe(false);
+        expect(matchesPackagePattern('.../internal/http', 'example.com/app/internal/http')).toBe(true);
+    });
+
+    it('should report forbidden direct imports', () => {
+        const edges = graph({ 'app/domain/user': ['app/http', 'app/log'], 'app/http': ['app/domain/user'], 'app/log': [] });
+        const nodes = [...edges.keys()].map(id => ({ id, name: id, local: true }));
+        const violations = findRuleViolations({ nodes, edges, problems: [] }, [{ from: 'app/domain/...', to: 'app/http/...', reason: 'domain stays transport-agnostic' }]);
+        expect(violations).toEqual([{ from: 'app/domain/user', to: 'app/http', rule: 'app/domain/... must not import app/http/...: domain stays transport-agnostic' }]);
+    });
+});
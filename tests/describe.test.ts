@@ -0,0 +1,68 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { auditLog } from '../src/audit/index.js';
+import { describeToolsTool } from '../src/tools/describe.js';
+import { whichBinary } from '../src/tools/environment.js';
+
+describe('Tool descriptions', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-describe-'));
+        process.env.MCP_AUDIT_LOG = join(root, 'audit.jsonl');
+    });
+
+    afterAll(async () => {
+        delete process.env.MCP_AUDIT_LOG;
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should describe every enabled tool with its schemas and flags', async () => {
+        const result: any = await describeToolsTool.run({});
+        expect(result.success).toBe(true);
+        const names = result.tools.map((t: any) => t.name);
+        expect(names).toContain('describe_tools');
+        expect(names).toContain('go');
+
+        const go = result.tools.find((t: any) => t.name === 'go');
+        expect(go.mutates).toBe('depends');
+        expect(go.cacheable).toBe(true);
+        expect(go.inputSchema.properties.workspace.type).toBe('string');
+        expect(go.outputSchema.required).toEqual(['success', 'errors', 'warnings', 'output']);
+
+        expect(result.tools.find((t: any) => t.name === 'run_command').mutates).toBe('always');
+        expect(result.tools.find((t: any) => t.name === 'git_status').mutates).toBe('never');
+    });
+
+    it('should report whether required binaries are on PATH', async () => {
+        const result: any = await describeToolsTool.run({ tools: ['go', 'list_tree', 'not_a_tool'], schemas: false });
+        expect(result.tools.map((t: any) => t.name)).toEqual(['go', 'list_tree']);
+        expect(result.warnings).toContain('Unknown or disabled tools: not_a_tool');
+        expect(result.tools[0].inputSchema).toBeUndefined();
+
+        const go = result.tools[0];
+        expect(go.binaries[0].name).toBe('go');
+        expect(go.binaries[0].found).toBe(true);
+        expect(go.available).toBe(true);
+        expect(result.tools[1].binaries).toEqual([]);
+        expect(result.tools[1].available).toBe(true);
+
+        expect(await whichBinary('definitely-not-installed-cf')).toBe(null);
+        expect(await whichBinary(go.binaries[0].path)).toBe(go.binaries[0].path);
+    });
+
+    it('should take typical latency from recent audited calls', async () => {
+        for (const durationMs of [100, 200, 300, 400, 5000]) {
+            await auditLog.append({ id: `call-${durationMs}`, timestamp: new Date().toISOString(), tool: 'go_mod_check', args: {}, durationMs, status: 'success', mutating: false, files: [] });
+        }
+        // Cache hits say nothing about how long the tool takes
+        await auditLog.append({ id: 'cached', timestamp: new Date().toISOString(), tool: 'go_mod_check', args: {}, durationMs: 1, status: 'success', mutating: false, cached: true, files: [] });
+
+        const result: any = await describeToolsTool.run({ tools: ['go_mod_check', 'go_benchmark'], schemas: false });
+        expect(result.tools[0].latency).toEqual({ source: 'history', calls: 5, medianMs: 300, p90Ms: 5000, maxMs: 5000 });
+        expect(result.tools[1].latency).toBe(null);
+        expect(result.output).toContain('go_mod_check, ~300ms');
+    });
+});
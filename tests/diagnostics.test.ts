@@ -0,0 +1,181 @@
+import { describe, it, expect } from 'vitest';
+import {
+    parseGoBuildOutput,
+    parseGoVetOutput,
+    parseGoplsCheckOutput,
+    parseGolangciLintOutput,
+    parseMypyJsonOutput,
+    parseMypyTextOutput,
+    parsePyrightJsonOutput,
+    parseRuffJsonOutput,
+    parseTscOutput,
+    parseEslintJsonOutput,
+    countBySeverity,
+} from '../src/diagnostics/index.js';
+
+describe('Go diagnostics parsers', () => {
+    it('should parse go build errors', () => {
+        const output = '# command-line-arguments\n./main.go:8:6: declared and not used: unused\n';
+        const diagnostics = parseGoBuildOutput(output, '/project');
+        expect(diagnostics).toEqual([{
+            file: '/project/main.go',
+            line: 8,
+            column: 6,
+            severity: 'error',
+            message: 'declared and not used: unused',
+            source: 'go build',
+        }]);
+    });
+
+    it('should parse go vet -json output with analyzer rules', () => {
+        const output = `# command-line-arguments
+{
+	"command-line-arguments": {
+		"printf": [
+			{
+				"posn": "/project/main.go:7:14",
+				"end": "/project/main.go:7:16",
+				"message": "fmt.Printf format %d has arg s of wrong type string"
+			}
+		]
+	}
+}
+`;
+        const diagnostics = parseGoVetOutput(output, '/project');
+        expect(diagnostics).toHaveLength(1);
+        expect(diagnostics[0]).toMatchObject({
+            file: '/project/main.go',
+            line: 7,
+            column: 14,
+            severity: 'warning',
+            rule: 'printf',
+        });
+    });
+
+    it('should fall back to plain vet output', () => {
+        const diagnostics = parseGoVetOutput('main.go:7:14: fmt.Printf format %d has arg s of wrong type string\n', '/project');
+        expect(diagnostics[0]?.file).toBe('/project/main.go');
+        expect(diagnostics[0]?.severity).toBe('warning');
+    });
+
+    it('should report vet type-check failures as errors', () => {
+        const output = '# command-line-arguments\n# [command-line-arguments]\nvet: ./bad.go:4:6: declared and not used: x\n';
+        const diagnostics = parseGoVetOutput(output, '/project');
+        expect(diagnostics).toHaveLength(1);
+        expect(diagnostics[0]).toMatchObject({ file: '/project/bad.go', line: 4, severity: 'error' });
+    });
+
+    it('should parse gopls check ranges', () => {
+        const diagnostics = parseGoplsCheckOutput('/project/main.go:3:2-7: "os" imported and not used\n', '/project');
+        expect(diagnostics[0]).toMatchObject({ line: 3, column: 2, message: '"os" imported and not used' });
+        expect(countBySeverity(diagnostics).warning).toBe(1);
+    });
+
+    it('should parse golangci-lint JSON issues with linter rules', () => {
+        const output = JSON.stringify({
+            Issues: [
+                { FromLinter: 'errcheck', Text: 'Error return value is not checked', Severity: '', Pos: { Filename: 'pkg/a.go', Line: 12, Column: 3 } },
+                { FromLinter: 'typecheck', Text: 'undefined: x', Severity: 'error', Pos: { Filename: 'main.go', Line: 4, Column: 9 } },
+            ],
+            Report: { Linters: [] },
+        }) + '\n0 issues.\n';
+        const diagnostics = parseGolangciLintOutput(output, '/project');
+        expect(diagnostics).toHaveLength(2);
+        expect(diagnostics[0]).toMatchObject({ file: '/project/pkg/a.go', line: 12, column: 3, severity: 'warning', rule: 'errcheck', source: 'golangci-lint' });
+        expect(diagnostics[1]?.severity).toBe('error');
+        expect(parseGolangciLintOutput('{"Issues":null}', '/project')).toHaveLength(0);
+    });
+
+    it('should mark golangci-lint issues that carry a fix', () => {
+        const output = JSON.stringify({
+            Issues: [
+                { FromLinter: 'gofmt', Text: 'File is not gofmt-ed', Pos: { Filename: 'a.go', Line: 1, Column: 1 }, Replacement: { NeedOnlyDelete: false, NewLines: ['x'] } },
+                { FromLinter: 'staticcheck', Text: 'should use strings.ReplaceAll', Pos: { Filename: 'a.go', Line: 5, Column: 2 }, SuggestedFixes: [{ Message: 'Use ReplaceAll' }] },
+                { FromLinter: 'errcheck', Text: 'unchecked', Pos: { Filename: 'a.go', Line: 9, Column: 2 }, Replacement: null },
+            ],
+        });
+        expect(parseGolangciLintOutput(output, '/project').map(d => d.fixable)).toEqual([true, true, undefined]);
+    });
+});
+
+describe('Python diagnostics parsers', () => {
+    it('should parse mypy JSON output', () => {
+        const output = [
+            '{"file": "pkg/a.py", "line": 3, "column": 4, "message": "Incompatible types in assignment", "hint": null, "code": "assignment", "severity": "error"}',
+            '{"file": "pkg/a.py", "line": 5, "column": 0, "message": "Revealed type is \\"int\\"", "hint": null, "code": null, "severity": "note"}',
+        ].join('\n');
+        const diagnostics = parseMypyJsonOutput(output, '/project');
+        expect(diagnostics).toHaveLength(2);
+        expect(diagnostics[0]).toMatchObject({ file: '/project/pkg/a.py', line: 3, column: 5, severity: 'error', rule: 'assignment', source: 'mypy' });
+        expect(diagnostics[1]?.severity).toBe('info');
+    });
+
+    it('should parse mypy text output', () => {
+        const diagnostics = parseMypyTextOutput('pkg/a.py:3:5: error: Name "x" is not defined  [name-defined]\n', '/project');
+        expect(diagnostics[0]).toMatchObject({ line: 3, column: 5, message: 'Name "x" is not defined', rule: 'name-defined' });
+    });
+
+    it('should parse pyright JSON output with 1-based positions', () => {
+        const output = JSON.stringify({
+            version: '1.1.380',
+            generalDiagnostics: [
+                { file: '/project/a.py', severity: 'error', message: 'Cannot assign', range: { start: { line: 2, character: 4 }, end: { line: 2, character: 5 } }, rule: 'reportAssignmentType' },
+                { file: '/project/a.py', severity: 'warning', message: 'Import could not be resolved', range: { start: { line: 0, character: 7 }, end: { line: 0, character: 10 } } },
+            ],
+            summary: { errorCount: 1, warningCount: 1 },
+        });
+        const diagnostics = parsePyrightJsonOutput(output, '/project');
+        expect(diagnostics[0]).toMatchObject({ line: 3, column: 5, severity: 'error', rule: 'reportAssignmentType', source: 'pyright' });
+        expect(diagnostics[1]?.rule).toBeUndefined();
+        expect(countBySeverity(diagnostics)).toEqual({ error: 1, warning: 1, info: 0 });
+    });
+
+    it('should parse ruff JSON output with fixability', () => {
+        const output = JSON.stringify([
+            { code: 'F401', message: '`os` imported but unused', filename: '/project/a.py', location: { row: 1, column: 8 }, fix: { applicability: 'safe', message: 'Remove unused import' } },
+            { code: 'E501', message: 'Line too long (120 > 88)', filename: 'b.py', location: { row: 4, column: 89 }, fix: null },
+            { code: null, message: 'SyntaxError: Expected an expression', filename: 'c.py', location: { row: 2, column: 5 }, fix: null },
+        ]);
+        const diagnostics = parseRuffJsonOutput(output, '/project');
+        expect(diagnostics[0]).toMatchObject({ file: '/project/a.py', line: 1, column: 8, severity: 'warning', rule: 'F401', source: 'ruff', fixable: true });
+        expect(diagnostics[1]).toMatchObject({ file: '/project/b.py', rule: 'E501' });
+        expect(diagnostics[1]?.fixable).toBeUndefined();
+        expect(diagnostics[2]?.severity).toBe('error');
+        expect(parseRuffJsonOutput('[]', '/project')).toEqual([]);
+    });
+});
+
+describe('TypeScript diagnostics parser', () => {
+    it('should parse tsc errors with codes and continuation lines', () => {
+        const output = [
+            "src/a.ts(3,7): error TS2322: Type 'string' is not assignable to type 'number'.",
+            "src/b.ts(10,1): error TS2345: Argument of type '{ a: string; }' is not assignable to parameter of type 'Opts'.",
+            "  Property 'b' is missing in type '{ a: string; }' but required in type 'Opts'.",
+            'error TS5083: Cannot read file \'/project/base.json\'.',
+        ].join('\n');
+        const diagnostics = parseTscOutput(output, '/project');
+        expect(diagnostics).toHaveLength(3);
+        expect(diagnostics[0]).toMatchObject({ file: '/project/src/a.ts', line: 3, column: 7, severity: 'error', rule: 'TS2322', source: 'tsc' });
+        expect(diagnostics[1]?.message).toContain("Property 'b' is missing");
+        expect(diagnostics[2]).toMatchObject({ file: '', rule: 'TS5083' });
+    });
+
+    it('should parse eslint JSON output', () => {
+        const output = JSON.stringify([
+            {
+                filePath: '/project/src/a.js',
+                messages: [
+                    { ruleId: 'no-unused-vars', severity: 2, message: "'x' is assigned a value but never used.", line: 3, column: 7 },
+                    { ruleId: 'semi', severity: 1, message: 'Missing semicolon.', line: 4, column: 12, fix: { range: [40, 40], text: ';' } },
+                ],
+            },
+            { filePath: '/project/src/b.js', messages: [{ ruleId: null, fatal: true, severity: 2, message: 'Parsing error: Unexpected token', line: 1, column: 5 }] },
+            { filePath: '/project/src/c.js', messages: [] },
+        ]);
+        const diagnostics = parseEslintJsonOutput(output, '/project');
+        expect(diagnostics).toHaveLength(3);
+        expect(diagnostics[0]).toMatchObject({ file: '/project/src/a.js', line: 3, column: 7, severity: 'error', rule: 'no-unused-vars', source: 'eslint' });
+        expect(diagnostics[1]).toMatchObject({ severity: 'warning', rule: 'semi', fixable: true });
+        expect(diagnostics[2]?.rule).toBeUndefined();
+    });
+});
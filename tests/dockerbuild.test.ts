@@ -0,0 +1,116 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { findInstruction, parseBuildKitOutput, parseDockerfile, parseHadolintOutput } from '../src/diagnostics/index.js';
+import { dockerBuildTool } from '../src/tools/dockerbuild.js';
+import { commandExists } from '../src/utils/command.js';
+
+const DOCKERFILE = `# syntax=docker/dockerfile:1
+FROM golang:1.22 AS build
+WORKDIR /src
+COPY go.mod go.sum ./
+RUN go mod download
+COPY . .
+RUN CGO_ENABLED=0 \\
+    go build -o /app ./cmd/server
+
+FROM alpine:3.20
+RUN <<EOF
+apk add --no-cache ca-certificates
+EOF
+COPY --from=build /app /app
+ENTRYPOINT ["/app"]
+`;
+
+const BUILD_OUTPUT = `#0 building with "default" instance using docker driver
+
+#1 [internal] load build definition from Dockerfile
+#1 transferring dockerfile: 412B done
+#1 DONE 0.0s
+
+#4 [build 1/5] FROM docker.io/library/golang:1.22
+#4 DONE 0.0s
+
+#6 [build 3/5] RUN go mod download
+#6 CACHED
+
+#8 [build 5/5] RUN CGO_ENABLED=0     go build -o /app ./cmd/server
+#8 0.412 # example.com/app/cmd/server
+#8 0.412 cmd/server/main.go:10:2: undefined: handler
+#8 ERROR: process "/bin/sh -c CGO_ENABLED=0     go build -o /app ./cmd/server" did not complete successfully: exit code: 1
+------
+ > [build 5/5] RUN CGO_ENABLED=0     go build -o /app ./cmd/server:
+0.412 # example.com/app/cmd/server
+0.412 cmd/server/main.go:10:2: undefined: handler
+------
+
+ 1 warning found (use docker --debug to expand):
+ - FromAsCasing: 'as' and 'FROM' keywords' casing do not match (line 2)
+Dockerfile:7
+--------------------
+   6 |     COPY . .
+   7 | >>> RUN CGO_ENABLED=0 \\
+   8 | >>>     go build -o /app ./cmd/server
+--------------------
+ERROR: failed to solve: process "/bin/sh -c CGO_ENABLED=0     go build -o /app ./cmd/server" did not complete successfully: exit code: 1
+`;
+
+describe('docker_build', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-docker-build-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'Dockerfile'), DOCKERFILE);
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should parse Dockerfile instructions and BuildKit output', () => {
+        const instructions = parseDockerfile(DOCKERFILE);
+        expect(instructions.map(i => `${i.line}-${i.endLine} ${i.stage} ${i.keyword}`)).toEqual([
+            '2-2 build FROM', '3-3 build WORKDIR', '4-4 build COPY', '5-5 build RUN', '6-6 build COPY', '7-8 build RUN',
+            '10-10 stage-1 FROM', '11-13 stage-1 RUN', '14-14 stage-1 COPY', '15-15 stage-1 ENTRYPOINT',
+        ]);
+
+        const parsed = parseBuildKitOutput(BUILD_OUTPUT);
+        expect(parsed.steps.map(s => `${s.id} ${s.stage ?? '-'} ${s.cached} ${s.error ? 'error' : 'ok'}`)).toEqual([
+            '0 - false ok', '1 - false ok', '4 build false ok', '6 build true ok', '8 build false error',
+        ]);
+        const failed = parsed.steps.find(s => s.error)!;
+        expect(failed.output).toEqual(['# example.com/app/cmd/server', 'cmd/server/main.go:10:2: undefined: handler']);
+        expect(findInstruction(instructions, failed)?.line).toBe(7);
+        expect(parsed.failureFile).toBe('Dockerfile');
+        expect(parsed.failureLine).toBe(7);
+        expect(parsed.failure).toContain('did not complete successfully: exit code: 1');
+        expect(parsed.checks).toEqual([{ rule: 'FromAsCasing', message: '\'as\' and \'FROM\' keywords\' casing do not match', line: 2 }]);
+
+        const parseError = parseBuildKitOutput('ERROR: failed to solve: dockerfile parse error on line 3: unknown instruction: RUNN\n');
+        expect(parseError.failureLine).toBe(3);
+    });
+
+    it('should parse hadolint findings', () => {
+        const json = JSON.stringify([
+            { code: 'DL3006', column: 1, file: '-', level: 'warning', line: 10, message: 'Always tag the version of an image explicitly' },
+            { code: 'SC2086', column: 1, file: '-', level: 'info', line: 5, message: 'Double quote to prevent globbing and word splitting.' },
+        ]);
+        expect(parseHadolintOutput(json, '/p/Dockerfile')).toEqual([
+            { file: '/p/Dockerfile', line: 10, column: 1, severity: 'warning', message: 'Always tag the version of an image explicitly', rule: 'DL3006', source: 'hadolint' },
+            { file: '/p/Dockerfile', line: 5, column: 1, severity: 'info', message: 'Double quote to prevent globbing and word splitting.', rule: 'SC2086', source: 'hadolint' },
+        ]);
+        expect(parseHadolintOutput('not json', '/p/Dockerfile')).toEqual([]);
+    });
+
+    it('should report a missing Dockerfile and a missing docker', async () => {
+        const missing: any = await dockerBuildTool.run({ path: root, dockerfile: 'Dockerfile.prod' });
+        expect(missing.errors).toEqual([`No Dockerfile at ${join(root, 'Dockerfile.prod')}`]);
+        if (await commandExists('docker')) return;
+        const result: any = await dockerBuildTool.run({ path: root, lint: false });
+        expect(result.success).toBe(false);
+        expect(result.errors).toEqual(['docker is not available to build the image']);
+    });
+});
@@ -0,0 +1,127 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { checkGoDocs, parseMarkdown, slugify } from '../src/docs/index.js';
+import { checkDocsTool } from '../src/tools/docs.js';
+import { commandExists } from '../src/utils/command.js';
+
+const README = `# Project
+
+See [the guide](docs/guide.md#getting-started), [setup](docs/guide.md#set-up) and [below](#usage-notes).
+![logo](assets/logo.png) and [the docs](docs/) and [home](https://example.com/#x).
+
+## Usage notes
+
+Read [the reference][ref] and [the FAQ][faq].
+
+\`[not a link](missing.md)\`
+
+<!-- [hidden](gone.md) -->
+
+[ref]: docs/reference.md
+`;
+
+const GUIDE = `# Guide
+
+## Getting started
+
+\`\`\`sh
+npm install
+\`\`\`
+
+## Getting started
+
+Back to [the top](../README.md#project) and [the second one](#getting-started-1).
+
+\`\`\`
+unclosed
+`;
+
+describe('check_docs', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-docs-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.mkdir(join(root, 'docs'));
+        await fs.writeFile(join(root, 'README.md'), README);
+        await fs.writeFile(join(root, 'docs', 'guide.md'), GUIDE);
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should slug headings the way GitHub does', () => {
+        expect(slugify('Getting Started: the `fast` way!')).toBe('getting-started-the-fast-way');
+        expect(slugify('API / v2 [docs](x.md)')).toBe('api--v2-docs');
+        const doc = parseMarkdown('# Intro\n## Intro\n### Custom {#here}\n<a name="legacy"></a>\n');
+        expect([...doc.anchors]).toEqual(['intro', 'intro-1', 'here', 'custom', 'legacy']);
+    });
+
+    it('should find fence problems and skip links in code', () => {
+        const doc = parseMarkdown('```js\n[a](in-code.md)\n```go\n```\n\n    [b](indented.md)\n\n~~~\nx\n');
+        expect(doc.links).toEqual([]);
+        expect(doc.findings.map(f => `${f.line} ${f.severity} ${f.rule}`)).toEqual(['3 warning nested-fence', '8 info fence-language', '8 error unclosed-fence']);
+    });
+
+    it('should report broken links, dead anchors and undefined references', async () => {
+        const result: any = await checkDocsTool.run({ path: root });
+        expect(result.success).toBe(false);
+        expect(result.files).toBe(2);
+        expect(result.diagnostics.map((d: any) => `${d.file.slice(root.length + 1)}:${d.line}:${d.column} ${d.rule}`)).toEqual([
+            'README.md:8:31 undefined-reference',
+            'README.md:3:49 dead-anchor',
+            'README.md:4:1 broken-link',
+            'README.md:14:1 broken-link',
+            'docs/guide.md:13:1 fence-language',
+            'docs/guide.md:13:1 unclosed-fence',
+        ]);
+        expect(result.output).toContain('README.md:3:49: error: No heading or anchor #set-up in docs/guide.md');
+    });
+
+    it('should honor exclude', async () => {
+        const result: any = await checkDocsTool.run({ path: root, exclude: ['README.md'] });
+        expect(result.files).toBe(1);
+        expect(result.diagnostics.map((d: any) => d.rule)).toEqual(['fence-language', 'unclosed-fence']);
+    });
+
+    it('should check Go doc comments', async () => {
+        expect(checkGoDocs([
+            { kind: 'type', name: 'Store', package: 'store', doc: 'A Store keeps values.\n', file: 'a.go', line: 3, column: 6 },
+            { kind: 'method', name: 'Store.Get', package: 'store', doc: 'Returns a value.\n', file: 'a.go', line: 8, column: 1 },
+            { kind: 'func', name: 'Old', package: 'store', doc: 'Deprecated: use New.\n', file: 'a.go', line: 9, column: 1 },
+            { kind: 'package', name: 'store', package: 'store', doc: 'Store does things.\n', file: 'a.go', line: 1, column: 1 },
+        ]).map(d => `${d.line} ${d.rule}: ${d.message}`)).toEqual([
+            '8 doc-form: comment on exported method store.Store.Get should be of the form "Get ..."',
+            '1 doc-form: package comment should be of the form "Package store ..."',
+        ]);
+        if (!await commandExists('go')) return;
+
+        const dir = join(root, 'store');
+        await fs.mkdir(dir);
+        await fs.writeFile(join(dir, 'store.go'), [
+            'package store',
+            '',
+            '// Store keeps values.',
+            'type Store struct{}',
+            '',
+            'func (s *Store) Get() string { return "" }',
+            '',
+            'const (',
+            '\t// Limit is the maximum size.',
+            '\tLimit = 10',
+            '\tMode = 1',
+            ')',
+            '',
+            'func helper() {}',
+            '',
+        ].join('\n'));
+        const result: any = await checkDocsTool.run({ path: dir, goDocs: true });
+        expect(result.success).toBe(true);
+        expect(result.diagnostics.map((d: any) => `${d.line} ${d.rule}`)).toEqual(['6 missing-doc', '11 missing-doc', '1 missing-package-doc']);
+        expect(result.output).toContain('5 exported Go declaration(s) and package(s) checked, 3 undocumented');
+    });
+});
@@ -0,0 +1,96 @@
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { previewChange } from '../src/utils/preview.js';
+import { editor } from '../src/tools/editor.js';
+import { filesystem } from '../src/tools/filesystem.js';
+import { applyChangesTool, applyPatchTool } from '../src/tools/patch.js';
+import { scaffoldProjectTool } from '../src/tools/scaffold.js';
+
+describe('previewChange', () => {
+    it('should describe how a file would change', () => {
+        const grown = previewChange('/repo/a.txt', 'one\ntwo\n', 'one\nTWO\nthree\nfour\n');
+        expect(grown.action).toBe('modify');
+        expect(grown.summary).toBe('would grow by 2 lines (+3 -1)');
+        expect(grown.diff).toContain('+TWO');
+        expect(previewChange('/repo/a.txt', 'a\nb\nc\n', 'a\n').summary).toBe('would shrink by 2 lines (+0 -2)');
+        expect(previewChange('/repo/a.txt', 'a\n', 'a\n').summary).toBe('would be unchanged');
+        expect(previewChange('/repo/a.txt', null, 'a\nb\n').summary).toBe('would be created with 2 lines');
+        expect(previewChange('/repo/a.txt', 'a\n', null).summary).toBe('would be deleted (1 line)');
+        const binary = previewChange('/repo/a.bin', Buffer.from([0, 1]), Buffer.from([0, 1, 2]));
+        expect(binary.summary).toBe('would grow by 1 byte');
+        expect(binary.diff).toBeUndefined();
+    });
+});
+
+describe('Dry-run mode', () => {
+    let root: string;
+
+    beforeEach(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-dryrun-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'a.txt'), 'one\ntwo\nthree\n');
+    });
+
+    afterEach(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should preview editor edits, creates and deletes', async () => {
+        const file = join(root, 'a.txt');
+        const edit: any = await editor.run({ action: 'edit', file_path: file, edits: [{ oldText: 'two', newText: 'two\n2' }], dryRun: true });
+        expect(edit.success).toBe(true);
+        expect(edit.previews[0].summary).toBe('would grow by 1 line (+1 -0)');
+        expect(edit.output).toContain('+2');
+        const remove: any = await editor.run({ action: 'delete', file_path: file, dryRun: true });
+        expect(remove.previews[0].action).toBe('delete');
+        const create: any = await editor.run({ action: 'create', file_path: join(root, 'b.txt'), content: 'x\n', dryRun: true });
+        expect(create.previews[0].summary).toBe('would be created with 1 line');
+        expect(await fs.readFile(file, 'utf-8')).toBe('one\ntwo\nthree\n');
+        await expect(fs.access(join(root, 'b.txt'))).rejects.toThrow();
+    });
+
+    it('should preview filesystem operations', async () => {
+        const result: any = await filesystem.run({
+            dryRun: true,
+            ops: [
+                { type: 'move', source: join(root, 'a.txt'), destination: join(root, 'moved.txt') },
+                { type: 'createDirectory', path: join(root, 'lib') },
+                { type: 'delete', path: join(root, '*.txt') },
+            ],
+        });
+        expect(result.success).toBe(true);
+        expect(result.dryRun).toBe(true);
+        expect(result.results[0].previews[0]).toMatchObject({ action: 'move', from: join(root, 'a.txt') });
+        expect(result.results[1].message).toContain('directory would be created');
+        expect(result.results[2].previews.map((p: any) => p.path)).toEqual([join(root, 'a.txt')]);
+        expect(await fs.readdir(root)).toEqual(['a.txt']);
+    });
+
+    it('should validate a change set without writing or verifying', async () => {
+        const result: any = await applyChangesTool.run({
+            rootPath: root,
+            changes: [{ path: 'a.txt', edits: [{ oldText: 'two\n', newText: '' }] }, { path: 'new.txt', content: 'hi\n' }],
+            verifyCommand: 'false',
+            dryRun: true,
+        });
+        expect(result.success).toBe(true);
+        expect(result.previews.map((p: any) => p.summary)).toEqual(['would shrink by 1 line (+0 -1)', 'would be created with 1 line']);
+        expect(result.warnings).toContain('Dry run: false was not run');
+        expect(await fs.readdir(root)).toEqual(['a.txt']);
+
+        const patch: any = await applyPatchTool.run({ rootPath: root, patch: '--- a/a.txt\n+++ b/a.txt\n@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three\n', dryRun: true });
+        expect(patch.previews[0].summary).toBe('would keep 3 lines (+1 -1)');
+    });
+
+    it('should list the files a template would create', async () => {
+        const target = join(root, 'svc');
+        const result: any = await scaffoldProjectTool.run({ path: target, template: 'go', dryRun: true });
+        expect(result.success).toBe(true);
+        expect(result.files).toContain('go.mod');
+        expect(result.previews.every((p: any) => p.action === 'create')).toBe(true);
+        await expect(fs.access(target)).rejects.toThrow();
+    });
+});
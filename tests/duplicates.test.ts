@@ -0,0 +1,103 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { findClones, tokenize } from '../src/duplicates/index.js';
+import { findDuplicatesTool } from '../src/tools/duplicates.js';
+
+const ORDERS = `package shop
+
+// Total sums the order lines after discounts.
+func Total(lines []Line, discount float64) float64 {
+	total := 0.0
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			continue
+		}
+		price := line.Price * float64(line.Quantity)
+		total += price - price*discount
+	}
+	return total
+}
+`;
+
+// The same function with everything renamed
+const INVOICES = `package billing
+
+func Amount(items []Item, rebate float64) float64 {
+	sum := 0.0
+	for _, item := range items {
+		if item.Count <= 0 {
+			continue
+		}
+		cost := item.Cost * float64(item.Count)
+		sum += cost - cost*rebate
+	}
+	return sum
+}
+`;
+
+const file = (name: string, source: string, hashComments = false) => ({ file: name, tokens: tokenize(source, hashComments) });
+
+describe('find_duplicates', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-dupes-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.mkdir(join(root, 'shop'));
+        await fs.writeFile(join(root, 'shop', 'orders.go'), ORDERS);
+        await fs.writeFile(join(root, 'shop', 'orders_test.go'), `package shop_test\n\nimport "testing"\n${ORDERS.slice(ORDERS.indexOf('func'))}`);
+        await fs.writeFile(join(root, 'invoices.go'), INVOICES);
+        await fs.writeFile(join(root, 'zz_generated.go'), `// Code generated by stringer. DO NOT EDIT.\n\n${INVOICES}`);
+        await fs.writeFile(join(root, 'other.go'), 'package other\n\nfunc Hello() string { return "hi" }\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should tokenize without comments and normalize names and literals', () => {
+        expect(tokenize('x := f(1, "a") // note\n/* block\n */ y').map(t => `${t.kind}@${t.line}`))
+            .toEqual(['ID@1', ':@1', '=@1', 'ID@1', '(@1', 'LIT@1', ',@1', 'LIT@1', ')@1', 'ID@3']);
+        expect(tokenize('def f():\n    """doc\n    string"""\n    return 0  # done\n', true).map(t => t.kind))
+            .toEqual(['def', 'ID', '(', ')', ':', 'LIT', 'return', 'LIT']);
+        expect(tokenize('if it\'s # not a comment').map(t => t.kind)).toEqual(['if', 'ID', 'LIT']);
+    });
+
+    it('should match renamed copies and score verbatim ones as identical', () => {
+        const renamed = findClones([file('a.go', ORDERS), file('b.go', INVOICES)], { minTokens: 30, minLines: 5 });
+        expect(renamed).toHaveLength(1);
+        expect(renamed[0]!.fragments).toEqual([{ file: 'a.go', startLine: 1, endLine: 14 }, { file: 'b.go', startLine: 1, endLine: 13 }]);
+        expect(renamed[0]!.similarity).toBeGreaterThan(0.3);
+        expect(renamed[0]!.similarity).toBeLessThan(1);
+
+        const copies = findClones([file('a.go', ORDERS), file('b.go', ORDERS), file('c.go', `package c\n\nvar x = 1\n${ORDERS.slice(ORDERS.indexOf('func'))}`)], { minTokens: 30, minLines: 5 });
+        expect(copies).toHaveLength(1);
+        expect(copies[0]!.similarity).toBe(1);
+        // a.go and b.go match from the package clause, c.go only from the function
+        expect(copies[0]!.fragments.map(f => `${f.file}:${f.startLine}-${f.endLine}`)).toEqual(['a.go:1-14', 'b.go:1-14', 'c.go:4-14']);
+
+        expect(findClones([file('a.go', ORDERS), file('b.go', INVOICES)], { minTokens: 200, minLines: 5 })).toEqual([]);
+    });
+
+    it('should report duplicated blocks across a workspace with diagnostics', async () => {
+        const result: any = await findDuplicatesTool.run({ path: root, minTokens: 30 });
+        expect(result.success).toBe(true);
+        expect(result.totals).toMatchObject({ files: 3, blocks: 1, duplicatedLines: 27 });
+        expect(result.duplicates[0].fragments.map((f: any) => f.file)).toEqual([join(root, 'invoices.go'), join(root, 'shop', 'orders.go')]);
+        expect(result.warnings).toEqual(['1 generated or oversized file(s) skipped']);
+        expect(result.diagnostics).toHaveLength(2);
+        expect(result.diagnostics[0]).toMatchObject({ file: join(root, 'invoices.go'), line: 1, severity: 'warning', rule: 'duplicate-code', source: 'find_duplicates' });
+        expect(result.diagnostics[0].message).toContain('also at shop/orders.go:1-14');
+        expect(result.output).toMatch(/^1 duplicated block\(s\) in 2 file\(s\)/);
+
+        const verbatim: any = await findDuplicatesTool.run({ path: root, minTokens: 30, minSimilarity: 1, includeTests: true, exclude: ['invoices.go'] });
+        expect(verbatim.duplicates).toHaveLength(1);
+        expect(verbatim.duplicates[0].fragments.map((f: any) => f.file)).toEqual([join(root, 'shop', 'orders.go'), join(root, 'shop', 'orders_test.go')]);
+
+        const none: any = await findDuplicatesTool.run({ path: root, minTokens: 30, exclude: ['invoices.go'] });
+        expect(none.output).toBe('No duplicated blocks of 30+ tokens in 2 file(s)');
+    });
+});
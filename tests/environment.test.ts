@@ -0,0 +1,26 @@
+import { describe, it, expect } from 'vitest';
+import { inspectEnvironmentTool } from '../src/tools/environment.js';
+
+describe('Environment inspection', () => {
+    it('should report toolchains, go env and PATH entries', async () => {
+        const result: any = await inspectEnvironmentTool.run({ refresh: true, tools: ['definitely-not-installed-cf'] });
+        expect(result.success).toBe(true);
+        const go = result.toolchains.find((t: any) => t.name === 'go');
+        expect(go.found).toBe(true);
+        expect(go.version).toMatch(/^\d+\.\d+/);
+        expect(result.go.GOOS).toBeDefined();
+        expect(result.go.GOARCH).toBeDefined();
+        expect(result.extra).toEqual([{ name: 'definitely-not-installed-cf', kind: 'extra', found: false }]);
+        expect(result.path.length).toBeGreaterThan(0);
+        expect(result.output).toContain('definitely-not-installed-cf: not found');
+    });
+
+    it('should serve the cached report until refreshed', async () => {
+        const first: any = await inspectEnvironmentTool.run({});
+        const second: any = await inspectEnvironmentTool.run({});
+        expect(second.cached).toBe(true);
+        expect(second.inspectedAt).toBe(first.inspectedAt);
+        const refreshed: any = await inspectEnvironmentTool.run({ refresh: true });
+        expect(refreshed.cached).toBe(false);
+    });
+});
@@ -0,0 +1,43 @@
+import { describe, it, expect } from 'vitest';
+import { detectNetworkSandbox } from '../src/executor/sandbox.js';
+import { evalSnippetTool, wrapGoSnippet } from '../src/tools/eval.js';
+import { commandExists } from '../src/utils/command.js';
+
+describe('eval_snippet', () => {
+    it('should wrap Go statements in a main function', () => {
+        expect(wrapGoSnippet('import "fmt"\n\nfmt.Println(1)')).toBe('package main\n\nimport "fmt"\n\nfunc main() {\nfmt.Println(1)\n}\n');
+        expect(wrapGoSnippet('import (\n\t"fmt"\n\t"strings"\n)\nfmt.Println(strings.ToUpper("a"))')).toContain('import (\n\t"fmt"\n\t"strings"\n)\n\nfunc main() {\nfmt.Println');
+        expect(wrapGoSnippet('func main() {}')).toBe('package main\n\nfunc main() {}\n');
+        expect(wrapGoSnippet('package main\nfunc main() {}')).toBe('package main\nfunc main() {}');
+    });
+
+    it('should run Node and Python snippets with stdin and report failures', async () => {
+        if (!(await detectNetworkSandbox())) return;
+        const node: any = await evalSnippetTool.run({ language: 'node', code: 'let s = "";\nfor await (const c of process.stdin) s += c;\nconsole.log(s.toUpperCase());', stdin: 'abc' });
+        expect(node).toMatchObject({ success: true, stdout: 'ABC\n', exitCode: 0, runner: 'node' });
+        if (await commandExists('python3')) {
+            const python: any = await evalSnippetTool.run({ language: 'python', code: 'import sys\nprint("x", file=sys.stderr)\nsys.exit(3)' });
+            expect(python).toMatchObject({ success: false, exitCode: 3, errors: ['Exited with code 3'] });
+            expect(python.stderr).toContain('x');
+        }
+    });
+
+    it('should run without network and within the timeout', async () => {
+        if (!(await detectNetworkSandbox())) return;
+        const offline: any = await evalSnippetTool.run({
+            language: 'node',
+            code: "const net = require('net');\nconst s = net.connect(80, '1.1.1.1');\ns.on('error', e => { console.log(e.code); process.exit(0); });\ns.on('connect', () => { console.log('connected'); process.exit(0); });",
+        });
+        expect(offline.stdout).not.toContain('connected');
+        const slow: any = await evalSnippetTool.run({ language: 'node', code: 'setTimeout(() => {}, 60000);', timeout: 500 });
+        expect(slow.success).toBe(false);
+        expect(slow.errors).toEqual(['Timed out after 500 ms']);
+    });
+
+    it('should compile and run Go statements', async () => {
+        if (!(await detectNetworkSandbox()) || !(await commandExists('go'))) return;
+        const result: any = await evalSnippetTool.run({ language: 'go', code: 'import "fmt"\n\nfmt.Println(min(3, 1, 2))', timeout: 60000 });
+        expect(result.stdout).toBe('1\n');
+        expect(result.success).toBe(true);
+    });
+});
@@ -0,0 +1,94 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { extractCodeBlocks } from '../src/docs/index.js';
+import { checkExamplesTool } from '../src/tools/examples.js';
+import { commandExists } from '../src/utils/command.js';
+
+const README = [
+    '# greet',                                   // 1
+    '',
+    '```go',
+    'import "example.com/greet"',
+    '',
+    'fmt.Println(greet.Hello("world"))',        // 6
+    '```',
+    '',
+    '```go',
+    'func Broken() int {',                       // 10
+    '\treturn "nope"',                           // 11
+    '}',
+    '```',
+    '',
+    '```go',
+    'panic("boom")',                             // 16
+    '```',
+    '',
+    '<!-- check_examples: ignore -->',
+    '```go',
+    'this is not Go',                            // 21
+    '```',
+    '',
+    '```python',
+    'import math',                               // 25
+    'print(math.sqrt(4))',
+    '```',
+    '',
+    '```pycon',
+    '>>> x = [1, 2',                             // 30
+    '>>> print(x)',
+    '[1, 2]',
+    '```',
+    '',
+].join('\n');
+
+describe('check_examples', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-examples-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/greet\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'greet.go'), 'package greet\n\n// Hello greets name.\nfunc Hello(name string) string { return "hello " + name }\n');
+        await fs.writeFile(join(root, 'README.md'), README);
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should extract fenced code blocks with their attributes', () => {
+        const blocks = extractCodeBlocks('text\n  ```Go norun\n  x := 1\n  ```\n~~~{.python}\nprint(1)\n');
+        expect(blocks).toEqual([
+            { language: 'go', attributes: ['norun'], code: 'x := 1', line: 3 },
+            { language: 'python', attributes: [], code: 'print(1)\n', line: 6 },
+        ]);
+    });
+
+    it('should report examples that do not compile on their Markdown lines', async () => {
+        if (!await commandExists('go') || !await commandExists('python3')) return;
+        const result: any = await checkExamplesTool.run({ path: root });
+        expect(result.success).toBe(false);
+        expect(result.snippets.map((s: any) => `${s.line} ${s.language} ${s.status}`)).toEqual([
+            '3 go ok',
+            '9 go compile-error',
+            '15 go ok',
+            '20 go skipped',
+            '24 python ok',
+            '29 python compile-error',
+        ]);
+        expect(result.diagnostics.map((d: any) => `${d.line} ${d.rule}`)).toEqual(['11 compile-error', '30 compile-error']);
+        expect(result.diagnostics[0].message).toContain('Go example does not compile:');
+        expect(result.output).toContain('6 example(s) in 1 Markdown file(s): 3 ok, 2 broken, 1 skipped');
+    });
+
+    it('should run programs when asked', async () => {
+        if (!await commandExists('go') || !await commandExists('python3')) return;
+        const result: any = await checkExamplesTool.run({ path: join(root, 'README.md'), run: true, languages: ['go'] });
+        expect(result.snippets.map((s: any) => s.status)).toEqual(['ok', 'compile-error', 'runtime-error', 'skipped']);
+        expect(result.diagnostics[1]).toMatchObject({ line: 16, rule: 'runtime-error', severity: 'error' });
+        expect(result.diagnostics[1].message).toContain('panic: boom');
+    });
+});
@@ -0,0 +1,62 @@
+import { describe, it, expect } from 'vitest';
+import { ExecutionRegistry, executionRegistry } from '../src/executions/index.js';
+import { cancelExecutionTool } from '../src/tools/cancel.js';
+import { runCommand, withCommandDefaults, withStreamHandler } from '../src/utils/command.js';
+
+describe('Executions', () => {
+    it('should track calls and collect their output', async () => {
+        const registry = new ExecutionRegistry();
+        const first = registry.start('req-1', 'go_test', 'ci');
+        registry.start('req-2', 'go_build', 'laptop');
+        expect(registry.list().map(e => e.id)).toEqual(['req-1', 'req-2']);
+        expect(registry.list('ci').map(e => e.tool)).toEqual(['go_test']);
+
+        const forwarded: string[] = [];
+        await withStreamHandler(first.streamHandler(chunk => forwarded.push(chunk)), () => runCommand('echo one; echo two >&2', { local: true }));
+        expect(first.partialOutput).toContain('one\n');
+        expect(first.partialOutput).toContain('two\n');
+        expect(forwarded.join('')).toBe(first.partialOutput);
+
+        expect(first.cancel('Cancelled: superseded')).toBe(true);
+        expect(first.cancel('again')).toBe(false);
+        expect(first.cancelReason).toBe('Cancelled: superseded');
+        expect(first.signal.aborted).toBe(true);
+        registry.finish(first);
+        expect(registry.get('req-1')).toBeUndefined();
+    });
+
+    it('should cancel a running call through cancel_execution', async () => {
+        const target = executionRegistry.start('req-long', 'go_test');
+        const running = target.run(() => withCommandDefaults({ signal: target.signal }, () => runCommand('echo begun; sleep 30', { timeout: 60000, local: true })));
+
+        const listed: any = await cancelExecutionTool.run({});
+        expect(listed.executions.map((e: any) => e.id)).toContain('req-long');
+
+        const caller = executionRegistry.start('req-cancel', 'cancel_execution');
+        const self: any = await caller.run(() => cancelExecutionTool.run({ executionId: 'req-cancel' }));
+        expect(self.errors).toEqual(['A call cannot cancel itself']);
+        await new Promise(resolve => setTimeout(resolve, 200));
+        const result: any = await caller.run(() => cancelExecutionTool.run({ executionId: 'req-long', reason: 'taking too long' }));
+        expect(result.success).toBe(true);
+        expect(result.output).toBe('Cancelled go_test call req-long');
+
+        const command = await running;
+        expect(command.cancelled).toBe(true);
+        expect(command.stdout).toBe('begun\n');
+        expect(target.cancelReason).toBe('Cancelled: taking too long');
+        executionRegistry.finish(target);
+        executionRegistry.finish(caller);
+        expect((await cancelExecutionTool.run({ executionId: 'req-long' })).errors).toEqual(['No tool call req-long is in flight']);
+    });
+
+    it('should only let an HTTP client cancel its own calls', async () => {
+        const other = executionRegistry.start('req-other', 'go_test', 'alice');
+        const caller = executionRegistry.start('req-bob', 'cancel_execution', 'bob');
+        const result: any = await caller.run(() => cancelExecutionTool.run({ executionId: 'req-other' }));
+        expect(result.errors).toEqual(['No tool call req-other is in flight']);
+        expect(other.cancelled).toBe(false);
+        expect((await caller.run(() => cancelExecutionTool.run({}))).output).toBe('No tool calls in flight');
+        executionRegistry.finish(other);
+        executionRegistry.finish(caller);
+    });
+});
@@ -0,0 +1,153 @@
+import { describe, it, expect, beforeAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { buildDockerCommand } from '../src/executor/docker.js';
+import { buildSshCommand, resolveSshTarget, toLocalPaths, toRemotePath } from '../src/executor/ssh.js';
+import { shellQuote } from '../src/utils/shell.js';
+import { runCommand, withCommandDefaults } from '../src/utils/command.js';
+import { buildLimitedCommand, detectLimitExceeded, tightestLimits } from '../src/executor/limits.js';
+import { mergeConfigs } from '../src/config/project.js';
+
+describe('Docker executor', () => {
+    beforeAll(() => {
+        Config.getInstance().addAllowedPaths(['/work']);
+        Config.getInstance().addReadOnlyPaths(['/work/vendor']);
+        Config.getInstance().setDockerImages({ go: 'golang:1.22' });
+    });
+
+    it('should quote shell words', () => {
+        expect(shellQuote(`it's`)).toBe(`'it'\\''s'`);
+    });
+
+    it('should mount allowed roots and pick the image by binary', () => {
+        const command = buildDockerCommand('go build ./...', { cwd: '/work/service', env: { CGO_ENABLED: '0' } });
+        expect(command).toContain(`-v '/work:/work'`);
+        expect(command).toContain(`-v '/work/vendor:/work/vendor:ro'`);
+        expect(command).toContain(`-w '/work/service'`);
+        expect(command).toContain(`-e 'CGO_ENABLED=0'`);
+        expect(command).toContain(`'golang:1.22' sh -c 'go build ./...'`);
+    });
+
+    it('should honor an explicit image override', () => {
+        const command = buildDockerCommand('go test ./...', { cwd: '/work', env: {}, image: 'golang:1.21' });
+        expect(command).toContain(`'golang:1.21' sh -c`);
+    });
+});
+
+describe('SSH executor', () => {
+    const remotes = { builder: { host: 'build-1.internal', user: 'ci', port: 2222, remoteRoot: '/srv/work/app', exclude: ['node_modules'] } };
+
+    it('should resolve the remote a workspace selects', () => {
+        const target = resolveSshTarget({ executor: 'builder', remotes }, '/home/me/app');
+        expect(target).toMatchObject({ name: 'builder', host: 'build-1.internal', localRoot: '/home/me/app', remoteRoot: '/srv/work/app' });
+        expect(toRemotePath('/home/me/app/cmd/server', target!)).toBe('/srv/work/app/cmd/server');
+        expect(toLocalPaths('/srv/work/app/main.go:3:1: undefined: x', target!)).toBe('/home/me/app/main.go:3:1: undefined: x');
+        expect(resolveSshTarget({ executor: 'docker', remotes }, '/home/me/app')).toBeNull();
+        expect(() => resolveSshTarget({ executor: 'elsewhere', remotes }, '/home/me/app')).toThrow('Executor "elsewhere" is not local, docker or one of the remotes');
+    });
+
+    it('should never let a workspace move its commands onto the host', () => {
+        expect(mergeConfigs({ executor: 'docker', remotes }, { executor: 'local' }).executor).toBe('docker');
+        expect(mergeConfigs({ remotes }, { executor: 'local' }).executor).toBeUndefined();
+        expect(mergeConfigs({ executor: 'docker', remotes }, { executor: 'builder' }).executor).toBe('builder');
+        expect(mergeConfigs({ executor: 'builder', remotes }, { executor: 'docker' }).executor).toBe('docker');
+        expect(mergeConfigs({ executor: 'docker' }, { executor: 'elsewhere' }).executor).toBe('docker');
+    });
+
+    it('should sync, run in the matching directory and copy changes back', () => {
+        const target = resolveSshTarget({ executor: 'builder', remotes }, '/home/me/app')!;
+        const command = buildSshCommand('go test ./...', { cwd: '/home/me/app/pkg', env: { CGO_ENABLED: '0' }, target, limits: { cpuSeconds: 60 } });
+        expect(command).toContain(`rsync -az --delete --rsync-path='mkdir -p '\\''/srv/work/app'\\'' && rsync'`);
+        expect(command).toContain(`--exclude='node_modules' '/home/me/app/' 'ci@build-1.internal:/srv/work/app/'`);
+        expect(command).toContain('-p 2222');
+        expect(command).toContain('export CGO_ENABLED=');
+        expect(command).toContain('ulimit -S -t 60 && go test ./...');
+        expect(command).toContain(`rsync -az --update`);
+        expect(command.endsWith('exit $__cf_status')).toBe(true);
+        expect(buildSshCommand('true', { cwd: '/home/me/app', env: {}, target: { ...target, syncBack: false } })).not.toContain('--update');
+    });
+
+    it('should run commands on the remote and report local paths', async () => {
+        const root = await fs.mkdtemp(join(tmpdir(), 'cf-ssh-'));
+        const [bin, local, remote] = [join(root, 'bin'), join(root, 'local'), join(root, 'remote')];
+        await Promise.all([fs.mkdir(bin), fs.mkdir(local)]);
+        await fs.writeFile(join(local, 'a.txt'), 'hello\n');
+        // Stand-ins that "connect" to this machine: ssh drops its options and host, rsync copies between the two directories
+        await fs.writeFile(join(bin, 'ssh'), '#!/bin/sh\nwhile [ $# -gt 0 ]; do case "$1" in -o|-p|-i|-P) shift 2;; -*) shift;; *) break;; esac; done\nshift\nexec sh -c "$*"\n', { mode: 0o755 });
+        await fs.writeFile(join(bin, 'rsync'), '#!/bin/sh\nfor a; do src=$dst; dst=$a; done\nsrc=${src#*:}; dst=${dst#*:}\nmkdir -p "$dst" && cp -R "$src". "$dst"\n', { mode: 0o755 });
+        const target = resolveSshTarget({ executor: 'builder', remotes: { builder: { host: 'localhost', remoteRoot: remote } } }, local)!;
+
+        const env = { PATH: `${bin}:${process.env.PATH}`, GREETING: 'hi' };
+        const result = await withCommandDefaults({ remote: target, env }, () => runCommand('cat a.txt; echo $GREETING; pwd; echo made > b.txt', { cwd: local }));
+        expect(result.exitCode).toBe(0);
+        expect(result.stdout).toBe(`hello\nhi\n${local}\n`);
+        expect(await fs.readFile(join(local, 'b.txt'), 'utf8')).toBe('made\n');
+
+        // Outside the synced workspace, commands stay on the host
+        const outside = await withCommandDefaults({ remote: target, env }, () => runCommand('pwd', { cwd: root }));
+        expect(outside.stdout.trim()).toBe(root);
+        await fs.rm(root, { recursive: true, force: true });
+    });
+});
+
+describe('Resource limits', () => {
+    it('should wrap commands with soft ulimits', () => {
+        expect(buildLimitedCommand('go test ./...', { memoryMb: 512, cpuSeconds: 30 })).toBe('ulimit -S -t 30 && ulimit -S -v 524288 && go test ./...');
+    });
+
+    it('should never let a workspace lift the server\'s caps', () => {
+        expect(tightestLimits({ memoryMb: 2048, cpuSeconds: 600 }, { memoryMb: 65536, cpuSeconds: 60 })).toEqual({ memoryMb: 2048, cpuSeconds: 60 });
+        expect(tightestLimits({}, { memoryMb: 512 }, undefined)).toEqual({ memoryMb: 512 });
+        expect(mergeConfigs({ limits: { cpuSeconds: 300 } }, { limits: { cpuSeconds: 3600, memoryMb: 1024 } }).limits).toEqual({ cpuSeconds: 300, memoryMb: 1024 });
+    });
+
+    it('should run in a systemd scope for the cgroup strategy', () => {
+        const command = buildLimitedCommand('npm test', { memoryMb: 1024 }, 'cgroup');
+        expect(command).toContain('systemd-run --user --scope');
+        expect(command).toContain('-p MemoryMax=1024M');
+        expect(command).toContain(`sh -c 'npm test'`);
+    });
+
+    it('should pass limits to docker', () => {
+        const command = buildDockerCommand('go test ./...', { cwd: '/work', env: {}, limits: { memoryMb: 256, cpuSeconds: 10 } });
+        expect(command).toContain('--memory 256m --memory-swap 256m');
+        expect(command).toContain('--ulimit cpu=10:15');
+    });
+
+    it('should tell which limit ended a command', () => {
+        const limits = { memoryMb: 256, cpuSeconds: 10 };
+        expect(detectLimitExceeded({ exitCode: 1, stderr: '', timedOut: true }, undefined)).toBe('timeout');
+        expect(detectLimitExceeded({ exitCode: 152, stderr: 'CPU time limit exceeded' }, limits)).toBe('cpu');
+        expect(detectLimitExceeded({ exitCode: 2, stderr: 'fatal error: runtime: out of memory' }, limits)).toBe('memory');
+        expect(detectLimitExceeded({ exitCode: 137, stderr: '' }, limits, 'cgroup')).toBe('memory');
+        expect(detectLimitExceeded({ exitCode: 1, stderr: 'FAIL' }, limits)).toBeNull();
+    });
+
+    it('should kill the whole process tree on timeout', async () => {
+        const started = Date.now();
+        const result = await runCommand('sleep 30 & sleep 30', { timeout: 300, local: true });
+        expect(result.limitExceeded).toBe('timeout');
+        expect(result.exitCode).not.toBe(0);
+        expect(Date.now() - started).toBeLessThan(5000);
+    });
+
+    it('should kill the process tree on cancellation and keep the partial output', async () => {
+        const controller = new AbortController();
+        const started = Date.now();
+        const running = runCommand('echo started; sleep 30 & sleep 30', { timeout: 60000, local: true, signal: controller.signal });
+        await new Promise(resolve => setTimeout(resolve, 300));
+        controller.abort();
+        const result = await running;
+        expect(result.cancelled).toBe(true);
+        expect(result.exitCode).toBe(130);
+        expect(result.stdout).toBe('started\n');
+        expect(result.limitExceeded).toBeUndefined();
+        expect(Date.now() - started).toBeLessThan(5000);
+
+        // Commands a cancelled call would run next never start
+        const skipped = await withCommandDefaults({ signal: controller.signal }, () => runCommand('echo never', { local: true }));
+        expect(skipped).toMatchObject({ stdout: '', exitCode: 130, cancelled: true });
+    });
+});
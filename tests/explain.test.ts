@@ -0,0 +1,123 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { auditLog } from '../src/audit/index.js';
+import { snapshotStore } from '../src/snapshots/index.js';
+import { historyStore } from '../src/history/index.js';
+import { editor } from '../src/tools/editor.js';
+import { changedLines, explainFailureTool, failureSymbols, lineMapper } from '../src/tools/explain.js';
+import { checkPermission } from '../src/permissions/index.js';
+
+const AUTH = 'package api\n\nfunc parseToken(raw string) (string, error) {\n\treturn raw, nil\n}\n';
+const HANDLER = [
+    'package api',
+    '',
+    'func handle(raw string) string {',
+    '\tuser, _ := parseToken(raw)',
+    '\treturn user',
+    '}',
+    '',
+    'func status() int {',
+    '\treturn 200',
+    '}',
+    '',
+].join('\n');
+
+describe('Failure explanation', () => {
+    let root: string;
+    let project: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-explain-'));
+        process.env.MCP_AUDIT_LOG = join(root, 'state', 'audit.jsonl');
+        process.env.MCP_SNAPSHOTS_DIR = join(root, 'state', 'snapshots');
+        process.env.MCP_RESULTS_DB = join(root, 'state', 'results.db');
+        historyStore.reset();
+        project = join(root, 'api');
+        await fs.mkdir(project);
+        await fs.writeFile(join(project, 'auth.go'), AUTH);
+        await fs.writeFile(join(project, 'handler.go'), HANDLER);
+        Config.getInstance().addAllowedPaths([project]);
+    });
+
+    afterAll(async () => {
+        delete process.env.MCP_AUDIT_LOG;
+        delete process.env.MCP_SNAPSHOTS_DIR;
+        delete process.env.MCP_RESULTS_DB;
+        historyStore.reset();
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    // What the server does around a mutating call: audit it and snapshot what it changes
+    const edit = async (args: any) => {
+        const record = auditLog.start(editor.name, args);
+        const result = await record.run(() => snapshotStore.capture({ tool: editor.name }, () => editor.run(args)));
+        await record.finish('success', { mutating: true });
+        return result;
+    };
+
+    it('should follow lines through later changes and find the symbols a failure names', () => {
+        const map = lineMapper('a\nb\nc\nd\n', 'a\nx\ny\nc\nd\n');
+        expect([1, 2, 3, 4].map(map)).toEqual([1, null, 4, 5]);
+        expect(lineMapper('a\nb\n', 'z\na\nb\n')(2)).toBe(3);
+        expect(changedLines('a\nb\nc\n', 'a\nB\nc\nd\n')).toMatchObject({ lines: [2, 4], removed: 'b', added: 'B\nd' });
+        expect(failureSymbols('undefined: auth.parseToken')).toEqual(['parseToken']);
+        expect(failureSymbols("error TS2304: Cannot find name 'loadUser'.")).toEqual(['loadUser']);
+        expect(failureSymbols('ReferenceError: renderPage is not defined')).toEqual(['renderPage']);
+        expect(failureSymbols('expected 1, got 2')).toEqual([]);
+    });
+
+    it('should trace failures to the edits that caused them', async () => {
+        await historyStore.record(project, { pipeline: 'default', passed: true, summary: 'PASS', diagnostics: [] });
+        await new Promise(done => setTimeout(done, 5));
+        await edit({ action: 'edit', file_path: join(project, 'auth.go'), edits: [{ oldText: 'func parseToken(', newText: 'func parseJWT(' }] });
+        await edit({ action: 'edit', file_path: join(project, 'handler.go'), edits: [{ oldText: '\treturn 200\n', newText: '\tif true {\n\t\treturn 200\n\t}\n' }] });
+        await historyStore.record(project, {
+            pipeline: 'default',
+            passed: false,
+            summary: 'FAIL',
+            diagnostics: [
+                { file: join(project, 'handler.go'), line: 4, column: 13, severity: 'error', message: 'undefined: parseToken', source: 'go build' },
+                { file: join(project, 'handler.go'), line: 12, column: 1, severity: 'error', message: 'missing return', source: 'go build' },
+            ],
+        });
+
+        const result: any = await explainFailureTool.run({ path: project });
+        expect(result.success).toBe(true);
+        expect(result.edits).toHaveLength(2);
+        const [renamed, missing] = result.failures;
+        expect(renamed.causes.map((c: any) => [c.reason, c.file])).toEqual([['symbol', join(project, 'auth.go')], ['file', join(project, 'handler.go')]]);
+        expect(renamed.causes[0].hunk).toContain('-func parseToken(raw string) (string, error) {');
+        expect(renamed.causes[0].snapshot).toBeDefined();
+        expect(missing.causes[0]).toMatchObject({ reason: 'line', file: join(project, 'handler.go') });
+        expect(result.suspects.map((s: any) => s.explains)).toEqual([1, 1]);
+        expect(result.output).toContain('undefined: parseToken');
+        expect(result.output).toContain('removed or renamed parseToken');
+        expect(result.output).toMatch(/Most likely cause: editor at .*revert_to_snapshot/);
+
+        const auth = result.edits.find((e: any) => e.files[0].path === join(project, 'auth.go'));
+        expect(auth.files[0].diff).toContain('+func parseJWT(raw string) (string, error) {');
+    });
+
+    it('should explain given diagnostics and say when nothing is failing', async () => {
+        // Lines move when the file changes after the edit
+        await fs.writeFile(join(project, 'handler.go'), `// Package api serves requests\n${await fs.readFile(join(project, 'handler.go'), 'utf-8')}`);
+        const shifted: any = await explainFailureTool.run({ path: project, diagnostics: [{ file: 'handler.go', line: 13, message: 'missing return' }] });
+        expect(shifted.failures[0].causes[0].reason).toBe('line');
+        expect(shifted.warnings).toContain('Changed outside tool calls since their last recorded edit: handler.go');
+
+        const unrelated: any = await explainFailureTool.run({ path: project, diagnostics: [{ file: join(root, 'other', 'main.go'), line: 1, message: 'expected 1, got 2' }] });
+        expect(unrelated.failures[0].causes).toEqual([]);
+        expect(unrelated.output).toContain('not explained by a recorded edit');
+
+        expect((await explainFailureTool.run({ path: project, diagnostics: [] })).output).toBe('Nothing is failing');
+        const both: any = await explainFailureTool.run({ path: project, diagnostics: [], run: 'x' });
+        expect(both.errors[0]).toContain('Pass at most one of diagnostics, pipeline or run');
+
+        // Running a pipeline is a mutating call; explaining recorded results is not
+        expect(checkPermission({}, 'reviewer', explainFailureTool, { path: project })).toBe(null);
+        expect(checkPermission({}, 'reviewer', explainFailureTool, { path: project, pipeline: 'default' })).toContain('may not make mutating calls');
+    });
+});
@@ -0,0 +1,93 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { describeRead, isBinaryContent, detectBinaryKind, readFileWindow } from '../src/utils/fileread.js';
+import { editor } from '../src/tools/editor.js';
+import { filesystem } from '../src/tools/filesystem.js';
+
+describe('File reads', () => {
+    let root: string;
+    let big: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-fileread-'));
+        Config.getInstance().addAllowedPaths([root]);
+        big = join(root, 'big.txt');
+        await fs.writeFile(big, Array.from({ length: 1000 }, (_, i) => `line ${i + 1}`).join('\n') + '\n');
+        await fs.writeFile(join(root, 'small.txt'), 'one\ntwo\nthree\n');
+        await fs.writeFile(join(root, 'image.png'), Buffer.from([0x89, 0x50, 0x4e, 0x47, 0x0d, 0x0a, 0x1a, 0x0a, 0, 0, 0, 13]));
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should return small files whole', async () => {
+        const read = await readFileWindow(join(root, 'small.txt'));
+        expect(read).toEqual({ content: 'one\ntwo\nthree\n', binary: false, totalBytes: 14, returnedBytes: 14, truncated: false, totalLines: 3, startLine: 1, endLine: 3 });
+        expect(describeRead('small.txt', read)).toBe('Read file small.txt');
+    });
+
+    it('should read line ranges, heads and tails', async () => {
+        const range = await readFileWindow(big, { startLine: 10, endLine: 12 });
+        expect(range.content).toBe('line 10\nline 11\nline 12');
+        expect(range).toMatchObject({ totalLines: 1000, startLine: 10, endLine: 12, truncated: false });
+        expect(describeRead('big.txt', range)).toBe('Read file big.txt (lines 10-12 of 1000)');
+
+        expect((await readFileWindow(big, { head: 2 })).content).toBe('line 1\nline 2');
+        const tail = await readFileWindow(big, { tail: 2 });
+        expect(tail.content).toBe('line 999\nline 1000');
+        expect(tail).toMatchObject({ startLine: 999, endLine: 1000 });
+        expect((await readFileWindow(big, { startLine: 998, endLine: 5000 })).endLine).toBe(1000);
+
+        await expect(readFileWindow(big, { startLine: 2000 })).rejects.toThrow('past the end of the file (1000 lines)');
+        await expect(readFileWindow(big, { head: 2, tail: 2 })).rejects.toThrow('only one of');
+    });
+
+    it('should cut reads at maxBytes on a line boundary', async () => {
+        const read = await readFileWindow(big, { maxBytes: 20 });
+        expect(read.content).toBe('line 1\nline 2\nline 3');
+        expect(read).toMatchObject({ truncated: true, startLine: 1, endLine: 3, nextStartLine: 4, totalLines: 1000 });
+        expect(describeRead('big.txt', read)).toContain('continue with startLine 4');
+
+        const tail = await readFileWindow(big, { tail: 100, maxBytes: 20 });
+        expect(tail.content).toBe('line 999\nline 1000');
+        expect(tail.truncated).toBe(true);
+
+        await fs.writeFile(join(root, 'minified.js'), 'x'.repeat(5000));
+        const long = await readFileWindow(join(root, 'minified.js'), { maxBytes: 100 });
+        expect(long.content).toHaveLength(100);
+        expect(long).toMatchObject({ truncated: true, endLine: 1 });
+        expect(long.nextStartLine).toBeUndefined();
+    });
+
+    it('should summarize binary files', async () => {
+        expect(isBinaryContent(Buffer.from('plain text\twith tabs\r\n'))).toBe(false);
+        expect(isBinaryContent(Buffer.from([0x41, 0, 0x42]))).toBe(true);
+        expect(detectBinaryKind(Buffer.from([0x7f, 0x45, 0x4c, 0x46, 2]))).toBe('ELF executable');
+
+        const read = await readFileWindow(join(root, 'image.png'));
+        expect(read.binary).toBe(true);
+        expect(read.kind).toBe('PNG image');
+        expect(read.content).toMatch(/^Binary file \(PNG image, 12 B, sha256 [0-9a-f]{64}\); content not shown$/);
+    });
+
+    it('should expose windows in the filesystem and editor tools', async () => {
+        const result: any = await filesystem.run({ ops: [{ type: 'readFile', path: big, startLine: 5, endLine: 6 }] });
+        expect(result.success).toBe(true);
+        expect(result.results[0].output).toBe('line 5\nline 6');
+        expect(result.results[0].file).toMatchObject({ totalLines: 1000, startLine: 5, endLine: 6 });
+
+        const raw: any = await filesystem.run({ ops: [{ type: 'readFile', path: join(root, 'image.png'), encoding: 'base64', maxBytes: 4 }] });
+        expect(raw.results[0].output).toBe(Buffer.from([0x89, 0x50, 0x4e, 0x47]).toString('base64'));
+        expect(raw.results[0].file).toMatchObject({ truncated: true, totalBytes: 12, returnedBytes: 4 });
+
+        const read: any = await editor.run({ action: 'read', file_path: big, tail: 1, maxBytes: 1024 });
+        expect(read.output).toBe('line 1000');
+        const binary: any = await editor.run({ action: 'read', file_path: join(root, 'image.png') });
+        expect(binary.success).toBe(true);
+        expect(binary.warnings[0]).toContain('PNG image');
+    });
+});
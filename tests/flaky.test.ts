@@ -0,0 +1,31 @@
+import { describe, it, expect } from 'vitest';
+import { type TestCaseResult } from '../src/diagnostics/index.js';
+import { aggregateRuns } from '../src/tools/flaky.js';
+
+const run = (statuses: Record<string, TestCaseResult['status']>): TestCaseResult[] =>
+    Object.entries(statuses).map(([name, status]) => ({
+        suite: 'pkg',
+        name,
+        status,
+        durationMs: 1,
+        ...(status === 'failed' ? { message: `${name} broke`, file: '/work/a_test.go', line: 3 } : {}),
+    }));
+
+describe('Flaky test detection', () => {
+    it('should tell flaky tests from consistently failing ones', () => {
+        const stats = aggregateRuns([
+            run({ TestA: 'passed', TestB: 'failed', TestC: 'failed', TestD: 'skipped' }),
+            run({ TestA: 'passed', TestB: 'passed', TestC: 'error', TestD: 'skipped' }),
+            run({ TestA: 'passed', TestB: 'passed', TestC: 'failed', TestD: 'skipped' }),
+            run({ TestA: 'passed', TestB: 'failed', TestC: 'failed', TestD: 'skipped' }),
+        ]);
+        expect(stats.map(s => `${s.name}:${s.verdict}`)).toEqual(['TestA:stable', 'TestB:flaky', 'TestC:failing', 'TestD:skipped']);
+        expect(stats[1]).toMatchObject({ runs: 4, passed: 2, failed: 2, passRate: 50, messages: ['TestB broke'], file: '/work/a_test.go', line: 3 });
+        expect(stats[3]?.passRate).toBe(0);
+    });
+
+    it('should count tests that only ran in some runs', () => {
+        const stats = aggregateRuns([run({ TestA: 'passed' }), run({ TestA: 'passed', TestNew: 'failed' })]);
+        expect(stats.find(s => s.name === 'TestNew')).toMatchObject({ runs: 1, verdict: 'failing' });
+    });
+});
@@ -0,0 +1,51 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { detectFormatters, formatCodeTool } from '../src/tools/format.js';
+
+describe('format_code', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-format-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/fmt\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'ok.go'), 'package fmt\n\nfunc OK() {}\n');
+        await fs.writeFile(join(root, 'messy.go'), 'package fmt\nfunc  Messy( ) {\n}\n');
+        await fs.mkdir(join(root, 'app'));
+        await fs.writeFile(join(root, 'app', 'package.json'), '{}');
+        await fs.writeFile(join(root, 'app', 'pyproject.toml'), '[tool.ruff]\nline-length = 100\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should pick formatters from extensions and project markers', async () => {
+        expect(await detectFormatters(join(root, 'messy.go'), false)).toEqual(['gofmt']);
+        expect(await detectFormatters(join(root, 'app', 'main.py'), false)).toEqual(['ruff']);
+        expect(await detectFormatters(join(root, 'README.md'), false)).toEqual(['prettier']);
+        expect(await detectFormatters(join(root, 'app'), true)).toEqual(['ruff', 'prettier']);
+        expect(await detectFormatters(join(root, 'data.bin'), false)).toEqual([]);
+    });
+
+    it('should report the diff of unformatted files without writing', async () => {
+        const result: any = await formatCodeTool.run({ path: root, formatter: 'gofmt' });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('1 file(s) need formatting');
+        expect(result.files.map((f: any) => f.path)).toEqual([join(root, 'messy.go')]);
+        expect(result.files[0].diff).toContain('+func Messy() {');
+        expect(await fs.readFile(join(root, 'messy.go'), 'utf-8')).toBe('package fmt\nfunc  Messy( ) {\n}\n');
+    });
+
+    it('should write formatted files in fix mode', async () => {
+        const result: any = await formatCodeTool.run({ path: join(root, 'messy.go'), fix: true });
+        expect(result.success).toBe(true);
+        expect(await fs.readFile(join(root, 'messy.go'), 'utf-8')).toBe('package fmt\n\nfunc Messy() {\n}\n');
+        const again: any = await formatCodeTool.run({ path: root });
+        expect(again.success).toBe(true);
+        expect(again.files).toEqual([]);
+    });
+});
@@ -0,0 +1,109 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { parseGoFuzzFailure } from '../src/diagnostics/index.js';
+import { goFuzzTool, parseFuzzList } from '../src/tools/fuzz.js';
+import { commandExists } from '../src/utils/command.js';
+
+const PANIC_OUTPUT = `fuzz: elapsed: 0s, gathering baseline coverage: 1/1 completed, now fuzzing with 1 workers
+fuzz: minimizing 30-byte failing input file
+--- FAIL: FuzzParse (0.45s)
+    --- FAIL: FuzzParse (0.00s)
+        testing.go:2076: panic: runtime error: index out of range [3] with length 0
+            goroutine 16660 [running]:
+            runtime/debug.Stack()
+            	/usr/local/go/src/runtime/debug/stack.go:26 +0x9b
+            testing.tRunner.func1()
+            	/usr/local/go/src/testing/testing.go:2076 +0x1b0
+            panic({0x858f28?, 0x2ceea77f5e48?})
+            	/usr/local/go/src/runtime/panic.go:859 +0x125
+            example.com/fz.Parse(...)
+            	/src/fz/fz.go:6
+            example.com/fz.FuzzParse.func1(0x0?, {0x2ceeafcd9a0d, 0x3})
+            	/src/fz/fz_test.go:8 +0x13d
+            testing.(*F).Fuzz.func1.1(0x2ceeafd5c248?)
+            	/usr/local/go/src/testing/fuzz.go:341 +0x312
+            created by testing.(*F).Fuzz.func1 in goroutine 6
+            	/usr/local/go/src/testing/fuzz.go:328 +0x678
+
+
+    Failing input written to testdata/fuzz/FuzzParse/21ef68f14f653d73
+    To re-run:
+    go test -run=FuzzParse/21ef68f14f653d73
+FAIL
+exit status 1
+FAIL	example.com/fz	0.452s
+`;
+
+describe('go_fuzz', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-fuzz-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/fz\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'fz.go'), 'package fz\n\nfunc Parse(s string) int {\n\tif len(s) > 2 && s[0] == \'x\' {\n\t\tvar a []int\n\t\treturn a[len(s)]\n\t}\n\treturn len(s)\n}\n');
+        await fs.writeFile(join(root, 'fz_test.go'), [
+            'package fz',
+            '',
+            'import "testing"',
+            '',
+            'func FuzzParse(f *testing.F) {',
+            '\tf.Add("abc")',
+            '\tf.Add("xyz")',
+            '\tf.Fuzz(func(t *testing.T, s string) { Parse(s) })',
+            '}',
+            '',
+            'func FuzzLength(f *testing.F) {',
+            '\tf.Add(1)',
+            '\tf.Fuzz(func(t *testing.T, n int) {',
+            '\t\tif n < 0 && n > 0 {',
+            '\t\t\tt.Errorf("impossible %d", n)',
+            '\t\t}',
+            '\t})',
+            '}',
+            '',
+        ].join('\n'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should parse fuzz target lists and failures', () => {
+        expect(parseFuzzList('FuzzParse\nFuzzOk\nok  \texample.com/fz\t0.002s\n?   \texample.com/fz/cmd\t[no test files]\n')).toEqual([
+            { package: 'example.com/fz', name: 'FuzzParse' },
+            { package: 'example.com/fz', name: 'FuzzOk' },
+        ]);
+        const failure = parseGoFuzzFailure(PANIC_OUTPUT)!;
+        expect(failure).toMatchObject({
+            target: 'FuzzParse',
+            message: 'panic: runtime error: index out of range [3] with length 0',
+            file: '/src/fz/fz.go',
+            line: 6,
+            inputFile: 'testdata/fuzz/FuzzParse/21ef68f14f653d73',
+        });
+        expect(failure.stack.map(f => f.function)[3]).toBe('example.com/fz.Parse');
+
+        const errorf = parseGoFuzzFailure('--- FAIL: FuzzErr (0.02s)\n    --- FAIL: FuzzErr (0.00s)\n        ok_test.go:8: bad input "Q000"\n    \n    Failing input written to testdata/fuzz/FuzzErr/be790d736bf8ed29\n');
+        expect(errorf).toEqual({ target: 'FuzzErr', message: 'bad input "Q000"', file: 'ok_test.go', line: 8, stack: [], inputFile: 'testdata/fuzz/FuzzErr/be790d736bf8ed29' });
+        expect(parseGoFuzzFailure('PASS\nok  \texample.com/fz\t1.2s\n')).toBeNull();
+    });
+
+    it('should fuzz targets and report crashers', async () => {
+        if (!await commandExists('go')) return;
+        const corpusDir = join(root, '.corpus');
+        const result: any = await goFuzzTool.run({ projectPath: root, fuzztime: '200x', corpusDir, timeout: 120000 });
+        expect(result.success).toBe(false);
+        expect(result.runs.map((r: any) => `${r.name} ${r.status}`)).toEqual(['FuzzParse crashed', 'FuzzLength passed']);
+        expect(result.runs[0].crasher).toMatchObject({ seed: 'FuzzParse/seed#1', file: join(root, 'fz.go'), line: 6 });
+        expect(result.diagnostics[0]).toMatchObject({ file: join(root, 'fz.go'), line: 6, rule: 'fuzz-crash', source: 'go fuzz' });
+        expect(result.diagnostics[0].message).toContain('FuzzParse failed on seed corpus entry FuzzParse/seed#1: panic: runtime error: index out of range');
+        expect(result.corpusDir).toBe(corpusDir);
+
+        const only: any = await goFuzzTool.run({ projectPath: root, target: 'Nothing' });
+        expect(only.warnings).toEqual(['No fuzz targets matching Nothing in ./...']);
+    }, 180000);
+});
@@ -0,0 +1,51 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { checkGeneratedTool } from '../src/tools/generated.js';
+
+describe('Generated code staleness', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-gen-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/gen\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'main.go'), 'package main\n\n//go:generate sh -c "cat schema.txt > schema_gen.txt"\n\nfunc main() {}\n');
+        await fs.writeFile(join(root, 'schema.txt'), 'v1\nv2\n');
+        await fs.writeFile(join(root, 'schema_gen.txt'), 'v1\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should report generated files that are out of date without touching the project', async () => {
+        const result: any = await checkGeneratedTool.run({ projectPath: root });
+        expect(result.success).toBe(false);
+        expect(result.commands).toEqual(['go generate ./...']);
+        expect(result.stale.map((s: any) => [s.path, s.action, s.added, s.removed])).toEqual([[join(root, 'schema_gen.txt'), 'modify', 1, 0]]);
+        expect(result.stale[0].diff).toContain('+v2');
+        expect(result.errors[0]).toContain('1 generated file(s) are out of date');
+        expect(await fs.readFile(join(root, 'schema_gen.txt'), 'utf-8')).toBe('v1\n');
+    });
+
+    it('should pass when regeneration changes nothing and report new outputs', async () => {
+        await fs.writeFile(join(root, 'schema_gen.txt'), 'v1\nv2\n');
+        const fresh: any = await checkGeneratedTool.run({ projectPath: root });
+        expect(fresh.success).toBe(true);
+        expect(fresh.stale).toEqual([]);
+
+        const created: any = await checkGeneratedTool.run({ projectPath: root, commands: ['echo mock > mock_gen.txt'] });
+        expect(created.stale.map((s: any) => s.action)).toEqual(['create']);
+        expect(created.output).toContain('missing, the generators create it');
+    });
+
+    it('should fail when a generator fails', async () => {
+        const result: any = await checkGeneratedTool.run({ projectPath: root, commands: ['exit 4', 'echo never > never.txt'] });
+        expect(result.success).toBe(false);
+        expect(result.errors).toHaveLength(1);
+        expect(result.errors[0]).toContain('exit 4 exited with code 4');
+    });
+});
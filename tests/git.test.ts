@@ -0,0 +1,88 @@
+import { describe, it, expect } from 'vitest';
+import { parseUnifiedDiff, parseGitStatus, parseGitBlame } from '../src/utils/git.js';
+import { registerTools } from '../src/tools';
+
+class DummyServer {
+    tools: any[] = [];
+    registerTool(tool: any) { this.tools.push(tool); }
+}
+
+describe('Git tools', () => {
+    it('should register the structured git tools', () => {
+        const server = new DummyServer();
+        registerTools(server);
+        for (const name of ['git_diff', 'git_status', 'git_blame']) {
+            const tool = server.tools.find(t => t.name === name);
+            expect(tool).toBeDefined();
+            expect(tool.inputSchema.properties.repoPath).toBeDefined();
+        }
+    });
+
+    it('should reject refs that git would parse as options', async () => {
+        const server = new DummyServer();
+        registerTools(server);
+        const diff = server.tools.find(t => t.name === 'git_diff');
+        const blame = server.tools.find(t => t.name === 'git_blame');
+        for (const args of [{ base: '--output=/tmp/pwned' }, { base: 'main', head: '-p' }]) {
+            const result = await diff.run({ repoPath: process.cwd(), ...args });
+            expect(result.success).toBe(false);
+            expect(result.errors[0]).toContain('Validation error');
+        }
+        const result = await blame.run({ repoPath: process.cwd(), file: 'README.md', ref: '--contents=/etc/passwd' });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('Refs must not start with "-"');
+    });
+
+    it('should parse unified diffs into hunks with line numbers', () => {
+        const diff = [
+            'diff --git a/f.txt b/f.txt',
+            'index 1111111..2222222 100644',
+            '--- a/f.txt',
+            '+++ b/f.txt',
+            '@@ -1,3 +1,4 @@',
+            ' a',
+            '-b',
+            '+B',
+            ' c',
+            '+d',
+            'diff --git a/new.txt b/new.txt',
+            'new file mode 100644',
+            '--- /dev/null',
+            '+++ b/new.txt',
+            '@@ -0,0 +1 @@',
+            '+n',
+        ].join('\n');
+        const files = parseUnifiedDiff(diff);
+        expect(files).toHaveLength(2);
+        expect(files[0]?.hunks[0]).toMatchObject({ oldStart: 1, oldLines: 3, newStart: 1, newLines: 4 });
+        expect(files[0]?.hunks[0]?.lines[1]).toEqual({ type: 'del', content: 'b', oldLine: 2, newLine: null });
+        expect(files[0]?.hunks[0]?.lines[4]).toEqual({ type: 'add', content: 'd', oldLine: null, newLine: 4 });
+        expect(files[1]).toMatchObject({ file: 'new.txt', status: 'added' });
+        expect(files[1]?.hunks[0]?.newLines).toBe(1);
+    });
+
+    it('should parse porcelain status with tracking info and renames', () => {
+        const status = parseGitStatus('## main...origin/main [ahead 2, behind 1]\nR  old.txt -> new.txt\n M src/a.ts\n?? scratch.txt\n');
+        expect(status).toMatchObject({ branch: 'main', upstream: 'origin/main', ahead: 2, behind: 1, clean: false });
+        expect(status.entries[0]).toMatchObject({ path: 'new.txt', origPath: 'old.txt', staged: true });
+        expect(status.entries[1]).toMatchObject({ path: 'src/a.ts', staged: false, worktree: 'M' });
+        expect(status.entries[2]).toMatchObject({ path: 'scratch.txt', untracked: true });
+    });
+
+    it('should parse porcelain blame output', () => {
+        const sha = 'a'.repeat(40);
+        const output = [
+            `${sha} 1 1 2`,
+            'author Jane',
+            'author-time 0',
+            'summary initial commit',
+            'filename f.txt',
+            '\tfirst',
+            `${sha} 2 2`,
+            '\tsecond',
+        ].join('\n');
+        const lines = parseGitBlame(output);
+        expect(lines).toHaveLength(2);
+        expect(lines[1]).toMatchObject({ line: 2, commit: sha, author: 'Jane', summary: 'initial commit', content: 'second' });
+    });
+});
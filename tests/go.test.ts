@@ -1,5 +1,6 @@
 import { describe, it, expect } from 'vitest';
 import { registerTools } from '../src/tools';
+import { parseGoRaceReports, raceReportDiagnostics } from '../src/diagnostics/index.js';
 
 class DummyServer {
     tools: any[] = [];
@@ -29,7 +30,74 @@ describe('Go Tool', () => {
         expect(tool.inputSchema.required).toContain('filePath');
         // Check that actions is an array of enums
         expect(tool.inputSchema.properties.actions.items.enum).toEqual([
-            'build', 'fmt', 'mod', 'vet', 'test'
+            'build', 'fmt', 'mod', 'vet', 'test', 'gopls'
         ]);
+        for (const flag of ['run', 'race', 'msan', 'asan', 'count', 'shuffle']) {
+            expect(tool.inputSchema.properties[flag]).toBeDefined();
+        }
+    });
+
+    it('should reject combined sanitizers', async () => {
+        const server = new DummyServer();
+        registerTools(server);
+        const tool = server.tools.find(t => t.name === 'go');
+        const result = await tool.run({ filePath: process.cwd(), actions: ['test'], race: true, asan: true });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('cannot be combined');
+    });
+});
+
+const RACE_OUTPUT = `=== RUN   TestCounter
+==================
+WARNING: DATA RACE
+Read at 0x00c0000182a8 by goroutine 8:
+  example.com/r.(*Counter).Inc()
+      /work/r/counter.go:9 +0x7b
+  example.com/r.TestCounter.func1()
+      /work/r/counter_test.go:12 +0x3c
+
+Previous write at 0x00c0000182a8 by main goroutine:
+  example.com/r.(*Counter).Inc()
+      /work/r/counter.go:9 +0x8d
+
+Goroutine 8 (running) created at:
+  example.com/r.TestCounter()
+      /work/r/counter_test.go:11 +0x11c
+  testing.tRunner()
+      /usr/local/go/src/testing/testing.go:2193 +0x21c
+==================
+    testing.go:1865: race detected during execution of test
+--- FAIL: TestCounter (0.00s)`;
+
+describe('Go race detector', () => {
+    it('should parse the conflicting accesses and goroutine stacks', () => {
+        const [race] = parseGoRaceReports(RACE_OUTPUT);
+        expect(race?.accesses.map(a => `${a.previous ? 'previous ' : ''}${a.kind} g${a.goroutine}`)).toEqual(['read g8', 'previous write g1']);
+        expect(race?.accesses[0]?.stack).toEqual([
+            { function: 'example.com/r.(*Counter).Inc', file: '/work/r/counter.go', line: 9 },
+            { function: 'example.com/r.TestCounter.func1', file: '/work/r/counter_test.go', line: 12 },
+        ]);
+        expect(race?.goroutines).toEqual([{
+            id: 8,
+            state: 'running',
+            createdAt: [
+                { function: 'example.com/r.TestCounter', file: '/work/r/counter_test.go', line: 11 },
+                { function: 'testing.tRunner', file: '/usr/local/go/src/testing/testing.go', line: 2193 },
+            ],
+        }]);
+        expect(race).toMatchObject({ file: '/work/r/counter.go', line: 9 });
+    });
+
+    it('should report each race as an error diagnostic', () => {
+        const diagnostics = raceReportDiagnostics([{ ...parseGoRaceReports(RACE_OUTPUT)[0]!, test: 'TestCounter' }], '/work/r');
+        expect(diagnostics).toEqual([{
+            file: '/work/r/counter.go',
+            line: 9,
+            column: 0,
+            severity: 'error',
+            message: 'data race: read by goroutine 8 at counter.go:9 conflicts with previous write by goroutine 1 at counter.go:9 (in TestCounter)',
+            rule: 'data-race',
+            source: 'go race',
+        }]);
     });
 }); 
\ No newline at end of file
@@ -0,0 +1,87 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { goAstQueryTool } from '../src/tools/goast.js';
+
+const SHAPES = `package shapes
+
+// Shape is anything with an area
+type Shape interface {
+	Area() float64
+	Name() string
+}
+
+type Square struct {
+	Side float64 \`json:"side" db:"side_len"\`
+	label string
+	Base
+}
+
+type Base struct{}
+
+type Circle struct{ R float64 }
+
+func (s Square) Area() float64 { return s.Side * s.Side }
+func (s *Square) Name() string { return "square" } // TODO: localize
+
+func (c Circle) Area() float64 { return 3.14 * c.R * c.R }
+func (c Circle) Name(upper bool) string { return "circle" }
+
+// New returns a square. FIXME reject negative sides
+func New(side float64) *Square { return &Square{Side: side} }
+
+func helper(a, b int) (int, error) { return a + b, nil }
+`;
+
+describe('go_ast_query', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-goast-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'shapes.go'), SHAPES);
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should require the interface name for implementations', async () => {
+        const result = await goAstQueryTool.run({ path: root, query: 'implementations' });
+        expect(result.errors).toEqual(['name (the interface) is required for implementations']);
+    });
+
+    it('should list exported functions with signatures', async () => {
+        const result: any = await goAstQueryTool.run({ path: root, query: 'functions', exported: true });
+        expect(result.success).toBe(true);
+        expect(result.results.map((f: any) => `${f.receiver ?? ''}.${f.name}${f.signature}`)).toEqual([
+            'Square.Area() float64',
+            'Square.Name() string',
+            'Circle.Area() float64',
+            'Circle.Name(bool) string',
+            '.New(float64) *Square',
+        ]);
+        expect(result.results[4]).toMatchObject({ file: join(root, 'shapes.go'), line: 26, doc: 'New returns a square. FIXME reject negative sides' });
+    });
+
+    it('should find implementations by method signature', async () => {
+        const result: any = await goAstQueryTool.run({ path: root, query: 'implementations', name: 'Shape' });
+        expect(result.results).toHaveLength(1);
+        expect(result.results[0]).toMatchObject({ name: 'Square', pointer: true, line: 9 });
+    });
+
+    it('should list struct fields with parsed tags', async () => {
+        const result: any = await goAstQueryTool.run({ path: root, query: 'struct_fields', name: 'Square' });
+        const fields = result.results[0].fields;
+        expect(fields.map((f: any) => f.name)).toEqual(['Side', 'label', 'Base']);
+        expect(fields[0]).toMatchObject({ type: 'float64', tags: { db: 'side_len', json: 'side' } });
+        expect(fields[2].embedded).toBe(true);
+    });
+
+    it('should locate TODO comments', async () => {
+        const result: any = await goAstQueryTool.run({ path: root, query: 'todos' });
+        expect(result.results.map((t: any) => `${t.line}:${t.tag}:${t.text}`)).toEqual(['20:TODO:localize', '25:FIXME:reject negative sides']);
+    });
+});
@@ -0,0 +1,81 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { findMajorVersionIssues, findReplaceIssues, goModCheckTool, parseGoModGraph, splitMajor } from '../src/tools/gomod.js';
+
+describe('go.mod hygiene', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-gomod-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.mkdir(join(root, 'lib'));
+        await fs.mkdir(join(root, 'app'));
+        await fs.writeFile(join(root, 'lib', 'go.mod'), 'module example.com/lib\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'lib', 'lib.go'), 'package lib\n\nfunc Name() string { return "lib" }\n');
+        // Required but never imported, so tidy would drop it
+        await fs.writeFile(join(root, 'app', 'go.mod'), 'module example.com/app\n\ngo 1.21\n\nrequire example.com/lib v1.0.0\n\nreplace example.com/lib => ../lib\n');
+        await fs.writeFile(join(root, 'app', 'main.go'), 'package main\n\nfunc main() {}\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should split major version suffixes', () => {
+        expect(splitMajor('github.com/a/b/v3')).toEqual({ base: 'github.com/a/b', major: 3 });
+        expect(splitMajor('gopkg.in/yaml.v2')).toEqual({ base: 'gopkg.in/yaml', major: 2 });
+        expect(splitMajor('github.com/a/b')).toEqual({ base: 'github.com/a/b', major: 1 });
+    });
+
+    it('should flag incompatible requirements and several majors of one module', () => {
+        const mod = {
+            module: 'example.com/app',
+            require: [
+                { path: 'github.com/old/lib', version: 'v2.3.0+incompatible', indirect: false },
+                { path: 'github.com/x/y', version: 'v1.4.0', indirect: false },
+            ],
+            replace: [],
+        };
+        const graph = parseGoModGraph('example.com/app github.com/x/y@v1.4.0\nexample.com/app github.com/z/w@v1.0.0\ngithub.com/z/w@v1.0.0 github.com/x/y/v3@v3.1.0\n');
+        const messages = findMajorVersionIssues(mod, [...graph.keys()]).map(i => i.message);
+        expect(messages).toHaveLength(2);
+        expect(messages[0]).toContain('+incompatible');
+        expect(messages[1]).toContain('github.com/x/y is in the module graph at 2 major versions');
+    });
+
+    it('should flag forks, downgrades and replaces without effect', () => {
+        const issues = findReplaceIssues({
+            module: 'example.com/app',
+            require: [{ path: 'github.com/a/b', version: 'v1.5.0', indirect: false }],
+            replace: [
+                { old: { path: 'github.com/a/b' }, new: { path: 'github.com/me/b', version: 'v1.2.0' } },
+                { old: { path: 'github.com/unused/c' }, new: { path: 'github.com/unused/c', version: 'v1.0.0' } },
+            ],
+        }, new Set(['example.com/app', 'github.com/a/b']));
+        expect(issues.map(i => i.message)).toEqual([
+            'github.com/a/b is replaced by a different module, github.com/me/b@v1.2.0; make sure the fork is meant to stay',
+            'github.com/a/b is required at v1.5.0 but replaced by the older v1.2.0',
+            'github.com/unused/c is replaced but no module in the graph requires it; the directive has no effect',
+        ]);
+    });
+
+    it('should report an untidy module and a local replace on its go.mod line', async () => {
+        const result: any = await goModCheckTool.run({ projectPath: join(root, 'app') });
+        expect(result.success).toBe(false);
+        expect(result.tidy.tidy).toBe(false);
+        expect(result.tidy.diff).toContain('-require example.com/lib v1.0.0');
+        const replace = result.diagnostics.find((d: any) => d.rule === 'replace');
+        expect(replace).toMatchObject({ file: join(root, 'app', 'go.mod'), line: 7, severity: 'warning' });
+        expect(replace.message).toContain('local directory ../lib');
+        expect(result.graph.edges['example.com/app']).toEqual(['example.com/lib@v1.0.0']);
+    });
+
+    it('should pass a tidy module', async () => {
+        const result: any = await goModCheckTool.run({ projectPath: join(root, 'lib'), checks: ['tidy'] });
+        expect(result.success).toBe(true);
+        expect(result.tidy).toEqual({ tidy: true });
+    });
+});
@@ -0,0 +1,60 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { parseGoplsSpan, parseWorkspaceSymbols, parseReferences, parseDefinitionJson, findReferencesTool } from '../src/tools/gopls.js';
+
+describe('gopls output', () => {
+    it('should parse point, single-line and multi-line spans', () => {
+        expect(parseGoplsSpan('/work/a.go:12:5')).toEqual({ file: '/work/a.go', line: 12, column: 5 });
+        expect(parseGoplsSpan('/work/a.go:12:5-9')).toEqual({ file: '/work/a.go', line: 12, column: 5, endLine: 12, endColumn: 9 });
+        expect(parseGoplsSpan('/work/a.go:12:5-14:2')).toEqual({ file: '/work/a.go', line: 12, column: 5, endLine: 14, endColumn: 2 });
+        expect(parseGoplsSpan('not a span')).toBeNull();
+    });
+
+    it('should parse workspace symbols', () => {
+        const output = [
+            '/work/server.go:10:6-12 Server Struct',
+            '/work/server.go:21:18-23 Server.Start Method',
+            '/work/my dir/util.go:3:6-10 helper Function',
+        ].join('\n');
+        expect(parseWorkspaceSymbols(output).map(s => `${s.name} ${s.kind} ${s.file}:${s.line}`)).toEqual([
+            'Server Struct /work/server.go:10',
+            'Server.Start Method /work/server.go:21',
+            'helper Function /work/my dir/util.go:3',
+        ]);
+    });
+
+    it('should parse references and definitions', () => {
+        expect(parseReferences('/work/a.go:3:2-7\n/work/b.go:9:14-19\n').map(r => `${r.file}:${r.line}:${r.column}`)).toEqual(['/work/a.go:3:2', '/work/b.go:9:14']);
+        const definition = parseDefinitionJson(JSON.stringify({
+            span: { uri: 'file:///work/server.go', start: { line: 21, column: 18, offset: 410 }, end: { line: 21, column: 23, offset: 415 } },
+            description: 'func (s *Server) Start() error\n\nStart listens on the configured address.',
+        }));
+        expect(definition?.location).toEqual({ file: '/work/server.go', line: 21, column: 18, endLine: 21, endColumn: 23 });
+        expect(definition?.description).toContain('func (s *Server) Start() error');
+        expect(parseDefinitionJson('gopls: no identifier found')).toBeNull();
+    });
+});
+
+describe('find_references', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-gopls-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/x\n');
+        await fs.writeFile(join(root, 'main.go'), 'package main\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should require a position or a symbol', async () => {
+        const result = await findReferencesTool.run({ filePath: join(root, 'main.go') });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toBe('Either line (and column) or symbol is required');
+    });
+});
@@ -0,0 +1,76 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { parseCliArgs } from '../src/cli.js';
+import { formatHealthReport, healthCheckTool, runHealthCheck } from '../src/tools/health.js';
+
+describe('health_check', () => {
+    let root: string;
+    const saved = { config: process.env.MCP_CONFIG_FILE, plugins: process.env.MCP_PLUGINS_FILE, workspaces: process.env.MCP_WORKSPACES_FILE };
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-health-test-'));
+        process.env.MCP_CONFIG_FILE = join(root, 'global.yaml');
+        process.env.MCP_PLUGINS_FILE = join(root, 'plugins.yaml');
+        process.env.MCP_WORKSPACES_FILE = join(root, 'workspaces.json');
+        await fs.mkdir(join(root, 'project'));
+        await fs.mkdir(join(root, 'broken'));
+        await fs.writeFile(join(root, 'project', '.code-feedback.yaml'), 'tools:\n  disabled: [go_vet]\n');
+        await fs.writeFile(join(root, 'broken', '.code-feedback.yaml'), 'pipelines: 3\n');
+        // Exists but cannot start: its interpreter is missing
+        await fs.writeFile(join(root, 'stale-tool'), '#!/nonexistent/interpreter\n', { mode: 0o755 });
+        Config.getInstance().addAllowedPaths([join(root, 'project')]);
+    });
+
+    afterAll(async () => {
+        for (const [key, value] of [['MCP_CONFIG_FILE', saved.config], ['MCP_PLUGINS_FILE', saved.plugins], ['MCP_WORKSPACES_FILE', saved.workspaces]] as const) {
+            if (value === undefined) delete process.env[key];
+            else process.env[key] = value;
+        }
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should check binaries of enabled tools only', async () => {
+        const report = await runHealthCheck({
+            path: join(root, 'project'),
+            tools: [
+                { name: 'go_build', binaries: ['node'] },
+                { name: 'go_vet', binaries: ['node', 'disabled-binary-cf'] },
+                { name: 'lint_a', binaries: ['missing-binary-cf'] },
+                { name: 'lint_b', binaries: ['missing-binary-cf', join(root, 'stale-tool')] },
+            ],
+        });
+        const binaries = report.checks.filter(c => c.category === 'binaries');
+        expect(binaries.map(c => `${c.name} ${c.status}`)).toEqual([
+            `${join(root, 'stale-tool')} fail`,
+            'missing-binary-cf warn',
+            'node ok',
+        ]);
+        expect(binaries[1]).toMatchObject({ message: 'Not found on PATH; 2 tool(s) cannot run', tools: ['lint_a', 'lint_b'] });
+        expect(report.checks.find(c => c.name === 'project config')).toMatchObject({ status: 'ok', message: `${join(root, 'project', '.code-feedback.yaml')} is valid` });
+        expect(report.checks.find(c => c.name === `allowed path ${join(root, 'project')}`)?.status).toBe('ok');
+        expect(report.ready).toBe(false);
+        expect(report.summary.fail).toBe(1);
+        expect(formatHealthReport(report)).toContain('[warn] missing-binary-cf: Not found on PATH; 2 tool(s) cannot run (lint_a, lint_b)');
+    });
+
+    it('should fail on config files that do not validate', async () => {
+        Config.getInstance().addAllowedPaths([join(root, 'broken')]);
+        await fs.writeFile(join(root, 'plugins.yaml'), 'plugins:\n  - name: 7\n');
+        const result: any = await healthCheckTool.run({ path: join(root, 'broken') });
+        expect(result.success).toBe(false);
+        expect(result.errors).toHaveLength(2);
+        expect(result.errors[0]).toContain(`project config: Invalid config file ${join(root, 'broken', '.code-feedback.yaml')}: pipelines`);
+        expect(result.errors[1]).toContain('plugins file: ');
+        expect(result.checks.find((c: any) => c.name === 'global config').message).toBe(`No ${join(root, 'global.yaml')}; defaults apply`);
+        expect((await healthCheckTool.run({ path: '/definitely/not/allowed' })).errors).toEqual(['Path not allowed']);
+    });
+
+    it('should parse the doctor command', () => {
+        expect(parseCliArgs(['doctor'], {})).toEqual({ command: 'doctor', format: 'text' });
+        expect(parseCliArgs(['doctor', '--path=api', '--format', 'json'], {})).toEqual({ command: 'doctor', path: 'api', format: 'json' });
+        expect(() => parseCliArgs(['doctor', '--format', 'sarif'], {})).toThrow('Invalid --format sarif');
+    });
+});
@@ -0,0 +1,93 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import type { Diagnostic } from '../src/diagnostics/index.js';
+import { diffDiagnostics, historyStore } from '../src/history/index.js';
+import { compareRunsTool, getHistoryTool } from '../src/tools/history.js';
+import { runPipelineTool } from '../src/tools/pipeline.js';
+import { runCommand } from '../src/utils/command.js';
+
+const lint = (file: string, line: number, message: string, severity: Diagnostic['severity'] = 'error'): Diagnostic =>
+    ({ file, line, column: 1, severity, message, rule: 'errcheck', source: 'golangci-lint' });
+
+describe('Run history', () => {
+    let root: string;
+    let repo: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-history-'));
+        repo = join(root, 'repo');
+        await fs.mkdir(repo);
+        process.env.MCP_RESULTS_DB = join(root, 'state', 'results.db');
+        historyStore.reset();
+        Config.getInstance().addAllowedPaths([root]);
+        await runCommand('git init -q && git -c user.email=t@t -c user.name=t commit -q --allow-empty -m init', { cwd: repo });
+    });
+
+    afterAll(async () => {
+        delete process.env.MCP_RESULTS_DB;
+        historyStore.reset();
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should match findings across runs even when their lines move', () => {
+        const base = [lint('a.go', 10, 'Error return value is not checked'), lint('a.go', 20, 'line is 130 characters', 'warning'), lint('b.go', 5, 'unused variable x')];
+        const head = [lint('a.go', 14, 'Error return value is not checked'), lint('a.go', 24, 'line is 131 characters', 'warning'), lint('c.go', 1, 'unused variable y'), lint('c.go', 9, 'unused variable y')];
+        const diff = diffDiagnostics(base, head);
+        expect(diff.existing.map(d => `${d.file}:${d.line}`)).toEqual(['a.go:14', 'a.go:24']);
+        expect(diff.new.map(d => `${d.file}:${d.line}`)).toEqual(['c.go:1', 'c.go:9']);
+        expect(diff.fixed.map(d => `${d.file}:${d.line}`)).toEqual(['b.go:5']);
+    });
+
+    it('should record pipeline runs with their commit', async () => {
+        const result: any = await runPipelineTool.run({ path: repo, steps: [{ name: 'ok', command: 'true' }] });
+        expect(result.success).toBe(true);
+        expect(result.historyId).toMatch(/^\d{8}T\d{9}Z-[0-9a-f]{6}$/);
+
+        const run = await historyStore.get(result.historyId);
+        const head = (await runCommand('git rev-parse HEAD', { cwd: repo })).stdout.trim();
+        expect(run?.commit).toBe(head);
+        expect(run?.dirty).toBe(false);
+        expect(run?.pipeline).toBe(null);
+        expect(run?.passed).toBe(true);
+    });
+
+    it('should compare runs into new, fixed and pre-existing findings', async () => {
+        await historyStore.record(repo, { pipeline: 'lint', passed: false, summary: 'FAIL', diagnostics: [join(repo, 'a.go'), join(repo, 'b.go'), join(repo, 'd.go'), join(repo, 'e.go')].map((file, i) => lint(file, i + 1, `problem ${file.slice(-4)}`)) });
+        await new Promise(resolve => setTimeout(resolve, 5));
+        await historyStore.record(repo, { pipeline: 'lint', passed: false, summary: 'FAIL', diagnostics: [join(repo, 'b.go'), join(repo, 'd.go'), join(repo, 'e.go'), join(repo, 'f.go'), join(repo, 'g.go')].map((file, i) => lint(file, i + 7, `problem ${file.slice(-4)}`)) });
+
+        const result: any = await compareRunsTool.run({ path: repo, pipeline: 'lint' });
+        expect(result.summary).toBe('2 new golangci-lint errors, 1 fixed, 3 pre-existing');
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('2 new error(s)');
+        expect(result.diagnostics.map((d: Diagnostic) => d.file)).toEqual([join(repo, 'f.go'), join(repo, 'g.go')]);
+        expect(result.fixed[0].file).toBe(join(repo, 'a.go'));
+        expect(result.head.diagnostics).toBeUndefined();
+
+        // The same run on both sides has nothing new
+        const same: any = await compareRunsTool.run({ base: result.head.id, head: result.head.id });
+        expect(same.success).toBe(true);
+        expect(same.counts).toEqual({ new: 0, fixed: 0, existing: 5 });
+
+        const commit = (await runCommand('git rev-parse --short HEAD', { cwd: repo })).stdout.trim();
+        const byCommit: any = await compareRunsTool.run({ path: repo, pipeline: 'lint', base: result.base.id, head: commit });
+        expect(byCommit.head.id).toBe(result.head.id);
+    });
+
+    it('should list a workspace history with changes between runs', async () => {
+        const result: any = await getHistoryTool.run({ path: repo });
+        expect(result.success).toBe(true);
+        expect(result.runs).toHaveLength(3);
+        expect(result.runs[0].pipeline).toBe('lint');
+        expect(result.runs[0].change).toEqual({ since: result.runs[1].id, new: 2, fixed: 1 });
+        expect(result.runs[2].change).toBeUndefined();
+        expect(result.runs[0].diagnostics).toBeUndefined();
+        expect(result.output).toContain('(+2 new, -1 fixed)');
+
+        const none: any = await compareRunsTool.run({ path: join(root, 'elsewhere') });
+        expect(none.errors).toEqual(['No runs recorded for this workspace']);
+    });
+});
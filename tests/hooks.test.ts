@@ -0,0 +1,116 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { join } from 'path';
+import { execSync } from 'child_process';
+import Config from '../src/config/index.js';
+import { parsePreCommitConfig, parsePreCommitOutput, runHooksTool } from '../src/tools/hooks.js';
+import { registerTools } from '../src/tools';
+
+class DummyServer {
+    tools: any[] = [];
+    registerTool(tool: any) { this.tools.push(tool); }
+}
+
+describe('run_hooks tool', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-hooks-'));
+        Config.getInstance().addAllowedPaths([root]);
+        execSync('git init -q', { cwd: root });
+        await fs.mkdir(join(root, '.husky'));
+        await fs.writeFile(join(root, '.husky', 'pre-commit'), 'echo checking\ntest ! -e BAD\n');
+        await fs.writeFile(join(root, '.husky', 'commit-msg'), 'grep -q "^feat" "$1" || { echo "bad message"; exit 3; }\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should register run_hooks as mutating', () => {
+        const server = new DummyServer();
+        registerTools(server);
+        const tool = server.tools.find(t => t.name === 'run_hooks');
+        expect(tool).toBeDefined();
+        expect(tool.mutates).toBe(true);
+        expect(tool.inputSchema.properties.stage).toBeDefined();
+    });
+
+    it('should read hook ids from .pre-commit-config.yaml', () => {
+        const hooks = parsePreCommitConfig([
+            'repos:',
+            '  - repo: https://github.com/pre-commit/pre-commit-hooks',
+            '    rev: v4.5.0',
+            '    hooks:',
+            '      - id: trailing-whitespace',
+            '      - id: end-of-file-fixer',
+            '  - repo: local',
+            '    hooks:',
+            '      - id: mypy',
+            '        name: type check',
+            '        stages: [pre-push]',
+        ].join('\n'));
+        expect(hooks.map(h => h.id)).toEqual(['trailing-whitespace', 'end-of-file-fixer', 'mypy']);
+        expect(hooks[2]).toEqual({ id: 'mypy', name: 'type check', repo: 'local', stages: ['pre-push'] });
+    });
+
+    it('should parse verbose pre-commit output', () => {
+        const { hooks, warnings } = parsePreCommitOutput([
+            '[WARNING] Unstaged files detected.',
+            '[INFO] Stashing unstaged files to /tmp/patch.',
+            'trim trailing whitespace.................................................Passed',
+            '- hook id: trailing-whitespace',
+            '- duration: 0.05s',
+            'fix end of files.........................................................\x1b[41mFailed\x1b[m',
+            '- hook id: end-of-file-fixer',
+            '- exit code: 1',
+            '- files were modified by this hook',
+            '',
+            'Fixing src/a.py',
+            '',
+            'mypy.................................................(no files to check)Skipped',
+            '- hook id: mypy',
+        ].join('\n'));
+        expect(warnings).toEqual(['Unstaged files detected.']);
+        expect(hooks).toHaveLength(3);
+        expect(hooks[0]).toMatchObject({ id: 'trailing-whitespace', name: 'trim trailing whitespace', status: 'passed', durationMs: 50 });
+        expect(hooks[1]).toMatchObject({ id: 'end-of-file-fixer', status: 'failed', exitCode: 1, filesModified: true, output: 'Fixing src/a.py' });
+        expect(hooks[2]).toMatchObject({ id: 'mypy', status: 'skipped', reason: 'no files to check' });
+    });
+
+    it('should run husky hooks and report failures', async () => {
+        const passed = await runHooksTool.run({ repoPath: root });
+        expect(passed.success).toBe(true);
+        expect(passed.frameworks).toEqual(['husky']);
+        expect(passed.hooks?.[0]).toMatchObject({ framework: 'husky', id: 'pre-commit', status: 'passed', exitCode: 0, output: 'checking' });
+
+        await fs.writeFile(join(root, 'BAD'), '');
+        const failed = await runHooksTool.run({ repoPath: root });
+        await fs.rm(join(root, 'BAD'));
+        expect(failed.success).toBe(false);
+        expect(failed.hooks?.[0]?.status).toBe('failed');
+        expect(failed.errors[0]).toContain('husky hook pre-commit failed');
+    });
+
+    it('should pass the message file to commit-msg hooks', async () => {
+        const missing = await runHooksTool.run({ repoPath: root, stage: 'commit-msg' });
+        expect(missing.errors).toEqual(['commitMessage is required for the commit-msg stage']);
+
+        const good = await runHooksTool.run({ repoPath: root, stage: 'commit-msg', commitMessage: 'feat: add hooks\n' });
+        expect(good.success).toBe(true);
+        const bad = await runHooksTool.run({ repoPath: root, stage: 'commit-msg', commitMessage: 'added hooks\n' });
+        expect(bad.success).toBe(false);
+        expect(bad.hooks?.[0]).toMatchObject({ exitCode: 3, output: 'bad message' });
+    });
+
+    it('should fail when no hooks are configured', async () => {
+        const empty = await fs.mkdtemp(join(tmpdir(), 'cf-hooks-empty-'));
+        Config.getInstance().addAllowedPaths([empty]);
+        execSync('git init -q', { cwd: empty });
+        const result = await runHooksTool.run({ repoPath: empty });
+        await fs.rm(empty, { recursive: true, force: true });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('No .pre-commit-config.yaml or husky hooks found');
+    });
+});
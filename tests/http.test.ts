@@ -0,0 +1,62 @@
+import { describe, it, expect, afterAll } from 'vitest';
+import type { AddressInfo } from 'net';
+import type { Server as HttpServer } from 'http';
+import { parseListenAddress, isAuthorized, startHttpServer } from '../src/transport/http.js';
+import { parseCliArgs } from '../src/cli.js';
+import { createServer } from '../src/server.js';
+
+describe('HTTP transport', () => {
+    let httpServer: HttpServer | undefined;
+
+    afterAll(async () => {
+        await new Promise(resolve => httpServer ? httpServer.close(resolve) : resolve(undefined));
+    });
+
+    it('should parse listen addresses', () => {
+        expect(parseListenAddress(':8080')).toEqual({ port: 8080 });
+        expect(parseListenAddress('8080')).toEqual({ port: 8080 });
+        expect(parseListenAddress('127.0.0.1:9000')).toEqual({ host: '127.0.0.1', port: 9000 });
+        expect(parseListenAddress('[::1]:9000')).toEqual({ host: '::1', port: 9000 });
+        expect(() => parseListenAddress('localhost')).toThrow();
+    });
+
+    it('should check bearer tokens', () => {
+        expect(isAuthorized(undefined, undefined)).toBe(true);
+        expect(isAuthorized('Bearer secret', 'secret')).toBe(true);
+        expect(isAuthorized('Bearer wrong', 'secret')).toBe(false);
+        expect(isAuthorized(undefined, 'secret')).toBe(false);
+    });
+
+    it('should parse serve arguments', () => {
+        expect(parseCliArgs([], {})).toEqual({ command: 'serve' });
+        expect(parseCliArgs(['serve', '--http', ':8080', '--token=abc'], {})).toEqual({ command: 'serve', http: ':8080', token: 'abc' });
+        expect(parseCliArgs(['serve', '--http', ':8080'], { MCP_AUTH_TOKEN: 'env' })).toEqual({ command: 'serve', http: ':8080', token: 'env' });
+        expect(parseCliArgs(['--help'], {})).toEqual({ command: 'help' });
+        expect(() => parseCliArgs(['--bogus'], {})).toThrow();
+    });
+
+    it('should require the bearer token on MCP endpoints', async () => {
+        httpServer = await startHttpServer({ host: '127.0.0.1', port: 0, token: 'secret', createMcpServer: () => createServer('test') });
+        const { port } = httpServer.address() as AddressInfo;
+        const base = `http://127.0.0.1:${port}`;
+
+        const health = await fetch(`${base}/health`);
+        expect(health.status).toBe(200);
+
+        const unauthorized = await fetch(`${base}/mcp`, { method: 'POST', body: '{}' });
+        expect(unauthorized.status).toBe(401);
+
+        const noSession = await fetch(`${base}/mcp`, {
+            method: 'POST',
+            headers: { Authorization: 'Bearer secret', 'Content-Type': 'application/json' },
+            body: JSON.stringify({ jsonrpc: '2.0', id: 1, method: 'tools/list' }),
+        });
+        expect(noSession.status).toBe(400);
+
+        expect((await fetch(`${base}/metrics`)).status).toBe(401);
+        const scrape = await fetch(`${base}/metrics`, { headers: { Authorization: 'Bearer secret' } });
+        expect(scrape.status).toBe(200);
+        expect(scrape.headers.get('content-type')).toContain('text/plain');
+        expect(await scrape.text()).toContain('# TYPE code_feedback_tool_duration_seconds histogram');
+    });
+});
@@ -0,0 +1,128 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import {
+    locateK8sResource,
+    parseKubeconformOutput,
+    parseKubeLinterOutput,
+    parseKubevalOutput,
+    parseTerraformValidateOutput,
+    parseTflintOutput,
+} from '../src/diagnostics/index.js';
+import { validateK8sTool, validateTerraformTool } from '../src/tools/iac.js';
+import { commandExists } from '../src/utils/command.js';
+
+const MANIFEST = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+---
+# The web server
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: "2"
+  template:
+    spec:
+      containers:
+        - name: sidecar
+          image: envoy
+        - name: web
+          image: nginx
+          ports:
+            - containerPort: http
+`;
+
+describe('validate_k8s and validate_terraform', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-iac-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'app.yaml'), MANIFEST);
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should locate resources and fields in manifests', () => {
+        expect(locateK8sResource(MANIFEST, 'Service', 'web')).toBe(1);
+        expect(locateK8sResource(MANIFEST, 'Deployment', 'web')).toBe(10);
+        expect(locateK8sResource(MANIFEST, 'Deployment', 'web', '/spec/replicas')).toBe(15);
+        expect(locateK8sResource(MANIFEST, 'Deployment', 'web', '/spec/template/spec/containers/1/image')).toBe(22);
+        expect(locateK8sResource(MANIFEST, 'Deployment', 'web', '/spec/template/spec/containers/1/ports/0/containerPort')).toBe(24);
+        expect(locateK8sResource(MANIFEST, 'Service', 'web', '/spec/ports/0/port')).toBe(7);
+        // As deep as the pointer goes
+        expect(locateK8sResource(MANIFEST, 'Deployment', 'web', '/spec/template/spec/volumes/0')).toBe(17);
+    });
+
+    it('should parse schema validation and lint reports', () => {
+        const file = join(root, 'app.yaml');
+        const locate = (_file: string, kind: string, name: string, pointer?: string) => locateK8sResource(MANIFEST, kind, name, pointer);
+        const kubeconform = JSON.stringify({
+            resources: [
+                { filename: 'app.yaml', kind: 'Deployment', name: 'web', version: 'apps/v1', status: 'statusInvalid', msg: 'problem validating schema', validationErrors: [{ path: '/spec/replicas', msg: 'expected integer or null, but got string' }] },
+                { filename: 'values.yaml', kind: '', name: '', version: '', status: 'statusError', msg: 'error while parsing: missing \'kind\' key' },
+                { filename: 'app.yaml', kind: 'Service', name: 'web', version: 'v1', status: 'statusValid', msg: '' },
+            ],
+        });
+        expect(parseKubeconformOutput(kubeconform, root, locate)).toEqual([
+            { file, line: 15, column: 0, severity: 'error', message: 'Deployment web: /spec/replicas: expected integer or null, but got string', rule: 'schema', source: 'kubeconform' },
+        ]);
+        const kubeval = JSON.stringify([{ filename: 'app.yaml', kind: 'Deployment', status: 'invalid', errors: ['spec.replicas: Invalid type. Expected: [integer,null], given: string'] }]);
+        expect(parseKubevalOutput(kubeval, root, locate)[0]).toMatchObject({ file, line: 15, rule: 'schema', source: 'kubeval' });
+        const kubeLinter = JSON.stringify({
+            Reports: [{
+                Diagnostic: { Message: 'container "web" does not have a read-only root file system' },
+                Check: 'no-read-only-root-fs',
+                Remediation: 'Set readOnlyRootFilesystem to true in the container securityContext.',
+                Object: { Metadata: { FilePath: 'app.yaml' }, K8sObject: { Name: 'web', GroupVersionKind: { Group: 'apps', Version: 'v1', Kind: 'Deployment' } } },
+            }],
+        });
+        expect(parseKubeLinterOutput(kubeLinter, root, locate)).toEqual([{
+            file, line: 10, column: 0, severity: 'warning', rule: 'no-read-only-root-fs', source: 'kube-linter',
+            message: 'Deployment web: container "web" does not have a read-only root file system (Set readOnlyRootFilesystem to true in the container securityContext.)',
+        }]);
+    });
+
+    it('should parse terraform validate and tflint reports', () => {
+        const validate = JSON.stringify({
+            valid: false,
+            diagnostics: [{ severity: 'error', summary: 'Unsupported argument', detail: 'An argument named "instance_typ" is not expected here.', range: { filename: 'main.tf', start: { line: 3, column: 3 } } }],
+        });
+        expect(parseTerraformValidateOutput(validate, root)).toEqual([
+            { file: join(root, 'main.tf'), line: 3, column: 3, severity: 'error', message: 'Unsupported argument: An argument named "instance_typ" is not expected here.', source: 'terraform' },
+        ]);
+        const tflint = JSON.stringify({
+            issues: [{ rule: { name: 'terraform_unused_declarations', severity: 'warning' }, message: 'variable "region" is declared but not used', range: { filename: 'variables.tf', start: { line: 1, column: 1 } } }],
+            errors: [{ message: 'Failed to load configurations', severity: 'error' }],
+        });
+        expect(parseTflintOutput(tflint, root)).toEqual([
+            { file: join(root, 'variables.tf'), line: 1, column: 1, severity: 'warning', message: 'variable "region" is declared but not used', rule: 'terraform_unused_declarations', source: 'tflint' },
+            { file: root, line: 1, column: 0, severity: 'error', message: 'Failed to load configurations', source: 'tflint' },
+        ]);
+    });
+
+    it('should say which tools are missing', async () => {
+        if (!await commandExists('kubeconform') && !await commandExists('kubeval') && !await commandExists('kube-linter')) {
+            const result: any = await validateK8sTool.run({ path: root });
+            expect(result.success).toBe(true);
+            expect(result.warnings).toEqual([
+                'Neither kubeconform nor kubeval is installed, so the manifests were not validated against the schemas',
+                'kube-linter is not installed, so the manifests were not linted',
+            ]);
+        }
+        if (!await commandExists('terraform') && !await commandExists('tofu')) {
+            expect((await validateTerraformTool.run({ path: root })).errors).toEqual(['Neither terraform nor tofu is installed']);
+        }
+        expect(validateTerraformTool.mutates({ path: root, init: false })).toBe(false);
+    });
+});
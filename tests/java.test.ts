@@ -0,0 +1,128 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { parseJavaCompilerOutput, parseJUnitXml, summarizeTests } from '../src/diagnostics/index.js';
+import { findJUnitReports, collectTestResults } from '../src/tools/java.js';
+import { parseXml } from '../src/utils/xml.js';
+
+const SUREFIRE_REPORT = `<?xml version="1.0" encoding="UTF-8"?>
+<testsuite xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" name="com.acme.CalculatorTest" time="0.052" tests="4" errors="1" skipped="1" failures="1">
+  <properties>
+    <property name="java.version" value="21"/>
+  </properties>
+  <testcase name="adds" classname="com.acme.CalculatorTest" time="0.004"/>
+  <testcase name="divides" classname="com.acme.CalculatorTest" time="0.011">
+    <failure message="expected: &lt;2&gt; but was: &lt;3&gt;" type="org.opentest4j.AssertionFailedError"><![CDATA[org.opentest4j.AssertionFailedError: expected: <2> but was: <3>
+	at org.junit.jupiter.api.AssertionUtils.fail(AssertionUtils.java:151)
+	at com.acme.CalculatorTest.divides(CalculatorTest.java:27)
+]]></failure>
+  </testcase>
+  <testcase name="parses" classname="com.acme.CalculatorTest" time="0.002">
+    <error message="boom" type="java.lang.IllegalStateException">java.lang.IllegalStateException: boom
+	at com.acme.Parser.parse(Parser.java:10)
+	at com.acme.CalculatorTest.parses(CalculatorTest.java:33)</error>
+  </testcase>
+  <testcase name="later" classname="com.acme.CalculatorTest" time="0">
+    <skipped message="not ready"/>
+  </testcase>
+</testsuite>
+`;
+
+describe('JUnit reports', () => {
+    it('should parse XML with entities and CDATA', () => {
+        const root = parseXml('<a x="1 &amp; 2"><b><![CDATA[<raw>]]></b><c/></a>');
+        expect(root?.attributes.x).toBe('1 & 2');
+        expect(root?.children.map(c => c.name)).toEqual(['b', 'c']);
+        expect(root?.children[0]?.text).toBe('<raw>');
+    });
+
+    it('should map surefire test cases into the common structure', () => {
+        const tests = parseJUnitXml(SUREFIRE_REPORT);
+        expect(tests.map(t => t.status)).toEqual(['passed', 'failed', 'error', 'skipped']);
+        expect(tests[1]).toMatchObject({
+            suite: 'com.acme.CalculatorTest',
+            name: 'divides',
+            durationMs: 11,
+            message: 'expected: <2> but was: <3>',
+            file: 'com/acme/CalculatorTest.java',
+            line: 27,
+        });
+        expect(tests[2]?.line).toBe(33);
+        expect(tests[3]?.message).toBe('not ready');
+        expect(summarizeTests(tests)).toEqual({ total: 4, passed: 1, failed: 1, errored: 1, skipped: 1, durationMs: 17 });
+    });
+
+    it('should read every suite under a testsuites root', () => {
+        const xml = '<testsuites><testsuite name="A"><testcase name="one"/></testsuite><testsuite name="B"><testcase name="two" classname="B"/></testsuite></testsuites>';
+        expect(parseJUnitXml(xml).map(t => `${t.suite}.${t.name}`)).toEqual(['A.one', 'B.two']);
+    });
+});
+
+describe('Java compiler output', () => {
+    it('should parse Maven compiler errors with their details', () => {
+        const output = [
+            '[INFO] Compiling 3 source files',
+            '[ERROR] /work/src/main/java/com/acme/App.java:[12,9] cannot find symbol',
+            '  symbol:   method missing()',
+            '  location: class com.acme.App',
+            '[WARNING] /work/src/main/java/com/acme/Old.java:[5,1] [deprecation] Date in java.util has been deprecated',
+            '[ERROR] COMPILATION ERROR : ',
+            '[ERROR] /work/src/main/java/com/acme/App.java:[12,9] cannot find symbol',
+        ].join('\n');
+        const diagnostics = parseJavaCompilerOutput(output, '/work');
+        expect(diagnostics).toHaveLength(2);
+        expect(diagnostics[0]).toMatchObject({ file: '/work/src/main/java/com/acme/App.java', line: 12, column: 9, severity: 'error' });
+        expect(diagnostics[0]?.message).toBe('cannot find symbol; symbol: method missing(); location: class com.acme.App');
+        expect(diagnostics[1]?.severity).toBe('warning');
+    });
+
+    it('should parse Gradle javac and kotlinc errors', () => {
+        const output = [
+            '> Task :compileJava FAILED',
+            'src/main/java/App.java:4: error: \';\' expected',
+            '        int x = 1',
+            '                 ^',
+            'e: file:///work/src/main/kotlin/Main.kt:3:5 Unresolved reference: foo',
+        ].join('\n');
+        const diagnostics = parseJavaCompilerOutput(output, '/work');
+        expect(diagnostics.map(d => `${d.source} ${d.file}:${d.line}`)).toEqual([
+            'javac /work/src/main/java/App.java:4',
+            'kotlinc /work/src/main/kotlin/Main.kt:3',
+        ]);
+    });
+});
+
+describe('Report discovery', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-java-'));
+        await fs.mkdir(join(root, 'core/target/surefire-reports'), { recursive: true });
+        await fs.mkdir(join(root, 'core/src/test/java/com/acme'), { recursive: true });
+        await fs.mkdir(join(root, 'api/build/test-results/test'), { recursive: true });
+        await fs.writeFile(join(root, 'core/target/surefire-reports/TEST-com.acme.CalculatorTest.xml'), SUREFIRE_REPORT);
+        await fs.writeFile(join(root, 'core/target/surefire-reports/com.acme.CalculatorTest.txt'), 'summary');
+        await fs.writeFile(join(root, 'core/src/test/java/com/acme/CalculatorTest.java'), 'class CalculatorTest {}');
+        await fs.writeFile(join(root, 'api/build/test-results/test/TEST-ApiTest.xml'), '<testsuite name="ApiTest"><testcase name="ok"/></testsuite>');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should find surefire and gradle reports in every module', async () => {
+        const reports = await findJUnitReports(root, 0);
+        expect(reports.map(r => r.slice(root.length + 1))).toEqual([
+            'api/build/test-results/test/TEST-ApiTest.xml',
+            'core/target/surefire-reports/TEST-com.acme.CalculatorTest.xml',
+        ]);
+        expect(await findJUnitReports(root, Date.now() + 60000)).toHaveLength(0);
+    });
+
+    it('should resolve failure locations to test sources', async () => {
+        const tests = await collectTestResults(await findJUnitReports(root, 0));
+        const failed = tests.find(t => t.name === 'divides');
+        expect(failed?.file).toBe(join(root, 'core/src/test/java/com/acme/CalculatorTest.java'));
+    });
+});
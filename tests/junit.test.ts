@@ -0,0 +1,68 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { parseJUnitXml, toTestReport, type TestCaseResult } from '../src/diagnostics/index.js';
+import { toJUnitXml } from '../src/diagnostics/junit.js';
+import { exportJUnitTool } from '../src/tools/junit.js';
+import { runPipeline } from '../src/tools/pipeline.js';
+import { parseCliArgs } from '../src/cli.js';
+
+const TESTS: TestCaseResult[] = [
+    { suite: 'example.com/calc', name: 'TestAdd', status: 'passed', durationMs: 12 },
+    { suite: 'example.com/calc', name: 'TestDiv/by_zero', status: 'failed', durationMs: 3, message: 'expected error, got nil', details: 'calc_test.go:7: expected error, got nil\n]]> <&>', file: 'calc_test.go', line: 7 },
+    { suite: 'tests/test_app.py', name: 'test_env', status: 'skipped', durationMs: 0, message: 'needs "DB"' },
+    { suite: 'tests/test_app.py', name: 'test_boot', status: 'error', durationMs: 1500, message: 'ImportError\nsecond line', output: 'booting\u0007' },
+];
+
+describe('JUnit export', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-junit-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, '.code-feedback.yaml'), 'pipelines:\n  default:\n    - { name: build, command: "true" }\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should write a testsuite per suite that parses back to the same results', () => {
+        const xml = toJUnitXml(TESTS);
+        expect(xml).toContain('<testsuites name="code-feedback" tests="4" failures="1" errors="1" skipped="1" time="1.515">');
+        expect(xml).toContain('<testsuite name="example.com/calc" tests="2" failures="1" errors="0" skipped="0" time="0.015">');
+        expect(xml).toContain('<skipped message="needs &quot;DB&quot;"/>');
+        expect(xml).toContain('<error message="ImportError"/>');
+        expect(xml).not.toContain('\u0007');
+
+        const parsed = parseJUnitXml(xml);
+        expect(parsed.map(t => [t.suite, t.name, t.status, t.durationMs])).toEqual(TESTS.map(t => [t.suite, t.name, t.status, t.durationMs]));
+        expect(parsed[1]).toMatchObject({ message: 'expected error, got nil', details: TESTS[1]!.details, file: 'calc_test.go' });
+        expect(parsed[3]!.output).toBe('booting');
+        expect(toJUnitXml([], { name: 'empty' })).toBe('<?xml version="1.0" encoding="UTF-8"?>\n<testsuites name="empty" tests="0" failures="0" errors="0" skipped="0" time="0.000">\n</testsuites>\n');
+    });
+
+    it('should keep the test cases pipeline steps report', async () => {
+        const tool = { name: 'go', inputSchema: { properties: {} }, run: async () => ({ success: false, errors: [], warnings: [], output: '', tests: toTestReport(TESTS) }) };
+        const results = await runPipeline([{ tool: 'go' }], root, [tool], () => true);
+        expect(results[0]!.tests).toEqual(TESTS);
+    });
+
+    it('should export given tests to a file and warn when a pipeline ran none', async () => {
+        const result: any = await exportJUnitTool.run({ path: root, tests: TESTS, outputFile: 'reports/junit.xml' });
+        expect(result.success).toBe(true);
+        expect(result.output).toBe(`4 tests (1 failed, 1 errored, 1 skipped) written to ${join(root, 'reports', 'junit.xml')}`);
+        expect(await fs.readFile(join(root, 'reports', 'junit.xml'), 'utf-8')).toBe(result.xml);
+
+        const empty: any = await exportJUnitTool.run({ path: root, pipeline: 'default' });
+        expect(empty.success).toBe(true);
+        expect(empty.warnings).toEqual(['No step of pipeline default reported test results']);
+        expect((await exportJUnitTool.run({ path: root })).errors).toEqual(['Pass tests or a pipeline to run']);
+    });
+
+    it('should parse --junit-out in batch mode', () => {
+        expect(parseCliArgs(['export-sarif', '--junit-out', 'junit.xml'], {})).toEqual({ command: 'export-sarif', path: '.', pipeline: 'default', junitOut: 'junit.xml' });
+    });
+});
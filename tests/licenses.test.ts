@@ -0,0 +1,81 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { evaluateLicense, licenseCheckTool, parseGoLicensesReport, parseLicenseCheckerJson, parsePipLicensesJson } from '../src/tools/licenses.js';
+
+describe('license checks', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-licenses-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'package.json'), '{"name":"app","version":"1.0.0"}');
+        await fs.writeFile(join(root, '.code-feedback.yaml'), 'licenses:\n  allow: [MIT, Apache-2.0]\n  deny: [GPL-3.0]\n  ignore: [reviewed]\n');
+        // Stands in for license-checker, which is not installed here
+        const report = {
+            'left-pad@1.3.0': { licenses: 'MIT' },
+            '@scope/dual@2.0.0': { licenses: '(MIT OR GPL-3.0)' },
+            'copyleft@0.1.0': { licenses: 'GPL-3.0' },
+            'odd@1.0.0': { licenses: 'BSD-3-Clause' },
+            'reviewed@1.0.0': { licenses: 'UNKNOWN' },
+        };
+        await fs.mkdir(join(root, 'node_modules', '.bin'), { recursive: true });
+        await fs.writeFile(join(root, 'node_modules', '.bin', 'license-checker'), `#!/bin/sh\ncat <<'EOF'\n${JSON.stringify(report)}\nEOF\n`, { mode: 0o755 });
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should parse scanner output', () => {
+        expect(parseGoLicensesReport('github.com/a/b,https://github.com/a/b/blob/v1/LICENSE,MIT\ngithub.com/c/d,Unknown,Unknown\n')).toEqual([
+            { package: 'github.com/a/b', license: 'MIT' },
+            { package: 'github.com/c/d', license: 'Unknown' },
+        ]);
+        expect(parseLicenseCheckerJson('{"@types/node@20.1.0":{"licenses":"MIT*"},"x@1.0.0":{"licenses":["MIT","ISC"]}}')).toEqual([
+            { package: '@types/node', version: '20.1.0', license: 'MIT' },
+            { package: 'x', version: '1.0.0', license: 'MIT OR ISC' },
+        ]);
+        expect(parsePipLicensesJson('[{"Name":"requests","Version":"2.31.0","License":"Apache Software License"},{"Name":"x","Version":"1","License":"UNKNOWN"}]')).toEqual([
+            { package: 'requests', version: '2.31.0', license: 'Apache Software License' },
+            { package: 'x', version: '1', license: 'UNKNOWN' },
+        ]);
+    });
+
+    it('should evaluate SPDX expressions against the policy', () => {
+        const policy = { allow: ['MIT', 'Apache-2.0'], deny: ['GPL-3.0'] };
+        expect(evaluateLicense('mit', policy)).toBe('allowed');
+        expect(evaluateLicense('MIT OR GPL-3.0', policy)).toBe('allowed');
+        expect(evaluateLicense('MIT AND GPL-3.0', policy)).toBe('denied');
+        expect(evaluateLicense('(MIT OR BSD-2-Clause) AND Apache-2.0', policy)).toBe('allowed');
+        expect(evaluateLicense('(MIT) AND (BSD-2-Clause)', policy)).toBe('unlisted');
+        expect(evaluateLicense('Apache-2.0 WITH LLVM-exception', policy)).toBe('allowed');
+        expect(evaluateLicense('UNKNOWN', policy)).toBe('unknown');
+        expect(evaluateLicense('BSD-3-Clause', { deny: ['GPL-3.0'] })).toBe('allowed');
+        expect(evaluateLicense('GPL-3.0', { deny: ['GPL-3.0'] })).toBe('denied');
+    });
+
+    it('should report violations of the project policy', async () => {
+        const result: any = await licenseCheckTool.run({ projectPath: root });
+        expect(result.ecosystem).toBe('npm');
+        expect(result.success).toBe(false);
+        expect(result.errors).toEqual([
+            'copyleft@0.1.0 (GPL-3.0): license is denied',
+            'odd@1.0.0 (BSD-3-Clause): license is not in the allow list',
+        ]);
+        const verdicts = Object.fromEntries(result.dependencies.map((d: any) => [d.package, d.verdict]));
+        expect(verdicts).toEqual({ 'left-pad': 'allowed', '@scope/dual': 'allowed', copyleft: 'denied', odd: 'unlisted', reviewed: 'ignored' });
+    });
+
+    it('should let the call widen the allow list', async () => {
+        const result: any = await licenseCheckTool.run({ projectPath: root, allow: ['MIT', 'Apache-2.0', 'BSD-3-Clause'] });
+        expect(result.errors).toEqual(['copyleft@0.1.0 (GPL-3.0): license is denied']);
+    });
+
+    it('should reject paths outside the allowed roots', async () => {
+        const result: any = await licenseCheckTool.run({ projectPath: '/definitely/not/allowed' });
+        expect(result.errors).toEqual(['Path not allowed']);
+    });
+});
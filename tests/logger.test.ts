@@ -0,0 +1,49 @@
+import { describe, it, expect } from 'vitest';
+import { Logger, getRequestId, withLogContext } from '../src/utils/logger.js';
+
+function capture(options: ConstructorParameters<typeof Logger>[0] = {}) {
+    const lines: string[] = [];
+    return { lines, logger: new Logger({ ...options, write: line => lines.push(line) }) };
+}
+
+describe('Logger', () => {
+    it('should drop lines below the configured level', () => {
+        const { lines, logger } = capture({ level: 'warn', format: 'text' });
+        logger.debug('hidden');
+        logger.info('hidden');
+        logger.warn('shown');
+        logger.error('shown too');
+        expect(lines).toHaveLength(2);
+        expect(lines[0]).toMatch(/^\S+Z WARN  \[MCP\] shown$/);
+        logger.setLevel('debug');
+        logger.debug('now shown');
+        expect(lines).toHaveLength(3);
+    });
+
+    it('should write key=value text and JSON lines', () => {
+        const text = capture({ level: 'info', format: 'text' });
+        text.logger.info('Command failed', { command: 'go build ./...', exitCode: 1, error: new Error('boom') });
+        expect(text.lines[0]).toContain('[MCP] Command failed command="go build ./..." exitCode=1 error=boom');
+
+        const json = capture({ level: 'info', format: 'json' });
+        json.logger.error('Fatal error', { error: new Error('boom') });
+        const entry = JSON.parse(json.lines[0]!);
+        expect(entry.level).toBe('error');
+        expect(entry.msg).toBe('Fatal error');
+        expect(entry.error.message).toBe('boom');
+        expect(typeof entry.time).toBe('string');
+    });
+
+    it('should attach the request context to every line logged inside it', async () => {
+        const { lines, logger } = capture({ level: 'info', format: 'json' });
+        expect(getRequestId()).toBeUndefined();
+        await withLogContext({ requestId: 'req-1', tool: 'go' }, async () => {
+            await new Promise(resolve => setTimeout(resolve, 1));
+            expect(getRequestId()).toBe('req-1');
+            logger.info('Tool call');
+        });
+        logger.info('Outside');
+        expect(JSON.parse(lines[0]!)).toMatchObject({ msg: 'Tool call', requestId: 'req-1', tool: 'go' });
+        expect(JSON.parse(lines[1]!).requestId).toBeUndefined();
+    });
+});
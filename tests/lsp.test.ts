@@ -0,0 +1,106 @@
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { join } from 'path';
+import { PassThrough } from 'stream';
+import { pathToFileURL } from 'url';
+import { MessageReader, frameMessage, serveLsp, toLspDiagnostic } from '../src/lsp/index.js';
+import { parseCliArgs } from '../src/cli.js';
+import type { Diagnostic } from '../src/diagnostics/index.js';
+
+async function waitFor(condition: () => boolean, timeoutMs = 3000): Promise<void> {
+    const deadline = Date.now() + timeoutMs;
+    while (!condition()) {
+        if (Date.now() > deadline) throw new Error('Timed out waiting for condition');
+        await new Promise(resolve => setTimeout(resolve, 20));
+    }
+}
+
+describe('LSP facade', () => {
+    let root: string;
+
+    beforeEach(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-lsp-'));
+        await fs.writeFile(join(root, 'main.go'), 'package main\n');
+    });
+
+    afterEach(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should frame and split messages across chunks', () => {
+        const reader = new MessageReader();
+        const framed = Buffer.concat([frameMessage({ id: 1, text: 'héllo' }), frameMessage({ id: 2 })]);
+        expect(reader.push(framed.subarray(0, 10))).toEqual([]);
+        expect(reader.push(framed.subarray(10, framed.length - 3))).toEqual([{ id: 1, text: 'héllo' }]);
+        expect(reader.push(framed.subarray(framed.length - 3))).toEqual([{ id: 2 }]);
+        expect(() => new MessageReader().push(Buffer.from('Content-Type: x\r\n\r\n'))).toThrow('Invalid message header');
+    });
+
+    it('should convert positions to 0-based ranges', () => {
+        const d: Diagnostic = { file: 'a.go', line: 3, column: 5, severity: 'warning', message: 'unused', rule: 'U1000', source: 'staticcheck' };
+        expect(toLspDiagnostic(d)).toEqual({ range: { start: { line: 2, character: 4 }, end: { line: 2, character: 4 } }, severity: 2, source: 'staticcheck', message: 'unused', code: 'U1000' });
+        expect(toLspDiagnostic({ ...d, column: 0 }).range.end.character).toBe(Number.MAX_SAFE_INTEGER);
+        expect(toLspDiagnostic({ ...d, line: 0, column: 0, severity: 'info' })).toMatchObject({ range: { start: { line: 0, character: 0 } }, severity: 3 });
+    });
+
+    it('should publish pipeline diagnostics and clear fixed files', async () => {
+        const input = new PassThrough();
+        const output = new PassThrough();
+        const received: any[] = [];
+        const reader = new MessageReader();
+        output.on('data', chunk => received.push(...reader.push(chunk)));
+
+        const d = (file: string, message: string): Diagnostic => ({ file: join(root, file), line: 1, column: 1, severity: 'error', message, source: 'go build' });
+        const results = [
+            [d('main.go', 'undefined: x'), d('util.go', 'missing return')],
+            [d('main.go', 'undefined: y')],
+        ];
+        let runs = 0;
+        const run = async () => {
+            const diagnostics = results[Math.min(runs++, results.length - 1)]!;
+            return { diagnostics, verdict: { passed: false, summary: `FAIL: ${diagnostics.length} errors` } };
+        };
+        const exited = serveLsp({ input, output, root: '.', pipeline: 'default', version: '1.2.3', debounceMs: 100, run });
+        const publishes = () => received.filter(m => m.method === 'textDocument/publishDiagnostics');
+
+        input.write(frameMessage({ jsonrpc: '2.0', id: 0, method: 'hover', params: {} }));
+        input.write(frameMessage({ jsonrpc: '2.0', id: 1, method: 'initialize', params: { rootUri: pathToFileURL(root).href } }));
+        await waitFor(() => received.length === 2);
+        expect(received[0]).toMatchObject({ id: 0, error: { code: -32002 } });
+        expect(received[1]).toMatchObject({ id: 1, result: { capabilities: { textDocumentSync: { openClose: true, change: 0 } }, serverInfo: { version: '1.2.3' } } });
+
+        input.write(frameMessage({ jsonrpc: '2.0', method: 'initialized', params: {} }));
+        await waitFor(() => publishes().length === 2);
+        const main = pathToFileURL(join(root, 'main.go')).href;
+        const util = pathToFileURL(join(root, 'util.go')).href;
+        expect(publishes().map(m => m.params.uri).sort()).toEqual([main, util].sort());
+        expect(received.find(m => m.method === 'window/logMessage').params).toEqual({ type: 2, message: 'FAIL: 2 errors' });
+
+        await fs.writeFile(join(root, 'main.go'), 'package main\n\nvar y = x\n');
+        await waitFor(() => publishes().length === 4);
+        const [first, second] = publishes().slice(2);
+        expect(first.params).toEqual({ uri: util, diagnostics: [] });
+        expect(second.params.uri).toBe(main);
+        expect(second.params.diagnostics[0].message).toBe('undefined: y');
+
+        input.write(frameMessage({ jsonrpc: '2.0', id: 2, method: 'textDocument/definition', params: {} }));
+        input.write(frameMessage({ jsonrpc: '2.0', id: 3, method: 'shutdown' }));
+        input.write(frameMessage({ jsonrpc: '2.0', method: 'exit' }));
+        expect(await exited).toBe(0);
+        expect(received.find(m => m.id === 2).error.code).toBe(-32601);
+        expect(received.find(m => m.id === 3)).toEqual({ jsonrpc: '2.0', id: 3, result: null });
+    });
+
+    it('should exit with 1 without a shutdown request', async () => {
+        const input = new PassThrough();
+        const exited = serveLsp({ input, output: new PassThrough(), root, pipeline: 'default', version: '0', run: async () => ({}) });
+        input.write(frameMessage({ jsonrpc: '2.0', method: 'exit' }));
+        expect(await exited).toBe(1);
+    });
+
+    it('should parse the lsp command', () => {
+        expect(parseCliArgs(['lsp', '--stdio'])).toEqual({ command: 'lsp', path: '.', pipeline: 'default' });
+        expect(parseCliArgs(['lsp', 'quick', '--path=src'])).toEqual({ command: 'lsp', path: 'src', pipeline: 'quick' });
+    });
+});
@@ -0,0 +1,49 @@
+import { describe, it, expect } from 'vitest';
+import { Metrics, metrics } from '../src/metrics/index.js';
+import { getMetricsTool } from '../src/tools/metrics.js';
+
+describe('Metrics', () => {
+    it('should count calls by outcome and track calls in flight', () => {
+        const registry = new Metrics();
+        const first = registry.start('go');
+        const second = registry.start('go');
+        expect(registry.snapshot().tools[0]).toMatchObject({ tool: 'go', calls: 0, inFlight: 2 });
+        first.finish('success');
+        second.finish('failure');
+        // A second finish is ignored
+        second.finish('failure');
+        registry.start('editor').finish('rejected');
+        registry.start('go').finish('success', { cached: true });
+
+        const [editor, go] = registry.snapshot().tools;
+        expect(go).toMatchObject({ tool: 'go', calls: 3, success: 2, failure: 1, cached: 1, inFlight: 0 });
+        expect(go!.failureRate).toBeCloseTo(1 / 3);
+        expect(editor).toMatchObject({ tool: 'editor', calls: 1, rejected: 1, meanMs: 0 });
+    });
+
+    it('should render the Prometheus text format', () => {
+        const registry = new Metrics();
+        registry.start('go "quoted"').finish('success');
+        const text = registry.render();
+        expect(text).toContain('# TYPE code_feedback_tool_calls_total counter');
+        expect(text).toContain('code_feedback_tool_calls_total{tool="go \\"quoted\\"",status="success"} 1');
+        expect(text).toContain('code_feedback_tool_duration_seconds_bucket{tool="go \\"quoted\\"",le="0.05"} 1');
+        expect(text).toContain('code_feedback_tool_duration_seconds_bucket{tool="go \\"quoted\\"",le="+Inf"} 1');
+        expect(text).toContain('code_feedback_tool_duration_seconds_count{tool="go \\"quoted\\""} 1');
+        expect(text).toMatch(/^code_feedback_cache_hits_total \d+$/m);
+        expect(text).toMatch(/^code_feedback_scheduler_queued \d+$/m);
+        expect(text.endsWith('\n')).toBe(true);
+    });
+
+    it('should report metrics through get_metrics', async () => {
+        metrics.start('lint').finish('error');
+        const result: any = await getMetricsTool.run({ tool: 'lint' });
+        expect(result.success).toBe(true);
+        expect(result.tools).toHaveLength(1);
+        expect(result.tools[0]).toMatchObject({ tool: 'lint', error: 1, failureRate: 1 });
+        expect(result.output).toContain('lint: 1 call(s), 100.0% failed');
+
+        const prometheus: any = await getMetricsTool.run({ format: 'prometheus' });
+        expect(prometheus.output).toContain('code_feedback_tool_calls_total{tool="lint",status="error"} 1');
+    });
+});
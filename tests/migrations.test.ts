@@ -0,0 +1,103 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { execSync } from 'child_process';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { checkMigrations, detectMigrations, gooseSection } from '../src/migrations/index.js';
+import { validateMigrationsTool } from '../src/tools/migrations.js';
+import { commandExists } from '../src/utils/command.js';
+
+async function write(root: string, files: Record<string, string>) {
+    for (const [name, content] of Object.entries(files)) {
+        await fs.mkdir(join(root, name, '..'), { recursive: true });
+        await fs.writeFile(join(root, name), content);
+    }
+}
+
+describe('validate_migrations', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-migrations-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should detect migration sets and check them statically', async () => {
+        const dir = join(root, 'static');
+        await write(dir, {
+            'db/migrations/1_users.up.sql': 'CREATE TABLE users (id INTEGER PRIMARY KEY);\n',
+            'db/migrations/1_users.down.sql': 'DROP TABLE users;\n',
+            'db/migrations/2_orders.up.sql': 'CREATE TABLE orders (id INTEGER PRIMARY KEY);\n',
+            'db/migrations/4_items.up.sql': 'CREATE TABLE items (id INTEGER);\n',
+            'db/migrations/4_items.down.sql': 'DROP TABLE items;\n',
+            'goose/00001_init.sql': '-- +goose Up\nCREATE TABLE a (id INT);\n\n-- +goose Down\nDROP TABLE a;\n',
+            'goose/00002_fn.sql': '-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n',
+            'alembic/versions/a1_base.py': 'revision = "a1"\ndown_revision = None\n',
+            'alembic/versions/b2_left.py': 'revision = "b2"\ndown_revision = "a1"\n',
+            'alembic/versions/c3_right.py': 'revision: str = "c3"\ndown_revision: Union[str, None] = "a1"\n',
+        });
+        const sets = await detectMigrations(dir);
+        expect(sets.map(s => `${s.tool} ${s.migrations.map(m => m.version).join(',')}`)).toEqual([
+            'alembic a1,b2,c3',
+            'golang-migrate 1,2,4',
+            'goose 00001,00002',
+        ]);
+        const rules = async (index: number) => (await checkMigrations(sets[index]!)).map(d => `${d.rule} ${d.severity}`);
+        expect(await rules(0)).toEqual(['multiple-heads error', 'multiple-heads error']);
+        expect(await rules(1)).toEqual(['missing-down warning', 'sequence-gap warning']);
+        expect(await rules(2)).toEqual(['missing-down warning', 'unbalanced-statement error']);
+        expect(gooseSection('-- +goose Up\nA;\n-- +goose Down\nB;', 'down')).toBe('\n\n-- +goose Down\nB;');
+    });
+
+    it('should flag migrations added before committed ones', async () => {
+        const dir = join(root, 'ordered');
+        await write(dir, {
+            'migrations/20240101000000_users.up.sql': 'CREATE TABLE users (id INTEGER);\n',
+            'migrations/20240101000000_users.down.sql': 'DROP TABLE users;\n',
+            'migrations/20240301000000_orders.up.sql': 'CREATE TABLE orders (id INTEGER);\n',
+            'migrations/20240301000000_orders.down.sql': 'DROP TABLE orders;\n',
+        });
+        const git = (command: string) => execSync(`git -c user.name=t -c user.email=t@example.com ${command}`, { cwd: dir, stdio: 'pipe' });
+        git('init -q');
+        git('add -A');
+        git('commit -qm init');
+        await write(dir, {
+            'migrations/20240201000000_items.up.sql': 'CREATE TABLE items (id INTEGER);\n',
+            'migrations/20240201000000_items.down.sql': 'DROP TABLE items;\n',
+        });
+        const result: any = await validateMigrationsTool.run({ path: dir, run: false });
+        expect(result.success).toBe(false);
+        expect(result.diagnostics).toHaveLength(1);
+        expect(result.diagnostics[0]).toMatchObject({ file: join(dir, 'migrations', '20240201000000_items.up.sql'), rule: 'out-of-order', severity: 'error', source: 'validate_migrations' });
+        expect(result.sets).toEqual([{ tool: 'golang-migrate', dir: join(dir, 'migrations'), migrations: 3, latest: '20240301000000' }]);
+    });
+
+    it('should run SQL migrations up and down on SQLite', async () => {
+        if (!await commandExists('sqlite3')) return;
+        const dir = join(root, 'sqlite');
+        await write(dir, {
+            'migrations/1_users.up.sql': 'CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);\n',
+            'migrations/1_users.down.sql': 'DROP TABLE users;\n',
+            'migrations/2_index.up.sql': 'CREATE INDEX users_name ON users (name);\n',
+            'migrations/2_index.down.sql': '-- drop the index\nDROP INDEX users_by_name;\n',
+        });
+        const result: any = await validateMigrationsTool.run({ path: dir, engine: 'sqlite' });
+        expect(result.success).toBe(false);
+        expect(result.engine).toBe('sqlite');
+        expect(result.steps.map((s: any) => `${s.direction} ${s.version} ${s.success}`)).toEqual(['up 1 true', 'up 2 true', 'down 2 false']);
+        expect(result.diagnostics).toHaveLength(1);
+        expect(result.diagnostics[0]).toMatchObject({ file: join(dir, 'migrations', '2_index.down.sql'), line: 2, rule: 'sql-error' });
+        expect(result.diagnostics[0].message).toContain('no such index: users_by_name');
+
+        await fs.writeFile(join(dir, 'migrations', '2_index.down.sql'), 'DROP INDEX users_name;\n');
+        const fixed: any = await validateMigrationsTool.run({ path: dir, engine: 'sqlite' });
+        expect(fixed.success).toBe(true);
+        expect(fixed.steps).toHaveLength(6);
+        expect(fixed.output).toContain('ran 6/6 step(s) on sqlite');
+    });
+});
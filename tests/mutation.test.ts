@@ -0,0 +1,96 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { execSync } from 'child_process';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { generateMutants, maskCode, pythonFunctions } from '../src/mutation/index.js';
+import { mutationTestTool } from '../src/tools/mutation.js';
+import { commandExists } from '../src/utils/command.js';
+
+describe('mutation_test', () => {
+    let root: string;
+    const git = (command: string) => execSync(`git -c user.name=t -c user.email=t@example.com ${command}`, { cwd: root, stdio: 'pipe' });
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-mutation-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/calc\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'calc.go'), 'package calc\n\nfunc Sign(n int) int {\n\treturn 1\n}\n');
+        await fs.writeFile(join(root, 'calc_test.go'), 'package calc\n\nimport "testing"\n\nfunc TestSign(t *testing.T) {\n\tif Sign(3) != 1 {\n\t\tt.Fatal("Sign(3)")\n\t}\n}\n');
+        git('init -q');
+        git('add -A');
+        git('commit -qm init');
+        // The change under test: only the positive case is checked
+        await fs.writeFile(join(root, 'calc.go'), 'package calc\n\nfunc Sign(n int) int {\n\tif n < 0 {\n\t\treturn -1\n\t}\n\treturn 1\n}\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should mask strings and comments', () => {
+        expect(maskCode(['x := "a < b" // c > d', 'y := `raw', '< still` + z'], 'go')).toEqual(['x := "     "         ', 'y := `   ', '       ` + z']);
+        expect(maskCode(['s = """a', 'b > c""" if x else y  # z'], 'python')).toEqual(['s = """ ', '     """ if x else y     ']);
+    });
+
+    it('should generate mutants outside strings and signatures', () => {
+        const go = 'func f(a, b int) bool {\n\tif a < b && ok {\n\t\treturn a+1 == b - 1\n\t}\n\treturn msg == "a < b"\n}';
+        expect(generateMutants(go, 'go', [1, 2, 3, 5]).map(m => `${m.line}:${m.column} ${m.operator} ${m.replacement}`)).toEqual([
+            '2:7 expression/comparison <=',
+            '2:11 expression/logical ||',
+            '2:1 branch/if if !(a < b && ok) {',
+            '3:14 expression/negate !=',
+            '3:19 expression/arithmetic +',
+            '5:13 expression/negate !=',
+        ]);
+        const py = 'def f(x):\n    if x is not None and x > 0:\n        return True\n    return x';
+        expect(generateMutants(py, 'python', [2, 3, 4]).map(m => m.mutatedLine.trim())).toEqual([
+            'if x is not None and x >= 0:',
+            'if x is not None or x > 0:',
+            'if x is None and x > 0:',
+            'if not (x is not None and x > 0):',
+            'return False',
+            'return None',
+            'return None',
+        ]);
+        expect(pythonFunctions(py.split('\n').concat('', 'class A:', '    def g(self):', '        pass'))).toEqual([
+            { name: 'f', start: 1, end: 4 },
+            { name: 'g', start: 7, end: 8 },
+        ]);
+    });
+
+    it('should report mutants the tests do not kill', async () => {
+        if (!await commandExists('go')) return;
+        const result: any = await mutationTestTool.run({ path: root, timeout: 300000 });
+        expect(result.success).toBe(false);
+        expect(result.mutants.map((m: any) => `${m.line} ${m.operator} ${m.status}`)).toEqual([
+            '4 expression/comparison survived',
+            '4 branch/if killed',
+        ]);
+        expect(result.diagnostics[0]).toMatchObject({ file: join(root, 'calc.go'), line: 4, severity: 'warning', rule: 'expression/comparison', source: 'mutation_test' });
+        expect(result.diagnostics[0].message).toBe('Mutant survived in Sign: < replaced with <= and no test failed');
+        expect(result.score).toBe(50);
+        // The tree is never written for Go mutants
+        expect(await fs.readFile(join(root, 'calc.go'), 'utf-8')).toContain('if n < 0 {');
+
+        await fs.writeFile(join(root, 'sign_test.go'), 'package calc\n\nimport "testing"\n\nfunc TestSignZero(t *testing.T) {\n\tif Sign(-2) != -1 || Sign(0) != 1 {\n\t\tt.Fatal("Sign(0)")\n\t}\n}\n');
+        const graded: any = await mutationTestTool.run({ path: root, timeout: 300000 });
+        expect(graded.summary).toEqual({ mutants: 2, killed: 2, timeout: 0, survived: 0, invalid: 0 });
+        expect(graded.success).toBe(true);
+    }, 300000);
+
+    it('should refuse to grade when the tests already fail', async () => {
+        if (!await commandExists('python3')) return;
+        await fs.writeFile(join(root, 'util.py'), 'def clamp(x):\n    return x if x > 0 else 0\n');
+        const result: any = await mutationTestTool.run({ path: root, files: ['util.py'], testCommand: 'python3 -c "raise SystemExit(1)"' });
+        expect(result.errors[0]).toContain('Tests fail before mutating');
+        expect(await fs.readFile(join(root, 'util.py'), 'utf-8')).toBe('def clamp(x):\n    return x if x > 0 else 0\n');
+
+        // A test command that checks nothing lets every mutant survive, and the file is restored
+        const weak: any = await mutationTestTool.run({ path: root, files: ['util.py'], testCommand: 'python3 -c "import util"', minScore: 0 });
+        expect(weak.success).toBe(true);
+        expect(weak.mutants.map((m: any) => `${m.operator} ${m.status}`)).toEqual(['expression/comparison survived', 'statement/return survived']);
+        expect(await fs.readFile(join(root, 'util.py'), 'utf-8')).toBe('def clamp(x):\n    return x if x > 0 else 0\n');
+    });
+});
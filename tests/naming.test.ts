@@ -0,0 +1,111 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { tokenize } from '../src/duplicates/index.js';
+import { goDeclarations, goPreferredName, GO_INITIALISMS, splitIdentifier, toSnakeCase } from '../src/naming/index.js';
+import { checkNamingTool } from '../src/tools/naming.js';
+
+const SERVER = `package server
+
+// Server will recieve requests.
+type HttpServer struct {
+	listenUrl string
+	Handler   http.Handler
+	sync.Mutex
+}
+
+const (
+	MAX_CONNS = 10
+	timeout   = 5
+)
+
+func (s *HttpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	user_id := r.Header.Get("X-User")
+	fmt.Println("could not retreive", user_id)
+}
+
+func Test_parse() {}
+
+var GrpcConn int
+`;
+
+const MODELS = `class user_profile:
+    def getName(self):
+        maxRetries = 3
+        return self.call(retryCount=maxRetries)
+
+    def setUp(self):
+        pass
+
+    def __init__(self):
+        MAX_SIZE = 1  # seperate from the limit
+`;
+
+describe('check_naming', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-naming-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'server.go'), SERVER);
+        await fs.writeFile(join(root, 'models.py'), MODELS);
+        await fs.writeFile(join(root, 'app.ts'), 'const acessToken = "x"; // TODO\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should split identifiers and suggest conventional names', () => {
+        expect(splitIdentifier('parseHTTPRequest_v2')).toEqual(['parse', 'HTTP', 'Request', 'v2']);
+        const initialisms = new Set(GO_INITIALISMS);
+        expect(goPreferredName('HttpServer', initialisms)).toBe('HTTPServer');
+        expect(goPreferredName('userId', initialisms)).toBe('userID');
+        expect(goPreferredName('user_id', initialisms)).toBe('userID');
+        expect(goPreferredName('idFor', initialisms)).toBe('idFor');
+        expect(goPreferredName('ServeHTTP', initialisms)).toBe('ServeHTTP');
+        expect(toSnakeCase('maxRetries')).toBe('max_retries');
+        expect(toSnakeCase('parseHTTPRequest')).toBe('parse_http_request');
+
+        const names = goDeclarations(tokenize(SERVER)).map(t => t.text);
+        expect(names).toEqual(['HttpServer', 'listenUrl', 'Handler', 'MAX_CONNS', 'timeout', 'ServeHTTP', 'user_id', 'Test_parse', 'GrpcConn']);
+    });
+
+    it('should report naming and spelling findings with suggestions', async () => {
+        const result: any = await checkNamingTool.run({ path: root });
+        expect(result.success).toBe(true);
+        const byFile = (name: string) => result.diagnostics.filter((d: any) => d.file === join(root, name)).map((d: any) => `${d.line}:${d.column} ${d.rule}: ${d.message}`);
+        expect(byFile('server.go')).toEqual([
+            '3:16 misspelling: "recieve" in comment is misspelled; did you mean "receive"?',
+            '4:6 initialism: HttpServer should be HTTPServer; initialisms keep one case in Go names',
+            '5:2 initialism: listenUrl should be listenURL; initialisms keep one case in Go names',
+            '11:2 all-caps: Go names use MixedCaps, not ALL_CAPS: MAX_CONNS',
+            '16:2 underscore: Go names use MixedCaps, not underscores: user_id should be userID',
+            '17:25 misspelling: "retreive" in string is misspelled; did you mean "retrieve"?',
+            '20:6 underscore: Go names use MixedCaps, not underscores: Test_parse should be TestParse',
+        ]);
+        expect(byFile('models.py')).toEqual([
+            '1:7 cap-words: Class user_profile should be CapWords: UserProfile',
+            '2:9 snake-case: Function getName should be snake_case: get_name',
+            '3:9 snake-case: Variable maxRetries should be snake_case: max_retries',
+            '10:25 misspelling: "seperate" in comment is misspelled; did you mean "separate"?',
+        ]);
+        expect(byFile('app.ts')).toEqual(['1:7 misspelling: "acess" in identifier acessToken is misspelled; did you mean "access"?']);
+        expect(result.totals).toEqual({ files: 3, naming: 8, misspellings: 4 });
+    });
+
+    it('should honor the allowlist, extra initialisms and options', async () => {
+        await fs.writeFile(join(root, '.code-feedback.yaml'), 'naming:\n  allow: [acess]\n  initialisms: [GRPC]\n');
+        const result: any = await checkNamingTool.run({ path: root, spelling: ['identifiers'], allow: ['retreive'] });
+        expect(result.diagnostics.filter((d: any) => d.rule === 'misspelling')).toEqual([]);
+
+        const conventions: any = await checkNamingTool.run({ path: join(root, 'server.go'), spelling: [] });
+        expect(conventions.diagnostics.map((d: any) => d.rule)).toEqual(['initialism', 'initialism', 'all-caps', 'underscore', 'underscore', 'initialism']);
+
+        const spelling: any = await checkNamingTool.run({ path: root, conventions: false, exclude: ['*.py', '*.ts'] });
+        expect(spelling.diagnostics.map((d: any) => d.line)).toEqual([3, 17]);
+        await fs.rm(join(root, '.code-feedback.yaml'));
+    });
+});
@@ -0,0 +1,95 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { dirname, join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { walkDirectory } from '../src/utils/gitignore.js';
+import { listTreeTool, searchFilesTool, compileSearchPattern } from '../src/tools/navigation.js';
+
+describe('Navigation tools', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-nav-'));
+        Config.getInstance().addAllowedPaths([root]);
+        const files: Record<string, string | Buffer> = {
+            '.git/HEAD': 'ref: refs/heads/main\n',
+            '.gitignore': 'node_modules/\n*.log\n/build\n',
+            '.env': 'TOKEN=abc\n',
+            'main.go': 'package main\n\nfunc main() {\n\t// TODO: flags\n\trun()\n}\n',
+            'pkg/run.go': 'package pkg\n\nfunc Run() {}\n// todo lowercase\n',
+            'pkg/.gitignore': 'generated.go\n',
+            'pkg/generated.go': 'package pkg // TODO generated\n',
+            'pkg/deep/deeper/leaf.txt': 'TODO leaf\n',
+            'node_modules/dep/index.js': '// TODO dependency\n',
+            'build/out.txt': 'TODO build\n',
+            'debug.log': 'TODO log\n',
+            'logo.png': Buffer.from([0x89, 0x50, 0x4e, 0x47, 0, 0, 0x54, 0x4f, 0x44, 0x4f]),
+        };
+        for (const [path, content] of Object.entries(files)) {
+            await fs.mkdir(dirname(join(root, path)), { recursive: true });
+            await fs.writeFile(join(root, path), content);
+        }
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should walk without ignored, hidden and VCS entries', async () => {
+        const seen: string[] = [];
+        await walkDirectory(root, {}, entry => { seen.push(entry.relativePath); });
+        expect(seen).toEqual(['logo.png', 'main.go', 'pkg', 'pkg/deep', 'pkg/deep/deeper', 'pkg/deep/deeper/leaf.txt', 'pkg/run.go']);
+
+        const all: string[] = [];
+        await walkDirectory(root, { respectGitignore: false, includeHidden: true, maxDepth: 1 }, entry => { all.push(entry.relativePath); });
+        expect(all).toEqual(['.env', '.gitignore', 'build', 'debug.log', 'logo.png', 'main.go', 'node_modules', 'pkg']);
+    });
+
+    it('should list a depth-limited tree', async () => {
+        const result: any = await listTreeTool.run({ path: root, maxDepth: 2 });
+        expect(result.success).toBe(true);
+        expect(result.entries.map((e: any) => e.relativePath)).toEqual(['logo.png', 'main.go', 'pkg', 'pkg/deep', 'pkg/run.go']);
+        expect(result.entries.find((e: any) => e.relativePath === 'pkg/deep').collapsed).toBe(true);
+        expect(result.output).toContain('  pkg/\n    deep/ …\n    run.go');
+        expect(result.output).toContain('2 directories, 3 file(s)');
+
+        const limited: any = await listTreeTool.run({ path: root, maxEntries: 2 });
+        expect(limited.truncated).toBe(true);
+        expect(limited.entries).toHaveLength(2);
+        expect((await listTreeTool.run({ path: root, dirsOnly: true }) as any).entries.every((e: any) => e.type === 'directory')).toBe(true);
+        expect((await listTreeTool.run({ path: '/definitely/elsewhere' }) as any).errors).toEqual(['Path not allowed']);
+    });
+
+    it('should search contents with context and globs', async () => {
+        const result: any = await searchFilesTool.run({ pattern: 'TODO', path: root, context: 1 });
+        expect(result.success).toBe(true);
+        expect(result.matches.map((m: any) => `${m.file}:${m.line}`)).toEqual(['main.go:4', 'pkg/deep/deeper/leaf.txt:1']);
+        expect(result.matches[0]).toMatchObject({ column: 5, text: '\t// TODO: flags', before: ['func main() {'], after: ['\trun()'] });
+        expect(result.skipped.binary).toBe(1);
+        expect(result.output).toContain('main.go:4:5:\t// TODO: flags');
+        expect(result.output).toContain('main.go-3-func main() {');
+
+        const insensitive: any = await searchFilesTool.run({ pattern: 'todo', path: root, caseSensitive: false, include: ['*.go'] });
+        expect(insensitive.matches.map((m: any) => m.file)).toEqual(['main.go', 'pkg/run.go']);
+        const excluded: any = await searchFilesTool.run({ pattern: 'TODO', path: root, exclude: ['pkg/**'] });
+        expect(excluded.matches.map((m: any) => m.file)).toEqual(['main.go']);
+        const unignored: any = await searchFilesTool.run({ pattern: 'TODO', path: root, respectGitignore: false });
+        expect(unignored.filesMatched).toBe(6);
+
+        const literal: any = await searchFilesTool.run({ pattern: 'run()', path: join(root, 'main.go'), literal: true });
+        expect(literal.matches).toHaveLength(1);
+        const capped: any = await searchFilesTool.run({ pattern: 'package', path: root, maxResults: 1 });
+        expect(capped.truncated).toBe(true);
+        expect(capped.matches).toHaveLength(1);
+    });
+
+    it('should reject invalid patterns', async () => {
+        expect(() => compileSearchPattern('(')).toThrow('Invalid pattern');
+        expect(compileSearchPattern('a.b', { literal: true }).test('axb')).toBe(false);
+        expect(compileSearchPattern('run', { wholeWord: true }).test('rerun')).toBe(false);
+        const result: any = await searchFilesTool.run({ pattern: '[', path: root });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('Invalid pattern');
+    });
+});
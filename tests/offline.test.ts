@@ -0,0 +1,72 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { detectNetworkAttempt, offlineEnv, offlineModeFromEnv, strictestOffline, withOfflineArg, type OfflineViolation } from '../src/executor/offline.js';
+import { detectNetworkSandbox } from '../src/executor/sandbox.js';
+import type { RetryEvent } from '../src/executor/retry.js';
+import { runCommand, withCommandDefaults } from '../src/utils/command.js';
+
+describe('Offline mode', () => {
+    let dir: string;
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-offline-'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should recognise tools refused the network', () => {
+        expect(detectNetworkAttempt('go: example.com/m@v1.2.0: module lookup disabled by GOPROXY=off')?.id).toBe('go-proxy-off');
+        expect(detectNetworkAttempt('npm error code ENOTCACHED\nnpm error request to https://registry.npmjs.org/left-pad failed: cache mode is \'only-if-cached\'')?.id).toBe('npm-offline');
+        expect(detectNetworkAttempt('ERROR: Could not find a version that satisfies the requirement requests (from versions: none)')?.id).toBe('pip-no-index');
+        expect(detectNetworkAttempt("fatal: unable to access 'https://github.com/a/b.git/': Could not resolve host: github.com")?.id).toBe('network-unreachable');
+        expect(detectNetworkAttempt('--- FAIL: TestSum (0.00s)\n    sum_test.go:9: got 3, want 4')).toBeNull();
+    });
+
+    it('should only ever tighten the configured mode', () => {
+        expect(offlineModeFromEnv({})).toBe('off');
+        expect(offlineModeFromEnv({ MCP_OFFLINE: '1' })).toBe('on');
+        expect(offlineModeFromEnv({ MCP_OFFLINE: 'Strict' })).toBe('strict');
+        expect(strictestOffline('off', 'on')).toBe('on');
+        expect(strictestOffline('strict', 'off')).toBe('strict');
+        expect(strictestOffline('on', undefined)).toBe('on');
+        expect(withOfflineArg({ type: 'object', properties: { path: { type: 'string' } } }).properties.offline_mode.enum).toEqual(['off', 'on', 'strict']);
+    });
+
+    it('should use vendored Go modules when they are there', async () => {
+        expect((await offlineEnv(dir, '-tags=integration -mod=mod')).GOFLAGS).toBe('-tags=integration');
+        await fs.mkdir(join(dir, 'vendor'));
+        await fs.writeFile(join(dir, 'vendor', 'modules.txt'), '# example.com/m v1.0.0\n');
+        const env = await offlineEnv(dir, '-tags=integration -mod=mod');
+        expect(env).toMatchObject({ GOPROXY: 'off', GOTOOLCHAIN: 'local', GOFLAGS: '-tags=integration -mod=vendor', npm_config_offline: 'true', PIP_NO_INDEX: '1' });
+    });
+
+    it('should fail fast instead of retrying a command that needed the network', async () => {
+        const violations: OfflineViolation[] = [];
+        const retries: RetryEvent[] = [];
+        const result = await withCommandDefaults(
+            {
+                retry: { attempts: 3, backoffMs: 10 },
+                onRetry: event => retries.push(event),
+                offline: { mode: 'on', sandbox: null },
+                onOfflineViolation: violation => violations.push(violation),
+            },
+            () => runCommand('echo "npm error code EAI_AGAIN" >&2; exit 1')
+        );
+        expect(result.offlineViolation).toBe('npm-offline');
+        expect(retries).toEqual([]);
+        expect(violations).toEqual([{ command: 'echo "npm error code EAI_AGAIN" >&2; exit 1', rule: 'npm-offline', description: 'npm needed a package that is not in its cache' }]);
+    });
+
+    it('should take the network away from commands in strict mode', async () => {
+        const sandbox = await detectNetworkSandbox();
+        if (!sandbox) return;
+        const probe = `node -e "require('net').connect(443, '1.1.1.1').on('connect', () => console.log('connected')).on('error', e => { console.error(e.code); process.exit(1); })"`;
+        const result = await withCommandDefaults({ offline: { mode: 'strict', sandbox } }, () => runCommand(probe, { timeout: 10000 }));
+        expect(result.stdout).not.toContain('connected');
+        expect(result.exitCode).not.toBe(0);
+    });
+});
@@ -0,0 +1,89 @@
+import { describe, it, expect } from 'vitest';
+import { applyOutputBudget, OutputStore, truncateText, withOutputBudgetArg } from '../src/output/index.js';
+import { getOutputPageTool } from '../src/tools/output.js';
+
+const size = (value: unknown) => Buffer.byteLength(JSON.stringify(value, null, 2));
+
+function testLog(passing: number): string {
+    const lines = ['=== RUN all'];
+    for (let i = 0; i < passing; i++) lines.push(`--- PASS: TestCase${i} (0.00s)`);
+    lines.splice(passing / 2, 0, '--- FAIL: TestBroken (0.01s)', '    broken_test.go:12: expected 2, got 3');
+    lines.push('FAIL\tgithub.com/acme/app\t1.234s');
+    return lines.join('\n');
+}
+
+describe('Output budgeting', () => {
+    it('should keep the head, error blocks and tail of long output', () => {
+        const log = testLog(5000);
+        const { text, omittedLines } = truncateText(log, 4096);
+        expect(Buffer.byteLength(text)).toBeLessThanOrEqual(4096);
+        expect(text.startsWith('=== RUN all')).toBe(true);
+        expect(text).toContain('--- FAIL: TestBroken (0.01s)');
+        expect(text).toContain('broken_test.go:12: expected 2, got 3');
+        expect(text.endsWith('FAIL\tgithub.com/acme/app\t1.234s')).toBe(true);
+        expect(text).toMatch(/\[\.\.\. \d+ line\(s\) omitted \.\.\.\]/);
+        expect(omittedLines).toBeGreaterThan(4000);
+
+        expect(truncateText('short', 4096)).toEqual({ text: 'short', omittedLines: 0 });
+        const oneLine = truncateText('x'.repeat(10000), 2048);
+        expect(oneLine.text.endsWith('[... output truncated ...]')).toBe(true);
+    });
+
+    it('should fit results into the budget, keeping errors and failing items first', () => {
+        const store = new OutputStore();
+        const diagnostics = Array.from({ length: 2000 }, (_, i) => ({ file: `/repo/f${i}.go`, line: i, column: 1, severity: i === 1500 ? 'error' : 'warning', message: `finding ${i}`, source: 'vet' }));
+        const result = { success: false, errors: ['go test failed'], warnings: [], output: testLog(5000), diagnostics };
+        const small = { success: true, errors: [], warnings: [], output: 'ok' };
+        expect(applyOutputBudget(small, 4096, store)).toBe(small);
+
+        const fitted: any = applyOutputBudget(result, 16384, store);
+        expect(size(fitted)).toBeLessThanOrEqual(16384);
+        expect(fitted.success).toBe(false);
+        expect(fitted.errors).toEqual(['go test failed']);
+        expect(fitted.diagnostics[0].message).toBe('finding 1500');
+        expect(fitted.output).toContain('--- FAIL: TestBroken');
+        expect(fitted.truncated.originalBytes).toBe(size(result));
+        expect(fitted.truncated.fields.diagnostics.omittedItems).toBeGreaterThan(1000);
+        expect(fitted.truncated.fields.output.omittedLines).toBeGreaterThan(0);
+        expect(fitted.warnings[0]).toContain('get_output_page');
+    });
+
+    it('should shorten each of a few large items instead of dropping them', () => {
+        const store = new OutputStore();
+        const steps = ['build', 'vet', 'test'].map(name => ({ name, success: name !== 'test', output: testLog(2000) }));
+        const fitted: any = applyOutputBudget({ success: false, errors: [], warnings: [], output: '', steps }, 16384, store);
+        expect(size(fitted)).toBeLessThanOrEqual(16384);
+        expect(fitted.steps.map((s: any) => s.name)).toEqual(['build', 'vet', 'test']);
+        expect(fitted.steps[2].output).toContain('--- FAIL: TestBroken');
+    });
+
+    it('should page through the full output with continuation tokens', async () => {
+        const log = testLog(3000);
+        const fitted: any = applyOutputBudget({ success: false, errors: [], warnings: [], output: log }, 8192);
+
+        let token: string | null = fitted.truncated.token;
+        let text = '';
+        let pages = 0;
+        while (token) {
+            const page: any = await getOutputPageTool.run({ token });
+            expect(page.success).toBe(true);
+            expect(Buffer.byteLength(page.output)).toBeLessThanOrEqual(8192);
+            // Pages end on line breaks
+            if (page.nextToken) expect(page.output.endsWith('\n')).toBe(true);
+            text += page.output;
+            token = page.nextToken;
+            pages++;
+        }
+        expect(text).toBe(log);
+        expect(pages).toBeGreaterThan(5);
+
+        const bad: any = await getOutputPageTool.run({ token: 'not-a-token' });
+        expect(bad.errors).toEqual(['Invalid output token']);
+    });
+
+    it('should advertise max_output_bytes on tool schemas', () => {
+        const schema = withOutputBudgetArg({ type: 'object', properties: { path: { type: 'string' } }, required: ['path'] });
+        expect(schema.properties.max_output_bytes.type).toBe('integer');
+        expect(schema.required).toEqual(['path']);
+    });
+});
@@ -0,0 +1,122 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { runPipeline, runPipelineTool, type PipelineStepResult } from '../src/tools/pipeline.js';
+import { summarizePipeline } from '../src/diagnostics/summary.js';
+import { callClass, withRole } from '../src/permissions/index.js';
+
+const fakeTools = [
+    { name: 'lint', inputSchema: { properties: {} }, run: async (args: any) => ({ success: false, errors: ['unused variable'], warnings: [], output: '', diagnostics: [{ file: args.filePath }] }) },
+    { name: 'vet', inputSchema: { properties: { timeout: {} } }, run: async (args: any) => ({ success: true, errors: [], warnings: [], output: `timeout=${args.timeout}` }) },
+];
+
+describe('Pipelines', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-pipeline-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, '.code-feedback.yaml'), [
+            'pipelines:',
+            '  default:',
+            '    - { name: build, command: "echo built" }',
+            '    - { name: test, command: "exit 2" }',
+            '    - { name: lint, command: "echo linted" }',
+        ].join('\n'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should continue past steps marked continueOnError and skip after a hard failure', async () => {
+        const results = await runPipeline([
+            { tool: 'lint', args: { filePath: 'main.go' }, continueOnError: true },
+            { tool: 'vet', timeout: 5000 },
+            { tool: 'lint' },
+            { tool: 'vet' },
+        ], '/work', fakeTools, () => true);
+        expect(results.map(r => r.status)).toEqual(['failed', 'passed', 'failed', 'skipped']);
+        expect(results[0]?.diagnostics).toEqual([{ file: '/work/main.go' }]);
+        expect(results[1]?.output).toBe('timeout=5000');
+    });
+
+    it('should fail steps for unknown or disabled tools', async () => {
+        const results = await runPipeline([
+            { tool: 'missing', continueOnError: true },
+            { tool: 'vet' },
+        ], '/work', fakeTools, name => name !== 'vet');
+        expect(results[0]?.errors[0]).toContain('Unknown tool');
+        expect(results[1]?.errors[0]).toContain('disabled');
+    });
+
+    it('should run a pipeline from the project config', async () => {
+        const result: any = await runPipelineTool.run({ path: root });
+        expect(result.success).toBe(false);
+        expect(result.summary).toEqual({ total: 3, passed: 1, failed: 1, skipped: 1 });
+        expect(result.steps[0].output).toContain('built');
+        expect(result.errors[0]).toContain('test: Exited with code 2');
+    });
+
+    it('should hold each step to the caller\'s role', async () => {
+        expect(callClass({}, runPipelineTool, { path: root, steps: [{ command: 'echo hi' }] })).toBe('dangerous');
+        expect(callClass({}, runPipelineTool, { path: root, steps: [{ tool: 'vet' }] })).toBe('mutating');
+
+        const tools = [...fakeTools, { name: 'shell', dangerous: true, inputSchema: { properties: {} }, run: async () => ({ success: true, errors: [], warnings: [], output: 'ran' }) }];
+        const steps = [{ tool: 'vet', continueOnError: true }, { tool: 'shell', continueOnError: true }, { command: 'echo ran' }];
+        const developer = await withRole('developer', () => runPipeline(steps, root, tools, () => true));
+        expect(developer.map(r => r.status)).toEqual(['passed', 'failed', 'failed']);
+        expect(developer[1]?.errors[0]).toContain('Role "developer" may not make dangerous calls; shell needs');
+        expect(developer[2]?.errors[0]).toContain('run_command needs');
+        expect(developer[2]?.output).toBe('');
+        // Configured pipelines are checked too
+        const configured: any = await withRole('developer', () => runPipelineTool.run({ path: root }));
+        expect(configured.errors[0]).toContain('build: Role "developer" may not make dangerous calls');
+
+        const admin = await withRole('admin', () => runPipeline(steps, root, tools, () => true));
+        expect(admin.map(r => r.status)).toEqual(['passed', 'passed', 'passed']);
+    });
+
+    it('should list configured pipelines when the name is unknown', async () => {
+        const result: any = await runPipelineTool.run({ path: root, pipeline: 'release' });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('available: default');
+    });
+
+    it('should roll step results up into a verdict', () => {
+        const diagnostic = (file: string, line: number, severity: 'error' | 'warning') => ({ file, line, column: 0, severity, message: `${severity} at ${line}\nmore detail`, source: 'go' });
+        const results: PipelineStepResult[] = [
+            { name: 'build', status: 'failed', durationMs: 5, errors: ['compile failed'], warnings: [], output: '', diagnostics: [diagnostic('/work/b.go', 3, 'error'), diagnostic('/work/a.go', 9, 'error'), diagnostic('/work/a.go', 2, 'warning')] },
+            { name: 'vet', status: 'passed', durationMs: 1, errors: [], warnings: [], output: '', diagnostics: [diagnostic('/work/c.go', 1, 'warning')] },
+            { name: 'test', status: 'failed', durationMs: 8, errors: ['Exited with code 1: FAIL'], warnings: [], output: '' },
+            { name: 'lint', status: 'skipped', durationMs: 0, errors: [], warnings: [], output: '' },
+        ];
+        const verdict = summarizePipeline(results, { root: '/work', maxIssues: 2 });
+        expect(verdict.passed).toBe(false);
+        expect(verdict.steps).toEqual({ total: 4, passed: 1, failed: 2, skipped: 1 });
+        expect(verdict.failedSteps).toEqual(['build', 'test']);
+        expect(verdict.severity).toEqual({ error: 2, warning: 2, info: 0 });
+        expect(verdict.blocking).toEqual([
+            { step: 'build', message: 'error at 9', file: 'a.go', line: 9, source: 'go' },
+            { step: 'build', message: 'error at 3', file: 'b.go', line: 3, source: 'go' },
+        ]);
+        expect(verdict.moreBlocking).toBe(1);
+        expect(verdict.summary).toBe('FAIL: build, test failed (1 of 4 steps passed, 1 skipped); 2 errors, 2 warnings, 0 info; first: a.go:9 error at 9');
+        expect(summarizePipeline([results[1]!]).summary).toBe('PASS: 1 step passed; 0 errors, 1 warning, 0 info');
+    });
+
+    it('should return only the verdict with detail summary', async () => {
+        const result: any = await runPipelineTool.run({ path: root, detail: 'summary' });
+        expect(result.success).toBe(false);
+        expect(result.diagnostics).toBeUndefined();
+        expect(result.steps).toEqual([
+            { name: 'build', status: 'passed', durationMs: result.steps[0].durationMs, diagnosticCount: 0 },
+            { name: 'test', status: 'failed', durationMs: result.steps[1].durationMs, diagnosticCount: 0 },
+            { name: 'lint', status: 'skipped', durationMs: 0, diagnosticCount: 0 },
+        ]);
+        expect(result.verdict.blocking).toEqual([{ step: 'test', message: 'Exited with code 2' }]);
+        expect(result.output.split('\n')[0]).toBe('FAIL: test failed (1 of 3 steps passed, 1 skipped); 0 errors, 0 warnings, 0 info; first: test Exited with code 2');
+    });
+});
@@ -0,0 +1,124 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { execSync } from 'child_process';
+import Config from '../src/config/index.js';
+import { describePlugin, loadPluginTools, loadPluginsFile } from '../src/plugins/index.js';
+
+const PROCESS_PLUGIN = `#!/usr/bin/env node
+import { readFileSync } from 'fs';
+const request = JSON.parse(readFileSync(0, 'utf-8'));
+if (request.method === 'describe') {
+    console.log(JSON.stringify({ tools: [{ name: 'acme_lint', description: 'Flag TODOs', inputSchema: { type: 'object', properties: { path: { type: 'string' } }, required: ['path'] } }] }));
+} else {
+    const text = readFileSync(request.arguments.path + '/main.go', 'utf-8');
+    const line = text.split('\\n').findIndex(l => l.includes('TODO')) + 1;
+    console.log(JSON.stringify({
+        success: line === 0,
+        errors: line ? ['TODO left in main.go'] : [],
+        output: 'checked ' + process.cwd() + ' with protocol ' + request.protocol + '/' + process.env.CODE_FEEDBACK_PLUGIN_PROTOCOL,
+        diagnostics: [{ file: request.arguments.path + '/main.go', line, message: 'TODO left' }, { bogus: true }],
+        checked: 1,
+    }));
+}
+`;
+
+// Lists the files of the path argument from inside the WASI sandbox
+const WASM_PLUGIN = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+func main() {
+	var request struct {
+		Method    string
+		Arguments struct{ Path string }
+	}
+	json.NewDecoder(os.Stdin).Decode(&request)
+	if request.Method == "describe" {
+		fmt.Println(\`{"tools":[{"name":"acme_files","description":"List files","inputSchema":{"type":"object"}}]}\`)
+		return
+	}
+	entries, err := os.ReadDir(request.Arguments.Path)
+	if err != nil {
+		json.NewEncoder(os.Stdout).Encode(map[string]any{"success": false, "errors": []string{err.Error()}})
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	json.NewEncoder(os.Stdout).Encode(map[string]any{"success": true, "output": fmt.Sprint(names)})
+}
+`;
+
+describe('plugins', () => {
+    let root: string;
+    let project: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-plugins-'));
+        project = join(root, 'project');
+        await fs.mkdir(project);
+        Config.getInstance().addAllowedPaths([project]);
+        await fs.writeFile(join(project, 'main.go'), 'package main\n\n// TODO: finish\n');
+        await fs.writeFile(join(root, 'lint.mjs'), PROCESS_PLUGIN, { mode: 0o755 });
+        await fs.writeFile(join(root, 'broken.sh'), '#!/bin/sh\necho not json; echo oops >&2; exit 3\n', { mode: 0o755 });
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should validate the plugins file and resolve paths against it', async () => {
+        expect(await loadPluginsFile(join(root, 'missing.yaml'))).toEqual({ plugins: [] });
+        const path = join(root, 'plugins.yaml');
+        await fs.writeFile(path, 'plugins:\n  - { name: acme, type: wasm }\n');
+        await expect(loadPluginsFile(path)).rejects.toThrow('wasm plugins need module');
+        await fs.writeFile(path, 'plugins:\n  - { name: acme, command: [./lint.mjs, --fast] }\n  - { name: py, command: [python3, tool.py] }\n');
+        const { plugins } = await loadPluginsFile(path);
+        expect(plugins.map(p => p.command)).toEqual([[join(root, 'lint.mjs'), '--fast'], ['python3', 'tool.py']]);
+        expect(plugins[0]).toMatchObject({ type: 'process', timeout: 60000 });
+    });
+
+    it('should expose process plugin tools as regular tools', async () => {
+        const [tool] = await describePlugin({ name: 'acme', type: 'process', command: [join(root, 'lint.mjs')], timeout: 10000 });
+        expect(tool).toMatchObject({ name: 'acme_lint', description: 'Flag TODOs (plugin acme)', mutates: false, plugin: 'acme' });
+
+        const result: any = await tool!.run({ path: project });
+        expect(result.success).toBe(false);
+        expect(result.errors).toEqual(['TODO left in main.go']);
+        expect(result.output).toBe(`checked ${project} with protocol 1/1`);
+        expect(result.diagnostics).toEqual([{ file: join(project, 'main.go'), line: 3, column: 0, severity: 'warning', message: 'TODO left', source: 'acme' }]);
+        expect(result.checked).toBe(1);
+        expect((await tool!.run({ path: '/etc' })).errors).toEqual(['Path not allowed']);
+    });
+
+    it('should skip plugins that fail to describe themselves or clash', async () => {
+        const path = join(root, 'plugins.yaml');
+        await fs.writeFile(path, 'plugins:\n  - { name: broken, command: [./broken.sh] }\n  - { name: first, command: [./lint.mjs] }\n  - { name: second, command: [./lint.mjs] }\n');
+        const tools = await loadPluginTools(['go_build'], path);
+        expect(tools.map(t => `${t.plugin}:${t.name}`)).toEqual(['first:acme_lint']);
+        await expect(describePlugin({ name: 'broken', type: 'process', command: [join(root, 'broken.sh')], timeout: 10000 })).rejects.toThrow('Plugin broken exited with 3: oops');
+        expect(await loadPluginTools(['acme_lint'], path)).toEqual([]);
+    });
+
+    it('should run WASI modules with only the path argument preopened', async () => {
+        const source = join(root, 'wasm');
+        await fs.mkdir(source);
+        await fs.writeFile(join(source, 'go.mod'), 'module files\n\ngo 1.21\n');
+        await fs.writeFile(join(source, 'main.go'), WASM_PLUGIN);
+        execSync('go build -o files.wasm .', { cwd: source, env: { ...process.env, GOOS: 'wasip1', GOARCH: 'wasm', GOFLAGS: '', GOWORK: 'off' } });
+
+        const [tool] = await describePlugin({ name: 'files', type: 'wasm', module: join(source, 'files.wasm'), timeout: 30000 });
+        expect(tool?.name).toBe('acme_files');
+        const listed: any = await tool!.run({ path: project });
+        expect(listed).toMatchObject({ success: true, output: '[main.go]' });
+        const outside: any = await tool!.run({ path: join(project, 'main.go'), filePath: root });
+        expect(outside.errors).toEqual(['Path not allowed']);
+    });
+});
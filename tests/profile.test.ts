@@ -0,0 +1,89 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { parsePprofTop, profileRunTool } from '../src/tools/profile.js';
+import { commandExists } from '../src/utils/command.js';
+
+const CPU_TOP = `File: pp.test
+Type: cpu
+Time: 2026-10-14 07:46:16 UTC
+Duration: 4.69s, Total samples = 4.62s (98.45%)
+Showing nodes accounting for 4.18s, 90.48% of 4.62s total
+Dropped 81 nodes (cum <= 0.02s)
+      flat  flat%   sum%        cum   cum%
+     4.02s 87.01% 87.01%      4.06s 87.88%  example.com/pp.Sum
+    50ms  1.08% 88.10%      0.05s  1.08%  runtime.memmove
+`;
+
+const HEAP_TOP = `File: pp.test
+Type: alloc_space
+Showing nodes accounting for 1.02GB, 99.24% of 1.03GB total
+      flat  flat%   sum%        cum   cum%
+    1.02GB 99.24% 99.24%     1.03GB 99.67%  example.com/pp.Build /src/pp/pp.go:8
+         0     0% 99.24%     1.03GB 99.67%  example.com/pp.TestHot /src/pp/pp_test.go:7 (inline)
+  512.50kB  0.05% 99.29%   512.50kB  0.05%  strings.Repeat /usr/local/go/src/strings/strings.go:600
+`;
+
+describe('profile_run', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-profile-test-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/pp\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'pp.go'), [
+            'package pp',
+            '',
+            'func Build(n int) [][]byte {',
+            '\tvar out [][]byte',
+            '\tfor i := 0; i < n; i++ {',
+            '\t\tout = append(out, make([]byte, 4096))',
+            '\t}',
+            '\treturn out',
+            '}',
+            '',
+        ].join('\n'));
+        await fs.writeFile(join(root, 'pp_test.go'), 'package pp\n\nimport "testing"\n\nfunc BenchmarkBuild(b *testing.B) {\n\tfor i := 0; i < b.N; i++ {\n\t\tBuild(64)\n\t}\n}\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should parse pprof top output', () => {
+        expect(parsePprofTop(CPU_TOP)).toEqual({
+            type: 'cpu',
+            unit: 'ms',
+            total: 4620,
+            duration: '4.69s',
+            entries: [
+                { function: 'example.com/pp.Sum', flat: 4020, flatPercent: 87.01, cum: 4060, cumPercent: 87.88 },
+                { function: 'runtime.memmove', flat: 50, flatPercent: 1.08, cum: 50, cumPercent: 1.08 },
+            ],
+        });
+        const heap = parsePprofTop(HEAP_TOP);
+        expect(heap.unit).toBe('bytes');
+        expect(heap.entries.map(e => `${e.function} ${e.file}:${e.line} ${e.flat}`)).toEqual([
+            'example.com/pp.Build /src/pp/pp.go:8 1095216660.48',
+            'example.com/pp.TestHot /src/pp/pp_test.go:7 0',
+            'strings.Repeat /usr/local/go/src/strings/strings.go:600 524800',
+        ]);
+    });
+
+    it('should profile benchmarks and report allocation sites', async () => {
+        if (!await commandExists('go')) return;
+        const result: any = await profileRunTool.run({ projectPath: root, bench: 'Build', benchtime: '2000x', top: 5 });
+        expect(result.success).toBe(true);
+        expect(result.cpu.type).toBe('cpu');
+        expect(result.heap.type).toBe('alloc_space');
+        expect(result.heap.entries[0]).toMatchObject({ function: 'example.com/pp.Build', file: join(root, 'pp.go'), line: 6 });
+        expect(result.diagnostics[0]).toMatchObject({ file: join(root, 'pp.go'), line: 6, severity: 'info', rule: 'allocation-site', source: 'pprof' });
+        expect(result.output).toContain('heap (alloc_space, total');
+    }, 120000);
+
+    it('should reject command together with tests', async () => {
+        expect((await profileRunTool.run({ projectPath: root, command: './server -cpuprofile {cpu}', bench: '.' })).errors).toEqual(['Pass command, or run and bench for tests, not both']);
+    });
+});
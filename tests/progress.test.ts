@@ -0,0 +1,49 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { Client } from '@modelcontextprotocol/sdk/client/index.js';
+import { InMemoryTransport } from '@modelcontextprotocol/sdk/inMemory.js';
+import type { Progress } from '@modelcontextprotocol/sdk/types.js';
+import Config from '../src/config/index.js';
+import { createServer } from '../src/server.js';
+
+describe('Progress notifications', () => {
+    let dir: string;
+    let client: Client;
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-progress-'));
+        Config.getInstance().addAllowedPaths([dir]);
+        const server = createServer('test');
+        const [clientTransport, serverTransport] = InMemoryTransport.createLinkedPair();
+        await server.connect(serverTransport);
+        client = new Client({ name: 'progress-test', version: '1.0.0' });
+        await client.connect(clientTransport);
+    });
+
+    afterAll(async () => {
+        await client.close();
+        Config.getInstance().removeAllowedPaths([dir]);
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should stream command output to a client that sent a progressToken', async () => {
+        const updates: Progress[] = [];
+        const result: any = await client.callTool(
+            { name: 'run_pipeline', arguments: { path: dir, steps: [{ name: 'slow', command: 'echo first; sleep 0.3; echo second' }] } },
+            undefined,
+            { onprogress: progress => updates.push(progress) },
+        );
+        expect(JSON.parse(result.content[0].text).success).toBe(true);
+
+        const messages = updates.map(update => update.message ?? '');
+        const first = messages.findIndex(message => message.includes('first'));
+        const second = messages.findIndex(message => message.includes('second'));
+        expect(first).toBeGreaterThanOrEqual(0);
+        expect(second).toBeGreaterThan(first);
+        expect(messages[first]).toMatch(/^\[stdout\] /);
+        // progress must increase across the whole call
+        expect(updates.map(update => update.progress)).toEqual(updates.map((_, index) => index + 1));
+    });
+});
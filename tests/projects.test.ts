@@ -0,0 +1,88 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { ProjectDetector, detectProjects, toolProjectKinds } from '../src/projects/index.js';
+import { listProjectsTool } from '../src/tools/projects.js';
+import { withWorkspaceArg } from '../src/utils/paths.js';
+
+const goTool = { binaries: ['go'], inputSchema: { properties: { projectPath: {}, filePath: {} } } };
+
+async function write(path: string, content: string) {
+    await fs.mkdir(join(path, '..'), { recursive: true });
+    await fs.writeFile(path, content);
+}
+
+describe('Project detection', () => {
+    let dir: string;
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-projects-test-'));
+        Config.getInstance().addAllowedPaths([dir]);
+        await fs.mkdir(join(dir, '.git'));
+        await write(join(dir, 'services', 'billing', 'go.mod'), 'module example.com/billing\n\ngo 1.22\n');
+        await write(join(dir, 'services', 'billing', 'internal', 'invoice.go'), 'package internal\n');
+        await write(join(dir, 'services', 'auth', 'go.mod'), 'module example.com/auth\n');
+        await write(join(dir, 'services', 'auth', 'testdata', 'go.mod'), 'module fixture\n');
+        await write(join(dir, 'web', 'package.json'), JSON.stringify({ name: '@acme/web' }));
+        await write(join(dir, 'web', 'node_modules', 'left-pad', 'package.json'), JSON.stringify({ name: 'left-pad' }));
+        await write(join(dir, 'ml', 'pyproject.toml'), '[build-system]\nrequires = ["hatchling"]\n\n[project]\nname = "acme-ml"\n');
+        await write(join(dir, 'ml', 'setup.py'), 'setup()\n');
+        await write(join(dir, 'tools', 'lint', 'Cargo.toml'), '[package]\nname = "acme-lint"\nversion = "0.1.0"\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should map sub-projects by manifest, skipping dependencies and fixtures', async () => {
+        const projects = await detectProjects(dir);
+        expect(projects.map(p => `${p.relativePath} ${p.kind} ${p.name}`)).toEqual([
+            'ml python acme-ml',
+            'services/auth go example.com/auth',
+            'services/billing go example.com/billing',
+            'tools/lint rust acme-lint',
+            'web node @acme/web',
+        ]);
+        expect(projects[0]?.manifest).toBe(join(dir, 'ml', 'pyproject.toml'));
+    });
+
+    it('should route explicit projects by name or relative path', async () => {
+        const detector = new ProjectDetector();
+        const byName = await detector.route({ projectPath: dir, project: 'example.com/auth', actions: ['build'] }, goTool);
+        expect(byName.args).toEqual({ projectPath: join(dir, 'services', 'auth'), actions: ['build'] });
+        const byPath = await detector.route({ projectPath: dir, project: 'services/billing' }, goTool);
+        expect(byPath.project?.name).toBe('example.com/billing');
+        await expect(detector.route({ projectPath: dir, project: 'web' }, goTool)).rejects.toThrow('No project web');
+    });
+
+    it('should route to the only project of the tool kind, or the one holding filePath', async () => {
+        const detector = new ProjectDetector();
+        const rust = await detector.route({ projectPath: dir }, { binaries: ['cargo'], inputSchema: goTool.inputSchema });
+        expect(rust.args.projectPath).toBe(join(dir, 'tools', 'lint'));
+        const routed = await detector.route({ projectPath: dir, filePath: 'services/billing/internal/invoice.go' }, goTool);
+        expect(routed.args.projectPath).toBe(join(dir, 'services', 'billing'));
+        await expect(detector.route({ projectPath: dir }, goTool)).rejects.toThrow('contains 2: services/auth (example.com/auth), services/billing (example.com/billing)');
+        // Calls already inside a project, and tools not tied to one ecosystem, are left alone
+        const inside = { projectPath: join(dir, 'services', 'billing', 'internal') };
+        expect((await detector.route(inside, goTool)).args).toBe(inside);
+        const generic = { projectPath: dir };
+        expect((await detector.route(generic, { inputSchema: goTool.inputSchema })).args).toBe(generic);
+    });
+
+    it('should derive project kinds from tool binaries', () => {
+        expect(toolProjectKinds({ binaries: ['golangci-lint'] })).toEqual(['go']);
+        expect(toolProjectKinds({ binaries: ['git'] })).toEqual([]);
+        expect(withWorkspaceArg({ properties: { projectPath: {} } }).properties.project).toBeDefined();
+        expect(withWorkspaceArg({ properties: { path: {} } }).properties.project).toBeUndefined();
+    });
+
+    it('should list projects with per-kind counts', async () => {
+        const result: any = await listProjectsTool.run({ path: dir, kind: 'go' });
+        expect(result.success).toBe(true);
+        expect(result.counts).toEqual({ go: 2 });
+        expect(result.output).toBe('services/auth [go] example.com/auth\nservices/billing [go] example.com/billing');
+        expect((await listProjectsTool.run({ path: '/nonexistent-root' })).errors).toEqual(['Path not allowed']);
+    });
+});
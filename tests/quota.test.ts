@@ -0,0 +1,116 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { createHash } from 'crypto';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import type { AddressInfo } from 'net';
+import type { Server as HttpServer } from 'http';
+import { authenticateClient, describeViolation, loadApiKeys, QuotaTracker, type ApiKeysFile } from '../src/quota/index.js';
+import { parseShellTimes } from '../src/executor/limits.js';
+import { runCommand, withCommandDefaults } from '../src/utils/command.js';
+import { startHttpServer } from '../src/transport/http.js';
+import { createServer } from '../src/server.js';
+
+const sha256 = (value: string) => createHash('sha256').update(value).digest('hex');
+
+describe('Quotas', () => {
+    let dir: string;
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-quota-'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should load and validate the API keys file', async () => {
+        const path = join(dir, 'api-keys.yaml');
+        await fs.writeFile(path, `defaults:\n  callsPerHour: 100\nkeys:\n  - name: ci\n    tokenSha256: ${sha256('ci-token-0123456789')}\n    limits: { callsPerMinute: 2 }\n`);
+        const file = await loadApiKeys(path);
+        expect(file.defaults).toEqual({ callsPerHour: 100 });
+        expect(file.keys[0]?.name).toBe('ci');
+
+        await fs.writeFile(path, 'keys:\n  - name: both\n    token: aaaaaaaaaaaaaaaa\n    tokenSha256: ' + sha256('x') + '\n');
+        await expect(loadApiKeys(path)).rejects.toThrow('exactly one of token or tokenSha256');
+        await fs.writeFile(path, 'keys:\n  - { name: a, token: aaaaaaaaaaaaaaaa }\n  - { name: a, token: bbbbbbbbbbbbbbbb }\n');
+        await expect(loadApiKeys(path)).rejects.toThrow('duplicate key name a');
+        expect(await loadApiKeys(join(dir, 'missing.yaml'))).toEqual({ keys: [] });
+    });
+
+    it('should resolve bearer tokens to clients', () => {
+        const apiKeys: ApiKeysFile = {
+            defaults: { callsPerHour: 10 },
+            keys: [
+                { name: 'ci', tokenSha256: sha256('ci-token-0123456789'), limits: { callsPerMinute: 2 } },
+                { name: 'alice', token: 'alice-token-0123456789' },
+            ],
+        };
+        expect(authenticateClient('Bearer ci-token-0123456789', { apiKeys })).toEqual({ name: 'ci', limits: { callsPerMinute: 2 } });
+        expect(authenticateClient('Bearer alice-token-0123456789', { apiKeys })).toEqual({ name: 'alice', limits: { callsPerHour: 10 } });
+        expect(authenticateClient('Bearer shared', { apiKeys, token: 'shared' })).toEqual({ name: 'default', limits: { callsPerHour: 10 } });
+        expect(authenticateClient('Bearer wrong', { apiKeys, token: 'shared' })).toBeUndefined();
+        expect(authenticateClient(undefined, { apiKeys })).toBeUndefined();
+        expect(authenticateClient(undefined, { remoteAddress: '10.0.0.1' })).toEqual({ name: 'anonymous@10.0.0.1', limits: {} });
+    });
+
+    it('should enforce sliding windows with a retry hint', () => {
+        const tracker = new QuotaTracker();
+        const client = { name: 'ci', limits: { callsPerMinute: 2, callsPerHour: 3, cpuSecondsPerHour: 10 } };
+        const start = 1_000_000;
+        expect(tracker.admit(client, start)).toBeNull();
+        expect(tracker.admit(client, start + 1000)).toBeNull();
+        const perMinute = tracker.admit(client, start + 2000);
+        expect(perMinute).toEqual({ client: 'ci', limit: 'callsPerMinute', max: 2, used: 2, retryAfterSeconds: 58 });
+        expect(describeViolation(perMinute!)).toBe('Quota exceeded for client ci: 2 of 2 tool calls per minute used; retry in 58s');
+
+        expect(tracker.admit(client, start + 61_000)).toBeNull();
+        expect(tracker.admit(client, start + 125_000)).toMatchObject({ limit: 'callsPerHour', used: 3 });
+        // The first call leaves the hour window
+        expect(tracker.admit(client, start + 3_600_001)).toBeNull();
+
+        tracker.recordCpu(client, 6, start + 3_600_002);
+        tracker.recordCpu(client, 6, start + 3_600_003);
+        const cpu = tracker.admit({ ...client, limits: { cpuSecondsPerHour: 10 } }, start + 3_600_004);
+        expect(cpu).toMatchObject({ limit: 'cpuSecondsPerHour', max: 10, used: 12 });
+        expect(tracker.usage(client, start + 3_600_004)).toMatchObject({ callsLastHour: 3, cpuSecondsLastHour: 12 });
+    });
+
+    it('should not limit clients without limits', () => {
+        const tracker = new QuotaTracker();
+        for (let i = 0; i < 100; i++) expect(tracker.admit({ name: 'free', limits: {} })).toBeNull();
+    });
+
+    it('should measure the CPU time of commands', async () => {
+        expect(parseShellTimes('0m0.010s 0m0.002s\n1m2.500s 0m0.500s\n')).toBe(63);
+        expect(parseShellTimes('')).toBeNull();
+
+        const charged: number[] = [];
+        const result = await withCommandDefaults({ onCpuTime: seconds => charged.push(seconds) }, () =>
+            runCommand('i=0; while [ $i -lt 20000 ]; do i=$((i+1)); done; echo done; exit 4', { cwd: dir }));
+        expect(result.stdout.trim()).toBe('done');
+        expect(result.exitCode).toBe(4);
+        expect(result.stderr).toBe('');
+        expect(charged).toHaveLength(1);
+        expect(charged[0]!).toBeGreaterThanOrEqual(0);
+        expect(charged[0]!).toBeLessThan(30);
+    });
+
+    it('should authenticate HTTP requests against the API keys', async () => {
+        const apiKeys: ApiKeysFile = { keys: [{ name: 'ci', token: 'ci-token-0123456789', limits: { callsPerMinute: 1 } }] };
+        const httpServer: HttpServer = await startHttpServer({ host: '127.0.0.1', port: 0, apiKeys, createMcpServer: client => createServer('test', { client }) });
+        try {
+            const { port } = httpServer.address() as AddressInfo;
+            const post = (token: string) => fetch(`http://127.0.0.1:${port}/mcp`, {
+                method: 'POST',
+                headers: { Authorization: `Bearer ${token}`, 'Content-Type': 'application/json' },
+                body: JSON.stringify({ jsonrpc: '2.0', id: 1, method: 'tools/list' }),
+            });
+            expect((await post('wrong-token-0123456789')).status).toBe(401);
+            // Authenticated, but not inside a session
+            expect((await post('ci-token-0123456789')).status).toBe(400);
+        } finally {
+            await new Promise(resolve => httpServer.close(resolve));
+        }
+    });
+});
@@ -0,0 +1,95 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { defaultPipeline, fullRepoCheckTool } from '../src/tools/repocheck.js';
+
+async function write(path: string, content: string) {
+    await fs.mkdir(join(path, '..'), { recursive: true });
+    await fs.writeFile(path, content);
+}
+
+describe('Full repository check', () => {
+    let dir: string;
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-repocheck-'));
+        Config.getInstance().addAllowedPaths([dir]);
+        await fs.mkdir(join(dir, '.git'));
+        // Every Go project gets pipelines.go; tooling and web bring their own steps
+        await write(join(dir, '.code-feedback.yaml'), [
+            'pipelines:',
+            '  go:',
+            '    - { name: build, command: "echo built" }',
+        ].join('\n'));
+        await write(join(dir, 'services', 'api', 'go.mod'), 'module example.com/api\n\ngo 1.22\n');
+        await write(join(dir, 'services', 'worker', 'go.mod'), 'module example.com/worker\n\ngo 1.22\n');
+        await write(join(dir, 'tooling', 'pyproject.toml'), '[project]\nname = "tooling"\n');
+        await write(join(dir, 'tooling', 'release.py'), 'print("release")  # TODO: sign\n');
+        await write(join(dir, 'tooling', '.code-feedback.yaml'), [
+            'rules:',
+            '  - { id: no-print, pattern: "print\\\\(", files: ["**/*.py"], message: "Use logging", severity: error }',
+            '  - { id: todo, pattern: "TODO", files: ["**/*.py"], message: "Open TODO", severity: warning }',
+            'pipelines:',
+            '  python:',
+            '    - { name: rules, tool: check_rules, args: { path: "." } }',
+        ].join('\n'));
+        await write(join(dir, 'web', 'package.json'), JSON.stringify({ name: '@acme/web' }));
+        await write(join(dir, 'web', '.code-feedback.yaml'), 'pipelines:\n  node:\n    - { name: test, command: "exit 3" }\n    - { name: build, command: "echo never" }\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should pick the built-in steps each project can run', async () => {
+        const project = (kind: any, path: string) => ({ name: 'p', kind, path, relativePath: '.', manifest: '' });
+        const without = async () => false;
+        expect((await defaultPipeline(project('go', dir), without)).map(s => s.name)).toEqual(['go build', 'go vet', 'go test']);
+        expect((await defaultPipeline(project('go', dir), async b => b === 'golangci-lint'))[1]).toMatchObject({ tool: 'golangci_lint', continueOnError: true });
+        expect(await defaultPipeline(project('python', join(dir, 'tooling')), without)).toEqual([]);
+
+        const web = join(dir, 'node-defaults');
+        await write(join(web, 'package.json'), JSON.stringify({ scripts: { test: 'echo "Error: no test specified" && exit 1' }, devDependencies: { typescript: '5' } }));
+        await write(join(web, 'tsconfig.json'), '{}');
+        await write(join(web, 'eslint.config.js'), 'export default [];\n');
+        expect((await defaultPipeline(project('node', web), without)).map(s => s.tool ?? s.command)).toEqual(['tsc_check', 'eslint']);
+        await write(join(web, 'package.json'), JSON.stringify({ devDependencies: { vitest: '2' } }));
+        expect((await defaultPipeline(project('node', web), without)).map(s => s.tool)).toEqual(['tsc_check', 'eslint', 'node_test']);
+        await fs.rm(web, { recursive: true });
+    });
+
+    it('should merge every project into one verdict grouped by directory', async () => {
+        const result: any = await fullRepoCheckTool.run({ path: dir });
+        expect(result.success).toBe(false);
+        expect(result.projects.map((p: any) => [p.directory, p.kind, p.pipeline])).toEqual([
+            ['services/api', 'go', 'configured'],
+            ['services/worker', 'go', 'configured'],
+            ['tooling', 'python', 'configured'],
+            ['web', 'node', 'configured'],
+        ]);
+        expect(result.verdict.failedSteps).toEqual(['tooling rules', 'web test']);
+        expect(result.verdict.steps).toEqual({ total: 5, passed: 2, failed: 2, skipped: 1 });
+        expect(result.severity).toEqual({ error: 1, warning: 1, info: 0 });
+
+        const tooling = result.directories.find((d: any) => d.directory === 'tooling');
+        expect(tooling).toMatchObject({ kinds: ['python'], passed: false, severity: { error: 1, warning: 1, info: 0 } });
+        expect(tooling.diagnostics.error[0]).toMatchObject({ line: 1, rule: 'no-print' });
+        expect(tooling.diagnostics.warning[0].rule).toBe('todo');
+        expect(result.directories.find((d: any) => d.directory === 'services/api').passed).toBe(true);
+        expect(result.output.split('\n')[0]).toMatch(/^FAIL: tooling rules, web test failed/);
+        expect(result.errors).toContain('web test: Exited with code 3');
+    });
+
+    it('should check only the languages asked for', async () => {
+        const result: any = await fullRepoCheckTool.run({ path: dir, languages: ['go'], detail: 'summary' });
+        expect(result.success).toBe(true);
+        expect(result.directories.map((d: any) => d.directory)).toEqual(['services/api', 'services/worker']);
+        expect(result.directories[0].diagnostics).toBeUndefined();
+        expect(result.output).toContain('services/worker (Go, ');
+
+        const none: any = await fullRepoCheckTool.run({ path: dir, languages: ['rust'] });
+        expect(none.errors).toEqual([`No Rust projects found under ${dir}`]);
+    });
+});
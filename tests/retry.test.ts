@@ -0,0 +1,69 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { backoffDelay, classifyFailure, retryPolicyFromEnv, type RetryEvent } from '../src/executor/retry.js';
+import { runCommand, withCommandDefaults } from '../src/utils/command.js';
+
+describe('Transient failure retries', () => {
+    let dir: string;
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-retry-'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should tell flaky infrastructure from broken code', () => {
+        expect(classifyFailure('go: downloading golang.org/x/text v0.14.0\ngo: golang.org/x/text@v0.14.0: Get "https://proxy.golang.org/golang.org/x/text/@v/v0.14.0.zip": dial tcp 142.250.1.1:443: i/o timeout')?.id).toBe('go-module-fetch');
+        expect(classifyFailure('go: example.com/m@v1.0.0: reading https://proxy.golang.org/example.com/m/@v/v1.0.0.mod: 502 Bad Gateway')?.id).toBe('go-module-fetch');
+        expect(classifyFailure('npm error code E503\nnpm error 503 Service Unavailable - GET https://registry.npmjs.org/left-pad')?.id).toBe('npm-registry');
+        expect(classifyFailure('npm ERR! code ECONNRESET\nnpm ERR! network aborted')?.id).toBe('npm-registry');
+        expect(classifyFailure("pip._vendor.urllib3.exceptions.ReadTimeoutError: HTTPSConnectionPool(host='files.pythonhosted.org', port=443): Read timed out.")?.id).toBe('pip-index');
+        expect(classifyFailure("fatal: unable to access 'https://github.com/a/b.git/': Could not resolve host: github.com")?.id).toBe('git-remote');
+        expect(classifyFailure('Error response from daemon: Get "https://registry-1.docker.io/v2/": net/http: TLS handshake timeout')?.id).toBe('docker-registry');
+
+        // What the code under test prints is not the toolchain's network trouble
+        expect(classifyFailure('--- FAIL: TestClient (0.01s)\n    client_test.go:12: dial tcp 127.0.0.1:8080: i/o timeout\nFAIL')).toBeNull();
+        expect(classifyFailure('npm error code ERESOLVE\nnpm error ERESOLVE unable to resolve dependency tree')).toBeNull();
+        expect(classifyFailure('src/a.ts(3,1): error TS2304: Cannot find name "x".')).toBeNull();
+        expect(classifyFailure('upload to nexus.internal failed: 503', ['nexus\\.internal.*50[0-4]'])).toMatchObject({ id: 'configured-1' });
+    });
+
+    it('should back off exponentially with jitter', () => {
+        expect([1, 2, 3].map(attempt => backoffDelay(1000, attempt, () => 0))).toEqual([1000, 2000, 4000]);
+        expect(backoffDelay(1000, 2, () => 1)).toBe(2500);
+        expect(backoffDelay(1000, 10, () => 0)).toBe(30000);
+        expect(retryPolicyFromEnv({})).toEqual({ attempts: 2, backoffMs: 2000 });
+        expect(retryPolicyFromEnv({ MCP_RETRY_ATTEMPTS: '0', MCP_RETRY_BACKOFF_MS: '500' })).toEqual({ attempts: 0, backoffMs: 500 });
+        expect(retryPolicyFromEnv({ MCP_RETRY_ATTEMPTS: 'many' })).toEqual({ attempts: 2, backoffMs: 2000 });
+    });
+
+    it('should retry a transient failure until it succeeds', async () => {
+        const counter = join(dir, 'runs');
+        // Fails like a flaky registry twice, then works
+        const command = `n=$(($(cat ${counter} 2>/dev/null || echo 0) + 1)); echo $n > ${counter}; if [ $n -lt 3 ]; then echo "npm error code E503" >&2; exit 1; fi; echo installed`;
+        const events: RetryEvent[] = [];
+        const result = await withCommandDefaults({ retry: { attempts: 3, backoffMs: 10 }, onRetry: event => events.push(event) }, () => runCommand(command, { cwd: dir }));
+        expect(result).toMatchObject({ exitCode: 0, stdout: 'installed\n', retries: 2 });
+        expect(events.map(e => [e.rule, e.attempt, e.exhausted ?? false])).toEqual([['npm-registry', 1, false], ['npm-registry', 2, false]]);
+    });
+
+    it('should report a failure still transient once retries run out', async () => {
+        const events: RetryEvent[] = [];
+        const failing = 'echo "npm error code ETIMEDOUT" >&2; exit 1';
+        const result = await withCommandDefaults({ retry: { attempts: 1, backoffMs: 10 }, onRetry: event => events.push(event) }, () => runCommand(failing));
+        expect(result).toMatchObject({ exitCode: 1, retries: 1, transientFailure: 'npm-registry' });
+        expect(events[1]).toMatchObject({ attempt: 1, exhausted: true });
+
+        const once = await withCommandDefaults({ retry: { attempts: 3, backoffMs: 10 } }, () => runCommand(failing, { retry: false }));
+        expect(once.retries).toBeUndefined();
+        expect(once.transientFailure).toBe('npm-registry');
+
+        const broken = await withCommandDefaults({ retry: { attempts: 3, backoffMs: 10 } }, () => runCommand('echo "expected 1, got 2" >&2; exit 1'));
+        expect(broken.retries).toBeUndefined();
+        expect(broken.transientFailure).toBeUndefined();
+    });
+});
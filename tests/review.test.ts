@@ -0,0 +1,250 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { join } from 'path';
+import { execSync } from 'child_process';
+import Config from '../src/config/index.js';
+import { ApiClient } from '../src/integrations/http.js';
+import { detectProvider, gitCredentialEnv, hostOptionsFor, patchLines, parseRemoteUrl } from '../src/integrations/index.js';
+import { planReview, publishReviewTool, renderInlineComment } from '../src/tools/review.js';
+
+const PATCH = [
+    '@@ -1,4 +1,5 @@',
+    ' package main',
+    '-import "fmt"',
+    '+import (',
+    '+\t"fmt"',
+    '+)',
+    ' func main() {}',
+    '@@ -20,2 +21,2 @@ func helper() {',
+    '-\treturn 1',
+    '+\treturn 2',
+    ' }',
+].join('\n');
+
+function fakeHost(routes: Record<string, unknown>, calls: Array<{ method: string; url: string; body?: any }>) {
+    return (async (url: string, init: any) => {
+        calls.push({ method: init.method, url, ...(init.body ? { body: JSON.parse(init.body) } : {}) });
+        const key = `${init.method} ${url.replace(/[?&](per_page|pagelen)=100/, '')}`;
+        if (!(key in routes)) return new Response(JSON.stringify({ message: 'Not Found' }), { status: 404 });
+        const route = routes[key];
+        return new Response(typeof route === 'string' ? route : JSON.stringify(route), { status: 200 });
+    }) as unknown as typeof fetch;
+}
+
+describe('review publishing', () => {
+    let root: string;
+    const savedFetch = globalThis.fetch;
+    const savedEnv = { GITHUB_TOKEN: process.env.GITHUB_TOKEN, GITLAB_TOKEN: process.env.GITLAB_TOKEN, BITBUCKET_TOKEN: process.env.BITBUCKET_TOKEN };
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-review-'));
+        Config.getInstance().addAllowedPaths([root]);
+        execSync('git init -q && git remote add origin git@github.com:acme/widgets.git', { cwd: root });
+    });
+
+    afterAll(async () => {
+        globalThis.fetch = savedFetch;
+        for (const [name, value] of Object.entries(savedEnv)) {
+            if (value === undefined) delete process.env[name];
+            else process.env[name] = value;
+        }
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should parse remotes and detect the provider', () => {
+        expect(parseRemoteUrl('git@github.com:acme/widgets.git')).toEqual({ host: 'github.com', path: 'acme/widgets' });
+        expect(parseRemoteUrl('https://gitlab.example.com/group/sub/widgets.git\n')).toEqual({ host: 'gitlab.example.com', path: 'group/sub/widgets' });
+        expect(parseRemoteUrl('ssh://git@ghe.example.com:2222/acme/widgets.git')).toEqual({ host: 'ghe.example.com', path: 'acme/widgets' });
+        expect(parseRemoteUrl('not a url')).toBeNull();
+        expect(detectProvider('bitbucket.org')).toBe('bitbucket');
+        expect(detectProvider('gitlab.example.com')).toBe('gitlab');
+        expect(detectProvider('ghe.example.com')).toBe('github');
+    });
+
+    it('should read tokens and API URLs from the environment', () => {
+        expect(hostOptionsFor('github', { env: { GH_TOKEN: 't' } })).toEqual({ token: 't', apiUrl: 'https://api.github.com' });
+        expect(hostOptionsFor('github', { env: { GITHUB_API_URL: 'https://ghe.example.com/api/v3/' } })).toEqual({ apiUrl: 'https://ghe.example.com/api/v3' });
+        expect(hostOptionsFor('gitlab', { env: { GITLAB_TOKEN: 'g' }, host: 'gitlab.example.com' })).toEqual({ token: 'g', apiUrl: 'https://gitlab.example.com/api/v4' });
+        expect(hostOptionsFor('gitlab', { env: {}, apiUrl: 'https://git.corp/api/v4/' }).apiUrl).toBe('https://git.corp/api/v4');
+        expect(hostOptionsFor('bitbucket', { env: { BITBUCKET_USERNAME: 'me', BITBUCKET_APP_PASSWORD: 'pw' } })).toEqual({ token: 'pw', username: 'me', apiUrl: 'https://api.bitbucket.org/2.0' });
+    });
+
+    it('should send git credentials only to the host they belong to', () => {
+        const header = (env: Record<string, string>) => env.GIT_CONFIG_VALUE_0;
+        expect(header(gitCredentialEnv('https://github.com/acme/widgets.git', 'github', { GITHUB_TOKEN: 't' }))).toBe(`Authorization: Basic ${Buffer.from('x-access-token:t').toString('base64')}`);
+        expect(gitCredentialEnv('https://evil.example/x.git', 'github', { GITHUB_TOKEN: 't' })).toEqual({ GIT_TERMINAL_PROMPT: '0' });
+        expect(gitCredentialEnv('https://github.com.evil.example/x.git', 'github', { GITHUB_TOKEN: 't' })).toEqual({ GIT_TERMINAL_PROMPT: '0' });
+        const enterprise = { GITHUB_TOKEN: 't', GITHUB_API_URL: 'https://ghe.example.com/api/v3' };
+        expect(header(gitCredentialEnv('https://ghe.example.com/acme/widgets.git', 'github', enterprise))).toBeDefined();
+        expect(header(gitCredentialEnv('https://github.com/acme/widgets.git', 'github', enterprise))).toBeUndefined();
+        // A self-hosted GitLab gets the token only when its API URL is configured
+        expect(header(gitCredentialEnv('https://gitlab.evil.example/x.git', 'gitlab', { GITLAB_TOKEN: 'g' }))).toBeUndefined();
+        expect(header(gitCredentialEnv('https://gitlab.example.com/x.git', 'gitlab', { GITLAB_TOKEN: 'g', GITLAB_API_URL: 'https://gitlab.example.com/api/v4' }))).toBeDefined();
+    });
+
+    it('should map commentable lines to their old lines', () => {
+        const lines = patchLines(PATCH);
+        expect([...lines.keys()].sort((a, b) => a - b)).toEqual([1, 2, 3, 4, 5, 21, 22]);
+        expect(lines.get(1)).toBe(1);
+        expect(lines.get(3)).toBeNull();
+        expect(lines.get(5)).toBe(3);
+    });
+
+    it('should plan inline comments and summary findings', () => {
+        const patches = [{ path: 'main.go', oldPath: 'main.go', lines: patchLines(PATCH) }];
+        const plan = planReview([
+            { path: 'main.go', file: 'main.go', line: 3, severity: 'warning', message: 'unused import' },
+            { path: 'main.go', file: 'main.go', line: 3, severity: 'error', message: 'syntax', source: 'go', rule: 'build' },
+            { path: 'main.go', file: 'main.go', line: 10, severity: 'error', message: 'not in the diff' },
+            { path: 'other.go', file: 'other.go', line: 1, severity: 'error', message: 'untouched file' },
+        ], patches, 10);
+        expect(plan.comments).toHaveLength(1);
+        expect(plan.comments[0]).toMatchObject({ path: 'main.go', line: 3 });
+        expect(plan.comments[0]!.oldLine).toBeUndefined();
+        expect(plan.comments[0]!.body).toContain('- **error** (go build): syntax');
+        expect(plan.outsideDiff.map(d => d.line)).toEqual([10]);
+        expect(plan.otherFiles.map(d => d.path)).toEqual(['other.go']);
+
+        const capped = planReview([
+            { path: 'main.go', file: 'main.go', line: 1, severity: 'info', message: 'a' },
+            { path: 'main.go', file: 'main.go', line: 21, severity: 'error', message: 'b' },
+        ], patches, 1);
+        expect(capped.comments.map(c => c.line)).toEqual([21]);
+        expect(planReview([{ path: 'main.go', file: 'main.go', line: 5, severity: 'info', message: 'c' }], patches, 1).comments[0]?.oldLine).toBe(3);
+        expect(capped.outsideDiff.map(d => d.message)).toEqual(['a']);
+    });
+
+    it('should follow pagination links', async () => {
+        let page = 0;
+        const client = new ApiClient({
+            name: 'GitHub',
+            baseUrl: 'https://api.github.com',
+            headers: {},
+            fetch: (async () => {
+                page++;
+                return new Response(JSON.stringify([{ filename: `f${page}` }]), {
+                    status: 200,
+                    headers: page === 1 ? { link: '<https://api.github.com/repos/a/b/pulls/1/files?page=2>; rel="next"' } : {},
+                });
+            }) as unknown as typeof fetch,
+        });
+        const files = await client.paginate<{ filename: string }>('/repos/a/b/pulls/1/files');
+        expect(files.map(f => f.filename)).toEqual(['f1', 'f2']);
+    });
+
+    it('should post a review and skip comments already posted', async () => {
+        process.env.GITHUB_TOKEN = 'secret';
+        const calls: Array<{ method: string; url: string; body?: any }> = [];
+        const repeated = renderInlineComment([{ file: 'main.go', line: 21, severity: 'warning', message: 'old finding' }]);
+        globalThis.fetch = fakeHost({
+            'GET https://api.github.com/repos/acme/widgets/pulls/7': { number: 7, html_url: 'https://github.com/acme/widgets/pull/7', state: 'open', head: { sha: 'abc123' } },
+            'GET https://api.github.com/repos/acme/widgets/pulls/7/files': [{ filename: 'main.go', status: 'modified', patch: PATCH }],
+            'GET https://api.github.com/repos/acme/widgets/pulls/7/comments': [{ path: 'main.go', line: 21, body: repeated }],
+            'POST https://api.github.com/repos/acme/widgets/pulls/7/reviews': { id: 99, html_url: 'https://github.com/acme/widgets/pull/7#pullrequestreview-99' },
+        }, calls);
+
+        const result: any = await publishReviewTool.run({
+            repoPath: root,
+            pullNumber: 7,
+            diagnostics: [
+                { file: join(root, 'main.go'), line: 3, severity: 'error', message: 'syntax error' },
+                { file: 'main.go', line: 21, severity: 'warning', message: 'old finding' },
+            ],
+        });
+        expect(result.errors).toEqual([]);
+        expect(result.published).toBe(true);
+        expect(result.provider).toBe('github');
+        expect(result.reviewId).toBe('99');
+        const post = calls.find(c => c.method === 'POST');
+        expect(post?.body.commit_id).toBe('abc123');
+        expect(post?.body.event).toBe('REQUEST_CHANGES');
+        expect(post?.body.comments).toHaveLength(1);
+        expect(post?.body.comments[0]).toMatchObject({ path: 'main.go', line: 3, side: 'RIGHT' });
+        expect(result.skipped.repeated).toBe(1);
+    });
+
+    it('should not post in dry run mode', async () => {
+        const calls: Array<{ method: string; url: string; body?: any }> = [];
+        globalThis.fetch = fakeHost({
+            'GET https://api.github.com/repos/other/repo/pulls/2': { number: 2, html_url: 'https://github.com/other/repo/pull/2', state: 'open', head: { sha: 'def' } },
+            'GET https://api.github.com/repos/other/repo/pulls/2/files': [{ filename: 'main.go', status: 'modified', patch: PATCH }],
+            'GET https://api.github.com/repos/other/repo/pulls/2/comments': [],
+        }, calls);
+        const result: any = await publishReviewTool.run({
+            repoPath: root,
+            pullNumber: 2,
+            repository: 'other/repo',
+            dryRun: true,
+            diagnostics: [{ file: 'main.go', line: 10, severity: 'warning', message: 'outside the diff' }],
+        });
+        expect(result.dryRun).toBe(true);
+        expect(result.review.event).toBe('COMMENT');
+        expect(result.review.body).toContain('`main.go:10` **warning**: outside the diff');
+        expect(calls.some(c => c.method === 'POST')).toBe(false);
+    });
+
+    it('should post GitLab merge request discussions', async () => {
+        process.env.GITLAB_TOKEN = 'glpat';
+        const calls: Array<{ method: string; url: string; body?: any }> = [];
+        const api = 'https://gitlab.com/api/v4/projects/group%2Fsub%2Fwidgets/merge_requests/5';
+        globalThis.fetch = fakeHost({
+            [`GET ${api}`]: { web_url: 'https://gitlab.com/group/sub/widgets/-/merge_requests/5', sha: 'h', diff_refs: { base_sha: 'b', start_sha: 's', head_sha: 'h' } },
+            [`GET ${api}/diffs`]: [{ old_path: 'main.go', new_path: 'main.go', diff: PATCH, deleted_file: false }],
+            [`GET ${api}/discussions`]: [],
+            [`POST ${api}/discussions`]: { id: 'd1' },
+            [`POST ${api}/notes`]: { id: 42 },
+        }, calls);
+        const result: any = await publishReviewTool.run({
+            repoPath: root,
+            pullNumber: 5,
+            provider: 'gitlab',
+            repository: 'group/sub/widgets',
+            diagnostics: [
+                { file: 'main.go', line: 3, severity: 'error', message: 'added line' },
+                { file: 'main.go', line: 5, severity: 'warning', message: 'context line' },
+            ],
+        });
+        expect(result.errors).toEqual([]);
+        expect(result.reviewUrl).toBe('https://gitlab.com/group/sub/widgets/-/merge_requests/5#note_42');
+        expect(result.warnings).toContain('GitLab has no API to request changes; the review was posted as comments');
+        const discussions = calls.filter(c => c.method === 'POST' && c.url.endsWith('/discussions'));
+        expect(discussions.map(d => d.body.position)).toEqual([
+            { position_type: 'text', base_sha: 'b', start_sha: 's', head_sha: 'h', old_path: 'main.go', new_path: 'main.go', new_line: 3 },
+            { position_type: 'text', base_sha: 'b', start_sha: 's', head_sha: 'h', old_path: 'main.go', new_path: 'main.go', new_line: 5, old_line: 3 },
+        ]);
+    });
+
+    it('should post Bitbucket comments and request changes', async () => {
+        process.env.BITBUCKET_TOKEN = 'bb';
+        const calls: Array<{ method: string; url: string; body?: any }> = [];
+        const api = 'https://api.bitbucket.org/2.0/repositories/team/widgets/pullrequests/3';
+        globalThis.fetch = fakeHost({
+            [`GET ${api}`]: { links: { html: { href: 'https://bitbucket.org/team/widgets/pull-requests/3' } }, source: { commit: { hash: 'abc' } } },
+            [`GET ${api}/diff`]: `diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n${PATCH}\n`,
+            [`GET ${api}/comments`]: { values: [] },
+            [`POST ${api}/comments`]: { id: 8 },
+            [`POST ${api}/request-changes`]: {},
+        }, calls);
+        const result: any = await publishReviewTool.run({
+            repoPath: root,
+            pullNumber: 3,
+            provider: 'bitbucket',
+            repository: 'team/widgets',
+            diagnostics: [{ file: 'main.go', line: 22, severity: 'error', message: 'wrong value' }],
+        });
+        expect(result.errors).toEqual([]);
+        expect(result.reviewUrl).toBe('https://bitbucket.org/team/widgets/pull-requests/3#comment-8');
+        const posts = calls.filter(c => c.method === 'POST');
+        expect(posts[0]?.body).toMatchObject({ inline: { path: 'main.go', to: 22 } });
+        expect(posts.some(c => c.url.endsWith('/request-changes'))).toBe(true);
+    });
+
+    it('should name the token variables when none is set', async () => {
+        delete process.env.GITLAB_TOKEN;
+        delete process.env.MCP_GITLAB_TOKEN;
+        const result: any = await publishReviewTool.run({ repoPath: root, pullNumber: 1, provider: 'gitlab', repository: 'a/b', diagnostics: [] });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('MCP_GITLAB_TOKEN or GITLAB_TOKEN');
+    });
+});
@@ -0,0 +1,123 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { projectConfigSchema, mergeConfigs, type CustomRule } from '../src/config/project.js';
+import { matchPatternRules, ruleApplies } from '../src/rules/index.js';
+import { checkRulesTool } from '../src/tools/rules.js';
+import { goAstQueryTool } from '../src/tools/goast.js';
+
+const MAIN = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+`;
+
+const STORE = `package store
+
+import (
+	f "fmt"
+	"github.com/pkg/errors"
+)
+
+func Save() error {
+	f.Println("saving")
+	fmt := struct{ Println func(string) }{}
+	fmt.Println("shadowed")
+	return errors.New("not implemented")
+}
+`;
+
+const CONFIG = `rules:
+  - id: no-console
+    pattern: "console\\\\.log\\\\("
+    files: ["web/**/*.ts"]
+    exclude: ["web/debug.ts"]
+    message: Use the logger
+    severity: error
+  - { id: no-println, go: { call: fmt.Println, exceptPackages: [main] }, message: Log instead of printing }
+  - { id: no-pkg-errors, go: { import: github.com/pkg/... }, message: Use the standard errors package, severity: info }
+`;
+
+function rule(overrides: Partial<CustomRule>): CustomRule {
+    return { id: 'r', message: 'm', severity: 'warning', ...overrides } as CustomRule;
+}
+
+describe('custom rules', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-rules-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, '.code-feedback.yaml'), CONFIG);
+        await fs.writeFile(join(root, '.gitignore'), 'dist/\n');
+        await fs.writeFile(join(root, 'go.mod'), 'module example.com/app\n\ngo 1.21\n');
+        await fs.writeFile(join(root, 'main.go'), MAIN);
+        await fs.mkdir(join(root, 'store'));
+        await fs.writeFile(join(root, 'store', 'store.go'), STORE);
+        await fs.mkdir(join(root, 'web'));
+        await fs.mkdir(join(root, 'dist', 'web'), { recursive: true });
+        await fs.writeFile(join(root, 'web', 'app.ts'), 'const a = 1;\nconsole.log(a); console.log(a);\n');
+        await fs.writeFile(join(root, 'web', 'debug.ts'), 'console.log("debug");\n');
+        await fs.writeFile(join(root, 'dist', 'web', 'app.ts'), 'console.log("built");\n');
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should validate rules in the project config', () => {
+        expect(projectConfigSchema.safeParse({ rules: [{ id: 'x', message: 'm' }] }).success).toBe(false);
+        expect(projectConfigSchema.safeParse({ rules: [{ id: 'x', message: 'm', pattern: '(' }] }).success).toBe(false);
+        expect(projectConfigSchema.safeParse({ rules: [{ id: 'x', message: 'm', go: { call: 'Println' } }] }).success).toBe(false);
+        const parsed = projectConfigSchema.parse({ rules: [{ id: 'x', message: 'm', pattern: 'TODO' }] });
+        expect(parsed.rules?.[0]?.severity).toBe('warning');
+    });
+
+    it('should let project rules replace global rules by id', () => {
+        const merged = mergeConfigs({ rules: [rule({ id: 'a', pattern: 'a' }), rule({ id: 'b', pattern: 'b' })] }, { rules: [rule({ id: 'b', pattern: 'B' })] });
+        expect(merged.rules?.map(r => `${r.id}=${r.pattern}`)).toEqual(['a=a', 'b=B']);
+    });
+
+    it('should match patterns per line and apply globs', () => {
+        const found = matchPatternRules([rule({ id: 'todo', pattern: 'TODO' })], '/x/a.ts', 'ok\r\n// TODO one TODO two\n');
+        expect(found.map(d => `${d.line}:${d.column}`)).toEqual(['2:4', '2:13']);
+        expect(found[0]).toMatchObject({ rule: 'todo', source: 'custom-rules', severity: 'warning' });
+        const scoped = rule({ files: ['src/**'], exclude: ['**/*_test.go'] });
+        expect(ruleApplies(scoped, '/repo', '/repo/src/a.go')).toBe(true);
+        expect(ruleApplies(scoped, '/repo', '/repo/src/a_test.go')).toBe(false);
+        expect(ruleApplies(scoped, '/repo', '/repo/cmd/a.go')).toBe(false);
+    });
+
+    it('should list calls of imported package functions', async () => {
+        const result: any = await goAstQueryTool.run({ path: root, query: 'calls' });
+        expect(result.success).toBe(true);
+        expect(result.results.map((c: any) => `${c.package} ${c.callee} ${c.importPath}`)).toEqual([
+            'main fmt.Println fmt',
+            'store f.Println fmt',
+            'store errors.New github.com/pkg/errors',
+        ]);
+    });
+
+    it('should report regex and Go rule findings as diagnostics', async () => {
+        const result: any = await checkRulesTool.run({ path: root })
+        expect(result.success).toBe(false);
+        expect(result.diagnostics.map((d: any) => `${d.file.slice(root.length + 1)}:${d.line}:${d.column} ${d.rule} ${d.severity}`)).toEqual([
+            'store/store.go:5:2 no-pkg-errors info',
+            'store/store.go:9:2 no-println warning',
+            'web/app.ts:2:1 no-console error',
+            'web/app.ts:2:17 no-console error',
+        ]);
+        expect(result.errors).toEqual(['web/app.ts:2: Use the logger (no-console)', 'web/app.ts:2: Use the logger (no-console)']);
+        expect(result.output).toBe('4 finding(s) of 3 rule(s) (2 error, 1 warning, 1 info) in 1 file(s) matched by pattern rules');
+
+        const goOnly: any = await checkRulesTool.run({ path: root, only: ['no-println'] });
+        expect(goOnly.success).toBe(true);
+        expect(goOnly.diagnostics).toHaveLength(1);
+        expect((await checkRulesTool.run({ path: root, only: ['nope'] })).errors).toEqual(['Unknown rule(s): nope']);
+    });
+});
@@ -0,0 +1,38 @@
+import { describe, it, expect } from 'vitest';
+import { registerTools } from '../src/tools';
+
+class DummyServer {
+    tools: any[] = [];
+    registerTool(tool: any) { this.tools.push(tool); }
+}
+
+describe('Rust Tool', () => {
+    it('should register the rust tool', () => {
+        const server = new DummyServer();
+        registerTools(server);
+        const tool = server.tools.find(t => t.name === 'rust');
+        expect(tool).toBeDefined();
+        expect(tool.inputSchema).toBeDefined();
+        expect(typeof tool.run).toBe('function');
+    });
+
+    it('should validate input schema for required and optional fields', () => {
+        const server = new DummyServer();
+        registerTools(server);
+        const tool = server.tools.find(t => t.name === 'rust');
+        expect(tool.inputSchema.properties.filePath).toBeDefined();
+        expect(tool.inputSchema.properties.actions).toBeDefined();
+        expect(tool.inputSchema.properties.command).toBeDefined();
+        expect(tool.inputSchema.required).toContain('filePath');
+        expect(tool.inputSchema.properties.actions.items.enum).toEqual([
+            'build', 'test', 'clippy', 'fmt'
+        ]);
+    });
+
+    it('should reject paths outside the allowed directories', async () => {
+        const tool = (await import('../src/tools/rust.js')).rustTool;
+        const result = await tool.run({ filePath: '/invalid/path/main.rs' });
+        expect(result.success).toBe(false);
+        expect(result.errors).toContain('Path not allowed');
+    });
+});
@@ -0,0 +1,52 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { validatePath } from '../src/utils/sandbox.js';
+
+describe('Workspace sandbox', () => {
+    let root: string;
+    let outside: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-sandbox-'));
+        outside = await fs.mkdtemp(join(tmpdir(), 'cf-outside-'));
+        await fs.mkdir(join(root, 'rw'), { recursive: true });
+        await fs.mkdir(join(root, 'rw', 'vendor'), { recursive: true });
+        await fs.writeFile(join(root, 'rw', 'file.txt'), 'hello');
+        await fs.writeFile(join(outside, 'secret.txt'), 'secret');
+        await fs.symlink(join(outside, 'secret.txt'), join(root, 'rw', 'escape.txt'));
+        Config.getInstance().addAllowedPaths([join(root, 'rw')]);
+        Config.getInstance().addReadOnlyPaths([join(root, 'rw', 'vendor')]);
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+        await fs.rm(outside, { recursive: true, force: true });
+    });
+
+    it('should resolve files inside an allowed root', async () => {
+        const resolved = await validatePath(join(root, 'rw', 'file.txt'), 'write');
+        expect(resolved.endsWith('file.txt')).toBe(true);
+    });
+
+    it('should allow new files in nested directories that do not exist yet', async () => {
+        const target = join(root, 'rw', 'a', 'b', 'new.txt');
+        await expect(validatePath(target, 'write')).resolves.toBe(target);
+    });
+
+    it('should reject sibling directories sharing a prefix', async () => {
+        await expect(validatePath(join(root, 'rw-other', 'file.txt'))).rejects.toThrow('Path not allowed');
+    });
+
+    it('should reject symlinks that escape the allowed roots', async () => {
+        await expect(validatePath(join(root, 'rw', 'escape.txt'))).rejects.toThrow('symlink target');
+    });
+
+    it('should allow reads but reject writes in read-only roots', async () => {
+        const target = join(root, 'rw', 'vendor', 'lib.txt');
+        await expect(validatePath(target, 'read')).resolves.toBe(target);
+        await expect(validatePath(target, 'write')).rejects.toThrow('read-only');
+    });
+});
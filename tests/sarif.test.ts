@@ -0,0 +1,81 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { toSarif } from '../src/diagnostics/sarif.js';
+import { exportSarifTool } from '../src/tools/sarif.js';
+import { parseCliArgs } from '../src/cli.js';
+
+describe('SARIF export', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-sarif-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, '.code-feedback.yaml'), [
+            'pipelines:',
+            '  default:',
+            '    - { name: check, command: "exit 3" }',
+        ].join('\n'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should group results by tool with rules and relative locations', () => {
+        const sarif = toSarif([
+            { file: '/repo/cmd/main.go', line: 12, column: 4, severity: 'error', message: 'undefined: x', source: 'go build' },
+            { file: '/repo/pkg/a b.go', line: 3, column: 0, severity: 'warning', message: 'unused', rule: 'unused', source: 'golangci-lint', fixable: true },
+            { file: '/repo/pkg/c.go', line: 0, column: 0, severity: 'info', message: 'note', rule: 'unused', source: 'golangci-lint' },
+            { file: '/elsewhere/x.go', line: 1, column: 1, severity: 'info', message: 'outside', rule: 'other', source: 'golangci-lint' },
+        ], { root: '/repo' });
+        expect(sarif.version).toBe('2.1.0');
+        expect(sarif.runs.map(r => r.tool.driver.name)).toEqual(['go build', 'golangci-lint']);
+        expect(sarif.runs[0]!.originalUriBaseIds).toEqual({ SRCROOT: { uri: 'file:///repo/' } });
+        expect(sarif.runs[0]!.results[0]).toEqual({
+            ruleId: 'go build',
+            ruleIndex: 0,
+            level: 'error',
+            message: { text: 'undefined: x' },
+            locations: [{ physicalLocation: { artifactLocation: { uri: 'cmd/main.go', uriBaseId: 'SRCROOT' }, region: { startLine: 12, startColumn: 4 } } }],
+        });
+        const lint = sarif.runs[1]!;
+        expect(lint.tool.driver.rules.map(r => r.id)).toEqual(['unused', 'other']);
+        expect(lint.results.map(r => [r.ruleIndex, r.level])).toEqual([[0, 'warning'], [0, 'note'], [1, 'note']]);
+        expect(lint.results[0]!.locations[0]!.physicalLocation).toEqual({ artifactLocation: { uri: 'pkg/a%20b.go', uriBaseId: 'SRCROOT' }, region: { startLine: 3 } });
+        expect(lint.results[0]!.properties).toEqual({ fixable: true });
+        expect(lint.results[1]!.locations[0]!.physicalLocation.region).toBeUndefined();
+        expect(lint.results[2]!.locations[0]!.physicalLocation.artifactLocation).toEqual({ uri: 'file:///elsewhere/x.go' });
+        expect(toSarif([]).runs).toEqual([{ tool: { driver: { name: 'code-feedback', rules: [] } }, results: [] }]);
+    });
+
+    it('should write given diagnostics to a file', async () => {
+        const result: any = await exportSarifTool.run({
+            path: root,
+            diagnostics: [{ file: join(root, 'main.py'), line: 2, message: 'E501 line too long', rule: 'E501', source: 'ruff' }],
+            outputFile: 'out/results.sarif',
+        });
+        expect(result.success).toBe(true);
+        expect(result.output).toBe(`1 result from 1 tool(s) written to ${join(root, 'out', 'results.sarif')}`);
+        const written = JSON.parse(await fs.readFile(join(root, 'out', 'results.sarif'), 'utf-8'));
+        expect(written.runs[0].results[0].locations[0].physicalLocation.artifactLocation.uri).toBe('main.py');
+    });
+
+    it('should export a pipeline and warn about steps without diagnostics', async () => {
+        const result: any = await exportSarifTool.run({ path: root, pipeline: 'default' });
+        expect(result.success).toBe(true);
+        expect(result.warnings).toEqual(['Step check failed without diagnostics']);
+        expect(result.sarif.runs[0].results).toEqual([]);
+        expect((await exportSarifTool.run({ path: root })).errors).toEqual(['Pass diagnostics or a pipeline to run']);
+        expect((await exportSarifTool.run({ path: root, pipeline: 'missing' })).errors[0]).toContain('Pipeline "missing" not found');
+    });
+
+    it('should parse the export-sarif command', () => {
+        expect(parseCliArgs(['export-sarif'], {})).toEqual({ command: 'export-sarif', path: '.', pipeline: 'default' });
+        expect(parseCliArgs(['export-sarif', '--path', 'repo', '--pipeline=ci', '--output', 'out.sarif', '--input', '-'], {}))
+            .toEqual({ command: 'export-sarif', path: 'repo', pipeline: 'ci', output: 'out.sarif', input: '-' });
+        expect(() => parseCliArgs(['export-sarif', '--http', ':80'], {})).toThrow('Unknown argument: --http');
+    });
+});
@@ -0,0 +1,68 @@
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { renderTemplate, resolveVariables, scaffoldProjectTool } from '../src/tools/scaffold.js';
+
+describe('Template rendering', () => {
+    it('should substitute variables and report undefined ones', () => {
+        expect(renderTemplate('module {{ module }}\n{{name}}', { module: 'example.com/x', name: 'x' })).toEqual({ text: 'module example.com/x\nx', missing: [] });
+        const result = renderTemplate('{{name}} {{owner}} ${{ github.ref }}', { name: 'x' });
+        expect(result).toEqual({ text: 'x {{owner}} ${{ github.ref }}', missing: ['owner'] });
+    });
+
+    it('should derive name and package and render defaults', () => {
+        const variables = resolveVariables({ module: { description: '', default: 'example.com/{{name}}' } }, '/work/My-Service', {});
+        expect(variables).toEqual({ name: 'My-Service', package: 'my_service', description: '', module: 'example.com/My-Service' });
+        expect(resolveVariables({ module: { description: '', default: 'x' } }, '/work/a', { name: 'b', module: 'given' }).module).toBe('given');
+    });
+});
+
+describe('scaffold_project', () => {
+    let root: string;
+
+    beforeEach(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-scaffold-'));
+        Config.getInstance().addAllowedPaths([root]);
+    });
+
+    afterEach(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should create a project from a built-in template', async () => {
+        const result = await scaffoldProjectTool.run({ path: join(root, 'svc'), template: 'python', variables: { description: 'Billing API' } });
+        expect(result.success).toBe(true);
+        expect(result.files).toContain('src/svc/core.py');
+        expect(await fs.readFile(join(root, 'svc/pyproject.toml'), 'utf-8')).toContain('description = "Billing API"');
+    });
+
+    it('should refuse to scaffold into a non-empty directory', async () => {
+        await fs.writeFile(join(root, 'existing.txt'), 'x');
+        const result = await scaffoldProjectTool.run({ path: root, template: 'go' });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('not empty');
+    });
+
+    it('should render a template directory with its manifest', async () => {
+        const templateDir = join(root, 'template');
+        await fs.mkdir(join(templateDir, 'cmd/{{name}}'), { recursive: true });
+        await fs.writeFile(join(templateDir, 'template.yaml'), 'description: Service\nvariables:\n  owner:\n    description: Team\n    default: platform\n');
+        await fs.writeFile(join(templateDir, 'cmd/{{name}}/main.go.tmpl'), 'package main // owned by {{owner}}\n');
+        const result = await scaffoldProjectTool.run({ path: join(root, 'out/api'), template: templateDir });
+        expect(result.success).toBe(true);
+        expect(result.files).toEqual(['cmd/api/main.go']);
+        expect(await fs.readFile(join(root, 'out/api/cmd/api/main.go'), 'utf-8')).toBe('package main // owned by platform\n');
+    });
+
+    it('should write nothing when a variable is missing', async () => {
+        const templateDir = join(root, 'template');
+        await fs.mkdir(templateDir);
+        await fs.writeFile(join(templateDir, 'a.txt'), 'ok');
+        await fs.writeFile(join(templateDir, 'b.txt'), '{{owner}}');
+        const result = await scaffoldProjectTool.run({ path: join(root, 'out'), template: templateDir });
+        expect(result.errors).toEqual(['Missing template variables: owner']);
+        expect(await fs.stat(join(root, 'out')).then(() => true, () => false)).toBe(false);
+    });
+});
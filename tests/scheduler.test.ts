@@ -0,0 +1,117 @@
+import { describe, it, expect } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import { Scheduler, resolveWorkspace, isMutatingCall } from '../src/scheduler/index.js';
+import { editor } from '../src/tools/editor.js';
+import { filesystem } from '../src/tools/filesystem.js';
+
+const sleep = (ms: number) => new Promise(resolve => setTimeout(resolve, ms));
+
+// Runs a task that records when it starts and finishes
+function tracked(log: string[], name: string, ms = 20) {
+    return async () => {
+        log.push(`${name}:start`);
+        await sleep(ms);
+        log.push(`${name}:end`);
+        return name;
+    };
+}
+
+describe('Scheduler', () => {
+    it('should run read-only calls on the same workspace concurrently', async () => {
+        const scheduler = new Scheduler(4);
+        const log: string[] = [];
+        await Promise.all([
+            scheduler.run('/work', false, tracked(log, 'build')),
+            scheduler.run('/work', false, tracked(log, 'vet')),
+        ]);
+        expect(log.slice(0, 2).sort()).toEqual(['build:start', 'vet:start']);
+    });
+
+    it('should serialize edits against builds in the same workspace', async () => {
+        const scheduler = new Scheduler(4);
+        const log: string[] = [];
+        await Promise.all([
+            scheduler.run('/work', false, tracked(log, 'build')),
+            scheduler.run('/work', true, tracked(log, 'edit')),
+            scheduler.run('/work', false, tracked(log, 'test')),
+        ]);
+        expect(log).toEqual(['build:start', 'build:end', 'edit:start', 'edit:end', 'test:start', 'test:end']);
+    });
+
+    it('should not block edits in other workspaces', async () => {
+        const scheduler = new Scheduler(4);
+        const log: string[] = [];
+        await Promise.all([
+            scheduler.run('/a', true, tracked(log, 'a')),
+            scheduler.run('/b', true, tracked(log, 'b')),
+        ]);
+        expect(log.slice(0, 2).sort()).toEqual(['a:start', 'b:start']);
+    });
+
+    it('should cap concurrency at the pool size', async () => {
+        const scheduler = new Scheduler(2);
+        let running = 0;
+        let peak = 0;
+        const task = async () => {
+            running++;
+            peak = Math.max(peak, running);
+            await sleep(10);
+            running--;
+        };
+        await Promise.all(Array.from({ length: 6 }, (_, i) => scheduler.run(`/w${i}`, false, task)));
+        expect(peak).toBe(2);
+        expect(scheduler.getStats()).toMatchObject({ active: 0, queued: 0, lockedWorkspaces: 0 });
+    });
+
+    it('should give free workers to interactive calls before background ones', async () => {
+        const scheduler = new Scheduler(1);
+        const log: string[] = [];
+        const positions: string[] = [];
+        await Promise.all([
+            scheduler.run('/a', false, tracked(log, 'first')),
+            scheduler.run('/b', false, tracked(log, 'ci'), { priority: 'background', onQueued: (position, queued) => positions.push(`${position}/${queued}`) }),
+            scheduler.run('/c', false, tracked(log, 'agent'), { priority: 'interactive' }),
+        ]);
+        expect(log.filter(e => e.endsWith(':start'))).toEqual(['first:start', 'agent:start', 'ci:start']);
+        // Queued first, then pushed back by the interactive call, then next in line
+        expect(positions).toEqual(['1/1', '2/2', '1/1']);
+    });
+
+    it('should refuse calls beyond the maximum queue depth', async () => {
+        const scheduler = new Scheduler(1, 1);
+        const log: string[] = [];
+        const running = scheduler.run('/a', false, tracked(log, 'running'));
+        const queued = scheduler.run('/b', false, tracked(log, 'queued'));
+        await expect(scheduler.run('/c', false, tracked(log, 'refused'))).rejects.toThrow('Server busy: 1 tool call(s) already queued');
+        await sleep(5);
+        expect(scheduler.getStats()).toMatchObject({ active: 1, queued: 1, queuedByPriority: { interactive: 1, background: 0 }, maxQueued: 1 });
+        await Promise.all([running, queued]);
+        expect(log).not.toContain('refused:start');
+        expect(await scheduler.run('/c', false, async () => 'ok')).toBe('ok');
+    });
+
+    it('should release the workspace when a call throws', async () => {
+        const scheduler = new Scheduler(1);
+        await expect(scheduler.run('/work', true, async () => { throw new Error('boom'); })).rejects.toThrow('boom');
+        expect(await scheduler.run('/work', true, async () => 'ok')).toBe('ok');
+    });
+
+    it('should key workspaces by repository root', async () => {
+        const root = await fs.mkdtemp(join(tmpdir(), 'cf-scheduler-'));
+        await fs.mkdir(join(root, '.git'));
+        await fs.mkdir(join(root, 'pkg'));
+        expect(await resolveWorkspace(join(root, 'pkg', 'main.go'), null)).toBe(root);
+        expect(await resolveWorkspace(join(root, 'pkg'), '/configured')).toBe('/configured');
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should classify mutating calls', () => {
+        expect(isMutatingCall(editor, { action: 'read' })).toBe(false);
+        expect(isMutatingCall(editor, { action: 'edit' })).toBe(true);
+        expect(isMutatingCall(filesystem, { ops: [{ type: 'readFile' }] })).toBe(false);
+        expect(isMutatingCall(filesystem, { ops: [{ type: 'readFile' }, { type: 'delete' }] })).toBe(true);
+        expect(isMutatingCall({}, {})).toBe(false);
+    });
+});
@@ -0,0 +1,83 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { scanForSecrets, shannonEntropy } from '../src/utils/secrets.js';
+import { editor } from '../src/tools/editor.js';
+import { mergeConfigs, strictestSecretScan } from '../src/config/project.js';
+
+// Built by concatenation so the fixtures themselves do not trip secret scanners
+const AWS_KEY_ID = 'AKIA' + 'IOSFODNN7EXAMPLE';
+const AWS_SECRET = 'wJalrXUtnFEMI/K7MDENG/' + 'bPxRfiCYEXAMPLEKEY';
+const GITHUB_TOKEN = 'ghp_' + 'a1B2c3D4e5F6g7H8i9J0k1L2m3N4o5P6q7R8';
+
+describe('Secret scanning', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-secrets-'));
+        Config.getInstance().addAllowedPaths([root]);
+    });
+
+    afterAll(async () => {
+        Config.getInstance().setSecretScanMode('warn');
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should detect common credential formats', () => {
+        const content = [
+            `const id = "${AWS_KEY_ID}";`,
+            `aws_secret_access_key = ${AWS_SECRET}`,
+            '-----BEGIN OPENSSH ' + 'PRIVATE KEY-----',
+            `token: ${GITHUB_TOKEN}`,
+            'const apiKey = "Zx9Qw2Lm8Rt5Vb3Nk7Hy";',
+        ].join('\n');
+        const findings = scanForSecrets(content);
+        expect(findings.map(f => f.rule)).toEqual(['aws-access-key-id', 'aws-secret-access-key', 'private-key', 'github-token', 'generic-secret']);
+        expect(findings[0]).toMatchObject({ line: 1, column: 13 });
+        expect(findings[0]?.match).not.toContain(AWS_KEY_ID);
+    });
+
+    it('should ignore low-entropy placeholders and allowlisted lines', () => {
+        expect(scanForSecrets('password = "changeme-changeme"')).toHaveLength(0);
+        expect(scanForSecrets(`const id = "${AWS_KEY_ID}"; // pragma: allowlist secret`)).toHaveLength(0);
+        expect(shannonEntropy('aaaa')).toBe(0);
+    });
+
+    it('should warn by default and block when configured', async () => {
+        const target = join(root, 'creds.ts');
+        const created: any = await editor.run({ action: 'create', file_path: target, content: `export const id = "${AWS_KEY_ID}";\n` });
+        expect(created.success).toBe(true);
+        expect(created.warnings[0]).toContain('AWS access key ID');
+
+        Config.getInstance().setSecretScanMode('block');
+        const edited: any = await editor.run({
+            action: 'edit',
+            file_path: target,
+            edits: [{ oldText: `export const id = "${AWS_KEY_ID}";`, newText: `export const token = "${GITHUB_TOKEN}";` }],
+        });
+        expect(edited.success).toBe(false);
+        expect(await fs.readFile(target, 'utf-8')).toContain(AWS_KEY_ID);
+    });
+
+    it('should not let a project loosen the scan', async () => {
+        const project = join(root, 'loose');
+        await fs.mkdir(project);
+        await fs.writeFile(join(project, '.code-feedback.yaml'), 'secretScan: off\n');
+        Config.getInstance().setSecretScanMode('block');
+        const created: any = await editor.run({ action: 'create', file_path: join(project, 'creds.ts'), content: `export const token = "${GITHUB_TOKEN}";\n` });
+        expect(created.success).toBe(false);
+        expect(strictestSecretScan('warn', 'block')).toBe('block');
+        expect(strictestSecretScan('block', 'off')).toBe('block');
+        expect(mergeConfigs({ secretScan: 'block' }, { secretScan: 'off' }).secretScan).toBe('block');
+    });
+
+    it('should persist clean edits', async () => {
+        const target = join(root, 'clean.ts');
+        await fs.writeFile(target, 'export const a = 1;\n');
+        const edited: any = await editor.run({ action: 'edit', file_path: target, edits: [{ oldText: 'export const a = 1;', newText: 'export const a = 2;' }] });
+        expect(edited.success).toBe(true);
+        expect(await fs.readFile(target, 'utf-8')).toBe('export const a = 2;\n');
+    });
+});
@@ -0,0 +1,106 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { createServer, type Server } from 'http';
+import type { AddressInfo } from 'net';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { SemanticIndex, chunkSource, createEmbeddingProvider, embeddingOptionsFromEnv } from '../src/semantic/index.js';
+import { semanticSearchTool } from '../src/tools/semantic.js';
+
+// Bag of words hashed into 32 dimensions: texts sharing words end up close
+function embedWords(text: string): number[] {
+    const vector = new Array(32).fill(0);
+    for (const word of text.toLowerCase().match(/[a-z]+/g) ?? []) {
+        let hash = 0;
+        for (const ch of word) hash = (hash * 31 + ch.charCodeAt(0)) % 32;
+        vector[hash] += 1;
+    }
+    return vector;
+}
+
+describe('Semantic search', () => {
+    let dir: string;
+    let server: Server;
+    let url: string;
+    const requests: string[][] = [];
+
+    beforeAll(async () => {
+        dir = await fs.mkdtemp(join(tmpdir(), 'cf-semantic-test-'));
+        await fs.mkdir(join(dir, 'src'));
+        await fs.writeFile(join(dir, 'src', 'retry.ts'), 'export async function retry(fn: () => Promise<void>) {\n    // backoff delay doubles after each failed attempt\n}\n');
+        await fs.writeFile(join(dir, 'src', 'session.ts'), 'export function expireSession(session: Session) {\n    session.expired = true;\n}\n');
+        server = createServer(async (req, res) => {
+            const chunks: Buffer[] = [];
+            for await (const chunk of req) chunks.push(chunk);
+            const body = JSON.parse(Buffer.concat(chunks).toString());
+            requests.push(body.input);
+            // Out of order on purpose: entries are matched by index
+            const data = body.input.map((text: string, index: number) => ({ index, embedding: embedWords(text) })).reverse();
+            res.writeHead(200, { 'content-type': 'application/json' }).end(JSON.stringify({ data }));
+        });
+        await new Promise<void>(resolve => server.listen(0, '127.0.0.1', resolve));
+        url = `http://127.0.0.1:${(server.address() as AddressInfo).port}/v1`;
+    });
+
+    afterAll(async () => {
+        server.close();
+        await fs.rm(dir, { recursive: true, force: true });
+    });
+
+    it('should chunk sources into overlapping windows that end at blank lines', () => {
+        const source = Array.from({ length: 130 }, (_, i) => (i % 20 === 19 ? '' : `line ${i + 1}`)).join('\n');
+        const chunks = chunkSource(source);
+        expect(chunks.map(c => [c.startLine, c.endLine])).toEqual([[1, 60], [51, 100], [91, 130]]);
+        expect(chunkSource('\n\n')).toEqual([]);
+    });
+
+    it('should read the provider from the environment', () => {
+        expect(embeddingOptionsFromEnv({})).toEqual({});
+        expect(embeddingOptionsFromEnv({ OPENAI_API_KEY: 'sk-test' })).toEqual({ url: 'https://api.openai.com/v1', apiKey: 'sk-test' });
+        expect(embeddingOptionsFromEnv({ MCP_EMBEDDINGS_URL: 'http://localhost:11434/v1/', MCP_EMBEDDINGS_MODEL: 'nomic-embed-text', MCP_EMBEDDINGS_BATCH: '8' }))
+            .toEqual({ url: 'http://localhost:11434/v1', model: 'nomic-embed-text', batchSize: 8 });
+        expect(createEmbeddingProvider({})).toBeNull();
+    });
+
+    it('should index incrementally and rank chunks by meaning', async () => {
+        const provider = createEmbeddingProvider({ url, batchSize: 1 })!;
+        const index = new SemanticIndex(dir, provider, join(dir, '.index'));
+        const first = await index.refresh();
+        expect(first).toMatchObject({ files: 2, chunks: 2, reindexedFiles: 2, embeddedChunks: 2, removedFiles: 0 });
+        expect(requests.length).toBe(2);
+        expect(requests[0]![0]).toMatch(/^src\/retry\.ts\n/);
+
+        const matches = await index.search('retry with backoff after a failed attempt', { limit: 1 });
+        expect(matches[0]).toMatchObject({ file: 'src/retry.ts', startLine: 1, endLine: 3 });
+        expect(matches[0]!.snippet).toContain('backoff delay');
+
+        // A new index on the same directory picks up the saved vectors
+        const reopened = new SemanticIndex(dir, provider, join(dir, '.index'));
+        expect(await reopened.refresh()).toMatchObject({ reindexedFiles: 0, embeddedChunks: 0 });
+        await fs.writeFile(join(dir, 'src', 'session.ts'), 'export function expireSession() {}\n');
+        await fs.rm(join(dir, 'src', 'retry.ts'));
+        expect(await reopened.refresh()).toMatchObject({ files: 1, reindexedFiles: 1, removedFiles: 1 });
+    });
+
+    it('should embed with a local command', async () => {
+        const script = 'let s="";process.stdin.on("data",d=>s+=d).on("end",()=>console.log(JSON.stringify(JSON.parse(s).map(t=>[t.length,1]))))';
+        const provider = createEmbeddingProvider({ command: `"${process.execPath}" -e '${script}'` })!;
+        expect(await provider.embed(['ab', 'abcd'])).toEqual([[2, 1], [4, 1]]);
+        const failing = createEmbeddingProvider({ command: 'echo nope' })!;
+        await expect(failing.embed(['a'])).rejects.toThrow('Embedding command did not print JSON');
+    });
+
+    it('should explain how to configure a provider', async () => {
+        const saved = { ...process.env };
+        for (const key of ['MCP_EMBEDDINGS_URL', 'MCP_EMBEDDINGS_API_KEY', 'MCP_EMBEDDINGS_COMMAND', 'OPENAI_API_KEY']) delete process.env[key];
+        try {
+            Config.getInstance().addAllowedPaths([dir]);
+            const result = await semanticSearchTool.run({ path: dir, query: 'sessions' });
+            expect(result.success).toBe(false);
+            expect(result.errors[0]).toContain('Semantic search is not configured');
+        } finally {
+            process.env = saved;
+        }
+    });
+});
@@ -0,0 +1,118 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { captureBeforeChange, snapshotStore } from '../src/snapshots/index.js';
+import { editor } from '../src/tools/editor.js';
+import { filesystem } from '../src/tools/filesystem.js';
+import { applyChangesTool } from '../src/tools/patch.js';
+import { listSnapshotsTool, revertToSnapshotTool } from '../src/tools/snapshots.js';
+
+describe('Snapshots', () => {
+    let root: string;
+    let project: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-snapshots-'));
+        process.env.MCP_SNAPSHOTS_DIR = join(root, 'state');
+        project = join(root, 'project');
+        await fs.mkdir(join(project, 'lib'), { recursive: true });
+        await fs.writeFile(join(project, 'main.go'), 'package main\n');
+        await fs.writeFile(join(project, 'lib', 'util.go'), 'package lib\n');
+        Config.getInstance().addAllowedPaths([project]);
+    });
+
+    afterAll(async () => {
+        delete process.env.MCP_SNAPSHOTS_DIR;
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    const call = (tool: { name: string; run(args: any): Promise<any> }, args: any) =>
+        snapshotStore.capture({ tool: tool.name }, () => tool.run(args));
+
+    it('should snapshot the files a call changes and revert a chain of calls', async () => {
+        const main = join(project, 'main.go');
+        await call(editor, { action: 'edit', file_path: main, edits: [{ oldText: 'package main', newText: 'package main\n\nfunc main() {}' }] });
+        await call(applyChangesTool, { rootPath: project, changes: [{ path: 'new.go', content: 'package main\n' }, { path: 'main.go', content: 'broken' }] });
+        await call(filesystem, { ops: [{ type: 'delete', path: join(project, 'lib') }] });
+        await editor.run({ action: 'read', file_path: main });
+
+        const listed: any = await listSnapshotsTool.run({ path: project });
+        expect(listed.snapshots.map((s: any) => s.tool)).toEqual(['filesystem', 'apply_changes', 'editor']);
+        const first = listed.snapshots[2];
+        expect(first.files.map((f: any) => [f.path, f.bytes])).toEqual([[main, 13]]);
+
+        const preview: any = await revertToSnapshotTool.run({ id: first.id, dryRun: true });
+        expect(preview.success).toBe(true);
+        expect(preview.snapshots).toHaveLength(3);
+        expect(await fs.readFile(main, 'utf-8')).toBe('broken');
+
+        const reverted: any = await call(revertToSnapshotTool, { id: first.id });
+        expect(reverted.success).toBe(true);
+        expect(await fs.readFile(main, 'utf-8')).toBe('package main\n');
+        expect(await fs.readFile(join(project, 'lib', 'util.go'), 'utf-8')).toBe('package lib\n');
+        await expect(fs.access(join(project, 'new.go'))).rejects.toThrow();
+
+        // The revert is a snapshot of its own
+        const latest = (await snapshotStore.list())[0]!;
+        expect(latest.tool).toBe('revert_to_snapshot');
+        await call(revertToSnapshotTool, { id: latest.id });
+        expect(await fs.readFile(main, 'utf-8')).toBe('broken');
+    });
+
+    it('should refuse to overwrite changes made outside tool calls', async () => {
+        const file = join(project, 'conflict.txt');
+        await call(editor, { action: 'create', file_path: file, content: 'v1' });
+        const [snapshot] = await snapshotStore.list();
+        await fs.writeFile(file, 'edited by hand');
+
+        const refused: any = await revertToSnapshotTool.run({ id: snapshot!.id });
+        expect(refused.success).toBe(false);
+        expect(refused.conflicts).toEqual([file]);
+        expect(await fs.readFile(file, 'utf-8')).toBe('edited by hand');
+
+        expect((await revertToSnapshotTool.run({ id: snapshot!.id, force: true })).success).toBe(true);
+        await expect(fs.access(file)).rejects.toThrow();
+        expect((await revertToSnapshotTool.run({ id: 'missing' })).errors[0]).toContain('Unknown snapshot');
+    });
+
+    it('should revert only the workspace the snapshot belongs to', async () => {
+        const mine = join(project, 'mine.txt');
+        const theirs = join(project, 'theirs.txt');
+        const write = (workspace: string, file: string, content: string) =>
+            snapshotStore.capture({ tool: editor.name, workspace }, () => editor.run({ action: 'create', file_path: file, content }));
+        await write('mine', mine, 'mine');
+        const [snapshot] = await snapshotStore.list();
+        await write('theirs', theirs, 'theirs');
+
+        const plan = await snapshotStore.planRevert(snapshot!.id);
+        expect(plan.snapshots).toEqual([snapshot!.id]);
+        expect([...plan.files.keys()]).toEqual([mine]);
+    });
+
+    it('should keep the blobs of a capture still running when pruning', async () => {
+        process.env.MCP_SNAPSHOT_LIMIT = '1';
+        try {
+            const slow = join(project, 'slow.txt');
+            await fs.writeFile(slow, 'before the slow call');
+            let finish!: () => void;
+            const running = snapshotStore.capture({ tool: 'slow' }, async () => {
+                await captureBeforeChange(slow);
+                await new Promise<void>(done => { finish = done; });
+                await fs.writeFile(slow, 'after');
+            });
+            await new Promise(done => setTimeout(done, 20));
+            // Saves and prunes while the slow call's blob is referenced by no saved snapshot
+            await call(editor, { action: 'create', file_path: join(project, 'quick.txt'), content: 'quick' });
+            finish();
+            await running;
+
+            const [latest] = await snapshotStore.list();
+            expect(latest!.tool).toBe('slow');
+            expect((await snapshotStore.planRevert(latest!.id)).files.get(slow)?.toString()).toBe('before the slow call');
+        } finally {
+            delete process.env.MCP_SNAPSHOT_LIMIT;
+        }
+    });
+});
@@ -0,0 +1,102 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import type { Diagnostic } from '../src/diagnostics/index.js';
+import { applySuppressions, findSuppressed, parseSuppression } from '../src/diagnostics/suppressions.js';
+import { runPipelineTool } from '../src/tools/pipeline.js';
+
+const GO = `package main
+
+func main() {
+	f.Close() //nolint:errcheck // read-only file
+	g.Close() //nolint
+	//nolint:gosec
+	exec.Command(name)
+	h.Close() //nolint:unused // wrong linter
+}
+`;
+
+const finding = (file: string, line: number, rule: string, source = 'golangci-lint'): Diagnostic =>
+    ({ file, line, column: 1, severity: 'error', message: `${rule} finding`, rule, source });
+
+describe('Inline suppressions', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-suppress-'));
+        process.env.MCP_RESULTS_STORE = 'off';
+        Config.getInstance().addAllowedPaths([root]);
+    });
+
+    afterAll(async () => {
+        delete process.env.MCP_RESULTS_STORE;
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should parse the supported suppression comments', () => {
+        expect(parseSuppression('x := f() //nolint:errcheck,gosec // legacy API')).toEqual({ kind: 'nolint', rules: ['errcheck', 'gosec'], reason: 'legacy API', scope: 'line' });
+        expect(parseSuppression('\t//nolint:all')).toEqual({ kind: 'nolint', rules: [], scope: 'next' });
+        expect(parseSuppression('import os  # noqa: F401, E501  # re-exported')).toEqual({ kind: 'noqa', rules: ['F401', 'E501'], reason: 're-exported', scope: 'line' });
+        expect(parseSuppression('x = 1  # noqa')).toEqual({ kind: 'noqa', rules: [], scope: 'line' });
+        expect(parseSuppression('  // eslint-disable-next-line no-console, no-alert -- debugging aid')).toEqual({ kind: 'eslint', rules: ['no-console', 'no-alert'], reason: 'debugging aid', scope: 'next' });
+        expect(parseSuppression('foo(); /* eslint-disable-line */')).toEqual({ kind: 'eslint', rules: [], scope: 'line' });
+        expect(parseSuppression('# code-feedback:ignore no-todo reason="tracked in PROJ-12"')).toEqual({ kind: 'code-feedback', rules: ['no-todo'], reason: 'tracked in PROJ-12', scope: 'next' });
+        expect(parseSuppression('run() // code-feedback:ignore')).toEqual({ kind: 'code-feedback', rules: [], scope: 'line' });
+        expect(parseSuppression('// nolintlint is a linter')).toBeNull();
+        expect(parseSuppression('plain code')).toBeNull();
+    });
+
+    it('should suppress findings covered by a comment and flag those without a reason', async () => {
+        const file = join(root, 'main.go');
+        await fs.writeFile(file, GO);
+        const diagnostics = [finding(file, 4, 'errcheck'), finding(file, 5, 'errcheck'), finding(file, 7, 'gosec'), finding(file, 8, 'errcheck'), finding(file, 3, 'unused')];
+        const { kept, suppressed, report } = await findSuppressed(diagnostics, { root });
+        expect(suppressed.map(d => d.line)).toEqual([4, 5, 7]);
+        expect(kept.map(d => d.line)).toEqual([8, 3]);
+        expect(report.count).toBe(3);
+        expect(report.byKind).toEqual({ nolint: 3 });
+        expect(report.unjustified.map(s => `${s.line}:${s.comment}`)).toEqual(['5:g.Close() //nolint', '6://nolint:gosec']);
+
+        const strict = await findSuppressed(diagnostics, { root, requireReason: true });
+        expect(strict.suppressed.map(d => d.line)).toEqual([4]);
+        expect(strict.report.unjustified).toHaveLength(2);
+    });
+
+    it('should pass a failure explained only by suppressed errors and report the counts', async () => {
+        const file = join(root, 'main.go');
+        const outcome = { success: false, errors: ['golangci-lint found issues'], warnings: [], diagnostics: [finding(file, 4, 'errcheck'), finding(file, 5, 'errcheck')] };
+        const applied = await applySuppressions(outcome, { root });
+        expect(applied.success).toBe(true);
+        expect(applied.diagnostics).toEqual([]);
+        expect(applied.suppressions?.count).toBe(2);
+        expect(applied.warnings).toEqual(['2 finding(s) suppressed by inline comments', `1 suppression comment(s) without a reason: ${file}:5 (nolint)`]);
+
+        const untouched = { ...outcome, diagnostics: [finding(file, 8, 'errcheck')] };
+        expect(await applySuppressions(untouched, { root })).toBe(untouched);
+    });
+
+    it('should leave suppressed findings out of pipeline steps', async () => {
+        const repo = join(root, 'repo');
+        await fs.mkdir(repo);
+        await fs.writeFile(join(repo, '.code-feedback.yaml'), `rules:
+  - { id: no-todo, pattern: "TODO", files: ["**/*.ts"], message: Resolve the TODO, severity: error }
+`);
+        await fs.writeFile(join(repo, 'a.ts'), '// code-feedback:ignore no-todo reason="PROJ-1"\nconst a = 1; // TODO remove\nconst b = 2; // TODO rename // code-feedback:ignore\n');
+        const steps = [{ tool: 'check_rules', args: { path: '.' } }];
+
+        const result: any = await runPipelineTool.run({ path: repo, steps });
+        expect(result.success).toBe(true);
+        expect(result.diagnostics).toEqual([]);
+        expect(result.suppressions.count).toBe(2);
+        expect(result.suppressions.unjustified.map((s: any) => s.line)).toEqual([3]);
+        expect(result.output).toContain('2 finding(s) suppressed by inline comments');
+
+        await fs.appendFile(join(repo, '.code-feedback.yaml'), 'suppressions: { requireReason: true }\n');
+        const strict: any = await runPipelineTool.run({ path: repo, steps });
+        expect(strict.success).toBe(false);
+        expect(strict.diagnostics.map((d: Diagnostic) => d.line)).toEqual([3]);
+        expect(strict.warnings.some((w: string) => w.includes('so they suppress nothing'))).toBe(true);
+    });
+});
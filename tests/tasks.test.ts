@@ -0,0 +1,87 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { parseMakefileTargets, parseTaskfileTargets, taskRunnerTool } from '../src/tools/tasks.js';
+
+describe('Task runner', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-tasks-'));
+        Config.getInstance().addAllowedPaths([root]);
+        await fs.writeFile(join(root, 'Makefile'), [
+            'GO := go',
+            '.PHONY: build check',
+            '',
+            'build: ## Build the binary',
+            '\t@echo building $(MODE)',
+            '',
+            'check:',
+            '\t@echo "broken.c:2:5: error: expected ;" >&2; exit 3',
+        ].join('\n'));
+        await fs.writeFile(join(root, 'broken.c'), 'int main() {\n    return 0\n}\n');
+        await fs.writeFile(join(root, 'package.json'), JSON.stringify({ name: 'demo', scripts: { build: 'tsc', lint: 'eslint .' } }));
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should parse Makefile targets and their descriptions', () => {
+        const targets = parseMakefileTargets([
+            'BIN = out:x',
+            'FLAGS ::= -O2',
+            '# Run the tests',
+            'test unit: build',
+            '\tgo test ./...',
+            '%.o: %.c',
+            '$(BIN): main.go',
+            'lint: ## Lint everything',
+        ].join('\n'), '/p/Makefile');
+        expect(targets.map(t => [t.name, t.description])).toEqual([
+            ['test', 'Run the tests'],
+            ['unit', 'Run the tests'],
+            ['lint', 'Lint everything'],
+        ]);
+    });
+
+    it('should parse Taskfile tasks and skip internal ones', () => {
+        const targets = parseTaskfileTargets([
+            'version: "3"',
+            'tasks:',
+            '  build:',
+            '    desc: Build it',
+            '    cmds: [go build ./...]',
+            '  helper:',
+            '    internal: true',
+            '  fmt: gofmt -w .',
+        ].join('\n'), '/p/Taskfile.yml');
+        expect(targets.map(t => [t.name, t.description])).toEqual([['build', 'Build it'], ['fmt', undefined]]);
+    });
+
+    it('should list targets from every task file', async () => {
+        const result: any = await taskRunnerTool.run({ projectPath: root });
+        expect(result.success).toBe(true);
+        expect(result.targets.map((t: any) => `${t.runner} ${t.name}`)).toEqual(['make build', 'make check', 'npm build', 'npm lint']);
+        expect(result.output).toContain('make build - Build the binary');
+    });
+
+    it('should run a target, preferring the Makefile, and pass variables', async () => {
+        const result: any = await taskRunnerTool.run({ projectPath: root, action: 'run', target: 'build', args: ['MODE=release'] });
+        expect(result.success).toBe(true);
+        expect(result.runner).toBe('make');
+        expect(result.output.trim()).toBe('building release');
+        expect(result.warnings[0]).toContain('also defined for npm');
+    });
+
+    it('should report failures with the diagnostics in the output', async () => {
+        const result: any = await taskRunnerTool.run({ projectPath: root, action: 'run', target: 'check' });
+        expect(result.success).toBe(false);
+        expect(result.exitCode).not.toBe(0);
+        expect(result.diagnostics).toEqual([{ file: join(root, 'broken.c'), line: 2, column: 5, severity: 'error', message: 'error: expected ;', source: 'make' }]);
+        const missing: any = await taskRunnerTool.run({ projectPath: root, action: 'run', target: 'deploy' });
+        expect(missing.errors[0]).toContain('not found');
+    });
+});
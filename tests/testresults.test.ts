@@ -0,0 +1,153 @@
+import { describe, it, expect } from 'vitest';
+import {
+    parseGoTestJson,
+    goTestJsonText,
+    parsePytestJson,
+    parseJUnitXml,
+    parseJestJson,
+    toTestReport,
+    describeTestFailure,
+} from '../src/diagnostics/index.js';
+
+const event = (fields: Record<string, unknown>) => JSON.stringify({ Package: 'example.com/calc', ...fields });
+
+const GO_TEST_JSON = [
+    event({ Action: 'start' }),
+    event({ Action: 'run', Test: 'TestAdd' }),
+    event({ Action: 'output', Test: 'TestAdd', Output: '=== RUN   TestAdd\n' }),
+    event({ Action: 'output', Test: 'TestAdd', Output: '    calc_test.go:5: adding\n' }),
+    event({ Action: 'output', Test: 'TestAdd', Output: '--- PASS: TestAdd (0.00s)\n' }),
+    event({ Action: 'pass', Test: 'TestAdd', Elapsed: 0.012 }),
+    event({ Action: 'run', Test: 'TestDiv' }),
+    event({ Action: 'output', Test: 'TestDiv', Output: '=== RUN   TestDiv\n' }),
+    event({ Action: 'run', Test: 'TestDiv/by_zero' }),
+    event({ Action: 'output', Test: 'TestDiv/by_zero', Output: '=== RUN   TestDiv/by_zero\n' }),
+    event({ Action: 'output', Test: 'TestDiv/by_zero', Output: '    calc_test.go:7: expected error, got nil\n' }),
+    event({ Action: 'output', Test: 'TestDiv/by_zero', Output: '--- FAIL: TestDiv/by_zero (0.00s)\n' }),
+    event({ Action: 'fail', Test: 'TestDiv/by_zero', Elapsed: 0 }),
+    event({ Action: 'output', Test: 'TestDiv', Output: '--- FAIL: TestDiv (0.00s)\n' }),
+    event({ Action: 'fail', Test: 'TestDiv', Elapsed: 0 }),
+    event({ Action: 'output', Test: 'TestLater', Output: '    calc_test.go:9: not ready\n' }),
+    event({ Action: 'skip', Test: 'TestLater', Elapsed: 0 }),
+    event({ Action: 'output', Output: 'FAIL\n' }),
+    event({ Action: 'output', Output: 'FAIL\texample.com/calc\t0.002s\n' }),
+    event({ Action: 'fail', Elapsed: 0.003 }),
+].join('\n');
+
+describe('go test -json', () => {
+    it('should report every test and subtest with its log location', () => {
+        const tests = parseGoTestJson(GO_TEST_JSON, '/work/calc');
+        expect(tests.map(t => `${t.name}:${t.status}`)).toEqual(['TestAdd:passed', 'TestDiv:failed', 'TestDiv/by_zero:failed', 'TestLater:skipped']);
+        expect(tests[0]).toMatchObject({ suite: 'example.com/calc', durationMs: 12, output: '    calc_test.go:5: adding' });
+        expect(tests[2]).toMatchObject({ message: 'expected error, got nil', file: '/work/calc/calc_test.go', line: 7 });
+        expect(tests[1]?.message).toBe('subtest TestDiv/by_zero failed');
+        expect(tests[3]?.message).toBe('not ready');
+    });
+
+    it('should not point failures at the testing framework', () => {
+        const output = [
+            event({ Action: 'output', Test: 'TestRace', Output: '    testing.go:1865: race detected during execution of test\n' }),
+            event({ Action: 'fail', Test: 'TestRace', Elapsed: 0 }),
+        ].join('\n');
+        const [test] = parseGoTestJson(output, '/work');
+        expect(test?.message).toBe('race detected during execution of test');
+        expect(test?.file).toBeUndefined();
+    });
+
+    it('should turn a package build failure into an error case', () => {
+        const output = [
+            JSON.stringify({ ImportPath: 'example.com/calc [example.com/calc.test]', Action: 'build-output', Output: '# example.com/calc [example.com/calc.test]\n' }),
+            JSON.stringify({ ImportPath: 'example.com/calc [example.com/calc.test]', Action: 'build-output', Output: './calc.go:2:28: cannot use "x" as int value\n' }),
+            JSON.stringify({ ImportPath: 'example.com/calc [example.com/calc.test]', Action: 'build-fail' }),
+            event({ Action: 'output', Output: 'FAIL\texample.com/calc [build failed]\n' }),
+            event({ Action: 'fail', Elapsed: 0, FailedBuild: 'example.com/calc [example.com/calc.test]' }),
+        ].join('\n');
+        const tests = parseGoTestJson(output);
+        expect(tests).toHaveLength(1);
+        expect(tests[0]).toMatchObject({ name: '(package)', status: 'error', message: './calc.go:2:28: cannot use "x" as int value' });
+        expect(goTestJsonText(output)).toContain('[build failed]');
+    });
+
+    it('should rebuild the plain log from output events', () => {
+        const text = goTestJsonText(`# some/pkg\n${GO_TEST_JSON}`);
+        expect(text.startsWith('# some/pkg\n=== RUN   TestAdd\n')).toBe(true);
+        expect(text).toContain('--- FAIL: TestDiv/by_zero (0.00s)');
+    });
+});
+
+describe('pytest reports', () => {
+    it('should parse pytest-json-report output', () => {
+        const tests = parsePytestJson({
+            tests: [
+                { nodeid: 'tests/test_calc.py::test_add', lineno: 3, outcome: 'passed', setup: { duration: 0.001, outcome: 'passed' }, call: { duration: 0.002, outcome: 'passed', stdout: 'hello\n' }, teardown: { duration: 0, outcome: 'passed' } },
+                {
+                    nodeid: 'tests/test_calc.py::TestDiv::test_zero',
+                    lineno: 9,
+                    outcome: 'failed',
+                    setup: { duration: 0, outcome: 'passed' },
+                    call: { duration: 0.01, outcome: 'failed', crash: { path: '/work/tests/test_calc.py', lineno: 12, message: 'ZeroDivisionError: division by zero' }, longrepr: 'def test_zero():\n>       1 / 0\nE       ZeroDivisionError: division by zero' },
+                },
+                { nodeid: 'tests/test_calc.py::test_later', lineno: 14, outcome: 'skipped', setup: { duration: 0, outcome: 'skipped', longrepr: "('/work/tests/test_calc.py', 15, 'Skipped: not ready')" } },
+            ],
+        });
+        expect(tests.map(t => t.status)).toEqual(['passed', 'failed', 'skipped']);
+        expect(tests[0]).toMatchObject({ suite: 'tests/test_calc.py', name: 'test_add', durationMs: 3, output: 'hello', file: 'tests/test_calc.py', line: 4 });
+        expect(tests[1]).toMatchObject({ suite: 'tests/test_calc.py::TestDiv', name: 'test_zero', message: 'ZeroDivisionError: division by zero', file: '/work/tests/test_calc.py', line: 12 });
+        expect(tests[2]?.message).toBe('not ready');
+    });
+
+    it('should locate failures and captured output in pytest junit XML', () => {
+        const xml = `<testsuites><testsuite name="pytest" tests="1">
+<testcase classname="tests.test_calc" name="test_div" time="0.004">
+<failure message="assert 2 == 3">def test_div():
+&gt;       assert 2 == 3
+E       assert 2 == 3
+
+tests/test_calc.py:8: AssertionError</failure>
+<system-out>dividing</system-out>
+</testcase></testsuite></testsuites>`;
+        const [test] = parseJUnitXml(xml);
+        expect(test).toMatchObject({ suite: 'tests.test_calc', status: 'failed', message: 'assert 2 == 3', file: 'tests/test_calc.py', line: 8, output: 'dividing' });
+    });
+});
+
+describe('jest --json', () => {
+    const report = {
+        testResults: [
+            {
+                name: '/work/src/sum.test.js',
+                status: 'failed',
+                assertionResults: [
+                    { ancestorTitles: ['sum'], title: 'adds', status: 'passed', duration: 3, failureMessages: [] },
+                    {
+                        ancestorTitles: ['sum', 'edge cases'],
+                        title: 'handles NaN',
+                        status: 'failed',
+                        duration: 5,
+                        failureMessages: ['\u001b[2mexpect(\u001b[22mreceived).toBe(expected)\n\nExpected: 0\nReceived: NaN\n    at Object.<anonymous> (/work/src/sum.test.js:14:21)'],
+                    },
+                    { ancestorTitles: [], title: 'todo', status: 'todo', duration: null, failureMessages: [] },
+                ],
+            },
+            { name: '/work/src/broken.test.js', status: 'failed', message: "Cannot find module './missing' from 'src/broken.test.js'", assertionResults: [] },
+        ],
+    };
+
+    it('should map assertions and unloadable test files', () => {
+        const tests = parseJestJson(report, '/work');
+        expect(tests.map(t => `${t.suite}|${t.name}|${t.status}`)).toEqual([
+            'src/sum.test.js|sum > adds|passed',
+            'src/sum.test.js|sum > edge cases > handles NaN|failed',
+            'src/sum.test.js|todo|skipped',
+            'src/broken.test.js|(test file)|error',
+        ]);
+        expect(tests[1]).toMatchObject({ message: 'expect(received).toBe(expected)', file: '/work/src/sum.test.js', line: 14 });
+        expect(tests[3]?.message).toContain("Cannot find module './missing'");
+    });
+
+    it('should summarize failures for the errors list', () => {
+        const tests = toTestReport(parseJestJson(report, '/work'));
+        expect(tests.summary).toMatchObject({ total: 4, passed: 1, failed: 1, errored: 1, skipped: 1 });
+        expect(describeTestFailure(tests.failures[0]!)).toBe('src/sum.test.js sum > edge cases > handles NaN failed: expect(received).toBe(expected) (/work/src/sum.test.js:14)');
+    });
+});
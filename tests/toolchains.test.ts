@@ -0,0 +1,67 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { delimiter, join } from 'path';
+import { tmpdir } from 'os';
+import { compareVersions, findToolchainPins, matchesPin, selectToolchains } from '../src/toolchains/index.js';
+
+describe('Toolchain selection', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-toolchains-'));
+    });
+
+    afterAll(async () => {
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should compare and match versions', () => {
+        expect(compareVersions('1.22.3', '1.22')).toBeGreaterThan(0);
+        expect(compareVersions('1.9', '1.21')).toBeLessThan(0);
+        expect(matchesPin('20.11.1', '20')).toBe(true);
+        expect(matchesPin('3.12.1', '3.11')).toBe(false);
+    });
+
+    it('should read pins from project files, with .tool-versions as the fallback', async () => {
+        const project = join(root, 'pins');
+        await fs.mkdir(join(project, 'sub'), { recursive: true });
+        await fs.writeFile(join(project, 'go.mod'), 'module example.com/x\n\ngo 1.22\n\ntoolchain go1.22.3\n');
+        await fs.writeFile(join(project, '.nvmrc'), 'v20.11.1\n');
+        await fs.writeFile(join(project, '.tool-versions'), 'nodejs 18.0.0\npython 3.12.1\n');
+        const { pins } = await findToolchainPins(join(project, 'sub'));
+        expect(pins.map(p => [p.language, p.version, p.minimum])).toEqual([
+            ['go', '1.22.3', undefined],
+            ['node', '20.11.1', undefined],
+            ['python', '3.12.1', undefined],
+        ]);
+    });
+
+    it('should put an installed nvm version on PATH', async () => {
+        const project = join(root, 'node');
+        const nvmDir = join(root, 'nvm');
+        const bin = join(nvmDir, 'versions', 'node', 'v19.9.0', 'bin');
+        await fs.mkdir(project, { recursive: true });
+        await fs.mkdir(bin, { recursive: true });
+        await fs.mkdir(join(nvmDir, 'versions', 'node', 'v19.1.0'), { recursive: true });
+        await fs.writeFile(join(project, '.nvmrc'), '19\n');
+        const result = await selectToolchains(project, 'auto', { NVM_DIR: nvmDir });
+        expect(result.selections[0]).toMatchObject({ language: 'node', version: '19', selected: '19.9.0', via: 'nvm' });
+        expect(result.env.PATH?.split(delimiter)[0]).toBe(bin);
+    });
+
+    it('should accept a newer go for a go directive and warn about unmet pins', async () => {
+        const project = join(root, 'go');
+        await fs.mkdir(project, { recursive: true });
+        await fs.writeFile(join(project, 'go.mod'), 'module example.com/y\n\ngo 1.16\n');
+        const satisfied = await selectToolchains(project, 'auto');
+        expect(satisfied.selections[0]).toMatchObject({ language: 'go', version: '1.16', minimum: true, via: 'path' });
+        expect(satisfied.env).toEqual({});
+
+        await fs.writeFile(join(project, 'go.mod'), 'module example.com/y\n\ngo 1.21\n\ntoolchain go1.21.99\n');
+        const unmet = await selectToolchains(project, 'auto');
+        expect(unmet.selections[0]?.selected).toBeUndefined();
+        expect(unmet.warnings[0]).toContain('is not downloaded');
+        expect(unmet.env.GOTOOLCHAIN).toBeUndefined();
+        expect((await selectToolchains(project, 'off')).selections).toEqual([]);
+    });
+});
@@ -0,0 +1,108 @@
+import { describe, it, expect, afterEach } from 'vitest';
+import { createServer, type IncomingMessage } from 'http';
+import type { AddressInfo } from 'net';
+import { tmpdir } from 'os';
+import { Tracer, tracer, formatTraceparent, parseTraceparent, toOtlpJson, tracingOptionsFromEnv, type FinishedSpan } from '../src/tracing/index.js';
+import { runCommand } from '../src/utils/command.js';
+import { runPipeline } from '../src/tools/pipeline.js';
+
+describe('Tracing', () => {
+    afterEach(() => {
+        tracer.configure({});
+    });
+
+    it('should parse and format W3C traceparent values', () => {
+        const context = parseTraceparent('00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01');
+        expect(context).toEqual({ traceId: '4bf92f3577b34da6a3ce929d0e0e4736', spanId: '00f067aa0ba902b7' });
+        expect(formatTraceparent(context!)).toBe('00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01');
+        expect(parseTraceparent('00-00000000000000000000000000000000-00f067aa0ba902b7-01')).toBeNull();
+        expect(parseTraceparent('garbage')).toBeNull();
+        expect(parseTraceparent(undefined)).toBeNull();
+    });
+
+    it('should read the standard OpenTelemetry environment variables', () => {
+        expect(tracingOptionsFromEnv({ OTEL_EXPORTER_OTLP_ENDPOINT: 'http://collector:4318/', OTEL_EXPORTER_OTLP_HEADERS: 'api-key=abc,x-team=dev' })).toEqual({
+            endpoint: 'http://collector:4318/v1/traces',
+            headers: { 'api-key': 'abc', 'x-team': 'dev' },
+            serviceName: 'code-feedback-mcp',
+        });
+        expect(tracingOptionsFromEnv({ OTEL_EXPORTER_OTLP_TRACES_ENDPOINT: 'http://x/traces', OTEL_SERVICE_NAME: 'ci' }).endpoint).toBe('http://x/traces');
+        expect(tracingOptionsFromEnv({ OTEL_EXPORTER_OTLP_ENDPOINT: 'http://x', OTEL_SDK_DISABLED: 'true' })).toEqual({});
+    });
+
+    it('should nest spans through async calls and subprocesses', async () => {
+        const exported: FinishedSpan[] = [];
+        tracer.configure({ export: async spans => { exported.push(...spans); } });
+        const parent = parseTraceparent('00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01');
+        const output = await tracer.withSpan('tool go', { kind: 'server', parent }, async () => {
+            const result = await runCommand('echo "$TRACEPARENT"; exit 3', { cwd: tmpdir() });
+            return result.stdout.trim();
+        });
+        await tracer.flush();
+
+        const [exec, tool] = exported;
+        expect(tool).toMatchObject({ name: 'tool go', kind: 'server', traceId: parent!.traceId, parentSpanId: parent!.spanId });
+        expect(exec).toMatchObject({ name: 'exec echo', kind: 'client', traceId: parent!.traceId, parentSpanId: tool!.spanId });
+        expect(exec!.attributes['process.exit_code']).toBe(3);
+        expect(exec!.status.code).toBe('error');
+        // The subprocess sees its own span as the parent
+        expect(output).toBe(formatTraceparent(exec!));
+    });
+
+    it('should mark spans of throwing functions as errors', async () => {
+        const exported: FinishedSpan[] = [];
+        tracer.configure({ export: async spans => { exported.push(...spans); } });
+        await expect(tracer.withSpan('boom', {}, async () => { throw new Error('broken'); })).rejects.toThrow('broken');
+        await tracer.flush();
+        expect(exported[0]!.status).toEqual({ code: 'error', message: 'broken' });
+    });
+
+    it('should trace each pipeline step', async () => {
+        const exported: FinishedSpan[] = [];
+        tracer.configure({ export: async spans => { exported.push(...spans); } });
+        await runPipeline([{ name: 'ok', command: 'true' }, { name: 'fails', command: 'false' }], tmpdir(), [], () => true);
+        await tracer.flush();
+        const steps = exported.filter(s => s.name.startsWith('pipeline step'));
+        expect(steps.map(s => [s.name, s.attributes['pipeline.step.status']])).toEqual([['pipeline step ok', 'passed'], ['pipeline step fails', 'failed']]);
+        const execs = exported.filter(s => s.name.startsWith('exec'));
+        expect(execs.map(s => s.parentSpanId)).toEqual(steps.map(s => s.spanId));
+    });
+
+    it('should export OTLP JSON to a collector', async () => {
+        const bodies: any[] = [];
+        let headers: IncomingMessage['headers'] = {};
+        const collector = createServer(async (req, res) => {
+            headers = req.headers;
+            const chunks: Buffer[] = [];
+            for await (const chunk of req) chunks.push(chunk);
+            bodies.push(JSON.parse(Buffer.concat(chunks).toString()));
+            res.writeHead(200).end('{}');
+        });
+        await new Promise<void>(resolve => collector.listen(0, '127.0.0.1', resolve));
+        try {
+            const { port } = collector.address() as AddressInfo;
+            const exporting = new Tracer({ endpoint: `http://127.0.0.1:${port}/v1/traces`, headers: { 'api-key': 'abc' }, serviceName: 'test', serviceVersion: '1.2.3' });
+            await exporting.withSpan('tool lint', { attributes: { 'mcp.tool.name': 'lint', ratio: 0.5, ok: true } }, async () => undefined);
+            await exporting.flush();
+
+            expect(headers['api-key']).toBe('abc');
+            const resourceSpans = bodies[0].resourceSpans[0];
+            expect(resourceSpans.resource.attributes).toContainEqual({ key: 'service.name', value: { stringValue: 'test' } });
+            const span = resourceSpans.scopeSpans[0].spans[0];
+            expect(span.name).toBe('tool lint');
+            expect(span.traceId).toMatch(/^[0-9a-f]{32}$/);
+            expect(span.attributes).toContainEqual({ key: 'ratio', value: { doubleValue: 0.5 } });
+            expect(span.attributes).toContainEqual({ key: 'ok', value: { boolValue: true } });
+            expect(BigInt(span.endTimeUnixNano) >= BigInt(span.startTimeUnixNano)).toBe(true);
+        } finally {
+            await new Promise(resolve => collector.close(resolve));
+        }
+    });
+
+    it('should encode integer attributes as strings', () => {
+        const body = toOtlpJson([{ traceId: 'a'.repeat(32), spanId: 'b'.repeat(16), name: 'x', kind: 'internal', startTimeUnixNano: 1n, endTimeUnixNano: 2n, attributes: { count: 3 }, status: { code: 'ok' } }], {});
+        const span = body.resourceSpans[0]!.scopeSpans[0]!.spans[0]!;
+        expect(span.attributes).toEqual([{ key: 'count', value: { intValue: '3' } }]);
+        expect(span.status).toEqual({ code: 1 });
+    });
+});
@@ -33,5 +33,14 @@ describe('TypeScript Tool', () => {
         }
     });
 
+    it('should register the tsc_check tool', () => {
+        const server = new DummyServer();
+        registerTools(server);
+        const tool = server.tools.find(t => t.name === 'tsc_check');
+        expect(tool).toBeDefined();
+        expect(tool.inputSchema.properties.projectPath).toBeDefined();
+        expect(tool.inputSchema.properties.tsConfigPath).toBeDefined();
+    });
+
     // More tests (integration, error cases) can be added here
 }); 
\ No newline at end of file
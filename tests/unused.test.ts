@@ -0,0 +1,51 @@
+import { describe, it, expect } from 'vitest';
+import { parseStaticcheckUnused, parseDeadcodeJson, parseVultureOutput } from '../src/tools/unused.js';
+
+describe('Unused code detection', () => {
+    it('should parse staticcheck U1000 findings', () => {
+        const output = [
+            JSON.stringify({ code: 'U1000', severity: 'error', location: { file: '/work/a.go', line: 7, column: 6 }, message: 'func helper is unused' }),
+            JSON.stringify({ code: 'U1000', severity: 'error', location: { file: '/work/a.go', line: 12, column: 2 }, message: 'field label is unused' }),
+            JSON.stringify({ code: 'U1000', severity: 'error', location: { file: '/work/a.go', line: 20, column: 17 }, message: 'func (*server).reset is unused' }),
+            JSON.stringify({ code: 'S1000', severity: 'error', location: { file: '/work/a.go', line: 30, column: 1 }, message: 'should use a simple channel send' }),
+        ].join('\n');
+        const { unused, errors } = parseStaticcheckUnused(output, '/work');
+        expect(errors).toEqual([]);
+        expect(unused.map(u => `${u.kind}:${u.name}:${u.line}`)).toEqual(['function:helper:7', 'field:label:12', 'method:(*server).reset:20']);
+        expect(unused[0]).toMatchObject({ file: '/work/a.go', column: 6, confidence: 100, source: 'staticcheck' });
+    });
+
+    it('should surface compile errors from staticcheck', () => {
+        const output = JSON.stringify({ code: 'compile', severity: 'error', location: { file: '/work/a.go', line: 3, column: 2 }, message: 'undefined: fmt' });
+        expect(parseStaticcheckUnused(output, '/work').errors).toEqual(['/work/a.go:3: undefined: fmt']);
+    });
+
+    it('should parse deadcode -json and skip generated functions', () => {
+        const output = JSON.stringify([
+            {
+                Name: 'main',
+                Path: 'example.com/app',
+                Funcs: [
+                    { Name: 'unreachable', Position: { File: '/work/main.go', Line: 14, Col: 6 }, Generated: false },
+                    { Name: 'T.String', Position: { File: '/work/t.go', Line: 5, Col: 12 }, Generated: false },
+                    { Name: 'pb.Reset', Position: { File: '/work/x.pb.go', Line: 40, Col: 1 }, Generated: true },
+                ],
+            },
+        ]);
+        const unused = parseDeadcodeJson(output);
+        expect(unused.map(u => `${u.kind}:${u.name}`)).toEqual(['function:unreachable', 'method:T.String']);
+        expect(unused[0]).toMatchObject({ file: '/work/main.go', line: 14, column: 6, message: 'unreachable func: unreachable (example.com/app)' });
+    });
+
+    it('should parse vulture findings with confidence', () => {
+        const output = [
+            "src/app.py:1: unused import 'os' (90% confidence)",
+            "src/app.py:12: unused function 'helper' (60% confidence)",
+            "src/app.py:20: unused class 'Legacy' (60% confidence, 8 lines)",
+            "src/app.py:31: unreachable code after 'return' (100% confidence)",
+        ].join('\n');
+        const unused = parseVultureOutput(output, '/work');
+        expect(unused.map(u => `${u.kind}:${u.name}:${u.confidence}`)).toEqual(['import:os:90', 'function:helper:60', 'class:Legacy:60', 'unreachable::100']);
+        expect(unused[3]).toMatchObject({ file: '/work/src/app.py', line: 31, message: "unreachable code after 'return'", source: 'vulture' });
+    });
+});
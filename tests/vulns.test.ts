@@ -0,0 +1,80 @@
+import { describe, it, expect } from 'vitest';
+import { parseGovulncheckOutput, parseNpmAuditOutput, parsePipAuditOutput, filterBySeverity } from '../src/tools/vulns.js';
+import { registerTools } from '../src/tools';
+
+class DummyServer {
+    tools: any[] = [];
+    registerTool(tool: any) { this.tools.push(tool); }
+}
+
+describe('Vulnerability scanning tools', () => {
+    it('should register the scanners', () => {
+        const server = new DummyServer();
+        registerTools(server);
+        for (const name of ['go_vulncheck', 'npm_audit', 'pip_audit']) {
+            const tool = server.tools.find(t => t.name === name);
+            expect(tool).toBeDefined();
+            expect(tool.inputSchema.properties.projectPath).toBeDefined();
+        }
+    });
+
+    it('should report only called vulnerabilities from govulncheck', () => {
+        const output = [
+            JSON.stringify({ config: { protocol_version: 'v1.0.0' } }, null, 2),
+            JSON.stringify({ osv: { id: 'GO-2023-1571', aliases: ['CVE-2022-41723', 'GHSA-vvpx-j8f3-3w6h'], summary: 'Denial of service in net/http' } }, null, 2),
+            JSON.stringify({ osv: { id: 'GO-2024-0001', aliases: [], summary: 'Imported only' } }, null, 2),
+            JSON.stringify({ finding: { osv: 'GO-2023-1571', fixed_version: 'v0.7.0', trace: [{ module: 'golang.org/x/net', version: 'v0.1.0', package: 'golang.org/x/net/http2', function: 'Read' }] } }, null, 2),
+            JSON.stringify({ finding: { osv: 'GO-2023-1571', fixed_version: 'v0.7.0', trace: [{ module: 'golang.org/x/net', version: 'v0.1.0', package: 'golang.org/x/net/http2', function: 'Write' }] } }, null, 2),
+            JSON.stringify({ finding: { osv: 'GO-2024-0001', trace: [{ module: 'example.com/lib', version: 'v1.0.0' }] } }, null, 2),
+        ].join('\n');
+        const records = parseGovulncheckOutput(output);
+        expect(records).toHaveLength(1);
+        expect(records[0]).toMatchObject({
+            package: 'golang.org/x/net',
+            version: 'v0.1.0',
+            id: 'CVE-2022-41723',
+            fixedVersion: 'v0.7.0',
+            severity: 'unknown',
+            source: 'govulncheck',
+        });
+        expect(records[0]?.aliases).toContain('GO-2023-1571');
+    });
+
+    it('should parse npm audit advisories', () => {
+        const output = JSON.stringify({
+            auditReportVersion: 2,
+            vulnerabilities: {
+                lodash: {
+                    name: 'lodash',
+                    severity: 'high',
+                    via: [{ source: 1096305, name: 'lodash', title: 'Prototype Pollution', url: 'https://github.com/advisories/GHSA-jf85-cpcp-j695', severity: 'high', range: '<4.17.12' }],
+                    fixAvailable: { name: 'lodash', version: '4.17.21', isSemVerMajor: false },
+                },
+                'some-wrapper': { name: 'some-wrapper', severity: 'high', via: ['lodash'], fixAvailable: true },
+                minimist: {
+                    name: 'minimist',
+                    severity: 'moderate',
+                    via: [{ source: 1, name: 'minimist', title: 'Prototype Pollution', url: 'https://github.com/advisories/GHSA-xvch-5gv4-984h', severity: 'moderate' }],
+                    fixAvailable: false,
+                },
+            },
+        });
+        const records = parseNpmAuditOutput(output);
+        expect(records).toHaveLength(2);
+        expect(records[0]).toMatchObject({ package: 'lodash', id: 'GHSA-jf85-cpcp-j695', severity: 'high', fixedVersion: '4.17.21' });
+        expect(records[1]).toMatchObject({ package: 'minimist', severity: 'medium', fixedVersion: null });
+        expect(filterBySeverity(records, 'high')).toHaveLength(1);
+    });
+
+    it('should parse pip-audit output in both formats', () => {
+        const dependencies = [
+            { name: 'requests', version: '2.19.0', vulns: [{ id: 'PYSEC-2018-28', fix_versions: ['2.20.0'], aliases: ['CVE-2018-18074', 'GHSA-x84v-xcm2-53pg'], description: 'Credentials leak on redirect' }] },
+            { name: 'flask', version: '3.0.0', vulns: [] },
+        ];
+        for (const output of [JSON.stringify({ dependencies, fixes: [] }), JSON.stringify(dependencies)]) {
+            const records = parsePipAuditOutput(output);
+            expect(records).toHaveLength(1);
+            expect(records[0]).toMatchObject({ package: 'requests', version: '2.19.0', id: 'CVE-2018-18074', fixedVersion: '2.20.0', source: 'pip-audit' });
+        }
+    });
+});
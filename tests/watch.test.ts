@@ -0,0 +1,128 @@
+import { describe, it, expect, beforeEach, afterEach } from 'vitest';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { join } from 'path';
+import type { AddressInfo } from 'net';
+import { WatchManager, WatchSession, type WatchRun } from '../src/watch/index.js';
+import { createIgnoreFilter } from '../src/utils/gitignore.js';
+import { parseCliArgs } from '../src/cli.js';
+import { serveWatchEvents } from '../src/batch.js';
+
+const verdict = { passed: true, steps: { total: 1, passed: 1, failed: 0, skipped: 0 }, failedSteps: [], severity: { error: 0, warning: 0, info: 0 }, blocking: [], moreBlocking: 0, summary: 'PASS: 1 step passed' };
+
+async function waitFor(condition: () => boolean, timeoutMs = 3000): Promise<void> {
+    const deadline = Date.now() + timeoutMs;
+    while (!condition()) {
+        if (Date.now() > deadline) throw new Error('Timed out waiting for condition');
+        await new Promise(resolve => setTimeout(resolve, 20));
+    }
+}
+
+describe('watch mode', () => {
+    let root: string;
+    let sessions: WatchSession[];
+
+    beforeEach(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-watch-'));
+        await fs.writeFile(join(root, '.gitignore'), 'build/\n*.log\n');
+        await fs.writeFile(join(root, 'main.go'), 'package main\n');
+        sessions = [];
+    });
+
+    afterEach(async () => {
+        for (const session of sessions) session.stop();
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    async function startSession(runs: WatchRun[]): Promise<WatchSession> {
+        const session = new WatchSession('watch-test', { root, pipeline: 'default', debounceMs: 100, run: async () => ({ verdict }) }, run => runs.push(run));
+        sessions.push(session);
+        await session.start();
+        await waitFor(() => runs.length === 1);
+        await session.idle();
+        return session;
+    }
+
+    it('should apply gitignore rules and skip VCS directories', async () => {
+        await fs.mkdir(join(root, 'src'));
+        await fs.writeFile(join(root, 'src', '.gitignore'), 'gen.go\n');
+        const ignored = await createIgnoreFilter(root);
+        expect(await ignored(join(root, 'main.go'), false)).toBe(false);
+        expect(await ignored(join(root, 'debug.log'), false)).toBe(true);
+        expect(await ignored(join(root, 'build', 'out', 'app'), false)).toBe(true);
+        expect(await ignored(join(root, 'src', 'gen.go'), false)).toBe(true);
+        expect(await ignored(join(root, 'src', 'main.go'), false)).toBe(false);
+        expect(await ignored(join(root, '.git', 'HEAD'), false)).toBe(true);
+    });
+
+    it('should run once on start and again after a debounced change', async () => {
+        const runs: WatchRun[] = [];
+        const session = await startSession(runs);
+        expect(runs[0]).toMatchObject({ watchId: 'watch-test', runNumber: 1, trigger: 'start', changedFiles: [] });
+
+        await fs.writeFile(join(root, 'main.go'), 'package main\n\nfunc main() {}\n');
+        await fs.writeFile(join(root, 'util.go'), 'package main\n');
+        await waitFor(() => runs.length === 2);
+        await session.idle();
+        expect(runs[1]).toMatchObject({ runNumber: 2, trigger: 'change', changedFiles: ['main.go', 'util.go'] });
+        expect(session.latest?.runNumber).toBe(2);
+        expect(session.status()).toMatchObject({ runs: 2, running: false, pending: [] });
+    });
+
+    it('should ignore gitignored files and unchanged content', async () => {
+        const runs: WatchRun[] = [];
+        const session = await startSession(runs);
+        await fs.writeFile(join(root, 'debug.log'), 'noise\n');
+        await fs.mkdir(join(root, 'build'));
+        await fs.writeFile(join(root, 'build', 'app'), 'binary\n');
+        await new Promise(resolve => setTimeout(resolve, 300));
+        await session.idle();
+        expect(runs).toHaveLength(1);
+
+        await fs.writeFile(join(root, 'main.go'), 'package main\n\n');
+        await waitFor(() => runs.length === 2);
+        await session.idle();
+        // Writing the same content again is not a change
+        await fs.writeFile(join(root, 'main.go'), 'package main\n\n');
+        await new Promise(resolve => setTimeout(resolve, 300));
+        await session.idle();
+        expect(runs).toHaveLength(2);
+    });
+
+    it('should share sessions per root and pipeline and notify subscribers', async () => {
+        const manager = new WatchManager();
+        const heard: WatchRun[] = [];
+        const unsubscribe = manager.subscribe(run => heard.push(run));
+        try {
+            const options = { root, pipeline: 'default', debounceMs: 100, run: async () => ({ verdict }) };
+            const first = await manager.start(options);
+            expect((await manager.start(options)).id).toBe(first.id);
+            expect(manager.list()).toHaveLength(1);
+            expect(first.id).toBe('watch-1');
+            await waitFor(() => heard.length === 1);
+
+            const events = await serveWatchEvents('127.0.0.1:0', manager);
+            try {
+                const { port } = events.address() as AddressInfo;
+                const latest: any = await (await fetch(`http://127.0.0.1:${port}/latest`)).json();
+                expect(latest.runs.map((r: any) => r.watchId)).toEqual(['watch-1']);
+                expect((await fetch(`http://127.0.0.1:${port}/other`)).status).toBe(404);
+            } finally {
+                events.closeAllConnections();
+                await new Promise(resolve => events.close(resolve));
+            }
+
+            expect(manager.stop('watch-1')).toBe(true);
+            expect(manager.list()).toEqual([]);
+        } finally {
+            unsubscribe();
+            manager.stopAll();
+        }
+    });
+
+    it('should parse the watch command', () => {
+        expect(parseCliArgs(['watch'])).toEqual({ command: 'watch', path: '.', pipeline: 'default' });
+        expect(parseCliArgs(['watch', 'quick', '--path', 'src', '--debounce=200', '--http', ':7070'])).toEqual({ command: 'watch', path: 'src', pipeline: 'quick', debounceMs: 200, http: ':7070' });
+        expect(() => parseCliArgs(['watch', '--debounce', '10'])).toThrow('Invalid --debounce 10');
+    });
+});
@@ -0,0 +1,208 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { tmpdir } from 'os';
+import { join } from 'path';
+import { createHmac } from 'crypto';
+import { execSync } from 'child_process';
+import type { AddressInfo } from 'net';
+import { handleWebhook, loadWebhooks, parseWebhookEvent, verifyWebhook, waitForRuns, type WebhooksFile } from '../src/webhooks/index.js';
+import { getRunResultTool } from '../src/tools/runs.js';
+import { createBaselineTool } from '../src/tools/baseline.js';
+import Config from '../src/config/index.js';
+import { startHttpServer } from '../src/transport/http.js';
+
+const SECRET = 'a-long-webhook-secret';
+
+function signed(payload: unknown) {
+    const body = Buffer.from(JSON.stringify(payload));
+    return { body, signature: `sha256=${createHmac('sha256', SECRET).update(body).digest('hex')}` };
+}
+
+describe('webhook runs', () => {
+    let root: string;
+    let source: string;
+    let sha: string;
+    const savedRunsDir = process.env.MCP_RUNS_DIR;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-webhooks-'));
+        process.env.MCP_RUNS_DIR = join(root, 'runs');
+        source = join(root, 'source');
+        await fs.mkdir(source);
+        execSync('git init -q -b main && git config user.email t@example.com && git config user.name t', { cwd: source });
+        await fs.writeFile(join(source, 'main.txt'), 'hello\n');
+        execSync('git add -A && git commit -qm init', { cwd: source });
+        sha = execSync('git rev-parse HEAD', { cwd: source }).toString().trim();
+    });
+
+    afterAll(async () => {
+        if (savedRunsDir === undefined) delete process.env.MCP_RUNS_DIR;
+        else process.env.MCP_RUNS_DIR = savedRunsDir;
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should validate the webhooks file', async () => {
+        expect(await loadWebhooks(join(root, 'missing.yaml'))).toEqual({ repositories: [] });
+        const path = join(root, 'webhooks.yaml');
+        await fs.writeFile(path, `repositories:\n  - { repository: acme/widgets, secret: ${SECRET} }\n  - { repository: ACME/widgets, secret: ${SECRET} }\n`);
+        await expect(loadWebhooks(path)).rejects.toThrow('duplicate repository github:acme/widgets');
+        await fs.writeFile(path, 'repositories:\n  - { repository: acme/widgets, secret: short }\n');
+        await expect(loadWebhooks(path)).rejects.toThrow('repositories.0.secret');
+    });
+
+    it('should verify GitHub signatures and GitLab tokens', () => {
+        const { body, signature } = signed({ a: 1 });
+        expect(verifyWebhook('github', SECRET, { 'x-hub-signature-256': signature }, body)).toBe(true);
+        expect(verifyWebhook('github', SECRET, { 'x-hub-signature-256': signature }, Buffer.from('{"a":2}'))).toBe(false);
+        expect(verifyWebhook('github', SECRET, {}, body)).toBe(false);
+        expect(verifyWebhook('gitlab', SECRET, { 'x-gitlab-token': SECRET }, body)).toBe(true);
+        expect(verifyWebhook('gitlab', SECRET, { 'x-gitlab-token': 'wrong' }, body)).toBe(false);
+    });
+
+    it('should parse push and pull request events', () => {
+        const repository = { full_name: 'acme/widgets', clone_url: 'https://github.com/acme/widgets.git' };
+        expect(parseWebhookEvent('github', 'push', { ref: 'refs/heads/ai/fix', after: 'abc', repository })).toEqual({
+            kind: 'event',
+            event: { provider: 'github', event: 'push', repository: 'acme/widgets', ref: 'ai/fix', fetchRef: 'refs/heads/ai/fix', sha: 'abc', cloneUrl: repository.clone_url },
+        });
+        expect(parseWebhookEvent('github', 'push', { ref: 'refs/tags/v1', repository }).kind).toBe('ignored');
+        expect(parseWebhookEvent('github', 'push', { ref: 'refs/heads/x', deleted: true, repository }).kind).toBe('ignored');
+        const pr = parseWebhookEvent('github', 'pull_request', { action: 'synchronize', repository, pull_request: { number: 4, head: { ref: 'ai/fix', sha: 'def' } } });
+        expect(pr).toMatchObject({ kind: 'event', event: { event: 'pull_request', pullNumber: 4, fetchRef: 'refs/pull/4/head', sha: 'def' } });
+        expect(parseWebhookEvent('github', 'pull_request', { action: 'labeled', repository }).kind).toBe('ignored');
+        expect(parseWebhookEvent('github', 'ping', {})).toEqual({ kind: 'ping' });
+
+        const project = { path_with_namespace: 'group/sub/cli', git_http_url: 'https://gitlab.com/group/sub/cli.git' };
+        expect(parseWebhookEvent('gitlab', 'Push Hook', { ref: 'refs/heads/main', checkout_sha: '123', project })).toMatchObject({ kind: 'event', event: { repository: 'group/sub/cli', sha: '123' } });
+        const mr = { iid: 9, action: 'update', oldrev: 'old', source_branch: 'ai/x', last_commit: { id: '456' } };
+        expect(parseWebhookEvent('gitlab', 'Merge Request Hook', { object_attributes: mr, project })).toMatchObject({ kind: 'event', event: { pullNumber: 9, fetchRef: 'refs/merge-requests/9/head', sha: '456' } });
+        expect(parseWebhookEvent('gitlab', 'Merge Request Hook', { object_attributes: { ...mr, oldrev: undefined }, project }).kind).toBe('ignored');
+    });
+
+    it('should run the pipeline for a signed push and store the result', async () => {
+        const file: WebhooksFile = {
+            repositories: [{
+                repository: 'acme/widgets', provider: 'github', secret: SECRET, events: ['push', 'pull_request'], branches: ['main'],
+                pipeline: 'default', publishReview: false,
+                steps: [{ name: 'exists', command: 'test -f main.txt' }, { name: 'missing', command: 'test -f other.txt' }],
+            }],
+        };
+        const payload = { ref: 'refs/heads/main', after: sha, repository: { full_name: 'acme/widgets', clone_url: source } };
+        const { body, signature } = signed(payload);
+
+        const rejected = await handleWebhook(file, 'github', { 'x-github-event': 'push', 'x-hub-signature-256': 'sha256=00' }, body);
+        expect(rejected.status).toBe(401);
+        const other = signed({ ...payload, ref: 'refs/heads/dev' });
+        const ignored = await handleWebhook(file, 'github', { 'x-github-event': 'push', 'x-hub-signature-256': other.signature }, other.body);
+        expect(ignored.body.ignored).toBe('branch dev is not listed');
+
+        const queued = await handleWebhook(file, 'github', { 'x-github-event': 'push', 'x-hub-signature-256': signature }, body);
+        expect(queued.status).toBe(202);
+        const runId = queued.body.runId as string;
+        await waitForRuns();
+
+        const result: any = await getRunResultTool.run({ runId });
+        expect(result.success).toBe(true);
+        expect(result.run).toMatchObject({ status: 'failed', repository: 'acme/widgets', ref: 'main', sha, summary: { total: 2, passed: 1, failed: 1, skipped: 0 } });
+        expect(result.run.steps.map((s: any) => s.status)).toEqual(['passed', 'failed']);
+        expect(result.run.verdict.failedSteps).toEqual(['missing']);
+
+        const brief: any = await getRunResultTool.run({ runId, detail: 'summary' });
+        expect(brief.run.steps).toEqual([{ name: 'exists', status: 'passed', durationMs: brief.run.steps[0].durationMs, diagnosticCount: 0 }, { name: 'missing', status: 'failed', durationMs: brief.run.steps[1].durationMs, diagnosticCount: 0 }]);
+        expect(brief.run.diagnostics).toBeUndefined();
+        const step: any = await getRunResultTool.run({ runId, step: 'missing' });
+        expect(step.step.errors[0]).toContain('Exited with code 1');
+
+        const listed: any = await getRunResultTool.run({ repository: 'acme/widgets', status: 'failed' });
+        expect(listed.runs.map((r: any) => r.id)).toContain(runId);
+        expect(listed.runs[0].steps).toBeUndefined();
+        expect((await getRunResultTool.run({ runId: 'nope' })).errors).toEqual(['Run not found: nope']);
+    });
+
+    it('should take a pull request\'s steps from its target branch', async () => {
+        await fs.writeFile(join(source, '.code-feedback.yaml'), 'pipelines:\n  default:\n    - { name: base, command: "test -f main.txt" }\n');
+        execSync('git add -A && git commit -qm config', { cwd: source });
+        // The head rewrites the pipeline, as a fork could
+        execSync('git checkout -q -b fork', { cwd: source });
+        await fs.writeFile(join(source, '.code-feedback.yaml'), `pipelines:\n  default:\n    - { name: pwned, command: "touch ${join(root, 'pwned')}" }\n`);
+        execSync('git add -A && git commit -qm head && git update-ref refs/pull/7/head HEAD && git checkout -q main', { cwd: source });
+        const head = execSync('git rev-parse refs/pull/7/head', { cwd: source }).toString().trim();
+
+        const file: WebhooksFile = {
+            repositories: [{ repository: 'acme/widgets', provider: 'github', secret: SECRET, events: ['pull_request'], pipeline: 'default', publishReview: false }],
+        };
+        const { body, signature } = signed({
+            action: 'synchronize',
+            repository: { full_name: 'acme/widgets', clone_url: source },
+            pull_request: { number: 7, head: { ref: 'fork', sha: head }, base: { ref: 'main' } },
+        });
+        const allowed = Config.getInstance().getAllowedPaths();
+        const queued = await handleWebhook(file, 'github', { 'x-github-event': 'pull_request', 'x-hub-signature-256': signature }, body);
+        await waitForRuns();
+
+        const result: any = await getRunResultTool.run({ runId: queued.body.runId });
+        expect(result.run).toMatchObject({ status: 'passed', sha: head });
+        expect(result.run.steps.map((s: any) => s.name)).toEqual(['base']);
+        await expect(fs.access(join(root, 'pwned'))).rejects.toThrow();
+        expect(Config.getInstance().getAllowedPaths()).toEqual(allowed);
+    });
+
+    it('should take a pull request\'s baseline from its target branch', async () => {
+        const config = 'rules:\n  - { id: no-todo, pattern: "TODO", files: ["**/*.ts"], message: Resolve the TODO, severity: error }\npipelines:\n  lint:\n    - tool: check_rules\n      args: { path: . }\n';
+        await fs.writeFile(join(source, '.code-feedback.yaml'), config);
+        await fs.writeFile(join(source, 'a.ts'), '// TODO old\n');
+        Config.getInstance().addAllowedPaths([source]);
+        try {
+            expect((await createBaselineTool.run({ path: source, pipeline: 'lint' }) as any).entries).toBe(1);
+            execSync('git add -A && git commit -qm baseline', { cwd: source });
+            // The head adds a finding and baselines it too
+            execSync('git checkout -q -b rebaseline', { cwd: source });
+            await fs.writeFile(join(source, 'b.ts'), '// TODO new\n');
+            expect((await createBaselineTool.run({ path: source, pipeline: 'lint' }) as any).entries).toBe(2);
+        } finally {
+            Config.getInstance().removeAllowedPaths([source]);
+        }
+        execSync('git add -A && git commit -qm head && git update-ref refs/pull/8/head HEAD && git checkout -q main', { cwd: source });
+        const head = execSync('git rev-parse refs/pull/8/head', { cwd: source }).toString().trim();
+
+        const file: WebhooksFile = {
+            repositories: [{ repository: 'acme/widgets', provider: 'github', secret: SECRET, events: ['pull_request'], pipeline: 'lint', publishReview: false }],
+        };
+        const { body, signature } = signed({
+            action: 'synchronize',
+            repository: { full_name: 'acme/widgets', clone_url: source },
+            pull_request: { number: 8, head: { ref: 'rebaseline', sha: head }, base: { ref: 'main' } },
+        });
+        const queued = await handleWebhook(file, 'github', { 'x-github-event': 'pull_request', 'x-hub-signature-256': signature }, body);
+        await waitForRuns();
+
+        const result: any = await getRunResultTool.run({ runId: queued.body.runId });
+        expect(result.run).toMatchObject({ status: 'failed', sha: head });
+        expect(result.run.diagnostics).toHaveLength(1);
+        expect(result.run.diagnostics[0].file).toMatch(/b\.ts$/);
+    });
+
+    it('should serve webhooks without the bearer token', async () => {
+        const calls: string[] = [];
+        const server = await startHttpServer({
+            host: '127.0.0.1',
+            port: 0,
+            token: 'server-token',
+            createMcpServer: () => { throw new Error('not used'); },
+            webhooks: async (provider, headers) => {
+                calls.push(`${provider} ${headers['x-github-event']}`);
+                return { status: 202, body: { ignored: 'test' } };
+            },
+        });
+        try {
+            const { port } = server.address() as AddressInfo;
+            const hook = await fetch(`http://127.0.0.1:${port}/webhooks/github`, { method: 'POST', headers: { 'X-GitHub-Event': 'push' }, body: '{}' });
+            expect(hook.status).toBe(202);
+            expect(calls).toEqual(['github push']);
+            const mcp = await fetch(`http://127.0.0.1:${port}/mcp`, { method: 'POST', body: '{}' });
+            expect(mcp.status).toBe(401);
+        } finally {
+            await new Promise(resolve => server.close(resolve));
+        }
+    });
+});
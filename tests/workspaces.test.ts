@@ -0,0 +1,96 @@
+import { describe, it, expect, beforeAll, afterAll } from 'vitest';
+import { promises as fs } from 'fs';
+import { join } from 'path';
+import { execSync } from 'child_process';
+import { tmpdir } from 'os';
+import Config from '../src/config/index.js';
+import { WorkspaceRegistry } from '../src/workspaces/index.js';
+import { registerWorkspaceTool, listWorkspacesTool, cloneWorkspaceTool } from '../src/tools/workspaces.js';
+import { collectExpiredClones, parseCloneUrl } from '../src/workspaces/clone.js';
+
+describe('Workspace registry', () => {
+    let root: string;
+
+    beforeAll(async () => {
+        root = await fs.mkdtemp(join(tmpdir(), 'cf-workspaces-'));
+        process.env.MCP_WORKSPACES_FILE = join(root, 'state', 'workspaces.json');
+        process.env.MCP_CLONES_DIR = join(root, 'clones');
+        await fs.mkdir(join(root, 'api', 'internal'), { recursive: true });
+        await fs.mkdir(join(root, 'web'));
+        Config.getInstance().addAllowedPaths([join(root, 'api'), join(root, 'web')]);
+    });
+
+    afterAll(async () => {
+        delete process.env.MCP_WORKSPACES_FILE;
+        delete process.env.MCP_CLONES_DIR;
+        await fs.rm(root, { recursive: true, force: true });
+    });
+
+    it('should register roots under unique ids and persist them', async () => {
+        const registry = new WorkspaceRegistry();
+        const api = await registry.register(join(root, 'api'));
+        expect(api.id).toBe('api');
+        expect((await registry.register(join(root, 'web'), { id: 'frontend', name: 'Web app' })).id).toBe('frontend');
+        expect(await registry.register(join(root, 'api'))).toEqual(api);
+        await expect(registry.register(join(root, 'web'), { id: 'api' })).rejects.toThrow('already registered');
+        await expect(registry.register(root)).rejects.toThrow('Path not allowed');
+
+        const reloaded = new WorkspaceRegistry();
+        expect((await reloaded.list()).map(w => `${w.id}=${w.root}`)).toEqual([`api=${join(root, 'api')}`, `frontend=${join(root, 'web')}`]);
+        expect(await reloaded.unregister('frontend')).toBe(true);
+        expect((await new WorkspaceRegistry().list()).map(w => w.id)).toEqual(['api']);
+    });
+
+    it('should resolve tool arguments against the workspace root', async () => {
+        const registry = new WorkspaceRegistry();
+        const properties = { projectPath: {}, timeout: {} };
+        expect(await registry.resolveArgs({ workspace: 'api', timeout: 5 }, properties)).toEqual({ projectPath: join(root, 'api'), timeout: 5 });
+        expect(await registry.resolveArgs({ workspace: 'api', projectPath: 'internal' }, properties)).toEqual({ projectPath: join(root, 'api', 'internal') });
+        await expect(registry.resolveArgs({ workspace: 'api', projectPath: '../web' }, properties)).rejects.toThrow('outside workspace api');
+        await expect(registry.resolveArgs({ workspace: 'missing' }, properties)).rejects.toThrow('Unknown workspace missing');
+        const plain = { projectPath: '/abs' };
+        expect(await registry.resolveArgs(plain, properties)).toBe(plain);
+    });
+
+    it('should register and list through the tools', async () => {
+        const result: any = await registerWorkspaceTool.run({ path: join(root, 'web'), id: 'web' });
+        expect(result.success).toBe(true);
+        const listed: any = await listWorkspacesTool.run();
+        expect(listed.workspaces.map((w: any) => w.id)).toContain('web');
+    });
+
+    it('should clone a remote into an expiring workspace', async () => {
+        const upstream = join(root, 'api', 'upstream');
+        await fs.mkdir(upstream);
+        execSync('git init -q -b main && git config user.email t@example.com && git config user.name t', { cwd: upstream });
+        for (const n of [1, 2]) {
+            await fs.writeFile(join(upstream, 'README.md'), `v${n}\n`);
+            execSync(`git add -A && git commit -qm v${n}`, { cwd: upstream });
+        }
+        execSync('git branch release', { cwd: upstream });
+
+        const result: any = await cloneWorkspaceTool.run({ url: `file://${upstream}`, branch: 'release', depth: 1, ttlMinutes: 5 });
+        expect(result.success).toBe(true);
+        expect(result.workspace).toMatchObject({ id: 'upstream', remote: `file://${upstream}` });
+        expect(result.branch).toBe('release');
+        expect(result.workspace.root.startsWith(join(root, 'clones'))).toBe(true);
+        expect(execSync('git rev-list --count HEAD', { cwd: result.workspace.root }).toString().trim()).toBe('1');
+        expect(Config.getInstance().isPathAllowed(join(result.workspace.root, 'README.md'))).toBe(true);
+
+        expect(await collectExpiredClones()).toEqual([]);
+        expect(await collectExpiredClones(Date.now() + 10 * 60 * 1000)).toEqual(['upstream']);
+        await expect(fs.stat(result.workspace.root)).rejects.toThrow();
+        expect((await listWorkspacesTool.run() as any).workspaces.map((w: any) => w.id)).not.toContain('upstream');
+    });
+
+    it('should refuse unsafe or unreachable remotes', async () => {
+        expect(parseCloneUrl('git@github.com:acme/widgets.git')).toEqual({ url: 'git@github.com:acme/widgets.git', host: 'github.com', local: false });
+        expect(() => parseCloneUrl('ext::sh -c touch% /tmp/x')).toThrow('Unsupported remote URL');
+        expect(() => parseCloneUrl('--upload-pack=touch /tmp/x')).toThrow('Invalid remote URL');
+        expect(() => parseCloneUrl(tmpdir())).toThrow('Path not allowed');
+        const result: any = await cloneWorkspaceTool.run({ url: join(root, 'api', 'missing') });
+        expect(result.success).toBe(false);
+        expect(result.errors[0]).toContain('git clone failed');
+        expect(await fs.readdir(join(root, 'clones'))).toEqual([]);
+    });
+});